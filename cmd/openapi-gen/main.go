@@ -0,0 +1,24 @@
+// Command openapi-gen writes the OpenAPI document for the JSON API. It is
+// run by go generate in internal/handlers.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"injection-tracker/internal/handlers"
+)
+
+func main() {
+	out := flag.String("o", "openapi.json", "File to write the document to")
+	flag.Parse()
+
+	spec, err := handlers.GenerateOpenAPI()
+	if err != nil {
+		log.Fatalf("Failed to generate OpenAPI document: %v", err)
+	}
+	if err := os.WriteFile(*out, spec, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+}
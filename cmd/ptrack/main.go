@@ -0,0 +1,13 @@
+// Command ptrack runs the injection tracker server and the commands for
+// managing an instance. See "ptrack help".
+package main
+
+import (
+	"os"
+
+	"injection-tracker/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(os.Args[1:]))
+}
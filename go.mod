@@ -5,12 +5,26 @@ go 1.24.0
 toolchain go1.24.11
 
 require (
+	filippo.io/age v1.0.0
+	github.com/BurntSushi/toml v1.4.0
 	github.com/go-chi/chi/v5 v5.0.11
 	github.com/go-chi/cors v1.2.1
 	github.com/golang-jwt/jwt/v5 v5.2.2
+	github.com/jackc/pgx/v5 v5.7.5
+	github.com/jackc/pgx/v5 v5.7.5
 	github.com/jung-kurt/gofpdf/v2 v2.17.3
 	github.com/mattn/go-sqlite3 v1.14.19
-	golang.org/x/crypto v0.18.0
+	golang.org/x/crypto v0.37.0
+	golang.org/x/term v0.31.0
 	golang.org/x/text v0.31.0
-	golang.org/x/time v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/sync v0.18.0 // indirect
+	golang.org/x/sys v0.32.0 // indirect
 )
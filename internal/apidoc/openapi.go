@@ -0,0 +1,383 @@
+// Package apidoc builds an OpenAPI 3 description of the JSON API from a
+// table of routes and the Go types their handlers read and write.
+package apidoc
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/respond"
+)
+
+// Route documents one API operation
+type Route struct {
+	Method  string
+	Path    string // chi pattern, e.g. /api/courses/{id}
+	Tag     string
+	Summary string
+	Query   []Param
+	Headers []Param
+
+	// Request is a value of the type decoded from the body, or nil when the
+	// operation takes no body. RequestType overrides application/json, e.g.
+	// for form or multipart uploads.
+	Request     interface{}
+	RequestType string
+
+	// Response is a value of the type encoded on success, or nil when the
+	// body has no fixed shape. ResponseType overrides application/json for
+	// files, HTML fragments and the like.
+	Response     interface{}
+	ResponseType string
+	Status       int // Success status; defaults to 200
+
+	Public bool // Needs no login
+	Admin  bool // Needs the site admin
+}
+
+// Param is a query string parameter
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Info is the document's title block
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// Generate returns the OpenAPI document for routes as indented JSON. The
+// output is stable for the same input so it can be checked in.
+func Generate(info Info, routes []Route) ([]byte, error) {
+	g := &generator{schemas: map[string]interface{}{}, names: map[reflect.Type]string{}}
+
+	paths := map[string]map[string]interface{}{}
+	for _, route := range routes {
+		method := strings.ToLower(route.Method)
+		if _, ok := paths[route.Path]; !ok {
+			paths[route.Path] = map[string]interface{}{}
+		}
+		if _, dup := paths[route.Path][method]; dup {
+			return nil, fmt.Errorf("%s %s documented twice", route.Method, route.Path)
+		}
+		paths[route.Path][method] = g.operation(route)
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       info.Title,
+			"version":     info.Version,
+			"description": info.Description,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"cookieAuth": map[string]interface{}{"type": "apiKey", "in": "cookie", "name": "auth_token"},
+				"csrfToken": map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "X-CSRF-Token",
+					"description": "Required on every request that is not GET, HEAD or OPTIONS; fetch one from GET /api/v1/csrf-token",
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type generator struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+func (g *generator) operation(route Route) map[string]interface{} {
+	op := map[string]interface{}{
+		"summary": route.Summary,
+	}
+	if route.Tag != "" {
+		op["tags"] = []string{route.Tag}
+	}
+
+	var params []interface{}
+	for _, m := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		params = append(params, map[string]interface{}{
+			"name": m[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, p := range route.Query {
+		params = append(params, parameter(p, "query"))
+	}
+	for _, p := range route.Headers {
+		params = append(params, parameter(p, "header"))
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if route.Request != nil || route.RequestType != "" {
+		contentType := route.RequestType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		schema := map[string]interface{}{}
+		if route.Request != nil {
+			schema = g.schema(reflect.TypeOf(route.Request))
+		}
+		op["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{contentType: map[string]interface{}{"schema": schema}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if status != http.StatusNoContent && (status < 300 || status >= 400) {
+		contentType := route.ResponseType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		schema := map[string]interface{}{}
+		if route.Response != nil {
+			schema = g.schema(reflect.TypeOf(route.Response))
+		} else if contentType != "application/json" && !strings.HasPrefix(contentType, "text/") {
+			schema = map[string]interface{}{"type": "string", "format": "binary"}
+		} else if contentType != "application/json" {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		success["content"] = map[string]interface{}{contentType: map[string]interface{}{"schema": schema}}
+	}
+	op["responses"] = map[string]interface{}{
+		fmt.Sprint(status): success,
+		"default": map[string]interface{}{
+			"description": "Error",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": g.schema(reflect.TypeOf(respond.ErrorResponse{}))},
+			},
+		},
+	}
+
+	switch {
+	case route.Public:
+		op["security"] = []interface{}{}
+	case route.Method != http.MethodGet && route.Method != http.MethodHead:
+		op["security"] = []interface{}{
+			map[string][]string{"bearerAuth": {}, "csrfToken": {}},
+			map[string][]string{"cookieAuth": {}, "csrfToken": {}},
+		}
+	default:
+		op["security"] = []interface{}{
+			map[string][]string{"bearerAuth": {}},
+			map[string][]string{"cookieAuth": {}},
+		}
+	}
+	if route.Admin {
+		op["description"] = "Site admin only."
+	}
+	return op
+}
+
+func parameter(p Param, in string) map[string]interface{} {
+	param := map[string]interface{}{
+		"name": p.Name, "in": in, "schema": map[string]interface{}{"type": "string"},
+	}
+	if p.Description != "" {
+		param["description"] = p.Description
+	}
+	if p.Required {
+		param["required"] = true
+	}
+	return param
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schema describes how encoding/json writes values of type t. Named structs
+// go into components and are referenced.
+func (g *generator) schema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		s := g.schema(t.Elem())
+		if _, isRef := s["$ref"]; isRef {
+			// Siblings of $ref are ignored in 3.0, so wrap it
+			return map[string]interface{}{"allOf": []interface{}{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalerType), reflect.PtrTo(t).Implements(jsonUnmarshalerType),
+		t.Implements(textMarshalerType):
+		// Custom encodings are all strings in this API
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s := map[string]interface{}{"type": "integer"}
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64 {
+			s["format"] = "int64"
+		}
+		return s
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + g.register(t)}
+	}
+	// interface{} and anything else: any value
+	return map[string]interface{}{}
+}
+
+// register adds a named struct to the components, returning its name
+func (g *generator) register(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := schemaName(t.Name())
+	for other := range g.names {
+		if g.names[other] == name {
+			// Same name in another package; qualify this one
+			name = pkgName(t) + name
+			break
+		}
+	}
+	g.names[t] = name
+	g.schemas[name] = map[string]interface{}{} // Placeholder so recursive types terminate
+	g.schemas[name] = g.structSchema(t)
+	return name
+}
+
+// schemaName turns an instantiated generic type's name, such as
+// Page[*example.com/pkg.Item], into an identifier like PageItem
+func schemaName(name string) string {
+	base, args, ok := strings.Cut(name, "[")
+	if !ok {
+		return name
+	}
+	args = strings.TrimSuffix(args, "]")
+	for _, arg := range strings.Split(args, ",") {
+		if i := strings.LastIndexAny(arg, "./*"); i >= 0 {
+			arg = arg[i+1:]
+		}
+		base += strings.TrimSpace(arg)
+	}
+	return base
+}
+
+func pkgName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" {
+		return ""
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:]
+}
+
+func (g *generator) structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	g.addFields(t, props)
+	s := map[string]interface{}{"type": "object"}
+	if len(props) > 0 {
+		s["properties"] = props
+	}
+	return s
+}
+
+// addFields follows encoding/json's rules: exported fields only, json tag
+// names, and untagged embedded structs flattened into the parent
+func (g *generator) addFields(t reflect.Type, props map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(ft, props)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue // Unexported
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		if strings.Contains(","+opts+",", ",string,") {
+			props[name] = map[string]interface{}{"type": "string"}
+			continue
+		}
+		props[name] = g.schema(f.Type)
+	}
+}
+
+// Paths lists "METHOD path" for each route, sorted, for comparing against
+// the router
+func Paths(routes []Route) []string {
+	out := make([]string, 0, len(routes))
+	for _, r := range routes {
+		out = append(out, r.Method+" "+r.Path)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,105 @@
+package apidoc
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type testBase struct {
+	ID int64 `json:"id"`
+}
+
+type testItem struct {
+	testBase
+	Name     string         `json:"name"`
+	Note     *string        `json:"note,omitempty"`
+	Count    int            `json:"count,string"`
+	When     time.Time      `json:"when"`
+	Optional sql.NullString // No tag: encoded as-is under the field name
+	Tags     []string       `json:"tags"`
+	Child    *testItem      `json:"child,omitempty"`
+	Secret   string         `json:"-"`
+	hidden   bool
+}
+
+func TestGenerate(t *testing.T) {
+	routes := []Route{
+		{Method: "GET", Path: "/api/items/{id}", Tag: "Items", Summary: "Get an item", Response: testItem{}},
+		{Method: "POST", Path: "/api/items", Summary: "Create an item", Request: testItem{}, Response: testItem{}, Status: 201},
+		{Method: "DELETE", Path: "/api/items/{id}", Summary: "Delete an item", Headers: []Param{{Name: "If-Match"}}, Status: 204},
+		{Method: "GET", Path: "/api/ping", Summary: "Ping", Public: true},
+	}
+	out, err := Generate(Info{Title: "Test", Version: "1"}, routes)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	again, _ := Generate(Info{Title: "Test", Version: "1"}, routes)
+	if string(out) != string(again) {
+		t.Error("Expected the same output for the same routes")
+	}
+
+	var doc struct {
+		Paths      map[string]map[string]map[string]interface{}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{}
+			}
+		}
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("Output is not JSON: %v", err)
+	}
+
+	props := doc.Components.Schemas["testItem"].Properties
+	for _, name := range []string{"id", "name", "note", "count", "when", "Optional", "tags", "child"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("Expected property %q, got %v", name, props)
+		}
+	}
+	for _, name := range []string{"Secret", "hidden", "testBase"} {
+		if _, ok := props[name]; ok {
+			t.Errorf("Did not expect property %q", name)
+		}
+	}
+	if props["when"]["format"] != "date-time" || props["count"]["type"] != "string" || props["note"]["nullable"] != true {
+		t.Errorf("Unexpected property schemas: %v", props)
+	}
+	if props["Optional"]["$ref"] != "#/components/schemas/NullString" {
+		t.Errorf("Expected sql.NullString as a referenced object, got %v", props["Optional"])
+	}
+
+	get := doc.Paths["/api/items/{id}"]["get"]
+	if params, _ := get["parameters"].([]interface{}); len(params) != 1 {
+		t.Errorf("Expected the id path parameter, got %v", get["parameters"])
+	}
+	del := doc.Paths["/api/items/{id}"]["delete"]
+	if params, _ := del["parameters"].([]interface{}); len(params) != 2 || params[1].(map[string]interface{})["in"] != "header" {
+		t.Errorf("Expected the id path parameter and a header, got %v", del["parameters"])
+	}
+	if _, ok := del["responses"].(map[string]interface{})["204"]; !ok {
+		t.Error("Expected a 204 response for delete")
+	}
+	if security, _ := doc.Paths["/api/ping"]["get"]["security"].([]interface{}); len(security) != 0 {
+		t.Errorf("Expected no security on a public route, got %v", security)
+	}
+
+	if _, err := Generate(Info{}, append(routes, routes[0])); err == nil {
+		t.Error("Expected an error for a route documented twice")
+	}
+}
+
+func TestSchemaName(t *testing.T) {
+	tests := map[string]string{
+		"Injection": "Injection",
+		"ListResponse[injection-tracker/internal/models.Injection]":      "ListResponseInjection",
+		"ListResponse[*injection-tracker/internal/models.MedicationLog]": "ListResponseMedicationLog",
+		"Pair[string,example.com/pkg.Item]":                              "PairstringItem",
+	}
+	for name, want := range tests {
+		if got := schemaName(name); got != want {
+			t.Errorf("schemaName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// ImpersonationDuration is how long the admin can act as another user
+// before having to start again. Impersonation tokens can't be refreshed.
+const ImpersonationDuration = time.Hour
+
+type impersonatorKey struct{}
+
+// WithImpersonator marks ctx as a request made by an admin impersonating
+// its user. It is kept apart from the rest of the user context so code that
+// only sees the context, such as the audit log, can attribute what is done
+// to both of them.
+func WithImpersonator(ctx context.Context, impersonatorID int64) context.Context {
+	if impersonatorID == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, impersonatorKey{}, impersonatorID)
+}
+
+// Impersonator returns the admin impersonating the request's user, or 0
+func Impersonator(ctx context.Context) int64 {
+	if ctx == nil {
+		return 0
+	}
+	id, _ := ctx.Value(impersonatorKey{}).(int64)
+	return id
+}
@@ -10,6 +10,9 @@ import (
 var (
 	ErrInvalidToken = errors.New("invalid token")
 	ErrExpiredToken = errors.New("expired token")
+	// ErrImpersonationToken is returned when refreshing an impersonation
+	// token, which ends when it expires
+	ErrImpersonationToken = errors.New("impersonation tokens can't be refreshed")
 )
 
 type Claims struct {
@@ -17,14 +20,25 @@ type Claims struct {
 	Username  string `json:"username"`
 	AccountID int64  `json:"account_id"` // Account the user belongs to
 	Role      string `json:"role"`       // 'owner' or 'member'
+	// SessionID names the session_tokens row the token belongs to, so the
+	// session can be revoked. Tokens issued before sessions were tracked
+	// have none.
+	SessionID string `json:"sid,omitempty"`
+	// ImpersonatorID is the admin signed in as the user, for tokens issued
+	// by impersonation
+	ImpersonatorID int64 `json:"imp,omitempty"`
 	jwt.RegisteredClaims
 }
 
 type JWTManager struct {
 	secret          []byte
 	sessionDuration time.Duration
+	idleTimeout     time.Duration
+	deviceTrust     time.Duration
 }
 
+// NewJWTManager signs tokens for sessions that last sessionDuration from
+// sign-in. Without an idle timeout, tokens last that long too.
 func NewJWTManager(secret string, sessionDuration time.Duration) *JWTManager {
 	return &JWTManager{
 		secret:          []byte(secret),
@@ -32,19 +46,57 @@ func NewJWTManager(secret string, sessionDuration time.Duration) *JWTManager {
 	}
 }
 
+// WithIdleTimeout ends sessions that go unused for idleTimeout, 0 for never.
+// Tokens then last only that long and are refreshed while the session is
+// in use.
+func (m *JWTManager) WithIdleTimeout(idleTimeout time.Duration) *JWTManager {
+	m.idleTimeout = idleTimeout
+	return m
+}
+
+// WithDeviceTrust lets users trust the device they sign in on for
+// deviceTrust, 0 for not at all
+func (m *JWTManager) WithDeviceTrust(deviceTrust time.Duration) *JWTManager {
+	m.deviceTrust = deviceTrust
+	return m
+}
+
 // GenerateToken creates a new JWT token for a user
 func (m *JWTManager) GenerateToken(userID int64, username string, accountID int64, role string) (string, error) {
-	now := time.Now()
-	claims := Claims{
+	return m.GenerateSessionToken(userID, username, accountID, role, "")
+}
+
+// GenerateSessionToken creates a new JWT token for a user's session
+func (m *JWTManager) GenerateSessionToken(userID int64, username string, accountID int64, role, sessionID string) (string, error) {
+	return m.sign(Claims{
 		UserID:    userID,
 		Username:  username,
 		AccountID: accountID,
 		Role:      role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(m.sessionDuration)),
-			IssuedAt:  jwt.NewNumericDate(now),
-			NotBefore: jwt.NewNumericDate(now),
-		},
+		SessionID: sessionID,
+	}, m.TokenDuration())
+}
+
+// GenerateImpersonationToken creates a token for impersonatorID to act as a
+// user. It lasts for duration, whatever the session duration, and can't be
+// refreshed.
+func (m *JWTManager) GenerateImpersonationToken(userID int64, username string, accountID int64, role, sessionID string, impersonatorID int64, duration time.Duration) (string, error) {
+	return m.sign(Claims{
+		UserID:         userID,
+		Username:       username,
+		AccountID:      accountID,
+		Role:           role,
+		SessionID:      sessionID,
+		ImpersonatorID: impersonatorID,
+	}, duration)
+}
+
+func (m *JWTManager) sign(claims Claims, duration time.Duration) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -76,31 +128,69 @@ func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+// ValidateExpiredToken returns the claims of a token that was issued by us,
+// whether or not it has expired, along with ErrExpiredToken if it has. It is
+// for refreshing tokens; the session decides whether that is still allowed.
+func (m *JWTManager) ValidateExpiredToken(tokenString string) (*Claims, error) {
+	claims, err := m.ValidateToken(tokenString)
+	if !errors.Is(err, ErrExpiredToken) {
+		return claims, err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
+		}
+		return m.secret, nil
+	}, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	return claims, ErrExpiredToken
+}
+
 // RefreshToken generates a new token with extended expiration
 func (m *JWTManager) RefreshToken(tokenString string) (string, error) {
-	claims, err := m.ValidateToken(tokenString)
+	claims, err := m.ValidateExpiredToken(tokenString)
 	if err != nil && !errors.Is(err, ErrExpiredToken) {
 		return "", err
 	}
 
-	// If claims is nil (which can happen with expired tokens), we need to parse without validation
-	if claims == nil {
-		token, _, err := jwt.NewParser().ParseUnverified(tokenString, &Claims{})
-		if err != nil {
-			return "", ErrInvalidToken
-		}
-		var ok bool
-		claims, ok = token.Claims.(*Claims)
-		if !ok {
-			return "", ErrInvalidToken
-		}
+	if claims.ImpersonatorID != 0 {
+		return "", ErrImpersonationToken
 	}
 
 	// Generate new token with same claims but new expiration
-	return m.GenerateToken(claims.UserID, claims.Username, claims.AccountID, claims.Role)
+	return m.GenerateSessionToken(claims.UserID, claims.Username, claims.AccountID, claims.Role, claims.SessionID)
 }
 
-// SessionDuration returns the configured session duration
+// SessionDuration returns how long a session lasts from sign-in, however
+// much it is used
 func (m *JWTManager) SessionDuration() time.Duration {
 	return m.sessionDuration
-}
\ No newline at end of file
+}
+
+// IdleTimeout returns how long a session can go unused, or 0 for no limit
+func (m *JWTManager) IdleTimeout() time.Duration {
+	return m.idleTimeout
+}
+
+// DeviceTrustDuration returns how long a device can be trusted when signing
+// in, or 0 if it can't
+func (m *JWTManager) DeviceTrustDuration() time.Duration {
+	return m.deviceTrust
+}
+
+// TokenDuration returns how long a session's tokens last: the idle timeout
+// when there is a shorter one, so a token taken from an idle device soon
+// stops working
+func (m *JWTManager) TokenDuration() time.Duration {
+	if m.idleTimeout > 0 && m.idleTimeout < m.sessionDuration {
+		return m.idleTimeout
+	}
+	return m.sessionDuration
+}
@@ -253,6 +253,48 @@ func TestRefreshToken(t *testing.T) {
 	}
 }
 
+func TestRefreshTokenKeepsSession(t *testing.T) {
+	manager := NewJWTManager("test-secret", 2*time.Hour)
+
+	token, err := manager.GenerateSessionToken(1, "testuser", 1, "owner", "session-1")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	newToken, err := manager.RefreshToken(token)
+	if err != nil {
+		t.Fatalf("Failed to refresh token: %v", err)
+	}
+	claims, err := manager.ValidateToken(newToken)
+	if err != nil {
+		t.Fatalf("Failed to validate refreshed token: %v", err)
+	}
+	if claims.SessionID != "session-1" {
+		t.Errorf("Expected the refreshed token to keep session-1, got %q", claims.SessionID)
+	}
+}
+
+func TestImpersonationToken(t *testing.T) {
+	manager := NewJWTManager("test-secret", 24*time.Hour)
+
+	token, err := manager.GenerateImpersonationToken(2, "patient", 1, "owner", "session-1", 1, ImpersonationDuration)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	claims, err := manager.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	if claims.UserID != 2 || claims.ImpersonatorID != 1 {
+		t.Errorf("Expected user 2 impersonated by 1, got %d by %d", claims.UserID, claims.ImpersonatorID)
+	}
+	if left := time.Until(claims.ExpiresAt.Time); left > ImpersonationDuration {
+		t.Errorf("Expected the token to last at most %v, got %v", ImpersonationDuration, left)
+	}
+	if _, err := manager.RefreshToken(token); err != ErrImpersonationToken {
+		t.Errorf("Expected ErrImpersonationToken refreshing, got %v", err)
+	}
+}
+
 func TestRefreshTokenExpired(t *testing.T) {
 	// Create manager with very short duration
 	manager := NewJWTManager("test-secret", 1*time.Millisecond)
@@ -320,6 +362,57 @@ func TestSessionDuration(t *testing.T) {
 	}
 }
 
+func TestIdleTimeoutShortensTokens(t *testing.T) {
+	manager := NewJWTManager("test-secret", 336*time.Hour)
+	if manager.TokenDuration() != 336*time.Hour {
+		t.Errorf("Without an idle timeout tokens should last the session, got %v", manager.TokenDuration())
+	}
+
+	manager.WithIdleTimeout(72 * time.Hour)
+	if manager.IdleTimeout() != 72*time.Hour || manager.TokenDuration() != 72*time.Hour {
+		t.Errorf("Expected tokens to last the idle timeout, got %v", manager.TokenDuration())
+	}
+	token, err := manager.GenerateSessionToken(1, "user", 1, "owner", "session-1")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	claims, err := manager.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	if left := time.Until(claims.ExpiresAt.Time); left > 72*time.Hour {
+		t.Errorf("Expected the token to last at most 72h, got %v", left)
+	}
+
+	// An idle timeout longer than the session doesn't make tokens outlast it
+	manager.WithIdleTimeout(500 * time.Hour)
+	if manager.TokenDuration() != 336*time.Hour {
+		t.Errorf("Expected tokens capped at the session lifetime, got %v", manager.TokenDuration())
+	}
+}
+
+func TestValidateExpiredToken(t *testing.T) {
+	manager := NewJWTManager("test-secret", time.Millisecond)
+	token, err := manager.GenerateSessionToken(7, "user", 1, "owner", "session-1")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	claims, err := manager.ValidateExpiredToken(token)
+	if err != ErrExpiredToken {
+		t.Fatalf("Expected ErrExpiredToken, got %v", err)
+	}
+	if claims == nil || claims.UserID != 7 || claims.SessionID != "session-1" {
+		t.Errorf("Expected the expired token's claims, got %+v", claims)
+	}
+
+	other := NewJWTManager("other-secret", time.Hour)
+	if _, err := other.ValidateExpiredToken(token); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for another secret, got %v", err)
+	}
+}
+
 func TestTokenClaims(t *testing.T) {
 	manager := NewJWTManager("test-secret", 2*time.Hour)
 
@@ -482,4 +575,4 @@ func TestConcurrentTokenOperations(t *testing.T) {
 			<-done
 		}
 	})
-}
\ No newline at end of file
+}
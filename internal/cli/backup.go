@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/handlers"
+	"injection-tracker/internal/services"
+
+	"golang.org/x/term"
+)
+
+const backupUsage = `Usage: ptrack backup [-o file] [-upload]
+
+Writes a backup of the database to data/backups, where the admin page lists
+it, or to -o. With -upload it is also copied to the remote destination set
+in the auto-backup settings. Safe to run while the server is running.
+`
+
+const restoreUsage = `Usage: ptrack restore [-yes] [-remote] [-passphrase-file file] <file>
+
+Replaces the database with a backup, after backing up the current one to
+data/backups. With -remote, file names a backup at the remote destination
+set in the auto-backup settings, which is downloaded first. PostgreSQL is
+restored immediately. A SQLite restore is applied the next time the server
+starts, so restart it afterwards. Asks for confirmation unless -yes is
+given.
+
+Compressed (.gz) and encrypted (.age) backups are decoded with the
+configured backup passphrase or key file. A backup encrypted with another
+passphrase needs -passphrase-file, or the passphrase typed at the prompt.
+`
+
+// runBackup handles "ptrack backup" and returns the exit code
+func runBackup(env *environment, args []string) int {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(env.stderr, backupUsage) }
+	output := flags.String("o", "", "write the backup to this file")
+	upload := flags.Bool("upload", false, "copy the backup to the remote destination")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	db, err := env.openDB()
+	if err != nil {
+		return env.fail(err)
+	}
+	defer db.Close()
+
+	path := *output
+	if path != "" {
+		if err := db.Backup(path); err != nil {
+			return env.fail(fmt.Errorf("failed to create backup: %w", err))
+		}
+		fmt.Fprintf(env.stdout, "Backed up to %s\n", path)
+	} else {
+		backup, err := handlers.CreateBackup(db, "manual")
+		if err != nil {
+			return env.fail(err)
+		}
+		path = backup.Path
+		fmt.Fprintf(env.stdout, "Backed up to %s (%s)\n", backup.Path, backup.SizeHuman)
+	}
+
+	if *upload {
+		dest, err := handlers.OpenBackupDestination(db)
+		if err != nil {
+			return env.fail(err)
+		}
+		if err := services.UploadBackup(context.Background(), dest, path); err != nil {
+			return env.fail(err)
+		}
+		fmt.Fprintln(env.stdout, "Uploaded and verified")
+	}
+	return 0
+}
+
+// runRestore handles "ptrack restore <file>" and returns the exit code
+func runRestore(env *environment, args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(env.stderr, restoreUsage) }
+	yes := flags.Bool("yes", false, "don't ask for confirmation")
+	remote := flags.Bool("remote", false, "restore a backup from the remote destination")
+	passphraseFile := flags.String("passphrase-file", "", "read the backup passphrase from this file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	path := flags.Arg(0)
+
+	db, err := env.openDB()
+	if err != nil {
+		return env.fail(err)
+	}
+	defer db.Close()
+
+	passphrase := ""
+	if *passphraseFile != "" {
+		data, err := os.ReadFile(*passphraseFile)
+		if err != nil {
+			return env.fail(fmt.Errorf("failed to read passphrase: %w", err))
+		}
+		passphrase = strings.TrimRight(string(data), "\r\n")
+	}
+
+	if *remote {
+		fmt.Fprintf(env.stdout, "Downloading %s\n", path)
+		fetched, err := handlers.FetchRemoteBackup(db, path, passphrase)
+		if services.IsBackupKeyError(err) && passphrase == "" {
+			if passphrase, err = env.readBackupPassphrase(); err == nil {
+				fetched, err = handlers.FetchRemoteBackup(db, path, passphrase)
+			}
+		}
+		if err != nil {
+			return env.fail(err)
+		}
+		path = fetched
+	}
+
+	plainPath, cleanup, err := handlers.OpenBackup(db, path, passphrase)
+	if services.IsBackupKeyError(err) && passphrase == "" {
+		if passphrase, err = env.readBackupPassphrase(); err == nil {
+			plainPath, cleanup, err = handlers.OpenBackup(db, path, passphrase)
+		}
+	}
+	if err != nil {
+		return env.fail(fmt.Errorf("failed to read %s: %w", path, err))
+	}
+	defer cleanup()
+	if err := db.ValidateBackup(plainPath); err != nil {
+		return env.fail(fmt.Errorf("%s is not a usable backup: %w", path, err))
+	}
+	if !*yes {
+		ok, err := env.confirm(fmt.Sprintf("Replace all data with %s?", path))
+		if err != nil {
+			return env.fail(err)
+		}
+		if !ok {
+			fmt.Fprintln(env.stdout, "Restore cancelled")
+			return 1
+		}
+	}
+
+	before, err := handlers.CreateBackup(db, "pre_restore")
+	if err != nil {
+		return env.fail(fmt.Errorf("failed to back up the current database: %w", err))
+	}
+	fmt.Fprintf(env.stdout, "Backed up the current database to %s\n", before.Path)
+
+	if db.Dialect.Name() == database.Postgres {
+		if err := db.Restore(plainPath); err != nil {
+			return env.fail(fmt.Errorf("failed to restore backup: %w", err))
+		}
+		fmt.Fprintf(env.stdout, "Restored %s\n", path)
+		return 0
+	}
+
+	if err := handlers.StageRestore(plainPath); err != nil {
+		return env.fail(fmt.Errorf("failed to prepare restore: %w", err))
+	}
+	fmt.Fprintf(env.stdout, "Restore of %s is ready and is applied when the server next starts; restart it now\n", path)
+	return 0
+}
+
+// readBackupPassphrase asks for the passphrase of an encrypted backup,
+// which only makes sense at a terminal
+func (env *environment) readBackupPassphrase() (string, error) {
+	f, ok := env.stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return "", errors.New("backup is encrypted with a passphrase other than the configured one; pass -passphrase-file")
+	}
+	fmt.Fprint(env.stderr, "Backup passphrase: ")
+	passphrase, err := term.ReadPassword(int(f.Fd()))
+	fmt.Fprintln(env.stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
+
+// confirm asks a yes/no question on stdin
+func (env *environment) confirm(question string) (bool, error) {
+	fmt.Fprintf(env.stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(env.stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, errors.New("no answer on stdin; pass -yes to skip confirmation")
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
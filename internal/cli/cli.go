@@ -0,0 +1,149 @@
+// Package cli implements the ptrack command: the server itself ("serve")
+// and the commands operators use to manage an instance from a shell
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"injection-tracker/internal/config"
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/logging"
+	"injection-tracker/internal/secrets"
+)
+
+const usage = `Usage: ptrack [-config file] <command> [arguments]
+
+Commands:
+  serve                      run the server (the default)
+  migrate <command>          show, apply or roll back schema migrations
+  integrity [-repair]        check the database for corruption and orphans
+  create-admin               create the administrator on a new instance
+  reset-password <user>      set a user's password and unlock their login
+  backup [-o file]           write a database backup
+  restore [-yes] <file>      restore the database from a backup
+  export -account <id>       write an account's data as JSON
+  doctor                     check the configuration and environment
+
+Settings come from the environment, .env and the -config file (or
+CONFIG_FILE), as for the server. Run "ptrack <command> -h" for a command's
+flags.
+`
+
+// command runs a subcommand with its arguments and returns the exit code
+type command func(env *environment, args []string) int
+
+var commands = map[string]command{
+	"serve":          runServe,
+	"migrate":        runMigrate,
+	"integrity":      runIntegrity,
+	"create-admin":   runCreateAdmin,
+	"reset-password": runResetPassword,
+	"backup":         runBackup,
+	"restore":        runRestore,
+	"export":         runExport,
+	"doctor":         runDoctor,
+}
+
+// environment is what every command starts with
+type environment struct {
+	cfg    *config.Config
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// envErr is why .env couldn't be read, if it couldn't
+	envErr error
+}
+
+// Main runs ptrack with the arguments after the program name and returns
+// the exit code
+func Main(args []string) int {
+	flags := flag.NewFlagSet("ptrack", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	configFile := flags.String("config", "", "YAML or TOML config file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	args = flags.Args()
+
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		fmt.Fprint(os.Stdout, usage)
+		return 0
+	}
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", name, usage)
+		return 2
+	}
+
+	env := &environment{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	env.envErr = config.LoadDotEnv(".env")
+	if *configFile != "" {
+		os.Setenv("CONFIG_FILE", *configFile)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+	env.cfg = cfg
+
+	// Every command reads the database through the same repositories, so
+	// they all need the key to the secrets stored in it
+	if cfg.Security.SecretsKey != "" {
+		keyring, err := secrets.NewKeyring(cfg.Security.SecretsKey, cfg.Security.SecretsPreviousKeys)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+			return 1
+		}
+		secrets.Use(keyring)
+	}
+
+	// Everything after this logs through slog, including the log package
+	logger, err := logging.New(os.Stderr, cfg.Logging.Level, cfg.Logging.Format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to configure logging: %v\n", err)
+		return 1
+	}
+	slog.SetDefault(logger)
+
+	return run(env, args)
+}
+
+// openDB opens the configured database. It isn't migrated.
+func (env *environment) openDB() (*database.DB, error) {
+	db, err := database.Open(env.cfg.Database.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetQueryTimeout(env.cfg.Database.QueryTimeout)
+	return db, nil
+}
+
+// openMigratedDB opens the database for commands that read or change data,
+// which need its schema to be current
+func (env *environment) openMigratedDB() (*database.DB, error) {
+	db, err := env.openDB()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.RunMigrations(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	return db, nil
+}
+
+// fail reports err and returns the exit code for a failed command
+func (env *environment) fail(err error) int {
+	fmt.Fprintf(env.stderr, "Error: %v\n", err)
+	return 1
+}
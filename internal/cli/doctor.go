@@ -0,0 +1,284 @@
+package cli
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/handlers"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/secrets"
+)
+
+const doctorUsage = `Usage: ptrack doctor
+
+Checks the configuration, database, files and ports the server needs and
+prints what it found. Exits 1 if anything would stop the server from
+working.
+`
+
+// Past these a backup or certificate is worth a warning
+const (
+	staleBackupAge = 7 * 24 * time.Hour
+	certExpiryWarn = 14 * 24 * time.Hour
+)
+
+// checkup collects the results of doctor's checks
+type checkup struct {
+	env    *environment
+	failed bool
+}
+
+func (c *checkup) ok(format string, args ...interface{}) {
+	fmt.Fprintf(c.env.stdout, "ok    %s\n", fmt.Sprintf(format, args...))
+}
+
+func (c *checkup) warn(format string, args ...interface{}) {
+	fmt.Fprintf(c.env.stdout, "warn  %s\n", fmt.Sprintf(format, args...))
+}
+
+func (c *checkup) fail(format string, args ...interface{}) {
+	fmt.Fprintf(c.env.stdout, "FAIL  %s\n", fmt.Sprintf(format, args...))
+	c.failed = true
+}
+
+// runDoctor handles "ptrack doctor" and returns the exit code. It only
+// reads: the database isn't migrated and nothing is repaired.
+func runDoctor(env *environment, args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(env.stderr, doctorUsage) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	c := &checkup{env: env}
+	c.checkConfig()
+	c.checkDataDir()
+	c.checkDatabase()
+	c.checkAssets()
+	c.checkBackups()
+	c.checkTLS()
+	c.checkPorts()
+
+	if c.failed {
+		fmt.Fprintln(env.stdout, "\nProblems were found.")
+		return 1
+	}
+	fmt.Fprintln(env.stdout, "\nNo problems found. Run \"ptrack integrity\" to check the data itself.")
+	return 0
+}
+
+func (c *checkup) checkConfig() {
+	cfg := c.env.cfg
+	if cfg.File != "" {
+		c.ok("Configuration is valid (config file %s)", cfg.File)
+	} else {
+		c.ok("Configuration is valid")
+	}
+	if c.env.envErr != nil && !os.IsNotExist(c.env.envErr) {
+		c.warn(".env couldn't be read: %v", c.env.envErr)
+	}
+	if len(cfg.Security.JWTSecret) < 32 {
+		c.warn("JWT_SECRET is only %d characters; use at least 32", len(cfg.Security.JWTSecret))
+	}
+	if cfg.Security.SecretsKey == "" {
+		c.warn("SECRETS_KEY is not set, so secrets stored in the database are unencrypted; generate one with: openssl rand -base64 32")
+	}
+	if cfg.Server.Environment == "production" && !cfg.TLS.Enabled() && len(cfg.Security.TrustedProxies) == 0 {
+		c.warn("Running in production without TLS or a trusted proxy in front; logins travel unencrypted")
+	}
+}
+
+func (c *checkup) checkDataDir() {
+	if err := os.MkdirAll("data", 0755); err != nil {
+		c.fail("Data directory can't be created: %v", err)
+		return
+	}
+	f, err := os.CreateTemp("data", ".doctor-*")
+	if err != nil {
+		c.fail("Data directory isn't writable: %v", err)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	c.ok("Data directory is writable")
+}
+
+func (c *checkup) checkDatabase() {
+	cfg := c.env.cfg
+	db, err := c.env.openDB()
+	if err != nil {
+		c.fail("Database: %v", err)
+		return
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		c.fail("Database isn't reachable: %v", err)
+		return
+	}
+	c.ok("Database opens (%s)", db.Dialect.Name())
+
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		c.fail("Migration status unavailable: %v", err)
+		return
+	}
+	var pending, unknown int
+	for _, s := range statuses {
+		switch {
+		case s.Unknown:
+			unknown++
+		case !s.Applied:
+			pending++
+		}
+	}
+	switch {
+	case unknown > 0:
+		c.fail("Database has %d migration(s) from a newer version; the server won't start", unknown)
+	case pending > 0:
+		c.warn("%d migration(s) pending; they run when the server starts, or with \"ptrack migrate up\"", pending)
+	default:
+		c.ok("Schema is up to date")
+		c.checkSecrets(db)
+	}
+
+	if db.Dialect.Name() == database.Postgres {
+		for _, tool := range []string{"pg_dump", "pg_restore"} {
+			if _, err := exec.LookPath(tool); err != nil {
+				c.fail("%s isn't on PATH; backups and restores need it", tool)
+			}
+		}
+	}
+
+	if path, ok := handlers.PendingRestore(); ok {
+		if _, isSQLite := database.SQLitePath(cfg.Database.DSN()); isSQLite {
+			c.warn("A restore of %s is waiting for the server to restart", path)
+		}
+	}
+}
+
+// checkSecrets checks the secrets stored in the database can be decrypted
+// and are all encrypted
+func (c *checkup) checkSecrets(db *database.DB) {
+	keyring := secrets.Active()
+	key, err := secrets.CurrentDataKey(db)
+	if err != nil {
+		c.fail("Stored secrets can't be checked: %v", err)
+		return
+	}
+	counts, err := repository.NewSecretRepository(db).Count()
+	if err != nil {
+		c.fail("Stored secrets can't be checked: %v", err)
+		return
+	}
+	switch {
+	case keyring == nil && counts.Encrypted > 0:
+		c.fail("%d stored secret(s) are encrypted but SECRETS_KEY isn't set", counts.Encrypted)
+	case keyring == nil:
+		// Already warned about with the configuration
+	case key != nil && !keyring.HasMasterKey(key.MasterKeyID):
+		c.fail("Stored secrets are under master key %s, which isn't configured; add it to SECRETS_PREVIOUS_KEYS", key.MasterKeyID)
+	case key != nil && key.MasterKeyID != keyring.MasterKeyID():
+		c.warn("Stored secrets are still under master key %s; rotate them from the admin page to finish changing SECRETS_KEY", key.MasterKeyID)
+	case counts.Unencrypted > 0:
+		c.warn("%d secret(s) were stored before SECRETS_KEY was set; rotate them from the admin page to encrypt them", counts.Unencrypted)
+	default:
+		c.ok("Stored secrets are encrypted (master key %s)", keyring.MasterKeyID())
+	}
+}
+
+func (c *checkup) checkAssets() {
+	for _, dir := range []string{"templates", "static"} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			c.fail("%s directory is missing from %s; run ptrack from the install directory", dir, workingDir())
+			continue
+		}
+		c.ok("%s directory present", dir)
+	}
+}
+
+func (c *checkup) checkBackups() {
+	entries, err := os.ReadDir(filepath.Join("data", "backups"))
+	if err != nil && !os.IsNotExist(err) {
+		c.warn("Backups directory can't be read: %v", err)
+		return
+	}
+	var newest time.Time
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, "pending_restore") || strings.HasPrefix(name, "restore_staging") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	switch {
+	case newest.IsZero():
+		c.warn("No backups yet; run \"ptrack backup\" or turn on auto-backup")
+	case time.Since(newest) > staleBackupAge:
+		c.warn("Newest backup is from %s", newest.Format("2006-01-02"))
+	default:
+		c.ok("Newest backup is from %s", newest.Format("2006-01-02 15:04"))
+	}
+}
+
+func (c *checkup) checkTLS() {
+	cfg := c.env.cfg.TLS
+	if cfg.CertFile == "" {
+		if len(cfg.ACMEDomains) > 0 {
+			c.ok("TLS certificates come from ACME for %s", strings.Join(cfg.ACMEDomains, ", "))
+		}
+		return
+	}
+	pair, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		c.fail("TLS certificate can't be loaded: %v", err)
+		return
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		c.fail("TLS certificate can't be parsed: %v", err)
+		return
+	}
+	switch left := time.Until(leaf.NotAfter); {
+	case left <= 0:
+		c.fail("TLS certificate expired on %s", leaf.NotAfter.Format("2006-01-02"))
+	case left < certExpiryWarn:
+		c.warn("TLS certificate expires on %s", leaf.NotAfter.Format("2006-01-02"))
+	default:
+		c.ok("TLS certificate is valid until %s", leaf.NotAfter.Format("2006-01-02"))
+	}
+}
+
+func (c *checkup) checkPorts() {
+	ports := []string{c.env.cfg.Server.Port}
+	if c.env.cfg.TLS.Enabled() {
+		ports = append(ports, c.env.cfg.TLS.HTTPPort)
+	}
+	for _, port := range ports {
+		ln, err := net.Listen("tcp", ":"+port)
+		if err != nil {
+			c.warn("Port %s is in use (fine if the server is running)", port)
+			continue
+		}
+		ln.Close()
+		c.ok("Port %s is free", port)
+	}
+}
+
+func workingDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "the working directory"
+	}
+	return dir
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"injection-tracker/internal/handlers"
+	"injection-tracker/internal/repository"
+)
+
+const exportUsage = `Usage: ptrack export -account <id> [-o file]
+
+Writes everything in an account as JSON, in the format the account page
+downloads and imports, to stdout or -o. The settings are the account
+owner's.
+`
+
+// runExport handles "ptrack export" and returns the exit code
+func runExport(env *environment, args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(env.stderr, exportUsage) }
+	accountID := flags.Int64("account", 0, "the account to export")
+	output := flags.String("o", "", "write the export to this file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *accountID <= 0 || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	db, err := env.openMigratedDB()
+	if err != nil {
+		return env.fail(err)
+	}
+	defer db.Close()
+
+	data, err := handlers.ExportAccountData(db, *accountID)
+	if err != nil {
+		return env.fail(err)
+	}
+
+	var out io.Writer = env.stdout
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return env.fail(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return env.fail(fmt.Errorf("failed to write export: %w", err))
+	}
+
+	_ = repository.NewAuditRepository(db).LogWithDetails(
+		sql.NullInt64{},
+		"export",
+		"account",
+		sql.NullInt64{Int64: *accountID, Valid: true},
+		map[string]interface{}{"format": "json", "via": "cli"},
+		"",
+		"",
+	)
+
+	if *output != "" {
+		fmt.Fprintf(env.stderr, "Exported account %d to %s\n", *accountID, *output)
+	}
+	return 0
+}
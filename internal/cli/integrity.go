@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"injection-tracker/internal/database"
+)
+
+const integrityUsage = `Usage: ptrack integrity [-repair]
+
+Checks the database: the engine's own integrity check (SQLite only), rows
+that reference missing rows, and inventory items whose quantity differs from
+the sum of their history. With -repair, orphaned rows are deleted or
+unlinked and inventory history is brought in line with stock, all in one
+transaction. Exits 1 if problems were found and not repaired.
+`
+
+// runIntegrity handles "ptrack integrity ..." and returns the exit
+// code
+func runIntegrity(env *environment, args []string) int {
+	out := env.stdout
+	flags := flag.NewFlagSet("integrity", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(env.stderr, integrityUsage) }
+	repair := flags.Bool("repair", false, "fix what can be fixed")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	db, err := env.openDB()
+	if err != nil {
+		return env.fail(err)
+	}
+	defer db.Close()
+
+	report, err := db.CheckIntegrity(*repair)
+	if err != nil && !errors.Is(err, database.ErrCorrupt) {
+		fmt.Fprintf(env.stderr, "Integrity check failed: %v\n", err)
+		return 1
+	}
+	printIntegrityReport(out, report)
+	if err != nil {
+		fmt.Fprintf(env.stderr, "Not repaired: %v\n", err)
+		return 1
+	}
+	if report.Repaired || report.OK() {
+		return 0
+	}
+	return 1
+}
+
+func printIntegrityReport(out io.Writer, report *database.IntegrityReport) {
+	switch {
+	case !report.EngineChecked:
+		fmt.Fprintln(out, "Engine check: not available for this database")
+	case len(report.EngineProblems) == 0:
+		fmt.Fprintln(out, "Engine check: ok")
+	default:
+		fmt.Fprintf(out, "Engine check: %d problem(s)\n", len(report.EngineProblems))
+		for _, p := range report.EngineProblems {
+			fmt.Fprintf(out, "  %s\n", p)
+		}
+	}
+
+	if len(report.Orphans) == 0 {
+		fmt.Fprintln(out, "Orphaned rows: none")
+	}
+	for _, o := range report.Orphans {
+		ids := make([]string, len(o.IDs))
+		for i, id := range o.IDs {
+			ids[i] = fmt.Sprint(id)
+		}
+		fmt.Fprintf(out, "Orphaned rows: %d in %s with a missing %s (%s): %s\n",
+			o.Count, o.Table, o.Parent, o.Repair, strings.Join(ids, ", "))
+	}
+
+	if len(report.Inventory) == 0 {
+		fmt.Fprintln(out, "Inventory: matches history")
+	}
+	for _, m := range report.Inventory {
+		fmt.Fprintf(out, "Inventory: %s has %g but its history adds up to %g\n", m.ItemType, m.Quantity, m.HistoryTotal)
+	}
+
+	if report.Repaired {
+		fmt.Fprintln(out, "Repaired.")
+	}
+}
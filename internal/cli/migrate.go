@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"injection-tracker/internal/database"
+)
+
+const migrateUsage = `Usage: ptrack migrate <command> [flags]
+
+Commands:
+  status               list migrations and whether they have been applied
+  up [-dry-run]        apply pending migrations
+  down [-steps N] [-dry-run]
+                       roll back the newest N applied migrations (default 1)
+
+-dry-run runs the migrations in a transaction that is rolled back, so
+errors show up without changing the database.
+`
+
+// runMigrate handles "ptrack migrate ..." and returns the exit code
+func runMigrate(env *environment, args []string) int {
+	out := env.stdout
+	if len(args) == 0 {
+		fmt.Fprint(env.stderr, migrateUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "roll back instead of committing")
+	steps := flags.Int("steps", 1, "number of migrations to roll back")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	// Not migrated: that's this command's job
+	db, err := env.openDB()
+	if err != nil {
+		return env.fail(err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			fmt.Fprintf(env.stderr, "Failed to get migration status: %v\n", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT\tDOWN")
+		for _, s := range statuses {
+			status, appliedAt, down := "pending", "", "no"
+			if s.Applied {
+				status = "applied"
+			}
+			if s.Unknown {
+				status = "UNKNOWN"
+			}
+			if s.AppliedAt.Valid {
+				appliedAt = s.AppliedAt.Time.Format("2006-01-02 15:04:05")
+			}
+			if s.Reversible {
+				down = "yes"
+			}
+			fmt.Fprintf(tw, "%03d\t%s\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt, down)
+		}
+		tw.Flush()
+		return 0
+
+	case "up":
+		applied, err := db.Migrate(*dryRun)
+		if err != nil {
+			fmt.Fprintf(env.stderr, "Migration failed: %v\n", err)
+			return 1
+		}
+		report(out, "apply", applied, *dryRun)
+		return 0
+
+	case "down":
+		if *steps < 1 {
+			fmt.Fprintln(env.stderr, "-steps must be at least 1")
+			return 2
+		}
+		undone, err := db.Rollback(*steps, *dryRun)
+		if err != nil {
+			fmt.Fprintf(env.stderr, "Rollback failed: %v\n", err)
+			return 1
+		}
+		report(out, "roll back", undone, *dryRun)
+		return 0
+	}
+
+	fmt.Fprint(env.stderr, migrateUsage)
+	return 2
+}
+
+func report(out io.Writer, verb string, migrations []database.Migration, dryRun bool) {
+	switch {
+	case len(migrations) == 0:
+		fmt.Fprintf(out, "Nothing to %s\n", verb)
+	case dryRun:
+		fmt.Fprintf(out, "Dry run: would %s %d migration(s), and all succeeded:\n", verb, len(migrations))
+		for _, m := range migrations {
+			fmt.Fprintf(out, "  %s\n", m.Name)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/handlers"
+	"injection-tracker/internal/server"
+)
+
+// runServe handles "ptrack serve" and returns the exit code
+func runServe(env *environment, args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(env.stderr, "Usage: ptrack serve\n") }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg := env.cfg
+	if env.envErr != nil {
+		slog.Warn("No .env file loaded", "err", env.envErr)
+	}
+	if cfg.File != "" {
+		slog.Info("Loaded config file", "path", cfg.File)
+	}
+	if cfg.Security.SecretsKey == "" {
+		slog.Warn("SECRETS_KEY is not set; the SMTP password and other secrets are stored unencrypted")
+	}
+
+	// A SQLite restore is swapped in while nothing has the database open.
+	// Only the server does this, so a command run beside it can't pull the
+	// file out from under it.
+	if dbPath, ok := database.SQLitePath(cfg.Database.DSN()); ok {
+		applied, err := handlers.ApplyPendingRestore(dbPath)
+		if err != nil {
+			slog.Error("Failed to apply pending restore", "err", err)
+			return 1
+		}
+		if applied {
+			slog.Info("Restored database from backup", "path", dbPath)
+		}
+	}
+
+	// Migrations refuse to run against a schema from a newer version of the
+	// server
+	db, err := env.openMigratedDB()
+	if err != nil {
+		slog.Error("Failed to start", "err", err)
+		return 1
+	}
+	defer db.Close()
+
+	if err := server.Run(cfg, db); err != nil {
+		slog.Error("Server failed", "err", err)
+		return 1
+	}
+	return 0
+}
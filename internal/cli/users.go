@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"injection-tracker/internal/handlers"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+const createAdminUsage = `Usage: ptrack create-admin -username name [-email address]
+
+Creates the administrator and their account on an instance that has no
+users yet, as first-run setup in the browser would. The password is
+prompted for, or read from the first line of stdin when it isn't a
+terminal.
+`
+
+const resetPasswordUsage = `Usage: ptrack reset-password <username>
+
+Sets a user's password and clears their failed logins and lockout. The
+password is prompted for, or read from the first line of stdin when it
+isn't a terminal.
+`
+
+// runCreateAdmin handles "ptrack create-admin" and returns the exit code
+func runCreateAdmin(env *environment, args []string) int {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(env.stderr, createAdminUsage) }
+	username := flags.String("username", "", "the administrator's username")
+	email := flags.String("email", "", "the administrator's email address")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	name := strings.TrimSpace(*username)
+	if name == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	if len(name) < 3 || len(name) > 50 {
+		return env.fail(errors.New("username must be 3-50 characters"))
+	}
+
+	db, err := env.openMigratedDB()
+	if err != nil {
+		return env.fail(err)
+	}
+	defer db.Close()
+
+	// The administrator is the first user, so there can only be one way in
+	var admin string
+	err = db.QueryRow("SELECT username FROM users ORDER BY id LIMIT 1").Scan(&admin)
+	if err == nil {
+		return env.fail(fmt.Errorf("setup is already complete and %s is the administrator; use reset-password to regain access", admin))
+	}
+	if err != sql.ErrNoRows {
+		return env.fail(fmt.Errorf("failed to check for users: %w", err))
+	}
+
+	hash, err := env.readPassword()
+	if err != nil {
+		return env.fail(err)
+	}
+
+	userRepo := repository.NewUserRepository(db)
+	user := &models.User{
+		Username:     name,
+		PasswordHash: hash,
+		Email:        sql.NullString{String: strings.TrimSpace(*email), Valid: strings.TrimSpace(*email) != ""},
+		IsActive:     true,
+	}
+	if err := userRepo.Create(user); err != nil {
+		return env.fail(err)
+	}
+	accountID, err := repository.NewAccountRepository(db.DB).Create(nil, user.ID)
+	if err != nil {
+		_ = userRepo.Delete(user.ID)
+		return env.fail(fmt.Errorf("failed to create account: %w", err))
+	}
+
+	_ = repository.NewAuditRepository(db).LogWithDetails(
+		sql.NullInt64{Int64: user.ID, Valid: true},
+		"first_run_setup",
+		"user",
+		sql.NullInt64{Int64: user.ID, Valid: true},
+		map[string]interface{}{"username": name, "account_id": accountID, "via": "cli"},
+		"",
+		"",
+	)
+
+	fmt.Fprintf(env.stdout, "Created administrator %s\n", name)
+	return 0
+}
+
+// runResetPassword handles "ptrack reset-password <user>" and returns the
+// exit code
+func runResetPassword(env *environment, args []string) int {
+	flags := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(env.stderr, resetPasswordUsage) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	db, err := env.openMigratedDB()
+	if err != nil {
+		return env.fail(err)
+	}
+	defer db.Close()
+
+	userRepo := repository.NewUserRepository(db)
+	user, err := userRepo.GetByUsername(flags.Arg(0))
+	if errors.Is(err, repository.ErrNotFound) {
+		return env.fail(fmt.Errorf("no user named %s", flags.Arg(0)))
+	}
+	if err != nil {
+		return env.fail(err)
+	}
+
+	hash, err := env.readPassword()
+	if err != nil {
+		return env.fail(err)
+	}
+	if err := userRepo.UpdatePassword(user.ID, hash); err != nil {
+		return env.fail(err)
+	}
+	if err := userRepo.ResetFailedLogins(user.ID); err != nil {
+		return env.fail(err)
+	}
+	// Reset links sent before now would undo this
+	if _, err := db.Exec("DELETE FROM password_reset_tokens WHERE user_id = ?", user.ID); err != nil {
+		return env.fail(fmt.Errorf("failed to clear reset links: %w", err))
+	}
+
+	_ = repository.NewAuditRepository(db).LogWithDetails(
+		sql.NullInt64{},
+		"password_reset",
+		"user",
+		sql.NullInt64{Int64: user.ID, Valid: true},
+		map[string]interface{}{"username": user.Username, "via": "cli"},
+		"",
+		"",
+	)
+
+	fmt.Fprintf(env.stdout, "Password for %s changed\n", user.Username)
+	return 0
+}
+
+// readPassword asks for a new password and returns its hash. On a terminal
+// it's typed twice without echo; otherwise it's the first line of stdin, so
+// scripts never have to put it on the command line.
+func (env *environment) readPassword() (string, error) {
+	var password string
+	if f, ok := env.stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(env.stderr, "New password: ")
+		first, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(env.stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		fmt.Fprint(env.stderr, "Confirm password: ")
+		second, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(env.stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		if string(first) != string(second) {
+			return "", errors.New("passwords do not match")
+		}
+		password = string(first)
+	} else {
+		line, err := bufio.NewReader(env.stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", errors.New("no password on stdin")
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if len(password) < 8 {
+		return "", errors.New("password must be at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), handlers.BcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
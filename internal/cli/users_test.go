@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"injection-tracker/internal/config"
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testEnv(t *testing.T, dbPath, stdin string) (*environment, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	return &environment{
+		cfg:    &config.Config{Database: config.DatabaseConfig{Path: dbPath}},
+		stdin:  strings.NewReader(stdin),
+		stdout: &out,
+		stderr: &out,
+	}, &out
+}
+
+func TestCreateAdminAndResetPassword(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	env, out := testEnv(t, dbPath, "first-password\n")
+	if code := runCreateAdmin(env, []string{"-username", "admin", "-email", "admin@example.com"}); code != 0 {
+		t.Fatalf("create-admin exited %d: %s", code, out)
+	}
+
+	// There's only ever one administrator
+	env, out = testEnv(t, dbPath, "other-password\n")
+	if code := runCreateAdmin(env, []string{"-username", "second"}); code != 1 {
+		t.Fatalf("Second create-admin exited %d, want 1: %s", code, out)
+	}
+	if !strings.Contains(out.String(), "reset-password") {
+		t.Errorf("Expected a hint to use reset-password, got %q", out)
+	}
+
+	env, out = testEnv(t, dbPath, "short\n")
+	if code := runResetPassword(env, []string{"admin"}); code != 1 {
+		t.Fatalf("reset-password with a short password exited %d, want 1", code)
+	}
+
+	env, out = testEnv(t, dbPath, "second-password\n")
+	if code := runResetPassword(env, []string{"ADMIN"}); code != 0 {
+		t.Fatalf("reset-password exited %d: %s", code, out)
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user, err := repository.NewUserRepository(db).GetByUsername("admin")
+	if err != nil {
+		t.Fatalf("Failed to get admin: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("second-password")) != nil {
+		t.Error("Password was not changed")
+	}
+	var members int
+	if err := db.QueryRow("SELECT COUNT(*) FROM account_members WHERE user_id = ? AND role = 'owner'", user.ID).Scan(&members); err != nil {
+		t.Fatalf("Failed to count memberships: %v", err)
+	}
+	if members != 1 {
+		t.Errorf("Admin owns %d accounts, want 1", members)
+	}
+}
+
+func TestResetPasswordUnknownUser(t *testing.T) {
+	env, out := testEnv(t, filepath.Join(t.TempDir(), "test.db"), "some-password\n")
+	if code := runResetPassword(env, []string{"nobody"}); code != 1 {
+		t.Fatalf("reset-password exited %d, want 1", code)
+	}
+	if !strings.Contains(out.String(), "no user named nobody") {
+		t.Errorf("Unexpected output %q", out)
+	}
+}
@@ -1,38 +1,118 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
+	// File is the config file the settings were read from, if any
+	File     string
 	Server   ServerConfig
+	TLS      TLSConfig
 	Database DatabaseConfig
 	Security SecurityConfig
 	SMTP     SMTPConfig
 	Backup   BackupConfig
+	Logging  LoggingConfig
 }
 
 type ServerConfig struct {
 	Port        string
 	Environment string
+	// ShutdownTimeout is how long in-flight requests and background jobs
+	// get to finish when the server stops
+	ShutdownTimeout time.Duration
+}
+
+// TLSConfig turns on HTTPS, with a certificate from files or obtained
+// automatically over ACME (Let's Encrypt) for ACMEDomains
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+
+	ACMEDomains  []string
+	ACMEEmail    string
+	ACMECacheDir string
+	// ACMEDirectoryURL is the ACME server; empty means Let's Encrypt
+	ACMEDirectoryURL string
+
+	// HTTPPort answers ACME challenges and redirects everything else to
+	// HTTPS. Empty turns the plain HTTP listener off.
+	HTTPPort string
+}
+
+// Enabled reports whether the server should serve HTTPS itself
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || len(c.ACMEDomains) > 0
 }
 
 type DatabaseConfig struct {
 	Path string
+	// URL selects the database when set: a postgres:// URL for PostgreSQL,
+	// or sqlite://path for SQLite. Path is used otherwise.
+	URL string
+	// QueryTimeout bounds each statement; 0 means no limit
+	QueryTimeout time.Duration
+}
+
+// DSN is what database.Open should be given
+func (c DatabaseConfig) DSN() string {
+	if c.URL != "" {
+		return c.URL
+	}
+	return c.Path
 }
 
 type SecurityConfig struct {
 	JWTSecret          string
 	CSRFSecret         string
-	SessionDuration    time.Duration
+	// SecretsKey is the master key the secrets stored in the database are
+	// encrypted under; empty leaves them unencrypted, which production
+	// refuses
+	SecretsKey         string
+	// SecretsPreviousKeys are master keys being rotated out, still used to
+	// decrypt until the admin rotates the stored secrets
+	SecretsPreviousKeys []string
+	// CSRFTokenTTL is how long a CSRF token is good for; pages left open
+	// are given new ones before it runs out
+	CSRFTokenTTL       time.Duration
+	// CSRFCookieDomain is the Domain of the CSRF session cookie, empty for
+	// the host the page was loaded from
+	CSRFCookieDomain   string
+	// SessionMaxLifetime is how long a session lasts from sign-in however
+	// much it is used; SESSION_DURATION is its older name
+	SessionMaxLifetime time.Duration
+	// SessionIdleTimeout ends a session not used for this long, 0 for never.
+	// Tokens last this long too and are refreshed while the session is used.
+	SessionIdleTimeout time.Duration
+	// TrustedDeviceDuration is how long a device can be trusted when
+	// signing in, 0 to not offer it. Sessions on a trusted device last that
+	// long and don't time out while idle.
+	TrustedDeviceDuration time.Duration
 	RateLimitRequests  int
 	RateLimitWindow    time.Duration
 	LoginRateLimit     int
 	LoginRateWindow    time.Duration
+	// ExportRateLimit is how many exports a user can run per
+	// ExportRateWindow
+	ExportRateLimit    int
+	ExportRateWindow   time.Duration
+	// RateLimitStore is where rate limit counts are kept: "memory", or
+	// "database" to keep them across restarts and share them between
+	// servers using the same database
+	RateLimitStore     string
 	CSPEnabled         bool
 	HSTSEnabled        bool
+	// CookieSecure is "auto" (Secure when the request came in over HTTPS),
+	// "always" or "never"
+	CookieSecure       string
+	// TrustedProxies are the CIDRs and addresses whose X-Forwarded-For,
+	// X-Real-IP and X-Forwarded-Proto headers are believed
+	TrustedProxies     []string
 }
 
 type SMTPConfig struct {
@@ -50,85 +130,260 @@ type BackupConfig struct {
 	RetentionDays  int
 }
 
-// Load reads configuration from environment variables
-func Load() (*Config, error) {
-	sessionDuration, err := time.ParseDuration(getEnv("SESSION_DURATION", "336h"))
-	if err != nil {
-		sessionDuration = 336 * time.Hour
-	}
+// LoggingConfig controls the structured logger. Level is debug, info, warn
+// or error; Format is text or json.
+type LoggingConfig struct {
+	Level  string
+	Format string
+}
 
-	rateLimitWindow, err := time.ParseDuration(getEnv("RATE_LIMIT_WINDOW", "1m"))
-	if err != nil {
-		rateLimitWindow = 1 * time.Minute
+// Load reads configuration from environment variables and, if CONFIG_FILE
+// names one, a YAML or TOML config file. Every problem found is reported
+// at once, rather than one per restart.
+func Load() (*Config, error) {
+	src := &source{}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		src.file = values
 	}
 
-	loginRateWindow, err := time.ParseDuration(getEnv("LOGIN_RATE_WINDOW", "15m"))
-	if err != nil {
-		loginRateWindow = 15 * time.Minute
+	acmeDomains := splitList(src.get("ACME_DOMAINS", ""))
+	// HTTP-01 challenges always arrive on port 80
+	httpPortDefault := ""
+	if len(acmeDomains) > 0 {
+		httpPortDefault = "80"
 	}
 
-	smtpPort, _ := strconv.Atoi(getEnv("SMTP_PORT", "587"))
-	smtpEnabled, _ := strconv.ParseBool(getEnv("SMTP_ENABLED", "false"))
-	backupEnabled, _ := strconv.ParseBool(getEnv("BACKUP_ENABLED", "true"))
-	backupRetention, _ := strconv.Atoi(getEnv("BACKUP_RETENTION_DAYS", "30"))
-	cspEnabled, _ := strconv.ParseBool(getEnv("CSP_ENABLED", "true"))
-	hstsEnabled, _ := strconv.ParseBool(getEnv("HSTS_ENABLED", "true"))
-	rateLimitReqs, _ := strconv.Atoi(getEnv("RATE_LIMIT_REQUESTS", "100"))
-	loginRateLimit, _ := strconv.Atoi(getEnv("LOGIN_RATE_LIMIT", "5"))
-
 	cfg := &Config{
+		File: os.Getenv("CONFIG_FILE"),
 		Server: ServerConfig{
-			Port:        getEnv("PORT", "8080"),
-			Environment: getEnv("ENVIRONMENT", "development"),
+			Port:            src.get("PORT", "8080"),
+			Environment:     src.get("ENVIRONMENT", "development"),
+			ShutdownTimeout: src.duration("SHUTDOWN_TIMEOUT", "30s"),
+		},
+		TLS: TLSConfig{
+			CertFile:         src.get("TLS_CERT_FILE", ""),
+			KeyFile:          src.get("TLS_KEY_FILE", ""),
+			ACMEDomains:      acmeDomains,
+			ACMEEmail:        src.get("ACME_EMAIL", ""),
+			ACMECacheDir:     src.get("ACME_CACHE_DIR", "./data/acme"),
+			ACMEDirectoryURL: src.get("ACME_DIRECTORY_URL", ""),
+			HTTPPort:         src.get("HTTP_PORT", httpPortDefault),
 		},
 		Database: DatabaseConfig{
-			Path: getEnv("DATABASE_PATH", "./data/tracker.db"),
+			Path:         src.get("DATABASE_PATH", "./data/tracker.db"),
+			URL:          src.get("DATABASE_URL", ""),
+			QueryTimeout: src.duration("DB_QUERY_TIMEOUT", "30s"),
 		},
 		Security: SecurityConfig{
-			JWTSecret:          getEnv("JWT_SECRET", ""),
-			CSRFSecret:         getEnv("CSRF_SECRET", ""),
-			SessionDuration:    sessionDuration,
-			RateLimitRequests:  rateLimitReqs,
-			RateLimitWindow:    rateLimitWindow,
-			LoginRateLimit:     loginRateLimit,
-			LoginRateWindow:    loginRateWindow,
-			CSPEnabled:         cspEnabled,
-			HSTSEnabled:        hstsEnabled,
+			JWTSecret:          src.secret("JWT_SECRET"),
+			CSRFSecret:         src.secret("CSRF_SECRET"),
+			SecretsKey:         src.secret("SECRETS_KEY"),
+			SecretsPreviousKeys: splitList(src.get("SECRETS_PREVIOUS_KEYS", "")),
+			CSRFTokenTTL:       src.duration("CSRF_TOKEN_TTL", "24h"),
+			CSRFCookieDomain:   src.get("CSRF_COOKIE_DOMAIN", ""),
+			SessionMaxLifetime: src.duration("SESSION_MAX_LIFETIME", src.get("SESSION_DURATION", "336h")),
+			SessionIdleTimeout: src.duration("SESSION_IDLE_TIMEOUT", "72h"),
+			TrustedDeviceDuration: src.duration("TRUSTED_DEVICE_DURATION", "720h"),
+			RateLimitRequests:  src.int("RATE_LIMIT_REQUESTS", "100", 1),
+			RateLimitWindow:    src.duration("RATE_LIMIT_WINDOW", "1m"),
+			LoginRateLimit:     src.int("LOGIN_RATE_LIMIT", "5", 1),
+			LoginRateWindow:    src.duration("LOGIN_RATE_WINDOW", "15m"),
+			ExportRateLimit:    src.int("EXPORT_RATE_LIMIT", "20", 1),
+			ExportRateWindow:   src.duration("EXPORT_RATE_WINDOW", "1h"),
+			RateLimitStore:     src.oneOf("RATE_LIMIT_STORE", "database", "memory", "database"),
+			CSPEnabled:         src.bool("CSP_ENABLED", "true"),
+			HSTSEnabled:        src.bool("HSTS_ENABLED", "true"),
+			CookieSecure:       src.oneOf("COOKIE_SECURE", "auto", "auto", "always", "never"),
+			TrustedProxies:     splitList(src.get("TRUSTED_PROXIES", "127.0.0.1,::1")),
 		},
 		SMTP: SMTPConfig{
-			Enabled:  smtpEnabled,
-			Host:     getEnv("SMTP_HOST", ""),
-			Port:     smtpPort,
-			Username: getEnv("SMTP_USERNAME", ""),
-			Password: getEnv("SMTP_PASSWORD", ""),
-			From:     getEnv("SMTP_FROM", ""),
+			Enabled:  src.bool("SMTP_ENABLED", "false"),
+			Host:     src.get("SMTP_HOST", ""),
+			Port:     src.int("SMTP_PORT", "587", 1),
+			Username: src.get("SMTP_USERNAME", ""),
+			Password: src.get("SMTP_PASSWORD", ""),
+			From:     src.get("SMTP_FROM", ""),
 		},
 		Backup: BackupConfig{
-			Enabled:        backupEnabled,
-			Schedule:       getEnv("BACKUP_SCHEDULE", "0 2 * * *"),
-			RetentionDays:  backupRetention,
+			Enabled:        src.bool("BACKUP_ENABLED", "true"),
+			Schedule:       src.get("BACKUP_SCHEDULE", "0 2 * * *"),
+			RetentionDays:  src.int("BACKUP_RETENTION_DAYS", "30", 0),
+		},
+		Logging: LoggingConfig{
+			Level:  src.oneOf("LOG_LEVEL", "info", "debug", "info", "warn", "error"),
+			Format: src.oneOf("LOG_FORMAT", "text", "text", "json"),
 		},
 	}
 
 	// Validate required fields
 	if cfg.Security.JWTSecret == "" {
-		return nil, ErrMissingJWTSecret
+		src.problem(ErrMissingJWTSecret.Message + " (security.jwt_secret in a config file); generate one with: openssl rand -base64 32")
 	}
-
 	if cfg.Security.CSRFSecret == "" {
-		return nil, ErrMissingCSRFSecret
+		src.problem(ErrMissingCSRFSecret.Message + " (security.csrf_secret in a config file); generate one with: openssl rand -base64 32")
+	}
+	if cfg.Server.Environment == "production" {
+		checkProductionSecret(src, "JWT_SECRET", cfg.Security.JWTSecret)
+		checkProductionSecret(src, "CSRF_SECRET", cfg.Security.CSRFSecret)
+		if cfg.Security.JWTSecret != "" && cfg.Security.JWTSecret == cfg.Security.CSRFSecret {
+			src.problem("JWT_SECRET and CSRF_SECRET must be different in production")
+		}
+		if cfg.Security.SecretsKey == "" {
+			src.problem("SECRETS_KEY or SECRETS_KEY_FILE is required in production, to encrypt the secrets kept in the database; generate one with: openssl rand -base64 32")
+		}
+	}
+
+	if cfg.Security.SessionMaxLifetime == 0 {
+		src.problem("SESSION_MAX_LIFETIME must be longer than 0")
 	}
 
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		src.problem("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.TLS.CertFile != "" && len(cfg.TLS.ACMEDomains) > 0 {
+		src.problem("use either TLS_CERT_FILE/TLS_KEY_FILE or ACME_DOMAINS, not both")
+	}
+
+	if len(src.problems) > 0 {
+		return nil, problemsError("configuration", src.problems)
+	}
 	return cfg, nil
 }
 
-func getEnv(key, defaultValue string) string {
+// source looks settings up in the environment, then the config file
+type source struct {
+	file     map[string]string
+	problems []string
+}
+
+func (s *source) problem(msg string) {
+	s.problems = append(s.problems, msg)
+}
+
+// secret returns the setting for key or, if it isn't set, the contents of
+// the file named by key_FILE, which is how Docker and systemd pass secrets
+func (s *source) secret(key string) string {
+	if value := s.get(key, ""); value != "" {
+		return value
+	}
+	path := s.get(key+"_FILE", "")
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.problem(fmt.Sprintf("%s_FILE: %v", key, err))
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// get returns the setting for key, or defaultValue if it isn't set
+func (s *source) get(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
+	if value := s.file[key]; value != "" {
+		return value
+	}
 	return defaultValue
 }
 
+func (s *source) duration(key, defaultValue string) time.Duration {
+	value := s.get(key, defaultValue)
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		s.problem(fmt.Sprintf("%s: %q is not a duration (e.g. 90s, 15m, 336h)", key, value))
+	}
+	return d
+}
+
+func (s *source) int(key, defaultValue string, min int) int {
+	value := s.get(key, defaultValue)
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		s.problem(fmt.Sprintf("%s: %q is not a whole number of at least %d", key, value, min))
+	}
+	return n
+}
+
+func (s *source) bool(key, defaultValue string) bool {
+	value := s.get(key, defaultValue)
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		s.problem(fmt.Sprintf("%s: %q is not true or false", key, value))
+	}
+	return b
+}
+
+// oneOf returns the setting for key, lowercased, if it is one of allowed
+func (s *source) oneOf(key, defaultValue string, allowed ...string) string {
+	value := strings.ToLower(strings.TrimSpace(s.get(key, defaultValue)))
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	s.problem(fmt.Sprintf("%s: %q must be %s", key, value, orList(allowed)))
+	return value
+}
+
+// minProductionSecretLength is the shortest JWT or CSRF secret production
+// accepts: 32 characters, as from openssl rand -base64 32
+const minProductionSecretLength = 32
+
+// exampleSecrets are the placeholders from .env.example, which must not
+// make it into production
+var exampleSecrets = []string{
+	"your-secret-key-here-generate-with-openssl-rand-base64-32",
+	"your-csrf-secret-here-generate-with-openssl-rand-base64-32",
+}
+
+// checkProductionSecret refuses a secret that is empty, short or copied
+// from the examples. Empty ones are already reported as missing.
+func checkProductionSecret(s *source, key, value string) {
+	for _, example := range exampleSecrets {
+		if value == example {
+			s.problem(fmt.Sprintf("%s is the example value; generate one with: openssl rand -base64 32", key))
+			return
+		}
+	}
+	if value != "" && len(value) < minProductionSecretLength {
+		s.problem(fmt.Sprintf("%s must be at least %d characters in production; generate one with: openssl rand -base64 32", key, minProductionSecretLength))
+	}
+}
+
+// orList formats {"a", "b", "c"} as "a, b or c"
+func orList(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
+
+// problemsError reports everything wrong with what was read from where
+func problemsError(where string, problems []string) error {
+	if len(problems) == 1 {
+		return &ConfigError{fmt.Sprintf("invalid %s: %s", where, problems[0])}
+	}
+	return &ConfigError{fmt.Sprintf("invalid %s:\n  - %s", where, strings.Join(problems, "\n  - "))}
+}
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 var (
 	ErrMissingJWTSecret  = &ConfigError{"JWT_SECRET environment variable is required"}
 	ErrMissingCSRFSecret = &ConfigError{"CSRF_SECRET environment variable is required"}
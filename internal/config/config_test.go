@@ -0,0 +1,195 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setRequired sets the settings Load insists on, strong enough for
+// production
+func setRequired(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("JWT_SECRET", "test-jwt-secret-at-least-32-characters")
+	t.Setenv("CSRF_SECRET", "test-csrf-secret-at-least-32-characters")
+	t.Setenv("SECRETS_KEY", "")
+	t.Setenv("SECRETS_KEY_FILE", "")
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadYAML(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfig(t, "config.yaml", `
+server:
+  port: 9090
+security:
+  jwt_secret: from-file
+  csrf_secret: from-file
+  rate_limit_window: 2m
+  trusted_proxies:
+    - 10.0.0.0/8
+    - 127.0.0.1
+logging:
+  level: debug
+`))
+	t.Setenv("PORT", "")
+	// The environment wins over the file
+	t.Setenv("JWT_SECRET", "from-env")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if cfg.Server.Port != "9090" || cfg.Logging.Level != "debug" {
+		t.Errorf("Expected settings from the file, got port %q level %q", cfg.Server.Port, cfg.Logging.Level)
+	}
+	if cfg.Security.JWTSecret != "from-env" || cfg.Security.CSRFSecret != "from-file" {
+		t.Errorf("Expected the environment to override the file, got %q %q", cfg.Security.JWTSecret, cfg.Security.CSRFSecret)
+	}
+	if cfg.Security.RateLimitWindow != 2*time.Minute {
+		t.Errorf("Expected a 2m window, got %v", cfg.Security.RateLimitWindow)
+	}
+	if got := strings.Join(cfg.Security.TrustedProxies, ","); got != "10.0.0.0/8,127.0.0.1" {
+		t.Errorf("Expected the list of proxies, got %q", got)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	setRequired(t)
+	t.Setenv("CONFIG_FILE", writeConfig(t, "config.toml", `
+[backups]
+enabled = false
+retention_days = 7
+`))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if cfg.Backup.Enabled || cfg.Backup.RetentionDays != 7 {
+		t.Errorf("Expected backups from the file, got %+v", cfg.Backup)
+	}
+}
+
+func TestLoadUnknownSetting(t *testing.T) {
+	setRequired(t)
+	t.Setenv("CONFIG_FILE", writeConfig(t, "config.yaml", "security:\n  jwt_secert: oops\n"))
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "unknown setting security.jwt_secert") {
+		t.Errorf("Expected an unknown setting error, got %v", err)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("CSRF_SECRET", "secret")
+	t.Setenv("RATE_LIMIT_WINDOW", "soon")
+	t.Setenv("COOKIE_SECURE", "sometimes")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{"JWT_SECRET", "RATE_LIMIT_WINDOW", "COOKIE_SECURE"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected the error to mention %s, got %v", want, err)
+		}
+	}
+}
+
+func TestNeedsRestart(t *testing.T) {
+	setRequired(t)
+	t.Setenv("CONFIG_FILE", "")
+	running, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("RATE_LIMIT_REQUESTS", "50")
+	t.Setenv("PORT", "9191")
+	next, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	if got := strings.Join(running.NeedsRestart(next), ","); got != "PORT" {
+		t.Errorf("Expected only PORT to need a restart, got %q", got)
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	setRequired(t)
+	// The example runs in production, which needs a master key
+	t.Setenv("SECRETS_KEY", "test-secrets-key")
+	t.Setenv("CONFIG_FILE", filepath.Join("..", "..", "config.example.yaml"))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected the example config to load: %v", err)
+	}
+	if got := strings.Join(cfg.Security.TrustedProxies, ","); got != "127.0.0.1,::1" {
+		t.Errorf("Unexpected trusted proxies %q", got)
+	}
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	setRequired(t)
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_SECRET_FILE", writeConfig(t, "jwt", "from-a-file\n"))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if cfg.Security.JWTSecret != "from-a-file" {
+		t.Errorf("Expected the secret from the file, got %q", cfg.Security.JWTSecret)
+	}
+
+	t.Setenv("JWT_SECRET_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "JWT_SECRET_FILE") {
+		t.Errorf("Expected an error for a missing secret file, got %v", err)
+	}
+}
+
+func TestLoadProductionSecrets(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("ENVIRONMENT", "production")
+	t.Setenv("JWT_SECRET", "your-secret-key-here-generate-with-openssl-rand-base64-32")
+	t.Setenv("CSRF_SECRET", "short")
+	t.Setenv("SECRETS_KEY", "")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Expected production to refuse weak secrets")
+	}
+	for _, want := range []string{"JWT_SECRET is the example value", "CSRF_SECRET must be at least 32", "SECRETS_KEY"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected the error to mention %q, got %v", want, err)
+		}
+	}
+
+	strong := strings.Repeat("x", 32)
+	t.Setenv("JWT_SECRET", strong)
+	t.Setenv("CSRF_SECRET", strong)
+	t.Setenv("SECRETS_KEY", "key")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "must be different") {
+		t.Errorf("Expected the same JWT and CSRF secret to be refused, got %v", err)
+	}
+
+	t.Setenv("CSRF_SECRET", strings.Repeat("y", 32))
+	if _, err := Load(); err != nil {
+		t.Errorf("Expected strong secrets to load: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import "os"
+
+// LoadDotEnv sets environment variables from a .env file of KEY=value
+// lines. Values in the file replace ones already in the environment.
+func LoadDotEnv(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := splitLines(string(data))
+	for _, line := range lines {
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		parts := splitOnce(line, '=')
+		if len(parts) == 2 {
+			os.Setenv(parts[0], parts[1])
+		}
+	}
+
+	return nil
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			lines = append(lines, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		lines = append(lines, s[start:])
+	}
+	return lines
+}
+
+func splitOnce(s string, sep byte) []string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == sep {
+			return []string{s[:i], s[i+1:]}
+		}
+	}
+	return []string{s}
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileKeys maps each setting in a config file, as section.key, to the
+// environment variable it stands in for. Environment variables (including
+// those from .env) win over the file.
+var fileKeys = map[string]string{
+	"server.port":             "PORT",
+	"server.environment":      "ENVIRONMENT",
+	"server.shutdown_timeout": "SHUTDOWN_TIMEOUT",
+
+	"tls.cert_file":          "TLS_CERT_FILE",
+	"tls.key_file":           "TLS_KEY_FILE",
+	"tls.acme_domains":       "ACME_DOMAINS",
+	"tls.acme_email":         "ACME_EMAIL",
+	"tls.acme_cache_dir":     "ACME_CACHE_DIR",
+	"tls.acme_directory_url": "ACME_DIRECTORY_URL",
+	"tls.http_port":          "HTTP_PORT",
+
+	"database.path":          "DATABASE_PATH",
+	"database.url":           "DATABASE_URL",
+	"database.query_timeout": "DB_QUERY_TIMEOUT",
+
+	"security.jwt_secret":              "JWT_SECRET",
+	"security.jwt_secret_file":         "JWT_SECRET_FILE",
+	"security.csrf_secret":             "CSRF_SECRET",
+	"security.csrf_secret_file":        "CSRF_SECRET_FILE",
+	"security.secrets_key":             "SECRETS_KEY",
+	"security.secrets_key_file":        "SECRETS_KEY_FILE",
+	"security.secrets_previous_keys":   "SECRETS_PREVIOUS_KEYS",
+	"security.csrf_token_ttl":          "CSRF_TOKEN_TTL",
+	"security.csrf_cookie_domain":      "CSRF_COOKIE_DOMAIN",
+	"security.session_duration":        "SESSION_DURATION",
+	"security.session_max_lifetime":    "SESSION_MAX_LIFETIME",
+	"security.session_idle_timeout":    "SESSION_IDLE_TIMEOUT",
+	"security.trusted_device_duration": "TRUSTED_DEVICE_DURATION",
+	"security.rate_limit_requests":     "RATE_LIMIT_REQUESTS",
+	"security.rate_limit_window":       "RATE_LIMIT_WINDOW",
+	"security.login_rate_limit":        "LOGIN_RATE_LIMIT",
+	"security.login_rate_window":       "LOGIN_RATE_WINDOW",
+	"security.export_rate_limit":       "EXPORT_RATE_LIMIT",
+	"security.export_rate_window":      "EXPORT_RATE_WINDOW",
+	"security.rate_limit_store":        "RATE_LIMIT_STORE",
+	"security.csp_enabled":             "CSP_ENABLED",
+	"security.hsts_enabled":            "HSTS_ENABLED",
+	"security.cookie_secure":           "COOKIE_SECURE",
+	"security.trusted_proxies":         "TRUSTED_PROXIES",
+
+	"smtp.enabled":  "SMTP_ENABLED",
+	"smtp.host":     "SMTP_HOST",
+	"smtp.port":     "SMTP_PORT",
+	"smtp.username": "SMTP_USERNAME",
+	"smtp.password": "SMTP_PASSWORD",
+	"smtp.from":     "SMTP_FROM",
+
+	"backups.enabled":        "BACKUP_ENABLED",
+	"backups.schedule":       "BACKUP_SCHEDULE",
+	"backups.retention_days": "BACKUP_RETENTION_DAYS",
+
+	"logging.level":  "LOG_LEVEL",
+	"logging.format": "LOG_FORMAT",
+}
+
+// readFile reads a YAML (.yaml, .yml) or TOML (.toml) config file into
+// values keyed by environment variable name. Unknown sections and settings
+// are errors, so a misspelt key doesn't silently fall back to its default.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &ConfigError{fmt.Sprintf("failed to read config file: %v", err)}
+	}
+
+	var sections map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &sections)
+	case ".toml":
+		_, err = toml.Decode(string(data), &sections)
+	default:
+		return nil, &ConfigError{fmt.Sprintf("%s: config files must end in .yaml, .yml or .toml", path)}
+	}
+	if err != nil {
+		return nil, &ConfigError{fmt.Sprintf("%s: %v", path, err)}
+	}
+
+	values := make(map[string]string)
+	var problems []string
+	for _, section := range sortedKeys(sections) {
+		settings, ok := sections[section].(map[string]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is not a section", section))
+			continue
+		}
+		for _, key := range sortedKeys(settings) {
+			name := section + "." + key
+			env, ok := fileKeys[name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("unknown setting %s", name))
+				continue
+			}
+			value, err := fileValue(settings[key])
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+				continue
+			}
+			values[env] = value
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problemsError(path, problems)
+	}
+	return values, nil
+}
+
+// fileValue formats a setting the way it would be written in the
+// environment. Lists become comma-separated.
+func fileValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(s, ",") {
+				return "", fmt.Errorf("list items can't contain commas")
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("expected a value or a list, got %T", v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,44 @@
+package config
+
+import "reflect"
+
+// NeedsRestart lists the settings that differ in next but are only read at
+// startup. Everything else the server uses (LOG_LEVEL, the rate limits,
+// COOKIE_SECURE and TRUSTED_PROXIES) is applied when the configuration is
+// reloaded.
+func (c *Config) NeedsRestart(next *Config) []string {
+	var changed []string
+	check := func(name string, old, new interface{}) {
+		if !reflect.DeepEqual(old, new) {
+			changed = append(changed, name)
+		}
+	}
+
+	check("PORT", c.Server.Port, next.Server.Port)
+	check("ENVIRONMENT", c.Server.Environment, next.Server.Environment)
+	check("SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout, next.Server.ShutdownTimeout)
+	check("TLS_CERT_FILE", c.TLS.CertFile, next.TLS.CertFile)
+	check("TLS_KEY_FILE", c.TLS.KeyFile, next.TLS.KeyFile)
+	check("ACME_DOMAINS", c.TLS.ACMEDomains, next.TLS.ACMEDomains)
+	check("ACME_EMAIL", c.TLS.ACMEEmail, next.TLS.ACMEEmail)
+	check("ACME_CACHE_DIR", c.TLS.ACMECacheDir, next.TLS.ACMECacheDir)
+	check("ACME_DIRECTORY_URL", c.TLS.ACMEDirectoryURL, next.TLS.ACMEDirectoryURL)
+	check("HTTP_PORT", c.TLS.HTTPPort, next.TLS.HTTPPort)
+	check("DATABASE_PATH", c.Database.Path, next.Database.Path)
+	check("DATABASE_URL", c.Database.URL, next.Database.URL)
+	check("DB_QUERY_TIMEOUT", c.Database.QueryTimeout, next.Database.QueryTimeout)
+	check("JWT_SECRET", c.Security.JWTSecret, next.Security.JWTSecret)
+	check("CSRF_SECRET", c.Security.CSRFSecret, next.Security.CSRFSecret)
+	check("SECRETS_KEY", c.Security.SecretsKey, next.Security.SecretsKey)
+	check("SECRETS_PREVIOUS_KEYS", c.Security.SecretsPreviousKeys, next.Security.SecretsPreviousKeys)
+	check("CSRF_TOKEN_TTL", c.Security.CSRFTokenTTL, next.Security.CSRFTokenTTL)
+	check("CSRF_COOKIE_DOMAIN", c.Security.CSRFCookieDomain, next.Security.CSRFCookieDomain)
+	check("SESSION_MAX_LIFETIME", c.Security.SessionMaxLifetime, next.Security.SessionMaxLifetime)
+	check("SESSION_IDLE_TIMEOUT", c.Security.SessionIdleTimeout, next.Security.SessionIdleTimeout)
+	check("TRUSTED_DEVICE_DURATION", c.Security.TrustedDeviceDuration, next.Security.TrustedDeviceDuration)
+	check("RATE_LIMIT_STORE", c.Security.RateLimitStore, next.Security.RateLimitStore)
+	check("CSP_ENABLED", c.Security.CSPEnabled, next.Security.CSPEnabled)
+	check("HSTS_ENABLED", c.Security.HSTSEnabled, next.Security.HSTSEnabled)
+	check("LOG_FORMAT", c.Logging.Format, next.Logging.Format)
+	return changed
+}
@@ -1,35 +1,68 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"os"
-	"path/filepath"
-	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// DefaultQueryTimeout is how long a single statement may run unless
+// SetQueryTimeout says otherwise
+const DefaultQueryTimeout = 30 * time.Second
+
 type DB struct {
 	*sql.DB
+	Dialect Dialect
+	dsn     string
+
+	// ctx is what statements run under; see WithContext
+	ctx          context.Context
+	queryTimeout time.Duration
+
+	// pool is shared with every copy so Swap can replace it under them
+	pool *pool
+}
+
+// pool holds the connection pool a DB and its copies run statements on
+type pool struct {
+	mu sync.RWMutex
+	db *sql.DB
 }
 
-// Open creates a new database connection with secure settings
+// swapDrainTimeout bounds how long Swap waits for connections still in use
+// when it closes the pool
+const swapDrainTimeout = 10 * time.Second
+
+// Open creates a new database connection with secure settings. A
+// postgres:// or postgresql:// URL opens PostgreSQL; anything else is the
+// path to a SQLite file, optionally prefixed with sqlite://.
 func Open(dbPath string) (*DB, error) {
-	// Clean up the path for Windows
-	if len(dbPath) > 1 && dbPath[0] == '.' && dbPath[1] == '/' {
-		dbPath = dbPath[2:]
-	}
+	if isPostgresURL(dbPath) {
+		db, err := openPostgres(dbPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
 
-	// SQLite connection string with security settings
-	dsn := fmt.Sprintf("%s?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL&_cache_size=10000", dbPath)
+		db.SetMaxOpenConns(25)
+		db.SetMaxIdleConns(5)
+		db.SetConnMaxLifetime(0)
 
-	db, err := sql.Open("sqlite3", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to ping database: %w", err)
+		}
+
+		return &DB{DB: db, Dialect: postgresDialect{}, dsn: dbPath, queryTimeout: DefaultQueryTimeout, pool: &pool{db: db}}, nil
 	}
 
+	dbPath, _ = SQLitePath(dbPath)
+	db := openSQLite(dbPath)
+
 	// Set connection pool settings
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(5)
@@ -37,168 +70,153 @@ func Open(dbPath string) (*DB, error) {
 
 	// Test the connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{db}, nil
+	return &DB{DB: db, Dialect: sqliteDialect{}, dsn: dbPath, queryTimeout: DefaultQueryTimeout, pool: &pool{db: db}}, nil
 }
 
-// RunMigrations executes all SQL migration files in order
-func (db *DB) RunMigrations() error {
-	// Create migrations table if it doesn't exist
-	if err := db.createMigrationsTable(); err != nil {
-		return fmt.Errorf("failed to create migrations table: %w", err)
-	}
-
-	// Get applied migrations
-	applied, err := db.getAppliedMigrations()
-	if err != nil {
-		return fmt.Errorf("failed to get applied migrations: %w", err)
-	}
-
-	// Read migration files
-	migrations, err := db.readMigrationFiles()
-	if err != nil {
-		return fmt.Errorf("failed to read migration files: %w", err)
+// SQLitePath returns the file a DSN given to Open refers to. It returns
+// false for PostgreSQL URLs.
+func SQLitePath(dsn string) (string, bool) {
+	if isPostgresURL(dsn) {
+		return "", false
 	}
+	dsn = strings.TrimPrefix(dsn, "sqlite://")
 
-	// Apply pending migrations
-	for _, migration := range migrations {
-		if applied[migration.Name] {
-			continue
-		}
-
-		if err := db.applyMigration(migration); err != nil {
-			return fmt.Errorf("failed to apply migration %s: %w", migration.Name, err)
-		}
-
-		fmt.Printf("Applied migration: %s\n", migration.Name)
+	// Clean up the path for Windows
+	if len(dsn) > 1 && dsn[0] == '.' && dsn[1] == '/' {
+		dsn = dsn[2:]
 	}
-
-	return nil
+	return dsn, true
 }
 
-type migration struct {
-	Name    string
-	Content string
+// File returns the path of a SQLite database's file. It returns false for
+// PostgreSQL.
+func (db *DB) File() (string, bool) {
+	return SQLitePath(db.dsn)
 }
 
-func (db *DB) createMigrationsTable() error {
-	query := `
-		CREATE TABLE IF NOT EXISTS schema_migrations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT UNIQUE NOT NULL,
-			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`
-	_, err := db.Exec(query)
-	return err
+// Close closes the database connection
+func (db *DB) Close() error {
+	return db.conn().Close()
 }
 
-func (db *DB) getAppliedMigrations() (map[string]bool, error) {
-	rows, err := db.Query("SELECT name FROM schema_migrations")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	applied := make(map[string]bool)
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			return nil, err
-		}
-		applied[name] = true
-	}
-
-	return applied, rows.Err()
+// conn returns the current connection pool. It waits while Swap runs.
+func (db *DB) conn() *sql.DB {
+	db.pool.mu.RLock()
+	defer db.pool.mu.RUnlock()
+	return db.pool.db
 }
 
-func (db *DB) readMigrationFiles() ([]migration, error) {
-	var migrations []migration
-
-	// Try multiple paths to find migrations
-	// 1. "migrations" (root execution)
-	// 2. "../../migrations" (test execution from internal/repository)
-	// 3. "../../../migrations" (just in case)
+// Swap closes the connection pool, calls replace while nothing has the
+// database open, then opens it again, whether or not replace succeeded.
+// Statements started meanwhile wait for the new pool. Callers should stop
+// other work first: connections still in use are given swapDrainTimeout to
+// finish, and copies made by WithContext before the swap keep a closed pool
+// in their embedded *sql.DB, though their statement methods move over.
+func (db *DB) Swap(replace func() error) error {
+	db.pool.mu.Lock()
+	defer db.pool.mu.Unlock()
 
-	possiblePaths := []string{
-		"migrations",
-		"../../migrations",
-		"../../../migrations",
+	old := db.pool.db
+	if err := old.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
 	}
-
-	var migrationsDir string
-	found := false
-
-	for _, path := range possiblePaths {
-		if _, err := os.Stat(path); err == nil {
-			migrationsDir = path
-			found = true
-			break
-		}
+	for deadline := time.Now().Add(swapDrainTimeout); old.Stats().OpenConnections > 0 && time.Now().Before(deadline); {
+		time.Sleep(20 * time.Millisecond)
 	}
 
-	if !found {
-		return nil, fmt.Errorf("failed to find migrations directory in any of: %v", possiblePaths)
-	}
+	replaceErr := replace()
 
-	files, err := os.ReadDir(migrationsDir)
+	fresh, err := Open(db.dsn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+		// Leave the closed pool in place; statements fail rather than hang
+		return fmt.Errorf("failed to reopen database: %w", err)
 	}
+	db.pool.db = fresh.DB
+	return replaceErr
+}
 
-	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".sql") {
-			continue
-		}
+// BeginTx starts a new transaction
+func (db *DB) BeginTx() (*sql.Tx, error) {
+	return db.Begin()
+}
 
-		path := filepath.Join(migrationsDir, file.Name())
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read migration file %s: %w", path, err)
-		}
+// WithContext returns a copy of db whose statements run under ctx, so they
+// are abandoned once it is cancelled. Handlers bind the request's context
+// this way; repositories and services built on the copy inherit it. The
+// connection pool is shared.
+func (db *DB) WithContext(ctx context.Context) *DB {
+	c := *db
+	c.DB = db.conn()
+	c.ctx = ctx
+	return &c
+}
 
-		migrations = append(migrations, migration{
-			Name:    file.Name(),
-			Content: string(content),
-		})
+// Context returns the context db's statements run under
+func (db *DB) Context() context.Context {
+	if db.ctx == nil {
+		return context.Background()
 	}
+	return db.ctx
+}
 
-	// Sort migrations by name to ensure order
-	sort.Slice(migrations, func(i, j int) bool {
-		return migrations[i].Name < migrations[j].Name
-	})
+// Detach returns a copy of db that keeps its context's values but not its
+// cancellation, for work a request starts in the background
+func (db *DB) Detach() *DB {
+	return db.WithContext(context.WithoutCancel(db.Context()))
+}
 
-	return migrations, nil
+// SetQueryTimeout sets how long a single statement may run. Zero removes
+// the limit. Copies made by WithContext afterwards share the setting.
+func (db *DB) SetQueryTimeout(d time.Duration) {
+	db.queryTimeout = d
 }
 
-func (db *DB) applyMigration(m migration) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
+// statementContext is the context for one statement: db's context, bounded
+// by the query timeout
+func (db *DB) statementContext() (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return db.Context(), func() {}
 	}
-	defer func() { _ = tx.Rollback() }()
+	return context.WithTimeout(db.Context(), db.queryTimeout)
+}
 
-	// Execute migration
-	if _, err := tx.Exec(m.Content); err != nil {
-		return err
-	}
+// Exec runs a statement under db's context and the query timeout
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := db.statementContext()
+	defer cancel()
+	return db.conn().ExecContext(ctx, query, args...)
+}
 
-	// Record migration
-	if _, err := tx.Exec("INSERT INTO schema_migrations (name) VALUES (?)", m.Name); err != nil {
-		return err
+// Query runs a query under db's context and the query timeout. The timeout
+// covers reading the rows too.
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, cancel := db.statementContext()
+	rows, err := db.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
-
-	return tx.Commit()
+	// Rows are read after we return, so the deadline has to stay in place;
+	// its timer releases the context when it fires
+	_ = cancel
+	return rows, nil
 }
 
-// Close closes the database connection
-func (db *DB) Close() error {
-	return db.DB.Close()
+// QueryRow runs a single-row query under db's context and the query timeout
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	ctx, cancel := db.statementContext()
+	// As with Query, the row is scanned after we return
+	_ = cancel
+	return db.conn().QueryRowContext(ctx, query, args...)
 }
 
-// BeginTx starts a new transaction
-func (db *DB) BeginTx() (*sql.Tx, error) {
-	return db.Begin()
+// Begin starts a transaction bound to db's context: it is rolled back if
+// the context is cancelled before it commits. Statements in it aren't
+// individually timed.
+func (db *DB) Begin() (*sql.Tx, error) {
+	return db.conn().BeginTx(db.Context(), nil)
 }
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// slowQuery takes seconds to run, long enough to be interrupted
+const slowQuery = `
+	WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 100000000)
+	SELECT COUNT(*) FROM n`
+
+func TestWithContextCancelled(t *testing.T) {
+	db := openTestDB(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bound := db.WithContext(ctx)
+
+	if _, err := bound.Exec("CREATE TABLE t (n INTEGER)"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Exec to fail with context.Canceled, got %v", err)
+	}
+	if _, err := bound.Begin(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Begin to fail with context.Canceled, got %v", err)
+	}
+
+	// The original is untouched
+	if _, err := db.Exec("CREATE TABLE t (n INTEGER)"); err != nil {
+		t.Errorf("Expected the unbound database to still work, got %v", err)
+	}
+	if _, err := bound.Detach().Exec("INSERT INTO t (n) VALUES (1)"); err != nil {
+		t.Errorf("Expected a detached copy to ignore the cancellation, got %v", err)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	db := openTestDB(t)
+	db.SetQueryTimeout(20 * time.Millisecond)
+
+	start := time.Now()
+	var n int
+	err := db.QueryRow(slowQuery).Scan(&n)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the query to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the query to stop at its timeout, took %v", elapsed)
+	}
+
+	// Requests that end stop their queries too
+	ctx, cancel := context.WithCancel(context.Background())
+	db.SetQueryTimeout(0)
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := db.WithContext(ctx).QueryRow(slowQuery).Scan(&n); err == nil {
+		t.Error("Expected the query to stop when its context was cancelled")
+	}
+}
+
+func TestTransactionRolledBackOnCancel(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec("CREATE TABLE t (n INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tx, err := db.WithContext(ctx).Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	if _, err := tx.Exec("INSERT INTO t (n) VALUES (1)"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	cancel()
+	if err := tx.Commit(); err == nil {
+		t.Error("Expected the commit to fail after cancellation")
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM t").Scan(&count); err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected the insert rolled back, got %d rows", count)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec("CREATE TABLE t (n INTEGER); INSERT INTO t (n) VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	before := db.WithContext(context.Background())
+
+	// Another database to swap in
+	otherPath := filepath.Join(t.TempDir(), "other.db")
+	other, err := Open(otherPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Exec("CREATE TABLE t (n INTEGER); INSERT INTO t (n) VALUES (2)"); err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+
+	err = db.Swap(func() error {
+		os.Remove(db.dsn + "-wal")
+		os.Remove(db.dsn + "-shm")
+		return os.Rename(otherPath, db.dsn)
+	})
+	if err != nil {
+		t.Fatalf("Swap failed: %v", err)
+	}
+
+	for name, d := range map[string]*DB{"original": db, "earlier copy": before, "new copy": db.WithContext(context.Background())} {
+		var n int
+		if err := d.QueryRow("SELECT n FROM t").Scan(&n); err != nil || n != 2 {
+			t.Errorf("%s read %d, %v; want 2 from the swapped-in database", name, n, err)
+		}
+	}
+
+	// A failed replacement still leaves a working database
+	replaceErr := errors.New("no backup")
+	if err := db.Swap(func() error { return replaceErr }); !errors.Is(err, replaceErr) {
+		t.Errorf("Swap returned %v, want the replace error", err)
+	}
+	if _, err := db.Exec("INSERT INTO t (n) VALUES (3)"); err != nil {
+		t.Errorf("Database unusable after a failed swap: %v", err)
+	}
+}
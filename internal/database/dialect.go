@@ -0,0 +1,217 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Dialect names
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+// Dialect covers what differs between the databases P-TRACK runs on. Most
+// SQL is written to work on both: ? placeholders (rewritten for
+// PostgreSQL by its connector), INSERT ... RETURNING id instead of
+// LastInsertId, ON CONFLICT upserts, TRUE/FALSE and time bounds computed in
+// Go. The rest goes through here.
+type Dialect interface {
+	Name() string
+	// Month is SQL for the year and month ("2006-01") of a timestamp
+	Month(expr string) string
+	// Date is SQL for the calendar day ("2006-01-02") of a timestamp as seen
+	// offset seconds east of UTC
+	Date(expr string, offset int) string
+	// ForUpdate ends a SELECT in a transaction that must hold the rows it
+	// reads until it commits, so transactions that check them before
+	// writing take turns
+	ForUpdate() string
+	// SameTime is SQL that is true when the timestamp expr is the time bound
+	// to the ? it adds, however each was written
+	SameTime(expr string) string
+	// BackupExtension is the file extension of this dialect's backups
+	BackupExtension() string
+
+	// migrationsDir is where the migrations live within migrations.FS
+	migrationsDir() string
+	migrationsTable() string
+	backup(db *DB, path string) error
+	validateBackup(path string) error
+	// integrityCheck runs the database's own consistency check and returns
+	// the problems it finds. ok is false if the database has no such check.
+	integrityCheck(db *DB) (problems []string, ok bool, err error)
+}
+
+// ErrRestoreNeedsRestart is returned by Restore for SQLite, whose backups
+// are restored by swapping the database file while the server is stopped
+var ErrRestoreNeedsRestart = errors.New("restoring a SQLite backup needs a restart")
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return SQLite }
+
+func (sqliteDialect) Month(expr string) string { return "strftime('%Y-%m', " + expr + ")" }
+
+func (sqliteDialect) Date(expr string, offset int) string {
+	return fmt.Sprintf("strftime('%%Y-%%m-%%d', %s, '%+d seconds')", expr, offset)
+}
+
+// ForUpdate is empty: SQLite transactions take the write lock at BEGIN
+// (see sqliteDSN), so they already take turns
+func (sqliteDialect) ForUpdate() string { return "" }
+
+// SameTime has to allow for SQLite keeping times as text. Times the
+// application wrote carry an offset and every digit of the nanoseconds, and
+// a time read back is written out again the same way, so those must match
+// exactly. Times SQLite stamped itself (CURRENT_TIMESTAMP, the triggers) have
+// no offset and at most milliseconds, so those are compared as julianday.
+func (sqliteDialect) SameTime(expr string) string {
+	return "(SELECT " + expr + " = t OR (julianday(" + expr + ") = julianday(t) AND substr(" + expr + ", -6, 1) NOT IN ('+', '-')) FROM (SELECT ? AS t))"
+}
+
+func (sqliteDialect) BackupExtension() string { return ".db" }
+
+func (sqliteDialect) migrationsDir() string { return "." }
+
+func (sqliteDialect) migrationsTable() string {
+	return `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+}
+
+// backup uses VACUUM INTO, which writes a consistent copy without blocking
+// writers for long
+func (sqliteDialect) backup(db *DB, path string) error {
+	_, err := db.conn().ExecContext(db.Context(), "VACUUM INTO ?", path)
+	return err
+}
+
+func (sqliteDialect) validateBackup(path string) error {
+	testDB, err := sql.Open("sqlite3", path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer testDB.Close()
+
+	var count int
+	if err := testDB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table'").Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("no tables")
+	}
+	return nil
+}
+
+// integrityCheck runs PRAGMA integrity_check, which reports "ok" or one row
+// per problem
+func (sqliteDialect) integrityCheck(db *DB) ([]string, bool, error) {
+	rows, err := db.conn().QueryContext(db.Context(), "PRAGMA integrity_check")
+	if err != nil {
+		return nil, true, err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, true, err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, true, rows.Err()
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return Postgres }
+
+func (postgresDialect) Month(expr string) string { return "to_char(" + expr + ", 'YYYY-MM')" }
+
+func (postgresDialect) Date(expr string, offset int) string {
+	return fmt.Sprintf("to_char((%s AT TIME ZONE 'UTC') + interval '%d seconds', 'YYYY-MM-DD')", expr, offset)
+}
+
+func (postgresDialect) ForUpdate() string { return " FOR UPDATE" }
+
+func (postgresDialect) SameTime(expr string) string { return expr + " = ?" }
+
+func (postgresDialect) BackupExtension() string { return ".dump" }
+
+func (postgresDialect) migrationsDir() string { return "postgres" }
+
+func (postgresDialect) migrationsTable() string {
+	return `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT UNIQUE NOT NULL,
+			applied_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+}
+
+// backup runs pg_dump, which must be installed alongside the server, in its
+// custom format so pg_restore can replace the schema as a whole
+func (postgresDialect) backup(db *DB, path string) error {
+	return runPGTool(db.Context(), "pg_dump", "--format=custom", "--no-owner", "--file="+path, "--dbname="+db.dsn)
+}
+
+func (postgresDialect) validateBackup(path string) error {
+	return runPGTool(context.Background(), "pg_restore", "--list", path)
+}
+
+// integrityCheck has nothing to run: PostgreSQL checks pages as it reads
+// them and has no built-in whole-database check
+func (postgresDialect) integrityCheck(db *DB) ([]string, bool, error) {
+	return nil, false, nil
+}
+
+func runPGTool(ctx context.Context, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// Backup writes a consistent snapshot of the database to path. It isn't
+// bound by the query timeout, but stops if db's context is cancelled.
+func (db *DB) Backup(path string) error {
+	if err := db.Dialect.backup(db, path); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// ValidateBackup checks that path holds a backup this database can restore
+func (db *DB) ValidateBackup(path string) error {
+	return db.Dialect.validateBackup(path)
+}
+
+// Restore replaces the contents of a PostgreSQL database with a backup. For
+// SQLite it returns ErrRestoreNeedsRestart.
+func (db *DB) Restore(path string) error {
+	if db.Dialect.Name() != Postgres {
+		return ErrRestoreNeedsRestart
+	}
+	return runPGTool(db.Context(), "pg_restore", "--clean", "--if-exists", "--no-owner", "--single-transaction", "--dbname="+db.dsn, path)
+}
@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrCorrupt is returned by CheckIntegrity when asked to repair a database
+// that fails the engine's own check. That needs a restore from backup, and
+// writing to it could make things worse.
+var ErrCorrupt = errors.New("database failed its integrity check; restore from a backup instead of repairing")
+
+// inventoryTolerance absorbs floating point noise when comparing stock with
+// the sum of its history
+const inventoryTolerance = 1e-6
+
+// orphanCheck is a reference that should always point at an existing row.
+// Foreign keys keep these intact, but rows written while they were off (an
+// old SQLite connection, a hand edit) can slip through.
+type orphanCheck struct {
+	Table, Column, Parent string
+	// SetNull repairs by clearing the column, matching ON DELETE SET NULL;
+	// otherwise orphans are deleted, matching ON DELETE CASCADE
+	SetNull bool
+}
+
+var orphanChecks = []orphanCheck{
+	{Table: "injections", Column: "course_id", Parent: "courses"},
+	{Table: "symptom_logs", Column: "course_id", Parent: "courses"},
+	{Table: "medication_logs", Column: "medication_id", Parent: "medications"},
+	{Table: "inventory_lot_consumptions", Column: "lot_id", Parent: "inventory_lots"},
+	{Table: "inventory_lot_consumptions", Column: "injection_id", Parent: "injections"},
+	{Table: "injections", Column: "lot_id", Parent: "inventory_lots", SetNull: true},
+	{Table: "injections", Column: "attachment_id", Parent: "attachments", SetNull: true},
+	{Table: "symptom_logs", Column: "attachment_id", Parent: "attachments", SetNull: true},
+}
+
+// OrphanReport counts rows whose reference points at a missing row
+type OrphanReport struct {
+	Table  string  `json:"table"`
+	Column string  `json:"column"`
+	Parent string  `json:"parent"`
+	Count  int     `json:"count"`
+	IDs    []int64 `json:"ids"`
+	// Repair is what a repair does with them: "delete" or "set null"
+	Repair string `json:"repair"`
+}
+
+// InventoryMismatch is a stock level that doesn't match the sum of its
+// inventory history
+type InventoryMismatch struct {
+	AccountID    int64   `json:"account_id,omitempty"`
+	ItemType     string  `json:"item_type"`
+	Quantity     float64 `json:"quantity"`
+	HistoryTotal float64 `json:"history_total"`
+}
+
+// IntegrityReport is the result of CheckIntegrity
+type IntegrityReport struct {
+	// EngineChecked is false for databases without a built-in check
+	EngineChecked  bool                `json:"engine_checked"`
+	EngineProblems []string            `json:"engine_problems"`
+	Orphans        []OrphanReport      `json:"orphans"`
+	Inventory      []InventoryMismatch `json:"inventory"`
+	Repaired       bool                `json:"repaired"`
+}
+
+// OK reports whether the check found nothing wrong
+func (r *IntegrityReport) OK() bool {
+	return len(r.EngineProblems) == 0 && len(r.Orphans) == 0 && len(r.Inventory) == 0
+}
+
+// CheckIntegrity runs the engine's integrity check, looks for orphaned rows
+// and compares each inventory item with the sum of its history. With repair,
+// it then fixes what it found in a single transaction: orphans are deleted
+// or unlinked as their foreign key would have done, and each mismatched item
+// gets a history entry for the difference. Stock levels are left alone, since
+// they are what the lots and the rest of the app agree on. The report
+// describes the database as found.
+func (db *DB) CheckIntegrity(repair bool) (*IntegrityReport, error) {
+	report := &IntegrityReport{Orphans: []OrphanReport{}, Inventory: []InventoryMismatch{}}
+
+	problems, ok, err := db.Dialect.integrityCheck(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	report.EngineChecked = ok
+	report.EngineProblems = append([]string{}, problems...)
+	if repair && len(problems) > 0 {
+		return report, ErrCorrupt
+	}
+
+	if !repair {
+		if err := findIntegrityProblems(db.Context(), db.conn(), report); err != nil {
+			return nil, err
+		}
+		return report, nil
+	}
+
+	// Look again inside the transaction so the repair covers exactly what
+	// the report lists
+	err = db.inTx(func(tx *sql.Tx) error {
+		if err := findIntegrityProblems(db.Context(), tx, report); err != nil {
+			return err
+		}
+		return repairIntegrityProblems(tx, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Repaired = true
+	return report, nil
+}
+
+// querier is a *sql.DB or *sql.Tx. The checks scan whole tables, so they run
+// without the per-query timeout.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func findIntegrityProblems(ctx context.Context, q querier, report *IntegrityReport) error {
+	report.Orphans = report.Orphans[:0]
+	for _, c := range orphanChecks {
+		ids, err := queryIDs(ctx, q, fmt.Sprintf(`
+			SELECT t.id FROM %[1]s t
+			WHERE t.%[2]s IS NOT NULL
+			  AND NOT EXISTS (SELECT 1 FROM %[3]s p WHERE p.id = t.%[2]s)
+			ORDER BY t.id`, c.Table, c.Column, c.Parent))
+		if err != nil {
+			return fmt.Errorf("failed to check %s.%s: %w", c.Table, c.Column, err)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		repair := "delete"
+		if c.SetNull {
+			repair = "set null"
+		}
+		report.Orphans = append(report.Orphans, OrphanReport{
+			Table:  c.Table,
+			Column: c.Column,
+			Parent: c.Parent,
+			Count:  len(ids),
+			IDs:    ids,
+			Repair: repair,
+		})
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT i.account_id, i.item_type, i.quantity, COALESCE(SUM(h.change_amount), 0)
+		FROM inventory_items i
+		LEFT JOIN inventory_history h ON h.item_type = i.item_type AND COALESCE(h.account_id, i.account_id) = i.account_id
+		GROUP BY i.id, i.account_id, i.item_type, i.quantity
+		ORDER BY i.item_type, i.account_id`)
+	if err != nil {
+		return fmt.Errorf("failed to sum inventory history: %w", err)
+	}
+	defer rows.Close()
+
+	report.Inventory = report.Inventory[:0]
+	for rows.Next() {
+		var m InventoryMismatch
+		var accountID sql.NullInt64
+		if err := rows.Scan(&accountID, &m.ItemType, &m.Quantity, &m.HistoryTotal); err != nil {
+			return fmt.Errorf("failed to scan inventory totals: %w", err)
+		}
+		m.AccountID = accountID.Int64
+		if math.Abs(m.Quantity-m.HistoryTotal) > inventoryTolerance {
+			report.Inventory = append(report.Inventory, m)
+		}
+	}
+	return rows.Err()
+}
+
+func repairIntegrityProblems(tx *sql.Tx, report *IntegrityReport) error {
+	for _, o := range report.Orphans {
+		query := fmt.Sprintf(`DELETE FROM %s WHERE %s IS NOT NULL AND NOT EXISTS (SELECT 1 FROM %s p WHERE p.id = %s.%s)`,
+			o.Table, o.Column, o.Parent, o.Table, o.Column)
+		if o.Repair == "set null" {
+			query = fmt.Sprintf(`UPDATE %s SET %s = NULL WHERE %s IS NOT NULL AND NOT EXISTS (SELECT 1 FROM %s p WHERE p.id = %s.%s)`,
+				o.Table, o.Column, o.Column, o.Parent, o.Table, o.Column)
+		}
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to repair %s.%s: %w", o.Table, o.Column, err)
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, m := range report.Inventory {
+		_, err := tx.Exec(`
+			INSERT INTO inventory_history (account_id, item_type, change_amount, quantity_before, quantity_after, reason, timestamp, notes)
+			VALUES (?, ?, ?, ?, ?, 'other', ?, ?)`,
+			sql.NullInt64{Int64: m.AccountID, Valid: m.AccountID != 0}, m.ItemType, m.Quantity-m.HistoryTotal, m.HistoryTotal, m.Quantity, now,
+			"Integrity repair: history brought in line with stock")
+		if err != nil {
+			return fmt.Errorf("failed to reconcile %s history: %w", m.ItemType, err)
+		}
+	}
+	return nil
+}
+
+func queryIDs(ctx context.Context, q querier, query string) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	// Orphans can only be written with foreign keys off, which is per
+	// connection
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	for _, stmt := range []string{
+		`PRAGMA foreign_keys = OFF`,
+		`INSERT INTO accounts (id, name) VALUES (1, 'Test')`,
+		`INSERT INTO courses (id, name, start_date, account_id) VALUES (1, 'Course', '2026-01-01', 1)`,
+		`INSERT INTO injections (id, course_id, side) VALUES (1, 1, 'left')`,
+		`INSERT INTO injections (id, course_id, side) VALUES (2, 99, 'right')`,
+		`INSERT INTO injections (id, course_id, side, lot_id) VALUES (3, 1, 'left', 42)`,
+		`INSERT INTO inventory_items (item_type, quantity, unit, account_id) VALUES ('swab', 10, 'count', 1)`,
+		`INSERT INTO inventory_history (item_type, change_amount, quantity_before, quantity_after, reason) VALUES ('swab', 12, 0, 12, 'restock')`,
+		`PRAGMA foreign_keys = ON`,
+	} {
+		if _, err := conn.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("Failed to seed %q: %v", stmt, err)
+		}
+	}
+	conn.Close()
+
+	report, err := db.CheckIntegrity(false)
+	if err != nil {
+		t.Fatalf("Failed to check integrity: %v", err)
+	}
+	if !report.EngineChecked || len(report.EngineProblems) != 0 {
+		t.Errorf("Expected a clean engine check, got %+v", report)
+	}
+	if len(report.Orphans) != 2 {
+		t.Fatalf("Expected 2 orphan reports, got %+v", report.Orphans)
+	}
+	if o := report.Orphans[0]; o.Table != "injections" || o.Column != "course_id" || o.IDs[0] != 2 || o.Repair != "delete" {
+		t.Errorf("Unexpected course orphan report: %+v", o)
+	}
+	if o := report.Orphans[1]; o.Column != "lot_id" || o.IDs[0] != 3 || o.Repair != "set null" {
+		t.Errorf("Unexpected lot orphan report: %+v", o)
+	}
+	if len(report.Inventory) != 1 || report.Inventory[0].Quantity != 10 || report.Inventory[0].HistoryTotal != 12 {
+		t.Errorf("Unexpected inventory mismatches: %+v", report.Inventory)
+	}
+
+	report, err = db.CheckIntegrity(true)
+	if err != nil {
+		t.Fatalf("Failed to repair: %v", err)
+	}
+	if !report.Repaired || report.OK() {
+		t.Errorf("Expected the repair to report what it fixed, got %+v", report)
+	}
+
+	report, err = db.CheckIntegrity(false)
+	if err != nil {
+		t.Fatalf("Failed to check integrity: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Expected no problems after repair, got %+v", report)
+	}
+
+	var injections int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM injections`).Scan(&injections); err != nil {
+		t.Fatalf("Failed to count injections: %v", err)
+	}
+	if injections != 2 {
+		t.Errorf("Expected the orphaned injection deleted and the others kept, got %d", injections)
+	}
+}
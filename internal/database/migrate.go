@@ -0,0 +1,325 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"injection-tracker/migrations"
+)
+
+// ErrUnknownMigrations means the database has migrations this binary doesn't
+// have, i.e. a newer version of the server has run against it. Starting
+// anyway could corrupt data the newer schema relies on.
+var ErrUnknownMigrations = errors.New("database schema is newer than this server")
+
+// ErrIrreversible is returned by Rollback for a migration without a down file
+var ErrIrreversible = errors.New("migration cannot be rolled back")
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version int
+	// Name is the up file's name, which is what schema_migrations records
+	Name string
+	Up   string
+	// Down undoes Up; empty if the migration can't be rolled back
+	Down string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt sql.NullTime
+	// Unknown is set for applied migrations this binary doesn't have
+	Unknown    bool
+	Reversible bool
+}
+
+// loadMigrations reads the dialect's migrations from the embedded files, in
+// version order
+func loadMigrations(d Dialect) ([]Migration, error) {
+	fsys, err := fs.Sub(migrations.FS, d.migrationsDir())
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	downs := make(map[int]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		prefix, _, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a version number and an underscore", name)
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		if strings.HasSuffix(name, ".down.sql") {
+			if _, dup := downs[version]; dup {
+				return nil, fmt.Errorf("migration %s: more than one down file for version %d", name, version)
+			}
+			downs[version] = string(content)
+			continue
+		}
+		if m, dup := byVersion[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", m.Name, name)
+		}
+		byVersion[version] = &Migration{Version: version, Name: name, Up: string(content)}
+	}
+
+	for version, down := range downs {
+		m, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("down migration for version %d has no up migration", version)
+		}
+		m.Down = down
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// RunMigrations applies pending migrations. It refuses to run against a
+// database that has migrations this binary doesn't know.
+func (db *DB) RunMigrations() error {
+	_, err := db.Migrate(false)
+	return err
+}
+
+// Migrate applies pending migrations in version order, each in its own
+// transaction, and returns them. With dryRun it runs them all in one
+// transaction and rolls it back, so mistakes show up without changing
+// anything.
+func (db *DB) Migrate(dryRun bool) ([]Migration, error) {
+	pending, err := db.pendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		for _, m := range pending {
+			if err := applyMigration(tx, m); err != nil {
+				return nil, fmt.Errorf("migration %s would fail: %w", m.Name, err)
+			}
+		}
+		return pending, nil
+	}
+
+	for _, m := range pending {
+		if err := db.inTx(func(tx *sql.Tx) error { return applyMigration(tx, m) }); err != nil {
+			return nil, fmt.Errorf("failed to apply migration %s: %w", m.Name, err)
+		}
+		slog.Info("Applied migration", "name", m.Name)
+	}
+	return pending, nil
+}
+
+// Rollback undoes the last steps applied migrations, newest first, and
+// returns them. Nothing is rolled back unless every one of them has a down
+// file. dryRun works as it does for Migrate.
+func (db *DB) Rollback(steps int, dryRun bool) ([]Migration, error) {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+	known, err := loadMigrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]Migration, len(known))
+	for _, m := range known {
+		byName[m.Name] = m
+	}
+
+	var undo []Migration
+	for i := len(statuses) - 1; i >= 0 && len(undo) < steps; i-- {
+		s := statuses[i]
+		if !s.Applied {
+			continue
+		}
+		if s.Unknown {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownMigrations, s.Name)
+		}
+		m := byName[s.Name]
+		if m.Down == "" {
+			return nil, fmt.Errorf("%w: %s has no down file", ErrIrreversible, m.Name)
+		}
+		undo = append(undo, m)
+	}
+
+	if dryRun {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		for _, m := range undo {
+			if err := revertMigration(tx, m); err != nil {
+				return nil, fmt.Errorf("rolling back %s would fail: %w", m.Name, err)
+			}
+		}
+		return undo, nil
+	}
+
+	for _, m := range undo {
+		if err := db.inTx(func(tx *sql.Tx) error { return revertMigration(tx, m) }); err != nil {
+			return nil, fmt.Errorf("failed to roll back migration %s: %w", m.Name, err)
+		}
+		slog.Info("Rolled back migration", "name", m.Name)
+	}
+	return undo, nil
+}
+
+// MigrationStatus lists every migration, known or applied, in version order
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	if err := db.createMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+	known, err := loadMigrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]sql.NullTime)
+	for rows.Next() {
+		var name string
+		var at sql.NullTime
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		applied[name] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(known))
+	for _, m := range known {
+		at, ok := applied[m.Name]
+		statuses = append(statuses, MigrationStatus{
+			Version:    m.Version,
+			Name:       m.Name,
+			Applied:    ok,
+			AppliedAt:  at,
+			Reversible: m.Down != "",
+		})
+		delete(applied, m.Name)
+	}
+	for name, at := range applied {
+		prefix, _, _ := strings.Cut(name, "_")
+		version, _ := strconv.Atoi(prefix)
+		statuses = append(statuses, MigrationStatus{
+			Version:   version,
+			Name:      name,
+			Applied:   true,
+			AppliedAt: at,
+			Unknown:   true,
+		})
+	}
+	sort.SliceStable(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// pendingMigrations returns the migrations still to apply, after checking
+// that the database has none this binary doesn't know
+func (db *DB) pendingMigrations() ([]Migration, error) {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+	pendingNames := make(map[string]bool)
+	for _, s := range statuses {
+		if s.Unknown {
+			unknown = append(unknown, s.Name)
+		} else if !s.Applied {
+			pendingNames[s.Name] = true
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMigrations, strings.Join(unknown, ", "))
+	}
+
+	known, err := loadMigrations(db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range known {
+		if pendingNames[m.Name] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+func (db *DB) createMigrationsTable() error {
+	_, err := db.Exec(db.Dialect.migrationsTable())
+	return err
+}
+
+func (db *DB) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func applyMigration(tx *sql.Tx, m Migration) error {
+	if _, err := tx.Exec(m.Up); err != nil {
+		return err
+	}
+	_, err := tx.Exec("INSERT INTO schema_migrations (name) VALUES (?)", m.Name)
+	return err
+}
+
+func revertMigration(tx *sql.Tx, m Migration) error {
+	if _, err := tx.Exec(m.Down); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM schema_migrations WHERE name = ?", m.Name)
+	return err
+}
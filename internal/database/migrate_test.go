@@ -0,0 +1,137 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func appliedCount(t *testing.T, db *DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&n); err != nil {
+		t.Fatalf("Failed to count applied migrations: %v", err)
+	}
+	return n
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for _, d := range []Dialect{sqliteDialect{}, postgresDialect{}} {
+		list, err := loadMigrations(d)
+		if err != nil {
+			t.Fatalf("%s: %v", d.Name(), err)
+		}
+		if len(list) == 0 || list[0].Version != 1 {
+			t.Fatalf("%s: expected migrations starting at version 1, got %d", d.Name(), len(list))
+		}
+		for i := 1; i < len(list); i++ {
+			if list[i].Version <= list[i-1].Version {
+				t.Errorf("%s: %s is out of order", d.Name(), list[i].Name)
+			}
+		}
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	db := openTestDB(t)
+
+	pending, err := db.Migrate(true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(pending) == 0 {
+		t.Fatal("Expected pending migrations on an empty database")
+	}
+	if n := appliedCount(t, db); n != 0 {
+		t.Errorf("Dry run applied %d migrations", n)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	total := appliedCount(t, db)
+
+	undone, err := db.Rollback(2, false)
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if len(undone) != 2 || undone[0].Version < undone[1].Version {
+		t.Fatalf("Expected the two newest migrations, newest first, got %+v", undone)
+	}
+	if n := appliedCount(t, db); n != total-2 {
+		t.Errorf("Expected %d applied migrations after rollback, got %d", total-2, n)
+	}
+
+	// Rolled back migrations apply again
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to reapply migrations: %v", err)
+	}
+	if n := appliedCount(t, db); n != total {
+		t.Errorf("Expected %d applied migrations, got %d", total, n)
+	}
+
+	// Every down file must work; rolling back past them must change nothing
+	reversible := 0
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	for i := len(statuses) - 1; i >= 0 && statuses[i].Reversible; i-- {
+		reversible++
+	}
+	if _, err := db.Rollback(reversible, true); err != nil {
+		t.Fatalf("Dry run of %d rollbacks failed: %v", reversible, err)
+	}
+	if _, err := db.Rollback(reversible+1, false); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("Expected ErrIrreversible, got %v", err)
+	}
+	if n := appliedCount(t, db); n != total {
+		t.Errorf("A refused rollback changed the schema: %d applied, want %d", n, total)
+	}
+
+	// Everything after 005, the schema before migrations could be undone,
+	// rolls back for real and applies again
+	if _, err := db.Rollback(reversible, false); err != nil {
+		t.Fatalf("Rollback of %d migrations failed: %v", reversible, err)
+	}
+	var last string
+	if err := db.QueryRow("SELECT name FROM schema_migrations ORDER BY name DESC LIMIT 1").Scan(&last); err != nil || last != "005_add_accounts_multi_user.sql" {
+		t.Errorf("Expected to roll back to 005, got %q (%v)", last, err)
+	}
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to reapply migrations: %v", err)
+	}
+	if n := appliedCount(t, db); n != total {
+		t.Errorf("Expected %d applied migrations, got %d", total, n)
+	}
+}
+
+func TestUnknownMigrationsRefused(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_migrations (name) VALUES ('999_from_the_future.sql')"); err != nil {
+		t.Fatalf("Failed to record migration: %v", err)
+	}
+
+	if err := db.RunMigrations(); !errors.Is(err, ErrUnknownMigrations) {
+		t.Fatalf("Expected ErrUnknownMigrations, got %v", err)
+	}
+	if _, err := db.Rollback(1, false); !errors.Is(err, ErrUnknownMigrations) {
+		t.Fatalf("Expected rollback to refuse too, got %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build postgres
+
+package database
+
+// Building with -tags postgres links the pgx driver for DATABASE_URL
+import _ "github.com/jackc/pgx/v5/stdlib"
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// busyTimeout is how long a write waits for another to finish before giving
+// up with "database is locked"
+const busyTimeout = 5 * time.Second
+
+// errWriteTimeout is what a write gets when the writer gate stays closed for
+// longer than busyTimeout, worded like SQLite's own error
+var errWriteTimeout = errors.New("database is locked: timed out waiting for another write")
+
+// sqliteDSN adds the connection settings every SQLite connection needs.
+// WAL lets readers carry on while a write is in progress, and
+// _txlock=immediate takes the write lock at BEGIN, so two transactions that
+// both read and then write wait their turn instead of deadlocking, which
+// SQLite reports as SQLITE_BUSY without waiting out the busy timeout.
+func sqliteDSN(path string) string {
+	return fmt.Sprintf("%s?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=%d&_synchronous=NORMAL&_cache_size=10000&_txlock=immediate",
+		path, busyTimeout.Milliseconds())
+}
+
+func openSQLite(path string) *sql.DB {
+	return sql.OpenDB(&serialConnector{
+		driver: &sqlite3.SQLiteDriver{},
+		dsn:    sqliteDSN(path),
+		gate:   make(chan struct{}, 1),
+	})
+}
+
+// serialConnector hands out connections that share one writer gate. SQLite
+// allows a single writer at a time; queueing writers here, in order and
+// without polling, keeps concurrent requests (an injection and the
+// inventory deduction it causes, say) from failing with "database is
+// locked". Reads don't go through the gate.
+type serialConnector struct {
+	driver *sqlite3.SQLiteDriver
+	dsn    string
+	gate   chan struct{}
+}
+
+func (c *serialConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &serialConn{Conn: conn, gate: c.gate}, nil
+}
+
+func (c *serialConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// serialConn takes the writer gate for each transaction and each statement
+// outside a transaction that may write
+type serialConn struct {
+	driver.Conn
+	gate chan struct{}
+	// inTx is set while this connection's transaction holds the gate, so
+	// statements inside it don't try to take it again
+	inTx bool
+}
+
+func (c *serialConn) acquire(ctx context.Context) error {
+	timer := time.NewTimer(busyTimeout)
+	defer timer.Stop()
+	select {
+	case c.gate <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return errWriteTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *serialConn) release() {
+	<-c.gate
+}
+
+func (c *serialConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	b, ok := c.Conn.(driver.ConnBeginTx)
+	if !ok {
+		return nil, errors.New("sqlite3 driver does not support BeginTx")
+	}
+	if opts.ReadOnly {
+		return b.BeginTx(ctx, opts)
+	}
+
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	tx, err := b.BeginTx(ctx, opts)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+	c.inTx = true
+	return &serialTx{Tx: tx, conn: c}, nil
+}
+
+func (c *serialConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *serialConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if c.inTx {
+		return e.ExecContext(ctx, query, args)
+	}
+
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return e.ExecContext(ctx, query, args)
+}
+
+// QueryContext lets SELECTs through and gates anything else, which in
+// practice means INSERT ... RETURNING. The gate is held until the rows are
+// closed, since SQLite keeps its write lock until then.
+func (c *serialConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if c.inTx || isReadQuery(query) {
+		return q.QueryContext(ctx, query, args)
+	}
+
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+	return &serialRows{Rows: rows, conn: c}, nil
+}
+
+func (c *serialConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+type serialTx struct {
+	driver.Tx
+	conn *serialConn
+}
+
+func (t *serialTx) Commit() error {
+	defer t.done()
+	return t.Tx.Commit()
+}
+
+func (t *serialTx) Rollback() error {
+	defer t.done()
+	return t.Tx.Rollback()
+}
+
+func (t *serialTx) done() {
+	t.conn.inTx = false
+	t.conn.release()
+}
+
+type serialRows struct {
+	driver.Rows
+	conn     *serialConn
+	released bool
+}
+
+func (r *serialRows) Close() error {
+	err := r.Rows.Close()
+	if !r.released {
+		r.released = true
+		r.conn.release()
+	}
+	return err
+}
+
+// isReadQuery reports whether query is a plain SELECT. Anything else,
+// including WITH, which can front an INSERT, is treated as a write.
+func isReadQuery(query string) bool {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	return len(query) >= 6 && strings.EqualFold(query[:6], "SELECT")
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSQLitePragmas(t *testing.T) {
+	db := openTestDB(t)
+
+	var journalMode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		t.Fatalf("Failed to read journal_mode: %v", err)
+	}
+	if journalMode != "wal" {
+		t.Errorf("Expected journal_mode wal, got %s", journalMode)
+	}
+
+	var foreignKeys, busyTimeoutMs int
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		t.Fatalf("Failed to read foreign_keys: %v", err)
+	}
+	if foreignKeys != 1 {
+		t.Errorf("Expected foreign_keys on, got %d", foreignKeys)
+	}
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeoutMs); err != nil {
+		t.Fatalf("Failed to read busy_timeout: %v", err)
+	}
+	if busyTimeoutMs != int(busyTimeout.Milliseconds()) {
+		t.Errorf("Expected busy_timeout %d, got %d", busyTimeout.Milliseconds(), busyTimeoutMs)
+	}
+}
+
+// TestConcurrentWrites runs read-then-write transactions alongside plain
+// inserts. Without the writer gate, deferred transactions deadlock on the
+// lock upgrade and SQLite fails one of them straight away.
+func TestConcurrentWrites(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec("CREATE TABLE counter (n INTEGER NOT NULL)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO counter (n) VALUES (0)"); err != nil {
+		t.Fatalf("Failed to seed table: %v", err)
+	}
+	if _, err := db.Exec("CREATE TABLE log (id INTEGER PRIMARY KEY, note TEXT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*2)
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			tx, err := db.Begin()
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer tx.Rollback()
+
+			var n int
+			if err := tx.QueryRow("SELECT n FROM counter").Scan(&n); err != nil {
+				errs <- err
+				return
+			}
+			// Give the other transactions time to take their snapshots
+			time.Sleep(time.Millisecond)
+			if _, err := tx.Exec("UPDATE counter SET n = ?", n+1); err != nil {
+				errs <- err
+				return
+			}
+			errs <- tx.Commit()
+		}()
+		go func() {
+			defer wg.Done()
+			var id int64
+			errs <- db.QueryRow("INSERT INTO log (note) VALUES ('x') RETURNING id").Scan(&id)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent write failed: %v", err)
+		}
+	}
+
+	var n, logged int
+	if err := db.QueryRow("SELECT n FROM counter").Scan(&n); err != nil {
+		t.Fatalf("Failed to read counter: %v", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM log").Scan(&logged); err != nil {
+		t.Fatalf("Failed to count log: %v", err)
+	}
+	if n != workers || logged != workers {
+		t.Errorf("Expected %d updates and %d inserts, got %d and %d", workers, workers, n, logged)
+	}
+}
+
+func TestIsReadQuery(t *testing.T) {
+	tests := map[string]bool{
+		"SELECT 1":                                           true,
+		"\n\t\tselect id FROM users":                         true,
+		"(SELECT 1) UNION (SELECT 2)":                        true,
+		"INSERT INTO t VALUES (1) RETURNING id":              false,
+		"WITH x AS (SELECT 1) INSERT INTO t SELECT * FROM x": false,
+		"PRAGMA journal_mode":                                false,
+	}
+	for query, want := range tests {
+		if got := isReadQuery(query); got != want {
+			t.Errorf("isReadQuery(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
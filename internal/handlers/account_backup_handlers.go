@@ -0,0 +1,583 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// accountBackupExtension is the extension of a plain account backup, which
+// holds the same JSON as an account export
+const accountBackupExtension = ".json"
+
+// ErrInvalidAccountBackup is returned when an account backup can't be
+// restored because of what is in it
+var ErrInvalidAccountBackup = errors.New("invalid account backup")
+
+// AccountBackupInfo describes an account backup file. AccountID is the
+// account it was taken from, or 0 for an uploaded backup.
+type AccountBackupInfo struct {
+	BackupInfo
+	AccountID int64 `json:"account_id,omitempty"`
+}
+
+// getAccountBackupDir returns the account backup directory path, creating it
+// if needed. It sits inside the backup directory but is never mistaken for a
+// database backup, which are all files.
+func getAccountBackupDir() (string, error) {
+	backupDir, err := getBackupDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(backupDir, "accounts")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create account backup directory: %w", err)
+	}
+	return dir, nil
+}
+
+// accountBackupPath resolves the name of an account backup in dir, refusing
+// anything that isn't one
+func accountBackupPath(dir, name string) (string, error) {
+	name = filepath.Base(name)
+	if !services.IsBackupName(name, accountBackupExtension) {
+		return "", errors.New("Invalid account backup file")
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// newAccountBackupInfo describes an account backup file, reading the account
+// from names like account-12_2026-01-01_000000.json
+func newAccountBackupInfo(name, path string, size int64, modTime time.Time) AccountBackupInfo {
+	info := AccountBackupInfo{BackupInfo: newBackupInfo(name, path, size, modTime)}
+	if rest, ok := strings.CutPrefix(name, "account-"); ok {
+		if id, _, ok := strings.Cut(rest, "_"); ok {
+			info.AccountID, _ = strconv.ParseInt(id, 10, 64)
+		}
+	}
+	return info
+}
+
+// latestAccountBackupSizes maps each account to the size of its newest
+// backup. Uploaded backups belong to no account and are left out.
+func latestAccountBackupSizes() map[int64]int64 {
+	sizes := make(map[int64]int64)
+	dir, err := getAccountBackupDir()
+	if err != nil {
+		return sizes
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return sizes
+	}
+	newest := make(map[int64]time.Time)
+	for _, entry := range entries {
+		if entry.IsDir() || !services.IsBackupName(entry.Name(), accountBackupExtension) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backup := newAccountBackupInfo(entry.Name(), "", info.Size(), info.ModTime())
+		if backup.AccountID == 0 || info.ModTime().Before(newest[backup.AccountID]) {
+			continue
+		}
+		newest[backup.AccountID] = info.ModTime()
+		sizes[backup.AccountID] = info.Size()
+	}
+	return sizes
+}
+
+// CreateAccountBackup writes everything in an account to a file in the
+// account backup directory, compressed and encrypted as codec says. Unlike
+// database backups, the key is chosen per backup and never stored, so each
+// account handed off can have its own passphrase.
+func CreateAccountBackup(db *database.DB, accountID int64, codec services.BackupCodec) (*AccountBackupInfo, error) {
+	dir, err := getAccountBackupDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := codec.Validate(); err != nil {
+		return nil, err
+	}
+
+	data, err := ExportAccountData(db, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := data.ExportedAt.Format("2006-01-02_150405")
+	plainPath := filepath.Join(dir, fmt.Sprintf("account-%d_%s%s", accountID, timestamp, accountBackupExtension))
+	backupPath := plainPath + codec.Suffix()
+
+	f, err := os.OpenFile(plainPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account backup: %w", err)
+	}
+	err = json.NewEncoder(f).Encode(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(plainPath)
+		return nil, fmt.Errorf("failed to write account backup: %w", err)
+	}
+
+	if backupPath != plainPath {
+		err := codec.Encode(plainPath, backupPath)
+		os.Remove(plainPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode account backup: %w", err)
+		}
+	}
+
+	stat, err := os.Stat(backupPath)
+	if err != nil {
+		return nil, fmt.Errorf("account backup created but failed to get info: %w", err)
+	}
+	info := newAccountBackupInfo(filepath.Base(backupPath), backupPath, stat.Size(), stat.ModTime())
+	return &info, nil
+}
+
+// readAccountBackup decodes and checks the account backup at path
+func readAccountBackup(path, passphrase string) (*AccountData, error) {
+	src := path
+	if _, compressed, encrypted := services.SplitBackupName(path); compressed || encrypted {
+		tmp, err := os.CreateTemp(filepath.Dir(path), ".decoded-*")
+		if err != nil {
+			return nil, err
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+
+		if err := (services.BackupCodec{Passphrase: passphrase}).Decode(path, tmp.Name()); err != nil {
+			return nil, err
+		}
+		src = tmp.Name()
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var data AccountData
+	if err := json.NewDecoder(io.LimitReader(f, maxAccountDataBytes)).Decode(&data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAccountBackup, err)
+	}
+	if err := checkAccountData(&data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAccountBackup, err)
+	}
+	return &data, nil
+}
+
+// RestoreAccountBackup restores the account backup at path into a new
+// account, leaving every other account alone. The new account has no members
+// yet, so references to users in the backup are left empty and their
+// preferences aren't restored. It returns the new account's ID and how many
+// records of each kind were created.
+func RestoreAccountBackup(db *database.DB, path, passphrase string) (int64, map[string]int, error) {
+	data, err := readAccountBackup(path, passphrase)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	tx, err := db.BeginTx()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Left unnamed so the backup's name is taken, and without the default
+	// item types, which the backup's replace
+	var accountID int64
+	err = tx.QueryRow(`
+		INSERT INTO accounts (created_at, updated_at)
+		VALUES (CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id
+	`).Scan(&accountID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create account: %w", err)
+	}
+
+	counts, err := restoreAccountData(tx, accountID, 0, data, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to restore account data: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return accountID, counts, nil
+}
+
+// HandleListAccountBackups returns the account backups, newest first
+func HandleListAccountBackups(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		dir, err := getAccountBackupDir()
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			respond.Error(w, "Failed to list account backups", http.StatusInternalServerError)
+			return
+		}
+
+		backups := []AccountBackupInfo{}
+		for _, entry := range entries {
+			if entry.IsDir() || !services.IsBackupName(entry.Name(), accountBackupExtension) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			backups = append(backups, newAccountBackupInfo(entry.Name(), filepath.Join(dir, entry.Name()), info.Size(), info.ModTime()))
+		}
+
+		sort.Slice(backups, func(i, j int) bool {
+			return backups[i].CreatedAt > backups[j].CreatedAt
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(backups)
+	}
+}
+
+// CreateAccountBackupRequest names the account to back up. A passphrase
+// encrypts the backup; it isn't kept, so it must be given again to restore.
+type CreateAccountBackupRequest struct {
+	AccountID  int64  `json:"account_id" validate:"required,min=1"`
+	Compress   bool   `json:"compress"`
+	Passphrase string `json:"passphrase,omitempty" validate:"raw"`
+}
+
+// HandleCreateAccountBackup backs up a single account. A backup larger than
+// the account's backup quota is thrown away and answered with 413.
+func HandleCreateAccountBackup(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req CreateAccountBackupRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Passphrase != "" && len(req.Passphrase) < 12 {
+			respond.Error(w, "Backup passphrase must be at least 12 characters", http.StatusBadRequest)
+			return
+		}
+
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM accounts WHERE id = ?)`, req.AccountID).Scan(&exists); err != nil {
+			respond.Error(w, "Failed to find account", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			respond.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+
+		backup, err := CreateAccountBackup(db, req.AccountID, services.BackupCodec{Compress: req.Compress, Passphrase: req.Passphrase})
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to back up account", "account_id", req.AccountID, "err", err)
+			respond.Error(w, "Failed to back up account: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := services.CheckBackupQuota(db, req.AccountID, backup.Size); !checkQuota(w, r, err, http.StatusRequestEntityTooLarge) {
+			os.Remove(backup.Path)
+			return
+		}
+
+		_ = repository.NewAuditRepository(db).LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"backup",
+			"account",
+			sql.NullInt64{Int64: req.AccountID, Valid: true},
+			map[string]interface{}{"filename": backup.Filename, "encrypted": backup.Encrypted},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Account backup created successfully",
+			"backup":  backup,
+		})
+	}
+}
+
+// HandleDownloadAccountBackup downloads an account backup as stored, so an
+// encrypted backup stays encrypted on its way to another instance
+func HandleDownloadAccountBackup(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		filename := r.URL.Query().Get("file")
+		if filename == "" {
+			respond.Error(w, "Filename required", http.StatusBadRequest)
+			return
+		}
+		dir, err := getAccountBackupDir()
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		path, err := accountBackupPath(dir, filename)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			respond.Error(w, "Account backup not found", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+		info, _ := file.Stat()
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(path)))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size()))
+		_, _ = io.Copy(w, file)
+	}
+}
+
+// HandleDeleteAccountBackup deletes an account backup
+func HandleDeleteAccountBackup(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req DeleteBackupRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		dir, err := getAccountBackupDir()
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		path, err := accountBackupPath(dir, req.Filename)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				respond.Error(w, "Account backup not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete account backup", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Account backup deleted successfully",
+			"success": true,
+		})
+	}
+}
+
+// HandleUploadAccountBackup stores an account backup taken on another
+// instance, so it can be restored here. It is checked when restored, since
+// an encrypted one can't be read without its passphrase.
+func HandleUploadAccountBackup(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxAccountDataBytes)
+		file, header, err := r.FormFile("backup")
+		if err != nil {
+			respond.Error(w, "No backup file provided", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		uploaded := filepath.Base(header.Filename)
+		if !services.IsBackupName(uploaded, accountBackupExtension) {
+			respond.Error(w, "Invalid file type. Expected an account backup (.json, optionally .gz or .age)", http.StatusBadRequest)
+			return
+		}
+		// Keep the codec suffixes, which say how to decode it
+		plain, _, _ := services.SplitBackupName(uploaded)
+		suffix := strings.TrimPrefix(uploaded, plain)
+
+		dir, err := getAccountBackupDir()
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		name := fmt.Sprintf("upload_%s%s%s", time.Now().Format("2006-01-02_150405"), accountBackupExtension, suffix)
+		path := filepath.Join(dir, name)
+
+		out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			respond.Error(w, "Failed to save account backup", http.StatusInternalServerError)
+			return
+		}
+		_, err = io.Copy(out, file)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+			respond.Error(w, "Failed to save account backup", http.StatusInternalServerError)
+			return
+		}
+
+		stat, err := os.Stat(path)
+		if err != nil {
+			respond.Error(w, "Failed to save account backup", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Account backup uploaded",
+			"backup":  newAccountBackupInfo(name, path, stat.Size(), stat.ModTime()),
+		})
+	}
+}
+
+// RestoreAccountBackupRequest names the account backup to restore.
+// OwnerEmail, if set, invites someone to register as the new account's owner.
+type RestoreAccountBackupRequest struct {
+	Filename   string `json:"filename" validate:"required,max=255"`
+	Passphrase string `json:"passphrase,omitempty" validate:"raw"`
+	OwnerEmail string `json:"owner_email,omitempty" validate:"max=254"`
+}
+
+// RestoreAccountBackupResponse describes the account a backup was restored
+// into. InviteToken is the owner invitation's token, when one was asked for.
+type RestoreAccountBackupResponse struct {
+	AccountID   int64          `json:"account_id"`
+	Imported    map[string]int `json:"imported"`
+	InviteToken string         `json:"invite_token,omitempty"`
+}
+
+// HandleRestoreAccountBackup restores an account backup into a new account
+func HandleRestoreAccountBackup(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req RestoreAccountBackupRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.OwnerEmail != "" {
+			existing, err := repository.NewUserRepository(db).GetByUsername(req.OwnerEmail)
+			if err == nil && existing != nil {
+				respond.Error(w, "A user with this email already exists", http.StatusConflict)
+				return
+			}
+		}
+
+		dir, err := getAccountBackupDir()
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		path, err := accountBackupPath(dir, req.Filename)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			respond.Error(w, "Account backup not found", http.StatusNotFound)
+			return
+		}
+
+		accountID, counts, err := RestoreAccountBackup(db, path, req.Passphrase)
+		if services.IsBackupKeyError(err) || errors.Is(err, ErrInvalidAccountBackup) {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to restore account backup", "filename", req.Filename, "err", err)
+			respond.Error(w, "Failed to restore account backup: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := RestoreAccountBackupResponse{AccountID: accountID, Imported: counts}
+		if req.OwnerEmail != "" {
+			expiresAt := time.Now().Add(7 * 24 * time.Hour)
+			token, err := repository.NewAccountRepository(db.DB).CreateOwnerInvitation(accountID, req.OwnerEmail, userID, expiresAt)
+			if err != nil {
+				// The account is there; the admin can still hand it over by
+				// other means
+				middleware.Log(r.Context()).Error("Failed to invite restored account owner", "account_id", accountID, "err", err)
+			}
+			response.InviteToken = token
+		}
+
+		_ = repository.NewAuditRepository(db).LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"restore",
+			"account",
+			sql.NullInt64{Int64: accountID, Valid: true},
+			map[string]interface{}{
+				"filename":    filepath.Base(path),
+				"owner_email": req.OwnerEmail,
+				"imported":    counts,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/services"
+)
+
+func TestAccountBackupRestoresIntoNewAccount(t *testing.T) {
+	t.Chdir(t.TempDir())
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	user := &models.User{Username: "owner", PasswordHash: "$2a$12$hash", IsActive: true}
+	if err := repository.NewUserRepository(db).Create(user); err != nil {
+		t.Fatal(err)
+	}
+	name := "Household"
+	accountID, err := repository.NewAccountRepository(db.DB).Create(&name, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO courses (account_id, name, start_date, is_active, created_by, created_at, updated_at)
+		VALUES (?, 'Spring course', ?, 1, ?, ?, ?)
+	`, accountID, time.Now(), user.ID, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := CreateAccountBackup(db, accountID, services.BackupCodec{Compress: true, Passphrase: "a long account passphrase"})
+	if err != nil {
+		t.Fatalf("CreateAccountBackup failed: %v", err)
+	}
+	if !backup.Encrypted || !backup.Compressed || backup.AccountID != accountID {
+		t.Errorf("Backup = %+v, want compressed and encrypted from account %d", backup, accountID)
+	}
+
+	if _, _, err := RestoreAccountBackup(db, backup.Path, "not the passphrase"); !services.IsBackupKeyError(err) {
+		t.Fatalf("Restore with the wrong passphrase = %v, want a key error", err)
+	}
+
+	restoredID, counts, err := RestoreAccountBackup(db, backup.Path, "a long account passphrase")
+	if err != nil {
+		t.Fatalf("RestoreAccountBackup failed: %v", err)
+	}
+	if restoredID == accountID {
+		t.Fatal("Backup restored over the original account")
+	}
+	if counts["courses"] != 1 {
+		t.Errorf("Restored %d courses, want 1", counts["courses"])
+	}
+
+	var restoredName string
+	var members, courses int
+	err = db.QueryRow(`
+		SELECT a.name,
+		       (SELECT COUNT(*) FROM account_members WHERE account_id = a.id),
+		       (SELECT COUNT(*) FROM courses WHERE account_id = a.id AND created_by IS NULL)
+		FROM accounts a WHERE a.id = ?
+	`, restoredID).Scan(&restoredName, &members, &courses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restoredName != name || members != 0 || courses != 1 {
+		t.Errorf("Restored account = %q with %d members and %d unowned courses", restoredName, members, courses)
+	}
+}
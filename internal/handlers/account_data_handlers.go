@@ -0,0 +1,1685 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+const (
+	// accountDataFormat identifies a full account export
+	accountDataFormat = "p-track-account"
+	// accountDataVersion is bumped when the export layout changes incompatibly
+	accountDataVersion = 1
+	// maxAccountDataBytes caps the size of an account import
+	maxAccountDataBytes = 50 << 20
+)
+
+// AccountData is a complete, machine-readable dump of one account. Records
+// keep their original IDs so references between them (an injection's course,
+// a course's compound, ...) can be rebuilt on import; user references point
+// into Users and are matched by username. Attachments and inventory history
+// are not included.
+type AccountData struct {
+	Format             string                       `json:"format"`
+	Version            int                          `json:"version"`
+	ExportedAt         time.Time                    `json:"exported_at"`
+	AccountName        *string                      `json:"account_name,omitempty"`
+	Users              []AccountDataUser            `json:"users"`
+	Settings           map[string]string            `json:"settings"`
+	InventoryItemTypes []AccountDataItemType        `json:"inventory_item_types"`
+	Inventory          []AccountDataInventoryItem   `json:"inventory"`
+	InventoryLots      []AccountDataLot             `json:"inventory_lots"`
+	LotConsumptions    []AccountDataLotConsumption  `json:"lot_consumptions"`
+	Vials              []AccountDataVial            `json:"vials,omitempty"`
+	VialConsumptions   []AccountDataVialConsumption `json:"vial_consumptions,omitempty"`
+	BarcodeMappings    []AccountDataBarcodeMapping  `json:"barcode_mappings,omitempty"`
+	Compounds          []AccountDataCompound        `json:"compounds"`
+	Courses            []AccountDataCourse          `json:"courses"`
+	DoseSteps          []AccountDataDoseStep        `json:"dose_steps,omitempty"`
+	CourseSchedules    []AccountDataCourseSchedule  `json:"course_schedules,omitempty"`
+	MissedInjections   []AccountDataMissedInjection `json:"missed_injections,omitempty"`
+	Injections         []AccountDataInjection       `json:"injections"`
+	SymptomCatalog     []AccountDataSymptomType     `json:"symptom_catalog,omitempty"`
+	Symptoms           []AccountDataSymptom         `json:"symptoms"`
+	Medications        []AccountDataMedication      `json:"medications"`
+	MedicationLogs     []AccountDataMedicationLog   `json:"medication_logs"`
+	PurchaseOrders     []AccountDataPurchaseOrder   `json:"purchase_orders"`
+	Vitals             []AccountDataVital           `json:"vitals"`
+	CheckIns           []AccountDataCheckIn         `json:"checkins,omitempty"`
+	Journal            []AccountDataJournalEntry    `json:"journal,omitempty"`
+	LabResults         []AccountDataLabResult       `json:"lab_results,omitempty"`
+	Trips              []AccountDataTrip            `json:"trips,omitempty"`
+	Appointments       []AccountDataAppointment     `json:"appointments"`
+}
+
+// AccountDataUser is a member of the exported account
+type AccountDataUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// AccountDataItemType is an inventory item type
+type AccountDataItemType struct {
+	ItemType              string   `json:"item_type"`
+	Name                  string   `json:"name"`
+	Unit                  string   `json:"unit"`
+	DecrementPerInjection float64  `json:"decrement_per_injection"`
+	ReorderThreshold      *float64 `json:"reorder_threshold,omitempty"`
+	PurchaseUnit          *string  `json:"purchase_unit,omitempty"`
+	UnitsPerPurchase      *float64 `json:"units_per_purchase,omitempty"`
+	SortOrder             int      `json:"sort_order"`
+}
+
+// AccountDataInventoryItem is the stock level of one item type
+type AccountDataInventoryItem struct {
+	ItemType          string     `json:"item_type"`
+	Quantity          float64    `json:"quantity"`
+	Unit              string     `json:"unit"`
+	ExpirationDate    *time.Time `json:"expiration_date,omitempty"`
+	LotNumber         *string    `json:"lot_number,omitempty"`
+	LowStockThreshold *float64   `json:"low_stock_threshold,omitempty"`
+	Notes             *string    `json:"notes,omitempty"`
+}
+
+// AccountDataLot is an inventory lot
+type AccountDataLot struct {
+	ID                int64      `json:"id"`
+	ItemType          string     `json:"item_type"`
+	LotNumber         *string    `json:"lot_number,omitempty"`
+	ExpirationDate    *time.Time `json:"expiration_date,omitempty"`
+	QuantityReceived  float64    `json:"quantity_received"`
+	QuantityRemaining float64    `json:"quantity_remaining"`
+	ReceivedAt        time.Time  `json:"received_at"`
+	Notes             *string    `json:"notes,omitempty"`
+}
+
+// AccountDataLotConsumption is an amount drawn from a lot, usually by an injection
+type AccountDataLotConsumption struct {
+	LotID       int64      `json:"lot_id"`
+	InjectionID *int64     `json:"injection_id,omitempty"`
+	Amount      float64    `json:"amount"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataVial is a medication vial
+type AccountDataVial struct {
+	ID               int64      `json:"id"`
+	ItemType         string     `json:"item_type"`
+	LotID            *int64     `json:"lot_id,omitempty"`
+	Label            *string    `json:"label,omitempty"`
+	VolumeML         float64    `json:"volume_ml"`
+	RemainingML      float64    `json:"remaining_ml"`
+	DiscardAfterDays int        `json:"discard_after_days"`
+	Status           string     `json:"status"`
+	OpenedAt         *time.Time `json:"opened_at,omitempty"`
+	DiscardAt        *time.Time `json:"discard_at,omitempty"`
+	ClosedAt         *time.Time `json:"closed_at,omitempty"`
+	Notes            *string    `json:"notes,omitempty"`
+	CreatedAt        *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataVialConsumption is an amount an injection drew from a vial
+type AccountDataVialConsumption struct {
+	VialID      int64      `json:"vial_id"`
+	InjectionID int64      `json:"injection_id"`
+	Amount      float64    `json:"amount"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataBarcodeMapping is a scanned code mapped to an item type
+type AccountDataBarcodeMapping struct {
+	Code      string     `json:"code"`
+	ItemType  string     `json:"item_type"`
+	Label     *string    `json:"label,omitempty"`
+	CreatedBy *int64     `json:"created_by,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataCompound is an injectable medication
+type AccountDataCompound struct {
+	ID                   int64      `json:"id"`
+	Name                 string     `json:"name"`
+	ConcentrationMgPerML *float64   `json:"concentration_mg_per_ml,omitempty"`
+	Route                string     `json:"route"`
+	InventoryItemType    string     `json:"inventory_item_type"`
+	DefaultDoseML        *float64   `json:"default_dose_ml,omitempty"`
+	Notes                *string    `json:"notes,omitempty"`
+	IsActive             bool       `json:"is_active"`
+	CreatedBy            *int64     `json:"created_by,omitempty"`
+	CreatedAt            *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataCourse is a treatment course
+type AccountDataCourse struct {
+	ID                   int64      `json:"id"`
+	Name                 string     `json:"name"`
+	StartDate            time.Time  `json:"start_date"`
+	ExpectedEndDate      *time.Time `json:"expected_end_date,omitempty"`
+	ActualEndDate        *time.Time `json:"actual_end_date,omitempty"`
+	IsActive             bool       `json:"is_active"`
+	Notes                *string    `json:"notes,omitempty"`
+	DoseML               *float64   `json:"dose_ml,omitempty"`
+	ConcentrationMgPerML *float64   `json:"concentration_mg_per_ml,omitempty"`
+	CompoundID           *int64     `json:"compound_id,omitempty"`
+	CreatedBy            *int64     `json:"created_by,omitempty"`
+	CreatedAt            *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataDoseStep is one step of a course's taper schedule
+type AccountDataDoseStep struct {
+	CourseID  int64      `json:"course_id"`
+	StartDate time.Time  `json:"start_date"`
+	EndDate   *time.Time `json:"end_date,omitempty"`
+	DoseML    float64    `json:"dose_ml"`
+}
+
+// AccountDataCourseSchedule is when a course's injections fall due
+type AccountDataCourseSchedule struct {
+	CourseID      int64   `json:"course_id"`
+	IntervalHours *int64  `json:"interval_hours,omitempty"`
+	Weekdays      *string `json:"weekdays,omitempty"` // e.g. "mon,wed,fri"
+	TimeOfDay     *string `json:"time_of_day,omitempty"`
+}
+
+// AccountDataMissedInjection is a dose that fell due and wasn't logged,
+// with why it was skipped
+type AccountDataMissedInjection struct {
+	CourseID    int64      `json:"course_id"`
+	DueAt       time.Time  `json:"due_at"`
+	Reason      *string    `json:"reason,omitempty"`
+	Note        *string    `json:"note,omitempty"`
+	AnnotatedBy *int64     `json:"annotated_by,omitempty"`
+	AnnotatedAt *time.Time `json:"annotated_at,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataInjection is an injection, including voided ones
+type AccountDataInjection struct {
+	ID             int64      `json:"id"`
+	CourseID       int64      `json:"course_id"`
+	AdministeredBy *int64     `json:"administered_by,omitempty"`
+	Timestamp      time.Time  `json:"timestamp"`
+	Side           string     `json:"side"`
+	SiteX          *float64   `json:"site_x,omitempty"`
+	SiteY          *float64   `json:"site_y,omitempty"`
+	PainLevel      *int       `json:"pain_level,omitempty"`
+	HasKnots       bool       `json:"has_knots"`
+	SiteReaction   *string    `json:"site_reaction,omitempty"`
+	Notes          *string    `json:"notes,omitempty"`
+	DoseML         *float64   `json:"dose_ml,omitempty"`
+	LotID          *int64     `json:"lot_id,omitempty"`
+	VoidedAt       *time.Time `json:"voided_at,omitempty"`
+	VoidedBy       *int64     `json:"voided_by,omitempty"`
+	VoidReason     *string    `json:"void_reason,omitempty"`
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataSymptomType is an entry in the account's symptom catalog
+type AccountDataSymptomType struct {
+	Key        string `json:"key"`
+	Name       string `json:"name"`
+	Category   string `json:"category"`
+	ScaleType  string `json:"scale_type"`
+	SortOrder  int    `json:"sort_order"`
+	IsArchived bool   `json:"is_archived,omitempty"`
+}
+
+// AccountDataSymptomItem is a catalog symptom recorded on a symptom log
+type AccountDataSymptomItem struct {
+	Key      string `json:"key"`
+	Severity *int   `json:"severity,omitempty"`
+}
+
+// AccountDataSymptom is a symptom log
+type AccountDataSymptom struct {
+	ID           int64                    `json:"id"`
+	CourseID     int64                    `json:"course_id"`
+	LoggedBy     *int64                   `json:"logged_by,omitempty"`
+	Timestamp    time.Time                `json:"timestamp"`
+	PainLevel    *int                     `json:"pain_level,omitempty"`
+	PainLocation *string                  `json:"pain_location,omitempty"`
+	PainType     *string                  `json:"pain_type,omitempty"`
+	HasKnots     bool                     `json:"has_knots"`
+	Symptoms     *string                  `json:"symptoms,omitempty"` // JSON array, as stored
+	Items        []AccountDataSymptomItem `json:"items,omitempty"`
+	DissipatedAt *time.Time               `json:"dissipated_at,omitempty"`
+	Notes        *string                  `json:"notes,omitempty"`
+	Visibility   string                   `json:"visibility,omitempty"` // 'private' is kept from other members
+	CreatedAt    *time.Time               `json:"created_at,omitempty"`
+}
+
+// AccountDataMedication is a non-injection medication
+type AccountDataMedication struct {
+	ID                int64      `json:"id"`
+	Name              string     `json:"name"`
+	Dosage            *string    `json:"dosage,omitempty"`
+	Frequency         *string    `json:"frequency,omitempty"`
+	StartDate         *time.Time `json:"start_date,omitempty"`
+	EndDate           *time.Time `json:"end_date,omitempty"`
+	IsActive          bool       `json:"is_active"`
+	Notes             *string    `json:"notes,omitempty"`
+	ScheduledTime     *string    `json:"scheduled_time,omitempty"`
+	DoseTimes         []string   `json:"dose_times,omitempty"`
+	TimeWindowMinutes *int       `json:"time_window_minutes,omitempty"`
+	ReminderEnabled   bool       `json:"reminder_enabled"`
+	InventoryItemType *string    `json:"inventory_item_type,omitempty"`
+	UnitsPerDose      *float64   `json:"units_per_dose,omitempty"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataMedicationLog is a taken or missed medication dose
+type AccountDataMedicationLog struct {
+	MedicationID int64      `json:"medication_id"`
+	LoggedBy     *int64     `json:"logged_by,omitempty"`
+	Timestamp    time.Time  `json:"timestamp"`
+	Taken        bool       `json:"taken"`
+	Late         bool       `json:"late,omitempty"`
+	Notes        *string    `json:"notes,omitempty"`
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataVital is a body measurement
+type AccountDataVital struct {
+	RecordedBy *int64     `json:"recorded_by,omitempty"`
+	Timestamp  time.Time  `json:"timestamp"`
+	Type       string     `json:"type"`
+	Value      float64    `json:"value"`
+	Unit       string     `json:"unit"`
+	Source     string     `json:"source"`
+	Notes      *string    `json:"notes,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataCheckIn is a member's daily wellness check-in
+type AccountDataCheckIn struct {
+	UserID      *int64     `json:"user_id,omitempty"`
+	Date        string     `json:"date"` // YYYY-MM-DD
+	Mood        *int64     `json:"mood,omitempty"`
+	Energy      *int64     `json:"energy,omitempty"`
+	SleepHours  *float64   `json:"sleep_hours,omitempty"`
+	Temperature *float64   `json:"temperature,omitempty"`
+	Weight      *float64   `json:"weight,omitempty"`
+	Notes       *string    `json:"notes,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataJournalEntry is a journal entry with its tags
+type AccountDataJournalEntry struct {
+	ID        int64      `json:"id"`
+	AuthorID  *int64     `json:"author_id,omitempty"`
+	CourseID  *int64     `json:"course_id,omitempty"`
+	EntryDate *string    `json:"entry_date,omitempty"` // YYYY-MM-DD
+	Title     *string    `json:"title,omitempty"`
+	Body      string     `json:"body"`
+	Tags      []string   `json:"tags,omitempty"`
+	IsPinned  bool       `json:"is_pinned,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataLabResult is one measurement from a blood draw
+type AccountDataLabResult struct {
+	CourseID      *int64     `json:"course_id,omitempty"`
+	Analyte       string     `json:"analyte"`
+	Value         float64    `json:"value"`
+	Unit          string     `json:"unit"`
+	ReferenceLow  *float64   `json:"reference_low,omitempty"`
+	ReferenceHigh *float64   `json:"reference_high,omitempty"`
+	DrawnAt       time.Time  `json:"drawn_at"`
+	Notes         *string    `json:"notes,omitempty"`
+	CreatedBy     *int64     `json:"created_by,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataTrip is time spent away in another timezone
+type AccountDataTrip struct {
+	Destination  string     `json:"destination"`
+	Timezone     string     `json:"timezone"`
+	HomeTimezone string     `json:"home_timezone"`
+	StartsAt     time.Time  `json:"starts_at"`
+	EndsAt       time.Time  `json:"ends_at"`
+	Shift        string     `json:"shift"`
+	Notes        *string    `json:"notes,omitempty"`
+	CreatedBy    *int64     `json:"created_by,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataAppointment is a clinic visit or other dated event
+type AccountDataAppointment struct {
+	Title     string     `json:"title"`
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Location  *string    `json:"location,omitempty"`
+	Notes     *string    `json:"notes,omitempty"`
+	CreatedBy *int64     `json:"created_by,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// AccountDataPurchaseOrder is a supply order with its line items
+type AccountDataPurchaseOrder struct {
+	ID              int64                          `json:"id"`
+	Supplier        string                         `json:"supplier"`
+	OrderNumber     *string                        `json:"order_number,omitempty"`
+	Status          string                         `json:"status"`
+	OrderedAt       time.Time                      `json:"ordered_at"`
+	ExpectedArrival *time.Time                     `json:"expected_arrival,omitempty"`
+	ReceivedAt      *time.Time                     `json:"received_at,omitempty"`
+	ShippingCost    float64                        `json:"shipping_cost"`
+	Notes           *string                        `json:"notes,omitempty"`
+	CreatedBy       *int64                         `json:"created_by,omitempty"`
+	CreatedAt       *time.Time                     `json:"created_at,omitempty"`
+	Items           []AccountDataPurchaseOrderItem `json:"items"`
+}
+
+// AccountDataPurchaseOrderItem is one line of a purchase order
+type AccountDataPurchaseOrderItem struct {
+	ItemType       string     `json:"item_type"`
+	Quantity       float64    `json:"quantity"`
+	UnitCost       float64    `json:"unit_cost"`
+	LotNumber      *string    `json:"lot_number,omitempty"`
+	ExpirationDate *time.Time `json:"expiration_date,omitempty"`
+	LotID          *int64     `json:"lot_id,omitempty"`
+}
+
+// AccountImportResponse counts the records an account import created
+type AccountImportResponse struct {
+	Imported map[string]int `json:"imported"`
+}
+
+// HandleExportAccountData downloads everything in the user's account as JSON
+func HandleExportAccountData(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		viewer, err := repository.LoadSymptomViewer(db, accountID, userID)
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to export account", "err", err)
+			respond.Error(w, "Failed to export account data", http.StatusInternalServerError)
+			return
+		}
+
+		data, err := gatherAccountData(db, accountID, userID, viewer)
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to export account", "err", err)
+			respond.Error(w, "Failed to export account data", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"export",
+			"account",
+			sql.NullInt64{Int64: accountID, Valid: true},
+			map[string]interface{}{"format": "json"},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		filename := fmt.Sprintf("injection-tracker-account-%s.json", data.ExportedAt.Format("2006-01-02"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(data); err != nil {
+			middleware.Log(r.Context()).Error("Failed to encode account export", "err", err)
+		}
+	}
+}
+
+// HandleImportAccountData restores an export from HandleExportAccountData into
+// the user's account, which must not have any courses, medications, compounds
+// or inventory yet (owner only). Users in the export are matched to members of
+// this account by username; references to anyone else are left empty. An
+// export larger than the account's backup quota is refused with 413.
+func HandleImportAccountData(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if middleware.GetRole(r.Context()) != "owner" {
+			respond.Error(w, "Forbidden: only account owner can import account data", http.StatusForbidden)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxAccountDataBytes)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respond.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := services.CheckBackupQuota(db, accountID, int64(len(body))); !checkQuota(w, r, err, http.StatusRequestEntityTooLarge) {
+			return
+		}
+		var data AccountData
+		if err := json.Unmarshal(body, &data); err != nil {
+			respond.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := checkAccountData(&data); err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		empty, err := accountIsEmpty(db, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to check account", http.StatusInternalServerError)
+			return
+		}
+		if !empty {
+			respond.Error(w, "Account already has data; import into a new account", http.StatusConflict)
+			return
+		}
+		members, err := accountMembersByUsername(db, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to load account members", http.StatusInternalServerError)
+			return
+		}
+
+		tx, err := db.BeginTx()
+		if err != nil {
+			respond.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		counts, err := restoreAccountData(tx, accountID, userID, &data, members)
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to import account data", "err", err)
+			if isAccountDataConflict(err) {
+				respond.Error(w, "Account data has conflicting or invalid records", http.StatusUnprocessableEntity)
+				return
+			}
+			respond.Error(w, "Failed to import account data", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			respond.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"import",
+			"account",
+			sql.NullInt64{Int64: accountID, Valid: true},
+			map[string]interface{}{
+				"exported_at": data.ExportedAt,
+				"imported":    counts,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(AccountImportResponse{Imported: counts})
+	}
+}
+
+// ExportAccountData reads everything in an account, as HandleExportAccountData
+// would for its owner, private symptom logs included
+func ExportAccountData(db *database.DB, accountID int64) (*AccountData, error) {
+	var ownerID int64
+	err := db.QueryRow(`
+		SELECT user_id FROM account_members WHERE account_id = ? AND role = 'owner'
+		ORDER BY user_id LIMIT 1
+	`, accountID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("account %d not found or has no owner", accountID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find account owner: %w", err)
+	}
+	return gatherAccountData(db, accountID, ownerID, repository.SymptomViewer{UserID: ownerID, SeeAll: true})
+}
+
+// gatherAccountData reads every exported record of an account that viewer
+// may see, with the exporting user's preferences as its settings
+func gatherAccountData(db *database.DB, accountID, userID int64, viewer repository.SymptomViewer) (*AccountData, error) {
+	data := &AccountData{
+		Format:     accountDataFormat,
+		Version:    accountDataVersion,
+		ExportedAt: time.Now(),
+		Settings:   make(map[string]string),
+	}
+
+	if err := db.QueryRow(`SELECT name FROM accounts WHERE id = ?`, accountID).Scan(&data.AccountName); err != nil {
+		return nil, fmt.Errorf("account: %w", err)
+	}
+
+	settingsRepo := repository.NewSettingsRepository(db)
+	userSettings, settingsErr := settingsRepo.ListUser(userID)
+	if settingsErr != nil {
+		return nil, fmt.Errorf("settings: %w", settingsErr)
+	}
+	for _, name := range repository.UserSettingKeys {
+		if value, ok := userSettings[name]; ok {
+			data.Settings[name] = value
+		}
+	}
+	prefs, settingsErr := settingsRepo.GetPreferences(userID)
+	if settingsErr != nil {
+		return nil, fmt.Errorf("settings: %w", settingsErr)
+	}
+	if !prefs.UpdatedAt.IsZero() {
+		for name, value := range preferenceValues(prefs) {
+			data.Settings[name] = value
+		}
+	}
+
+	sections := []struct {
+		name  string
+		query string
+		scan  func(*sql.Rows) error
+	}{
+		{"users", `
+			SELECT u.id, u.username FROM users u
+			JOIN account_members am ON am.user_id = u.id
+			WHERE am.account_id = ? ORDER BY u.id`,
+			func(rows *sql.Rows) error {
+				var u AccountDataUser
+				err := rows.Scan(&u.ID, &u.Username)
+				data.Users = append(data.Users, u)
+				return err
+			}},
+		{"inventory item types", `
+			SELECT item_type, name, unit, decrement_per_injection, reorder_threshold, purchase_unit, units_per_purchase,
+			       sort_order
+			FROM inventory_item_types WHERE account_id = ? ORDER BY sort_order, id`,
+			func(rows *sql.Rows) error {
+				var t AccountDataItemType
+				err := rows.Scan(&t.ItemType, &t.Name, &t.Unit, &t.DecrementPerInjection, &t.ReorderThreshold,
+					&t.PurchaseUnit, &t.UnitsPerPurchase, &t.SortOrder)
+				data.InventoryItemTypes = append(data.InventoryItemTypes, t)
+				return err
+			}},
+		{"inventory", `
+			SELECT item_type, quantity, unit, expiration_date, lot_number, low_stock_threshold, notes
+			FROM inventory_items WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var i AccountDataInventoryItem
+				err := rows.Scan(&i.ItemType, &i.Quantity, &i.Unit, &i.ExpirationDate, &i.LotNumber, &i.LowStockThreshold, &i.Notes)
+				data.Inventory = append(data.Inventory, i)
+				return err
+			}},
+		{"inventory lots", `
+			SELECT id, item_type, lot_number, expiration_date, quantity_received, quantity_remaining, received_at, notes
+			FROM inventory_lots WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var l AccountDataLot
+				err := rows.Scan(&l.ID, &l.ItemType, &l.LotNumber, &l.ExpirationDate, &l.QuantityReceived,
+					&l.QuantityRemaining, &l.ReceivedAt, &l.Notes)
+				data.InventoryLots = append(data.InventoryLots, l)
+				return err
+			}},
+		{"lot consumptions", `
+			SELECT c.lot_id, c.injection_id, c.amount, c.created_at
+			FROM inventory_lot_consumptions c
+			JOIN inventory_lots l ON l.id = c.lot_id
+			WHERE l.account_id = ? ORDER BY c.id`,
+			func(rows *sql.Rows) error {
+				var c AccountDataLotConsumption
+				err := rows.Scan(&c.LotID, &c.InjectionID, &c.Amount, &c.CreatedAt)
+				data.LotConsumptions = append(data.LotConsumptions, c)
+				return err
+			}},
+		{"vials", `
+			SELECT id, item_type, lot_id, label, volume_ml, remaining_ml, discard_after_days, status,
+			       opened_at, discard_at, closed_at, notes, created_at
+			FROM inventory_vials WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var v AccountDataVial
+				err := rows.Scan(&v.ID, &v.ItemType, &v.LotID, &v.Label, &v.VolumeML, &v.RemainingML, &v.DiscardAfterDays,
+					&v.Status, &v.OpenedAt, &v.DiscardAt, &v.ClosedAt, &v.Notes, &v.CreatedAt)
+				data.Vials = append(data.Vials, v)
+				return err
+			}},
+		{"vial consumptions", `
+			SELECT c.vial_id, c.injection_id, c.amount, c.created_at
+			FROM inventory_vial_consumptions c
+			JOIN inventory_vials v ON v.id = c.vial_id
+			WHERE v.account_id = ? ORDER BY c.id`,
+			func(rows *sql.Rows) error {
+				var c AccountDataVialConsumption
+				err := rows.Scan(&c.VialID, &c.InjectionID, &c.Amount, &c.CreatedAt)
+				data.VialConsumptions = append(data.VialConsumptions, c)
+				return err
+			}},
+		{"barcode mappings", `
+			SELECT code, item_type, label, created_by, created_at
+			FROM barcode_mappings WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var m AccountDataBarcodeMapping
+				err := rows.Scan(&m.Code, &m.ItemType, &m.Label, &m.CreatedBy, &m.CreatedAt)
+				data.BarcodeMappings = append(data.BarcodeMappings, m)
+				return err
+			}},
+		{"compounds", `
+			SELECT id, name, concentration_mg_per_ml, route, inventory_item_type, default_dose_ml, notes,
+			       is_active, created_by, created_at
+			FROM compounds WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var c AccountDataCompound
+				err := rows.Scan(&c.ID, &c.Name, &c.ConcentrationMgPerML, &c.Route, &c.InventoryItemType,
+					&c.DefaultDoseML, &c.Notes, &c.IsActive, &c.CreatedBy, &c.CreatedAt)
+				data.Compounds = append(data.Compounds, c)
+				return err
+			}},
+		{"courses", `
+			SELECT id, name, start_date, expected_end_date, actual_end_date, COALESCE(is_active, FALSE), notes,
+			       dose_ml, concentration_mg_per_ml, compound_id, created_by, created_at
+			FROM courses WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var c AccountDataCourse
+				err := rows.Scan(&c.ID, &c.Name, &c.StartDate, &c.ExpectedEndDate, &c.ActualEndDate, &c.IsActive,
+					&c.Notes, &c.DoseML, &c.ConcentrationMgPerML, &c.CompoundID, &c.CreatedBy, &c.CreatedAt)
+				data.Courses = append(data.Courses, c)
+				return err
+			}},
+		{"dose steps", `
+			SELECT s.course_id, s.start_date, s.end_date, s.dose_ml
+			FROM course_dose_steps s
+			JOIN courses c ON c.id = s.course_id
+			WHERE c.account_id = ? ORDER BY s.course_id, s.start_date`,
+			func(rows *sql.Rows) error {
+				var d AccountDataDoseStep
+				err := rows.Scan(&d.CourseID, &d.StartDate, &d.EndDate, &d.DoseML)
+				data.DoseSteps = append(data.DoseSteps, d)
+				return err
+			}},
+		{"course schedules", `
+			SELECT s.course_id, s.interval_hours, s.weekdays, s.time_of_day
+			FROM course_schedules s
+			JOIN courses c ON c.id = s.course_id
+			WHERE c.account_id = ? ORDER BY s.course_id`,
+			func(rows *sql.Rows) error {
+				var cs AccountDataCourseSchedule
+				err := rows.Scan(&cs.CourseID, &cs.IntervalHours, &cs.Weekdays, &cs.TimeOfDay)
+				data.CourseSchedules = append(data.CourseSchedules, cs)
+				return err
+			}},
+		{"missed injections", `
+			SELECT m.course_id, m.due_at, m.reason, m.note, m.annotated_by, m.annotated_at, m.created_at
+			FROM missed_injections m
+			JOIN courses c ON c.id = m.course_id
+			WHERE c.account_id = ? ORDER BY m.course_id, m.due_at`,
+			func(rows *sql.Rows) error {
+				var m AccountDataMissedInjection
+				err := rows.Scan(&m.CourseID, &m.DueAt, &m.Reason, &m.Note, &m.AnnotatedBy, &m.AnnotatedAt, &m.CreatedAt)
+				data.MissedInjections = append(data.MissedInjections, m)
+				return err
+			}},
+		{"injections", `
+			SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level,
+			       COALESCE(i.has_knots, FALSE), i.site_reaction, i.notes, i.dose_ml, i.lot_id,
+			       i.voided_at, i.voided_by, i.void_reason, i.created_at
+			FROM injections i
+			JOIN courses c ON c.id = i.course_id
+			WHERE c.account_id = ? ORDER BY i.id`,
+			func(rows *sql.Rows) error {
+				var i AccountDataInjection
+				err := rows.Scan(&i.ID, &i.CourseID, &i.AdministeredBy, &i.Timestamp, &i.Side, &i.SiteX, &i.SiteY,
+					&i.PainLevel, &i.HasKnots, &i.SiteReaction, &i.Notes, &i.DoseML, &i.LotID,
+					&i.VoidedAt, &i.VoidedBy, &i.VoidReason, &i.CreatedAt)
+				data.Injections = append(data.Injections, i)
+				return err
+			}},
+		{"symptom catalog", `
+			SELECT key, name, category, scale_type, sort_order, is_archived
+			FROM symptom_catalog WHERE account_id = ? ORDER BY sort_order, id`,
+			func(rows *sql.Rows) error {
+				var t AccountDataSymptomType
+				err := rows.Scan(&t.Key, &t.Name, &t.Category, &t.ScaleType, &t.SortOrder, &t.IsArchived)
+				data.SymptomCatalog = append(data.SymptomCatalog, t)
+				return err
+			}},
+		{"symptoms", `
+			SELECT s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type,
+			       COALESCE(s.has_knots, FALSE), s.symptoms, s.dissipated_at, s.notes, s.visibility, s.created_at
+			FROM symptom_logs s
+			JOIN courses c ON c.id = s.course_id
+			WHERE c.account_id = ? ORDER BY s.id`,
+			func(rows *sql.Rows) error {
+				var s AccountDataSymptom
+				var loggedBy sql.NullInt64
+				err := rows.Scan(&s.ID, &s.CourseID, &loggedBy, &s.Timestamp, &s.PainLevel, &s.PainLocation,
+					&s.PainType, &s.HasKnots, &s.Symptoms, &s.DissipatedAt, &s.Notes, &s.Visibility, &s.CreatedAt)
+				if err != nil || !viewer.CanSee(s.Visibility, loggedBy) {
+					return err
+				}
+				s.LoggedBy = nullInt64ToInt(loggedBy)
+				data.Symptoms = append(data.Symptoms, s)
+				return nil
+			}},
+		{"medications", `
+			SELECT id, name, dosage, frequency, start_date, end_date, COALESCE(is_active, FALSE), notes,
+			       scheduled_time, dose_times, time_window_minutes, COALESCE(reminder_enabled, FALSE),
+			       inventory_item_type, units_per_dose, created_at
+			FROM medications WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var m AccountDataMedication
+				var doseTimes sql.NullString
+				err := rows.Scan(&m.ID, &m.Name, &m.Dosage, &m.Frequency, &m.StartDate, &m.EndDate, &m.IsActive,
+					&m.Notes, &m.ScheduledTime, &doseTimes, &m.TimeWindowMinutes, &m.ReminderEnabled,
+					&m.InventoryItemType, &m.UnitsPerDose, &m.CreatedAt)
+				if doseTimes.String != "" {
+					m.DoseTimes = strings.Split(doseTimes.String, ",")
+				}
+				data.Medications = append(data.Medications, m)
+				return err
+			}},
+		{"medication logs", `
+			SELECT l.medication_id, l.logged_by, l.timestamp, l.taken, l.late, l.notes, l.scheduled_for, l.created_at
+			FROM medication_logs l
+			JOIN medications m ON m.id = l.medication_id
+			WHERE m.account_id = ? ORDER BY l.id`,
+			func(rows *sql.Rows) error {
+				var l AccountDataMedicationLog
+				err := rows.Scan(&l.MedicationID, &l.LoggedBy, &l.Timestamp, &l.Taken, &l.Late, &l.Notes, &l.ScheduledFor, &l.CreatedAt)
+				data.MedicationLogs = append(data.MedicationLogs, l)
+				return err
+			}},
+		{"purchase orders", `
+			SELECT id, supplier, order_number, status, ordered_at, expected_arrival, received_at, shipping_cost,
+			       notes, created_by, created_at
+			FROM purchase_orders WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				o := AccountDataPurchaseOrder{Items: []AccountDataPurchaseOrderItem{}}
+				err := rows.Scan(&o.ID, &o.Supplier, &o.OrderNumber, &o.Status, &o.OrderedAt, &o.ExpectedArrival,
+					&o.ReceivedAt, &o.ShippingCost, &o.Notes, &o.CreatedBy, &o.CreatedAt)
+				data.PurchaseOrders = append(data.PurchaseOrders, o)
+				return err
+			}},
+		{"vitals", `
+			SELECT recorded_by, timestamp, type, value, unit, source, notes, created_at
+			FROM vitals WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var v AccountDataVital
+				err := rows.Scan(&v.RecordedBy, &v.Timestamp, &v.Type, &v.Value, &v.Unit, &v.Source, &v.Notes, &v.CreatedAt)
+				data.Vitals = append(data.Vitals, v)
+				return err
+			}},
+		{"check-ins", `
+			SELECT user_id, date, mood, energy, sleep_hours, temperature, weight, notes, created_at
+			FROM wellness_checkins WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var c AccountDataCheckIn
+				var date time.Time
+				err := rows.Scan(&c.UserID, &date, &c.Mood, &c.Energy, &c.SleepHours, &c.Temperature, &c.Weight, &c.Notes, &c.CreatedAt)
+				c.Date = date.UTC().Format("2006-01-02")
+				data.CheckIns = append(data.CheckIns, c)
+				return err
+			}},
+		{"journal entries", `
+			SELECT id, author_id, course_id, entry_date, title, body, is_pinned, created_at
+			FROM journal_entries WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var e AccountDataJournalEntry
+				var date sql.NullTime
+				err := rows.Scan(&e.ID, &e.AuthorID, &e.CourseID, &date, &e.Title, &e.Body, &e.IsPinned, &e.CreatedAt)
+				if date.Valid {
+					day := date.Time.UTC().Format("2006-01-02")
+					e.EntryDate = &day
+				}
+				data.Journal = append(data.Journal, e)
+				return err
+			}},
+		{"lab results", `
+			SELECT course_id, analyte, value, unit, reference_low, reference_high, drawn_at, notes, created_by, created_at
+			FROM lab_results WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var l AccountDataLabResult
+				err := rows.Scan(&l.CourseID, &l.Analyte, &l.Value, &l.Unit, &l.ReferenceLow, &l.ReferenceHigh,
+					&l.DrawnAt, &l.Notes, &l.CreatedBy, &l.CreatedAt)
+				data.LabResults = append(data.LabResults, l)
+				return err
+			}},
+		{"trips", `
+			SELECT destination, timezone, home_timezone, starts_at, ends_at, shift, notes, created_by, created_at
+			FROM trips WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var t AccountDataTrip
+				err := rows.Scan(&t.Destination, &t.Timezone, &t.HomeTimezone, &t.StartsAt, &t.EndsAt, &t.Shift, &t.Notes, &t.CreatedBy, &t.CreatedAt)
+				data.Trips = append(data.Trips, t)
+				return err
+			}},
+		{"appointments", `
+			SELECT title, starts_at, ends_at, location, notes, created_by, created_at
+			FROM appointments WHERE account_id = ? ORDER BY id`,
+			func(rows *sql.Rows) error {
+				var a AccountDataAppointment
+				err := rows.Scan(&a.Title, &a.StartsAt, &a.EndsAt, &a.Location, &a.Notes, &a.CreatedBy, &a.CreatedAt)
+				data.Appointments = append(data.Appointments, a)
+				return err
+			}},
+	}
+	for _, section := range sections {
+		if err := scanAccountRows(db, section.query, accountID, section.scan); err != nil {
+			return nil, fmt.Errorf("%s: %w", section.name, err)
+		}
+	}
+
+	// Catalog symptoms are attached to the logs read above; private logs the
+	// viewer can't see were left out, so their items are skipped too
+	symptoms := make(map[int64]*AccountDataSymptom, len(data.Symptoms))
+	for i := range data.Symptoms {
+		symptoms[data.Symptoms[i].ID] = &data.Symptoms[i]
+	}
+	err := scanAccountRows(db, `
+		SELECT i.symptom_log_id, c.key, i.severity
+		FROM symptom_log_items i
+		JOIN symptom_catalog c ON c.id = i.catalog_id
+		WHERE c.account_id = ? ORDER BY i.symptom_log_id, c.sort_order, c.id`,
+		accountID, func(rows *sql.Rows) error {
+			var logID int64
+			var item AccountDataSymptomItem
+			if err := rows.Scan(&logID, &item.Key, &item.Severity); err != nil {
+				return err
+			}
+			if symptom, ok := symptoms[logID]; ok {
+				symptom.Items = append(symptom.Items, item)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("symptom items: %w", err)
+	}
+
+	// Journal tags are attached to their entries, which are read above
+	entries := make(map[int64]*AccountDataJournalEntry, len(data.Journal))
+	for i := range data.Journal {
+		entries[data.Journal[i].ID] = &data.Journal[i]
+	}
+	err = scanAccountRows(db, `
+		SELECT t.entry_id, t.tag
+		FROM journal_entry_tags t
+		JOIN journal_entries e ON e.id = t.entry_id
+		WHERE e.account_id = ? ORDER BY t.entry_id, t.tag`,
+		accountID, func(rows *sql.Rows) error {
+			var entryID int64
+			var tag string
+			if err := rows.Scan(&entryID, &tag); err != nil {
+				return err
+			}
+			if entry, ok := entries[entryID]; ok {
+				entry.Tags = append(entry.Tags, tag)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("journal tags: %w", err)
+	}
+
+	// Purchase order items are attached to their orders, which are read above
+	orders := make(map[int64]*AccountDataPurchaseOrder, len(data.PurchaseOrders))
+	for i := range data.PurchaseOrders {
+		orders[data.PurchaseOrders[i].ID] = &data.PurchaseOrders[i]
+	}
+	err = scanAccountRows(db, `
+		SELECT i.order_id, i.item_type, i.quantity, i.unit_cost, i.lot_number, i.expiration_date, i.lot_id
+		FROM purchase_order_items i
+		JOIN purchase_orders o ON o.id = i.order_id
+		WHERE o.account_id = ? ORDER BY i.id`,
+		accountID, func(rows *sql.Rows) error {
+			var orderID int64
+			var item AccountDataPurchaseOrderItem
+			if err := rows.Scan(&orderID, &item.ItemType, &item.Quantity, &item.UnitCost, &item.LotNumber,
+				&item.ExpirationDate, &item.LotID); err != nil {
+				return err
+			}
+			if order, ok := orders[orderID]; ok {
+				order.Items = append(order.Items, item)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("purchase order items: %w", err)
+	}
+
+	return data, nil
+}
+
+// scanAccountRows runs an account-scoped query and hands each row to scan
+func scanAccountRows(db *database.DB, query string, accountID int64, scan func(*sql.Rows) error) error {
+	rows, err := db.Query(query, accountID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// checkAccountData checks that data is an export this version can import
+func checkAccountData(data *AccountData) error {
+	if data.Format != accountDataFormat {
+		return errors.New("Not an account export")
+	}
+	if data.Version < 1 || data.Version > accountDataVersion {
+		return fmt.Errorf("Unsupported export version %d", data.Version)
+	}
+	return validateAccountData(data)
+}
+
+// validateAccountData checks that every reference in an export points at a
+// record in it, so a truncated or hand-edited file fails before any writes
+func validateAccountData(data *AccountData) error {
+	itemTypes := make(map[string]bool)
+	for _, t := range data.InventoryItemTypes {
+		itemTypes[t.ItemType] = true
+	}
+	lots := make(map[int64]bool)
+	for _, l := range data.InventoryLots {
+		if !itemTypes[l.ItemType] {
+			return fmt.Errorf("lot #%d has unknown item type %q", l.ID, l.ItemType)
+		}
+		lots[l.ID] = true
+	}
+	for _, i := range data.Inventory {
+		if !itemTypes[i.ItemType] {
+			return fmt.Errorf("inventory has unknown item type %q", i.ItemType)
+		}
+	}
+	compounds := make(map[int64]bool)
+	for _, c := range data.Compounds {
+		compounds[c.ID] = true
+	}
+	courses := make(map[int64]bool)
+	for _, c := range data.Courses {
+		if c.CompoundID != nil && !compounds[*c.CompoundID] {
+			return fmt.Errorf("course #%d references unknown compound #%d", c.ID, *c.CompoundID)
+		}
+		courses[c.ID] = true
+	}
+	for _, d := range data.DoseSteps {
+		if !courses[d.CourseID] {
+			return fmt.Errorf("dose step references unknown course #%d", d.CourseID)
+		}
+		if d.DoseML <= 0 {
+			return fmt.Errorf("dose step for course #%d has no dose", d.CourseID)
+		}
+	}
+	for _, cs := range data.CourseSchedules {
+		if !courses[cs.CourseID] {
+			return fmt.Errorf("course schedule references unknown course #%d", cs.CourseID)
+		}
+		if (cs.IntervalHours == nil) == (cs.Weekdays == nil) {
+			return fmt.Errorf("schedule for course #%d needs either interval_hours or weekdays", cs.CourseID)
+		}
+	}
+	for _, m := range data.MissedInjections {
+		if !courses[m.CourseID] {
+			return fmt.Errorf("missed injection references unknown course #%d", m.CourseID)
+		}
+		if m.Reason != nil && !models.IsMissedInjectionReason(*m.Reason) {
+			return fmt.Errorf("missed injection for course #%d has unknown reason %q", m.CourseID, *m.Reason)
+		}
+	}
+	injections := make(map[int64]bool)
+	for _, i := range data.Injections {
+		if !courses[i.CourseID] {
+			return fmt.Errorf("injection #%d references unknown course #%d", i.ID, i.CourseID)
+		}
+		if i.LotID != nil && !lots[*i.LotID] {
+			return fmt.Errorf("injection #%d references unknown lot #%d", i.ID, *i.LotID)
+		}
+		injections[i.ID] = true
+	}
+	for _, c := range data.LotConsumptions {
+		if !lots[c.LotID] {
+			return fmt.Errorf("lot consumption references unknown lot #%d", c.LotID)
+		}
+		if c.InjectionID != nil && !injections[*c.InjectionID] {
+			return fmt.Errorf("lot consumption references unknown injection #%d", *c.InjectionID)
+		}
+	}
+	vials := make(map[int64]bool)
+	for _, v := range data.Vials {
+		if !itemTypes[v.ItemType] {
+			return fmt.Errorf("vial #%d has unknown item type %q", v.ID, v.ItemType)
+		}
+		if v.LotID != nil && !lots[*v.LotID] {
+			return fmt.Errorf("vial #%d references unknown lot #%d", v.ID, *v.LotID)
+		}
+		switch v.Status {
+		case models.VialSealed, models.VialOpen, models.VialEmpty, models.VialDiscarded:
+		default:
+			return fmt.Errorf("vial #%d has unknown status %q", v.ID, v.Status)
+		}
+		if v.VolumeML <= 0 || v.RemainingML < 0 || v.DiscardAfterDays <= 0 {
+			return fmt.Errorf("vial #%d has an invalid volume or discard period", v.ID)
+		}
+		vials[v.ID] = true
+	}
+	for _, c := range data.VialConsumptions {
+		if !vials[c.VialID] {
+			return fmt.Errorf("vial consumption references unknown vial #%d", c.VialID)
+		}
+		if !injections[c.InjectionID] {
+			return fmt.Errorf("vial consumption references unknown injection #%d", c.InjectionID)
+		}
+	}
+	codes := make(map[string]bool)
+	for _, m := range data.BarcodeMappings {
+		if m.Code == "" || codes[m.Code] {
+			return fmt.Errorf("barcode mapping %q is empty or repeated", m.Code)
+		}
+		if !itemTypes[m.ItemType] {
+			return fmt.Errorf("barcode mapping %q has unknown item type %q", m.Code, m.ItemType)
+		}
+		codes[m.Code] = true
+	}
+	symptomTypes := make(map[string]bool)
+	for _, t := range data.SymptomCatalog {
+		if t.Key == "" || t.Name == "" {
+			return fmt.Errorf("symptom catalog entry %q has no key or name", t.Key)
+		}
+		if !validSymptomScaleType(t.ScaleType) {
+			return fmt.Errorf("symptom catalog entry %q has unknown scale type %q", t.Key, t.ScaleType)
+		}
+		symptomTypes[t.Key] = true
+	}
+	for _, s := range data.Symptoms {
+		if !courses[s.CourseID] {
+			return fmt.Errorf("symptom #%d references unknown course #%d", s.ID, s.CourseID)
+		}
+		if s.Visibility != "" && !validSymptomVisibility(s.Visibility) {
+			return fmt.Errorf("symptom #%d has unknown visibility %q", s.ID, s.Visibility)
+		}
+		for _, item := range s.Items {
+			if !symptomTypes[item.Key] {
+				return fmt.Errorf("symptom #%d references unknown catalog symptom %q", s.ID, item.Key)
+			}
+			if item.Severity != nil && (*item.Severity < 1 || *item.Severity > 10) {
+				return fmt.Errorf("symptom #%d rates %q outside 1-10", s.ID, item.Key)
+			}
+		}
+	}
+	medications := make(map[int64]bool)
+	for _, m := range data.Medications {
+		medications[m.ID] = true
+		if m.InventoryItemType != nil && !itemTypes[*m.InventoryItemType] {
+			return fmt.Errorf("medication #%d has unknown item type %q", m.ID, *m.InventoryItemType)
+		}
+		if m.UnitsPerDose != nil && *m.UnitsPerDose <= 0 {
+			return fmt.Errorf("medication #%d uses %v units a dose", m.ID, *m.UnitsPerDose)
+		}
+	}
+	for _, l := range data.MedicationLogs {
+		if !medications[l.MedicationID] {
+			return fmt.Errorf("medication log references unknown medication #%d", l.MedicationID)
+		}
+	}
+	for _, c := range data.CheckIns {
+		if _, err := time.Parse("2006-01-02", c.Date); err != nil {
+			return fmt.Errorf("check-in has invalid date %q", c.Date)
+		}
+		if (c.Mood != nil && (*c.Mood < 1 || *c.Mood > 5)) || (c.Energy != nil && (*c.Energy < 1 || *c.Energy > 5)) {
+			return fmt.Errorf("check-in for %s rates mood or energy outside 1-5", c.Date)
+		}
+		if c.SleepHours != nil && (*c.SleepHours < 0 || *c.SleepHours > 24) {
+			return fmt.Errorf("check-in for %s has sleep outside 0-24 hours", c.Date)
+		}
+	}
+	for _, e := range data.Journal {
+		if strings.TrimSpace(e.Body) == "" {
+			return fmt.Errorf("journal entry #%d has no body", e.ID)
+		}
+		if e.CourseID != nil && !courses[*e.CourseID] {
+			return fmt.Errorf("journal entry #%d references unknown course #%d", e.ID, *e.CourseID)
+		}
+		if e.EntryDate != nil {
+			if _, err := time.Parse("2006-01-02", *e.EntryDate); err != nil {
+				return fmt.Errorf("journal entry #%d has invalid date %q", e.ID, *e.EntryDate)
+			}
+		}
+	}
+	for _, l := range data.LabResults {
+		if strings.TrimSpace(l.Analyte) == "" || strings.TrimSpace(l.Unit) == "" {
+			return fmt.Errorf("lab result drawn %s has no analyte or unit", l.DrawnAt.Format("2006-01-02"))
+		}
+		if l.Value < 0 {
+			return fmt.Errorf("%s result drawn %s is negative", l.Analyte, l.DrawnAt.Format("2006-01-02"))
+		}
+		if l.CourseID != nil && !courses[*l.CourseID] {
+			return fmt.Errorf("%s result references unknown course #%d", l.Analyte, *l.CourseID)
+		}
+	}
+	for _, t := range data.Trips {
+		if strings.TrimSpace(t.Destination) == "" {
+			return fmt.Errorf("trip starting %s has no destination", t.StartsAt.Format("2006-01-02"))
+		}
+		for _, tz := range []string{t.Timezone, t.HomeTimezone} {
+			if _, err := time.LoadLocation(tz); err != nil || tz == "" {
+				return fmt.Errorf("trip to %s has unknown timezone %q", t.Destination, tz)
+			}
+		}
+		if !t.EndsAt.After(t.StartsAt) {
+			return fmt.Errorf("trip to %s ends before it starts", t.Destination)
+		}
+		if t.Shift != models.TripShiftInstant && t.Shift != models.TripShiftGradual {
+			return fmt.Errorf("trip to %s has unknown shift %q", t.Destination, t.Shift)
+		}
+	}
+	for _, o := range data.PurchaseOrders {
+		for _, item := range o.Items {
+			if item.LotID != nil && !lots[*item.LotID] {
+				return fmt.Errorf("purchase order item references unknown lot #%d", *item.LotID)
+			}
+		}
+	}
+	return nil
+}
+
+// isAccountDataConflict reports whether a restore failed on a unique, check
+// or foreign key constraint, which means records in the export clash with
+// each other rather than the database failing
+func isAccountDataConflict(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "constraint failed") || strings.Contains(msg, "violates")
+}
+
+// accountIsEmpty reports whether an account has no records an import would
+// collide with; the default inventory item types don't count
+func accountIsEmpty(db *database.DB, accountID int64) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM courses WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM medications WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM compounds WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM inventory_items WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM inventory_lots WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM inventory_vials WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM barcode_mappings WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM purchase_orders WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM vitals WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM wellness_checkins WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM journal_entries WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM lab_results WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM trips WHERE account_id = ?)
+		    OR EXISTS(SELECT 1 FROM appointments WHERE account_id = ?)
+	`, accountID, accountID, accountID, accountID, accountID, accountID, accountID, accountID, accountID, accountID, accountID, accountID, accountID, accountID).Scan(&exists)
+	return !exists, err
+}
+
+// accountMembersByUsername maps lowercased usernames to the account's members
+func accountMembersByUsername(db *database.DB, accountID int64) (map[string]int64, error) {
+	rows, err := db.Query(`
+		SELECT u.id, u.username FROM users u
+		JOIN account_members am ON am.user_id = u.id
+		WHERE am.account_id = ?
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		var username string
+		if err := rows.Scan(&id, &username); err != nil {
+			return nil, err
+		}
+		members[strings.ToLower(username)] = id
+	}
+	return members, rows.Err()
+}
+
+// restoreAccountData writes an export into an empty account, returning how
+// many records of each kind were created. The export's settings become
+// userID's preferences; with no user they are skipped.
+func restoreAccountData(tx *sql.Tx, accountID, userID int64, data *AccountData, members map[string]int64) (map[string]int, error) {
+	counts := make(map[string]int)
+	now := time.Now()
+
+	// Exported user IDs become the member with the same username, if any
+	users := make(map[int64]int64)
+	for _, u := range data.Users {
+		if id, ok := members[strings.ToLower(u.Username)]; ok {
+			users[u.ID] = id
+		}
+	}
+	user := func(id *int64) sql.NullInt64 {
+		if id == nil {
+			return sql.NullInt64{}
+		}
+		mapped, ok := users[*id]
+		return sql.NullInt64{Int64: mapped, Valid: ok}
+	}
+	ref := func(ids map[int64]int64, id *int64) sql.NullInt64 {
+		if id == nil {
+			return sql.NullInt64{}
+		}
+		return sql.NullInt64{Int64: ids[*id], Valid: true}
+	}
+	insert := func(what, query string, args ...interface{}) (int64, error) {
+		var id int64
+		if err := tx.QueryRow(query+" RETURNING id", args...).Scan(&id); err != nil {
+			return 0, fmt.Errorf("%s: %w", what, err)
+		}
+		counts[what]++
+		return id, nil
+	}
+
+	if data.AccountName != nil && *data.AccountName != "" {
+		if _, err := tx.Exec(`UPDATE accounts SET name = ? WHERE id = ? AND (name IS NULL OR name = '')`, *data.AccountName, accountID); err != nil {
+			return nil, fmt.Errorf("account name: %w", err)
+		}
+	}
+
+	// The export's item types replace the defaults the account was created with
+	if _, err := tx.Exec(`DELETE FROM inventory_item_types WHERE account_id = ?`, accountID); err != nil {
+		return nil, fmt.Errorf("inventory item types: %w", err)
+	}
+	for _, t := range data.InventoryItemTypes {
+		if _, err := insert("inventory_item_types", `
+			INSERT INTO inventory_item_types (account_id, item_type, name, unit, decrement_per_injection, reorder_threshold,
+			                                  purchase_unit, units_per_purchase, sort_order)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, t.ItemType, t.Name, t.Unit, t.DecrementPerInjection, t.ReorderThreshold,
+			t.PurchaseUnit, t.UnitsPerPurchase, t.SortOrder); err != nil {
+			return nil, err
+		}
+	}
+	for _, i := range data.Inventory {
+		if _, err := insert("inventory", `
+			INSERT INTO inventory_items (account_id, item_type, quantity, unit, expiration_date, lot_number, low_stock_threshold, notes)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, i.ItemType, i.Quantity, i.Unit, i.ExpirationDate, i.LotNumber, i.LowStockThreshold, i.Notes); err != nil {
+			return nil, err
+		}
+	}
+	lots := make(map[int64]int64)
+	for _, l := range data.InventoryLots {
+		id, err := insert("inventory_lots", `
+			INSERT INTO inventory_lots (account_id, item_type, lot_number, expiration_date, quantity_received, quantity_remaining, received_at, notes)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, l.ItemType, l.LotNumber, l.ExpirationDate, l.QuantityReceived, l.QuantityRemaining, l.ReceivedAt, l.Notes)
+		if err != nil {
+			return nil, err
+		}
+		lots[l.ID] = id
+	}
+	vials := make(map[int64]int64)
+	for _, v := range data.Vials {
+		id, err := insert("vials", `
+			INSERT INTO inventory_vials (account_id, item_type, lot_id, label, volume_ml, remaining_ml, discard_after_days,
+			                             status, opened_at, discard_at, closed_at, notes, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, v.ItemType, ref(lots, v.LotID), v.Label, v.VolumeML, v.RemainingML, v.DiscardAfterDays,
+			v.Status, v.OpenedAt, v.DiscardAt, v.ClosedAt, v.Notes, orNow(v.CreatedAt, now), now)
+		if err != nil {
+			return nil, err
+		}
+		vials[v.ID] = id
+	}
+	for _, m := range data.BarcodeMappings {
+		if _, err := insert("barcode_mappings", `
+			INSERT INTO barcode_mappings (account_id, code, item_type, label, created_by, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, accountID, m.Code, m.ItemType, m.Label, user(m.CreatedBy), orNow(m.CreatedAt, now), now); err != nil {
+			return nil, err
+		}
+	}
+
+	compounds := make(map[int64]int64)
+	for _, c := range data.Compounds {
+		id, err := insert("compounds", `
+			INSERT INTO compounds (account_id, name, concentration_mg_per_ml, route, inventory_item_type, default_dose_ml,
+			                       notes, is_active, created_by, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, c.Name, c.ConcentrationMgPerML, c.Route, c.InventoryItemType, c.DefaultDoseML,
+			c.Notes, c.IsActive, user(c.CreatedBy), orNow(c.CreatedAt, now), now)
+		if err != nil {
+			return nil, err
+		}
+		compounds[c.ID] = id
+	}
+
+	courses := make(map[int64]int64)
+	for _, c := range data.Courses {
+		id, err := insert("courses", `
+			INSERT INTO courses (account_id, name, start_date, expected_end_date, actual_end_date, is_active, notes,
+			                     dose_ml, concentration_mg_per_ml, compound_id, created_by, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, c.Name, c.StartDate, c.ExpectedEndDate, c.ActualEndDate, c.IsActive, c.Notes,
+			c.DoseML, c.ConcentrationMgPerML, ref(compounds, c.CompoundID), user(c.CreatedBy), orNow(c.CreatedAt, now), now)
+		if err != nil {
+			return nil, err
+		}
+		courses[c.ID] = id
+	}
+	for _, d := range data.DoseSteps {
+		if _, err := insert("course_dose_steps", `
+			INSERT INTO course_dose_steps (course_id, start_date, end_date, dose_ml, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, courses[d.CourseID], d.StartDate, d.EndDate, d.DoseML, now); err != nil {
+			return nil, err
+		}
+	}
+	// A schedule is keyed by its course, so it has no ID to return
+	for _, cs := range data.CourseSchedules {
+		if _, err := tx.Exec(`
+			INSERT INTO course_schedules (course_id, interval_hours, weekdays, time_of_day, updated_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, courses[cs.CourseID], cs.IntervalHours, cs.Weekdays, cs.TimeOfDay, now); err != nil {
+			return nil, fmt.Errorf("course_schedules: %w", err)
+		}
+		counts["course_schedules"]++
+	}
+	for _, m := range data.MissedInjections {
+		if _, err := insert("missed_injections", `
+			INSERT INTO missed_injections (course_id, due_at, reason, note, annotated_by, annotated_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, courses[m.CourseID], m.DueAt.UTC(), m.Reason, m.Note, user(m.AnnotatedBy), m.AnnotatedAt, orNow(m.CreatedAt, now)); err != nil {
+			return nil, err
+		}
+	}
+
+	injections := make(map[int64]int64)
+	for _, i := range data.Injections {
+		id, err := insert("injections", `
+			INSERT INTO injections (course_id, administered_by, timestamp, side, site_x, site_y, pain_level, has_knots,
+			                        site_reaction, notes, dose_ml, lot_id, voided_at, voided_by, void_reason,
+			                        created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, courses[i.CourseID], user(i.AdministeredBy), i.Timestamp, i.Side, i.SiteX, i.SiteY, i.PainLevel, i.HasKnots,
+			i.SiteReaction, i.Notes, i.DoseML, ref(lots, i.LotID), i.VoidedAt, user(i.VoidedBy), i.VoidReason,
+			orNow(i.CreatedAt, now), now)
+		if err != nil {
+			return nil, err
+		}
+		injections[i.ID] = id
+	}
+	for _, c := range data.LotConsumptions {
+		if _, err := insert("lot_consumptions", `
+			INSERT INTO inventory_lot_consumptions (lot_id, injection_id, amount, created_at) VALUES (?, ?, ?, ?)
+		`, lots[c.LotID], ref(injections, c.InjectionID), c.Amount, orNow(c.CreatedAt, now)); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range data.VialConsumptions {
+		if _, err := insert("vial_consumptions", `
+			INSERT INTO inventory_vial_consumptions (vial_id, injection_id, amount, created_at) VALUES (?, ?, ?, ?)
+		`, vials[c.VialID], injections[c.InjectionID], c.Amount, orNow(c.CreatedAt, now)); err != nil {
+			return nil, err
+		}
+	}
+
+	// The export's symptom catalog replaces the defaults too. Exports from
+	// before catalogs existed keep the defaults, and their logs are linked
+	// by the keys in their JSON arrays, as migration 028 did.
+	symptomTypes := make(map[string]int64)
+	if len(data.SymptomCatalog) > 0 {
+		if _, err := tx.Exec(`DELETE FROM symptom_catalog WHERE account_id = ?`, accountID); err != nil {
+			return nil, fmt.Errorf("symptom catalog: %w", err)
+		}
+		for _, t := range data.SymptomCatalog {
+			id, err := insert("symptom_catalog", `
+				INSERT INTO symptom_catalog (account_id, key, name, category, scale_type, sort_order, is_archived)
+				VALUES (?, ?, ?, ?, ?, ?, ?)
+			`, accountID, t.Key, t.Name, t.Category, t.ScaleType, t.SortOrder, t.IsArchived)
+			if err != nil {
+				return nil, err
+			}
+			symptomTypes[t.Key] = id
+		}
+	} else {
+		for _, t := range repository.DefaultSymptomCatalog {
+			var id int64
+			err := tx.QueryRow(`SELECT id FROM symptom_catalog WHERE account_id = ? AND key = ?`, accountID, t.Key).Scan(&id)
+			if err == sql.ErrNoRows {
+				id, err = insert("symptom_catalog", `
+					INSERT INTO symptom_catalog (account_id, key, name, category, scale_type, sort_order)
+					VALUES (?, ?, ?, ?, 'presence', ?)
+				`, accountID, t.Key, t.Name, t.Category, t.SortOrder)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("symptom catalog: %w", err)
+			}
+			symptomTypes[t.Key] = id
+		}
+	}
+
+	for _, s := range data.Symptoms {
+		if s.Visibility == "" {
+			s.Visibility = repository.SymptomVisibilityShared
+		}
+		id, err := insert("symptoms", `
+			INSERT INTO symptom_logs (course_id, logged_by, timestamp, pain_level, pain_location, pain_type, has_knots,
+			                          symptoms, dissipated_at, notes, visibility, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, courses[s.CourseID], user(s.LoggedBy), s.Timestamp, s.PainLevel, s.PainLocation, s.PainType, s.HasKnots,
+			s.Symptoms, s.DissipatedAt, s.Notes, s.Visibility, orNow(s.CreatedAt, now), now)
+		if err != nil {
+			return nil, err
+		}
+
+		items := s.Items
+		if len(data.SymptomCatalog) == 0 && s.Symptoms != nil {
+			items = nil
+			var keys []string
+			_ = json.Unmarshal([]byte(*s.Symptoms), &keys)
+			for _, name := range keys {
+				key := repository.SymptomKey(name)
+				if key == "" || len(key) > maxItemTypeLength {
+					continue
+				}
+				if _, ok := symptomTypes[key]; !ok {
+					typeID, err := insert("symptom_catalog", `
+						INSERT INTO symptom_catalog (account_id, key, name, category, sort_order)
+						VALUES (?, ?, ?, 'other', 20)
+					`, accountID, key, strings.TrimSpace(name))
+					if err != nil {
+						return nil, err
+					}
+					symptomTypes[key] = typeID
+				}
+				items = append(items, AccountDataSymptomItem{Key: key})
+			}
+		}
+		for _, item := range items {
+			if _, err := tx.Exec(`
+				INSERT INTO symptom_log_items (symptom_log_id, catalog_id, severity) VALUES (?, ?, ?)
+				ON CONFLICT DO NOTHING
+			`, id, symptomTypes[item.Key], item.Severity); err != nil {
+				return nil, fmt.Errorf("symptom items: %w", err)
+			}
+		}
+	}
+
+	medications := make(map[int64]int64)
+	for _, m := range data.Medications {
+		// Exports from before dose times have only the scheduled time
+		doseTimes := m.ScheduledTime
+		if len(m.DoseTimes) > 0 {
+			joined := strings.Join(m.DoseTimes, ",")
+			doseTimes = &joined
+		}
+		id, err := insert("medications", `
+			INSERT INTO medications (account_id, name, dosage, frequency, start_date, end_date, is_active, notes,
+			                         scheduled_time, dose_times, time_window_minutes, reminder_enabled,
+			                         inventory_item_type, units_per_dose, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, m.Name, m.Dosage, m.Frequency, m.StartDate, m.EndDate, m.IsActive, m.Notes,
+			m.ScheduledTime, doseTimes, m.TimeWindowMinutes, m.ReminderEnabled,
+			m.InventoryItemType, m.UnitsPerDose, orNow(m.CreatedAt, now), now)
+		if err != nil {
+			return nil, err
+		}
+		medications[m.ID] = id
+	}
+	for _, l := range data.MedicationLogs {
+		if _, err := insert("medication_logs", `
+			INSERT INTO medication_logs (medication_id, logged_by, timestamp, taken, late, notes, scheduled_for, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, medications[l.MedicationID], user(l.LoggedBy), l.Timestamp, l.Taken, l.Taken && l.Late, l.Notes, l.ScheduledFor, orNow(l.CreatedAt, now)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, o := range data.PurchaseOrders {
+		orderID, err := insert("purchase_orders", `
+			INSERT INTO purchase_orders (account_id, supplier, order_number, status, ordered_at, expected_arrival,
+			                             received_at, shipping_cost, notes, created_by, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, o.Supplier, o.OrderNumber, o.Status, o.OrderedAt, o.ExpectedArrival,
+			o.ReceivedAt, o.ShippingCost, o.Notes, user(o.CreatedBy), orNow(o.CreatedAt, now), now)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range o.Items {
+			if _, err := tx.Exec(`
+				INSERT INTO purchase_order_items (order_id, item_type, quantity, unit_cost, lot_number, expiration_date, lot_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?)
+			`, orderID, item.ItemType, item.Quantity, item.UnitCost, item.LotNumber, item.ExpirationDate, ref(lots, item.LotID)); err != nil {
+				return nil, fmt.Errorf("purchase order items: %w", err)
+			}
+		}
+	}
+
+	for _, v := range data.Vitals {
+		if _, err := insert("vitals", `
+			INSERT INTO vitals (account_id, recorded_by, timestamp, type, value, unit, source, notes, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, user(v.RecordedBy), v.Timestamp, v.Type, v.Value, v.Unit, v.Source, v.Notes, orNow(v.CreatedAt, now)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range data.CheckIns {
+		date, _ := time.Parse("2006-01-02", c.Date)
+		if _, err := insert("check-ins", `
+			INSERT INTO wellness_checkins (account_id, user_id, date, mood, energy, sleep_hours, temperature, weight, notes, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, user(c.UserID), date, c.Mood, c.Energy, c.SleepHours, c.Temperature, c.Weight, c.Notes, orNow(c.CreatedAt, now), now); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, e := range data.Journal {
+		var date sql.NullTime
+		if e.EntryDate != nil {
+			day, _ := time.Parse("2006-01-02", *e.EntryDate)
+			date = sql.NullTime{Time: day, Valid: true}
+		}
+		id, err := insert("journal entries", `
+			INSERT INTO journal_entries (account_id, author_id, course_id, entry_date, title, body, is_pinned, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, user(e.AuthorID), ref(courses, e.CourseID), date, e.Title, e.Body, e.IsPinned, orNow(e.CreatedAt, now), now)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, tag := range e.Tags {
+			tag = repository.JournalTag(tag)
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			if _, err := tx.Exec(`INSERT INTO journal_entry_tags (entry_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+				return nil, fmt.Errorf("journal tags: %w", err)
+			}
+		}
+	}
+
+	for _, l := range data.LabResults {
+		if _, err := insert("lab results", `
+			INSERT INTO lab_results (account_id, course_id, analyte, value, unit, reference_low, reference_high, drawn_at, notes, created_by, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, ref(courses, l.CourseID), l.Analyte, l.Value, l.Unit, l.ReferenceLow, l.ReferenceHigh, l.DrawnAt.UTC(),
+			l.Notes, user(l.CreatedBy), orNow(l.CreatedAt, now), now); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range data.Trips {
+		if _, err := insert("trips", `
+			INSERT INTO trips (account_id, destination, timezone, home_timezone, starts_at, ends_at, shift, notes, created_by, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, t.Destination, t.Timezone, t.HomeTimezone, t.StartsAt.UTC(), t.EndsAt.UTC(), t.Shift,
+			t.Notes, user(t.CreatedBy), orNow(t.CreatedAt, now), now); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, a := range data.Appointments {
+		if _, err := insert("appointments", `
+			INSERT INTO appointments (account_id, title, starts_at, ends_at, location, notes, created_by, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, accountID, a.Title, a.StartsAt, a.EndsAt, a.Location, a.Notes, user(a.CreatedBy), orNow(a.CreatedAt, now), now); err != nil {
+			return nil, err
+		}
+	}
+
+	settings := repository.NewSettingsRepositoryTx(tx)
+	for _, name := range repository.UserSettingKeys {
+		value, ok := data.Settings[name]
+		if !ok || userID == 0 {
+			continue
+		}
+		if err := settings.SetUser(userID, name, value); err != nil {
+			return nil, fmt.Errorf("settings: %w", err)
+		}
+		counts["settings"]++
+	}
+
+	// Preferences this version doesn't accept are skipped
+	if update, n := preferencesUpdate(data.Settings); n > 0 && userID != 0 {
+		prefs, err := settings.GetPreferences(userID)
+		if err != nil {
+			return nil, fmt.Errorf("settings: %w", err)
+		}
+		rejected := applyPreferences(prefs, &update)
+		if err := settings.SavePreferences(prefs); err != nil {
+			return nil, fmt.Errorf("settings: %w", err)
+		}
+		counts["settings"] += n - len(rejected)
+	}
+
+	// Record the imported item types as the account's consumption profile
+	if _, err := repository.EnsureConsumptionProfileVersion(tx, accountID, sql.NullInt64{Int64: userID, Valid: userID != 0}); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// orNow returns t, or now for records exported without a creation time
+func orNow(t *time.Time, now time.Time) time.Time {
+	if t == nil {
+		return now
+	}
+	return *t
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// setupAccountDataDB returns a database with a seeded account 1 owned by
+// alice, with bob as a member, and an empty account 2 owned by carol, with
+// dave as a member
+func setupAccountDataDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	users := repository.NewUserRepository(db)
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		if err := users.Create(&models.User{Username: name, PasswordHash: "$2a$12$hash", IsActive: true}); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	accounts := repository.NewAccountRepository(db.DB)
+	if _, err := accounts.Create(nil, 1); err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	if _, err := accounts.Create(nil, 3); err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 2, 'member'), (2, 4, 'member');
+		INSERT INTO compounds (id, account_id, name, inventory_item_type, created_by) VALUES (1, 1, 'Progesterone in oil', 'progesterone', 1);
+		INSERT INTO courses (id, account_id, name, start_date, is_active, compound_id, created_by)
+		VALUES (1, 1, 'First course', '2026-01-01 00:00:00', 0, 1, 1), (2, 1, 'Second course', '2026-03-01 00:00:00', 1, 1, 2);
+		INSERT INTO inventory_lots (id, account_id, item_type, lot_number, quantity_received, quantity_remaining)
+		VALUES (1, 1, 'progesterone', 'L-100', 10, 9);
+		INSERT INTO injections (id, course_id, administered_by, timestamp, side, lot_id)
+		VALUES (1, 2, 2, '2026-03-02 08:00:00', 'left', 1), (2, 1, 1, '2026-01-05 08:00:00', 'right', NULL);
+		INSERT INTO inventory_lot_consumptions (lot_id, injection_id, amount) VALUES (1, 1, 1);
+		INSERT INTO symptom_logs (course_id, logged_by, timestamp, pain_level, visibility)
+		VALUES (2, 2, '2026-03-02 20:00:00', 3, 'private');
+		INSERT INTO medications (id, account_id, name, is_active) VALUES (1, 1, 'Estradiol', 1);
+		INSERT INTO medication_logs (medication_id, logged_by, timestamp, taken) VALUES (1, 1, '2026-03-02 09:00:00', 1);
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+	return db
+}
+
+func accountDataRequest(t *testing.T, handler http.HandlerFunc, method string, body []byte, userID, accountID int64, role string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "/", bytes.NewReader(body))
+	userCtx := &middleware.UserContext{UserID: userID, AccountID: accountID, Role: role}
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, userCtx))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestAccountDataExportImportRoundTrip(t *testing.T) {
+	db := setupAccountDataDB(t)
+
+	rec := accountDataRequest(t, HandleExportAccountData(db), http.MethodGet, nil, 1, 1, "owner")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Export returned %d: %s", rec.Code, rec.Body.String())
+	}
+	export := rec.Body.Bytes()
+
+	var data AccountData
+	if err := json.Unmarshal(export, &data); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	if len(data.Courses) != 2 || len(data.Injections) != 2 || len(data.Compounds) != 1 || len(data.InventoryLots) != 1 ||
+		len(data.LotConsumptions) != 1 || len(data.Medications) != 1 || len(data.MedicationLogs) != 1 {
+		t.Fatalf("Export missing records: %d courses, %d injections, %d compounds, %d lots, %d consumptions, %d medications, %d logs",
+			len(data.Courses), len(data.Injections), len(data.Compounds), len(data.InventoryLots),
+			len(data.LotConsumptions), len(data.Medications), len(data.MedicationLogs))
+	}
+	// The owner doesn't see bob's private log unless the account allows it
+	if len(data.Symptoms) != 0 {
+		t.Errorf("Expected another member's private symptom log left out, got %d", len(data.Symptoms))
+	}
+	if _, err := db.Exec(`UPDATE accounts SET owners_see_private = 1 WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	data = AccountData{}
+	if err := json.Unmarshal(accountDataRequest(t, HandleExportAccountData(db), http.MethodGet, nil, 1, 1, "owner").Body.Bytes(), &data); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	// As if moving to an instance where bob signed up as dave
+	for i := range data.Users {
+		if data.Users[i].Username == "bob" {
+			data.Users[i].Username = "dave"
+		}
+	}
+	export, _ = json.Marshal(data)
+
+	rec = accountDataRequest(t, HandleImportAccountData(db), http.MethodPost, export, 3, 2, "owner")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Import returned %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp AccountImportResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode import response: %v", err)
+	}
+	if resp.Imported["courses"] != 2 || resp.Imported["injections"] != 2 || resp.Imported["symptoms"] != 1 {
+		t.Errorf("Unexpected import counts: %v", resp.Imported)
+	}
+
+	// Records are renumbered, and references follow them into account 2
+	var courseName string
+	var compoundAccount, lotAccount, courseAccount int64
+	var administeredBy, courseCreatedBy int64
+	if err := db.QueryRow(`
+		SELECT c.name, c.account_id, c.created_by, cp.account_id, l.account_id, i.administered_by
+		FROM injections i
+		JOIN courses c ON c.id = i.course_id
+		JOIN compounds cp ON cp.id = c.compound_id
+		JOIN inventory_lots l ON l.id = i.lot_id
+		WHERE c.account_id = 2 AND i.side = 'left'
+	`).Scan(&courseName, &courseAccount, &courseCreatedBy, &compoundAccount, &lotAccount, &administeredBy); err != nil {
+		t.Fatalf("Failed to read the imported injection: %v", err)
+	}
+	if courseName != "Second course" || courseAccount != 2 || compoundAccount != 2 || lotAccount != 2 {
+		t.Errorf("Expected the injection's course, compound and lot in account 2, got %q in %d, %d, %d",
+			courseName, courseAccount, compoundAccount, lotAccount)
+	}
+	// dave is a member of account 2, so bob's references become his
+	if administeredBy != 4 || courseCreatedBy != 4 {
+		t.Errorf("Expected dave matched by username, got administered_by %d and created_by %d", administeredBy, courseCreatedBy)
+	}
+
+	// alice isn't a member of account 2, so her references are left empty
+	var aliceRefs int
+	if err := db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM courses WHERE account_id = 2 AND created_by IS NULL)
+		     + (SELECT COUNT(*) FROM medication_logs ml JOIN medications m ON m.id = ml.medication_id WHERE m.account_id = 2 AND ml.logged_by IS NULL)
+	`).Scan(&aliceRefs); err != nil {
+		t.Fatal(err)
+	}
+	if aliceRefs != 2 {
+		t.Errorf("Expected alice's course and medication log unowned, got %d", aliceRefs)
+	}
+
+	var consumptionInjection, symptomCourse string
+	if err := db.QueryRow(`
+		SELECT i.side FROM inventory_lot_consumptions lc
+		JOIN inventory_lots l ON l.id = lc.lot_id
+		JOIN injections i ON i.id = lc.injection_id
+		WHERE l.account_id = 2
+	`).Scan(&consumptionInjection); err != nil || consumptionInjection != "left" {
+		t.Errorf("Expected the lot consumption remapped to the left injection, got %q (%v)", consumptionInjection, err)
+	}
+	if err := db.QueryRow(`
+		SELECT c.name FROM symptom_logs s JOIN courses c ON c.id = s.course_id
+		WHERE c.account_id = 2 AND s.visibility = 'private' AND s.logged_by = 4
+	`).Scan(&symptomCourse); err != nil || symptomCourse != "Second course" {
+		t.Errorf("Expected dave's private log on the imported second course, got %q (%v)", symptomCourse, err)
+	}
+
+	// Account 1 is untouched
+	var originals int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM injections i JOIN courses c ON c.id = i.course_id WHERE c.account_id = 1`).Scan(&originals); err != nil || originals != 2 {
+		t.Errorf("Expected account 1 to keep its 2 injections, got %d (%v)", originals, err)
+	}
+
+	// Importing again collides with the data just imported
+	rec = accountDataRequest(t, HandleImportAccountData(db), http.MethodPost, export, 3, 2, "owner")
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 importing into an account with data, got %d", rec.Code)
+	}
+}
+
+func TestHandleImportAccountDataOwnerOnly(t *testing.T) {
+	db := setupAccountDataDB(t)
+
+	export := accountDataRequest(t, HandleExportAccountData(db), http.MethodGet, nil, 1, 1, "owner").Body.Bytes()
+	rec := accountDataRequest(t, HandleImportAccountData(db), http.MethodPost, export, 4, 2, "member")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a member, got %d", rec.Code)
+	}
+	var courses int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM courses WHERE account_id = 2`).Scan(&courses); err != nil || courses != 0 {
+		t.Errorf("Expected nothing imported, got %d courses (%v)", courses, err)
+	}
+}
+
+func TestHandleImportAccountDataInvalid(t *testing.T) {
+	db := setupAccountDataDB(t)
+
+	data, err := ExportAccountData(db, 1)
+	if err != nil {
+		t.Fatalf("ExportAccountData failed: %v", err)
+	}
+	data.Injections[0].CourseID = 99
+	body, _ := json.Marshal(data)
+
+	rec := accountDataRequest(t, HandleImportAccountData(db), http.MethodPost, body, 3, 2, "owner")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unknown course #99") {
+		t.Errorf("Expected 400 for a dangling course reference, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Two compounds tracking the same item pass validation but not the schema
+	data.Injections[0].CourseID = data.Courses[0].ID
+	data.Compounds = append(data.Compounds, data.Compounds[0])
+	data.Compounds[1].ID = 2
+	data.Compounds[1].Name = "Second compound"
+	body, _ = json.Marshal(data)
+	rec = accountDataRequest(t, HandleImportAccountData(db), http.MethodPost, body, 3, 2, "owner")
+	if rec.Code != http.StatusUnprocessableEntity || strings.Contains(rec.Body.String(), "constraint") {
+		t.Errorf("Expected a generic 422 for clashing records, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestValidateAccountData(t *testing.T) {
+	lotID, compoundID, injectionID := int64(7), int64(3), int64(9)
+	tests := []struct {
+		name    string
+		mutate  func(*AccountData)
+		wantErr string
+	}{
+		{"valid", func(*AccountData) {}, ""},
+		{"course with unknown compound", func(d *AccountData) { d.Courses[0].CompoundID = &compoundID }, "unknown compound #3"},
+		{"injection with unknown course", func(d *AccountData) { d.Injections[0].CourseID = 2 }, "unknown course #2"},
+		{"injection with unknown lot", func(d *AccountData) { d.Injections[0].LotID = &lotID }, "unknown lot #7"},
+		{"consumption of unknown injection", func(d *AccountData) {
+			d.LotConsumptions = []AccountDataLotConsumption{{LotID: 1, InjectionID: &injectionID, Amount: 1}}
+		}, "unknown injection #9"},
+		{"lot with unknown item type", func(d *AccountData) { d.InventoryLots[0].ItemType = "testosterone" }, "unknown item type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := &AccountData{
+				Format:             accountDataFormat,
+				Version:            accountDataVersion,
+				InventoryItemTypes: []AccountDataItemType{{ItemType: "progesterone", Name: "Progesterone", Unit: "mL"}},
+				InventoryLots:      []AccountDataLot{{ID: 1, ItemType: "progesterone"}},
+				Courses:            []AccountDataCourse{{ID: 1, Name: "Course"}},
+				Injections:         []AccountDataInjection{{ID: 1, CourseID: 1, Side: "left"}},
+			}
+			tt.mutate(data)
+			err := validateAccountData(data)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
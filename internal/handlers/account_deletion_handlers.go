@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"injection-tracker/internal/auth"
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// deletionRequestTTL is how long a deletion confirmation token stays valid
+const deletionRequestTTL = time.Hour
+
+// PersonalData is everything stored about a user: their profile, their
+// own settings and activity, and the data of the account they belong to
+type PersonalData struct {
+	Format     string                 `json:"format"`
+	ExportedAt time.Time              `json:"exported_at"`
+	User       PersonalDataUser       `json:"user"`
+	Settings   map[string]string      `json:"settings"`
+	Account    *AccountData           `json:"account,omitempty"`
+	Activity   []PersonalDataActivity `json:"activity"`
+}
+
+type PersonalDataUser struct {
+	ID        int64      `json:"id"`
+	Username  string     `json:"username"`
+	Email     *string    `json:"email,omitempty"`
+	Role      string     `json:"role,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastLogin *time.Time `json:"last_login,omitempty"`
+}
+
+type PersonalDataActivity struct {
+	Action     string    `json:"action"`
+	EntityType string    `json:"entity_type"`
+	EntityID   *int64    `json:"entity_id,omitempty"`
+	Details    *string   `json:"details,omitempty"`
+	IPAddress  *string   `json:"ip_address,omitempty"`
+	UserAgent  *string   `json:"user_agent,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+type AccountDeletionRequest struct {
+	Password string `json:"password" validate:"raw"`
+}
+
+type AccountDeletionConfirmRequest struct {
+	Token string `json:"token" validate:"required,max=100"`
+}
+
+// HandleGetMyData downloads everything stored about the current user
+func HandleGetMyData(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		data, err := gatherPersonalData(db, userID, middleware.GetAccountID(r.Context()))
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to export personal data", "err", err)
+			respond.Error(w, "Failed to export your data", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"export",
+			"user",
+			sql.NullInt64{Int64: userID, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		filename := fmt.Sprintf("injection-tracker-my-data-%s.json", data.ExportedAt.Format("2006-01-02"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(data); err != nil {
+			middleware.Log(r.Context()).Error("Failed to encode personal data export", "err", err)
+		}
+	}
+}
+
+// HandleRequestAccountDeletion starts deleting the current user. After
+// checking their password it hands out a confirmation token, by email when
+// possible, that HandleConfirmAccountDeletion needs to go ahead.
+func HandleRequestAccountDeletion(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req AccountDeletionRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		user, err := repository.NewUserRepository(db).GetByID(userID)
+		if err != nil {
+			respond.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if req.Password == "" || auth.VerifyPassword(user.PasswordHash, req.Password) != nil {
+			respond.Error(w, "Incorrect password", http.StatusUnauthorized)
+			return
+		}
+		if IsAdmin(db, userID) {
+			respond.Error(w, "The administrator cannot delete their own user", http.StatusForbidden)
+			return
+		}
+
+		accountRepo := repository.NewAccountRepository(db.DB)
+		if err := accountRepo.CanDeleteUser(userID); err != nil {
+			if errors.Is(err, repository.ErrLastOwner) {
+				respond.Error(w, "Make another member an owner before deleting your account", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to check account membership", http.StatusInternalServerError)
+			return
+		}
+
+		expiresAt := time.Now().Add(deletionRequestTTL)
+		token, err := accountRepo.CreateDeletionRequest(userID, expiresAt)
+		if err != nil {
+			respond.Error(w, "Failed to create deletion request", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"deletion_requested",
+			"user",
+			sql.NullInt64{Int64: userID, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		response := map[string]interface{}{
+			"expires_at": expiresAt,
+		}
+
+		// Emailing the token proves the request came from the mailbox owner
+		// too; without mail the token is handed back directly
+		smtpCfg := services.LoadSMTPConfig(db)
+		if user.Email.Valid && user.Email.String != "" && smtpCfg.IsConfigured() {
+			site := getSiteSettings(db)
+			p := i18n.FromContext(r.Context())
+			body := p.T("Someone, hopefully you, asked to delete your %s account %q.\n\n"+
+				"To confirm, enter this code within the next hour:\n\n%s\n\n"+
+				"A copy of your data will be emailed to you before anything is deleted. "+
+				"If you did not ask for this, ignore this email and consider changing your password.",
+				site.SiteTitle, user.Username, token)
+			if err := services.SendEmail(smtpCfg, user.Email.String, p.T("%s: confirm account deletion", site.SiteTitle), body); err != nil {
+				middleware.Log(r.Context()).Error("Failed to email deletion token", "err", err)
+				respond.Error(w, "Failed to send confirmation email", http.StatusBadGateway)
+				return
+			}
+			response["message"] = "A confirmation code was sent to your email address"
+			response["emailed"] = true
+		} else {
+			response["message"] = "Confirm with the token to delete your account"
+			response["emailed"] = false
+			response["token"] = token
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
+
+// HandleCancelAccountDeletion drops the current user's pending deletion request
+func HandleCancelAccountDeletion(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err := repository.NewAccountRepository(db.DB).CancelDeletionRequest(userID); err != nil {
+			respond.Error(w, "Failed to cancel deletion request", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleConfirmAccountDeletion deletes the current user, and their account
+// if nobody else belongs to it. A final export is emailed first when the
+// user has an email address; if it can't be sent nothing is deleted.
+func HandleConfirmAccountDeletion(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req AccountDeletionConfirmRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		accountRepo := repository.NewAccountRepository(db.DB)
+		if err := accountRepo.ValidateDeletionRequest(userID, req.Token); err != nil {
+			respond.Error(w, "Invalid or expired confirmation token", http.StatusBadRequest)
+			return
+		}
+		if err := accountRepo.CanDeleteUser(userID); err != nil {
+			if errors.Is(err, repository.ErrLastOwner) {
+				respond.Error(w, "Make another member an owner before deleting your account", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to check account membership", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := repository.NewUserRepository(db).GetByID(userID)
+		if err != nil {
+			respond.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		emailed := false
+		smtpCfg := services.LoadSMTPConfig(db)
+		if user.Email.Valid && user.Email.String != "" && smtpCfg.IsConfigured() {
+			if err := emailFinalExport(db, smtpCfg, user, accountID); err != nil {
+				middleware.Log(r.Context()).Error("Failed to email final export", "err", err)
+				respond.Error(w, "Failed to email your data; nothing was deleted", http.StatusBadGateway)
+				return
+			}
+			emailed = true
+		}
+
+		// The records go with the account, so note the files beforehand
+		var attachments []*models.Attachment
+		if accountID != 0 {
+			attachments, err = repository.NewAttachmentRepository(db).ListByAccount(accountID)
+			if err != nil {
+				respond.Error(w, "Failed to delete account", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		deletedAccountID, err := accountRepo.DeleteUser(userID, req.Token)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDeletionTokenInvalid):
+				respond.Error(w, "Invalid or expired confirmation token", http.StatusBadRequest)
+			case errors.Is(err, repository.ErrLastOwner):
+				respond.Error(w, "Make another member an owner before deleting your account", http.StatusConflict)
+			default:
+				middleware.Log(r.Context()).Error("Failed to delete user", "err", err)
+				respond.Error(w, "Failed to delete account", http.StatusInternalServerError)
+			}
+			return
+		}
+		if deletedAccountID != 0 {
+			newAttachmentService(db).RemoveFiles(attachments)
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     "auth_token",
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   middleware.SecureCookie(r),
+			SameSite: http.SameSiteStrictMode,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":         "Your account has been deleted",
+			"account_deleted": deletedAccountID != 0,
+			"export_emailed":  emailed,
+		})
+	}
+}
+
+// emailFinalExport sends the user a copy of their data as a JSON attachment
+func emailFinalExport(db *database.DB, cfg services.SMTPConfig, user *models.User, accountID int64) error {
+	data, err := gatherPersonalData(db, user.ID, accountID)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	site := getSiteSettings(db)
+	body := fmt.Sprintf("Your %s account %q is being deleted, as you asked.\n\n"+
+		"Attached is a copy of your data as it was just before deletion. "+
+		"Once deleted, it can't be recovered from %s.",
+		site.SiteTitle, user.Username, site.SiteTitle)
+
+	return services.SendEmailWithAttachments(cfg, user.Email.String, site.SiteTitle+": your data", body,
+		services.EmailAttachment{
+			Filename:    fmt.Sprintf("injection-tracker-my-data-%s.json", data.ExportedAt.Format("2006-01-02")),
+			ContentType: "application/json",
+			Data:        encoded,
+		})
+}
+
+// gatherPersonalData collects the user's profile, settings and activity,
+// plus the data of their account if they belong to one
+func gatherPersonalData(db *database.DB, userID, accountID int64) (*PersonalData, error) {
+	user, err := repository.NewUserRepository(db).GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	data := &PersonalData{
+		Format:     "p-track-personal-data",
+		ExportedAt: time.Now().UTC(),
+		User: PersonalDataUser{
+			ID:        user.ID,
+			Username:  user.Username,
+			CreatedAt: user.CreatedAt,
+		},
+		Settings: map[string]string{},
+		Activity: []PersonalDataActivity{},
+	}
+	if user.Email.Valid {
+		data.User.Email = &user.Email.String
+	}
+	if user.LastLogin.Valid {
+		data.User.LastLogin = &user.LastLogin.Time
+	}
+
+	settingsRepo := repository.NewSettingsRepository(db)
+	settings, err := settingsRepo.ListUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	prefs, err := settingsRepo.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !prefs.UpdatedAt.IsZero() {
+		for name, value := range preferenceValues(prefs) {
+			settings[name] = value
+		}
+	}
+	data.Settings = settings
+
+	auditRepo := repository.NewAuditRepository(db)
+	const page = 1000
+	for offset := 0; ; offset += page {
+		logs, err := auditRepo.GetByUser(userID, page, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range logs {
+			data.Activity = append(data.Activity, PersonalDataActivity{
+				Action:     l.Action,
+				EntityType: l.EntityType,
+				EntityID:   nullInt64ToInt(l.EntityID),
+				Details:    nullStringToPtr(l.Details),
+				IPAddress:  nullStringToPtr(l.IPAddress),
+				UserAgent:  nullStringToPtr(l.UserAgent),
+				Timestamp:  l.Timestamp,
+			})
+		}
+		if len(logs) < page {
+			break
+		}
+	}
+
+	if accountID != 0 {
+		_ = db.QueryRow(`SELECT role FROM account_members WHERE user_id = ?`, userID).Scan(&data.User.Role)
+		viewer, err := repository.LoadSymptomViewer(db, accountID, userID)
+		if err != nil {
+			return nil, err
+		}
+		data.Account, err = gatherAccountData(db, accountID, userID, viewer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
@@ -1,16 +1,25 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/mail"
+	"net/url"
 	"strconv"
 	"time"
 
+	"injection-tracker/internal/auth"
 	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
 	"injection-tracker/internal/middleware"
 	"injection-tracker/internal/models"
 	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -20,31 +29,53 @@ import (
 // ============================================
 
 type UpdateAccountRequest struct {
-	Name *string `json:"name,omitempty"`
+	Name             *string `json:"name,omitempty" validate:"max=100"`
+	OwnersSeePrivate *bool   `json:"owners_see_private,omitempty"` // Owner only
 }
 
 type CreateInvitationRequest struct {
-	Email string `json:"email"`
-	Role  string `json:"role"` // 'owner' or 'member'
+	Email         string `json:"email,omitempty" validate:"max=254"` // Optional; the link is emailed here when mail is set up
+	Role          string `json:"role" validate:"oneof=owner member"` // 'owner' or 'member'
+	ExpiresInDays int    `json:"expires_in_days,omitempty" validate:"min=0"`
+}
+
+type ResendInvitationRequest struct {
+	ExpiresInDays int `json:"expires_in_days,omitempty" validate:"min=0"`
 }
 
 type InvitationResponse struct {
-	ID         int64      `json:"id"`
-	Email      string     `json:"email"`
-	Token      string     `json:"token,omitempty"` // Only included on creation
-	InvitedBy  int64      `json:"invited_by"`
-	Role       string     `json:"role"`
-	CreatedAt  time.Time  `json:"created_at"`
-	ExpiresAt  time.Time  `json:"expires_at"`
-	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	ID              int64      `json:"id"`
+	Email           string     `json:"email"`
+	Token           string     `json:"token,omitempty"` // Only included on creation and resend
+	InvitedBy       int64      `json:"invited_by"`
+	InviterUsername string     `json:"inviter_username,omitempty"`
+	Role            string     `json:"role"`
+	Status          string     `json:"status"` // 'pending', 'expired' or 'accepted'
+	CreatedAt       time.Time  `json:"created_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	AcceptedAt      *time.Time `json:"accepted_at,omitempty"`
+	Emailed         bool       `json:"emailed,omitempty"`
 }
 
 type AcceptInvitationRequest struct {
-	Token string `json:"token"`
+	Token string `json:"token" validate:"required,max=100"`
 }
 
 type UpdateMemberRoleRequest struct {
-	Role string `json:"role"` // 'owner' or 'member'
+	Role string `json:"role" validate:"required,oneof=owner member"` // 'owner' or 'member'
+}
+
+type TransferOwnershipRequest struct {
+	Password string `json:"password" validate:"raw"` // The current owner's, to confirm
+}
+
+type AccountMemberResponse struct {
+	UserID       int64      `json:"user_id"`
+	Username     string     `json:"username"`
+	Role         string     `json:"role"`
+	JoinedAt     time.Time  `json:"joined_at"`
+	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`  // Only shown to owners
+	LastActiveAt *time.Time `json:"last_active_at,omitempty"` // Only shown to owners
 }
 
 // ============================================
@@ -54,10 +85,11 @@ type UpdateMemberRoleRequest struct {
 // HandleGetAccount returns the current user's account information
 func HandleGetAccount(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -65,10 +97,10 @@ func HandleGetAccount(db *database.DB) http.HandlerFunc {
 		account, err := accountRepo.GetByID(accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Account not found", http.StatusNotFound)
+				respond.Error(w, "Account not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve account", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve account", http.StatusInternalServerError)
 			return
 		}
 
@@ -77,37 +109,62 @@ func HandleGetAccount(db *database.DB) http.HandlerFunc {
 	}
 }
 
-// HandleUpdateAccount updates the account name
+// HandleUpdateAccount updates the account name, and whether owners see
+// members' private symptom logs (owner only)
 func HandleUpdateAccount(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		var req UpdateAccountRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		if req.Name == nil {
-			http.Error(w, "name is required", http.StatusBadRequest)
+		if req.Name == nil && req.OwnersSeePrivate == nil {
+			respond.Validation(w, "name is required", respond.Field("name", "is required"))
+			return
+		}
+		if req.OwnersSeePrivate != nil && middleware.GetRole(r.Context()) != "owner" {
+			respond.Error(w, "Forbidden: only account owner can change who sees private entries", http.StatusForbidden)
 			return
 		}
 
 		accountRepo := repository.NewAccountRepository(db.DB)
-		if err := accountRepo.UpdateName(accountID, *req.Name); err != nil {
-			http.Error(w, "Failed to update account", http.StatusInternalServerError)
-			return
+		if req.Name != nil {
+			if err := accountRepo.UpdateName(accountID, *req.Name); err != nil {
+				respond.Error(w, "Failed to update account", http.StatusInternalServerError)
+				return
+			}
+		}
+		if req.OwnersSeePrivate != nil {
+			if err := accountRepo.SetOwnersSeePrivate(accountID, *req.OwnersSeePrivate); err != nil {
+				respond.Error(w, "Failed to update account", http.StatusInternalServerError)
+				return
+			}
+
+			// Members should be able to find out when this changed
+			auditRepo := repository.NewAuditRepository(db)
+			_ = auditRepo.LogWithDetails(
+				sql.NullInt64{Int64: userID, Valid: true},
+				"update",
+				"account",
+				sql.NullInt64{Int64: accountID, Valid: true},
+				map[string]interface{}{"owners_see_private": *req.OwnersSeePrivate},
+				r.RemoteAddr,
+				r.UserAgent(),
+			)
 		}
 
 		// Return updated account
 		account, err := accountRepo.GetByID(accountID)
 		if err != nil {
-			http.Error(w, "Failed to retrieve updated account", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve updated account", http.StatusInternalServerError)
 			return
 		}
 
@@ -116,69 +173,132 @@ func HandleUpdateAccount(db *database.DB) http.HandlerFunc {
 	}
 }
 
-// HandleGetAccountMembers returns all members of the current user's account
+// toAccountMemberResponse converts a member. Last activity is the later of
+// their last login and their last audited action.
+func toAccountMemberResponse(m *models.AccountMember, withActivity bool) AccountMemberResponse {
+	resp := AccountMemberResponse{
+		UserID:   m.UserID,
+		Username: m.Username,
+		Role:     m.Role,
+		JoinedAt: m.JoinedAt,
+	}
+	if !withActivity {
+		return resp
+	}
+	if m.LastLogin.Valid {
+		t := m.LastLogin.Time
+		resp.LastLoginAt = &t
+		resp.LastActiveAt = &t
+	}
+	if m.LastAuditedAt.Valid && (resp.LastActiveAt == nil || m.LastAuditedAt.Time.After(*resp.LastActiveAt)) {
+		t := m.LastAuditedAt.Time
+		resp.LastActiveAt = &t
+	}
+	return resp
+}
+
+// memberIDParam parses the {userID} URL parameter
+func memberIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	memberID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		respond.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return memberID, true
+}
+
+// logMemberAudit records a change to an account's membership
+func logMemberAudit(db *database.DB, r *http.Request, action string, memberID int64, details map[string]interface{}) {
+	details["account_id"] = middleware.GetAccountID(r.Context())
+	auditRepo := repository.NewAuditRepository(db)
+	_ = auditRepo.LogWithDetails(
+		sql.NullInt64{Int64: middleware.GetUserID(r.Context()), Valid: true},
+		action,
+		"account_member",
+		sql.NullInt64{Int64: memberID, Valid: true},
+		details,
+		r.RemoteAddr,
+		r.UserAgent(),
+	)
+}
+
+// HandleGetAccountMembers returns all members of the current user's account.
+// Owners also see when each member was last active.
 func HandleGetAccountMembers(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		accountRepo := repository.NewAccountRepository(db.DB)
 		members, err := accountRepo.GetMembers(accountID)
 		if err != nil {
-			http.Error(w, "Failed to retrieve members", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve members", http.StatusInternalServerError)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(members)
+		isOwner := middleware.GetRole(r.Context()) == "owner"
+		resp := make([]AccountMemberResponse, 0, len(members))
+		for _, m := range members {
+			resp = append(resp, toAccountMemberResponse(m, isOwner))
+		}
+
+		respond.JSON(w, http.StatusOK, resp)
 	}
 }
 
-// HandleRemoveAccountMember removes a member from the account (owner only)
+// HandleRemoveAccountMember removes a member from the account (owner only).
+// They keep their login in an account of their own; what they logged stays
+// behind, still attributed to them.
 func HandleRemoveAccountMember(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		role := middleware.GetRole(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// Only owner can remove members
 		if role != "owner" {
-			http.Error(w, "Forbidden: only account owner can remove members", http.StatusForbidden)
+			respond.Error(w, "Forbidden: only account owner can remove members", http.StatusForbidden)
 			return
 		}
 
-		// Get member user ID from URL
-		memberIDStr := chi.URLParam(r, "userID")
-		memberID, err := strconv.ParseInt(memberIDStr, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		memberID, ok := memberIDParam(w, r)
+		if !ok {
 			return
 		}
 
 		// Prevent removing yourself
 		if memberID == userID {
-			http.Error(w, "Cannot remove yourself from account", http.StatusBadRequest)
+			respond.Error(w, "Cannot remove yourself from account", http.StatusBadRequest)
 			return
 		}
 
 		accountRepo := repository.NewAccountRepository(db.DB)
-		if err := accountRepo.RemoveMember(accountID, memberID); err != nil {
-			if err == repository.ErrNotFound {
-				http.Error(w, "Member not found", http.StatusNotFound)
-				return
+		newAccountID, err := accountRepo.RemoveMember(accountID, memberID)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				respond.Error(w, "Member not found", http.StatusNotFound)
+			case errors.Is(err, repository.ErrLastOwner):
+				respond.Error(w, "The account needs another owner first", http.StatusConflict)
+			default:
+				middleware.Log(r.Context()).Error("Failed to remove member", "err", err)
+				respond.Error(w, "Failed to remove member", http.StatusInternalServerError)
 			}
-			http.Error(w, "Failed to remove member", http.StatusInternalServerError)
 			return
 		}
 
+		logMemberAudit(db, r, "remove", memberID, map[string]interface{}{"new_account_id": newAccountID})
+
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -186,227 +306,465 @@ func HandleRemoveAccountMember(db *database.DB) http.HandlerFunc {
 // HandleUpdateMemberRole updates a member's role (owner only)
 func HandleUpdateMemberRole(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		role := middleware.GetRole(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// Only owner can update roles
 		if role != "owner" {
-			http.Error(w, "Forbidden: only account owner can update roles", http.StatusForbidden)
+			respond.Error(w, "Forbidden: only account owner can update roles", http.StatusForbidden)
 			return
 		}
 
-		memberIDStr := chi.URLParam(r, "userID")
-		memberID, err := strconv.ParseInt(memberIDStr, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		memberID, ok := memberIDParam(w, r)
+		if !ok {
 			return
 		}
 
 		var req UpdateMemberRoleRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		accountRepo := repository.NewAccountRepository(db.DB)
+		if err := accountRepo.UpdateMemberRole(accountID, memberID, req.Role); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				respond.Error(w, "Member not found", http.StatusNotFound)
+			case errors.Is(err, repository.ErrLastOwner):
+				respond.Error(w, "The account needs another owner first", http.StatusConflict)
+			default:
+				respond.Error(w, "Failed to update member role", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		logMemberAudit(db, r, "update_role", memberID, map[string]interface{}{"role": req.Role})
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleTransferOwnership hands the account over to another member (owner
+// only). The owner confirms with their password and becomes a member.
+func HandleTransferOwnership(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if middleware.GetRole(r.Context()) != "owner" {
+			respond.Error(w, "Forbidden: only account owner can transfer ownership", http.StatusForbidden)
+			return
+		}
+
+		memberID, ok := memberIDParam(w, r)
+		if !ok {
+			return
+		}
+		if memberID == userID {
+			respond.Error(w, "You already own this account", http.StatusBadRequest)
+			return
+		}
+
+		var req TransferOwnershipRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		if req.Role != "owner" && req.Role != "member" {
-			http.Error(w, "role must be 'owner' or 'member'", http.StatusBadRequest)
+		user, err := repository.NewUserRepository(db).GetByID(userID)
+		if err != nil {
+			respond.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if req.Password == "" || auth.VerifyPassword(user.PasswordHash, req.Password) != nil {
+			respond.Error(w, "Incorrect password", http.StatusUnauthorized)
 			return
 		}
 
 		accountRepo := repository.NewAccountRepository(db.DB)
-		if err := accountRepo.UpdateMemberRole(accountID, memberID, req.Role); err != nil {
-			if err == repository.ErrNotFound {
-				http.Error(w, "Member not found", http.StatusNotFound)
-				return
+		if err := accountRepo.TransferOwnership(accountID, userID, memberID); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				respond.Error(w, "Member not found", http.StatusNotFound)
+			case errors.Is(err, repository.ErrNotOwner):
+				respond.Error(w, "Forbidden: only account owner can transfer ownership", http.StatusForbidden)
+			default:
+				middleware.Log(r.Context()).Error("Failed to transfer ownership", "err", err)
+				respond.Error(w, "Failed to transfer ownership", http.StatusInternalServerError)
 			}
-			http.Error(w, "Failed to update member role", http.StatusInternalServerError)
 			return
 		}
 
+		logMemberAudit(db, r, "transfer_ownership", memberID, map[string]interface{}{"previous_owner_id": userID})
+
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+// CurrentMembership replaces the account and role carried in the login token
+// with the user's current membership, so removing a member or changing their
+// role takes effect on their next request rather than when they log in
+// again. It also notes whether the account is suspended, for
+// AccountSuspension. It must come after authentication.
+func CurrentMembership(db *database.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userCtx := middleware.GetUserContext(r)
+			if userCtx == nil || userCtx.UserID == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var accountID int64
+			var role string
+			var suspension sql.NullString
+			err := db.WithContext(r.Context()).QueryRow(`
+				SELECT am.account_id, am.role, a.suspension
+				FROM account_members am
+				JOIN accounts a ON a.id = am.account_id
+				WHERE am.user_id = ?`, userCtx.UserID,
+			).Scan(&accountID, &role, &suspension)
+			if err == sql.ErrNoRows {
+				respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				respond.Error(w, "Failed to check account membership", http.StatusInternalServerError)
+				return
+			}
+
+			if accountID != userCtx.AccountID || role != userCtx.Role || suspension.String != userCtx.Suspension {
+				updated := *userCtx
+				updated.AccountID, updated.Role, updated.Suspension = accountID, role, suspension.String
+				ctx := context.WithValue(r.Context(), middleware.UserContextKey, &updated)
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // ============================================
 // INVITATION HANDLERS
 // ============================================
 
-// HandleCreateInvitation creates a new invitation (owner/member can invite)
+// Invitations last this many days unless asked otherwise, and no longer
+// than the maximum
+const (
+	defaultInvitationExpiryDays = 7
+	maxInvitationExpiryDays     = 30
+)
+
+func toInvitationResponse(inv *models.AccountInvitation) InvitationResponse {
+	resp := InvitationResponse{
+		ID:              inv.ID,
+		Email:           inv.Email,
+		InvitedBy:       inv.InvitedBy,
+		InviterUsername: inv.InviterUsername,
+		Role:            inv.Role,
+		CreatedAt:       inv.CreatedAt,
+		ExpiresAt:       inv.ExpiresAt,
+		Status:          "pending",
+	}
+	switch {
+	case inv.AcceptedAt.Valid:
+		t := inv.AcceptedAt.Time
+		resp.AcceptedAt = &t
+		resp.Status = "accepted"
+	case inv.IsExpired:
+		resp.Status = "expired"
+	}
+	return resp
+}
+
+// invitationExpiry returns when an invitation asked to last days days
+// expires, or false if days is out of range
+func invitationExpiry(days int) (time.Time, bool) {
+	if days == 0 {
+		days = defaultInvitationExpiryDays
+	}
+	if days < 1 || days > maxInvitationExpiryDays {
+		return time.Time{}, false
+	}
+	return time.Now().Add(time.Duration(days) * 24 * time.Hour), true
+}
+
+// canManageInvitation reports whether the current user may resend or revoke
+// an invitation: owners may for any, members only for their own
+func canManageInvitation(r *http.Request, inv *models.AccountInvitation) bool {
+	return middleware.GetRole(r.Context()) == "owner" || inv.InvitedBy == middleware.GetUserID(r.Context())
+}
+
+// sendInvitationEmail emails the invite link, if the invitation has an
+// address and mail is set up. It is written in the inviter's language. It
+// reports whether the email went out; a failure is logged, and the link can
+// still be shared by hand.
+func sendInvitationEmail(db *database.DB, r *http.Request, inv *models.AccountInvitation, token string, inviter string) bool {
+	smtpCfg := services.LoadSMTPConfig(db)
+	if inv.Email == "" || !smtpCfg.IsConfigured() {
+		return false
+	}
+
+	site := getSiteSettings(db)
+	link := siteBaseURL(r, site) + "/register?invite=" + url.QueryEscape(token)
+	p := i18n.FromContext(r.Context())
+	body := p.T("%s has invited you to share their %s account.\n\n"+
+		"Create your login with this link:\n\n%s\n\n"+
+		"The link works once and expires on %s. If you weren't expecting this, ignore this email.",
+		inviter, site.SiteTitle, link, p.LongDate(inv.ExpiresAt))
+	if err := services.SendEmail(smtpCfg, inv.Email, p.T("%s: you've been invited", site.SiteTitle), body); err != nil {
+		middleware.Log(r.Context()).Error("Failed to email invitation", "invitation_id", inv.ID, "err", err)
+		return false
+	}
+	return true
+}
+
+// logInvitationAudit records a change to an invitation in the audit log
+func logInvitationAudit(db *database.DB, r *http.Request, action string, inv *models.AccountInvitation) {
+	auditRepo := repository.NewAuditRepository(db)
+	_ = auditRepo.LogWithDetails(
+		sql.NullInt64{Int64: middleware.GetUserID(r.Context()), Valid: true},
+		action,
+		"invitation",
+		sql.NullInt64{Int64: inv.ID, Valid: true},
+		map[string]interface{}{"account_id": inv.AccountID, "email": inv.Email, "role": inv.Role},
+		r.RemoteAddr,
+		r.UserAgent(),
+	)
+}
+
+// HandleCreateInvitation creates a new invitation and emails the link to the
+// invitee when an address is given and mail is set up. Members can invite
+// members; only owners can invite owners. Pending invitations count toward
+// the account's member quota.
 func HandleCreateInvitation(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		var req CreateInvitationRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		if req.Email == "" {
-			http.Error(w, "email is required", http.StatusBadRequest)
-			return
+		if req.Email != "" {
+			if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+				respond.Validation(w, "", respond.Field("email", "is not a valid email address"))
+				return
+			}
 		}
 
 		if req.Role == "" {
 			req.Role = "member"
 		}
-
-		if req.Role != "owner" && req.Role != "member" {
-			http.Error(w, "role must be 'owner' or 'member'", http.StatusBadRequest)
+		if req.Role == "owner" && middleware.GetRole(r.Context()) != "owner" {
+			respond.Error(w, "Forbidden: only account owners can invite owners", http.StatusForbidden)
 			return
 		}
 
-		// Check if email is already registered
-		userRepo := repository.NewUserRepository(db)
-		existingUser, err := userRepo.GetByUsername(req.Email)
-		if err == nil && existingUser != nil {
-			http.Error(w, "A user with this email already exists", http.StatusConflict)
+		expiresAt, ok := invitationExpiry(req.ExpiresInDays)
+		if !ok {
+			respond.Validation(w, "", respond.Field("expires_in_days", fmt.Sprintf("must be between 1 and %d", maxInvitationExpiryDays)))
 			return
 		}
 
-		accountRepo := repository.NewAccountRepository(db.DB)
+		// Invitations are for people without a login; existing users already
+		// belong to an account
+		if req.Email != "" {
+			var exists bool
+			if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE email = ? OR username = ?)`, req.Email, req.Email).Scan(&exists); err != nil {
+				respond.Error(w, "Failed to check email", http.StatusInternalServerError)
+				return
+			}
+			if exists {
+				respond.Error(w, "A user with this email already exists", http.StatusConflict)
+				return
+			}
+		}
 
-		// Set expiration to 7 days from now
-		expiresAt := time.Now().Add(7 * 24 * time.Hour)
+		// Nobody could register with it
+		if registrationMode(db) == RegistrationDisabled {
+			respond.Error(w, "Registration is closed on this site, so invitations can't be used", http.StatusForbidden)
+			return
+		}
+		if err := services.CheckMemberQuota(db, accountID, true); !checkQuota(w, r, err, http.StatusForbidden) {
+			return
+		}
 
-		token, err := accountRepo.CreateInvitation(accountID, req.Email, userID, expiresAt)
+		accountRepo := repository.NewAccountRepository(db.DB)
+		token, err := accountRepo.CreateInvitation(accountID, req.Email, userID, req.Role, expiresAt)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create invitation: %v", err), http.StatusInternalServerError)
+			respond.Error(w, "Failed to create invitation", http.StatusInternalServerError)
 			return
 		}
 
 		// Retrieve the created invitation
 		invitation, err := accountRepo.GetInvitationByToken(token)
 		if err != nil {
-			http.Error(w, "Invitation created but failed to retrieve", http.StatusInternalServerError)
+			respond.Error(w, "Invitation created but failed to retrieve", http.StatusInternalServerError)
 			return
 		}
+		logInvitationAudit(db, r, "create", invitation)
 
-		response := InvitationResponse{
-			ID:         invitation.ID,
-			Email:      invitation.Email,
-			Token:      token, // Return the plain token (not hashed)
-			InvitedBy:  invitation.InvitedBy,
-			Role:       invitation.Role,
-			CreatedAt:  invitation.CreatedAt,
-			ExpiresAt:  invitation.ExpiresAt,
-			AcceptedAt: nil,
+		response := toInvitationResponse(invitation)
+		response.Token = token // Return the plain token (not hashed)
+		response.Emailed = sendInvitationEmail(db, r, invitation, token, invitation.InviterUsername)
+
+		respond.JSON(w, http.StatusCreated, response)
+	}
+}
+
+// HandleGetInvitations returns all invitations for the account (pending,
+// expired and accepted)
+func HandleGetInvitations(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
-		if invitation.AcceptedAt.Valid {
-			t := invitation.AcceptedAt.Time
-			response.AcceptedAt = &t
+
+		invitations, err := repository.NewAccountRepository(db.DB).ListInvitations(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve invitations", http.StatusInternalServerError)
+			return
+		}
+
+		responses := make([]InvitationResponse, 0, len(invitations))
+		for _, inv := range invitations {
+			responses = append(responses, toInvitationResponse(inv))
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		_ = json.NewEncoder(w).Encode(response)
+		_ = json.NewEncoder(w).Encode(responses)
 	}
 }
 
-// HandleGetInvitations returns all invitations for the account (pending and accepted)
-func HandleGetInvitations(db *database.DB) http.HandlerFunc {
+// HandleResendInvitation issues a pending or expired invitation a new link,
+// which stops the old one working, extends its expiry and emails it again
+func HandleResendInvitation(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Get all invitations including accepted ones
-		rows, err := db.DB.Query(`
-			SELECT
-				id, account_id, email, invited_by, role,
-				created_at, expires_at, accepted_at, accepted_by
-			FROM account_invitations
-			WHERE account_id = ?
-			ORDER BY created_at DESC
-		`, accountID)
+		invID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 		if err != nil {
-			http.Error(w, "Failed to retrieve invitations", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var invitations []*models.AccountInvitation
-		for rows.Next() {
-			var inv models.AccountInvitation
-			err = rows.Scan(
-				&inv.ID,
-				&inv.AccountID,
-				&inv.Email,
-				&inv.InvitedBy,
-				&inv.Role,
-				&inv.CreatedAt,
-				&inv.ExpiresAt,
-				&inv.AcceptedAt,
-				&inv.AcceptedBy,
-			)
-			if err != nil {
-				continue
+			respond.Error(w, "Invalid invitation ID", http.StatusBadRequest)
+			return
+		}
+
+		// The body is optional
+		var req ResendInvitationRequest
+		if r.ContentLength != 0 {
+			if !decodeRequest(w, r, &req) {
+				return
 			}
-			invitations = append(invitations, &inv)
+		}
+		expiresAt, ok := invitationExpiry(req.ExpiresInDays)
+		if !ok {
+			respond.Validation(w, "", respond.Field("expires_in_days", fmt.Sprintf("must be between 1 and %d", maxInvitationExpiryDays)))
+			return
 		}
 
-		responses := make([]InvitationResponse, 0, len(invitations))
-		for _, inv := range invitations {
-			resp := InvitationResponse{
-				ID:         inv.ID,
-				Email:      inv.Email,
-				InvitedBy:  inv.InvitedBy,
-				Role:       inv.Role,
-				CreatedAt:  inv.CreatedAt,
-				ExpiresAt:  inv.ExpiresAt,
-				AcceptedAt: nil,
+		accountRepo := repository.NewAccountRepository(db.DB)
+		invitation, err := accountRepo.GetInvitation(accountID, invID)
+		if err != nil {
+			if err == repository.ErrInvitationNotFound {
+				respond.Error(w, "Invitation not found", http.StatusNotFound)
+				return
 			}
-			if inv.AcceptedAt.Valid {
-				t := inv.AcceptedAt.Time
-				resp.AcceptedAt = &t
+			respond.Error(w, "Failed to retrieve invitation", http.StatusInternalServerError)
+			return
+		}
+		if !canManageInvitation(r, invitation) {
+			respond.Error(w, "Forbidden: only account owners can resend others' invitations", http.StatusForbidden)
+			return
+		}
+
+		token, err := accountRepo.RenewInvitation(accountID, invID, expiresAt)
+		if err != nil {
+			if err == repository.ErrInvitationUsed {
+				respond.Error(w, "Invitation has already been accepted", http.StatusConflict)
+				return
 			}
-			responses = append(responses, resp)
+			respond.Error(w, "Failed to resend invitation", http.StatusInternalServerError)
+			return
+		}
+		invitation.ExpiresAt = expiresAt
+		invitation.IsExpired = false
+		logInvitationAudit(db, r, "resend", invitation)
+
+		inviter := invitation.InviterUsername
+		if userCtx := middleware.GetUserContext(r); userCtx != nil {
+			inviter = userCtx.Username
 		}
+		response := toInvitationResponse(invitation)
+		response.Token = token
+		response.Emailed = sendInvitationEmail(db, r, invitation, token, inviter)
 
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(responses)
+		_ = json.NewEncoder(w).Encode(response)
 	}
 }
 
 // HandleRevokeInvitation revokes/deletes an invitation
 func HandleRevokeInvitation(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		invIDStr := chi.URLParam(r, "id")
 		invID, err := strconv.ParseInt(invIDStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid invitation ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid invitation ID", http.StatusBadRequest)
 			return
 		}
 
 		accountRepo := repository.NewAccountRepository(db.DB)
-		if err := accountRepo.DeleteInvitation(invID); err != nil {
-			if err == repository.ErrNotFound {
-				http.Error(w, "Invitation not found", http.StatusNotFound)
+		invitation, err := accountRepo.GetInvitation(accountID, invID)
+		if err == nil && !canManageInvitation(r, invitation) {
+			respond.Error(w, "Forbidden: only account owners can revoke others' invitations", http.StatusForbidden)
+			return
+		}
+		if err == nil {
+			err = accountRepo.DeleteInvitation(accountID, invID)
+		}
+		if err != nil {
+			if err == repository.ErrInvitationNotFound {
+				respond.Error(w, "Invitation not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to revoke invitation", http.StatusInternalServerError)
+			respond.Error(w, "Failed to revoke invitation", http.StatusInternalServerError)
 			return
 		}
+		logInvitationAudit(db, r, "delete", invitation)
 
 		w.WriteHeader(http.StatusNoContent)
 	}
@@ -416,17 +774,18 @@ func HandleRevokeInvitation(db *database.DB) http.HandlerFunc {
 // This is called during user registration with an invite token
 func HandleAcceptInvitation(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		// Extract token from query parameter
 		token := r.URL.Query().Get("token")
 		if token == "" {
-			http.Error(w, "token is required", http.StatusBadRequest)
+			respond.Validation(w, "token is required", respond.Field("token", "is required"))
 			return
 		}
 
 		// User must be authenticated
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized: must be logged in to accept invitation", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized: must be logged in to accept invitation", http.StatusUnauthorized)
 			return
 		}
 
@@ -435,43 +794,47 @@ func HandleAcceptInvitation(db *database.DB) http.HandlerFunc {
 		// Verify invitation exists and is valid
 		invitation, err := accountRepo.GetInvitationByToken(token)
 		if err != nil {
-			if err == repository.ErrNotFound {
-				http.Error(w, "Invalid or expired invitation", http.StatusNotFound)
+			if err == repository.ErrInvitationNotFound {
+				respond.Error(w, "Invalid or expired invitation", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to verify invitation", http.StatusInternalServerError)
+			respond.Error(w, "Failed to verify invitation", http.StatusInternalServerError)
 			return
 		}
 
 		// Check if already accepted
 		if invitation.AcceptedAt.Valid {
-			http.Error(w, "Invitation has already been accepted", http.StatusConflict)
+			respond.Error(w, "Invitation has already been accepted", http.StatusConflict)
 			return
 		}
 
 		// Check if expired
 		if time.Now().After(invitation.ExpiresAt) {
-			http.Error(w, "Invitation has expired", http.StatusGone)
+			respond.Error(w, "Invitation has expired", http.StatusGone)
 			return
 		}
 
 		// Check if user is already in an account
 		currentAccount, err := accountRepo.GetUserAccount(userID)
 		if err == nil && currentAccount != nil {
-			http.Error(w, "You are already a member of an account. Please contact support to switch accounts.", http.StatusConflict)
+			respond.Error(w, "You are already a member of an account. Please contact support to switch accounts.", http.StatusConflict)
+			return
+		}
+
+		if err := services.CheckMemberQuota(db, invitation.AccountID, false); !checkQuota(w, r, err, http.StatusForbidden) {
 			return
 		}
 
 		// Accept the invitation
 		if err := accountRepo.AcceptInvitation(invitation.ID, userID); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to accept invitation: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to accept invitation: %v", err), http.StatusInternalServerError)
 			return
 		}
 
 		// Return success with account info
 		account, err := accountRepo.GetByID(invitation.AccountID)
 		if err != nil {
-			http.Error(w, "Invitation accepted but failed to retrieve account", http.StatusInternalServerError)
+			respond.Error(w, "Invitation accepted but failed to retrieve account", http.StatusInternalServerError)
 			return
 		}
 
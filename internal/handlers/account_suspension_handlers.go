@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// SuspendAccountRequest suspends an account, or changes how it is suspended
+type SuspendAccountRequest struct {
+	AccountID  int64  `json:"account_id" validate:"required,min=1"`
+	Suspension string `json:"suspension" validate:"required,oneof=read_only locked"`
+	Reason     string `json:"reason,omitempty" validate:"max=500"` // Told to the account's members
+}
+
+// ReactivateAccountRequest names the suspended account to reactivate
+type ReactivateAccountRequest struct {
+	AccountID int64 `json:"account_id" validate:"required,min=1"`
+}
+
+// AccountSuspension holds back members of a suspended account. A read-only
+// account's members can only look; a locked account's members are signed
+// out. Anyone can still log out. It must come after CurrentMembership.
+func AccountSuspension(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userCtx := middleware.GetUserContext(r)
+		if userCtx == nil || userCtx.Suspension == "" || strings.HasSuffix(r.URL.Path, "/auth/logout") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if userCtx.Suspension == models.AccountReadOnly {
+			switch {
+			case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions,
+				strings.HasSuffix(r.URL.Path, "/auth/stop-impersonating"):
+				next.ServeHTTP(w, r)
+			default:
+				respond.ErrorWithCode(w, http.StatusForbidden, respond.CodeAccountSuspended,
+					"This account has been made read-only by the site administrator")
+			}
+			return
+		}
+
+		clearAuthCookie(w, r)
+		if strings.HasPrefix(r.URL.Path, "/api/") || r.Header.Get("HX-Request") != "" {
+			respond.ErrorWithCode(w, http.StatusForbidden, respond.CodeAccountSuspended,
+				"This account has been locked by the site administrator")
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	})
+}
+
+// HandleSuspendAccount suspends an account as read-only or locked, keeping
+// its data as it is, and tells its members. The admin's own account can't
+// be suspended.
+func HandleSuspendAccount(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req SuspendAccountRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		var adminAccountID int64
+		_ = db.QueryRow("SELECT account_id FROM account_members WHERE user_id = ?", userID).Scan(&adminAccountID)
+		if req.AccountID == adminAccountID {
+			respond.Error(w, "Cannot suspend your own account", http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewAccountRepository(db.DB).Suspend(req.AccountID, req.Suspension, req.Reason, userID); err != nil {
+			if err == repository.ErrAccountNotFound {
+				respond.Error(w, "Account not found", http.StatusNotFound)
+				return
+			}
+			middleware.Log(r.Context()).Error("Failed to suspend account", "account_id", req.AccountID, "err", err)
+			respond.Error(w, "Failed to suspend account", http.StatusInternalServerError)
+			return
+		}
+
+		logSuspensionAudit(db, r, "suspend", req.AccountID, map[string]interface{}{"suspension": req.Suspension, "reason": req.Reason})
+		if err := services.NotifyAccountSuspension(db, req.AccountID, req.Suspension, req.Reason); err != nil {
+			middleware.Log(r.Context()).Error("Failed to notify account members of suspension", "account_id", req.AccountID, "err", err)
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"message": "Account suspended",
+			"success": true,
+		})
+	}
+}
+
+// HandleReactivateAccount lifts an account's suspension and tells its
+// members
+func HandleReactivateAccount(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req ReactivateAccountRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		accountRepo := repository.NewAccountRepository(db.DB)
+		account, err := accountRepo.GetByID(req.AccountID)
+		if err == repository.ErrAccountNotFound {
+			respond.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			respond.Error(w, "Failed to get account", http.StatusInternalServerError)
+			return
+		}
+		if !account.Suspension.Valid {
+			respond.Error(w, "Account is not suspended", http.StatusConflict)
+			return
+		}
+
+		if err := accountRepo.Reactivate(req.AccountID); err != nil {
+			middleware.Log(r.Context()).Error("Failed to reactivate account", "account_id", req.AccountID, "err", err)
+			respond.Error(w, "Failed to reactivate account", http.StatusInternalServerError)
+			return
+		}
+
+		logSuspensionAudit(db, r, "reactivate", req.AccountID, map[string]interface{}{"suspension": account.Suspension.String})
+		if err := services.NotifyAccountSuspension(db, req.AccountID, "", ""); err != nil {
+			middleware.Log(r.Context()).Error("Failed to notify account members of reactivation", "account_id", req.AccountID, "err", err)
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"message": "Account reactivated",
+			"success": true,
+		})
+	}
+}
+
+// logSuspensionAudit records the admin suspending or reactivating an account
+func logSuspensionAudit(db *database.DB, r *http.Request, action string, accountID int64, details map[string]interface{}) {
+	_ = repository.NewAuditRepository(db).LogWithDetails(
+		sql.NullInt64{Int64: middleware.GetUserID(r.Context()), Valid: true},
+		action,
+		"account",
+		sql.NullInt64{Int64: accountID, Valid: true},
+		details,
+		getIPAddress(r),
+		r.UserAgent(),
+	)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/respond"
+)
+
+func TestAccountSuspension(t *testing.T) {
+	tests := []struct {
+		name       string
+		suspension string
+		method     string
+		path       string
+		hx         bool
+		status     int
+	}{
+		{"active account writes", "", http.MethodPost, "/api/injections", false, http.StatusOK},
+		{"read-only reads", models.AccountReadOnly, http.MethodGet, "/api/injections", false, http.StatusOK},
+		{"read-only writes", models.AccountReadOnly, http.MethodPost, "/api/injections", false, http.StatusForbidden},
+		{"read-only logs out", models.AccountReadOnly, http.MethodPost, "/api/auth/logout", false, http.StatusOK},
+		{"locked reads", models.AccountLocked, http.MethodGet, "/api/injections", false, http.StatusForbidden},
+		{"locked HTMX", models.AccountLocked, http.MethodGet, "/dashboard", true, http.StatusForbidden},
+		{"locked page", models.AccountLocked, http.MethodGet, "/dashboard", false, http.StatusSeeOther},
+		{"locked logs out", models.AccountLocked, http.MethodPost, "/api/v1/auth/logout", false, http.StatusOK},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.hx {
+				req.Header.Set("HX-Request", "true")
+			}
+			userCtx := &middleware.UserContext{UserID: 2, AccountID: 2, Role: "owner", Suspension: tt.suspension}
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, userCtx))
+			rec := httptest.NewRecorder()
+
+			AccountSuspension(next).ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.status != http.StatusForbidden {
+				return
+			}
+			var body respond.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Code != respond.CodeAccountSuspended {
+				t.Errorf("Expected the account_suspended code, got %s", rec.Body.String())
+			}
+		})
+	}
+}
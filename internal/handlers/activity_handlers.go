@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// ActivityResponse is one entry in the activity feed
+type ActivityResponse struct {
+	Type      string         `json:"type"` // injection, symptom, medication, inventory or course
+	ID        int64          `json:"id"`   // ID of the injection, symptom log, medication log, inventory history entry or course event
+	Timestamp time.Time      `json:"timestamp"`
+	Title     string         `json:"title"`  // In the reader's language
+	Action    string         `json:"action"` // Side injected; taken, late or missed; inventory reason; or course action
+	Subject   string         `json:"subject,omitempty"`
+	CourseID  *int64         `json:"course_id,omitempty"`
+	PainLevel *int64         `json:"pain_level,omitempty"`
+	Amount    *float64       `json:"amount,omitempty"` // Dose injected or change in stock
+	Notes     string         `json:"notes,omitempty"`
+	Actor     *ActivityActor `json:"actor,omitempty"`
+	Link      string         `json:"link"` // API path of the record the entry is about
+}
+
+// ActivityActor is the member who did what an activity entry records
+type ActivityActor struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username,omitempty"`
+}
+
+func toActivityResponse(p *i18n.Printer, e *models.ActivityEntry) ActivityResponse {
+	resp := ActivityResponse{
+		Type:      e.Kind,
+		ID:        e.ID,
+		Timestamp: e.Timestamp,
+		Title:     services.ActivityTitle(p, e),
+		Action:    e.Action,
+		Subject:   e.Subject,
+		Notes:     e.Notes.String,
+		Link:      services.ActivityLink(e),
+	}
+	if e.CourseID.Valid {
+		resp.CourseID = &e.CourseID.Int64
+	}
+	if e.PainLevel.Valid {
+		resp.PainLevel = &e.PainLevel.Int64
+	}
+	if e.Amount.Valid {
+		resp.Amount = &e.Amount.Float64
+	}
+	if e.ActorID.Valid {
+		resp.Actor = &ActivityActor{ID: e.ActorID.Int64, Username: e.ActorName.String}
+	}
+	return resp
+}
+
+// listActivity reads a page of the account's activity feed as userID sees
+// it. Other members' private symptom logs are left out; if that can't be
+// worked out, the user sees only shared logs and their own.
+func listActivity(db *database.DB, accountID, userID int64, filter repository.ActivityFilter, page repository.PageRequest) (*repository.Page[*models.ActivityEntry], error) {
+	viewer, _ := repository.LoadSymptomViewer(db, accountID, userID)
+	return repository.NewActivityRepository(db).ListPage(accountID, viewer, filter, page)
+}
+
+// HandleGetActivity lists the account's activity feed newest first: its
+// injections, symptom logs, medication logs, inventory changes and course
+// events. type takes a comma-separated list of kinds and course_id limits
+// it to one course's injections, symptom logs and events.
+func HandleGetActivity(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		var filter repository.ActivityFilter
+		if v := query.Get("type"); v != "" {
+			for _, kind := range strings.Split(v, ",") {
+				kind = strings.TrimSpace(kind)
+				if !containsKind(repository.ActivityKinds, kind) {
+					msg := "must be one of " + strings.Join(repository.ActivityKinds, ", ")
+					respond.Validation(w, "Invalid type: "+msg, respond.Field("type", msg))
+					return
+				}
+				filter.Kinds = append(filter.Kinds, kind)
+			}
+		}
+		if v := query.Get("course_id"); v != "" {
+			filter.CourseID, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				respond.Validation(w, "Invalid course_id", respond.Field("course_id", "is invalid"))
+				return
+			}
+		}
+
+		result, err := listActivity(db, accountID, userID, filter, page)
+		if err != nil {
+			listPageError(w, err, "Failed to retrieve activity")
+			return
+		}
+		p := i18n.FromContext(r.Context())
+		respondJSON(w, http.StatusOK, newListResponse(result, func(e *models.ActivityEntry) ActivityResponse {
+			return toActivityResponse(p, e)
+		}))
+	}
+}
+
+func containsKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -1,15 +1,19 @@
 package handlers
 
 import (
-	"crypto/tls"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	netsmtp "net/smtp"
+	"strings"
 	"time"
 
 	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
 	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
 )
 
 // ============================================
@@ -18,22 +22,32 @@ import (
 
 // SMTPSettings represents SMTP configuration
 type SMTPSettings struct {
-	Host      string `json:"host"`
-	Port      int    `json:"port"`
-	Username  string `json:"username"`
-	Password  string `json:"password,omitempty"` // Only used for updates, never returned
-	FromName  string `json:"from_name"`
-	FromEmail string `json:"from_email"`
+	Host      string `json:"host" validate:"max=253"`
+	Port      int    `json:"port" validate:"min=0,max=65535"`
+	Username  string `json:"username" validate:"max=254"`
+	Password  string `json:"password,omitempty" validate:"raw,max=1000"` // Only used for updates, never returned
+	FromName  string `json:"from_name" validate:"max=100"`
+	FromEmail string `json:"from_email" validate:"max=254"`
 	Enabled   bool   `json:"enabled"`
 }
 
 // SiteSettings represents site-wide configuration
 type SiteSettings struct {
-	SiteURL         string `json:"site_url"`
-	SiteTitle       string `json:"site_title"`
-	SiteDescription string `json:"site_description"`
+	SiteURL          string `json:"site_url" validate:"max=2000"`
+	SiteTitle        string `json:"site_title" validate:"max=100"`
+	SiteDescription  string `json:"site_description" validate:"multiline,max=500"`
+	ReportLetterhead string `json:"report_letterhead" validate:"multiline,max=1000"` // Clinic name/address lines for PDF reports
+	// RegistrationMode is who can register: open, invite_only or disabled
+	RegistrationMode string `json:"registration_mode" validate:"omitempty,oneof=open invite_only disabled"`
 }
 
+// Registration modes, from the site settings
+const (
+	RegistrationOpen       = "open"        // Anyone can register
+	RegistrationInviteOnly = "invite_only" // Only with an invitation to an account
+	RegistrationDisabled   = "disabled"    // Nobody can register
+)
+
 // AdminSettingsResponse represents all admin settings
 type AdminSettingsResponse struct {
 	SMTP       SMTPSettings  `json:"smtp"`
@@ -69,6 +83,10 @@ type AccountInfo struct {
 	MemberCount int    `json:"member_count"`
 	CreatedAt   string `json:"created_at"`
 	OwnerName   string `json:"owner_name"`
+	// Suspension is read_only or locked while the account is suspended
+	Suspension       string `json:"suspension,omitempty"`
+	SuspensionReason string `json:"suspension_reason,omitempty"`
+	SuspendedAt      string `json:"suspended_at,omitempty"`
 }
 
 // ============================================
@@ -79,9 +97,10 @@ type AccountInfo struct {
 func RequireAdmin(db *database.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			db := db.WithContext(r.Context())
 			userID := middleware.GetUserID(r.Context())
 			if userID == 0 {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
@@ -89,12 +108,12 @@ func RequireAdmin(db *database.DB) func(http.Handler) http.Handler {
 			var firstUserID int64
 			err := db.QueryRow("SELECT id FROM users ORDER BY id LIMIT 1").Scan(&firstUserID)
 			if err != nil {
-				http.Error(w, "Failed to verify admin status", http.StatusInternalServerError)
+				respond.Error(w, "Failed to verify admin status", http.StatusInternalServerError)
 				return
 			}
 
 			if userID != firstUserID {
-				http.Error(w, "Admin access required", http.StatusForbidden)
+				respond.Error(w, "Admin access required", http.StatusForbidden)
 				return
 			}
 
@@ -120,15 +139,16 @@ func IsAdmin(db *database.DB, userID int64) bool {
 // HandleGetAdminSettings returns all admin settings
 func HandleGetAdminSettings(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		isAdmin := IsAdmin(db, userID)
 		if !isAdmin {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
@@ -157,22 +177,22 @@ func HandleGetAdminSettings(db *database.DB) http.HandlerFunc {
 // HandleUpdateSMTPSettings updates SMTP configuration
 func HandleUpdateSMTPSettings(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
 		var req SMTPSettings
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
 		// Validate required fields if enabled
 		if req.Enabled {
 			if req.Host == "" || req.Port == 0 || req.FromEmail == "" {
-				http.Error(w, "Host, port, and from_email are required when SMTP is enabled", http.StatusBadRequest)
+				respond.Error(w, "Host, port, and from_email are required when SMTP is enabled", http.StatusBadRequest)
 				return
 			}
 		}
@@ -180,7 +200,7 @@ func HandleUpdateSMTPSettings(db *database.DB) http.HandlerFunc {
 		// Begin transaction
 		tx, err := db.BeginTx()
 		if err != nil {
-			http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+			respond.Error(w, "Failed to start transaction", http.StatusInternalServerError)
 			return
 		}
 		defer func() { _ = tx.Rollback() }()
@@ -197,23 +217,15 @@ func HandleUpdateSMTPSettings(db *database.DB) http.HandlerFunc {
 			"smtp_enabled":    fmt.Sprintf("%t", req.Enabled),
 		}
 
-		// Only update password if provided
+		// Only update password if provided. It is stored encrypted.
 		if req.Password != "" {
-			// In production, encrypt this password
 			settings["smtp_password"] = req.Password
 		}
 
+		siteSettings := repository.NewSettingsRepositoryTx(tx)
 		for key, value := range settings {
-			_, err := tx.Exec(`
-				INSERT INTO settings (key, value, updated_at, updated_by)
-				VALUES (?, ?, ?, ?)
-				ON CONFLICT(key) DO UPDATE SET
-					value = excluded.value,
-					updated_at = excluded.updated_at,
-					updated_by = excluded.updated_by
-			`, key, value, now, userID)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to save setting %s: %v", key, err), http.StatusInternalServerError)
+			if err := siteSettings.SetSite(key, value, sql.NullInt64{Int64: userID, Valid: true}); err != nil {
+				respond.Error(w, fmt.Sprintf("Failed to save setting %s: %v", key, err), http.StatusInternalServerError)
 				return
 			}
 		}
@@ -225,7 +237,7 @@ func HandleUpdateSMTPSettings(db *database.DB) http.HandlerFunc {
 		`, userID, "update", "admin_settings", 0, "Updated SMTP settings", now)
 
 		if err := tx.Commit(); err != nil {
-			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			respond.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
 			return
 		}
 
@@ -239,41 +251,43 @@ func HandleUpdateSMTPSettings(db *database.DB) http.HandlerFunc {
 	}
 }
 
+// TestSMTPRequest is the address to send a test email to
+type TestSMTPRequest struct {
+	Email string `json:"email" validate:"required,max=254"`
+}
+
 // HandleTestSMTP sends a test email to verify SMTP settings
 func HandleTestSMTP(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
-		var req struct {
-			Email string `json:"email"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
-			http.Error(w, "Email address is required", http.StatusBadRequest)
+		var req TestSMTPRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
 		// Get SMTP settings
 		smtp := getSMTPSettings(db)
 		if !smtp.Enabled {
-			http.Error(w, "SMTP is not enabled", http.StatusBadRequest)
+			respond.Error(w, "SMTP is not enabled", http.StatusBadRequest)
 			return
 		}
 
 		if smtp.Host == "" || smtp.Port == 0 {
-			http.Error(w, "SMTP is not properly configured", http.StatusBadRequest)
+			respond.Error(w, "SMTP is not properly configured", http.StatusBadRequest)
 			return
 		}
 
 		// Get password for sending
-		var password string
-		_ = db.QueryRow("SELECT value FROM settings WHERE key = 'smtp_password'").Scan(&password)
+		password := getSettingValue(db, "smtp_password")
 
 		// Send test email
-		err := sendTestEmail(smtp, password, req.Email)
+		err := sendTestEmail(i18n.FromContext(r.Context()), smtp, password, req.Email)
 		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			_ = json.NewEncoder(w).Encode(map[string]interface{}{
@@ -294,9 +308,10 @@ func HandleTestSMTP(db *database.DB) http.HandlerFunc {
 // HandleGetSiteStats returns site-wide statistics
 func HandleGetSiteStats(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
@@ -309,9 +324,10 @@ func HandleGetSiteStats(db *database.DB) http.HandlerFunc {
 // HandleCheckAdmin checks if the current user is an admin
 func HandleCheckAdmin(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -329,9 +345,10 @@ func HandleCheckAdmin(db *database.DB) http.HandlerFunc {
 // HandleGetSiteSettings returns site configuration
 func HandleGetSiteSettings(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
@@ -344,56 +361,52 @@ func HandleGetSiteSettings(db *database.DB) http.HandlerFunc {
 // HandleUpdateSiteSettings updates site configuration
 func HandleUpdateSiteSettings(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
 		var req SiteSettings
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		now := time.Now()
+		siteSettings := repository.NewSettingsRepository(db)
+		updatedBy := sql.NullInt64{Int64: userID, Valid: true}
 
 		// Handle site_url specially - delete if empty to revert to default
 		if req.SiteURL == "" {
-			_, _ = db.Exec(`DELETE FROM settings WHERE key = 'site_url'`)
+			_ = siteSettings.DeleteSite("site_url")
 		} else {
-			_, err := db.Exec(`
-				INSERT INTO settings (key, value, updated_at, updated_by)
-				VALUES (?, ?, ?, ?)
-				ON CONFLICT(key) DO UPDATE SET
-					value = excluded.value,
-					updated_at = excluded.updated_at,
-					updated_by = excluded.updated_by
-			`, "site_url", req.SiteURL, now, userID)
-			if err != nil {
-				http.Error(w, "Failed to save site_url", http.StatusInternalServerError)
+			if err := siteSettings.SetSite("site_url", req.SiteURL, updatedBy); err != nil {
+				respond.Error(w, "Failed to save site_url", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// An empty letterhead goes back to the site title on reports
+		if strings.TrimSpace(req.ReportLetterhead) == "" {
+			_ = siteSettings.DeleteSite("report_letterhead")
+		} else {
+			if err := siteSettings.SetSite("report_letterhead", strings.TrimSpace(req.ReportLetterhead), updatedBy); err != nil {
+				respond.Error(w, "Failed to save report_letterhead", http.StatusInternalServerError)
 				return
 			}
 		}
 
 		// Upsert other settings (only update non-empty values)
 		settings := map[string]string{
-			"site_title":       req.SiteTitle,
-			"site_description": req.SiteDescription,
+			"site_title":        req.SiteTitle,
+			"site_description":  req.SiteDescription,
+			"registration_mode": req.RegistrationMode,
 		}
 
 		for key, value := range settings {
 			if value != "" { // Only update non-empty values
-				_, err := db.Exec(`
-					INSERT INTO settings (key, value, updated_at, updated_by)
-					VALUES (?, ?, ?, ?)
-					ON CONFLICT(key) DO UPDATE SET
-						value = excluded.value,
-						updated_at = excluded.updated_at,
-						updated_by = excluded.updated_by
-				`, key, value, now, userID)
-				if err != nil {
-					http.Error(w, fmt.Sprintf("Failed to save setting %s", key), http.StatusInternalServerError)
+				if err := siteSettings.SetSite(key, value, updatedBy); err != nil {
+					respond.Error(w, fmt.Sprintf("Failed to save setting %s", key), http.StatusInternalServerError)
 					return
 				}
 			}
@@ -414,9 +427,10 @@ func HandleUpdateSiteSettings(db *database.DB) http.HandlerFunc {
 // HandleGetAllUsers returns all users for admin management
 func HandleGetAllUsers(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
@@ -429,7 +443,7 @@ func HandleGetAllUsers(db *database.DB) http.HandlerFunc {
 			ORDER BY u.id
 		`)
 		if err != nil {
-			http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+			respond.Error(w, "Failed to fetch users", http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
@@ -461,9 +475,10 @@ func HandleGetAllUsers(db *database.DB) http.HandlerFunc {
 // HandleGetAllAccounts returns all accounts for admin management
 func HandleGetAllAccounts(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
@@ -472,12 +487,13 @@ func HandleGetAllAccounts(db *database.DB) http.HandlerFunc {
 			       (SELECT COUNT(*) FROM account_members WHERE account_id = a.id) as member_count,
 			       COALESCE((SELECT u.username FROM users u 
 			                 JOIN account_members am ON u.id = am.user_id 
-			                 WHERE am.account_id = a.id AND am.role = 'owner' LIMIT 1), 'Unknown') as owner_name
+			                 WHERE am.account_id = a.id AND am.role = 'owner' LIMIT 1), 'Unknown') as owner_name,
+			       a.suspension, a.suspension_reason, a.suspended_at
 			FROM accounts a
 			ORDER BY a.id
 		`)
 		if err != nil {
-			http.Error(w, "Failed to fetch accounts", http.StatusInternalServerError)
+			respond.Error(w, "Failed to fetch accounts", http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
@@ -486,13 +502,19 @@ func HandleGetAllAccounts(db *database.DB) http.HandlerFunc {
 		for rows.Next() {
 			var a AccountInfo
 			var createdAt time.Time
+			var suspension, reason sql.NullString
+			var suspendedAt sql.NullTime
 
-			err := rows.Scan(&a.ID, &a.Name, &createdAt, &a.MemberCount, &a.OwnerName)
+			err := rows.Scan(&a.ID, &a.Name, &createdAt, &a.MemberCount, &a.OwnerName, &suspension, &reason, &suspendedAt)
 			if err != nil {
 				continue
 			}
 
 			a.CreatedAt = createdAt.Format("2006-01-02")
+			a.Suspension, a.SuspensionReason = suspension.String, reason.String
+			if suspendedAt.Valid {
+				a.SuspendedAt = suspendedAt.Time.Format("2006-01-02 15:04")
+			}
 			accounts = append(accounts, a)
 		}
 
@@ -501,25 +523,23 @@ func HandleGetAllAccounts(db *database.DB) http.HandlerFunc {
 	}
 }
 
+// DeleteAccountRequest names the account to delete
+type DeleteAccountRequest struct {
+	AccountID int64 `json:"account_id" validate:"required"`
+}
+
 // HandleDeleteAccount deletes an account and all its data
 func HandleDeleteAccount(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
-			return
-		}
-
-		var req struct {
-			AccountID int64 `json:"account_id"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
-		if req.AccountID == 0 {
-			http.Error(w, "Account ID is required", http.StatusBadRequest)
+		var req DeleteAccountRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
@@ -527,14 +547,14 @@ func HandleDeleteAccount(db *database.DB) http.HandlerFunc {
 		var adminAccountID int64
 		_ = db.QueryRow("SELECT account_id FROM account_members WHERE user_id = ?", userID).Scan(&adminAccountID)
 		if req.AccountID == adminAccountID {
-			http.Error(w, "Cannot delete your own account", http.StatusBadRequest)
+			respond.Error(w, "Cannot delete your own account", http.StatusBadRequest)
 			return
 		}
 
 		// Begin transaction
 		tx, err := db.BeginTx()
 		if err != nil {
-			http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+			respond.Error(w, "Failed to start transaction", http.StatusInternalServerError)
 			return
 		}
 		defer func() { _ = tx.Rollback() }()
@@ -549,7 +569,7 @@ func HandleDeleteAccount(db *database.DB) http.HandlerFunc {
 		_, _ = tx.Exec("DELETE FROM accounts WHERE id = ?", req.AccountID)
 
 		if err := tx.Commit(); err != nil {
-			http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+			respond.Error(w, "Failed to delete account", http.StatusInternalServerError)
 			return
 		}
 
@@ -561,32 +581,35 @@ func HandleDeleteAccount(db *database.DB) http.HandlerFunc {
 	}
 }
 
+// UserStatusRequest activates or deactivates a user
+type UserStatusRequest struct {
+	TargetUserID int64 `json:"user_id"`
+	Active       bool  `json:"active"`
+}
+
 // HandleDeactivateUser deactivates a user account
 func HandleDeactivateUser(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
-		var req struct {
-			TargetUserID int64 `json:"user_id"`
-			Active       bool  `json:"active"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		var req UserStatusRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
 		if req.TargetUserID == userID {
-			http.Error(w, "Cannot deactivate yourself", http.StatusBadRequest)
+			respond.Error(w, "Cannot deactivate yourself", http.StatusBadRequest)
 			return
 		}
 
 		_, err := db.Exec("UPDATE users SET is_active = ? WHERE id = ?", req.Active, req.TargetUserID)
 		if err != nil {
-			http.Error(w, "Failed to update user", http.StatusInternalServerError)
+			respond.Error(w, "Failed to update user", http.StatusInternalServerError)
 			return
 		}
 
@@ -603,32 +626,35 @@ func HandleDeactivateUser(db *database.DB) http.HandlerFunc {
 	}
 }
 
+// DeleteUserRequest names the user to delete
+type DeleteUserRequest struct {
+	TargetUserID int64 `json:"user_id"`
+}
+
 // HandleDeleteUser permanently deletes a user
 func HandleDeleteUser(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
-		var req struct {
-			TargetUserID int64 `json:"user_id"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		var req DeleteUserRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
 		// Cannot delete admin (first user)
 		if req.TargetUserID == 1 {
-			http.Error(w, "Cannot delete the admin user", http.StatusBadRequest)
+			respond.Error(w, "Cannot delete the admin user", http.StatusBadRequest)
 			return
 		}
 
 		// Cannot delete yourself
 		if req.TargetUserID == userID {
-			http.Error(w, "Cannot delete yourself", http.StatusBadRequest)
+			respond.Error(w, "Cannot delete yourself", http.StatusBadRequest)
 			return
 		}
 
@@ -640,7 +666,7 @@ func HandleDeleteUser(db *database.DB) http.HandlerFunc {
 			// User exists but not in any account - just delete user
 			_, err = db.Exec("DELETE FROM users WHERE id = ?", req.TargetUserID)
 			if err != nil {
-				http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+				respond.Error(w, "Failed to delete user", http.StatusInternalServerError)
 				return
 			}
 			w.Header().Set("Content-Type", "application/json")
@@ -656,7 +682,7 @@ func HandleDeleteUser(db *database.DB) http.HandlerFunc {
 
 		tx, err := db.BeginTx()
 		if err != nil {
-			http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+			respond.Error(w, "Failed to start transaction", http.StatusInternalServerError)
 			return
 		}
 		defer func() { _ = tx.Rollback() }()
@@ -682,7 +708,7 @@ func HandleDeleteUser(db *database.DB) http.HandlerFunc {
 		_, _ = tx.Exec("DELETE FROM users WHERE id = ?", req.TargetUserID)
 
 		if err := tx.Commit(); err != nil {
-			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+			respond.Error(w, "Failed to delete user", http.StatusInternalServerError)
 			return
 		}
 
@@ -705,24 +731,23 @@ func HandleDeleteUser(db *database.DB) http.HandlerFunc {
 
 func getSMTPSettings(db *database.DB) SMTPSettings {
 	smtp := SMTPSettings{}
-
-	var value string
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'smtp_host'").Scan(&value); err == nil {
+	settings := repository.NewSettingsRepository(db)
+	if value, err := settings.GetSite("smtp_host"); err == nil {
 		smtp.Host = value
 	}
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'smtp_port'").Scan(&value); err == nil {
+	if value, err := settings.GetSite("smtp_port"); err == nil {
 		_, _ = fmt.Sscanf(value, "%d", &smtp.Port)
 	}
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'smtp_username'").Scan(&value); err == nil {
+	if value, err := settings.GetSite("smtp_username"); err == nil {
 		smtp.Username = value
 	}
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'smtp_from_name'").Scan(&value); err == nil {
+	if value, err := settings.GetSite("smtp_from_name"); err == nil {
 		smtp.FromName = value
 	}
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'smtp_from_email'").Scan(&value); err == nil {
+	if value, err := settings.GetSite("smtp_from_email"); err == nil {
 		smtp.FromEmail = value
 	}
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'smtp_enabled'").Scan(&value); err == nil {
+	if value, err := settings.GetSite("smtp_enabled"); err == nil {
 		smtp.Enabled = value == "true"
 	}
 
@@ -734,21 +759,33 @@ func getSiteSettings(db *database.DB) *SiteSettings {
 	site := &SiteSettings{
 		SiteTitle: "P-TRACK", // Default
 	}
-
-	var value string
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'site_url'").Scan(&value); err == nil {
+	settings := repository.NewSettingsRepository(db)
+	if value, err := settings.GetSite("site_url"); err == nil {
 		site.SiteURL = value
 	}
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'site_title'").Scan(&value); err == nil && value != "" {
+	if value, err := settings.GetSite("site_title"); err == nil && value != "" {
 		site.SiteTitle = value
 	}
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'site_description'").Scan(&value); err == nil {
+	if value, err := settings.GetSite("site_description"); err == nil {
 		site.SiteDescription = value
 	}
+	if value, err := settings.GetSite("report_letterhead"); err == nil {
+		site.ReportLetterhead = value
+	}
+	site.RegistrationMode = registrationMode(db)
 
 	return site
 }
 
+// registrationMode returns who can register. Registration is open until the
+// admin changes it.
+func registrationMode(db *database.DB) string {
+	if value, err := repository.NewSettingsRepository(db).GetSite("registration_mode"); err == nil && value != "" {
+		return value
+	}
+	return RegistrationOpen
+}
+
 func getSiteStats(db *database.DB) *SiteStats {
 	stats := &SiteStats{}
 
@@ -765,79 +802,19 @@ func IsSMTPConfigured(db *database.DB) bool {
 	return smtp.Enabled && smtp.Host != "" && smtp.Port > 0 && smtp.FromEmail != ""
 }
 
-// sendTestEmail sends a test email using the provided SMTP settings
-func sendTestEmail(settings SMTPSettings, password string, toEmail string) error {
-	addr := fmt.Sprintf("%s:%d", settings.Host, settings.Port)
-
-	// Setup message
-	from := settings.FromEmail
-	if settings.FromName != "" {
-		from = fmt.Sprintf("%s <%s>", settings.FromName, settings.FromEmail)
-	}
-
-	subject := "P-TRACK SMTP Test"
-	body := "This is a test email from P-TRACK to verify your SMTP configuration is working correctly."
-
-	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
-		from, toEmail, subject, body)
-
-	// Use TLS for port 465, STARTTLS for other ports
-	if settings.Port == 465 {
-		// Direct TLS connection
-		tlsConfig := &tls.Config{
-			ServerName: settings.Host,
-		}
-
-		conn, err := tls.Dial("tcp", addr, tlsConfig)
-		if err != nil {
-			return fmt.Errorf("TLS connection failed: %w", err)
-		}
-		defer conn.Close()
-
-		client, err := netsmtp.NewClient(conn, settings.Host)
-		if err != nil {
-			return fmt.Errorf("SMTP client creation failed: %w", err)
-		}
-		defer client.Close()
-
-		// Auth if credentials provided
-		if settings.Username != "" && password != "" {
-			auth := netsmtp.PlainAuth("", settings.Username, password, settings.Host)
-			if err := client.Auth(auth); err != nil {
-				return fmt.Errorf("authentication failed: %w", err)
-			}
-		}
-
-		if err := client.Mail(settings.FromEmail); err != nil {
-			return fmt.Errorf("MAIL FROM failed: %w", err)
-		}
-		if err := client.Rcpt(toEmail); err != nil {
-			return fmt.Errorf("RCPT TO failed: %w", err)
-		}
-
-		wc, err := client.Data()
-		if err != nil {
-			return fmt.Errorf("DATA failed: %w", err)
-		}
-		_, err = wc.Write([]byte(msg))
-		wc.Close()
-		if err != nil {
-			return fmt.Errorf("write message failed: %w", err)
-		}
-
-		return client.Quit()
-	}
-
-	// Standard SMTP with optional STARTTLS
-	var auth netsmtp.Auth
-	if settings.Username != "" && password != "" {
-		auth = netsmtp.PlainAuth("", settings.Username, password, settings.Host)
-	}
-
-	err := netsmtp.SendMail(addr, auth, settings.FromEmail, []string{toEmail}, []byte(msg))
-	if err != nil {
-		return fmt.Errorf("send mail failed: %w", err)
+// sendTestEmail sends a test email using the provided SMTP settings, in p's
+// language
+func sendTestEmail(p *i18n.Printer, settings SMTPSettings, password string, toEmail string) error {
+	cfg := services.SMTPConfig{
+		Host:      settings.Host,
+		Port:      settings.Port,
+		Username:  settings.Username,
+		Password:  password,
+		FromName:  settings.FromName,
+		FromEmail: settings.FromEmail,
+		Enabled:   settings.Enabled,
 	}
 
-	return nil
+	return services.SendEmail(cfg, toEmail, p.T("P-TRACK SMTP Test"),
+		p.T("This is a test email from P-TRACK to verify your SMTP configuration is working correctly."))
 }
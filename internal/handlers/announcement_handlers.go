@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/web"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maintenance turns away everyone but the admin while enabled
+var maintenance = middleware.NewMaintenanceMode(renderMaintenancePage)
+
+// Maintenance returns the server's maintenance mode
+func Maintenance() *middleware.MaintenanceMode {
+	return maintenance
+}
+
+// LoadMaintenanceMode restores maintenance mode as it was last set, so it
+// survives a restart in the middle of the work it was enabled for
+func LoadMaintenanceMode(db *database.DB) {
+	maintenance.Set(getSettingValue(db, "maintenance_mode") == "true", getSettingValue(db, "maintenance_message"))
+}
+
+// MaintenanceGate lets only the admin through while maintenance mode is
+// enabled. Anyone can still log out. It must come after authentication.
+func MaintenanceGate(db *database.DB) func(http.Handler) http.Handler {
+	return maintenance.Middleware(func(r *http.Request) bool {
+		if strings.HasSuffix(r.URL.Path, "/auth/logout") {
+			return true
+		}
+		userID := middleware.GetUserID(r.Context())
+		return userID != 0 && IsAdmin(db.WithContext(r.Context()), userID)
+	})
+}
+
+// renderMaintenancePage is shown to browsers turned away by maintenance mode
+func renderMaintenancePage(w http.ResponseWriter, r *http.Request, message string) {
+	data := map[string]interface{}{
+		"Title":              "Maintenance",
+		"IsAuthenticated":    false,
+		"MaintenanceMessage": message,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := web.Render(w, r, "maintenance.html", data); err != nil {
+		slog.Error("Failed to render maintenance page", "err", err)
+	}
+}
+
+// AnnouncementResponse is a site-wide banner
+type AnnouncementResponse struct {
+	ID        int64      `json:"id"`
+	Message   string     `json:"message" validate:"required,multiline,max=1000"`
+	Severity  string     `json:"severity" validate:"oneof=info warning danger"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Active    bool       `json:"active"`
+}
+
+func toAnnouncementResponse(a *models.Announcement, now time.Time) AnnouncementResponse {
+	resp := AnnouncementResponse{
+		ID:        a.ID,
+		Message:   a.Message,
+		Severity:  a.Severity,
+		CreatedAt: a.CreatedAt,
+		Active:    true,
+	}
+	if a.ExpiresAt.Valid {
+		resp.ExpiresAt = &a.ExpiresAt.Time
+		resp.Active = a.ExpiresAt.Time.After(now)
+	}
+	return resp
+}
+
+// activeAnnouncements returns the banners to show on every page
+func activeAnnouncements(db *database.DB) []AnnouncementResponse {
+	now := time.Now()
+	announcements, err := repository.NewAnnouncementRepository(db).ListActive(now)
+	if err != nil {
+		slog.Error("Failed to load announcements", "err", err)
+	}
+	resp := []AnnouncementResponse{}
+	for _, a := range announcements {
+		resp = append(resp, toAnnouncementResponse(a, now))
+	}
+	return resp
+}
+
+// HandleGetAnnouncements returns the announcements that haven't expired
+func HandleGetAnnouncements(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(activeAnnouncements(db))
+	}
+}
+
+// HandleListAllAnnouncements returns every announcement, expired or not
+func HandleListAllAnnouncements(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		announcements, err := repository.NewAnnouncementRepository(db).List()
+		if err != nil {
+			respond.Error(w, "Failed to load announcements", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		resp := []AnnouncementResponse{}
+		for _, a := range announcements {
+			resp = append(resp, toAnnouncementResponse(a, now))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// CreateAnnouncementRequest is a new announcement. Severity is info,
+// warning or danger; info if empty. Without ExpiresAt it shows until
+// deleted.
+type CreateAnnouncementRequest struct {
+	Message   string     `json:"message" validate:"required,multiline,max=1000"`
+	Severity  string     `json:"severity" validate:"oneof=info warning danger"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// HandleCreateAnnouncement posts an announcement
+func HandleCreateAnnouncement(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req CreateAnnouncementRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Severity == "" {
+			req.Severity = "info"
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			respond.Validation(w, "", respond.Field("expires_at", "must be in the future"))
+			return
+		}
+
+		a := &models.Announcement{
+			Message:   req.Message,
+			Severity:  req.Severity,
+			CreatedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if req.ExpiresAt != nil {
+			a.ExpiresAt = sql.NullTime{Time: *req.ExpiresAt, Valid: true}
+		}
+		if err := repository.NewAnnouncementRepository(db).Create(a); err != nil {
+			respond.Error(w, "Failed to create announcement", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"announcement",
+			sql.NullInt64{Int64: a.ID, Valid: true},
+			map[string]interface{}{"severity": a.Severity},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respond.JSON(w, http.StatusCreated, toAnnouncementResponse(a, time.Now()))
+	}
+}
+
+// HandleDeleteAnnouncement removes an announcement
+func HandleDeleteAnnouncement(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid announcement ID", http.StatusBadRequest)
+			return
+		}
+		if err := repository.NewAnnouncementRepository(db).Delete(id); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Announcement not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete announcement", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"announcement",
+			sql.NullInt64{Int64: id, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MaintenanceModeSettings is whether maintenance mode is on, and what the
+// turned-away users are told
+type MaintenanceModeSettings struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message" validate:"multiline,max=1000"`
+}
+
+// HandleGetMaintenanceMode returns the maintenance mode settings
+func HandleGetMaintenanceMode(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		enabled, message := maintenance.Status()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(MaintenanceModeSettings{Enabled: enabled, Message: message})
+	}
+}
+
+// HandleUpdateMaintenanceMode turns maintenance mode on or off
+func HandleUpdateMaintenanceMode(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req MaintenanceModeSettings
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		setSettingValue(db, "maintenance_mode", strconv.FormatBool(req.Enabled))
+		setSettingValue(db, "maintenance_message", req.Message)
+		maintenance.Set(req.Enabled, req.Message)
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"maintenance_mode",
+			sql.NullInt64{},
+			map[string]interface{}{"enabled": req.Enabled},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+		middleware.Log(r.Context()).Info("Maintenance mode changed", "enabled", req.Enabled)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(req)
+	}
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AppointmentRequest is the payload for creating or updating an appointment
+type AppointmentRequest struct {
+	Title    *string    `json:"title,omitempty" validate:"max=200"`
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	Location *string    `json:"location,omitempty" validate:"max=200"`
+	Notes    *string    `json:"notes,omitempty" validate:"multiline,max=2000"`
+}
+
+// AppointmentResponse is an appointment as returned by the API
+type AppointmentResponse struct {
+	ID        int64      `json:"id"`
+	Title     string     `json:"title"`
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Location  string     `json:"location,omitempty"`
+	Notes     string     `json:"notes,omitempty"`
+	CreatedBy *int64     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func toAppointmentResponse(a *models.Appointment) AppointmentResponse {
+	resp := AppointmentResponse{
+		ID:        a.ID,
+		Title:     a.Title,
+		StartsAt:  a.StartsAt,
+		Location:  a.Location.String,
+		Notes:     a.Notes.String,
+		CreatedBy: nullInt64ToInt(a.CreatedBy),
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+	}
+	if a.EndsAt.Valid {
+		resp.EndsAt = &a.EndsAt.Time
+	}
+	return resp
+}
+
+// applyAppointmentRequest validates req and copies the provided fields onto
+// a, returning a client-facing error message on failure
+func applyAppointmentRequest(a *models.Appointment, req *AppointmentRequest) string {
+	if req.Title != nil {
+		if *req.Title == "" {
+			return "title is required"
+		}
+		a.Title = *req.Title
+	}
+	if req.StartsAt != nil {
+		a.StartsAt = req.StartsAt.UTC()
+	}
+	if req.EndsAt != nil {
+		a.EndsAt = sql.NullTime{Time: req.EndsAt.UTC(), Valid: true}
+	}
+	if req.Location != nil {
+		a.Location = optionalText(req.Location)
+	}
+	if req.Notes != nil {
+		a.Notes = optionalText(req.Notes)
+	}
+	if a.EndsAt.Valid && !a.EndsAt.Time.After(a.StartsAt) {
+		return "ends_at must be after starts_at"
+	}
+	return ""
+}
+
+// HandleGetAppointments lists the account's appointments, optionally those
+// starting between start_date and end_date (YYYY-MM-DD, inclusive)
+func HandleGetAppointments(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		loc := userLocation(db, userID)
+		var start, end time.Time
+		var err error
+		if v := r.URL.Query().Get("start_date"); v != "" {
+			if start, err = time.ParseInLocation("2006-01-02", v, loc); err != nil {
+				respond.Validation(w, "Invalid start_date format (use YYYY-MM-DD)", respond.Field("start_date", "invalid format (use YYYY-MM-DD)"))
+				return
+			}
+		}
+		if v := r.URL.Query().Get("end_date"); v != "" {
+			if end, err = time.ParseInLocation("2006-01-02", v, loc); err != nil {
+				respond.Validation(w, "Invalid end_date format (use YYYY-MM-DD)", respond.Field("end_date", "invalid format (use YYYY-MM-DD)"))
+				return
+			}
+			end = end.AddDate(0, 0, 1) // Include the whole end day
+		}
+
+		appointments, err := repository.NewAppointmentRepository(db).List(accountID, start, end)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve appointments", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]AppointmentResponse, 0, len(appointments))
+		for _, a := range appointments {
+			resp = append(resp, toAppointmentResponse(a))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleGetAppointment returns a single appointment
+func HandleGetAppointment(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid appointment ID", http.StatusBadRequest)
+			return
+		}
+
+		appointment, err := repository.NewAppointmentRepository(db).GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Appointment not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve appointment", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, toAppointmentResponse(appointment))
+	}
+}
+
+// HandleCreateAppointment adds an appointment to the account
+func HandleCreateAppointment(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req AppointmentRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Title == nil || req.StartsAt == nil {
+			var fields []respond.FieldError
+			if req.Title == nil {
+				fields = append(fields, respond.Field("title", "is required"))
+			}
+			if req.StartsAt == nil {
+				fields = append(fields, respond.Field("starts_at", "is required"))
+			}
+			respond.Validation(w, "title and starts_at are required", fields...)
+			return
+		}
+
+		appointment := &models.Appointment{
+			AccountID: accountID,
+			CreatedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if msg := applyAppointmentRequest(appointment, &req); msg != "" {
+			respond.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewAppointmentRepository(db).Create(appointment); err != nil {
+			respond.Error(w, "Failed to create appointment", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"appointment",
+			sql.NullInt64{Int64: appointment.ID, Valid: true},
+			map[string]interface{}{
+				"title":     appointment.Title,
+				"starts_at": appointment.StartsAt,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusCreated, toAppointmentResponse(appointment))
+	}
+}
+
+// HandleUpdateAppointment updates an appointment's details
+func HandleUpdateAppointment(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid appointment ID", http.StatusBadRequest)
+			return
+		}
+
+		var req AppointmentRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		appointmentRepo := repository.NewAppointmentRepository(db)
+		appointment, err := appointmentRepo.GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Appointment not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve appointment", http.StatusInternalServerError)
+			return
+		}
+
+		if msg := applyAppointmentRequest(appointment, &req); msg != "" {
+			respond.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		if err := appointmentRepo.Update(appointment); err != nil {
+			respond.Error(w, "Failed to update appointment", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"appointment",
+			sql.NullInt64{Int64: appointment.ID, Valid: true},
+			map[string]interface{}{
+				"title":     appointment.Title,
+				"starts_at": appointment.StartsAt,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, toAppointmentResponse(appointment))
+	}
+}
+
+// HandleDeleteAppointment deletes an appointment
+func HandleDeleteAppointment(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid appointment ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewAppointmentRepository(db).Delete(id, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Appointment not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete appointment", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"appointment",
+			sql.NullInt64{Int64: id, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
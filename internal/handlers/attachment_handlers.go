@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AttachmentResponse is attachment metadata as returned by the API
+type AttachmentResponse struct {
+	ID               int64     `json:"id"`
+	OriginalFilename string    `json:"original_filename"`
+	ContentType      string    `json:"content_type"`
+	SizeBytes        int64     `json:"size_bytes"`
+	SHA256           string    `json:"sha256"`
+	Width            *int64    `json:"width,omitempty"`
+	Height           *int64    `json:"height,omitempty"`
+	URL              string    `json:"url"`
+	ThumbnailURL     string    `json:"thumbnail_url,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func toAttachmentResponse(a *models.Attachment) AttachmentResponse {
+	resp := AttachmentResponse{
+		ID:               a.ID,
+		OriginalFilename: a.OriginalFilename,
+		ContentType:      a.ContentType,
+		SizeBytes:        a.SizeBytes,
+		SHA256:           a.SHA256,
+		Width:            nullInt64ToInt(a.Width),
+		Height:           nullInt64ToInt(a.Height),
+		URL:              fmt.Sprintf("/api/v1/attachments/%d/file", a.ID),
+		CreatedAt:        a.CreatedAt,
+	}
+	if a.ThumbnailKey.Valid {
+		resp.ThumbnailURL = fmt.Sprintf("/api/v1/attachments/%d/thumbnail", a.ID)
+	}
+	return resp
+}
+
+// attachmentStore returns the configured store for attachment files
+func attachmentStore() services.AttachmentStore {
+	return services.NewLocalAttachmentStore(services.DefaultUploadDir)
+}
+
+func newAttachmentService(db *database.DB) *services.AttachmentService {
+	return services.NewAttachmentService(db, attachmentStore())
+}
+
+// attachmentVisible reports whether an attachment exists in the account and
+// userID may see it
+func attachmentVisible(db *database.DB, attachmentID, accountID, userID int64) bool {
+	if attachmentID <= 0 || accountID == 0 {
+		return false
+	}
+	viewer, err := repository.LoadSymptomViewer(db, accountID, userID)
+	if err != nil {
+		return false
+	}
+	_, err = repository.NewAttachmentRepository(db).GetByID(attachmentID, accountID, viewer)
+	return err == nil
+}
+
+// HandleUploadAttachment accepts a multipart image upload in the "file" field.
+// Optional injection_id or symptom_id form fields link the new attachment.
+// Uploads that would take the account past its storage quota get 413.
+func HandleUploadAttachment(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// Leave headroom for multipart boundaries and other form fields
+		r.Body = http.MaxBytesReader(w, r.Body, services.MaxAttachmentSize+(1<<20))
+		if err := r.ParseMultipartForm(services.MaxAttachmentSize); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				respond.Error(w, fmt.Sprintf("File too large (max %d MB)", services.MaxAttachmentSize>>20), http.StatusRequestEntityTooLarge)
+				return
+			}
+			respond.Error(w, "Invalid multipart form", http.StatusBadRequest)
+			return
+		}
+		defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			respond.Validation(w, "file is required", respond.Field("file", "is required"))
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, services.MaxAttachmentSize+1))
+		if err != nil {
+			respond.Error(w, "Failed to read upload", http.StatusBadRequest)
+			return
+		}
+
+		var injectionID, symptomID int64
+		if v := r.FormValue("injection_id"); v != "" {
+			if injectionID, err = strconv.ParseInt(v, 10, 64); err != nil {
+				respond.Validation(w, "Invalid injection_id", respond.Field("injection_id", "is invalid"))
+				return
+			}
+		}
+		if v := r.FormValue("symptom_id"); v != "" {
+			if symptomID, err = strconv.ParseInt(v, 10, 64); err != nil {
+				respond.Validation(w, "Invalid symptom_id", respond.Field("symptom_id", "is invalid"))
+				return
+			}
+		}
+
+		if err := services.CheckAttachmentQuota(db, accountID, int64(len(data))); !checkQuota(w, r, err, http.StatusRequestEntityTooLarge) {
+			return
+		}
+
+		svc := newAttachmentService(db)
+		attachment, err := svc.Upload(accountID, sql.NullInt64{Int64: userID, Valid: true}, header.Filename, data)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrAttachmentTooLarge):
+				respond.Error(w, fmt.Sprintf("File too large (max %d MB)", services.MaxAttachmentSize>>20), http.StatusRequestEntityTooLarge)
+			case errors.Is(err, services.ErrUnsupportedAttachmentType):
+				respond.Error(w, "Unsupported file type (allowed: JPEG, PNG, GIF, WebP)", http.StatusUnsupportedMediaType)
+			case errors.Is(err, services.ErrAttachmentEmpty):
+				respond.Error(w, "File is empty", http.StatusBadRequest)
+			default:
+				middleware.Log(r.Context()).Error("Failed to store attachment", "err", err)
+				respond.Error(w, "Failed to store attachment", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		attachmentRepo := repository.NewAttachmentRepository(db)
+		if injectionID != 0 {
+			if err := attachmentRepo.LinkToInjection(attachment.ID, injectionID, accountID); err != nil {
+				_ = svc.Delete(attachment)
+				if err == repository.ErrNotFound {
+					respond.Error(w, "Injection not found", http.StatusNotFound)
+					return
+				}
+				respond.Error(w, "Failed to link attachment", http.StatusInternalServerError)
+				return
+			}
+		}
+		if symptomID != 0 {
+			viewer, err := repository.LoadSymptomViewer(db, accountID, userID)
+			if err == nil {
+				err = attachmentRepo.LinkToSymptomLog(attachment.ID, symptomID, accountID, viewer)
+			}
+			if err != nil {
+				_ = svc.Delete(attachment)
+				if err == repository.ErrNotFound {
+					respond.Error(w, "Symptom log not found", http.StatusNotFound)
+					return
+				}
+				respond.Error(w, "Failed to link attachment", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"attachment",
+			sql.NullInt64{Int64: attachment.ID, Valid: true},
+			map[string]interface{}{
+				"content_type": attachment.ContentType,
+				"size_bytes":   attachment.SizeBytes,
+				"injection_id": injectionID,
+				"symptom_id":   symptomID,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(toAttachmentResponse(attachment))
+	}
+}
+
+// getAttachmentFromRequest loads the {id} attachment for the current account,
+// writing an error response and returning nil if it cannot. Attachments on
+// another member's private symptom log are not found.
+func getAttachmentFromRequest(db *database.DB, w http.ResponseWriter, r *http.Request) *models.Attachment {
+	accountID := middleware.GetAccountID(r.Context())
+	userID := middleware.GetUserID(r.Context())
+	if accountID == 0 {
+		respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respond.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return nil
+	}
+
+	viewer, err := repository.LoadSymptomViewer(db, accountID, userID)
+	if err != nil {
+		respond.Error(w, "Failed to retrieve attachment", http.StatusInternalServerError)
+		return nil
+	}
+
+	attachment, err := repository.NewAttachmentRepository(db).GetByID(id, accountID, viewer)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			respond.Error(w, "Attachment not found", http.StatusNotFound)
+			return nil
+		}
+		respond.Error(w, "Failed to retrieve attachment", http.StatusInternalServerError)
+		return nil
+	}
+	return attachment
+}
+
+// HandleGetAttachment returns attachment metadata
+func HandleGetAttachment(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		attachment := getAttachmentFromRequest(db, w, r)
+		if attachment == nil {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toAttachmentResponse(attachment))
+	}
+}
+
+// HandleGetAttachmentFile streams the original uploaded file
+func HandleGetAttachmentFile(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		attachment := getAttachmentFromRequest(db, w, r)
+		if attachment == nil {
+			return
+		}
+		serveAttachment(w, r, attachment, attachment.StorageKey, attachment.ContentType)
+	}
+}
+
+// HandleGetAttachmentThumbnail streams the JPEG thumbnail, if one was generated
+func HandleGetAttachmentThumbnail(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		attachment := getAttachmentFromRequest(db, w, r)
+		if attachment == nil {
+			return
+		}
+		if !attachment.ThumbnailKey.Valid {
+			respond.Error(w, "No thumbnail available", http.StatusNotFound)
+			return
+		}
+		serveAttachment(w, r, attachment, attachment.ThumbnailKey.String, "image/jpeg")
+	}
+}
+
+func serveAttachment(w http.ResponseWriter, r *http.Request, attachment *models.Attachment, key, contentType string) {
+	f, err := attachmentStore().Open(key)
+	if err != nil {
+		middleware.Log(r.Context()).Error("Failed to open attachment", "attachment_id", attachment.ID, "key", key, "err", err)
+		respond.Error(w, "Attachment file not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	// Stored content never changes for a key, so it can be cached privately
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.OriginalFilename}))
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", attachment.CreatedAt, rs)
+		return
+	}
+	_, _ = io.Copy(w, f)
+}
+
+// HandleDeleteAttachment deletes an attachment; linked injections and symptom
+// logs keep their other data and simply lose the reference
+func HandleDeleteAttachment(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		attachment := getAttachmentFromRequest(db, w, r)
+		if attachment == nil {
+			return
+		}
+
+		if err := newAttachmentService(db).Delete(attachment); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Attachment not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete attachment", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"attachment",
+			sql.NullInt64{Int64: attachment.ID, Valid: true},
+			map[string]interface{}{
+				"original_filename": attachment.OriginalFilename,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
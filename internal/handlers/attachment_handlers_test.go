@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+)
+
+func TestGetAttachmentOnPrivateSymptomLog(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'author', 'hash'), (2, 'member', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner'), (1, 2, 'member');
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', '2026-03-01 00:00:00', 1);
+		INSERT INTO attachments (id, account_id, uploaded_by, original_filename, content_type, size_bytes, sha256, storage_key)
+		VALUES (1, 1, 1, 'site.jpg', 'image/jpeg', 10, 'abc', 'ab/abc.jpg');
+		INSERT INTO symptom_logs (course_id, logged_by, timestamp, attachment_id, visibility) VALUES (1, 1, '2026-03-02 08:00:00', 1, 'private');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+
+	get := func(path string, userID int64) int {
+		router := chi.NewRouter()
+		router.Get("/attachments/{id}", HandleGetAttachment(db))
+		router.Get("/attachments/{id}/file", HandleGetAttachmentFile(db))
+		router.Get("/attachments/{id}/thumbnail", HandleGetAttachmentThumbnail(db))
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		userCtx := &middleware.UserContext{UserID: userID, AccountID: 1, Role: "member"}
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, userCtx))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, suffix := range []string{"", "/file", "/thumbnail"} {
+		path := fmt.Sprintf("/attachments/1%s", suffix)
+		if code := get(path, 2); code != http.StatusNotFound {
+			t.Errorf("Expected 404 for another member's private attachment at %s, got %d", path, code)
+		}
+	}
+	if code := get("/attachments/1", 1); code != http.StatusOK {
+		t.Errorf("Expected the author to see their attachment, got %d", code)
+	}
+
+	if _, err := db.Exec(`UPDATE symptom_logs SET visibility = 'shared'`); err != nil {
+		t.Fatalf("Failed to share the log: %v", err)
+	}
+	if code := get("/attachments/1", 2); code != http.StatusOK {
+		t.Errorf("Expected a shared log's attachment to be visible, got %d", code)
+	}
+}
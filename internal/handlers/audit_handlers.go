@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// AuditLogResponse is an audit log entry. Details is the JSON the entry was
+// logged with, or a string for older entries that aren't JSON.
+type AuditLogResponse struct {
+	ID         int64           `json:"id"`
+	UserID     *int64          `json:"user_id"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   *int64          `json:"entity_id"`
+	Details    json.RawMessage `json:"details"`
+	IPAddress  string          `json:"ip_address,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	Timestamp  string          `json:"timestamp"`
+	// ImpersonatorID is the admin who did it while impersonating the user
+	ImpersonatorID *int64 `json:"impersonator_id,omitempty"`
+}
+
+// AuditPruneResponse reports a retention run
+type AuditPruneResponse struct {
+	Deleted int64  `json:"deleted"`
+	Archive string `json:"archive,omitempty"`
+}
+
+func newAuditLogResponse(l *models.AuditLog) AuditLogResponse {
+	resp := AuditLogResponse{
+		ID:         l.ID,
+		Action:     l.Action,
+		EntityType: l.EntityType,
+		IPAddress:  l.IPAddress.String,
+		UserAgent:  l.UserAgent.String,
+		Timestamp:  l.Timestamp.UTC().Format(time.RFC3339),
+		Details:    json.RawMessage("null"),
+	}
+	if l.UserID.Valid {
+		resp.UserID = &l.UserID.Int64
+	}
+	if l.EntityID.Valid {
+		resp.EntityID = &l.EntityID.Int64
+	}
+	if l.ImpersonatorID.Valid {
+		resp.ImpersonatorID = &l.ImpersonatorID.Int64
+	}
+	if l.Details.Valid {
+		if json.Valid([]byte(l.Details.String)) {
+			resp.Details = json.RawMessage(l.Details.String)
+		} else {
+			resp.Details, _ = json.Marshal(l.Details.String)
+		}
+	}
+	return resp
+}
+
+// parseAuditFilter reads the user_id, action, entity_type, entity_id,
+// impersonator_id, start_date and end_date query parameters
+func parseAuditFilter(r *http.Request, loc *time.Location) (repository.AuditFilter, error) {
+	q := r.URL.Query()
+	filter := repository.AuditFilter{
+		Action:     q.Get("action"),
+		EntityType: q.Get("entity_type"),
+	}
+	var err error
+	if v := q.Get("user_id"); v != "" {
+		if filter.UserID, err = strconv.ParseInt(v, 10, 64); err != nil || filter.UserID <= 0 {
+			return filter, errors.New("Invalid user_id")
+		}
+	}
+	if v := q.Get("entity_id"); v != "" {
+		if filter.EntityID, err = strconv.ParseInt(v, 10, 64); err != nil || filter.EntityID <= 0 {
+			return filter, errors.New("Invalid entity_id")
+		}
+	}
+	if v := q.Get("impersonator_id"); v != "" {
+		if filter.ImpersonatorID, err = strconv.ParseInt(v, 10, 64); err != nil || filter.ImpersonatorID <= 0 {
+			return filter, errors.New("Invalid impersonator_id")
+		}
+	}
+	filter.Start, filter.End, err = parseListDateRange(r, loc)
+	return filter, err
+}
+
+// HandleGetAuditLogs lists audit log entries across the site, newest first
+func HandleGetAuditLogs(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter, err := parseAuditFilter(r, userLocation(db, userID))
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := repository.NewAuditRepository(db).ListPage(filter, page)
+		if err != nil {
+			listPageError(w, err, "Failed to retrieve audit logs")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, newListResponse(result, newAuditLogResponse))
+	}
+}
+
+// HandleExportAuditLogs streams every audit log entry matching the filters as
+// CSV
+func HandleExportAuditLogs(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+
+		filter, err := parseAuditFilter(r, userLocation(db, userID))
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filename := fmt.Sprintf("audit-logs-%s.csv", time.Now().Format("2006-01-02"))
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+		csvWriter := csv.NewWriter(w)
+		_ = csvWriter.Write(services.AuditCSVHeader)
+		// Headers are gone once rows start streaming, so a failure part way
+		// through can only cut the file short
+		_ = repository.NewAuditRepository(db).Each(filter, func(l *models.AuditLog) error {
+			return csvWriter.Write(services.AuditCSVRow(l))
+		})
+		csvWriter.Flush()
+	}
+}
+
+// HandleGetAuditRetention returns the audit log retention policy
+func HandleGetAuditRetention(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		respondJSON(w, http.StatusOK, services.LoadAuditRetention(db))
+	}
+}
+
+// HandleUpdateAuditRetention sets how many days audit logs are kept and
+// whether they are archived before being pruned
+func HandleUpdateAuditRetention(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+
+		var req services.AuditRetention
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		if err := services.SaveAuditRetention(db, req); err != nil {
+			respond.Error(w, "Failed to save retention policy", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update_audit_retention",
+			"settings",
+			sql.NullInt64{},
+			map[string]interface{}{"days": req.Days, "archive": req.Archive},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, services.LoadAuditRetention(db))
+	}
+}
+
+// HandlePruneAuditLogs applies the retention policy now rather than waiting
+// for the daily run
+func HandlePruneAuditLogs(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		deleted, archive, err := services.PruneAuditLogs(db, time.Now())
+		if err != nil {
+			respond.Error(w, "Failed to prune audit logs", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, AuditPruneResponse{Deleted: deleted, Archive: archive})
+	}
+}
@@ -3,16 +3,22 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	"injection-tracker/internal/auth"
 	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
 	"injection-tracker/internal/middleware"
 	"injection-tracker/internal/models"
 	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+	"injection-tracker/internal/web"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -25,16 +31,19 @@ const (
 
 // LoginRequest represents the login request payload
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"max=50"`
+	Password string `json:"password" validate:"raw"`
+	// RememberDevice trusts the device for the site's trusted device
+	// duration, so its sessions last that long
+	RememberDevice bool `json:"remember_device,omitempty"`
 }
 
 // RegisterRequest represents the registration request payload
 type RegisterRequest struct {
-	Username    string `json:"username"`
-	Password    string `json:"password"`
-	Email       string `json:"email,omitempty"`
-	InviteToken string `json:"invite_token,omitempty"` // For joining existing account
+	Username    string `json:"username" validate:"max=50"`
+	Password    string `json:"password" validate:"raw"`
+	Email       string `json:"email,omitempty" validate:"max=254"`
+	InviteToken string `json:"invite_token,omitempty" validate:"max=100"` // For joining existing account
 }
 
 // AuthResponse represents the authentication response
@@ -53,18 +62,13 @@ type UserResponse struct {
 	CreatedAt string `json:"created_at"`
 }
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
-}
-
 // HandleLogin handles user login with account lockout protection
 func HandleLogin(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc {
-	userRepo := repository.NewUserRepository(db)
-	auditRepo := repository.NewAuditRepository(db)
-
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userRepo := repository.NewUserRepository(db)
+		auditRepo := repository.NewAuditRepository(db)
+
 		var req LoginRequest
 
 		// Parse request - support both JSON and form data
@@ -82,6 +86,10 @@ func HandleLogin(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc
 			}
 			req.Username = r.FormValue("username")
 			req.Password = r.FormValue("password")
+			req.RememberDevice = r.FormValue("remember_device") == "true"
+		}
+		if !validFormRequest(w, r, &req) {
+			return
 		}
 
 		// Validate input
@@ -145,7 +153,7 @@ func HandleLogin(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc
 				ipAddress,
 				userAgent,
 			)
-			respondErrorWithRequest(w, r, http.StatusForbidden, fmt.Sprintf("Account is locked due to too many failed login attempts. Please try again in %d minutes.", LockoutDurationMins))
+			respondErrorWithRequest(w, r, http.StatusForbidden, "Account is locked due to too many failed login attempts. Please try again in %d minutes.", LockoutDurationMins)
 			return
 		}
 
@@ -154,7 +162,7 @@ func HandleLogin(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc
 			// Increment failed attempts
 			if err := userRepo.IncrementFailedLogins(user.ID); err != nil {
 				// Log error but continue with response
-				fmt.Printf("Error incrementing failed logins: %v\n", err)
+				middleware.Log(r.Context()).Error("Failed to increment failed logins", "err", err)
 			}
 
 			// Check if we need to lock the account
@@ -162,7 +170,7 @@ func HandleLogin(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc
 			if user.FailedLoginAttempts >= MaxFailedAttempts {
 				lockUntil := time.Now().Add(LockoutDurationMins * time.Minute)
 				if err := userRepo.LockAccount(user.ID, lockUntil); err != nil {
-					fmt.Printf("Error locking account: %v\n", err)
+					middleware.Log(r.Context()).Error("Failed to lock account", "err", err)
 				}
 
 				_ = auditRepo.LogWithDetails(
@@ -175,7 +183,7 @@ func HandleLogin(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc
 					userAgent,
 				)
 
-				respondErrorWithRequest(w, r, http.StatusForbidden, fmt.Sprintf("Account locked due to too many failed login attempts. Please try again in %d minutes.", LockoutDurationMins))
+				respondErrorWithRequest(w, r, http.StatusForbidden, "Account locked due to too many failed login attempts. Please try again in %d minutes.", LockoutDurationMins)
 				return
 			}
 
@@ -195,12 +203,12 @@ func HandleLogin(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc
 
 		// Successful login - reset failed attempts
 		if err := userRepo.ResetFailedLogins(user.ID); err != nil {
-			fmt.Printf("Error resetting failed logins: %v\n", err)
+			middleware.Log(r.Context()).Error("Failed to reset failed logins", "err", err)
 		}
 
 		// Update last login timestamp
 		if err := userRepo.UpdateLastLogin(user.ID); err != nil {
-			fmt.Printf("Error updating last login: %v\n", err)
+			middleware.Log(r.Context()).Error("Failed to update last login", "err", err)
 		}
 
 		// Get user's account
@@ -219,6 +227,19 @@ func HandleLogin(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc
 			respondErrorWithRequest(w, r, http.StatusInternalServerError, "User account not properly configured. Please contact support.")
 			return
 		}
+		if account.Suspension.String == models.AccountLocked {
+			_ = auditRepo.LogWithDetails(
+				sql.NullInt64{Int64: user.ID, Valid: true},
+				"login_failed",
+				"user",
+				sql.NullInt64{Int64: user.ID, Valid: true},
+				map[string]interface{}{"reason": "account_suspended"},
+				ipAddress,
+				userAgent,
+			)
+			respondErrorWithRequest(w, r, http.StatusForbidden, "This account has been locked by the site administrator")
+			return
+		}
 
 		// Get user's role in the account
 		member, err := accountRepo.GetMember(account.ID, user.ID)
@@ -227,8 +248,38 @@ func HandleLogin(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc
 			return
 		}
 
+		// A device the user already trusts keeps its trust, and they can
+		// choose to trust this one
+		device := currentTrustedDevice(db, r, user.ID)
+		if device == nil && req.RememberDevice && jwtManager.DeviceTrustDuration() > 0 {
+			var deviceToken string
+			device, deviceToken, err = repository.NewTrustedDeviceRepository(db).Create(user.ID, time.Now().Add(jwtManager.DeviceTrustDuration()), ipAddress, userAgent)
+			if err != nil {
+				middleware.Log(r.Context()).Error("Failed to trust device", "err", err)
+				device = nil
+			} else {
+				setDeviceCookie(w, r, deviceToken, device.ExpiresAt)
+			}
+		}
+
+		// Start a session for the device, so it can be signed out later. On
+		// a trusted device it lasts as long as the trust.
+		sessions := repository.NewSessionRepository(db)
+		var session *models.SessionToken
+		var sessionID string
+		if device != nil {
+			session, sessionID, err = sessions.CreateTrusted(user.ID, device.ID, device.ExpiresAt, ipAddress, userAgent)
+		} else {
+			session, sessionID, err = sessions.Create(user.ID, time.Now().Add(jwtManager.SessionDuration()), ipAddress, userAgent)
+		}
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to start session", "err", err)
+			respondErrorWithRequest(w, r, http.StatusInternalServerError, "An error occurred")
+			return
+		}
+
 		// Generate JWT token with account info
-		token, err := jwtManager.GenerateToken(user.ID, user.Username, account.ID, member.Role)
+		token, err := jwtManager.GenerateSessionToken(user.ID, user.Username, account.ID, member.Role, sessionID)
 		if err != nil {
 			respondErrorWithRequest(w, r, http.StatusInternalServerError, "Failed to generate authentication token")
 			return
@@ -239,23 +290,49 @@ func HandleLogin(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc
 			Name:     "auth_token",
 			Value:    token,
 			Path:     "/",
-			MaxAge:   int(jwtManager.SessionDuration().Seconds()),
+			MaxAge:   int(time.Until(session.ExpiresAt).Seconds()),
 			HttpOnly: true,
-			Secure:   true,
+			Secure:   middleware.SecureCookie(r),
 			SameSite: http.SameSiteStrictMode,
 		})
 
+		// Compare with earlier sign-ins before this one is logged
+		country := middleware.ClientCountry(r)
+		anomaly, err := services.DetectLoginAnomaly(db, user.ID, ipAddress, userAgent, country, time.Now())
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to check login history", "err", err)
+		}
+		details := anomaly.Details()
+		details["session_id"] = session.ID
+		if device != nil {
+			details["trusted_device_id"] = device.ID
+		}
+		if country != "" {
+			details["country"] = country
+		}
+
 		// Log successful login
 		_ = auditRepo.LogWithDetails(
 			sql.NullInt64{Int64: user.ID, Valid: true},
 			"login_success",
 			"user",
 			sql.NullInt64{Int64: user.ID, Valid: true},
-			nil,
+			details,
 			ipAddress,
 			userAgent,
 		)
 
+		// The alert goes out after the response, so it can't use the
+		// request's context
+		if anomaly.ShouldAlert() {
+			alertDB := db.Detach()
+			services.RunBackground(func() {
+				if err := services.SendLoginAlert(alertDB, user.ID, anomaly, ipAddress, userAgent, country); err != nil {
+					slog.Error("Failed to send login alert", "user_id", user.ID, "err", err)
+				}
+			})
+		}
+
 		// Respond based on request type
 		if r.Header.Get("HX-Request") == "true" {
 			// HTMX request - redirect to dashboard
@@ -278,12 +355,15 @@ func HandleLogin(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc
 	}
 }
 
-// HandleRegister handles user registration
+// HandleRegister handles user registration. Depending on the site's
+// registration mode, anyone can register, only someone with an invitation,
+// or nobody.
 func HandleRegister(db *database.DB) http.HandlerFunc {
-	userRepo := repository.NewUserRepository(db)
-	auditRepo := repository.NewAuditRepository(db)
-
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userRepo := repository.NewUserRepository(db)
+		auditRepo := repository.NewAuditRepository(db)
+
 		// Parse request - support both JSON and form data
 		var req RegisterRequest
 		contentType := r.Header.Get("Content-Type")
@@ -303,10 +383,33 @@ func HandleRegister(db *database.DB) http.HandlerFunc {
 			req.Email = r.FormValue("email")
 			req.InviteToken = r.FormValue("invite_token")
 		}
+		if !validFormRequest(w, r, &req) {
+			return
+		}
 
 		ipAddress := getIPAddress(r)
 		userAgent := r.Header.Get("User-Agent")
 
+		// The admin decides who can sign up; an invitation is checked below
+		mode := registrationMode(db)
+		if mode == RegistrationDisabled || (mode == RegistrationInviteOnly && req.InviteToken == "") {
+			_ = auditRepo.LogWithDetails(
+				sql.NullInt64{Valid: false},
+				"registration_failed",
+				"user",
+				sql.NullInt64{Valid: false},
+				map[string]interface{}{"reason": "registration_" + mode, "username": req.Username},
+				ipAddress,
+				userAgent,
+			)
+			if mode == RegistrationDisabled {
+				respondErrorWithRequest(w, r, http.StatusForbidden, "Registration is closed on this site")
+			} else {
+				respondErrorWithRequest(w, r, http.StatusForbidden, "Registration on this site is by invitation only")
+			}
+			return
+		}
+
 		// Validate input
 		if req.Username == "" || req.Password == "" {
 			respondErrorWithRequest(w, r, http.StatusBadRequest, "Username and password are required")
@@ -426,6 +529,26 @@ func HandleRegister(db *database.DB) http.HandlerFunc {
 				return
 			}
 
+			if err := services.CheckMemberQuota(db, invitation.AccountID, false); err != nil {
+				_ = userRepo.Delete(user.ID)
+				var quotaErr *services.QuotaError
+				if errors.As(err, &quotaErr) {
+					_ = auditRepo.LogWithDetails(
+						sql.NullInt64{Valid: false},
+						"registration_failed",
+						"user",
+						sql.NullInt64{Valid: false},
+						map[string]interface{}{"reason": "quota_exceeded", "account_id": invitation.AccountID},
+						ipAddress,
+						userAgent,
+					)
+					respondErrorWithRequest(w, r, http.StatusForbidden, quotaErr.Format(), quotaErr.Limit)
+					return
+				}
+				respondErrorWithRequest(w, r, http.StatusInternalServerError, "Failed to accept invitation")
+				return
+			}
+
 			// Accept the invitation
 			if err := accountRepo.AcceptInvitation(invitation.ID, user.ID); err != nil {
 				_ = userRepo.Delete(user.ID)
@@ -509,33 +632,11 @@ func HandleRegister(db *database.DB) http.HandlerFunc {
 		// Respond with success
 		if r.Header.Get("HX-Request") == "true" {
 			// HTMX request - show success message then redirect
-			w.Header().Set("Content-Type", "text/html")
-			w.WriteHeader(http.StatusOK)
-			successHTML := `
-<div role="alert" style="
-	background-color: #d4edda;
-	border: 2px solid #28a745;
-	border-radius: 8px;
-	padding: 1rem 1.25rem;
-	margin-bottom: 1.5rem;
-	box-shadow: 0 2px 4px rgba(0,0,0,0.1);
-">
-	<div style="display: flex; align-items: start; gap: 0.75rem;">
-		<span style="font-size: 1.5rem; line-height: 1;">✓</span>
-		<div style="flex: 1;">
-			<strong style="color: #28a745; font-size: 1rem; display: block; margin-bottom: 0.25rem;">Success!</strong>
-			<p style="color: #155724; margin: 0; font-size: 0.95rem; line-height: 1.5;">
-				Account created successfully. Redirecting to login...
-			</p>
-		</div>
-	</div>
-</div>
-<script>
-	setTimeout(function() {
-		window.location.href = "/login?registered=true";
-	}, 1500);
-</script>`
-			fmt.Fprint(w, successHTML)
+			renderFragment(w, r, http.StatusOK, "registration_success.html", web.Alert{
+				Kind:    "success",
+				Title:   "Success!",
+				Message: i18n.FromContext(r.Context()).T("Account created successfully. Redirecting to login..."),
+			})
 		} else {
 			// Standard JSON API response
 			respondJSON(w, http.StatusCreated, AuthResponse{
@@ -554,9 +655,10 @@ func HandleRegister(db *database.DB) http.HandlerFunc {
 
 // HandleLogout handles user logout
 func HandleLogout(db *database.DB) http.HandlerFunc {
-	auditRepo := repository.NewAuditRepository(db)
-
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		auditRepo := repository.NewAuditRepository(db)
+
 		// Get user context if available
 		userCtx := middleware.GetUserContext(r)
 		ipAddress := getIPAddress(r)
@@ -564,6 +666,7 @@ func HandleLogout(db *database.DB) http.HandlerFunc {
 
 		// Log logout
 		if userCtx != nil {
+			revokeCurrentSession(db, r)
 			_ = auditRepo.LogWithDetails(
 				sql.NullInt64{Int64: userCtx.UserID, Valid: true},
 				"logout",
@@ -576,15 +679,7 @@ func HandleLogout(db *database.DB) http.HandlerFunc {
 		}
 
 		// Clear authentication cookie
-		http.SetCookie(w, &http.Cookie{
-			Name:     "auth_token",
-			Value:    "",
-			Path:     "/",
-			MaxAge:   -1,
-			HttpOnly: true,
-			Secure:   true,
-			SameSite: http.SameSiteStrictMode,
-		})
+		clearAuthCookie(w, r)
 
 		respondJSON(w, http.StatusOK, map[string]interface{}{
 			"success": true,
@@ -593,11 +688,29 @@ func HandleLogout(db *database.DB) http.HandlerFunc {
 	}
 }
 
+// revokeCurrentSession revokes the session the request signed in with, so
+// its token can't be used again after logging out
+func revokeCurrentSession(db *database.DB, r *http.Request) {
+	sessionID := middleware.GetSessionID(r.Context())
+	if sessionID == "" {
+		return
+	}
+	sessions := repository.NewSessionRepository(db)
+	session, err := sessions.GetActive(sessionID)
+	if err != nil {
+		return
+	}
+	if err := sessions.Revoke(session.ID, session.UserID); err != nil {
+		middleware.Log(r.Context()).Error("Failed to revoke session", "err", err)
+	}
+}
+
 // HandleGetCurrentUser returns the current authenticated user's information
 func HandleGetCurrentUser(db *database.DB) http.HandlerFunc {
-	userRepo := repository.NewUserRepository(db)
-
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userRepo := repository.NewUserRepository(db)
+
 		// Get user context (set by auth middleware)
 		userCtx := middleware.GetUserContext(r)
 		if userCtx == nil {
@@ -632,12 +745,14 @@ func HandleGetCurrentUser(db *database.DB) http.HandlerFunc {
 	}
 }
 
-// HandleRefreshToken generates a new JWT token from an existing (possibly expired) token
+// HandleRefreshToken generates a new JWT token from an existing (possibly
+// expired) token, while the token's session is still in use
 func HandleRefreshToken(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc {
-	userRepo := repository.NewUserRepository(db)
-	auditRepo := repository.NewAuditRepository(db)
-
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userRepo := repository.NewUserRepository(db)
+		auditRepo := repository.NewAuditRepository(db)
+
 		ipAddress := getIPAddress(r)
 		userAgent := r.Header.Get("User-Agent")
 
@@ -698,14 +813,30 @@ func HandleRefreshToken(db *database.DB, jwtManager *auth.JWTManager) http.Handl
 			return
 		}
 
+		// Refreshing doesn't extend the session, which ends when its
+		// lifetime is over however much it is used
+		cookieAge := jwtManager.SessionDuration()
+		if claims.SessionID != "" {
+			session, err := repository.NewSessionRepository(db).GetActive(claims.SessionID)
+			if err == repository.ErrNotFound {
+				respondErrorWithRequest(w, r, http.StatusUnauthorized, "Invalid or expired token")
+				return
+			}
+			if err != nil {
+				respondErrorWithRequest(w, r, http.StatusInternalServerError, "An error occurred")
+				return
+			}
+			cookieAge = time.Until(session.ExpiresAt)
+		}
+
 		// Set new token in cookie
 		http.SetCookie(w, &http.Cookie{
 			Name:     "auth_token",
 			Value:    newToken,
 			Path:     "/",
-			MaxAge:   int(jwtManager.SessionDuration().Seconds()),
+			MaxAge:   int(cookieAge.Seconds()),
 			HttpOnly: true,
-			Secure:   true,
+			Secure:   middleware.SecureCookie(r),
 			SameSite: http.SameSiteStrictMode,
 		})
 
@@ -731,30 +862,10 @@ func HandleRefreshToken(db *database.DB, jwtManager *auth.JWTManager) http.Handl
 
 // Helper functions
 
-// getIPAddress extracts the client IP address from the request
+// getIPAddress extracts the client IP address from the request. Only
+// trusted proxies can change it; see middleware.RealIP.
 func getIPAddress(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies)
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		parts := strings.Split(ip, ",")
-		return strings.TrimSpace(parts[0])
-	}
-
-	// Check X-Real-IP header
-	ip = r.Header.Get("X-Real-IP")
-	if ip != "" {
-		return ip
-	}
-
-	// Fall back to RemoteAddr
-	ip = r.RemoteAddr
-	// RemoteAddr includes port, strip it
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
-	}
-
-	return ip
+	return middleware.ClientIP(r)
 }
 
 // getTokenFromRequest extracts JWT token from request (cookie or header)
@@ -778,72 +889,47 @@ func getTokenFromRequest(r *http.Request) string {
 
 // respondJSON sends a JSON response
 func respondJSON(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		fmt.Printf("Error encoding JSON response: %v\n", err)
-	}
-}
-
-// respondError sends an error response
-func respondError(w http.ResponseWriter, statusCode int, message string) {
-	respondJSON(w, statusCode, ErrorResponse{
-		Error:   http.StatusText(statusCode),
-		Message: message,
-	})
+	respond.JSON(w, statusCode, data)
 }
 
-// respondErrorWithRequest sends an error response (HTML for HTMX, JSON otherwise)
-func respondErrorWithRequest(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
+// respondErrorWithRequest sends an error response (HTML for HTMX, JSON
+// otherwise). args fill in message's verbs; the HTML is in the request's
+// language, the JSON in English.
+func respondErrorWithRequest(w http.ResponseWriter, r *http.Request, statusCode int, message string, args ...interface{}) {
 	if r.Header.Get("HX-Request") == "true" {
 		// HTMX request - return prominent HTML error message
-		w.Header().Set("Content-Type", "text/html")
-		w.WriteHeader(statusCode)
-		// Create a prominent, styled error alert
-		errorHTML := fmt.Sprintf(`
-<div role="alert" style="
-	background-color: #fee;
-	border: 2px solid #c33;
-	border-radius: 8px;
-	padding: 1rem 1.25rem;
-	margin-bottom: 1.5rem;
-	box-shadow: 0 2px 4px rgba(0,0,0,0.1);
-">
-	<div style="display: flex; align-items: start; gap: 0.75rem;">
-		<span style="font-size: 1.5rem; line-height: 1;">⚠️</span>
-		<div style="flex: 1;">
-			<strong style="color: #c33; font-size: 1rem; display: block; margin-bottom: 0.25rem;">Error</strong>
-			<p style="color: #333; margin: 0; font-size: 0.95rem; line-height: 1.5;">%s</p>
-		</div>
-	</div>
-</div>`, message)
-		fmt.Fprint(w, errorHTML)
+		renderFragment(w, r, statusCode, "alert.html", web.Alert{
+			Kind:    "danger",
+			Title:   "Error",
+			Message: i18n.FromContext(r.Context()).T(message, args...),
+		})
 	} else {
 		// Standard JSON response
-		respondError(w, statusCode, message)
+		respond.Error(w, fmt.Sprintf(message, args...), statusCode)
 	}
 }
 
 // HandleSetup handles first-run setup (creates initial admin user)
 func HandleSetup(db *database.DB) http.HandlerFunc {
-	userRepo := repository.NewUserRepository(db)
-	auditRepo := repository.NewAuditRepository(db)
-
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userRepo := repository.NewUserRepository(db)
+		auditRepo := repository.NewAuditRepository(db)
+
 		// Check if users already exist (prevent setup bypass)
 		var count int
 		if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
-			http.Error(w, "Database error", http.StatusInternalServerError)
+			respond.Error(w, "Database error", http.StatusInternalServerError)
 			return
 		}
 		if count > 0 {
-			http.Error(w, "Setup already completed", http.StatusForbidden)
+			respond.Error(w, "Setup already completed", http.StatusForbidden)
 			return
 		}
 
 		// Parse form data
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			respond.Error(w, "Invalid form data", http.StatusBadRequest)
 			return
 		}
 
@@ -854,29 +940,29 @@ func HandleSetup(db *database.DB) http.HandlerFunc {
 
 		// Validate inputs
 		if username == "" || password == "" {
-			http.Error(w, "Username and password are required", http.StatusBadRequest)
+			respond.Error(w, "Username and password are required", http.StatusBadRequest)
 			return
 		}
 
 		if password != confirmPassword {
-			http.Error(w, "Passwords do not match", http.StatusBadRequest)
+			respond.Error(w, "Passwords do not match", http.StatusBadRequest)
 			return
 		}
 
 		if len(password) < 8 {
-			http.Error(w, "Password must be at least 8 characters", http.StatusBadRequest)
+			respond.Error(w, "Password must be at least 8 characters", http.StatusBadRequest)
 			return
 		}
 
 		if len(username) < 3 || len(username) > 50 {
-			http.Error(w, "Username must be 3-50 characters", http.StatusBadRequest)
+			respond.Error(w, "Username must be 3-50 characters", http.StatusBadRequest)
 			return
 		}
 
 		// Hash password
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
 		if err != nil {
-			http.Error(w, "Failed to process password", http.StatusInternalServerError)
+			respond.Error(w, "Failed to process password", http.StatusInternalServerError)
 			return
 		}
 
@@ -891,10 +977,10 @@ func HandleSetup(db *database.DB) http.HandlerFunc {
 		// Create user in database
 		if err := userRepo.Create(user); err != nil {
 			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-				http.Error(w, "Username already exists", http.StatusConflict)
+				respond.Error(w, "Username already exists", http.StatusConflict)
 				return
 			}
-			http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
+			respond.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -904,7 +990,7 @@ func HandleSetup(db *database.DB) http.HandlerFunc {
 		if err != nil {
 			// Rollback: Delete the user if account creation fails
 			_ = userRepo.Delete(user.ID)
-			http.Error(w, "Failed to create account: "+err.Error(), http.StatusInternalServerError)
+			respond.Error(w, "Failed to create account: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"injection-tracker/internal/database"
+)
+
+func TestRegisterFollowsRegistrationMode(t *testing.T) {
+	t.Chdir(t.TempDir())
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	register := func(username, inviteToken string) int {
+		body := `{"username":"` + username + `","password":"Correct-Horse-Battery-9","invite_token":"` + inviteToken + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		HandleRegister(db)(rec, req)
+		return rec.Code
+	}
+
+	setSettingValue(db, "registration_mode", RegistrationDisabled)
+	if code := register("closed", ""); code != http.StatusForbidden {
+		t.Errorf("Disabled registration returned %d, want %d", code, http.StatusForbidden)
+	}
+	if code := register("closedinvite", "some-token"); code != http.StatusForbidden {
+		t.Errorf("Disabled registration with an invitation returned %d, want %d", code, http.StatusForbidden)
+	}
+
+	setSettingValue(db, "registration_mode", RegistrationInviteOnly)
+	if code := register("uninvited", ""); code != http.StatusForbidden {
+		t.Errorf("Invite-only registration without an invitation returned %d, want %d", code, http.StatusForbidden)
+	}
+
+	setSettingValue(db, "registration_mode", RegistrationOpen)
+	if code := register("welcome", ""); code != http.StatusCreated {
+		t.Errorf("Open registration returned %d, want %d", code, http.StatusCreated)
+	}
+
+	var users int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&users); err != nil {
+		t.Fatalf("Failed to count users: %v", err)
+	}
+	if users != 1 {
+		t.Errorf("%d users registered, want only the one from open registration", users)
+	}
+}
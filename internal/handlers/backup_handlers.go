@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,6 +17,9 @@ import (
 
 	"injection-tracker/internal/database"
 	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
 )
 
 // BackupInfo represents information about a backup file
@@ -23,19 +29,202 @@ type BackupInfo struct {
 	SizeHuman string `json:"size_human"`
 	CreatedAt string `json:"created_at"`
 	Path      string `json:"-"` // Internal use only
+
+	// Compressed and Encrypted are read from the file name's suffixes
+	Compressed bool `json:"compressed,omitempty"`
+	Encrypted  bool `json:"encrypted,omitempty"`
+}
+
+// newBackupInfo describes a backup file
+func newBackupInfo(name, path string, size int64, modTime time.Time) BackupInfo {
+	_, compressed, encrypted := services.SplitBackupName(name)
+	return BackupInfo{
+		Filename:   name,
+		Size:       size,
+		SizeHuman:  formatSize(size),
+		CreatedAt:  modTime.Format("2006-01-02 15:04:05"),
+		Path:       path,
+		Compressed: compressed,
+		Encrypted:  encrypted,
+	}
 }
 
 // AutoBackupSettings represents auto-backup configuration
 type AutoBackupSettings struct {
 	Enabled   bool   `json:"enabled"`
-	Frequency string `json:"frequency"` // "daily" or "weekly"
+	Frequency string `json:"frequency" validate:"oneof=daily weekly"` // "daily" or "weekly"
 	KeepCount int    `json:"keep_count"`
 	LastRun   string `json:"last_run,omitempty"`
+
+	// Destination is where auto-backups are also copied ("s3", "webdav" or
+	// "rsync"); empty keeps them on this server only. Secrets in
+	// DestinationConfig are masked when read back.
+	Destination       string                 `json:"destination"`
+	DestinationConfig map[string]interface{} `json:"destination_config,omitempty"`
+	RemoteLastUpload  string                 `json:"remote_last_upload,omitempty"`
+	RemoteLastError   string                 `json:"remote_last_error,omitempty"`
+
+	// Compress gzips backups. Encryption is "passphrase" or "keyfile" to
+	// encrypt them with age, using Passphrase (masked when read back) or the
+	// age identity file at KeyFile. Both apply to every backup made here,
+	// not just auto-backups.
+	Compress   bool   `json:"compress"`
+	Encryption string `json:"encryption"`
+	Passphrase string `json:"passphrase,omitempty"`
+	KeyFile    string `json:"key_file,omitempty"`
 }
 
-var (
-	shutdownChan = make(chan struct{})
-)
+// backupDestinationTimeout bounds one upload, listing or download
+const backupDestinationTimeout = 30 * time.Minute
+
+// pendingRestoreFlag names the backup a restart should swap in
+var pendingRestoreFlag = filepath.Join("data", "pending_restore")
+
+// requestGate holds requests back while a restore swaps the database
+var requestGate = middleware.NewRequestGate()
+
+// RequestGate returns the gate every request must pass through, so a
+// restore can wait for them to finish
+func RequestGate() *middleware.RequestGate {
+	return requestGate
+}
+
+// PendingRestore returns the backup waiting to be swapped in at the next
+// start, if there is one
+func PendingRestore() (string, bool) {
+	data, err := os.ReadFile(pendingRestoreFlag)
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(data)), true
+}
+
+// ApplyPendingRestore swaps a SQLite backup prepared by StageRestore into
+// place at dbPath. Nothing may have the database open: it runs at startup,
+// before the database is opened, and inside database.DB.Swap for a restore
+// made while the server runs. The replaced database is kept as
+// dbPath.pre_restore. It reports whether there was a restore to apply.
+func ApplyPendingRestore(dbPath string) (bool, error) {
+	data, err := os.ReadFile(pendingRestoreFlag)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	restorePath := strings.TrimSpace(string(data))
+	if _, err := os.Stat(restorePath); err != nil {
+		os.Remove(pendingRestoreFlag)
+		return false, fmt.Errorf("pending restore %s is missing: %w", restorePath, err)
+	}
+
+	// Keep the current database beside the new one. A hard link leaves it
+	// at dbPath too, so the rename below replaces it in one step and there
+	// is never a moment without a database.
+	keepPath := dbPath + ".pre_restore"
+	os.Remove(keepPath)
+	os.Remove(keepPath + "-wal")
+	if err := os.Link(dbPath, keepPath); err != nil && !os.IsNotExist(err) {
+		if err := os.Rename(dbPath, keepPath); err != nil {
+			return false, fmt.Errorf("failed to set aside current database: %w", err)
+		}
+	}
+	// Move the WAL along with the database it belongs to, in case it wasn't
+	// checkpointed when the database was closed
+	_ = os.Rename(dbPath+"-wal", keepPath+"-wal")
+	os.Remove(dbPath + "-shm")
+
+	if err := os.Rename(restorePath, dbPath); err != nil {
+		return false, fmt.Errorf("failed to move restore into place: %w", err)
+	}
+	os.Remove(pendingRestoreFlag)
+	return true, nil
+}
+
+// cancelPendingRestore drops a restore staged by StageRestore
+func cancelPendingRestore() {
+	if restorePath, ok := PendingRestore(); ok {
+		os.Remove(restorePath)
+	}
+	os.Remove(pendingRestoreFlag)
+}
+
+// restoreSQLite swaps a SQLite backup in under the running server. The
+// backup is staged and migrated first, so one that can't be brought up to
+// date is refused before anything changes. Then, once no other request is
+// in flight, the connection pool is closed, the file swapped and the pool
+// reopened, and live event streams are told to resync.
+func restoreSQLite(r *http.Request, db *database.DB, sourcePath string) error {
+	dbPath, ok := db.File()
+	if !ok {
+		return errors.New("not a SQLite database")
+	}
+	if err := StageRestore(sourcePath); err != nil {
+		return fmt.Errorf("failed to prepare restore: %w", err)
+	}
+
+	restorePath, _ := PendingRestore()
+	staged, err := database.Open(restorePath)
+	if err == nil {
+		err = staged.RunMigrations()
+		staged.Close()
+	}
+	if err != nil {
+		cancelPendingRestore()
+		return fmt.Errorf("failed to bring the backup up to date: %w", err)
+	}
+
+	return requestGate.Exclusive(r, func() error {
+		err := db.Swap(func() error {
+			_, err := ApplyPendingRestore(dbPath)
+			return err
+		})
+		if err != nil {
+			cancelPendingRestore()
+			return err
+		}
+		liveEvents.Reset()
+		return nil
+	})
+}
+
+// StageRestore copies a SQLite backup aside for ApplyPendingRestore to swap
+// in when the server next starts. The file can't be swapped while the
+// database is open.
+func StageRestore(sourcePath string) error {
+	backupDir, err := getBackupDir()
+	if err != nil {
+		return err
+	}
+	restorePath := filepath.Join(backupDir, "pending_restore.db")
+
+	src, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(restorePath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(restorePath)
+		return err
+	}
+
+	// ApplyPendingRestore finds this on startup
+	if err := os.WriteFile(pendingRestoreFlag, []byte(restorePath), 0644); err != nil {
+		os.Remove(restorePath)
+		return err
+	}
+	return nil
+}
 
 // getBackupDir returns the backup directory path, creating it if needed
 func getBackupDir() (string, error) {
@@ -63,15 +252,16 @@ func formatSize(bytes int64) string {
 // HandleListBackups returns list of available backup files
 func HandleListBackups(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
 		backupDir, err := getBackupDir()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -84,7 +274,7 @@ func HandleListBackups(db *database.DB) http.HandlerFunc {
 
 		backups := []BackupInfo{}
 		for _, entry := range entries {
-			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".db") {
+			if entry.IsDir() || !services.IsBackupName(entry.Name(), db.Dialect.BackupExtension()) {
 				continue
 			}
 
@@ -93,13 +283,7 @@ func HandleListBackups(db *database.DB) http.HandlerFunc {
 				continue
 			}
 
-			backups = append(backups, BackupInfo{
-				Filename:  entry.Name(),
-				Size:      info.Size(),
-				SizeHuman: formatSize(info.Size()),
-				CreatedAt: info.ModTime().Format("2006-01-02 15:04:05"),
-				Path:      filepath.Join(backupDir, entry.Name()),
-			})
+			backups = append(backups, newBackupInfo(entry.Name(), filepath.Join(backupDir, entry.Name()), info.Size(), info.ModTime()))
 		}
 
 		sort.Slice(backups, func(i, j int) bool {
@@ -114,15 +298,16 @@ func HandleListBackups(db *database.DB) http.HandlerFunc {
 // HandleCreateBackup creates a new database backup
 func HandleCreateBackup(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
 		backup, err := CreateBackup(db, "manual")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -141,54 +326,99 @@ func CreateBackup(db *database.DB, prefix string) (*BackupInfo, error) {
 		return nil, err
 	}
 
+	codec := backupCodec(db)
 	timestamp := time.Now().Format("2006-01-02_150405")
-	backupFilename := fmt.Sprintf("%s_%s.db", prefix, timestamp)
-	backupPath := filepath.Join(backupDir, backupFilename)
+	plainPath := filepath.Join(backupDir, fmt.Sprintf("%s_%s%s", prefix, timestamp, db.Dialect.BackupExtension()))
+	backupPath := plainPath + codec.Suffix()
 
-	// Use SQLite's backup mechanism via VACUUM INTO
-	_, err = db.Exec(fmt.Sprintf("VACUUM INTO '%s'", backupPath))
-	if err != nil {
+	if err := db.Backup(plainPath); err != nil {
 		return nil, fmt.Errorf("failed to create backup: %w", err)
 	}
+	if backupPath != plainPath {
+		// Refuse rather than quietly leave an unencrypted backup behind
+		err := codec.Encode(plainPath, backupPath)
+		os.Remove(plainPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode backup: %w", err)
+		}
+	}
 
 	info, err := os.Stat(backupPath)
 	if err != nil {
 		return nil, fmt.Errorf("backup created but failed to get info: %w", err)
 	}
 
-	return &BackupInfo{
-		Filename:  backupFilename,
-		Size:      info.Size(),
-		SizeHuman: formatSize(info.Size()),
-		CreatedAt: info.ModTime().Format("2006-01-02 15:04:05"),
-		Path:      backupPath,
-	}, nil
+	backup := newBackupInfo(filepath.Base(backupPath), backupPath, info.Size(), info.ModTime())
+	return &backup, nil
+}
+
+// backupCodec returns how new backups are compressed and encrypted, and the
+// keys to decrypt them
+func backupCodec(db *database.DB) services.BackupCodec {
+	codec := services.BackupCodec{Compress: getSettingValue(db, "auto_backup_compress") == "true"}
+	switch getSettingValue(db, "auto_backup_encryption") {
+	case services.BackupEncryptionPassphrase:
+		codec.Passphrase = getSettingValue(db, "auto_backup_passphrase")
+	case services.BackupEncryptionKeyFile:
+		codec.KeyFile = getSettingValue(db, "auto_backup_key_file")
+	}
+	return codec
+}
+
+// OpenBackup returns the path of a plain copy of the backup at path. A
+// compressed or encrypted backup is decoded into a temporary file, which
+// cleanup removes; otherwise path itself is returned. passphrase is tried
+// as well as the configured keys, for backups encrypted elsewhere or
+// before the passphrase was changed.
+func OpenBackup(db *database.DB, path, passphrase string) (plainPath string, cleanup func(), err error) {
+	if _, compressed, encrypted := services.SplitBackupName(path); !compressed && !encrypted {
+		return path, func() {}, nil
+	}
+
+	codec := backupCodec(db)
+	if passphrase != "" {
+		codec.Passphrase = passphrase
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".decoded-*")
+	if err != nil {
+		return "", nil, err
+	}
+	tmp.Close()
+	cleanup = func() { os.Remove(tmp.Name()) }
+
+	if err := codec.Decode(path, tmp.Name()); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmp.Name(), cleanup, nil
 }
 
-// HandleDownloadBackup downloads a backup file
+// HandleDownloadBackup downloads a backup file. Compressed and encrypted
+// backups are decoded first unless ?raw=1 asks for the file as stored.
 func HandleDownloadBackup(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
 		filename := r.URL.Query().Get("file")
 		if filename == "" {
-			http.Error(w, "Filename required", http.StatusBadRequest)
+			respond.Error(w, "Filename required", http.StatusBadRequest)
 			return
 		}
 
 		filename = filepath.Base(filename)
-		if !strings.HasSuffix(filename, ".db") {
-			http.Error(w, "Invalid backup file", http.StatusBadRequest)
+		if !services.IsBackupName(filename, db.Dialect.BackupExtension()) {
+			respond.Error(w, "Invalid backup file", http.StatusBadRequest)
 			return
 		}
 
 		backupDir, err := getBackupDir()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -197,13 +427,33 @@ func HandleDownloadBackup(db *database.DB) http.HandlerFunc {
 		absBackupDir, _ := filepath.Abs(backupDir)
 		absPath, err := filepath.Abs(backupPath)
 		if err != nil || !strings.HasPrefix(absPath, absBackupDir) {
-			http.Error(w, "Invalid backup path", http.StatusBadRequest)
+			respond.Error(w, "Invalid backup path", http.StatusBadRequest)
+			return
+		}
+		if _, err := os.Stat(backupPath); err != nil {
+			respond.Error(w, "Backup file not found", http.StatusNotFound)
 			return
 		}
 
+		if r.URL.Query().Get("raw") != "1" {
+			plainPath, cleanup, err := OpenBackup(db, backupPath, "")
+			if services.IsBackupKeyError(err) {
+				respond.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				middleware.Log(r.Context()).Error("Failed to decode backup", "filename", filename, "err", err)
+				respond.Error(w, "Failed to decode backup", http.StatusInternalServerError)
+				return
+			}
+			defer cleanup()
+			backupPath = plainPath
+			filename, _, _ = services.SplitBackupName(filename)
+		}
+
 		file, err := os.Open(backupPath)
 		if err != nil {
-			http.Error(w, "Backup file not found", http.StatusNotFound)
+			respond.Error(w, "Backup file not found", http.StatusNotFound)
 			return
 		}
 		defer file.Close()
@@ -218,32 +468,35 @@ func HandleDownloadBackup(db *database.DB) http.HandlerFunc {
 	}
 }
 
+// DeleteBackupRequest names the backup file to delete
+type DeleteBackupRequest struct {
+	Filename string `json:"filename" validate:"required,max=255"`
+}
+
 // HandleDeleteBackup deletes a backup file
 func HandleDeleteBackup(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
-		var req struct {
-			Filename string `json:"filename"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Filename == "" {
-			http.Error(w, "Filename required", http.StatusBadRequest)
+		var req DeleteBackupRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
 		filename := filepath.Base(req.Filename)
-		if !strings.HasSuffix(filename, ".db") {
-			http.Error(w, "Invalid backup file", http.StatusBadRequest)
+		if !services.IsBackupName(filename, db.Dialect.BackupExtension()) {
+			respond.Error(w, "Invalid backup file", http.StatusBadRequest)
 			return
 		}
 
 		backupDir, err := getBackupDir()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -252,12 +505,12 @@ func HandleDeleteBackup(db *database.DB) http.HandlerFunc {
 		absBackupDir, _ := filepath.Abs(backupDir)
 		absPath, err := filepath.Abs(backupPath)
 		if err != nil || !strings.HasPrefix(absPath, absBackupDir) {
-			http.Error(w, "Invalid backup path", http.StatusBadRequest)
+			respond.Error(w, "Invalid backup path", http.StatusBadRequest)
 			return
 		}
 
 		if err := os.Remove(backupPath); err != nil {
-			http.Error(w, "Failed to delete backup", http.StatusInternalServerError)
+			respond.Error(w, "Failed to delete backup", http.StatusInternalServerError)
 			return
 		}
 
@@ -272,9 +525,10 @@ func HandleDeleteBackup(db *database.DB) http.HandlerFunc {
 // HandleUploadBackup handles backup file upload for restore
 func HandleUploadBackup(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
@@ -283,27 +537,42 @@ func HandleUploadBackup(db *database.DB) http.HandlerFunc {
 
 		file, header, err := r.FormFile("backup")
 		if err != nil {
-			http.Error(w, "Failed to read uploaded file", http.StatusBadRequest)
+			respond.Error(w, "Failed to read uploaded file", http.StatusBadRequest)
 			return
 		}
 		defer file.Close()
 
-		if !strings.HasSuffix(header.Filename, ".db") {
-			http.Error(w, "Invalid file type. Must be a .db file", http.StatusBadRequest)
+		ext := db.Dialect.BackupExtension()
+		uploadName, compressed, encrypted := services.SplitBackupName(header.Filename)
+		if !strings.HasSuffix(uploadName, ext) {
+			respond.Error(w, "Invalid file type. Must be a "+ext+" file, optionally compressed (.gz) or encrypted (.age)", http.StatusBadRequest)
 			return
 		}
 
 		backupDir, err := getBackupDir()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Save uploaded file to staging area
-		stagingPath := filepath.Join(backupDir, "restore_staging.db")
+		// Save uploaded file to staging area, keeping its suffixes so it can
+		// be decoded again at restore
+		stagingName := "restore_staging" + ext
+		if compressed {
+			stagingName += services.CompressedBackupSuffix
+		}
+		if encrypted {
+			stagingName += services.EncryptedBackupSuffix
+		}
+		stagingPath := filepath.Join(backupDir, stagingName)
+		// Only the latest upload is staged
+		previous, _ := filepath.Glob(filepath.Join(backupDir, "restore_staging"+ext+"*"))
+		for _, p := range previous {
+			os.Remove(p)
+		}
 		out, err := os.Create(stagingPath)
 		if err != nil {
-			http.Error(w, "Failed to save uploaded file", http.StatusInternalServerError)
+			respond.Error(w, "Failed to save uploaded file", http.StatusInternalServerError)
 			return
 		}
 
@@ -311,153 +580,145 @@ func HandleUploadBackup(db *database.DB) http.HandlerFunc {
 		out.Close()
 		if err != nil {
 			os.Remove(stagingPath)
-			http.Error(w, "Failed to save uploaded file", http.StatusInternalServerError)
+			respond.Error(w, "Failed to save uploaded file", http.StatusInternalServerError)
 			return
 		}
 
-		// Validate it's a valid SQLite database
-		testDB, err := sql.Open("sqlite3", stagingPath+"?mode=ro")
+		plainPath, cleanup, err := OpenBackup(db, stagingPath, r.FormValue("passphrase"))
 		if err != nil {
 			os.Remove(stagingPath)
-			http.Error(w, "Invalid database file", http.StatusBadRequest)
+			if services.IsBackupKeyError(err) {
+				respond.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			respond.Error(w, "Failed to decode backup: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		// Try a simple query to validate
-		var count int
-		err = testDB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table'").Scan(&count)
-		testDB.Close()
-		if err != nil || count == 0 {
+		err = db.ValidateBackup(plainPath)
+		cleanup()
+		if err != nil {
 			os.Remove(stagingPath)
-			http.Error(w, "Invalid or empty database file", http.StatusBadRequest)
+			respond.Error(w, "Invalid or empty database file", http.StatusBadRequest)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{
 			"message":      "Backup uploaded and validated. Ready to restore.",
-			"staging_file": "restore_staging.db",
+			"staging_file": stagingName,
 			"success":      true,
 		})
 	}
 }
 
-// HandleRestoreBackup performs the actual restore and triggers server restart
+// RestoreBackupRequest names the backup to restore; Confirm must be set.
+// Passphrase is only needed for a backup encrypted with a passphrase other
+// than the configured one.
+type RestoreBackupRequest struct {
+	Filename   string `json:"filename" validate:"max=255"`
+	Confirm    bool   `json:"confirm"`
+	Passphrase string `json:"passphrase,omitempty" validate:"raw"`
+}
+
+// HandleRestoreBackup replaces the database with a backup while the server
+// keeps running
 func HandleRestoreBackup(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
-		var req struct {
-			Filename string `json:"filename"`
-			Confirm  bool   `json:"confirm"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
+		var req RestoreBackupRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
 		if !req.Confirm {
-			http.Error(w, "Confirmation required", http.StatusBadRequest)
+			respond.Error(w, "Confirmation required", http.StatusBadRequest)
 			return
 		}
 
 		backupDir, err := getBackupDir()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// Determine source file
+		stagingName := "restore_staging" + db.Dialect.BackupExtension()
 		var sourcePath string
-		if req.Filename == "" || req.Filename == "restore_staging.db" {
-			sourcePath = filepath.Join(backupDir, "restore_staging.db")
+		if req.Filename == "" {
+			// The staged upload, whatever its suffixes
+			sourcePath = filepath.Join(backupDir, stagingName)
+			if staged, _ := filepath.Glob(sourcePath + "*"); len(staged) > 0 {
+				sourcePath = staged[0]
+			}
 		} else {
 			sourcePath = filepath.Join(backupDir, filepath.Base(req.Filename))
 		}
 
 		if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
-			http.Error(w, "Backup file not found", http.StatusNotFound)
+			respond.Error(w, "Backup file not found", http.StatusNotFound)
 			return
 		}
 
-		// Create pre-restore backup
-		_, err = CreateBackup(db, "pre_restore")
-		if err != nil {
-			http.Error(w, "Failed to create pre-restore backup: "+err.Error(), http.StatusInternalServerError)
+		// Decode before anything else so a wrong passphrase changes nothing
+		sourcePath, cleanup, err := OpenBackup(db, sourcePath, req.Passphrase)
+		if services.IsBackupKeyError(err) {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		dbPath := filepath.Join("data", "tracker.db")
-
-		// Close database and perform restore
-		// We'll copy the file and then signal for restart
-		restorePath := filepath.Join(backupDir, "pending_restore.db")
-
-		// Copy source to pending restore location
-		src, err := os.Open(sourcePath)
 		if err != nil {
-			http.Error(w, "Failed to open backup file", http.StatusInternalServerError)
+			respond.Error(w, "Failed to decode backup: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		defer src.Close()
+		defer cleanup()
 
-		dst, err := os.Create(restorePath)
+		// Create pre-restore backup
+		_, err = CreateBackup(db, "pre_restore")
 		if err != nil {
-			http.Error(w, "Failed to prepare restore", http.StatusInternalServerError)
+			respond.Error(w, "Failed to create pre-restore backup: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		_, err = io.Copy(dst, src)
-		dst.Close()
+		// PostgreSQL restores in place, in one transaction, so there is
+		// nothing to swap; other requests still wait so none straddles it
+		if db.Dialect.Name() == database.Postgres {
+			err = requestGate.Exclusive(r, func() error {
+				if err := db.Restore(sourcePath); err != nil {
+					return err
+				}
+				liveEvents.Reset()
+				return db.RunMigrations()
+			})
+		} else {
+			err = restoreSQLite(r, db, sourcePath)
+		}
 		if err != nil {
-			os.Remove(restorePath)
-			http.Error(w, "Failed to prepare restore", http.StatusInternalServerError)
+			middleware.Log(r.Context()).Error("Failed to restore backup", "err", err)
+			respond.Error(w, "Failed to restore backup: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Write a restore flag file that main.go can check on startup
-		flagPath := filepath.Join("data", "pending_restore")
-		_ = os.WriteFile(flagPath, []byte(restorePath), 0644)
-
+		middleware.Log(r.Context()).Info("Restored database from backup", "filename", req.Filename)
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{
-			"message": "Restore prepared. Server will restart now. Please wait and refresh the page.",
+			"message": "Backup restored. Please refresh the page.",
 			"success": true,
 		})
-
-		// Trigger graceful shutdown after response is sent
-		go func() {
-			time.Sleep(500 * time.Millisecond)
-
-			// Perform the actual file swap
-			db.Close()
-			time.Sleep(100 * time.Millisecond)
-
-			// Backup current DB
-			_ = os.Rename(dbPath, dbPath+".pre_restore")
-
-			// Move pending restore to main DB
-			_ = os.Rename(restorePath, dbPath)
-
-			// Remove flag file
-			os.Remove(flagPath)
-
-			// Exit - process manager should restart us
-			os.Exit(0)
-		}()
 	}
 }
 
 // HandleGetAutoBackupSettings returns auto-backup configuration
 func HandleGetAutoBackupSettings(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
@@ -470,26 +731,108 @@ func HandleGetAutoBackupSettings(db *database.DB) http.HandlerFunc {
 // HandleUpdateAutoBackupSettings updates auto-backup configuration
 func HandleUpdateAutoBackupSettings(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 || !IsAdmin(db, userID) {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			respond.Error(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
 		var req AutoBackupSettings
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
 		// Validate
-		if req.Frequency != "" && req.Frequency != "daily" && req.Frequency != "weekly" {
-			http.Error(w, "Frequency must be 'daily' or 'weekly'", http.StatusBadRequest)
-			return
+		if req.Frequency == "" {
+			req.Frequency = "daily"
 		}
 		if req.KeepCount < 1 {
 			req.KeepCount = 7
 		}
+
+		destConfig := ""
+		if req.Destination != "" {
+			if !services.IsValidBackupDestinationType(req.Destination) {
+				respond.Error(w, "Destination must be 's3', 'webdav' or 'rsync'", http.StatusBadRequest)
+				return
+			}
+			if req.DestinationConfig == nil {
+				req.DestinationConfig = map[string]interface{}{}
+			}
+			// Leaving a secret masked keeps the stored one
+			services.MergeBackupDestinationSecrets(getSettingValue(db, "auto_backup_destination_config"), req.DestinationConfig)
+			raw, err := json.Marshal(req.DestinationConfig)
+			if err != nil {
+				respond.Error(w, "Invalid destination config", http.StatusBadRequest)
+				return
+			}
+			if _, err := services.NewBackupDestination(req.Destination, string(raw), http.DefaultClient); err != nil {
+				respond.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			destConfig = string(raw)
+		}
+
+		passphrase, keyFile := "", ""
+		switch req.Encryption {
+		case "":
+		case services.BackupEncryptionPassphrase:
+			// Leaving it masked keeps the stored one
+			passphrase = req.Passphrase
+			if passphrase == "********" {
+				passphrase = getSettingValue(db, "auto_backup_passphrase")
+			}
+			if len(passphrase) < 12 {
+				respond.Error(w, "Backup passphrase must be at least 12 characters", http.StatusBadRequest)
+				return
+			}
+		case services.BackupEncryptionKeyFile:
+			keyFile = strings.TrimSpace(req.KeyFile)
+			if err := (services.BackupCodec{KeyFile: keyFile}).Validate(); err != nil {
+				respond.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			respond.Error(w, "Encryption must be 'passphrase' or 'keyfile'", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.BeginTx()
+		if err != nil {
+			respond.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		values := map[string]string{
+			"auto_backup_enabled":            fmt.Sprint(req.Enabled),
+			"auto_backup_frequency":          req.Frequency,
+			"auto_backup_keep_count":         fmt.Sprint(req.KeepCount),
+			"auto_backup_destination":        req.Destination,
+			"auto_backup_destination_config": destConfig,
+			"auto_backup_compress":           fmt.Sprint(req.Compress),
+			"auto_backup_encryption":         req.Encryption,
+			"auto_backup_passphrase":         passphrase,
+			"auto_backup_key_file":           keyFile,
+		}
+		settings := repository.NewSettingsRepositoryTx(tx)
+		for key, value := range values {
+			if err := settings.SetSite(key, value, sql.NullInt64{Int64: userID, Valid: true}); err != nil {
+				respond.Error(w, "Failed to save auto-backup settings", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			respond.Error(w, "Failed to save auto-backup settings", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":  "Auto-backup settings saved",
+			"settings": getAutoBackupSettings(db),
+		})
 	}
 }
 
@@ -499,23 +842,200 @@ func getAutoBackupSettings(db *database.DB) *AutoBackupSettings {
 		KeepCount: 7,
 	}
 
-	var value string
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'auto_backup_enabled'").Scan(&value); err == nil {
-		settings.Enabled = value == "true"
-	}
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'auto_backup_frequency'").Scan(&value); err == nil && value != "" {
+	settings.Enabled = getSettingValue(db, "auto_backup_enabled") == "true"
+	if value := getSettingValue(db, "auto_backup_frequency"); value != "" {
 		settings.Frequency = value
 	}
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'auto_backup_keep_count'").Scan(&value); err == nil {
+	if value := getSettingValue(db, "auto_backup_keep_count"); value != "" {
 		_, _ = fmt.Sscanf(value, "%d", &settings.KeepCount)
 	}
-	if err := db.QueryRow("SELECT value FROM settings WHERE key = 'auto_backup_last_run'").Scan(&value); err == nil {
-		settings.LastRun = value
+	settings.LastRun = getSettingValue(db, "auto_backup_last_run")
+	settings.Destination = getSettingValue(db, "auto_backup_destination")
+	if settings.Destination != "" {
+		settings.DestinationConfig = services.RedactBackupDestinationConfig(getSettingValue(db, "auto_backup_destination_config"))
+	}
+	settings.RemoteLastUpload = getSettingValue(db, "auto_backup_remote_last_upload")
+	settings.RemoteLastError = getSettingValue(db, "auto_backup_remote_last_error")
+	settings.Compress = getSettingValue(db, "auto_backup_compress") == "true"
+	settings.Encryption = getSettingValue(db, "auto_backup_encryption")
+	if getSettingValue(db, "auto_backup_passphrase") != "" {
+		settings.Passphrase = "********"
 	}
+	settings.KeyFile = getSettingValue(db, "auto_backup_key_file")
 
 	return settings
 }
 
+// getSettingValue reads one site setting, or "" if it isn't set
+func getSettingValue(db *database.DB, key string) string {
+	value, _ := repository.NewSettingsRepository(db).GetSite(key)
+	return value
+}
+
+// setSettingValue writes one site setting
+func setSettingValue(db *database.DB, key, value string) {
+	_ = repository.NewSettingsRepository(db).SetSite(key, value, sql.NullInt64{})
+}
+
+// OpenBackupDestination returns the remote destination auto-backups are
+// copied to, or services.ErrNoBackupDestination if there isn't one
+func OpenBackupDestination(db *database.DB) (services.BackupDestination, error) {
+	destType := getSettingValue(db, "auto_backup_destination")
+	if destType == "" {
+		return nil, services.ErrNoBackupDestination
+	}
+	return services.NewBackupDestination(destType, getSettingValue(db, "auto_backup_destination_config"), http.DefaultClient)
+}
+
+// FetchRemoteBackup downloads a backup from the remote destination into the
+// backup directory, checks it can be restored and returns its local path.
+// passphrase is passed to OpenBackup.
+func FetchRemoteBackup(db *database.DB, name, passphrase string) (string, error) {
+	dest, err := OpenBackupDestination(db)
+	if err != nil {
+		return "", err
+	}
+	name = filepath.Base(name)
+	if !services.IsBackupName(name, db.Dialect.BackupExtension()) {
+		return "", fmt.Errorf("%s is not a %s backup", name, db.Dialect.BackupExtension())
+	}
+	backupDir, err := getBackupDir()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(db.Context(), backupDestinationTimeout)
+	defer cancel()
+	localPath := filepath.Join(backupDir, name)
+	if err := services.DownloadBackup(ctx, dest, name, localPath); err != nil {
+		return "", err
+	}
+	plainPath, cleanup, err := OpenBackup(db, localPath, passphrase)
+	if err != nil {
+		os.Remove(localPath)
+		return "", fmt.Errorf("downloaded backup is not usable: %w", err)
+	}
+	defer cleanup()
+	if err := db.ValidateBackup(plainPath); err != nil {
+		os.Remove(localPath)
+		return "", fmt.Errorf("downloaded backup is not usable: %w", err)
+	}
+	return localPath, nil
+}
+
+// uploadAutoBackup copies a new auto-backup to the remote destination, if
+// there is one, then prunes the remote copies to the keep count. The result
+// is recorded for the admin page.
+func uploadAutoBackup(db *database.DB, backup *BackupInfo, keepCount int) {
+	dest, err := OpenBackupDestination(db)
+	if errors.Is(err, services.ErrNoBackupDestination) {
+		return
+	}
+
+	if err == nil {
+		ctx, cancel := context.WithTimeout(db.Context(), backupDestinationTimeout)
+		defer cancel()
+		err = services.UploadBackup(ctx, dest, backup.Path)
+		if err == nil {
+			err = services.PruneRemoteBackups(ctx, dest, "auto_", keepCount)
+		}
+	}
+	if err != nil {
+		slog.Error("Failed to copy backup to remote destination", "backup", backup.Filename, "err", err)
+		setSettingValue(db, "auto_backup_remote_last_error", time.Now().Format("2006-01-02 15:04:05")+": "+err.Error())
+		return
+	}
+	setSettingValue(db, "auto_backup_remote_last_upload", time.Now().Format("2006-01-02 15:04:05"))
+	setSettingValue(db, "auto_backup_remote_last_error", "")
+}
+
+// HandleListRemoteBackups lists the backups at the remote destination
+func HandleListRemoteBackups(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		dest, err := OpenBackupDestination(db)
+		if errors.Is(err, services.ErrNoBackupDestination) {
+			respond.Error(w, "No backup destination configured", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		remote, err := dest.List(r.Context())
+		if err != nil {
+			middleware.Log(r.Context()).Warn("Failed to list remote backups", "err", err)
+			respond.Error(w, "Failed to list remote backups: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		backups := []BackupInfo{}
+		for _, b := range remote {
+			if !services.IsBackupName(b.Name, db.Dialect.BackupExtension()) {
+				continue
+			}
+			backups = append(backups, newBackupInfo(b.Name, "", b.Size, b.ModTime.Local()))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(backups)
+	}
+}
+
+// FetchRemoteBackupRequest names the remote backup to download, with the
+// passphrase it was encrypted with if that isn't the configured one
+type FetchRemoteBackupRequest struct {
+	Filename   string `json:"filename" validate:"required,max=255"`
+	Passphrase string `json:"passphrase,omitempty" validate:"raw"`
+}
+
+// HandleFetchRemoteBackup downloads a remote backup into the local backup
+// list, from where it can be restored
+func HandleFetchRemoteBackup(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req FetchRemoteBackupRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		localPath, err := FetchRemoteBackup(db, req.Filename, req.Passphrase)
+		if errors.Is(err, services.ErrNoBackupDestination) {
+			respond.Error(w, "No backup destination configured", http.StatusNotFound)
+			return
+		}
+		if services.IsBackupKeyError(err) {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			middleware.Log(r.Context()).Warn("Failed to fetch remote backup", "filename", req.Filename, "err", err)
+			respond.Error(w, "Failed to fetch remote backup: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":  "Backup downloaded. Ready to restore.",
+			"filename": filepath.Base(localPath),
+			"success":  true,
+		})
+	}
+}
+
 // PruneOldBackups removes old auto-backups beyond the keep count
 func PruneOldBackups(db *database.DB) error {
 	settings := getAutoBackupSettings(db)
@@ -536,7 +1056,7 @@ func PruneOldBackups(db *database.DB) error {
 	// Collect auto-backups only
 	var autoBackups []os.DirEntry
 	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasPrefix(entry.Name(), "auto_") && strings.HasSuffix(entry.Name(), ".db") {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), "auto_") && services.IsBackupName(entry.Name(), db.Dialect.BackupExtension()) {
 			autoBackups = append(autoBackups, entry)
 		}
 	}
@@ -587,43 +1107,44 @@ func RunAutoBackup(db *database.DB) error {
 	}
 
 	// Create backup
-	_, err := CreateBackup(db, "auto")
+	backup, err := CreateBackup(db, "auto")
 	if err != nil {
 		return err
 	}
 
 	// Update last run time
-	now := time.Now().Format("2006-01-02 15:04:05")
-	_, _ = db.Exec(`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
-		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
-		"auto_backup_last_run", now, now)
+	setSettingValue(db, "auto_backup_last_run", time.Now().Format("2006-01-02 15:04:05"))
 
 	// Prune old backups
 	_ = PruneOldBackups(db)
 
+	// Copy it off the server, if a destination is set
+	uploadAutoBackup(db, backup, settings.KeepCount)
+
 	return nil
 }
 
 // StartAutoBackupScheduler starts the background auto-backup scheduler
 func StartAutoBackupScheduler(db *database.DB) {
-	// Run immediately on startup
-	go func() {
-		time.Sleep(10 * time.Second) // Wait for server to fully start
-		_ = RunAutoBackup(db)
-	}()
-
-	// Then run every hour to check
-	go func() {
+	services.RegisterScheduler("auto_backup", time.Hour)
+	services.RunBackground(func() {
+		// Run soon after startup, once the server is fully up
+		if !services.SleepUnlessShutdown(10 * time.Second) {
+			return
+		}
+		services.RecordSchedulerRun("auto_backup", RunAutoBackup(db))
+
+		// Then run every hour to check
 		ticker := time.NewTicker(1 * time.Hour)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ticker.C:
-				_ = RunAutoBackup(db)
-			case <-shutdownChan:
+				services.RecordSchedulerRun("auto_backup", RunAutoBackup(db))
+			case <-services.ShutdownChan():
 				return
 			}
 		}
-	}()
+	})
 }
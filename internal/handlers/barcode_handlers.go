@@ -0,0 +1,506 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Barcode limits
+const (
+	maxBarcodeCodeLength     = 100
+	maxNDCProductsListed     = 200
+	defaultNDCProductsListed = 50
+)
+
+// Where a scanned code's item type came from
+const (
+	barcodeSourceMapping   = "mapping"
+	barcodeSourceDirectory = "ndc_directory"
+)
+
+// ResolveBarcodeRequest is the payload for looking up a scanned code. It's
+// raw as GS1 codes separate their fields with control characters.
+type ResolveBarcodeRequest struct {
+	Payload string `json:"payload" validate:"raw,max=500"`
+}
+
+// ResolveBarcodeResponse is what a scanned code says, and what it stocks if
+// the account's mappings or the NDC directory know it. Matched is false when
+// the item type still has to be picked by hand.
+type ResolveBarcodeResponse struct {
+	Format         string     `json:"format"` // gs1, gtin, upc, ndc or other
+	Code           string     `json:"code"`   // The key to save a mapping under
+	GTIN           string     `json:"gtin,omitempty"`
+	NDC            string     `json:"ndc,omitempty"` // Formatted 5-3-2
+	LotNumber      string     `json:"lot_number,omitempty"`
+	ExpirationDate *time.Time `json:"expiration_date,omitempty"`
+	IsExpired      bool       `json:"is_expired"`
+	SerialNumber   string     `json:"serial_number,omitempty"`
+	Matched        bool       `json:"matched"`
+	Source         string     `json:"source,omitempty"` // mapping or ndc_directory
+	ItemType       string     `json:"item_type,omitempty"`
+	ItemName       string     `json:"item_name,omitempty"`
+	Unit           string     `json:"unit,omitempty"`
+	ProductName    string     `json:"product_name,omitempty"`
+	MappingID      *int64     `json:"mapping_id,omitempty"`
+}
+
+// BarcodeMappingRequest is the payload for remembering what a code stocks.
+// Code may be the code from a lookup or the scanned payload itself.
+type BarcodeMappingRequest struct {
+	Code     string  `json:"code" validate:"raw,max=500"`
+	ItemType string  `json:"item_type" validate:"max=50"`
+	Label    *string `json:"label,omitempty" validate:"max=100"`
+}
+
+// BarcodeMappingResponse is a saved mapping as returned by the API
+type BarcodeMappingResponse struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"`
+	ItemType  string    `json:"item_type"`
+	Label     *string   `json:"label,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NDCProductRequest is one NDC directory entry. The NDC may have dashes and
+// may be the 11-digit billing form.
+type NDCProductRequest struct {
+	NDC                string  `json:"ndc" validate:"max=20"`
+	ProductName        string  `json:"product_name" validate:"required,max=200"`
+	Labeler            *string `json:"labeler,omitempty" validate:"max=200"`
+	ItemType           *string `json:"item_type,omitempty" validate:"max=50"`
+	PackageDescription *string `json:"package_description,omitempty" validate:"max=500"`
+}
+
+// ImportNDCProductsRequest is the payload for adding to the NDC directory
+type ImportNDCProductsRequest struct {
+	Products []NDCProductRequest `json:"products" validate:"required,max=1000"`
+}
+
+// NDCProductResponse is an NDC directory entry as returned by the API
+type NDCProductResponse struct {
+	NDC                string    `json:"ndc"` // Formatted 5-3-2
+	ProductName        string    `json:"product_name"`
+	Labeler            *string   `json:"labeler,omitempty"`
+	ItemType           *string   `json:"item_type,omitempty"`
+	PackageDescription *string   `json:"package_description,omitempty"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+func toBarcodeMappingResponse(m *models.BarcodeMapping) BarcodeMappingResponse {
+	resp := BarcodeMappingResponse{
+		ID:        m.ID,
+		Code:      m.Code,
+		ItemType:  m.ItemType,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+	if m.Label.Valid {
+		resp.Label = &m.Label.String
+	}
+	return resp
+}
+
+func toNDCProductResponse(p *models.NDCProduct) NDCProductResponse {
+	resp := NDCProductResponse{
+		NDC:         services.FormatNDC(p.NDC),
+		ProductName: p.ProductName,
+		UpdatedAt:   p.UpdatedAt,
+	}
+	if p.Labeler.Valid {
+		resp.Labeler = &p.Labeler.String
+	}
+	if p.ItemType.Valid {
+		resp.ItemType = &p.ItemType.String
+	}
+	if p.PackageDescription.Valid {
+		resp.PackageDescription = &p.PackageDescription.String
+	}
+	return resp
+}
+
+// resolveBarcode fills in what the account stocks from a scanned code. The
+// account's own mapping wins over the NDC directory, and either only counts
+// if the account still tracks the item type.
+func resolveBarcode(db *database.DB, accountID int64, code services.ScannedCode, now time.Time) (ResolveBarcodeResponse, error) {
+	resp := ResolveBarcodeResponse{
+		Format:       code.Format,
+		Code:         code.Key(),
+		GTIN:         code.GTIN,
+		LotNumber:    code.LotNumber,
+		SerialNumber: code.SerialNumber,
+	}
+	if code.NDC != "" {
+		resp.NDC = services.FormatNDC(code.NDC)
+	}
+	if !code.ExpirationDate.IsZero() {
+		resp.ExpirationDate = &code.ExpirationDate
+		resp.IsExpired = code.ExpirationDate.Before(now.UTC().Truncate(24 * time.Hour))
+	}
+
+	match := func(source, itemType string) bool {
+		def := lookupInventoryItemType(db, accountID, itemType)
+		if def == nil {
+			return false
+		}
+		resp.Matched = true
+		resp.Source = source
+		resp.ItemType = def.ItemType
+		resp.ItemName = def.Name
+		resp.Unit = def.Unit
+		return true
+	}
+
+	repo := repository.NewBarcodeRepository(db)
+	mapping, err := repo.GetMapping(accountID, resp.Code)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return resp, err
+	}
+	if mapping != nil {
+		resp.MappingID = &mapping.ID
+		if mapping.Label.Valid {
+			resp.ProductName = mapping.Label.String
+		}
+		match(barcodeSourceMapping, mapping.ItemType)
+	}
+
+	if code.NDC == "" {
+		return resp, nil
+	}
+	product, err := repo.GetNDCProduct(code.NDC)
+	if errors.Is(err, repository.ErrNotFound) {
+		return resp, nil
+	}
+	if err != nil {
+		return resp, err
+	}
+	if resp.ProductName == "" {
+		resp.ProductName = product.ProductName
+	}
+	if !resp.Matched && product.ItemType.Valid {
+		match(barcodeSourceDirectory, product.ItemType.String)
+	}
+	return resp, nil
+}
+
+// HandleResolveBarcode looks up a scanned GS1 DataMatrix, GTIN, UPC or NDC.
+// The lot and expiration come from the code itself; the item type from the
+// account's mappings or the NDC directory.
+func HandleResolveBarcode(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req ResolveBarcodeRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		code, err := services.ParseBarcode(req.Payload)
+		if err != nil {
+			respond.Validation(w, "Invalid barcode: payload is required", respond.Field("payload", "is required"))
+			return
+		}
+
+		resp, err := resolveBarcode(db, accountID, code, time.Now())
+		if err != nil {
+			respond.Error(w, "Failed to look up barcode", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleGetBarcodeMappings lists the codes the account has mapped
+func HandleGetBarcodeMappings(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		mappings, err := repository.NewBarcodeRepository(db).ListMappings(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve barcode mappings", http.StatusInternalServerError)
+			return
+		}
+		response := make([]BarcodeMappingResponse, 0, len(mappings))
+		for _, m := range mappings {
+			response = append(response, toBarcodeMappingResponse(m))
+		}
+		respondJSON(w, http.StatusOK, response)
+	}
+}
+
+// HandleSaveBarcodeMapping remembers which item type a code stocks,
+// replacing what it was mapped to before
+func HandleSaveBarcodeMapping(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req BarcodeMappingRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		code, err := services.ParseBarcode(req.Code)
+		if err != nil {
+			respond.Validation(w, "Invalid barcode mapping: code is required", respond.Field("code", "is required"))
+			return
+		}
+		key := code.Key()
+		if len(key) > maxBarcodeCodeLength {
+			msg := fmt.Sprintf("must be at most %d characters", maxBarcodeCodeLength)
+			respond.Validation(w, "Invalid barcode mapping: code "+msg, respond.Field("code", msg))
+			return
+		}
+		def := lookupInventoryItemType(db, accountID, req.ItemType)
+		if def == nil {
+			respond.Validation(w, "Invalid item type", respond.Field("item_type", "is not tracked by this account"))
+			return
+		}
+		mapping := &models.BarcodeMapping{
+			AccountID: accountID,
+			Code:      key,
+			ItemType:  def.ItemType,
+			CreatedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if req.Label != nil {
+			mapping.Label = optionalText(req.Label)
+		}
+
+		if err := repository.NewBarcodeRepository(db).SaveMapping(mapping); err != nil {
+			respond.Error(w, "Failed to save barcode mapping", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"barcode_mapping",
+			sql.NullInt64{Int64: mapping.ID, Valid: true},
+			map[string]interface{}{"code": mapping.Code, "item_type": mapping.ItemType},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, toBarcodeMappingResponse(mapping))
+	}
+}
+
+// HandleDeleteBarcodeMapping forgets a mapped code
+func HandleDeleteBarcodeMapping(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid barcode mapping ID", http.StatusBadRequest)
+			return
+		}
+		if err := repository.NewBarcodeRepository(db).DeleteMapping(id, accountID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				respond.Error(w, "Barcode mapping not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete barcode mapping", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"barcode_mapping",
+			sql.NullInt64{Int64: id, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseNDC reads a typed NDC, with or without dashes, as its 10 digits
+func parseNDC(s string) (string, bool) {
+	code, err := services.ParseBarcode(s)
+	if err != nil || code.Format != services.BarcodeNDC {
+		return "", false
+	}
+	return code.NDC, true
+}
+
+// HandleListNDCProducts lists the NDC directory. Search with q; limit caps
+// the results (default 50, at most 200).
+func HandleListNDCProducts(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		limit := defaultNDCProductsListed
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxNDCProductsListed {
+				msg := fmt.Sprintf("must be between 1 and %d", maxNDCProductsListed)
+				respond.Validation(w, "Invalid limit: "+msg, respond.Field("limit", msg))
+				return
+			}
+			limit = n
+		}
+
+		// A typed NDC is searched by its digits
+		q := r.URL.Query().Get("q")
+		if ndc, ok := parseNDC(q); ok {
+			q = ndc
+		}
+		products, err := repository.NewBarcodeRepository(db).ListNDCProducts(q, limit)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve NDC products", http.StatusInternalServerError)
+			return
+		}
+		response := make([]NDCProductResponse, 0, len(products))
+		for _, p := range products {
+			response = append(response, toNDCProductResponse(p))
+		}
+		respondJSON(w, http.StatusOK, response)
+	}
+}
+
+// HandleImportNDCProducts adds entries to the NDC directory, replacing those
+// already there. Nothing is saved unless every entry is valid.
+func HandleImportNDCProducts(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req ImportNDCProductsRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		products := make([]*models.NDCProduct, 0, len(req.Products))
+		for i, p := range req.Products {
+			field := fmt.Sprintf("products[%d]", i)
+			ndc, ok := parseNDC(p.NDC)
+			if !ok {
+				respond.Validation(w, "Invalid NDC products: "+field+".ndc must be a 10 or 11 digit NDC", respond.Field(field+".ndc", "must be a 10 or 11 digit NDC"))
+				return
+			}
+			product := &models.NDCProduct{
+				NDC:                ndc,
+				ProductName:        p.ProductName,
+				Labeler:            nullString(p.Labeler),
+				PackageDescription: nullString(p.PackageDescription),
+			}
+			if p.ItemType != nil {
+				product.ItemType = optionalText(p.ItemType)
+			}
+			products = append(products, product)
+		}
+
+		if err := repository.NewBarcodeRepository(db).SaveNDCProducts(products); err != nil {
+			respond.Error(w, "Failed to save NDC products", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"ndc_product",
+			sql.NullInt64{},
+			map[string]interface{}{"count": len(products)},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		response := make([]NDCProductResponse, 0, len(products))
+		for _, p := range products {
+			response = append(response, toNDCProductResponse(p))
+		}
+		respondJSON(w, http.StatusOK, response)
+	}
+}
+
+// HandleDeleteNDCProduct removes the NDC given by the ndc query parameter
+// from the directory
+func HandleDeleteNDCProduct(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		ndc, ok := parseNDC(r.URL.Query().Get("ndc"))
+		if !ok {
+			respond.Validation(w, "Invalid NDC", respond.Field("ndc", "must be a 10 or 11 digit NDC"))
+			return
+		}
+		if err := repository.NewBarcodeRepository(db).DeleteNDCProduct(ndc); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				respond.Error(w, "NDC product not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete NDC product", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"ndc_product",
+			sql.NullInt64{},
+			map[string]interface{}{"ndc": ndc},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+)
+
+// maxBulkItems caps how many records one bulk request changes
+const maxBulkItems = 100
+
+// Bulk item result statuses
+const (
+	BulkApplied = "applied" // Changed
+	BulkFailed  = "failed"  // Rejected; error says why
+	BulkSkipped = "skipped" // Fine on its own, but not changed because another item failed
+)
+
+// BulkItemResult reports what happened to one record of a bulk request
+type BulkItemResult struct {
+	ID         int64              `json:"id"`
+	Status     string             `json:"status"`
+	StatusCode int                `json:"status_code"` // What the single-record endpoint would have answered
+	Error      *respond.ErrorBody `json:"error,omitempty"`
+	TrashID    *int64             `json:"trash_id,omitempty"` // Deletes: the trash item to restore it from
+	Record     interface{}        `json:"record,omitempty"`   // Updates: the record as saved
+}
+
+// BulkResponse lists a result for every record, in request order
+type BulkResponse struct {
+	Results []BulkItemResult `json:"results"`
+}
+
+// BulkFailedResponse is the 422 body when any record of a bulk request
+// fails. Nothing is changed; the results say which records failed.
+type BulkFailedResponse struct {
+	Error   respond.ErrorBody `json:"error"`
+	Results []BulkItemResult  `json:"results"`
+}
+
+// bulkIDs reads the comma separated ids query parameter of a bulk delete
+func bulkIDs(w http.ResponseWriter, r *http.Request) ([]int64, bool) {
+	raw := strings.TrimSpace(r.URL.Query().Get("ids"))
+	if raw == "" {
+		respond.Validation(w, "", respond.Field("ids", "is required"))
+		return nil, false
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxBulkItems {
+		respond.Validation(w, "", respond.Field("ids", fmt.Sprintf("must have at most %d items", maxBulkItems)))
+		return nil, false
+	}
+	ids := make([]int64, 0, len(parts))
+	seen := map[int64]bool{}
+	for _, part := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil || id <= 0 {
+			respond.Validation(w, "", respond.Field("ids", "must be a comma separated list of IDs"))
+			return nil, false
+		}
+		if seen[id] {
+			respond.Validation(w, "", respond.Field("ids", fmt.Sprintf("%d is repeated", id)))
+			return nil, false
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// bulkFailed is the result for a record that can't be changed
+func bulkFailed(id int64, status int, message string) BulkItemResult {
+	return BulkItemResult{
+		ID:         id,
+		Status:     BulkFailed,
+		StatusCode: status,
+		Error:      &respond.ErrorBody{Code: respond.CodeFor(status), Message: message},
+	}
+}
+
+// bulkRecorded is the result for a record whose check was written as a
+// response to rec, e.g. by a helper shared with the single-record endpoint
+func bulkRecorded(id int64, rec *syncRecorder) BulkItemResult {
+	result := BulkItemResult{ID: id, Status: BulkFailed, StatusCode: rec.status}
+	var body respond.ErrorResponse
+	if json.Unmarshal(rec.body.Bytes(), &body) == nil {
+		result.Error = &body.Error
+	}
+	return result
+}
+
+// bulkHasFailures reports whether any record of a bulk request failed
+func bulkHasFailures(results []BulkItemResult) bool {
+	for _, result := range results {
+		if result.Status == BulkFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// respondBulkFailed answers a bulk request that changed nothing because
+// some of its records failed; the others are marked skipped
+func respondBulkFailed(w http.ResponseWriter, results []BulkItemResult) {
+	failed := 0
+	for i := range results {
+		if results[i].Status == BulkFailed {
+			failed++
+			continue
+		}
+		results[i] = BulkItemResult{ID: results[i].ID, Status: BulkSkipped, StatusCode: http.StatusFailedDependency}
+	}
+	respondJSON(w, http.StatusUnprocessableEntity, BulkFailedResponse{
+		Error: respond.ErrorBody{
+			Code:    respond.CodeUnprocessable,
+			Message: fmt.Sprintf("Nothing was changed because %d of the %d items failed", failed, len(results)),
+		},
+		Results: results,
+	})
+}
+
+// respondBulkError answers a bulk request whose transaction failed. Every
+// record is in the transaction, in request order, so an error naming one
+// maps straight to its result; a record deleted since it was checked gets
+// notFound.
+func respondBulkError(w http.ResponseWriter, err error, results []BulkItemResult, notFound, failed string) {
+	var batchErr *repository.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index >= len(results) {
+		respond.Error(w, failed, http.StatusInternalServerError)
+		return
+	}
+	i := batchErr.Index
+	if errors.Is(batchErr.Err, repository.ErrNotFound) {
+		results[i] = bulkFailed(results[i].ID, http.StatusNotFound, notFound)
+	} else {
+		results[i] = bulkFailed(results[i].ID, http.StatusInternalServerError, failed)
+	}
+	respondBulkFailed(w, results)
+}
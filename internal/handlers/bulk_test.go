@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBulkIDs(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+		ok    bool
+	}{
+		{"ids=1,2,%203", 3, true},
+		{"", 0, false},
+		{"ids=1,x", 0, false},
+		{"ids=1,0", 0, false},
+		{"ids=4,4", 0, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodDelete, "/api/symptoms?"+tt.query, nil)
+		rec := httptest.NewRecorder()
+		ids, ok := bulkIDs(rec, req)
+		if ok != tt.ok || len(ids) != tt.want {
+			t.Errorf("%q: expected %d IDs and %v, got %v and %v", tt.query, tt.want, tt.ok, ids, ok)
+		}
+		if !ok && rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected a 400, got %d", tt.query, rec.Code)
+		}
+	}
+}
+
+func TestRespondBulkFailed(t *testing.T) {
+	results := []BulkItemResult{{ID: 1}, bulkFailed(2, http.StatusNotFound, "Symptom log not found"), {ID: 3}}
+	rec := httptest.NewRecorder()
+	respondBulkFailed(rec, results)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected a 422, got %d", rec.Code)
+	}
+
+	var body BulkFailedResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Error.Message != "Nothing was changed because 1 of the 3 items failed" {
+		t.Errorf("Unexpected message %q", body.Error.Message)
+	}
+	want := []string{BulkSkipped, BulkFailed, BulkSkipped}
+	for i, result := range body.Results {
+		if result.Status != want[i] {
+			t.Errorf("Expected item %d %s, got %s", result.ID, want[i], result.Status)
+		}
+	}
+	if body.Results[1].StatusCode != http.StatusNotFound || body.Results[1].Error == nil {
+		t.Errorf("Expected the failed item's error, got %+v", body.Results[1])
+	}
+}
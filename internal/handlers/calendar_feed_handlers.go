@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+const (
+	// calendarFeedDays is how far ahead injections are listed in a feed
+	calendarFeedDays = 60
+	// calendarFeedMaxInjections caps injections in a feed, for short
+	// frequencies
+	calendarFeedMaxInjections = 200
+	// calendarFeedPastDays keeps recent appointments in a feed
+	calendarFeedPastDays = 30
+)
+
+// CalendarTokenResponse is a calendar feed token as listed by the API
+type CalendarTokenResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreateCalendarTokenRequest names a new calendar feed token
+type CreateCalendarTokenRequest struct {
+	Name string `json:"name" validate:"max=100"`
+}
+
+// CreateCalendarTokenResponse carries the feed URL, which can't be shown
+// again
+type CreateCalendarTokenResponse struct {
+	CalendarTokenResponse
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+func calendarTokenToResponse(t *models.CalendarToken) CalendarTokenResponse {
+	resp := CalendarTokenResponse{ID: t.ID, Name: t.Name, CreatedAt: t.CreatedAt}
+	if t.LastUsedAt.Valid {
+		resp.LastUsedAt = &t.LastUsedAt.Time
+	}
+	return resp
+}
+
+// siteBaseURL is the configured site URL, or failing that the scheme and
+// host the request came in on
+func siteBaseURL(r *http.Request, site *SiteSettings) string {
+	if site.SiteURL != "" {
+		return strings.TrimRight(site.SiteURL, "/")
+	}
+	scheme := "http"
+	if middleware.IsHTTPS(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// HandleGetCalendarTokens lists the current user's calendar feed tokens
+func HandleGetCalendarTokens(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		tokens, err := repository.NewCalendarTokenRepository(db).List(accountID, userID)
+		if err != nil {
+			respond.Error(w, "Failed to get calendar tokens", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]CalendarTokenResponse, 0, len(tokens))
+		for _, t := range tokens {
+			resp = append(resp, calendarTokenToResponse(t))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleCreateCalendarToken creates a calendar feed token for the current
+// user and returns its subscription URL
+func HandleCreateCalendarToken(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req CreateCalendarTokenRequest
+		if r.ContentLength != 0 {
+			if !decodeRequest(w, r, &req) {
+				return
+			}
+		}
+		if req.Name == "" {
+			req.Name = "Calendar"
+		}
+
+		calendarToken, token, err := repository.NewCalendarTokenRepository(db).Create(accountID, userID, req.Name)
+		if err != nil {
+			respond.Error(w, "Failed to create calendar token", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"calendar_token",
+			sql.NullInt64{Int64: calendarToken.ID, Valid: true},
+			map[string]interface{}{"name": calendarToken.Name},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusCreated, CreateCalendarTokenResponse{
+			CalendarTokenResponse: calendarTokenToResponse(calendarToken),
+			Token:                 token,
+			URL:                   siteBaseURL(r, getSiteSettings(db)) + "/calendar.ics?token=" + url.QueryEscape(token),
+		})
+	}
+}
+
+// HandleRevokeCalendarToken revokes one of the current user's calendar feed
+// tokens; its feed stops working straight away
+func HandleRevokeCalendarToken(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid calendar token ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewCalendarTokenRepository(db).Revoke(id, accountID, userID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Calendar token not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to revoke calendar token", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"revoke",
+			"calendar_token",
+			sql.NullInt64{Int64: id, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleCalendarFeed serves the iCalendar feed for a calendar token:
+// upcoming injections for the active course, scheduled medication times and
+// appointments. It needs no login, so calendar apps can subscribe to it;
+// the token in the URL is the only credential.
+func HandleCalendarFeed(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			respond.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		tokenRepo := repository.NewCalendarTokenRepository(db)
+		calendarToken, err := tokenRepo.GetByToken(token)
+		if err != nil {
+			if err != repository.ErrNotFound {
+				middleware.Log(r.Context()).Error("Failed to look up calendar token", "err", err)
+			}
+			respond.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		// The feed stops if its owner is deactivated or leaves the account,
+		// or while the account is locked
+		var isActive bool
+		var suspension sql.NullString
+		err = db.QueryRow(`
+			SELECT u.is_active, a.suspension
+			FROM users u
+			JOIN account_members m ON m.user_id = u.id AND m.account_id = ?
+			JOIN accounts a ON a.id = m.account_id
+			WHERE u.id = ?
+		`, calendarToken.AccountID, calendarToken.UserID).Scan(&isActive, &suspension)
+		if err != nil || !isActive || suspension.String == models.AccountLocked {
+			respond.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		now := time.Now()
+		events, err := calendarFeedEvents(db, calendarToken.AccountID, userLocation(db, calendarToken.UserID), now)
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to build calendar feed", "err", err)
+			respond.Error(w, "Failed to build calendar feed", http.StatusInternalServerError)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := services.WriteICalendar(&buf, getSiteSettings(db).SiteTitle, events, now); err != nil {
+			respond.Error(w, "Failed to build calendar feed", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tokenRepo.MarkUsed(calendarToken.ID, now); err != nil {
+			middleware.Log(r.Context()).Warn("Failed to record calendar token use", "err", err)
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="p-track.ics"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// injectionPlan is the injections due on an account's active courses
+type injectionPlan struct {
+	Doses []plannedDose // In the order they fall due
+	Trip  *models.Trip  // The trip the schedules are following, if any
+}
+
+// plannedDose is an injection due on one of the active courses
+type plannedDose struct {
+	services.ScheduledDose
+	Course   *models.Course
+	Schedule services.InjectionSchedule
+}
+
+// planInjections lists the injections due on the account's active courses
+// in [from, until), at most max in the order they fall due, each on its
+// course's schedule with the dose a taper schedule sets for its day in loc.
+// While a trip moves the schedules they follow the trip's clock instead of
+// loc. It returns nil when no course is active.
+func planInjections(db *database.DB, accountID int64, loc *time.Location, from, until time.Time, max int) (*injectionPlan, error) {
+	courses, err := repository.NewCourseRepository(db).ListActive(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if len(courses) == 0 {
+		return nil, nil
+	}
+	travelLoc, trip, err := services.LoadTravelLocation(db, accountID, loc, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &injectionPlan{Trip: trip}
+	for _, course := range courses {
+		doses, err := planCourseInjections(db, accountID, course, loc, travelLoc, from, until, max)
+		if err != nil {
+			return nil, err
+		}
+		plan.Doses = append(plan.Doses, doses...)
+	}
+	sort.SliceStable(plan.Doses, func(i, j int) bool {
+		return plan.Doses[i].Due.Before(plan.Doses[j].Due)
+	})
+	if len(plan.Doses) > max {
+		plan.Doses = plan.Doses[:max]
+	}
+	return plan, nil
+}
+
+// planCourseInjections lists the injections due on one active course for
+// planInjections, following its schedule in travelLoc
+func planCourseInjections(db *database.DB, accountID int64, course *models.Course, loc, travelLoc *time.Location, from, until time.Time, max int) ([]plannedDose, error) {
+	schedule, err := services.LoadInjectionSchedule(db, accountID, course, travelLoc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing is due after the course's expected last day
+	if course.ExpectedEndDate.Valid {
+		end := course.ExpectedEndDate.Time
+		if courseEnd := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1); courseEnd.Before(until) {
+			until = courseEnd
+		}
+	}
+
+	med, err := repository.GetCourseMedication(db, course.ID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := repository.NewDoseScheduleRepository(db).List(course.ID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	var doses []plannedDose
+	for _, due := range schedule.DueTimes(from, until, max) {
+		dose := med.DoseML
+		if scheduled, ok := repository.ScheduledDoseML(steps, due.In(loc)); ok {
+			dose = scheduled
+		}
+		doses = append(doses, plannedDose{
+			ScheduledDose: services.ScheduledDose{Due: due, DoseML: dose, ItemType: med.ItemType},
+			Course:        course,
+			Schedule:      schedule,
+		})
+	}
+	return doses, nil
+}
+
+// calendarFeedEvents gathers an account's upcoming events, with times of day
+// taken in loc
+func calendarFeedEvents(db *database.DB, accountID int64, loc *time.Location, now time.Time) ([]services.CalendarEvent, error) {
+	var events []services.CalendarEvent
+
+	// Injections due on the active courses
+	local := now.In(loc)
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	plan, err := planInjections(db, accountID, loc, from, from.AddDate(0, 0, calendarFeedDays), calendarFeedMaxInjections)
+	if err != nil {
+		return nil, err
+	}
+	if plan != nil {
+		for _, dose := range plan.Doses {
+			events = append(events, services.CalendarEvent{
+				UID:         fmt.Sprintf("injection-%d-%s@p-track", dose.Course.ID, dose.Due.UTC().Format("20060102T1504Z")),
+				Summary:     "Injection due",
+				Description: fmt.Sprintf("%s mL, %s. Course: %s", formatDose(dose.DoseML), dose.Schedule.Describe(), dose.Course.Name),
+				Start:       dose.Due,
+				End:         dose.Due.Add(30 * time.Minute),
+			})
+		}
+	}
+
+	// Medications with dose times, one recurring event per time of day
+	medications, err := repository.NewMedicationRepository(db).ListActive(accountID)
+	if err != nil {
+		return nil, err
+	}
+	for _, med := range medications {
+		// Doses every N hours follow on from the first time alone
+		_, everyHours := services.ParseMedicationFrequency(med.Frequency.String)
+		for i, doseTime := range med.DoseTimes {
+			if !isValidTimeFormat(doseTime) || (i > 0 && everyHours > 0) {
+				continue
+			}
+			uid := fmt.Sprintf("medication-%d@p-track", med.ID)
+			if i > 0 {
+				uid = fmt.Sprintf("medication-%d-%s@p-track", med.ID, strings.ReplaceAll(doseTime, ":", ""))
+			}
+			if event, ok := medicationCalendarEvent(med, doseTime, uid, loc, now); ok {
+				events = append(events, event)
+			}
+		}
+	}
+
+	// Appointments
+	appointments, err := repository.NewAppointmentRepository(db).List(accountID, now.AddDate(0, 0, -calendarFeedPastDays), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range appointments {
+		end := a.StartsAt.Add(time.Hour)
+		if a.EndsAt.Valid {
+			end = a.EndsAt.Time
+		}
+		events = append(events, services.CalendarEvent{
+			UID:         fmt.Sprintf("appointment-%d@p-track", a.ID),
+			Summary:     a.Title,
+			Description: a.Notes.String,
+			Location:    a.Location.String,
+			Start:       a.StartsAt,
+			End:         end,
+		})
+	}
+
+	return events, nil
+}
+
+// medicationCalendarEvent is the recurring event for one of a medication's
+// dose times, or false if the medication has ended
+func medicationCalendarEvent(med *models.Medication, doseTime, uid string, loc *time.Location, now time.Time) (services.CalendarEvent, bool) {
+	startDay := med.CreatedAt
+	if med.StartDate.Valid {
+		startDay = med.StartDate.Time
+	}
+	start := services.AtClock(time.Date(startDay.Year(), startDay.Month(), startDay.Day(), 12, 0, 0, 0, loc), doseTime, loc)
+
+	rrule := services.MedicationRRule(med.Frequency.String)
+	if med.EndDate.Valid {
+		end := med.EndDate.Time
+		until := services.AtClock(time.Date(end.Year(), end.Month(), end.Day(), 12, 0, 0, 0, loc), "23:59", loc)
+		if until.Before(now) {
+			return services.CalendarEvent{}, false
+		}
+		rrule += ";UNTIL=" + until.UTC().Format("20060102T150405Z")
+	}
+
+	var details []string
+	if med.Dosage.Valid && med.Dosage.String != "" {
+		details = append(details, "Dosage: "+med.Dosage.String)
+	}
+	if med.Frequency.Valid && med.Frequency.String != "" {
+		details = append(details, "Frequency: "+med.Frequency.String)
+	}
+	if med.TimeWindowMinutes.Valid && med.TimeWindowMinutes.Int64 > 0 {
+		details = append(details, fmt.Sprintf("Take within %d minutes of the scheduled time", med.TimeWindowMinutes.Int64))
+	}
+	return services.CalendarEvent{
+		UID:         uid,
+		Summary:     "Take " + med.Name,
+		Description: strings.Join(details, "\n"),
+		Start:       start,
+		End:         start.Add(15 * time.Minute),
+		RRule:       rrule,
+		TZ:          loc,
+	}, true
+}
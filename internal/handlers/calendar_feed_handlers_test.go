@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+)
+
+func TestPlanInjectionsMergesActiveCourses(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	// Progesterone daily at 19:00, estradiol every other day at 08:00
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner');
+		INSERT INTO courses (id, account_id, name, start_date, is_active, dose_ml) VALUES (1, 1, 'Progesterone', '2026-03-01', 1, 1), (2, 1, 'Estradiol', '2026-03-05', 1, 0.25);
+		INSERT INTO course_schedules (course_id, interval_hours, time_of_day) VALUES (1, 24, '19:00'), (2, 48, '08:00');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+	for _, injection := range []struct {
+		courseID int64
+		at       time.Time
+	}{
+		{1, time.Date(2026, 3, 13, 19, 5, 0, 0, time.UTC)},
+		{2, time.Date(2026, 3, 12, 8, 10, 0, 0, time.UTC)},
+	} {
+		if _, err := db.Exec(`INSERT INTO injections (course_id, timestamp, side) VALUES (?, ?, 'left')`, injection.courseID, injection.at); err != nil {
+			t.Fatalf("Failed to seed injection: %v", err)
+		}
+	}
+
+	from := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	plan, err := planInjections(db, 1, time.UTC, from, from.AddDate(0, 0, 3), 10)
+	if err != nil || plan == nil {
+		t.Fatalf("planInjections failed: %v, %v", plan, err)
+	}
+	want := []struct {
+		courseID int64
+		due      time.Time
+		doseML   float64
+	}{
+		{2, time.Date(2026, 3, 14, 8, 0, 0, 0, time.UTC), 0.25},
+		{1, time.Date(2026, 3, 14, 19, 0, 0, 0, time.UTC), 1},
+		{1, time.Date(2026, 3, 15, 19, 0, 0, 0, time.UTC), 1},
+		{2, time.Date(2026, 3, 16, 8, 0, 0, 0, time.UTC), 0.25},
+		{1, time.Date(2026, 3, 16, 19, 0, 0, 0, time.UTC), 1},
+	}
+	if len(plan.Doses) != len(want) {
+		t.Fatalf("Expected %d doses across both courses, got %d", len(want), len(plan.Doses))
+	}
+	for i, w := range want {
+		d := plan.Doses[i]
+		if d.Course.ID != w.courseID || !d.Due.Equal(w.due) || d.DoseML != w.doseML {
+			t.Errorf("Dose %d = course %d at %v (%v mL), want course %d at %v (%v mL)", i, d.Course.ID, d.Due, d.DoseML, w.courseID, w.due, w.doseML)
+		}
+	}
+
+	// max applies after the courses are merged
+	plan, err = planInjections(db, 1, time.UTC, from, from.AddDate(0, 0, 3), 2)
+	if err != nil || len(plan.Doses) != 2 || plan.Doses[0].Course.ID != 2 || plan.Doses[1].Course.ID != 1 {
+		t.Errorf("Expected the first estradiol then the first progesterone dose, got %+v, %v", plan, err)
+	}
+}
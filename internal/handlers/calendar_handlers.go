@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+)
+
+// CalendarMonthResponse is a month of activity, one entry per day, with days
+// taken in Timezone
+type CalendarMonthResponse struct {
+	Month    string               `json:"month"` // YYYY-MM
+	Timezone string               `json:"timezone"`
+	Days     []CalendarDay        `json:"days"`
+	Summary  CalendarMonthSummary `json:"summary"`
+}
+
+// CalendarDay is what happened on one day
+type CalendarDay struct {
+	Date         string                 `json:"date"` // YYYY-MM-DD
+	Injections   []CalendarInjection    `json:"injections"`
+	Medications  CalendarMedications    `json:"medications"`
+	Symptoms     CalendarSymptoms       `json:"symptoms"`
+	Appointments []CalendarAppointment  `json:"appointments"`
+	Due          []CalendarDueInjection `json:"due"` // From today on, on the active courses' schedules
+}
+
+// CalendarDueInjection is an injection due on the day
+type CalendarDueInjection struct {
+	Due        time.Time `json:"due"`
+	DoseML     float64   `json:"dose_ml"`
+	CourseID   int64     `json:"course_id"`
+	CourseName string    `json:"course_name"`
+}
+
+// CalendarInjection is an injection given on the day
+type CalendarInjection struct {
+	ID        int64     `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Side      string    `json:"side"`
+	PainLevel *int      `json:"pain_level,omitempty"`
+}
+
+// CalendarMedications counts the day's medication doses logged as taken and
+// as missed
+type CalendarMedications struct {
+	Taken  int                      `json:"taken"`
+	Missed int                      `json:"missed"`
+	Doses  []CalendarMedicationDose `json:"doses"`
+}
+
+// CalendarMedicationDose is one logged dose
+type CalendarMedicationDose struct {
+	ID         int64     `json:"id"`
+	Medication string    `json:"medication"`
+	Timestamp  time.Time `json:"timestamp"`
+	Taken      bool      `json:"taken"`
+}
+
+// CalendarSymptoms summarises the day's symptom logs
+type CalendarSymptoms struct {
+	Count   int     `json:"count"`
+	MaxPain *int    `json:"max_pain,omitempty"`
+	IDs     []int64 `json:"ids"`
+}
+
+// CalendarAppointment is an appointment starting on the day
+type CalendarAppointment struct {
+	ID       int64      `json:"id"`
+	Title    string     `json:"title"`
+	StartsAt time.Time  `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	Location string     `json:"location,omitempty"`
+}
+
+// CalendarMonthSummary totals the month
+type CalendarMonthSummary struct {
+	Injections        int      `json:"injections"`
+	Left              int      `json:"left"`
+	Right             int      `json:"right"`
+	SymptomsLogged    int      `json:"symptoms_logged"`
+	AveragePain       *float64 `json:"average_pain,omitempty"` // Over injections and symptom logs with a pain level
+	MedicationsTaken  int      `json:"medications_taken"`
+	MedicationsMissed int      `json:"medications_missed"`
+	Appointments      int      `json:"appointments"`
+}
+
+// HandleGetCalendar returns per-day activity for a month (month=YYYY-MM,
+// default the current month). Days run midnight to midnight in the user's
+// timezone.
+func HandleGetCalendar(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		loc := userLocation(db, userID)
+		now := time.Now().In(loc)
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		if v := r.URL.Query().Get("month"); v != "" {
+			month, err := time.ParseInLocation("2006-01", v, loc)
+			if err != nil {
+				respond.Validation(w, "Invalid month format (use YYYY-MM)", respond.Field("month", "invalid format (use YYYY-MM)"))
+				return
+			}
+			start = month
+		}
+		end := start.AddDate(0, 1, 0)
+
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
+			return
+		}
+
+		// The range is inclusive, so stop just short of the next month
+		data, err := gatherExportData(db, accountID, viewer, start.UTC(), end.UTC().Add(-time.Nanosecond), "")
+		if err != nil {
+			respond.Error(w, "Failed to get calendar data", http.StatusInternalServerError)
+			return
+		}
+		appointments, err := repository.NewAppointmentRepository(db).List(accountID, start, end)
+		if err != nil {
+			respond.Error(w, "Failed to get appointments", http.StatusInternalServerError)
+			return
+		}
+
+		month := buildCalendarMonth(data, appointments, start, loc)
+		if err := addDueInjections(db, accountID, month, start, end, loc); err != nil {
+			respond.Error(w, "Failed to plan injections", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, month)
+	}
+}
+
+// calendarMaxDue caps the due injections shown in a month, enough for one
+// every hour
+const calendarMaxDue = 31 * 24
+
+// addDueInjections puts the injections due on the active courses from the
+// start of today to the end of the month, start to end in loc, on their
+// days
+func addDueInjections(db *database.DB, accountID int64, month *CalendarMonthResponse, start, end time.Time, loc *time.Location) error {
+	local := time.Now().In(loc)
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	if from.Before(start) {
+		from = start
+	}
+	if !from.Before(end) {
+		return nil
+	}
+	plan, err := planInjections(db, accountID, loc, from, end, calendarMaxDue)
+	if err != nil || plan == nil {
+		return err
+	}
+	for _, dose := range plan.Doses {
+		// Days run from the 1st, one per day of the month
+		if i := dose.Due.In(loc).Day() - 1; i < len(month.Days) {
+			month.Days[i].Due = append(month.Days[i].Due, CalendarDueInjection{
+				Due:        dose.Due,
+				DoseML:     dose.DoseML,
+				CourseID:   dose.Course.ID,
+				CourseName: dose.Course.Name,
+			})
+		}
+	}
+	return nil
+}
+
+// buildCalendarMonth sorts a month's records into days. start is midnight
+// on the first of the month in loc.
+func buildCalendarMonth(data *ExportData, appointments []*models.Appointment, start time.Time, loc *time.Location) *CalendarMonthResponse {
+	resp := &CalendarMonthResponse{
+		Month:    start.Format("2006-01"),
+		Timezone: loc.String(),
+	}
+
+	end := start.AddDate(0, 1, 0)
+	index := make(map[string]int)
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		index[date] = len(resp.Days)
+		resp.Days = append(resp.Days, CalendarDay{
+			Date:         date,
+			Injections:   []CalendarInjection{},
+			Medications:  CalendarMedications{Doses: []CalendarMedicationDose{}},
+			Symptoms:     CalendarSymptoms{IDs: []int64{}},
+			Appointments: []CalendarAppointment{},
+			Due:          []CalendarDueInjection{},
+		})
+	}
+	dayOf := func(t time.Time) *CalendarDay {
+		i, ok := index[t.In(loc).Format("2006-01-02")]
+		if !ok {
+			return nil
+		}
+		return &resp.Days[i]
+	}
+
+	var painTotal, painCount int
+	summary := &resp.Summary
+
+	// Export data is newest first; list each day's records in time order
+	for i := len(data.Injections) - 1; i >= 0; i-- {
+		inj := data.Injections[i]
+		day := dayOf(inj.Timestamp)
+		if day == nil {
+			continue
+		}
+		entry := CalendarInjection{ID: inj.ID, Timestamp: inj.Timestamp, Side: inj.Side}
+		if inj.HasPain {
+			pain := inj.PainLevel
+			entry.PainLevel = &pain
+			painTotal += pain
+			painCount++
+		}
+		day.Injections = append(day.Injections, entry)
+
+		summary.Injections++
+		switch inj.Side {
+		case "left":
+			summary.Left++
+		case "right":
+			summary.Right++
+		}
+	}
+
+	for i := len(data.Medications) - 1; i >= 0; i-- {
+		med := data.Medications[i]
+		day := dayOf(med.Timestamp)
+		if day == nil {
+			continue
+		}
+		day.Medications.Doses = append(day.Medications.Doses, CalendarMedicationDose{
+			ID:         med.ID,
+			Medication: med.MedicationName,
+			Timestamp:  med.Timestamp,
+			Taken:      med.Taken,
+		})
+		if med.Taken {
+			day.Medications.Taken++
+			summary.MedicationsTaken++
+		} else {
+			day.Medications.Missed++
+			summary.MedicationsMissed++
+		}
+	}
+
+	for i := len(data.Symptoms) - 1; i >= 0; i-- {
+		sym := data.Symptoms[i]
+		day := dayOf(sym.Timestamp)
+		if day == nil {
+			continue
+		}
+		day.Symptoms.Count++
+		day.Symptoms.IDs = append(day.Symptoms.IDs, sym.ID)
+		if sym.HasPain {
+			if day.Symptoms.MaxPain == nil || sym.PainLevel > *day.Symptoms.MaxPain {
+				pain := sym.PainLevel
+				day.Symptoms.MaxPain = &pain
+			}
+			painTotal += sym.PainLevel
+			painCount++
+		}
+		summary.SymptomsLogged++
+	}
+
+	for _, a := range appointments {
+		day := dayOf(a.StartsAt)
+		if day == nil {
+			continue
+		}
+		entry := CalendarAppointment{ID: a.ID, Title: a.Title, StartsAt: a.StartsAt, Location: a.Location.String}
+		if a.EndsAt.Valid {
+			entry.EndsAt = &a.EndsAt.Time
+		}
+		day.Appointments = append(day.Appointments, entry)
+		summary.Appointments++
+	}
+
+	if painCount > 0 {
+		avg := float64(painTotal) / float64(painCount)
+		summary.AveragePain = &avg
+	}
+	return resp
+}
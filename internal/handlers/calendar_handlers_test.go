@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestBuildCalendarMonth(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("No timezone data: %v", err)
+	}
+	start := time.Date(2025, 2, 1, 0, 0, 0, 0, loc)
+
+	// Newest first, as gatherExportData returns them
+	data := &ExportData{
+		Injections: []ExportInjection{
+			{ID: 3, Timestamp: time.Date(2025, 2, 10, 20, 0, 0, 0, loc), Side: "right"},
+			{ID: 2, Timestamp: time.Date(2025, 2, 10, 8, 0, 0, 0, loc), Side: "left", PainLevel: 4, HasPain: true},
+			// 01:00 UTC on the 1st is still January 31st in New York
+			{ID: 1, Timestamp: time.Date(2025, 2, 1, 1, 0, 0, 0, time.UTC), Side: "left"},
+		},
+		Symptoms: []ExportSymptom{
+			{ID: 5, Timestamp: time.Date(2025, 2, 10, 21, 0, 0, 0, loc), PainLevel: 6, HasPain: true},
+			{ID: 4, Timestamp: time.Date(2025, 2, 10, 9, 0, 0, 0, loc)},
+		},
+		Medications: []ExportMedication{
+			{ID: 8, Timestamp: time.Date(2025, 2, 28, 23, 30, 0, 0, loc), MedicationName: "Progesterone", Taken: false},
+			{ID: 7, Timestamp: time.Date(2025, 2, 28, 9, 0, 0, 0, loc), MedicationName: "Estradiol", Taken: true},
+		},
+	}
+	appointments := []*models.Appointment{
+		{ID: 9, Title: "Clinic", StartsAt: time.Date(2025, 2, 14, 15, 0, 0, 0, time.UTC), Location: sql.NullString{String: "Room 4", Valid: true}},
+	}
+
+	month := buildCalendarMonth(data, appointments, start, loc)
+
+	if month.Month != "2025-02" || month.Timezone != "America/New_York" {
+		t.Errorf("Unexpected month %q in %q", month.Month, month.Timezone)
+	}
+	if len(month.Days) != 28 {
+		t.Fatalf("Expected 28 days, got %d", len(month.Days))
+	}
+	if first := month.Days[0]; first.Date != "2025-02-01" || len(first.Injections) != 0 {
+		t.Errorf("Expected an empty February 1st, got %+v", first)
+	}
+
+	day := month.Days[9]
+	if day.Date != "2025-02-10" {
+		t.Fatalf("Expected day 10 to be 2025-02-10, got %s", day.Date)
+	}
+	if len(day.Injections) != 2 || day.Injections[0].ID != 2 || day.Injections[1].Side != "right" {
+		t.Errorf("Expected the day's injections in time order, got %+v", day.Injections)
+	}
+	if day.Injections[0].PainLevel == nil || *day.Injections[0].PainLevel != 4 || day.Injections[1].PainLevel != nil {
+		t.Errorf("Unexpected injection pain levels: %+v", day.Injections)
+	}
+	if day.Symptoms.Count != 2 || day.Symptoms.MaxPain == nil || *day.Symptoms.MaxPain != 6 {
+		t.Errorf("Unexpected symptoms: %+v", day.Symptoms)
+	}
+
+	last := month.Days[27]
+	if last.Medications.Taken != 1 || last.Medications.Missed != 1 || last.Medications.Doses[0].Medication != "Estradiol" {
+		t.Errorf("Unexpected medications: %+v", last.Medications)
+	}
+	if appts := month.Days[13].Appointments; len(appts) != 1 || appts[0].Location != "Room 4" {
+		t.Errorf("Expected the appointment on the 14th, got %+v", appts)
+	}
+
+	s := month.Summary
+	if s.Injections != 2 || s.Left != 1 || s.Right != 1 {
+		t.Errorf("Unexpected injection totals: %+v", s)
+	}
+	if s.SymptomsLogged != 2 || s.MedicationsTaken != 1 || s.MedicationsMissed != 1 || s.Appointments != 1 {
+		t.Errorf("Unexpected totals: %+v", s)
+	}
+	if s.AveragePain == nil || *s.AveragePain != 5 {
+		t.Errorf("Expected average pain 5, got %v", s.AveragePain)
+	}
+}
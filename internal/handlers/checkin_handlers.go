@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CheckInRequest is the payload for recording or replacing a daily wellness
+// check-in. Readings left out are not recorded; on update they are cleared.
+type CheckInRequest struct {
+	Date        string   `json:"date,omitempty"` // YYYY-MM-DD, default today; only used on create
+	Mood        *int     `json:"mood,omitempty" validate:"min=1,max=5"`
+	Energy      *int     `json:"energy,omitempty" validate:"min=1,max=5"`
+	SleepHours  *float64 `json:"sleep_hours,omitempty" validate:"min=0,max=24"`
+	Temperature *float64 `json:"temperature,omitempty" validate:"min=30,max=45"` // degC
+	Weight      *float64 `json:"weight,omitempty"`                               // kg
+	Notes       *string  `json:"notes,omitempty" validate:"multiline,max=2000"`
+}
+
+// CheckInResponse is a check-in as returned by the API
+type CheckInResponse struct {
+	ID          int64     `json:"id"`
+	UserID      *int64    `json:"user_id,omitempty"`
+	Date        string    `json:"date"`
+	Mood        *int64    `json:"mood,omitempty"`
+	Energy      *int64    `json:"energy,omitempty"`
+	SleepHours  *float64  `json:"sleep_hours,omitempty"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Weight      *float64  `json:"weight,omitempty"`
+	Notes       string    `json:"notes,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CheckInTrendsResponse has one entry per day with a check-in, averaging
+// the readings of every member included. Days with no reading of a kind
+// are null in that series.
+type CheckInTrendsResponse struct {
+	Dates       []string   `json:"dates"`
+	Mood        []*float64 `json:"mood"`
+	Energy      []*float64 `json:"energy"`
+	SleepHours  []*float64 `json:"sleep_hours"`
+	Temperature []*float64 `json:"temperature"`
+	Weight      []*float64 `json:"weight"`
+}
+
+func nullFloat64ToPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+func toCheckInResponse(c *models.WellnessCheckIn) CheckInResponse {
+	return CheckInResponse{
+		ID:          c.ID,
+		UserID:      nullInt64ToInt(c.UserID),
+		Date:        c.Date.UTC().Format("2006-01-02"),
+		Mood:        nullInt64ToInt(c.Mood),
+		Energy:      nullInt64ToInt(c.Energy),
+		SleepHours:  nullFloat64ToPtr(c.SleepHours),
+		Temperature: nullFloat64ToPtr(c.Temperature),
+		Weight:      nullFloat64ToPtr(c.Weight),
+		Notes:       c.Notes.String,
+		CreatedAt:   c.CreatedAt,
+		UpdatedAt:   c.UpdatedAt,
+	}
+}
+
+// applyCheckInRequest validates req and sets c's readings from it
+func applyCheckInRequest(c *models.WellnessCheckIn, req *CheckInRequest) *respond.FieldError {
+	if req.Weight != nil && (*req.Weight <= 0 || *req.Weight > 500) {
+		fe := respond.Field("weight", "must be in kilograms, between 0 and 500")
+		return &fe
+	}
+
+	notes := optionalText(req.Notes)
+	if req.Mood == nil && req.Energy == nil && req.SleepHours == nil && req.Temperature == nil && req.Weight == nil && !notes.Valid {
+		fe := respond.Field("mood", "record at least one reading or a note")
+		return &fe
+	}
+
+	c.Mood = nullInt(req.Mood)
+	c.Energy = nullInt(req.Energy)
+	c.SleepHours = nullFloat64(req.SleepHours)
+	c.Temperature = nullFloat64(req.Temperature)
+	c.Weight = nullFloat64(req.Weight)
+	c.Notes = notes
+	return nil
+}
+
+// HandleGetCheckIns lists the account's check-ins, newest first, optionally
+// for one member (user_id) and between start_date and end_date inclusive
+func HandleGetCheckIns(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		var start, end time.Time
+		var err error
+		if v := query.Get("start_date"); v != "" {
+			if start, err = time.Parse("2006-01-02", v); err != nil {
+				respond.Validation(w, "Invalid start_date format (use YYYY-MM-DD)", respond.Field("start_date", "invalid format (use YYYY-MM-DD)"))
+				return
+			}
+		}
+		if v := query.Get("end_date"); v != "" {
+			if end, err = time.Parse("2006-01-02", v); err != nil {
+				respond.Validation(w, "Invalid end_date format (use YYYY-MM-DD)", respond.Field("end_date", "invalid format (use YYYY-MM-DD)"))
+				return
+			}
+		}
+		var memberID int64
+		if v := query.Get("user_id"); v != "" {
+			if memberID, err = strconv.ParseInt(v, 10, 64); err != nil {
+				respond.Validation(w, "Invalid user_id", respond.Field("user_id", "must be a number"))
+				return
+			}
+		}
+		limit := 100
+		if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 {
+			limit = min(v, 1000)
+		}
+
+		checkIns, err := repository.NewWellnessCheckInRepository(db).List(accountID, memberID, start, end, limit)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve check-ins", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]CheckInResponse, 0, len(checkIns))
+		for _, c := range checkIns {
+			resp = append(resp, toCheckInResponse(c))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleGetTodayCheckIn returns the current user's check-in for today in
+// their timezone, or 404 when they haven't checked in yet
+func HandleGetTodayCheckIn(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		today := time.Now().In(userLocation(db, userID))
+		checkIn, err := repository.NewWellnessCheckInRepository(db).GetForDay(accountID, userID, today)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "No check-in for today yet", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve check-in", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, toCheckInResponse(checkIn))
+	}
+}
+
+// HandleCreateCheckIn records the current user's check-in for a day, today
+// unless date is given. A second check-in for the same day is refused.
+func HandleCreateCheckIn(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req CheckInRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		today := time.Now().In(userLocation(db, userID))
+		date := repository.CheckInDate(today)
+		if req.Date != "" {
+			parsed, err := time.Parse("2006-01-02", req.Date)
+			if err != nil {
+				respond.Validation(w, "Invalid date format (use YYYY-MM-DD)", respond.Field("date", "invalid format (use YYYY-MM-DD)"))
+				return
+			}
+			if parsed.After(date) {
+				respond.Validation(w, "Can't check in for a future day", respond.Field("date", "is in the future"))
+				return
+			}
+			date = parsed
+		}
+
+		checkIn := &models.WellnessCheckIn{
+			AccountID: accountID,
+			UserID:    sql.NullInt64{Int64: userID, Valid: true},
+			Date:      date,
+		}
+		if fe := applyCheckInRequest(checkIn, &req); fe != nil {
+			respond.Validation(w, "Invalid check-in: "+fe.Field+" "+fe.Message, *fe)
+			return
+		}
+
+		if err := repository.NewWellnessCheckInRepository(db).Create(checkIn); err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") || strings.Contains(err.Error(), "duplicate key") {
+				respond.Error(w, "You've already checked in for "+date.Format("2006-01-02"), http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to record check-in", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"wellness_checkin",
+			sql.NullInt64{Int64: checkIn.ID, Valid: true},
+			map[string]interface{}{"date": date.Format("2006-01-02")},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusCreated, toCheckInResponse(checkIn))
+	}
+}
+
+// ownCheckIn loads the check-in named in the URL, answering for the handler
+// when it doesn't exist or belongs to another member
+func ownCheckIn(db *database.DB, w http.ResponseWriter, r *http.Request, accountID, userID int64) (*models.WellnessCheckIn, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respond.Error(w, "Invalid check-in ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	checkIn, err := repository.NewWellnessCheckInRepository(db).GetByID(id, accountID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			respond.Error(w, "Check-in not found", http.StatusNotFound)
+			return nil, false
+		}
+		respond.Error(w, "Failed to retrieve check-in", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !checkIn.UserID.Valid || checkIn.UserID.Int64 != userID {
+		respond.Error(w, "Only the member who checked in can change it", http.StatusForbidden)
+		return nil, false
+	}
+	return checkIn, true
+}
+
+// HandleUpdateCheckIn replaces the readings of one of the current user's
+// check-ins
+func HandleUpdateCheckIn(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req CheckInRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		checkIn, ok := ownCheckIn(db, w, r, accountID, userID)
+		if !ok {
+			return
+		}
+		if fe := applyCheckInRequest(checkIn, &req); fe != nil {
+			respond.Validation(w, "Invalid check-in: "+fe.Field+" "+fe.Message, *fe)
+			return
+		}
+
+		if err := repository.NewWellnessCheckInRepository(db).Update(checkIn); err != nil {
+			respond.Error(w, "Failed to update check-in", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"wellness_checkin",
+			sql.NullInt64{Int64: checkIn.ID, Valid: true},
+			map[string]interface{}{"date": checkIn.Date.UTC().Format("2006-01-02")},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, toCheckInResponse(checkIn))
+	}
+}
+
+// HandleDeleteCheckIn deletes one of the current user's check-ins
+func HandleDeleteCheckIn(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		checkIn, ok := ownCheckIn(db, w, r, accountID, userID)
+		if !ok {
+			return
+		}
+		if err := repository.NewWellnessCheckInRepository(db).Delete(checkIn.ID, accountID); err != nil {
+			respond.Error(w, "Failed to delete check-in", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"wellness_checkin",
+			sql.NullInt64{Int64: checkIn.ID, Valid: true},
+			map[string]interface{}{"date": checkIn.Date.UTC().Format("2006-01-02")},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleGetCheckInTrends returns daily averages of check-in readings over
+// the last days (default 30), for one member when user_id is given
+func HandleGetCheckInTrends(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		days := 30
+		if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 {
+			days = min(d, 366)
+		}
+		var memberID int64
+		if v := r.URL.Query().Get("user_id"); v != "" {
+			var err error
+			if memberID, err = strconv.ParseInt(v, 10, 64); err != nil {
+				respond.Validation(w, "Invalid user_id", respond.Field("user_id", "must be a number"))
+				return
+			}
+		}
+
+		today := time.Now().In(userLocation(db, userID))
+		checkIns, err := repository.NewWellnessCheckInRepository(db).List(accountID, memberID, today.AddDate(0, 0, -(days-1)), today, days*50)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve check-in trends", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, checkInTrends(checkIns))
+	}
+}
+
+// checkInTrends averages check-ins per day, oldest day first
+func checkInTrends(checkIns []*models.WellnessCheckIn) CheckInTrendsResponse {
+	type sums struct {
+		total [5]float64
+		count [5]int
+	}
+	byDate := map[string]*sums{}
+	var dates []string
+	for _, c := range checkIns {
+		date := c.Date.UTC().Format("2006-01-02")
+		s := byDate[date]
+		if s == nil {
+			s = &sums{}
+			byDate[date] = s
+			dates = append(dates, date)
+		}
+		readings := [5]sql.NullFloat64{
+			{Float64: float64(c.Mood.Int64), Valid: c.Mood.Valid},
+			{Float64: float64(c.Energy.Int64), Valid: c.Energy.Valid},
+			c.SleepHours,
+			c.Temperature,
+			c.Weight,
+		}
+		for i, v := range readings {
+			if v.Valid {
+				s.total[i] += v.Float64
+				s.count[i]++
+			}
+		}
+	}
+
+	resp := CheckInTrendsResponse{
+		Dates:       []string{},
+		Mood:        []*float64{},
+		Energy:      []*float64{},
+		SleepHours:  []*float64{},
+		Temperature: []*float64{},
+		Weight:      []*float64{},
+	}
+	series := []*[]*float64{&resp.Mood, &resp.Energy, &resp.SleepHours, &resp.Temperature, &resp.Weight}
+	// Check-ins come newest first
+	for i := len(dates) - 1; i >= 0; i-- {
+		s := byDate[dates[i]]
+		resp.Dates = append(resp.Dates, dates[i])
+		for j, out := range series {
+			var avg *float64
+			if s.count[j] > 0 {
+				v := s.total[j] / float64(s.count[j])
+				avg = &v
+			}
+			*out = append(*out, avg)
+		}
+	}
+	return resp
+}
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CompoundRequest is the payload for creating or updating a compound.
+// InventoryItemType defaults to a slug of the name and cannot be changed later.
+type CompoundRequest struct {
+	Name              *string  `json:"name,omitempty" validate:"max=100"`
+	Concentration     *float64 `json:"concentration_mg_per_ml,omitempty" validate:"min=0"` // 0 clears it
+	Route             *string  `json:"route,omitempty" validate:"oneof=intramuscular subcutaneous intradermal other"`
+	InventoryItemType *string  `json:"inventory_item_type,omitempty" validate:"max=50"`
+	DefaultDoseML     *float64 `json:"default_dose_ml,omitempty"` // 0 clears it
+	Notes             *string  `json:"notes,omitempty" validate:"multiline,max=2000"`
+	IsActive          *bool    `json:"is_active,omitempty"`
+}
+
+// CompoundResponse is a compound as returned by the API
+type CompoundResponse struct {
+	ID                int64     `json:"id"`
+	Name              string    `json:"name"`
+	Concentration     *float64  `json:"concentration_mg_per_ml,omitempty"`
+	Route             string    `json:"route"`
+	InventoryItemType string    `json:"inventory_item_type"`
+	DefaultDoseML     *float64  `json:"default_dose_ml,omitempty"`
+	Notes             string    `json:"notes,omitempty"`
+	IsActive          bool      `json:"is_active"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func toCompoundResponse(c *models.Compound) CompoundResponse {
+	resp := CompoundResponse{
+		ID:                c.ID,
+		Name:              c.Name,
+		Route:             c.Route,
+		InventoryItemType: c.InventoryItemType,
+		Notes:             c.Notes.String,
+		IsActive:          c.IsActive,
+		CreatedAt:         c.CreatedAt,
+		UpdatedAt:         c.UpdatedAt,
+	}
+	if c.Concentration.Valid {
+		resp.Concentration = &c.Concentration.Float64
+	}
+	if c.DefaultDoseML.Valid {
+		resp.DefaultDoseML = &c.DefaultDoseML.Float64
+	}
+	return resp
+}
+
+// compoundBelongsToAccount reports whether a compound exists in the account
+func compoundBelongsToAccount(db *database.DB, compoundID, accountID int64) bool {
+	if compoundID <= 0 || accountID == 0 {
+		return false
+	}
+	_, err := repository.NewCompoundRepository(db).GetByID(compoundID, accountID)
+	return err == nil
+}
+
+// applyCompoundRequest validates req and copies the provided fields onto c,
+// returning a client-facing error message on failure
+func applyCompoundRequest(c *models.Compound, req *CompoundRequest) string {
+	if req.Name != nil {
+		if *req.Name == "" {
+			return "name is required"
+		}
+		c.Name = *req.Name
+	}
+	if req.Concentration != nil {
+		c.Concentration = sql.NullFloat64{Float64: *req.Concentration, Valid: *req.Concentration != 0}
+	}
+	if req.Route != nil && *req.Route != "" {
+		c.Route = *req.Route
+	}
+	if req.DefaultDoseML != nil {
+		if *req.DefaultDoseML == 0 {
+			c.DefaultDoseML = sql.NullFloat64{}
+		} else if err := validateDoseML(req.DefaultDoseML); err != nil {
+			return "default_" + err.Error()
+		} else {
+			c.DefaultDoseML = sql.NullFloat64{Float64: *req.DefaultDoseML, Valid: true}
+		}
+	}
+	if req.Notes != nil {
+		c.Notes = optionalText(req.Notes)
+	}
+	if req.IsActive != nil {
+		c.IsActive = *req.IsActive
+	}
+	return ""
+}
+
+// HandleGetCompounds lists the account's compounds
+func HandleGetCompounds(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		compoundRepo := repository.NewCompoundRepository(db)
+		var compounds []*models.Compound
+		var err error
+		if r.URL.Query().Get("filter") == "active" {
+			compounds, err = compoundRepo.ListActive(accountID)
+		} else {
+			compounds, err = compoundRepo.List(accountID)
+		}
+		if err != nil {
+			respond.Error(w, "Failed to retrieve compounds", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]CompoundResponse, 0, len(compounds))
+		for _, c := range compounds {
+			resp = append(resp, toCompoundResponse(c))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// HandleGetCompound returns a single compound
+func HandleGetCompound(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid compound ID", http.StatusBadRequest)
+			return
+		}
+
+		compound, err := repository.NewCompoundRepository(db).GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Compound not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve compound", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toCompoundResponse(compound))
+	}
+}
+
+// HandleCreateCompound adds an injectable medication to the account
+func HandleCreateCompound(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req CompoundRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Name == nil {
+			respond.Validation(w, "name is required", respond.Field("name", "is required"))
+			return
+		}
+
+		compound := &models.Compound{
+			AccountID: accountID,
+			Route:     "intramuscular",
+			IsActive:  true,
+			CreatedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if msg := applyCompoundRequest(compound, &req); msg != "" {
+			respond.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		itemType := itemTypeSlug(compound.Name)
+		if req.InventoryItemType != nil && *req.InventoryItemType != "" {
+			itemType = itemTypeSlug(*req.InventoryItemType)
+		}
+		if itemType == "" {
+			respond.Validation(w, "inventory_item_type must contain letters or digits", respond.Field("inventory_item_type", "must contain letters or digits"))
+			return
+		}
+		if existing := lookupInventoryItemType(db, accountID, itemType); existing != nil && existing.Unit != "mL" {
+			respond.Validation(w, "inventory_item_type must be an item measured in mL", respond.Field("inventory_item_type", "must be an item measured in mL"))
+			return
+		}
+		compound.InventoryItemType = itemType
+
+		compoundRepo := repository.NewCompoundRepository(db)
+		if err := compoundRepo.Create(compound); err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				respond.Error(w, "A compound with this name or inventory item already exists", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to create compound", http.StatusInternalServerError)
+			return
+		}
+
+		// Give the compound's stock an item type so it can be adjusted and alerted on
+		itemTypeRepo := repository.NewInventoryItemTypeRepository(db)
+		if err := itemTypeRepo.EnsureExists(&models.InventoryItemType{
+			AccountID: accountID,
+			ItemType:  compound.InventoryItemType,
+			Name:      compound.Name,
+			Unit:      "mL",
+			SortOrder: 10,
+		}); err != nil {
+			middleware.Log(r.Context()).Error("Failed to create inventory item type for compound", "compound_id", compound.ID, "err", err)
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"compound",
+			sql.NullInt64{Int64: compound.ID, Valid: true},
+			map[string]interface{}{
+				"name":                compound.Name,
+				"inventory_item_type": compound.InventoryItemType,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(toCompoundResponse(compound))
+	}
+}
+
+// HandleUpdateCompound updates a compound's details
+func HandleUpdateCompound(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid compound ID", http.StatusBadRequest)
+			return
+		}
+
+		var req CompoundRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		compoundRepo := repository.NewCompoundRepository(db)
+		compound, err := compoundRepo.GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Compound not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve compound", http.StatusInternalServerError)
+			return
+		}
+
+		if req.InventoryItemType != nil && *req.InventoryItemType != compound.InventoryItemType {
+			respond.Validation(w, "inventory_item_type cannot be changed", respond.Field("inventory_item_type", "cannot be changed"))
+			return
+		}
+		if msg := applyCompoundRequest(compound, &req); msg != "" {
+			respond.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		if err := compoundRepo.Update(compound); err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				respond.Error(w, "A compound with this name already exists", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to update compound", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"compound",
+			sql.NullInt64{Int64: compound.ID, Valid: true},
+			map[string]interface{}{"name": compound.Name},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		compound.UpdatedAt = time.Now()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toCompoundResponse(compound))
+	}
+}
+
+// HandleDeleteCompound removes a compound that no course uses. Compounds with
+// courses should be deactivated instead so past injections keep their medication.
+func HandleDeleteCompound(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid compound ID", http.StatusBadRequest)
+			return
+		}
+
+		compoundRepo := repository.NewCompoundRepository(db)
+		count, err := compoundRepo.CountCourses(id, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to check compound usage", http.StatusInternalServerError)
+			return
+		}
+		if count > 0 {
+			respond.Error(w, "Compound is used by existing courses; deactivate it instead", http.StatusConflict)
+			return
+		}
+
+		if err := compoundRepo.Delete(id, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Compound not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete compound", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"compound",
+			sql.NullInt64{Int64: id, Valid: true},
+			map[string]interface{}{},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
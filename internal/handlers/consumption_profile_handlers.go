@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+)
+
+// ConsumptionProfileRequest replaces the account's consumption profile.
+// Items maps item types to the amount one injection uses; item types left
+// out are no longer decremented.
+type ConsumptionProfileRequest struct {
+	Items map[string]float64 `json:"items"`
+}
+
+// ConsumptionProfileItemResponse is one item of a consumption profile
+type ConsumptionProfileItemResponse struct {
+	ItemType string  `json:"item_type"`
+	Name     string  `json:"name"`
+	Unit     string  `json:"unit,omitempty"`
+	Amount   float64 `json:"amount"`
+}
+
+// ConsumptionProfileResponse is a version of the account's consumption profile
+type ConsumptionProfileResponse struct {
+	Version   int                              `json:"version"`
+	Items     []ConsumptionProfileItemResponse `json:"items"`
+	CreatedBy *int64                           `json:"created_by,omitempty"`
+	CreatedAt time.Time                        `json:"created_at"`
+}
+
+// toConsumptionProfileResponse names a profile's items using the account's
+// current item types; item types deleted since keep their key as the name
+func toConsumptionProfileResponse(p *models.ConsumptionProfile, types map[string]*models.InventoryItemType) ConsumptionProfileResponse {
+	resp := ConsumptionProfileResponse{
+		Version:   p.Version,
+		Items:     make([]ConsumptionProfileItemResponse, 0, len(p.Items)),
+		CreatedAt: p.CreatedAt,
+	}
+	if p.CreatedBy.Valid {
+		resp.CreatedBy = &p.CreatedBy.Int64
+	}
+	for _, item := range p.Items {
+		itemResp := ConsumptionProfileItemResponse{
+			ItemType: item.ItemType,
+			Name:     formatItemTypeName(item.ItemType),
+			Amount:   item.Amount,
+		}
+		if t, ok := types[item.ItemType]; ok {
+			itemResp.Name = t.Name
+			itemResp.Unit = t.Unit
+		}
+		resp.Items = append(resp.Items, itemResp)
+	}
+	return resp
+}
+
+// accountItemTypes loads the account's item types keyed by item type
+func accountItemTypes(db *database.DB, accountID int64) (map[string]*models.InventoryItemType, error) {
+	types, err := repository.NewInventoryItemTypeRepository(db).List(accountID)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*models.InventoryItemType, len(types))
+	for _, t := range types {
+		byKey[t.ItemType] = t
+	}
+	return byKey, nil
+}
+
+// HandleGetConsumptionProfile returns what one injection uses besides the
+// medication. Pass ?version=N for a past version, e.g. one recorded in
+// inventory history.
+func HandleGetConsumptionProfile(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		profileRepo := repository.NewConsumptionProfileRepository(db)
+		var profile *models.ConsumptionProfile
+		var err error
+		if v := r.URL.Query().Get("version"); v != "" {
+			version, convErr := strconv.Atoi(v)
+			if convErr != nil || version < 1 {
+				respond.Error(w, "Invalid version", http.StatusBadRequest)
+				return
+			}
+			profile, err = profileRepo.GetVersion(accountID, version)
+		} else {
+			profile, err = profileRepo.GetCurrent(accountID)
+		}
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Profile version not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve consumption profile", http.StatusInternalServerError)
+			return
+		}
+
+		types, err := accountItemTypes(db, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve item types", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toConsumptionProfileResponse(profile, types))
+	}
+}
+
+// HandleGetConsumptionProfileVersions lists every version of the account's
+// consumption profile, newest first
+func HandleGetConsumptionProfileVersions(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		profiles, err := repository.NewConsumptionProfileRepository(db).List(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve consumption profiles", http.StatusInternalServerError)
+			return
+		}
+		types, err := accountItemTypes(db, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve item types", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]ConsumptionProfileResponse, 0, len(profiles))
+		for _, p := range profiles {
+			resp = append(resp, toConsumptionProfileResponse(p, types))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// HandleUpdateConsumptionProfile replaces the account's consumption profile,
+// recording a new version if anything changed
+func HandleUpdateConsumptionProfile(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req ConsumptionProfileRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Items == nil {
+			respond.Validation(w, "items is required", respond.Field("items", "is required"))
+			return
+		}
+
+		types, err := accountItemTypes(db, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve item types", http.StatusInternalServerError)
+			return
+		}
+		for itemType, amount := range req.Items {
+			if _, ok := types[itemType]; !ok {
+				respond.Error(w, fmt.Sprintf("Unknown item type %q", itemType), http.StatusBadRequest)
+				return
+			}
+			if amount < 0 {
+				respond.Error(w, fmt.Sprintf("Amount for %s cannot be negative", itemType), http.StatusBadRequest)
+				return
+			}
+		}
+
+		profile, err := repository.NewConsumptionProfileRepository(db).Update(accountID, req.Items, userID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Item type was removed, please retry", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to update consumption profile", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"consumption_profile",
+			sql.NullInt64{Int64: profile.ID, Valid: true},
+			map[string]interface{}{
+				"version": profile.Version,
+				"items":   req.Items,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toConsumptionProfileResponse(profile, types))
+	}
+}
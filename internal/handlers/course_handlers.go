@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,25 +12,33 @@ import (
 	"injection-tracker/internal/middleware"
 	"injection-tracker/internal/models"
 	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
 
 	"github.com/go-chi/chi/v5"
 )
 
 // CreateCourseRequest represents the request body for creating a course
 type CreateCourseRequest struct {
-	Name            string  `json:"name"`
-	StartDate       string  `json:"start_date"`
-	ExpectedEndDate *string `json:"expected_end_date,omitempty"`
-	Notes           *string `json:"notes,omitempty"`
-	IsActive        *bool   `json:"is_active,omitempty"`
+	Name            string   `json:"name" validate:"required,max=100"`
+	StartDate       string   `json:"start_date" validate:"required"`
+	ExpectedEndDate *string  `json:"expected_end_date,omitempty"`
+	Notes           *string  `json:"notes,omitempty" validate:"multiline,max=2000"`
+	IsActive        *bool    `json:"is_active,omitempty"`
+	DoseML          *float64 `json:"dose_ml,omitempty"`
+	Concentration   *float64 `json:"concentration_mg_per_ml,omitempty"`
+	CompoundID      *int64   `json:"compound_id,omitempty"`
 }
 
 // UpdateCourseRequest represents the request body for updating a course
 type UpdateCourseRequest struct {
-	Name            *string `json:"name,omitempty"`
-	StartDate       *string `json:"start_date,omitempty"`
-	ExpectedEndDate *string `json:"expected_end_date,omitempty"`
-	Notes           *string `json:"notes,omitempty"`
+	Name            *string  `json:"name,omitempty" validate:"max=100"`
+	StartDate       *string  `json:"start_date,omitempty"`
+	ExpectedEndDate *string  `json:"expected_end_date,omitempty"`
+	Notes           *string  `json:"notes,omitempty" validate:"multiline,max=2000"`
+	DoseML          *float64 `json:"dose_ml,omitempty"`                 // 0 clears it
+	Concentration   *float64 `json:"concentration_mg_per_ml,omitempty"` // 0 clears it
+	CompoundID      *int64   `json:"compound_id,omitempty"`             // 0 clears it
 }
 
 // CloseCourseRequest represents the request body for closing a course
@@ -42,10 +49,11 @@ type CloseCourseRequest struct {
 // HandleGetCourses returns a list of all courses
 func HandleGetCourses(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -66,13 +74,13 @@ func HandleGetCourses(db *database.DB) http.HandlerFunc {
 		}
 
 		if err != nil {
-			http.Error(w, "Failed to retrieve courses", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve courses", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(courses); err != nil {
-			log.Printf("Failed to encode courses response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode courses response", "err", err)
 		}
 	}
 }
@@ -80,33 +88,23 @@ func HandleGetCourses(db *database.DB) http.HandlerFunc {
 // HandleCreateCourse creates a new course
 func HandleCreateCourse(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		var req CreateCourseRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
-		}
-
-		// Validate required fields
-		if req.Name == "" {
-			http.Error(w, "name is required", http.StatusBadRequest)
-			return
-		}
-		if req.StartDate == "" {
-			http.Error(w, "start_date is required", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
 		// Parse start date
 		startDate, err := time.Parse("2006-01-02", req.StartDate)
 		if err != nil {
-			http.Error(w, "Invalid start_date format, use YYYY-MM-DD", http.StatusBadRequest)
+			respond.Validation(w, "Invalid start_date format, use YYYY-MM-DD", respond.Field("start_date", "invalid format, use YYYY-MM-DD"))
 			return
 		}
 
@@ -115,12 +113,25 @@ func HandleCreateCourse(db *database.DB) http.HandlerFunc {
 		if req.ExpectedEndDate != nil && *req.ExpectedEndDate != "" {
 			parsedDate, err := time.Parse("2006-01-02", *req.ExpectedEndDate)
 			if err != nil {
-				http.Error(w, "Invalid expected_end_date format, use YYYY-MM-DD", http.StatusBadRequest)
+				respond.Validation(w, "Invalid expected_end_date format, use YYYY-MM-DD", respond.Field("expected_end_date", "invalid format, use YYYY-MM-DD"))
 				return
 			}
 			expectedEndDate = sql.NullTime{Time: parsedDate, Valid: true}
 		}
 
+		if err := validateDoseML(req.DoseML); err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Concentration != nil && *req.Concentration <= 0 {
+			respond.Validation(w, "concentration_mg_per_ml must be greater than 0", respond.Field("concentration_mg_per_ml", "must be greater than 0"))
+			return
+		}
+		if req.CompoundID != nil && !compoundBelongsToAccount(db, *req.CompoundID, accountID) {
+			respond.Error(w, "Compound not found", http.StatusBadRequest)
+			return
+		}
+
 		// Set is_active default to true if not specified
 		isActive := true
 		if req.IsActive != nil {
@@ -134,18 +145,19 @@ func HandleCreateCourse(db *database.DB) http.HandlerFunc {
 			ExpectedEndDate: expectedEndDate,
 			IsActive:        isActive,
 			Notes:           nullString(req.Notes),
+			DoseML:          nullFloat64(req.DoseML),
+			Concentration:   nullFloat64(req.Concentration),
+			CompoundID:      nullInt64(req.CompoundID),
 			CreatedBy:       sql.NullInt64{Int64: userID, Valid: true},
 			AccountID:       accountID,
 		}
 
 		courseRepo := repository.NewCourseRepository(db)
 
-		// If creating an active course, deactivate others first
-		// Note: When creating an active course, the Create method handles setting is_active.
-		// Pre-deactivation of other courses is handled by the Activate method if needed.
-
+		// Active courses run side by side (e.g. progesterone and estradiol), so
+		// creating one leaves the account's other active courses alone.
 		if err := courseRepo.Create(course); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create course: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to create course: %v", err), http.StatusInternalServerError)
 			return
 		}
 
@@ -167,7 +179,7 @@ func HandleCreateCourse(db *database.DB) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(course); err != nil {
-			log.Printf("Failed to encode course response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode course response", "err", err)
 		}
 	}
 }
@@ -175,10 +187,11 @@ func HandleCreateCourse(db *database.DB) http.HandlerFunc {
 // HandleGetActiveCourse returns the currently active course
 func HandleGetActiveCourse(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -186,16 +199,16 @@ func HandleGetActiveCourse(db *database.DB) http.HandlerFunc {
 		course, err := courseRepo.GetActiveCourse(accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "No active course found", http.StatusNotFound)
+				respond.Error(w, "No active course found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve active course", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve active course", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(course); err != nil {
-			log.Printf("Failed to encode course response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode course response", "err", err)
 		}
 	}
 }
@@ -203,17 +216,18 @@ func HandleGetActiveCourse(db *database.DB) http.HandlerFunc {
 // HandleGetCourse returns a single course by ID
 func HandleGetCourse(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid course ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid course ID", http.StatusBadRequest)
 			return
 		}
 
@@ -221,16 +235,16 @@ func HandleGetCourse(db *database.DB) http.HandlerFunc {
 		course, err := courseRepo.GetByID(id, accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Course not found", http.StatusNotFound)
+				respond.Error(w, "Course not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve course", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve course", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(course); err != nil {
-			log.Printf("Failed to encode course response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode course response", "err", err)
 		}
 	}
 }
@@ -238,23 +252,23 @@ func HandleGetCourse(db *database.DB) http.HandlerFunc {
 // HandleUpdateCourse updates an existing course
 func HandleUpdateCourse(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid course ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid course ID", http.StatusBadRequest)
 			return
 		}
 
 		var req UpdateCourseRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
@@ -263,10 +277,10 @@ func HandleUpdateCourse(db *database.DB) http.HandlerFunc {
 		course, err := courseRepo.GetByID(id, accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Course not found", http.StatusNotFound)
+				respond.Error(w, "Course not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve course", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve course", http.StatusInternalServerError)
 			return
 		}
 
@@ -277,7 +291,7 @@ func HandleUpdateCourse(db *database.DB) http.HandlerFunc {
 		if req.StartDate != nil {
 			startDate, err := time.Parse("2006-01-02", *req.StartDate)
 			if err != nil {
-				http.Error(w, "Invalid start_date format, use YYYY-MM-DD", http.StatusBadRequest)
+				respond.Validation(w, "Invalid start_date format, use YYYY-MM-DD", respond.Field("start_date", "invalid format, use YYYY-MM-DD"))
 				return
 			}
 			course.StartDate = startDate
@@ -288,7 +302,7 @@ func HandleUpdateCourse(db *database.DB) http.HandlerFunc {
 			} else {
 				parsedDate, err := time.Parse("2006-01-02", *req.ExpectedEndDate)
 				if err != nil {
-					http.Error(w, "Invalid expected_end_date format, use YYYY-MM-DD", http.StatusBadRequest)
+					respond.Validation(w, "Invalid expected_end_date format, use YYYY-MM-DD", respond.Field("expected_end_date", "invalid format, use YYYY-MM-DD"))
 					return
 				}
 				course.ExpectedEndDate = sql.NullTime{Time: parsedDate, Valid: true}
@@ -301,10 +315,41 @@ func HandleUpdateCourse(db *database.DB) http.HandlerFunc {
 				course.Notes = sql.NullString{String: *req.Notes, Valid: true}
 			}
 		}
+		if req.DoseML != nil {
+			if *req.DoseML == 0 {
+				course.DoseML = sql.NullFloat64{Valid: false}
+			} else {
+				if err := validateDoseML(req.DoseML); err != nil {
+					respond.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				course.DoseML = sql.NullFloat64{Float64: *req.DoseML, Valid: true}
+			}
+		}
+		if req.Concentration != nil {
+			if *req.Concentration == 0 {
+				course.Concentration = sql.NullFloat64{Valid: false}
+			} else if *req.Concentration < 0 {
+				respond.Validation(w, "concentration_mg_per_ml must be greater than 0", respond.Field("concentration_mg_per_ml", "must be greater than 0"))
+				return
+			} else {
+				course.Concentration = sql.NullFloat64{Float64: *req.Concentration, Valid: true}
+			}
+		}
+		if req.CompoundID != nil {
+			if *req.CompoundID == 0 {
+				course.CompoundID = sql.NullInt64{Valid: false}
+			} else if !compoundBelongsToAccount(db, *req.CompoundID, accountID) {
+				respond.Error(w, "Compound not found", http.StatusBadRequest)
+				return
+			} else {
+				course.CompoundID = sql.NullInt64{Int64: *req.CompoundID, Valid: true}
+			}
+		}
 
 		// Update course
 		if err := courseRepo.Update(course, accountID); err != nil {
-			http.Error(w, "Failed to update course", http.StatusInternalServerError)
+			respond.Error(w, "Failed to update course", http.StatusInternalServerError)
 			return
 		}
 
@@ -324,25 +369,27 @@ func HandleUpdateCourse(db *database.DB) http.HandlerFunc {
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(course); err != nil {
-			log.Printf("Failed to encode course response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode course response", "err", err)
 		}
 	}
 }
 
-// HandleDeleteCourse deletes a course and all associated data (CASCADE)
+// HandleDeleteCourse moves a course, with its injections, symptom logs and
+// everything else that belongs to it, to the trash
 func HandleDeleteCourse(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid course ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid course ID", http.StatusBadRequest)
 			return
 		}
 
@@ -351,16 +398,21 @@ func HandleDeleteCourse(db *database.DB) http.HandlerFunc {
 		course, err := courseRepo.GetByID(id, accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Course not found", http.StatusNotFound)
+				respond.Error(w, "Course not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve course", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve course", http.StatusInternalServerError)
 			return
 		}
 
-		// Delete course (will cascade delete injections, symptoms, etc.)
-		if err := courseRepo.Delete(id, accountID); err != nil {
-			http.Error(w, "Failed to delete course", http.StatusInternalServerError)
+		trashed := moveToTrash(w, db, &models.TrashItem{
+			AccountID: accountID,
+			ItemType:  repository.TrashCourse,
+			ItemID:    id,
+			Label:     course.Name,
+			DeletedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}, "Course not found")
+		if trashed == nil {
 			return
 		}
 
@@ -372,7 +424,8 @@ func HandleDeleteCourse(db *database.DB) http.HandlerFunc {
 			"course",
 			sql.NullInt64{Int64: id, Valid: true},
 			map[string]interface{}{
-				"name": course.Name,
+				"name":     course.Name,
+				"trash_id": trashed.ID,
 			},
 			r.RemoteAddr,
 			r.UserAgent(),
@@ -382,20 +435,21 @@ func HandleDeleteCourse(db *database.DB) http.HandlerFunc {
 	}
 }
 
-// HandleActivateCourse activates a course and deactivates all others
+// HandleActivateCourse activates a course alongside any already active ones
 func HandleActivateCourse(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid course ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid course ID", http.StatusBadRequest)
 			return
 		}
 
@@ -405,16 +459,16 @@ func HandleActivateCourse(db *database.DB) http.HandlerFunc {
 		course, err := courseRepo.GetByID(id, accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Course not found", http.StatusNotFound)
+				respond.Error(w, "Course not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve course", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve course", http.StatusInternalServerError)
 			return
 		}
 
 		// Activate course
 		if err := courseRepo.Activate(id, accountID); err != nil {
-			http.Error(w, "Failed to activate course", http.StatusInternalServerError)
+			respond.Error(w, "Failed to activate course", http.StatusInternalServerError)
 			return
 		}
 
@@ -436,7 +490,7 @@ func HandleActivateCourse(db *database.DB) http.HandlerFunc {
 		course, _ = courseRepo.GetByID(id, accountID)
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(course); err != nil {
-			log.Printf("Failed to encode course response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode course response", "err", err)
 		}
 	}
 }
@@ -444,17 +498,18 @@ func HandleActivateCourse(db *database.DB) http.HandlerFunc {
 // HandleCloseCourse closes a course by setting the actual end date
 func HandleCloseCourse(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid course ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid course ID", http.StatusBadRequest)
 			return
 		}
 
@@ -463,13 +518,16 @@ func HandleCloseCourse(db *database.DB) http.HandlerFunc {
 			// If no body provided, use current date
 			req.ActualEndDate = nil
 		}
+		if !validRequest(w, &req) {
+			return
+		}
 
 		// Parse actual end date or use current time
 		var endDate time.Time
 		if req.ActualEndDate != nil && *req.ActualEndDate != "" {
 			parsedDate, err := time.Parse("2006-01-02", *req.ActualEndDate)
 			if err != nil {
-				http.Error(w, "Invalid actual_end_date format, use YYYY-MM-DD", http.StatusBadRequest)
+				respond.Validation(w, "Invalid actual_end_date format, use YYYY-MM-DD", respond.Field("actual_end_date", "invalid format, use YYYY-MM-DD"))
 				return
 			}
 			endDate = parsedDate
@@ -483,16 +541,16 @@ func HandleCloseCourse(db *database.DB) http.HandlerFunc {
 		course, err := courseRepo.GetByID(id, accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Course not found", http.StatusNotFound)
+				respond.Error(w, "Course not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve course", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve course", http.StatusInternalServerError)
 			return
 		}
 
 		// Close course
 		if err := courseRepo.Close(id, accountID, endDate); err != nil {
-			http.Error(w, "Failed to close course", http.StatusInternalServerError)
+			respond.Error(w, "Failed to close course", http.StatusInternalServerError)
 			return
 		}
 
@@ -511,11 +569,81 @@ func HandleCloseCourse(db *database.DB) http.HandlerFunc {
 			r.UserAgent(),
 		)
 
+		emitWebhookEvent(db, accountID, services.EventCourseClosed, map[string]interface{}{
+			"id":         id,
+			"name":       course.Name,
+			"start_date": course.StartDate.Format("2006-01-02"),
+			"end_date":   endDate.Format("2006-01-02"),
+		})
+
 		// Return updated course
 		course, _ = courseRepo.GetByID(id, accountID)
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(course); err != nil {
-			log.Printf("Failed to encode course response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode course response", "err", err)
+		}
+	}
+}
+
+// CourseAutoCloseRunResponse lists the courses closed by an on-demand run
+type CourseAutoCloseRunResponse struct {
+	Closed []services.AutoClosedCourse `json:"closed"`
+}
+
+// HandleGetCourseAutoClose returns the policy for closing courses past
+// their expected end date
+func HandleGetCourseAutoClose(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		respondJSON(w, http.StatusOK, services.LoadCourseAutoClose(db))
+	}
+}
+
+// HandleUpdateCourseAutoClose turns auto-closing on or off and sets how many
+// days past its end date a course is kept open
+func HandleUpdateCourseAutoClose(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+
+		var req services.CourseAutoClose
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		if err := services.SaveCourseAutoClose(db, req); err != nil {
+			respond.Error(w, "Failed to save auto-close policy", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update_course_auto_close",
+			"settings",
+			sql.NullInt64{},
+			map[string]interface{}{"enabled": req.Enabled, "grace_days": req.GraceDays},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, services.LoadCourseAutoClose(db))
+	}
+}
+
+// HandleRunCourseAutoClose closes expired courses now rather than waiting
+// for the daily run
+func HandleRunCourseAutoClose(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		closed, err := services.CloseExpiredCourses(db, time.Now())
+		if err != nil {
+			respond.Error(w, "Failed to close expired courses", http.StatusInternalServerError)
+			return
+		}
+		if closed == nil {
+			closed = []services.AutoClosedCourse{}
 		}
+		respondJSON(w, http.StatusOK, CourseAutoCloseRunResponse{Closed: closed})
 	}
 }
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// courseScheduleHorizonDays is how far ahead a course schedule's next
+// injection is looked for
+const courseScheduleHorizonDays = 100
+
+// CourseScheduleRequest sets when a course's injections fall due: every
+// interval_hours, or on weekdays ("sun" to "sat"). Whole-day intervals and
+// weekdays are due at time_of_day (HH:MM); leave it out to use the account's
+// reminder time.
+type CourseScheduleRequest struct {
+	IntervalHours int      `json:"interval_hours,omitempty" validate:"min=0,max=2160"`
+	Weekdays      []string `json:"weekdays,omitempty" validate:"max=7"`
+	TimeOfDay     string   `json:"time_of_day,omitempty" validate:"max=5"`
+}
+
+// CourseScheduleResponse is when a course's injections fall due
+type CourseScheduleResponse struct {
+	CourseID      int64              `json:"course_id"`
+	Inherited     bool               `json:"inherited"` // No schedule of its own; it follows the account's reminder frequency and time
+	IntervalHours int                `json:"interval_hours,omitempty"`
+	Weekdays      []string           `json:"weekdays,omitempty"`
+	TimeOfDay     string             `json:"time_of_day"`
+	Description   string             `json:"description"` // e.g. "every 24 hours" or "on Mon, Wed, Fri"
+	NextDue       *time.Time         `json:"next_due,omitempty"`
+	Travel        *TravelDueResponse `json:"travel,omitempty"` // The next one at home and away, while a trip moves the schedule
+	UpdatedAt     *time.Time         `json:"updated_at,omitempty"`
+}
+
+// parseCourseSchedule validates a course schedule request
+func parseCourseSchedule(courseID int64, req CourseScheduleRequest) (*models.CourseSchedule, *respond.FieldError) {
+	schedule := &models.CourseSchedule{CourseID: courseID, IntervalHours: req.IntervalHours}
+	if (req.IntervalHours > 0) == (len(req.Weekdays) > 0) {
+		f := respond.Field("interval_hours", "set either interval_hours or weekdays")
+		return nil, &f
+	}
+
+	seen := map[time.Weekday]bool{}
+	for i, name := range req.Weekdays {
+		day, ok := models.ParseWeekday(name)
+		if !ok {
+			f := respond.Field(fmt.Sprintf("weekdays[%d]", i), "must be sun, mon, tue, wed, thu, fri or sat")
+			return nil, &f
+		}
+		seen[day] = true
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if seen[day] {
+			schedule.Weekdays = append(schedule.Weekdays, day)
+		}
+	}
+
+	if req.TimeOfDay != "" {
+		if _, err := time.Parse("15:04", req.TimeOfDay); err != nil || len(req.TimeOfDay) != 5 {
+			f := respond.Field("time_of_day", "must be HH:MM")
+			return nil, &f
+		}
+		schedule.TimeOfDay = sql.NullString{String: req.TimeOfDay, Valid: true}
+	}
+	return schedule, nil
+}
+
+// courseScheduleResponse describes when the course's injections fall due,
+// with the next one after the start of today in loc, on the trip's clock
+// while travelling. schedule is nil when the course follows the account's
+// settings.
+func courseScheduleResponse(db *database.DB, accountID int64, course *models.Course, schedule *models.CourseSchedule, loc *time.Location) (CourseScheduleResponse, error) {
+	now := time.Now()
+	travelLoc, trip, err := services.LoadTravelLocation(db, accountID, loc, now)
+	if err != nil {
+		return CourseScheduleResponse{}, err
+	}
+	plan, err := services.LoadInjectionSchedule(db, accountID, course, travelLoc)
+	if err != nil {
+		return CourseScheduleResponse{}, err
+	}
+	resp := CourseScheduleResponse{
+		CourseID:    course.ID,
+		Inherited:   schedule == nil,
+		TimeOfDay:   plan.ReminderTime,
+		Description: plan.Describe(),
+	}
+	if len(plan.Weekdays) > 0 {
+		for _, d := range plan.Weekdays {
+			resp.Weekdays = append(resp.Weekdays, models.WeekdayName(d))
+		}
+	} else {
+		resp.IntervalHours = plan.FrequencyHours
+	}
+	if schedule != nil {
+		resp.UpdatedAt = &schedule.UpdatedAt
+	}
+
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	if due := plan.DueTimes(today, today.AddDate(0, 0, courseScheduleHorizonDays), 1); len(due) > 0 {
+		resp.NextDue = &due[0]
+		resp.Travel = travelDue(trip, due[0])
+	}
+	return resp, nil
+}
+
+// courseScheduleFormData is a course's schedule as the courses page shows
+// and edits it, with whole-day intervals in days
+func courseScheduleFormData(db *database.DB, userID int64, resp CourseScheduleResponse) map[string]interface{} {
+	checked := map[string]bool{}
+	for _, d := range resp.Weekdays {
+		checked[d] = true
+	}
+	// The week runs Monday to Sunday on the form
+	var days []map[string]interface{}
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		name := models.WeekdayName(day)
+		days = append(days, map[string]interface{}{"Value": name, "Label": day.String()[:3], "Checked": checked[name]})
+	}
+	data := map[string]interface{}{
+		"CourseID":    resp.CourseID,
+		"Description": resp.Description,
+		"Inherited":   resp.Inherited,
+		"Weekly":      len(resp.Weekdays) > 0,
+		"Days":        days,
+		"Every":       resp.IntervalHours,
+		"Unit":        "hours",
+		"TimeOfDay":   resp.TimeOfDay,
+	}
+	if resp.IntervalHours > 0 && resp.IntervalHours%24 == 0 {
+		data["Every"] = resp.IntervalHours / 24
+		data["Unit"] = "days"
+	}
+	if resp.NextDue != nil {
+		data["NextDue"] = FormatDateTimeForUser(db, userID, *resp.NextDue)
+	}
+	if resp.Travel != nil {
+		data["Travel"] = travelDueFormData(resp.Travel)
+	}
+	return data
+}
+
+// courseFromURL loads the course named by the {id} URL parameter, writing
+// the error response if it isn't the account's
+func courseFromURL(w http.ResponseWriter, r *http.Request, db *database.DB, accountID int64) (*models.Course, bool) {
+	courseID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respond.Error(w, "Invalid course ID", http.StatusBadRequest)
+		return nil, false
+	}
+	course, err := repository.NewCourseRepository(db).GetByID(courseID, accountID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			respond.Error(w, "Course not found", http.StatusNotFound)
+		} else {
+			respond.Error(w, "Failed to retrieve course", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return course, true
+}
+
+// HandleGetCourseSchedule returns when a course's injections fall due, on
+// its own schedule or the account's settings, and when the next one is
+func HandleGetCourseSchedule(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		course, ok := courseFromURL(w, r, db, accountID)
+		if !ok {
+			return
+		}
+		schedule, err := repository.NewCourseScheduleRepository(db).Get(course.ID, accountID)
+		if err != nil && err != repository.ErrNotFound {
+			respond.Error(w, "Failed to retrieve course schedule", http.StatusInternalServerError)
+			return
+		}
+
+		resp, err := courseScheduleResponse(db, accountID, course, schedule, userLocation(db, userID))
+		if err != nil {
+			respond.Error(w, "Failed to plan injections", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleUpdateCourseSchedule sets a course's own schedule
+func HandleUpdateCourseSchedule(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		course, ok := courseFromURL(w, r, db, accountID)
+		if !ok {
+			return
+		}
+		var req CourseScheduleRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		schedule, field := parseCourseSchedule(course.ID, req)
+		if field != nil {
+			respond.Validation(w, "Invalid schedule: "+field.Message, *field)
+			return
+		}
+
+		if err := repository.NewCourseScheduleRepository(db).Set(schedule, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Course not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to save course schedule", http.StatusInternalServerError)
+			return
+		}
+
+		resp, err := courseScheduleResponse(db, accountID, course, schedule, userLocation(db, userID))
+		if err != nil {
+			respond.Error(w, "Failed to plan injections", http.StatusInternalServerError)
+			return
+		}
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update_schedule",
+			"course",
+			sql.NullInt64{Int64: course.ID, Valid: true},
+			map[string]interface{}{"interval_hours": req.IntervalHours, "weekdays": resp.Weekdays, "time_of_day": req.TimeOfDay},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleDeleteCourseSchedule removes a course's own schedule, so it follows
+// the account's reminder frequency and time again
+func HandleDeleteCourseSchedule(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		course, ok := courseFromURL(w, r, db, accountID)
+		if !ok {
+			return
+		}
+		// Deleting a schedule the course doesn't have leaves it as it is
+		if err := repository.NewCourseScheduleRepository(db).Delete(course.ID, accountID); err != nil && err != repository.ErrNotFound {
+			respond.Error(w, "Failed to delete course schedule", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete_schedule",
+			"course",
+			sql.NullInt64{Int64: course.ID, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
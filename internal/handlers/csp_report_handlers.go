@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/respond"
+)
+
+// maxCSPReportBytes bounds a violation report body; real ones are well
+// under a kilobyte, but report-to can batch a few
+const maxCSPReportBytes = 64 << 10
+
+// CSPViolation is a Content Security Policy violation report, as sent to
+// report-uri in {"csp-report": {...}}
+type CSPViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	BlockedURI         string `json:"blocked-uri"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+}
+
+// cspReportTo is one report in a report-to batch, whose body has the same
+// fields as CSPViolation under other names
+type cspReportTo struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		BlockedURL         string `json:"blockedURL"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+	} `json:"body"`
+}
+
+// HandleCSPReport logs the Content Security Policy violations browsers
+// report, from both report-uri and report-to. It needs no login, as a
+// violation can happen on any page.
+func HandleCSPReport(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCSPReportBytes))
+	if err != nil {
+		respond.Error(w, "Report too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var violations []CSPViolation
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/reports+json":
+		var reports []cspReportTo
+		if err := json.Unmarshal(body, &reports); err != nil {
+			respond.Error(w, "Invalid report", http.StatusBadRequest)
+			return
+		}
+		for _, report := range reports {
+			if report.Type != "csp-violation" {
+				continue
+			}
+			violations = append(violations, CSPViolation{
+				DocumentURI:        report.Body.DocumentURL,
+				EffectiveDirective: report.Body.EffectiveDirective,
+				BlockedURI:         report.Body.BlockedURL,
+				SourceFile:         report.Body.SourceFile,
+				LineNumber:         report.Body.LineNumber,
+			})
+		}
+	case "application/csp-report", "application/json":
+		var report struct {
+			Violation *CSPViolation `json:"csp-report"`
+		}
+		if err := json.Unmarshal(body, &report); err != nil || report.Violation == nil {
+			respond.Error(w, "Invalid report", http.StatusBadRequest)
+			return
+		}
+		violations = append(violations, *report.Violation)
+	default:
+		respond.Error(w, "Unsupported report type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	for _, v := range violations {
+		directive := v.EffectiveDirective
+		if directive == "" {
+			directive = v.ViolatedDirective
+		}
+		source := reportURL(v.SourceFile)
+		if source != "" && v.LineNumber > 0 {
+			source += ":" + strconv.Itoa(v.LineNumber)
+		}
+		middleware.Log(r.Context()).Warn("Content Security Policy violation",
+			"directive", directive,
+			"blocked", reportURL(v.BlockedURI),
+			"page", reportURL(v.DocumentURI),
+			"source", source,
+		)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reportURL is a URL from a report fit for the log: without its query
+// string, which can hold tokens, or a share link's token
+func reportURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		// "inline", "eval" and the like
+		return raw
+	}
+	u.RawQuery, u.Fragment = "", ""
+	if strings.HasPrefix(u.Path, "/share/") {
+		u.Path = "/share/redacted"
+	}
+	return u.String()
+}
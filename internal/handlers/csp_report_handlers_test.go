@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleCSPReport(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{
+			name:        "report-uri",
+			contentType: "application/csp-report",
+			body:        `{"csp-report":{"document-uri":"https://example.com/dashboard?x=1","violated-directive":"script-src-elem","blocked-uri":"inline","line-number":12}}`,
+			want:        http.StatusNoContent,
+		},
+		{
+			name:        "report-to",
+			contentType: "application/reports+json",
+			body:        `[{"type":"csp-violation","body":{"documentURL":"https://example.com/share/abc","effectiveDirective":"style-src-elem","blockedURL":"https://cdn.example.com/x.css"}},{"type":"deprecation","body":{}}]`,
+			want:        http.StatusNoContent,
+		},
+		{"not a report", "application/json", `{"hello":"world"}`, http.StatusBadRequest},
+		{"malformed", "application/reports+json", `[{`, http.StatusBadRequest},
+		{"other type", "text/plain", `hello`, http.StatusUnsupportedMediaType},
+		{"too large", "application/csp-report", `{"csp-report":{"blocked-uri":"` + strings.Repeat("a", maxCSPReportBytes) + `"}}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/csp-report", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			HandleCSPReport(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestReportURL(t *testing.T) {
+	tests := map[string]string{
+		"inline":                                "inline",
+		"https://example.com/dashboard?token=x": "https://example.com/dashboard",
+		"https://example.com/share/abc123#top":  "https://example.com/share/redacted",
+		"":                                      "",
+	}
+	for raw, want := range tests {
+		if got := reportURL(raw); got != want {
+			t.Errorf("reportURL(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
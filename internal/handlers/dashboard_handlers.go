@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+const (
+	// dashboardRecentActivity is how many recent entries the dashboard lists
+	dashboardRecentActivity = 10
+
+	// dashboardNextInjectionDays is how far ahead the dashboard looks for the
+	// next injection due
+	dashboardNextInjectionDays = 31
+)
+
+// DashboardResponse is everything the dashboard shows, in one round trip
+type DashboardResponse struct {
+	NextInjection  *DashboardNextInjection `json:"next_injection,omitempty"` // Absent when no course is active or nothing is due
+	LastInjection  *DashboardLastInjection `json:"last_injection,omitempty"`
+	AdherenceToday DoseAdherenceResponse   `json:"adherence_today"` // Medication doses due today
+	LowStock       []StockAlertResponse    `json:"low_stock"`
+	RecentActivity []ActivityResponse      `json:"recent_activity"`
+	GeneratedAt    time.Time               `json:"generated_at"`
+	Timezone       string                  `json:"timezone"`
+}
+
+// DashboardNextInjection is the next injection due on any active course
+type DashboardNextInjection struct {
+	CourseID   int64                    `json:"course_id"`
+	CourseName string                   `json:"course_name"`
+	Due        time.Time                `json:"due"`
+	DoseML     float64                  `json:"dose_ml,omitempty"`
+	Overdue    bool                     `json:"overdue"`
+	Site       *services.SiteSuggestion `json:"site,omitempty"`   // When the rotation planner is enabled
+	Travel     *TravelDueResponse       `json:"travel,omitempty"` // When a trip moves the schedule
+}
+
+// DashboardLastInjection is the account's most recent injection
+type DashboardLastInjection struct {
+	ID        int64     `json:"id"`
+	CourseID  int64     `json:"course_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Side      string    `json:"side"`
+	PainLevel *int64    `json:"pain_level,omitempty"`
+	DoseML    *float64  `json:"dose_ml,omitempty"`
+}
+
+// HandleGetDashboard returns the dashboard's figures in one payload so the
+// app doesn't make a request for each panel
+func HandleGetDashboard(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		loc := userLocation(db, userID)
+		now := time.Now()
+		local := now.In(loc)
+		today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		resp := DashboardResponse{
+			LowStock:    []StockAlertResponse{},
+			GeneratedAt: now,
+			Timezone:    loc.String(),
+		}
+
+		// Starting from the beginning of today keeps an injection due earlier
+		// today, and so now overdue, in view
+		plan, err := planInjections(db, accountID, loc, today, today.AddDate(0, 0, dashboardNextInjectionDays), 1)
+		if err != nil {
+			respond.Error(w, "Failed to plan injections", http.StatusInternalServerError)
+			return
+		}
+		if plan != nil && len(plan.Doses) > 0 {
+			dose := plan.Doses[0]
+			resp.NextInjection = &DashboardNextInjection{
+				CourseID:   dose.Course.ID,
+				CourseName: dose.Course.Name,
+				Due:        dose.Due,
+				DoseML:     dose.DoseML,
+				Overdue:    dose.Due.Before(now),
+				Travel:     travelDue(plan.Trip, dose.Due),
+			}
+			if FeatureEnabled(db, accountID, services.FeatureRotationPlanner) {
+				if suggestion, err := suggestNextInjectionSite(db, accountID, services.DefaultSiteRotationRules()); err == nil {
+					resp.NextInjection.Site = suggestion
+				}
+			}
+		}
+
+		recent, err := repository.NewInjectionRepository(db).GetRecent(accountID, 1)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve injections", http.StatusInternalServerError)
+			return
+		}
+		if len(recent) > 0 {
+			last := recent[0]
+			resp.LastInjection = &DashboardLastInjection{
+				ID:        last.ID,
+				CourseID:  last.CourseID,
+				Timestamp: last.Timestamp,
+				Side:      last.Side,
+			}
+			if last.PainLevel.Valid {
+				resp.LastInjection.PainLevel = &last.PainLevel.Int64
+			}
+			if last.DoseML.Valid {
+				resp.LastInjection.DoseML = &last.DoseML.Float64
+			}
+		}
+
+		medicationRepo := repository.NewMedicationRepository(db)
+		medications, err := medicationRepo.ListActive(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve medications", http.StatusInternalServerError)
+			return
+		}
+		var adherence services.DoseAdherence
+		for _, med := range medications {
+			doses, err := medicationDoses(medicationRepo, med, loc, today, today.AddDate(0, 0, 1), now)
+			if err != nil {
+				respond.Error(w, "Failed to retrieve medication logs", http.StatusInternalServerError)
+				return
+			}
+			for _, d := range doses {
+				adherence.Add(d)
+			}
+		}
+		resp.AdherenceToday = toDoseAdherenceResponse(adherence)
+
+		alerts, err := repository.NewStockAlertRepository(db).ListActive(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve stock alerts", http.StatusInternalServerError)
+			return
+		}
+		if len(alerts) > 0 {
+			types, err := accountItemTypes(db, accountID)
+			if err != nil {
+				respond.Error(w, "Failed to retrieve item types", http.StatusInternalServerError)
+				return
+			}
+			for _, a := range alerts {
+				resp.LowStock = append(resp.LowStock, toStockAlertResponse(a, types))
+			}
+		}
+
+		activity, err := listActivity(db, accountID, userID, repository.ActivityFilter{}, repository.PageRequest{Limit: dashboardRecentActivity})
+		if err != nil {
+			respond.Error(w, "Failed to retrieve recent activity", http.StatusInternalServerError)
+			return
+		}
+		resp.RecentActivity = make([]ActivityResponse, 0, len(activity.Items))
+		for _, e := range activity.Items {
+			resp.RecentActivity = append(resp.RecentActivity, toActivityResponse(i18n.FromContext(r.Context()), e))
+		}
+
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+func TestHandleGetDashboard(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	now := time.Now()
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'one', 'hash'), (2, 'two', 'hash');
+		INSERT INTO accounts (id) VALUES (1), (2);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner'), (2, 2, 'owner');
+	`); err != nil {
+		t.Fatalf("Failed to seed accounts: %v", err)
+	}
+	// Keep the user's day well clear of midnight, so doses due early today
+	// and the hourly schedule below don't straddle a day boundary
+	zone := "UTC"
+	if offset := 12 - now.UTC().Hour(); offset > 0 {
+		zone = fmt.Sprintf("Etc/GMT-%d", offset)
+	} else if offset < 0 {
+		zone = fmt.Sprintf("Etc/GMT+%d", -offset)
+	}
+	prefs := repository.DefaultUserPreferences(1)
+	prefs.Timezone = zone
+	if err := repository.NewSettingsRepository(db).SavePreferences(prefs); err != nil {
+		t.Fatalf("Failed to save preferences: %v", err)
+	}
+
+	// Both accounts have an active course due every hour
+	for _, accountID := range []int64{1, 2} {
+		if _, err := db.Exec(`INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (?, ?, 'Course', ?, 1)`,
+			accountID, accountID, now.AddDate(0, 0, -10)); err != nil {
+			t.Fatalf("Failed to seed course: %v", err)
+		}
+		if _, err := db.Exec(`INSERT INTO course_schedules (course_id, interval_hours) VALUES (?, 1)`, accountID); err != nil {
+			t.Fatalf("Failed to seed schedule: %v", err)
+		}
+	}
+
+	injections := repository.NewInjectionRepository(db)
+	own := &models.Injection{CourseID: 1, AdministeredBy: sql.NullInt64{Int64: 1, Valid: true}, Timestamp: now.Add(-3 * time.Hour), Side: "left",
+		PainLevel: sql.NullInt64{Int64: 4, Valid: true}}
+	other := &models.Injection{CourseID: 2, AdministeredBy: sql.NullInt64{Int64: 2, Valid: true}, Timestamp: now.Add(-10 * time.Minute), Side: "right"}
+	for _, injection := range []*models.Injection{own, other} {
+		if err := injections.Create(injection); err != nil {
+			t.Fatalf("Failed to create injection: %v", err)
+		}
+	}
+
+	// A medication with two doses due today, the first of them taken
+	loc := userLocation(db, 1)
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	medications := repository.NewMedicationRepository(db)
+	for _, accountID := range []int64{1, 2} {
+		med := &models.Medication{Name: "Estradiol", IsActive: true, DoseTimes: []string{"00:00", "23:59"}, AccountID: accountID}
+		if err := medications.Create(med); err != nil {
+			t.Fatalf("Failed to create medication: %v", err)
+		}
+		if accountID != 1 {
+			continue
+		}
+		log := &models.MedicationLog{MedicationID: med.ID, LoggedBy: sql.NullInt64{Int64: 1, Valid: true}, Timestamp: today,
+			Taken: true, ScheduledFor: sql.NullTime{Time: today, Valid: true}}
+		if err := medications.CreateLog(log); err != nil {
+			t.Fatalf("Failed to log dose: %v", err)
+		}
+	}
+
+	alerts := repository.NewStockAlertRepository(db)
+	for _, a := range []*models.StockAlert{
+		{AccountID: 1, ItemType: "progesterone", Level: models.StockAlertLow, Quantity: 2, Threshold: 5},
+		{AccountID: 2, ItemType: "syringes", Level: models.StockAlertOut, Quantity: 0, Threshold: 10},
+	} {
+		if err := alerts.Raise(a); err != nil {
+			t.Fatalf("Failed to raise alert: %v", err)
+		}
+	}
+
+	dashboard := func() DashboardResponse {
+		req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+		userCtx := &middleware.UserContext{UserID: 1, AccountID: 1, Role: "owner"}
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, userCtx))
+		rec := httptest.NewRecorder()
+		HandleGetDashboard(db)(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp DashboardResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode dashboard: %v", err)
+		}
+		return resp
+	}
+
+	resp := dashboard()
+	if resp.Timezone != zone {
+		t.Errorf("Expected the user's timezone %s, got %s", zone, resp.Timezone)
+	}
+	if resp.NextInjection == nil || resp.NextInjection.CourseID != 1 {
+		t.Fatalf("Expected the next injection on course 1, got %+v", resp.NextInjection)
+	}
+	if !resp.NextInjection.Overdue || !resp.NextInjection.Due.Before(now) {
+		t.Errorf("Expected an hourly injection last given 3 hours ago to be overdue, got %+v", resp.NextInjection)
+	}
+	if resp.LastInjection == nil || resp.LastInjection.ID != own.ID || resp.LastInjection.Side != "left" ||
+		resp.LastInjection.PainLevel == nil || *resp.LastInjection.PainLevel != 4 {
+		t.Errorf("Expected the account's own last injection, got %+v", resp.LastInjection)
+	}
+	if resp.AdherenceToday.Expected != 2 || resp.AdherenceToday.Taken != 1 {
+		t.Errorf("Expected 1 of 2 doses taken today, got %+v", resp.AdherenceToday)
+	}
+	if len(resp.LowStock) != 1 || resp.LowStock[0].ItemType != "progesterone" || resp.LowStock[0].Level != models.StockAlertLow {
+		t.Errorf("Expected only the account's progesterone alert, got %+v", resp.LowStock)
+	}
+	var sawOwn bool
+	for _, a := range resp.RecentActivity {
+		if a.Type == "injection" && a.ID == other.ID {
+			t.Errorf("Expected no activity from another account, got %+v", a)
+		}
+		if a.Type == "injection" && a.ID == own.ID {
+			sawOwn = true
+		}
+	}
+	if !sawOwn {
+		t.Errorf("Expected the account's injection in recent activity, got %+v", resp.RecentActivity)
+	}
+
+	// Injecting now moves the next injection an hour ahead
+	latest := &models.Injection{CourseID: 1, Timestamp: time.Now(), Side: "right"}
+	if err := injections.Create(latest); err != nil {
+		t.Fatalf("Failed to create injection: %v", err)
+	}
+	resp = dashboard()
+	if resp.NextInjection == nil || resp.NextInjection.Overdue {
+		t.Errorf("Expected the next injection no longer overdue, got %+v", resp.NextInjection)
+	}
+	if resp.LastInjection == nil || resp.LastInjection.ID != latest.ID {
+		t.Errorf("Expected the new injection as the last one, got %+v", resp.LastInjection)
+	}
+}
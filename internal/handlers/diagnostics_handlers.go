@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// serverStartedAt is when the process started, for the uptime
+var serverStartedAt = time.Now()
+
+// redactedValue replaces secrets in the support bundle
+const redactedValue = "********"
+
+// Diagnostics describes the state of the server, for the admin and for
+// support requests. Nothing in it identifies users.
+type Diagnostics struct {
+	GeneratedAt     time.Time                  `json:"generated_at"`
+	Database        DatabaseDiagnostics        `json:"database"`
+	Backups         BackupDiagnostics          `json:"backups"`
+	Schedulers      []services.SchedulerStatus `json:"schedulers"`
+	SMTPConfigured  bool                       `json:"smtp_configured"`
+	MaintenanceMode bool                       `json:"maintenance_mode"`
+	Disk            DiskDiagnostics            `json:"disk"`
+	Runtime         RuntimeDiagnostics         `json:"runtime"`
+}
+
+// DatabaseDiagnostics is the database's size and schema version. WALSize is
+// only reported for SQLite.
+type DatabaseDiagnostics struct {
+	Dialect           string `json:"dialect"`
+	Size              int64  `json:"size"`
+	WALSize           *int64 `json:"wal_size,omitempty"`
+	MigrationVersion  int    `json:"migration_version"`
+	PendingMigrations int    `json:"pending_migrations"`
+	UnknownMigrations int    `json:"unknown_migrations"`
+	Error             string `json:"error,omitempty"`
+}
+
+// BackupDiagnostics is when the newest local backup was made and how
+// auto-backup is doing
+type BackupDiagnostics struct {
+	LastBackupAt      *time.Time `json:"last_backup_at,omitempty"`
+	LastBackupAge     *int64     `json:"last_backup_age_seconds,omitempty"`
+	AutoBackupEnabled bool       `json:"auto_backup_enabled"`
+	AutoBackupLastRun string     `json:"auto_backup_last_run,omitempty"`
+	RemoteLastError   string     `json:"remote_last_error,omitempty"`
+}
+
+// DiskDiagnostics is the free space on the volume holding the data
+// directory
+type DiskDiagnostics struct {
+	Path  string `json:"path"`
+	Free  uint64 `json:"free"`
+	Total uint64 `json:"total"`
+	Error string `json:"error,omitempty"`
+}
+
+// RuntimeDiagnostics is the Go runtime's view of the process
+type RuntimeDiagnostics struct {
+	GoVersion     string `json:"go_version"`
+	OS            string `json:"os"`
+	Arch          string `json:"arch"`
+	NumCPU        int    `json:"num_cpu"`
+	Goroutines    int    `json:"goroutines"`
+	HeapAlloc     uint64 `json:"heap_alloc"`
+	Sys           uint64 `json:"sys"`
+	NumGC         uint32 `json:"num_gc"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+}
+
+// collectDiagnostics gathers the diagnostics. Each part that can't be read
+// says so in its own Error rather than failing the whole report.
+func collectDiagnostics(db *database.DB) Diagnostics {
+	now := time.Now()
+	enabled, _ := maintenance.Status()
+	d := Diagnostics{
+		GeneratedAt:     now.UTC(),
+		Database:        databaseDiagnostics(db),
+		Backups:         backupDiagnostics(db, now),
+		Schedulers:      services.SchedulerStatuses(),
+		SMTPConfigured:  services.LoadSMTPConfig(db).IsConfigured(),
+		MaintenanceMode: enabled,
+		Disk:            DiskDiagnostics{Path: "data"},
+	}
+
+	if abs, err := filepath.Abs("data"); err == nil {
+		d.Disk.Path = abs
+	}
+	if free, total, err := services.DiskUsage(d.Disk.Path); err != nil {
+		d.Disk.Error = err.Error()
+	} else {
+		d.Disk.Free, d.Disk.Total = free, total
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	d.Runtime = RuntimeDiagnostics{
+		GoVersion:     runtime.Version(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		NumCPU:        runtime.NumCPU(),
+		Goroutines:    runtime.NumGoroutine(),
+		HeapAlloc:     mem.HeapAlloc,
+		Sys:           mem.Sys,
+		NumGC:         mem.NumGC,
+		UptimeSeconds: int64(now.Sub(serverStartedAt).Seconds()),
+	}
+	return d
+}
+
+func databaseDiagnostics(db *database.DB) DatabaseDiagnostics {
+	d := DatabaseDiagnostics{Dialect: db.Dialect.Name()}
+
+	if path, ok := db.File(); ok {
+		if info, err := os.Stat(path); err == nil {
+			d.Size = info.Size()
+		} else {
+			d.Error = err.Error()
+		}
+		var wal int64
+		if info, err := os.Stat(path + "-wal"); err == nil {
+			wal = info.Size()
+		}
+		d.WALSize = &wal
+	} else if err := db.QueryRow("SELECT pg_database_size(current_database())").Scan(&d.Size); err != nil {
+		d.Error = err.Error()
+	}
+
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	for _, s := range statuses {
+		switch {
+		case s.Unknown:
+			d.UnknownMigrations++
+		case !s.Applied:
+			d.PendingMigrations++
+		}
+		if s.Applied && s.Version > d.MigrationVersion {
+			d.MigrationVersion = s.Version
+		}
+	}
+	return d
+}
+
+func backupDiagnostics(db *database.DB, now time.Time) BackupDiagnostics {
+	settings := getAutoBackupSettings(db)
+	d := BackupDiagnostics{
+		AutoBackupEnabled: settings.Enabled,
+		AutoBackupLastRun: settings.LastRun,
+		RemoteLastError:   getSettingValue(db, "auto_backup_remote_last_error"),
+	}
+
+	entries, _ := os.ReadDir(filepath.Join("data", "backups"))
+	var newest time.Time
+	for _, entry := range entries {
+		if entry.IsDir() || !services.IsBackupName(entry.Name(), db.Dialect.BackupExtension()) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	if !newest.IsZero() {
+		age := int64(now.Sub(newest).Seconds())
+		d.LastBackupAt = &newest
+		d.LastBackupAge = &age
+	}
+	return d
+}
+
+// HandleGetDiagnostics returns the server's diagnostics
+func HandleGetDiagnostics(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(collectDiagnostics(db))
+	}
+}
+
+// isSecretSetting reports whether a setting holds a credential
+func isSecretSetting(key string) bool {
+	for _, word := range []string{"password", "secret", "token", "passphrase", "api_key"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedSettings returns the site-wide settings with credentials masked
+func redactedSettings(db *database.DB) (map[string]interface{}, error) {
+	site, err := repository.NewSettingsRepository(db).ListSite()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := map[string]interface{}{}
+	for _, setting := range site {
+		key, value := setting.Key, setting.Value
+		switch {
+		case key == "auto_backup_destination_config":
+			settings[key] = services.RedactBackupDestinationConfig(value)
+		case isSecretSetting(key) && value != "":
+			settings[key] = redactedValue
+		default:
+			settings[key] = value
+		}
+	}
+	return settings, nil
+}
+
+// HandleDownloadSupportBundle sends a zip of the diagnostics, the migration
+// history and the site settings with credentials masked, to attach to a
+// support request
+func HandleDownloadSupportBundle(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		settings, err := redactedSettings(db)
+		if err != nil {
+			respond.Error(w, "Failed to load settings", http.StatusInternalServerError)
+			return
+		}
+		migrations, err := db.MigrationStatus()
+		if err != nil {
+			respond.Error(w, "Failed to load migration status", http.StatusInternalServerError)
+			return
+		}
+		files := map[string]interface{}{
+			"diagnostics.json": collectDiagnostics(db),
+			"migrations.json":  migrations,
+			"settings.json":    settings,
+		}
+
+		filename := fmt.Sprintf("ptrack-support_%s.zip", time.Now().Format("20060102_150405"))
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+		w.Header().Set("Cache-Control", "no-store")
+
+		zw := zip.NewWriter(w)
+		for _, name := range []string{"diagnostics.json", "migrations.json", "settings.json"} {
+			f, err := zw.Create(name)
+			if err != nil {
+				slog.Error("Failed to write support bundle", "err", err)
+				return
+			}
+			enc := json.NewEncoder(f)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(files[name]); err != nil {
+				slog.Error("Failed to write support bundle", "err", err)
+				return
+			}
+		}
+		if err := zw.Close(); err != nil {
+			slog.Error("Failed to write support bundle", "err", err)
+		}
+	}
+}
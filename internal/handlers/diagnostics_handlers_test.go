@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/repository"
+)
+
+func TestSupportBundleRedactsSettings(t *testing.T) {
+	t.Chdir(t.TempDir())
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	setSettingValue(db, "smtp_host", "mail.example.com")
+	setSettingValue(db, "smtp_password", "hunter2")
+	setSettingValue(db, "auto_backup_destination_config", `{"bucket":"backups","secret_access_key":"s3cret"}`)
+	if _, err := db.Exec(`INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash')`); err != nil {
+		t.Fatalf("Failed to seed user: %v", err)
+	}
+	if err := repository.NewSettingsRepository(db).SetUser(1, repository.UserSettingCheckInReminder, "20:00"); err != nil {
+		t.Fatalf("Failed to save user setting: %v", err)
+	}
+
+	settings, err := redactedSettings(db)
+	if err != nil {
+		t.Fatalf("redactedSettings failed: %v", err)
+	}
+	if settings["smtp_host"] != "mail.example.com" {
+		t.Errorf("smtp_host = %v, want it kept", settings["smtp_host"])
+	}
+	if settings["smtp_password"] != redactedValue {
+		t.Errorf("smtp_password = %v, want it redacted", settings["smtp_password"])
+	}
+	dest, _ := settings["auto_backup_destination_config"].(map[string]interface{})
+	if dest["bucket"] != "backups" || dest["secret_access_key"] == "s3cret" {
+		t.Errorf("Destination config = %v, want the bucket kept and the key redacted", dest)
+	}
+	if _, ok := settings[repository.UserSettingCheckInReminder]; ok {
+		t.Error("Per-user settings should be left out")
+	}
+
+	d := collectDiagnostics(db)
+	if d.Database.Dialect != database.SQLite || d.Database.Size == 0 || d.Database.MigrationVersion == 0 || d.Database.PendingMigrations != 0 {
+		t.Errorf("Database diagnostics = %+v", d.Database)
+	}
+	if d.Backups.LastBackupAt != nil {
+		t.Errorf("LastBackupAt = %v with no backups", d.Backups.LastBackupAt)
+	}
+}
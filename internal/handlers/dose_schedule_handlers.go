@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+)
+
+// DoseStepRequest is one step of a taper schedule. Dates are YYYY-MM-DD and
+// inclusive; leave end_date out to keep the step going until the course ends.
+type DoseStepRequest struct {
+	StartDate string  `json:"start_date" validate:"required"`
+	EndDate   string  `json:"end_date,omitempty"`
+	DoseML    float64 `json:"dose_ml"`
+}
+
+// DoseScheduleRequest replaces a course's taper schedule; an empty list of
+// steps removes it. A schedule has at most a year of weekly steps.
+type DoseScheduleRequest struct {
+	Steps []DoseStepRequest `json:"steps" validate:"max=52"`
+}
+
+// DoseStepResponse is one step of a taper schedule
+type DoseStepResponse struct {
+	StartDate string  `json:"start_date"`
+	EndDate   string  `json:"end_date,omitempty"`
+	DoseML    float64 `json:"dose_ml"`
+}
+
+// DoseScheduleResponse is a course's taper schedule
+type DoseScheduleResponse struct {
+	CourseID int64              `json:"course_id"`
+	Steps    []DoseStepResponse `json:"steps"`
+}
+
+func toDoseScheduleResponse(courseID int64, steps []*models.DoseStep) DoseScheduleResponse {
+	resp := DoseScheduleResponse{CourseID: courseID, Steps: make([]DoseStepResponse, 0, len(steps))}
+	for _, s := range steps {
+		step := DoseStepResponse{StartDate: s.StartDate.Format("2006-01-02"), DoseML: s.DoseML}
+		if s.EndDate.Valid {
+			step.EndDate = s.EndDate.Time.Format("2006-01-02")
+		}
+		resp.Steps = append(resp.Steps, step)
+	}
+	return resp
+}
+
+// parseDoseSteps validates a taper schedule and returns its steps in date
+// order. Steps may leave gaps, which fall back to the course's dose, but
+// can't overlap.
+func parseDoseSteps(req []DoseStepRequest) ([]*models.DoseStep, *respond.FieldError) {
+	steps := make([]*models.DoseStep, 0, len(req))
+	for i, s := range req {
+		field := fmt.Sprintf("steps[%d]", i)
+		start, err := time.Parse("2006-01-02", s.StartDate)
+		if err != nil {
+			f := respond.Field(field+".start_date", "must be YYYY-MM-DD")
+			return nil, &f
+		}
+		step := &models.DoseStep{StartDate: start, DoseML: s.DoseML}
+		if s.EndDate != "" {
+			end, err := time.Parse("2006-01-02", s.EndDate)
+			if err != nil {
+				f := respond.Field(field+".end_date", "must be YYYY-MM-DD")
+				return nil, &f
+			}
+			if end.Before(start) {
+				f := respond.Field(field+".end_date", "must not be before start_date")
+				return nil, &f
+			}
+			step.EndDate = sql.NullTime{Time: end, Valid: true}
+		}
+		if err := validateDoseML(&s.DoseML); err != nil {
+			f := respond.Field(field+".dose_ml", err.Error())
+			return nil, &f
+		}
+		steps = append(steps, step)
+	}
+
+	sort.Slice(steps, func(i, j int) bool { return steps[i].StartDate.Before(steps[j].StartDate) })
+	for i := 1; i < len(steps); i++ {
+		prev := steps[i-1]
+		if !prev.EndDate.Valid || !prev.EndDate.Time.Before(steps[i].StartDate) {
+			f := respond.Field("steps", fmt.Sprintf("the step starting %s overlaps the one before it", steps[i].StartDate.Format("2006-01-02")))
+			return nil, &f
+		}
+	}
+	return steps, nil
+}
+
+// HandleGetDoseSchedule returns a course's taper schedule
+func HandleGetDoseSchedule(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		courseID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid course ID", http.StatusBadRequest)
+			return
+		}
+
+		steps, err := repository.NewDoseScheduleRepository(db).List(courseID, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Course not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve taper schedule", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, toDoseScheduleResponse(courseID, steps))
+	}
+}
+
+// HandleUpdateDoseSchedule replaces a course's taper schedule
+func HandleUpdateDoseSchedule(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		courseID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid course ID", http.StatusBadRequest)
+			return
+		}
+
+		var req DoseScheduleRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		steps, field := parseDoseSteps(req.Steps)
+		if field != nil {
+			respond.Validation(w, "Invalid taper schedule: "+field.Message, *field)
+			return
+		}
+
+		if err := repository.NewDoseScheduleRepository(db).Replace(courseID, accountID, steps); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Course not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to save taper schedule", http.StatusInternalServerError)
+			return
+		}
+
+		resp := toDoseScheduleResponse(courseID, steps)
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update_taper",
+			"course",
+			sql.NullInt64{Int64: courseID, Valid: true},
+			map[string]interface{}{"steps": resp.Steps},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
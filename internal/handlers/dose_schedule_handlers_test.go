@@ -0,0 +1,39 @@
+package handlers
+
+import "testing"
+
+func TestParseDoseSteps(t *testing.T) {
+	steps, field := parseDoseSteps([]DoseStepRequest{
+		{StartDate: "2026-03-15", DoseML: 0.5},
+		{StartDate: "2026-03-01", EndDate: "2026-03-14", DoseML: 1},
+	})
+	if field != nil {
+		t.Fatalf("Expected a valid schedule, got %+v", field)
+	}
+	if len(steps) != 2 || steps[0].DoseML != 1 || steps[1].EndDate.Valid {
+		t.Errorf("Expected the steps in date order, got %+v", steps)
+	}
+
+	tests := []struct {
+		name  string
+		steps []DoseStepRequest
+		field string
+	}{
+		{"bad date", []DoseStepRequest{{StartDate: "03/01/2026", DoseML: 1}}, "steps[0].start_date"},
+		{"end before start", []DoseStepRequest{{StartDate: "2026-03-10", EndDate: "2026-03-01", DoseML: 1}}, "steps[0].end_date"},
+		{"no dose", []DoseStepRequest{{StartDate: "2026-03-01"}}, "steps[0].dose_ml"},
+		{"overlap", []DoseStepRequest{
+			{StartDate: "2026-03-01", EndDate: "2026-03-14", DoseML: 1},
+			{StartDate: "2026-03-14", DoseML: 0.5},
+		}, "steps"},
+		{"open-ended step followed by another", []DoseStepRequest{
+			{StartDate: "2026-03-01", DoseML: 1},
+			{StartDate: "2026-04-01", DoseML: 0.5},
+		}, "steps"},
+	}
+	for _, tt := range tests {
+		if _, field := parseDoseSteps(tt.steps); field == nil || field.Field != tt.field {
+			t.Errorf("%s: expected an error on %s, got %+v", tt.name, tt.field, field)
+		}
+	}
+}
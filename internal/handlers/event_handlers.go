@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+const (
+	// eventRetryMillis is how long browsers wait before reconnecting
+	eventRetryMillis = 3000
+	// eventHeartbeat keeps idle streams open through proxies
+	eventHeartbeat = 25 * time.Second
+	// eventTimeoutMargin ends a stream this long before the request times
+	// out, so it closes cleanly and the browser reconnects
+	eventTimeoutMargin = 5 * time.Second
+)
+
+// liveEvents carries change events between the sessions of an account
+var liveEvents = services.NewEventBroker()
+
+// publishLiveEvent tells the account's open sessions about a change
+func publishLiveEvent(accountID int64, event string, data interface{}) {
+	if accountID == 0 {
+		return
+	}
+	liveEvents.Publish(accountID, event, data)
+}
+
+// publishInventoryAdjusted reports a change in an item's quantity
+func publishInventoryAdjusted(accountID int64, item *models.InventoryItem, quantityBefore float64) {
+	publishLiveEvent(accountID, services.LiveInventoryAdjusted, map[string]interface{}{
+		"item_type":       item.ItemType,
+		"quantity":        item.Quantity,
+		"unit":            item.Unit,
+		"quantity_before": quantityBefore,
+	})
+}
+
+// HandleEvents streams the account's change events as server-sent events.
+// Clients reconnecting with Last-Event-ID (or last_event_id in the query)
+// get the events they missed, or a resync event when those are gone.
+func HandleEvents(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("last_event_id")
+		}
+		var lastEventID int64
+		if lastID != "" {
+			var err error
+			lastEventID, err = strconv.ParseInt(lastID, 10, 64)
+			if err != nil || lastEventID < 0 {
+				respond.Validation(w, "", respond.Field("Last-Event-ID", "must be an event id"))
+				return
+			}
+		}
+
+		sub := liveEvents.Subscribe(accountID, lastEventID)
+		defer sub.Close()
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		_, _ = fmt.Fprintf(w, "retry: %d\n\n", eventRetryMillis)
+		if sub.Resync {
+			_, _ = fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+		}
+		for _, e := range sub.Missed {
+			writeLiveEvent(w, e)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		var deadline <-chan time.Time
+		if d, ok := r.Context().Deadline(); ok {
+			timer := time.NewTimer(time.Until(d) - eventTimeoutMargin)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+		heartbeat := time.NewTicker(eventHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-deadline:
+				return
+			case <-services.ShutdownChan():
+				// Let the server stop; the browser reconnects to its
+				// replacement
+				return
+			case <-requestGate.Draining():
+				// A restore is waiting for requests to finish; the browser
+				// reconnects once it is done and is told to resync
+				return
+			case <-heartbeat.C:
+				_, _ = fmt.Fprint(w, ": ping\n\n")
+			case e, ok := <-sub.C:
+				if !ok {
+					// Fell behind; the browser reconnects and catches up
+					return
+				}
+				writeLiveEvent(w, e)
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeLiveEvent writes one event in text/event-stream format
+func writeLiveEvent(w http.ResponseWriter, e services.LiveEvent) {
+	_, _ = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+}
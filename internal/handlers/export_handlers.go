@@ -2,14 +2,29 @@ package handlers
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
 
 	"github.com/jung-kurt/gofpdf/v2"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 )
 
 // ExportData represents the data structure for exports
@@ -17,10 +32,20 @@ type ExportData struct {
 	Injections  []ExportInjection
 	Symptoms    []ExportSymptom
 	Medications []ExportMedication
+	CheckIns    []ExportCheckIn
+	Journal     []ExportJournalEntry
+	Labs        []ExportLabResult
+	Missed      []ExportMissedInjection
+	Trips       []*models.Trip // Overlapping the date range, earliest first
 	StartDate   time.Time
 	EndDate     time.Time
 	CourseID    int64
 	CourseName  string
+
+	// Only filled in for the PDF report, see gatherReportData
+	Previous *ExportData             // The same length of time just before StartDate
+	Courses  []services.CoursePeriod // When courses ran, for adherence
+	Location *time.Location          // Timezone times are shown in
 }
 
 // ExportInjection represents an injection for export
@@ -29,10 +54,38 @@ type ExportInjection struct {
 	Timestamp      time.Time
 	Side           string
 	PainLevel      int
+	HasPain        bool // PainLevel was recorded rather than defaulted to 0
 	HasKnots       bool
 	SiteReaction   string
 	Notes          string
 	AdministeredBy string
+	DoseML         float64
+	DoseMG         sql.NullFloat64 // Set when the course has a concentration
+	Compound       string          // Empty when the course has no compound
+	Route          string
+	CourseID       int64
+
+	// The trip the injection was given on, if any, and when that was on the
+	// destination's clock
+	Trip      string
+	TripLocal time.Time
+
+	// The taper schedule's dose for the day; only filled in for the PDF report
+	ScheduledDoseML sql.NullFloat64
+}
+
+// travelNote says which trip an injection was given on and the local time
+// there; it is empty for one given at home
+func travelNote(p *i18n.Printer, inj ExportInjection) string {
+	if inj.Trip == "" {
+		return ""
+	}
+	return p.T("%s, %s local time", inj.Trip, inj.TripLocal.Format("2006-01-02 15:04 MST"))
+}
+
+// OffSchedule reports whether the dose given differs from the taper schedule
+func (inj ExportInjection) OffSchedule() bool {
+	return inj.ScheduledDoseML.Valid && math.Abs(inj.DoseML-inj.ScheduledDoseML.Float64) >= 0.005
 }
 
 // ExportSymptom represents a symptom for export
@@ -40,6 +93,7 @@ type ExportSymptom struct {
 	ID           int64
 	Timestamp    time.Time
 	PainLevel    int
+	HasPain      bool
 	PainLocation string
 	PainType     string
 	Symptoms     string
@@ -51,61 +105,111 @@ type ExportMedication struct {
 	ID             int64
 	Timestamp      time.Time
 	MedicationName string
+	Dosage         string
 	Taken          bool
 	Notes          string
 }
 
+// ExportCheckIn represents a daily wellness check-in for export
+type ExportCheckIn struct {
+	ID          int64
+	Date        time.Time // Calendar date, at midnight UTC
+	Member      string
+	Mood        sql.NullInt64
+	Energy      sql.NullInt64
+	SleepHours  sql.NullFloat64
+	Temperature sql.NullFloat64 // degC
+	Weight      sql.NullFloat64 // kg
+	Notes       string
+}
+
+// ExportJournalEntry represents a journal entry for export
+type ExportJournalEntry struct {
+	ID        int64
+	CreatedAt time.Time
+	EntryDate sql.NullTime // Calendar date, at midnight UTC
+	Author    string
+	Course    string
+	Title     string
+	Body      string
+	Tags      string // Comma-separated
+}
+
+// ExportLabResult represents a lab result for export, with the dose given
+// before the draw
+type ExportLabResult struct {
+	ID            int64
+	DrawnAt       time.Time
+	Analyte       string
+	Value         float64
+	Unit          string
+	ReferenceLow  sql.NullFloat64
+	ReferenceHigh sql.NullFloat64
+	Flag          string // low, high or empty
+	Course        string
+	Notes         string
+	PriorDoseAt   sql.NullTime
+	PriorDoseML   sql.NullFloat64
+}
+
+// ExportMissedInjection represents a missed dose for export, with why it
+// was skipped if anyone has said
+type ExportMissedInjection struct {
+	ID          int64
+	DueAt       time.Time
+	Course      string
+	Reason      string // One of models.MissedInjectionReasons, or empty
+	Note        string
+	AnnotatedBy string
+}
+
+// missedReasonLabel names why a missed dose was skipped, in p's language
+func missedReasonLabel(p *i18n.Printer, reason string) string {
+	switch reason {
+	case "clinic_instruction":
+		return p.T("Clinic instruction")
+	case "travel":
+		return p.T("Travel")
+	case "forgot":
+		return p.T("Forgot")
+	case "side_effects":
+		return p.T("Side effects")
+	case "out_of_supply":
+		return p.T("Out of supply")
+	case "other":
+		return p.T("Other")
+	}
+	return p.T("No reason given")
+}
+
 // HandleExportPDF generates a PDF report with injection and symptom data
 func HandleExportPDF(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse query parameters
-		startDate := r.URL.Query().Get("start_date")
-		endDate := r.URL.Query().Get("end_date")
+		db := db.WithContext(r.Context())
 		courseID := r.URL.Query().Get("course_id")
-
-		// Validate date parameters
-		var start, end time.Time
-		var err error
-
-		if startDate != "" {
-			start, err = time.Parse("2006-01-02", startDate)
-			if err != nil {
-				http.Error(w, "Invalid start_date format. Use YYYY-MM-DD", http.StatusBadRequest)
-				return
-			}
-		} else {
-			// Default to 30 days ago
-			start = time.Now().AddDate(0, 0, -30)
-		}
-
-		if endDate != "" {
-			end, err = time.Parse("2006-01-02", endDate)
-			if err != nil {
-				http.Error(w, "Invalid end_date format. Use YYYY-MM-DD", http.StatusBadRequest)
-				return
-			}
-		} else {
-			// Default to today
-			end = time.Now()
+		start, end, err := parseExportRange(r)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		// Ensure end is after start
-		if end.Before(start) {
-			http.Error(w, "end_date must be after start_date", http.StatusBadRequest)
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
 			return
 		}
 
-		// Gather export data
-		exportData, err := gatherExportData(db, start, end, courseID)
+		// Gather export data, with the previous period for comparison
+		loc := userLocation(db, middleware.GetUserID(r.Context()))
+		exportData, err := gatherReportData(db, middleware.GetAccountID(r.Context()), viewer, start, end, courseID, loc)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to gather export data: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to gather export data: %v", err), http.StatusInternalServerError)
 			return
 		}
 
 		// Generate PDF
-		pdfBytes, err := generatePDF(exportData)
+		pdfBytes, err := generatePDF(exportData, getSiteSettings(db), i18n.FromContext(r.Context()))
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to generate PDF: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to generate PDF: %v", err), http.StatusInternalServerError)
 			return
 		}
 
@@ -123,9 +227,7 @@ func HandleExportPDF(db *database.DB) http.HandlerFunc {
 // HandleExportCSV generates CSV export of injection, symptom, and medication data
 func HandleExportCSV(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse query parameters
-		startDate := r.URL.Query().Get("start_date")
-		endDate := r.URL.Query().Get("end_date")
+		db := db.WithContext(r.Context())
 		courseID := r.URL.Query().Get("course_id")
 		dataType := r.URL.Query().Get("type") // "injections", "symptoms", "medications", or "all"
 
@@ -133,65 +235,59 @@ func HandleExportCSV(db *database.DB) http.HandlerFunc {
 			dataType = "all"
 		}
 
-		// Validate date parameters
-		var start, end time.Time
-		var err error
-
-		if startDate != "" {
-			start, err = time.Parse("2006-01-02", startDate)
-			if err != nil {
-				http.Error(w, "Invalid start_date format. Use YYYY-MM-DD", http.StatusBadRequest)
-				return
-			}
-		} else {
-			// Default to 30 days ago
-			start = time.Now().AddDate(0, 0, -30)
+		start, end, err := parseExportRange(r)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		if endDate != "" {
-			end, err = time.Parse("2006-01-02", endDate)
-			if err != nil {
-				http.Error(w, "Invalid end_date format. Use YYYY-MM-DD", http.StatusBadRequest)
-				return
-			}
-		} else {
-			// Default to today
-			end = time.Now()
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
+			return
 		}
 
 		// Gather export data
-		exportData, err := gatherExportData(db, start, end, courseID)
+		exportData, err := gatherExportData(db, middleware.GetAccountID(r.Context()), viewer, start, end, courseID)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to gather export data: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to gather export data: %v", err), http.StatusInternalServerError)
 			return
 		}
 
-		// Generate CSV
+		// Generate CSV, headed in the reader's language
+		p := i18n.FromContext(r.Context())
 		var csvBuffer bytes.Buffer
 		csvWriter := csv.NewWriter(&csvBuffer)
 
 		switch dataType {
 		case "injections":
-			err = writeInjectionsCSV(csvWriter, exportData.Injections)
+			err = writeInjectionsCSV(csvWriter, p, exportData.Injections)
 		case "symptoms":
-			err = writeSymptomsCSV(csvWriter, exportData.Symptoms)
+			err = writeSymptomsCSV(csvWriter, p, exportData.Symptoms)
 		case "medications":
-			err = writeMedicationsCSV(csvWriter, exportData.Medications)
+			err = writeMedicationsCSV(csvWriter, p, exportData.Medications)
+		case "checkins":
+			err = writeCheckInsCSV(csvWriter, p, exportData.CheckIns)
+		case "journal":
+			err = writeJournalCSV(csvWriter, p, exportData.Journal)
+		case "labs":
+			err = writeLabsCSV(csvWriter, p, exportData.Labs)
+		case "missed":
+			err = writeMissedCSV(csvWriter, p, exportData.Missed)
 		case "all":
-			err = writeAllDataCSV(csvWriter, exportData)
+			err = writeAllDataCSV(csvWriter, p, exportData)
 		default:
-			http.Error(w, "Invalid type parameter. Use: injections, symptoms, medications, or all", http.StatusBadRequest)
+			respond.Error(w, "Invalid type parameter. Use: injections, symptoms, medications, checkins, journal, labs, missed, or all", http.StatusBadRequest)
 			return
 		}
 
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to generate CSV: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to generate CSV: %v", err), http.StatusInternalServerError)
 			return
 		}
 
 		csvWriter.Flush()
 		if err := csvWriter.Error(); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to flush CSV writer: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to flush CSV writer: %v", err), http.StatusInternalServerError)
 			return
 		}
 
@@ -206,8 +302,128 @@ func HandleExportCSV(db *database.DB) http.HandlerFunc {
 	}
 }
 
+// HandleExportXLSX generates an Excel workbook with a sheet each for
+// injections, symptoms, medication logs and current inventory
+func HandleExportXLSX(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		courseID := r.URL.Query().Get("course_id")
+
+		start, end, err := parseExportRange(r)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
+			return
+		}
+
+		exportData, err := gatherExportData(db, accountID, viewer, start, end, courseID)
+		if err != nil {
+			respond.Error(w, fmt.Sprintf("Failed to gather export data: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		inventory, err := repository.NewInventoryRepository(db).List(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to gather inventory", http.StatusInternalServerError)
+			return
+		}
+
+		var buf bytes.Buffer
+		workbook := buildExportWorkbook(exportData, inventory, userLocation(db, userID), i18n.FromContext(r.Context()))
+		if err := workbook.write(&buf); err != nil {
+			respond.Error(w, fmt.Sprintf("Failed to generate XLSX: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		filename := fmt.Sprintf("injection-tracker-%s-to-%s.xlsx", start.Format("2006-01-02"), end.Format("2006-01-02"))
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", buf.Len()))
+
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// HandleExportFHIR exports injections, medication logs and symptom logs as a
+// FHIR R4 Bundle for patient portals and other health record tools
+func HandleExportFHIR(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		courseID := r.URL.Query().Get("course_id")
+
+		start, end, err := parseExportRange(r)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
+			return
+		}
+
+		exportData, err := gatherExportData(db, accountID, viewer, start, end, courseID)
+		if err != nil {
+			respond.Error(w, fmt.Sprintf("Failed to gather export data: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		account, err := repository.NewAccountRepository(db.DB).GetByID(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to get account", http.StatusInternalServerError)
+			return
+		}
+
+		body, err := json.MarshalIndent(buildFHIRBundle(exportData, accountID, account.Name.String, time.Now()), "", "  ")
+		if err != nil {
+			respond.Error(w, fmt.Sprintf("Failed to generate FHIR bundle: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		filename := fmt.Sprintf("injection-tracker-fhir-%s-to-%s.json", start.Format("2006-01-02"), end.Format("2006-01-02"))
+		w.Header().Set("Content-Type", "application/fhir+json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
+
+		_, _ = w.Write(body)
+	}
+}
+
+// parseExportRange reads the start_date and end_date query parameters,
+// defaulting to the last 30 days
+func parseExportRange(r *http.Request) (time.Time, time.Time, error) {
+	start := time.Now().AddDate(0, 0, -30)
+	end := time.Now()
+
+	var err error
+	if startDate := r.URL.Query().Get("start_date"); startDate != "" {
+		start, err = time.Parse("2006-01-02", startDate)
+		if err != nil {
+			return start, end, errors.New("Invalid start_date format. Use YYYY-MM-DD")
+		}
+	}
+	if endDate := r.URL.Query().Get("end_date"); endDate != "" {
+		end, err = time.Parse("2006-01-02", endDate)
+		if err != nil {
+			return start, end, errors.New("Invalid end_date format. Use YYYY-MM-DD")
+		}
+	}
+
+	if end.Before(start) {
+		return start, end, errors.New("end_date must be after start_date")
+	}
+	return start, end, nil
+}
+
 // gatherExportData collects all data needed for export
-func gatherExportData(db *database.DB, start, end time.Time, courseIDStr string) (*ExportData, error) {
+func gatherExportData(db *database.DB, accountID int64, viewer repository.SymptomViewer, start, end time.Time, courseIDStr string) (*ExportData, error) {
 	data := &ExportData{
 		StartDate: start,
 		EndDate:   end,
@@ -222,7 +438,7 @@ func gatherExportData(db *database.DB, start, end time.Time, courseIDStr string)
 		args = append(args, courseIDStr)
 
 		// Get course name
-		err := db.QueryRow("SELECT id, name FROM courses WHERE id = ?", courseIDStr).Scan(&data.CourseID, &data.CourseName)
+		err := db.QueryRow("SELECT id, name FROM courses WHERE id = ? AND account_id = ?", courseIDStr, accountID).Scan(&data.CourseID, &data.CourseName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get course: %w", err)
 		}
@@ -232,15 +448,25 @@ func gatherExportData(db *database.DB, start, end time.Time, courseIDStr string)
 	injectionQuery := `
 		SELECT i.id, i.timestamp, i.side,
 			COALESCE(i.pain_level, 0) as pain_level,
+			i.pain_level IS NOT NULL as has_pain,
 			i.has_knots,
 			COALESCE(i.site_reaction, '') as site_reaction,
 			COALESCE(i.notes, '') as notes,
-			COALESCE(u.username, '') as administered_by
+			COALESCE(u.username, '') as administered_by,
+			COALESCE(i.dose_ml, ?) as dose_ml,
+			COALESCE(i.dose_ml, ?) * COALESCE(c.concentration_mg_per_ml, m.concentration_mg_per_ml) as dose_mg,
+			COALESCE(m.name, '') as compound,
+			COALESCE(m.route, '') as route,
+			i.course_id
 		FROM injections i
 		LEFT JOIN users u ON i.administered_by = u.id
-	` + whereClause + " ORDER BY i.timestamp DESC"
+		LEFT JOIN courses c ON c.id = i.course_id
+		LEFT JOIN compounds m ON m.id = c.compound_id
+	` + whereClause + " AND c.account_id = ? AND i.voided_at IS NULL ORDER BY i.timestamp DESC"
 
-	rows, err := db.Query(injectionQuery, args...)
+	injectionArgs := append([]interface{}{defaultDoseML, defaultDoseML}, args...)
+	injectionArgs = append(injectionArgs, accountID)
+	rows, err := db.Query(injectionQuery, injectionArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query injections: %w", err)
 	}
@@ -253,10 +479,16 @@ func gatherExportData(db *database.DB, start, end time.Time, courseIDStr string)
 			&inj.Timestamp,
 			&inj.Side,
 			&inj.PainLevel,
+			&inj.HasPain,
 			&inj.HasKnots,
 			&inj.SiteReaction,
 			&inj.Notes,
 			&inj.AdministeredBy,
+			&inj.DoseML,
+			&inj.DoseMG,
+			&inj.Compound,
+			&inj.Route,
+			&inj.CourseID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan injection: %w", err)
@@ -264,18 +496,20 @@ func gatherExportData(db *database.DB, start, end time.Time, courseIDStr string)
 		data.Injections = append(data.Injections, inj)
 	}
 
-	// Gather symptoms
+	// Gather the symptoms viewer may see
+	visible, visibleArgs := viewer.Condition("")
 	symptomQuery := `
 		SELECT id, timestamp,
 			COALESCE(pain_level, 0) as pain_level,
+			pain_level IS NOT NULL as has_pain,
 			COALESCE(pain_location, '') as pain_location,
 			COALESCE(pain_type, '') as pain_type,
 			COALESCE(symptoms, '') as symptoms,
 			COALESCE(notes, '') as notes
 		FROM symptom_logs
-	` + whereClause + " ORDER BY timestamp DESC"
+	` + whereClause + " AND course_id IN (SELECT id FROM courses WHERE account_id = ?) AND " + visible + " ORDER BY timestamp DESC"
 
-	rows, err = db.Query(symptomQuery, args...)
+	rows, err = db.Query(symptomQuery, append(append(args, accountID), visibleArgs...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query symptoms: %w", err)
 	}
@@ -287,6 +521,7 @@ func gatherExportData(db *database.DB, start, end time.Time, courseIDStr string)
 			&sym.ID,
 			&sym.Timestamp,
 			&sym.PainLevel,
+			&sym.HasPain,
 			&sym.PainLocation,
 			&sym.PainType,
 			&sym.Symptoms,
@@ -300,13 +535,14 @@ func gatherExportData(db *database.DB, start, end time.Time, courseIDStr string)
 
 	// Gather medication logs
 	medicationQuery := `
-		SELECT ml.id, ml.timestamp, m.name as medication_name, ml.taken,
+		SELECT ml.id, ml.timestamp, m.name as medication_name,
+			COALESCE(m.dosage, '') as dosage, ml.taken,
 			COALESCE(ml.notes, '') as notes
 		FROM medication_logs ml
 		JOIN medications m ON ml.medication_id = m.id
-	` + whereClause + " ORDER BY ml.timestamp DESC"
+	` + whereClause + " AND m.account_id = ? ORDER BY ml.timestamp DESC"
 
-	rows, err = db.Query(medicationQuery, args...)
+	rows, err = db.Query(medicationQuery, append(args, accountID)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query medication logs: %w", err)
 	}
@@ -318,6 +554,7 @@ func gatherExportData(db *database.DB, start, end time.Time, courseIDStr string)
 			&med.ID,
 			&med.Timestamp,
 			&med.MedicationName,
+			&med.Dosage,
 			&med.Taken,
 			&med.Notes,
 		)
@@ -327,22 +564,293 @@ func gatherExportData(db *database.DB, start, end time.Time, courseIDStr string)
 		data.Medications = append(data.Medications, med)
 	}
 
+	// Gather check-ins, which belong to the account rather than a course,
+	// for the days the range touches
+	rows, err = db.Query(`
+		SELECT w.id, w.date, COALESCE(u.username, ''), w.mood, w.energy, w.sleep_hours,
+			w.temperature, w.weight, COALESCE(w.notes, '')
+		FROM wellness_checkins w
+		LEFT JOIN users u ON u.id = w.user_id
+		WHERE w.account_id = ? AND w.date >= ? AND w.date <= ?
+		ORDER BY w.date DESC, w.id DESC
+	`, accountID, repository.CheckInDate(start), repository.CheckInDate(end))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query check-ins: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c ExportCheckIn
+		err := rows.Scan(&c.ID, &c.Date, &c.Member, &c.Mood, &c.Energy, &c.SleepHours, &c.Temperature, &c.Weight, &c.Notes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan check-in: %w", err)
+		}
+		data.CheckIns = append(data.CheckIns, c)
+	}
+
+	// Gather journal entries written in the range; with a course, only those
+	// linked to it
+	journalQuery := `
+		SELECT j.id, j.created_at, j.entry_date, COALESCE(u.username, ''), COALESCE(c.name, ''),
+			COALESCE(j.title, ''), j.body
+		FROM journal_entries j
+		LEFT JOIN users u ON u.id = j.author_id
+		LEFT JOIN courses c ON c.id = j.course_id
+		WHERE j.account_id = ? AND j.created_at BETWEEN ? AND ?`
+	journalArgs := []interface{}{accountID, start, end}
+	if courseIDStr != "" {
+		journalQuery += " AND j.course_id = ?"
+		journalArgs = append(journalArgs, courseIDStr)
+	}
+	rows, err = db.Query(journalQuery+" ORDER BY j.created_at DESC, j.id DESC", journalArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query journal entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var j ExportJournalEntry
+		if err := rows.Scan(&j.ID, &j.CreatedAt, &j.EntryDate, &j.Author, &j.Course, &j.Title, &j.Body); err != nil {
+			return nil, fmt.Errorf("failed to scan journal entry: %w", err)
+		}
+		data.Journal = append(data.Journal, j)
+	}
+	if len(data.Journal) > 0 {
+		rows, err = db.Query(`
+			SELECT t.entry_id, t.tag
+			FROM journal_entry_tags t
+			JOIN journal_entries j ON j.id = t.entry_id
+			WHERE j.account_id = ? AND j.created_at BETWEEN ? AND ?
+			ORDER BY t.tag
+		`, accountID, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query journal tags: %w", err)
+		}
+		defer rows.Close()
+
+		tags := make(map[int64][]string)
+		for rows.Next() {
+			var entryID int64
+			var tag string
+			if err := rows.Scan(&entryID, &tag); err != nil {
+				return nil, fmt.Errorf("failed to scan journal tag: %w", err)
+			}
+			tags[entryID] = append(tags[entryID], tag)
+		}
+		for i := range data.Journal {
+			data.Journal[i].Tags = strings.Join(tags[data.Journal[i].ID], ", ")
+		}
+	}
+
+	// Gather lab results drawn in the range; with a course, those linked to
+	// it and those not linked to any
+	labQuery := `
+		SELECT l.id, l.drawn_at, l.analyte, l.value, l.unit, l.reference_low, l.reference_high,
+			COALESCE(c.name, ''), COALESCE(l.notes, '')
+		FROM lab_results l
+		LEFT JOIN courses c ON c.id = l.course_id
+		WHERE l.account_id = ? AND l.drawn_at BETWEEN ? AND ?`
+	labArgs := []interface{}{accountID, start, end}
+	if courseIDStr != "" {
+		labQuery += " AND (l.course_id = ? OR l.course_id IS NULL)"
+		labArgs = append(labArgs, courseIDStr)
+	}
+	rows, err = db.Query(labQuery+" ORDER BY l.drawn_at DESC, l.id DESC", labArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lab results: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l ExportLabResult
+		if err := rows.Scan(&l.ID, &l.DrawnAt, &l.Analyte, &l.Value, &l.Unit, &l.ReferenceLow, &l.ReferenceHigh, &l.Course, &l.Notes); err != nil {
+			return nil, fmt.Errorf("failed to scan lab result: %w", err)
+		}
+		l.Flag = (&models.LabResult{Value: l.Value, ReferenceLow: l.ReferenceLow, ReferenceHigh: l.ReferenceHigh}).Flag()
+		data.Labs = append(data.Labs, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lab results: %w", err)
+	}
+	labRepo := repository.NewLabResultRepository(db)
+	for i := range data.Labs {
+		dose, err := labRepo.PriorDose(accountID, data.Labs[i].DrawnAt)
+		if err == repository.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data.Labs[i].PriorDoseAt = sql.NullTime{Time: dose.InjectedAt, Valid: true}
+		data.Labs[i].PriorDoseML = sql.NullFloat64{Float64: dose.DoseML, Valid: true}
+	}
+
+	// Gather doses missed in the range and why they were skipped
+	missedQuery := `
+		SELECT m.id, m.due_at, c.name, COALESCE(m.reason, ''), COALESCE(m.note, ''), COALESCE(u.username, '')
+		FROM missed_injections m
+		JOIN courses c ON c.id = m.course_id
+		LEFT JOIN users u ON u.id = m.annotated_by
+		WHERE c.account_id = ? AND m.due_at BETWEEN ? AND ?`
+	missedArgs := []interface{}{accountID, start.UTC(), end.UTC()}
+	if courseIDStr != "" {
+		missedQuery += " AND m.course_id = ?"
+		missedArgs = append(missedArgs, courseIDStr)
+	}
+	rows, err = db.Query(missedQuery+" ORDER BY m.due_at DESC, m.id DESC", missedArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query missed injections: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m ExportMissedInjection
+		if err := rows.Scan(&m.ID, &m.DueAt, &m.Course, &m.Reason, &m.Note, &m.AnnotatedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan missed injection: %w", err)
+		}
+		data.Missed = append(data.Missed, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read missed injections: %w", err)
+	}
+
+	// Mark the injections given on a trip
+	data.Trips, err = repository.NewTripRepository(db).Between(accountID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for i := range data.Injections {
+		inj := &data.Injections[i]
+		for _, trip := range data.Trips {
+			if !trip.Covers(inj.Timestamp) {
+				continue
+			}
+			inj.Trip = trip.Destination
+			inj.TripLocal = inj.Timestamp
+			if loc, err := time.LoadLocation(trip.Timezone); err == nil {
+				inj.TripLocal = inj.Timestamp.In(loc)
+			}
+			break
+		}
+	}
+
+	return data, nil
+}
+
+// gatherReportData collects export data for start to end along with the
+// same length of time before it and the account's course dates and
+// schedules, which the PDF report needs for its comparisons and adherence
+// chart
+func gatherReportData(db *database.DB, accountID int64, viewer repository.SymptomViewer, start, end time.Time, courseIDStr string, loc *time.Location) (*ExportData, error) {
+	data, err := gatherExportData(db, accountID, viewer, start, end, courseIDStr)
+	if err != nil {
+		return nil, err
+	}
+	data.Previous, err = gatherExportData(db, accountID, viewer, start.Add(-end.Sub(start)), start, courseIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules, err := repository.NewCourseScheduleRepository(db).ListByAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT id, start_date, actual_end_date FROM courses WHERE account_id = ?"
+	args := []interface{}{accountID}
+	if courseIDStr != "" {
+		query += " AND id = ?"
+		args = append(args, courseIDStr)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query courses: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var period services.CoursePeriod
+		var id int64
+		var endDate sql.NullTime
+		if err := rows.Scan(&id, &period.Start, &endDate); err != nil {
+			return nil, fmt.Errorf("failed to scan course: %w", err)
+		}
+		if endDate.Valid {
+			period.End = endDate.Time
+		}
+		period.Schedule = schedules[id]
+		data.Courses = append(data.Courses, period)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read courses: %w", err)
+	}
+
+	data.Location = loc
+	data.Previous.Courses = data.Courses
+	data.Previous.Location = loc
+
+	steps := map[int64][]*models.DoseStep{}
+	for _, d := range []*ExportData{data, data.Previous} {
+		if err := applyDoseSchedules(db, accountID, d, steps); err != nil {
+			return nil, err
+		}
+	}
 	return data, nil
 }
 
+// applyDoseSchedules fills in each injection's dose from its course's taper
+// schedule, on the day it was given in the report's timezone. schedules caches the
+// steps by course between calls.
+func applyDoseSchedules(db *database.DB, accountID int64, data *ExportData, schedules map[int64][]*models.DoseStep) error {
+	repo := repository.NewDoseScheduleRepository(db)
+	for i := range data.Injections {
+		inj := &data.Injections[i]
+		steps, ok := schedules[inj.CourseID]
+		if !ok {
+			var err error
+			steps, err = repo.List(inj.CourseID, accountID)
+			if err != nil {
+				return fmt.Errorf("failed to get taper schedule: %w", err)
+			}
+			schedules[inj.CourseID] = steps
+		}
+		if dose, ok := repository.ScheduledDoseML(steps, inj.Timestamp.In(exportLocation(data))); ok {
+			inj.ScheduledDoseML = sql.NullFloat64{Float64: dose, Valid: true}
+		}
+	}
+	return nil
+}
+
+// formatTotalDose sums injected volume, adding mg when every injection has it
+func formatTotalDose(injections []ExportInjection) string {
+	var totalML, totalMG float64
+	allMG := len(injections) > 0
+	for _, inj := range injections {
+		totalML += inj.DoseML
+		if inj.DoseMG.Valid {
+			totalMG += inj.DoseMG.Float64
+		} else {
+			allMG = false
+		}
+	}
+	if allMG {
+		return fmt.Sprintf("%s mL (%s mg)", formatDose(totalML), formatDose(totalMG))
+	}
+	return formatDose(totalML) + " mL"
+}
+
 // writeInjectionsCSV writes injection data to CSV
-func writeInjectionsCSV(writer *csv.Writer, injections []ExportInjection) error {
+func writeInjectionsCSV(writer *csv.Writer, p *i18n.Printer, injections []ExportInjection) error {
 	// Write header
-	header := []string{"ID", "Date", "Time", "Side", "Pain Level", "Has Knots", "Site Reaction", "Notes", "Administered By"}
+	header := translateAll(p, "ID", "Date", "Time", "Side", "Dose (mL)", "Dose (mg)", "Pain Level", "Has Knots", "Site Reaction", "Notes", "Administered By", "Travel")
 	if err := writer.Write(header); err != nil {
 		return err
 	}
 
 	// Write data
 	for _, inj := range injections {
-		hasKnots := "No"
-		if inj.HasKnots {
-			hasKnots = "Yes"
+		doseMG := ""
+		if inj.DoseMG.Valid {
+			doseMG = formatDose(inj.DoseMG.Float64)
 		}
 
 		row := []string{
@@ -350,11 +858,14 @@ func writeInjectionsCSV(writer *csv.Writer, injections []ExportInjection) error
 			inj.Timestamp.Format("2006-01-02"),
 			inj.Timestamp.Format("15:04:05"),
 			inj.Side,
+			formatDose(inj.DoseML),
+			doseMG,
 			fmt.Sprintf("%d", inj.PainLevel),
-			hasKnots,
+			yesNo(p, inj.HasKnots),
 			inj.SiteReaction,
 			inj.Notes,
 			inj.AdministeredBy,
+			travelNote(p, inj),
 		}
 		if err := writer.Write(row); err != nil {
 			return err
@@ -365,9 +876,9 @@ func writeInjectionsCSV(writer *csv.Writer, injections []ExportInjection) error
 }
 
 // writeSymptomsCSV writes symptom data to CSV
-func writeSymptomsCSV(writer *csv.Writer, symptoms []ExportSymptom) error {
+func writeSymptomsCSV(writer *csv.Writer, p *i18n.Printer, symptoms []ExportSymptom) error {
 	// Write header
-	header := []string{"ID", "Date", "Time", "Pain Level", "Pain Location", "Pain Type", "Symptoms", "Notes"}
+	header := translateAll(p, "ID", "Date", "Time", "Pain Level", "Pain Location", "Pain Type", "Symptoms", "Notes")
 	if err := writer.Write(header); err != nil {
 		return err
 	}
@@ -393,26 +904,21 @@ func writeSymptomsCSV(writer *csv.Writer, symptoms []ExportSymptom) error {
 }
 
 // writeMedicationsCSV writes medication data to CSV
-func writeMedicationsCSV(writer *csv.Writer, medications []ExportMedication) error {
+func writeMedicationsCSV(writer *csv.Writer, p *i18n.Printer, medications []ExportMedication) error {
 	// Write header
-	header := []string{"ID", "Date", "Time", "Medication", "Taken", "Notes"}
+	header := translateAll(p, "ID", "Date", "Time", "Medication", "Taken", "Notes")
 	if err := writer.Write(header); err != nil {
 		return err
 	}
 
 	// Write data
 	for _, med := range medications {
-		taken := "No"
-		if med.Taken {
-			taken = "Yes"
-		}
-
 		row := []string{
 			fmt.Sprintf("%d", med.ID),
 			med.Timestamp.Format("2006-01-02"),
 			med.Timestamp.Format("15:04:05"),
 			med.MedicationName,
-			taken,
+			yesNo(p, med.Taken),
 			med.Notes,
 		}
 		if err := writer.Write(row); err != nil {
@@ -423,110 +929,952 @@ func writeMedicationsCSV(writer *csv.Writer, medications []ExportMedication) err
 	return nil
 }
 
-// writeAllDataCSV writes all data types to a single CSV with sections
-func writeAllDataCSV(writer *csv.Writer, data *ExportData) error {
-	// Write report header
-	if err := writer.Write([]string{"Progesterone Injection Tracker - Complete Export"}); err != nil {
-		return err
-	}
-	if err := writer.Write([]string{fmt.Sprintf("Report Period: %s to %s", data.StartDate.Format("2006-01-02"), data.EndDate.Format("2006-01-02"))}); err != nil {
+// writeCheckInsCSV writes wellness check-ins to CSV
+func writeCheckInsCSV(writer *csv.Writer, p *i18n.Printer, checkIns []ExportCheckIn) error {
+	header := translateAll(p, "ID", "Date", "Member", "Mood", "Energy", "Sleep (hours)", "Temperature (C)", "Weight (kg)", "Notes")
+	if err := writer.Write(header); err != nil {
 		return err
 	}
-	if data.CourseName != "" {
-		if err := writer.Write([]string{fmt.Sprintf("Course: %s", data.CourseName)}); err != nil {
+
+	for _, c := range checkIns {
+		row := []string{
+			fmt.Sprintf("%d", c.ID),
+			c.Date.UTC().Format("2006-01-02"),
+			c.Member,
+			formatNullInt(c.Mood),
+			formatNullInt(c.Energy),
+			formatNullFloat(c.SleepHours),
+			formatNullFloat(c.Temperature),
+			formatNullFloat(c.Weight),
+			c.Notes,
+		}
+		if err := writer.Write(row); err != nil {
 			return err
 		}
 	}
-	if err := writer.Write([]string{""}); err != nil {
-		return err
-	}
 
-	// Injections section
-	if err := writer.Write([]string{"=== INJECTIONS ==="}); err != nil {
-		return err
-	}
-	if err := writeInjectionsCSV(writer, data.Injections); err != nil {
-		return err
-	}
-	if err := writer.Write([]string{""}); err != nil {
-		return err
-	}
+	return nil
+}
 
-	// Symptoms section
-	if err := writer.Write([]string{"=== SYMPTOMS ==="}); err != nil {
-		return err
-	}
-	if err := writeSymptomsCSV(writer, data.Symptoms); err != nil {
+// writeJournalCSV writes journal entries to CSV
+func writeJournalCSV(writer *csv.Writer, p *i18n.Printer, entries []ExportJournalEntry) error {
+	header := translateAll(p, "ID", "Written", "Date", "Author", "Course", "Title", "Tags", "Body")
+	if err := writer.Write(header); err != nil {
 		return err
 	}
-	if err := writer.Write([]string{""}); err != nil {
-		return err
+
+	for _, j := range entries {
+		date := ""
+		if j.EntryDate.Valid {
+			date = j.EntryDate.Time.UTC().Format("2006-01-02")
+		}
+		row := []string{
+			fmt.Sprintf("%d", j.ID),
+			j.CreatedAt.Format("2006-01-02 15:04:05"),
+			date,
+			j.Author,
+			j.Course,
+			j.Title,
+			j.Tags,
+			j.Body,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
 	}
 
-	// Medications section
-	if err := writer.Write([]string{"=== MEDICATIONS ==="}); err != nil {
+	return nil
+}
+
+// writeLabsCSV writes lab results to CSV
+func writeLabsCSV(writer *csv.Writer, p *i18n.Printer, labs []ExportLabResult) error {
+	header := translateAll(p, "ID", "Drawn", "Analyte", "Value", "Unit", "Reference Low", "Reference High", "Flag", "Course", "Last Injection", "Last Dose (mL)", "Notes")
+	if err := writer.Write(header); err != nil {
 		return err
 	}
-	if err := writeMedicationsCSV(writer, data.Medications); err != nil {
-		return err
+
+	for _, l := range labs {
+		injected := ""
+		if l.PriorDoseAt.Valid {
+			injected = l.PriorDoseAt.Time.Format("2006-01-02 15:04:05")
+		}
+		row := []string{
+			fmt.Sprintf("%d", l.ID),
+			l.DrawnAt.Format("2006-01-02 15:04:05"),
+			l.Analyte,
+			strconv.FormatFloat(l.Value, 'f', -1, 64),
+			l.Unit,
+			formatNullFloat(l.ReferenceLow),
+			formatNullFloat(l.ReferenceHigh),
+			l.Flag,
+			l.Course,
+			injected,
+			formatNullFloat(l.PriorDoseML),
+			l.Notes,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// generatePDF creates a PDF from the export data
-func generatePDF(data *ExportData) ([]byte, error) {
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.SetMargins(15, 15, 15)
-	pdf.AddPage()
+// writeMissedCSV writes missed doses to CSV
+func writeMissedCSV(writer *csv.Writer, p *i18n.Printer, missed []ExportMissedInjection) error {
+	header := translateAll(p, "ID", "Due", "Course", "Reason", "Notes", "Annotated By")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
 
-	// Title
-	pdf.SetFont("Arial", "B", 20)
-	pdf.SetTextColor(63, 81, 181)
-	pdf.CellFormat(0, 15, "Progesterone Injection Tracker", "", 1, "C", false, 0, "")
-	pdf.SetTextColor(0, 0, 0)
+	for _, m := range missed {
+		row := []string{
+			fmt.Sprintf("%d", m.ID),
+			m.DueAt.Format("2006-01-02 15:04:05"),
+			m.Course,
+			missedReasonLabel(p, m.Reason),
+			m.Note,
+			m.AnnotatedBy,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTripsCSV writes trips away to CSV
+func writeTripsCSV(writer *csv.Writer, p *i18n.Printer, trips []*models.Trip) error {
+	header := translateAll(p, "ID", "Leaving", "Back", "Destination", "Timezone", "Home Timezone", "Shift", "Notes")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, t := range trips {
+		row := []string{
+			fmt.Sprintf("%d", t.ID),
+			t.StartsAt.Format("2006-01-02 15:04:05"),
+			t.EndsAt.Format("2006-01-02 15:04:05"),
+			t.Destination,
+			t.Timezone,
+			t.HomeTimezone,
+			t.Shift,
+			t.Notes.String,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	// Report Info
-	pdf.SetFont("Arial", "", 11)
-	pdf.Ln(5)
-	pdf.CellFormat(0, 7, fmt.Sprintf("Report Period: %s to %s",
-		data.StartDate.Format("January 2, 2006"),
-		data.EndDate.Format("January 2, 2006")), "", 1, "L", false, 0, "")
+// formatNullInt shows an optional whole number, blank when unset
+func formatNullInt(v sql.NullInt64) string {
+	if !v.Valid {
+		return ""
+	}
+	return strconv.FormatInt(v.Int64, 10)
+}
 
+// formatNullFloat shows an optional reading, blank when unset
+func formatNullFloat(v sql.NullFloat64) string {
+	if !v.Valid {
+		return ""
+	}
+	return strconv.FormatFloat(v.Float64, 'f', -1, 64)
+}
+
+// writeAllDataCSV writes all data types to a single CSV with sections
+func writeAllDataCSV(writer *csv.Writer, p *i18n.Printer, data *ExportData) error {
+	// Write report header
+	if err := writer.Write([]string{p.T("Progesterone Injection Tracker - Complete Export")}); err != nil {
+		return err
+	}
+	if err := writer.Write([]string{p.T("Report Period: %s to %s", data.StartDate.Format("2006-01-02"), data.EndDate.Format("2006-01-02"))}); err != nil {
+		return err
+	}
 	if data.CourseName != "" {
-		pdf.CellFormat(0, 7, fmt.Sprintf("Course: %s", data.CourseName), "", 1, "L", false, 0, "")
+		if err := writer.Write([]string{p.T("Course: %s", data.CourseName)}); err != nil {
+			return err
+		}
+	}
+	if err := writer.Write([]string{""}); err != nil {
+		return err
+	}
+
+	// Injections section
+	if err := writer.Write([]string{"=== " + strings.ToUpper(p.T("Injections")) + " ==="}); err != nil {
+		return err
+	}
+	if err := writeInjectionsCSV(writer, p, data.Injections); err != nil {
+		return err
+	}
+	if err := writer.Write([]string{""}); err != nil {
+		return err
+	}
+
+	// Symptoms section
+	if err := writer.Write([]string{"=== " + strings.ToUpper(p.T("Symptoms")) + " ==="}); err != nil {
+		return err
+	}
+	if err := writeSymptomsCSV(writer, p, data.Symptoms); err != nil {
+		return err
+	}
+	if err := writer.Write([]string{""}); err != nil {
+		return err
+	}
+
+	// Medications section
+	if err := writer.Write([]string{"=== " + strings.ToUpper(p.T("Medications")) + " ==="}); err != nil {
+		return err
+	}
+	if err := writeMedicationsCSV(writer, p, data.Medications); err != nil {
+		return err
+	}
+	if err := writer.Write([]string{""}); err != nil {
+		return err
+	}
+
+	// Check-ins section
+	if err := writer.Write([]string{"=== " + strings.ToUpper(p.T("Check-ins")) + " ==="}); err != nil {
+		return err
+	}
+	if err := writeCheckInsCSV(writer, p, data.CheckIns); err != nil {
+		return err
+	}
+	if err := writer.Write([]string{""}); err != nil {
+		return err
+	}
+
+	// Journal section
+	if err := writer.Write([]string{"=== " + strings.ToUpper(p.T("Journal")) + " ==="}); err != nil {
+		return err
+	}
+	if err := writeJournalCSV(writer, p, data.Journal); err != nil {
+		return err
+	}
+	if err := writer.Write([]string{""}); err != nil {
+		return err
+	}
+
+	// Lab results section
+	if err := writer.Write([]string{"=== " + strings.ToUpper(p.T("Lab results")) + " ==="}); err != nil {
+		return err
+	}
+	if err := writeLabsCSV(writer, p, data.Labs); err != nil {
+		return err
+	}
+	if err := writer.Write([]string{""}); err != nil {
+		return err
+	}
+
+	// Missed injections section
+	if err := writer.Write([]string{"=== " + strings.ToUpper(p.T("Missed Injections")) + " ==="}); err != nil {
+		return err
+	}
+	if err := writeMissedCSV(writer, p, data.Missed); err != nil {
+		return err
+	}
+
+	// Trips section
+	if len(data.Trips) > 0 {
+		if err := writer.Write([]string{""}); err != nil {
+			return err
+		}
+		if err := writer.Write([]string{"=== " + strings.ToUpper(p.T("Trips")) + " ==="}); err != nil {
+			return err
+		}
+		if err := writeTripsCSV(writer, p, data.Trips); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildExportWorkbook lays out export data as a workbook, with times shown
+// in loc and headings in p's language
+func buildExportWorkbook(data *ExportData, inventory []*models.InventoryItem, loc *time.Location, p *i18n.Printer) *xlsxWorkbook {
+	wb := &xlsxWorkbook{}
+
+	injections := wb.addSheet(p.T("Injections"), translateAll(p, "ID", "Date", "Side", "Dose (mL)", "Dose (mg)", "Pain Level", "Has Knots", "Site Reaction", "Notes", "Administered By", "Travel")...)
+	for _, inj := range data.Injections {
+		doseMG := xlsxCell{}
+		if inj.DoseMG.Valid {
+			doseMG = xlsxNumber(inj.DoseMG.Float64)
+		}
+		injections.addRow(
+			xlsxNumber(float64(inj.ID)),
+			xlsxDateTime(inj.Timestamp.In(loc)),
+			xlsxText(inj.Side),
+			xlsxNumber(inj.DoseML),
+			doseMG,
+			xlsxNumber(float64(inj.PainLevel)),
+			xlsxText(yesNo(p, inj.HasKnots)),
+			xlsxText(inj.SiteReaction),
+			xlsxText(inj.Notes),
+			xlsxText(inj.AdministeredBy),
+			xlsxText(travelNote(p, inj)),
+		)
+	}
+
+	symptoms := wb.addSheet(p.T("Symptoms"), translateAll(p, "ID", "Date", "Pain Level", "Pain Location", "Pain Type", "Symptoms", "Notes")...)
+	for _, sym := range data.Symptoms {
+		symptoms.addRow(
+			xlsxNumber(float64(sym.ID)),
+			xlsxDateTime(sym.Timestamp.In(loc)),
+			xlsxNumber(float64(sym.PainLevel)),
+			xlsxText(sym.PainLocation),
+			xlsxText(sym.PainType),
+			xlsxText(sym.Symptoms),
+			xlsxText(sym.Notes),
+		)
+	}
+
+	medications := wb.addSheet(p.T("Medications"), translateAll(p, "ID", "Date", "Medication", "Taken", "Notes")...)
+	for _, med := range data.Medications {
+		medications.addRow(
+			xlsxNumber(float64(med.ID)),
+			xlsxDateTime(med.Timestamp.In(loc)),
+			xlsxText(med.MedicationName),
+			xlsxText(yesNo(p, med.Taken)),
+			xlsxText(med.Notes),
+		)
+	}
+
+	stock := wb.addSheet(p.T("Inventory"), translateAll(p, "Item", "Quantity", "Unit", "Low Stock Threshold", "Lot Number", "Expiration Date", "Notes")...)
+	for _, item := range inventory {
+		threshold, expiration := xlsxCell{}, xlsxCell{}
+		if item.LowStockThreshold.Valid {
+			threshold = xlsxNumber(item.LowStockThreshold.Float64)
+		}
+		if item.ExpirationDate.Valid {
+			// Expiration dates are calendar dates, not instants
+			expiration = xlsxDate(item.ExpirationDate.Time.UTC())
+		}
+		stock.addRow(
+			xlsxText(item.ItemType),
+			xlsxNumber(item.Quantity),
+			xlsxText(item.Unit),
+			threshold,
+			xlsxText(item.LotNumber.String),
+			expiration,
+			xlsxText(item.Notes.String),
+		)
+	}
+
+	checkIns := wb.addSheet(p.T("Check-ins"), translateAll(p, "ID", "Date", "Member", "Mood", "Energy", "Sleep (hours)", "Temperature (C)", "Weight (kg)", "Notes")...)
+	for _, c := range data.CheckIns {
+		reading := func(v sql.NullFloat64) xlsxCell {
+			if !v.Valid {
+				return xlsxCell{}
+			}
+			return xlsxNumber(v.Float64)
+		}
+		checkIns.addRow(
+			xlsxNumber(float64(c.ID)),
+			xlsxDate(c.Date.UTC()),
+			xlsxText(c.Member),
+			reading(sql.NullFloat64{Float64: float64(c.Mood.Int64), Valid: c.Mood.Valid}),
+			reading(sql.NullFloat64{Float64: float64(c.Energy.Int64), Valid: c.Energy.Valid}),
+			reading(c.SleepHours),
+			reading(c.Temperature),
+			reading(c.Weight),
+			xlsxText(c.Notes),
+		)
+	}
+
+	journal := wb.addSheet(p.T("Journal"), translateAll(p, "ID", "Written", "Date", "Author", "Course", "Title", "Tags", "Body")...)
+	for _, j := range data.Journal {
+		date := xlsxCell{}
+		if j.EntryDate.Valid {
+			date = xlsxDate(j.EntryDate.Time.UTC())
+		}
+		journal.addRow(
+			xlsxNumber(float64(j.ID)),
+			xlsxDateTime(j.CreatedAt.In(loc)),
+			date,
+			xlsxText(j.Author),
+			xlsxText(j.Course),
+			xlsxText(j.Title),
+			xlsxText(j.Tags),
+			xlsxText(j.Body),
+		)
+	}
+
+	labs := wb.addSheet(p.T("Labs"), translateAll(p, "ID", "Drawn", "Analyte", "Value", "Unit", "Reference Low", "Reference High", "Flag", "Course", "Last Injection", "Last Dose (mL)", "Notes")...)
+	for _, l := range data.Labs {
+		optional := func(v sql.NullFloat64) xlsxCell {
+			if !v.Valid {
+				return xlsxCell{}
+			}
+			return xlsxNumber(v.Float64)
+		}
+		injected := xlsxCell{}
+		if l.PriorDoseAt.Valid {
+			injected = xlsxDateTime(l.PriorDoseAt.Time.In(loc))
+		}
+		labs.addRow(
+			xlsxNumber(float64(l.ID)),
+			xlsxDateTime(l.DrawnAt.In(loc)),
+			xlsxText(l.Analyte),
+			xlsxNumber(l.Value),
+			xlsxText(l.Unit),
+			optional(l.ReferenceLow),
+			optional(l.ReferenceHigh),
+			xlsxText(l.Flag),
+			xlsxText(l.Course),
+			injected,
+			optional(l.PriorDoseML),
+			xlsxText(l.Notes),
+		)
+	}
+
+	missed := wb.addSheet(p.T("Missed"), translateAll(p, "ID", "Due", "Course", "Reason", "Notes", "Annotated By")...)
+	for _, m := range data.Missed {
+		missed.addRow(
+			xlsxNumber(float64(m.ID)),
+			xlsxDateTime(m.DueAt.In(loc)),
+			xlsxText(m.Course),
+			xlsxText(missedReasonLabel(p, m.Reason)),
+			xlsxText(m.Note),
+			xlsxText(m.AnnotatedBy),
+		)
+	}
+
+	trips := wb.addSheet(p.T("Trips"), translateAll(p, "ID", "Leaving", "Back", "Destination", "Timezone", "Home Timezone", "Shift", "Notes")...)
+	for _, t := range data.Trips {
+		trips.addRow(
+			xlsxNumber(float64(t.ID)),
+			xlsxDateTime(t.StartsAt.In(loc)),
+			xlsxDateTime(t.EndsAt.In(loc)),
+			xlsxText(t.Destination),
+			xlsxText(t.Timezone),
+			xlsxText(t.HomeTimezone),
+			xlsxText(t.Shift),
+			xlsxText(t.Notes.String),
+		)
+	}
+
+	return wb
+}
+
+// yesNo writes b as Yes or No in p's language
+func yesNo(p *i18n.Printer, b bool) string {
+	if b {
+		return p.T("Yes")
+	}
+	return p.T("No")
+}
+
+// translateAll translates each of msgs, as for a row of column headings
+func translateAll(p *i18n.Printer, msgs ...string) []string {
+	out := make([]string, len(msgs))
+	for i, msg := range msgs {
+		out[i] = p.T(msg)
+	}
+	return out
+}
+
+// reportStats are the figures the PDF summary compares between periods
+type reportStats struct {
+	Injections    int
+	Left, Right   int
+	Knots         int
+	InjectionPain sql.NullFloat64 // Average of recorded pain levels
+	SymptomPain   sql.NullFloat64
+	ExpectedDays  int
+	InjectedDays  int
+	MedsLogged    int
+	MedsTaken     int
+	Scheduled     int // Injections on a day the taper schedule covers
+	OffSchedule   int // ...whose dose differs from it
+	CheckIns      int
+	Mood          sql.NullFloat64 // Averages of recorded check-in readings
+	Energy        sql.NullFloat64
+	Sleep         sql.NullFloat64
+}
+
+func computeReportStats(data *ExportData) reportStats {
+	stats := reportStats{Injections: len(data.Injections)}
+
+	var painSum, painCount int
+	times := make([]time.Time, 0, len(data.Injections))
+	for _, inj := range data.Injections {
+		times = append(times, inj.Timestamp)
+		switch inj.Side {
+		case "left":
+			stats.Left++
+		case "right":
+			stats.Right++
+		}
+		if inj.HasKnots {
+			stats.Knots++
+		}
+		if inj.HasPain {
+			painSum += inj.PainLevel
+			painCount++
+		}
+		if inj.ScheduledDoseML.Valid {
+			stats.Scheduled++
+			if inj.OffSchedule() {
+				stats.OffSchedule++
+			}
+		}
+	}
+	if painCount > 0 {
+		stats.InjectionPain = sql.NullFloat64{Float64: float64(painSum) / float64(painCount), Valid: true}
 	}
-	pdf.Ln(5)
 
-	// Summary Statistics
+	painSum, painCount = 0, 0
+	for _, sym := range data.Symptoms {
+		if sym.HasPain {
+			painSum += sym.PainLevel
+			painCount++
+		}
+	}
+	if painCount > 0 {
+		stats.SymptomPain = sql.NullFloat64{Float64: float64(painSum) / float64(painCount), Valid: true}
+	}
+
+	for _, med := range data.Medications {
+		stats.MedsLogged++
+		if med.Taken {
+			stats.MedsTaken++
+		}
+	}
+
+	stats.CheckIns = len(data.CheckIns)
+	var mood, energy, sleep []float64
+	for _, c := range data.CheckIns {
+		if c.Mood.Valid {
+			mood = append(mood, float64(c.Mood.Int64))
+		}
+		if c.Energy.Valid {
+			energy = append(energy, float64(c.Energy.Int64))
+		}
+		if c.SleepHours.Valid {
+			sleep = append(sleep, c.SleepHours.Float64)
+		}
+	}
+	stats.Mood, stats.Energy, stats.Sleep = meanOf(mood), meanOf(energy), meanOf(sleep)
+
+	stats.ExpectedDays, stats.InjectedDays = services.InjectionAdherence(times, data.Courses, data.StartDate, data.EndDate, exportLocation(data))
+	return stats
+}
+
+// meanOf averages values, invalid when there are none
+func meanOf(values []float64) sql.NullFloat64 {
+	if len(values) == 0 {
+		return sql.NullFloat64{}
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sql.NullFloat64{Float64: sum / float64(len(values)), Valid: true}
+}
+
+// exportLocation is the timezone to show export times in
+func exportLocation(data *ExportData) *time.Location {
+	if data.Location == nil {
+		return time.UTC
+	}
+	return data.Location
+}
+
+// formatChange shows the difference between two figures, signed
+func formatChange(p *i18n.Printer, diff float64, format, unit string) string {
+	s := fmt.Sprintf(format, math.Abs(diff))
+	if s == fmt.Sprintf(format, 0.0) {
+		return p.T("no change")
+	}
+	if diff < 0 {
+		return "-" + s + unit
+	}
+	return "+" + s + unit
+}
+
+// summaryRows lays out the metric, this period, previous period and change
+// columns of the PDF summary table, in p's language
+func summaryRows(p *i18n.Printer, data *ExportData, cur, prev reportStats) [][4]string {
+	adherence := func(s reportStats) string {
+		if s.ExpectedDays == 0 {
+			return "-"
+		}
+		return p.T("%d%% (%d/%d days)", s.InjectedDays*100/s.ExpectedDays, s.InjectedDays, s.ExpectedDays)
+	}
+	average := func(v sql.NullFloat64) string {
+		if !v.Valid {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f", v.Float64)
+	}
+	count := func(a, b int) string {
+		return formatChange(p, float64(a-b), "%.0f", "")
+	}
+
+	adherenceChange := ""
+	if cur.ExpectedDays > 0 && prev.ExpectedDays > 0 {
+		diff := float64(cur.InjectedDays*100/cur.ExpectedDays - prev.InjectedDays*100/prev.ExpectedDays)
+		adherenceChange = formatChange(p, diff, "%.0f", p.T(" pts"))
+	}
+	painChange := func(a, b sql.NullFloat64) string {
+		if !a.Valid || !b.Valid {
+			return ""
+		}
+		return formatChange(p, a.Float64-b.Float64, "%.1f", "")
+	}
+
+	var previousDose string
+	if data.Previous != nil {
+		previousDose = formatTotalDose(data.Previous.Injections)
+	}
+
+	rows := [][4]string{
+		{p.T("Injections"), strconv.Itoa(cur.Injections), strconv.Itoa(prev.Injections), count(cur.Injections, prev.Injections)},
+		{p.T("Adherence"), adherence(cur), adherence(prev), adherenceChange},
+		{p.T("Average injection pain"), average(cur.InjectionPain), average(prev.InjectionPain), painChange(cur.InjectionPain, prev.InjectionPain)},
+		{p.T("Average symptom pain"), average(cur.SymptomPain), average(prev.SymptomPain), painChange(cur.SymptomPain, prev.SymptomPain)},
+		{p.T("Injections with knots"), strconv.Itoa(cur.Knots), strconv.Itoa(prev.Knots), count(cur.Knots, prev.Knots)},
+		{p.T("Left / right"), fmt.Sprintf("%d / %d", cur.Left, cur.Right), fmt.Sprintf("%d / %d", prev.Left, prev.Right), ""},
+		{p.T("Total dose"), formatTotalDose(data.Injections), previousDose, ""},
+		{p.T("Medication doses taken"), p.T("%d of %d", cur.MedsTaken, cur.MedsLogged), p.T("%d of %d", prev.MedsTaken, prev.MedsLogged), ""},
+	}
+	if cur.Scheduled > 0 || prev.Scheduled > 0 {
+		offSchedule := func(s reportStats) string {
+			return p.T("%d of %d", s.OffSchedule, s.Scheduled)
+		}
+		rows = append(rows, [4]string{p.T("Doses off taper schedule"), offSchedule(cur), offSchedule(prev), count(cur.OffSchedule, prev.OffSchedule)})
+	}
+	if cur.CheckIns > 0 || prev.CheckIns > 0 {
+		rows = append(rows,
+			[4]string{p.T("Check-ins"), strconv.Itoa(cur.CheckIns), strconv.Itoa(prev.CheckIns), count(cur.CheckIns, prev.CheckIns)},
+			[4]string{p.T("Average mood (1-5)"), average(cur.Mood), average(prev.Mood), painChange(cur.Mood, prev.Mood)},
+			[4]string{p.T("Average energy (1-5)"), average(cur.Energy), average(prev.Energy), painChange(cur.Energy, prev.Energy)},
+			[4]string{p.T("Average sleep (hours)"), average(cur.Sleep), average(prev.Sleep), painChange(cur.Sleep, prev.Sleep)},
+		)
+	}
+	return rows
+}
+
+// dailyPain averages pain levels per local day, as chart points at midday
+func dailyPain(times []time.Time, levels []int, loc *time.Location) []chartPoint {
+	type total struct{ sum, count int }
+	days := map[time.Time]*total{}
+	for i, t := range times {
+		local := t.In(loc)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 12, 0, 0, 0, loc)
+		if days[day] == nil {
+			days[day] = &total{}
+		}
+		days[day].sum += levels[i]
+		days[day].count++
+	}
+
+	points := make([]chartPoint, 0, len(days))
+	for day, t := range days {
+		points = append(points, chartPoint{X: day, Y: float64(t.sum) / float64(t.count)})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].X.Before(points[j].X) })
+	return points
+}
+
+// painSeries builds the pain trend lines for injections and symptom logs
+func painSeries(p *i18n.Printer, data *ExportData) []chartSeries {
+	loc := exportLocation(data)
+
+	var times []time.Time
+	var levels []int
+	for _, inj := range data.Injections {
+		if inj.HasPain {
+			times = append(times, inj.Timestamp)
+			levels = append(levels, inj.PainLevel)
+		}
+	}
+	injections := chartSeries{Label: p.T("Injection pain"), Color: chartBlue, Points: dailyPain(times, levels, loc)}
+
+	times, levels = nil, nil
+	for _, sym := range data.Symptoms {
+		if sym.HasPain {
+			times = append(times, sym.Timestamp)
+			levels = append(levels, sym.PainLevel)
+		}
+	}
+	symptoms := chartSeries{Label: p.T("Symptom pain"), Color: chartOrange, Points: dailyPain(times, levels, loc)}
+
+	return []chartSeries{injections, symptoms}
+}
+
+// wellnessSeries builds the daily mood and energy lines from check-ins,
+// averaging members who checked in on the same day
+func wellnessSeries(p *i18n.Printer, data *ExportData) []chartSeries {
+	daily := func(reading func(ExportCheckIn) sql.NullInt64) []chartPoint {
+		var times []time.Time
+		var levels []int
+		for _, c := range data.CheckIns {
+			if v := reading(c); v.Valid {
+				times = append(times, c.Date.UTC())
+				levels = append(levels, int(v.Int64))
+			}
+		}
+		return dailyPain(times, levels, time.UTC)
+	}
+	return []chartSeries{
+		{Label: p.T("Mood"), Color: chartBlue, Points: daily(func(c ExportCheckIn) sql.NullInt64 { return c.Mood })},
+		{Label: p.T("Energy"), Color: chartGreen, Points: daily(func(c ExportCheckIn) sql.NullInt64 { return c.Energy })},
+	}
+}
+
+// maxPDFLabCharts is how many analytes get a chart in the PDF report; the
+// rest are only in the table
+const maxPDFLabCharts = 2
+
+// labChart is one analyte's levels, with the top of its scale
+type labChart struct {
+	Title  string
+	YMax   float64
+	Series []chartSeries
+}
+
+// labCharts charts the analytes with the most results, up to limit. Each
+// gets its own chart since levels of different analytes are on different
+// scales; a series per unit keeps mixed units apart.
+func labCharts(data *ExportData, limit int) []labChart {
+	type analyte struct {
+		name   string
+		units  []string
+		byUnit map[string][]chartPoint
+		count  int
+		max    float64
+	}
+	var order []*analyte
+	index := map[string]*analyte{}
+	// Results come newest first, so the first spelling seen is the latest
+	for i := len(data.Labs) - 1; i >= 0; i-- {
+		l := data.Labs[i]
+		key := strings.ToLower(l.Analyte)
+		a := index[key]
+		if a == nil {
+			a = &analyte{byUnit: map[string][]chartPoint{}}
+			index[key] = a
+			order = append(order, a)
+		}
+		a.name = l.Analyte
+		if _, ok := a.byUnit[l.Unit]; !ok {
+			a.units = append(a.units, l.Unit)
+		}
+		a.byUnit[l.Unit] = append(a.byUnit[l.Unit], chartPoint{X: l.DrawnAt, Y: l.Value})
+		a.count++
+		a.max = math.Max(a.max, math.Max(l.Value, l.ReferenceHigh.Float64))
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].count > order[j].count })
+
+	colors := []chartColor{chartBlue, chartOrange, chartGreen}
+	var charts []labChart
+	for _, a := range order[:min(len(order), limit)] {
+		chart := labChart{Title: a.name, YMax: niceCeiling(a.max * 1.1)}
+		for i, unit := range a.units {
+			chart.Series = append(chart.Series, chartSeries{
+				Label:  a.name + " (" + unit + ")",
+				Color:  colors[i%len(colors)],
+				Points: a.byUnit[unit],
+			})
+		}
+		charts = append(charts, chart)
+	}
+	return charts
+}
+
+// niceCeiling rounds v up to 1, 2 or 5 times a power of ten, so axis ticks
+// land on readable values
+func niceCeiling(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	scale := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if v <= step*scale {
+			return step * scale
+		}
+	}
+	return 10 * scale
+}
+
+// adherenceBars splits the period into weeks (or, for long periods, enough
+// days to keep to about a dozen bars) and gives adherence for each
+func adherenceBars(p *i18n.Printer, data *ExportData) []chartBar {
+	loc := exportLocation(data)
+	times := make([]time.Time, 0, len(data.Injections))
+	for _, inj := range data.Injections {
+		times = append(times, inj.Timestamp)
+	}
+
+	days := int(math.Ceil(data.EndDate.Sub(data.StartDate).Hours() / 24))
+	bucket := 7
+	if days > 84 {
+		bucket = (days + 11) / 12
+	}
+
+	first := data.StartDate.In(loc)
+	var bars []chartBar
+	for from := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); from.Before(data.EndDate); from = from.AddDate(0, 0, bucket) {
+		to := from.AddDate(0, 0, bucket)
+		if to.After(data.EndDate) {
+			to = data.EndDate
+		}
+		bar := chartBar{Label: p.MonthDay(from)}
+		expected, injected := services.InjectionAdherence(times, data.Courses, from, to, loc)
+		if expected == 0 {
+			bar.Empty = true
+		} else {
+			bar.Value = float64(injected * 100 / expected)
+		}
+		bars = append(bars, bar)
+	}
+	return bars
+}
+
+// pdfSectionTitle writes a shaded section heading
+func pdfSectionTitle(pdf *gofpdf.Fpdf, title string) {
 	pdf.SetFont("Arial", "B", 14)
 	pdf.SetFillColor(240, 240, 240)
-	pdf.CellFormat(0, 10, "Summary Statistics", "", 1, "L", true, 0, "")
+	pdf.CellFormat(0, 10, title, "", 1, "L", true, 0, "")
 	pdf.Ln(2)
+}
 
-	pdf.SetFont("Arial", "", 11)
-	pdf.CellFormat(90, 7, fmt.Sprintf("Total Injections: %d", len(data.Injections)), "", 0, "L", false, 0, "")
-	pdf.CellFormat(90, 7, fmt.Sprintf("Total Symptom Logs: %d", len(data.Symptoms)), "", 1, "L", false, 0, "")
-	pdf.CellFormat(90, 7, fmt.Sprintf("Total Medication Logs: %d", len(data.Medications)), "", 1, "L", false, 0, "")
-	pdf.Ln(8)
+// generatePDF creates a PDF report from data built by gatherReportData: a
+// summary page with charts, then the injection and symptom logs and any
+// wellness check-ins and lab results. The heading uses the site's report
+// letterhead, or its title when unset, and the rest is written in p's
+// language.
+func generatePDF(data *ExportData, site *SiteSettings, p *i18n.Printer) ([]byte, error) {
+	loc := exportLocation(data)
+	previous := data.Previous
+	if previous == nil {
+		previous = &ExportData{}
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	// Core fonts are cp1252, so translate anything the user typed
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	text := pdfText{p: p, tr: tr}
+
+	title := site.SiteTitle
+	if title == "" {
+		title = p.T("Injection Tracker")
+	}
+	now := time.Now().In(loc)
+	generated := p.T("%s at %s", p.LongDate(now), p.Time(now))
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 10, tr(p.T("Generated on %s - %s - Page %d of {nb}", generated, title, pdf.PageNo())), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+	pdf.AddPage()
+
+	// Letterhead: the first line as the heading, the rest (address and so
+	// on) in small print under it
+	var letterhead []string
+	for _, line := range strings.Split(site.ReportLetterhead, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			letterhead = append(letterhead, line)
+		}
+	}
+	heading := title
+	if len(letterhead) > 0 {
+		heading, letterhead = letterhead[0], letterhead[1:]
+	}
+
+	pdf.SetFont("Arial", "B", 16)
+	pdf.SetTextColor(63, 81, 181)
+	pdf.CellFormat(110, 8, tr(heading), "", 1, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 9)
+	pdf.SetTextColor(90, 90, 90)
+	for _, line := range letterhead {
+		pdf.CellFormat(110, 4.5, tr(line), "", 1, "L", false, 0, "")
+	}
+	headerBottom := pdf.GetY()
+
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetXY(125, 15)
+	pdf.SetFont("Arial", "B", 12)
+	pdf.CellFormat(70, 8, text.T("Treatment Report"), "", 2, "R", false, 0, "")
+	pdf.SetFont("Arial", "", 9)
+	pdf.CellFormat(70, 4.5, text.T("%s to %s", p.LongDate(data.StartDate), p.LongDate(data.EndDate)), "", 2, "R", false, 0, "")
+	if data.CourseName != "" {
+		pdf.CellFormat(70, 4.5, text.T("Course: %s", data.CourseName), "", 2, "R", false, 0, "")
+	}
+	if pdf.GetY() > headerBottom {
+		headerBottom = pdf.GetY()
+	}
+
+	pdf.SetDrawColor(63, 81, 181)
+	pdf.SetLineWidth(0.5)
+	pdf.Line(15, headerBottom+2, 195, headerBottom+2)
+	pdf.SetLineWidth(0.2)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetXY(15, headerBottom+6)
+
+	// Summary compared with the period before
+	pdfSectionTitle(pdf, text.T("Summary"))
+	pdf.SetFont("Arial", "I", 9)
+	pdf.CellFormat(0, 5, text.T("Previous period: %s to %s", p.LongDate(previous.StartDate), p.LongDate(data.StartDate)), "", 1, "L", false, 0, "")
+	pdf.Ln(1)
+
+	widths := []float64{70, 40, 40, 30}
+	pdf.SetFont("Arial", "B", 9)
+	pdf.SetFillColor(200, 200, 200)
+	for i, h := range []string{"Metric", "This period", "Previous period", "Change"} {
+		pdf.CellFormat(widths[i], 7, text.T(h), "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Arial", "", 9)
+	for _, row := range summaryRows(p, data, computeReportStats(data), computeReportStats(previous)) {
+		for i, cell := range row {
+			align := "C"
+			if i == 0 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], 6, tr(cell), "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(6)
+
+	// Charts
+	stats := computeReportStats(data)
+	y := pdf.GetY()
+	drawLineChart(pdf, text, 15, y, 180, 62, p.T("Pain Trend (daily average)"), data.StartDate, data.EndDate, 10, painSeries(p, data))
+	y += 68
+	drawPieChart(pdf, text, 15, y, 85, 50, p.T("Left / Right Balance"), []chartSlice{
+		{Label: p.T("Left"), Value: float64(stats.Left), Color: chartBlue},
+		{Label: p.T("Right"), Value: float64(stats.Right), Color: chartOrange},
+	})
+	drawBarChart(pdf, text, 105, y, 90, 50, p.T("Adherence"), adherenceBars(p, data), 100, "%")
 
 	// Injections Section
 	if len(data.Injections) > 0 {
-		pdf.SetFont("Arial", "B", 14)
-		pdf.SetFillColor(240, 240, 240)
-		pdf.CellFormat(0, 10, "Injection Log", "", 1, "L", true, 0, "")
-		pdf.Ln(2)
+		pdf.AddPage()
+		pdfSectionTitle(pdf, text.T("Injection Log"))
 
 		// Table Header
 		pdf.SetFont("Arial", "B", 9)
 		pdf.SetFillColor(200, 200, 200)
-		pdf.CellFormat(25, 7, "Date", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(15, 7, "Time", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(15, 7, "Side", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(15, 7, "Pain", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(20, 7, "Knots", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(30, 7, "Reaction", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(60, 7, "Notes", "1", 1, "C", true, 0, "")
+		pdf.CellFormat(25, 7, text.T("Date"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(15, 7, text.T("Time"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(15, 7, text.T("Side"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(15, 7, text.T("Dose"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(15, 7, text.T("Pain"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(20, 7, text.T("Knots"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(30, 7, text.T("Reaction"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(45, 7, text.T("Notes"), "1", 1, "C", true, 0, "")
 
 		// Table Data
 		pdf.SetFont("Arial", "", 8)
@@ -539,18 +1887,24 @@ func generatePDF(data *ExportData) ([]byte, error) {
 
 		for i := 0; i < maxRows; i++ {
 			inj := data.Injections[i]
-			hasKnots := "No"
-			if inj.HasKnots {
-				hasKnots = "Yes"
+			ts := inj.Timestamp.In(loc)
+			pain := "-"
+			if inj.HasPain {
+				pain = strconv.Itoa(inj.PainLevel)
 			}
 
-			pdf.CellFormat(25, 6, inj.Timestamp.Format("2006-01-02"), "1", 0, "L", false, 0, "")
-			pdf.CellFormat(15, 6, inj.Timestamp.Format("15:04"), "1", 0, "L", false, 0, "")
-			pdf.CellFormat(15, 6, inj.Side, "1", 0, "C", false, 0, "")
-			pdf.CellFormat(15, 6, fmt.Sprintf("%d", inj.PainLevel), "1", 0, "C", false, 0, "")
-			pdf.CellFormat(20, 6, hasKnots, "1", 0, "C", false, 0, "")
-			pdf.CellFormat(30, 6, inj.SiteReaction, "1", 0, "L", false, 0, "")
-			pdf.CellFormat(60, 6, truncateString(inj.Notes, 30), "1", 1, "L", false, 0, "")
+			pdf.CellFormat(25, 6, ts.Format("2006-01-02"), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(15, 6, ts.Format("15:04"), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(15, 6, text.T(cases.Title(language.English).String(inj.Side)), "1", 0, "C", false, 0, "")
+			dose := formatDose(inj.DoseML) + " mL"
+			if inj.OffSchedule() {
+				dose += "*"
+			}
+			pdf.CellFormat(15, 6, dose, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(15, 6, pain, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(20, 6, text.tr(yesNo(p, inj.HasKnots)), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(30, 6, tr(inj.SiteReaction), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(45, 6, tr(truncateString(inj.Notes, 22)), "1", 1, "L", false, 0, "")
 
 			// Add new page if needed
 			if pdf.GetY() > 260 && i < maxRows-1 {
@@ -561,31 +1915,156 @@ func generatePDF(data *ExportData) ([]byte, error) {
 		if len(data.Injections) > maxRows {
 			pdf.Ln(3)
 			pdf.SetFont("Arial", "I", 9)
-			pdf.CellFormat(0, 5, fmt.Sprintf("Showing %d of %d injections. Export CSV for complete data.", maxRows, len(data.Injections)), "", 1, "L", false, 0, "")
+			pdf.CellFormat(0, 5, text.T("Showing %d of %d injections. Export CSV for complete data.", maxRows, len(data.Injections)), "", 1, "L", false, 0, "")
 		}
 		pdf.Ln(5)
+
+		// Every injection whose dose differs from the taper schedule
+		var offSchedule []ExportInjection
+		for _, inj := range data.Injections {
+			if inj.OffSchedule() {
+				offSchedule = append(offSchedule, inj)
+			}
+		}
+		if len(offSchedule) > 0 {
+			if pdf.GetY() > 230 {
+				pdf.AddPage()
+			}
+			pdfSectionTitle(pdf, text.T("Taper Schedule Deviations"))
+			pdf.SetFont("Arial", "I", 9)
+			pdf.CellFormat(0, 5, text.T("* Doses that differ from the course's taper schedule for that day"), "", 1, "L", false, 0, "")
+			pdf.Ln(1)
+
+			pdf.SetFont("Arial", "B", 9)
+			pdf.SetFillColor(200, 200, 200)
+			pdf.CellFormat(40, 7, text.T("Date"), "1", 0, "C", true, 0, "")
+			pdf.CellFormat(40, 7, text.T("Scheduled"), "1", 0, "C", true, 0, "")
+			pdf.CellFormat(40, 7, text.T("Given"), "1", 0, "C", true, 0, "")
+			pdf.CellFormat(40, 7, text.T("Difference"), "1", 1, "C", true, 0, "")
+
+			pdf.SetFont("Arial", "", 8)
+			for i, inj := range offSchedule {
+				if i == maxRows {
+					pdf.SetFont("Arial", "I", 9)
+					pdf.CellFormat(0, 5, text.T("Showing %d of %d deviations.", maxRows, len(offSchedule)), "", 1, "L", false, 0, "")
+					break
+				}
+				pdf.CellFormat(40, 6, inj.Timestamp.In(loc).Format("2006-01-02 15:04"), "1", 0, "L", false, 0, "")
+				pdf.CellFormat(40, 6, formatDose(inj.ScheduledDoseML.Float64)+" mL", "1", 0, "C", false, 0, "")
+				pdf.CellFormat(40, 6, formatDose(inj.DoseML)+" mL", "1", 0, "C", false, 0, "")
+				pdf.CellFormat(40, 6, formatChange(p, inj.DoseML-inj.ScheduledDoseML.Float64, "%.2f", " mL"), "1", 1, "C", false, 0, "")
+				if pdf.GetY() > 260 && i < len(offSchedule)-1 {
+					pdf.AddPage()
+				}
+			}
+			pdf.Ln(5)
+		}
 	}
 
-	// Symptoms Section
-	if len(data.Symptoms) > 0 {
-		if pdf.GetY() > 220 {
+	// Trips away, with the injections given on them on both clocks
+	if len(data.Trips) > 0 {
+		if pdf.GetY() > 230 {
 			pdf.AddPage()
 		}
+		pdfSectionTitle(pdf, text.T("Travel"))
 
-		pdf.SetFont("Arial", "B", 14)
-		pdf.SetFillColor(240, 240, 240)
-		pdf.CellFormat(0, 10, "Symptom Log", "", 1, "L", true, 0, "")
+		pdf.SetFont("Arial", "", 9)
+		for _, trip := range data.Trips {
+			shift := text.T("switched to local time at once")
+			if trip.Shift == models.TripShiftGradual {
+				shift = text.T("shifted an hour a day")
+			}
+			line := fmt.Sprintf("%s (%s): %s - %s, %s", trip.Destination, trip.Timezone,
+				trip.StartsAt.In(loc).Format("2006-01-02 15:04"), trip.EndsAt.In(loc).Format("2006-01-02 15:04"), shift)
+			pdf.CellFormat(0, 5, tr(line), "", 1, "L", false, 0, "")
+		}
 		pdf.Ln(2)
 
+		var travelling []ExportInjection
+		for _, inj := range data.Injections {
+			if inj.Trip != "" {
+				travelling = append(travelling, inj)
+			}
+		}
+		if len(travelling) > 0 {
+			pdf.SetFont("Arial", "B", 9)
+			pdf.SetFillColor(200, 200, 200)
+			pdf.CellFormat(40, 7, text.T("Date"), "1", 0, "C", true, 0, "")
+			pdf.CellFormat(45, 7, text.T("Local Time"), "1", 0, "C", true, 0, "")
+			pdf.CellFormat(50, 7, text.T("Trip"), "1", 0, "C", true, 0, "")
+			pdf.CellFormat(20, 7, text.T("Side"), "1", 0, "C", true, 0, "")
+			pdf.CellFormat(25, 7, text.T("Dose"), "1", 1, "C", true, 0, "")
+
+			const maxRows = 25
+			pdf.SetFont("Arial", "", 8)
+			for i, inj := range travelling {
+				if i == maxRows {
+					pdf.SetFont("Arial", "I", 9)
+					pdf.CellFormat(0, 5, text.T("Showing %d of %d injections given while travelling.", maxRows, len(travelling)), "", 1, "L", false, 0, "")
+					break
+				}
+				pdf.CellFormat(40, 6, inj.Timestamp.In(loc).Format("2006-01-02 15:04"), "1", 0, "L", false, 0, "")
+				pdf.CellFormat(45, 6, inj.TripLocal.Format("2006-01-02 15:04 MST"), "1", 0, "L", false, 0, "")
+				pdf.CellFormat(50, 6, tr(truncateString(inj.Trip, 28)), "1", 0, "L", false, 0, "")
+				pdf.CellFormat(20, 6, text.T(cases.Title(language.English).String(inj.Side)), "1", 0, "C", false, 0, "")
+				pdf.CellFormat(25, 6, formatDose(inj.DoseML)+" mL", "1", 1, "C", false, 0, "")
+				if pdf.GetY() > 260 && i < len(travelling)-1 {
+					pdf.AddPage()
+				}
+			}
+		}
+		pdf.Ln(5)
+	}
+
+	// Doses the schedule had due that weren't given, and why
+	if len(data.Missed) > 0 {
+		if pdf.GetY() > 230 {
+			pdf.AddPage()
+		}
+		pdfSectionTitle(pdf, text.T("Missed Injections"))
+
+		pdf.SetFont("Arial", "B", 9)
+		pdf.SetFillColor(200, 200, 200)
+		pdf.CellFormat(35, 7, text.T("Due"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(40, 7, text.T("Course"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(35, 7, text.T("Reason"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(70, 7, text.T("Notes"), "1", 1, "C", true, 0, "")
+
+		const maxRows = 25
+		pdf.SetFont("Arial", "", 8)
+		for i, m := range data.Missed {
+			if i == maxRows {
+				pdf.SetFont("Arial", "I", 9)
+				pdf.CellFormat(0, 5, text.T("Showing %d of %d missed injections.", maxRows, len(data.Missed)), "", 1, "L", false, 0, "")
+				break
+			}
+			pdf.CellFormat(35, 6, m.DueAt.In(loc).Format("2006-01-02 15:04"), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(40, 6, tr(truncateString(m.Course, 22)), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(35, 6, text.tr(missedReasonLabel(p, m.Reason)), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(70, 6, tr(truncateString(m.Note, 40)), "1", 1, "L", false, 0, "")
+			if pdf.GetY() > 260 && i < len(data.Missed)-1 {
+				pdf.AddPage()
+			}
+		}
+		pdf.Ln(5)
+	}
+
+	// Symptoms Section
+	if len(data.Symptoms) > 0 {
+		if len(data.Injections) == 0 || pdf.GetY() > 220 {
+			pdf.AddPage()
+		}
+		pdfSectionTitle(pdf, text.T("Symptom Log"))
+
 		// Table Header
 		pdf.SetFont("Arial", "B", 9)
 		pdf.SetFillColor(200, 200, 200)
-		pdf.CellFormat(25, 7, "Date", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(15, 7, "Time", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(15, 7, "Pain", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(35, 7, "Location", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(30, 7, "Type", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(60, 7, "Notes", "1", 1, "C", true, 0, "")
+		pdf.CellFormat(25, 7, text.T("Date"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(15, 7, text.T("Time"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(15, 7, text.T("Pain"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(35, 7, text.T("Location"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(30, 7, text.T("Type"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(60, 7, text.T("Notes"), "1", 1, "C", true, 0, "")
 
 		// Table Data
 		pdf.SetFont("Arial", "", 8)
@@ -596,13 +2075,18 @@ func generatePDF(data *ExportData) ([]byte, error) {
 
 		for i := 0; i < maxRows; i++ {
 			sym := data.Symptoms[i]
+			ts := sym.Timestamp.In(loc)
+			pain := "-"
+			if sym.HasPain {
+				pain = strconv.Itoa(sym.PainLevel)
+			}
 
-			pdf.CellFormat(25, 6, sym.Timestamp.Format("2006-01-02"), "1", 0, "L", false, 0, "")
-			pdf.CellFormat(15, 6, sym.Timestamp.Format("15:04"), "1", 0, "L", false, 0, "")
-			pdf.CellFormat(15, 6, fmt.Sprintf("%d", sym.PainLevel), "1", 0, "C", false, 0, "")
-			pdf.CellFormat(35, 6, truncateString(sym.PainLocation, 15), "1", 0, "L", false, 0, "")
-			pdf.CellFormat(30, 6, truncateString(sym.PainType, 12), "1", 0, "L", false, 0, "")
-			pdf.CellFormat(60, 6, truncateString(sym.Notes, 30), "1", 1, "L", false, 0, "")
+			pdf.CellFormat(25, 6, ts.Format("2006-01-02"), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(15, 6, ts.Format("15:04"), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(15, 6, pain, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(35, 6, tr(truncateString(sym.PainLocation, 15)), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(30, 6, tr(truncateString(sym.PainType, 12)), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(60, 6, tr(truncateString(sym.Notes, 30)), "1", 1, "L", false, 0, "")
 
 			if pdf.GetY() > 260 && i < maxRows-1 {
 				pdf.AddPage()
@@ -612,15 +2096,124 @@ func generatePDF(data *ExportData) ([]byte, error) {
 		if len(data.Symptoms) > maxRows {
 			pdf.Ln(3)
 			pdf.SetFont("Arial", "I", 9)
-			pdf.CellFormat(0, 5, fmt.Sprintf("Showing %d of %d symptoms. Export CSV for complete data.", maxRows, len(data.Symptoms)), "", 1, "L", false, 0, "")
+			pdf.CellFormat(0, 5, text.T("Showing %d of %d symptoms. Export CSV for complete data.", maxRows, len(data.Symptoms)), "", 1, "L", false, 0, "")
 		}
 	}
 
-	// Footer
-	pdf.SetY(-20)
-	pdf.SetFont("Arial", "I", 8)
-	pdf.SetTextColor(128, 128, 128)
-	pdf.CellFormat(0, 10, fmt.Sprintf("Generated on %s - P-TRACK Medical Report", time.Now().Format("January 2, 2006 at 3:04 PM")), "", 0, "C", false, 0, "")
+	// Check-ins section: mood and energy over the period, then the log
+	if len(data.CheckIns) > 0 {
+		pdf.AddPage()
+		pdfSectionTitle(pdf, text.T("Wellness Check-ins"))
+		y := pdf.GetY()
+		drawLineChart(pdf, text, 15, y, 180, 55, p.T("Mood and Energy (daily average)"), data.StartDate, data.EndDate, 5, wellnessSeries(p, data))
+		pdf.SetXY(15, y+60)
+
+		pdf.SetFont("Arial", "B", 9)
+		pdf.SetFillColor(200, 200, 200)
+		pdf.CellFormat(25, 7, text.T("Date"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(30, 7, text.T("Member"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(15, 7, text.T("Mood"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(15, 7, text.T("Energy"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(15, 7, text.T("Sleep"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(15, 7, text.T("Temp"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(20, 7, text.T("Weight"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(45, 7, text.T("Notes"), "1", 1, "C", true, 0, "")
+
+		dash := func(s, unit string) string {
+			if s == "" {
+				return "-"
+			}
+			return s + unit
+		}
+		pdf.SetFont("Arial", "", 8)
+		maxRows := 25
+		if len(data.CheckIns) < maxRows {
+			maxRows = len(data.CheckIns)
+		}
+		for i := 0; i < maxRows; i++ {
+			c := data.CheckIns[i]
+			pdf.CellFormat(25, 6, c.Date.UTC().Format("2006-01-02"), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(30, 6, tr(truncateString(c.Member, 15)), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(15, 6, dash(formatNullInt(c.Mood), ""), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(15, 6, dash(formatNullInt(c.Energy), ""), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(15, 6, dash(formatNullFloat(c.SleepHours), " h"), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(15, 6, dash(formatNullFloat(c.Temperature), " C"), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(20, 6, dash(formatNullFloat(c.Weight), " kg"), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(45, 6, tr(truncateString(c.Notes, 22)), "1", 1, "L", false, 0, "")
+
+			if pdf.GetY() > 260 && i < maxRows-1 {
+				pdf.AddPage()
+			}
+		}
+
+		if len(data.CheckIns) > maxRows {
+			pdf.Ln(3)
+			pdf.SetFont("Arial", "I", 9)
+			pdf.CellFormat(0, 5, text.T("Showing %d of %d check-ins. Export CSV for complete data.", maxRows, len(data.CheckIns)), "", 1, "L", false, 0, "")
+		}
+	}
+
+	if len(data.Labs) > 0 {
+		pdf.AddPage()
+		pdfSectionTitle(pdf, text.T("Lab Results"))
+		y := pdf.GetY()
+		for _, chart := range labCharts(data, maxPDFLabCharts) {
+			drawLineChart(pdf, text, 15, y, 180, 50, chart.Title, data.StartDate, data.EndDate, chart.YMax, chart.Series)
+			y += 55
+		}
+		pdf.SetXY(15, y)
+
+		pdf.SetFont("Arial", "B", 9)
+		pdf.SetFillColor(200, 200, 200)
+		pdf.CellFormat(32, 7, text.T("Drawn"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(35, 7, text.T("Analyte"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(28, 7, text.T("Value"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(30, 7, text.T("Reference"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(12, 7, text.T("Flag"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(20, 7, text.T("Last Dose"), "1", 0, "C", true, 0, "")
+		pdf.CellFormat(23, 7, text.T("Hours After"), "1", 1, "C", true, 0, "")
+
+		loc := exportLocation(data)
+		pdf.SetFont("Arial", "", 8)
+		maxRows := 30
+		if len(data.Labs) < maxRows {
+			maxRows = len(data.Labs)
+		}
+		for i := 0; i < maxRows; i++ {
+			l := data.Labs[i]
+			reference := "-"
+			switch {
+			case l.ReferenceLow.Valid && l.ReferenceHigh.Valid:
+				reference = formatNullFloat(l.ReferenceLow) + " - " + formatNullFloat(l.ReferenceHigh)
+			case l.ReferenceLow.Valid:
+				reference = ">= " + formatNullFloat(l.ReferenceLow)
+			case l.ReferenceHigh.Valid:
+				reference = "<= " + formatNullFloat(l.ReferenceHigh)
+			}
+			dose, after := "-", "-"
+			if l.PriorDoseAt.Valid {
+				dose = formatDose(l.PriorDoseML.Float64) + " mL"
+				after = fmt.Sprintf("%.0f", l.DrawnAt.Sub(l.PriorDoseAt.Time).Hours())
+			}
+			pdf.CellFormat(32, 6, l.DrawnAt.In(loc).Format("2006-01-02 15:04"), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(35, 6, tr(truncateString(l.Analyte, 18)), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(28, 6, tr(truncateString(formatDose(l.Value)+" "+l.Unit, 14)), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(30, 6, reference, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(12, 6, l.Flag, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(20, 6, dose, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(23, 6, after, "1", 1, "C", false, 0, "")
+
+			if pdf.GetY() > 260 && i < maxRows-1 {
+				pdf.AddPage()
+			}
+		}
+
+		if len(data.Labs) > maxRows {
+			pdf.Ln(3)
+			pdf.SetFont("Arial", "I", 9)
+			pdf.CellFormat(0, 5, text.T("Showing %d of %d lab results. Export CSV for complete data.", maxRows, len(data.Labs)), "", 1, "L", false, 0, "")
+		}
+	}
 
 	var buf bytes.Buffer
 	err := pdf.Output(&buf)
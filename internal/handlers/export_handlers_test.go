@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/services"
+)
+
+func TestComputeReportStats(t *testing.T) {
+	loc := time.UTC
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, loc)
+	data := &ExportData{
+		StartDate: start,
+		EndDate:   start.AddDate(0, 0, 4),
+		Courses:   []services.CoursePeriod{{Start: start}},
+		Location:  loc,
+		Injections: []ExportInjection{
+			{Timestamp: start.Add(9 * time.Hour), Side: "left", PainLevel: 4, HasPain: true, HasKnots: true},
+			{Timestamp: start.Add(33 * time.Hour), Side: "right"}, // No pain recorded
+			{Timestamp: start.Add(57 * time.Hour), Side: "left", PainLevel: 2, HasPain: true},
+		},
+		Medications: []ExportMedication{{Taken: true}, {Taken: false}},
+	}
+
+	stats := computeReportStats(data)
+	if stats.Injections != 3 || stats.Left != 2 || stats.Right != 1 || stats.Knots != 1 {
+		t.Errorf("Unexpected counts: %+v", stats)
+	}
+	if !stats.InjectionPain.Valid || stats.InjectionPain.Float64 != 3 {
+		t.Errorf("Expected unrecorded pain to be left out of the average, got %v", stats.InjectionPain)
+	}
+	if stats.SymptomPain.Valid {
+		t.Error("Expected no symptom pain average without symptom logs")
+	}
+	if stats.ExpectedDays != 4 || stats.InjectedDays != 3 {
+		t.Errorf("Expected 3 of 4 days, got %d of %d", stats.InjectedDays, stats.ExpectedDays)
+	}
+	if stats.MedsLogged != 2 || stats.MedsTaken != 1 {
+		t.Errorf("Expected 1 of 2 medications taken, got %d of %d", stats.MedsTaken, stats.MedsLogged)
+	}
+}
+
+func TestFormatChange(t *testing.T) {
+	cases := []struct {
+		diff   float64
+		format string
+		unit   string
+		want   string
+	}{
+		{2, "%.0f", "", "+2"},
+		{-1.25, "%.1f", "", "-1.2"},
+		{0.01, "%.1f", "", "no change"},
+		{-10, "%.0f", " pts", "-10 pts"},
+	}
+	for _, c := range cases {
+		if got := formatChange(i18n.Default(), c.diff, c.format, c.unit); got != c.want {
+			t.Errorf("formatChange(%v) = %q, want %q", c.diff, got, c.want)
+		}
+	}
+}
+
+func TestGeneratePDF(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := &ExportData{
+		StartDate: start,
+		EndDate:   start.AddDate(0, 0, 30),
+		Courses:   []services.CoursePeriod{{Start: start}},
+		Injections: []ExportInjection{
+			{Timestamp: start.Add(24 * time.Hour), Side: "left", PainLevel: 3, HasPain: true, DoseML: 1},
+			{Timestamp: start.Add(240 * time.Hour), Side: "right", DoseML: 1, Trip: "Tokyo", TripLocal: start.Add(240 * time.Hour).In(time.FixedZone("JST", 9*3600))},
+		},
+		Symptoms: []ExportSymptom{{Timestamp: start.Add(48 * time.Hour), PainLevel: 5, HasPain: true}},
+		Trips: []*models.Trip{{
+			Destination:  "Tokyo",
+			Timezone:     "Asia/Tokyo",
+			HomeTimezone: "UTC",
+			StartsAt:     start.AddDate(0, 0, 9),
+			EndsAt:       start.AddDate(0, 0, 14),
+			Shift:        models.TripShiftGradual,
+		}},
+	}
+	if note := travelNote(i18n.Default(), data.Injections[1]); note != "Tokyo, 2025-01-11 09:00 JST local time" {
+		t.Errorf("Unexpected travel note %q", note)
+	}
+	data.Previous = &ExportData{StartDate: start.AddDate(0, 0, -30), EndDate: start}
+
+	// A letterhead and an empty period must both render
+	site := &SiteSettings{SiteTitle: "Tracker", ReportLetterhead: "Clinic Café\n1 Main St"}
+	for _, d := range []*ExportData{data, data.Previous} {
+		out, err := generatePDF(d, site, i18n.Default())
+		if err != nil {
+			t.Fatalf("Failed to generate PDF: %v", err)
+		}
+		if !bytes.HasPrefix(out, []byte("%PDF-")) {
+			t.Error("Expected PDF output")
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// featureStates collects the admin's settings of every flag for an account
+func featureStates(overrides []*models.FeatureFlagOverride, accountID int64) map[string]services.FeatureState {
+	states := make(map[string]services.FeatureState)
+	for _, o := range overrides {
+		enabled := o.Enabled
+		state := states[o.Key]
+		switch {
+		case !o.AccountID.Valid:
+			state.Global = &enabled
+		case o.AccountID.Int64 == accountID:
+			state.Account = &enabled
+		default:
+			continue
+		}
+		states[o.Key] = state
+	}
+	return states
+}
+
+// enabledFeatures resolves every feature flag for an account. If the flags
+// can't be loaded each one takes its default.
+func enabledFeatures(db *database.DB, accountID int64) map[string]bool {
+	overrides, err := repository.NewFeatureFlagRepository(db).ForAccount(accountID)
+	if err != nil {
+		slog.Error("Failed to load feature flags", "err", err)
+	}
+	states := featureStates(overrides, accountID)
+
+	features := make(map[string]bool, len(services.FeatureFlags))
+	for _, f := range services.FeatureFlags {
+		features[f.Key] = f.Enabled(states[f.Key])
+	}
+	return features
+}
+
+// FeatureEnabled reports whether a feature is on for an account
+func FeatureEnabled(db *database.DB, accountID int64, key string) bool {
+	return enabledFeatures(db, accountID)[key]
+}
+
+// RequireFeature answers 404 Not Found for requests from accounts the
+// feature is off for, as though the route didn't exist. It must come after
+// authentication.
+func RequireFeature(db *database.DB, key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			accountID := middleware.GetAccountID(r.Context())
+			if !FeatureEnabled(db.WithContext(r.Context()), accountID, key) {
+				respond.Error(w, "Not found", http.StatusNotFound)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HandleGetFeatures returns which features are on for the user's account
+func HandleGetFeatures(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(enabledFeatures(db, accountID))
+	}
+}
+
+// FeatureFlagResponse is a feature flag with the admin's settings of it.
+// Global is nil when the site-wide setting is the default; Accounts maps
+// account IDs to their own settings.
+type FeatureFlagResponse struct {
+	services.FeatureFlag
+	Global   *bool          `json:"global"`
+	Accounts map[int64]bool `json:"accounts"`
+}
+
+// HandleListFeatureFlags returns every feature flag and how it's set
+func HandleListFeatureFlags(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		overrides, err := repository.NewFeatureFlagRepository(db).List()
+		if err != nil {
+			respond.Error(w, "Failed to load feature flags", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]FeatureFlagResponse, 0, len(services.FeatureFlags))
+		index := make(map[string]int, len(services.FeatureFlags))
+		for i, f := range services.FeatureFlags {
+			resp = append(resp, FeatureFlagResponse{FeatureFlag: f, Accounts: map[int64]bool{}})
+			index[f.Key] = i
+		}
+		for _, o := range overrides {
+			i, ok := index[o.Key]
+			if !ok {
+				// Left behind by a flag that's since been removed
+				continue
+			}
+			if o.AccountID.Valid {
+				resp[i].Accounts[o.AccountID.Int64] = o.Enabled
+			} else {
+				enabled := o.Enabled
+				resp[i].Global = &enabled
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// FeatureFlagRequest names a feature flag setting: site-wide when AccountID
+// is zero, for that account otherwise. Enabled is ignored when clearing it.
+type FeatureFlagRequest struct {
+	Key       string `json:"key" validate:"required,max=100"`
+	AccountID int64  `json:"account_id,omitempty"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// decodeFeatureFlagRequest reads and checks a FeatureFlagRequest, writing
+// the error response if it isn't valid
+func decodeFeatureFlagRequest(w http.ResponseWriter, r *http.Request, db *database.DB) (FeatureFlagRequest, bool) {
+	var req FeatureFlagRequest
+	if !decodeRequest(w, r, &req) {
+		return req, false
+	}
+	if _, ok := services.LookupFeatureFlag(req.Key); !ok {
+		respond.Validation(w, "", respond.Field("key", "is not a known feature flag"))
+		return req, false
+	}
+	if req.AccountID < 0 {
+		respond.Validation(w, "", respond.Field("account_id", "must be positive"))
+		return req, false
+	}
+	if req.AccountID != 0 {
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM accounts WHERE id = ?)`, req.AccountID).Scan(&exists); err != nil {
+			respond.Error(w, "Failed to load account", http.StatusInternalServerError)
+			return req, false
+		}
+		if !exists {
+			respond.Error(w, "Account not found", http.StatusNotFound)
+			return req, false
+		}
+	}
+	return req, true
+}
+
+// HandleSetFeatureFlag switches a feature on or off, site-wide or for one
+// account
+func HandleSetFeatureFlag(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		req, ok := decodeFeatureFlagRequest(w, r, db)
+		if !ok {
+			return
+		}
+		accountID := sql.NullInt64{Int64: req.AccountID, Valid: req.AccountID != 0}
+		if err := repository.NewFeatureFlagRepository(db).Set(req.Key, accountID, req.Enabled, userID); err != nil {
+			respond.Error(w, "Failed to save feature flag", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"feature_flag",
+			sql.NullInt64{},
+			map[string]interface{}{"key": req.Key, "account_id": req.AccountID, "enabled": req.Enabled},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+		middleware.Log(r.Context()).Info("Feature flag changed", "key", req.Key, "account_id", req.AccountID, "enabled", req.Enabled)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(req)
+	}
+}
+
+// HandleClearFeatureFlag removes a feature flag setting, so the account
+// follows the site-wide setting or the site follows the default
+func HandleClearFeatureFlag(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 || !IsAdmin(db, userID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		req, ok := decodeFeatureFlagRequest(w, r, db)
+		if !ok {
+			return
+		}
+		accountID := sql.NullInt64{Int64: req.AccountID, Valid: req.AccountID != 0}
+		if err := repository.NewFeatureFlagRepository(db).Clear(req.Key, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Feature flag not set", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to clear feature flag", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"feature_flag",
+			sql.NullInt64{},
+			map[string]interface{}{"key": req.Key, "account_id": req.AccountID},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FHIR R4 resources for exporting records to patient portals and other
+// health tools. Only the fields this app has data for are modelled.
+// Injections and medication logs become MedicationAdministration resources,
+// symptom logs and injection pain become Observations, all about a single
+// Patient standing for the account.
+
+const (
+	fhirSNOMED           = "http://snomed.info/sct"
+	fhirLOINC            = "http://loinc.org"
+	fhirUCUM             = "http://unitsofmeasure.org"
+	fhirObservationClass = "http://terminology.hl7.org/CodeSystem/observation-category"
+)
+
+type fhirCoding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type fhirCodeableConcept struct {
+	Coding []fhirCoding `json:"coding,omitempty"`
+	Text   string       `json:"text,omitempty"`
+}
+
+type fhirReference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+type fhirQuantity struct {
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit,omitempty"`
+	System string  `json:"system,omitempty"`
+	Code   string  `json:"code,omitempty"`
+}
+
+type fhirAnnotation struct {
+	Text string `json:"text"`
+}
+
+type fhirHumanName struct {
+	Text string `json:"text"`
+}
+
+type fhirBundle struct {
+	ResourceType string            `json:"resourceType"`
+	Type         string            `json:"type"`
+	Timestamp    string            `json:"timestamp"`
+	Entry        []fhirBundleEntry `json:"entry"`
+}
+
+type fhirBundleEntry struct {
+	Resource interface{} `json:"resource"`
+}
+
+type fhirPatient struct {
+	ResourceType string          `json:"resourceType"`
+	ID           string          `json:"id"`
+	Name         []fhirHumanName `json:"name,omitempty"`
+}
+
+type fhirPerformer struct {
+	Actor fhirReference `json:"actor"`
+}
+
+type fhirDosage struct {
+	Text  string               `json:"text,omitempty"`
+	Site  *fhirCodeableConcept `json:"site,omitempty"`
+	Route *fhirCodeableConcept `json:"route,omitempty"`
+	Dose  *fhirQuantity        `json:"dose,omitempty"`
+}
+
+type fhirMedicationAdministration struct {
+	ResourceType              string              `json:"resourceType"`
+	ID                        string              `json:"id"`
+	Status                    string              `json:"status"`
+	MedicationCodeableConcept fhirCodeableConcept `json:"medicationCodeableConcept"`
+	Subject                   fhirReference       `json:"subject"`
+	EffectiveDateTime         string              `json:"effectiveDateTime"`
+	Performer                 []fhirPerformer     `json:"performer,omitempty"`
+	Note                      []fhirAnnotation    `json:"note,omitempty"`
+	Dosage                    *fhirDosage         `json:"dosage,omitempty"`
+}
+
+type fhirObservation struct {
+	ResourceType      string                `json:"resourceType"`
+	ID                string                `json:"id"`
+	Status            string                `json:"status"`
+	PartOf            []fhirReference       `json:"partOf,omitempty"`
+	Category          []fhirCodeableConcept `json:"category"`
+	Code              fhirCodeableConcept   `json:"code"`
+	Subject           fhirReference         `json:"subject"`
+	EffectiveDateTime string                `json:"effectiveDateTime"`
+	ValueInteger      *int                  `json:"valueInteger,omitempty"`
+	ValueString       string                `json:"valueString,omitempty"`
+	BodySite          *fhirCodeableConcept  `json:"bodySite,omitempty"`
+	Note              []fhirAnnotation      `json:"note,omitempty"`
+}
+
+// fhirRoutes maps compound routes to SNOMED CT route of administration codes
+var fhirRoutes = map[string]fhirCoding{
+	"intramuscular": {System: fhirSNOMED, Code: "78421000", Display: "Intramuscular route"},
+	"subcutaneous":  {System: fhirSNOMED, Code: "34206005", Display: "Subcutaneous route"},
+	"intradermal":   {System: fhirSNOMED, Code: "372464004", Display: "Intradermal route"},
+}
+
+// fhirPainScore is the LOINC 0-10 pain severity rating
+var fhirPainScore = fhirCodeableConcept{
+	Coding: []fhirCoding{{System: fhirLOINC, Code: "72514-3", Display: "Pain severity - 0-10 verbal numeric rating [Score] - Reported"}},
+	Text:   "Pain level",
+}
+
+var fhirSurveyCategory = []fhirCodeableConcept{{
+	Coding: []fhirCoding{{System: fhirObservationClass, Code: "survey", Display: "Survey"}},
+}}
+
+func fhirTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// fhirNotes turns non-empty strings into annotations
+func fhirNotes(texts ...string) []fhirAnnotation {
+	var notes []fhirAnnotation
+	for _, text := range texts {
+		if text = strings.TrimSpace(text); text != "" {
+			notes = append(notes, fhirAnnotation{Text: text})
+		}
+	}
+	return notes
+}
+
+// symptomList reads the stored JSON array of symptoms as a comma-separated
+// list, falling back to the raw text
+func symptomList(raw string) string {
+	var symptoms []string
+	if err := json.Unmarshal([]byte(raw), &symptoms); err != nil {
+		return raw
+	}
+	return strings.Join(symptoms, ", ")
+}
+
+// buildFHIRBundle converts export data for an account into a FHIR R4
+// collection Bundle
+func buildFHIRBundle(data *ExportData, accountID int64, accountName string, generated time.Time) *fhirBundle {
+	patient := fhirPatient{ResourceType: "Patient", ID: fmt.Sprintf("account-%d", accountID)}
+	if accountName != "" {
+		patient.Name = []fhirHumanName{{Text: accountName}}
+	}
+	subject := fhirReference{Reference: "Patient/" + patient.ID}
+
+	bundle := &fhirBundle{
+		ResourceType: "Bundle",
+		Type:         "collection",
+		Timestamp:    fhirTime(generated),
+		Entry:        []fhirBundleEntry{{Resource: patient}},
+	}
+
+	for _, inj := range data.Injections {
+		id := fmt.Sprintf("injection-%d", inj.ID)
+
+		medication := inj.Compound
+		if medication == "" {
+			medication = "Progesterone in oil"
+		}
+		dosage := &fhirDosage{
+			Text: formatDose(inj.DoseML) + " mL",
+			Dose: &fhirQuantity{Value: inj.DoseML, Unit: "mL", System: fhirUCUM, Code: "mL"},
+		}
+		if inj.DoseMG.Valid {
+			dosage.Text += fmt.Sprintf(" (%s mg)", formatDose(inj.DoseMG.Float64))
+		}
+		if inj.Side != "" {
+			dosage.Site = &fhirCodeableConcept{Text: strings.ToUpper(inj.Side[:1]) + inj.Side[1:] + " side"}
+		}
+		if coding, ok := fhirRoutes[inj.Route]; ok {
+			dosage.Route = &fhirCodeableConcept{Coding: []fhirCoding{coding}, Text: coding.Display}
+		} else if inj.Route != "" {
+			dosage.Route = &fhirCodeableConcept{Text: inj.Route}
+		}
+
+		admin := fhirMedicationAdministration{
+			ResourceType:              "MedicationAdministration",
+			ID:                        id,
+			Status:                    "completed",
+			MedicationCodeableConcept: fhirCodeableConcept{Text: medication},
+			Subject:                   subject,
+			EffectiveDateTime:         fhirTime(inj.Timestamp),
+			Dosage:                    dosage,
+		}
+		if inj.AdministeredBy != "" {
+			admin.Performer = []fhirPerformer{{Actor: fhirReference{Display: inj.AdministeredBy}}}
+		}
+		var knots string
+		if inj.HasKnots {
+			knots = "Knots at the injection site"
+		}
+		var reaction string
+		if inj.SiteReaction != "" {
+			reaction = "Site reaction: " + inj.SiteReaction
+		}
+		admin.Note = fhirNotes(knots, reaction, inj.Notes)
+		bundle.Entry = append(bundle.Entry, fhirBundleEntry{Resource: admin})
+
+		if inj.HasPain {
+			pain := inj.PainLevel
+			bundle.Entry = append(bundle.Entry, fhirBundleEntry{Resource: fhirObservation{
+				ResourceType:      "Observation",
+				ID:                id + "-pain",
+				Status:            "final",
+				PartOf:            []fhirReference{{Reference: "MedicationAdministration/" + id}},
+				Category:          fhirSurveyCategory,
+				Code:              fhirPainScore,
+				Subject:           subject,
+				EffectiveDateTime: fhirTime(inj.Timestamp),
+				ValueInteger:      &pain,
+			}})
+		}
+	}
+
+	for _, med := range data.Medications {
+		status := "completed"
+		if !med.Taken {
+			status = "not-done"
+		}
+		admin := fhirMedicationAdministration{
+			ResourceType:              "MedicationAdministration",
+			ID:                        fmt.Sprintf("medication-log-%d", med.ID),
+			Status:                    status,
+			MedicationCodeableConcept: fhirCodeableConcept{Text: med.MedicationName},
+			Subject:                   subject,
+			EffectiveDateTime:         fhirTime(med.Timestamp),
+			Note:                      fhirNotes(med.Notes),
+		}
+		if med.Dosage != "" {
+			admin.Dosage = &fhirDosage{Text: med.Dosage}
+		}
+		bundle.Entry = append(bundle.Entry, fhirBundleEntry{Resource: admin})
+	}
+
+	for _, sym := range data.Symptoms {
+		obs := fhirObservation{
+			ResourceType:      "Observation",
+			ID:                fmt.Sprintf("symptom-%d", sym.ID),
+			Status:            "final",
+			Category:          fhirSurveyCategory,
+			Subject:           subject,
+			EffectiveDateTime: fhirTime(sym.Timestamp),
+		}
+		symptoms := symptomList(sym.Symptoms)
+		var painType string
+		if sym.PainType != "" {
+			painType = "Pain type: " + sym.PainType
+		}
+		if sym.HasPain {
+			pain := sym.PainLevel
+			obs.Code = fhirPainScore
+			obs.ValueInteger = &pain
+			if symptoms != "" {
+				symptoms = "Symptoms: " + symptoms
+			}
+			obs.Note = fhirNotes(painType, symptoms, sym.Notes)
+		} else {
+			// Without a pain score, report the symptoms themselves
+			obs.Code = fhirCodeableConcept{Text: "Symptoms"}
+			obs.ValueString = symptoms
+			obs.Note = fhirNotes(painType, sym.Notes)
+		}
+		if sym.PainLocation != "" {
+			obs.BodySite = &fhirCodeableConcept{Text: sym.PainLocation}
+		}
+		bundle.Entry = append(bundle.Entry, fhirBundleEntry{Resource: obs})
+	}
+
+	return bundle
+}
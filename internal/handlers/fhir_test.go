@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestBuildFHIRBundle(t *testing.T) {
+	at := time.Date(2025, 1, 2, 18, 0, 0, 0, time.UTC)
+	data := &ExportData{
+		Injections: []ExportInjection{{
+			ID:        7,
+			Timestamp: at,
+			Side:      "left",
+			PainLevel: 3,
+			HasPain:   true,
+			DoseML:    1,
+			DoseMG:    sql.NullFloat64{Float64: 50, Valid: true},
+			Compound:  "Progesterone",
+			Route:     "intramuscular",
+		}},
+		Medications: []ExportMedication{{ID: 3, Timestamp: at, MedicationName: "Estradiol", Taken: false}},
+		Symptoms:    []ExportSymptom{{ID: 5, Timestamp: at, Symptoms: `["itching","swelling"]`}},
+	}
+
+	body, err := json.Marshal(buildFHIRBundle(data, 1, "Smith Family", at))
+	if err != nil {
+		t.Fatalf("Failed to encode bundle: %v", err)
+	}
+
+	var bundle struct {
+		ResourceType string
+		Type         string
+		Entry        []struct {
+			Resource map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(body, &bundle); err != nil {
+		t.Fatalf("Failed to decode bundle: %v", err)
+	}
+	if bundle.ResourceType != "Bundle" || bundle.Type != "collection" {
+		t.Errorf("Expected a collection Bundle, got %s %s", bundle.ResourceType, bundle.Type)
+	}
+
+	resources := map[string]map[string]interface{}{}
+	for _, e := range bundle.Entry {
+		resources[e.Resource["resourceType"].(string)+"/"+e.Resource["id"].(string)] = e.Resource
+	}
+	if len(resources) != 5 {
+		t.Fatalf("Expected patient, 2 administrations and 2 observations, got %v", resources)
+	}
+
+	injection := resources["MedicationAdministration/injection-7"]
+	if injection["status"] != "completed" || injection["effectiveDateTime"] != "2025-01-02T18:00:00Z" {
+		t.Errorf("Unexpected injection: %v", injection)
+	}
+	dosage := injection["dosage"].(map[string]interface{})
+	if dosage["text"] != "1 mL (50 mg)" {
+		t.Errorf("Expected dose in mL and mg, got %v", dosage["text"])
+	}
+	if route := dosage["route"].(map[string]interface{}); route["text"] != "Intramuscular route" {
+		t.Errorf("Expected a coded route, got %v", route)
+	}
+
+	pain := resources["Observation/injection-7-pain"]
+	if pain["valueInteger"] != float64(3) {
+		t.Errorf("Expected injection pain as an observation, got %v", pain)
+	}
+
+	if med := resources["MedicationAdministration/medication-log-3"]; med["status"] != "not-done" {
+		t.Errorf("Expected a skipped dose to be not-done, got %v", med["status"])
+	}
+
+	symptom := resources["Observation/symptom-5"]
+	if symptom["valueString"] != "itching, swelling" || symptom["valueInteger"] != nil {
+		t.Errorf("Expected symptoms without pain as text, got %v", symptom)
+	}
+
+	if patient := resources["Patient/account-1"]; patient == nil {
+		t.Error("Expected a patient for the account")
+	}
+}
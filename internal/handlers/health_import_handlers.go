@@ -0,0 +1,567 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+const (
+	// maxAppleHealthBytes caps an Apple Health export.xml, which is streamed
+	maxAppleHealthBytes = 512 << 20
+	// maxGoogleFitBytes caps a Google Fit takeout file, which is read whole
+	maxGoogleFitBytes = 50 << 20
+)
+
+// healthSourceNames are shown in notes on imported records
+var healthSourceNames = map[string]string{
+	services.HealthSourceApple:  "Apple Health",
+	services.HealthSourceGoogle: "Google Fit",
+}
+
+// HealthImportTypeResult reports what happened to the records of one type
+type HealthImportTypeResult struct {
+	SourceType string `json:"source_type"`
+	Target     string `json:"target"`
+	Found      int    `json:"found"`
+	Imported   int    `json:"imported"`
+	Duplicates int    `json:"duplicates"`
+	Skipped    int    `json:"skipped"`
+	Error      string `json:"error,omitempty"` // Why records were skipped
+}
+
+// HealthImportResponse summarises a health app import. On a dry run,
+// Imported is what would have been imported.
+type HealthImportResponse struct {
+	DryRun     bool                     `json:"dry_run"`
+	Source     string                   `json:"source"`
+	CourseID   *int64                   `json:"course_id,omitempty"` // Course symptoms were logged against
+	Found      int                      `json:"found"`
+	Imported   int                      `json:"imported"`
+	Duplicates int                      `json:"duplicates"`
+	Skipped    int                      `json:"skipped"`
+	Types      []HealthImportTypeResult `json:"types"`
+}
+
+// HealthImportMapping is one record type's mapping, for the mapping screen
+type HealthImportMapping struct {
+	SourceType    string `json:"source_type"`
+	Source        string `json:"source"`
+	DefaultTarget string `json:"default_target,omitempty"`
+	Target        string `json:"target"`
+}
+
+// HealthImportMappingsResponse lists every mapped record type along with the
+// vital types (and their units) a record can map to
+type HealthImportMappingsResponse struct {
+	Mappings   []HealthImportMapping `json:"mappings"`
+	VitalTypes map[string]string     `json:"vital_types"`
+}
+
+// UpdateHealthImportMappingsRequest sets targets by record type; an empty
+// target restores the default
+type UpdateHealthImportMappingsRequest struct {
+	Mappings map[string]string `json:"mappings"`
+}
+
+// VitalResponse is a vital as returned by the API
+type VitalResponse struct {
+	ID         int64     `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Type       string    `json:"type"`
+	Value      float64   `json:"value"`
+	Unit       string    `json:"unit"`
+	Source     string    `json:"source"`
+	Notes      *string   `json:"notes,omitempty"`
+	RecordedBy *int64    `json:"recorded_by,omitempty"`
+}
+
+// healthImportSymptom is a symptom log ready to insert
+type healthImportSymptom struct {
+	timestamp time.Time
+	symptoms  string // JSON array, as stored
+	notes     string
+	result    *HealthImportTypeResult
+}
+
+// HandleImportHealth imports body temperature, weight and symptoms from an
+// Apple Health export.xml (application/xml) or a Google Fit takeout JSON
+// file (application/json). Record types are mapped to vitals or symptoms by
+// the account's health import mappings. Vitals already recorded at the same
+// time, and symptoms already logged at the same minute, are skipped as
+// duplicates. Symptoms are logged against course_id, or the active course.
+// dry_run=true reports what would be imported without writing anything.
+func HandleImportHealth(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		dryRun := query.Get("dry_run") == "true"
+
+		var courseID int64
+		courseRepo := repository.NewCourseRepository(db)
+		if v := query.Get("course_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				respond.Validation(w, "Invalid course_id", respond.Field("course_id", "is invalid"))
+				return
+			}
+			if _, err := courseRepo.GetByID(id, accountID); err != nil {
+				respond.Error(w, "Course not found", http.StatusBadRequest)
+				return
+			}
+			courseID = id
+		} else if course, err := courseRepo.GetActiveCourse(accountID); err == nil {
+			courseID = course.ID
+		}
+
+		vitalRepo := repository.NewVitalRepository(db)
+		mappings, err := healthImportMappings(vitalRepo, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to load import mappings", http.StatusInternalServerError)
+			return
+		}
+		wanted := func(sourceType string) bool {
+			target, ok := mappings[sourceType]
+			return ok && target != services.HealthTargetIgnore
+		}
+
+		var source string
+		var records []services.HealthRecord
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/xml", "text/xml":
+			source = services.HealthSourceApple
+			r.Body = http.MaxBytesReader(w, r.Body, maxAppleHealthBytes)
+			records, err = services.ParseAppleHealth(r.Body, wanted)
+		case "application/json":
+			source = services.HealthSourceGoogle
+			r.Body = http.MaxBytesReader(w, r.Body, maxGoogleFitBytes)
+			records, err = services.ParseGoogleFit(r.Body, wanted)
+		default:
+			respond.Error(w, "Content-Type must be application/xml (Apple Health) or application/json (Google Fit)", http.StatusUnsupportedMediaType)
+			return
+		}
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				respond.Error(w, "Import file is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		vitalTimes, err := vitalRepo.Times(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to check for duplicates", http.StatusInternalServerError)
+			return
+		}
+		symptomKeys, err := accountSymptomKeys(db, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to check for duplicates", http.StatusInternalServerError)
+			return
+		}
+
+		resp := HealthImportResponse{DryRun: dryRun, Source: source}
+		if courseID != 0 {
+			resp.CourseID = &courseID
+		}
+		results := make(map[string]*HealthImportTypeResult)
+		sourceNote := "Imported from " + healthSourceNames[source]
+
+		var vitals []*models.Vital
+		var vitalResults []*HealthImportTypeResult
+		var symptoms []healthImportSymptom
+		for _, record := range records {
+			target := mappings[record.SourceType]
+			result := results[record.SourceType]
+			if result == nil {
+				result = &HealthImportTypeResult{SourceType: record.SourceType, Target: target}
+				results[record.SourceType] = result
+			}
+			result.Found++
+
+			kind, name, _ := strings.Cut(target, ":")
+			switch {
+			case kind == "vital" && !record.Category:
+				value, err := services.ConvertVital(name, record.Value, record.Unit)
+				if err != nil {
+					result.Skipped++
+					result.Error = err.Error()
+					continue
+				}
+				timestamp := record.Timestamp.UTC().Truncate(time.Second)
+				if vitalTimes[name] == nil {
+					vitalTimes[name] = make(map[time.Time]bool)
+				}
+				if vitalTimes[name][timestamp] {
+					result.Duplicates++
+					continue
+				}
+				vitalTimes[name][timestamp] = true
+
+				vitals = append(vitals, &models.Vital{
+					AccountID:  accountID,
+					RecordedBy: sql.NullInt64{Int64: userID, Valid: true},
+					Timestamp:  timestamp,
+					Type:       name,
+					Value:      value,
+					Unit:       services.VitalUnits[name],
+					Source:     source,
+					Notes:      sql.NullString{String: sourceNote, Valid: true},
+				})
+				vitalResults = append(vitalResults, result)
+
+			case kind == "symptom":
+				if courseID == 0 {
+					result.Skipped++
+					result.Error = "no active course to log symptoms against; pass course_id"
+					continue
+				}
+				encoded, _ := json.Marshal([]string{name})
+				timestamp := record.Timestamp.UTC().Truncate(time.Minute)
+				key := timestamp.Format(time.RFC3339) + "|" + string(encoded)
+				if symptomKeys[key] {
+					result.Duplicates++
+					continue
+				}
+				symptomKeys[key] = true
+
+				notes := sourceNote
+				if record.Severity != "" {
+					notes = fmt.Sprintf("Severity: %s. %s", record.Severity, sourceNote)
+				}
+				symptoms = append(symptoms, healthImportSymptom{
+					timestamp: timestamp,
+					symptoms:  string(encoded),
+					notes:     notes,
+					result:    result,
+				})
+
+			default:
+				result.Skipped++
+				result.Error = fmt.Sprintf("%s records can't be imported as %s", record.SourceType, target)
+			}
+		}
+
+		if !dryRun && (len(vitals) > 0 || len(symptoms) > 0) {
+			tx, err := db.BeginTx()
+			if err != nil {
+				respond.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+				return
+			}
+			defer func() { _ = tx.Rollback() }()
+
+			imported := 0
+			for i, vital := range vitals {
+				created, err := vitalRepo.Create(tx, vital)
+				if err != nil {
+					respond.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				if created {
+					vitalResults[i].Imported++
+					imported++
+				} else {
+					vitalResults[i].Duplicates++
+				}
+			}
+			for _, s := range symptoms {
+				_, err := tx.Exec(`
+					INSERT INTO symptom_logs (course_id, logged_by, timestamp, symptoms, notes, created_at, updated_at)
+					VALUES (?, ?, ?, ?, ?, ?, ?)
+				`, courseID, userID, s.timestamp, s.symptoms, s.notes, time.Now(), time.Now())
+				if err != nil {
+					respond.Error(w, fmt.Sprintf("Failed to import symptom: %v", err), http.StatusInternalServerError)
+					return
+				}
+				s.result.Imported++
+				imported++
+			}
+
+			_, err = tx.Exec(`
+				INSERT INTO audit_logs (user_id, action, entity_type, details, impersonator_id, timestamp)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`,
+				userID,
+				"import",
+				"health_data",
+				fmt.Sprintf("Imported %d vitals and symptoms from %s", imported, healthSourceNames[source]),
+				repository.ImpersonatorOf(r.Context()),
+				time.Now(),
+			)
+			if err != nil {
+				respond.Error(w, "Failed to create audit log", http.StatusInternalServerError)
+				return
+			}
+
+			if err := tx.Commit(); err != nil {
+				respond.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			for _, result := range vitalResults {
+				result.Imported++
+			}
+			for _, s := range symptoms {
+				s.result.Imported++
+			}
+		}
+
+		resp.Types = make([]HealthImportTypeResult, 0, len(results))
+		for _, result := range results {
+			resp.Types = append(resp.Types, *result)
+			resp.Found += result.Found
+			resp.Imported += result.Imported
+			resp.Duplicates += result.Duplicates
+			resp.Skipped += result.Skipped
+		}
+		sort.Slice(resp.Types, func(i, j int) bool { return resp.Types[i].SourceType < resp.Types[j].SourceType })
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			middleware.Log(r.Context()).Error("Failed to encode health import response", "err", err)
+		}
+	}
+}
+
+// HandleGetHealthImportMappings lists how each health app record type is
+// imported
+func HandleGetHealthImportMappings(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		resp, err := healthImportMappingsResponse(repository.NewVitalRepository(db), accountID)
+		if err != nil {
+			respond.Error(w, "Failed to load import mappings", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleUpdateHealthImportMappings changes how health app record types are
+// imported for the account
+func HandleUpdateHealthImportMappings(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req UpdateHealthImportMappingsRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		changes := make(map[string]string, len(req.Mappings))
+		details := make(map[string]interface{}, len(req.Mappings))
+		for sourceType, target := range req.Mappings {
+			sourceType = strings.TrimSpace(sourceType)
+			target = strings.TrimSpace(target)
+			if sourceType == "" || len(sourceType) > 100 {
+				respond.Error(w, "Record types must be 1 to 100 characters", http.StatusBadRequest)
+				return
+			}
+			if target == services.DefaultHealthMappings[sourceType] {
+				target = ""
+			}
+			if target != "" {
+				if err := services.ValidateHealthTarget(target); err != nil {
+					respond.Error(w, fmt.Sprintf("%s: %v", sourceType, err), http.StatusBadRequest)
+					return
+				}
+			}
+			changes[sourceType] = target
+			details[sourceType] = target
+		}
+
+		vitalRepo := repository.NewVitalRepository(db)
+		if err := vitalRepo.SetHealthImportMappings(accountID, changes); err != nil {
+			respond.Error(w, "Failed to save import mappings", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"health_import_mapping",
+			sql.NullInt64{},
+			details,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		resp, err := healthImportMappingsResponse(vitalRepo, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to load import mappings", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleGetVitals lists the account's vitals, newest first, optionally by
+// type and date range
+func HandleGetVitals(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		vitalType := query.Get("type")
+		if _, ok := services.VitalUnits[vitalType]; vitalType != "" && !ok {
+			respond.Error(w, "Invalid type", http.StatusBadRequest)
+			return
+		}
+
+		var start, end time.Time
+		var err error
+		if v := query.Get("start_date"); v != "" {
+			if start, err = time.Parse("2006-01-02", v); err != nil {
+				respond.Validation(w, "Invalid start_date format (use YYYY-MM-DD)", respond.Field("start_date", "invalid format (use YYYY-MM-DD)"))
+				return
+			}
+		}
+		if v := query.Get("end_date"); v != "" {
+			if end, err = time.Parse("2006-01-02", v); err != nil {
+				respond.Validation(w, "Invalid end_date format (use YYYY-MM-DD)", respond.Field("end_date", "invalid format (use YYYY-MM-DD)"))
+				return
+			}
+			end = end.AddDate(0, 0, 1) // Include the whole end day
+		}
+
+		limit := 500
+		if v := query.Get("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 5000 {
+				limit = n
+			}
+		}
+
+		vitals, err := repository.NewVitalRepository(db).List(accountID, vitalType, start, end, limit)
+		if err != nil {
+			respond.Error(w, "Failed to get vitals", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]VitalResponse, 0, len(vitals))
+		for _, v := range vitals {
+			resp = append(resp, VitalResponse{
+				ID:         v.ID,
+				Timestamp:  v.Timestamp,
+				Type:       v.Type,
+				Value:      v.Value,
+				Unit:       v.Unit,
+				Source:     v.Source,
+				Notes:      nullStringToPtr(v.Notes),
+				RecordedBy: nullInt64ToInt(v.RecordedBy),
+			})
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// healthImportMappings returns the account's effective mappings: the
+// defaults with its overrides applied
+func healthImportMappings(repo *repository.VitalRepository, accountID int64) (map[string]string, error) {
+	overrides, err := repo.HealthImportMappings(accountID)
+	if err != nil {
+		return nil, err
+	}
+	mappings := make(map[string]string, len(services.DefaultHealthMappings)+len(overrides))
+	for sourceType, target := range services.DefaultHealthMappings {
+		mappings[sourceType] = target
+	}
+	for sourceType, target := range overrides {
+		mappings[sourceType] = target
+	}
+	return mappings, nil
+}
+
+func healthImportMappingsResponse(repo *repository.VitalRepository, accountID int64) (*HealthImportMappingsResponse, error) {
+	mappings, err := healthImportMappings(repo, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &HealthImportMappingsResponse{VitalTypes: services.VitalUnits}
+	for sourceType, target := range mappings {
+		source := services.HealthSourceApple
+		if !strings.HasPrefix(sourceType, "HK") {
+			source = services.HealthSourceGoogle
+		}
+		resp.Mappings = append(resp.Mappings, HealthImportMapping{
+			SourceType:    sourceType,
+			Source:        source,
+			DefaultTarget: services.DefaultHealthMappings[sourceType],
+			Target:        target,
+		})
+	}
+	sort.Slice(resp.Mappings, func(i, j int) bool {
+		if resp.Mappings[i].Source != resp.Mappings[j].Source {
+			return resp.Mappings[i].Source < resp.Mappings[j].Source
+		}
+		return resp.Mappings[i].SourceType < resp.Mappings[j].SourceType
+	})
+	return resp, nil
+}
+
+// accountSymptomKeys returns "minute|symptoms" keys for the account's
+// symptom logs, to spot imports of symptoms already logged
+func accountSymptomKeys(db *database.DB, accountID int64) (map[string]bool, error) {
+	rows, err := db.Query(`
+		SELECT s.timestamp, COALESCE(s.symptoms, '')
+		FROM symptom_logs s
+		JOIN courses c ON c.id = s.course_id
+		WHERE c.account_id = ?
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var timestamp time.Time
+		var symptoms string
+		if err := rows.Scan(&timestamp, &symptoms); err != nil {
+			return nil, err
+		}
+		keys[timestamp.UTC().Truncate(time.Minute).Format(time.RFC3339)+"|"+symptoms] = true
+	}
+	return keys, rows.Err()
+}
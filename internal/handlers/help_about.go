@@ -11,11 +11,12 @@ import (
 // HandleHelpPage renders the help page
 func HandleHelpPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Help & Support"
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "help.html", data); err != nil {
+		if err := web.Render(w, r, "help.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
@@ -25,11 +26,12 @@ func HandleHelpPage(db *database.DB, csrf *middleware.CSRFProtection) http.Handl
 // HandleAboutPage renders the about page
 func HandleAboutPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "About"
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "about.html", data); err != nil {
+		if err := web.Render(w, r, "about.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
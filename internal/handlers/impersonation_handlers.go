@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"injection-tracker/internal/auth"
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+)
+
+// impersonatorCookie keeps the admin's own token while they impersonate
+// someone, so stopping signs them back in as themselves
+const impersonatorCookie = "impersonator_token"
+
+// ImpersonateRequest names the user the admin wants to act as
+type ImpersonateRequest struct {
+	TargetUserID int64 `json:"user_id" validate:"required,min=1"`
+}
+
+// ImpersonationResponse is a started impersonation session. The token is
+// also set as the auth cookie.
+type ImpersonationResponse struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Token     string    `json:"token"`
+}
+
+// StopImpersonationResponse reports whether the admin was signed back in
+// as themselves, which needs their own session to still be active
+type StopImpersonationResponse struct {
+	Restored bool `json:"restored"`
+}
+
+// HandleStartImpersonation signs the admin in as another user, for support.
+// The session lasts auth.ImpersonationDuration and can't be refreshed; the
+// user sees it among their signed-in devices and can sign it out, and
+// everything done in it is audited under both of them.
+func HandleStartImpersonation(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		adminID := middleware.GetUserID(r.Context())
+		if adminID == 0 || !IsAdmin(db, adminID) {
+			respond.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		var req ImpersonateRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.TargetUserID == adminID {
+			respond.Error(w, "Cannot impersonate yourself", http.StatusBadRequest)
+			return
+		}
+
+		user, err := repository.NewUserRepository(db).GetByID(req.TargetUserID)
+		if err == repository.ErrNotFound {
+			respond.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			respond.Error(w, "Failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if !user.IsActive {
+			respond.Error(w, "Cannot impersonate an inactive user", http.StatusBadRequest)
+			return
+		}
+
+		accountRepo := repository.NewAccountRepository(db.DB)
+		account, err := accountRepo.GetUserAccount(user.ID)
+		if err != nil {
+			respond.Error(w, "User has no account", http.StatusBadRequest)
+			return
+		}
+		if account.Suspension.String == models.AccountLocked {
+			respond.Error(w, "Cannot impersonate a member of a locked account", http.StatusBadRequest)
+			return
+		}
+		member, err := accountRepo.GetMember(account.ID, user.ID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve account membership", http.StatusInternalServerError)
+			return
+		}
+
+		ipAddress := getIPAddress(r)
+		userAgent := r.UserAgent()
+		expiresAt := time.Now().Add(auth.ImpersonationDuration)
+		session, sessionID, err := repository.NewSessionRepository(db).CreateImpersonation(user.ID, adminID, expiresAt, ipAddress, userAgent)
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to start impersonation session", "err", err)
+			respond.Error(w, "Failed to start session", http.StatusInternalServerError)
+			return
+		}
+		token, err := jwtManager.GenerateImpersonationToken(user.ID, user.Username, account.ID, member.Role, sessionID, adminID, auth.ImpersonationDuration)
+		if err != nil {
+			respond.Error(w, "Failed to generate authentication token", http.StatusInternalServerError)
+			return
+		}
+
+		// Logged as the user, with the admin as impersonator, like
+		// everything that follows
+		auditRepo := repository.NewAuditRepository(db.WithContext(auth.WithImpersonator(r.Context(), adminID)))
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: user.ID, Valid: true},
+			"impersonate_start",
+			"user",
+			sql.NullInt64{Int64: user.ID, Valid: true},
+			map[string]interface{}{"session_id": session.ID, "expires_at": session.ExpiresAt},
+			ipAddress,
+			userAgent,
+		)
+
+		if cookie, err := r.Cookie("auth_token"); err == nil {
+			http.SetCookie(w, &http.Cookie{
+				Name:     impersonatorCookie,
+				Value:    cookie.Value,
+				Path:     "/",
+				MaxAge:   int(jwtManager.SessionDuration().Seconds()),
+				HttpOnly: true,
+				Secure:   middleware.SecureCookie(r),
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     "auth_token",
+			Value:    token,
+			Path:     "/",
+			MaxAge:   int(auth.ImpersonationDuration.Seconds()),
+			HttpOnly: true,
+			Secure:   middleware.SecureCookie(r),
+			SameSite: http.SameSiteStrictMode,
+		})
+
+		respondJSON(w, http.StatusOK, ImpersonationResponse{
+			UserID:    user.ID,
+			Username:  user.Username,
+			ExpiresAt: session.ExpiresAt,
+			Token:     token,
+		})
+	}
+}
+
+// HandleStopImpersonation ends an impersonation session and signs the admin
+// back in with the token they had before it started, if it is still good
+func HandleStopImpersonation(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		impersonatorID := middleware.GetImpersonatorID(r.Context())
+		if impersonatorID == 0 {
+			respond.Error(w, "Not impersonating anyone", http.StatusBadRequest)
+			return
+		}
+
+		revokeCurrentSession(db, r)
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"impersonate_stop",
+			"user",
+			sql.NullInt64{Int64: userID, Valid: true},
+			nil,
+			getIPAddress(r),
+			r.UserAgent(),
+		)
+
+		// Cleared first, then replaced by the admin's own token if it's
+		// still good
+		clearAuthCookie(w, r)
+		resp := StopImpersonationResponse{}
+		if token, maxAge := impersonatorToken(db, r, jwtManager, impersonatorID); token != "" {
+			http.SetCookie(w, &http.Cookie{
+				Name:     "auth_token",
+				Value:    token,
+				Path:     "/",
+				MaxAge:   maxAge,
+				HttpOnly: true,
+				Secure:   middleware.SecureCookie(r),
+				SameSite: http.SameSiteStrictMode,
+			})
+			resp.Restored = true
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// impersonatorToken returns the admin's own token kept while impersonating,
+// and how many seconds it has left, if it is theirs and still valid
+func impersonatorToken(db *database.DB, r *http.Request, jwtManager *auth.JWTManager, impersonatorID int64) (string, int) {
+	cookie, err := r.Cookie(impersonatorCookie)
+	if err != nil {
+		return "", 0
+	}
+	claims, err := jwtManager.ValidateToken(cookie.Value)
+	if err != nil || claims.UserID != impersonatorID || claims.ImpersonatorID != 0 {
+		return "", 0
+	}
+	if claims.SessionID != "" {
+		if _, err := repository.NewSessionRepository(db).GetActive(claims.SessionID); err != nil {
+			return "", 0
+		}
+	}
+	return cookie.Value, int(time.Until(claims.ExpiresAt.Time).Seconds())
+}
+
+// NoImpersonation refuses requests made while impersonating a user, for
+// what only the user should do themselves, such as changing their password
+func NoImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if middleware.GetImpersonatorID(r.Context()) != 0 {
+			respond.Error(w, "Not allowed while impersonating a user", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,483 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+)
+
+const (
+	// maxImportBytes caps the size of an import request body
+	maxImportBytes = 5 << 20
+	// maxImportRows caps the number of injections imported at once
+	maxImportRows = 5000
+)
+
+// importTimestampLayouts are accepted for timestamps without an offset,
+// which are read in the importing user's timezone
+var importTimestampLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ImportInjectionRow is one past injection to import. CSV files use the same
+// names as header columns; the injections CSV export can be imported as is.
+type ImportInjectionRow struct {
+	Timestamp    string   `json:"timestamp"`
+	Side         string   `json:"side"`
+	PainLevel    *int     `json:"pain_level,omitempty"`
+	HasKnots     bool     `json:"has_knots"`
+	SiteReaction *string  `json:"site_reaction,omitempty"`
+	Notes        *string  `json:"notes,omitempty"`
+	DoseML       *float64 `json:"dose_ml,omitempty"` // Defaults to the course dose
+}
+
+// ImportRowResult reports what happened to one imported row
+type ImportRowResult struct {
+	Row         int    `json:"row"` // CSV line or 1-based JSON index
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	InjectionID *int64 `json:"injection_id,omitempty"`
+}
+
+// ImportInjectionsResponse summarises an injection import
+type ImportInjectionsResponse struct {
+	DryRun        bool              `json:"dry_run"`
+	CourseID      int64             `json:"course_id"`
+	Total         int               `json:"total"`
+	Imported      int               `json:"imported"`
+	Duplicates    int               `json:"duplicates"`
+	Errors        int               `json:"errors"`
+	SkipInventory bool              `json:"skip_inventory"`
+	Rows          []ImportRowResult `json:"rows"`
+}
+
+// importInjection is a validated row ready to insert
+type importInjection struct {
+	row       int
+	timestamp time.Time
+	ImportInjectionRow
+}
+
+// HandleImportInjections imports past injections from a CSV (text/csv) or
+// JSON array (application/json) body into a course, the active one unless
+// course_id is given. Rows at the same minute as an existing injection, or an
+// earlier row, are skipped as duplicates. If any row is invalid nothing is
+// imported; dry_run=true only validates. skip_inventory=true leaves inventory
+// untouched, as historical injections usually came from stock that was never
+// entered.
+func HandleImportInjections(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		dryRun := query.Get("dry_run") == "true"
+		skipInventory := query.Get("skip_inventory") == "true"
+
+		courseRepo := repository.NewCourseRepository(db)
+		var courseID int64
+		if v := query.Get("course_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				respond.Validation(w, "Invalid course_id", respond.Field("course_id", "is invalid"))
+				return
+			}
+			if _, err := courseRepo.GetByID(id, accountID); err != nil {
+				respond.Error(w, "Course not found", http.StatusBadRequest)
+				return
+			}
+			courseID = id
+		} else {
+			course, err := courseRepo.GetActiveCourse(accountID)
+			if err != nil {
+				respond.Error(w, "No active course; pass course_id", http.StatusBadRequest)
+				return
+			}
+			courseID = course.ID
+		}
+
+		loc, err := time.LoadLocation(GetUserTimezone(db, userID))
+		if err != nil {
+			loc = time.UTC
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+		var rows []ImportInjectionRow
+		var lines []int
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			rows, lines, err = parseInjectionCSV(r.Body)
+		case "application/json", "":
+			err = json.NewDecoder(r.Body).Decode(&rows)
+		default:
+			respond.Error(w, "Content-Type must be text/csv or application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		if err != nil {
+			respond.Error(w, fmt.Sprintf("Invalid import file: %v", err), http.StatusBadRequest)
+			return
+		}
+		if len(rows) == 0 {
+			respond.Error(w, "No injections to import", http.StatusBadRequest)
+			return
+		}
+		if len(rows) > maxImportRows {
+			respond.Error(w, fmt.Sprintf("Too many rows; import at most %d at a time", maxImportRows), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		resp := ImportInjectionsResponse{
+			DryRun:        dryRun,
+			CourseID:      courseID,
+			Total:         len(rows),
+			SkipInventory: skipInventory,
+			Rows:          make([]ImportRowResult, len(rows)),
+		}
+
+		existing, err := accountInjectionMinutes(db, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to check for duplicates", http.StatusInternalServerError)
+			return
+		}
+
+		var valid []importInjection
+		for i, row := range rows {
+			result := &resp.Rows[i]
+			result.Row = i + 1
+			if lines != nil {
+				result.Row = lines[i]
+			}
+
+			timestamp, err := validateImportRow(&row, loc)
+			if err != nil {
+				result.Status = "error"
+				result.Error = err.Error()
+				resp.Errors++
+				continue
+			}
+			minute := timestamp.UTC().Truncate(time.Minute)
+			if existing[minute] {
+				result.Status = "duplicate"
+				resp.Duplicates++
+				continue
+			}
+			existing[minute] = true
+
+			result.Status = "valid"
+			valid = append(valid, importInjection{row: i, timestamp: timestamp, ImportInjectionRow: row})
+		}
+
+		if resp.Errors > 0 || dryRun || len(valid) == 0 {
+			status := http.StatusOK
+			if resp.Errors > 0 && !dryRun {
+				status = http.StatusUnprocessableEntity
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(resp)
+			return
+		}
+
+		tx, err := db.BeginTx()
+		if err != nil {
+			respond.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		medication, err := repository.GetCourseMedication(tx, courseID, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to look up course medication", http.StatusInternalServerError)
+			return
+		}
+
+		quantitiesBefore := make(map[string]float64)
+		for _, inj := range valid {
+			doseML := medication.DoseML
+			if inj.DoseML != nil {
+				doseML = *inj.DoseML
+			}
+
+			var injectionID int64
+			err := tx.QueryRow(`
+				INSERT INTO injections (
+					course_id, administered_by, timestamp, side,
+					pain_level, has_knots, site_reaction, notes, dose_ml, created_at, updated_at
+				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				RETURNING id
+			`,
+				courseID,
+				userID,
+				inj.timestamp,
+				inj.Side,
+				nullInt(inj.PainLevel),
+				inj.HasKnots,
+				nullString(inj.SiteReaction),
+				nullString(inj.Notes),
+				doseML,
+				time.Now(),
+				time.Now(),
+			).Scan(&injectionID)
+			if err != nil {
+				respond.Error(w, fmt.Sprintf("Failed to import row %d: %v", resp.Rows[inj.row].Row, err), http.StatusInternalServerError)
+				return
+			}
+
+			if !skipInventory {
+				before, err := repository.ConsumeInjectionInventory(tx, injectionID, accountID, userID, medication.ItemType, doseML,
+					fmt.Sprintf("Auto-decremented for imported injection #%d", injectionID))
+				if err != nil {
+					respond.Error(w, fmt.Sprintf("Failed to decrement inventory: %v", err), http.StatusInternalServerError)
+					return
+				}
+				for itemType, qty := range before {
+					if _, seen := quantitiesBefore[itemType]; !seen {
+						quantitiesBefore[itemType] = qty
+					}
+				}
+			}
+
+			resp.Rows[inj.row].Status = "imported"
+			resp.Rows[inj.row].InjectionID = &injectionID
+			resp.Imported++
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details, impersonator_id, timestamp)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`,
+			userID,
+			"import",
+			"injection",
+			courseID,
+			fmt.Sprintf("Imported %d injections into course #%d (%d duplicates skipped, inventory decremented: %t)",
+				resp.Imported, courseID, resp.Duplicates, !skipInventory),
+			repository.ImpersonatorOf(r.Context()),
+			time.Now(),
+		)
+		if err != nil {
+			respond.Error(w, "Failed to create audit log", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			respond.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		for itemType, before := range quantitiesBefore {
+			if item, err := getInventoryItemByType(db, accountID, itemType); err == nil {
+				emitLowStockIfCrossed(db, accountID, item, before)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			middleware.Log(r.Context()).Error("Failed to encode import response", "err", err)
+		}
+	}
+}
+
+// validateImportRow normalises a row in place and returns its timestamp
+func validateImportRow(row *ImportInjectionRow, loc *time.Location) (time.Time, error) {
+	timestamp, err := parseImportTimestamp(row.Timestamp, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if timestamp.After(time.Now().Add(time.Minute)) {
+		return time.Time{}, errors.New("timestamp is in the future")
+	}
+
+	row.Side = strings.ToLower(strings.TrimSpace(row.Side))
+	if row.Side != "left" && row.Side != "right" {
+		return time.Time{}, errors.New("side must be 'left' or 'right'")
+	}
+	if row.PainLevel != nil && (*row.PainLevel < 1 || *row.PainLevel > 10) {
+		return time.Time{}, errors.New("pain_level must be between 1 and 10")
+	}
+	if row.SiteReaction != nil {
+		validReactions := map[string]bool{"none": true, "redness": true, "swelling": true, "bruising": true, "other": true}
+		if !validReactions[*row.SiteReaction] {
+			return time.Time{}, errors.New("invalid site_reaction value")
+		}
+	}
+	if err := validateDoseML(row.DoseML); err != nil {
+		return time.Time{}, err
+	}
+	return timestamp, nil
+}
+
+// parseImportTimestamp accepts RFC3339 or one of importTimestampLayouts
+func parseImportTimestamp(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, errors.New("timestamp is required")
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range importTimestampLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q, use RFC3339 or YYYY-MM-DD HH:MM", value)
+}
+
+// parseInjectionCSV reads CSV rows by header name, returning each row's line
+// number alongside it. Headers are matched case-insensitively and may be
+// written as in the CSV export ("Pain Level", "Dose (mL)"); a separate Date and
+// Time column can stand in for timestamp. Other columns are ignored. Values
+// that fail to parse are left for validateImportRow to report, so one bad
+// cell does not hide the rest of the file's errors.
+func parseInjectionCSV(body io.Reader) ([]ImportInjectionRow, []int, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[normalizeImportColumn(name)] = i
+	}
+	if _, ok := columns["side"]; !ok {
+		return nil, nil, errors.New("missing side column")
+	}
+	_, hasTimestamp := columns["timestamp"]
+	_, hasDate := columns["date"]
+	if !hasTimestamp && !hasDate {
+		return nil, nil, errors.New("missing timestamp (or date) column")
+	}
+
+	var rows []ImportInjectionRow
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := ImportInjectionRow{
+			Timestamp: field("timestamp"),
+			Side:      i18n.Source(field("side")),
+		}
+		if row.Timestamp == "" && field("date") != "" {
+			row.Timestamp = strings.TrimSpace(field("date") + " " + field("time"))
+		}
+		if v := field("pain_level"); v != "" {
+			// The export writes 0 for injections without a pain level
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				n = -1
+			}
+			if n != 0 {
+				row.PainLevel = &n
+			}
+		}
+		switch strings.ToLower(i18n.Source(field("has_knots"))) {
+		case "yes", "true", "1":
+			row.HasKnots = true
+		}
+		if v := strings.ToLower(field("site_reaction")); v != "" {
+			row.SiteReaction = &v
+		}
+		if v := field("notes"); v != "" {
+			row.Notes = &v
+		}
+		if v := field("dose_ml"); v != "" {
+			dose, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				dose = -1
+			}
+			row.DoseML = &dose
+		}
+
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, row)
+		lines = append(lines, line)
+	}
+	return rows, lines, nil
+}
+
+// normalizeImportColumn maps a CSV header, in English or as exported in
+// another language, to an ImportInjectionRow field name
+func normalizeImportColumn(name string) string {
+	name = strings.ToLower(i18n.Source(strings.TrimPrefix(name, "\ufeff")))
+	name = strings.ReplaceAll(name, "(ml)", "ml")
+	name = strings.Join(strings.Fields(name), "_")
+	switch name {
+	case "pain":
+		return "pain_level"
+	case "dose":
+		return "dose_ml"
+	case "knots":
+		return "has_knots"
+	}
+	return name
+}
+
+// accountInjectionMinutes returns the minutes (in UTC) at which the account
+// already has a non-voided injection
+func accountInjectionMinutes(db *database.DB, accountID int64) (map[time.Time]bool, error) {
+	rows, err := db.Query(`
+		SELECT i.timestamp
+		FROM injections i
+		JOIN courses c ON c.id = i.course_id
+		WHERE c.account_id = ? AND i.voided_at IS NULL
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	minutes := make(map[time.Time]bool)
+	for rows.Next() {
+		var timestamp sql.NullTime
+		if err := rows.Scan(&timestamp); err != nil {
+			return nil, err
+		}
+		if timestamp.Valid {
+			minutes[timestamp.Time.UTC().Truncate(time.Minute)] = true
+		}
+	}
+	return minutes, rows.Err()
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInjectionCSV(t *testing.T) {
+	// Same columns as the injections CSV export, with a BOM as Excel writes it
+	body := "\ufeffID,Date,Time,Side,Dose (mL),Dose (mg),Pain Level,Has Knots,Site Reaction,Notes,Administered By\n" +
+		"1,2025-01-02,08:00:00,Left,1,,3,No,,first,someone\n" +
+		"2,2025-01-03,08:30:00,right,,,0,Yes,redness,\"two\nlines\",someone\n" +
+		"3,2025-01-04,,up,abc,,11,,,,someone\n"
+
+	rows, lines, err := parseInjectionCSV(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+	if lines[0] != 2 || lines[1] != 3 || lines[2] != 5 {
+		t.Errorf("Expected rows on lines 2, 3 and 5, got %v", lines)
+	}
+
+	first := rows[0]
+	if first.Timestamp != "2025-01-02 08:00:00" || first.PainLevel == nil || *first.PainLevel != 3 || first.DoseML == nil || *first.DoseML != 1 {
+		t.Errorf("Unexpected first row: %+v", first)
+	}
+	second := rows[1]
+	if second.PainLevel != nil || !second.HasKnots || second.Notes == nil || *second.Notes != "two\nlines" {
+		t.Errorf("Unexpected second row: %+v", second)
+	}
+
+	loc := time.UTC
+	if _, err := validateImportRow(&rows[0], loc); err != nil || rows[0].Side != "left" {
+		t.Errorf("Expected first row to be valid with side normalised, got %q (%v)", rows[0].Side, err)
+	}
+	if _, err := validateImportRow(&rows[2], loc); err == nil {
+		t.Error("Expected third row to be invalid")
+	}
+
+	// The same export written in Spanish
+	body = "Fecha,Hora,Lado,Dosis (mL),Nivel de dolor,Con nódulos,Notas\n" +
+		"2025-01-05,09:00:00,Izquierda,1,2,Sí,tercera\n"
+	rows, _, err = parseInjectionCSV(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to parse Spanish CSV: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Timestamp != "2025-01-05 09:00:00" || !rows[0].HasKnots || rows[0].PainLevel == nil || *rows[0].PainLevel != 2 {
+		t.Fatalf("Unexpected rows from Spanish CSV: %+v", rows)
+	}
+	if _, err := validateImportRow(&rows[0], loc); err != nil || rows[0].Side != "left" {
+		t.Errorf("Expected Spanish row to be valid on the left, got %q (%v)", rows[0].Side, err)
+	}
+
+	if _, _, err := parseInjectionCSV(strings.NewReader("when,where\n1,2\n")); err == nil {
+		t.Error("Expected error for CSV without side and timestamp columns")
+	}
+}
+
+func TestParseImportTimestamp(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+
+	local, err := parseImportTimestamp("2025-01-02 08:00", loc)
+	if err != nil || !local.Equal(time.Date(2025, 1, 2, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected local time read in the user's timezone, got %v (%v)", local, err)
+	}
+	utc, err := parseImportTimestamp("2025-01-02T08:00:00Z", loc)
+	if err != nil || !utc.Equal(time.Date(2025, 1, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected RFC3339 offset to be kept, got %v (%v)", utc, err)
+	}
+	if _, err := parseImportTimestamp("02/01/2025", loc); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
@@ -3,43 +3,81 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
 	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
 	"injection-tracker/internal/middleware"
 	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+	"injection-tracker/internal/web"
 
 	"github.com/go-chi/chi/v5"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 )
 
 // CreateInjectionRequest represents the request body for creating an injection
 type CreateInjectionRequest struct {
-	CourseID       int64    `json:"course_id"`
-	Side           string   `json:"side"`
+	CourseID       int64    `json:"course_id" validate:"required"`
+	Side           string   `json:"side" validate:"required,oneof=left right"`
 	Timestamp      *string  `json:"timestamp,omitempty"`
 	SiteX          *float64 `json:"site_x,omitempty"`
 	SiteY          *float64 `json:"site_y,omitempty"`
-	PainLevel      *int     `json:"pain_level,omitempty"`
+	PainLevel      *int     `json:"pain_level,omitempty" validate:"min=1,max=10"`
 	HasKnots       bool     `json:"has_knots"`
-	SiteReaction   *string  `json:"site_reaction,omitempty"`
-	Notes          *string  `json:"notes,omitempty"`
+	SiteReaction   *string  `json:"site_reaction,omitempty" validate:"oneof=none redness swelling bruising other"`
+	Notes          *string  `json:"notes,omitempty" validate:"multiline,max=2000"`
 	AdministeredBy *int64   `json:"administered_by,omitempty"`
+	DoseML         *float64 `json:"dose_ml,omitempty"` // Overrides the course dose
+	AttachmentID   *int64   `json:"attachment_id,omitempty"`
+	// AllowDuplicate logs the injection even if one on the same course and
+	// side was logged shortly before or after it
+	AllowDuplicate bool `json:"allow_duplicate"`
+}
+
+// DuplicateInjectionResponse is the 409 body when a new injection looks like
+// one already logged, e.g. by a partner: the error, plus the closest such
+// injection. Sending it again with allow_duplicate logs it anyway.
+type DuplicateInjectionResponse struct {
+	Error    respond.ErrorBody `json:"error"`
+	Existing models.Injection  `json:"existing"`
+}
+
+// DuplicateGroupResponse is a run of injections on the same course and side
+// that look double-logged
+type DuplicateGroupResponse struct {
+	CourseID   int64              `json:"course_id"`
+	Side       string             `json:"side"`
+	Injections []models.Injection `json:"injections"` // Oldest first
 }
 
 // UpdateInjectionRequest represents the request body for updating an injection
 type UpdateInjectionRequest struct {
-	Side         *string  `json:"side,omitempty"`
+	Side         *string  `json:"side,omitempty" validate:"oneof=left right"`
 	Timestamp    *string  `json:"timestamp,omitempty"`
 	SiteX        *float64 `json:"site_x,omitempty"`
 	SiteY        *float64 `json:"site_y,omitempty"`
-	PainLevel    *int     `json:"pain_level,omitempty"`
+	PainLevel    *int     `json:"pain_level,omitempty" validate:"min=1,max=10"`
 	HasKnots     *bool    `json:"has_knots,omitempty"`
-	SiteReaction *string  `json:"site_reaction,omitempty"`
-	Notes        *string  `json:"notes,omitempty"`
+	SiteReaction *string  `json:"site_reaction,omitempty" validate:"oneof=none redness swelling bruising other"`
+	Notes        *string  `json:"notes,omitempty" validate:"multiline,max=2000"`
+	DoseML       *float64 `json:"dose_ml,omitempty"`
+	AttachmentID *int64   `json:"attachment_id,omitempty"` // 0 clears the attachment
+}
+
+func (req UpdateInjectionRequest) isEmpty() bool {
+	return req.Side == nil && req.Timestamp == nil && req.SiteX == nil && req.SiteY == nil &&
+		req.PainLevel == nil && req.HasKnots == nil && req.SiteReaction == nil && req.Notes == nil &&
+		req.DoseML == nil && req.AttachmentID == nil
 }
 
 // InjectionStatsResponse represents injection statistics
@@ -51,6 +89,30 @@ type InjectionStatsResponse struct {
 	LastInjection   *models.Injection `json:"last_injection,omitempty"`
 	FrequencyByDay  map[string]int    `json:"frequency_by_day"`
 	PainTrend       []PainTrendPoint  `json:"pain_trend"`
+	TotalDoseML     float64           `json:"total_dose_ml"`
+	AvgDoseML       float64           `json:"avg_dose_ml"`
+	TotalDoseMG     *float64          `json:"total_dose_mg,omitempty"`
+	DoseHistory     []DosePoint       `json:"dose_history"`
+	// Labs are the lab results drawn since the oldest day in the pain trend
+	// or dose history, for drawing over them; points are dated in the user's
+	// timezone as those days are
+	Labs []LabSeries `json:"labs"`
+	// Courses breaks the figures down per active course; only set when the
+	// stats aren't already limited to one course
+	Courses []CourseInjectionSummary `json:"courses,omitempty"`
+}
+
+// CourseInjectionSummary is one active course's share of the injection statistics
+type CourseInjectionSummary struct {
+	CourseID        int64      `json:"course_id"`
+	Name            string     `json:"name"`
+	Compound        string     `json:"compound,omitempty"`
+	TotalInjections int        `json:"total_injections"`
+	LeftCount       int        `json:"left_count"`
+	RightCount      int        `json:"right_count"`
+	AvgPainLevel    float64    `json:"avg_pain_level"`
+	TotalDoseML     float64    `json:"total_dose_ml"`
+	LastInjectionAt *time.Time `json:"last_injection_at,omitempty"`
 }
 
 // PainTrendPoint represents a point in the pain trend graph
@@ -59,45 +121,232 @@ type PainTrendPoint struct {
 	PainLevel float64 `json:"pain_level"`
 }
 
+// DosePoint is the total dose given on one day
+type DosePoint struct {
+	Date   string   `json:"date"`
+	DoseML float64  `json:"dose_ml"`
+	DoseMG *float64 `json:"dose_mg,omitempty"` // Only when the course has a concentration
+}
+
+// defaultDoseML is the volume used when neither the injection nor its course
+// specifies one
+const defaultDoseML = repository.DefaultDoseML
+
+// maxDoseML rejects obviously mistyped volumes (e.g. 25 instead of 2.5)
+const maxDoseML = 10.0
+
+// formatDose renders a volume or mass with at most two decimals
+func formatDose(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func validateDoseML(dose *float64) error {
+	if dose != nil && (*dose <= 0 || *dose > maxDoseML) {
+		return fmt.Errorf("dose_ml must be greater than 0 and at most %g", maxDoseML)
+	}
+	return nil
+}
+
+// injectionDetails are the fields of an injection checked the same way
+// wherever one is entered, beyond their validate tags
+type injectionDetails struct {
+	DoseML       *float64
+	AttachmentID *int64
+}
+
+func (req CreateInjectionRequest) injectionDetails() injectionDetails {
+	return injectionDetails{req.DoseML, req.AttachmentID}
+}
+
+// validInjectionDetails checks a new injection's details, writing the error
+// response if they're invalid
+func validInjectionDetails(w http.ResponseWriter, db *database.DB, accountID, userID int64, d injectionDetails) bool {
+	if err := validateDoseML(d.DoseML); err != nil {
+		respond.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if d.AttachmentID != nil {
+		if !attachmentVisible(db, *d.AttachmentID, accountID, userID) {
+			respond.Validation(w, "attachment_id not found", respond.Field("attachment_id", "not found"))
+			return false
+		}
+	}
+	return true
+}
+
+// setScheduledDose gives an injection without a dose the one its course's
+// taper schedule sets for its day, writing the error response if the course
+// can't be read
+func setScheduledDose(w http.ResponseWriter, db *database.DB, accountID, userID int64, injection *models.Injection) bool {
+	steps, err := repository.NewDoseScheduleRepository(db).List(injection.CourseID, accountID)
+	if err == repository.ErrNotFound {
+		respond.Error(w, "Course not found", http.StatusBadRequest)
+		return false
+	}
+	if err != nil {
+		respond.Error(w, "Failed to retrieve taper schedule", http.StatusInternalServerError)
+		return false
+	}
+	if dose, ok := repository.ScheduledDoseML(steps, injection.Timestamp.In(userLocation(db, userID))); ok {
+		injection.DoseML = sql.NullFloat64{Float64: dose, Valid: true}
+	}
+	return true
+}
+
+// announceInjection tells webhooks and live clients about a newly logged
+// injection and the stock it used, warning in X-Lot-Warning if the lot in
+// use is expiring
+func announceInjection(w http.ResponseWriter, db *database.DB, accountID, userID int64, injection *models.Injection, usage *repository.InventoryUsage) {
+	emitWebhookEvent(db, accountID, services.EventInjectionCreated, map[string]interface{}{
+		"id":              injection.ID,
+		"course_id":       injection.CourseID,
+		"side":            injection.Side,
+		"timestamp":       injection.Timestamp,
+		"dose_ml":         injection.DoseML.Float64,
+		"pain_level":      nullableInt64(injection.PainLevel),
+		"has_knots":       injection.HasKnots,
+		"administered_by": nullableInt64(injection.AdministeredBy),
+	})
+	publishLiveEvent(accountID, services.LiveInjectionCreated, map[string]interface{}{
+		"id":        injection.ID,
+		"course_id": injection.CourseID,
+		"side":      injection.Side,
+		"timestamp": injection.Timestamp,
+		"logged_by": userID,
+	})
+	for itemType, before := range usage.QuantitiesBefore {
+		if item, err := getInventoryItemByType(db, accountID, itemType); err == nil {
+			emitLowStockIfCrossed(db, accountID, item, before)
+			publishInventoryAdjusted(accountID, item, before)
+		}
+	}
+
+	if warning := activeLotExpiryWarning(db, accountID, usage.MedicationItemType, time.Now()); warning != "" {
+		w.Header().Set("X-Lot-Warning", warning)
+	}
+}
+
+// maxDuplicateReviewDays caps how far back the duplicate review looks
+const maxDuplicateReviewDays = 3650
+
+// injectionDuplicateWindow is how close to an existing injection on the same
+// course and side a new one counts as a duplicate; 0 when the account has
+// turned the check off
+func injectionDuplicateWindow(db *database.DB, accountID int64) time.Duration {
+	minutes := DefaultDuplicateWindow
+	value, err := repository.NewSettingsRepository(db).GetAccount(accountID, repository.AccountSettingDuplicateWindow)
+	if err == nil {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			minutes = n
+		}
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// respondDuplicateInjection answers 409 with the injection a new one looks
+// like a duplicate of
+func respondDuplicateInjection(w http.ResponseWriter, db *database.DB, accountID, userID int64, duplicate *repository.DuplicateInjectionError) {
+	existing := *duplicate.Existing
+	existing.AccountID = accountID
+	existing.Timestamp = existing.Timestamp.In(userLocation(db, userID))
+	respond.JSON(w, http.StatusConflict, DuplicateInjectionResponse{
+		Error: respond.ErrorBody{
+			Code: respond.CodePossibleDuplicate,
+			Message: fmt.Sprintf("A %s side injection was already logged for this course at %s. Log this one too only if it is a separate injection.",
+				existing.Side, FormatDateTimeForUser(db, userID, existing.Timestamp)),
+		},
+		Existing: existing,
+	})
+}
+
+// HandleGetInjectionDuplicates lists runs of injections that look
+// double-logged, for review: the same course and side, each within the
+// duplicate window of the one before. ?window= sets the window in minutes
+// (default the account's, or 30 if its check is off), ?days= how far back to
+// look (default 90) and ?course_id= limits it to one course.
+func HandleGetInjectionDuplicates(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		window := injectionDuplicateWindow(db, accountID)
+		if window <= 0 {
+			window = DefaultDuplicateWindow * time.Minute
+		}
+		if v := query.Get("window"); v != "" {
+			minutes, err := strconv.Atoi(v)
+			if err != nil || minutes < 1 || minutes > 1440 {
+				respond.Validation(w, "", respond.Field("window", "must be 1 to 1440 minutes"))
+				return
+			}
+			window = time.Duration(minutes) * time.Minute
+		}
+		days := 90
+		if v := query.Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxDuplicateReviewDays {
+				respond.Validation(w, "", respond.Field("days", fmt.Sprintf("must be 1 to %d", maxDuplicateReviewDays)))
+				return
+			}
+			days = n
+		}
+		var courseID int64
+		if v := query.Get("course_id"); v != "" {
+			var err error
+			courseID, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				respond.Validation(w, "Invalid course_id", respond.Field("course_id", "is invalid"))
+				return
+			}
+		}
+
+		groups, err := repository.NewInjectionRepository(db).ListDuplicateGroups(accountID, courseID, time.Now().AddDate(0, 0, -days), window)
+		if err != nil {
+			respond.Error(w, "Failed to find duplicate injections", http.StatusInternalServerError)
+			return
+		}
+
+		loc := userLocation(db, userID)
+		resp := make([]DuplicateGroupResponse, 0, len(groups))
+		for _, group := range groups {
+			g := DuplicateGroupResponse{CourseID: group[0].CourseID, Side: group[0].Side}
+			for _, injection := range group {
+				injection.AccountID = accountID
+				injection.Timestamp = injection.Timestamp.In(loc)
+				g.Injections = append(g.Injections, *injection)
+			}
+			resp = append(resp, g)
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
 // HandleCreateInjection creates a new injection and automatically decrements inventory
 func HandleCreateInjection(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		// Get user ID from context
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// Parse request body
 		var req CreateInjectionRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
-		}
-
-		// Validate required fields
-		if req.CourseID == 0 {
-			http.Error(w, "course_id is required", http.StatusBadRequest)
-			return
-		}
-		if req.Side != "left" && req.Side != "right" {
-			http.Error(w, "side must be 'left' or 'right'", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		// Validate optional fields
-		if req.PainLevel != nil && (*req.PainLevel < 1 || *req.PainLevel > 10) {
-			http.Error(w, "pain_level must be between 1 and 10", http.StatusBadRequest)
+		if !validInjectionDetails(w, db, middleware.GetAccountID(r.Context()), userID, req.injectionDetails()) {
 			return
 		}
-		if req.SiteReaction != nil {
-			validReactions := map[string]bool{"none": true, "redness": true, "swelling": true, "bruising": true, "other": true}
-			if !validReactions[*req.SiteReaction] {
-				http.Error(w, "invalid site_reaction value", http.StatusBadRequest)
-				return
-			}
-		}
 
 		// Parse timestamp or use current time
 		var timestamp time.Time
@@ -105,7 +354,7 @@ func HandleCreateInjection(db *database.DB) http.HandlerFunc {
 			var err error
 			timestamp, err = time.Parse(time.RFC3339, *req.Timestamp)
 			if err != nil {
-				http.Error(w, "invalid timestamp format, use RFC3339", http.StatusBadRequest)
+				respond.Validation(w, "invalid timestamp format, use RFC3339", respond.Field("timestamp", "invalid format, use RFC3339"))
 				return
 			}
 		} else {
@@ -117,285 +366,143 @@ func HandleCreateInjection(db *database.DB) http.HandlerFunc {
 			req.AdministeredBy = &userID
 		}
 
-		// Begin transaction for atomic operation
-		tx, err := db.BeginTx()
-		if err != nil {
-			http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
-			return
-		}
-		defer func() { _ = tx.Rollback() }()
-
-		// Insert injection
-		result, err := tx.Exec(`
-			INSERT INTO injections (
-				course_id, administered_by, timestamp, side,
-				site_x, site_y, pain_level, has_knots,
-				site_reaction, notes, created_at, updated_at
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`,
-			req.CourseID,
-			nullInt64(req.AdministeredBy),
-			timestamp,
-			req.Side,
-			nullFloat64(req.SiteX),
-			nullFloat64(req.SiteY),
-			nullInt(req.PainLevel),
-			req.HasKnots,
-			nullString(req.SiteReaction),
-			nullString(req.Notes),
-			time.Now(),
-			time.Now(),
-		)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create injection: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		injectionID, err := result.LastInsertId()
-		if err != nil {
-			http.Error(w, "Failed to get injection ID", http.StatusInternalServerError)
+		// Log the injection and take what it uses out of inventory
+		accountID := middleware.GetAccountID(r.Context())
+		injectionRepo := repository.NewInjectionRepository(db)
+		injection := &models.Injection{
+			CourseID:       req.CourseID,
+			AdministeredBy: nullInt64(req.AdministeredBy),
+			Timestamp:      timestamp,
+			Side:           req.Side,
+			SiteX:          nullFloat64(req.SiteX),
+			SiteY:          nullFloat64(req.SiteY),
+			PainLevel:      nullInt(req.PainLevel),
+			HasKnots:       req.HasKnots,
+			SiteReaction:   nullString(req.SiteReaction),
+			Notes:          nullString(req.Notes),
+			DoseML:         nullFloat64(req.DoseML), // Overrides the course's configured dose
+			AttachmentID:   nullInt64(req.AttachmentID),
+		}
+		if req.DoseML == nil && !setScheduledDose(w, db, accountID, userID, injection) {
 			return
 		}
-
-		// **CRITICAL: Automatically decrement inventory**
-		inventoryItems := []struct {
-			itemType string
-			amount   float64
-			unit     string
-		}{
-			{"progesterone", 1.0, "mL"},
-			{"draw_needle", 1.0, "count"},
-			{"injection_needle", 1.0, "count"},
-			{"syringe", 1.0, "count"},
-			{"swab", 1.0, "count"},
+		// The check runs in the transaction that logs the injection, so two
+		// members logging the same dose at once can't both get past it
+		var duplicateWindow time.Duration
+		if !req.AllowDuplicate {
+			duplicateWindow = injectionDuplicateWindow(db, accountID)
 		}
-
-		for _, item := range inventoryItems {
-			// Get current quantity
-			var currentQty float64
-			err := tx.QueryRow(`
-				SELECT quantity FROM inventory_items WHERE item_type = ?
-			`, item.itemType).Scan(&currentQty)
-
-			if err != nil {
-				if err == sql.ErrNoRows {
-					// Item doesn't exist - initialize with 0 quantity
-					_, err = tx.Exec(`
-						INSERT INTO inventory_items (item_type, quantity, unit, created_at, updated_at)
-						VALUES (?, ?, ?, ?, ?)
-					`, item.itemType, 0.0, item.unit, time.Now(), time.Now())
-					if err != nil {
-						http.Error(w, fmt.Sprintf("Failed to initialize inventory for %s: %v", item.itemType, err), http.StatusInternalServerError)
-						return
-					}
-					currentQty = 0.0
-				} else {
-					http.Error(w, fmt.Sprintf("Failed to check inventory for %s: %v", item.itemType, err), http.StatusInternalServerError)
-					return
-				}
-			}
-
-			// Calculate new quantity (don't go below 0)
-			newQty := currentQty - item.amount
-			if newQty < 0 {
-				newQty = 0
-			}
-
-			// Update inventory
-			_, err = tx.Exec(`
-				UPDATE inventory_items
-				SET quantity = ?, updated_at = ?
-				WHERE item_type = ?
-			`, newQty, time.Now(), item.itemType)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to update inventory for %s: %v", item.itemType, err), http.StatusInternalServerError)
+		usage, err := injectionRepo.Record(injection, accountID, userID, duplicateWindow)
+		if err != nil {
+			var duplicate *repository.DuplicateInjectionError
+			if errors.As(err, &duplicate) {
+				respondDuplicateInjection(w, db, accountID, userID, duplicate)
 				return
 			}
-
-			// Log inventory change
-			_, err = tx.Exec(`
-				INSERT INTO inventory_history (
-					item_type, change_amount, quantity_before, quantity_after,
-					reason, reference_id, reference_type, performed_by, timestamp, notes
-				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-			`,
-				item.itemType,
-				-item.amount,
-				currentQty,
-				newQty,
-				"injection",
-				injectionID,
-				"injection",
-				userID,
-				time.Now(),
-				fmt.Sprintf("Auto-decremented for injection #%d", injectionID),
-			)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to log inventory history for %s: %v", item.itemType, err), http.StatusInternalServerError)
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Course not found", http.StatusBadRequest)
 				return
 			}
-		}
-
-		// Create audit log
-		_, err = tx.Exec(`
-			INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details, timestamp)
-			VALUES (?, ?, ?, ?, ?, ?)
-		`,
-			userID,
-			"create",
-			"injection",
-			injectionID,
-			fmt.Sprintf("Created injection on %s side with auto inventory decrement", req.Side),
-			time.Now(),
-		)
-		if err != nil {
-			http.Error(w, "Failed to create audit log", http.StatusInternalServerError)
-			return
-		}
-
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to create injection: %v", err), http.StatusInternalServerError)
 			return
 		}
 
 		// Retrieve the created injection
-		injection, err := getInjectionByID(db, injectionID)
+		injection, err = injectionRepo.GetByID(injection.ID, accountID)
 		if err != nil {
-			http.Error(w, "Injection created but failed to retrieve", http.StatusInternalServerError)
+			respond.Error(w, "Injection created but failed to retrieve", http.StatusInternalServerError)
 			return
 		}
 
+		announceInjection(w, db, accountID, userID, injection, usage)
+
 		// Return success response
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(injection); err != nil {
-			log.Printf("Failed to encode injection response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode injection response", "err", err)
 		}
 	}
 }
 
-// HandleGetInjections returns a list of injections with optional filtering
+// HandleGetInjections returns a page of injections with optional filtering
 func HandleGetInjections(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse query parameters
-		courseID := r.URL.Query().Get("course_id")
-		side := r.URL.Query().Get("side")
-		startDate := r.URL.Query().Get("start_date")
-		endDate := r.URL.Query().Get("end_date")
-		limit := r.URL.Query().Get("limit")
-		offset := r.URL.Query().Get("offset")
-
-		// Build query
-		query := `
-			SELECT id, course_id, administered_by, timestamp, side,
-				site_x, site_y, pain_level, has_knots, site_reaction,
-				notes, created_at, updated_at
-			FROM injections
-			WHERE 1=1
-		`
-		args := []interface{}{}
-
-		if courseID != "" {
-			query += " AND course_id = ?"
-			args = append(args, courseID)
-		}
-		if side != "" {
-			query += " AND side = ?"
-			args = append(args, side)
-		}
-		if startDate != "" {
-			query += " AND timestamp >= ?"
-			args = append(args, startDate)
-		}
-		if endDate != "" {
-			query += " AND timestamp <= ?"
-			args = append(args, endDate)
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
 
-		query += " ORDER BY timestamp DESC"
+		page, err := parsePageRequest(r)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		if limit != "" {
-			query += " LIMIT ?"
-			args = append(args, limit)
+		filter := repository.InjectionFilter{
+			Side:          r.URL.Query().Get("side"),
+			IncludeVoided: r.URL.Query().Get("include_voided") == "true",
 		}
-		if offset != "" {
-			query += " OFFSET ?"
-			args = append(args, offset)
+		if v := r.URL.Query().Get("course_id"); v != "" {
+			filter.CourseID, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				respond.Validation(w, "Invalid course_id", respond.Field("course_id", "is invalid"))
+				return
+			}
+		}
+		filter.Start, filter.End, err = parseListDateRange(r, userLocation(db, userID))
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		rows, err := db.Query(query, args...)
+		result, err := repository.NewInjectionRepository(db).ListPage(accountID, filter, page)
 		if err != nil {
-			http.Error(w, "Failed to query injections", http.StatusInternalServerError)
+			listPageError(w, err, "Failed to query injections")
 			return
 		}
-		defer rows.Close()
 
 		// Get user's timezone preference
-		userID := middleware.GetUserID(r.Context())
 		userTimezone := GetUserTimezone(db, userID)
 
-		injections := []models.Injection{}
-		for rows.Next() {
-			var inj models.Injection
-			err := rows.Scan(
-				&inj.ID,
-				&inj.CourseID,
-				&inj.AdministeredBy,
-				&inj.Timestamp,
-				&inj.Side,
-				&inj.SiteX,
-				&inj.SiteY,
-				&inj.PainLevel,
-				&inj.HasKnots,
-				&inj.SiteReaction,
-				&inj.Notes,
-				&inj.CreatedAt,
-				&inj.UpdatedAt,
-			)
-			if err != nil {
-				http.Error(w, "Failed to scan injection", http.StatusInternalServerError)
-				return
-			}
-
+		respondJSON(w, http.StatusOK, newListResponse(result, func(inj *models.Injection) models.Injection {
 			// Convert timestamps to user's timezone
 			inj.Timestamp = ConvertToUserTZ(inj.Timestamp, userTimezone)
 			inj.CreatedAt = ConvertToUserTZ(inj.CreatedAt, userTimezone)
 			inj.UpdatedAt = ConvertToUserTZ(inj.UpdatedAt, userTimezone)
-
-			injections = append(injections, inj)
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(injections); err != nil {
-			log.Printf("Failed to encode injections response: %v", err)
-		}
+			return *inj
+		}))
 	}
 }
 
 // HandleGetInjection returns a single injection by ID
 func HandleGetInjection(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid injection ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid injection ID", http.StatusBadRequest)
 			return
 		}
 
-		injection, err := getInjectionByID(db, id)
+		injection, err := repository.NewInjectionRepository(db).GetByID(id, middleware.GetAccountID(r.Context()))
 		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Injection not found", http.StatusNotFound)
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Injection not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to get injection", http.StatusInternalServerError)
+			respond.Error(w, "Failed to get injection", http.StatusInternalServerError)
 			return
 		}
 
+		setVersionHeaders(w, injection.UpdatedAt)
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(injection); err != nil {
-			log.Printf("Failed to encode injection response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode injection response", "err", err)
 		}
 	}
 }
@@ -403,266 +510,273 @@ func HandleGetInjection(db *database.DB) http.HandlerFunc {
 // HandleUpdateInjection updates an existing injection
 func HandleUpdateInjection(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid injection ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid injection ID", http.StatusBadRequest)
 			return
 		}
 
 		// Parse request body
 		var req UpdateInjectionRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		// Validate side if provided
-		if req.Side != nil && *req.Side != "left" && *req.Side != "right" {
-			http.Error(w, "side must be 'left' or 'right'", http.StatusBadRequest)
+		if err := validateDoseML(req.DoseML); err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Validate pain level if provided
-		if req.PainLevel != nil && (*req.PainLevel < 1 || *req.PainLevel > 10) {
-			http.Error(w, "pain_level must be between 1 and 10", http.StatusBadRequest)
+		if req.isEmpty() {
+			respond.Error(w, "No fields to update", http.StatusBadRequest)
 			return
 		}
 
-		// Build update query dynamically
-		updates := []string{}
-		args := []interface{}{}
-
-		if req.Side != nil {
-			updates = append(updates, "side = ?")
-			args = append(args, *req.Side)
-		}
+		var timestamp time.Time
 		if req.Timestamp != nil {
-			timestamp, err := time.Parse(time.RFC3339, *req.Timestamp)
+			timestamp, err = time.Parse(time.RFC3339, *req.Timestamp)
 			if err != nil {
-				http.Error(w, "invalid timestamp format", http.StatusBadRequest)
+				respond.Validation(w, "invalid timestamp format", respond.Field("timestamp", "invalid format"))
 				return
 			}
-			updates = append(updates, "timestamp = ?")
-			args = append(args, timestamp)
+		}
+
+		accountID := middleware.GetAccountID(r.Context())
+		if req.AttachmentID != nil && *req.AttachmentID != 0 && !attachmentVisible(db, *req.AttachmentID, accountID, userID) {
+			respond.Validation(w, "attachment_id not found", respond.Field("attachment_id", "not found"))
+			return
+		}
+
+		injectionRepo := repository.NewInjectionRepository(db)
+		injection, err := injectionRepo.GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Injection not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to update injection", http.StatusInternalServerError)
+			return
+		}
+		current := *injection
+		if !checkPreconditions(w, r, injection.UpdatedAt, true, func() interface{} { return &current }) {
+			return
+		}
+		if injection.VoidedAt.Valid {
+			respond.Error(w, "Voided injections cannot be edited; restore it first", http.StatusConflict)
+			return
+		}
+
+		if req.Side != nil {
+			injection.Side = *req.Side
+		}
+		if req.Timestamp != nil {
+			injection.Timestamp = timestamp
 		}
 		if req.SiteX != nil {
-			updates = append(updates, "site_x = ?")
-			args = append(args, *req.SiteX)
+			injection.SiteX = nullFloat64(req.SiteX)
 		}
 		if req.SiteY != nil {
-			updates = append(updates, "site_y = ?")
-			args = append(args, *req.SiteY)
+			injection.SiteY = nullFloat64(req.SiteY)
 		}
 		if req.PainLevel != nil {
-			updates = append(updates, "pain_level = ?")
-			args = append(args, *req.PainLevel)
+			injection.PainLevel = nullInt(req.PainLevel)
 		}
 		if req.HasKnots != nil {
-			updates = append(updates, "has_knots = ?")
-			args = append(args, *req.HasKnots)
+			injection.HasKnots = *req.HasKnots
 		}
 		if req.SiteReaction != nil {
-			updates = append(updates, "site_reaction = ?")
-			args = append(args, *req.SiteReaction)
+			injection.SiteReaction = nullString(req.SiteReaction)
 		}
 		if req.Notes != nil {
-			updates = append(updates, "notes = ?")
-			args = append(args, *req.Notes)
-		}
-
-		if len(updates) == 0 {
-			http.Error(w, "No fields to update", http.StatusBadRequest)
-			return
-		}
-
-		updates = append(updates, "updated_at = ?")
-		args = append(args, time.Now())
-		args = append(args, id)
-
-		query := "UPDATE injections SET " + joinStrings(updates, ", ") + " WHERE id = ?"
-
-		result, err := db.Exec(query, args...)
-		if err != nil {
-			http.Error(w, "Failed to update injection", http.StatusInternalServerError)
-			return
-		}
-
-		rowsAffected, err := result.RowsAffected()
-		if err != nil || rowsAffected == 0 {
-			http.Error(w, "Injection not found", http.StatusNotFound)
+			injection.Notes = nullString(req.Notes)
+		}
+		if req.DoseML != nil {
+			injection.DoseML = nullFloat64(req.DoseML)
+		}
+		if req.AttachmentID != nil {
+			injection.AttachmentID = sql.NullInt64{Int64: *req.AttachmentID, Valid: *req.AttachmentID != 0}
+		}
+
+		// Saving corrects the medication stock if the dose changed
+		if err := injectionRepo.Amend(injection, accountID, userID, current.UpdatedAt); err != nil {
+			switch err {
+			case repository.ErrNotFound:
+				respond.Error(w, "Injection not found", http.StatusNotFound)
+			case repository.ErrInjectionVoided:
+				respond.Error(w, "Voided injections cannot be edited; restore it first", http.StatusConflict)
+			case repository.ErrStale:
+				latest, err := injectionRepo.GetByID(id, accountID)
+				if err != nil {
+					respond.Error(w, "Failed to update injection", http.StatusInternalServerError)
+					return
+				}
+				respondPreconditionFailed(w, latest.UpdatedAt, latest)
+			default:
+				respond.Error(w, fmt.Sprintf("Failed to update injection: %v", err), http.StatusInternalServerError)
+			}
 			return
 		}
 
-		// Create audit log
-		_, _ = db.Exec(`
-			INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details, timestamp)
-			VALUES (?, ?, ?, ?, ?, ?)
-		`, userID, "update", "injection", id, "Updated injection", time.Now())
-
 		// Return updated injection
-		injection, err := getInjectionByID(db, id)
+		injection, err = injectionRepo.GetByID(id, accountID)
 		if err != nil {
-			http.Error(w, "Failed to retrieve updated injection", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve updated injection", http.StatusInternalServerError)
 			return
 		}
 
+		setVersionHeaders(w, injection.UpdatedAt)
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(injection); err != nil {
-			log.Printf("Failed to encode injection response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode injection response", "err", err)
 		}
 	}
 }
 
-// HandleDeleteInjection deletes an injection and ROLLBACKS inventory changes
+// VoidInjectionRequest is the optional body of a void (DELETE) request
+type VoidInjectionRequest struct {
+	Reason *string `json:"reason,omitempty" validate:"multiline,max=500"` // The injections.void_reason CHECK
+}
+
+// HandleDeleteInjection voids an injection and returns its inventory. The row
+// is kept, with who voided it, when and an optional reason (JSON body or
+// ?reason=), so it stays in the audit trail and can be restored.
 func HandleDeleteInjection(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid injection ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid injection ID", http.StatusBadRequest)
 			return
 		}
 
-		// Begin transaction
-		tx, err := db.BeginTx()
-		if err != nil {
-			http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
-			return
+		var req VoidInjectionRequest
+		if r.ContentLength != 0 {
+			if !decodeRequest(w, r, &req) {
+				return
+			}
+		}
+		if req.Reason == nil {
+			if q := r.URL.Query().Get("reason"); q != "" {
+				req.Reason = &q
+				if !validRequest(w, &req) {
+					return
+				}
+			}
 		}
-		defer func() { _ = tx.Rollback() }()
 
-		// Get inventory changes for this injection
-		rows, err := tx.Query(`
-			SELECT item_type, change_amount, quantity_before
-			FROM inventory_history
-			WHERE reference_id = ? AND reference_type = 'injection'
-		`, id)
+		accountID := middleware.GetAccountID(r.Context())
+		injectionRepo := repository.NewInjectionRepository(db)
+		injection, err := injectionRepo.GetByID(id, accountID)
 		if err != nil {
-			http.Error(w, "Failed to query inventory history", http.StatusInternalServerError)
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Injection not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to void injection", http.StatusInternalServerError)
 			return
 		}
-
-		type inventoryRollback struct {
-			itemType  string
-			amount    float64
-			qtyBefore float64
+		if !checkPreconditions(w, r, injection.UpdatedAt, false, func() interface{} { return injection }) {
+			return
 		}
-		rollbacks := []inventoryRollback{}
 
-		for rows.Next() {
-			var rb inventoryRollback
-			if err := rows.Scan(&rb.itemType, &rb.amount, &rb.qtyBefore); err != nil {
-				rows.Close()
-				http.Error(w, "Failed to scan inventory history", http.StatusInternalServerError)
-				return
+		if err := injectionRepo.Void(id, accountID, userID, nullString(req.Reason)); err != nil {
+			switch err {
+			case repository.ErrNotFound:
+				respond.Error(w, "Injection not found", http.StatusNotFound)
+			case repository.ErrInjectionVoided:
+				respond.Error(w, "Injection is already voided", http.StatusConflict)
+			default:
+				respond.Error(w, fmt.Sprintf("Failed to void injection: %v", err), http.StatusInternalServerError)
 			}
-			rollbacks = append(rollbacks, rb)
+			return
 		}
-		rows.Close()
-
-		// Rollback inventory changes
-		for _, rb := range rollbacks {
-			// Get current quantity
-			var currentQty float64
-			err := tx.QueryRow(`SELECT quantity FROM inventory_items WHERE item_type = ?`, rb.itemType).Scan(&currentQty)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to get current inventory for %s", rb.itemType), http.StatusInternalServerError)
-				return
-			}
 
-			// Reverse the change (add back what was subtracted)
-			newQty := currentQty - rb.amount
-
-			// Update inventory
-			_, err = tx.Exec(`
-				UPDATE inventory_items
-				SET quantity = ?, updated_at = ?
-				WHERE item_type = ?
-			`, newQty, time.Now(), rb.itemType)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to rollback inventory for %s", rb.itemType), http.StatusInternalServerError)
-				return
-			}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
 
-			// Log the rollback
-			_, err = tx.Exec(`
-				INSERT INTO inventory_history (
-					item_type, change_amount, quantity_before, quantity_after,
-					reason, reference_id, reference_type, performed_by, timestamp, notes
-				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-			`,
-				rb.itemType,
-				-rb.amount, // Opposite of the original change
-				currentQty,
-				newQty,
-				"other",
-				id,
-				"injection",
-				userID,
-				time.Now(),
-				fmt.Sprintf("Rollback for deleted injection #%d", id),
-			)
-			if err != nil {
-				http.Error(w, "Failed to log inventory rollback", http.StatusInternalServerError)
-				return
-			}
+// HandleRestoreInjection un-voids an injection, taking its dose and the
+// current consumption profile out of inventory again
+func HandleRestoreInjection(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
 
-		// Delete the injection
-		result, err := tx.Exec("DELETE FROM injections WHERE id = ?", id)
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Failed to delete injection", http.StatusInternalServerError)
+			respond.Error(w, "Invalid injection ID", http.StatusBadRequest)
 			return
 		}
 
-		rowsAffected, err := result.RowsAffected()
-		if err != nil || rowsAffected == 0 {
-			http.Error(w, "Injection not found", http.StatusNotFound)
+		accountID := middleware.GetAccountID(r.Context())
+		injectionRepo := repository.NewInjectionRepository(db)
+		usage, err := injectionRepo.Restore(id, accountID, userID)
+		if err != nil {
+			switch err {
+			case repository.ErrNotFound:
+				respond.Error(w, "Injection not found", http.StatusNotFound)
+			case repository.ErrInjectionNotVoided:
+				respond.Error(w, "Injection is not voided", http.StatusConflict)
+			default:
+				respond.Error(w, fmt.Sprintf("Failed to restore injection: %v", err), http.StatusInternalServerError)
+			}
 			return
 		}
 
-		// Create audit log
-		_, _ = tx.Exec(`
-			INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details, timestamp)
-			VALUES (?, ?, ?, ?, ?, ?)
-		`, userID, "delete", "injection", id, "Deleted injection with inventory rollback", time.Now())
+		for itemType, before := range usage.QuantitiesBefore {
+			if item, err := getInventoryItemByType(db, accountID, itemType); err == nil {
+				emitLowStockIfCrossed(db, accountID, item, before)
+			}
+		}
 
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		injection, err := injectionRepo.GetByID(id, accountID)
+		if err != nil {
+			respond.Error(w, "Injection restored but failed to retrieve", http.StatusInternalServerError)
 			return
 		}
 
-		w.WriteHeader(http.StatusNoContent)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(injection); err != nil {
+			middleware.Log(r.Context()).Error("Failed to encode injection response", "err", err)
+		}
 	}
 }
 
 // HandleGetRecentInjections returns the last 10 injections
 func HandleGetRecentInjections(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		rows, err := db.Query(`
 			SELECT id, course_id, administered_by, timestamp, side,
 				site_x, site_y, pain_level, has_knots, site_reaction,
-				notes, created_at, updated_at
+				notes, dose_ml, attachment_id, lot_id, created_at, updated_at
 			FROM injections
+			WHERE voided_at IS NULL
 			ORDER BY timestamp DESC
 			LIMIT 10
 		`)
 		if err != nil {
-			http.Error(w, "Failed to query recent injections", http.StatusInternalServerError)
+			respond.Error(w, "Failed to query recent injections", http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
@@ -682,11 +796,14 @@ func HandleGetRecentInjections(db *database.DB) http.HandlerFunc {
 				&inj.HasKnots,
 				&inj.SiteReaction,
 				&inj.Notes,
+				&inj.DoseML,
+				&inj.AttachmentID,
+				&inj.LotID,
 				&inj.CreatedAt,
 				&inj.UpdatedAt,
 			)
 			if err != nil {
-				http.Error(w, "Failed to scan injection", http.StatusInternalServerError)
+				respond.Error(w, "Failed to scan injection", http.StatusInternalServerError)
 				return
 			}
 			injections = append(injections, inj)
@@ -694,62 +811,240 @@ func HandleGetRecentInjections(db *database.DB) http.HandlerFunc {
 
 		// Check if request wants HTML (from HTMX)
 		if r.Header.Get("HX-Request") == "true" {
-			w.Header().Set("Content-Type", "text/html")
 			if len(injections) == 0 {
-				_, _ = w.Write([]byte(`<p style="text-align: center; color: var(--pico-muted-color);">No injections recorded yet.</p>`))
+				renderFragment(w, r, http.StatusOK, "empty.html", web.EmptyState{Message: "No injections recorded yet."})
 				return
 			}
 
-			html := `<div class="overflow-auto"><table><thead><tr>
-				<th>Date</th><th>Side</th><th>Pain</th><th>Notes</th>
-			</tr></thead><tbody>`
-
+			p := i18n.FromContext(r.Context())
+			type injectionRow struct {
+				Date  string
+				Side  string
+				Pain  string
+				Notes string
+			}
+			rows := make([]injectionRow, 0, len(injections))
 			for _, inj := range injections {
-				pain := "N/A"
+				row := injectionRow{
+					Date: p.DateTime(inj.Timestamp),
+					Side: cases.Title(language.English).String(inj.Side),
+					Pain: p.T("N/A"),
+				}
 				if inj.PainLevel.Valid {
-					pain = fmt.Sprintf("%d/10", inj.PainLevel.Int64)
+					row.Pain = fmt.Sprintf("%d/10", inj.PainLevel.Int64)
 				}
-				notes := ""
 				if inj.Notes.Valid {
-					notes = inj.Notes.String
-					if len(notes) > 50 {
-						notes = notes[:50] + "..."
+					row.Notes = inj.Notes.String
+					if len([]rune(row.Notes)) > 50 {
+						row.Notes = string([]rune(row.Notes)[:50]) + "..."
 					}
 				}
-				html += fmt.Sprintf(`<tr>
-					<td>%s</td>
-					<td>%s</td>
-					<td>%s</td>
-					<td>%s</td>
-				</tr>`, inj.Timestamp.Format("Jan 2, 2006 3:04 PM"), inj.Side, pain, notes)
+				rows = append(rows, row)
 			}
-
-			html += `</tbody></table></div>`
-			_, _ = w.Write([]byte(html))
+			renderFragment(w, r, http.StatusOK, "recent_injections.html", rows)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(injections); err != nil {
-			log.Printf("Failed to encode injections response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode injections response", "err", err)
 		}
 	}
 }
 
+// suggestNextInjectionSite runs the rotation planner over the account's recent injections
+func suggestNextInjectionSite(db *database.DB, accountID int64, rules services.SiteRotationRules) (*services.SiteSuggestion, error) {
+	now := time.Now()
+	injectionRepo := repository.NewInjectionRepository(db)
+	history, err := injectionRepo.ListByDateRange(accountID, now.AddDate(0, 0, -rules.LookbackDays), now, 500, 0)
+	if err != nil {
+		return nil, err
+	}
+	return services.SuggestNextSite(history, now, rules), nil
+}
+
+// HandleGetNextSite suggests the side and spot for the next injection
+func HandleGetNextSite(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		rules := services.DefaultSiteRotationRules()
+		if minDaysStr := r.URL.Query().Get("min_days"); minDaysStr != "" {
+			minDays, err := strconv.Atoi(minDaysStr)
+			if err != nil || minDays < 0 || minDays > 90 {
+				respond.Validation(w, "min_days must be between 0 and 90", respond.Field("min_days", "must be between 0 and 90"))
+				return
+			}
+			rules.MinDaysSameSite = minDays
+			if rules.LookbackDays < minDays {
+				rules.LookbackDays = minDays
+			}
+		}
+
+		suggestion, err := suggestNextInjectionSite(db, accountID, rules)
+		if err != nil {
+			respond.Error(w, "Failed to compute next injection site", http.StatusInternalServerError)
+			return
+		}
+
+		if r.Header.Get("HX-Request") == "true" {
+			renderFragment(w, r, http.StatusOK, "next_site.html", map[string]interface{}{
+				"Side":    cases.Title(language.English).String(suggestion.Side),
+				"Reasons": suggestion.Reasons,
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(suggestion); err != nil {
+			middleware.Log(r.Context()).Error("Failed to encode next site response", "err", err)
+		}
+	}
+}
+
+// statsDays is how many days, up to today, the daily stats cover
+const statsDays = 30
+
+// injectionDailyStats groups the injections matching whereClause over the
+// statsDays days up to now by day in loc and returns how many there were,
+// the average pain (over days where it was recorded) and the total dose.
+// Both lists come newest first. Days are summed by the database, one query
+// per stretch of the window over which loc's offset from UTC stays the same.
+func injectionDailyStats(db *database.DB, whereClause string, args []interface{}, loc *time.Location, now time.Time) (map[string]int, []PainTrendPoint, []DosePoint, error) {
+	type day struct {
+		count  int
+		pain   float64
+		painN  int
+		doseML float64
+		doseMG float64
+		withMG int
+	}
+	days := map[string]*day{}
+
+	today := now.In(loc)
+	from := time.Date(today.Year(), today.Month(), today.Day()-(statsDays-1), 0, 0, 0, 0, loc)
+	for {
+		// The last stretch is left open, so injections logged ahead of now
+		// are counted as before
+		_, offset := from.Zone()
+		_, until := from.ZoneBounds()
+		bound := " AND injections.timestamp >= ?"
+		boundArgs := []interface{}{from.UTC()}
+		last := until.IsZero() || until.After(now)
+		if !last {
+			bound += " AND injections.timestamp < ?"
+			boundArgs = append(boundArgs, until.UTC())
+		}
+
+		rows, err := db.Query(`
+			SELECT `+db.Dialect.Date("injections.timestamp", offset)+`, COUNT(*),
+				SUM(injections.pain_level), COUNT(injections.pain_level),
+				SUM(COALESCE(injections.dose_ml, ?)),
+				SUM(COALESCE(injections.dose_ml, ?) * COALESCE(c.concentration_mg_per_ml, m.concentration_mg_per_ml)),
+				COUNT(COALESCE(c.concentration_mg_per_ml, m.concentration_mg_per_ml))
+			FROM injections
+			JOIN courses c ON c.id = injections.course_id
+			LEFT JOIN compounds m ON m.id = c.compound_id
+		`+whereClause+bound+` GROUP BY 1`, append(append([]interface{}{defaultDoseML, defaultDoseML}, args...), boundArgs...)...)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to query injections: %w", err)
+		}
+		for rows.Next() {
+			var key string
+			var n, painN, withMG int
+			var pain, doseMG sql.NullFloat64
+			var doseML float64
+			if err := rows.Scan(&key, &n, &pain, &painN, &doseML, &doseMG, &withMG); err != nil {
+				rows.Close()
+				return nil, nil, nil, fmt.Errorf("failed to scan injection day: %w", err)
+			}
+			// A day split by a change of offset is summed from both stretches
+			d := days[key]
+			if d == nil {
+				d = &day{}
+				days[key] = d
+			}
+			d.count += n
+			d.pain += pain.Float64
+			d.painN += painN
+			d.doseML += doseML
+			d.doseMG += doseMG.Float64
+			d.withMG += withMG
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to read injections: %w", err)
+		}
+		if last {
+			break
+		}
+		from = until
+	}
+
+	keys := make([]string, 0, len(days))
+	for key := range days {
+		keys = append(keys, key)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	frequency := map[string]int{}
+	painTrend := []PainTrendPoint{}
+	doseHistory := []DosePoint{}
+	for _, key := range keys {
+		d := days[key]
+		frequency[key] = d.count
+		if d.painN > 0 {
+			painTrend = append(painTrend, PainTrendPoint{Date: key, PainLevel: d.pain / float64(d.painN)})
+		}
+		point := DosePoint{Date: key, DoseML: d.doseML}
+		if d.withMG == d.count {
+			mg := d.doseMG
+			point.DoseMG = &mg
+		}
+		doseHistory = append(doseHistory, point)
+	}
+	return frequency, painTrend, doseHistory, nil
+}
+
 // HandleGetInjectionStats returns statistics for injections
 func HandleGetInjectionStats(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		courseID := r.URL.Query().Get("course_id")
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var courseID int64
+		if v := r.URL.Query().Get("course_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id <= 0 {
+				respond.Validation(w, "Invalid course_id", respond.Field("course_id", "must be a positive integer"))
+				return
+			}
+			courseID = id
+		}
 
 		stats := InjectionStatsResponse{
 			FrequencyByDay: make(map[string]int),
 			PainTrend:      []PainTrendPoint{},
+			DoseHistory:    []DosePoint{},
+			Labs:           []LabSeries{},
 		}
 
-		// Build query based on whether course_id is provided
-		whereClause := " WHERE 1=1"
-		args := []interface{}{}
-		if courseID != "" {
+		// Without a course_id the figures combine every course in the account;
+		// voided injections never count
+		whereClause := " WHERE voided_at IS NULL AND course_id IN (SELECT id FROM courses WHERE account_id = ?)"
+		args := []interface{}{accountID}
+		if courseID != 0 {
 			whereClause += " AND course_id = ?"
 			args = append(args, courseID)
 		}
@@ -774,7 +1069,7 @@ func HandleGetInjectionStats(db *database.DB) http.HandlerFunc {
 		query = `
 			SELECT id, course_id, administered_by, timestamp, side,
 				site_x, site_y, pain_level, has_knots, site_reaction,
-				notes, created_at, updated_at
+				notes, dose_ml, attachment_id, lot_id, created_at, updated_at
 			FROM injections
 		` + whereClause + " ORDER BY timestamp DESC LIMIT 1"
 
@@ -791,6 +1086,9 @@ func HandleGetInjectionStats(db *database.DB) http.HandlerFunc {
 			&lastInj.HasKnots,
 			&lastInj.SiteReaction,
 			&lastInj.Notes,
+			&lastInj.DoseML,
+			&lastInj.AttachmentID,
+			&lastInj.LotID,
 			&lastInj.CreatedAt,
 			&lastInj.UpdatedAt,
 		)
@@ -798,110 +1096,139 @@ func HandleGetInjectionStats(db *database.DB) http.HandlerFunc {
 			stats.LastInjection = &lastInj
 		}
 
-		// Get frequency by day
+		// Daily figures go by the day in the user's timezone, so an evening
+		// injection isn't counted on the next day as it would be in UTC
+		loc := userLocation(db, userID)
+		if frequency, painTrend, doseHistory, err := injectionDailyStats(db, whereClause, args, loc, time.Now()); err != nil {
+			middleware.Log(r.Context()).Error("Failed to compute daily injection stats", "err", err)
+		} else {
+			stats.FrequencyByDay, stats.PainTrend, stats.DoseHistory = frequency, painTrend, doseHistory
+		}
+
+		// Get dose totals; mg is only reported when every course involved has a concentration
+		var totalDose, totalMG sql.NullFloat64
+		var withConcentration int
 		query = `
-			SELECT DATE(timestamp) as day, COUNT(*) as count
+			SELECT SUM(COALESCE(injections.dose_ml, ?)),
+				SUM(COALESCE(injections.dose_ml, ?) * COALESCE(c.concentration_mg_per_ml, m.concentration_mg_per_ml)),
+				COUNT(COALESCE(c.concentration_mg_per_ml, m.concentration_mg_per_ml))
 			FROM injections
-		` + whereClause + `
-			GROUP BY DATE(timestamp)
-			ORDER BY day DESC
-			LIMIT 30
-		`
-		rows, err := db.Query(query, args...)
-		if err == nil {
-			defer rows.Close()
-			for rows.Next() {
-				var day string
-				var count int
-				if err := rows.Scan(&day, &count); err == nil {
-					stats.FrequencyByDay[day] = count
-				}
+			JOIN courses c ON c.id = injections.course_id
+			LEFT JOIN compounds m ON m.id = c.compound_id
+		` + whereClause
+		doseArgs := append([]interface{}{defaultDoseML, defaultDoseML}, args...)
+		if err := db.QueryRow(query, doseArgs...).Scan(&totalDose, &totalMG, &withConcentration); err == nil {
+			stats.TotalDoseML = totalDose.Float64
+			if stats.TotalInjections > 0 {
+				stats.AvgDoseML = totalDose.Float64 / float64(stats.TotalInjections)
+			}
+			if totalMG.Valid && withConcentration == stats.TotalInjections {
+				stats.TotalDoseMG = &totalMG.Float64
 			}
 		}
 
-		// Get pain trend (last 30 days)
-		query = `
-			SELECT DATE(timestamp) as day, AVG(CAST(pain_level AS REAL)) as avg_pain
-			FROM injections
-		` + whereClause + ` AND pain_level IS NOT NULL
-			GROUP BY DATE(timestamp)
-			ORDER BY day DESC
-			LIMIT 30
-		`
-		rows, err = db.Query(query, args...)
-		if err == nil {
-			defer rows.Close()
-			for rows.Next() {
-				var point PainTrendPoint
-				if err := rows.Scan(&point.Date, &point.PainLevel); err == nil {
-					stats.PainTrend = append(stats.PainTrend, point)
-				}
+		// Both lists come newest first
+		var since string
+		if n := len(stats.PainTrend); n > 0 {
+			since = stats.PainTrend[n-1].Date
+		}
+		if n := len(stats.DoseHistory); n > 0 && (since == "" || stats.DoseHistory[n-1].Date < since) {
+			since = stats.DoseHistory[n-1].Date
+		}
+		if start, err := time.ParseInLocation("2006-01-02", since, loc); err == nil {
+			labs, err := repository.NewLabResultRepository(db).List(accountID, repository.LabResultFilter{CourseID: courseID, Start: start}, maxLabTrendResults)
+			if err != nil {
+				middleware.Log(r.Context()).Error("Failed to load lab results for stats", "err", err)
+			} else {
+				stats.Labs = labSeries(labs, loc, nil)
 			}
 		}
 
+		if courseID == 0 {
+			courses, err := activeCourseSummaries(db, accountID)
+			if err != nil {
+				middleware.Log(r.Context()).Error("Failed to summarise active courses", "err", err)
+				respond.Error(w, "Failed to compute statistics", http.StatusInternalServerError)
+				return
+			}
+			stats.Courses = courses
+		}
+
 		// Check if request wants HTML (from HTMX)
 		if r.Header.Get("HX-Request") == "true" {
-			w.Header().Set("Content-Type", "text/html")
-			html := fmt.Sprintf(`
-				<div style="display: grid; grid-template-columns: repeat(auto-fit, minmax(150px, 1fr)); gap: 1rem;">
-					<div style="text-align: center;">
-						<div style="font-size: 0.85rem; color: var(--color-text-secondary); text-transform: uppercase; letter-spacing: 0.05em; margin-bottom: 0.5rem;">Total</div>
-						<div style="font-size: 2rem; font-weight: bold; color: var(--brand-primary); line-height: 1;">%d</div>
-					</div>
-					<div style="text-align: center;">
-						<div style="font-size: 0.85rem; color: var(--color-text-secondary); text-transform: uppercase; letter-spacing: 0.05em; margin-bottom: 0.5rem;">Left</div>
-						<div style="font-size: 2rem; font-weight: bold; color: var(--color-text-primary); line-height: 1;">%d</div>
-					</div>
-					<div style="text-align: center;">
-						<div style="font-size: 0.85rem; color: var(--color-text-secondary); text-transform: uppercase; letter-spacing: 0.05em; margin-bottom: 0.5rem;">Right</div>
-						<div style="font-size: 2rem; font-weight: bold; color: var(--color-text-primary); line-height: 1;">%d</div>
-					</div>
-					<div style="text-align: center;">
-						<div style="font-size: 0.85rem; color: var(--color-text-secondary); text-transform: uppercase; letter-spacing: 0.05em; margin-bottom: 0.5rem;">Avg Pain</div>
-						<div style="font-size: 2rem; font-weight: bold; color: var(--color-text-primary); line-height: 1;">%.1f<small style="font-size: 1rem; color: var(--color-text-muted);">/10</small></div>
-					</div>
-				</div>
-			`, stats.TotalInjections, stats.LeftCount, stats.RightCount, stats.AvgPainLevel)
-			_, _ = w.Write([]byte(html))
+			renderFragment(w, r, http.StatusOK, "injection_stats.html", stats)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(stats); err != nil {
-			log.Printf("Failed to encode stats response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode stats response", "err", err)
 		}
 	}
 }
 
-// Helper functions
-
-func getInjectionByID(db *database.DB, id int64) (*models.Injection, error) {
-	var inj models.Injection
-	err := db.QueryRow(`
-		SELECT id, course_id, administered_by, timestamp, side,
-			site_x, site_y, pain_level, has_knots, site_reaction,
-			notes, created_at, updated_at
-		FROM injections
-		WHERE id = ?
-	`, id).Scan(
-		&inj.ID,
-		&inj.CourseID,
-		&inj.AdministeredBy,
-		&inj.Timestamp,
-		&inj.Side,
-		&inj.SiteX,
-		&inj.SiteY,
-		&inj.PainLevel,
-		&inj.HasKnots,
-		&inj.SiteReaction,
-		&inj.Notes,
-		&inj.CreatedAt,
-		&inj.UpdatedAt,
-	)
+// activeCourseSummaries returns the injection figures for each of the account's
+// active courses, most recently started first
+func activeCourseSummaries(db *database.DB, accountID int64) ([]CourseInjectionSummary, error) {
+	courses, err := repository.NewCourseRepository(db).ListActive(accountID)
 	if err != nil {
 		return nil, err
 	}
-	return &inj, nil
+
+	summaries := make([]CourseInjectionSummary, 0, len(courses))
+	for _, course := range courses {
+		summary := CourseInjectionSummary{CourseID: course.ID, Name: course.Name}
+
+		var avgPain, totalDose sql.NullFloat64
+		err := db.QueryRow(`
+			SELECT COUNT(*),
+				COALESCE(SUM(CASE WHEN side = 'left' THEN 1 ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN side = 'right' THEN 1 ELSE 0 END), 0),
+				AVG(CAST(pain_level AS REAL)),
+				SUM(COALESCE(dose_ml, ?))
+			FROM injections
+			WHERE course_id = ? AND voided_at IS NULL
+		`, defaultDoseML, course.ID).Scan(&summary.TotalInjections, &summary.LeftCount, &summary.RightCount, &avgPain, &totalDose)
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarise course %d: %w", course.ID, err)
+		}
+		summary.AvgPainLevel = avgPain.Float64
+		summary.TotalDoseML = totalDose.Float64
+
+		var last time.Time
+		err = db.QueryRow(`
+			SELECT timestamp FROM injections
+			WHERE course_id = ? AND voided_at IS NULL
+			ORDER BY timestamp DESC
+			LIMIT 1
+		`, course.ID).Scan(&last)
+		if err == nil {
+			summary.LastInjectionAt = &last
+		} else if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to get last injection for course %d: %w", course.ID, err)
+		}
+
+		if course.CompoundID.Valid {
+			err = db.QueryRow(`SELECT name FROM compounds WHERE id = ? AND account_id = ?`,
+				course.CompoundID.Int64, accountID).Scan(&summary.Compound)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, fmt.Errorf("failed to get compound for course %d: %w", course.ID, err)
+			}
+		}
+
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// Helper functions
+
+// nullableInt64 converts a sql.NullInt64 to a JSON-friendly value (nil when not set)
+func nullableInt64(v sql.NullInt64) interface{} {
+	if !v.Valid {
+		return nil
+	}
+	return v.Int64
 }
 
 func nullInt64(v *int64) sql.NullInt64 {
@@ -931,14 +1258,3 @@ func nullString(v *string) sql.NullString {
 	}
 	return sql.NullString{String: *v, Valid: true}
 }
-
-func joinStrings(strs []string, sep string) string {
-	if len(strs) == 0 {
-		return ""
-	}
-	result := strs[0]
-	for i := 1; i < len(strs); i++ {
-		result += sep + strs[i]
-	}
-	return result
-}
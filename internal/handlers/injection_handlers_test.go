@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestActiveCourseSummaries(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	now := time.Now()
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner');
+		INSERT INTO compounds (id, account_id, name, inventory_item_type) VALUES (100, 1, 'Estradiol valerate', 'estradiol');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Progesterone', ?, 1)`, now.AddDate(0, 0, -20)); err != nil {
+		t.Fatalf("Failed to seed course: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO courses (id, account_id, name, start_date, is_active, compound_id) VALUES (2, 1, 'Estradiol', ?, 0, 100)`, now.AddDate(0, 0, -10)); err != nil {
+		t.Fatalf("Failed to seed course: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO injections (course_id, timestamp, side, pain_level) VALUES (1, ?, 'left', 4), (1, ?, 'right', 2), (2, ?, 'left', 6)`,
+		now.AddDate(0, 0, -2), now.AddDate(0, 0, -1), now.Add(-time.Hour)); err != nil {
+		t.Fatalf("Failed to seed injections: %v", err)
+	}
+
+	if err := repository.NewCourseRepository(db).Activate(2, 1); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+
+	summaries, err := activeCourseSummaries(db, 1)
+	if err != nil {
+		t.Fatalf("activeCourseSummaries failed: %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("Expected activating a course to leave the other running, got %+v", summaries)
+	}
+	estradiol, progesterone := summaries[0], summaries[1]
+	if estradiol.CourseID != 2 || estradiol.Compound != "Estradiol valerate" || estradiol.TotalInjections != 1 || estradiol.LastInjectionAt == nil {
+		t.Errorf("Unexpected summary for the newest course: %+v", estradiol)
+	}
+	if progesterone.CourseID != 1 || progesterone.LeftCount != 1 || progesterone.RightCount != 1 || progesterone.AvgPainLevel != 3 {
+		t.Errorf("Unexpected summary for the older course: %+v", progesterone)
+	}
+}
+
+func TestInjectionDailyStats(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Progesterone', '2026-03-01 00:00:00', 1);
+	`); err != nil {
+		t.Fatalf("Failed to seed course: %v", err)
+	}
+	// 02:30 UTC on the 3rd is the evening of the 2nd in New York. Clocks go
+	// forward on the 8th, after which 04:30 UTC on the 10th is just past
+	// midnight there. January is before the window.
+	for _, inj := range []struct {
+		at   time.Time
+		pain interface{}
+		dose float64
+	}{
+		{time.Date(2026, 1, 15, 14, 0, 0, 0, time.UTC), 8, 1},
+		{time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC), 2, 1},
+		{time.Date(2026, 3, 3, 2, 30, 0, 0, time.UTC), 6, 0.5},
+		{time.Date(2026, 3, 3, 15, 0, 0, 0, time.UTC), nil, 1},
+		{time.Date(2026, 3, 10, 4, 30, 0, 0, time.UTC), nil, 1},
+	} {
+		if _, err := db.Exec(`INSERT INTO injections (course_id, timestamp, side, pain_level, dose_ml) VALUES (1, ?, 'left', ?, ?)`, inj.at, inj.pain, inj.dose); err != nil {
+			t.Fatalf("Failed to seed injection: %v", err)
+		}
+	}
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data not available")
+	}
+	where := " WHERE voided_at IS NULL AND course_id IN (SELECT id FROM courses WHERE account_id = ?)"
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	frequency, pain, dose, err := injectionDailyStats(db, where, []interface{}{int64(1)}, newYork, now)
+	if err != nil {
+		t.Fatalf("injectionDailyStats failed: %v", err)
+	}
+	if len(frequency) != 3 || frequency["2026-03-02"] != 2 || frequency["2026-03-03"] != 1 || frequency["2026-03-10"] != 1 {
+		t.Errorf("Expected 2 injections on the 2nd and 1 on each of the 3rd and 10th, got %v", frequency)
+	}
+	if len(pain) != 1 || pain[0].Date != "2026-03-02" || pain[0].PainLevel != 4 {
+		t.Errorf("Expected an average pain of 4 on the 2nd only, got %+v", pain)
+	}
+	if len(dose) != 3 || dose[1].Date != "2026-03-03" || dose[1].DoseML != 1 || dose[2].DoseML != 1.5 || dose[2].DoseMG != nil {
+		t.Errorf("Unexpected dose history %+v", dose)
+	}
+
+	// The same injections by UTC day
+	if frequency, _, _, _ := injectionDailyStats(db, where, []interface{}{int64(1)}, time.UTC, now); frequency["2026-03-03"] != 2 {
+		t.Errorf("Expected 2 injections on the 3rd in UTC, got %v", frequency)
+	}
+
+	// The window moves on with today
+	if frequency, _, _, _ := injectionDailyStats(db, where, []interface{}{int64(1)}, newYork, now.AddDate(0, 0, 25)); len(frequency) != 1 {
+		t.Errorf("Expected only the 10th left in the window, got %v", frequency)
+	}
+}
+
+func TestHandleCreateInjectionConcurrentDuplicates(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'one', 'hash'), (2, 'two', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner'), (1, 2, 'member');
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Progesterone', '2026-03-01 00:00:00', 1);
+	`); err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+
+	// Both members log the same dose at the same moment, a few times over
+	const requests = 6
+	handler := HandleCreateInjection(db)
+	codes := make([]int, requests)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := `{"course_id": 1, "side": "left", "timestamp": "2026-03-10T19:00:00Z"}`
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/injections", strings.NewReader(body))
+			userCtx := &middleware.UserContext{UserID: int64(i%2 + 1), AccountID: 1, Role: "owner"}
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, userCtx))
+			rec := httptest.NewRecorder()
+			<-start
+			handler(rec, req)
+			codes[i] = rec.Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	created := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Errorf("Expected 201 or 409, got %d", code)
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected one request to log the injection, got %d (%v)", created, codes)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM injections`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("Expected one injection logged, got %d (%v)", n, err)
+	}
+}
+
+func TestHandleUpdateInjectionSameETag(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'one', 'hash'), (2, 'two', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner'), (1, 2, 'member');
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Progesterone', '2026-03-01 00:00:00', 1);
+		INSERT INTO injections (id, course_id, timestamp, side) VALUES (1, 1, '2026-03-10 19:00:00', 'left');
+	`); err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+	injection, err := repository.NewInjectionRepository(db).GetByID(1, 1)
+	if err != nil {
+		t.Fatalf("Failed to get injection: %v", err)
+	}
+	etag := versionETag(injection.UpdatedAt)
+
+	// Both members save a change to the version they loaded at once
+	router := chi.NewRouter()
+	router.Put("/api/injections/{id}", HandleUpdateInjection(db))
+	recs := make([]*httptest.ResponseRecorder, 2)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range recs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"notes": "edited by %d"}`, i+1)
+			req := httptest.NewRequest(http.MethodPut, "/api/injections/1", strings.NewReader(body))
+			req.Header.Set("If-Match", etag)
+			userCtx := &middleware.UserContext{UserID: int64(i + 1), AccountID: 1, Role: "owner"}
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, userCtx))
+			recs[i] = httptest.NewRecorder()
+			<-start
+			router.ServeHTTP(recs[i], req)
+		}(i)
+	}
+	// Holding the write lock lets both load the injection and pass the
+	// If-Match check before either saves
+	hold, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	close(start)
+	time.Sleep(100 * time.Millisecond)
+	_ = hold.Rollback()
+	wg.Wait()
+
+	var saved, refused *httptest.ResponseRecorder
+	for _, rec := range recs {
+		switch rec.Code {
+		case http.StatusOK:
+			saved = rec
+		case http.StatusPreconditionFailed:
+			refused = rec
+		}
+	}
+	if saved == nil || refused == nil {
+		t.Fatalf("Expected one 200 and one 412, got %d and %d", recs[0].Code, recs[1].Code)
+	}
+
+	// The one refused is shown the change that won
+	var body struct {
+		Current models.Injection `json:"current"`
+	}
+	if err := json.Unmarshal(refused.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode 412 body: %v", err)
+	}
+	if got := refused.Header().Get("ETag"); got != saved.Header().Get("ETag") {
+		t.Errorf("Expected the 412 to carry the saved version %s, got %s", saved.Header().Get("ETag"), got)
+	}
+	var notes string
+	if err := db.QueryRow(`SELECT notes FROM injections WHERE id = 1`).Scan(&notes); err != nil {
+		t.Fatalf("Failed to read notes: %v", err)
+	}
+	if !body.Current.Notes.Valid || body.Current.Notes.String != notes {
+		t.Errorf("Expected the 412 to show the saved notes %q, got %+v", notes, body.Current.Notes)
+	}
+}
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// StartInjectionSessionRequest starts a guided injection on a course
+type StartInjectionSessionRequest struct {
+	CourseID int64 `json:"course_id" validate:"required"`
+}
+
+// InjectionSessionWarmingRequest starts or stops a session's warm-up timer
+type InjectionSessionWarmingRequest struct {
+	Action string `json:"action" validate:"required,oneof=start stop"`
+}
+
+// InjectionSessionDetailsRequest is the injection as entered during a
+// session. Timestamp defaults to when the details were recorded.
+type InjectionSessionDetailsRequest struct {
+	Side           string   `json:"side" validate:"required,oneof=left right"`
+	Timestamp      *string  `json:"timestamp,omitempty"`
+	SiteX          *float64 `json:"site_x,omitempty"`
+	SiteY          *float64 `json:"site_y,omitempty"`
+	PainLevel      *int     `json:"pain_level,omitempty" validate:"min=1,max=10"`
+	HasKnots       bool     `json:"has_knots"`
+	SiteReaction   *string  `json:"site_reaction,omitempty" validate:"oneof=none redness swelling bruising other"`
+	Notes          *string  `json:"notes,omitempty" validate:"multiline,max=2000"`
+	AdministeredBy *int64   `json:"administered_by,omitempty"`
+	DoseML         *float64 `json:"dose_ml,omitempty"` // Overrides the course dose
+	AttachmentID   *int64   `json:"attachment_id,omitempty"`
+}
+
+func (req InjectionSessionDetailsRequest) injectionDetails() injectionDetails {
+	return injectionDetails{req.DoseML, req.AttachmentID}
+}
+
+// InjectionSessionResponse is a guided injection session as returned by
+// the API
+type InjectionSessionResponse struct {
+	ID              int64                           `json:"id"`
+	CourseID        int64                           `json:"course_id"`
+	Status          string                          `json:"status"` // open, completed or cancelled
+	Step            string                          `json:"step"`   // While open: prep until warming is timed, details, then ready to complete
+	StartedBy       *int64                          `json:"started_by,omitempty"`
+	StartedAt       time.Time                       `json:"started_at"`
+	Warming         bool                            `json:"warming"` // The warm-up timer is running
+	WarmingSeconds  int64                           `json:"warming_seconds"`
+	Details         *InjectionSessionDetailsRequest `json:"details,omitempty"`
+	DetailsAt       *time.Time                      `json:"details_at,omitempty"`
+	InjectionID     *int64                          `json:"injection_id,omitempty"`
+	ClosedAt        *time.Time                      `json:"closed_at,omitempty"`
+	DurationSeconds *int64                          `json:"duration_seconds,omitempty"`
+}
+
+// CompletedInjectionSessionResponse is a completed session with the
+// injection it wrote
+type CompletedInjectionSessionResponse struct {
+	Session   InjectionSessionResponse `json:"session"`
+	Injection *models.Injection        `json:"injection"`
+}
+
+func toInjectionSessionResponse(s *models.InjectionSession, now time.Time) InjectionSessionResponse {
+	resp := InjectionSessionResponse{
+		ID:             s.ID,
+		CourseID:       s.CourseID,
+		Status:         s.Status,
+		Step:           s.Status,
+		StartedAt:      s.StartedAt,
+		Warming:        s.WarmingStartedAt.Valid,
+		WarmingSeconds: int64(s.WarmingTime(now) / time.Second),
+	}
+	if s.Status == models.InjectionSessionOpen {
+		switch {
+		case s.Details.Valid:
+			resp.Step = "ready"
+		case s.WarmingStartedAt.Valid || s.WarmingSeconds == 0:
+			resp.Step = "prep"
+		default:
+			resp.Step = "details"
+		}
+	}
+	if s.StartedBy.Valid {
+		resp.StartedBy = &s.StartedBy.Int64
+	}
+	if s.Details.Valid {
+		var details InjectionSessionDetailsRequest
+		if json.Unmarshal([]byte(s.Details.String), &details) == nil {
+			resp.Details = &details
+		}
+	}
+	if s.DetailsAt.Valid {
+		resp.DetailsAt = &s.DetailsAt.Time
+	}
+	if s.InjectionID.Valid {
+		resp.InjectionID = &s.InjectionID.Int64
+	}
+	if s.ClosedAt.Valid {
+		resp.ClosedAt = &s.ClosedAt.Time
+	}
+	if s.DurationSeconds.Valid {
+		resp.DurationSeconds = &s.DurationSeconds.Int64
+	}
+	return resp
+}
+
+// injectionSessionFromRequest loads the session named in the URL, writing
+// the error response and returning nil if it can't
+func injectionSessionFromRequest(w http.ResponseWriter, r *http.Request, db *database.DB, accountID int64) *models.InjectionSession {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respond.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return nil
+	}
+	session, err := repository.NewInjectionSessionRepository(db).GetByID(id, accountID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respond.Error(w, "Injection session not found", http.StatusNotFound)
+			return nil
+		}
+		respond.Error(w, "Failed to retrieve injection session", http.StatusInternalServerError)
+		return nil
+	}
+	return session
+}
+
+// respondInjectionSessionError writes the response for an error from
+// recording a step of, completing or cancelling a session
+func respondInjectionSessionError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, repository.ErrInjectionSessionClosed):
+		respond.Error(w, "Injection session is no longer open", http.StatusConflict)
+	case errors.Is(err, repository.ErrNotFound):
+		respond.Error(w, "Injection session not found", http.StatusNotFound)
+	default:
+		respond.Error(w, "Failed to "+action, http.StatusInternalServerError)
+	}
+}
+
+// HandleStartInjectionSession starts a guided injection on one of the
+// account's courses
+func HandleStartInjectionSession(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req StartInjectionSessionRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		session := &models.InjectionSession{
+			AccountID: accountID,
+			CourseID:  req.CourseID,
+			StartedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if err := repository.NewInjectionSessionRepository(db).Start(session); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				respond.Validation(w, "course_id not found", respond.Field("course_id", "not found"))
+			case errors.Is(err, repository.ErrInjectionSessionInProgress):
+				respond.Error(w, "An injection session is already in progress", http.StatusConflict)
+			default:
+				respond.Error(w, "Failed to start injection session", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, toInjectionSessionResponse(session, time.Now()))
+	}
+}
+
+// HandleGetCurrentInjectionSession returns the user's open session, so an
+// interrupted guided injection can be picked up again
+func HandleGetCurrentInjectionSession(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		session, err := repository.NewInjectionSessionRepository(db).GetOpen(accountID, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				respond.Error(w, "No injection session in progress", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve injection session", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, toInjectionSessionResponse(session, time.Now()))
+	}
+}
+
+// HandleGetInjectionSession returns a session
+func HandleGetInjectionSession(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		session := injectionSessionFromRequest(w, r, db, accountID)
+		if session == nil {
+			return
+		}
+		respondJSON(w, http.StatusOK, toInjectionSessionResponse(session, time.Now()))
+	}
+}
+
+// HandleInjectionSessionWarming starts or stops a session's warm-up timer.
+// Warming can be timed in as many stretches as it takes.
+func HandleInjectionSessionWarming(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req InjectionSessionWarmingRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		session := injectionSessionFromRequest(w, r, db, accountID)
+		if session == nil {
+			return
+		}
+		repo := repository.NewInjectionSessionRepository(db)
+		var err error
+		if req.Action == "start" {
+			err = repo.StartWarming(session)
+		} else {
+			err = repo.StopWarming(session)
+		}
+		if err != nil {
+			respondInjectionSessionError(w, err, "update warm-up timer")
+			return
+		}
+		respondJSON(w, http.StatusOK, toInjectionSessionResponse(session, time.Now()))
+	}
+}
+
+// HandleInjectionSessionDetails records the injection's details, checked
+// as they would be when logging an injection directly. Sending them again
+// replaces them.
+func HandleInjectionSessionDetails(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req InjectionSessionDetailsRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if !validInjectionDetails(w, db, accountID, userID, req.injectionDetails()) {
+			return
+		}
+		if req.Timestamp != nil {
+			if _, err := time.Parse(time.RFC3339, *req.Timestamp); err != nil {
+				respond.Validation(w, "invalid timestamp format, use RFC3339", respond.Field("timestamp", "invalid format, use RFC3339"))
+				return
+			}
+		}
+
+		session := injectionSessionFromRequest(w, r, db, accountID)
+		if session == nil {
+			return
+		}
+		details, err := json.Marshal(req)
+		if err != nil {
+			respond.Error(w, "Failed to save injection details", http.StatusInternalServerError)
+			return
+		}
+		if err := repository.NewInjectionSessionRepository(db).SaveDetails(session, string(details)); err != nil {
+			respondInjectionSessionError(w, err, "save injection details")
+			return
+		}
+		respondJSON(w, http.StatusOK, toInjectionSessionResponse(session, time.Now()))
+	}
+}
+
+// HandleCompleteInjectionSession writes the session's injection, taking
+// its dose out of inventory, and closes the session with its timings in
+// the same transaction. As when logging one directly, a likely duplicate
+// gets a 409 unless ?allow_duplicate=true.
+func HandleCompleteInjectionSession(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		session := injectionSessionFromRequest(w, r, db, accountID)
+		if session == nil {
+			return
+		}
+		if session.Status != models.InjectionSessionOpen {
+			respond.Error(w, "Injection session is no longer open", http.StatusConflict)
+			return
+		}
+		if !session.Details.Valid {
+			respond.Validation(w, "Record the injection's details before completing the session", respond.Field("details", "is required"))
+			return
+		}
+		var details InjectionSessionDetailsRequest
+		if err := json.Unmarshal([]byte(session.Details.String), &details); err != nil {
+			respond.Error(w, "Failed to read injection details", http.StatusInternalServerError)
+			return
+		}
+
+		timestamp := session.DetailsAt.Time
+		if details.Timestamp != nil {
+			if t, err := time.Parse(time.RFC3339, *details.Timestamp); err == nil {
+				timestamp = t
+			}
+		}
+		if details.AdministeredBy == nil {
+			details.AdministeredBy = &userID
+		}
+		injection := &models.Injection{
+			CourseID:       session.CourseID,
+			AdministeredBy: nullInt64(details.AdministeredBy),
+			Timestamp:      timestamp,
+			Side:           details.Side,
+			SiteX:          nullFloat64(details.SiteX),
+			SiteY:          nullFloat64(details.SiteY),
+			PainLevel:      nullInt(details.PainLevel),
+			HasKnots:       details.HasKnots,
+			SiteReaction:   nullString(details.SiteReaction),
+			Notes:          nullString(details.Notes),
+			DoseML:         nullFloat64(details.DoseML),
+			AttachmentID:   nullInt64(details.AttachmentID),
+		}
+		if details.DoseML == nil && !setScheduledDose(w, db, accountID, userID, injection) {
+			return
+		}
+		var duplicateWindow time.Duration
+		if r.URL.Query().Get("allow_duplicate") != "true" {
+			duplicateWindow = injectionDuplicateWindow(db, accountID)
+		}
+
+		usage, err := repository.NewInjectionSessionRepository(db).Complete(session, injection, userID, duplicateWindow)
+		if err != nil {
+			var duplicate *repository.DuplicateInjectionError
+			if errors.As(err, &duplicate) {
+				respondDuplicateInjection(w, db, accountID, userID, duplicate)
+				return
+			}
+			respondInjectionSessionError(w, err, "complete injection session")
+			return
+		}
+		injection, err = repository.NewInjectionRepository(db).GetByID(injection.ID, accountID)
+		if err != nil {
+			respond.Error(w, "Injection created but failed to retrieve", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"complete",
+			"injection_session",
+			sql.NullInt64{Int64: session.ID, Valid: true},
+			map[string]interface{}{
+				"injection_id":     injection.ID,
+				"warming_seconds":  session.WarmingSeconds,
+				"duration_seconds": session.DurationSeconds.Int64,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+		announceInjection(w, db, accountID, userID, injection, usage)
+
+		respondJSON(w, http.StatusCreated, CompletedInjectionSessionResponse{
+			Session:   toInjectionSessionResponse(session, time.Now()),
+			Injection: injection,
+		})
+	}
+}
+
+// HandleCancelInjectionSession abandons a session without logging an
+// injection
+func HandleCancelInjectionSession(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		session := injectionSessionFromRequest(w, r, db, accountID)
+		if session == nil {
+			return
+		}
+		if err := repository.NewInjectionSessionRepository(db).Cancel(session); err != nil {
+			respondInjectionSessionError(w, err, "cancel injection session")
+			return
+		}
+		respondJSON(w, http.StatusOK, toInjectionSessionResponse(session, time.Now()))
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+)
+
+// HandleCheckIntegrity reports integrity problems without changing anything
+func HandleCheckIntegrity(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		report, err := db.CheckIntegrity(false)
+		if err != nil {
+			respond.Error(w, "Failed to check database integrity", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, report)
+	}
+}
+
+// HandleRepairIntegrity fixes orphaned rows and inventory history mismatches
+// in one transaction and returns what it found. A database that fails the
+// engine's own check is left alone.
+func HandleRepairIntegrity(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+
+		report, err := db.CheckIntegrity(true)
+		if errors.Is(err, database.ErrCorrupt) {
+			respond.Error(w, "The database is corrupt and must be restored from a backup", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			respond.Error(w, "Failed to repair database", http.StatusInternalServerError)
+			return
+		}
+
+		orphans := 0
+		for _, o := range report.Orphans {
+			orphans += o.Count
+		}
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"repair_integrity",
+			"database",
+			sql.NullInt64{},
+			map[string]interface{}{
+				"orphaned_rows":        orphans,
+				"inventory_mismatches": len(report.Inventory),
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, report)
+	}
+}
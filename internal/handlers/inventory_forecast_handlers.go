@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// InventoryForecastResponse is the projected supply runway for an account
+type InventoryForecastResponse struct {
+	GeneratedAt      time.Time                 `json:"generated_at"`
+	CourseID         *int64                    `json:"course_id,omitempty"`
+	InjectionsPerDay float64                   `json:"injections_per_day"`
+	LookbackDays     int                       `json:"lookback_days"`
+	LeadTimeDays     int                       `json:"lead_time_days"`
+	Items            []services.SupplyForecast `json:"items"`
+}
+
+// parseDaysParam reads an optional whole-day query parameter within [lo, hi]
+func parseDaysParam(r *http.Request, name string, def, lo, hi int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days < lo || days > hi {
+		return 0, false
+	}
+	return days, true
+}
+
+// HandleGetInventoryForecast projects days of supply, run-out and reorder
+// dates for each item. Optional query parameters: lead_time_days (0-90) and
+// lookback_days (7-180).
+func HandleGetInventoryForecast(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		leadTime, ok := parseDaysParam(r, "lead_time_days", services.DefaultReorderLeadTimeDays, 0, 90)
+		if !ok {
+			respond.Validation(w, "lead_time_days must be between 0 and 90", respond.Field("lead_time_days", "must be between 0 and 90"))
+			return
+		}
+		lookback, ok := parseDaysParam(r, "lookback_days", services.DefaultForecastLookbackDays, 7, 180)
+		if !ok {
+			respond.Validation(w, "lookback_days must be between 7 and 180", respond.Field("lookback_days", "must be between 7 and 180"))
+			return
+		}
+
+		now := time.Now()
+		since := now.AddDate(0, 0, -lookback)
+		response := InventoryForecastResponse{
+			GeneratedAt:  now,
+			LookbackDays: lookback,
+			LeadTimeDays: leadTime,
+		}
+
+		itemTypes, err := repository.NewInventoryItemTypeRepository(db).List(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to load item types", http.StatusInternalServerError)
+			return
+		}
+		stock, err := repository.NewInventoryRepository(db).List(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to load inventory", http.StatusInternalServerError)
+			return
+		}
+
+		// What each injection on the active course uses, and how often it happens
+		perInjection := map[string]float64{}
+		course, err := repository.NewCourseRepository(db).GetActiveCourse(accountID)
+		if err == nil && course != nil {
+			courseID := course.ID
+			response.CourseID = &courseID
+
+			medication, err := repository.GetCourseMedication(db, course.ID, accountID)
+			if err != nil {
+				respond.Error(w, "Failed to look up course medication", http.StatusInternalServerError)
+				return
+			}
+			for _, t := range itemTypes {
+				if t.ItemType != medication.ItemType {
+					perInjection[t.ItemType] = t.DecrementPerInjection
+				}
+			}
+			perInjection[medication.ItemType] = medication.DoseML
+
+			windowStart := since
+			if course.StartDate.After(windowStart) {
+				windowStart = course.StartDate
+			}
+			var count int
+			if err := db.QueryRow(`
+				SELECT COUNT(*) FROM injections WHERE course_id = ? AND timestamp >= ? AND voided_at IS NULL
+			`, course.ID, windowStart).Scan(&count); err != nil {
+				respond.Error(w, "Failed to count recent injections", http.StatusInternalServerError)
+				return
+			}
+			if count > 0 {
+				days := max(now.Sub(windowStart).Hours()/24, 1)
+				response.InjectionsPerDay = math.Round(float64(count)/days*1000) / 1000
+			}
+		}
+
+		// What the doses of active medications linked to an item take from it a day
+		perDay := map[string]float64{}
+		medications, err := repository.NewMedicationRepository(db).ListActive(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to load medications", http.StatusInternalServerError)
+			return
+		}
+		loc := userLocation(db, userID)
+		for _, med := range medications {
+			if !med.InventoryItemType.Valid {
+				continue
+			}
+			schedule := services.NewMedicationSchedule(med, loc)
+			if !schedule.End.IsZero() && schedule.End.AddDate(0, 0, 1).Before(now) {
+				continue
+			}
+			perDay[med.InventoryItemType.String] += schedule.DosesPerDay() * repository.MedicationDoseUnits(med)
+		}
+
+		// Net use over the window: injection and medication decrements less
+		// rollbacks, plus losses
+		recentUsage := map[string]float64{}
+		rows, err := db.Query(`
+			SELECT item_type, -SUM(change_amount)
+			FROM inventory_history
+			WHERE account_id = ? AND timestamp >= ?
+			  AND (reference_type IN ('injection', 'medication_log') OR reason IN ('expired', 'damaged'))
+			GROUP BY item_type
+		`, accountID, since)
+		if err != nil {
+			respond.Error(w, "Failed to load inventory history", http.StatusInternalServerError)
+			return
+		}
+		for rows.Next() {
+			var itemType string
+			var used float64
+			if err := rows.Scan(&itemType, &used); err != nil {
+				rows.Close()
+				respond.Error(w, "Failed to scan inventory history", http.StatusInternalServerError)
+				return
+			}
+			recentUsage[itemType] = used
+		}
+		rows.Close()
+
+		quantities := make(map[string]float64, len(stock))
+		for _, item := range stock {
+			quantities[item.ItemType] = item.Quantity
+		}
+
+		// Every defined type, in display order, then any stock without a type
+		supply := make([]services.SupplyItem, 0, len(itemTypes))
+		seen := map[string]bool{}
+		for _, t := range itemTypes {
+			seen[t.ItemType] = true
+			supply = append(supply, services.SupplyItem{
+				ItemType:     t.ItemType,
+				Name:         t.Name,
+				Unit:         t.Unit,
+				Quantity:     quantities[t.ItemType],
+				PerInjection: perInjection[t.ItemType],
+				PerDay:       perDay[t.ItemType],
+				RecentUsage:  recentUsage[t.ItemType],
+			})
+		}
+		for _, item := range stock {
+			if seen[item.ItemType] {
+				continue
+			}
+			supply = append(supply, services.SupplyItem{
+				ItemType:     item.ItemType,
+				Name:         formatItemTypeName(item.ItemType),
+				Unit:         item.Unit,
+				Quantity:     item.Quantity,
+				PerInjection: perInjection[item.ItemType],
+				PerDay:       perDay[item.ItemType],
+				RecentUsage:  recentUsage[item.ItemType],
+			})
+		}
+
+		response.Items = services.ForecastSupply(supply, services.SupplyForecastParams{
+			InjectionsPerDay: response.InjectionsPerDay,
+			LookbackDays:     lookback,
+			LeadTimeDays:     leadTime,
+			Now:              now,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			middleware.Log(r.Context()).Error("Failed to encode inventory forecast", "err", err)
+		}
+	}
+}
+
+// reserveStock works out what the injections scheduled over the next days
+// will take of each item, counting from the start of today in loc so one due
+// earlier today and not yet logged still counts. Items are listed in display
+// order; nothing is reserved without an active course.
+func reserveStock(db *database.DB, accountID int64, loc *time.Location, now time.Time, days int) ([]services.StockReservation, error) {
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	plan, err := planInjections(db, accountID, loc, today, today.AddDate(0, 0, days), days*24)
+	if err != nil || plan == nil {
+		return []services.StockReservation{}, err
+	}
+
+	itemTypes, err := repository.NewInventoryItemTypeRepository(db).List(accountID)
+	if err != nil {
+		return nil, err
+	}
+	stock, err := repository.NewInventoryRepository(db).List(accountID)
+	if err != nil {
+		return nil, err
+	}
+	byType := make(map[string]*models.InventoryItem, len(stock))
+	for _, item := range stock {
+		byType[item.ItemType] = item
+	}
+
+	items := make([]services.ReservableItem, 0, len(itemTypes))
+	for _, t := range itemTypes {
+		item := services.ReservableItem{
+			ItemType:     t.ItemType,
+			Name:         t.Name,
+			Unit:         t.Unit,
+			PerInjection: t.DecrementPerInjection,
+		}
+		if s, ok := byType[t.ItemType]; ok {
+			item.Quantity = s.Quantity
+			if s.LowStockThreshold.Valid {
+				item.LowStockThreshold = &s.LowStockThreshold.Float64
+			}
+		}
+		items = append(items, item)
+	}
+	doses := make([]services.ScheduledDose, len(plan.Doses))
+	for i, d := range plan.Doses {
+		doses[i] = d.ScheduledDose
+	}
+	return services.ReserveStock(items, doses), nil
+}
@@ -3,15 +3,21 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
 	"injection-tracker/internal/middleware"
 	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+	"injection-tracker/internal/web"
 
 	"github.com/go-chi/chi/v5"
 	"golang.org/x/text/cases"
@@ -35,11 +41,11 @@ type InventoryItemResponse struct {
 
 // UpdateInventoryRequest represents the request to update an inventory item
 type UpdateInventoryRequest struct {
-	Quantity          *float64   `json:"quantity,omitempty"`
+	Quantity          *float64   `json:"quantity,omitempty" validate:"min=0"`
 	ExpirationDate    *time.Time `json:"expiration_date,omitempty"`
-	LotNumber         *string    `json:"lot_number,omitempty"`
-	LowStockThreshold *float64   `json:"low_stock_threshold,omitempty"`
-	Notes             *string    `json:"notes,omitempty"`
+	LotNumber         *string    `json:"lot_number,omitempty" validate:"max=100"`
+	LowStockThreshold *float64   `json:"low_stock_threshold,omitempty" validate:"min=0"`
+	Notes             *string    `json:"notes,omitempty" validate:"multiline,max=2000"`
 }
 
 // FlexibleDate is a custom type that can unmarshal various date formats
@@ -74,14 +80,19 @@ func (fd *FlexibleDate) UnmarshalJSON(b []byte) error {
 	return fmt.Errorf("unable to parse date: %s", s)
 }
 
-// AdjustInventoryRequest represents a manual inventory adjustment
+// AdjustInventoryRequest represents a manual inventory adjustment.
+// ChangeAmount is in the item's usage unit unless Unit names its purchase
+// unit, in which case it is converted using the item type's units per
+// purchase or UnitsPerPurchase for this entry.
 type AdjustInventoryRequest struct {
-	ChangeAmount      float64       `json:"change_amount"`
-	Reason            string        `json:"reason"`
-	Notes             *string       `json:"notes,omitempty"`
+	ChangeAmount      float64       `json:"change_amount" validate:"required"`
+	Unit              *string       `json:"unit,omitempty" validate:"max=20"`
+	UnitsPerPurchase  *float64      `json:"units_per_purchase,omitempty"`
+	Reason            string        `json:"reason" validate:"required,oneof=restock manual_adjustment correction expired damaged initial_setup"`
+	Notes             *string       `json:"notes,omitempty" validate:"multiline,max=2000"`
 	ExpirationDate    *FlexibleDate `json:"expiration_date,omitempty"`
-	LotNumber         *string       `json:"lot_number,omitempty"`
-	LowStockThreshold *float64      `json:"low_stock_threshold,omitempty"`
+	LotNumber         *string       `json:"lot_number,omitempty" validate:"max=100"`
+	LowStockThreshold *float64      `json:"low_stock_threshold,omitempty" validate:"min=0"`
 }
 
 // InventoryHistoryResponse represents an inventory history entry
@@ -97,6 +108,8 @@ type InventoryHistoryResponse struct {
 	PerformedBy    *int64    `json:"performed_by,omitempty"`
 	Timestamp      time.Time `json:"timestamp"`
 	Notes          *string   `json:"notes,omitempty"`
+	ProfileVersion *int64    `json:"profile_version,omitempty"`
+	ReversedBy     *int64    `json:"reversed_by,omitempty"`
 }
 
 // InventoryAlertResponse represents a low stock or expiration alert
@@ -106,25 +119,32 @@ type InventoryAlertResponse struct {
 	LowStockThreshold float64    `json:"low_stock_threshold,omitempty"`
 	Unit              string     `json:"unit"`
 	Severity          string     `json:"severity"`   // "warning", "critical"
-	AlertType         string     `json:"alert_type"` // "low_stock", "expiring", "expired"
+	AlertType         string     `json:"alert_type"` // "low_stock", "expiring", "expired", "vial_discard", "reserved_shortfall", "reserved_low_stock"
 	ExpirationDate    *time.Time `json:"expiration_date,omitempty"`
 	DaysUntilExpiry   *int       `json:"days_until_expiry,omitempty"`
+	VialID            *int64     `json:"vial_id,omitempty"`
+	Reserved          *float64   `json:"reserved,omitempty"`    // Set aside for scheduled injections
+	Uncommitted       *float64   `json:"uncommitted,omitempty"` // Quantity less Reserved
+	ShortAt           *time.Time `json:"short_at,omitempty"`    // When the first uncovered injection is due
+	StockAlertID      *int64     `json:"stock_alert_id,omitempty"`
+	Acknowledged      bool       `json:"acknowledged,omitempty"` // The stock alert has been acknowledged
 	Message           string     `json:"message"`
 }
 
 // HandleGetInventory returns all inventory items with current quantities
 func HandleGetInventory(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// Get user's account ID
 		accountID, err := getUserAccountID(db, userID)
 		if err != nil {
-			http.Error(w, "Failed to get account ID", http.StatusInternalServerError)
+			respond.Error(w, "Failed to get account ID", http.StatusInternalServerError)
 			return
 		}
 
@@ -137,7 +157,7 @@ func HandleGetInventory(db *database.DB) http.HandlerFunc {
 			ORDER BY item_type
 		`, accountID)
 		if err != nil {
-			http.Error(w, "Failed to query inventory", http.StatusInternalServerError)
+			respond.Error(w, "Failed to query inventory", http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
@@ -159,7 +179,7 @@ func HandleGetInventory(db *database.DB) http.HandlerFunc {
 				&item.UpdatedAt,
 			)
 			if err != nil {
-				http.Error(w, "Failed to scan inventory item", http.StatusInternalServerError)
+				respond.Error(w, "Failed to scan inventory item", http.StatusInternalServerError)
 				return
 			}
 
@@ -169,13 +189,13 @@ func HandleGetInventory(db *database.DB) http.HandlerFunc {
 		}
 
 		if err := rows.Err(); err != nil {
-			http.Error(w, "Error iterating inventory items", http.StatusInternalServerError)
+			respond.Error(w, "Error iterating inventory items", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(items); err != nil {
-			log.Printf("Failed to encode inventory items response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode inventory items response", "err", err)
 		}
 	}
 }
@@ -183,396 +203,333 @@ func HandleGetInventory(db *database.DB) http.HandlerFunc {
 // HandleUpdateInventory updates a specific inventory item
 func HandleUpdateInventory(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		itemType := chi.URLParam(r, "itemType")
-		if !isValidItemType(itemType) {
-			http.Error(w, "Invalid item type", http.StatusBadRequest)
+		if !isValidItemType(db, middleware.GetAccountID(r.Context()), itemType) {
+			respond.Error(w, "Invalid item type", http.StatusBadRequest)
 			return
 		}
 
 		// Parse request body
 		var req UpdateInventoryRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		// Validate quantity is non-negative if provided
-		if req.Quantity != nil && *req.Quantity < 0 {
-			http.Error(w, "Quantity cannot be negative", http.StatusBadRequest)
+		if req.Quantity == nil && req.ExpirationDate == nil && req.LotNumber == nil && req.LowStockThreshold == nil && req.Notes == nil {
+			respond.Error(w, "No fields to update", http.StatusBadRequest)
 			return
 		}
 
-		// Validate low stock threshold is non-negative if provided
-		if req.LowStockThreshold != nil && *req.LowStockThreshold < 0 {
-			http.Error(w, "Low stock threshold cannot be negative", http.StatusBadRequest)
+		accountID := middleware.GetAccountID(r.Context())
+		inventoryRepo := repository.NewInventoryRepository(db)
+		item, err := inventoryRepo.GetByType(itemType, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Inventory item not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to update inventory", http.StatusInternalServerError)
 			return
 		}
-
-		// Build update query dynamically
-		updates := []string{}
-		args := []interface{}{}
+		quantityBefore := item.Quantity
 
 		if req.Quantity != nil {
-			updates = append(updates, "quantity = ?")
-			args = append(args, *req.Quantity)
+			item.Quantity = *req.Quantity
 		}
 		if req.ExpirationDate != nil {
-			updates = append(updates, "expiration_date = ?")
-			args = append(args, *req.ExpirationDate)
+			item.ExpirationDate = sql.NullTime{Time: *req.ExpirationDate, Valid: true}
 		}
 		if req.LotNumber != nil {
-			updates = append(updates, "lot_number = ?")
-			args = append(args, *req.LotNumber)
+			item.LotNumber = nullString(req.LotNumber)
 		}
 		if req.LowStockThreshold != nil {
-			updates = append(updates, "low_stock_threshold = ?")
-			args = append(args, *req.LowStockThreshold)
+			item.LowStockThreshold = nullFloat64(req.LowStockThreshold)
 		}
 		if req.Notes != nil {
-			updates = append(updates, "notes = ?")
-			args = append(args, *req.Notes)
+			item.Notes = nullString(req.Notes)
 		}
 
-		if len(updates) == 0 {
-			http.Error(w, "No fields to update", http.StatusBadRequest)
+		if err := inventoryRepo.UpdateDetails(item, userID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Inventory item not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, fmt.Sprintf("Failed to update inventory: %v", err), http.StatusInternalServerError)
 			return
 		}
 
-		updates = append(updates, "updated_at = ?")
-		args = append(args, time.Now())
-		args = append(args, itemType)
-
-		query := "UPDATE inventory_items SET " + joinStrings(updates, ", ") + " WHERE item_type = ?"
-
-		result, err := db.Exec(query, args...)
+		// Return updated item
+		item, err = inventoryRepo.GetByType(itemType, accountID)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to update inventory: %v", err), http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve updated inventory item", http.StatusInternalServerError)
 			return
 		}
 
-		rowsAffected, err := result.RowsAffected()
-		if err != nil || rowsAffected == 0 {
-			http.Error(w, "Inventory item not found", http.StatusNotFound)
-			return
-		}
-
-		// Create audit log
-		_, _ = db.Exec(`
-			INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details, timestamp)
-			VALUES (?, ?, ?, ?, ?, ?)
-		`, userID, "update", "inventory", 0, fmt.Sprintf("Updated inventory for %s", itemType), time.Now())
-
-		// Return updated item
-		item, err := getInventoryItemByType(db, itemType)
-		if err != nil {
-			http.Error(w, "Failed to retrieve updated inventory item", http.StatusInternalServerError)
-			return
+		if quantityBefore != item.Quantity {
+			publishInventoryAdjusted(accountID, item, quantityBefore)
 		}
+		checkStockAlert(db, accountID, item)
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(inventoryItemToResponse(item)); err != nil {
-			log.Printf("Failed to encode inventory item response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode inventory item response", "err", err)
 		}
 	}
 }
 
-// HandleGetInventoryHistory returns the history for a specific item type
+// HandleGetInventoryHistory returns a page of the history for a specific item type
 func HandleGetInventoryHistory(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		itemType := chi.URLParam(r, "itemType")
-		if !isValidItemType(itemType) {
-			http.Error(w, "Invalid item type", http.StatusBadRequest)
+		db := db.WithContext(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Parse query parameters for pagination
-		limit := r.URL.Query().Get("limit")
-		if limit == "" {
-			limit = "50" // Default limit
+		itemType := chi.URLParam(r, "itemType")
+		if !isValidItemType(db, accountID, itemType) {
+			respond.Error(w, "Invalid item type", http.StatusBadRequest)
+			return
 		}
 
-		// Query history
-		rows, err := db.Query(`
-			SELECT id, item_type, change_amount, quantity_before, quantity_after,
-				reason, reference_id, reference_type, performed_by, timestamp, notes
-			FROM inventory_history
-			WHERE item_type = ?
-			ORDER BY timestamp DESC
-			LIMIT ?
-		`, itemType, limit)
+		page, err := parsePageRequest(r)
 		if err != nil {
-			http.Error(w, "Failed to query inventory history", http.StatusInternalServerError)
+			respond.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		defer rows.Close()
-
-		history := []InventoryHistoryResponse{}
-		for rows.Next() {
-			var h models.InventoryHistory
-			err := rows.Scan(
-				&h.ID,
-				&h.ItemType,
-				&h.ChangeAmount,
-				&h.QuantityBefore,
-				&h.QuantityAfter,
-				&h.Reason,
-				&h.ReferenceID,
-				&h.ReferenceType,
-				&h.PerformedBy,
-				&h.Timestamp,
-				&h.Notes,
-			)
-			if err != nil {
-				http.Error(w, "Failed to scan history entry", http.StatusInternalServerError)
-				return
-			}
-
-			response := InventoryHistoryResponse{
-				ID:             h.ID,
-				ItemType:       h.ItemType,
-				ChangeAmount:   h.ChangeAmount,
-				QuantityBefore: h.QuantityBefore,
-				QuantityAfter:  h.QuantityAfter,
-				Reason:         h.Reason,
-				Timestamp:      h.Timestamp,
-			}
-
-			if h.ReferenceID.Valid {
-				response.ReferenceID = &h.ReferenceID.Int64
-			}
-			if h.ReferenceType.Valid {
-				response.ReferenceType = &h.ReferenceType.String
-			}
-			if h.PerformedBy.Valid {
-				response.PerformedBy = &h.PerformedBy.Int64
-			}
-			if h.Notes.Valid {
-				response.Notes = &h.Notes.String
-			}
-
-			history = append(history, response)
-		}
 
-		if err := rows.Err(); err != nil {
-			http.Error(w, "Error iterating history entries", http.StatusInternalServerError)
+		result, err := repository.NewInventoryRepository(db).HistoryPage(itemType, accountID, page)
+		if err != nil {
+			listPageError(w, err, "Failed to query inventory history")
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(history); err != nil {
-			log.Printf("Failed to encode inventory history: %v", err)
-		}
+		respondJSON(w, http.StatusOK, newListResponse(result, inventoryHistoryToResponse))
 	}
 }
 
+// inventoryHistoryToResponse converts an inventory history entry to its API form
+func inventoryHistoryToResponse(h *models.InventoryHistory) InventoryHistoryResponse {
+	response := InventoryHistoryResponse{
+		ID:             h.ID,
+		ItemType:       h.ItemType,
+		ChangeAmount:   h.ChangeAmount,
+		QuantityBefore: h.QuantityBefore,
+		QuantityAfter:  h.QuantityAfter,
+		Reason:         h.Reason,
+		Timestamp:      h.Timestamp,
+	}
+
+	if h.ReferenceID.Valid {
+		response.ReferenceID = &h.ReferenceID.Int64
+	}
+	if h.ReferenceType.Valid {
+		response.ReferenceType = &h.ReferenceType.String
+	}
+	if h.PerformedBy.Valid {
+		response.PerformedBy = &h.PerformedBy.Int64
+	}
+	if h.Notes.Valid {
+		response.Notes = &h.Notes.String
+	}
+	if h.ProfileVersion.Valid {
+		response.ProfileVersion = &h.ProfileVersion.Int64
+	}
+	if h.ReversedBy.Valid {
+		response.ReversedBy = &h.ReversedBy.Int64
+	}
+	return response
+}
+
 // HandleAdjustInventory performs a manual inventory adjustment with reason
 func HandleAdjustInventory(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
+		accountID := middleware.GetAccountID(r.Context())
 		itemType := chi.URLParam(r, "itemType")
-		if !isValidItemType(itemType) {
-			http.Error(w, "Invalid item type", http.StatusBadRequest)
+		itemTypeDef := lookupInventoryItemType(db, accountID, itemType)
+		if itemTypeDef == nil {
+			respond.Error(w, "Invalid item type", http.StatusBadRequest)
 			return
 		}
 
 		// Parse request body
 		var req AdjustInventoryRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		// Validate required fields
-		if req.ChangeAmount == 0 {
-			http.Error(w, "change_amount is required and cannot be zero", http.StatusBadRequest)
-			return
-		}
-		if req.Reason == "" {
-			http.Error(w, "reason is required", http.StatusBadRequest)
-			return
+		change := req.ChangeAmount
+		notes := nullString(req.Notes)
+		if req.Unit != nil {
+			conv := itemUnitConversion(itemTypeDef)
+			if req.UnitsPerPurchase != nil {
+				if *req.UnitsPerPurchase <= 0 {
+					respond.Validation(w, "units_per_purchase must be greater than 0", respond.Field("units_per_purchase", "must be greater than 0"))
+					return
+				}
+				conv.PerPurchase = *req.UnitsPerPurchase
+			}
+			unit, _ := services.NormalizeUnit(*req.Unit)
+			var err error
+			change, err = conv.ToUsage(req.ChangeAmount, *req.Unit)
+			if err != nil {
+				msg := fmt.Sprintf("unit must be %s or %s", itemTypeDef.Unit, services.PurchaseUnitFor(itemTypeDef.Unit))
+				if errors.Is(err, services.ErrNoPackSize) {
+					msg = "units_per_purchase is required; the item type has no " + unit + " size"
+				}
+				respond.Validation(w, msg, respond.Field("unit", msg))
+				return
+			}
+			if unit != itemTypeDef.Unit {
+				// Keep what was entered, since history only records the
+				// converted amount
+				entered := fmt.Sprintf("%g × %g %s %s", req.ChangeAmount, conv.PerPurchase, itemTypeDef.Unit, unit)
+				if notes.Valid {
+					entered = notes.String + " (" + entered + ")"
+				}
+				notes = sql.NullString{String: entered, Valid: true}
+			}
 		}
 
-		// Valid reasons for manual adjustment
-		validReasons := map[string]bool{
-			"restock":           true,
-			"manual_adjustment": true,
-			"correction":        true,
-			"expired":           true,
-			"damaged":           true,
-			"initial_setup":     true,
+		adjustment := repository.InventoryAdjustment{
+			Change:            change,
+			Reason:            req.Reason,
+			Notes:             notes,
+			LotNumber:         nullString(req.LotNumber),
+			LowStockThreshold: nullFloat64(req.LowStockThreshold),
 		}
-		if !validReasons[req.Reason] {
-			http.Error(w, "Invalid reason. Must be one of: restock, manual_adjustment, correction, expired, damaged, initial_setup", http.StatusBadRequest)
-			return
+		if req.ExpirationDate != nil {
+			adjustment.ExpirationDate = sql.NullTime{Time: req.ExpirationDate.Time, Valid: true}
 		}
 
-		// Begin transaction
-		tx, err := db.BeginTx()
+		inventoryRepo := repository.NewInventoryRepository(db)
+		quantityBefore, err := inventoryRepo.ApplyAdjustment(itemTypeDef, accountID, userID, adjustment)
 		if err != nil {
-			http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
-			return
-		}
-		defer func() { _ = tx.Rollback() }()
-
-		// Get current quantity (or create item if doesn't exist)
-		var currentQty float64
-		var unit string
-		err = tx.QueryRow(`SELECT quantity, unit FROM inventory_items WHERE item_type = ?`, itemType).Scan(&currentQty, &unit)
-
-		if err == sql.ErrNoRows {
-			// Item doesn't exist - create it with default unit and optional fields
-			unit = getDefaultUnit(itemType)
-			now := time.Now()
-
-			insertQuery := `INSERT INTO inventory_items (item_type, quantity, unit`
-			valuePlaceholders := `VALUES (?, ?, ?`
-			insertValues := []interface{}{itemType, 0, unit}
-
-			if req.ExpirationDate != nil {
-				insertQuery += `, expiration_date`
-				valuePlaceholders += `, ?`
-				insertValues = append(insertValues, req.ExpirationDate.Time)
-			}
-			if req.LotNumber != nil {
-				insertQuery += `, lot_number`
-				valuePlaceholders += `, ?`
-				insertValues = append(insertValues, *req.LotNumber)
-			}
-			if req.LowStockThreshold != nil {
-				insertQuery += `, low_stock_threshold`
-				valuePlaceholders += `, ?`
-				insertValues = append(insertValues, *req.LowStockThreshold)
-			}
-
-			insertQuery += `, created_at, updated_at) `
-			valuePlaceholders += `, ?, ?)`
-			insertValues = append(insertValues, now, now)
-
-			_, err = tx.Exec(insertQuery+valuePlaceholders, insertValues...)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to create inventory item: %v", err), http.StatusInternalServerError)
+			if errors.Is(err, repository.ErrNegativeStock) {
+				respond.Error(w, "Cannot adjust: "+err.Error(), http.StatusBadRequest)
 				return
 			}
-			currentQty = 0
-		} else if err != nil {
-			http.Error(w, "Failed to get current inventory", http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to adjust inventory: %v", err), http.StatusInternalServerError)
 			return
 		}
 
-		// Calculate new quantity
-		newQty := currentQty + req.ChangeAmount
-
-		// Validate new quantity is non-negative
-		if newQty < 0 {
-			http.Error(w, fmt.Sprintf("Cannot adjust: would result in negative quantity (%.2f)", newQty), http.StatusBadRequest)
+		// Return updated item
+		item, err := inventoryRepo.GetByType(itemType, accountID)
+		if err != nil {
+			respond.Error(w, "Adjustment successful but failed to retrieve updated item", http.StatusInternalServerError)
 			return
 		}
 
-		// Update inventory (including optional expiration_date and lot_number)
-		updateQuery := `UPDATE inventory_items SET quantity = ?, updated_at = ?`
-		updateArgs := []interface{}{newQty, time.Now()}
+		emitLowStockIfCrossed(db, accountID, item, quantityBefore)
+		publishInventoryAdjusted(accountID, item, quantityBefore)
 
-		if req.ExpirationDate != nil {
-			updateQuery += `, expiration_date = ?`
-			updateArgs = append(updateArgs, req.ExpirationDate.Time)
-		}
-		if req.LotNumber != nil {
-			updateQuery += `, lot_number = ?`
-			updateArgs = append(updateArgs, *req.LotNumber)
-		}
-		if req.LowStockThreshold != nil {
-			updateQuery += `, low_stock_threshold = ?`
-			updateArgs = append(updateArgs, *req.LowStockThreshold)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(inventoryItemToResponse(item)); err != nil {
+			middleware.Log(r.Context()).Error("Failed to encode inventory item", "err", err)
 		}
+	}
+}
 
-		updateQuery += ` WHERE item_type = ?`
-		updateArgs = append(updateArgs, itemType)
+// ReverseInventoryEntryRequest is why a history entry is being reversed;
+// without notes the reversal is noted with the entry it undoes
+type ReverseInventoryEntryRequest struct {
+	Notes *string `json:"notes,omitempty" validate:"multiline,max=1000"`
+}
 
-		_, err = tx.Exec(updateQuery, updateArgs...)
-		if err != nil {
-			http.Error(w, "Failed to update inventory", http.StatusInternalServerError)
-			return
-		}
-
-		// Log the adjustment
-		_, err = tx.Exec(`
-			INSERT INTO inventory_history (
-				item_type, change_amount, quantity_before, quantity_after,
-				reason, performed_by, timestamp, notes
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-		`,
-			itemType,
-			req.ChangeAmount,
-			currentQty,
-			newQty,
-			req.Reason,
-			userID,
-			time.Now(),
-			nullString(req.Notes),
-		)
-		if err != nil {
-			http.Error(w, "Failed to log inventory adjustment", http.StatusInternalServerError)
+// HandleReverseInventoryEntry undoes an inventory history entry made in
+// error with a new entry for the opposite change, and returns the reversal.
+// An entry can only be reversed once.
+func HandleReverseInventoryEntry(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Create audit log
-		_, _ = tx.Exec(`
-			INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details, timestamp)
-			VALUES (?, ?, ?, ?, ?, ?)
-		`,
-			userID,
-			"adjust",
-			"inventory",
-			0,
-			fmt.Sprintf("Adjusted %s inventory by %.2f (reason: %s)", itemType, req.ChangeAmount, req.Reason),
-			time.Now(),
-		)
-
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid history entry ID", http.StatusBadRequest)
 			return
 		}
 
-		// Return updated item
-		item, err := getInventoryItemByType(db, itemType)
+		var req ReverseInventoryEntryRequest
+		if r.ContentLength != 0 {
+			if !decodeRequest(w, r, &req) {
+				return
+			}
+		}
+		notes := optionalText(req.Notes)
+
+		inventoryRepo := repository.NewInventoryRepository(db)
+		reversal, err := inventoryRepo.ReverseEntry(id, accountID, userID, notes)
 		if err != nil {
-			http.Error(w, "Adjustment successful but failed to retrieve updated item", http.StatusInternalServerError)
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				respond.Error(w, "History entry not found", http.StatusNotFound)
+			case errors.Is(err, repository.ErrEntryReversed):
+				respond.Error(w, "History entry has already been reversed", http.StatusConflict)
+			case errors.Is(err, repository.ErrEntryNotReversible):
+				respond.Error(w, "History entry cannot be reversed", http.StatusConflict)
+			case errors.Is(err, repository.ErrNegativeStock):
+				respond.Error(w, "Cannot reverse: "+err.Error(), http.StatusBadRequest)
+			default:
+				respond.Error(w, "Failed to reverse history entry", http.StatusInternalServerError)
+			}
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(inventoryItemToResponse(item)); err != nil {
-			log.Printf("Failed to encode inventory item: %v", err)
+		if item, err := inventoryRepo.GetByType(reversal.ItemType, accountID); err == nil {
+			emitLowStockIfCrossed(db, accountID, item, reversal.QuantityBefore)
+			publishInventoryAdjusted(accountID, item, reversal.QuantityBefore)
 		}
+
+		respondJSON(w, http.StatusOK, inventoryHistoryToResponse(reversal))
 	}
 }
 
-// HandleGetInventoryAlerts returns items below low stock threshold or expiring soon
+// HandleGetInventoryAlerts returns items below low stock threshold or expiring soon.
+// Injections scheduled over the next reserve_days (default 14, 0 to turn off)
+// reserve stock, which raises alerts before the quantity on hand runs low.
 func HandleGetInventoryAlerts(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
+		p := i18n.FromContext(r.Context())
 
 		// Get user's account ID
 		accountID, err := getUserAccountID(db, userID)
 		if err != nil {
-			http.Error(w, "Failed to get account ID", http.StatusInternalServerError)
+			respond.Error(w, "Failed to get account ID", http.StatusInternalServerError)
+			return
+		}
+
+		reserveDays, ok := parseDaysParam(r, "reserve_days", services.DefaultReservationDays, 0, services.MaxReservationDays)
+		if !ok {
+			msg := fmt.Sprintf("must be between 0 and %d", services.MaxReservationDays)
+			respond.Validation(w, "reserve_days "+msg, respond.Field("reserve_days", msg))
 			return
 		}
 
@@ -593,7 +550,7 @@ func HandleGetInventoryAlerts(db *database.DB) http.HandlerFunc {
 				quantity ASC
 		`, accountID)
 		if err != nil {
-			http.Error(w, "Failed to query inventory alerts", http.StatusInternalServerError)
+			respond.Error(w, "Failed to query inventory alerts", http.StatusInternalServerError)
 			return
 		}
 		defer lowStockRows.Close()
@@ -608,7 +565,7 @@ func HandleGetInventoryAlerts(db *database.DB) http.HandlerFunc {
 				&alert.Unit,
 			)
 			if err != nil {
-				http.Error(w, "Failed to scan alert", http.StatusInternalServerError)
+				respond.Error(w, "Failed to scan alert", http.StatusInternalServerError)
 				return
 			}
 
@@ -619,12 +576,12 @@ func HandleGetInventoryAlerts(db *database.DB) http.HandlerFunc {
 				// Determine severity
 				if alert.Quantity <= alert.LowStockThreshold/2 {
 					alert.Severity = "critical"
-					alert.Message = fmt.Sprintf("%s is critically low (%.1f %s remaining)",
-						formatItemTypeName(alert.ItemType), alert.Quantity, alert.Unit)
+					alert.Message = p.T("%s is critically low (%.1f %s remaining)",
+						p.T(formatItemTypeName(alert.ItemType)), alert.Quantity, alert.Unit)
 				} else {
 					alert.Severity = "warning"
-					alert.Message = fmt.Sprintf("%s is running low (%.1f %s remaining)",
-						formatItemTypeName(alert.ItemType), alert.Quantity, alert.Unit)
+					alert.Message = p.T("%s is running low (%.1f %s remaining)",
+						p.T(formatItemTypeName(alert.ItemType)), alert.Quantity, alert.Unit)
 				}
 			}
 
@@ -632,21 +589,36 @@ func HandleGetInventoryAlerts(db *database.DB) http.HandlerFunc {
 		}
 
 		if err := lowStockRows.Err(); err != nil {
-			http.Error(w, "Error iterating low stock alerts", http.StatusInternalServerError)
+			respond.Error(w, "Error iterating low stock alerts", http.StatusInternalServerError)
 			return
 		}
 
+		// Low stock alerts carry the state of their delivered stock alert
+		stockAlerts, err := repository.NewStockAlertRepository(db).ListActive(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to query stock alerts", http.StatusInternalServerError)
+			return
+		}
+		for _, sa := range stockAlerts {
+			for i := range alerts {
+				if alerts[i].ItemType == sa.ItemType {
+					alerts[i].StockAlertID = &sa.ID
+					alerts[i].Acknowledged = sa.AcknowledgedAt.Valid
+				}
+			}
+		}
+
 		// Query 2: Expiring or expired items
 		expirationRows, err := db.Query(`
 			SELECT item_type, quantity, unit, expiration_date
 			FROM inventory_items
 			WHERE account_id = ?
 			  AND expiration_date IS NOT NULL
-			  AND expiration_date <= date('now', '+30 days')
+			  AND expiration_date <= ?
 			ORDER BY expiration_date ASC
-		`, accountID)
+		`, accountID, time.Now().UTC().AddDate(0, 0, 30))
 		if err != nil {
-			http.Error(w, "Failed to query expiration alerts", http.StatusInternalServerError)
+			respond.Error(w, "Failed to query expiration alerts", http.StatusInternalServerError)
 			return
 		}
 		defer expirationRows.Close()
@@ -662,7 +634,7 @@ func HandleGetInventoryAlerts(db *database.DB) http.HandlerFunc {
 				&expirationDate,
 			)
 			if err != nil {
-				http.Error(w, "Failed to scan expiration alert", http.StatusInternalServerError)
+				respond.Error(w, "Failed to scan expiration alert", http.StatusInternalServerError)
 				return
 			}
 
@@ -674,59 +646,150 @@ func HandleGetInventoryAlerts(db *database.DB) http.HandlerFunc {
 				// Expired
 				alert.AlertType = "expired"
 				alert.Severity = "critical"
-				alert.Message = fmt.Sprintf("%s expired on %s - please dispose and restock",
-					formatItemTypeName(alert.ItemType), expirationDate.Format("Jan 2, 2006"))
+				alert.Message = p.T("%s expired on %s - please dispose and restock",
+					p.T(formatItemTypeName(alert.ItemType)), p.Date(expirationDate))
 			} else if daysUntil <= 7 {
 				// Expiring within 7 days
 				alert.AlertType = "expiring"
 				alert.Severity = "critical"
-				alert.Message = fmt.Sprintf("%s expires in %d days (on %s)",
-					formatItemTypeName(alert.ItemType), daysUntil, expirationDate.Format("Jan 2, 2006"))
+				alert.Message = p.T("%s expires in %d days (on %s)",
+					p.T(formatItemTypeName(alert.ItemType)), daysUntil, p.Date(expirationDate))
 			} else {
 				// Expiring within 30 days
 				alert.AlertType = "expiring"
 				alert.Severity = "warning"
-				alert.Message = fmt.Sprintf("%s expires in %d days (on %s)",
-					formatItemTypeName(alert.ItemType), daysUntil, expirationDate.Format("Jan 2, 2006"))
+				alert.Message = p.T("%s expires in %d days (on %s)",
+					p.T(formatItemTypeName(alert.ItemType)), daysUntil, p.Date(expirationDate))
 			}
 
 			alerts = append(alerts, alert)
 		}
 
 		if err := expirationRows.Err(); err != nil {
-			http.Error(w, "Error iterating expiration alerts", http.StatusInternalServerError)
+			respond.Error(w, "Error iterating expiration alerts", http.StatusInternalServerError)
 			return
 		}
 
+		// Query 3: Open vials nearing or past their beyond-use date
+		vials, err := repository.NewInventoryVialRepository(db).ListDueForDiscard(accountID, now.Add(services.VialDiscardWarningHours*time.Hour))
+		if err != nil {
+			respond.Error(w, "Failed to query vial alerts", http.StatusInternalServerError)
+			return
+		}
+		for _, vial := range vials {
+			alert := InventoryAlertResponse{
+				ItemType:       vial.ItemType,
+				Quantity:       vial.RemainingML,
+				Unit:           "mL",
+				AlertType:      "vial_discard",
+				Severity:       "warning",
+				ExpirationDate: &vial.DiscardAt.Time,
+				VialID:         &vial.ID,
+			}
+			_, message, args := repository.VialDiscardNotificationContent(vial, now)
+			alert.Message = p.T(message, args...)
+			if !vial.DiscardAt.Time.After(now) {
+				alert.Severity = "critical"
+			}
+			alerts = append(alerts, alert)
+		}
+
+		// Query 4: Stock the scheduled injections have reserved
+		reservations := []services.StockReservation{}
+		loc := userLocation(db, userID)
+		if reserveDays > 0 {
+			reservations, err = reserveStock(db, accountID, loc, now, reserveDays)
+			if err != nil {
+				respond.Error(w, "Failed to reserve stock for scheduled injections", http.StatusInternalServerError)
+				return
+			}
+		}
+		for _, res := range reservations {
+			reserved, uncommitted := res.Reserved, res.Uncommitted
+			lowNow := false
+			for i := range alerts {
+				if alerts[i].ItemType == res.ItemType && alerts[i].AlertType == "low_stock" {
+					alerts[i].Reserved, alerts[i].Uncommitted = &reserved, &uncommitted
+					lowNow = true
+				}
+			}
+
+			alert := InventoryAlertResponse{
+				ItemType:    res.ItemType,
+				Quantity:    res.Quantity,
+				Unit:        res.Unit,
+				Reserved:    &reserved,
+				Uncommitted: &uncommitted,
+				ShortAt:     res.ShortAt,
+			}
+			if res.LowStockThreshold != nil {
+				alert.LowStockThreshold = *res.LowStockThreshold
+			}
+			switch {
+			case res.Shortfall():
+				alert.AlertType = "reserved_shortfall"
+				alert.Severity = "critical"
+				alert.Message = fmt.Sprintf("%s on hand (%s %s) won't cover the next %d scheduled injections (%s %s); it runs out by %s",
+					res.Name, formatDose(res.Quantity), res.Unit, res.Injections, formatDose(res.Reserved), res.Unit,
+					res.ShortAt.In(loc).Format("Jan 2"))
+			case res.BelowThreshold() && !lowNow:
+				alert.AlertType = "reserved_low_stock"
+				alert.Severity = "warning"
+				alert.Message = fmt.Sprintf("%s will be low once the next %d scheduled injections are given (%s %s uncommitted)",
+					res.Name, res.Injections, formatDose(res.Uncommitted), res.Unit)
+			default:
+				continue
+			}
+			alerts = append(alerts, alert)
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"alerts": alerts,
-			"count":  len(alerts),
+			"alerts":       alerts,
+			"count":        len(alerts),
+			"reserve_days": reserveDays,
+			"reservations": reservations,
 		}); err != nil {
-			log.Printf("Failed to encode alerts: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode alerts", "err", err)
 		}
 	}
 }
 
 // Helper functions
 
-func isValidItemType(itemType string) bool {
-	validTypes := map[string]bool{
-		"progesterone":     true,
-		"draw_needle":      true,
-		"injection_needle": true,
-		"syringe":          true,
-		"swab":             true,
-		"gauze":            true,
+// lookupInventoryItemType returns the account's definition of itemType, or
+// nil if the account doesn't track it
+func lookupInventoryItemType(db *database.DB, accountID int64, itemType string) *models.InventoryItemType {
+	if accountID == 0 || itemType == "" {
+		return nil
+	}
+	t, err := repository.NewInventoryItemTypeRepository(db).GetByItemType(itemType, accountID)
+	if err != nil {
+		return nil
 	}
-	return validTypes[itemType]
+	return t
 }
 
-func getDefaultUnit(itemType string) string {
-	if itemType == "progesterone" {
-		return "mL"
+// ensureInventoryItem returns the stock on hand for an item type, creating an
+// empty stock record with the type's unit and reorder threshold if needed
+func ensureInventoryItem(tx *sql.Tx, accountID int64, def *models.InventoryItemType) (float64, error) {
+	var quantity float64
+	err := tx.QueryRow(`SELECT quantity FROM inventory_items WHERE item_type = ? AND account_id = ?`, def.ItemType, accountID).Scan(&quantity)
+	if err != sql.ErrNoRows {
+		return quantity, err
 	}
-	return "count"
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO inventory_items (item_type, quantity, unit, low_stock_threshold, account_id, created_at, updated_at)
+		VALUES (?, 0, ?, ?, ?, ?, ?)
+	`, def.ItemType, def.Unit, def.ReorderThreshold, accountID, now, now)
+	return 0, err
+}
+
+// isValidItemType reports whether the account has defined itemType
+func isValidItemType(db *database.DB, accountID int64, itemType string) bool {
+	return lookupInventoryItemType(db, accountID, itemType) != nil
 }
 
 func formatItemTypeName(itemType string) string {
@@ -744,33 +807,14 @@ func formatItemTypeName(itemType string) string {
 	case "gauze":
 		return "Gauze Pads"
 	default:
-		return itemType
+		// Compound item types are slugs of the compound name
+		return cases.Title(language.English).String(strings.ReplaceAll(itemType, "_", " "))
 	}
 }
 
-func getInventoryItemByType(db *database.DB, itemType string) (*models.InventoryItem, error) {
-	var item models.InventoryItem
-	err := db.QueryRow(`
-		SELECT id, item_type, quantity, unit, expiration_date,
-			lot_number, low_stock_threshold, notes, created_at, updated_at
-		FROM inventory_items
-		WHERE item_type = ?
-	`, itemType).Scan(
-		&item.ID,
-		&item.ItemType,
-		&item.Quantity,
-		&item.Unit,
-		&item.ExpirationDate,
-		&item.LotNumber,
-		&item.LowStockThreshold,
-		&item.Notes,
-		&item.CreatedAt,
-		&item.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return &item, nil
+// getInventoryItemByType loads the account's stock of an item type
+func getInventoryItemByType(db *database.DB, accountID int64, itemType string) (*models.InventoryItem, error) {
+	return repository.NewInventoryRepository(db).GetByType(itemType, accountID)
 }
 
 func inventoryItemToResponse(item *models.InventoryItem) InventoryItemResponse {
@@ -806,7 +850,7 @@ func HandleUpdateInventorySettings(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -817,25 +861,30 @@ func HandleUpdateInventorySettings(db *database.DB) http.HandlerFunc {
 	}
 }
 
-// HandleGetRecentInventoryChanges returns recent inventory changes
+// HandleGetRecentInventoryChanges returns the account's recent inventory
+// changes
 func HandleGetRecentInventoryChanges(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
-		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
+		p := i18n.FromContext(r.Context())
+
 		// Get recent inventory changes
 		rows, err := db.Query(`
 			SELECT item_type, change_amount, reason, timestamp, notes
 			FROM inventory_history
+			WHERE account_id = ?
 			ORDER BY timestamp DESC
 			LIMIT 10
-		`)
+		`, accountID)
 		if err != nil {
-			w.Header().Set("Content-Type", "text/html")
-			_, _ = w.Write([]byte(`<p>Error loading inventory changes</p>`))
+			renderFragment(w, r, http.StatusOK, "empty.html", web.EmptyState{Message: "Error loading inventory changes"})
 			return
 		}
 		defer rows.Close()
@@ -857,12 +906,7 @@ func HandleGetRecentInventoryChanges(db *database.DB) http.HandlerFunc {
 		}
 
 		if len(changes) == 0 {
-			w.Header().Set("Content-Type", "text/html")
-			_, _ = w.Write([]byte(`
-				<div style="text-align: center; padding: 2rem; color: var(--pico-muted-color);">
-					<p>No recent changes.</p>
-				</div>
-			`))
+			renderFragment(w, r, http.StatusOK, "empty.html", web.EmptyState{Message: "No recent changes."})
 			return
 		}
 
@@ -876,101 +920,56 @@ func HandleGetRecentInventoryChanges(db *database.DB) http.HandlerFunc {
 			"gauze":            "Gauze Pads",
 		}
 
-		w.Header().Set("Content-Type", "text/html")
-		html := `<div style="display: flex; flex-direction: column; gap: 0.5rem;">`
-
+		type changeItem struct {
+			Item   string
+			Amount float64
+			Reason string
+			Notes  string
+			When   string
+		}
+		items := make([]changeItem, 0, len(changes))
 		for _, change := range changes {
 			itemName := displayNames[change.ItemType]
 			if itemName == "" {
-				itemName = change.ItemType
+				itemName = formatItemTypeName(change.ItemType)
 			}
-
-			sign := "+"
-			color := "var(--pico-ins-color)"
-			if change.ChangeAmount < 0 {
-				sign = ""
-				color = "var(--pico-del-color)"
-			}
-
-			html += `<article style="margin: 0; padding: 0.75rem;">`
-			html += `<div style="display: flex; justify-content: space-between; align-items: start;">`
-			html += `<div><strong>` + itemName + `</strong> `
-			html += `<span style="color: ` + color + `;">` + sign + fmt.Sprintf("%.1f", change.ChangeAmount) + `</span>`
-			html += `<br><small style="color: var(--pico-muted-color);">` + cases.Title(language.English).String(strings.ReplaceAll(change.Reason, "_", " ")) + `</small>`
-
-			if change.Notes.Valid && change.Notes.String != "" {
-				html += `<br><small>` + change.Notes.String + `</small>`
-			}
-
-			html += `</div>`
-			html += `<small style="color: var(--pico-muted-color); white-space: nowrap;">` + formatTimeAgo(change.Timestamp) + `</small>`
-			html += `</div></article>`
-		}
-
-		html += `</div>`
-		_, _ = w.Write([]byte(html))
+			items = append(items, changeItem{
+				Item:   itemName,
+				Amount: change.ChangeAmount,
+				Reason: cases.Title(language.English).String(strings.ReplaceAll(change.Reason, "_", " ")),
+				Notes:  change.Notes.String,
+				When:   formatTimeAgo(p, change.Timestamp),
+			})
+		}
+		renderFragment(w, r, http.StatusOK, "recent_inventory_changes.html", items)
 	}
 }
 
-// HandleGetAllInventoryHistory returns all inventory history (for /api/inventory/history)
+// HandleGetAllInventoryHistory returns a page of the account's inventory
+// history across all item types (for /api/inventory/history)
 func HandleGetAllInventoryHistory(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
-		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Get limit from query params (default 100)
-		limit := 100
-		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-			if parsedLimit, err := fmt.Sscanf(limitStr, "%d", &limit); err == nil && parsedLimit == 1 {
-				if limit > 1000 {
-					limit = 1000 // Cap at 1000
-				}
-			}
-		}
-
-		// Get all inventory changes
-		rows, err := db.Query(`
-			SELECT item_type, change_amount, reason, timestamp, notes
-			FROM inventory_history
-			ORDER BY timestamp DESC
-			LIMIT ?
-		`, limit)
+		page, err := parsePageRequest(r)
 		if err != nil {
-			http.Error(w, "Failed to retrieve inventory history", http.StatusInternalServerError)
+			respond.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		defer rows.Close()
 
-		type HistoryEntry struct {
-			ItemType     string  `json:"item_type"`
-			ChangeAmount float64 `json:"change_amount"`
-			Reason       string  `json:"reason"`
-			Timestamp    string  `json:"timestamp"`
-			Notes        *string `json:"notes,omitempty"`
-		}
-
-		history := []HistoryEntry{}
-		for rows.Next() {
-			var entry HistoryEntry
-			var notes sql.NullString
-			var timestamp time.Time
-
-			if err := rows.Scan(&entry.ItemType, &entry.ChangeAmount, &entry.Reason, &timestamp, &notes); err == nil {
-				entry.Timestamp = timestamp.Format(time.RFC3339)
-				if notes.Valid {
-					entry.Notes = &notes.String
-				}
-				history = append(history, entry)
-			}
+		result, err := repository.NewInventoryRepository(db).HistoryPage("", accountID, page)
+		if err != nil {
+			listPageError(w, err, "Failed to retrieve inventory history")
+			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(history); err != nil {
-			log.Printf("Failed to encode inventory history: %v", err)
-		}
+		respondJSON(w, http.StatusOK, newListResponse(result, inventoryHistoryToResponse))
 	}
 }
 
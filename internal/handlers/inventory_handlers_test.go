@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+func TestHandleReverseInventoryEntryOtherAccount(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'one', 'hash'), (2, 'two', 'hash');
+		INSERT INTO accounts (id) VALUES (1), (2);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner'), (2, 2, 'owner');
+		INSERT INTO inventory_items (item_type, quantity, unit, account_id) VALUES ('progesterone', 3, 'mL', 1);
+		INSERT INTO inventory_lots (account_id, item_type, quantity_received, quantity_remaining) VALUES (1, 'progesterone', 3, 3);
+		INSERT INTO courses (id, account_id, name, start_date, is_active, dose_ml) VALUES (1, 1, 'Progesterone', '2026-03-01 00:00:00', 1, 1);
+	`); err != nil {
+		t.Fatalf("Failed to seed accounts: %v", err)
+	}
+	injection := &models.Injection{CourseID: 1, Timestamp: time.Now(), Side: "left"}
+	if _, err := repository.NewInjectionRepository(db).Record(injection, 1, 1, 0); err != nil {
+		t.Fatalf("Failed to record injection: %v", err)
+	}
+	var entryID int64
+	if err := db.QueryRow(`SELECT id FROM inventory_history WHERE reference_type = 'injection' AND reference_id = ? AND item_type = 'progesterone'`,
+		injection.ID).Scan(&entryID); err != nil {
+		t.Fatalf("Failed to find the injection's entry: %v", err)
+	}
+
+	// The first account stops stocking the item and the other takes it up,
+	// leaving the first account's history behind
+	if _, err := db.Exec(`
+		UPDATE inventory_items SET account_id = 2 WHERE item_type = 'progesterone';
+		INSERT INTO inventory_lots (account_id, item_type, quantity_received, quantity_remaining) VALUES (2, 'progesterone', 2, 2);
+	`); err != nil {
+		t.Fatalf("Failed to move the item: %v", err)
+	}
+
+	reverse := func(userID, accountID int64) int {
+		router := chi.NewRouter()
+		router.Post("/inventory/history/{id}/reverse", HandleReverseInventoryEntry(db))
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/inventory/history/%d/reverse", entryID), nil)
+		userCtx := &middleware.UserContext{UserID: userID, AccountID: accountID, Role: "owner"}
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, userCtx))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	lots := func(accountID int64) float64 {
+		var remaining float64
+		if err := db.QueryRow(`SELECT SUM(quantity_remaining) FROM inventory_lots WHERE account_id = ?`, accountID).Scan(&remaining); err != nil {
+			t.Fatalf("Failed to get lots: %v", err)
+		}
+		return remaining
+	}
+
+	if code := reverse(2, 2); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for another account's entry, got %d", code)
+	}
+	var quantity float64
+	if err := db.QueryRow(`SELECT quantity FROM inventory_items WHERE item_type = 'progesterone'`).Scan(&quantity); err != nil || quantity != 2 {
+		t.Errorf("Expected the other account's stock untouched, got %v (%v)", quantity, err)
+	}
+	if lots(1) != 2 || lots(2) != 2 {
+		t.Errorf("Expected both accounts' lots untouched, got %v and %v", lots(1), lots(2))
+	}
+	var reversed bool
+	if err := db.QueryRow(`SELECT reversed_by IS NOT NULL FROM inventory_history WHERE id = ?`, entryID).Scan(&reversed); err != nil || reversed {
+		t.Errorf("Expected the entry not reversed, got %v (%v)", reversed, err)
+	}
+
+	if history, err := repository.NewInventoryRepository(db).GetAllHistory(2, 10, 0); err != nil || len(history) != 0 {
+		t.Errorf("Expected none of the first account's history, got %d (%v)", len(history), err)
+	}
+}
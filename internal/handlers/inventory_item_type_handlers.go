@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// InventoryItemTypeRequest is the payload for creating or updating an item
+// type. ItemType and Unit can only be set on creation. An empty
+// PurchaseUnit clears the purchase unit.
+type InventoryItemTypeRequest struct {
+	ItemType              *string  `json:"item_type,omitempty" validate:"max=100"` // Slugged to at most 50 characters
+	Name                  *string  `json:"name,omitempty" validate:"max=100"`
+	Unit                  *string  `json:"unit,omitempty" validate:"max=20"`
+	DecrementPerInjection *float64 `json:"decrement_per_injection,omitempty" validate:"min=0"`
+	ReorderThreshold      *float64 `json:"reorder_threshold,omitempty" validate:"min=0"`
+	PurchaseUnit          *string  `json:"purchase_unit,omitempty" validate:"max=20"` // vial for mL, box for count
+	UnitsPerPurchase      *float64 `json:"units_per_purchase,omitempty"`              // Vial size or pack size
+	SortOrder             *int     `json:"sort_order,omitempty"`
+}
+
+// InventoryItemTypeResponse is an item type as returned by the API
+type InventoryItemTypeResponse struct {
+	ItemType              string    `json:"item_type"`
+	Name                  string    `json:"name"`
+	Unit                  string    `json:"unit"`
+	DecrementPerInjection float64   `json:"decrement_per_injection"`
+	ReorderThreshold      *float64  `json:"reorder_threshold,omitempty"`
+	PurchaseUnit          *string   `json:"purchase_unit,omitempty"`
+	UnitsPerPurchase      *float64  `json:"units_per_purchase,omitempty"`
+	SortOrder             int       `json:"sort_order"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// maxItemTypeLength matches the inventory_items.item_type CHECK
+const maxItemTypeLength = 50
+
+func toInventoryItemTypeResponse(t *models.InventoryItemType) InventoryItemTypeResponse {
+	resp := InventoryItemTypeResponse{
+		ItemType:              t.ItemType,
+		Name:                  t.Name,
+		Unit:                  t.Unit,
+		DecrementPerInjection: t.DecrementPerInjection,
+		SortOrder:             t.SortOrder,
+		CreatedAt:             t.CreatedAt,
+		UpdatedAt:             t.UpdatedAt,
+	}
+	if t.ReorderThreshold.Valid {
+		resp.ReorderThreshold = &t.ReorderThreshold.Float64
+	}
+	if t.PurchaseUnit.Valid && t.UnitsPerPurchase.Valid {
+		resp.PurchaseUnit = &t.PurchaseUnit.String
+		resp.UnitsPerPurchase = &t.UnitsPerPurchase.Float64
+	}
+	return resp
+}
+
+// itemUnitConversion converts between an item type's usage unit and its
+// purchase unit, if it has one
+func itemUnitConversion(t *models.InventoryItemType) services.UnitConversion {
+	conv := services.UnitConversion{UsageUnit: t.Unit}
+	if t.PurchaseUnit.Valid && t.UnitsPerPurchase.Valid {
+		conv.PurchaseUnit = t.PurchaseUnit.String
+		conv.PerPurchase = t.UnitsPerPurchase.Float64
+	}
+	return conv
+}
+
+// itemTypeSlug turns a display name into an inventory item type key,
+// e.g. "Estradiol Valerate" becomes "estradiol_valerate"
+func itemTypeSlug(name string) string {
+	var b strings.Builder
+	pendingSep := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if pendingSep && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			pendingSep = false
+		} else {
+			pendingSep = true
+		}
+	}
+	s := b.String()
+	if len(s) > maxItemTypeLength {
+		s = strings.TrimRight(s[:maxItemTypeLength], "_")
+	}
+	return s
+}
+
+// applyInventoryItemTypeRequest validates and copies the mutable fields of req
+// onto t, returning a client-facing error message on failure
+func applyInventoryItemTypeRequest(t *models.InventoryItemType, req *InventoryItemTypeRequest) string {
+	if req.Name != nil {
+		if *req.Name == "" {
+			return "name is required"
+		}
+		t.Name = *req.Name
+	}
+	if req.DecrementPerInjection != nil {
+		t.DecrementPerInjection = *req.DecrementPerInjection
+	}
+	if req.ReorderThreshold != nil {
+		t.ReorderThreshold = sql.NullFloat64{Float64: *req.ReorderThreshold, Valid: true}
+	}
+	if req.PurchaseUnit != nil {
+		if *req.PurchaseUnit == "" {
+			t.PurchaseUnit = sql.NullString{}
+			t.UnitsPerPurchase = sql.NullFloat64{}
+		} else {
+			want := services.PurchaseUnitFor(t.Unit)
+			unit, err := services.NormalizeUnit(*req.PurchaseUnit)
+			if err != nil || unit != want {
+				return "purchase_unit must be '" + want + "' for " + t.Unit + " item types"
+			}
+			t.PurchaseUnit = sql.NullString{String: unit, Valid: true}
+		}
+	}
+	if req.UnitsPerPurchase != nil {
+		if *req.UnitsPerPurchase <= 0 {
+			return "units_per_purchase must be greater than 0"
+		}
+		t.UnitsPerPurchase = sql.NullFloat64{Float64: *req.UnitsPerPurchase, Valid: true}
+		if !t.PurchaseUnit.Valid {
+			t.PurchaseUnit = sql.NullString{String: services.PurchaseUnitFor(t.Unit), Valid: true}
+		}
+	}
+	if t.PurchaseUnit.Valid != t.UnitsPerPurchase.Valid {
+		return "purchase_unit and units_per_purchase must be set together"
+	}
+	if req.SortOrder != nil {
+		t.SortOrder = *req.SortOrder
+	}
+	return ""
+}
+
+// HandleGetInventoryItemTypes lists the account's item types, which together
+// with their per-injection decrements make up its consumption profile
+func HandleGetInventoryItemTypes(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		types, err := repository.NewInventoryItemTypeRepository(db).List(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve item types", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]InventoryItemTypeResponse, 0, len(types))
+		for _, t := range types {
+			resp = append(resp, toInventoryItemTypeResponse(t))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// HandleCreateInventoryItemType adds an item type to the account
+func HandleCreateInventoryItemType(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req InventoryItemTypeRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Name == nil {
+			respond.Validation(w, "name is required", respond.Field("name", "is required"))
+			return
+		}
+		if req.Unit == nil || (*req.Unit != "mL" && *req.Unit != "count") {
+			respond.Validation(w, "unit must be 'mL' or 'count'", respond.Field("unit", "must be 'mL' or 'count'"))
+			return
+		}
+
+		itemType := &models.InventoryItemType{
+			AccountID: accountID,
+			Unit:      *req.Unit,
+		}
+		if msg := applyInventoryItemTypeRequest(itemType, &req); msg != "" {
+			respond.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		itemType.ItemType = itemTypeSlug(itemType.Name)
+		if req.ItemType != nil && *req.ItemType != "" {
+			itemType.ItemType = itemTypeSlug(*req.ItemType)
+		}
+		if itemType.ItemType == "" {
+			respond.Validation(w, "item_type must contain letters or digits", respond.Field("item_type", "must contain letters or digits"))
+			return
+		}
+
+		itemTypeRepo := repository.NewInventoryItemTypeRepository(db)
+		if err := itemTypeRepo.Create(itemType); err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				respond.Error(w, "An item type with this key already exists", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to create item type", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"inventory_item_type",
+			sql.NullInt64{Int64: itemType.ID, Valid: true},
+			map[string]interface{}{
+				"item_type":               itemType.ItemType,
+				"unit":                    itemType.Unit,
+				"decrement_per_injection": itemType.DecrementPerInjection,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(toInventoryItemTypeResponse(itemType))
+	}
+}
+
+// HandleUpdateInventoryItemType updates an item type's name, per-injection
+// decrement, reorder threshold, purchase unit or sort order
+func HandleUpdateInventoryItemType(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req InventoryItemTypeRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		itemTypeRepo := repository.NewInventoryItemTypeRepository(db)
+		itemType, err := itemTypeRepo.GetByItemType(chi.URLParam(r, "itemType"), accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Item type not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve item type", http.StatusInternalServerError)
+			return
+		}
+
+		if req.ItemType != nil && *req.ItemType != itemType.ItemType {
+			respond.Validation(w, "item_type cannot be changed", respond.Field("item_type", "cannot be changed"))
+			return
+		}
+		if req.Unit != nil && *req.Unit != itemType.Unit {
+			respond.Validation(w, "unit cannot be changed", respond.Field("unit", "cannot be changed"))
+			return
+		}
+		if msg := applyInventoryItemTypeRequest(itemType, &req); msg != "" {
+			respond.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		if err := itemTypeRepo.Update(itemType); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Item type not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to update item type", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"inventory_item_type",
+			sql.NullInt64{Int64: itemType.ID, Valid: true},
+			map[string]interface{}{
+				"item_type":               itemType.ItemType,
+				"decrement_per_injection": itemType.DecrementPerInjection,
+				"purchase_unit":           itemType.PurchaseUnit.String,
+				"units_per_purchase":      itemType.UnitsPerPurchase.Float64,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		itemType.UpdatedAt = time.Now()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toInventoryItemTypeResponse(itemType))
+	}
+}
+
+// HandleDeleteInventoryItemType removes an item type the account no longer
+// tracks. Types with stock on hand or used by a compound are kept.
+func HandleDeleteInventoryItemType(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		key := chi.URLParam(r, "itemType")
+		itemType := lookupInventoryItemType(db, accountID, key)
+		if itemType == nil {
+			respond.Error(w, "Item type not found", http.StatusNotFound)
+			return
+		}
+
+		if _, err := repository.NewCompoundRepository(db).GetByInventoryItemType(key, accountID); err == nil {
+			respond.Error(w, "Item type is used by a compound", http.StatusConflict)
+			return
+		}
+
+		var quantity float64
+		err := db.QueryRow(`SELECT quantity FROM inventory_items WHERE item_type = ? AND account_id = ?`, key, accountID).Scan(&quantity)
+		if err != nil && err != sql.ErrNoRows {
+			respond.Error(w, "Failed to check stock", http.StatusInternalServerError)
+			return
+		}
+		if quantity > 0 {
+			respond.Error(w, "Item type still has stock; adjust it to zero first", http.StatusConflict)
+			return
+		}
+
+		if err := repository.NewInventoryItemTypeRepository(db).Delete(key, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Item type not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete item type", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"inventory_item_type",
+			sql.NullInt64{Int64: itemType.ID, Valid: true},
+			map[string]interface{}{"item_type": key},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
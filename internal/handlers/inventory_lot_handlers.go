@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// InventoryLotResponse is a received lot as returned by the API
+type InventoryLotResponse struct {
+	ID                int64      `json:"id"`
+	ItemType          string     `json:"item_type"`
+	LotNumber         *string    `json:"lot_number,omitempty"`
+	ExpirationDate    *time.Time `json:"expiration_date,omitempty"`
+	QuantityReceived  float64    `json:"quantity_received"`
+	QuantityRemaining float64    `json:"quantity_remaining"`
+	ReceivedAt        time.Time  `json:"received_at"`
+	Notes             *string    `json:"notes,omitempty"`
+	IsActive          bool       `json:"is_active"`
+	IsExpired         bool       `json:"is_expired"`
+	IsExpiringSoon    bool       `json:"is_expiring_soon"`
+}
+
+func toInventoryLotResponse(lot *models.InventoryLot, now time.Time) InventoryLotResponse {
+	resp := InventoryLotResponse{
+		ID:                lot.ID,
+		ItemType:          lot.ItemType,
+		QuantityReceived:  lot.QuantityReceived,
+		QuantityRemaining: lot.QuantityRemaining,
+		ReceivedAt:        lot.ReceivedAt,
+	}
+	if lot.LotNumber.Valid {
+		resp.LotNumber = &lot.LotNumber.String
+	}
+	if lot.ExpirationDate.Valid {
+		resp.ExpirationDate = &lot.ExpirationDate.Time
+		resp.IsExpired, resp.IsExpiringSoon = lotExpiryStatus(lot, now)
+	}
+	if lot.Notes.Valid {
+		resp.Notes = &lot.Notes.String
+	}
+	return resp
+}
+
+// lotExpiryStatus reports whether a lot has expired or is within the
+// expiration warning window
+func lotExpiryStatus(lot *models.InventoryLot, now time.Time) (expired, expiringSoon bool) {
+	if !lot.ExpirationDate.Valid {
+		return false, false
+	}
+	expires := lot.ExpirationDate.Time
+	if expires.Before(now) {
+		return true, false
+	}
+	return false, expires.Before(now.AddDate(0, 0, services.ExpirationWarningDays))
+}
+
+// HandleGetInventoryLots lists an item's lots in the order they will be used.
+// Pass include_empty=true to include used-up lots.
+func HandleGetInventoryLots(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		itemType := chi.URLParam(r, "itemType")
+		if !isValidItemType(db, accountID, itemType) {
+			respond.Error(w, "Invalid item type", http.StatusBadRequest)
+			return
+		}
+
+		includeEmpty := r.URL.Query().Get("include_empty") == "true"
+		lots, err := repository.NewInventoryLotRepository(db).ListByItemType(accountID, itemType, includeEmpty)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve inventory lots", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		response := make([]InventoryLotResponse, 0, len(lots))
+		activeSeen := false
+		for _, lot := range lots {
+			resp := toInventoryLotResponse(lot, now)
+			if !activeSeen && lot.QuantityRemaining > 0 {
+				resp.IsActive = true
+				activeSeen = true
+			}
+			response = append(response, resp)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			middleware.Log(r.Context()).Error("Failed to encode inventory lots", "err", err)
+		}
+	}
+}
+
+// activeLotExpiryWarning checks the lot an item will next be drawn from and,
+// if it has expired or expires soon, notifies the account and returns the
+// warning text. It returns "" when there is nothing to warn about.
+func activeLotExpiryWarning(db *database.DB, accountID int64, itemType string, now time.Time) string {
+	if accountID == 0 {
+		return ""
+	}
+	lot, err := repository.NewInventoryLotRepository(db).GetActiveLot(accountID, itemType)
+	if err != nil {
+		return ""
+	}
+
+	expired, expiringSoon := lotExpiryStatus(lot, now)
+	if !expired && !expiringSoon {
+		return ""
+	}
+
+	title, message, args := repository.ExpirationNotificationContent(itemType, lot.ExpirationDate.Time, expired)
+	if lot.LotNumber.Valid && lot.LotNumber.String != "" {
+		message = fmt.Sprintf("Lot %s: %s", lot.LotNumber.String, message)
+	}
+
+	priority := services.PriorityDefault
+	if expired {
+		priority = services.PriorityHigh
+	}
+	// The notification goes out after the response, so it can't use the
+	// request's context
+	dispatcher := services.NewNotificationDispatcher(db.Detach())
+	services.RunBackground(func() {
+		err := dispatcher.Dispatch(services.NotificationMessage{
+			AccountID:   accountID,
+			Type:        "expiration_warning",
+			Title:       title,
+			Message:     message,
+			Args:        args,
+			Priority:    priority,
+			DedupeKey:   itemType,
+			DedupeHours: 24,
+		})
+		if err != nil {
+			slog.Error("Failed to dispatch lot expiration notification", "account_id", accountID, "item_type", itemType, "err", err)
+		}
+	})
+
+	return message
+}
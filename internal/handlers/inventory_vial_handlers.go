@@ -0,0 +1,515 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Vial limits
+const (
+	// defaultVialDiscardAfterDays is the usual beyond-use period of an
+	// opened multi-dose vial
+	defaultVialDiscardAfterDays = 28
+	maxVialDiscardAfterDays     = 365
+	maxVialsPerReceipt          = 50
+	maxVialVolumeML             = 100
+)
+
+// ReceiveVialsRequest is the payload for adding sealed vials to stock
+type ReceiveVialsRequest struct {
+	ItemType         string        `json:"item_type,omitempty" validate:"max=50"` // Defaults to progesterone
+	Count            int           `json:"count,omitempty"`                       // Defaults to 1
+	VolumeML         float64       `json:"volume_ml"`
+	DiscardAfterDays int           `json:"discard_after_days,omitempty"` // Defaults to 28
+	Label            *string       `json:"label,omitempty" validate:"max=100"`
+	LotNumber        *string       `json:"lot_number,omitempty" validate:"max=100"`
+	ExpirationDate   *FlexibleDate `json:"expiration_date,omitempty"`
+	Notes            *string       `json:"notes,omitempty" validate:"multiline,max=1000"`
+}
+
+// UpdateVialRequest is the payload for updating a vial. Omitted fields are
+// left alone; opened_at can only be corrected on a vial already opened.
+type UpdateVialRequest struct {
+	Label            *string    `json:"label,omitempty" validate:"max=100"`
+	Notes            *string    `json:"notes,omitempty" validate:"multiline,max=1000"`
+	DiscardAfterDays *int       `json:"discard_after_days,omitempty"`
+	OpenedAt         *time.Time `json:"opened_at,omitempty"`
+}
+
+// OpenVialRequest optionally backdates when a vial was opened
+type OpenVialRequest struct {
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+// DiscardVialRequest optionally explains why a vial was thrown out
+type DiscardVialRequest struct {
+	Notes *string `json:"notes,omitempty" validate:"multiline,max=1000"`
+}
+
+// InventoryVialResponse is a vial as returned by the API
+type InventoryVialResponse struct {
+	ID               int64      `json:"id"`
+	ItemType         string     `json:"item_type"`
+	LotID            *int64     `json:"lot_id,omitempty"`
+	Label            *string    `json:"label,omitempty"`
+	VolumeML         float64    `json:"volume_ml"`
+	RemainingML      float64    `json:"remaining_ml"`
+	DiscardAfterDays int        `json:"discard_after_days"`
+	Status           string     `json:"status"` // sealed, open, empty or discarded
+	OpenedAt         *time.Time `json:"opened_at,omitempty"`
+	DiscardAt        *time.Time `json:"discard_at,omitempty"`
+	ClosedAt         *time.Time `json:"closed_at,omitempty"`
+	Notes            *string    `json:"notes,omitempty"`
+	IsPastDiscard    bool       `json:"is_past_discard"`
+	IsDiscardSoon    bool       `json:"is_discard_soon"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+func toInventoryVialResponse(vial *models.InventoryVial, now time.Time) InventoryVialResponse {
+	resp := InventoryVialResponse{
+		ID:               vial.ID,
+		ItemType:         vial.ItemType,
+		VolumeML:         vial.VolumeML,
+		RemainingML:      vial.RemainingML,
+		DiscardAfterDays: vial.DiscardAfterDays,
+		Status:           vial.Status,
+		CreatedAt:        vial.CreatedAt,
+	}
+	if vial.LotID.Valid {
+		resp.LotID = &vial.LotID.Int64
+	}
+	if vial.Label.Valid {
+		resp.Label = &vial.Label.String
+	}
+	if vial.OpenedAt.Valid {
+		resp.OpenedAt = &vial.OpenedAt.Time
+	}
+	if vial.DiscardAt.Valid {
+		resp.DiscardAt = &vial.DiscardAt.Time
+		if vial.Status == models.VialOpen {
+			resp.IsPastDiscard = !vial.DiscardAt.Time.After(now)
+			resp.IsDiscardSoon = !resp.IsPastDiscard &&
+				vial.DiscardAt.Time.Before(now.Add(services.VialDiscardWarningHours*time.Hour))
+		}
+	}
+	if vial.ClosedAt.Valid {
+		resp.ClosedAt = &vial.ClosedAt.Time
+	}
+	if vial.Notes.Valid {
+		resp.Notes = &vial.Notes.String
+	}
+	return resp
+}
+
+// optionalText is an optional label or note, NULL when blank
+func optionalText(v *string) sql.NullString {
+	if v == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *v, Valid: *v != ""}
+}
+
+func validVialDiscardAfterDays(days int) *respond.FieldError {
+	if days < 1 || days > maxVialDiscardAfterDays {
+		fe := respond.Field("discard_after_days", fmt.Sprintf("must be between 1 and %d", maxVialDiscardAfterDays))
+		return &fe
+	}
+	return nil
+}
+
+// vialFromRequest loads the vial named in the URL, writing the error
+// response and returning nil if it can't
+func vialFromRequest(w http.ResponseWriter, r *http.Request, db *database.DB, accountID int64) *models.InventoryVial {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respond.Error(w, "Invalid vial ID", http.StatusBadRequest)
+		return nil
+	}
+	vial, err := repository.NewInventoryVialRepository(db).GetByID(id, accountID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respond.Error(w, "Vial not found", http.StatusNotFound)
+			return nil
+		}
+		respond.Error(w, "Failed to retrieve vial", http.StatusInternalServerError)
+		return nil
+	}
+	return vial
+}
+
+// afterVialStockChange reports a change a vial made to its item's stock
+func afterVialStockChange(db *database.DB, accountID int64, itemType string, quantityBefore float64) {
+	item, err := repository.NewInventoryRepository(db).GetByType(itemType, accountID)
+	if err != nil {
+		return
+	}
+	emitLowStockIfCrossed(db, accountID, item, quantityBefore)
+	publishInventoryAdjusted(accountID, item, quantityBefore)
+}
+
+// HandleGetInventoryVials lists the account's vials, open ones first. Filter
+// with item_type; pass include_closed=true to include empty and discarded
+// vials.
+func HandleGetInventoryVials(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		itemType := r.URL.Query().Get("item_type")
+		includeClosed := r.URL.Query().Get("include_closed") == "true"
+		vials, err := repository.NewInventoryVialRepository(db).List(accountID, itemType, includeClosed)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve vials", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		response := make([]InventoryVialResponse, 0, len(vials))
+		for _, vial := range vials {
+			response = append(response, toInventoryVialResponse(vial, now))
+		}
+		respondJSON(w, http.StatusOK, response)
+	}
+}
+
+// HandleGetInventoryVial returns one vial
+func HandleGetInventoryVial(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		vial := vialFromRequest(w, r, db, accountID)
+		if vial == nil {
+			return
+		}
+		respondJSON(w, http.StatusOK, toInventoryVialResponse(vial, time.Now()))
+	}
+}
+
+// HandleReceiveInventoryVials adds sealed vials to stock. Their volume is
+// restocked as one lot.
+func HandleReceiveInventoryVials(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req ReceiveVialsRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		if req.ItemType == "" {
+			req.ItemType = repository.DefaultMedicationItemType
+		}
+		def := lookupInventoryItemType(db, accountID, req.ItemType)
+		if def == nil {
+			respond.Validation(w, "Invalid item type", respond.Field("item_type", "is not tracked by this account"))
+			return
+		}
+		if def.Unit != "mL" {
+			respond.Validation(w, "Vials can only hold items measured in mL", respond.Field("item_type", "must be measured in mL"))
+			return
+		}
+
+		if req.Count == 0 {
+			req.Count = 1
+		}
+		if req.Count < 1 || req.Count > maxVialsPerReceipt {
+			msg := fmt.Sprintf("must be between 1 and %d", maxVialsPerReceipt)
+			respond.Validation(w, "Invalid vials: count "+msg, respond.Field("count", msg))
+			return
+		}
+		if req.VolumeML <= 0 || req.VolumeML > maxVialVolumeML {
+			msg := fmt.Sprintf("must be more than 0 and at most %d", maxVialVolumeML)
+			respond.Validation(w, "Invalid vials: volume_ml "+msg, respond.Field("volume_ml", msg))
+			return
+		}
+		if req.DiscardAfterDays == 0 {
+			req.DiscardAfterDays = defaultVialDiscardAfterDays
+		}
+		if fe := validVialDiscardAfterDays(req.DiscardAfterDays); fe != nil {
+			respond.Validation(w, "Invalid vials: "+fe.Field+" "+fe.Message, *fe)
+			return
+		}
+
+		receipt := repository.VialReceipt{
+			Count:            req.Count,
+			VolumeML:         req.VolumeML,
+			DiscardAfterDays: req.DiscardAfterDays,
+			LotNumber:        nullString(req.LotNumber),
+			Label:            optionalText(req.Label),
+			Notes:            optionalText(req.Notes),
+		}
+		if req.ExpirationDate != nil {
+			receipt.ExpirationDate = sql.NullTime{Time: req.ExpirationDate.Time, Valid: true}
+		}
+
+		vials, quantityBefore, err := repository.NewInventoryVialRepository(db).Receive(def, accountID, userID, receipt)
+		if err != nil {
+			respond.Error(w, "Failed to receive vials", http.StatusInternalServerError)
+			return
+		}
+		afterVialStockChange(db, accountID, def.ItemType, quantityBefore)
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"inventory_vial",
+			sql.NullInt64{Int64: vials[0].ID, Valid: true},
+			map[string]interface{}{
+				"item_type": def.ItemType,
+				"count":     req.Count,
+				"volume_ml": req.VolumeML,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		now := time.Now()
+		response := make([]InventoryVialResponse, 0, len(vials))
+		for _, vial := range vials {
+			response = append(response, toInventoryVialResponse(vial, now))
+		}
+		respondJSON(w, http.StatusCreated, response)
+	}
+}
+
+// HandleUpdateInventoryVial updates a vial's label, notes, discard-after
+// days or opened date
+func HandleUpdateInventoryVial(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req UpdateVialRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		vial := vialFromRequest(w, r, db, accountID)
+		if vial == nil {
+			return
+		}
+
+		if req.Label != nil {
+			vial.Label = optionalText(req.Label)
+		}
+		if req.Notes != nil {
+			vial.Notes = optionalText(req.Notes)
+		}
+		if req.DiscardAfterDays != nil {
+			if fe := validVialDiscardAfterDays(*req.DiscardAfterDays); fe != nil {
+				respond.Validation(w, "Invalid vial: "+fe.Field+" "+fe.Message, *fe)
+				return
+			}
+			vial.DiscardAfterDays = *req.DiscardAfterDays
+		}
+		if req.OpenedAt != nil {
+			if !vial.OpenedAt.Valid {
+				respond.Validation(w, "Invalid vial: opened_at can only be changed once the vial is open",
+					respond.Field("opened_at", "can only be changed once the vial is open"))
+				return
+			}
+			if req.OpenedAt.After(time.Now()) {
+				respond.Validation(w, "Invalid vial: opened_at must not be in the future", respond.Field("opened_at", "must not be in the future"))
+				return
+			}
+			vial.OpenedAt = sql.NullTime{Time: *req.OpenedAt, Valid: true}
+		}
+
+		if err := repository.NewInventoryVialRepository(db).Update(vial); err != nil {
+			respond.Error(w, "Failed to update vial", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"inventory_vial",
+			sql.NullInt64{Int64: vial.ID, Valid: true},
+			map[string]interface{}{
+				"discard_after_days": vial.DiscardAfterDays,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, toInventoryVialResponse(vial, time.Now()))
+	}
+}
+
+// HandleOpenInventoryVial opens a sealed vial, starting its beyond-use
+// clock. Injections open vials themselves as they need them; this is for
+// opening one ahead of time or recording when it was opened.
+func HandleOpenInventoryVial(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req OpenVialRequest
+		if r.ContentLength != 0 {
+			if !decodeRequest(w, r, &req) {
+				return
+			}
+		}
+		now := time.Now()
+		openedAt := now
+		if req.OpenedAt != nil {
+			if req.OpenedAt.After(now) {
+				respond.Validation(w, "opened_at must not be in the future", respond.Field("opened_at", "must not be in the future"))
+				return
+			}
+			openedAt = *req.OpenedAt
+		}
+
+		vial := vialFromRequest(w, r, db, accountID)
+		if vial == nil {
+			return
+		}
+		if err := repository.NewInventoryVialRepository(db).Open(vial, openedAt); err != nil {
+			if errors.Is(err, repository.ErrVialNotSealed) {
+				respond.Error(w, "Vial has already been opened", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to open vial", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"open",
+			"inventory_vial",
+			sql.NullInt64{Int64: vial.ID, Valid: true},
+			map[string]interface{}{
+				"opened_at":  vial.OpenedAt.Time,
+				"discard_at": vial.DiscardAt.Time,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, toInventoryVialResponse(vial, now))
+	}
+}
+
+// HandleDiscardInventoryVial throws a vial out, taking what was left in it
+// out of stock
+func HandleDiscardInventoryVial(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req DiscardVialRequest
+		if r.ContentLength != 0 {
+			if !decodeRequest(w, r, &req) {
+				return
+			}
+		}
+		notes := optionalText(req.Notes)
+
+		vial := vialFromRequest(w, r, db, accountID)
+		if vial == nil {
+			return
+		}
+		quantityBefore, err := repository.NewInventoryVialRepository(db).Discard(vial, userID, notes.String)
+		if err != nil {
+			if errors.Is(err, repository.ErrVialClosed) {
+				respond.Error(w, "Vial is already empty or discarded", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to discard vial", http.StatusInternalServerError)
+			return
+		}
+		afterVialStockChange(db, accountID, vial.ItemType, quantityBefore)
+
+		respondJSON(w, http.StatusOK, toInventoryVialResponse(vial, time.Now()))
+	}
+}
+
+// HandleDeleteInventoryVial stops tracking a vial entered by mistake. Stock
+// is left as it is; use discard for a vial thrown out.
+func HandleDeleteInventoryVial(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid vial ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewInventoryVialRepository(db).Delete(id, accountID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				respond.Error(w, "Vial not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete vial", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"inventory_vial",
+			sql.NullInt64{Int64: id, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
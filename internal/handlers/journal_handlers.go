@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// JournalEntryRequest is the payload for creating or updating a journal
+// entry. On update, omitted fields are left alone; course_id 0 and an empty
+// entry_date unlink the entry from its course or day.
+type JournalEntryRequest struct {
+	Title     *string   `json:"title,omitempty" validate:"max=200"`
+	Body      *string   `json:"body,omitempty" validate:"multiline,max=50000"` // Markdown
+	Tags      *[]string `json:"tags,omitempty" validate:"max=20,itemmax=40"`
+	CourseID  *int64    `json:"course_id,omitempty"`
+	EntryDate *string   `json:"entry_date,omitempty"` // YYYY-MM-DD
+	IsPinned  *bool     `json:"is_pinned,omitempty"`
+}
+
+// JournalEntryResponse is a journal entry as returned by the API
+type JournalEntryResponse struct {
+	ID        int64     `json:"id"`
+	AuthorID  *int64    `json:"author_id,omitempty"`
+	CourseID  *int64    `json:"course_id,omitempty"`
+	EntryDate *string   `json:"entry_date,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Body      string    `json:"body"`
+	Tags      []string  `json:"tags"`
+	IsPinned  bool      `json:"is_pinned"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func toJournalEntryResponse(e *models.JournalEntry) JournalEntryResponse {
+	resp := JournalEntryResponse{
+		ID:        e.ID,
+		AuthorID:  nullInt64ToInt(e.AuthorID),
+		CourseID:  nullInt64ToInt(e.CourseID),
+		Title:     e.Title.String,
+		Body:      e.Body,
+		Tags:      e.Tags,
+		IsPinned:  e.IsPinned,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}
+	if e.EntryDate.Valid {
+		date := e.EntryDate.Time.UTC().Format("2006-01-02")
+		resp.EntryDate = &date
+	}
+	if resp.Tags == nil {
+		resp.Tags = []string{}
+	}
+	return resp
+}
+
+// applyJournalEntryRequest validates req and copies the provided fields onto
+// e, returning the first invalid field
+func applyJournalEntryRequest(db *database.DB, e *models.JournalEntry, req *JournalEntryRequest) *respond.FieldError {
+	if req.Title != nil {
+		e.Title = optionalText(req.Title)
+	}
+	if req.Body != nil {
+		if *req.Body == "" {
+			fe := respond.Field("body", "is required")
+			return &fe
+		}
+		e.Body = *req.Body
+	}
+	if req.Tags != nil {
+		tags := []string{}
+		for _, tag := range *req.Tags {
+			tag = repository.JournalTag(tag)
+			if tag == "" {
+				continue
+			}
+			tags = append(tags, tag)
+		}
+		e.Tags = tags
+	}
+	if req.CourseID != nil {
+		e.CourseID = sql.NullInt64{}
+		if *req.CourseID != 0 {
+			if _, err := repository.NewCourseRepository(db).GetByID(*req.CourseID, e.AccountID); err != nil {
+				fe := respond.Field("course_id", "course not found")
+				return &fe
+			}
+			e.CourseID = sql.NullInt64{Int64: *req.CourseID, Valid: true}
+		}
+	}
+	if req.EntryDate != nil {
+		e.EntryDate = sql.NullTime{}
+		if *req.EntryDate != "" {
+			date, err := time.Parse("2006-01-02", *req.EntryDate)
+			if err != nil {
+				fe := respond.Field("entry_date", "invalid format (use YYYY-MM-DD)")
+				return &fe
+			}
+			e.EntryDate = sql.NullTime{Time: date, Valid: true}
+		}
+	}
+	if req.IsPinned != nil {
+		e.IsPinned = *req.IsPinned
+	}
+	return nil
+}
+
+// HandleGetJournalEntries returns a page of the account's journal entries,
+// newest first. q searches titles, bodies and tags; tag, course_id, date
+// (the day an entry is about), start_date and end_date (when it was
+// written) and pinned=true narrow the list.
+func HandleGetJournalEntries(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		filter := repository.JournalFilter{
+			Tag:        query.Get("tag"),
+			Query:      query.Get("q"),
+			PinnedOnly: query.Get("pinned") == "true",
+		}
+		if v := query.Get("course_id"); v != "" {
+			filter.CourseID, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				respond.Validation(w, "Invalid course_id", respond.Field("course_id", "is invalid"))
+				return
+			}
+		}
+		if v := query.Get("date"); v != "" {
+			filter.Date, err = time.Parse("2006-01-02", v)
+			if err != nil {
+				respond.Validation(w, "Invalid date format (use YYYY-MM-DD)", respond.Field("date", "invalid format (use YYYY-MM-DD)"))
+				return
+			}
+		}
+		filter.Start, filter.End, err = parseListDateRange(r, userLocation(db, userID))
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := repository.NewJournalRepository(db).ListPage(accountID, filter, page)
+		if err != nil {
+			listPageError(w, err, "Failed to retrieve journal entries")
+			return
+		}
+		respondJSON(w, http.StatusOK, newListResponse(result, toJournalEntryResponse))
+	}
+}
+
+// HandleGetJournalTags lists the tags used in the account's journal with how
+// many entries carry each
+func HandleGetJournalTags(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		tags, err := repository.NewJournalRepository(db).Tags(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve journal tags", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, tags)
+	}
+}
+
+// HandleGetJournalEntry returns a single journal entry
+func HandleGetJournalEntry(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid journal entry ID", http.StatusBadRequest)
+			return
+		}
+
+		entry, err := repository.NewJournalRepository(db).GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Journal entry not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve journal entry", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, toJournalEntryResponse(entry))
+	}
+}
+
+// HandleCreateJournalEntry adds an entry to the account's journal
+func HandleCreateJournalEntry(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req JournalEntryRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Body == nil {
+			respond.Validation(w, "body is required", respond.Field("body", "is required"))
+			return
+		}
+
+		entry := &models.JournalEntry{
+			AccountID: accountID,
+			AuthorID:  sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if fe := applyJournalEntryRequest(db, entry, &req); fe != nil {
+			respond.Validation(w, "Invalid journal entry: "+fe.Field+" "+fe.Message, *fe)
+			return
+		}
+
+		if err := repository.NewJournalRepository(db).Create(entry); err != nil {
+			respond.Error(w, "Failed to create journal entry", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"journal_entry",
+			sql.NullInt64{Int64: entry.ID, Valid: true},
+			map[string]interface{}{
+				"title": entry.Title.String,
+				"tags":  entry.Tags,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusCreated, toJournalEntryResponse(entry))
+	}
+}
+
+// HandleUpdateJournalEntry updates a journal entry, including pinning it to
+// or unpinning it from the dashboard
+func HandleUpdateJournalEntry(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid journal entry ID", http.StatusBadRequest)
+			return
+		}
+
+		var req JournalEntryRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		journalRepo := repository.NewJournalRepository(db)
+		entry, err := journalRepo.GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Journal entry not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve journal entry", http.StatusInternalServerError)
+			return
+		}
+
+		if fe := applyJournalEntryRequest(db, entry, &req); fe != nil {
+			respond.Validation(w, "Invalid journal entry: "+fe.Field+" "+fe.Message, *fe)
+			return
+		}
+
+		if err := journalRepo.Update(entry); err != nil {
+			respond.Error(w, "Failed to update journal entry", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"journal_entry",
+			sql.NullInt64{Int64: entry.ID, Valid: true},
+			map[string]interface{}{
+				"title":     entry.Title.String,
+				"tags":      entry.Tags,
+				"is_pinned": entry.IsPinned,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, toJournalEntryResponse(entry))
+	}
+}
+
+// HandleDeleteJournalEntry moves a journal entry to the trash
+func HandleDeleteJournalEntry(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid journal entry ID", http.StatusBadRequest)
+			return
+		}
+
+		entry, err := repository.NewJournalRepository(db).GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Journal entry not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve journal entry", http.StatusInternalServerError)
+			return
+		}
+
+		trashed := moveToTrash(w, db, &models.TrashItem{
+			AccountID: accountID,
+			ItemType:  repository.TrashJournalEntry,
+			ItemID:    id,
+			Label:     journalTrashLabel(entry),
+			DeletedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}, "Journal entry not found")
+		if trashed == nil {
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"journal_entry",
+			sql.NullInt64{Int64: id, Valid: true},
+			map[string]interface{}{"trash_id": trashed.ID},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,551 @@
+package handlers
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Lab result limits
+const (
+	maxLabTrendDays    = 730
+	maxLabTrendResults = 1000
+)
+
+// LabResultRequest is the payload for recording or updating a lab result.
+// On update, omitted fields are left alone; course_id 0 unlinks the result
+// from its course and a reference bound of 0 clears it.
+type LabResultRequest struct {
+	Analyte       *string    `json:"analyte,omitempty" validate:"max=100"`
+	Value         *float64   `json:"value,omitempty" validate:"min=0"`
+	Unit          *string    `json:"unit,omitempty" validate:"max=30"`
+	ReferenceLow  *float64   `json:"reference_low,omitempty" validate:"min=0"`
+	ReferenceHigh *float64   `json:"reference_high,omitempty" validate:"min=0"`
+	DrawnAt       *time.Time `json:"drawn_at,omitempty"`
+	CourseID      *int64     `json:"course_id,omitempty"`
+	Notes         *string    `json:"notes,omitempty" validate:"multiline,max=2000"`
+}
+
+// LabResultResponse is a lab result as returned by the API
+type LabResultResponse struct {
+	ID            int64         `json:"id"`
+	CourseID      *int64        `json:"course_id,omitempty"`
+	Analyte       string        `json:"analyte"`
+	Value         float64       `json:"value"`
+	Unit          string        `json:"unit"`
+	ReferenceLow  *float64      `json:"reference_low,omitempty"`
+	ReferenceHigh *float64      `json:"reference_high,omitempty"`
+	Flag          string        `json:"flag,omitempty"` // low or high
+	DrawnAt       time.Time     `json:"drawn_at"`
+	Notes         string        `json:"notes,omitempty"`
+	PriorDose     *LabPriorDose `json:"prior_dose,omitempty"`
+	CreatedBy     *int64        `json:"created_by,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// LabPriorDose is the injection given most recently before a draw
+type LabPriorDose struct {
+	InjectedAt  time.Time `json:"injected_at"`
+	HoursBefore float64   `json:"hours_before"` // From the injection to the draw
+	DoseML      float64   `json:"dose_ml"`
+	DoseMG      *float64  `json:"dose_mg,omitempty"`
+}
+
+// LabTrendsResponse has a series per analyte drawn in the window
+type LabTrendsResponse struct {
+	StartDate string      `json:"start_date"`
+	EndDate   string      `json:"end_date"`
+	Series    []LabSeries `json:"series"`
+}
+
+// LabSeries is one analyte's results in one unit, oldest first. A change of
+// unit starts a separate series, as the values can't share an axis.
+type LabSeries struct {
+	Analyte       string     `json:"analyte"`
+	Unit          string     `json:"unit"`
+	ReferenceLow  *float64   `json:"reference_low,omitempty"` // From the latest result
+	ReferenceHigh *float64   `json:"reference_high,omitempty"`
+	Points        []LabPoint `json:"points"`
+}
+
+// LabPoint is one result in a series
+type LabPoint struct {
+	ID        int64         `json:"id"`
+	Date      string        `json:"date"`             // Day drawn, in the viewer's timezone
+	Bucket    string        `json:"bucket,omitempty"` // Chart bucket the draw falls in, when the chart has them
+	DrawnAt   time.Time     `json:"drawn_at"`
+	Value     float64       `json:"value"`
+	Flag      string        `json:"flag,omitempty"`
+	PriorDose *LabPriorDose `json:"prior_dose,omitempty"`
+}
+
+func toLabResultResponse(l *models.LabResult) LabResultResponse {
+	return LabResultResponse{
+		ID:            l.ID,
+		CourseID:      nullInt64ToInt(l.CourseID),
+		Analyte:       l.Analyte,
+		Value:         l.Value,
+		Unit:          l.Unit,
+		ReferenceLow:  nullFloat64ToPtr(l.ReferenceLow),
+		ReferenceHigh: nullFloat64ToPtr(l.ReferenceHigh),
+		Flag:          l.Flag(),
+		DrawnAt:       l.DrawnAt,
+		Notes:         l.Notes.String,
+		CreatedBy:     nullInt64ToInt(l.CreatedBy),
+		CreatedAt:     l.CreatedAt,
+		UpdatedAt:     l.UpdatedAt,
+	}
+}
+
+// labPriorDose looks up the dose given before a draw; a lookup failure just
+// leaves it out
+func labPriorDose(db *database.DB, accountID int64, drawnAt time.Time) *LabPriorDose {
+	dose, err := repository.NewLabResultRepository(db).PriorDose(accountID, drawnAt)
+	if err != nil {
+		return nil
+	}
+	return &LabPriorDose{
+		InjectedAt:  dose.InjectedAt,
+		HoursBefore: math.Round(drawnAt.Sub(dose.InjectedAt).Hours()*10) / 10,
+		DoseML:      dose.DoseML,
+		DoseMG:      nullFloat64ToPtr(dose.DoseMG),
+	}
+}
+
+// applyLabResultRequest validates req and copies the provided fields onto l,
+// returning the first invalid field
+func applyLabResultRequest(db *database.DB, l *models.LabResult, req *LabResultRequest) *respond.FieldError {
+	if req.Analyte != nil {
+		analyte := strings.Join(strings.Fields(*req.Analyte), " ")
+		if analyte == "" {
+			fe := respond.Field("analyte", "is required")
+			return &fe
+		}
+		l.Analyte = analyte
+	}
+	if req.Value != nil {
+		l.Value = *req.Value
+	}
+	if req.Unit != nil {
+		if *req.Unit == "" {
+			fe := respond.Field("unit", "is required")
+			return &fe
+		}
+		l.Unit = *req.Unit
+	}
+	if req.ReferenceLow != nil {
+		l.ReferenceLow = sql.NullFloat64{Float64: *req.ReferenceLow, Valid: *req.ReferenceLow != 0}
+	}
+	if req.ReferenceHigh != nil {
+		l.ReferenceHigh = sql.NullFloat64{Float64: *req.ReferenceHigh, Valid: *req.ReferenceHigh != 0}
+	}
+	if l.ReferenceLow.Valid && l.ReferenceHigh.Valid && l.ReferenceLow.Float64 > l.ReferenceHigh.Float64 {
+		fe := respond.Field("reference_low", "must not be above reference_high")
+		return &fe
+	}
+	if req.DrawnAt != nil {
+		if req.DrawnAt.After(time.Now().Add(time.Hour)) {
+			fe := respond.Field("drawn_at", "is in the future")
+			return &fe
+		}
+		l.DrawnAt = req.DrawnAt.UTC()
+	}
+	if req.CourseID != nil {
+		l.CourseID = sql.NullInt64{}
+		if *req.CourseID != 0 {
+			if _, err := repository.NewCourseRepository(db).GetByID(*req.CourseID, l.AccountID); err != nil {
+				fe := respond.Field("course_id", "course not found")
+				return &fe
+			}
+			l.CourseID = sql.NullInt64{Int64: *req.CourseID, Valid: true}
+		}
+	}
+	if req.Notes != nil {
+		l.Notes = optionalText(req.Notes)
+	}
+	return nil
+}
+
+// labSeries groups results by analyte and unit into series sorted by
+// analyte, each oldest first. Results must come newest first, as List
+// returns them. bucketOf, when set, fills in each point's Bucket.
+func labSeries(results []*models.LabResult, loc *time.Location, bucketOf func(time.Time) string) []LabSeries {
+	series := []LabSeries{}
+	index := make(map[string]int)
+	for _, l := range results {
+		key := strings.ToLower(l.Analyte) + "\x00" + l.Unit
+		i, ok := index[key]
+		if !ok {
+			// The newest result names the series and gives its range
+			i = len(series)
+			index[key] = i
+			series = append(series, LabSeries{
+				Analyte:       l.Analyte,
+				Unit:          l.Unit,
+				ReferenceLow:  nullFloat64ToPtr(l.ReferenceLow),
+				ReferenceHigh: nullFloat64ToPtr(l.ReferenceHigh),
+				Points:        []LabPoint{},
+			})
+		}
+		point := LabPoint{
+			ID:      l.ID,
+			Date:    l.DrawnAt.In(loc).Format("2006-01-02"),
+			DrawnAt: l.DrawnAt,
+			Value:   l.Value,
+			Flag:    l.Flag(),
+		}
+		if bucketOf != nil {
+			point.Bucket = bucketOf(l.DrawnAt)
+		}
+		series[i].Points = append(series[i].Points, point)
+	}
+
+	for _, s := range series {
+		for a, b := 0, len(s.Points)-1; a < b; a, b = a+1, b-1 {
+			s.Points[a], s.Points[b] = s.Points[b], s.Points[a]
+		}
+	}
+	sort.SliceStable(series, func(a, b int) bool {
+		return strings.ToLower(series[a].Analyte) < strings.ToLower(series[b].Analyte)
+	})
+	return series
+}
+
+// HandleGetLabResults lists the account's lab results, newest draw first,
+// optionally for one analyte, one course (results not linked to a course
+// are always included) and drawn between start_date and end_date
+func HandleGetLabResults(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		filter := repository.LabResultFilter{Analyte: query.Get("analyte")}
+		var err error
+		if v := query.Get("course_id"); v != "" {
+			filter.CourseID, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				respond.Validation(w, "Invalid course_id", respond.Field("course_id", "is invalid"))
+				return
+			}
+		}
+		filter.Start, filter.End, err = parseListDateRange(r, userLocation(db, userID))
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit := 100
+		if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 {
+			limit = min(v, maxLabTrendResults)
+		}
+
+		results, err := repository.NewLabResultRepository(db).List(accountID, filter, limit)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve lab results", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]LabResultResponse, 0, len(results))
+		for _, l := range results {
+			resp = append(resp, toLabResultResponse(l))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleGetLabAnalytes lists the analytes the account has results for, for
+// suggesting names when recording a new one
+func HandleGetLabAnalytes(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		analytes, err := repository.NewLabResultRepository(db).Analytes(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve lab analytes", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, analytes)
+	}
+}
+
+// HandleGetLabTrends returns the account's lab results over the last days
+// (default 180) as a series per analyte, each point with the dose given
+// before the draw. analyte and course_id narrow the results as for the list.
+func HandleGetLabTrends(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		days := 180
+		if d, err := strconv.Atoi(query.Get("days")); err == nil && d > 0 {
+			days = min(d, maxLabTrendDays)
+		}
+		filter := repository.LabResultFilter{Analyte: query.Get("analyte")}
+		if v := query.Get("course_id"); v != "" {
+			var err error
+			filter.CourseID, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				respond.Validation(w, "Invalid course_id", respond.Field("course_id", "is invalid"))
+				return
+			}
+		}
+
+		loc := userLocation(db, userID)
+		now := time.Now().In(loc)
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		filter.Start = today.AddDate(0, 0, -(days - 1))
+
+		results, err := repository.NewLabResultRepository(db).List(accountID, filter, maxLabTrendResults)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve lab trends", http.StatusInternalServerError)
+			return
+		}
+
+		resp := LabTrendsResponse{
+			StartDate: filter.Start.Format("2006-01-02"),
+			EndDate:   today.Format("2006-01-02"),
+			Series:    labSeries(results, loc, nil),
+		}
+		for _, s := range resp.Series {
+			for i := range s.Points {
+				s.Points[i].PriorDose = labPriorDose(db, accountID, s.Points[i].DrawnAt)
+			}
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleGetLabResult returns a single lab result along with the dose given
+// before it was drawn
+func HandleGetLabResult(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid lab result ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := repository.NewLabResultRepository(db).GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Lab result not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve lab result", http.StatusInternalServerError)
+			return
+		}
+
+		resp := toLabResultResponse(result)
+		resp.PriorDose = labPriorDose(db, accountID, result.DrawnAt)
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleCreateLabResult records a lab result for the account
+func HandleCreateLabResult(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req LabResultRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		var missing []respond.FieldError
+		if req.Analyte == nil {
+			missing = append(missing, respond.Field("analyte", "is required"))
+		}
+		if req.Value == nil {
+			missing = append(missing, respond.Field("value", "is required"))
+		}
+		if req.Unit == nil {
+			missing = append(missing, respond.Field("unit", "is required"))
+		}
+		if req.DrawnAt == nil {
+			missing = append(missing, respond.Field("drawn_at", "is required"))
+		}
+		if len(missing) > 0 {
+			respond.Validation(w, "analyte, value, unit and drawn_at are required", missing...)
+			return
+		}
+
+		result := &models.LabResult{
+			AccountID: accountID,
+			CreatedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if fe := applyLabResultRequest(db, result, &req); fe != nil {
+			respond.Validation(w, "Invalid lab result: "+fe.Field+" "+fe.Message, *fe)
+			return
+		}
+
+		if err := repository.NewLabResultRepository(db).Create(result); err != nil {
+			respond.Error(w, "Failed to record lab result", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"lab_result",
+			sql.NullInt64{Int64: result.ID, Valid: true},
+			map[string]interface{}{
+				"analyte":  result.Analyte,
+				"drawn_at": result.DrawnAt,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		resp := toLabResultResponse(result)
+		resp.PriorDose = labPriorDose(db, accountID, result.DrawnAt)
+		respondJSON(w, http.StatusCreated, resp)
+	}
+}
+
+// HandleUpdateLabResult updates a lab result
+func HandleUpdateLabResult(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid lab result ID", http.StatusBadRequest)
+			return
+		}
+
+		var req LabResultRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		labRepo := repository.NewLabResultRepository(db)
+		result, err := labRepo.GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Lab result not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve lab result", http.StatusInternalServerError)
+			return
+		}
+
+		if fe := applyLabResultRequest(db, result, &req); fe != nil {
+			respond.Validation(w, "Invalid lab result: "+fe.Field+" "+fe.Message, *fe)
+			return
+		}
+
+		if err := labRepo.Update(result); err != nil {
+			respond.Error(w, "Failed to update lab result", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"lab_result",
+			sql.NullInt64{Int64: result.ID, Valid: true},
+			map[string]interface{}{
+				"analyte":  result.Analyte,
+				"drawn_at": result.DrawnAt,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		resp := toLabResultResponse(result)
+		resp.PriorDose = labPriorDose(db, accountID, result.DrawnAt)
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleDeleteLabResult deletes a lab result
+func HandleDeleteLabResult(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid lab result ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewLabResultRepository(db).Delete(id, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Lab result not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete lab result", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"lab_result",
+			sql.NullInt64{Int64: id, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+)
+
+const (
+	maxCatalogMedicationsListed     = 50
+	defaultCatalogMedicationsListed = 10
+)
+
+// MedicationCatalogResponse is a catalog entry offered while typing a
+// medication's name
+type MedicationCatalogResponse struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	DoseForm    string  `json:"dose_form"`
+	Route       *string `json:"route,omitempty"`
+	BrandNames  *string `json:"brand_names,omitempty"`
+	DisplayName string  `json:"display_name"` // The name a medication picked from it is given
+}
+
+func toMedicationCatalogResponse(e *models.MedicationCatalogEntry) MedicationCatalogResponse {
+	resp := MedicationCatalogResponse{
+		ID:          e.ID,
+		Name:        e.Name,
+		DoseForm:    e.DoseForm,
+		DisplayName: e.DisplayName(),
+	}
+	if e.Route.Valid {
+		resp.Route = &e.Route.String
+	}
+	if e.BrandNames.Valid {
+		resp.BrandNames = &e.BrandNames.String
+	}
+	return resp
+}
+
+// catalogEntry looks up the catalog entry a medication request names,
+// writing a validation error if there isn't one
+func catalogEntry(w http.ResponseWriter, db *database.DB, id int64) (*models.MedicationCatalogEntry, bool) {
+	entry, err := repository.NewMedicationCatalogRepository(db).GetByID(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respond.Validation(w, "catalog_id is not a catalog medication", respond.Field("catalog_id", "not found"))
+		return nil, false
+	}
+	if err != nil {
+		respond.Error(w, "Failed to retrieve catalog medication", http.StatusInternalServerError)
+		return nil, false
+	}
+	return entry, true
+}
+
+// HandleSearchMedicationCatalog is the typeahead for medication names. q is
+// matched word by word against names, dose forms and brand names; limit
+// caps the results (default 10, at most 50).
+func HandleSearchMedicationCatalog(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		limit := defaultCatalogMedicationsListed
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxCatalogMedicationsListed {
+				msg := fmt.Sprintf("must be between 1 and %d", maxCatalogMedicationsListed)
+				respond.Validation(w, "Invalid limit: "+msg, respond.Field("limit", msg))
+				return
+			}
+			limit = n
+		}
+
+		entries, err := repository.NewMedicationCatalogRepository(db).Search(r.URL.Query().Get("q"), limit)
+		if err != nil {
+			respond.Error(w, "Failed to search medications", http.StatusInternalServerError)
+			return
+		}
+		response := make([]MedicationCatalogResponse, 0, len(entries))
+		for _, e := range entries {
+			response = append(response, toMedicationCatalogResponse(e))
+		}
+		respondJSON(w, http.StatusOK, response)
+	}
+}
@@ -4,61 +4,233 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
 	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
 	"injection-tracker/internal/middleware"
 	"injection-tracker/internal/models"
 	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+	"injection-tracker/internal/web"
 
 	"github.com/go-chi/chi/v5"
 )
 
 // CreateMedicationRequest represents the request body for creating a medication
 type CreateMedicationRequest struct {
-	Name              string  `json:"name"`
-	Dosage            *string `json:"dosage,omitempty"`
-	Frequency         *string `json:"frequency,omitempty"`
-	StartDate         *string `json:"start_date,omitempty"`
-	EndDate           *string `json:"end_date,omitempty"`
-	Notes             *string `json:"notes,omitempty"`
-	ScheduledTime     *string `json:"scheduled_time,omitempty"`      // HH:MM format
-	TimeWindowMinutes *int64  `json:"time_window_minutes,omitempty"` // Optional time window
-	ReminderEnabled   *bool   `json:"reminder_enabled,omitempty"`
-	IsActive          *bool   `json:"is_active,omitempty"`
+	Name              string   `json:"name" validate:"max=100"`
+	Dosage            *string  `json:"dosage,omitempty" validate:"max=100"`
+	Frequency         *string  `json:"frequency,omitempty" validate:"max=100"`
+	StartDate         *string  `json:"start_date,omitempty"`
+	EndDate           *string  `json:"end_date,omitempty"`
+	Notes             *string  `json:"notes,omitempty" validate:"multiline,max=2000"`
+	ScheduledTime     *string  `json:"scheduled_time,omitempty"`                               // HH:MM format; dose_times takes precedence
+	DoseTimes         []string `json:"dose_times,omitempty" validate:"max=24"`                 // HH:MM of each dose a day
+	TimeWindowMinutes *int64   `json:"time_window_minutes,omitempty" validate:"min=0,max=720"` // Optional time window, at most half a day
+	ReminderEnabled   *bool    `json:"reminder_enabled,omitempty"`
+	IsActive          *bool    `json:"is_active,omitempty"`
+	CatalogID         *int64   `json:"catalog_id,omitempty"`                            // 0 unlinks on update
+	InventoryItemType *string  `json:"inventory_item_type,omitempty" validate:"max=50"` // Item a dose taken comes out of
+	UnitsPerDose      *float64 `json:"units_per_dose,omitempty"`                        // Default 1
 }
 
 // UpdateMedicationRequest represents the request body for updating a medication
 type UpdateMedicationRequest struct {
-	Name              *string `json:"name,omitempty"`
-	Dosage            *string `json:"dosage,omitempty"`
-	Frequency         *string `json:"frequency,omitempty"`
-	StartDate         *string `json:"start_date,omitempty"`
-	EndDate           *string `json:"end_date,omitempty"`
-	Notes             *string `json:"notes,omitempty"`
-	ScheduledTime     *string `json:"scheduled_time,omitempty"`
-	TimeWindowMinutes *int64  `json:"time_window_minutes,omitempty"`
-	ReminderEnabled   *bool   `json:"reminder_enabled,omitempty"`
-	IsActive          *bool   `json:"is_active,omitempty"`
+	Name              *string   `json:"name,omitempty" validate:"max=100"`
+	Dosage            *string   `json:"dosage,omitempty" validate:"max=100"`
+	Frequency         *string   `json:"frequency,omitempty" validate:"max=100"`
+	StartDate         *string   `json:"start_date,omitempty"`
+	EndDate           *string   `json:"end_date,omitempty"`
+	Notes             *string   `json:"notes,omitempty" validate:"multiline,max=2000"`
+	ScheduledTime     *string   `json:"scheduled_time,omitempty"` // Replaces the dose times with this one, "" clears them
+	DoseTimes         *[]string `json:"dose_times,omitempty" validate:"max=24"`
+	TimeWindowMinutes *int64    `json:"time_window_minutes,omitempty" validate:"min=0,max=720"` // 0 clears it
+	ReminderEnabled   *bool     `json:"reminder_enabled,omitempty"`
+	IsActive          *bool     `json:"is_active,omitempty"`
+	CatalogID         *int64    `json:"catalog_id,omitempty"`                            // 0 unlinks on update
+	InventoryItemType *string   `json:"inventory_item_type,omitempty" validate:"max=50"` // "" unlinks it from inventory
+	UnitsPerDose      *float64  `json:"units_per_dose,omitempty"`
+}
+
+// BulkMedicationUpdate is one medication's changes in a bulk update
+type BulkMedicationUpdate struct {
+	ID int64 `json:"id" validate:"required"`
+	// BaseUpdatedAt is the updated_at the client last saw. If the
+	// medication has changed since, its update fails with 412.
+	BaseUpdatedAt *time.Time `json:"base_updated_at,omitempty"`
+	UpdateMedicationRequest
+}
+
+// BulkUpdateMedicationsRequest changes several medications at once
+type BulkUpdateMedicationsRequest struct {
+	Updates []BulkMedicationUpdate `json:"updates" validate:"required,max=100"`
 }
 
 // LogMedicationRequest represents the request body for logging medication taken/missed
 type LogMedicationRequest struct {
-	Timestamp *string `json:"timestamp,omitempty"`
-	Taken     bool    `json:"taken"`
-	Notes     *string `json:"notes,omitempty"`
+	Timestamp    *string `json:"timestamp,omitempty"`
+	Taken        bool    `json:"taken"`
+	Late         bool    `json:"late"` // Taken late; doses logged after their window closes are late anyway
+	Notes        *string `json:"notes,omitempty" validate:"multiline,max=2000"`
+	ScheduledFor *string `json:"scheduled_for,omitempty"` // RFC3339 time of the dose; defaults to the nearest one not logged
+}
+
+// SnoozeMedicationDoseRequest represents the request body for snoozing a
+// dose's reminder
+type SnoozeMedicationDoseRequest struct {
+	Minutes      int     `json:"minutes"`
+	ScheduledFor *string `json:"scheduled_for,omitempty"` // RFC3339 time of the dose; defaults to the one due now
+}
+
+// medicationDoseTimes works out a medication's dose times from dose_times,
+// or failing that scheduled_time, writing a validation error if they're
+// invalid
+func medicationDoseTimes(w http.ResponseWriter, doseTimes []string, scheduledTime *string) ([]string, bool) {
+	if len(doseTimes) == 0 && scheduledTime != nil && *scheduledTime != "" {
+		doseTimes = []string{*scheduledTime}
+	}
+	times, err := services.NormalizeDoseTimes(doseTimes)
+	if err != nil {
+		respond.Validation(w, "Invalid dose_times: "+err.Error(), respond.Field("dose_times", err.Error()))
+		return nil, false
+	}
+	return times, true
+}
+
+// setDoseTimes gives a medication its dose times, keeping scheduled_time as
+// the first of them
+func setDoseTimes(medication *models.Medication, times []string) {
+	medication.DoseTimes = times
+	medication.ScheduledTime = sql.NullString{}
+	if len(times) > 0 {
+		medication.ScheduledTime = sql.NullString{String: times[0], Valid: true}
+	}
+}
+
+// setMedicationStock links a medication to the inventory item its doses
+// come out of, writing a validation error if the account has no such item
+// type. An empty item type unlinks it.
+func setMedicationStock(w http.ResponseWriter, db *database.DB, accountID int64, medication *models.Medication, itemType *string, unitsPerDose *float64) bool {
+	if itemType != nil {
+		if *itemType == "" {
+			medication.InventoryItemType = sql.NullString{}
+			medication.UnitsPerDose = sql.NullFloat64{}
+		} else if lookupInventoryItemType(db, accountID, *itemType) == nil {
+			respond.Validation(w, "inventory_item_type is not one of the account's item types", respond.Field("inventory_item_type", "not found"))
+			return false
+		} else {
+			medication.InventoryItemType = sql.NullString{String: *itemType, Valid: true}
+		}
+	}
+	if unitsPerDose != nil {
+		if *unitsPerDose <= 0 || math.IsInf(*unitsPerDose, 0) || math.IsNaN(*unitsPerDose) {
+			respond.Validation(w, "units_per_dose must be greater than 0", respond.Field("units_per_dose", "must be greater than 0"))
+			return false
+		}
+		medication.UnitsPerDose = sql.NullFloat64{Float64: *unitsPerDose, Valid: true}
+	}
+	return true
+}
+
+// applyMedicationUpdate sets the fields req provides on medication. It
+// writes the error response and returns false if one is invalid.
+func applyMedicationUpdate(w http.ResponseWriter, db *database.DB, accountID int64, medication *models.Medication, req UpdateMedicationRequest) bool {
+	if req.Name != nil {
+		medication.Name = *req.Name
+	}
+	if req.Dosage != nil {
+		if *req.Dosage == "" {
+			medication.Dosage = sql.NullString{Valid: false}
+		} else {
+			medication.Dosage = sql.NullString{String: *req.Dosage, Valid: true}
+		}
+	}
+	if req.Frequency != nil {
+		if *req.Frequency == "" {
+			medication.Frequency = sql.NullString{Valid: false}
+		} else {
+			medication.Frequency = sql.NullString{String: *req.Frequency, Valid: true}
+		}
+	}
+	if req.StartDate != nil {
+		if *req.StartDate == "" {
+			medication.StartDate = sql.NullTime{Valid: false}
+		} else {
+			parsedDate, err := time.Parse("2006-01-02", *req.StartDate)
+			if err != nil {
+				respond.Validation(w, "Invalid start_date format, use YYYY-MM-DD", respond.Field("start_date", "invalid format, use YYYY-MM-DD"))
+				return false
+			}
+			medication.StartDate = sql.NullTime{Time: parsedDate, Valid: true}
+		}
+	}
+	if req.EndDate != nil {
+		if *req.EndDate == "" {
+			medication.EndDate = sql.NullTime{Valid: false}
+		} else {
+			parsedDate, err := time.Parse("2006-01-02", *req.EndDate)
+			if err != nil {
+				respond.Validation(w, "Invalid end_date format, use YYYY-MM-DD", respond.Field("end_date", "invalid format, use YYYY-MM-DD"))
+				return false
+			}
+			medication.EndDate = sql.NullTime{Time: parsedDate, Valid: true}
+		}
+	}
+	if req.Notes != nil {
+		if *req.Notes == "" {
+			medication.Notes = sql.NullString{Valid: false}
+		} else {
+			medication.Notes = sql.NullString{String: *req.Notes, Valid: true}
+		}
+	}
+	if req.IsActive != nil {
+		medication.IsActive = *req.IsActive
+	}
+	if req.DoseTimes != nil || req.ScheduledTime != nil {
+		var requested []string
+		if req.DoseTimes != nil {
+			requested = *req.DoseTimes
+		}
+		doseTimes, ok := medicationDoseTimes(w, requested, req.ScheduledTime)
+		if !ok {
+			return false
+		}
+		setDoseTimes(medication, doseTimes)
+	}
+	if req.TimeWindowMinutes != nil {
+		medication.TimeWindowMinutes = sql.NullInt64{Int64: *req.TimeWindowMinutes, Valid: *req.TimeWindowMinutes > 0}
+	}
+	if req.ReminderEnabled != nil {
+		medication.ReminderEnabled = *req.ReminderEnabled
+	}
+	if req.CatalogID != nil {
+		if *req.CatalogID == 0 {
+			medication.CatalogID = sql.NullInt64{}
+		} else {
+			entry, ok := catalogEntry(w, db, *req.CatalogID)
+			if !ok {
+				return false
+			}
+			medication.CatalogID = sql.NullInt64{Int64: entry.ID, Valid: true}
+		}
+	}
+	return setMedicationStock(w, db, accountID, medication, req.InventoryItemType, req.UnitsPerDose)
 }
 
 // HandleGetMedications returns a list of medications
 func HandleGetMedications(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -76,13 +248,13 @@ func HandleGetMedications(db *database.DB) http.HandlerFunc {
 		}
 
 		if err != nil {
-			http.Error(w, "Failed to retrieve medications", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve medications", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(medications); err != nil {
-			log.Printf("Failed to encode medications response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode medications response", "err", err)
 		}
 	}
 }
@@ -90,31 +262,54 @@ func HandleGetMedications(db *database.DB) http.HandlerFunc {
 // HandleCreateMedication creates a new medication
 func HandleCreateMedication(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		var req CreateMedicationRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
+		// A medication picked from the catalog is named after it unless
+		// the name was changed
+		var catalogID sql.NullInt64
+		if req.CatalogID != nil && *req.CatalogID != 0 {
+			entry, ok := catalogEntry(w, db, *req.CatalogID)
+			if !ok {
+				return
+			}
+			catalogID = sql.NullInt64{Int64: entry.ID, Valid: true}
+			if req.Name == "" {
+				req.Name = entry.DisplayName()
+			}
+		}
+
 		// Validate required fields
 		if req.Name == "" {
-			http.Error(w, "name is required", http.StatusBadRequest)
+			respond.Validation(w, "name is required", respond.Field("name", "is required"))
 			return
 		}
 
+		doseTimes, ok := medicationDoseTimes(w, req.DoseTimes, req.ScheduledTime)
+		if !ok {
+			return
+		}
+		var timeWindow sql.NullInt64
+		if req.TimeWindowMinutes != nil && *req.TimeWindowMinutes > 0 {
+			timeWindow = sql.NullInt64{Int64: *req.TimeWindowMinutes, Valid: true}
+		}
+
 		// Parse dates if provided
 		var startDate sql.NullTime
 		if req.StartDate != nil && *req.StartDate != "" {
 			parsedDate, err := time.Parse("2006-01-02", *req.StartDate)
 			if err != nil {
-				http.Error(w, "Invalid start_date format, use YYYY-MM-DD", http.StatusBadRequest)
+				respond.Validation(w, "Invalid start_date format, use YYYY-MM-DD", respond.Field("start_date", "invalid format, use YYYY-MM-DD"))
 				return
 			}
 			startDate = sql.NullTime{Time: parsedDate, Valid: true}
@@ -124,7 +319,7 @@ func HandleCreateMedication(db *database.DB) http.HandlerFunc {
 		if req.EndDate != nil && *req.EndDate != "" {
 			parsedDate, err := time.Parse("2006-01-02", *req.EndDate)
 			if err != nil {
-				http.Error(w, "Invalid end_date format, use YYYY-MM-DD", http.StatusBadRequest)
+				respond.Validation(w, "Invalid end_date format, use YYYY-MM-DD", respond.Field("end_date", "invalid format, use YYYY-MM-DD"))
 				return
 			}
 			endDate = sql.NullTime{Time: parsedDate, Valid: true}
@@ -151,15 +346,19 @@ func HandleCreateMedication(db *database.DB) http.HandlerFunc {
 			EndDate:           endDate,
 			IsActive:          isActive,
 			Notes:             nullString(req.Notes),
-			ScheduledTime:     nullString(req.ScheduledTime),
-			TimeWindowMinutes: nullInt64(req.TimeWindowMinutes),
+			TimeWindowMinutes: timeWindow,
 			ReminderEnabled:   reminderEnabled,
+			CatalogID:         catalogID,
 			AccountID:         accountID,
 		}
+		setDoseTimes(medication, doseTimes)
+		if !setMedicationStock(w, db, accountID, medication, req.InventoryItemType, req.UnitsPerDose) {
+			return
+		}
 
 		medicationRepo := repository.NewMedicationRepository(db)
 		if err := medicationRepo.Create(medication); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create medication: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to create medication: %v", err), http.StatusInternalServerError)
 			return
 		}
 
@@ -181,7 +380,7 @@ func HandleCreateMedication(db *database.DB) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(medication); err != nil {
-			log.Printf("Failed to encode medication response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode medication response", "err", err)
 		}
 	}
 }
@@ -189,17 +388,18 @@ func HandleCreateMedication(db *database.DB) http.HandlerFunc {
 // HandleGetMedication returns a single medication by ID
 func HandleGetMedication(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid medication ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid medication ID", http.StatusBadRequest)
 			return
 		}
 
@@ -207,16 +407,17 @@ func HandleGetMedication(db *database.DB) http.HandlerFunc {
 		medication, err := medicationRepo.GetByID(id, accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Medication not found", http.StatusNotFound)
+				respond.Error(w, "Medication not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
 			return
 		}
 
+		setVersionHeaders(w, medication.UpdatedAt)
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(medication); err != nil {
-			log.Printf("Failed to encode medication response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode medication response", "err", err)
 		}
 	}
 }
@@ -224,23 +425,23 @@ func HandleGetMedication(db *database.DB) http.HandlerFunc {
 // HandleUpdateMedication updates an existing medication
 func HandleUpdateMedication(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid medication ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid medication ID", http.StatusBadRequest)
 			return
 		}
 
 		var req UpdateMedicationRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
@@ -249,69 +450,30 @@ func HandleUpdateMedication(db *database.DB) http.HandlerFunc {
 		medication, err := medicationRepo.GetByID(id, accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Medication not found", http.StatusNotFound)
+				respond.Error(w, "Medication not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
 			return
 		}
-
-		// Update fields if provided
-		if req.Name != nil {
-			medication.Name = *req.Name
-		}
-		if req.Dosage != nil {
-			if *req.Dosage == "" {
-				medication.Dosage = sql.NullString{Valid: false}
-			} else {
-				medication.Dosage = sql.NullString{String: *req.Dosage, Valid: true}
-			}
-		}
-		if req.Frequency != nil {
-			if *req.Frequency == "" {
-				medication.Frequency = sql.NullString{Valid: false}
-			} else {
-				medication.Frequency = sql.NullString{String: *req.Frequency, Valid: true}
-			}
+		current := *medication
+		if !checkPreconditions(w, r, medication.UpdatedAt, true, func() interface{} { return &current }) {
+			return
 		}
-		if req.StartDate != nil {
-			if *req.StartDate == "" {
-				medication.StartDate = sql.NullTime{Valid: false}
-			} else {
-				parsedDate, err := time.Parse("2006-01-02", *req.StartDate)
-				if err != nil {
-					http.Error(w, "Invalid start_date format, use YYYY-MM-DD", http.StatusBadRequest)
-					return
-				}
-				medication.StartDate = sql.NullTime{Time: parsedDate, Valid: true}
-			}
+
+		if !applyMedicationUpdate(w, db, accountID, medication, req) {
+			return
 		}
-		if req.EndDate != nil {
-			if *req.EndDate == "" {
-				medication.EndDate = sql.NullTime{Valid: false}
-			} else {
-				parsedDate, err := time.Parse("2006-01-02", *req.EndDate)
-				if err != nil {
-					http.Error(w, "Invalid end_date format, use YYYY-MM-DD", http.StatusBadRequest)
+
+		// Update medication
+		if err := medicationRepo.Update(medication, accountID, current.UpdatedAt); err != nil {
+			if err == repository.ErrStale {
+				if latest, err := medicationRepo.GetByID(id, accountID); err == nil {
+					respondPreconditionFailed(w, latest.UpdatedAt, latest)
 					return
 				}
-				medication.EndDate = sql.NullTime{Time: parsedDate, Valid: true}
 			}
-		}
-		if req.Notes != nil {
-			if *req.Notes == "" {
-				medication.Notes = sql.NullString{Valid: false}
-			} else {
-				medication.Notes = sql.NullString{String: *req.Notes, Valid: true}
-			}
-		}
-		if req.IsActive != nil {
-			medication.IsActive = *req.IsActive
-		}
-
-		// Update medication
-		if err := medicationRepo.Update(medication, accountID); err != nil {
-			http.Error(w, "Failed to update medication", http.StatusInternalServerError)
+			respond.Error(w, "Failed to update medication", http.StatusInternalServerError)
 			return
 		}
 
@@ -329,27 +491,114 @@ func HandleUpdateMedication(db *database.DB) http.HandlerFunc {
 			r.UserAgent(),
 		)
 
+		setVersionHeaders(w, medication.UpdatedAt)
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(medication); err != nil {
-			log.Printf("Failed to encode medication response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode medication response", "err", err)
 		}
 	}
 }
 
-// HandleDeleteMedication soft-deletes a medication by setting is_active to false
+// HandleBulkUpdateMedications applies several medications' changes in one
+// transaction. Each is checked as PUT /api/medications/{id} checks it; if
+// any fails, none are saved and the results say which.
+func HandleBulkUpdateMedications(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req BulkUpdateMedicationsRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		seen := map[int64]bool{}
+		for i, update := range req.Updates {
+			if seen[update.ID] {
+				respond.Validation(w, "", respond.Field(fmt.Sprintf("updates[%d].id", i), "is repeated"))
+				return
+			}
+			seen[update.ID] = true
+		}
+
+		medicationRepo := repository.NewMedicationRepository(db)
+		results := make([]BulkItemResult, len(req.Updates))
+		medications := make([]*models.Medication, len(req.Updates))
+		for i, update := range req.Updates {
+			results[i] = BulkItemResult{ID: update.ID}
+			medication, err := medicationRepo.GetByID(update.ID, accountID)
+			if err != nil {
+				if err == repository.ErrNotFound {
+					results[i] = bulkFailed(update.ID, http.StatusNotFound, "Medication not found")
+				} else {
+					results[i] = bulkFailed(update.ID, http.StatusInternalServerError, "Failed to retrieve medication")
+				}
+				continue
+			}
+			if update.BaseUpdatedAt != nil && medication.UpdatedAt.Truncate(time.Second).After(*update.BaseUpdatedAt) {
+				results[i] = bulkFailed(update.ID, http.StatusPreconditionFailed, "This was changed by someone else after you loaded it. Reload to see their changes.")
+				results[i].Record = medication
+				continue
+			}
+			rec := &syncRecorder{header: http.Header{}}
+			if !applyMedicationUpdate(rec, db, accountID, medication, update.UpdateMedicationRequest) {
+				results[i] = bulkRecorded(update.ID, rec)
+				continue
+			}
+			medications[i] = medication
+		}
+		if bulkHasFailures(results) {
+			respondBulkFailed(w, results)
+			return
+		}
+
+		if err := medicationRepo.UpdateAll(medications, accountID); err != nil {
+			respondBulkError(w, err, results, "Medication not found", "Failed to update medication")
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		for i, medication := range medications {
+			_ = auditRepo.LogWithDetails(
+				sql.NullInt64{Int64: userID, Valid: true},
+				"update",
+				"medication",
+				sql.NullInt64{Int64: medication.ID, Valid: true},
+				map[string]interface{}{
+					"name": medication.Name,
+					"bulk": true,
+				},
+				r.RemoteAddr,
+				r.UserAgent(),
+			)
+			results[i].Status = BulkApplied
+			results[i].StatusCode = http.StatusOK
+			results[i].Record = medication
+		}
+
+		respondJSON(w, http.StatusOK, BulkResponse{Results: results})
+	}
+}
+
+// HandleDeleteMedication moves a medication and its dose history to the trash
 func HandleDeleteMedication(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid medication ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid medication ID", http.StatusBadRequest)
 			return
 		}
 
@@ -358,16 +607,21 @@ func HandleDeleteMedication(db *database.DB) http.HandlerFunc {
 		medication, err := medicationRepo.GetByID(id, accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Medication not found", http.StatusNotFound)
+				respond.Error(w, "Medication not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
 			return
 		}
 
-		// Hard delete medication (this will cascade delete all logs)
-		if err := medicationRepo.HardDelete(id, accountID); err != nil {
-			http.Error(w, "Failed to delete medication", http.StatusInternalServerError)
+		trashed := moveToTrash(w, db, &models.TrashItem{
+			AccountID: accountID,
+			ItemType:  repository.TrashMedication,
+			ItemID:    id,
+			Label:     medication.Name,
+			DeletedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}, "Medication not found")
+		if trashed == nil {
 			return
 		}
 
@@ -379,7 +633,8 @@ func HandleDeleteMedication(db *database.DB) http.HandlerFunc {
 			"medication",
 			sql.NullInt64{Int64: id, Valid: true},
 			map[string]interface{}{
-				"name": medication.Name,
+				"name":     medication.Name,
+				"trash_id": trashed.ID,
 			},
 			r.RemoteAddr,
 			r.UserAgent(),
@@ -392,23 +647,27 @@ func HandleDeleteMedication(db *database.DB) http.HandlerFunc {
 // HandleLogMedication creates a log entry for medication taken or missed
 func HandleLogMedication(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		medicationID, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid medication ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid medication ID", http.StatusBadRequest)
 			return
 		}
 
 		var req LogMedicationRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Late && !req.Taken {
+			respond.Validation(w, "A late dose must be logged as taken", respond.Field("late", "requires taken"))
 			return
 		}
 
@@ -417,10 +676,10 @@ func HandleLogMedication(db *database.DB) http.HandlerFunc {
 		medication, err := medicationRepo.GetByID(medicationID, accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Medication not found", http.StatusNotFound)
+				respond.Error(w, "Medication not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
 			return
 		}
 
@@ -429,12 +688,33 @@ func HandleLogMedication(db *database.DB) http.HandlerFunc {
 		if req.Timestamp != nil && *req.Timestamp != "" {
 			timestamp, err = time.Parse(time.RFC3339, *req.Timestamp)
 			if err != nil {
-				http.Error(w, "Invalid timestamp format, use RFC3339", http.StatusBadRequest)
+				respond.Validation(w, "Invalid timestamp format, use RFC3339", respond.Field("timestamp", "invalid format, use RFC3339"))
 				return
 			}
 		} else {
 			timestamp = time.Now()
 		}
+		timestamp = timestamp.UTC()
+
+		// The log is for the dose named, or else the nearest one still open
+		var scheduledFor sql.NullTime
+		if req.ScheduledFor != nil && *req.ScheduledFor != "" {
+			t, err := time.Parse(time.RFC3339, *req.ScheduledFor)
+			if err != nil {
+				respond.Validation(w, "Invalid scheduled_for format, use RFC3339", respond.Field("scheduled_for", "invalid format, use RFC3339"))
+				return
+			}
+			scheduledFor = sql.NullTime{Time: t.UTC(), Valid: true}
+		} else if len(medication.DoseTimes) > 0 {
+			doses, err := medicationDoses(medicationRepo, medication, userLocation(db, userID), timestamp.Add(-24*time.Hour), timestamp.Add(24*time.Hour), time.Now())
+			if err != nil {
+				respond.Error(w, "Failed to retrieve medication logs", http.StatusInternalServerError)
+				return
+			}
+			if due, err := services.DoseFor(doses, timestamp); err == nil {
+				scheduledFor = sql.NullTime{Time: due.UTC(), Valid: true}
+			}
+		}
 
 		// Create medication log
 		medLog := &models.MedicationLog{
@@ -442,11 +722,17 @@ func HandleLogMedication(db *database.DB) http.HandlerFunc {
 			LoggedBy:     sql.NullInt64{Int64: userID, Valid: true},
 			Timestamp:    timestamp,
 			Taken:        req.Taken,
+			Late:         req.Late,
 			Notes:        nullString(req.Notes),
+			ScheduledFor: scheduledFor,
+		}
+		if req.Taken && scheduledFor.Valid && timestamp.After(scheduledFor.Time.Add(services.DoseWindow(medication))) {
+			medLog.Late = true
 		}
 
-		if err := medicationRepo.CreateLog(medLog); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create medication log: %v", err), http.StatusInternalServerError)
+		usage, err := medicationRepo.RecordLog(medLog, medication, accountID, userID)
+		if err != nil {
+			respond.Error(w, fmt.Sprintf("Failed to create medication log: %v", err), http.StatusInternalServerError)
 			return
 		}
 
@@ -461,33 +747,71 @@ func HandleLogMedication(db *database.DB) http.HandlerFunc {
 				"medication_id":   medicationID,
 				"medication_name": medication.Name,
 				"taken":           req.Taken,
+				"late":            medLog.Late,
 			},
 			r.RemoteAddr,
 			r.UserAgent(),
 		)
 
+		publishLiveEvent(accountID, services.LiveMedicationLogged, map[string]interface{}{
+			"medication_id":   medicationID,
+			"medication_name": medication.Name,
+			"log_id":          medLog.ID,
+			"timestamp":       timestamp,
+			"taken":           req.Taken,
+			"late":            medLog.Late,
+			"logged_by":       userID,
+		})
+		if usage != nil {
+			for itemType, before := range usage.QuantitiesBefore {
+				if item, err := getInventoryItemByType(db, accountID, itemType); err == nil {
+					emitLowStockIfCrossed(db, accountID, item, before)
+					publishInventoryAdjusted(accountID, item, before)
+				}
+			}
+		}
+		// A dose already found missed by the background check has been
+		// reported
+		report := !req.Taken
+		if report && medLog.ScheduledFor.Valid {
+			if report, err = medicationRepo.RecordMissedDose(medicationID, medLog.ScheduledFor.Time); err != nil {
+				middleware.Log(r.Context()).Error("Failed to record missed dose", "medication_id", medicationID, "err", err)
+				report = true
+			}
+		}
+		if report {
+			emitWebhookEvent(db, accountID, services.EventMedicationMissed, map[string]interface{}{
+				"medication_id":   medicationID,
+				"medication_name": medication.Name,
+				"log_id":          medLog.ID,
+				"timestamp":       timestamp,
+				"notes":           medLog.Notes.String,
+			})
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(medLog); err != nil {
-			log.Printf("Failed to encode medication log response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode medication log response", "err", err)
 		}
 	}
 }
 
-// HandleGetMedicationLogs returns medication logs with optional filtering
+// HandleGetMedicationLogs returns a page of a medication's logs with optional filtering
 func HandleGetMedicationLogs(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		medicationID, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid medication ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid medication ID", http.StatusBadRequest)
 			return
 		}
 
@@ -496,191 +820,493 @@ func HandleGetMedicationLogs(db *database.DB) http.HandlerFunc {
 		_, err = medicationRepo.GetByID(medicationID, accountID)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Medication not found", http.StatusNotFound)
+				respond.Error(w, "Medication not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
 			return
 		}
 
-		// Parse query parameters
-		startDate := r.URL.Query().Get("start_date")
-		endDate := r.URL.Query().Get("end_date")
-		limitStr := r.URL.Query().Get("limit")
-		offsetStr := r.URL.Query().Get("offset")
-
-		// Set defaults
-		limit := 50
-		offset := 0
-
-		if limitStr != "" {
-			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-				limit = l
-			}
-		}
-		if offsetStr != "" {
-			if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-				offset = o
-			}
+		page, err := parsePageRequest(r)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-
-		var logs []*models.MedicationLog
-
-		// Filter by date range if provided
-		if startDate != "" && endDate != "" {
-			start, err1 := time.Parse("2006-01-02", startDate)
-			end, err2 := time.Parse("2006-01-02", endDate)
-			if err1 != nil || err2 != nil {
-				http.Error(w, "Invalid date format, use YYYY-MM-DD", http.StatusBadRequest)
-				return
-			}
-			logs, err = medicationRepo.ListLogsByDateRange(medicationID, start, end, limit, offset)
-		} else {
-			logs, err = medicationRepo.ListLogs(medicationID, limit, offset)
+		start, end, err := parseListDateRange(r, userLocation(db, userID))
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
+		result, err := medicationRepo.ListLogsPage(medicationID, start, end, page)
 		if err != nil {
-			http.Error(w, "Failed to retrieve medication logs", http.StatusInternalServerError)
+			listPageError(w, err, "Failed to retrieve medication logs")
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(logs); err != nil {
-			log.Printf("Failed to encode medication logs response: %v", err)
-		}
+		respondJSON(w, http.StatusOK, newListResponse(result, func(l *models.MedicationLog) *models.MedicationLog { return l }))
 	}
 }
 
 // HandleGetTodaySchedule returns medications scheduled for today
 func HandleGetTodaySchedule(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		medicationRepo := repository.NewMedicationRepository(db)
 		medications, err := medicationRepo.ListActive(accountID)
 		if err != nil {
-			http.Error(w, "Failed to retrieve medications", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve medications", http.StatusInternalServerError)
 			return
 		}
 
 		// Return empty state HTML if no medications
 		if len(medications) == 0 {
-			w.Header().Set("Content-Type", "text/html")
-			_, _ = w.Write([]byte(`
-				<div style="text-align: center; padding: 2rem; color: var(--pico-muted-color);">
-					<p>No medications scheduled for today.</p>
-				</div>
-			`))
+			renderFragment(w, r, http.StatusOK, "empty.html", web.EmptyState{Message: "No medications scheduled for today."})
 			return
 		}
 
 		// Return as JSON for now
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(medications); err != nil {
-			log.Printf("Failed to encode medications response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode medications response", "err", err)
+		}
+	}
+}
+
+const (
+	defaultAdherenceDays = 30
+	maxAdherenceDays     = 365
+)
+
+// MedicationDoseResponse is one of a medication's expected doses
+type MedicationDoseResponse struct {
+	Due          time.Time  `json:"due"`
+	Time         string     `json:"time"`   // HH:MM in the user's timezone
+	Status       string     `json:"status"` // taken, late, missed, due or upcoming
+	LogID        *int64     `json:"log_id,omitempty"`
+	LoggedAt     *time.Time `json:"logged_at,omitempty"`
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+}
+
+// DoseAdherenceResponse counts doses by what became of them
+type DoseAdherenceResponse struct {
+	Expected   int     `json:"expected"`
+	Taken      int     `json:"taken"` // On time or late
+	OnTime     int     `json:"on_time"`
+	Late       int     `json:"late"`
+	Missed     int     `json:"missed"`
+	Pending    int     `json:"pending"`      // Not yet due or still within their window
+	Rate       float64 `json:"rate"`         // Percent of taken and missed doses that were taken
+	OnTimeRate float64 `json:"on_time_rate"` // Percent of taken and missed doses that were taken on time
+}
+
+// MedicationAdherenceResponse is a medication's adherence overall and for
+// each of its dose times
+type MedicationAdherenceResponse struct {
+	MedicationID int64  `json:"medication_id"`
+	Name         string `json:"name"`
+	DoseAdherenceResponse
+	Slots []DoseSlotAdherence `json:"slots"`
+}
+
+// DoseSlotAdherence is adherence for one time of day
+type DoseSlotAdherence struct {
+	Time string `json:"time"`
+	DoseAdherenceResponse
+}
+
+func toDoseAdherenceResponse(a services.DoseAdherence) DoseAdherenceResponse {
+	return DoseAdherenceResponse{
+		Expected:   a.Expected,
+		Taken:      a.Taken,
+		OnTime:     a.Taken - a.Late,
+		Late:       a.Late,
+		Missed:     a.Missed,
+		Pending:    a.Pending,
+		Rate:       math.Round(a.Rate()*10) / 10,
+		OnTimeRate: math.Round(a.OnTimeRate()*10) / 10,
+	}
+}
+
+func toMedicationDoseResponse(d services.MedicationDose, loc *time.Location) MedicationDoseResponse {
+	resp := MedicationDoseResponse{Due: d.Due, Time: d.Due.In(loc).Format("15:04"), Status: d.Status}
+	if d.Log != nil {
+		resp.LogID = &d.Log.ID
+		resp.LoggedAt = &d.Log.Timestamp
+	}
+	if !d.SnoozedUntil.IsZero() && d.Status == services.DoseDue {
+		resp.SnoozedUntil = &d.SnoozedUntil
+	}
+	return resp
+}
+
+// medicationDoses lays out a medication's doses due in [from, until) with
+// their logs and snoozes. Logs are read from a little either side so doses
+// near the edges still find theirs.
+func medicationDoses(repo *repository.MedicationRepository, med *models.Medication, loc *time.Location, from, until, now time.Time) ([]services.MedicationDose, error) {
+	if len(med.DoseTimes) == 0 {
+		return nil, nil
+	}
+	logs, err := repo.ListLogsBetween(med.ID, from.Add(-24*time.Hour).UTC(), until.Add(24*time.Hour).UTC())
+	if err != nil {
+		return nil, err
+	}
+	snoozes, err := repo.ListSnoozesBetween(med.ID, from.UTC(), until.UTC())
+	if err != nil {
+		return nil, err
+	}
+	doses := services.MedicationDoses(services.NewMedicationSchedule(med, loc), services.DoseWindow(med), logs, from, until, now)
+	services.ApplySnoozes(doses, snoozes)
+	return doses, nil
+}
+
+// setTakenToday marks which medications were taken today in loc. One with
+// dose times counts as taken once every dose due today is; one without,
+// once any dose is logged as taken.
+func setTakenToday(db *database.DB, medications []*models.Medication, loc *time.Location) {
+	repo := repository.NewMedicationRepository(db)
+	now := time.Now()
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	tomorrow := today.AddDate(0, 0, 1)
+	for _, med := range medications {
+		doses, err := medicationDoses(repo, med, loc, today, tomorrow, now)
+		if err == nil && len(doses) > 0 {
+			med.DosesToday = len(doses)
+			med.DosesTakenToday = 0
+			for _, d := range doses {
+				if d.Status == services.DoseTaken || d.Status == services.DoseLate {
+					med.DosesTakenToday++
+				}
+			}
+			med.TakenToday = med.DosesTakenToday == med.DosesToday
+			continue
 		}
+
+		var count int
+		_ = db.QueryRow(`
+			SELECT COUNT(*) FROM medication_logs
+			WHERE medication_id = ?
+			AND timestamp >= ? AND timestamp < ?
+			AND taken = TRUE
+		`, med.ID, today.UTC(), tomorrow.UTC()).Scan(&count)
+		med.TakenToday = count > 0
 	}
 }
 
-// HandleGetAdherence returns adherence statistics
+// HandleGetMedicationDoses lists a medication's doses due on one day (date,
+// default today, in the user's timezone) and what became of each
+func HandleGetMedicationDoses(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		medicationID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid medication ID", http.StatusBadRequest)
+			return
+		}
+
+		medicationRepo := repository.NewMedicationRepository(db)
+		medication, err := medicationRepo.GetByID(medicationID, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Medication not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
+			return
+		}
+
+		loc := userLocation(db, userID)
+		now := time.Now()
+		local := now.In(loc)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		if v := r.URL.Query().Get("date"); v != "" {
+			day, err = time.ParseInLocation("2006-01-02", v, loc)
+			if err != nil {
+				respond.Validation(w, "Invalid date format, use YYYY-MM-DD", respond.Field("date", "invalid format, use YYYY-MM-DD"))
+				return
+			}
+		}
+
+		doses, err := medicationDoses(medicationRepo, medication, loc, day, day.AddDate(0, 0, 1), now)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve medication logs", http.StatusInternalServerError)
+			return
+		}
+		response := make([]MedicationDoseResponse, 0, len(doses))
+		for _, d := range doses {
+			response = append(response, toMedicationDoseResponse(d, loc))
+		}
+		respondJSON(w, http.StatusOK, response)
+	}
+}
+
+// MedicationSnoozeResponse is a dose's snoozed reminder
+type MedicationSnoozeResponse struct {
+	MedicationID int64     `json:"medication_id"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	Time         string    `json:"time"` // HH:MM of the dose in the user's timezone
+	SnoozedUntil time.Time `json:"snoozed_until"`
+	SnoozeCount  int       `json:"snooze_count"`
+}
+
+// HandleSnoozeMedicationDose pushes a due dose's reminder back by minutes,
+// no further than the end of its window. The dose is the one scheduled_for
+// names, or else the one due now nearest its time.
+func HandleSnoozeMedicationDose(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		medicationID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid medication ID", http.StatusBadRequest)
+			return
+		}
+
+		var req SnoozeMedicationDoseRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Minutes < 1 || req.Minutes > services.MaxSnoozeMinutes {
+			msg := fmt.Sprintf("must be between 1 and %d", services.MaxSnoozeMinutes)
+			respond.Validation(w, "minutes "+msg, respond.Field("minutes", msg))
+			return
+		}
+		var scheduledFor time.Time
+		if req.ScheduledFor != nil && *req.ScheduledFor != "" {
+			scheduledFor, err = time.Parse(time.RFC3339, *req.ScheduledFor)
+			if err != nil {
+				respond.Validation(w, "Invalid scheduled_for format, use RFC3339", respond.Field("scheduled_for", "invalid format, use RFC3339"))
+				return
+			}
+		}
+
+		medicationRepo := repository.NewMedicationRepository(db)
+		medication, err := medicationRepo.GetByID(medicationID, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Medication not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve medication", http.StatusInternalServerError)
+			return
+		}
+		if len(medication.DoseTimes) == 0 {
+			respond.Validation(w, "Medication has no dose times to snooze", respond.Field("dose_times", "required to snooze a dose"))
+			return
+		}
+
+		loc := userLocation(db, userID)
+		now := time.Now()
+		doses, err := medicationDoses(medicationRepo, medication, loc, now.Add(-24*time.Hour), now.Add(24*time.Hour), now)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve medication logs", http.StatusInternalServerError)
+			return
+		}
+
+		var dose *services.MedicationDose
+		for i := range doses {
+			d := &doses[i]
+			if !scheduledFor.IsZero() {
+				if d.Due.Equal(scheduledFor) {
+					dose = d
+				}
+				continue
+			}
+			if d.Status == services.DoseDue && (dose == nil || d.Due.Sub(now).Abs() < dose.Due.Sub(now).Abs()) {
+				dose = d
+			}
+		}
+		if dose == nil {
+			respond.Error(w, "No dose is due to snooze", http.StatusNotFound)
+			return
+		}
+
+		until, err := services.SnoozeUntil(*dose, services.DoseWindow(medication), req.Minutes, now)
+		if err != nil {
+			respond.Error(w, "Cannot snooze: "+err.Error(), http.StatusConflict)
+			return
+		}
+		snooze, err := medicationRepo.SnoozeDose(medicationID, dose.Due, until, userID)
+		if err != nil {
+			respond.Error(w, "Failed to snooze dose", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"snooze_medication_dose",
+			"medication",
+			sql.NullInt64{Int64: medicationID, Valid: true},
+			map[string]interface{}{
+				"medication_name": medication.Name,
+				"scheduled_for":   snooze.ScheduledFor,
+				"snoozed_until":   snooze.SnoozedUntil,
+				"snooze_count":    snooze.SnoozeCount,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, MedicationSnoozeResponse{
+			MedicationID: medicationID,
+			ScheduledFor: snooze.ScheduledFor,
+			Time:         snooze.ScheduledFor.In(loc).Format("15:04"),
+			SnoozedUntil: snooze.SnoozedUntil,
+			SnoozeCount:  snooze.SnoozeCount,
+		})
+	}
+}
+
+// HandleGetAdherence reports dose by dose adherence for each active
+// medication with dose times over the last days days (default 30, at most
+// 365) including today, overall and for each time of day
 func HandleGetAdherence(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// For now, return empty data
-		_ = r.URL.Query().Get("days") // Unused for now
-		response := map[string]interface{}{
-			"medications": []string{},
-			"taken":       []int{},
-			"missed":      []int{},
+		days := defaultAdherenceDays
+		if v := r.URL.Query().Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxAdherenceDays {
+				msg := fmt.Sprintf("must be between 1 and %d", maxAdherenceDays)
+				respond.Validation(w, "Invalid days: "+msg, respond.Field("days", msg))
+				return
+			}
+			days = n
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Printf("Failed to encode adherence response: %v", err)
+		medicationRepo := repository.NewMedicationRepository(db)
+		medications, err := medicationRepo.ListActive(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve medications", http.StatusInternalServerError)
+			return
 		}
+
+		loc := userLocation(db, userID)
+		now := time.Now()
+		local := now.In(loc)
+		until := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+		from := until.AddDate(0, 0, -days)
+
+		results := []MedicationAdherenceResponse{}
+		for _, med := range medications {
+			if len(med.DoseTimes) == 0 {
+				continue
+			}
+			doses, err := medicationDoses(medicationRepo, med, loc, from, until, now)
+			if err != nil {
+				respond.Error(w, "Failed to retrieve medication logs", http.StatusInternalServerError)
+				return
+			}
+
+			var total services.DoseAdherence
+			bySlot := make(map[string]*services.DoseAdherence)
+			for _, d := range doses {
+				total.Add(d)
+				slot := d.Due.In(loc).Format("15:04")
+				if bySlot[slot] == nil {
+					bySlot[slot] = &services.DoseAdherence{}
+				}
+				bySlot[slot].Add(d)
+			}
+
+			result := MedicationAdherenceResponse{
+				MedicationID:          med.ID,
+				Name:                  med.Name,
+				DoseAdherenceResponse: toDoseAdherenceResponse(total),
+				Slots:                 []DoseSlotAdherence{},
+			}
+			slots := make([]string, 0, len(bySlot))
+			for slot := range bySlot {
+				slots = append(slots, slot)
+			}
+			sort.Strings(slots)
+			for _, slot := range slots {
+				result.Slots = append(result.Slots, DoseSlotAdherence{Time: slot, DoseAdherenceResponse: toDoseAdherenceResponse(*bySlot[slot])})
+			}
+			results = append(results, result)
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"days":        days,
+			"from":        from,
+			"medications": results,
+		})
 	}
 }
 
 // HandleGetDailySchedule returns HTML for today's medication schedule
 func HandleGetDailySchedule(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
+		p := i18n.FromContext(r.Context())
 		medicationRepo := repository.NewMedicationRepository(db)
 		activeMeds, err := medicationRepo.ListActive(accountID)
 		if err != nil || len(activeMeds) == 0 {
-			w.Header().Set("Content-Type", "text/html")
-			_, _ = w.Write([]byte(`
-				<div style="text-align: center; padding: 2rem; color: var(--pico-muted-color);">
-					<p>No active medications.</p>
-				</div>
-			`))
+			renderFragment(w, r, http.StatusOK, "empty.html", web.EmptyState{Message: "No active medications."})
 			return
 		}
 
-		// Check which medications were taken today
-		for _, med := range activeMeds {
-			var count int
-			_ = db.QueryRow(`
-				SELECT COUNT(*) FROM medication_logs
-				WHERE medication_id = ?
-				AND DATE(timestamp) = DATE('now')
-				AND taken = 1
-			`, med.ID).Scan(&count)
-			med.TakenToday = count > 0
-		}
-
-		// Build HTML
-		html := `<div style="display: flex; flex-direction: column; gap: 0.5rem;">`
-		for _, med := range activeMeds {
-			status := "⚠️ Not taken"
-			statusColor := "var(--pico-warning)"
-			if med.TakenToday {
-				status = "✓ Taken"
-				statusColor = "var(--pico-success)"
-			}
+		setTakenToday(db, activeMeds, userLocation(db, userID))
 
-			// Extract string values from NullString
-			dosage := "N/A"
-			if med.Dosage.Valid {
-				dosage = med.Dosage.String
+		type scheduleItem struct {
+			Name      string
+			Dosage    string
+			Frequency string
+			Taken     bool
+			Status    string
+		}
+		items := make([]scheduleItem, 0, len(activeMeds))
+		for _, med := range activeMeds {
+			item := scheduleItem{
+				Name:      med.Name,
+				Dosage:    nullStringValue(med.Dosage, p.T("N/A")),
+				Frequency: nullStringValue(med.Frequency, p.T("N/A")),
+				Taken:     med.TakenToday,
+				Status:    "⚠️ " + p.T("Not taken"),
 			}
-			frequency := "N/A"
-			if med.Frequency.Valid {
-				frequency = med.Frequency.String
+			if med.TakenToday {
+				item.Status = "✓ " + p.T("Taken")
+			} else if med.DosesToday > 0 {
+				item.Status = p.T("%d of %d doses taken", med.DosesTakenToday, med.DosesToday)
 			}
-
-			html += fmt.Sprintf(`
-				<div style="display: flex; justify-content: space-between; align-items: center; padding: 0.5rem; border: 1px solid var(--pico-muted-border-color); border-radius: var(--pico-border-radius);">
-					<div>
-						<strong>%s</strong><br>
-						<small>%s • %s</small>
-					</div>
-					<div style="color: %s; font-weight: bold;">
-						%s
-					</div>
-				</div>
-			`, med.Name, dosage, frequency, statusColor, status)
+			items = append(items, item)
 		}
-		html += `</div>`
-
-		w.Header().Set("Content-Type", "text/html")
-		_, _ = w.Write([]byte(html))
+		renderFragment(w, r, http.StatusOK, "daily_schedule.html", items)
 	}
 }
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// MilestonesResponse is the account's injection streaks, its courses'
+// progress and the milestones reached so far
+type MilestonesResponse struct {
+	CurrentStreak int                      `json:"current_streak"` // Days in a row with an injection, up to today
+	LongestStreak int                      `json:"longest_streak"`
+	InjectedToday bool                     `json:"injected_today"`
+	Courses       []CourseProgressResponse `json:"courses"`
+	Milestones    []MilestoneResponse      `json:"milestones"` // Newest first
+	Timezone      string                   `json:"timezone"`   // Days are counted in this zone
+}
+
+// CourseProgressResponse is how far a course has come
+type CourseProgressResponse struct {
+	CourseID        int64      `json:"course_id"`
+	Name            string     `json:"name"`
+	IsActive        bool       `json:"is_active"`
+	TotalInjections int        `json:"total_injections"`
+	NextMilestone   int        `json:"next_milestone"`         // Injection count of the next milestone
+	HalfwayDate     *time.Time `json:"halfway_date,omitempty"` // When the course has an expected end
+}
+
+// MilestoneResponse is a point a course reached
+type MilestoneResponse struct {
+	Type       string    `json:"type"` // injections or halfway
+	CourseID   int64     `json:"course_id"`
+	CourseName string    `json:"course_name"`
+	Count      int       `json:"count,omitempty"` // Which injection, for injection milestones
+	Title      string    `json:"title"`
+	ReachedAt  time.Time `json:"reached_at"`
+}
+
+func toMilestoneResponse(m services.Milestone) MilestoneResponse {
+	return MilestoneResponse{
+		Type:       m.Kind,
+		CourseID:   m.CourseID,
+		CourseName: m.CourseName,
+		Count:      m.Count,
+		Title:      m.Title(),
+		ReachedAt:  m.ReachedAt,
+	}
+}
+
+// loadCourseProgress reads the account's courses, oldest first, with the
+// times of their injections. Voided injections don't count.
+func loadCourseProgress(db *database.DB, accountID int64) ([]services.CourseProgress, error) {
+	rows, err := db.Query(`SELECT id, name, start_date, expected_end_date, actual_end_date, is_active
+		FROM courses WHERE account_id = ? ORDER BY start_date, id`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query courses: %w", err)
+	}
+	defer rows.Close()
+
+	var courses []services.CourseProgress
+	index := map[int64]int{}
+	for rows.Next() {
+		var c services.CourseProgress
+		var expectedEnd, end sql.NullTime
+		if err := rows.Scan(&c.CourseID, &c.Name, &c.Start, &expectedEnd, &end, &c.IsActive); err != nil {
+			return nil, fmt.Errorf("failed to scan course: %w", err)
+		}
+		if expectedEnd.Valid {
+			c.ExpectedEnd = expectedEnd.Time
+		}
+		if end.Valid {
+			c.End = end.Time
+		}
+		index[c.CourseID] = len(courses)
+		courses = append(courses, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read courses: %w", err)
+	}
+
+	rows, err = db.Query(`SELECT i.course_id, i.timestamp FROM injections i
+		JOIN courses c ON c.id = i.course_id
+		WHERE c.account_id = ? AND i.voided_at IS NULL
+		ORDER BY i.timestamp, i.id`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query injections: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var courseID int64
+		var ts time.Time
+		if err := rows.Scan(&courseID, &ts); err != nil {
+			return nil, fmt.Errorf("failed to scan injection: %w", err)
+		}
+		if i, ok := index[courseID]; ok {
+			courses[i].Injections = append(courses[i].Injections, ts)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read injections: %w", err)
+	}
+	return courses, nil
+}
+
+// HandleGetMilestones returns the account's current and longest injection
+// streaks, how many injections each course has had and the milestones
+// reached along the way, such as a course's 50th injection or its halfway
+// point
+func HandleGetMilestones(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		courses, err := loadCourseProgress(db, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve courses", http.StatusInternalServerError)
+			return
+		}
+
+		loc := userLocation(db, userID)
+		now := time.Now()
+		today := now.In(loc).Format("2006-01-02")
+		resp := MilestonesResponse{
+			Courses:    []CourseProgressResponse{},
+			Milestones: []MilestoneResponse{},
+			Timezone:   loc.String(),
+		}
+		resp.CurrentStreak, resp.LongestStreak = services.InjectionStreaks(courses, now, loc)
+
+		var milestones []services.Milestone
+		for _, c := range courses {
+			progress := CourseProgressResponse{
+				CourseID:        c.CourseID,
+				Name:            c.Name,
+				IsActive:        c.IsActive,
+				TotalInjections: len(c.Injections),
+				NextMilestone:   services.NextInjectionMilestone(len(c.Injections)),
+			}
+			if half := services.HalfwayDate(c, loc); !half.IsZero() {
+				progress.HalfwayDate = &half
+			}
+			resp.Courses = append(resp.Courses, progress)
+
+			for _, t := range c.Injections {
+				if t.In(loc).Format("2006-01-02") == today {
+					resp.InjectedToday = true
+				}
+			}
+			milestones = append(milestones, services.CourseMilestones(c, now, loc)...)
+		}
+
+		sort.SliceStable(milestones, func(i, j int) bool {
+			return milestones[i].ReachedAt.After(milestones[j].ReachedAt)
+		})
+		for _, m := range milestones {
+			resp.Milestones = append(resp.Milestones, toMilestoneResponse(m))
+		}
+
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+)
+
+// maxMissedInjectionDays caps how far back missed doses are listed
+const maxMissedInjectionDays = 3650
+
+// maxMissedInjections caps how many missed doses are listed at once
+const maxMissedInjections = 500
+
+// MissedInjectionResponse is a dose that fell due and wasn't logged
+type MissedInjectionResponse struct {
+	ID          int64      `json:"id"`
+	CourseID    int64      `json:"course_id"`
+	CourseName  string     `json:"course_name"`
+	DueAt       time.Time  `json:"due_at"`
+	Reason      string     `json:"reason,omitempty"` // Empty until someone says why it was skipped
+	Note        string     `json:"note,omitempty"`
+	AnnotatedBy string     `json:"annotated_by,omitempty"`
+	AnnotatedAt *time.Time `json:"annotated_at,omitempty"`
+}
+
+// AnnotateMissedInjectionRequest says why a missed dose was skipped
+type AnnotateMissedInjectionRequest struct {
+	Reason string `json:"reason" validate:"required,oneof=clinic_instruction travel forgot side_effects out_of_supply other"`
+	Note   string `json:"note,omitempty" validate:"max=500"`
+}
+
+// toMissedInjectionResponse shows a missed dose with times in loc
+func toMissedInjectionResponse(m *models.MissedInjection, loc *time.Location) MissedInjectionResponse {
+	resp := MissedInjectionResponse{
+		ID:          m.ID,
+		CourseID:    m.CourseID,
+		CourseName:  m.CourseName,
+		DueAt:       m.DueAt.In(loc),
+		Reason:      m.Reason.String,
+		Note:        m.Note.String,
+		AnnotatedBy: m.AnnotatedByUsername,
+	}
+	if m.AnnotatedAt.Valid {
+		at := m.AnnotatedAt.Time.In(loc)
+		resp.AnnotatedAt = &at
+	}
+	return resp
+}
+
+// HandleGetMissedInjections lists the account's missed doses, most recently
+// due first. ?days (default 90) sets how far back to look, ?course_id
+// narrows it to one course and ?unannotated=true leaves out those with a
+// reason.
+func HandleGetMissedInjections(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		days := 90
+		if v := query.Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxMissedInjectionDays {
+				respond.Validation(w, "", respond.Field("days", fmt.Sprintf("must be 1 to %d", maxMissedInjectionDays)))
+				return
+			}
+			days = n
+		}
+		filter := repository.MissedInjectionFilter{
+			Start:           time.Now().AddDate(0, 0, -days),
+			UnannotatedOnly: query.Get("unannotated") == "true",
+		}
+		if v := query.Get("course_id"); v != "" {
+			var err error
+			filter.CourseID, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				respond.Validation(w, "Invalid course_id", respond.Field("course_id", "is invalid"))
+				return
+			}
+		}
+
+		missed, err := repository.NewMissedInjectionRepository(db).List(accountID, filter, maxMissedInjections)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve missed injections", http.StatusInternalServerError)
+			return
+		}
+
+		loc := userLocation(db, userID)
+		resp := make([]MissedInjectionResponse, 0, len(missed))
+		for _, m := range missed {
+			resp = append(resp, toMissedInjectionResponse(m, loc))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleAnnotateMissedInjection records why a missed dose was skipped
+func HandleAnnotateMissedInjection(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid missed injection ID", http.StatusBadRequest)
+			return
+		}
+		var req AnnotateMissedInjectionRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		repo := repository.NewMissedInjectionRepository(db)
+		missed := &models.MissedInjection{
+			ID:          id,
+			Reason:      sql.NullString{String: req.Reason, Valid: true},
+			AnnotatedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if note := strings.TrimSpace(req.Note); note != "" {
+			missed.Note = sql.NullString{String: note, Valid: true}
+		}
+		if err := repo.Annotate(missed, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Missed injection not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to annotate missed injection", http.StatusInternalServerError)
+			return
+		}
+		missed, err = repo.GetByID(id, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve missed injection", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"annotate",
+			"missed_injection",
+			sql.NullInt64{Int64: id, Valid: true},
+			map[string]interface{}{"course_id": missed.CourseID, "reason": req.Reason},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, toMissedInjectionResponse(missed, userLocation(db, userID)))
+	}
+}
+
+// HandleDeleteMissedInjection dismisses a missed dose, e.g. one that was
+// given but logged too late to clear it
+func HandleDeleteMissedInjection(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid missed injection ID", http.StatusBadRequest)
+			return
+		}
+		if err := repository.NewMissedInjectionRepository(db).Delete(id, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Missed injection not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete missed injection", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"missed_injection",
+			sql.NullInt64{Int64: id, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
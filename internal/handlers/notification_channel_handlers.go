@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// NotificationChannelRequest is the payload for creating or updating a channel
+type NotificationChannelRequest struct {
+	Type      string                 `json:"type" validate:"max=20"`
+	Name      string                 `json:"name" validate:"max=100"`
+	Config    map[string]interface{} `json:"config"`
+	IsEnabled *bool                  `json:"is_enabled"`
+}
+
+// NotificationChannelResponse is a channel with its secrets redacted
+type NotificationChannelResponse struct {
+	ID         int64                  `json:"id"`
+	Type       string                 `json:"type"`
+	Name       string                 `json:"name"`
+	Config     map[string]interface{} `json:"config"`
+	IsEnabled  bool                   `json:"is_enabled"`
+	LastSentAt *time.Time             `json:"last_sent_at,omitempty"`
+	LastError  string                 `json:"last_error,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+}
+
+func toNotificationChannelResponse(c *models.NotificationChannel) NotificationChannelResponse {
+	resp := NotificationChannelResponse{
+		ID:        c.ID,
+		Type:      c.Type,
+		Name:      c.Name,
+		Config:    services.RedactChannelConfig(c.Config),
+		IsEnabled: c.IsEnabled,
+		LastError: c.LastError.String,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+	if c.LastSentAt.Valid {
+		resp.LastSentAt = &c.LastSentAt.Time
+	}
+	return resp
+}
+
+// validateNotificationChannel checks that the channel's config can produce a sender
+func validateNotificationChannel(channel *models.NotificationChannel) error {
+	_, err := services.NewChannelSender(channel, http.DefaultClient)
+	return err
+}
+
+// HandleGetNotificationChannels lists the account's notification channels
+func HandleGetNotificationChannels(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		channelRepo := repository.NewNotificationChannelRepository(db)
+		channels, err := channelRepo.List(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve notification channels", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]NotificationChannelResponse, 0, len(channels))
+		for _, c := range channels {
+			resp = append(resp, toNotificationChannelResponse(c))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// HandleCreateNotificationChannel adds a notification channel (owner only)
+func HandleCreateNotificationChannel(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		role := middleware.GetRole(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if role != "owner" {
+			respond.Error(w, "Forbidden: only account owner can manage notification channels", http.StatusForbidden)
+			return
+		}
+
+		var req NotificationChannelRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		if req.Name == "" {
+			respond.Validation(w, "name is required", respond.Field("name", "is required"))
+			return
+		}
+		if !services.IsValidChannelType(req.Type) {
+			respond.Validation(w, "type must be one of: ntfy, gotify, webhook", respond.Field("type", "must be one of: ntfy, gotify, webhook"))
+			return
+		}
+
+		config, err := json.Marshal(req.Config)
+		if err != nil || req.Config == nil {
+			respond.Validation(w, "config is required", respond.Field("config", "is required"))
+			return
+		}
+
+		channel := &models.NotificationChannel{
+			AccountID: accountID,
+			Type:      req.Type,
+			Name:      req.Name,
+			Config:    string(config),
+			IsEnabled: req.IsEnabled == nil || *req.IsEnabled,
+			CreatedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if err := validateNotificationChannel(channel); err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		channelRepo := repository.NewNotificationChannelRepository(db)
+		if err := channelRepo.Create(channel); err != nil {
+			respond.Error(w, "Failed to create notification channel", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"notification_channel",
+			sql.NullInt64{Int64: channel.ID, Valid: true},
+			map[string]interface{}{"type": channel.Type, "name": channel.Name},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(toNotificationChannelResponse(channel))
+	}
+}
+
+// HandleUpdateNotificationChannel updates a notification channel (owner only).
+// Secret config values left blank keep their stored value.
+func HandleUpdateNotificationChannel(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		role := middleware.GetRole(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if role != "owner" {
+			respond.Error(w, "Forbidden: only account owner can manage notification channels", http.StatusForbidden)
+			return
+		}
+
+		channelID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid channel ID", http.StatusBadRequest)
+			return
+		}
+
+		var req NotificationChannelRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		channelRepo := repository.NewNotificationChannelRepository(db)
+		channel, err := channelRepo.GetByID(channelID, accountID)
+		if err == repository.ErrNotFound {
+			respond.Error(w, "Notification channel not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			respond.Error(w, "Failed to retrieve notification channel", http.StatusInternalServerError)
+			return
+		}
+
+		if req.Type != "" && req.Type != channel.Type {
+			respond.Error(w, "Channel type cannot be changed", http.StatusBadRequest)
+			return
+		}
+		if req.Name != "" {
+			channel.Name = req.Name
+		}
+		if req.IsEnabled != nil {
+			channel.IsEnabled = *req.IsEnabled
+		}
+		if req.Config != nil {
+			services.MergeChannelSecrets(channel.Config, req.Config)
+			config, err := json.Marshal(req.Config)
+			if err != nil {
+				respond.Error(w, "Invalid config", http.StatusBadRequest)
+				return
+			}
+			channel.Config = string(config)
+		}
+
+		if err := validateNotificationChannel(channel); err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := channelRepo.Update(channel); err != nil {
+			respond.Error(w, "Failed to update notification channel", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"notification_channel",
+			sql.NullInt64{Int64: channel.ID, Valid: true},
+			map[string]interface{}{"name": channel.Name, "is_enabled": channel.IsEnabled},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		channel.UpdatedAt = time.Now()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toNotificationChannelResponse(channel))
+	}
+}
+
+// HandleDeleteNotificationChannel removes a notification channel (owner only)
+func HandleDeleteNotificationChannel(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		role := middleware.GetRole(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if role != "owner" {
+			respond.Error(w, "Forbidden: only account owner can manage notification channels", http.StatusForbidden)
+			return
+		}
+
+		channelID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid channel ID", http.StatusBadRequest)
+			return
+		}
+
+		channelRepo := repository.NewNotificationChannelRepository(db)
+		if err := channelRepo.Delete(channelID, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Notification channel not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete notification channel", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"notification_channel",
+			sql.NullInt64{Int64: channelID, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleTestNotificationChannel sends a test message through a channel (owner only)
+func HandleTestNotificationChannel(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		role := middleware.GetRole(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if role != "owner" {
+			respond.Error(w, "Forbidden: only account owner can manage notification channels", http.StatusForbidden)
+			return
+		}
+
+		channelID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid channel ID", http.StatusBadRequest)
+			return
+		}
+
+		channelRepo := repository.NewNotificationChannelRepository(db)
+		channel, err := channelRepo.GetByID(channelID, accountID)
+		if err == repository.ErrNotFound {
+			respond.Error(w, "Notification channel not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			respond.Error(w, "Failed to retrieve notification channel", http.StatusInternalServerError)
+			return
+		}
+
+		dispatcher := services.NewNotificationDispatcher(db)
+		err = dispatcher.SendToChannel(channel, services.NotificationMessage{
+			AccountID: accountID,
+			Type:      "system",
+			Title:     "P-TRACK Test Notification",
+			Message:   "This is a test notification from P-TRACK. Your %s channel is working.",
+			Args:      []interface{}{channel.Type},
+			Priority:  services.PriorityDefault,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"message": "Failed to send test notification: " + err.Error(),
+				"success": false,
+			})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Test notification sent to " + channel.Name,
+			"success": true,
+		})
+	}
+}
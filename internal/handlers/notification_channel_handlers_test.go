@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+func TestHandleTestNotificationChannelOwnerOnly(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash'), (2, 'member', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner'), (1, 2, 'member');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+
+	var hits atomic.Int32
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer endpoint.Close()
+
+	channel := &models.NotificationChannel{
+		AccountID: 1,
+		Type:      "webhook",
+		Name:      "Hook",
+		Config:    fmt.Sprintf(`{"url":%q}`, endpoint.URL),
+		IsEnabled: true,
+		CreatedBy: sql.NullInt64{Int64: 1, Valid: true},
+	}
+	if err := repository.NewNotificationChannelRepository(db).Create(channel); err != nil {
+		t.Fatalf("Failed to create channel: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Post("/notification-channels/{id}/test", HandleTestNotificationChannel(db))
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/notification-channels/%d/test", channel.ID), nil)
+	userCtx := &middleware.UserContext{UserID: 2, AccountID: 1, Role: "member"}
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, userCtx))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a member, got %d", rec.Code)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("Expected no request to the channel, got %d", hits.Load())
+	}
+}
@@ -3,15 +3,16 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
 	"injection-tracker/internal/middleware"
 	"injection-tracker/internal/models"
 	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -38,9 +39,10 @@ type NotificationsListResponse struct {
 // HandleGetNotifications returns user notifications
 func HandleGetNotifications(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -68,21 +70,21 @@ func HandleGetNotifications(db *database.DB) http.HandlerFunc {
 		// Get notifications
 		notifications, err := repo.GetByUserID(userID, includeRead, limit, offset)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get notifications: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to get notifications: %v", err), http.StatusInternalServerError)
 			return
 		}
 
 		// Get unread count
 		unreadCount, err := repo.CountUnread(userID)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to count unread notifications: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to count unread notifications: %v", err), http.StatusInternalServerError)
 			return
 		}
 
 		// Convert to response format
 		responseNotifications := make([]*NotificationResponse, 0, len(notifications))
 		for _, n := range notifications {
-			responseNotifications = append(responseNotifications, notificationToResponse(n))
+			responseNotifications = append(responseNotifications, notificationToResponse(i18n.FromContext(r.Context()), n))
 		}
 
 		response := NotificationsListResponse{
@@ -93,7 +95,7 @@ func HandleGetNotifications(db *database.DB) http.HandlerFunc {
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Printf("Failed to encode notifications response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode notifications response", "err", err)
 		}
 	}
 }
@@ -101,26 +103,27 @@ func HandleGetNotifications(db *database.DB) http.HandlerFunc {
 // HandleMarkNotificationRead marks a notification as read
 func HandleMarkNotificationRead(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid notification ID", http.StatusBadRequest)
 			return
 		}
 
 		repo := repository.NewNotificationRepository(db)
 		if err := repo.MarkAsRead(id, userID); err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Notification not found", http.StatusNotFound)
+				respond.Error(w, "Notification not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, fmt.Sprintf("Failed to mark notification as read: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to mark notification as read: %v", err), http.StatusInternalServerError)
 			return
 		}
 
@@ -131,15 +134,16 @@ func HandleMarkNotificationRead(db *database.DB) http.HandlerFunc {
 // HandleMarkAllNotificationsRead marks all notifications as read
 func HandleMarkAllNotificationsRead(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		repo := repository.NewNotificationRepository(db)
 		if err := repo.MarkAllAsRead(userID); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to mark all notifications as read: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to mark all notifications as read: %v", err), http.StatusInternalServerError)
 			return
 		}
 
@@ -150,26 +154,27 @@ func HandleMarkAllNotificationsRead(db *database.DB) http.HandlerFunc {
 // HandleDeleteNotification deletes a notification
 func HandleDeleteNotification(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid notification ID", http.StatusBadRequest)
 			return
 		}
 
 		repo := repository.NewNotificationRepository(db)
 		if err := repo.Delete(id, userID); err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Notification not found", http.StatusNotFound)
+				respond.Error(w, "Notification not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, fmt.Sprintf("Failed to delete notification: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to delete notification: %v", err), http.StatusInternalServerError)
 			return
 		}
 
@@ -180,28 +185,29 @@ func HandleDeleteNotification(db *database.DB) http.HandlerFunc {
 // HandleGetUnreadCount returns the count of unread notifications
 func HandleGetUnreadCount(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		repo := repository.NewNotificationRepository(db)
 		count, err := repo.CountUnread(userID)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to count unread notifications: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to count unread notifications: %v", err), http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(map[string]int64{"count": count}); err != nil {
-			log.Printf("Failed to encode count response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode count response", "err", err)
 		}
 	}
 }
 
 // notificationToResponse converts a notification model to API response
-func notificationToResponse(n *models.Notification) *NotificationResponse {
+func notificationToResponse(p *i18n.Printer, n *models.Notification) *NotificationResponse {
 	var scheduledTime *time.Time
 	if n.ScheduledTime.Valid {
 		scheduledTime = &n.ScheduledTime.Time
@@ -215,6 +221,6 @@ func notificationToResponse(n *models.Notification) *NotificationResponse {
 		IsRead:        n.IsRead,
 		ScheduledTime: scheduledTime,
 		CreatedAt:     n.CreatedAt,
-		TimeAgo:       formatTimeAgo(n.CreatedAt),
+		TimeAgo:       formatTimeAgo(p, n.CreatedAt),
 	}
 }
@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"injection-tracker/internal/apidoc"
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/services"
+	"injection-tracker/internal/web"
+)
+
+//go:generate go run ../../cmd/openapi-gen -o openapi.json
+
+// openAPISpec is generated by GenerateOpenAPI through go generate; a test fails when
+// it is out of date
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// APIInfo is the title block of the OpenAPI document
+var APIInfo = apidoc.Info{
+	Title:   "P-TRACK API",
+	Version: "1.0.0",
+	Description: "JSON API behind the P-TRACK web app. Authenticate with the auth_token cookie " +
+		"from POST /api/v1/auth/login or the same JWT as a Bearer token. Errors are JSON objects of the form " +
+		"{\"error\": {\"code\", \"message\", \"fields\"}}; code is one of validation_failed, unauthorized, " +
+		"token_expired, session_idle, session_expired, forbidden, csrf_invalid, account_suspended, quota_exceeded, not_found, method_not_allowed, conflict, precondition_failed, precondition_required, " +
+		"payload_too_large, unsupported_media_type, " +
+		"unprocessable, rate_limited, internal_error, not_implemented, service_unavailable or upstream_failed, " +
+		"and fields lists per-field problems for some validation failures. Unversioned /api paths are a " +
+		"deprecated alias for /api/v1 and answer with Deprecation and Sunset headers. Injections, symptom " +
+		"logs, medications and settings carry an ETag; edits must send If-Match or If-Unmodified-Since and get " +
+		"412 with the current record in \"current\" if someone else changed it first. A 401 with token_expired " +
+		"can be retried after POST /api/v1/auth/refresh; session_idle and session_expired mean signing in again.",
+}
+
+var (
+	dateRange = []apidoc.Param{
+		{Name: "start_date", Description: "YYYY-MM-DD"},
+		{Name: "end_date", Description: "YYYY-MM-DD"},
+	}
+	paging = []apidoc.Param{
+		{Name: "limit"},
+		{Name: "offset"},
+	}
+	cursorPaging = []apidoc.Param{
+		{Name: "limit", Description: "Page size, default 50, at most 500"},
+		{Name: "cursor", Description: "next_cursor from the previous page"},
+	}
+	auditFilter = params([]apidoc.Param{
+		{Name: "user_id"},
+		{Name: "action"},
+		{Name: "entity_type"},
+		{Name: "entity_id"},
+	}, dateRange)
+	exportParams = append([]apidoc.Param{{Name: "course_id"}}, dateRange...)
+	ifMatch      = []apidoc.Param{
+		{Name: "If-Match", Description: "ETag from GET; 412 if the record has changed since"},
+		{Name: "If-Unmodified-Since", Description: "Alternative to If-Match, to the second"},
+	}
+)
+
+func params(groups ...[]apidoc.Param) []apidoc.Param {
+	var out []apidoc.Param
+	for _, g := range groups {
+		out = append(out, g...)
+	}
+	return out
+}
+
+type anyObject = map[string]interface{}
+
+// APIRoutes documents every /api route registered in internal/server. Keep
+// it in step with the router and run go generate ./internal/handlers
+// afterwards.
+func APIRoutes() []apidoc.Route {
+	return []apidoc.Route{
+		// Setup and authentication
+		{Method: "POST", Path: "/api/setup", Tag: "Auth", Summary: "Create the first admin user on a new install, then redirect to login", RequestType: "application/x-www-form-urlencoded", Status: http.StatusSeeOther, Public: true},
+		{Method: "POST", Path: "/api/auth/login", Tag: "Auth", Summary: "Log in; sets the auth_token cookie, and with remember_device the device_token cookie that trusts the device", Request: LoginRequest{}, Response: AuthResponse{}, Public: true},
+		{Method: "POST", Path: "/api/auth/register", Tag: "Auth", Summary: "Register, optionally with an invitation token; 403 when the site is invite-only and there is none, or registration is disabled", Request: RegisterRequest{}, Response: AuthResponse{}, Status: http.StatusCreated, Public: true},
+		{Method: "POST", Path: "/api/auth/forgot-password", Tag: "Auth", Summary: "Not implemented; always 501", Public: true},
+		{Method: "POST", Path: "/api/auth/reset-password", Tag: "Auth", Summary: "Not implemented; always 501", Public: true},
+		{Method: "POST", Path: "/api/csp-report", Tag: "Auth", Summary: "Where browsers report Content Security Policy violations (report-uri and report-to); they are logged", RequestType: "application/csp-report", Status: http.StatusNoContent, Public: true},
+		{Method: "GET", Path: "/api/csrf-token", Tag: "Auth", Summary: "Get a CSRF token for the X-CSRF-Token header", Response: map[string]string{}},
+		{Method: "GET", Path: "/api/auth/me", Tag: "Auth", Summary: "Get the current user", Response: UserResponse{}},
+		{Method: "POST", Path: "/api/auth/logout", Tag: "Auth", Summary: "Log out and revoke the session", Response: anyObject{}},
+		{Method: "POST", Path: "/api/auth/refresh", Tag: "Auth", Summary: "Issue a fresh token, even for an expired one, while its session is in use", Response: AuthResponse{}},
+		{Method: "POST", Path: "/api/auth/stop-impersonating", Tag: "Auth", Summary: "End an impersonation session, signing the admin back in as themselves if their own session is still active", Response: StopImpersonationResponse{}},
+		{Method: "GET", Path: "/api/me/admin", Tag: "Auth", Summary: "Whether the current user is the site admin", Response: map[string]bool{}},
+		{Method: "GET", Path: "/api/me/security", Tag: "Auth", Summary: "Your signed-in devices, the devices you trust and sign-in attempts over the last 30 days, flagging new addresses, new devices and impossible travel", Response: SecurityResponse{}},
+		{Method: "DELETE", Path: "/api/me/security/sessions/{id}", Tag: "Auth", Summary: "Sign out one of your devices; its token stops working at once", Status: http.StatusNoContent},
+		{Method: "DELETE", Path: "/api/me/security/devices/{id}", Tag: "Auth", Summary: "Stop trusting one of your devices and sign out its sessions", Status: http.StatusNoContent},
+
+		// Dashboard
+		{Method: "GET", Path: "/api/dashboard", Tag: "Dashboard", Summary: "Next injection due, last injection, today's medication adherence, low stock and recent activity in one payload", Response: DashboardResponse{}},
+		{Method: "GET", Path: "/api/dashboard/recent", Tag: "Dashboard", Summary: "Recent activity as an HTML fragment", ResponseType: "text/html"},
+		{Method: "GET", Path: "/api/activity", Tag: "Dashboard", Summary: "Activity feed: injections, symptom logs, medication logs, inventory changes and course events, newest first", Query: params([]apidoc.Param{{Name: "type", Description: "Comma-separated kinds: injection, symptom, medication, inventory, course"}, {Name: "course_id"}}, cursorPaging), Response: ListResponse[ActivityResponse]{}},
+		{Method: "GET", Path: "/api/stats/milestones", Tag: "Dashboard", Summary: "Current and longest injection streaks, injections per course and milestones reached such as the 50th injection or a course's halfway point", Response: MilestonesResponse{}},
+		{Method: "GET", Path: "/api/features", Tag: "Dashboard", Summary: "Which feature flags are on for the account", Response: map[string]bool{}},
+		{Method: "GET", Path: "/api/announcements", Tag: "Dashboard", Summary: "Site-wide announcements that haven't expired", Response: []AnnouncementResponse{}},
+		{Method: "GET", Path: "/api/events", Tag: "Dashboard", Summary: "Server-sent events for changes in the account: injection.created, inventory.adjusted, medication.logged, and resync when missed events are gone", Query: []apidoc.Param{{Name: "last_event_id", Description: "Resume after this event; the Last-Event-ID header also works"}}, ResponseType: "text/event-stream"},
+		{Method: "POST", Path: "/api/sync", Tag: "Sync", Summary: "Apply changes queued offline, in order; each result is applied, duplicate, conflict, failed or in_progress", Request: SyncRequest{}, Response: SyncResponse{}},
+
+		// Account
+		{Method: "GET", Path: "/api/account", Tag: "Account", Summary: "Get the current account", Response: models.Account{}},
+		{Method: "PUT", Path: "/api/account", Tag: "Account", Summary: "Rename the account", Request: UpdateAccountRequest{}, Response: models.Account{}},
+		{Method: "GET", Path: "/api/account/members", Tag: "Account", Summary: "List account members", Response: []AccountMemberResponse{}},
+		{Method: "DELETE", Path: "/api/account/members/{userID}", Tag: "Account", Summary: "Remove a member", Status: http.StatusNoContent},
+		{Method: "PUT", Path: "/api/account/members/{userID}/role", Tag: "Account", Summary: "Change a member's role", Request: UpdateMemberRoleRequest{}, Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/account/members/{userID}/transfer-ownership", Tag: "Account", Summary: "Transfer ownership to another member", Request: TransferOwnershipRequest{}, Status: http.StatusNoContent},
+		{Method: "GET", Path: "/api/account/my-data", Tag: "Account", Summary: "Download your personal data", Response: PersonalData{}},
+		{Method: "POST", Path: "/api/account/deletion", Tag: "Account", Summary: "Request deletion of your user or the account", Request: AccountDeletionRequest{}, Response: anyObject{}, Status: http.StatusCreated},
+		{Method: "DELETE", Path: "/api/account/deletion", Tag: "Account", Summary: "Cancel a pending deletion", Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/account/deletion/confirm", Tag: "Account", Summary: "Confirm a pending deletion", Request: AccountDeletionConfirmRequest{}, Response: anyObject{}},
+
+		// Invitations
+		{Method: "POST", Path: "/api/account/invitations", Tag: "Account", Summary: "Invite someone to the account, emailing them the link when mail is set up", Request: CreateInvitationRequest{}, Response: InvitationResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/account/invitations", Tag: "Account", Summary: "List the account's invitations", Response: []InvitationResponse{}},
+		{Method: "POST", Path: "/api/account/invitations/{id}/resend", Tag: "Account", Summary: "Issue an invitation a new link and email it again; the old link stops working", Request: ResendInvitationRequest{}, Response: InvitationResponse{}},
+		{Method: "DELETE", Path: "/api/account/invitations/{id}", Tag: "Account", Summary: "Revoke an invitation", Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/account/invitations/accept", Tag: "Account", Summary: "Accept an invitation", Query: []apidoc.Param{{Name: "token", Required: true}}, Response: anyObject{}},
+
+		// Courses
+		{Method: "GET", Path: "/api/courses", Tag: "Courses", Summary: "List courses", Query: []apidoc.Param{{Name: "filter", Description: "active for active courses only"}}, Response: []*models.Course{}},
+		{Method: "POST", Path: "/api/courses", Tag: "Courses", Summary: "Create a course", Request: CreateCourseRequest{}, Response: models.Course{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/courses/active", Tag: "Courses", Summary: "Get the most recently started active course", Response: models.Course{}},
+		{Method: "GET", Path: "/api/courses/{id}", Tag: "Courses", Summary: "Get a course", Response: models.Course{}},
+		{Method: "PUT", Path: "/api/courses/{id}", Tag: "Courses", Summary: "Update a course", Request: UpdateCourseRequest{}, Response: models.Course{}},
+		{Method: "DELETE", Path: "/api/courses/{id}", Tag: "Courses", Summary: "Move a course, with its injections and symptom logs, to the trash", Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/courses/{id}/activate", Tag: "Courses", Summary: "Activate a course alongside any others", Response: models.Course{}},
+		{Method: "POST", Path: "/api/courses/{id}/close", Tag: "Courses", Summary: "Close a course", Request: CloseCourseRequest{}, Response: models.Course{}},
+		{Method: "GET", Path: "/api/courses/{id}/taper", Tag: "Courses", Summary: "Get a course's taper schedule", Response: DoseScheduleResponse{}},
+		{Method: "PUT", Path: "/api/courses/{id}/taper", Tag: "Courses", Summary: "Replace a course's taper schedule", Request: DoseScheduleRequest{}, Response: DoseScheduleResponse{}},
+		{Method: "GET", Path: "/api/courses/{id}/schedule", Tag: "Courses", Summary: "When a course's injections fall due, on its own schedule or the account's reminder settings, and the next one", Response: CourseScheduleResponse{}},
+		{Method: "PUT", Path: "/api/courses/{id}/schedule", Tag: "Courses", Summary: "Set a course's schedule: every interval_hours, or on weekdays, at time_of_day", Request: CourseScheduleRequest{}, Response: CourseScheduleResponse{}},
+		{Method: "DELETE", Path: "/api/courses/{id}/schedule", Tag: "Courses", Summary: "Remove a course's schedule so it follows the account's reminder settings", Status: http.StatusNoContent},
+
+		// Compounds
+		{Method: "GET", Path: "/api/compounds", Tag: "Compounds", Summary: "List compounds", Query: []apidoc.Param{{Name: "filter", Description: "active for active compounds only"}}, Response: []CompoundResponse{}},
+		{Method: "POST", Path: "/api/compounds", Tag: "Compounds", Summary: "Create a compound", Request: CompoundRequest{}, Response: CompoundResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/compounds/{id}", Tag: "Compounds", Summary: "Get a compound", Response: CompoundResponse{}},
+		{Method: "PUT", Path: "/api/compounds/{id}", Tag: "Compounds", Summary: "Update a compound", Request: CompoundRequest{}, Response: CompoundResponse{}},
+		{Method: "DELETE", Path: "/api/compounds/{id}", Tag: "Compounds", Summary: "Delete a compound", Status: http.StatusNoContent},
+
+		// Injections
+		{Method: "GET", Path: "/api/injections", Tag: "Injections", Summary: "List injections", Query: params([]apidoc.Param{{Name: "course_id"}, {Name: "side"}, {Name: "include_voided"}}, dateRange, cursorPaging), Response: ListResponse[models.Injection]{}},
+		{Method: "POST", Path: "/api/injections", Tag: "Injections", Summary: "Log an injection; 409 with the existing injection if one on the same course and side was logged within the duplicate window, unless allow_duplicate is set", Request: CreateInjectionRequest{}, Response: models.Injection{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/injections/recent", Tag: "Injections", Summary: "Most recent injections", Response: []models.Injection{}},
+		{Method: "GET", Path: "/api/injections/stats", Tag: "Injections", Summary: "Injection statistics", Query: []apidoc.Param{{Name: "course_id"}}, Response: InjectionStatsResponse{}},
+		{Method: "GET", Path: "/api/injections/duplicates", Tag: "Injections", Summary: "Injections that look double-logged, grouped by course and side, to review and void", Query: []apidoc.Param{{Name: "course_id"}, {Name: "days", Description: "Days back to look, default 90"}, {Name: "window", Description: "Minutes between injections, 1 to 1440; default the account's duplicate window"}}, Response: []DuplicateGroupResponse{}},
+		{Method: "GET", Path: "/api/injections/missed", Tag: "Injections", Summary: "Doses that fell due on a course's schedule and weren't logged, most recently due first", Query: []apidoc.Param{{Name: "course_id"}, {Name: "days", Description: "Days back to look, default 90"}, {Name: "unannotated", Description: "true for those nobody has given a reason for"}}, Response: []MissedInjectionResponse{}},
+		{Method: "PUT", Path: "/api/injections/missed/{id}", Tag: "Injections", Summary: "Say why a missed dose was skipped", Request: AnnotateMissedInjectionRequest{}, Response: MissedInjectionResponse{}},
+		{Method: "DELETE", Path: "/api/injections/missed/{id}", Tag: "Injections", Summary: "Dismiss a missed dose, e.g. one given but logged late", Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/injections/sessions", Tag: "Injections", Summary: "Start a guided injection session; 409 if one is already open", Request: StartInjectionSessionRequest{}, Response: InjectionSessionResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/injections/sessions/current", Tag: "Injections", Summary: "Your open guided injection session, to resume it", Response: InjectionSessionResponse{}},
+		{Method: "GET", Path: "/api/injections/sessions/{id}", Tag: "Injections", Summary: "Get a guided injection session", Response: InjectionSessionResponse{}},
+		{Method: "POST", Path: "/api/injections/sessions/{id}/warming", Tag: "Injections", Summary: "Start or stop a session's warm-up timer", Request: InjectionSessionWarmingRequest{}, Response: InjectionSessionResponse{}},
+		{Method: "PUT", Path: "/api/injections/sessions/{id}/details", Tag: "Injections", Summary: "Record the injection's details", Request: InjectionSessionDetailsRequest{}, Response: InjectionSessionResponse{}},
+		{Method: "POST", Path: "/api/injections/sessions/{id}/complete", Tag: "Injections", Summary: "Log the session's injection and close it with its timings; 409 if it looks like a duplicate", Query: []apidoc.Param{{Name: "allow_duplicate", Description: "true to log it even if it looks like a duplicate"}}, Response: CompletedInjectionSessionResponse{}, Status: http.StatusCreated},
+		{Method: "POST", Path: "/api/injections/sessions/{id}/cancel", Tag: "Injections", Summary: "Abandon a session without logging an injection", Response: InjectionSessionResponse{}},
+		{Method: "GET", Path: "/api/injections/next-site", Tag: "Injections", Summary: "Suggest the next injection site; 404 when the rotation planner is off", Query: []apidoc.Param{{Name: "min_days", Description: "Days before a site may be reused, 0 to 90"}}, Response: services.SiteSuggestion{}},
+		{Method: "GET", Path: "/api/injections/{id}", Tag: "Injections", Summary: "Get an injection", Response: models.Injection{}},
+		{Method: "PUT", Path: "/api/injections/{id}", Tag: "Injections", Summary: "Update an injection; needs If-Match or If-Unmodified-Since", Headers: ifMatch, Request: UpdateInjectionRequest{}, Response: models.Injection{}},
+		{Method: "DELETE", Path: "/api/injections/{id}", Tag: "Injections", Summary: "Void an injection", Query: []apidoc.Param{{Name: "reason"}}, Headers: ifMatch, Request: VoidInjectionRequest{}, Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/injections/{id}/restore", Tag: "Injections", Summary: "Restore a voided injection", Response: models.Injection{}},
+
+		// Symptoms
+		{Method: "GET", Path: "/api/symptoms", Tag: "Symptoms", Summary: "List symptom logs", Query: params([]apidoc.Param{{Name: "course_id"}}, dateRange, cursorPaging), Response: ListResponse[SymptomLogResponse]{}},
+		{Method: "POST", Path: "/api/symptoms", Tag: "Symptoms", Summary: "Log symptoms", Request: CreateSymptomRequest{}, Response: models.SymptomLog{}, Status: http.StatusCreated},
+		{Method: "DELETE", Path: "/api/symptoms", Tag: "Symptoms", Summary: "Move up to 100 symptom logs to the trash in one transaction; 422 with per-item results if any fail", Query: []apidoc.Param{{Name: "ids", Description: "Comma separated symptom log IDs"}}, Response: BulkResponse{}},
+		{Method: "GET", Path: "/api/symptoms/recent", Tag: "Symptoms", Summary: "Recent symptom logs as an HTML fragment", ResponseType: "text/html"},
+		{Method: "GET", Path: "/api/symptoms/trends", Tag: "Symptoms", Summary: "Pain levels and per-symptom counts over recent days, by day or week", Query: []apidoc.Param{{Name: "days"}, {Name: "granularity"}}, Response: SymptomTrendsResponse{}},
+		{Method: "GET", Path: "/api/symptoms/catalog", Tag: "Symptoms", Summary: "List the account's symptom catalog", Query: []apidoc.Param{{Name: "include_archived", Description: "true to include archived symptoms"}}, Response: []SymptomCatalogResponse{}},
+		{Method: "POST", Path: "/api/symptoms/catalog", Tag: "Symptoms", Summary: "Add a symptom to the catalog", Request: SymptomCatalogRequest{}, Response: SymptomCatalogResponse{}, Status: http.StatusCreated},
+		{Method: "PUT", Path: "/api/symptoms/catalog/{id}", Tag: "Symptoms", Summary: "Update a catalog symptom; its key can't change", Request: SymptomCatalogRequest{}, Response: SymptomCatalogResponse{}},
+		{Method: "DELETE", Path: "/api/symptoms/catalog/{id}", Tag: "Symptoms", Summary: "Delete a catalog symptom; one that logs use is archived instead and returned with 200", Status: http.StatusNoContent},
+		{Method: "GET", Path: "/api/symptoms/{id}", Tag: "Symptoms", Summary: "Get a symptom log", Response: anyObject{}},
+		{Method: "PUT", Path: "/api/symptoms/{id}", Tag: "Symptoms", Summary: "Update a symptom log; needs If-Match or If-Unmodified-Since", Headers: ifMatch, Request: UpdateSymptomRequest{}, Response: models.SymptomLog{}},
+		{Method: "DELETE", Path: "/api/symptoms/{id}", Tag: "Symptoms", Summary: "Move a symptom log to the trash", Headers: ifMatch, Status: http.StatusNoContent},
+
+		// Wellness check-ins
+		{Method: "GET", Path: "/api/checkins", Tag: "Check-ins", Summary: "List daily check-ins", Query: params([]apidoc.Param{{Name: "user_id", Description: "Only this member's"}}, dateRange, []apidoc.Param{{Name: "limit", Description: "Default 100, at most 1000"}}), Response: []CheckInResponse{}},
+		{Method: "POST", Path: "/api/checkins", Tag: "Check-ins", Summary: "Check in for today or an earlier day", Request: CheckInRequest{}, Response: CheckInResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/checkins/today", Tag: "Check-ins", Summary: "The current user's check-in for today", Response: CheckInResponse{}},
+		{Method: "GET", Path: "/api/checkins/trends", Tag: "Check-ins", Summary: "Daily averages of check-in readings", Query: []apidoc.Param{{Name: "days"}, {Name: "user_id"}}, Response: CheckInTrendsResponse{}},
+		{Method: "PUT", Path: "/api/checkins/{id}", Tag: "Check-ins", Summary: "Replace the readings of one of your check-ins", Request: CheckInRequest{}, Response: CheckInResponse{}},
+		{Method: "DELETE", Path: "/api/checkins/{id}", Tag: "Check-ins", Summary: "Delete one of your check-ins", Status: http.StatusNoContent},
+
+		// Journal
+		{Method: "GET", Path: "/api/journal", Tag: "Journal", Summary: "List journal entries", Query: params([]apidoc.Param{
+			{Name: "q", Description: "Search titles, bodies and tags"},
+			{Name: "tag"},
+			{Name: "course_id"},
+			{Name: "date", Description: "Entries about this day (YYYY-MM-DD)"},
+			{Name: "pinned", Description: "true for pinned entries only"},
+		}, dateRange, cursorPaging), Response: ListResponse[JournalEntryResponse]{}},
+		{Method: "POST", Path: "/api/journal", Tag: "Journal", Summary: "Write a journal entry", Request: JournalEntryRequest{}, Response: JournalEntryResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/journal/tags", Tag: "Journal", Summary: "Tags in use, most used first", Response: []repository.JournalTagCount{}},
+		{Method: "GET", Path: "/api/journal/{id}", Tag: "Journal", Summary: "Get a journal entry", Response: JournalEntryResponse{}},
+		{Method: "PUT", Path: "/api/journal/{id}", Tag: "Journal", Summary: "Update, pin or unpin a journal entry", Request: JournalEntryRequest{}, Response: JournalEntryResponse{}},
+		{Method: "DELETE", Path: "/api/journal/{id}", Tag: "Journal", Summary: "Move a journal entry to the trash", Status: http.StatusNoContent},
+
+		// Lab results
+		{Method: "GET", Path: "/api/labs", Tag: "Labs", Summary: "List lab results, newest draw first", Query: params([]apidoc.Param{
+			{Name: "analyte", Description: "Only this analyte, ignoring case"},
+			{Name: "course_id", Description: "Only this course's results and those not linked to a course"},
+		}, dateRange, []apidoc.Param{{Name: "limit", Description: "Default 100, at most 1000"}}), Response: []LabResultResponse{}},
+		{Method: "POST", Path: "/api/labs", Tag: "Labs", Summary: "Record a lab result", Request: LabResultRequest{}, Response: LabResultResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/labs/analytes", Tag: "Labs", Summary: "Analytes with results, most recently drawn first", Response: []repository.LabAnalyte{}},
+		{Method: "GET", Path: "/api/labs/trends", Tag: "Labs", Summary: "Lab results over recent days as a series per analyte, with the dose before each draw", Query: []apidoc.Param{{Name: "days", Description: "Default 180, at most 730"}, {Name: "analyte"}, {Name: "course_id"}}, Response: LabTrendsResponse{}},
+		{Method: "GET", Path: "/api/labs/{id}", Tag: "Labs", Summary: "Get a lab result with the dose before the draw", Response: LabResultResponse{}},
+		{Method: "PUT", Path: "/api/labs/{id}", Tag: "Labs", Summary: "Update a lab result", Request: LabResultRequest{}, Response: LabResultResponse{}},
+		{Method: "DELETE", Path: "/api/labs/{id}", Tag: "Labs", Summary: "Delete a lab result", Status: http.StatusNoContent},
+		// Travel
+		{Method: "GET", Path: "/api/trips", Tag: "Travel", Summary: "List trips, latest start first", Response: []TripResponse{}},
+		{Method: "POST", Path: "/api/trips", Tag: "Travel", Summary: "Record a trip; injections fall due on the destination's clock while it lasts. 409 if it overlaps another trip", Request: TripRequest{}, Response: TripResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/trips/{id}", Tag: "Travel", Summary: "Get a trip", Response: TripResponse{}},
+		{Method: "PUT", Path: "/api/trips/{id}", Tag: "Travel", Summary: "Update a trip, e.g. set ends_at to end it early", Request: TripRequest{}, Response: TripResponse{}},
+		{Method: "DELETE", Path: "/api/trips/{id}", Tag: "Travel", Summary: "Delete a trip", Status: http.StatusNoContent},
+		// Attachments
+		{Method: "POST", Path: "/api/attachments", Tag: "Attachments", Summary: "Upload a photo (file, with injection_id or symptom_id)", RequestType: "multipart/form-data", Response: AttachmentResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/attachments/{id}", Tag: "Attachments", Summary: "Get attachment details", Response: AttachmentResponse{}},
+		{Method: "GET", Path: "/api/attachments/{id}/file", Tag: "Attachments", Summary: "Download the original file", ResponseType: "application/octet-stream"},
+		{Method: "GET", Path: "/api/attachments/{id}/thumbnail", Tag: "Attachments", Summary: "Download the thumbnail", ResponseType: "image/jpeg"},
+		{Method: "DELETE", Path: "/api/attachments/{id}", Tag: "Attachments", Summary: "Delete an attachment", Status: http.StatusNoContent},
+
+		// Medications
+		{Method: "GET", Path: "/api/medications", Tag: "Medications", Summary: "List medications", Query: []apidoc.Param{{Name: "filter", Description: "active for active medications only"}}, Response: []*models.Medication{}},
+		{Method: "POST", Path: "/api/medications", Tag: "Medications", Summary: "Add a medication", Request: CreateMedicationRequest{}, Response: models.Medication{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/medications/search", Tag: "Medications", Summary: "Search the medication catalog for normalized names", Query: []apidoc.Param{{Name: "q", Description: "Words matched against names, dose forms and brand names"}, {Name: "limit", Description: "Default 10, at most 50"}}, Response: []MedicationCatalogResponse{}},
+		{Method: "GET", Path: "/api/medications/schedule/today", Tag: "Medications", Summary: "Today's schedule as an HTML fragment", ResponseType: "text/html"},
+		{Method: "GET", Path: "/api/medications/adherence", Tag: "Medications", Summary: "On time, late and missed dose adherence per medication and dose time over recent days", Query: []apidoc.Param{{Name: "days", Description: "Default 30, at most 365"}}, Response: anyObject{}},
+		{Method: "PATCH", Path: "/api/medications/bulk", Tag: "Medications", Summary: "Update up to 100 medications in one transaction; 422 with per-item results if any fail", Request: BulkUpdateMedicationsRequest{}, Response: BulkResponse{}},
+		{Method: "GET", Path: "/api/medications/{id}", Tag: "Medications", Summary: "Get a medication", Response: models.Medication{}},
+		{Method: "PUT", Path: "/api/medications/{id}", Tag: "Medications", Summary: "Update a medication; needs If-Match or If-Unmodified-Since", Headers: ifMatch, Request: UpdateMedicationRequest{}, Response: models.Medication{}},
+		{Method: "DELETE", Path: "/api/medications/{id}", Tag: "Medications", Summary: "Move a medication and its dose history to the trash", Status: http.StatusNoContent},
+		{Method: "GET", Path: "/api/medications/{id}/doses", Tag: "Medications", Summary: "A day's doses and whether each was taken on time, late or missed", Query: []apidoc.Param{{Name: "date", Description: "YYYY-MM-DD in the user's timezone, default today"}}, Response: []MedicationDoseResponse{}},
+		{Method: "POST", Path: "/api/medications/{id}/log", Tag: "Medications", Summary: "Log a dose as taken, taken late or missed", Request: LogMedicationRequest{}, Response: models.MedicationLog{}, Status: http.StatusCreated},
+		{Method: "POST", Path: "/api/medications/{id}/snooze", Tag: "Medications", Summary: "Snooze a due dose's reminder, no later than the end of its window", Request: SnoozeMedicationDoseRequest{}, Response: MedicationSnoozeResponse{}},
+		{Method: "GET", Path: "/api/medications/{id}/logs", Tag: "Medications", Summary: "List a medication's logs", Query: params(dateRange, cursorPaging), Response: ListResponse[*models.MedicationLog]{}},
+
+		// Inventory
+		{Method: "GET", Path: "/api/inventory", Tag: "Inventory", Summary: "List inventory items", Response: []InventoryItemResponse{}},
+		{Method: "PUT", Path: "/api/inventory/{itemType}", Tag: "Inventory", Summary: "Set an item's stock and details", Request: UpdateInventoryRequest{}, Response: InventoryItemResponse{}},
+		{Method: "GET", Path: "/api/inventory/history", Tag: "Inventory", Summary: "Inventory changes across all items", Query: cursorPaging, Response: ListResponse[InventoryHistoryResponse]{}},
+		{Method: "GET", Path: "/api/inventory/history/recent", Tag: "Inventory", Summary: "Recent inventory changes as an HTML fragment", ResponseType: "text/html"},
+		{Method: "POST", Path: "/api/inventory/history/{id}/reverse", Tag: "Inventory", Summary: "Undo a history entry with one for the opposite change; 409 if already reversed", Request: ReverseInventoryEntryRequest{}, Response: InventoryHistoryResponse{}},
+		{Method: "GET", Path: "/api/inventory/{itemType}/history", Tag: "Inventory", Summary: "An item's inventory changes", Query: cursorPaging, Response: ListResponse[InventoryHistoryResponse]{}},
+		{Method: "POST", Path: "/api/inventory/{itemType}/adjust", Tag: "Inventory", Summary: "Add or remove stock", Request: AdjustInventoryRequest{}, Response: InventoryItemResponse{}},
+		{Method: "GET", Path: "/api/inventory/{itemType}/lots", Tag: "Inventory", Summary: "List an item's lots", Query: []apidoc.Param{{Name: "include_empty"}}, Response: []InventoryLotResponse{}},
+		{Method: "GET", Path: "/api/inventory/vials", Tag: "Inventory", Summary: "List vials, open ones first", Query: []apidoc.Param{{Name: "item_type"}, {Name: "include_closed", Description: "Include empty and discarded vials"}}, Response: []InventoryVialResponse{}},
+		{Method: "POST", Path: "/api/inventory/vials", Tag: "Inventory", Summary: "Receive sealed vials into stock as one lot", Request: ReceiveVialsRequest{}, Response: []InventoryVialResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/inventory/vials/{id}", Tag: "Inventory", Summary: "Get a vial", Response: InventoryVialResponse{}},
+		{Method: "PUT", Path: "/api/inventory/vials/{id}", Tag: "Inventory", Summary: "Update a vial's label, notes, discard-after days or opened date", Request: UpdateVialRequest{}, Response: InventoryVialResponse{}},
+		{Method: "DELETE", Path: "/api/inventory/vials/{id}", Tag: "Inventory", Summary: "Stop tracking a vial, leaving stock unchanged", Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/inventory/vials/{id}/open", Tag: "Inventory", Summary: "Open a sealed vial, starting its beyond-use clock", Request: OpenVialRequest{}, Response: InventoryVialResponse{}},
+		{Method: "POST", Path: "/api/inventory/vials/{id}/discard", Tag: "Inventory", Summary: "Discard a vial, taking what was left out of stock", Request: DiscardVialRequest{}, Response: InventoryVialResponse{}},
+		{Method: "GET", Path: "/api/inventory/stocktakes", Tag: "Inventory", Summary: "List stocktakes, most recent first", Response: []StocktakeResponse{}},
+		{Method: "POST", Path: "/api/inventory/stocktakes", Tag: "Inventory", Summary: "Start a stocktake", Request: StartStocktakeRequest{}, Response: StocktakeResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/inventory/stocktakes/{id}", Tag: "Inventory", Summary: "Get a stocktake with its counts and variance report", Response: StocktakeReportResponse{}},
+		{Method: "GET", Path: "/api/inventory/stocktakes/{id}/export", Tag: "Inventory", Summary: "Download a stocktake's variance report as CSV", ResponseType: "text/csv"},
+		{Method: "POST", Path: "/api/inventory/stocktakes/{id}/counts", Tag: "Inventory", Summary: "Record the physical count of an item or lot", Request: StocktakeCountRequest{}, Response: StocktakeCountResponse{}},
+		{Method: "DELETE", Path: "/api/inventory/stocktakes/{id}/counts/{countId}", Tag: "Inventory", Summary: "Remove a count from an open stocktake", Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/inventory/stocktakes/{id}/complete", Tag: "Inventory", Summary: "Complete a stocktake, posting its variances to stock", Response: StocktakeReportResponse{}},
+		{Method: "POST", Path: "/api/inventory/stocktakes/{id}/cancel", Tag: "Inventory", Summary: "Cancel a stocktake without changing stock", Response: StocktakeResponse{}},
+		{Method: "POST", Path: "/api/inventory/barcode/resolve", Tag: "Inventory", Summary: "Look up a scanned GS1 DataMatrix, GTIN, UPC or NDC for restocking", Request: ResolveBarcodeRequest{}, Response: ResolveBarcodeResponse{}},
+		{Method: "GET", Path: "/api/inventory/barcode/mappings", Tag: "Inventory", Summary: "List the codes mapped to item types", Response: []BarcodeMappingResponse{}},
+		{Method: "POST", Path: "/api/inventory/barcode/mappings", Tag: "Inventory", Summary: "Remember which item type a code stocks", Request: BarcodeMappingRequest{}, Response: BarcodeMappingResponse{}},
+		{Method: "DELETE", Path: "/api/inventory/barcode/mappings/{id}", Tag: "Inventory", Summary: "Forget a mapped code", Status: http.StatusNoContent},
+		{Method: "GET", Path: "/api/inventory/forecast", Tag: "Inventory", Summary: "Forecast when stock runs out", Response: InventoryForecastResponse{}},
+		{Method: "GET", Path: "/api/inventory/alerts", Tag: "Inventory", Summary: "Low stock and expiry alerts, with stock reserved for scheduled injections", Query: []apidoc.Param{{Name: "reserve_days", Description: "Days of scheduled injections that reserve stock, default 14, 0 to 90; 0 turns reservations off"}}, Response: anyObject{}},
+		{Method: "GET", Path: "/api/inventory/stock-alerts", Tag: "Inventory", Summary: "Active low stock alerts, most severe first", Response: []StockAlertResponse{}},
+		{Method: "POST", Path: "/api/inventory/stock-alerts/{id}/acknowledge", Tag: "Inventory", Summary: "Acknowledge a stock alert so it isn't repeated until it escalates", Response: StockAlertResponse{}},
+		{Method: "POST", Path: "/api/inventory/settings", Tag: "Inventory", Summary: "Update inventory settings (accepted but not stored)", Response: anyObject{}},
+		{Method: "GET", Path: "/api/inventory/item-types", Tag: "Inventory", Summary: "List item types", Response: []InventoryItemTypeResponse{}},
+		{Method: "POST", Path: "/api/inventory/item-types", Tag: "Inventory", Summary: "Create an item type", Request: InventoryItemTypeRequest{}, Response: InventoryItemTypeResponse{}, Status: http.StatusCreated},
+		{Method: "PUT", Path: "/api/inventory/item-types/{itemType}", Tag: "Inventory", Summary: "Update an item type", Request: InventoryItemTypeRequest{}, Response: InventoryItemTypeResponse{}},
+		{Method: "DELETE", Path: "/api/inventory/item-types/{itemType}", Tag: "Inventory", Summary: "Delete an item type", Status: http.StatusNoContent},
+		{Method: "GET", Path: "/api/inventory/consumption-profile", Tag: "Inventory", Summary: "Get the consumption profile", Query: []apidoc.Param{{Name: "version"}}, Response: ConsumptionProfileResponse{}},
+		{Method: "PUT", Path: "/api/inventory/consumption-profile", Tag: "Inventory", Summary: "Save a new consumption profile version", Request: ConsumptionProfileRequest{}, Response: ConsumptionProfileResponse{}},
+		{Method: "GET", Path: "/api/inventory/consumption-profile/versions", Tag: "Inventory", Summary: "List consumption profile versions", Response: []ConsumptionProfileResponse{}},
+
+		// Purchases
+		{Method: "GET", Path: "/api/purchases", Tag: "Purchases", Summary: "List purchase orders", Query: []apidoc.Param{{Name: "status"}}, Response: []PurchaseOrderResponse{}},
+		{Method: "POST", Path: "/api/purchases", Tag: "Purchases", Summary: "Create a purchase order", Request: PurchaseOrderRequest{}, Response: PurchaseOrderResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/purchases/spend", Tag: "Purchases", Summary: "Spend over a date range", Query: dateRange, Response: PurchaseSpendResponse{}},
+		{Method: "GET", Path: "/api/purchases/{id}", Tag: "Purchases", Summary: "Get a purchase order", Response: PurchaseOrderResponse{}},
+		{Method: "PUT", Path: "/api/purchases/{id}", Tag: "Purchases", Summary: "Update a purchase order", Request: PurchaseOrderRequest{}, Response: PurchaseOrderResponse{}},
+		{Method: "DELETE", Path: "/api/purchases/{id}", Tag: "Purchases", Summary: "Delete a purchase order", Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/purchases/{id}/receive", Tag: "Purchases", Summary: "Receive an order into inventory", Request: ReceivePurchaseOrderRequest{}, Response: PurchaseOrderResponse{}},
+
+		// Export and import
+		{Method: "GET", Path: "/api/export/pdf", Tag: "Export", Summary: "PDF report", Query: exportParams, ResponseType: "application/pdf"},
+		{Method: "GET", Path: "/api/export/csv", Tag: "Export", Summary: "CSV export", Query: params(exportParams, []apidoc.Param{{Name: "type", Description: "injections, symptoms, medications or all"}}), ResponseType: "text/csv"},
+		{Method: "GET", Path: "/api/export/xlsx", Tag: "Export", Summary: "Excel workbook", Query: exportParams, ResponseType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{Method: "GET", Path: "/api/export/fhir", Tag: "Export", Summary: "FHIR R4 bundle", Query: exportParams, ResponseType: "application/fhir+json"},
+		{Method: "GET", Path: "/api/export/json", Tag: "Export", Summary: "Full account export", Response: AccountData{}},
+		{Method: "POST", Path: "/api/import/injections", Tag: "Import", Summary: "Import injections from JSON rows or CSV (text/csv)", Query: []apidoc.Param{{Name: "course_id"}, {Name: "dry_run"}, {Name: "skip_inventory"}}, Request: []ImportInjectionRow{}, Response: ImportInjectionsResponse{}},
+		{Method: "POST", Path: "/api/import/json", Tag: "Import", Summary: "Restore a full account export into an empty account", Request: AccountData{}, Response: AccountImportResponse{}},
+		{Method: "POST", Path: "/api/import/health", Tag: "Import", Summary: "Import an Apple Health XML or Google Fit JSON export", Query: []apidoc.Param{{Name: "course_id"}, {Name: "dry_run"}}, RequestType: "application/xml", Response: HealthImportResponse{}},
+		{Method: "GET", Path: "/api/import/health/mappings", Tag: "Import", Summary: "Get health import type mappings", Response: HealthImportMappingsResponse{}},
+		{Method: "PUT", Path: "/api/import/health/mappings", Tag: "Import", Summary: "Update health import type mappings", Request: UpdateHealthImportMappingsRequest{}, Response: HealthImportMappingsResponse{}},
+		{Method: "GET", Path: "/api/vitals", Tag: "Import", Summary: "List imported vitals", Query: params([]apidoc.Param{{Name: "type"}}, dateRange, []apidoc.Param{{Name: "limit"}}), Response: []VitalResponse{}},
+
+		// Settings
+		{Method: "GET", Path: "/api/settings", Tag: "Settings", Summary: "Get settings", Response: anyObject{}},
+		{Method: "PUT", Path: "/api/settings", Tag: "Settings", Summary: "Update settings; needs If-Match or If-Unmodified-Since", Headers: ifMatch, Request: UpdateSettingsRequest{}, Response: SettingsResponse{}},
+		{Method: "POST", Path: "/api/settings/profile", Tag: "Settings", Summary: "Update profile (accepted but not stored)", Response: anyObject{}},
+		{Method: "POST", Path: "/api/settings/password", Tag: "Settings", Summary: "Change password (accepted but not stored)", Response: anyObject{}},
+		{Method: "POST", Path: "/api/settings/app", Tag: "Settings", Summary: "Update display settings", Request: AppSettingsRequest{}, Response: anyObject{}},
+		{Method: "POST", Path: "/api/settings/notifications", Tag: "Settings", Summary: "Update notification settings", Request: NotificationSettingsRequest{}, Response: anyObject{}},
+		{Method: "GET", Path: "/api/me/preferences", Tag: "Settings", Summary: "Get display preferences; send If-None-Match to revalidate a cached copy", Response: PreferencesResponse{}},
+		{Method: "PUT", Path: "/api/me/preferences", Tag: "Settings", Summary: "Update display preferences; needs If-Match or If-Unmodified-Since", Headers: ifMatch, Request: UpdatePreferencesRequest{}, Response: PreferencesResponse{}},
+
+		// Notifications
+		{Method: "GET", Path: "/api/notifications", Tag: "Notifications", Summary: "List notifications", Query: params([]apidoc.Param{{Name: "include_read"}}, paging), Response: NotificationsListResponse{}},
+		{Method: "GET", Path: "/api/notifications/count", Tag: "Notifications", Summary: "Unread notification count", Response: map[string]int64{}},
+		{Method: "PUT", Path: "/api/notifications/{id}/read", Tag: "Notifications", Summary: "Mark a notification read", Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/notifications/mark-all-read", Tag: "Notifications", Summary: "Mark all notifications read", Status: http.StatusNoContent},
+		{Method: "DELETE", Path: "/api/notifications/{id}", Tag: "Notifications", Summary: "Delete a notification", Status: http.StatusNoContent},
+		{Method: "GET", Path: "/api/notification-channels", Tag: "Notifications", Summary: "List notification channels", Response: []NotificationChannelResponse{}},
+		{Method: "POST", Path: "/api/notification-channels", Tag: "Notifications", Summary: "Add a notification channel", Request: NotificationChannelRequest{}, Response: NotificationChannelResponse{}, Status: http.StatusCreated},
+		{Method: "PUT", Path: "/api/notification-channels/{id}", Tag: "Notifications", Summary: "Update a notification channel", Request: NotificationChannelRequest{}, Response: NotificationChannelResponse{}},
+		{Method: "DELETE", Path: "/api/notification-channels/{id}", Tag: "Notifications", Summary: "Delete a notification channel", Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/notification-channels/{id}/test", Tag: "Notifications", Summary: "Send a test notification", Response: anyObject{}},
+
+		// Calendar
+		{Method: "GET", Path: "/api/calendar", Tag: "Calendar", Summary: "Per-day activity for a month", Query: []apidoc.Param{{Name: "month", Description: "YYYY-MM; defaults to the current month"}}, Response: CalendarMonthResponse{}},
+		{Method: "GET", Path: "/api/appointments", Tag: "Calendar", Summary: "List appointments", Query: dateRange, Response: []AppointmentResponse{}},
+		{Method: "POST", Path: "/api/appointments", Tag: "Calendar", Summary: "Add an appointment", Request: AppointmentRequest{}, Response: AppointmentResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/appointments/{id}", Tag: "Calendar", Summary: "Get an appointment", Response: AppointmentResponse{}},
+		{Method: "PUT", Path: "/api/appointments/{id}", Tag: "Calendar", Summary: "Update an appointment", Request: AppointmentRequest{}, Response: AppointmentResponse{}},
+		{Method: "DELETE", Path: "/api/appointments/{id}", Tag: "Calendar", Summary: "Delete an appointment", Status: http.StatusNoContent},
+		{Method: "GET", Path: "/api/calendar/tokens", Tag: "Calendar", Summary: "List your calendar feed tokens", Response: []CalendarTokenResponse{}},
+		{Method: "POST", Path: "/api/calendar/tokens", Tag: "Calendar", Summary: "Create a calendar feed token", Request: CreateCalendarTokenRequest{}, Response: CreateCalendarTokenResponse{}, Status: http.StatusCreated},
+		{Method: "DELETE", Path: "/api/calendar/tokens/{id}", Tag: "Calendar", Summary: "Revoke a calendar feed token", Status: http.StatusNoContent},
+
+		// Caregiver share links
+		{Method: "GET", Path: "/api/share-links", Tag: "Sharing", Summary: "List the account's share links", Response: []ShareLinkResponse{}},
+		{Method: "POST", Path: "/api/share-links", Tag: "Sharing", Summary: "Create a read-only share link", Request: CreateShareLinkRequest{}, Response: CreateShareLinkResponse{}, Status: http.StatusCreated},
+		{Method: "DELETE", Path: "/api/share-links/{id}", Tag: "Sharing", Summary: "Revoke a share link", Status: http.StatusNoContent},
+		{Method: "GET", Path: "/api/share-links/{id}/accesses", Tag: "Sharing", Summary: "List recent views of a share link", Response: []ShareLinkAccessResponse{}},
+
+		// Trash
+		{Method: "GET", Path: "/api/trash", Tag: "Trash", Summary: "List deleted courses, symptom logs, medications and journal entries that can still be restored", Query: []apidoc.Param{{Name: "type", Description: "course, symptom_log, medication or journal_entry"}}, Response: []TrashItemResponse{}},
+		{Method: "DELETE", Path: "/api/trash", Tag: "Trash", Summary: "Empty the trash (owner only)", Response: EmptyTrashResponse{}},
+		{Method: "POST", Path: "/api/trash/{id}/restore", Tag: "Trash", Summary: "Restore a deleted item with its original ID; 409 if something it belongs to is gone", Response: TrashItemResponse{}},
+		{Method: "DELETE", Path: "/api/trash/{id}", Tag: "Trash", Summary: "Delete an item in the trash for good", Status: http.StatusNoContent},
+
+		// Saved reports
+		{Method: "GET", Path: "/api/reports/fields", Tag: "Reports", Summary: "List the entities and fields reports can be built from", Response: []repository.ReportEntity{}},
+		{Method: "POST", Path: "/api/reports/preview", Tag: "Reports", Summary: "Run a report definition without saving it", Request: repository.ReportDefinition{}, Response: ReportRunResponse{}},
+		{Method: "GET", Path: "/api/reports", Tag: "Reports", Summary: "List saved reports", Response: []SavedReportResponse{}},
+		{Method: "POST", Path: "/api/reports", Tag: "Reports", Summary: "Save a report", Request: SavedReportRequest{}, Response: SavedReportResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/reports/{id}", Tag: "Reports", Summary: "Get a saved report", Response: SavedReportResponse{}},
+		{Method: "PUT", Path: "/api/reports/{id}", Tag: "Reports", Summary: "Update a saved report", Request: SavedReportRequest{}, Response: SavedReportResponse{}},
+		{Method: "DELETE", Path: "/api/reports/{id}", Tag: "Reports", Summary: "Delete a saved report", Status: http.StatusNoContent},
+		{Method: "GET", Path: "/api/reports/{id}/run", Tag: "Reports", Summary: "Run a saved report over its date range; format=csv or pdf downloads it", Query: []apidoc.Param{{Name: "format", Description: "json (default), csv or pdf"}}, Response: ReportRunResponse{}},
+
+		// Report schedules
+		{Method: "GET", Path: "/api/report-schedules", Tag: "Reports", Summary: "List report schedules", Response: []ReportScheduleResponse{}},
+		{Method: "POST", Path: "/api/report-schedules", Tag: "Reports", Summary: "Create a report schedule", Request: ReportScheduleRequest{}, Response: ReportScheduleResponse{}, Status: http.StatusCreated},
+		{Method: "PUT", Path: "/api/report-schedules/{id}", Tag: "Reports", Summary: "Update a report schedule", Request: ReportScheduleRequest{}, Response: ReportScheduleResponse{}},
+		{Method: "DELETE", Path: "/api/report-schedules/{id}", Tag: "Reports", Summary: "Delete a report schedule", Status: http.StatusNoContent},
+		{Method: "POST", Path: "/api/report-schedules/{id}/send", Tag: "Reports", Summary: "Send a scheduled report now", Response: anyObject{}},
+
+		// Webhooks
+		{Method: "GET", Path: "/api/webhooks", Tag: "Webhooks", Summary: "List webhooks", Response: anyObject{}},
+		{Method: "POST", Path: "/api/webhooks", Tag: "Webhooks", Summary: "Create a webhook; the secret is returned once", Request: WebhookRequest{}, Response: WebhookResponse{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/api/webhooks/{id}", Tag: "Webhooks", Summary: "Get a webhook", Response: WebhookResponse{}},
+		{Method: "PUT", Path: "/api/webhooks/{id}", Tag: "Webhooks", Summary: "Update a webhook", Request: WebhookRequest{}, Response: WebhookResponse{}},
+		{Method: "DELETE", Path: "/api/webhooks/{id}", Tag: "Webhooks", Summary: "Delete a webhook", Status: http.StatusNoContent},
+		{Method: "GET", Path: "/api/webhooks/{id}/deliveries", Tag: "Webhooks", Summary: "List a webhook's deliveries", Query: paging, Response: []WebhookDeliveryResponse{}},
+
+		// Admin
+		{Method: "GET", Path: "/api/admin/settings", Tag: "Admin", Summary: "Get SMTP and site settings", Response: AdminSettingsResponse{}, Admin: true},
+		{Method: "PUT", Path: "/api/admin/smtp", Tag: "Admin", Summary: "Update SMTP settings", Request: SMTPSettings{}, Response: anyObject{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/smtp/test", Tag: "Admin", Summary: "Send a test email", Request: TestSMTPRequest{}, Response: anyObject{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/stats", Tag: "Admin", Summary: "Site statistics", Response: SiteStats{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/site", Tag: "Admin", Summary: "Get site settings", Response: SiteSettings{}, Admin: true},
+		{Method: "PUT", Path: "/api/admin/site", Tag: "Admin", Summary: "Update site settings, including who can register (registration_mode open, invite_only or disabled)", Request: SiteSettings{}, Response: anyObject{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/users", Tag: "Admin", Summary: "List all users", Response: []UserInfo{}, Admin: true},
+		{Method: "PUT", Path: "/api/admin/users/status", Tag: "Admin", Summary: "Activate or deactivate a user", Request: UserStatusRequest{}, Response: anyObject{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/users/impersonate", Tag: "Admin", Summary: "Sign in as another user for up to an hour; what you do is audited under both of you", Request: ImpersonateRequest{}, Response: ImpersonationResponse{}, Admin: true},
+		{Method: "DELETE", Path: "/api/admin/users", Tag: "Admin", Summary: "Delete a user", Request: DeleteUserRequest{}, Response: anyObject{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/announcements", Tag: "Admin", Summary: "List all announcements, including expired ones", Response: []AnnouncementResponse{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/announcements", Tag: "Admin", Summary: "Post a site-wide announcement", Request: CreateAnnouncementRequest{}, Response: AnnouncementResponse{}, Status: http.StatusCreated, Admin: true},
+		{Method: "DELETE", Path: "/api/admin/announcements/{id}", Tag: "Admin", Summary: "Delete an announcement", Status: http.StatusNoContent, Admin: true},
+		{Method: "GET", Path: "/api/admin/maintenance", Tag: "Admin", Summary: "Get maintenance mode", Response: MaintenanceModeSettings{}, Admin: true},
+		{Method: "PUT", Path: "/api/admin/maintenance", Tag: "Admin", Summary: "Turn maintenance mode on or off", Request: MaintenanceModeSettings{}, Response: MaintenanceModeSettings{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/flags", Tag: "Admin", Summary: "List feature flags and how they're set", Response: []FeatureFlagResponse{}, Admin: true},
+		{Method: "PUT", Path: "/api/admin/flags", Tag: "Admin", Summary: "Switch a feature on or off, site-wide or for one account", Request: FeatureFlagRequest{}, Response: FeatureFlagRequest{}, Admin: true},
+		{Method: "DELETE", Path: "/api/admin/flags", Tag: "Admin", Summary: "Clear a feature flag setting", Request: FeatureFlagRequest{}, Status: http.StatusNoContent, Admin: true},
+		{Method: "GET", Path: "/api/admin/ndc-products", Tag: "Admin", Summary: "List the NDC directory used for barcode lookups", Query: []apidoc.Param{{Name: "q", Description: "Search NDC, product name and labeler"}, {Name: "limit", Description: "Default 50, at most 200"}}, Response: []NDCProductResponse{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/ndc-products", Tag: "Admin", Summary: "Add or replace NDC directory entries", Request: ImportNDCProductsRequest{}, Response: []NDCProductResponse{}, Admin: true},
+		{Method: "DELETE", Path: "/api/admin/ndc-products", Tag: "Admin", Summary: "Remove an NDC from the directory", Query: []apidoc.Param{{Name: "ndc", Required: true}}, Status: http.StatusNoContent, Admin: true},
+		{Method: "GET", Path: "/api/admin/accounts", Tag: "Admin", Summary: "List all accounts", Response: []AccountInfo{}, Admin: true},
+		{Method: "DELETE", Path: "/api/admin/accounts", Tag: "Admin", Summary: "Delete an account and its data", Request: DeleteAccountRequest{}, Response: anyObject{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/accounts/suspend", Tag: "Admin", Summary: "Suspend an account as read_only or locked, keeping its data, and tell its members", Request: SuspendAccountRequest{}, Response: anyObject{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/accounts/reactivate", Tag: "Admin", Summary: "Lift an account's suspension and tell its members; 409 if it isn't suspended", Request: ReactivateAccountRequest{}, Response: anyObject{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/accounts/usage", Tag: "Admin", Summary: "What every account is using (members, attachment storage, newest backup) next to its quota limits", Response: []AccountUsageResponse{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/quotas", Tag: "Admin", Summary: "Get the site-wide account quotas; a null limit is unlimited", Response: services.QuotaSettings{}, Admin: true},
+		{Method: "PUT", Path: "/api/admin/quotas", Tag: "Admin", Summary: "Set account quotas, site-wide or for one account; a null limit falls back to the site-wide one and 0 is unlimited", Request: QuotaRequest{}, Response: QuotaRequest{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/accounts/backups", Tag: "Admin", Summary: "List account backups", Response: []AccountBackupInfo{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/accounts/backups", Tag: "Admin", Summary: "Back up one account", Request: CreateAccountBackupRequest{}, Response: anyObject{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/accounts/backups/download", Tag: "Admin", Summary: "Download an account backup as stored", Query: []apidoc.Param{{Name: "file", Required: true}}, ResponseType: "application/octet-stream", Admin: true},
+		{Method: "DELETE", Path: "/api/admin/accounts/backups", Tag: "Admin", Summary: "Delete an account backup", Request: DeleteBackupRequest{}, Response: anyObject{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/accounts/backups/upload", Tag: "Admin", Summary: "Upload an account backup (backup file field)", RequestType: "multipart/form-data", Response: anyObject{}, Status: http.StatusCreated, Admin: true},
+		{Method: "POST", Path: "/api/admin/accounts/backups/restore", Tag: "Admin", Summary: "Restore an account backup into a new account", Request: RestoreAccountBackupRequest{}, Response: RestoreAccountBackupResponse{}, Status: http.StatusCreated, Admin: true},
+		{Method: "GET", Path: "/api/admin/backups", Tag: "Admin", Summary: "List backups", Response: []BackupInfo{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/backups", Tag: "Admin", Summary: "Create a backup", Response: anyObject{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/backups/download", Tag: "Admin", Summary: "Download a backup", Query: []apidoc.Param{{Name: "file", Required: true}}, ResponseType: "application/octet-stream", Admin: true},
+		{Method: "DELETE", Path: "/api/admin/backups", Tag: "Admin", Summary: "Delete a backup", Request: DeleteBackupRequest{}, Response: anyObject{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/backups/upload", Tag: "Admin", Summary: "Upload a backup (backup file field)", RequestType: "multipart/form-data", Response: anyObject{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/backups/restore", Tag: "Admin", Summary: "Restore a backup in place", Request: RestoreBackupRequest{}, Response: anyObject{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/backups/auto", Tag: "Admin", Summary: "Get automatic backup settings", Response: AutoBackupSettings{}, Admin: true},
+		{Method: "PUT", Path: "/api/admin/backups/auto", Tag: "Admin", Summary: "Update automatic backup settings", Request: AutoBackupSettings{}, Response: anyObject{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/backups/remote", Tag: "Admin", Summary: "List backups at the remote destination", Response: []BackupInfo{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/backups/remote/fetch", Tag: "Admin", Summary: "Download a remote backup into the backup list", Request: FetchRemoteBackupRequest{}, Response: anyObject{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/integrity", Tag: "Admin", Summary: "Check the database for corruption, orphaned rows and inventory that doesn't match its history", Response: database.IntegrityReport{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/integrity/repair", Tag: "Admin", Summary: "Delete or unlink orphaned rows and reconcile inventory history in one transaction; 409 if the database is corrupt", Response: database.IntegrityReport{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/secrets", Tag: "Admin", Summary: "Whether the secrets stored in the database are encrypted, and the fingerprints of the keys in use", Response: SecretsStatusResponse{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/secrets/rotate", Tag: "Admin", Summary: "Re-encrypt every stored secret under a new data key sealed with the current SECRETS_KEY; 409 if none is set", Response: SecretsRotateResponse{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/diagnostics", Tag: "Admin", Summary: "Database, backup, scheduler, disk and runtime diagnostics", Response: Diagnostics{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/diagnostics/bundle", Tag: "Admin", Summary: "Download a zip of the diagnostics, migration history and site settings with credentials masked", ResponseType: "application/zip", Admin: true},
+		{Method: "GET", Path: "/api/admin/audit-logs", Tag: "Admin", Summary: "List audit log entries, newest first", Query: params(auditFilter, cursorPaging), Response: ListResponse[AuditLogResponse]{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/audit-logs/export", Tag: "Admin", Summary: "Download the matching audit log entries as CSV", Query: auditFilter, ResponseType: "text/csv", Admin: true},
+		{Method: "GET", Path: "/api/admin/audit-logs/retention", Tag: "Admin", Summary: "Get the audit log retention policy", Response: services.AuditRetention{}, Admin: true},
+		{Method: "PUT", Path: "/api/admin/audit-logs/retention", Tag: "Admin", Summary: "Set how many days audit logs are kept (0 keeps them forever) and whether pruned entries are archived", Request: services.AuditRetention{}, Response: services.AuditRetention{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/audit-logs/prune", Tag: "Admin", Summary: "Apply the retention policy now", Response: AuditPruneResponse{}, Admin: true},
+		{Method: "GET", Path: "/api/admin/courses/auto-close", Tag: "Admin", Summary: "Get the policy for closing courses past their expected end date", Response: services.CourseAutoClose{}, Admin: true},
+		{Method: "PUT", Path: "/api/admin/courses/auto-close", Tag: "Admin", Summary: "Turn auto-closing on or off and set how many days past its end date a course stays open", Request: services.CourseAutoClose{}, Response: services.CourseAutoClose{}, Admin: true},
+		{Method: "POST", Path: "/api/admin/courses/auto-close/run", Tag: "Admin", Summary: "Close courses past their end date and grace period now", Response: CourseAutoCloseRunResponse{}, Admin: true},
+
+		// This document
+		{Method: "GET", Path: "/api/openapi.json", Tag: "Docs", Summary: "This OpenAPI document", Response: anyObject{}},
+	}
+}
+
+// GenerateOpenAPI returns the OpenAPI document for APIRoutes. Routes are
+// registered once under /api and documented at their versioned paths.
+func GenerateOpenAPI() ([]byte, error) {
+	routes := APIRoutes()
+	prefix := fmt.Sprintf("/api/v%d/", middleware.LatestAPIVersion)
+	for i := range routes {
+		routes[i].Path = prefix + strings.TrimPrefix(routes[i].Path, "/api/")
+	}
+	return apidoc.Generate(APIInfo, routes)
+}
+
+// HandleOpenAPISpec serves the generated OpenAPI document
+func HandleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
+
+// HandleAPIDocsPage renders Swagger UI over the OpenAPI document
+func HandleAPIDocsPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		data := getBasePageData(db, r, csrf)
+		data["Title"] = "API Documentation"
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := web.Render(w, r, "api-docs.html", data); err != nil {
+			http.Error(w, "Failed to render template", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"injection-tracker/internal/apidoc"
+)
+
+func TestOpenAPISpecUpToDate(t *testing.T) {
+	spec, err := GenerateOpenAPI()
+	if err != nil {
+		t.Fatalf("Failed to generate OpenAPI document: %v", err)
+	}
+	if !bytes.Equal(spec, openAPISpec) {
+		t.Error("openapi.json is out of date; run go generate ./internal/handlers")
+	}
+}
+
+// TestOpenAPIRoutesMatchRouter checks every /api route in internal/server is
+// documented, and nothing documented has gone away
+func TestOpenAPIRoutesMatchRouter(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "../server/server.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse server.go: %v", err)
+	}
+
+	var registered []string
+	var walk func(node ast.Node, prefix string)
+	walk = func(node ast.Node, prefix string) {
+		ast.Inspect(node, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			path, _ := strconv.Unquote(lit.Value)
+
+			switch sel.Sel.Name {
+			case "Route":
+				if len(call.Args) == 2 {
+					walk(call.Args[1], prefix+path)
+				}
+				return false
+			case "Get", "Post", "Put", "Delete", "Patch":
+				full := prefix + path
+				if len(full) > 1 {
+					full = strings.TrimSuffix(full, "/")
+				}
+				if strings.HasPrefix(full, "/api/") || full == "/api" {
+					registered = append(registered, strings.ToUpper(sel.Sel.Name)+" "+full)
+				}
+			}
+			return true
+		})
+	}
+	walk(file, "")
+	sort.Strings(registered)
+
+	if len(registered) == 0 {
+		t.Fatal("Found no /api routes in server.go")
+	}
+
+	documented := map[string]bool{}
+	for _, route := range apidoc.Paths(APIRoutes()) {
+		documented[route] = true
+	}
+	for _, route := range registered {
+		if !documented[route] {
+			t.Errorf("%s is not documented in APIRoutes", route)
+		}
+		delete(documented, route)
+	}
+	for route := range documented {
+		t.Errorf("%s is documented but not registered", route)
+	}
+}
+
+func TestHandleOpenAPISpec(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+	HandleOpenAPISpec(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Unexpected response %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte(`"openapi": "3.0.3"`)) {
+		t.Error("Expected an OpenAPI 3 document")
+	}
+}
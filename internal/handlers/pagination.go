@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+)
+
+// ListResponse is the envelope for paginated lists. Pass next_cursor back as
+// cursor to get the following page; it is null on the last page.
+type ListResponse[T any] struct {
+	Data       []T     `json:"data"`
+	Total      int64   `json:"total"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// newListResponse converts a repository page into the response envelope
+func newListResponse[T, R any](page *repository.Page[T], convert func(T) R) ListResponse[R] {
+	resp := ListResponse[R]{
+		Data:  make([]R, 0, len(page.Items)),
+		Total: page.Total,
+	}
+	for _, item := range page.Items {
+		resp.Data = append(resp.Data, convert(item))
+	}
+	if page.NextCursor != "" {
+		resp.NextCursor = &page.NextCursor
+	}
+	return resp
+}
+
+// parsePageRequest reads the limit and cursor query parameters
+func parsePageRequest(r *http.Request) (repository.PageRequest, error) {
+	page := repository.PageRequest{Cursor: r.URL.Query().Get("cursor")}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return page, errors.New("Invalid limit")
+		}
+		page.Limit = limit
+	}
+	return page, nil
+}
+
+// parseListDateRange reads the optional start_date and end_date query
+// parameters (YYYY-MM-DD, both inclusive) as days in loc. It returns the
+// half-open range [start, end), with a zero time for a missing bound.
+func parseListDateRange(r *http.Request, loc *time.Location) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if v := r.URL.Query().Get("start_date"); v != "" {
+		start, err = time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			return start, end, errors.New("Invalid start_date format, use YYYY-MM-DD")
+		}
+	}
+	if v := r.URL.Query().Get("end_date"); v != "" {
+		end, err = time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			return start, end, errors.New("Invalid end_date format, use YYYY-MM-DD")
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		return start, end, errors.New("end_date must not be before start_date")
+	}
+	return start.UTC(), end.UTC(), nil
+}
+
+// listPageError writes the response for a failed page lookup
+func listPageError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		respond.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	respond.Error(w, message, http.StatusInternalServerError)
+}
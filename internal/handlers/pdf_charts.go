@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"injection-tracker/internal/i18n"
+
+	"github.com/jung-kurt/gofpdf/v2"
+)
+
+// Simple charts drawn with gofpdf primitives for the PDF report. Each chart
+// fills the box it is given, title included. Titles and labels are given
+// translated, in UTF-8; the charts encode them for the PDF's core fonts.
+
+type chartColor struct{ R, G, B int }
+
+var (
+	chartBlue   = chartColor{63, 81, 181}
+	chartOrange = chartColor{255, 152, 0}
+	chartGreen  = chartColor{76, 175, 80}
+	chartGrid   = chartColor{220, 220, 220}
+	chartText   = chartColor{90, 90, 90}
+)
+
+type chartPoint struct {
+	X time.Time
+	Y float64
+}
+
+type chartSeries struct {
+	Label  string
+	Color  chartColor
+	Points []chartPoint // In time order
+}
+
+type chartSlice struct {
+	Label string
+	Value float64
+	Color chartColor
+}
+
+type chartBar struct {
+	Label string
+	Value float64
+	Empty bool // Nothing to show for this bar, e.g. no course was running
+}
+
+// pdfText writes the PDF report's text in the reader's language, encoded
+// for the core fonts, which are cp1252
+type pdfText struct {
+	p  *i18n.Printer
+	tr func(string) string
+}
+
+// T translates msg as Printer.T does, encoded for the PDF
+func (t pdfText) T(msg string, args ...interface{}) string {
+	return t.tr(t.p.T(msg, args...))
+}
+
+// drawChartFrame draws the title and returns the plot area below it
+func drawChartFrame(pdf *gofpdf.Fpdf, text pdfText, x, y, w, h float64, title string) (float64, float64, float64, float64) {
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetXY(x, y)
+	pdf.CellFormat(w, 6, text.tr(title), "", 0, "L", false, 0, "")
+	return x, y + 8, w, h - 8
+}
+
+// drawChartMessage writes that nothing was recorded in the middle of an
+// empty chart
+func drawChartMessage(pdf *gofpdf.Fpdf, text pdfText, x, y, w, h float64) {
+	pdf.SetFont("Arial", "I", 9)
+	pdf.SetTextColor(chartText.R, chartText.G, chartText.B)
+	pdf.SetXY(x, y+h/2-3)
+	pdf.CellFormat(w, 6, text.T("Nothing recorded in this period"), "", 0, "C", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// drawLineChart plots series over start to end with a y axis from 0 to yMax
+func drawLineChart(pdf *gofpdf.Fpdf, text pdfText, x, y, w, h float64, title string, start, end time.Time, yMax float64, series []chartSeries) {
+	x, y, w, h = drawChartFrame(pdf, text, x, y, w, h, title)
+
+	// Room for y labels on the left, x labels and legend below
+	plotX, plotY := x+8, y
+	plotW, plotH := w-10, h-14
+
+	pdf.SetLineWidth(0.2)
+	pdf.SetDrawColor(chartGrid.R, chartGrid.G, chartGrid.B)
+	pdf.SetFont("Arial", "", 7)
+	pdf.SetTextColor(chartText.R, chartText.G, chartText.B)
+	const ticks = 5
+	for i := 0; i <= ticks; i++ {
+		value := yMax * float64(i) / ticks
+		ty := plotY + plotH - plotH*float64(i)/ticks
+		pdf.Line(plotX, ty, plotX+plotW, ty)
+		pdf.SetXY(x, ty-2)
+		pdf.CellFormat(7, 4, formatDose(value), "", 0, "R", false, 0, "")
+	}
+
+	span := end.Sub(start).Seconds()
+	if span <= 0 {
+		span = 1
+	}
+	for i, t := range []time.Time{start, start.Add(end.Sub(start) / 2), end} {
+		align := []string{"L", "C", "R"}[i]
+		lx := plotX + plotW*float64(i)/2
+		switch align {
+		case "C":
+			lx -= 15
+		case "R":
+			lx -= 30
+		}
+		pdf.SetXY(lx, plotY+plotH+1)
+		pdf.CellFormat(30, 4, text.tr(text.p.MonthDay(t)), "", 0, align, false, 0, "")
+	}
+
+	plotted := false
+	for _, s := range series {
+		if len(s.Points) == 0 {
+			continue
+		}
+		plotted = true
+		pdf.SetDrawColor(s.Color.R, s.Color.G, s.Color.B)
+		pdf.SetFillColor(s.Color.R, s.Color.G, s.Color.B)
+		pdf.SetLineWidth(0.5)
+
+		var prevX, prevY float64
+		for i, p := range s.Points {
+			px := plotX + plotW*p.X.Sub(start).Seconds()/span
+			py := plotY + plotH - plotH*math.Min(p.Y, yMax)/yMax
+			if i > 0 {
+				pdf.Line(prevX, prevY, px, py)
+			}
+			pdf.Circle(px, py, 0.7, "F")
+			prevX, prevY = px, py
+		}
+	}
+
+	// Legend
+	lx := plotX
+	pdf.SetFont("Arial", "", 7)
+	for _, s := range series {
+		pdf.SetFillColor(s.Color.R, s.Color.G, s.Color.B)
+		pdf.Rect(lx, plotY+plotH+7, 3, 3, "F")
+		pdf.SetXY(lx+4, plotY+plotH+6)
+		label := text.tr(s.Label)
+		if len(s.Points) == 0 {
+			label = text.T("%s (none)", s.Label)
+		}
+		pdf.CellFormat(pdf.GetStringWidth(label)+2, 5, label, "", 0, "L", false, 0, "")
+		lx += pdf.GetStringWidth(label) + 12
+	}
+
+	if !plotted {
+		drawChartMessage(pdf, text, plotX, plotY, plotW, plotH)
+	}
+
+	pdf.SetLineWidth(0.2)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// drawPieChart draws slices as a pie with a legend beside it
+func drawPieChart(pdf *gofpdf.Fpdf, text pdfText, x, y, w, h float64, title string, slices []chartSlice) {
+	x, y, w, h = drawChartFrame(pdf, text, x, y, w, h, title)
+
+	var total float64
+	for _, s := range slices {
+		total += s.Value
+	}
+	if total <= 0 {
+		drawChartMessage(pdf, text, x, y, w, h)
+		return
+	}
+
+	r := math.Min(h, w/2) / 2
+	cx, cy := x+r+2, y+h/2
+
+	angle := -math.Pi / 2 // Start at 12 o'clock
+	for _, s := range slices {
+		if s.Value <= 0 {
+			continue
+		}
+		sweep := 2 * math.Pi * s.Value / total
+		points := []gofpdf.PointType{{X: cx, Y: cy}}
+		steps := int(math.Ceil(sweep / (math.Pi / 90))) // About 2 degrees each
+		for i := 0; i <= steps; i++ {
+			a := angle + sweep*float64(i)/float64(steps)
+			points = append(points, gofpdf.PointType{X: cx + r*math.Cos(a), Y: cy + r*math.Sin(a)})
+		}
+		pdf.SetFillColor(s.Color.R, s.Color.G, s.Color.B)
+		pdf.Polygon(points, "F")
+		angle += sweep
+	}
+
+	pdf.SetFont("Arial", "", 8)
+	ly := cy - float64(len(slices))*3
+	for _, s := range slices {
+		pdf.SetFillColor(s.Color.R, s.Color.G, s.Color.B)
+		pdf.Rect(cx+r+5, ly+1, 3, 3, "F")
+		pdf.SetXY(cx+r+9, ly)
+		pdf.CellFormat(w-2*r-10, 5, fmt.Sprintf("%s: %s (%.0f%%)", text.tr(s.Label), formatDose(s.Value), s.Value*100/total), "", 0, "L", false, 0, "")
+		ly += 6
+	}
+}
+
+// drawBarChart draws one bar per entry on an axis from 0 to yMax, each
+// labelled with its value and unit
+func drawBarChart(pdf *gofpdf.Fpdf, text pdfText, x, y, w, h float64, title string, bars []chartBar, yMax float64, unit string) {
+	x, y, w, h = drawChartFrame(pdf, text, x, y, w, h, title)
+	if len(bars) == 0 {
+		drawChartMessage(pdf, text, x, y, w, h)
+		return
+	}
+
+	plotX, plotY := x, y+4
+	plotW, plotH := w, h-10
+
+	pdf.SetLineWidth(0.2)
+	pdf.SetDrawColor(chartGrid.R, chartGrid.G, chartGrid.B)
+	pdf.Line(plotX, plotY+plotH, plotX+plotW, plotY+plotH)
+
+	slot := plotW / float64(len(bars))
+	barW := slot * 0.7
+	for i, b := range bars {
+		bx := plotX + slot*float64(i) + (slot-barW)/2
+
+		pdf.SetFont("Arial", "", 6)
+		pdf.SetTextColor(chartText.R, chartText.G, chartText.B)
+		pdf.SetXY(plotX+slot*float64(i), plotY+plotH+1)
+		pdf.CellFormat(slot, 3, text.tr(b.Label), "", 0, "C", false, 0, "")
+
+		label := "-"
+		if !b.Empty {
+			bh := plotH * math.Min(b.Value, yMax) / yMax
+			pdf.SetFillColor(chartGreen.R, chartGreen.G, chartGreen.B)
+			pdf.Rect(bx, plotY+plotH-bh, barW, bh, "F")
+			label = fmt.Sprintf("%.0f%s", b.Value, unit)
+		}
+		pdf.SetXY(plotX+slot*float64(i), plotY+plotH-plotH*math.Min(b.Value, yMax)/yMax-4)
+		pdf.CellFormat(slot, 3, label, "", 0, "C", false, 0, "")
+	}
+
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetTextColor(0, 0, 0)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/respond"
+)
+
+// PreconditionFailedResponse is the 412 body when a record changed since the
+// client loaded it: the error, plus the record as it is now so the client
+// can merge or ask the user
+type PreconditionFailedResponse struct {
+	Error   respond.ErrorBody `json:"error"`
+	Current interface{}       `json:"current"`
+}
+
+// versionETag is the entity tag for a record last changed at updatedAt. It
+// keeps the full precision of updated_at, so two edits in the same second
+// still get different tags; If-Unmodified-Since only resolves to the second.
+// A zero time (settings that were never saved) has the tag "0".
+func versionETag(updatedAt time.Time) string {
+	if updatedAt.IsZero() {
+		return `"0"`
+	}
+	return fmt.Sprintf(`"%x"`, updatedAt.UnixNano())
+}
+
+// setVersionHeaders sets ETag and Last-Modified for a record
+func setVersionHeaders(w http.ResponseWriter, updatedAt time.Time) {
+	w.Header().Set("ETag", versionETag(updatedAt))
+	if !updatedAt.IsZero() {
+		w.Header().Set("Last-Modified", updatedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// checkPreconditions checks If-Match, or else If-Unmodified-Since, against
+// the updated_at of the record a request changes. If the record has changed
+// it answers 412 with current; if neither header is sent and required is
+// set it answers 428. It reports whether the change may go ahead.
+func checkPreconditions(w http.ResponseWriter, r *http.Request, updatedAt time.Time, required bool, current func() interface{}) bool {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if etagMatches(ifMatch, versionETag(updatedAt)) {
+			return true
+		}
+	} else if ifUnmodified := r.Header.Get("If-Unmodified-Since"); ifUnmodified != "" {
+		since, err := http.ParseTime(ifUnmodified)
+		if err != nil {
+			respond.Validation(w, "", respond.Field("If-Unmodified-Since", "must be an HTTP date"))
+			return false
+		}
+		if !updatedAt.Truncate(time.Second).After(since) {
+			return true
+		}
+	} else if required {
+		respond.Error(w, "Send If-Match with the ETag from GET, or If-Unmodified-Since, so changes made by others aren't overwritten", http.StatusPreconditionRequired)
+		return false
+	} else {
+		return true
+	}
+
+	respondPreconditionFailed(w, updatedAt, current())
+	return false
+}
+
+// respondPreconditionFailed answers 412 with a record as it is now, last
+// changed at updatedAt. Handlers also use it when the repository returns
+// repository.ErrStale: someone else saved between checkPreconditions and
+// the write.
+func respondPreconditionFailed(w http.ResponseWriter, updatedAt time.Time, current interface{}) {
+	setVersionHeaders(w, updatedAt)
+	respond.JSON(w, http.StatusPreconditionFailed, PreconditionFailedResponse{
+		Error: respond.ErrorBody{
+			Code:    respond.CodePreconditionFailed,
+			Message: "This was changed by someone else after you loaded it. Reload to see their changes.",
+		},
+		Current: current,
+	})
+}
+
+// etagMatches reports whether an If-Match header matches etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	updatedAt := time.Date(2026, 3, 4, 10, 30, 15, 500000000, time.UTC)
+	current := func() interface{} { return map[string]int{"id": 7} }
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		required bool
+		ok       bool
+		status   int
+	}{
+		{"matching ETag", map[string]string{"If-Match": versionETag(updatedAt)}, true, true, 0},
+		{"one of several ETags", map[string]string{"If-Match": `"1", ` + versionETag(updatedAt)}, true, true, 0},
+		{"wildcard", map[string]string{"If-Match": "*"}, true, true, 0},
+		{"stale ETag", map[string]string{"If-Match": versionETag(updatedAt.Add(-time.Millisecond))}, true, false, http.StatusPreconditionFailed},
+		{"unmodified since the same second", map[string]string{"If-Unmodified-Since": "Wed, 04 Mar 2026 10:30:15 GMT"}, true, true, 0},
+		{"modified since", map[string]string{"If-Unmodified-Since": "Wed, 04 Mar 2026 10:30:14 GMT"}, true, false, http.StatusPreconditionFailed},
+		{"If-Match wins", map[string]string{"If-Match": `"1"`, "If-Unmodified-Since": "Wed, 04 Mar 2026 11:00:00 GMT"}, true, false, http.StatusPreconditionFailed},
+		{"bad date", map[string]string{"If-Unmodified-Since": "yesterday"}, true, false, http.StatusBadRequest},
+		{"missing when required", nil, true, false, http.StatusPreconditionRequired},
+		{"missing when optional", nil, false, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/api/injections/7", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+
+			if ok := checkPreconditions(rec, req, updatedAt, tt.required, current); ok != tt.ok {
+				t.Fatalf("Expected %v, got %v", tt.ok, ok)
+			}
+			if tt.ok {
+				if rec.Body.Len() != 0 {
+					t.Errorf("Expected nothing written, got %s", rec.Body.String())
+				}
+				return
+			}
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.status != http.StatusPreconditionFailed {
+				return
+			}
+
+			var body PreconditionFailedResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode 412 body: %v", err)
+			}
+			if body.Error.Code != "precondition_failed" || body.Current == nil {
+				t.Errorf("Unexpected 412 body %s", rec.Body.String())
+			}
+			if rec.Header().Get("ETag") != versionETag(updatedAt) {
+				t.Errorf("Expected the current ETag, got %q", rec.Header().Get("ETag"))
+			}
+		})
+	}
+}
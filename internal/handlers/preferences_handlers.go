@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/validate"
+
+	"golang.org/x/text/language"
+)
+
+// Accepted values of the display preferences
+var (
+	validDateFormats = map[string]bool{"MM/DD/YYYY": true, "DD/MM/YYYY": true, "YYYY-MM-DD": true}
+	validTimeFormats = map[string]bool{"12h": true, "24h": true}
+	validUnits       = map[string]bool{"metric": true, "imperial": true}
+	validWeekStarts  = map[string]bool{"sunday": true, "monday": true}
+	validThemes      = map[string]bool{"auto": true, "light": true, "dark": true}
+)
+
+// PreferencesResponse is a user's display preferences
+type PreferencesResponse struct {
+	Timezone   string    `json:"timezone"`    // IANA name
+	Locale     string    `json:"locale"`      // BCP 47 tag
+	DateFormat string    `json:"date_format"` // MM/DD/YYYY, DD/MM/YYYY or YYYY-MM-DD
+	TimeFormat string    `json:"time_format"` // 12h or 24h
+	Units      string    `json:"units"`       // metric or imperial
+	WeekStart  string    `json:"week_start"`  // sunday or monday
+	Theme      string    `json:"theme"`       // auto, light or dark
+	UpdatedAt  time.Time `json:"updated_at"`  // Zero until first saved
+}
+
+// UpdatePreferencesRequest changes some of a user's display preferences.
+// Fields left out keep their value.
+type UpdatePreferencesRequest struct {
+	Timezone   *string `json:"timezone,omitempty" validate:"max=64"`
+	Locale     *string `json:"locale,omitempty" validate:"max=35"`
+	DateFormat *string `json:"date_format,omitempty"`
+	TimeFormat *string `json:"time_format,omitempty"`
+	Units      *string `json:"units,omitempty"`
+	WeekStart  *string `json:"week_start,omitempty"`
+	Theme      *string `json:"theme,omitempty"`
+}
+
+func toPreferencesResponse(p *models.UserPreferences) PreferencesResponse {
+	return PreferencesResponse{
+		Timezone:   p.Timezone,
+		Locale:     p.Locale,
+		DateFormat: p.DateFormat,
+		TimeFormat: p.TimeFormat,
+		Units:      p.Units,
+		WeekStart:  p.WeekStart,
+		Theme:      p.Theme,
+		UpdatedAt:  p.UpdatedAt,
+	}
+}
+
+// preferenceValues lists p's preferences by their names in the API, as
+// account data exports carry them
+func preferenceValues(p *models.UserPreferences) map[string]string {
+	return map[string]string{
+		"timezone":    p.Timezone,
+		"locale":      p.Locale,
+		"date_format": p.DateFormat,
+		"time_format": p.TimeFormat,
+		"units":       p.Units,
+		"week_start":  p.WeekStart,
+		"theme":       p.Theme,
+	}
+}
+
+// preferencesUpdate is the update setting the preferences named in values,
+// and how many there are
+func preferencesUpdate(values map[string]string) (UpdatePreferencesRequest, int) {
+	var req UpdatePreferencesRequest
+	n := 0
+	for name, dest := range map[string]**string{
+		"timezone":    &req.Timezone,
+		"locale":      &req.Locale,
+		"date_format": &req.DateFormat,
+		"time_format": &req.TimeFormat,
+		"units":       &req.Units,
+		"week_start":  &req.WeekStart,
+		"theme":       &req.Theme,
+	} {
+		if value, ok := values[name]; ok {
+			*dest = &value
+			n++
+		}
+	}
+	return req, n
+}
+
+// applyPreferences copies the fields set in req onto p, checking each, and
+// returns the ones that were rejected. The locale is stored in its
+// canonical form.
+func applyPreferences(p *models.UserPreferences, req *UpdatePreferencesRequest) []respond.FieldError {
+	var v validate.Validator
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" {
+			v.Add("timezone", "is not a known time zone")
+		} else {
+			p.Timezone = *req.Timezone
+		}
+	}
+	if req.Locale != nil {
+		if tag, err := language.Parse(*req.Locale); err != nil {
+			v.Add("locale", "is not a valid language tag, such as en-US")
+		} else {
+			p.Locale = tag.String()
+		}
+	}
+	for _, f := range []struct {
+		name  string
+		value *string
+		valid map[string]bool
+		dest  *string
+		msg   string
+	}{
+		{"date_format", req.DateFormat, validDateFormats, &p.DateFormat, "must be MM/DD/YYYY, DD/MM/YYYY or YYYY-MM-DD"},
+		{"time_format", req.TimeFormat, validTimeFormats, &p.TimeFormat, "must be 12h or 24h"},
+		{"units", req.Units, validUnits, &p.Units, "must be metric or imperial"},
+		{"week_start", req.WeekStart, validWeekStarts, &p.WeekStart, "must be sunday or monday"},
+		{"theme", req.Theme, validThemes, &p.Theme, "must be auto, light or dark"},
+	} {
+		if f.value == nil {
+			continue
+		}
+		if !f.valid[*f.value] {
+			v.Add(f.name, f.msg)
+			continue
+		}
+		*f.dest = *f.value
+	}
+	return v.Errors()
+}
+
+// HandleGetPreferences returns the user's display preferences as one
+// document, with an ETag so clients can cache it and revalidate with
+// If-None-Match
+func HandleGetPreferences(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		prefs, err := repository.NewSettingsRepository(db).GetPreferences(userID)
+		if err != nil {
+			respond.Error(w, "Failed to get preferences", http.StatusInternalServerError)
+			return
+		}
+
+		setVersionHeaders(w, prefs.UpdatedAt)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, versionETag(prefs.UpdatedAt)) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		respondJSON(w, http.StatusOK, toPreferencesResponse(prefs))
+	}
+}
+
+// HandleUpdatePreferences changes the user's display preferences. Like the
+// settings, it needs If-Match or If-Unmodified-Since so a change made on
+// another device isn't overwritten.
+func HandleUpdatePreferences(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req UpdatePreferencesRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		settingsRepo := repository.NewSettingsRepository(db)
+		prefs, err := settingsRepo.GetPreferences(userID)
+		if err != nil {
+			respond.Error(w, "Failed to get preferences", http.StatusInternalServerError)
+			return
+		}
+		version := prefs.UpdatedAt
+		current := toPreferencesResponse(prefs)
+		if !checkPreconditions(w, r, version, true, func() interface{} { return current }) {
+			return
+		}
+
+		if fields := applyPreferences(prefs, &req); len(fields) > 0 {
+			respond.Validation(w, "", fields...)
+			return
+		}
+		if err := settingsRepo.UpdatePreferences(prefs, version); err != nil {
+			if err == repository.ErrStale {
+				if latest, err := settingsRepo.GetPreferences(userID); err == nil {
+					respondPreconditionFailed(w, latest.UpdatedAt, toPreferencesResponse(latest))
+					return
+				}
+			}
+			respond.Error(w, "Failed to save preferences", http.StatusInternalServerError)
+			return
+		}
+
+		setVersionHeaders(w, prefs.UpdatedAt)
+		respondJSON(w, http.StatusOK, toPreferencesResponse(prefs))
+	}
+}
+
+// Localize picks the language a request is answered in and puts it in the
+// request context for pages, fragments and emails: the signed-in user's
+// saved locale, or for visitors and users who never saved their
+// preferences, the browser's Accept-Language. Run it after authentication.
+func Localize(db *database.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var locale string
+			if userID := middleware.GetUserID(r.Context()); userID != 0 {
+				prefs, err := repository.NewSettingsRepository(db.WithContext(r.Context())).GetPreferences(userID)
+				if err == nil && !prefs.UpdatedAt.IsZero() {
+					locale = prefs.Locale
+				}
+			}
+			p := i18n.Negotiate(locale, r.Header.Get("Accept-Language"))
+			w.Header().Add("Vary", "Accept-Language")
+			w.Header().Set("Content-Language", p.Lang())
+			next.ServeHTTP(w, r.WithContext(i18n.WithPrinter(r.Context(), p)))
+		})
+	}
+}
@@ -0,0 +1,659 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PurchaseOrderItemRequest is one line of an order
+type PurchaseOrderItemRequest struct {
+	ItemType       string        `json:"item_type" validate:"max=50"`
+	Quantity       float64       `json:"quantity"`
+	UnitCost       float64       `json:"unit_cost" validate:"min=0"`
+	LotNumber      *string       `json:"lot_number,omitempty" validate:"max=100"`
+	ExpirationDate *FlexibleDate `json:"expiration_date,omitempty"`
+}
+
+// PurchaseOrderRequest is the payload for creating or updating an order.
+// Items, when given, replace the order's lines.
+type PurchaseOrderRequest struct {
+	Supplier        *string                     `json:"supplier,omitempty" validate:"max=200"`
+	OrderNumber     *string                     `json:"order_number,omitempty" validate:"max=100"`
+	Status          *string                     `json:"status,omitempty"` // ordered or cancelled
+	OrderedAt       *FlexibleDate               `json:"ordered_at,omitempty"`
+	ExpectedArrival *FlexibleDate               `json:"expected_arrival,omitempty"`
+	ShippingCost    *float64                    `json:"shipping_cost,omitempty" validate:"min=0"`
+	Notes           *string                     `json:"notes,omitempty" validate:"multiline,max=2000"`
+	Items           *[]PurchaseOrderItemRequest `json:"items,omitempty" validate:"max=100"`
+}
+
+// ReceivePurchaseOrderRequest is the optional payload for receiving an order
+type ReceivePurchaseOrderRequest struct {
+	ReceivedAt *FlexibleDate `json:"received_at,omitempty"`
+}
+
+// PurchaseOrderItemResponse is an order line as returned by the API
+type PurchaseOrderItemResponse struct {
+	ID             int64      `json:"id"`
+	ItemType       string     `json:"item_type"`
+	Quantity       float64    `json:"quantity"`
+	UnitCost       float64    `json:"unit_cost"`
+	LineTotal      float64    `json:"line_total"`
+	LotNumber      *string    `json:"lot_number,omitempty"`
+	ExpirationDate *time.Time `json:"expiration_date,omitempty"`
+	LotID          *int64     `json:"lot_id,omitempty"`
+}
+
+// PurchaseOrderResponse is an order as returned by the API
+type PurchaseOrderResponse struct {
+	ID              int64                       `json:"id"`
+	Supplier        string                      `json:"supplier"`
+	OrderNumber     *string                     `json:"order_number,omitempty"`
+	Status          string                      `json:"status"`
+	OrderedAt       time.Time                   `json:"ordered_at"`
+	ExpectedArrival *time.Time                  `json:"expected_arrival,omitempty"`
+	ReceivedAt      *time.Time                  `json:"received_at,omitempty"`
+	ShippingCost    float64                     `json:"shipping_cost"`
+	TotalCost       float64                     `json:"total_cost"`
+	Notes           *string                     `json:"notes,omitempty"`
+	Items           []PurchaseOrderItemResponse `json:"items"`
+	CreatedAt       time.Time                   `json:"created_at"`
+	UpdatedAt       time.Time                   `json:"updated_at"`
+}
+
+// roundCurrency rounds an amount to cents
+func roundCurrency(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func toPurchaseOrderResponse(o *models.PurchaseOrder) PurchaseOrderResponse {
+	resp := PurchaseOrderResponse{
+		ID:           o.ID,
+		Supplier:     o.Supplier,
+		Status:       o.Status,
+		OrderedAt:    o.OrderedAt,
+		ShippingCost: o.ShippingCost,
+		TotalCost:    roundCurrency(o.TotalCost()),
+		Items:        make([]PurchaseOrderItemResponse, 0, len(o.Items)),
+		CreatedAt:    o.CreatedAt,
+		UpdatedAt:    o.UpdatedAt,
+	}
+	if o.OrderNumber.Valid {
+		resp.OrderNumber = &o.OrderNumber.String
+	}
+	if o.ExpectedArrival.Valid {
+		resp.ExpectedArrival = &o.ExpectedArrival.Time
+	}
+	if o.ReceivedAt.Valid {
+		resp.ReceivedAt = &o.ReceivedAt.Time
+	}
+	if o.Notes.Valid {
+		resp.Notes = &o.Notes.String
+	}
+	for _, item := range o.Items {
+		line := PurchaseOrderItemResponse{
+			ID:        item.ID,
+			ItemType:  item.ItemType,
+			Quantity:  item.Quantity,
+			UnitCost:  item.UnitCost,
+			LineTotal: roundCurrency(item.Quantity * item.UnitCost),
+			LotID:     nullInt64ToInt(item.LotID),
+		}
+		if item.LotNumber.Valid {
+			line.LotNumber = &item.LotNumber.String
+		}
+		if item.ExpirationDate.Valid {
+			line.ExpirationDate = &item.ExpirationDate.Time
+		}
+		resp.Items = append(resp.Items, line)
+	}
+	return resp
+}
+
+// applyPurchaseOrderRequest validates req and copies the provided fields onto
+// o, returning a client-facing error message on failure
+func applyPurchaseOrderRequest(db *database.DB, o *models.PurchaseOrder, req *PurchaseOrderRequest) string {
+	if req.Supplier != nil {
+		if *req.Supplier == "" {
+			return "supplier is required"
+		}
+		o.Supplier = *req.Supplier
+	}
+	if req.OrderNumber != nil {
+		o.OrderNumber = sql.NullString{String: *req.OrderNumber, Valid: *req.OrderNumber != ""}
+	}
+	if req.Status != nil {
+		if *req.Status != "ordered" && *req.Status != "cancelled" {
+			return "status must be 'ordered' or 'cancelled'; use the receive endpoint to receive an order"
+		}
+		o.Status = *req.Status
+	}
+	if req.OrderedAt != nil {
+		o.OrderedAt = req.OrderedAt.Time
+	}
+	if req.ExpectedArrival != nil {
+		o.ExpectedArrival = sql.NullTime{Time: req.ExpectedArrival.Time, Valid: true}
+	}
+	if req.ShippingCost != nil {
+		o.ShippingCost = *req.ShippingCost
+	}
+	if req.Notes != nil {
+		o.Notes = optionalText(req.Notes)
+	}
+	if req.Items != nil {
+		if len(*req.Items) == 0 {
+			return "an order needs at least one item"
+		}
+		items := make([]models.PurchaseOrderItem, 0, len(*req.Items))
+		for _, line := range *req.Items {
+			if !isValidItemType(db, o.AccountID, line.ItemType) {
+				return fmt.Sprintf("unknown item_type %q", line.ItemType)
+			}
+			if line.Quantity <= 0 {
+				return "item quantity must be greater than 0"
+			}
+			item := models.PurchaseOrderItem{
+				ItemType: line.ItemType,
+				Quantity: line.Quantity,
+				UnitCost: line.UnitCost,
+			}
+			if line.LotNumber != nil && *line.LotNumber != "" {
+				item.LotNumber = sql.NullString{String: *line.LotNumber, Valid: true}
+			}
+			if line.ExpirationDate != nil {
+				item.ExpirationDate = sql.NullTime{Time: line.ExpirationDate.Time, Valid: true}
+			}
+			items = append(items, item)
+		}
+		o.Items = items
+	}
+	return ""
+}
+
+// getPurchaseOrderFromRequest loads the {id} order for the current account,
+// writing an error response and returning nil if it cannot
+func getPurchaseOrderFromRequest(db *database.DB, w http.ResponseWriter, r *http.Request) *models.PurchaseOrder {
+	accountID := middleware.GetAccountID(r.Context())
+	if middleware.GetUserID(r.Context()) == 0 || accountID == 0 {
+		respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respond.Error(w, "Invalid purchase order ID", http.StatusBadRequest)
+		return nil
+	}
+
+	order, err := repository.NewPurchaseRepository(db).GetByID(id, accountID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			respond.Error(w, "Purchase order not found", http.StatusNotFound)
+			return nil
+		}
+		respond.Error(w, "Failed to retrieve purchase order", http.StatusInternalServerError)
+		return nil
+	}
+	return order
+}
+
+// HandleGetPurchaseOrders lists the account's orders, optionally by ?status=
+func HandleGetPurchaseOrders(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		status := r.URL.Query().Get("status")
+		if status != "" && status != "ordered" && status != "received" && status != "cancelled" {
+			respond.Validation(w, "status must be ordered, received or cancelled", respond.Field("status", "must be ordered, received or cancelled"))
+			return
+		}
+
+		orders, err := repository.NewPurchaseRepository(db).List(accountID, status)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve purchase orders", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]PurchaseOrderResponse, 0, len(orders))
+		for _, o := range orders {
+			resp = append(resp, toPurchaseOrderResponse(o))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// HandleGetPurchaseOrder returns a single order with its items
+func HandleGetPurchaseOrder(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		order := getPurchaseOrderFromRequest(db, w, r)
+		if order == nil {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toPurchaseOrderResponse(order))
+	}
+}
+
+// HandleCreatePurchaseOrder records a new order
+func HandleCreatePurchaseOrder(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req PurchaseOrderRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Supplier == nil {
+			respond.Validation(w, "supplier is required", respond.Field("supplier", "is required"))
+			return
+		}
+		if req.Items == nil {
+			respond.Error(w, "an order needs at least one item", http.StatusBadRequest)
+			return
+		}
+
+		order := &models.PurchaseOrder{
+			AccountID: accountID,
+			Status:    "ordered",
+			OrderedAt: time.Now(),
+			CreatedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if msg := applyPurchaseOrderRequest(db, order, &req); msg != "" {
+			respond.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewPurchaseRepository(db).Create(order); err != nil {
+			respond.Error(w, "Failed to create purchase order", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"purchase_order",
+			sql.NullInt64{Int64: order.ID, Valid: true},
+			map[string]interface{}{
+				"supplier":   order.Supplier,
+				"items":      len(order.Items),
+				"total_cost": roundCurrency(order.TotalCost()),
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(toPurchaseOrderResponse(order))
+	}
+}
+
+// HandleUpdatePurchaseOrder edits or cancels an order that has not been received
+func HandleUpdatePurchaseOrder(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		order := getPurchaseOrderFromRequest(db, w, r)
+		if order == nil {
+			return
+		}
+		if order.Status == "received" {
+			respond.Error(w, "Received orders cannot be changed", http.StatusConflict)
+			return
+		}
+
+		var req PurchaseOrderRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if msg := applyPurchaseOrderRequest(db, order, &req); msg != "" {
+			respond.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewPurchaseRepository(db).Update(order); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Purchase order not found or already received", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to update purchase order", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"purchase_order",
+			sql.NullInt64{Int64: order.ID, Valid: true},
+			map[string]interface{}{"status": order.Status},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		order.UpdatedAt = time.Now()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toPurchaseOrderResponse(order))
+	}
+}
+
+// HandleDeletePurchaseOrder deletes an order that has not been received.
+// Received orders are kept since their stock is part of inventory history.
+func HandleDeletePurchaseOrder(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		order := getPurchaseOrderFromRequest(db, w, r)
+		if order == nil {
+			return
+		}
+		if order.Status == "received" {
+			respond.Error(w, "Received orders cannot be deleted", http.StatusConflict)
+			return
+		}
+
+		if err := repository.NewPurchaseRepository(db).Delete(order.ID, order.AccountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Purchase order not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete purchase order", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"purchase_order",
+			sql.NullInt64{Int64: order.ID, Valid: true},
+			map[string]interface{}{"supplier": order.Supplier},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleReceivePurchaseOrder marks an order received and adds each item to
+// inventory as a restock, creating a lot per line
+func HandleReceivePurchaseOrder(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		order := getPurchaseOrderFromRequest(db, w, r)
+		if order == nil {
+			return
+		}
+		if order.Status != "ordered" {
+			respond.Error(w, fmt.Sprintf("Order is already %s", order.Status), http.StatusConflict)
+			return
+		}
+
+		var req ReceivePurchaseOrderRequest
+		if r.ContentLength != 0 {
+			if !decodeRequest(w, r, &req) {
+				return
+			}
+		}
+		receivedAt := time.Now()
+		if req.ReceivedAt != nil {
+			receivedAt = req.ReceivedAt.Time
+		}
+
+		// Resolve item types up front so nothing reads outside the transaction
+		itemTypeDefs := make(map[string]*models.InventoryItemType, len(order.Items))
+		for _, item := range order.Items {
+			def := lookupInventoryItemType(db, order.AccountID, item.ItemType)
+			if def == nil {
+				respond.Error(w, fmt.Sprintf("Item type %q no longer exists", item.ItemType), http.StatusConflict)
+				return
+			}
+			itemTypeDefs[item.ItemType] = def
+		}
+
+		tx, err := db.BeginTx()
+		if err != nil {
+			respond.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		for i := range order.Items {
+			item := &order.Items[i]
+			itemTypeDef := itemTypeDefs[item.ItemType]
+
+			currentQty, err := ensureInventoryItem(tx, order.AccountID, itemTypeDef)
+			if err != nil {
+				respond.Error(w, fmt.Sprintf("Failed to get inventory for %s", item.ItemType), http.StatusInternalServerError)
+				return
+			}
+			newQty := currentQty + item.Quantity
+
+			if _, err := tx.Exec(`
+				UPDATE inventory_items SET quantity = ?, updated_at = ? WHERE item_type = ? AND account_id = ?
+			`, newQty, time.Now(), item.ItemType, order.AccountID); err != nil {
+				respond.Error(w, fmt.Sprintf("Failed to update inventory for %s", item.ItemType), http.StatusInternalServerError)
+				return
+			}
+
+			lot := &models.InventoryLot{
+				AccountID:        order.AccountID,
+				ItemType:         item.ItemType,
+				LotNumber:        item.LotNumber,
+				ExpirationDate:   item.ExpirationDate,
+				QuantityReceived: item.Quantity,
+				ReceivedAt:       receivedAt,
+				Notes:            sql.NullString{String: fmt.Sprintf("Purchase order #%d", order.ID), Valid: true},
+			}
+			if err := repository.ReceiveLot(tx, lot); err != nil {
+				respond.Error(w, fmt.Sprintf("Failed to record lot for %s", item.ItemType), http.StatusInternalServerError)
+				return
+			}
+			item.LotID = sql.NullInt64{Int64: lot.ID, Valid: true}
+
+			_, err = tx.Exec(`
+				INSERT INTO inventory_history (
+					account_id, item_type, change_amount, quantity_before, quantity_after,
+					reason, reference_id, reference_type, performed_by, timestamp, notes
+				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`,
+				order.AccountID,
+				item.ItemType,
+				item.Quantity,
+				currentQty,
+				newQty,
+				"restock",
+				order.ID,
+				"purchase",
+				userID,
+				time.Now(),
+				fmt.Sprintf("Received from %s (purchase order #%d)", order.Supplier, order.ID),
+			)
+			if err != nil {
+				respond.Error(w, "Failed to log inventory restock", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := repository.MarkPurchaseOrderReceived(tx, order, receivedAt); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Order was changed by another request", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to mark order received", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			respond.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		inventoryRepo := repository.NewInventoryRepository(db)
+		for _, item := range order.Items {
+			if stock, err := inventoryRepo.GetByType(item.ItemType, order.AccountID); err == nil {
+				checkStockAlert(db, order.AccountID, stock)
+			}
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"receive",
+			"purchase_order",
+			sql.NullInt64{Int64: order.ID, Valid: true},
+			map[string]interface{}{
+				"supplier": order.Supplier,
+				"items":    len(order.Items),
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(toPurchaseOrderResponse(order)); err != nil {
+			middleware.Log(r.Context()).Error("Failed to encode purchase order", "err", err)
+		}
+	}
+}
+
+// PurchaseSpendResponse reports spend on orders over a date range
+type PurchaseSpendResponse struct {
+	StartDate    string              `json:"start_date"`
+	EndDate      string              `json:"end_date"`
+	Orders       int                 `json:"orders"`
+	ItemCost     float64             `json:"item_cost"`
+	ShippingCost float64             `json:"shipping_cost"`
+	TotalCost    float64             `json:"total_cost"`
+	ByMonth      []PurchaseSpendLine `json:"by_month"`
+	ByItemType   []PurchaseSpendLine `json:"by_item_type"`
+}
+
+// PurchaseSpendLine is the spend for one month or item type
+type PurchaseSpendLine struct {
+	Month        string   `json:"month,omitempty"`
+	ItemType     string   `json:"item_type,omitempty"`
+	Orders       int      `json:"orders"`
+	Quantity     *float64 `json:"quantity,omitempty"`
+	ItemCost     float64  `json:"item_cost"`
+	ShippingCost *float64 `json:"shipping_cost,omitempty"`
+	TotalCost    *float64 `json:"total_cost,omitempty"`
+}
+
+// HandleGetPurchaseSpend reports spend by month and by item type for orders
+// placed between start_date and end_date (default: the last 12 months).
+// Cancelled orders are excluded.
+func HandleGetPurchaseSpend(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		end := time.Now()
+		start := end.AddDate(-1, 0, 0)
+		if v := r.URL.Query().Get("start_date"); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				respond.Validation(w, "Invalid start_date format, use YYYY-MM-DD", respond.Field("start_date", "invalid format, use YYYY-MM-DD"))
+				return
+			}
+			start = t
+		}
+		if v := r.URL.Query().Get("end_date"); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				respond.Validation(w, "Invalid end_date format, use YYYY-MM-DD", respond.Field("end_date", "invalid format, use YYYY-MM-DD"))
+				return
+			}
+			end = t
+		}
+		if end.Before(start) {
+			respond.Validation(w, "end_date must not be before start_date", respond.Field("end_date", "must not be before start_date"))
+			return
+		}
+
+		purchaseRepo := repository.NewPurchaseRepository(db)
+		byMonth, err := purchaseRepo.SpendByMonth(accountID, start, end)
+		if err != nil {
+			respond.Error(w, "Failed to calculate spend", http.StatusInternalServerError)
+			return
+		}
+		byItemType, err := purchaseRepo.SpendByItemType(accountID, start, end)
+		if err != nil {
+			respond.Error(w, "Failed to calculate spend", http.StatusInternalServerError)
+			return
+		}
+
+		resp := PurchaseSpendResponse{
+			StartDate:  start.Format("2006-01-02"),
+			EndDate:    end.Format("2006-01-02"),
+			ByMonth:    make([]PurchaseSpendLine, 0, len(byMonth)),
+			ByItemType: make([]PurchaseSpendLine, 0, len(byItemType)),
+		}
+		for _, s := range byMonth {
+			shipping := roundCurrency(s.ShippingCost)
+			total := roundCurrency(s.ItemCost + s.ShippingCost)
+			resp.ByMonth = append(resp.ByMonth, PurchaseSpendLine{
+				Month:        s.Key,
+				Orders:       s.Orders,
+				ItemCost:     roundCurrency(s.ItemCost),
+				ShippingCost: &shipping,
+				TotalCost:    &total,
+			})
+			resp.Orders += s.Orders
+			resp.ItemCost += s.ItemCost
+			resp.ShippingCost += s.ShippingCost
+		}
+		for _, s := range byItemType {
+			quantity := s.Quantity
+			resp.ByItemType = append(resp.ByItemType, PurchaseSpendLine{
+				ItemType: s.Key,
+				Orders:   s.Orders,
+				Quantity: &quantity,
+				ItemCost: roundCurrency(s.ItemCost),
+			})
+		}
+		resp.TotalCost = roundCurrency(resp.ItemCost + resp.ShippingCost)
+		resp.ItemCost = roundCurrency(resp.ItemCost)
+		resp.ShippingCost = roundCurrency(resp.ShippingCost)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
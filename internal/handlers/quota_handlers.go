@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// QuotaRequest sets the limits on accounts: site-wide when AccountID is
+// zero, for that account otherwise. A limit left out isn't set at that
+// level, so leaving them all out clears an account's own limits.
+type QuotaRequest struct {
+	AccountID int64 `json:"account_id,omitempty"`
+	services.QuotaSettings
+}
+
+// AccountUsageResponse is what an account is using next to its limits.
+// Quota is the account's own limits; Limits are the ones that apply to it,
+// with the site-wide limits filled in.
+type AccountUsageResponse struct {
+	AccountID          int64                  `json:"account_id"`
+	Name               string                 `json:"name"`
+	Members            int64                  `json:"members"`
+	PendingInvitations int64                  `json:"pending_invitations"`
+	Attachments        int64                  `json:"attachments"`
+	AttachmentBytes    int64                  `json:"attachment_bytes"`
+	BackupBytes        int64                  `json:"backup_bytes"` // The newest account backup, if any
+	Quota              services.QuotaSettings `json:"quota"`
+	Limits             services.QuotaLimits   `json:"limits"`
+}
+
+// checkQuota writes the error response and returns false if a quota check
+// failed: status with the quota_exceeded code when the account is over a
+// limit, 500 when the limit couldn't be checked
+func checkQuota(w http.ResponseWriter, r *http.Request, err error, status int) bool {
+	if err == nil {
+		return true
+	}
+	var quotaErr *services.QuotaError
+	if errors.As(err, &quotaErr) {
+		respond.ErrorWithCode(w, status, respond.CodeQuotaExceeded, quotaErr.Error())
+		return false
+	}
+	middleware.Log(r.Context()).Error("Failed to check quota", "err", err)
+	respond.Error(w, "Failed to check quota", http.StatusInternalServerError)
+	return false
+}
+
+// HandleGetQuotas returns the site-wide limits on accounts
+func HandleGetQuotas(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		// No account has ID 0, so only the site-wide quota comes back
+		quotas, err := repository.NewQuotaRepository(db).ForAccount(0)
+		if err != nil {
+			respond.Error(w, "Failed to load quotas", http.StatusInternalServerError)
+			return
+		}
+		var site *models.ResourceQuota
+		if len(quotas) > 0 {
+			site = quotas[0]
+		}
+		respondJSON(w, http.StatusOK, services.NewQuotaSettings(site))
+	}
+}
+
+// HandleUpdateQuotas sets the limits on accounts, site-wide or for one
+// account. Accounts already past a new limit keep what they have but can't
+// add more.
+func HandleUpdateQuotas(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+
+		var req QuotaRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.AccountID < 0 {
+			respond.Validation(w, "", respond.Field("account_id", "must be positive"))
+			return
+		}
+		if req.AccountID != 0 {
+			var exists bool
+			if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM accounts WHERE id = ?)`, req.AccountID).Scan(&exists); err != nil {
+				respond.Error(w, "Failed to load account", http.StatusInternalServerError)
+				return
+			}
+			if !exists {
+				respond.Error(w, "Account not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		optional := func(v *int64) sql.NullInt64 {
+			if v == nil {
+				return sql.NullInt64{}
+			}
+			return sql.NullInt64{Int64: *v, Valid: true}
+		}
+		quota := &models.ResourceQuota{
+			AccountID:           sql.NullInt64{Int64: req.AccountID, Valid: req.AccountID != 0},
+			MaxMembers:          optional(req.MaxMembers),
+			AttachmentStorageMB: optional(req.AttachmentStorageMB),
+			BackupMB:            optional(req.BackupMB),
+			UpdatedBy:           sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if err := repository.NewQuotaRepository(db).Set(quota); err != nil {
+			middleware.Log(r.Context()).Error("Failed to save quota", "account_id", req.AccountID, "err", err)
+			respond.Error(w, "Failed to save quota", http.StatusInternalServerError)
+			return
+		}
+
+		_ = repository.NewAuditRepository(db).LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"quota",
+			sql.NullInt64{Int64: req.AccountID, Valid: req.AccountID != 0},
+			map[string]interface{}{
+				"max_members":           req.MaxMembers,
+				"attachment_storage_mb": req.AttachmentStorageMB,
+				"backup_mb":             req.BackupMB,
+			},
+			getIPAddress(r),
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, req)
+	}
+}
+
+// HandleGetAccountUsage returns what every account is using next to its
+// limits
+func HandleGetAccountUsage(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		quotaRepo := repository.NewQuotaRepository(db)
+
+		usage, err := quotaRepo.ListUsage()
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to get account usage", "err", err)
+			respond.Error(w, "Failed to get account usage", http.StatusInternalServerError)
+			return
+		}
+		quotas, err := quotaRepo.List()
+		if err != nil {
+			respond.Error(w, "Failed to load quotas", http.StatusInternalServerError)
+			return
+		}
+		own := make(map[int64]*models.ResourceQuota, len(quotas))
+		for _, q := range quotas {
+			if q.AccountID.Valid {
+				own[q.AccountID.Int64] = q
+			}
+		}
+		backups := latestAccountBackupSizes()
+
+		resp := make([]AccountUsageResponse, 0, len(usage))
+		for _, u := range usage {
+			resp = append(resp, AccountUsageResponse{
+				AccountID:          u.AccountID,
+				Name:               u.Name,
+				Members:            u.Members,
+				PendingInvitations: u.PendingInvitations,
+				Attachments:        u.Attachments,
+				AttachmentBytes:    u.AttachmentBytes,
+				BackupBytes:        backups[u.AccountID],
+				Quota:              services.NewQuotaSettings(own[u.AccountID]),
+				Limits:             services.ResolveQuotaLimits(quotas, u.AccountID),
+			})
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
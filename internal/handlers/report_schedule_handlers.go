@@ -0,0 +1,670 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ReportScheduleRequest is the payload for creating or updating a report
+// schedule. Fields left out keep their current (or default) value; a
+// course_id of 0 reports on all courses, and a saved_report_id of 0
+// attaches no saved report.
+type ReportScheduleRequest struct {
+	Name          *string `json:"name" validate:"max=100"`
+	Frequency     *string `json:"frequency" validate:"oneof=weekly monthly"`
+	DayOfWeek     *int    `json:"day_of_week" validate:"min=0,max=6"` // 0 is Sunday
+	DayOfMonth    *int    `json:"day_of_month" validate:"min=1,max=28"`
+	Hour          *int    `json:"hour" validate:"min=0,max=23"`
+	CourseID      *int64  `json:"course_id"`
+	IncludePDF    *bool   `json:"include_pdf"`
+	SavedReportID *int64  `json:"saved_report_id"`
+	IsEnabled     *bool   `json:"is_enabled"`
+}
+
+// ReportScheduleResponse is a report schedule as returned by the API
+type ReportScheduleResponse struct {
+	ID            int64      `json:"id"`
+	Name          string     `json:"name"`
+	Frequency     string     `json:"frequency"`
+	DayOfWeek     int        `json:"day_of_week"`
+	DayOfMonth    int        `json:"day_of_month"`
+	Hour          int        `json:"hour"`
+	CourseID      *int64     `json:"course_id,omitempty"`
+	IncludePDF    bool       `json:"include_pdf"`
+	SavedReportID *int64     `json:"saved_report_id,omitempty"` // Attached as CSV
+	IsEnabled     bool       `json:"is_enabled"`
+	NextRunAt     *time.Time `json:"next_run_at,omitempty"` // Only while enabled
+	LastSentAt    *time.Time `json:"last_sent_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ReportSummary is what a scheduled report email says about its period
+type ReportSummary struct {
+	Start, End        time.Time
+	CourseName        string
+	Injections        int
+	ExpectedDays      int // Days a course was running
+	InjectedDays      int // Of those, days with an injection
+	AvgPain           sql.NullFloat64
+	PreviousAvgPain   sql.NullFloat64 // Same length period before this one
+	MedicationsTaken  int
+	MedicationsLogged int
+	CurrentStreak     int                  // Days in a row with an injection at the end of the period
+	LongestStreak     int                  // Ever, for the whole account
+	Milestones        []services.Milestone // Reached during the period, oldest first
+	LowStock          []*models.InventoryItem
+	Export            *ExportData // Backs the PDF attachment
+	SavedReport       *reportRun  // Attached as CSV, if the schedule has one
+}
+
+func toReportScheduleResponse(s *models.ReportSchedule) ReportScheduleResponse {
+	resp := ReportScheduleResponse{
+		ID:            s.ID,
+		Name:          s.Name,
+		Frequency:     s.Frequency,
+		DayOfWeek:     s.DayOfWeek,
+		DayOfMonth:    s.DayOfMonth,
+		Hour:          s.Hour,
+		CourseID:      nullInt64ToInt(s.CourseID),
+		IncludePDF:    s.IncludePDF,
+		SavedReportID: nullInt64ToInt(s.SavedReportID),
+		IsEnabled:     s.IsEnabled,
+		LastError:     s.LastError.String,
+		CreatedAt:     s.CreatedAt,
+		UpdatedAt:     s.UpdatedAt,
+	}
+	if s.IsEnabled {
+		next := s.NextRunAt
+		resp.NextRunAt = &next
+	}
+	if s.LastSentAt.Valid {
+		resp.LastSentAt = &s.LastSentAt.Time
+	}
+	return resp
+}
+
+// applyReportScheduleRequest copies the fields set in req onto s and checks
+// what the tags can't
+func applyReportScheduleRequest(db *database.DB, s *models.ReportSchedule, req *ReportScheduleRequest) error {
+	if req.Name != nil {
+		s.Name = *req.Name
+	}
+	if req.Frequency != nil && *req.Frequency != "" {
+		s.Frequency = *req.Frequency
+	}
+	if req.DayOfWeek != nil {
+		s.DayOfWeek = *req.DayOfWeek
+	}
+	if req.DayOfMonth != nil {
+		s.DayOfMonth = *req.DayOfMonth
+	}
+	if req.Hour != nil {
+		s.Hour = *req.Hour
+	}
+	if req.CourseID != nil {
+		s.CourseID = sql.NullInt64{Int64: *req.CourseID, Valid: *req.CourseID != 0}
+	}
+	if req.IncludePDF != nil {
+		s.IncludePDF = *req.IncludePDF
+	}
+	if req.SavedReportID != nil {
+		s.SavedReportID = sql.NullInt64{Int64: *req.SavedReportID, Valid: *req.SavedReportID != 0}
+	}
+	if req.IsEnabled != nil {
+		s.IsEnabled = *req.IsEnabled
+	}
+
+	if s.Name == "" {
+		return errors.New("name is required")
+	}
+	if s.CourseID.Valid {
+		var exists int
+		err := db.QueryRow(`SELECT 1 FROM courses WHERE id = ? AND account_id = ?`, s.CourseID.Int64, s.AccountID).Scan(&exists)
+		if err != nil {
+			return errors.New("course not found")
+		}
+	}
+	if s.SavedReportID.Valid {
+		if _, err := repository.NewSavedReportRepository(db).GetByID(s.SavedReportID.Int64, s.AccountID, s.UserID); err != nil {
+			return errors.New("saved report not found")
+		}
+	}
+	return nil
+}
+
+// userLocation returns the user's configured timezone
+func userLocation(db *database.DB, userID int64) *time.Location {
+	loc, err := time.LoadLocation(GetUserTimezone(db, userID))
+	if err != nil {
+		loc, _ = time.LoadLocation("America/New_York")
+	}
+	return loc
+}
+
+// HandleGetReportSchedules lists the current user's report schedules
+func HandleGetReportSchedules(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		schedules, err := repository.NewReportScheduleRepository(db).List(accountID, userID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve report schedules", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]ReportScheduleResponse, 0, len(schedules))
+		for _, s := range schedules {
+			resp = append(resp, toReportScheduleResponse(s))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// HandleCreateReportSchedule adds a report schedule for the current user
+func HandleCreateReportSchedule(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req ReportScheduleRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		user, err := repository.NewUserRepository(db).GetByID(userID)
+		if err != nil {
+			respond.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if !user.Email.Valid || user.Email.String == "" {
+			respond.Error(w, "Add an email address to your profile to receive reports", http.StatusBadRequest)
+			return
+		}
+
+		schedule := &models.ReportSchedule{
+			AccountID:  accountID,
+			UserID:     userID,
+			Frequency:  "weekly",
+			DayOfWeek:  1,
+			DayOfMonth: 1,
+			Hour:       8,
+			IncludePDF: true,
+			IsEnabled:  true,
+		}
+		if err := applyReportScheduleRequest(db, schedule, &req); err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		schedule.NextRunAt = services.NextReportRun(schedule, userLocation(db, userID), time.Now())
+
+		if err := repository.NewReportScheduleRepository(db).Create(schedule); err != nil {
+			respond.Error(w, "Failed to create report schedule", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"report_schedule",
+			sql.NullInt64{Int64: schedule.ID, Valid: true},
+			map[string]interface{}{"name": schedule.Name, "frequency": schedule.Frequency},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(toReportScheduleResponse(schedule))
+	}
+}
+
+// HandleUpdateReportSchedule changes one of the current user's report schedules
+func HandleUpdateReportSchedule(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		scheduleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid report schedule ID", http.StatusBadRequest)
+			return
+		}
+
+		var req ReportScheduleRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		scheduleRepo := repository.NewReportScheduleRepository(db)
+		schedule, err := scheduleRepo.GetByID(scheduleID, accountID, userID)
+		if err == repository.ErrNotFound {
+			respond.Error(w, "Report schedule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			respond.Error(w, "Failed to retrieve report schedule", http.StatusInternalServerError)
+			return
+		}
+
+		if err := applyReportScheduleRequest(db, schedule, &req); err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		schedule.NextRunAt = services.NextReportRun(schedule, userLocation(db, userID), time.Now())
+
+		if err := scheduleRepo.Update(schedule); err != nil {
+			respond.Error(w, "Failed to update report schedule", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"report_schedule",
+			sql.NullInt64{Int64: schedule.ID, Valid: true},
+			map[string]interface{}{"name": schedule.Name, "is_enabled": schedule.IsEnabled},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		schedule.UpdatedAt = time.Now()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toReportScheduleResponse(schedule))
+	}
+}
+
+// HandleDeleteReportSchedule removes one of the current user's report schedules
+func HandleDeleteReportSchedule(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		scheduleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid report schedule ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewReportScheduleRepository(db).Delete(scheduleID, accountID, userID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Report schedule not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete report schedule", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"report_schedule",
+			sql.NullInt64{Int64: scheduleID, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleSendReportNow emails a report straight away, covering the period
+// it would cover if it were due now. The schedule itself is unchanged.
+func HandleSendReportNow(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		scheduleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid report schedule ID", http.StatusBadRequest)
+			return
+		}
+
+		scheduleRepo := repository.NewReportScheduleRepository(db)
+		schedule, err := scheduleRepo.GetByID(scheduleID, accountID, userID)
+		if err == repository.ErrNotFound {
+			respond.Error(w, "Report schedule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			respond.Error(w, "Failed to retrieve report schedule", http.StatusInternalServerError)
+			return
+		}
+
+		err = sendScheduledReport(db, schedule, time.Now())
+		_ = scheduleRepo.RecordRun(schedule.ID, err, time.Time{})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"message": "Failed to send report: " + err.Error(),
+				"success": false,
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Report sent",
+			"success": true,
+		})
+	}
+}
+
+// RunDueReports sends every enabled report whose time has come and moves it
+// to its next run. Runs missed while the server was down are sent once, not
+// once per missed period.
+func RunDueReports(db *database.DB) error {
+	scheduleRepo := repository.NewReportScheduleRepository(db)
+	now := time.Now()
+
+	schedules, err := scheduleRepo.ListDue(now)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range schedules {
+		sendErr := sendScheduledReport(db, s, s.NextRunAt)
+		if sendErr != nil {
+			slog.Error("Failed to send report schedule", "schedule_id", s.ID, "err", sendErr)
+		}
+		next := services.NextReportRun(s, userLocation(db, s.UserID), now)
+		if err := scheduleRepo.RecordRun(s.ID, sendErr, next); err != nil {
+			slog.Error("Failed to record report schedule", "schedule_id", s.ID, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// StartReportScheduler starts the background scheduled report sender
+func StartReportScheduler(db *database.DB) {
+	services.RegisterScheduler("scheduled_reports", 15*time.Minute)
+	services.RunBackground(func() {
+		// Wait for server to fully start
+		if !services.SleepUnlessShutdown(30 * time.Second) {
+			return
+		}
+		services.RecordSchedulerRun("scheduled_reports", RunDueReports(db))
+
+		ticker := time.NewTicker(15 * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				services.RecordSchedulerRun("scheduled_reports", RunDueReports(db))
+			case <-services.ShutdownChan():
+				return
+			}
+		}
+	})
+}
+
+// sendScheduledReport emails the report a schedule would send at runAt
+func sendScheduledReport(db *database.DB, s *models.ReportSchedule, runAt time.Time) error {
+	cfg := services.LoadSMTPConfig(db)
+	if !cfg.IsConfigured() {
+		return errors.New("email is not configured on this server")
+	}
+
+	user, err := repository.NewUserRepository(db).GetByID(s.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.Email.Valid || user.Email.String == "" {
+		return errors.New("no email address on your profile")
+	}
+
+	// Written in the owner's language, as they would see it signed in
+	loc := userLocation(db, s.UserID)
+	p := i18n.Default()
+	if prefs, err := repository.NewSettingsRepository(db).GetPreferences(s.UserID); err == nil && !prefs.UpdatedAt.IsZero() {
+		p = i18n.For(prefs.Locale)
+	}
+	start, end := services.ReportPeriod(s.Frequency, runAt, loc)
+	summary, err := buildReportSummary(db, s, start, end, loc)
+	if err != nil {
+		return err
+	}
+
+	site := getSiteSettings(db)
+	subject := fmt.Sprintf("%s: %s", site.SiteTitle, s.Name)
+	body := formatReportEmail(p, summary, s, site.SiteTitle, loc)
+
+	var attachments []services.EmailAttachment
+	if s.IncludePDF {
+		pdfBytes, err := generatePDF(summary.Export, site, p)
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, services.EmailAttachment{
+			Filename:    fmt.Sprintf("injection-tracker-report-%s-to-%s.pdf", start.Format("2006-01-02"), end.Format("2006-01-02")),
+			ContentType: "application/pdf",
+			Data:        pdfBytes,
+		})
+	}
+	if summary.SavedReport != nil {
+		csvBytes, err := savedReportCSV(p, summary.SavedReport)
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, services.EmailAttachment{
+			Filename:    savedReportFilename(summary.SavedReport, "csv"),
+			ContentType: "text/csv",
+			Data:        csvBytes,
+		})
+	}
+
+	if len(attachments) == 0 {
+		return services.SendEmail(cfg, user.Email.String, subject, body)
+	}
+	return services.SendEmailWithAttachments(cfg, user.Email.String, subject, body, attachments...)
+}
+
+// buildReportSummary gathers the figures a report shows for start to end
+func buildReportSummary(db *database.DB, s *models.ReportSchedule, start, end time.Time, loc *time.Location) (*ReportSummary, error) {
+	courseID := ""
+	if s.CourseID.Valid {
+		courseID = strconv.FormatInt(s.CourseID.Int64, 10)
+	}
+	viewer, err := repository.LoadSymptomViewer(db, s.AccountID, s.UserID)
+	if err != nil {
+		return nil, err
+	}
+	export, err := gatherReportData(db, s.AccountID, viewer, start, end, courseID, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	current, previous := computeReportStats(export), computeReportStats(export.Previous)
+	summary := &ReportSummary{
+		Start:             start,
+		End:               end,
+		CourseName:        export.CourseName,
+		Injections:        current.Injections,
+		AvgPain:           current.InjectionPain,
+		PreviousAvgPain:   previous.InjectionPain,
+		ExpectedDays:      current.ExpectedDays,
+		InjectedDays:      current.InjectedDays,
+		MedicationsLogged: current.MedsLogged,
+		MedicationsTaken:  current.MedsTaken,
+		Export:            export,
+	}
+
+	courses, err := loadCourseProgress(db, s.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	summary.CurrentStreak, summary.LongestStreak = services.InjectionStreaks(courses, end, loc)
+	for _, c := range courses {
+		if s.CourseID.Valid && c.CourseID != s.CourseID.Int64 {
+			continue
+		}
+		for _, m := range services.CourseMilestones(c, end, loc) {
+			if !m.ReachedAt.Before(start) && m.ReachedAt.Before(end) {
+				summary.Milestones = append(summary.Milestones, m)
+			}
+		}
+	}
+	sort.SliceStable(summary.Milestones, func(i, j int) bool {
+		return summary.Milestones[i].ReachedAt.Before(summary.Milestones[j].ReachedAt)
+	})
+
+	summary.LowStock, err = repository.NewInventoryRepository(db).ListLowStock(s.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The saved report covers the same period, whatever its own range
+	if s.SavedReportID.Valid {
+		saved, err := repository.NewSavedReportRepository(db).GetByID(s.SavedReportID.Int64, s.AccountID, s.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get saved report: %w", err)
+		}
+		def, err := reportDefinition(saved)
+		if err != nil {
+			return nil, err
+		}
+		summary.SavedReport, err = runReportOver(db, def, s.AccountID, viewer, start, end, loc)
+		if err != nil {
+			return nil, fmt.Errorf("saved report %q: %w", saved.Name, err)
+		}
+		summary.SavedReport.Name = saved.Name
+	}
+
+	return summary, nil
+}
+
+// formatReportEmail renders a report summary as a plain-text email
+func formatReportEmail(p *i18n.Printer, summary *ReportSummary, s *models.ReportSchedule, siteTitle string, loc *time.Location) string {
+	var b strings.Builder
+
+	b.WriteString(p.T("Your %s %s report", p.T(s.Frequency), siteTitle) + "\n")
+	// The period ends at midnight, so its last day is the one before
+	b.WriteString(p.T("%s to %s", p.Date(summary.Start.In(loc)), p.Date(summary.End.In(loc).AddDate(0, 0, -1))))
+	if summary.CourseName != "" {
+		b.WriteString(p.T(" (course: %s)", summary.CourseName))
+	}
+	b.WriteString("\n\n")
+
+	b.WriteString(p.T("Injections: %d", summary.Injections))
+	if summary.Injections > 0 {
+		b.WriteString(p.T(" (%s total)", formatTotalDose(summary.Export.Injections)))
+	}
+	b.WriteString("\n")
+
+	if summary.ExpectedDays > 0 {
+		b.WriteString(p.T("Adherence: injected on %d of %d days (%d%%)",
+			summary.InjectedDays, summary.ExpectedDays, summary.InjectedDays*100/summary.ExpectedDays) + "\n")
+	} else {
+		b.WriteString(p.T("Adherence: no course was running") + "\n")
+	}
+
+	switch {
+	case !summary.AvgPain.Valid:
+		b.WriteString(p.T("Average pain: not recorded") + "\n")
+	case !summary.PreviousAvgPain.Valid:
+		b.WriteString(p.T("Average pain: %.1f", summary.AvgPain.Float64) + "\n")
+	default:
+		trend := "steady"
+		if diff := summary.AvgPain.Float64 - summary.PreviousAvgPain.Float64; diff >= 0.5 {
+			trend = "up"
+		} else if diff <= -0.5 {
+			trend = "down"
+		}
+		b.WriteString(p.T("Average pain: %.1f (%s from %.1f)", summary.AvgPain.Float64, p.T(trend), summary.PreviousAvgPain.Float64) + "\n")
+	}
+
+	if summary.MedicationsLogged > 0 {
+		b.WriteString(p.T("Medications: %d of %d logged doses taken", summary.MedicationsTaken, summary.MedicationsLogged) + "\n")
+	}
+
+	if summary.LongestStreak > 0 {
+		b.WriteString(p.T("Streak: %s in a row (longest %s)", dayCount(p, summary.CurrentStreak), dayCount(p, summary.LongestStreak)) + "\n")
+	}
+	if len(summary.Milestones) > 0 {
+		b.WriteString("\n" + p.T("Milestones reached:") + "\n")
+		for _, m := range summary.Milestones {
+			fmt.Fprintf(&b, "  - %s (%s)\n", m.Title(), p.MonthDay(m.ReachedAt.In(loc)))
+		}
+	}
+
+	if len(summary.LowStock) == 0 {
+		b.WriteString("\n" + p.T("No supplies are running low.") + "\n")
+	} else {
+		b.WriteString("\n" + p.T("Running low:") + "\n")
+		for _, item := range summary.LowStock {
+			b.WriteString("  - " + p.T("%s: %s %s left (reorder at %s)",
+				item.ItemType, formatDose(item.Quantity), item.Unit, formatDose(item.LowStockThreshold.Float64)) + "\n")
+		}
+	}
+
+	if s.IncludePDF {
+		b.WriteString("\n" + p.T("The full report is attached as a PDF.") + "\n")
+	}
+	if summary.SavedReport != nil {
+		b.WriteString("\n" + p.T("Your saved report %q is attached as a CSV.", summary.SavedReport.Name) + "\n")
+	}
+	b.WriteString("\n" + p.T("You receive this because of the report schedule %q in %s. You can change or turn it off in your settings.", s.Name, siteTitle) + "\n")
+
+	return b.String()
+}
+
+// dayCount writes a number of days, as in "1 day" or "3 days"
+func dayCount(p *i18n.Printer, n int) string {
+	if n == 1 {
+		return p.T("1 day")
+	}
+	return p.T("%d days", n)
+}
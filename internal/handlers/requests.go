@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/validate"
+)
+
+// decodeRequest reads the JSON request body into req, then cleans and checks
+// it against its validate tags. It writes the error response and returns
+// false if either fails.
+func decodeRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		respond.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return validRequest(w, req)
+}
+
+// validRequest cleans and checks a request read some other way against its
+// validate tags, writing the error response if it's invalid
+func validRequest(w http.ResponseWriter, req interface{}) bool {
+	if fields := validate.Struct(req); len(fields) > 0 {
+		respond.Validation(w, "", fields...)
+		return false
+	}
+	return true
+}
+
+// validFormRequest is validRequest for handlers that also take HTMX form
+// posts, which are shown the first problem as an alert
+func validFormRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	fields := validate.Struct(req)
+	if len(fields) == 0 {
+		return true
+	}
+	if r.Header.Get("HX-Request") == "true" {
+		respondErrorWithRequest(w, r, http.StatusBadRequest, "%s %s", fields[0].Field, fields[0].Message)
+	} else {
+		respond.Validation(w, "", fields...)
+	}
+	return false
+}
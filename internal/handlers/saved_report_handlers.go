@@ -0,0 +1,679 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jung-kurt/gofpdf/v2"
+)
+
+// SavedReportRequest is the payload for creating or updating a saved
+// report. Fields left out keep their current value.
+type SavedReportRequest struct {
+	Name       *string                      `json:"name" validate:"max=100"`
+	Definition *repository.ReportDefinition `json:"definition"`
+}
+
+// SavedReportResponse is a saved report as returned by the API
+type SavedReportResponse struct {
+	ID         int64                       `json:"id"`
+	Name       string                      `json:"name"`
+	Definition repository.ReportDefinition `json:"definition"`
+	CreatedAt  time.Time                   `json:"created_at"`
+	UpdatedAt  time.Time                   `json:"updated_at"`
+}
+
+// ReportRunResponse is a report as run, for showing on screen. Start and
+// End are left out for a report over all dates; End is the day after the
+// last.
+type ReportRunResponse struct {
+	Name     string                  `json:"name,omitempty"`
+	Start    *time.Time              `json:"start,omitempty"`
+	End      *time.Time              `json:"end,omitempty"`
+	Sections []ReportSectionResponse `json:"sections"`
+}
+
+// ReportSectionResponse is a section of a report as run: its column
+// headings, and its rows with each value as text, a number, true or false,
+// or null
+type ReportSectionResponse struct {
+	Entity  string          `json:"entity"`
+	Title   string          `json:"title"`
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// maxPDFReportRows is how many rows of a section the PDF shows; the CSV has
+// them all
+const maxPDFReportRows = 500
+
+func toSavedReportResponse(report *models.SavedReport) SavedReportResponse {
+	resp := SavedReportResponse{
+		ID:        report.ID,
+		Name:      report.Name,
+		CreatedAt: report.CreatedAt,
+		UpdatedAt: report.UpdatedAt,
+	}
+	_ = json.Unmarshal([]byte(report.Definition), &resp.Definition)
+	return resp
+}
+
+// applySavedReportRequest copies the fields set in req onto report and
+// checks its definition against what reports can be built from
+func applySavedReportRequest(report *models.SavedReport, req *SavedReportRequest) error {
+	if req.Name != nil {
+		report.Name = *req.Name
+	}
+	if req.Definition != nil {
+		definition, err := json.Marshal(req.Definition)
+		if err != nil {
+			return errors.New("invalid definition")
+		}
+		report.Definition = string(definition)
+	}
+
+	if report.Name == "" {
+		return errors.New("name is required")
+	}
+	if report.Definition == "" {
+		return errors.New("definition is required")
+	}
+	def, err := reportDefinition(report)
+	if err != nil {
+		return err
+	}
+	return def.Validate()
+}
+
+// reportDefinition reads a saved report's definition
+func reportDefinition(report *models.SavedReport) (*repository.ReportDefinition, error) {
+	var def repository.ReportDefinition
+	if err := json.Unmarshal([]byte(report.Definition), &def); err != nil {
+		return nil, fmt.Errorf("invalid definition: %w", err)
+	}
+	return &def, nil
+}
+
+// HandleGetReportFields lists the entities reports can be built from, with
+// the fields each has, labelled in the reader's language
+func HandleGetReportFields() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := i18n.FromContext(r.Context())
+		entities := repository.ReportEntities()
+		resp := make([]repository.ReportEntity, len(entities))
+		for i, e := range entities {
+			resp[i] = repository.ReportEntity{Name: e.Name, Label: p.T(e.Label), Fields: make([]repository.ReportField, len(e.Fields))}
+			for j, f := range e.Fields {
+				resp[i].Fields[j] = repository.ReportField{Name: f.Name, Label: p.T(f.Label), Kind: f.Kind}
+			}
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleGetSavedReports lists the current user's saved reports
+func HandleGetSavedReports(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		reports, err := repository.NewSavedReportRepository(db).List(accountID, userID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve saved reports", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]SavedReportResponse, 0, len(reports))
+		for _, report := range reports {
+			resp = append(resp, toSavedReportResponse(report))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleGetSavedReport returns one of the current user's saved reports
+func HandleGetSavedReport(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, ok := loadSavedReport(db.WithContext(r.Context()), w, r)
+		if !ok {
+			return
+		}
+		respondJSON(w, http.StatusOK, toSavedReportResponse(report))
+	}
+}
+
+// HandleCreateSavedReport saves a report for the current user
+func HandleCreateSavedReport(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req SavedReportRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		report := &models.SavedReport{AccountID: accountID, UserID: userID}
+		if err := applySavedReportRequest(report, &req); err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewSavedReportRepository(db).Create(report); err != nil {
+			respond.Error(w, "Failed to save report", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"saved_report",
+			sql.NullInt64{Int64: report.ID, Valid: true},
+			map[string]interface{}{"name": report.Name},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusCreated, toSavedReportResponse(report))
+	}
+}
+
+// HandleUpdateSavedReport changes one of the current user's saved reports
+func HandleUpdateSavedReport(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+
+		var req SavedReportRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		report, ok := loadSavedReport(db, w, r)
+		if !ok {
+			return
+		}
+		if err := applySavedReportRequest(report, &req); err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewSavedReportRepository(db).Update(report); err != nil {
+			respond.Error(w, "Failed to update saved report", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"saved_report",
+			sql.NullInt64{Int64: report.ID, Valid: true},
+			map[string]interface{}{"name": report.Name},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		report.UpdatedAt = time.Now()
+		respondJSON(w, http.StatusOK, toSavedReportResponse(report))
+	}
+}
+
+// HandleDeleteSavedReport removes one of the current user's saved reports.
+// Report schedules that attached it keep sending without it.
+func HandleDeleteSavedReport(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		reportID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid saved report ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewSavedReportRepository(db).Delete(reportID, accountID, userID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Saved report not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete saved report", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"saved_report",
+			sql.NullInt64{Int64: reportID, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleRunSavedReport runs one of the current user's saved reports over
+// its date range as of now, as JSON, or with format=csv or format=pdf as a
+// download
+func HandleRunSavedReport(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+		if format != "json" && format != "csv" && format != "pdf" {
+			respond.Error(w, "Invalid format. Use: json, csv or pdf", http.StatusBadRequest)
+			return
+		}
+
+		report, ok := loadSavedReport(db, w, r)
+		if !ok {
+			return
+		}
+		def, err := reportDefinition(report)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		run, ok := runReport(db, w, r, def)
+		if !ok {
+			return
+		}
+		run.Name = report.Name
+		p := i18n.FromContext(r.Context())
+
+		switch format {
+		case "csv":
+			data, err := savedReportCSV(p, run)
+			if err != nil {
+				respond.Error(w, fmt.Sprintf("Failed to generate CSV: %v", err), http.StatusInternalServerError)
+				return
+			}
+			writeReportDownload(w, "text/csv", savedReportFilename(run, "csv"), data)
+		case "pdf":
+			data, err := generateSavedReportPDF(run, getSiteSettings(db), p)
+			if err != nil {
+				respond.Error(w, fmt.Sprintf("Failed to generate PDF: %v", err), http.StatusInternalServerError)
+				return
+			}
+			writeReportDownload(w, "application/pdf", savedReportFilename(run, "pdf"), data)
+		default:
+			respondJSON(w, http.StatusOK, run.response(p))
+		}
+	}
+}
+
+// HandlePreviewReport runs a report definition without saving it, so it can
+// be tried out while it's being built
+func HandlePreviewReport(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		var def repository.ReportDefinition
+		if !decodeRequest(w, r, &def) {
+			return
+		}
+		if err := def.Validate(); err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		run, ok := runReport(db, w, r, &def)
+		if !ok {
+			return
+		}
+		respondJSON(w, http.StatusOK, run.response(i18n.FromContext(r.Context())))
+	}
+}
+
+// loadSavedReport gets the current user's saved report named by the URL,
+// writing the error response if it can't
+func loadSavedReport(db *database.DB, w http.ResponseWriter, r *http.Request) (*models.SavedReport, bool) {
+	userID := middleware.GetUserID(r.Context())
+	accountID := middleware.GetAccountID(r.Context())
+	if userID == 0 || accountID == 0 {
+		respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respond.Error(w, "Invalid saved report ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	report, err := repository.NewSavedReportRepository(db).GetByID(reportID, accountID, userID)
+	if err == repository.ErrNotFound {
+		respond.Error(w, "Saved report not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		respond.Error(w, "Failed to retrieve saved report", http.StatusInternalServerError)
+		return nil, false
+	}
+	return report, true
+}
+
+// reportRun is a report as run over Start to End (both zero for all dates)
+type reportRun struct {
+	Name       string
+	Start, End time.Time
+	Tables     []*repository.ReportTable
+	loc        *time.Location
+}
+
+// runReport runs def for the current user over its date range as of now,
+// writing the error response if it can't
+func runReport(db *database.DB, w http.ResponseWriter, r *http.Request, def *repository.ReportDefinition) (*reportRun, bool) {
+	viewer, ok := symptomViewer(db, w, r)
+	if !ok {
+		return nil, false
+	}
+	loc := userLocation(db, middleware.GetUserID(r.Context()))
+	start, end, err := def.DateRange.Bounds(time.Now(), loc)
+	if err != nil {
+		respond.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	run, err := runReportOver(db, def, middleware.GetAccountID(r.Context()), viewer, start, end, loc)
+	if errors.Is(err, repository.ErrReportTooBig) {
+		respond.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		middleware.Log(r.Context()).Error("Failed to run report", "err", err)
+		respond.Error(w, "Failed to run report", http.StatusInternalServerError)
+		return nil, false
+	}
+	return run, true
+}
+
+// runReportOver runs def for an account over start to end
+func runReportOver(db *database.DB, def *repository.ReportDefinition, accountID int64, viewer repository.SymptomViewer, start, end time.Time, loc *time.Location) (*reportRun, error) {
+	tables, err := repository.NewSavedReportRepository(db).Run(def, accountID, viewer, start, end, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &reportRun{Start: start, End: end, Tables: tables, loc: loc}, nil
+}
+
+// response lays out a run for the API, headed in p's language
+func (run *reportRun) response(p *i18n.Printer) ReportRunResponse {
+	resp := ReportRunResponse{Name: run.Name, Sections: make([]ReportSectionResponse, 0, len(run.Tables))}
+	if !run.Start.IsZero() {
+		resp.Start = &run.Start
+	}
+	if !run.End.IsZero() {
+		resp.End = &run.End
+	}
+	for _, table := range run.Tables {
+		section := ReportSectionResponse{
+			Entity:  table.Entity,
+			Title:   p.T(table.Label),
+			Columns: reportHeadings(p, table),
+			Rows:    make([][]interface{}, 0, len(table.Rows)),
+		}
+		for _, row := range table.Rows {
+			values := make([]interface{}, len(row))
+			for i, v := range row {
+				if t, ok := v.(time.Time); ok {
+					values[i] = formatReportValue(p, t, table.Columns[i].Kind)
+				} else {
+					values[i] = v
+				}
+			}
+			section.Rows = append(section.Rows, values)
+		}
+		resp.Sections = append(resp.Sections, section)
+	}
+	return resp
+}
+
+// reportHeadings returns a table's column headings in p's language
+func reportHeadings(p *i18n.Printer, table *repository.ReportTable) []string {
+	headings := make([]string, len(table.Columns))
+	for i, c := range table.Columns {
+		label := p.T(c.Label)
+		switch c.Aggregate {
+		case "count":
+			if c.Label != "Records" {
+				label = p.T("%s (count)", label)
+			}
+		case "sum":
+			label = p.T("%s (total)", label)
+		case "avg":
+			label = p.T("%s (average)", label)
+		case "min":
+			label = p.T("%s (lowest)", label)
+		case "max":
+			label = p.T("%s (highest)", label)
+		}
+		headings[i] = label
+	}
+	return headings
+}
+
+// formatReportValue writes a value of a report as text. Times are already
+// in the report's timezone; calendar days and date groups show as dates.
+func formatReportValue(p *i18n.Printer, v interface{}, kind string) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return yesNo(p, v)
+	case float64:
+		return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+	case time.Time:
+		if kind == repository.ReportDate {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprint(v)
+}
+
+// reportPeriod describes the dates a run covers
+func reportPeriod(p *i18n.Printer, run *reportRun) string {
+	switch {
+	case run.Start.IsZero() && run.End.IsZero():
+		return p.T("All dates")
+	case run.Start.IsZero():
+		return p.T("Up to %s", run.End.AddDate(0, 0, -1).Format("2006-01-02"))
+	case run.End.IsZero():
+		return p.T("From %s", run.Start.Format("2006-01-02"))
+	}
+	// The period ends at midnight, so its last day is the one before
+	return p.T("%s to %s", run.Start.Format("2006-01-02"), run.End.AddDate(0, 0, -1).Format("2006-01-02"))
+}
+
+// savedReportCSV writes a run as CSV: its name and period, then each
+// section under a heading of its own
+func savedReportCSV(p *i18n.Printer, run *reportRun) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	if err := writer.Write([]string{run.Name}); err != nil {
+		return nil, err
+	}
+	if err := writer.Write([]string{p.T("Report Period: %s", reportPeriod(p, run))}); err != nil {
+		return nil, err
+	}
+	for _, table := range run.Tables {
+		if err := writer.Write([]string{""}); err != nil {
+			return nil, err
+		}
+		if err := writer.Write([]string{"=== " + strings.ToUpper(p.T(table.Label)) + " ==="}); err != nil {
+			return nil, err
+		}
+		if err := writer.Write(reportHeadings(p, table)); err != nil {
+			return nil, err
+		}
+		for _, row := range table.Rows {
+			record := make([]string, len(row))
+			for i, v := range row {
+				record[i] = formatReportValue(p, v, table.Columns[i].Kind)
+			}
+			if err := writer.Write(record); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// generateSavedReportPDF lays out a run as a landscape table per section,
+// headed like the PDF report
+func generateSavedReportPDF(run *reportRun, site *SiteSettings, p *i18n.Printer) ([]byte, error) {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	text := pdfText{p: p, tr: tr}
+
+	title := site.SiteTitle
+	if title == "" {
+		title = p.T("Injection Tracker")
+	}
+	now := time.Now().In(run.loc)
+	generated := p.T("%s at %s", p.LongDate(now), p.Time(now))
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 10, tr(p.T("Generated on %s - %s - Page %d of {nb}", generated, title, pdf.PageNo())), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+	pdf.AddPage()
+
+	pdf.SetFont("Arial", "B", 16)
+	pdf.SetTextColor(63, 81, 181)
+	pdf.CellFormat(0, 8, tr(run.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 9)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.CellFormat(0, 5, tr(title+" - "+reportPeriod(p, run)), "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(4)
+
+	const width = 267.0 // A4 landscape inside the margins
+	for _, table := range run.Tables {
+		if pdf.GetY() > 170 {
+			pdf.AddPage()
+		}
+		pdfSectionTitle(pdf, text.T(table.Label))
+
+		cell := width / float64(len(table.Columns))
+		// Roughly how many characters fit in a cell at 8pt
+		chars := int(cell / 1.7)
+		header := func() {
+			pdf.SetFont("Arial", "B", 8)
+			pdf.SetFillColor(200, 200, 200)
+			for _, heading := range reportHeadings(p, table) {
+				pdf.CellFormat(cell, 7, tr(truncateString(heading, chars)), "1", 0, "C", true, 0, "")
+			}
+			pdf.Ln(-1)
+			pdf.SetFont("Arial", "", 8)
+		}
+		header()
+
+		if len(table.Rows) == 0 {
+			pdf.SetFont("Arial", "I", 9)
+			pdf.CellFormat(0, 6, text.T("Nothing to show for this period."), "", 1, "L", false, 0, "")
+		}
+		for i, row := range table.Rows {
+			if i == maxPDFReportRows {
+				pdf.SetFont("Arial", "I", 9)
+				pdf.CellFormat(0, 6, text.T("Showing %d of %d rows. Download the CSV for all of them.", maxPDFReportRows, len(table.Rows)), "", 1, "L", false, 0, "")
+				break
+			}
+			for j, v := range row {
+				align := "L"
+				if _, ok := v.(float64); ok {
+					align = "R"
+				}
+				pdf.CellFormat(cell, 6, tr(truncateString(formatReportValue(p, v, table.Columns[j].Kind), chars)), "1", 0, align, false, 0, "")
+			}
+			pdf.Ln(-1)
+			if pdf.GetY() > 185 && i < len(table.Rows)-1 {
+				pdf.AddPage()
+				header()
+			}
+		}
+		pdf.Ln(5)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var filenameUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// savedReportFilename names a download of a run after the report and its
+// period
+func savedReportFilename(run *reportRun, ext string) string {
+	name := strings.Trim(filenameUnsafe.ReplaceAllString(strings.ToLower(run.Name), "-"), "-")
+	if name == "" {
+		name = "report"
+	}
+	if !run.Start.IsZero() && !run.End.IsZero() {
+		name += fmt.Sprintf("-%s-to-%s", run.Start.Format("2006-01-02"), run.End.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	return name + "." + ext
+}
+
+// writeReportDownload sends data as an attachment
+func writeReportDownload(w http.ResponseWriter, contentType, filename string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/repository"
+)
+
+func testReportRun() *reportRun {
+	return &reportRun{
+		Name:  "Pain & sides",
+		Start: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+		loc:   time.UTC,
+		Tables: []*repository.ReportTable{
+			{
+				Entity: "injections",
+				Label:  "Injections",
+				Columns: []repository.ReportTableColumn{
+					{Label: "Side", Kind: repository.ReportText},
+					{Label: "Records", Aggregate: "count", Kind: repository.ReportNumber},
+					{Label: "Pain Level", Aggregate: "avg", Kind: repository.ReportNumber},
+				},
+				Rows: [][]interface{}{{"left", 3.0, 10.0 / 3}, {"right", 1.0, nil}},
+			},
+			{
+				Entity:  "checkins",
+				Label:   "Check-ins",
+				Columns: []repository.ReportTableColumn{{Label: "Date", Kind: repository.ReportDate}, {Label: "Notes", Kind: repository.ReportText}},
+			},
+		},
+	}
+}
+
+func TestSavedReportCSV(t *testing.T) {
+	out, err := savedReportCSV(i18n.Default(), testReportRun())
+	if err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	want := "Pain & sides\n" +
+		"Report Period: 2026-03-01 to 2026-03-31\n" +
+		"\n=== INJECTIONS ===\n" +
+		"Side,Records,Pain Level (average)\n" +
+		"left,3,3.33\n" +
+		"right,1,\n" +
+		"\n=== CHECK-INS ===\n" +
+		"Date,Notes\n"
+	if string(out) != want {
+		t.Errorf("Unexpected CSV:\n%s\nwant:\n%s", out, want)
+	}
+
+	// Headings follow the reader's language
+	headings := reportHeadings(i18n.For("de"), testReportRun().Tables[0])
+	if got := strings.Join(headings, ","); got != "Seite,Einträge,Schmerzstärke (Durchschnitt)" {
+		t.Errorf("Unexpected German headings: %v", headings)
+	}
+}
+
+func TestGenerateSavedReportPDF(t *testing.T) {
+	run := testReportRun()
+	out, err := generateSavedReportPDF(run, &SiteSettings{SiteTitle: "Tracker"}, i18n.Default())
+	if err != nil {
+		t.Fatalf("Failed to generate PDF: %v", err)
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-")) {
+		t.Error("Expected PDF output")
+	}
+
+	if got := savedReportFilename(run, "pdf"); got != "pain-sides-2026-03-01-to-2026-03-31.pdf" {
+		t.Errorf("Unexpected filename %q", got)
+	}
+	run.Start, run.End, run.Name = time.Time{}, time.Time{}, "!!"
+	if got := savedReportFilename(run, "csv"); got != "report.csv" {
+		t.Errorf("Unexpected filename %q for a report over all dates", got)
+	}
+}
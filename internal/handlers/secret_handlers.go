@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/secrets"
+)
+
+// SecretsStatusResponse describes how the secrets stored in the database
+// (the SMTP password, backup credentials, notification channel configs and
+// webhook secrets) are protected
+type SecretsStatusResponse struct {
+	// EncryptionEnabled is set when SECRETS_KEY is configured
+	EncryptionEnabled bool `json:"encryption_enabled"`
+	// MasterKeyID is the fingerprint of SECRETS_KEY, never the key itself
+	MasterKeyID      string     `json:"master_key_id,omitempty"`
+	DataKeyID        *int64     `json:"data_key_id,omitempty"`
+	DataKeyCreatedAt *time.Time `json:"data_key_created_at,omitempty"`
+	Encrypted        int        `json:"encrypted"`
+	Unencrypted      int        `json:"unencrypted"`
+	// RotationNeeded is set when secrets are stored unencrypted, or the data
+	// key is sealed with a master key from SECRETS_PREVIOUS_KEYS
+	RotationNeeded bool `json:"rotation_needed"`
+}
+
+// SecretsRotateResponse is the result of rotating the data key
+type SecretsRotateResponse struct {
+	Reencrypted int                   `json:"reencrypted"`
+	Status      SecretsStatusResponse `json:"status"`
+}
+
+func secretsStatus(db *database.DB) (SecretsStatusResponse, error) {
+	keyring := secrets.Active()
+	status := SecretsStatusResponse{
+		EncryptionEnabled: keyring != nil,
+		MasterKeyID:       keyring.MasterKeyID(),
+	}
+	counts, err := repository.NewSecretRepository(db).Count()
+	if err != nil {
+		return status, err
+	}
+	status.Encrypted, status.Unencrypted = counts.Encrypted, counts.Unencrypted
+
+	key, err := secrets.CurrentDataKey(db)
+	if err != nil {
+		return status, err
+	}
+	if key != nil {
+		status.DataKeyID = &key.ID
+		status.DataKeyCreatedAt = &key.CreatedAt
+	}
+	status.RotationNeeded = keyring != nil &&
+		(status.Unencrypted > 0 || (key != nil && key.MasterKeyID != keyring.MasterKeyID()))
+	return status, nil
+}
+
+// HandleGetSecretsStatus reports whether stored secrets are encrypted and
+// with which keys
+func HandleGetSecretsStatus(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		status, err := secretsStatus(db)
+		if err != nil {
+			respond.Error(w, "Failed to check stored secrets", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, status)
+	}
+}
+
+// HandleRotateSecrets re-encrypts every stored secret under a new data key
+// sealed with the current SECRETS_KEY. It encrypts secrets saved before
+// SECRETS_KEY was set, and finishes moving to a new master key.
+func HandleRotateSecrets(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+
+		keyring := secrets.Active()
+		if keyring == nil {
+			respond.Error(w, "Set SECRETS_KEY to encrypt stored secrets", http.StatusConflict)
+			return
+		}
+		count, err := repository.NewSecretRepository(db).Rotate(keyring)
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to rotate secrets", "err", err)
+			respond.Error(w, "Failed to rotate secrets", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"rotate_secrets",
+			"settings",
+			sql.NullInt64{},
+			map[string]interface{}{"reencrypted": count, "master_key_id": keyring.MasterKeyID()},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		status, err := secretsStatus(db)
+		if err != nil {
+			respond.Error(w, "Failed to check stored secrets", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, SecretsRotateResponse{Reencrypted: count, Status: status})
+	}
+}
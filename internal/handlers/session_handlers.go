@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/auth"
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// sessionTouchInterval is how out of date a session's last use may get, to
+// save a write on every request
+const sessionTouchInterval = 5 * time.Minute
+
+// recentLoginDays is how far back the sign-in activity goes
+const recentLoginDays = 30
+
+// deviceCookie holds a trusted device's token
+const deviceCookie = "device_token"
+
+// SecurityResponse is the user's signed-in devices, the devices they trust
+// and recent sign-ins
+type SecurityResponse struct {
+	Sessions       []SessionResponse       `json:"sessions"`
+	TrustedDevices []TrustedDeviceResponse `json:"trusted_devices"`
+	RecentLogins   []LoginActivityResponse `json:"recent_logins"`
+}
+
+// SessionResponse is one signed-in device
+type SessionResponse struct {
+	ID         int64      `json:"id"`
+	Device     string     `json:"device"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	// Current is set for the session making the request
+	Current bool `json:"current"`
+	// Impersonator is the admin signed in as the user, for support
+	Impersonator string `json:"impersonator,omitempty"`
+	// TrustedDeviceID is the trusted device the session is on, if any
+	TrustedDeviceID int64 `json:"trusted_device_id,omitempty"`
+}
+
+// TrustedDeviceResponse is one device the user trusts
+type TrustedDeviceResponse struct {
+	ID         int64      `json:"id"`
+	Device     string     `json:"device"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // When it last signed in
+	ExpiresAt  time.Time  `json:"expires_at"`
+	// Current is set for the device making the request
+	Current bool `json:"current"`
+}
+
+// LoginActivityResponse is one sign-in attempt, with what was unusual about
+// it if it succeeded
+type LoginActivityResponse struct {
+	Timestamp        time.Time `json:"timestamp"`
+	Success          bool      `json:"success"`
+	Reason           string    `json:"reason,omitempty"` // Why it failed
+	Device           string    `json:"device"`
+	IPAddress        string    `json:"ip_address,omitempty"`
+	Country          string    `json:"country,omitempty"`
+	NewIP            bool      `json:"new_ip"`
+	NewDevice        bool      `json:"new_device"`
+	ImpossibleTravel bool      `json:"impossible_travel"`
+}
+
+// CurrentSession rejects tokens whose session has been revoked, has gone
+// unused for longer than the idle timeout or has reached the end of its
+// lifetime, and records when each session was last used. The last two get
+// the codes session_idle and session_expired, so clients know to sign in
+// again rather than refresh; sessions on a trusted device don't go idle.
+// Tokens issued before sessions were tracked have no session and are let
+// through until they expire.
+func CurrentSession(db *database.DB, jwtManager *auth.JWTManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sessionID := middleware.GetSessionID(r.Context())
+			if sessionID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sessions := repository.NewSessionRepository(db.WithContext(r.Context()))
+			session, err := sessions.Get(sessionID)
+			if err == repository.ErrNotFound {
+				clearAuthCookie(w, r)
+				respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				respond.Error(w, "Failed to check session", http.StatusInternalServerError)
+				return
+			}
+
+			now := time.Now()
+			switch sessionEnd(session, jwtManager.IdleTimeout(), now) {
+			case respond.CodeSessionExpired:
+				clearAuthCookie(w, r)
+				respond.ErrorWithCode(w, http.StatusUnauthorized, respond.CodeSessionExpired, "Session expired, please sign in again")
+				return
+			case respond.CodeSessionIdle:
+				clearAuthCookie(w, r)
+				respond.ErrorWithCode(w, http.StatusUnauthorized, respond.CodeSessionIdle, "Signed out after a period of inactivity")
+				return
+			}
+
+			if !session.LastUsedAt.Valid || now.Sub(session.LastUsedAt.Time) > sessionTouchInterval {
+				if err := sessions.MarkUsed(session.ID, now); err != nil {
+					middleware.Log(r.Context()).Error("Failed to update session", "err", err)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sessionEnd returns why a session can no longer be used at now: the code
+// session_expired once its lifetime is over, session_idle once it has gone
+// unused for longer than idleTimeout, or "" while it can
+func sessionEnd(session *models.SessionToken, idleTimeout time.Duration, now time.Time) respond.Code {
+	if !session.ExpiresAt.After(now) {
+		return respond.CodeSessionExpired
+	}
+	if session.TrustedDeviceID.Valid {
+		return ""
+	}
+	lastUsed := session.CreatedAt
+	if session.LastUsedAt.Valid {
+		lastUsed = session.LastUsedAt.Time
+	}
+	if idleTimeout > 0 && now.Sub(lastUsed) > idleTimeout {
+		return respond.CodeSessionIdle
+	}
+	return ""
+}
+
+// HandleGetSecurity lists the user's signed-in devices, the devices they
+// trust and their sign-in attempts over the last 30 days
+func HandleGetSecurity(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		sessionRepo := repository.NewSessionRepository(db)
+		sessions, err := sessionRepo.ListActive(userID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve sessions", http.StatusInternalServerError)
+			return
+		}
+		devices, err := repository.NewTrustedDeviceRepository(db).ListActive(userID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve trusted devices", http.StatusInternalServerError)
+			return
+		}
+		var currentID, currentDeviceID int64
+		if sessionID := middleware.GetSessionID(r.Context()); sessionID != "" {
+			if current, err := sessionRepo.GetActive(sessionID); err == nil {
+				currentID = current.ID
+				currentDeviceID = current.TrustedDeviceID.Int64
+			}
+		}
+
+		logins, err := repository.NewAuditRepository(db).GetLogins(userID, time.Now().AddDate(0, 0, -recentLoginDays), 50)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve sign-in activity", http.StatusInternalServerError)
+			return
+		}
+
+		resp := SecurityResponse{
+			Sessions:       make([]SessionResponse, 0, len(sessions)),
+			TrustedDevices: make([]TrustedDeviceResponse, 0, len(devices)),
+			RecentLogins:   make([]LoginActivityResponse, 0, len(logins)),
+		}
+		userRepo := repository.NewUserRepository(db)
+		for _, s := range sessions {
+			session := toSessionResponse(s, currentID)
+			if s.ImpersonatorID.Valid {
+				session.Impersonator = "admin"
+				if admin, err := userRepo.GetByID(s.ImpersonatorID.Int64); err == nil {
+					session.Impersonator = admin.Username
+				}
+			}
+			resp.Sessions = append(resp.Sessions, session)
+		}
+		for _, d := range devices {
+			resp.TrustedDevices = append(resp.TrustedDevices, toTrustedDeviceResponse(d, currentDeviceID))
+		}
+		for _, l := range logins {
+			resp.RecentLogins = append(resp.RecentLogins, toLoginActivityResponse(l))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleRevokeSession signs one of the user's devices out. Revoking the
+// current session logs out.
+func HandleRevokeSession(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid session ID", http.StatusBadRequest)
+			return
+		}
+
+		sessionRepo := repository.NewSessionRepository(db)
+		current := false
+		if sessionID := middleware.GetSessionID(r.Context()); sessionID != "" {
+			if s, err := sessionRepo.GetActive(sessionID); err == nil {
+				current = s.ID == id
+			}
+		}
+
+		if err := sessionRepo.Revoke(id, userID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Session not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"revoke",
+			"session",
+			sql.NullInt64{Int64: id, Valid: true},
+			map[string]interface{}{"current": current},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		if current {
+			clearAuthCookie(w, r)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleRevokeTrustedDevice stops trusting one of the user's devices and
+// signs out its sessions. Revoking the current device logs out.
+func HandleRevokeTrustedDevice(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		if userID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid device ID", http.StatusBadRequest)
+			return
+		}
+
+		current := false
+		if sessionID := middleware.GetSessionID(r.Context()); sessionID != "" {
+			if s, err := repository.NewSessionRepository(db).GetActive(sessionID); err == nil {
+				current = s.TrustedDeviceID.Valid && s.TrustedDeviceID.Int64 == id
+			}
+		}
+
+		if err := repository.NewTrustedDeviceRepository(db).Revoke(id, userID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Trusted device not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to revoke trusted device", http.StatusInternalServerError)
+			return
+		}
+
+		_ = repository.NewAuditRepository(db).LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"revoke",
+			"trusted_device",
+			sql.NullInt64{Int64: id, Valid: true},
+			map[string]interface{}{"current": current},
+			getIPAddress(r),
+			r.UserAgent(),
+		)
+
+		if current {
+			clearAuthCookie(w, r)
+			setDeviceCookie(w, r, "", time.Time{})
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// currentTrustedDevice returns the user's trusted device the request came
+// from, if it carries a device token for one that is still trusted, and
+// records that it was used
+func currentTrustedDevice(db *database.DB, r *http.Request, userID int64) *models.TrustedDevice {
+	cookie, err := r.Cookie(deviceCookie)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	devices := repository.NewTrustedDeviceRepository(db)
+	device, err := devices.GetActive(userID, cookie.Value)
+	if err != nil {
+		return nil
+	}
+	if err := devices.MarkUsed(device.ID, time.Now()); err != nil {
+		middleware.Log(r.Context()).Error("Failed to update trusted device", "err", err)
+	}
+	return device
+}
+
+// setDeviceCookie stores a trusted device's token until expiresAt, or
+// removes it when token is empty. Unlike the auth cookie it outlasts
+// logging out.
+func setDeviceCookie(w http.ResponseWriter, r *http.Request, token string, expiresAt time.Time) {
+	maxAge := -1
+	if token != "" {
+		maxAge = int(time.Until(expiresAt).Seconds())
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     deviceCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   middleware.SecureCookie(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func toTrustedDeviceResponse(d *models.TrustedDevice, currentID int64) TrustedDeviceResponse {
+	resp := TrustedDeviceResponse{
+		ID:        d.ID,
+		Device:    services.DeviceName(d.UserAgent.String),
+		IPAddress: d.IPAddress.String,
+		UserAgent: d.UserAgent.String,
+		CreatedAt: d.CreatedAt,
+		ExpiresAt: d.ExpiresAt,
+		Current:   d.ID == currentID,
+	}
+	if d.LastUsedAt.Valid {
+		resp.LastUsedAt = &d.LastUsedAt.Time
+	}
+	return resp
+}
+
+func toSessionResponse(s *models.SessionToken, currentID int64) SessionResponse {
+	resp := SessionResponse{
+		ID:              s.ID,
+		Device:          services.DeviceName(s.UserAgent.String),
+		IPAddress:       s.IPAddress.String,
+		UserAgent:       s.UserAgent.String,
+		CreatedAt:       s.CreatedAt,
+		ExpiresAt:       s.ExpiresAt,
+		Current:         s.ID == currentID,
+		TrustedDeviceID: s.TrustedDeviceID.Int64,
+	}
+	if s.LastUsedAt.Valid {
+		resp.LastUsedAt = &s.LastUsedAt.Time
+	}
+	return resp
+}
+
+func toLoginActivityResponse(l *models.AuditLog) LoginActivityResponse {
+	details := services.ParseLoginDetails(l)
+	resp := LoginActivityResponse{
+		Timestamp:        l.Timestamp,
+		Success:          l.Action == "login_success",
+		Device:           services.DeviceName(l.UserAgent.String),
+		IPAddress:        l.IPAddress.String,
+		Country:          details.Country,
+		NewIP:            details.NewIP,
+		NewDevice:        details.NewDevice,
+		ImpossibleTravel: details.ImpossibleTravel,
+	}
+	if !resp.Success {
+		resp.Reason = details.Reason
+	}
+	return resp
+}
+
+// clearAuthCookie removes the auth cookie, logging the browser out, along
+// with the admin's own token kept while impersonating
+func clearAuthCookie(w http.ResponseWriter, r *http.Request) {
+	for _, name := range []string{"auth_token", impersonatorCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   middleware.SecureCookie(r),
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
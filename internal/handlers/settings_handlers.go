@@ -4,34 +4,41 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"injection-tracker/internal/database"
 	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
 )
 
 // SettingsResponse represents the settings API response
 type SettingsResponse struct {
-	AdvancedModeEnabled bool      `json:"advanced_mode_enabled"`
-	HeatMapDays         int       `json:"heat_map_days"`
-	LowStockAlerts      bool      `json:"low_stock_alerts"`
-	InjectionReminders  bool      `json:"injection_reminders"`
-	ReminderTime        string    `json:"reminder_time"`      // HH:MM format
-	ReminderFrequency   int       `json:"reminder_frequency"` // Hours between injections
-	UpdatedAt           time.Time `json:"updated_at"`
+	AdvancedModeEnabled bool   `json:"advanced_mode_enabled"`
+	HeatMapDays         int    `json:"heat_map_days"`
+	LowStockAlerts      bool   `json:"low_stock_alerts"`
+	InjectionReminders  bool   `json:"injection_reminders"`
+	ReminderTime        string `json:"reminder_time"`      // HH:MM format
+	ReminderFrequency   int    `json:"reminder_frequency"` // Hours between injections
+	// Minutes either side of an existing injection on the same course and
+	// side within which a new one is taken for a duplicate; 0 turns the
+	// check off
+	DuplicateWindowMinutes int       `json:"duplicate_window_minutes"`
+	UpdatedAt              time.Time `json:"updated_at"`
 }
 
 // UpdateSettingsRequest represents the request to update settings
 type UpdateSettingsRequest struct {
-	AdvancedModeEnabled *bool   `json:"advanced_mode_enabled,omitempty"`
-	HeatMapDays         *int    `json:"heat_map_days,omitempty"`
-	LowStockAlerts      *bool   `json:"low_stock_alerts,omitempty"`
-	InjectionReminders  *bool   `json:"injection_reminders,omitempty"`
-	ReminderTime        *string `json:"reminder_time,omitempty"`
-	ReminderFrequency   *int    `json:"reminder_frequency,omitempty"`
+	AdvancedModeEnabled    *bool   `json:"advanced_mode_enabled,omitempty"`
+	HeatMapDays            *int    `json:"heat_map_days,omitempty" validate:"min=1,max=90"`
+	LowStockAlerts         *bool   `json:"low_stock_alerts,omitempty"`
+	InjectionReminders     *bool   `json:"injection_reminders,omitempty"`
+	ReminderTime           *string `json:"reminder_time,omitempty" validate:"max=5"`
+	ReminderFrequency      *int    `json:"reminder_frequency,omitempty" validate:"min=1,max=168"` // Hours
+	DuplicateWindowMinutes *int    `json:"duplicate_window_minutes,omitempty" validate:"min=0,max=1440"`
 }
 
 // Default settings values
@@ -40,60 +47,59 @@ const (
 	DefaultHeatMapDays        = 14
 	DefaultLowStockAlerts     = true
 	DefaultInjectionReminders = false
-	DefaultReminderTime       = "19:00"
-	DefaultReminderFrequency  = 24
+	DefaultReminderTime       = services.DefaultInjectionTime
+	DefaultReminderFrequency  = services.DefaultInjectionFrequencyHours
+	DefaultDuplicateWindow    = 30 // Minutes
 )
 
 // HandleGetSettings returns all application settings
 func HandleGetSettings(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
 
-		settings, err := getSettings(db)
+		settings, err := getSettings(db, accountID)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get settings: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to get settings: %v", err), http.StatusInternalServerError)
 			return
 		}
 
 		// Add user-specific settings
 		response := map[string]interface{}{
-			"advanced_mode_enabled": settings.AdvancedModeEnabled,
-			"heat_map_days":         settings.HeatMapDays,
-			"low_stock_alerts":      settings.LowStockAlerts,
-			"injection_reminders":   settings.InjectionReminders,
-			"reminder_time":         settings.ReminderTime,
-			"reminder_frequency":    settings.ReminderFrequency,
-			"updated_at":            settings.UpdatedAt,
-			"theme":                 "auto", // default
-			"timezone":              "America/New_York",
-			"date_format":           "MM/DD/YYYY",
-			"time_format":           "12h",
+			"advanced_mode_enabled":    settings.AdvancedModeEnabled,
+			"heat_map_days":            settings.HeatMapDays,
+			"low_stock_alerts":         settings.LowStockAlerts,
+			"injection_reminders":      settings.InjectionReminders,
+			"reminder_time":            settings.ReminderTime,
+			"reminder_frequency":       settings.ReminderFrequency,
+			"duplicate_window_minutes": settings.DuplicateWindowMinutes,
+			"updated_at":               settings.UpdatedAt,
+			"theme":                    "auto", // default
+			"timezone":                 "America/New_York",
+			"date_format":              "MM/DD/YYYY",
+			"time_format":              "12h",
 		}
 
 		// Load user-specific settings if authenticated
 		if userID != 0 {
-			var theme, timezone, dateFormat, timeFormat string
-			err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, fmt.Sprintf("user_theme_%d", userID)).Scan(&theme)
-			if err == nil {
-				response["theme"] = theme
-			}
-			err = db.QueryRow(`SELECT value FROM settings WHERE key = ?`, fmt.Sprintf("user_timezone_%d", userID)).Scan(&timezone)
-			if err == nil {
-				response["timezone"] = timezone
-			}
-			err = db.QueryRow(`SELECT value FROM settings WHERE key = ?`, fmt.Sprintf("user_date_format_%d", userID)).Scan(&dateFormat)
-			if err == nil {
-				response["date_format"] = dateFormat
-			}
-			err = db.QueryRow(`SELECT value FROM settings WHERE key = ?`, fmt.Sprintf("user_time_format_%d", userID)).Scan(&timeFormat)
-			if err == nil {
-				response["time_format"] = timeFormat
+			settingsRepo := repository.NewSettingsRepository(db)
+			prefs, err := settingsRepo.GetPreferences(userID)
+			if err != nil {
+				respond.Error(w, fmt.Sprintf("Failed to get settings: %v", err), http.StatusInternalServerError)
+				return
 			}
+			response["theme"] = prefs.Theme
+			response["timezone"] = prefs.Timezone
+			response["date_format"] = prefs.DateFormat
+			response["time_format"] = prefs.TimeFormat
+			response["checkin_reminder_time"], _ = settingsRepo.GetUser(userID, repository.UserSettingCheckInReminder)
 		}
 
+		setVersionHeaders(w, settings.UpdatedAt)
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Printf("Failed to encode settings response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode settings response", "err", err)
 		}
 	}
 }
@@ -101,214 +107,183 @@ func HandleGetSettings(db *database.DB) http.HandlerFunc {
 // HandleUpdateSettings updates application settings
 func HandleUpdateSettings(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
-		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// Parse request body
 		var req UpdateSettingsRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
-		}
-
-		// Validate settings
-		if req.HeatMapDays != nil && (*req.HeatMapDays < 1 || *req.HeatMapDays > 90) {
-			http.Error(w, "heat_map_days must be between 1 and 90", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
 		if req.ReminderTime != nil {
 			if !isValidTimeFormat(*req.ReminderTime) {
-				http.Error(w, "reminder_time must be in HH:MM format (24-hour)", http.StatusBadRequest)
+				respond.Validation(w, "reminder_time must be in HH:MM format (24-hour)", respond.Field("reminder_time", "must be in HH:MM format (24-hour)"))
 				return
 			}
 		}
 
-		if req.ReminderFrequency != nil && (*req.ReminderFrequency < 1 || *req.ReminderFrequency > 168) {
-			http.Error(w, "reminder_frequency must be between 1 and 168 hours", http.StatusBadRequest)
+		current, err := getSettings(db, accountID)
+		if err != nil {
+			respond.Error(w, fmt.Sprintf("Failed to get settings: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !checkPreconditions(w, r, current.UpdatedAt, true, func() interface{} { return current }) {
 			return
 		}
 
 		// Begin transaction
 		tx, err := db.BeginTx()
 		if err != nil {
-			http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+			respond.Error(w, "Failed to start transaction", http.StatusInternalServerError)
 			return
 		}
 		defer func() { _ = tx.Rollback() }()
 
-		now := time.Now()
+		// The settings are several rows, so rather than each UPDATE checking
+		// its own updated_at, the version is checked again now that other
+		// writers wait for this transaction
+		var locked int64
+		if err := tx.QueryRow(`SELECT id FROM accounts WHERE id = ?`+db.Dialect.ForUpdate(), accountID).Scan(&locked); err != nil {
+			respond.Error(w, "Failed to lock settings", http.StatusInternalServerError)
+			return
+		}
+		latest, err := readSettings(repository.NewSettingsRepositoryTx(tx), accountID)
+		if err != nil {
+			respond.Error(w, fmt.Sprintf("Failed to get settings: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !latest.UpdatedAt.Equal(current.UpdatedAt) {
+			respondPreconditionFailed(w, latest.UpdatedAt, latest)
+			return
+		}
+
+		now := time.Now().UTC()
 
 		// Update each setting if provided
+		values := map[string]string{}
 		if req.AdvancedModeEnabled != nil {
-			if err := upsertSetting(tx, "advanced_mode_enabled", boolToString(*req.AdvancedModeEnabled), userID, now); err != nil {
-				http.Error(w, "Failed to update advanced_mode_enabled", http.StatusInternalServerError)
-				return
-			}
+			values[repository.AccountSettingAdvancedMode] = boolToString(*req.AdvancedModeEnabled)
 		}
-
 		if req.HeatMapDays != nil {
-			if err := upsertSetting(tx, "heat_map_days", fmt.Sprintf("%d", *req.HeatMapDays), userID, now); err != nil {
-				http.Error(w, "Failed to update heat_map_days", http.StatusInternalServerError)
-				return
-			}
+			values[repository.AccountSettingHeatMapDays] = strconv.Itoa(*req.HeatMapDays)
 		}
-
 		if req.LowStockAlerts != nil {
-			if err := upsertSetting(tx, "low_stock_alerts", boolToString(*req.LowStockAlerts), userID, now); err != nil {
-				http.Error(w, "Failed to update low_stock_alerts", http.StatusInternalServerError)
-				return
-			}
+			values[repository.AccountSettingLowStockAlerts] = boolToString(*req.LowStockAlerts)
 		}
-
 		if req.InjectionReminders != nil {
-			if err := upsertSetting(tx, "injection_reminders", boolToString(*req.InjectionReminders), userID, now); err != nil {
-				http.Error(w, "Failed to update injection_reminders", http.StatusInternalServerError)
-				return
-			}
+			values[repository.AccountSettingInjectionReminders] = boolToString(*req.InjectionReminders)
 		}
-
 		if req.ReminderTime != nil {
-			if err := upsertSetting(tx, "reminder_time", *req.ReminderTime, userID, now); err != nil {
-				http.Error(w, "Failed to update reminder_time", http.StatusInternalServerError)
-				return
-			}
+			values[repository.AccountSettingReminderTime] = *req.ReminderTime
 		}
-
 		if req.ReminderFrequency != nil {
-			if err := upsertSetting(tx, "reminder_frequency", fmt.Sprintf("%d", *req.ReminderFrequency), userID, now); err != nil {
-				http.Error(w, "Failed to update reminder_frequency", http.StatusInternalServerError)
+			values[repository.AccountSettingReminderFrequency] = strconv.Itoa(*req.ReminderFrequency)
+		}
+		if req.DuplicateWindowMinutes != nil {
+			values[repository.AccountSettingDuplicateWindow] = strconv.Itoa(*req.DuplicateWindowMinutes)
+		}
+		settingsRepo := repository.NewSettingsRepositoryTx(tx)
+		for key, value := range values {
+			if err := settingsRepo.SetAccount(accountID, key, value, sql.NullInt64{Int64: userID, Valid: true}); err != nil {
+				respond.Error(w, "Failed to update "+key, http.StatusInternalServerError)
 				return
 			}
 		}
 
 		// Create audit log
 		_, _ = tx.Exec(`
-			INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details, timestamp)
-			VALUES (?, ?, ?, ?, ?, ?)
-		`, userID, "update", "settings", 0, "Updated application settings", now)
+			INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details, impersonator_id, timestamp)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, userID, "update", "settings", 0, "Updated application settings", repository.ImpersonatorOf(r.Context()), now)
 
 		// Commit transaction
 		if err := tx.Commit(); err != nil {
-			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			respond.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
 			return
 		}
 
 		// Return updated settings
-		settings, err := getSettings(db)
+		settings, err := getSettings(db, accountID)
 		if err != nil {
-			http.Error(w, "Settings updated but failed to retrieve", http.StatusInternalServerError)
+			respond.Error(w, "Settings updated but failed to retrieve", http.StatusInternalServerError)
 			return
 		}
 
+		setVersionHeaders(w, settings.UpdatedAt)
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(settings); err != nil {
-			log.Printf("Failed to encode settings response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode settings response", "err", err)
 		}
 	}
 }
 
 // Helper functions
 
-// getSettings retrieves all settings from the database with defaults
-func getSettings(db *database.DB) (*SettingsResponse, error) {
+// getSettings retrieves the account's settings with defaults
+func getSettings(db *database.DB, accountID int64) (*SettingsResponse, error) {
+	return readSettings(repository.NewSettingsRepository(db), accountID)
+}
+
+// readSettings reads an account's settings through repo, which may be
+// within a transaction
+func readSettings(repo *repository.SettingsRepository, accountID int64) (*SettingsResponse, error) {
 	settings := &SettingsResponse{
-		AdvancedModeEnabled: DefaultAdvancedMode,
-		HeatMapDays:         DefaultHeatMapDays,
-		LowStockAlerts:      DefaultLowStockAlerts,
-		InjectionReminders:  DefaultInjectionReminders,
-		ReminderTime:        DefaultReminderTime,
-		ReminderFrequency:   DefaultReminderFrequency,
-		UpdatedAt:           time.Now(),
+		AdvancedModeEnabled:    DefaultAdvancedMode,
+		HeatMapDays:            DefaultHeatMapDays,
+		LowStockAlerts:         DefaultLowStockAlerts,
+		InjectionReminders:     DefaultInjectionReminders,
+		ReminderTime:           DefaultReminderTime,
+		ReminderFrequency:      DefaultReminderFrequency,
+		DuplicateWindowMinutes: DefaultDuplicateWindow,
 	}
 
-	// Query all settings
-	rows, err := db.Query(`
-		SELECT key, value, updated_at
-		FROM settings
-	`)
+	stored, err := repo.ListAccount(accountID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var latestUpdate time.Time
-
-	for rows.Next() {
-		var key, value string
-		var updatedAt time.Time
-
-		if err := rows.Scan(&key, &value, &updatedAt); err != nil {
-			return nil, err
-		}
-
-		// Track the latest update time
-		if updatedAt.After(latestUpdate) {
-			latestUpdate = updatedAt
-		}
-
-		// Parse each setting
-		switch key {
-		case "advanced_mode_enabled":
+	for _, setting := range stored {
+		value := setting.Value
+		switch setting.Key {
+		case repository.AccountSettingAdvancedMode:
 			settings.AdvancedModeEnabled = stringToBool(value)
-		case "heat_map_days":
+		case repository.AccountSettingHeatMapDays:
 			if days, err := strconv.Atoi(value); err == nil {
 				settings.HeatMapDays = days
 			}
-		case "low_stock_alerts":
+		case repository.AccountSettingLowStockAlerts:
 			settings.LowStockAlerts = stringToBool(value)
-		case "injection_reminders":
+		case repository.AccountSettingInjectionReminders:
 			settings.InjectionReminders = stringToBool(value)
-		case "reminder_time":
+		case repository.AccountSettingReminderTime:
 			settings.ReminderTime = value
-		case "reminder_frequency":
+		case repository.AccountSettingReminderFrequency:
 			if freq, err := strconv.Atoi(value); err == nil {
 				settings.ReminderFrequency = freq
 			}
+		case repository.AccountSettingDuplicateWindow:
+			if minutes, err := strconv.Atoi(value); err == nil {
+				settings.DuplicateWindowMinutes = minutes
+			}
 		}
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	if !latestUpdate.IsZero() {
-		settings.UpdatedAt = latestUpdate
+		// The most recent change is the settings' version
+		if setting.UpdatedAt.After(latestUpdate) {
+			latestUpdate = setting.UpdatedAt
+		}
 	}
+	settings.UpdatedAt = latestUpdate
 
 	return settings, nil
 }
 
-// upsertSetting inserts or updates a setting
-func upsertSetting(tx *sql.Tx, key, value string, userID int64, now time.Time) error {
-	// Check if setting exists
-	var exists bool
-	err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM settings WHERE key = ?)", key).Scan(&exists)
-	if err != nil {
-		return err
-	}
-
-	if exists {
-		// Update existing setting
-		_, err = tx.Exec(`
-			UPDATE settings
-			SET value = ?, updated_at = ?, updated_by = ?
-			WHERE key = ?
-		`, value, now, userID, key)
-	} else {
-		// Insert new setting
-		_, err = tx.Exec(`
-			INSERT INTO settings (key, value, updated_at, updated_by)
-			VALUES (?, ?, ?, ?)
-		`, key, value, now, userID)
-	}
-
-	return err
-}
-
 // isValidTimeFormat validates HH:MM time format
 func isValidTimeFormat(timeStr string) bool {
 	_, err := time.Parse("15:04", timeStr)
@@ -331,13 +306,11 @@ func stringToBool(s string) bool {
 // GetUserTimezone retrieves the user's timezone preference from the database
 // Returns "America/New_York" (ET with automatic DST) as default
 func GetUserTimezone(db *database.DB, userID int64) string {
-	var timezone string
-	err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`,
-		fmt.Sprintf("user_timezone_%d", userID)).Scan(&timezone)
-	if err != nil || timezone == "" {
+	prefs, err := repository.NewSettingsRepository(db).GetPreferences(userID)
+	if err != nil || prefs.Timezone == "" {
 		return "America/New_York" // Default to ET
 	}
-	return timezone
+	return prefs.Timezone
 }
 
 // ConvertToUserTZ converts a time.Time to the user's timezone
@@ -357,16 +330,14 @@ func ConvertToUserTZ(t time.Time, timezone string) time.Time {
 
 // FormatTimeForUser formats a time according to user's time format preference
 func FormatTimeForUser(db *database.DB, userID int64, t time.Time) string {
-	var timeFormat string
-	err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`,
-		fmt.Sprintf("user_time_format_%d", userID)).Scan(&timeFormat)
+	prefs, err := repository.NewSettingsRepository(db).GetPreferences(userID)
 
 	// Convert to user's timezone first
 	timezone := GetUserTimezone(db, userID)
 	t = ConvertToUserTZ(t, timezone)
 
 	// Format based on preference
-	if err == nil && timeFormat == "24h" {
+	if err == nil && prefs.TimeFormat == "24h" {
 		return t.Format("15:04") // 24-hour format
 	}
 	return t.Format("3:04 PM") // 12-hour format (default)
@@ -374,9 +345,7 @@ func FormatTimeForUser(db *database.DB, userID int64, t time.Time) string {
 
 // FormatDateTimeForUser formats a date and time according to user preferences
 func FormatDateTimeForUser(db *database.DB, userID int64, t time.Time) string {
-	var dateFormat string
-	err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`,
-		fmt.Sprintf("user_date_format_%d", userID)).Scan(&dateFormat)
+	prefs, err := repository.NewSettingsRepository(db).GetPreferences(userID)
 
 	// Convert to user's timezone first
 	timezone := GetUserTimezone(db, userID)
@@ -385,7 +354,7 @@ func FormatDateTimeForUser(db *database.DB, userID int64, t time.Time) string {
 	// Determine date format
 	var goDateFormat string
 	if err == nil {
-		switch dateFormat {
+		switch prefs.DateFormat {
 		case "DD/MM/YYYY":
 			goDateFormat = "02/01/2006"
 		case "YYYY-MM-DD":
@@ -408,7 +377,7 @@ func HandleUpdateProfile(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -425,7 +394,7 @@ func HandleChangePassword(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -437,89 +406,103 @@ func HandleChangePassword(db *database.DB) http.HandlerFunc {
 	}
 }
 
+// AppSettingsRequest is the payload for updating display settings
+type AppSettingsRequest struct {
+	Theme        string `json:"theme"`
+	Timezone     string `json:"timezone" validate:"max=64"`
+	Locale       string `json:"locale" validate:"max=35"`
+	DateFormat   string `json:"date_format"`
+	TimeFormat   string `json:"time_format"`
+	Units        string `json:"units"`
+	WeekStart    string `json:"week_start"`
+	AdvancedMode bool   `json:"advanced_mode"`
+	// Shared by the account; leaving it out keeps it
+	DuplicateWindowMinutes *int `json:"duplicate_window_minutes,omitempty" validate:"min=0,max=1440"`
+}
+
 // HandleUpdateAppSettings updates application settings (theme, timezone, etc.)
 func HandleUpdateAppSettings(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		var req struct {
-			Theme        string `json:"theme"`
-			Timezone     string `json:"timezone"`
-			DateFormat   string `json:"date_format"`
-			TimeFormat   string `json:"time_format"`
-			AdvancedMode bool   `json:"advanced_mode"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		accountID := middleware.GetAccountID(r.Context())
+		if accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Validate theme
-		validThemes := map[string]bool{"light": true, "dark": true, "auto": true}
-		if req.Theme != "" && !validThemes[req.Theme] {
-			http.Error(w, "Invalid theme", http.StatusBadRequest)
+		var req AppSettingsRequest
+
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		// Validate timezone
+		// Empty fields are left as they are
+		update := UpdatePreferencesRequest{}
+		if req.Theme != "" {
+			update.Theme = &req.Theme
+		}
 		if req.Timezone != "" {
-			if _, err := time.LoadLocation(req.Timezone); err != nil {
-				http.Error(w, "Invalid timezone", http.StatusBadRequest)
-				return
-			}
+			update.Timezone = &req.Timezone
+		}
+		if req.Locale != "" {
+			update.Locale = &req.Locale
+		}
+		if req.DateFormat != "" {
+			update.DateFormat = &req.DateFormat
+		}
+		if req.TimeFormat != "" {
+			update.TimeFormat = &req.TimeFormat
+		}
+		if req.Units != "" {
+			update.Units = &req.Units
+		}
+		if req.WeekStart != "" {
+			update.WeekStart = &req.WeekStart
 		}
 
 		// Begin transaction
 		tx, err := db.BeginTx()
 		if err != nil {
-			http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+			respond.Error(w, "Failed to start transaction", http.StatusInternalServerError)
 			return
 		}
 		defer func() { _ = tx.Rollback() }()
 
-		now := time.Now()
-
-		// Store settings with user ID prefix
-		if req.Theme != "" {
-			if err := upsertSetting(tx, fmt.Sprintf("user_theme_%d", userID), req.Theme, userID, now); err != nil {
-				http.Error(w, "Failed to update theme", http.StatusInternalServerError)
-				return
-			}
+		settingsRepo := repository.NewSettingsRepositoryTx(tx)
+		prefs, err := settingsRepo.GetPreferences(userID)
+		if err != nil {
+			respond.Error(w, "Failed to get preferences", http.StatusInternalServerError)
+			return
 		}
-
-		if req.Timezone != "" {
-			if err := upsertSetting(tx, fmt.Sprintf("user_timezone_%d", userID), req.Timezone, userID, now); err != nil {
-				http.Error(w, "Failed to update timezone", http.StatusInternalServerError)
-				return
-			}
+		if fields := applyPreferences(prefs, &update); len(fields) > 0 {
+			respond.Validation(w, "", fields...)
+			return
 		}
-
-		if req.DateFormat != "" {
-			if err := upsertSetting(tx, fmt.Sprintf("user_date_format_%d", userID), req.DateFormat, userID, now); err != nil {
-				http.Error(w, "Failed to update date format", http.StatusInternalServerError)
-				return
-			}
+		if err := settingsRepo.SavePreferences(prefs); err != nil {
+			respond.Error(w, "Failed to update preferences", http.StatusInternalServerError)
+			return
 		}
 
-		if req.TimeFormat != "" {
-			if err := upsertSetting(tx, fmt.Sprintf("user_time_format_%d", userID), req.TimeFormat, userID, now); err != nil {
-				http.Error(w, "Failed to update time format", http.StatusInternalServerError)
+		// Advanced mode and the duplicate window are shared by the account
+		if err := settingsRepo.SetAccount(accountID, repository.AccountSettingAdvancedMode, boolToString(req.AdvancedMode), sql.NullInt64{Int64: userID, Valid: true}); err != nil {
+			respond.Error(w, "Failed to update advanced mode", http.StatusInternalServerError)
+			return
+		}
+		if req.DuplicateWindowMinutes != nil {
+			if err := settingsRepo.SetAccount(accountID, repository.AccountSettingDuplicateWindow, strconv.Itoa(*req.DuplicateWindowMinutes), sql.NullInt64{Int64: userID, Valid: true}); err != nil {
+				respond.Error(w, "Failed to update duplicate window", http.StatusInternalServerError)
 				return
 			}
 		}
 
-		if err := upsertSetting(tx, "advanced_mode_enabled", boolToString(req.AdvancedMode), userID, now); err != nil {
-			http.Error(w, "Failed to update advanced mode", http.StatusInternalServerError)
-			return
-		}
-
 		if err := tx.Commit(); err != nil {
-			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			respond.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
 			return
 		}
 
@@ -529,61 +512,87 @@ func HandleUpdateAppSettings(db *database.DB) http.HandlerFunc {
 	}
 }
 
+// NotificationSettingsRequest is the payload for updating notification settings
+type NotificationSettingsRequest struct {
+	EnableNotifications bool   `json:"enable_notifications"`
+	InjectionReminders  bool   `json:"injection_reminders"`
+	ReminderTime        string `json:"reminder_time"`
+	LowStockAlerts      bool   `json:"low_stock_alerts"`
+
+	// When to remind the user to do their daily check-in, HH:MM in their
+	// timezone; empty turns the reminder off and leaving it out keeps it
+	CheckInReminderTime *string `json:"checkin_reminder_time,omitempty"`
+}
+
 // HandleUpdateNotificationSettings updates notification settings
 func HandleUpdateNotificationSettings(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		var req struct {
-			EnableNotifications bool   `json:"enable_notifications"`
-			InjectionReminders  bool   `json:"injection_reminders"`
-			ReminderTime        string `json:"reminder_time"`
-			LowStockAlerts      bool   `json:"low_stock_alerts"`
+		accountID := middleware.GetAccountID(r.Context())
+		if accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
 
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		var req NotificationSettingsRequest
+
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.CheckInReminderTime != nil && *req.CheckInReminderTime != "" && !isValidTimeFormat(*req.CheckInReminderTime) {
+			respond.Validation(w, "Invalid check-in reminder time (use HH:MM)", respond.Field("checkin_reminder_time", "invalid format (use HH:MM)"))
 			return
 		}
 
 		// Begin transaction
 		tx, err := db.BeginTx()
 		if err != nil {
-			http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+			respond.Error(w, "Failed to start transaction", http.StatusInternalServerError)
 			return
 		}
 		defer func() { _ = tx.Rollback() }()
 
-		now := time.Now()
+		settingsRepo := repository.NewSettingsRepositoryTx(tx)
+		updatedBy := sql.NullInt64{Int64: userID, Valid: true}
 
-		if err := upsertSetting(tx, fmt.Sprintf("user_enable_notifications_%d", userID), boolToString(req.EnableNotifications), userID, now); err != nil {
-			http.Error(w, "Failed to update enable notifications", http.StatusInternalServerError)
+		if err := settingsRepo.SetUser(userID, repository.UserSettingEnableNotifications, boolToString(req.EnableNotifications)); err != nil {
+			respond.Error(w, "Failed to update enable notifications", http.StatusInternalServerError)
 			return
 		}
 
-		if err := upsertSetting(tx, "injection_reminders", boolToString(req.InjectionReminders), userID, now); err != nil {
-			http.Error(w, "Failed to update injection reminders", http.StatusInternalServerError)
+		// Reminders and stock alerts are shared by the account
+		if err := settingsRepo.SetAccount(accountID, repository.AccountSettingInjectionReminders, boolToString(req.InjectionReminders), updatedBy); err != nil {
+			respond.Error(w, "Failed to update injection reminders", http.StatusInternalServerError)
 			return
 		}
 
 		if req.ReminderTime != "" {
-			if err := upsertSetting(tx, "reminder_time", req.ReminderTime, userID, now); err != nil {
-				http.Error(w, "Failed to update reminder time", http.StatusInternalServerError)
+			if err := settingsRepo.SetAccount(accountID, repository.AccountSettingReminderTime, req.ReminderTime, updatedBy); err != nil {
+				respond.Error(w, "Failed to update reminder time", http.StatusInternalServerError)
 				return
 			}
 		}
 
-		if err := upsertSetting(tx, "low_stock_alerts", boolToString(req.LowStockAlerts), userID, now); err != nil {
-			http.Error(w, "Failed to update low stock alerts", http.StatusInternalServerError)
+		if err := settingsRepo.SetAccount(accountID, repository.AccountSettingLowStockAlerts, boolToString(req.LowStockAlerts), updatedBy); err != nil {
+			respond.Error(w, "Failed to update low stock alerts", http.StatusInternalServerError)
 			return
 		}
 
+		if req.CheckInReminderTime != nil {
+			if err := settingsRepo.SetUser(userID, repository.UserSettingCheckInReminder, *req.CheckInReminderTime); err != nil {
+				respond.Error(w, "Failed to update check-in reminder", http.StatusInternalServerError)
+				return
+			}
+		}
+
 		if err := tx.Commit(); err != nil {
-			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			respond.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
 			return
 		}
 
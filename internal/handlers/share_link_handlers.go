@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+	"injection-tracker/internal/web"
+)
+
+// What a share link can show
+const (
+	ShareScopeInjections  = "injections"  // Recent injections
+	ShareScopeAdherence   = "adherence"   // Days injected while a course ran
+	ShareScopeSchedule    = "schedule"    // Upcoming injections and appointments
+	ShareScopeMedications = "medications" // Active medications and their times
+)
+
+// ShareScopes lists every scope, in the order the shared dashboard shows them
+var ShareScopes = []string{ShareScopeInjections, ShareScopeAdherence, ShareScopeSchedule, ShareScopeMedications}
+
+// defaultShareScopes is what a link shows when no scopes are asked for
+var defaultShareScopes = []string{ShareScopeInjections, ShareScopeAdherence, ShareScopeSchedule}
+
+const (
+	// Share links last this many days unless asked otherwise, and no longer
+	// than the maximum
+	defaultShareLinkExpiryDays = 7
+	maxShareLinkExpiryDays     = 90
+
+	// sharedInjectionDays is how far back the shared dashboard lists
+	// injections, and sharedAdherenceDays how far back it measures adherence
+	sharedInjectionDays = 14
+	sharedAdherenceDays = 30
+	// sharedScheduleMax caps the upcoming events shown
+	sharedScheduleMax = 10
+	// shareLinkAccessLimit caps the views listed for a link
+	shareLinkAccessLimit = 100
+)
+
+// CreateShareLinkRequest describes a new share link
+type CreateShareLinkRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes,omitempty" validate:"max=20,itemmax=50"` // Defaults to injections, adherence and schedule
+	ExpiresInDays int      `json:"expires_in_days,omitempty"`
+}
+
+// ShareLinkResponse is a share link as listed by the API
+type ShareLinkResponse struct {
+	ID              int64      `json:"id"`
+	Name            string     `json:"name"`
+	Scopes          []string   `json:"scopes"`
+	CreatedBy       int64      `json:"created_by"`
+	CreatorUsername string     `json:"creator_username,omitempty"`
+	Status          string     `json:"status"` // 'active' or 'expired'
+	CreatedAt       time.Time  `json:"created_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreateShareLinkResponse carries the link's URL, which can't be shown again
+type CreateShareLinkResponse struct {
+	ShareLinkResponse
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// ShareLinkAccessResponse is one view of a share link
+type ShareLinkAccessResponse struct {
+	AccessedAt time.Time `json:"accessed_at"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+func toShareLinkResponse(link *models.ShareLink, now time.Time) ShareLinkResponse {
+	resp := ShareLinkResponse{
+		ID:              link.ID,
+		Name:            link.Name,
+		Scopes:          link.Scopes,
+		CreatedBy:       link.CreatedBy,
+		CreatorUsername: link.CreatorUsername,
+		Status:          "active",
+		CreatedAt:       link.CreatedAt,
+		ExpiresAt:       link.ExpiresAt,
+	}
+	if !now.Before(link.ExpiresAt) {
+		resp.Status = "expired"
+	}
+	if link.LastUsedAt.Valid {
+		resp.LastUsedAt = &link.LastUsedAt.Time
+	}
+	return resp
+}
+
+// validShareScopes checks requested scopes and returns them in ShareScopes
+// order without duplicates
+func validShareScopes(requested []string) ([]string, bool) {
+	if len(requested) == 0 {
+		return defaultShareScopes, true
+	}
+	want := map[string]bool{}
+	for _, s := range requested {
+		want[s] = true
+	}
+	var scopes []string
+	for _, s := range ShareScopes {
+		if want[s] {
+			scopes = append(scopes, s)
+			delete(want, s)
+		}
+	}
+	return scopes, len(want) == 0
+}
+
+// HandleGetShareLinks lists the account's share links that haven't been
+// revoked
+func HandleGetShareLinks(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		links, err := repository.NewShareLinkRepository(db).List(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to get share links", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		resp := make([]ShareLinkResponse, 0, len(links))
+		for _, link := range links {
+			resp = append(resp, toShareLinkResponse(link, now))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleCreateShareLink creates a share link and returns its URL
+func HandleCreateShareLink(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req CreateShareLinkRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		scopes, ok := validShareScopes(req.Scopes)
+		if !ok {
+			respond.Validation(w, "", respond.Field("scopes", "must be any of "+strings.Join(ShareScopes, ", ")))
+			return
+		}
+		if req.ExpiresInDays == 0 {
+			req.ExpiresInDays = defaultShareLinkExpiryDays
+		}
+		if req.ExpiresInDays < 1 || req.ExpiresInDays > maxShareLinkExpiryDays {
+			respond.Validation(w, "", respond.Field("expires_in_days", "must be between 1 and "+strconv.Itoa(maxShareLinkExpiryDays)))
+			return
+		}
+
+		now := time.Now()
+		link, token, err := repository.NewShareLinkRepository(db).Create(accountID, userID, req.Name, scopes, now.AddDate(0, 0, req.ExpiresInDays))
+		if err != nil {
+			respond.Error(w, "Failed to create share link", http.StatusInternalServerError)
+			return
+		}
+		link.CreatorUsername = middleware.GetUserContext(r).Username
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"share_link",
+			sql.NullInt64{Int64: link.ID, Valid: true},
+			map[string]interface{}{"name": link.Name, "scopes": link.Scopes, "expires_at": link.ExpiresAt},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusCreated, CreateShareLinkResponse{
+			ShareLinkResponse: toShareLinkResponse(link, now),
+			Token:             token,
+			URL:               siteBaseURL(r, getSiteSettings(db)) + "/share/" + url.PathEscape(token),
+		})
+	}
+}
+
+// shareLinkFromURL loads the account's share link named by the {id} URL
+// parameter, writing the error response if it can't
+func shareLinkFromURL(w http.ResponseWriter, r *http.Request, db *database.DB, accountID int64) (*models.ShareLink, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respond.Error(w, "Invalid share link ID", http.StatusBadRequest)
+		return nil, false
+	}
+	link, err := repository.NewShareLinkRepository(db).Get(accountID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respond.Error(w, "Share link not found", http.StatusNotFound)
+		} else {
+			respond.Error(w, "Failed to get share link", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return link, true
+}
+
+// HandleRevokeShareLink revokes a share link straight away. Owners can
+// revoke any link; members only their own.
+func HandleRevokeShareLink(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		link, ok := shareLinkFromURL(w, r, db, accountID)
+		if !ok {
+			return
+		}
+		if link.CreatedBy != userID && middleware.GetRole(r.Context()) != "owner" {
+			respond.Error(w, "Forbidden: only the account owner or the link's creator can revoke it", http.StatusForbidden)
+			return
+		}
+
+		if err := repository.NewShareLinkRepository(db).Revoke(accountID, link.ID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				respond.Error(w, "Share link not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to revoke share link", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"revoke",
+			"share_link",
+			sql.NullInt64{Int64: link.ID, Valid: true},
+			map[string]interface{}{"name": link.Name},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleGetShareLinkAccesses lists the most recent views of a share link
+func HandleGetShareLinkAccesses(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		link, ok := shareLinkFromURL(w, r, db, accountID)
+		if !ok {
+			return
+		}
+
+		accesses, err := repository.NewShareLinkRepository(db).ListAccesses(link.ID, shareLinkAccessLimit)
+		if err != nil {
+			respond.Error(w, "Failed to get share link accesses", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]ShareLinkAccessResponse, 0, len(accesses))
+		for _, a := range accesses {
+			resp = append(resp, ShareLinkAccessResponse{
+				AccessedAt: a.AccessedAt,
+				IPAddress:  a.IPAddress.String,
+				UserAgent:  a.UserAgent.String,
+			})
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// SharedDashboard is what a share link shows. Sections outside the link's
+// scopes are left empty; times are formatted in the creator's timezone.
+type SharedDashboard struct {
+	Name        string
+	ExpiresAt   string
+	Scopes      map[string]bool
+	Injections  []SharedInjection
+	Adherence   *SharedAdherence
+	Schedule    []SharedEvent
+	Medications []SharedMedication
+}
+
+// SharedInjection is an injection on the shared dashboard. Notes are left
+// out.
+type SharedInjection struct {
+	When           string
+	Side           string
+	PainLevel      int
+	HasPain        bool
+	SiteReaction   string
+	AdministeredBy string
+}
+
+// SharedAdherence is the share of days with an injection while a course ran
+type SharedAdherence struct {
+	Days         int
+	ExpectedDays int
+	InjectedDays int
+	Percent      int
+}
+
+// SharedEvent is an upcoming injection or appointment
+type SharedEvent struct {
+	When     string
+	Summary  string
+	Location string
+}
+
+// SharedMedication is an active medication
+type SharedMedication struct {
+	Name          string
+	Dosage        string
+	Frequency     string
+	ScheduledTime string
+}
+
+// buildSharedDashboard gathers what a share link shows as of now
+func buildSharedDashboard(db *database.DB, link *models.ShareLink, now time.Time) (*SharedDashboard, error) {
+	loc := userLocation(db, link.CreatedBy)
+	d := &SharedDashboard{
+		Name:      link.Name,
+		ExpiresAt: link.ExpiresAt.In(loc).Format("Jan 2, 2006 3:04 PM"),
+		Scopes:    map[string]bool{},
+	}
+	for _, s := range link.Scopes {
+		d.Scopes[s] = true
+	}
+
+	if d.Scopes[ShareScopeInjections] || d.Scopes[ShareScopeAdherence] {
+		// Only shared symptom logs, though no section shows them
+		report, err := gatherReportData(db, link.AccountID, repository.SymptomViewer{}, now.AddDate(0, 0, -sharedAdherenceDays), now, "", loc)
+		if err != nil {
+			return nil, err
+		}
+		if d.Scopes[ShareScopeInjections] {
+			since := now.AddDate(0, 0, -sharedInjectionDays)
+			d.Injections = []SharedInjection{}
+			for _, inj := range report.Injections {
+				if inj.Timestamp.Before(since) {
+					continue
+				}
+				d.Injections = append(d.Injections, SharedInjection{
+					When:           inj.Timestamp.In(loc).Format("Mon Jan 2, 3:04 PM"),
+					Side:           inj.Side,
+					PainLevel:      inj.PainLevel,
+					HasPain:        inj.HasPain,
+					SiteReaction:   inj.SiteReaction,
+					AdministeredBy: inj.AdministeredBy,
+				})
+			}
+		}
+		if d.Scopes[ShareScopeAdherence] {
+			stats := computeReportStats(report)
+			d.Adherence = &SharedAdherence{
+				Days:         sharedAdherenceDays,
+				ExpectedDays: stats.ExpectedDays,
+				InjectedDays: stats.InjectedDays,
+			}
+			if stats.ExpectedDays > 0 {
+				d.Adherence.Percent = stats.InjectedDays * 100 / stats.ExpectedDays
+			}
+		}
+	}
+
+	if d.Scopes[ShareScopeSchedule] {
+		events, err := calendarFeedEvents(db, link.AccountID, loc, now)
+		if err != nil {
+			return nil, err
+		}
+		// Repeating medication times belong to the medications scope
+		var upcoming []services.CalendarEvent
+		for _, e := range events {
+			if e.RRule == "" && e.End.After(now) {
+				upcoming = append(upcoming, e)
+			}
+		}
+		sort.Slice(upcoming, func(i, j int) bool { return upcoming[i].Start.Before(upcoming[j].Start) })
+		if len(upcoming) > sharedScheduleMax {
+			upcoming = upcoming[:sharedScheduleMax]
+		}
+		d.Schedule = []SharedEvent{}
+		for _, e := range upcoming {
+			d.Schedule = append(d.Schedule, SharedEvent{
+				When:     e.Start.In(loc).Format("Mon Jan 2, 3:04 PM"),
+				Summary:  e.Summary,
+				Location: e.Location,
+			})
+		}
+	}
+
+	if d.Scopes[ShareScopeMedications] {
+		medications, err := repository.NewMedicationRepository(db).ListActive(link.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		d.Medications = []SharedMedication{}
+		for _, med := range medications {
+			d.Medications = append(d.Medications, SharedMedication{
+				Name:          med.Name,
+				Dosage:        med.Dosage.String,
+				Frequency:     med.Frequency.String,
+				ScheduledTime: strings.Join(med.DoseTimes, ", "),
+			})
+		}
+	}
+
+	return d, nil
+}
+
+// renderSharePage renders the shared dashboard, or with a nil dashboard the
+// page saying the link doesn't work
+func renderSharePage(w http.ResponseWriter, r *http.Request, db *database.DB, status int, dashboard *SharedDashboard) {
+	data := map[string]interface{}{
+		"Title":           "Shared Dashboard",
+		"IsAuthenticated": false,
+		"SiteTitle":       getSiteSettings(db).SiteTitle,
+		"Share":           dashboard,
+	}
+
+	// The token is in the URL, so keep it out of referrers and caches
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := web.Render(w, r, "share.html", data); err != nil {
+		middleware.Log(r.Context()).Error("Failed to render shared dashboard", "err", err)
+	}
+}
+
+// HandleSharedDashboard serves the read-only dashboard for a share link. It
+// needs no login; the token in the URL is the only credential. Every view
+// is recorded.
+func HandleSharedDashboard(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		now := time.Now()
+
+		linkRepo := repository.NewShareLinkRepository(db)
+		link, err := linkRepo.GetByToken(chi.URLParam(r, "token"), now)
+		if err != nil {
+			if !errors.Is(err, repository.ErrNotFound) {
+				middleware.Log(r.Context()).Error("Failed to look up share link", "err", err)
+			}
+			renderSharePage(w, r, db, http.StatusNotFound, nil)
+			return
+		}
+
+		// The link stops if its creator is deactivated or leaves the
+		// account, or while the account is locked
+		var isActive bool
+		var suspension sql.NullString
+		err = db.QueryRow(`
+			SELECT u.is_active, a.suspension
+			FROM users u
+			JOIN account_members m ON m.user_id = u.id AND m.account_id = ?
+			JOIN accounts a ON a.id = m.account_id
+			WHERE u.id = ?
+		`, link.AccountID, link.CreatedBy).Scan(&isActive, &suspension)
+		if err != nil || !isActive || suspension.String == models.AccountLocked {
+			renderSharePage(w, r, db, http.StatusNotFound, nil)
+			return
+		}
+
+		dashboard, err := buildSharedDashboard(db, link, now)
+		if err != nil {
+			middleware.Log(r.Context()).Error("Failed to build shared dashboard", "err", err)
+			respond.Error(w, "Failed to build shared dashboard", http.StatusInternalServerError)
+			return
+		}
+
+		if err := linkRepo.RecordAccess(link.ID, getIPAddress(r), r.UserAgent(), now); err != nil {
+			middleware.Log(r.Context()).Warn("Failed to record share link access", "err", err)
+		}
+
+		renderSharePage(w, r, db, http.StatusOK, dashboard)
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+func TestValidShareScopes(t *testing.T) {
+	scopes, ok := validShareScopes(nil)
+	if !ok || len(scopes) != len(defaultShareScopes) {
+		t.Errorf("Expected the default scopes, got %v", scopes)
+	}
+
+	scopes, ok = validShareScopes([]string{"medications", "injections", "medications"})
+	if !ok || len(scopes) != 2 || scopes[0] != "injections" || scopes[1] != "medications" {
+		t.Errorf("Expected injections then medications, got %v", scopes)
+	}
+
+	if _, ok := validShareScopes([]string{"injections", "notes"}); ok {
+		t.Error("Expected an unknown scope to be rejected")
+	}
+}
+
+func TestBuildSharedDashboardScopes(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	now := time.Now()
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', ?, 1)`, now.AddDate(0, 0, -10)); err != nil {
+		t.Fatalf("Failed to seed course: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO injections (course_id, timestamp, side, notes) VALUES (1, ?, 'left', 'private'), (1, ?, 'right', '')`,
+		now.Add(-time.Hour), now.AddDate(0, 0, -20)); err != nil {
+		t.Fatalf("Failed to seed injections: %v", err)
+	}
+
+	link := &models.ShareLink{AccountID: 1, CreatedBy: 1, Name: "Nurse", Scopes: []string{ShareScopeInjections}, ExpiresAt: now.Add(time.Hour)}
+	d, err := buildSharedDashboard(db, link, now)
+	if err != nil {
+		t.Fatalf("buildSharedDashboard failed: %v", err)
+	}
+	if len(d.Injections) != 1 || d.Injections[0].Side != "left" {
+		t.Errorf("Expected only the injection from the last two weeks, got %+v", d.Injections)
+	}
+	if d.Adherence != nil || d.Schedule != nil || d.Medications != nil {
+		t.Errorf("Expected sections outside the scopes left out, got %+v", d)
+	}
+
+	link.Scopes = []string{ShareScopeAdherence, ShareScopeSchedule}
+	d, err = buildSharedDashboard(db, link, now)
+	if err != nil {
+		t.Fatalf("buildSharedDashboard failed: %v", err)
+	}
+	if d.Injections != nil || d.Adherence == nil || d.Adherence.InjectedDays != 1 {
+		t.Errorf("Expected adherence without injections, got %+v", d)
+	}
+	if len(d.Schedule) == 0 {
+		t.Error("Expected upcoming injections on the schedule")
+	}
+}
+
+func TestTokenRoutesOfSuspendedAccount(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	initTestTemplates(t)
+
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+	_, shareToken, err := repository.NewShareLinkRepository(db).Create(1, 1, "Nurse", defaultShareScopes, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create share link: %v", err)
+	}
+	_, calendarToken, err := repository.NewCalendarTokenRepository(db).Create(1, 1, "Phone")
+	if err != nil {
+		t.Fatalf("Failed to create calendar token: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/share/{token}", HandleSharedDashboard(db))
+	router.Get("/calendar.ics", HandleCalendarFeed(db))
+	accounts := repository.NewAccountRepository(db.DB)
+	for _, tt := range []struct {
+		suspension string
+		status     int
+	}{
+		{"", http.StatusOK},
+		{models.AccountReadOnly, http.StatusOK},
+		{models.AccountLocked, http.StatusNotFound},
+	} {
+		if tt.suspension == "" {
+			err = accounts.Reactivate(1)
+		} else {
+			err = accounts.Suspend(1, tt.suspension, "", 1)
+		}
+		if err != nil {
+			t.Fatalf("Failed to set suspension %q: %v", tt.suspension, err)
+		}
+		for _, path := range []string{"/share/" + shareToken, "/calendar.ics?token=" + url.QueryEscape(calendarToken)} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != tt.status {
+				t.Errorf("Expected %d for %s with suspension %q, got %d", tt.status, path[:6], tt.suspension, rec.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// StockAlertResponse is an item's active low stock alert
+type StockAlertResponse struct {
+	ID             int64      `json:"id"`
+	ItemType       string     `json:"item_type"`
+	Name           string     `json:"name"`
+	Level          string     `json:"level"` // "low", "critical" or "out"
+	Quantity       float64    `json:"quantity"`
+	Threshold      float64    `json:"threshold"`
+	RaisedAt       time.Time  `json:"raised_at"`
+	NotifiedAt     *time.Time `json:"notified_at,omitempty"`
+	NotifyCount    int        `json:"notify_count"`
+	Acknowledged   bool       `json:"acknowledged"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy *int64     `json:"acknowledged_by,omitempty"`
+}
+
+// toStockAlertResponse converts an alert, naming its item from the account's
+// item types
+func toStockAlertResponse(a *models.StockAlert, types map[string]*models.InventoryItemType) StockAlertResponse {
+	resp := StockAlertResponse{
+		ID:           a.ID,
+		ItemType:     a.ItemType,
+		Name:         formatItemTypeName(a.ItemType),
+		Level:        a.Level,
+		Quantity:     a.Quantity,
+		Threshold:    a.Threshold,
+		RaisedAt:     a.RaisedAt,
+		NotifyCount:  a.NotifyCount,
+		Acknowledged: a.AcknowledgedAt.Valid,
+	}
+	if def := types[a.ItemType]; def != nil {
+		resp.Name = def.Name
+	}
+	if a.NotifiedAt.Valid {
+		resp.NotifiedAt = &a.NotifiedAt.Time
+	}
+	if a.AcknowledgedAt.Valid {
+		resp.AcknowledgedAt = &a.AcknowledgedAt.Time
+	}
+	if a.AcknowledgedBy.Valid {
+		resp.AcknowledgedBy = &a.AcknowledgedBy.Int64
+	}
+	return resp
+}
+
+// checkStockAlert brings an item's stock alert up to date after its
+// quantity or threshold changed. Any notification goes out after the
+// response, so it can't use the request's context.
+func checkStockAlert(db *database.DB, accountID int64, item *models.InventoryItem) {
+	if accountID == 0 || item == nil {
+		return
+	}
+	service := services.NewNotificationService(db.Detach())
+	services.RunBackground(func() {
+		if err := service.CheckStockAlert(accountID, item); err != nil {
+			slog.Error("Failed to check stock alert", "account_id", accountID, "item_type", item.ItemType, "err", err)
+		}
+	})
+}
+
+// HandleGetStockAlerts lists the account's active low stock alerts, most
+// severe first, with whether each has been acknowledged
+func HandleGetStockAlerts(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		alerts, err := repository.NewStockAlertRepository(db).ListActive(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve stock alerts", http.StatusInternalServerError)
+			return
+		}
+		types, err := accountItemTypes(db, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve item types", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]StockAlertResponse, 0, len(alerts))
+		for _, a := range alerts {
+			resp = append(resp, toStockAlertResponse(a, types))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleAcknowledgeStockAlert stops an active stock alert being repeated.
+// It is sent again only if the item falls to a more severe level.
+func HandleAcknowledgeStockAlert(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid stock alert ID", http.StatusBadRequest)
+			return
+		}
+
+		alert, err := repository.NewStockAlertRepository(db).Acknowledge(id, accountID, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				respond.Error(w, "Stock alert not found or already resolved", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to acknowledge stock alert", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"acknowledge",
+			"stock_alert",
+			sql.NullInt64{Int64: alert.ID, Valid: true},
+			map[string]interface{}{"item_type": alert.ItemType, "level": alert.Level},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		types, _ := accountItemTypes(db, accountID)
+		respondJSON(w, http.StatusOK, toStockAlertResponse(alert, types))
+	}
+}
@@ -0,0 +1,557 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxStocktakesListed caps how many stocktakes are listed
+const maxStocktakesListed = 50
+
+// StartStocktakeRequest is the optional payload for starting a stocktake
+type StartStocktakeRequest struct {
+	Notes *string `json:"notes,omitempty" validate:"multiline,max=1000"`
+}
+
+// StocktakeCountRequest records what was found of an item, or of one of its
+// lots. CountedQuantity is in the item's usage unit unless Unit names its
+// purchase unit.
+type StocktakeCountRequest struct {
+	ItemType        string   `json:"item_type" validate:"required,max=50"`
+	LotID           *int64   `json:"lot_id,omitempty"`
+	CountedQuantity *float64 `json:"counted_quantity" validate:"required,min=0"`
+	Unit            *string  `json:"unit,omitempty" validate:"max=20"`
+	Notes           *string  `json:"notes,omitempty" validate:"multiline,max=1000"`
+}
+
+// StocktakeResponse is a stocktake as returned by the API
+type StocktakeResponse struct {
+	ID        int64      `json:"id"`
+	Status    string     `json:"status"` // open, completed or cancelled
+	Notes     *string    `json:"notes,omitempty"`
+	StartedBy *int64     `json:"started_by,omitempty"`
+	StartedAt time.Time  `json:"started_at"`
+	ClosedBy  *int64     `json:"closed_by,omitempty"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+}
+
+// StocktakeCountResponse is one count in a stocktake
+type StocktakeCountResponse struct {
+	ID               int64     `json:"id"`
+	ItemType         string    `json:"item_type"`
+	LotID            *int64    `json:"lot_id,omitempty"`
+	LotNumber        *string   `json:"lot_number,omitempty"`
+	RecordedQuantity float64   `json:"recorded_quantity"`
+	CountedQuantity  float64   `json:"counted_quantity"`
+	Variance         float64   `json:"variance"`
+	Notes            *string   `json:"notes,omitempty"`
+	CountedBy        *int64    `json:"counted_by,omitempty"`
+	CountedAt        time.Time `json:"counted_at"`
+}
+
+// StocktakeItemResponse is one item's line in a stocktake's variance report
+type StocktakeItemResponse struct {
+	ItemType         string                   `json:"item_type"`
+	Name             string                   `json:"name"`
+	Unit             string                   `json:"unit"`
+	RecordedQuantity float64                  `json:"recorded_quantity"`
+	CountedQuantity  float64                  `json:"counted_quantity"`
+	Variance         float64                  `json:"variance"`
+	VariancePercent  *float64                 `json:"variance_percent,omitempty"` // Of the recorded quantity
+	ByLot            bool                     `json:"by_lot"`
+	Counts           []StocktakeCountResponse `json:"counts"`
+}
+
+// StocktakeReportResponse is a stocktake with its variance report. Uncounted
+// lists item types with stock that haven't been counted; completing leaves
+// them as recorded.
+type StocktakeReportResponse struct {
+	Stocktake     StocktakeResponse          `json:"stocktake"`
+	Items         []StocktakeItemResponse    `json:"items"`
+	ItemsCounted  int                        `json:"items_counted"`
+	Discrepancies int                        `json:"discrepancies"`
+	Uncounted     []string                   `json:"uncounted"`
+	Adjustments   []InventoryHistoryResponse `json:"adjustments,omitempty"` // Posted on completion
+}
+
+func toStocktakeResponse(s *models.Stocktake) StocktakeResponse {
+	resp := StocktakeResponse{
+		ID:        s.ID,
+		Status:    s.Status,
+		StartedAt: s.StartedAt,
+	}
+	if s.Notes.Valid {
+		resp.Notes = &s.Notes.String
+	}
+	if s.StartedBy.Valid {
+		resp.StartedBy = &s.StartedBy.Int64
+	}
+	if s.ClosedBy.Valid {
+		resp.ClosedBy = &s.ClosedBy.Int64
+	}
+	if s.ClosedAt.Valid {
+		resp.ClosedAt = &s.ClosedAt.Time
+	}
+	return resp
+}
+
+func toStocktakeCountResponse(c *models.StocktakeCount) StocktakeCountResponse {
+	resp := StocktakeCountResponse{
+		ID:               c.ID,
+		ItemType:         c.ItemType,
+		RecordedQuantity: c.RecordedQuantity,
+		CountedQuantity:  c.CountedQuantity,
+		Variance:         math.Round(c.Variance()*1000) / 1000,
+		CountedAt:        c.CountedAt,
+	}
+	if c.LotID.Valid {
+		resp.LotID = &c.LotID.Int64
+	}
+	if c.LotNumber.Valid {
+		resp.LotNumber = &c.LotNumber.String
+	}
+	if c.Notes.Valid {
+		resp.Notes = &c.Notes.String
+	}
+	if c.CountedBy.Valid {
+		resp.CountedBy = &c.CountedBy.Int64
+	}
+	return resp
+}
+
+// stocktakeFromRequest loads the stocktake named in the URL, writing the
+// error response and returning nil if it can't
+func stocktakeFromRequest(w http.ResponseWriter, r *http.Request, db *database.DB, accountID int64) *models.Stocktake {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respond.Error(w, "Invalid stocktake ID", http.StatusBadRequest)
+		return nil
+	}
+	stocktake, err := repository.NewStocktakeRepository(db).GetByID(id, accountID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respond.Error(w, "Stocktake not found", http.StatusNotFound)
+			return nil
+		}
+		respond.Error(w, "Failed to retrieve stocktake", http.StatusInternalServerError)
+		return nil
+	}
+	return stocktake
+}
+
+// respondStocktakeError writes the response for an error from counting,
+// completing or cancelling a stocktake
+func respondStocktakeError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, repository.ErrStocktakeClosed):
+		respond.Error(w, "Stocktake is no longer open", http.StatusConflict)
+	case errors.Is(err, repository.ErrStocktakeCountMixed):
+		respond.Error(w, "Item is already counted the other way; count it either as a whole or by lot", http.StatusConflict)
+	case errors.Is(err, repository.ErrNotFound):
+		respond.Error(w, "Stocktake not found", http.StatusNotFound)
+	default:
+		respond.Error(w, "Failed to "+action, http.StatusInternalServerError)
+	}
+}
+
+// buildStocktakeReport totals a stocktake's counts per item and lists the
+// stocked items left uncounted
+func buildStocktakeReport(db *database.DB, accountID int64, stocktake *models.Stocktake) (*StocktakeReportResponse, error) {
+	repo := repository.NewStocktakeRepository(db)
+	counts, err := repo.ListCounts(stocktake.ID)
+	if err != nil {
+		return nil, err
+	}
+	types, err := accountItemTypes(db, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StocktakeReportResponse{
+		Stocktake: toStocktakeResponse(stocktake),
+		Items:     []StocktakeItemResponse{},
+		Uncounted: []string{},
+	}
+	counted := map[string]bool{}
+	for _, v := range services.StocktakeVariances(counts) {
+		item := StocktakeItemResponse{
+			ItemType:         v.ItemType,
+			Name:             formatItemTypeName(v.ItemType),
+			RecordedQuantity: v.Recorded,
+			CountedQuantity:  v.Counted,
+			Variance:         v.Variance,
+			VariancePercent:  v.Percent,
+			ByLot:            v.ByLot,
+			Counts:           make([]StocktakeCountResponse, 0, len(v.Counts)),
+		}
+		if def := types[v.ItemType]; def != nil {
+			item.Name, item.Unit = def.Name, def.Unit
+		}
+		for _, c := range v.Counts {
+			item.Counts = append(item.Counts, toStocktakeCountResponse(c))
+		}
+		if v.Discrepancy() {
+			report.Discrepancies++
+		}
+		counted[v.ItemType] = true
+		report.Items = append(report.Items, item)
+	}
+	report.ItemsCounted = len(report.Items)
+
+	items, err := repository.NewInventoryRepository(db).List(accountID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.Quantity > 0 && !counted[item.ItemType] {
+			report.Uncounted = append(report.Uncounted, item.ItemType)
+		}
+	}
+
+	if stocktake.Status == models.StocktakeCompleted {
+		adjustments, err := repo.Adjustments(stocktake.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range adjustments {
+			report.Adjustments = append(report.Adjustments, inventoryHistoryToResponse(h))
+		}
+	}
+	return report, nil
+}
+
+// HandleGetStocktakes lists the account's stocktakes, most recent first
+func HandleGetStocktakes(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		stocktakes, err := repository.NewStocktakeRepository(db).List(accountID, maxStocktakesListed)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve stocktakes", http.StatusInternalServerError)
+			return
+		}
+		resp := make([]StocktakeResponse, 0, len(stocktakes))
+		for _, s := range stocktakes {
+			resp = append(resp, toStocktakeResponse(s))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleStartStocktake opens a stocktake. An account has one open at a time.
+func HandleStartStocktake(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req StartStocktakeRequest
+		if r.ContentLength != 0 {
+			if !decodeRequest(w, r, &req) {
+				return
+			}
+		}
+		notes := optionalText(req.Notes)
+
+		stocktake := &models.Stocktake{
+			AccountID: accountID,
+			Notes:     notes,
+			StartedBy: sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if err := repository.NewStocktakeRepository(db).Start(stocktake); err != nil {
+			if errors.Is(err, repository.ErrStocktakeInProgress) {
+				respond.Error(w, "A stocktake is already in progress", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to start stocktake", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"start",
+			"stocktake",
+			sql.NullInt64{Int64: stocktake.ID, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusCreated, toStocktakeResponse(stocktake))
+	}
+}
+
+// HandleGetStocktake returns a stocktake with its counts and variance report
+func HandleGetStocktake(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		stocktake := stocktakeFromRequest(w, r, db, accountID)
+		if stocktake == nil {
+			return
+		}
+		report, err := buildStocktakeReport(db, accountID, stocktake)
+		if err != nil {
+			respond.Error(w, "Failed to build stocktake report", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, report)
+	}
+}
+
+// HandleExportStocktake downloads a stocktake's variance report as CSV, one
+// row per count
+func HandleExportStocktake(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		stocktake := stocktakeFromRequest(w, r, db, accountID)
+		if stocktake == nil {
+			return
+		}
+		report, err := buildStocktakeReport(db, accountID, stocktake)
+		if err != nil {
+			respond.Error(w, "Failed to build stocktake report", http.StatusInternalServerError)
+			return
+		}
+
+		filename := fmt.Sprintf("stocktake-%d-%s.csv", stocktake.ID, stocktake.StartedAt.Format("2006-01-02"))
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+		csvWriter := csv.NewWriter(w)
+		_ = csvWriter.Write([]string{"item_type", "name", "unit", "lot_number", "recorded_quantity", "counted_quantity", "variance", "notes"})
+		for _, item := range report.Items {
+			for _, c := range item.Counts {
+				lotNumber, notes := "", ""
+				if c.LotNumber != nil {
+					lotNumber = *c.LotNumber
+				}
+				if c.Notes != nil {
+					notes = *c.Notes
+				}
+				_ = csvWriter.Write([]string{
+					item.ItemType,
+					item.Name,
+					item.Unit,
+					lotNumber,
+					strconv.FormatFloat(c.RecordedQuantity, 'f', -1, 64),
+					strconv.FormatFloat(c.CountedQuantity, 'f', -1, 64),
+					strconv.FormatFloat(c.Variance, 'f', -1, 64),
+					notes,
+				})
+			}
+		}
+		csvWriter.Flush()
+	}
+}
+
+// HandleRecordStocktakeCount records what was found of an item, or of one
+// of its lots, in an open stocktake. Counting it again replaces the count.
+func HandleRecordStocktakeCount(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req StocktakeCountRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		stocktake := stocktakeFromRequest(w, r, db, accountID)
+		if stocktake == nil {
+			return
+		}
+
+		def := lookupInventoryItemType(db, accountID, req.ItemType)
+		if def == nil {
+			respond.Validation(w, "item_type must be one of the account's item types", respond.Field("item_type", "must be one of the account's item types"))
+			return
+		}
+		counted := *req.CountedQuantity
+		if req.Unit != nil {
+			var err error
+			counted, err = itemUnitConversion(def).ToUsage(counted, *req.Unit)
+			if err != nil {
+				msg := fmt.Sprintf("unit must be %s, or %s if the item type has a %s size", def.Unit,
+					services.PurchaseUnitFor(def.Unit), services.PurchaseUnitFor(def.Unit))
+				respond.Validation(w, msg, respond.Field("unit", msg))
+				return
+			}
+		}
+		notes := optionalText(req.Notes)
+
+		count := &models.StocktakeCount{
+			ItemType:        def.ItemType,
+			CountedQuantity: counted,
+			Notes:           notes,
+			CountedBy:       sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if req.LotID != nil {
+			lot, err := repository.NewInventoryLotRepository(db).GetByID(*req.LotID, accountID)
+			if err != nil || lot.ItemType != def.ItemType {
+				respond.Validation(w, "lot_id must be a lot of the item", respond.Field("lot_id", "must be a lot of the item"))
+				return
+			}
+			count.LotID = sql.NullInt64{Int64: lot.ID, Valid: true}
+		}
+
+		if err := repository.NewStocktakeRepository(db).RecordCount(stocktake, count); err != nil {
+			respondStocktakeError(w, err, "record count")
+			return
+		}
+		respondJSON(w, http.StatusOK, toStocktakeCountResponse(count))
+	}
+}
+
+// HandleDeleteStocktakeCount removes a count entered by mistake from an open
+// stocktake
+func HandleDeleteStocktakeCount(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		countID, err := strconv.ParseInt(chi.URLParam(r, "countId"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid count ID", http.StatusBadRequest)
+			return
+		}
+		stocktake := stocktakeFromRequest(w, r, db, accountID)
+		if stocktake == nil {
+			return
+		}
+
+		if err := repository.NewStocktakeRepository(db).DeleteCount(stocktake, countID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				respond.Error(w, "Count not found", http.StatusNotFound)
+				return
+			}
+			respondStocktakeError(w, err, "delete count")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleCompleteStocktake closes a stocktake and posts each counted item's
+// variance to its stock, returning the variance report with the adjustments
+// made
+func HandleCompleteStocktake(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		stocktake := stocktakeFromRequest(w, r, db, accountID)
+		if stocktake == nil {
+			return
+		}
+
+		posted, err := repository.NewStocktakeRepository(db).Complete(stocktake, userID)
+		if err != nil {
+			respondStocktakeError(w, err, "complete stocktake")
+			return
+		}
+		inventoryRepo := repository.NewInventoryRepository(db)
+		for _, h := range posted {
+			if item, err := inventoryRepo.GetByType(h.ItemType, accountID); err == nil {
+				emitLowStockIfCrossed(db, accountID, item, h.QuantityBefore)
+				publishInventoryAdjusted(accountID, item, h.QuantityBefore)
+			}
+		}
+
+		report, err := buildStocktakeReport(db, accountID, stocktake)
+		if err != nil {
+			respond.Error(w, "Stocktake completed but failed to build its report", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, report)
+	}
+}
+
+// HandleCancelStocktake closes a stocktake without changing any stock
+func HandleCancelStocktake(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		stocktake := stocktakeFromRequest(w, r, db, accountID)
+		if stocktake == nil {
+			return
+		}
+		if err := repository.NewStocktakeRepository(db).Cancel(stocktake, userID); err != nil {
+			respondStocktakeError(w, err, "cancel stocktake")
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"cancel",
+			"stocktake",
+			sql.NullInt64{Int64: stocktake.ID, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, toStocktakeResponse(stocktake))
+	}
+}
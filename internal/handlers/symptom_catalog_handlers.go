@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Symptom scale types
+const (
+	SymptomScalePresence = "presence" // Ticked or not
+	SymptomScaleSeverity = "severity" // Rated 1-10 when logged
+)
+
+// maxSymptomNameLength matches the symptom_catalog.name CHECK
+const maxSymptomNameLength = 100
+
+// SymptomCatalogRequest is the payload for creating or updating a catalog
+// symptom. Key can only be set on creation and defaults to a slug of the
+// name.
+type SymptomCatalogRequest struct {
+	Key        *string `json:"key,omitempty" validate:"max=50"`
+	Name       *string `json:"name,omitempty" validate:"max=100"`
+	Category   *string `json:"category,omitempty" validate:"max=50"`
+	ScaleType  *string `json:"scale_type,omitempty" validate:"oneof=presence severity"` // Defaults to presence
+	SortOrder  *int    `json:"sort_order,omitempty"`
+	IsArchived *bool   `json:"is_archived,omitempty"`
+}
+
+// SymptomCatalogResponse is a catalog symptom as returned by the API
+type SymptomCatalogResponse struct {
+	ID         int64     `json:"id"`
+	Key        string    `json:"key"`
+	Name       string    `json:"name"`
+	Category   string    `json:"category"`
+	ScaleType  string    `json:"scale_type"`
+	SortOrder  int       `json:"sort_order"`
+	IsArchived bool      `json:"is_archived"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SymptomItemRequest records a catalog symptom on a symptom log. Severity is
+// only accepted for 'severity' symptoms.
+type SymptomItemRequest struct {
+	CatalogID int64 `json:"catalog_id"`
+	Severity  *int  `json:"severity,omitempty" validate:"min=1,max=10"`
+}
+
+// SymptomItemResponse is a catalog symptom recorded on a symptom log
+type SymptomItemResponse struct {
+	CatalogID int64  `json:"catalog_id"`
+	Key       string `json:"key"`
+	Name      string `json:"name"`
+	Category  string `json:"category"`
+	Severity  *int64 `json:"severity,omitempty"`
+}
+
+func toSymptomCatalogResponse(item *models.SymptomCatalogItem) SymptomCatalogResponse {
+	return SymptomCatalogResponse{
+		ID:         item.ID,
+		Key:        item.Key,
+		Name:       item.Name,
+		Category:   item.Category,
+		ScaleType:  item.ScaleType,
+		SortOrder:  item.SortOrder,
+		IsArchived: item.IsArchived,
+		CreatedAt:  item.CreatedAt,
+		UpdatedAt:  item.UpdatedAt,
+	}
+}
+
+func validSymptomScaleType(scale string) bool {
+	return scale == SymptomScalePresence || scale == SymptomScaleSeverity
+}
+
+// applySymptomCatalogRequest validates and copies the mutable fields of req
+// onto item
+func applySymptomCatalogRequest(item *models.SymptomCatalogItem, req *SymptomCatalogRequest) *respond.FieldError {
+	if req.Name != nil {
+		if *req.Name == "" {
+			f := respond.Field("name", "is required")
+			return &f
+		}
+		item.Name = *req.Name
+	}
+	if req.Category != nil {
+		category := itemTypeSlug(*req.Category)
+		if category == "" {
+			f := respond.Field("category", "must contain letters or digits")
+			return &f
+		}
+		item.Category = category
+	}
+	if req.ScaleType != nil && *req.ScaleType != "" {
+		item.ScaleType = *req.ScaleType
+	}
+	if req.SortOrder != nil {
+		item.SortOrder = *req.SortOrder
+	}
+	if req.IsArchived != nil {
+		item.IsArchived = *req.IsArchived
+	}
+	return nil
+}
+
+// resolveSymptomItems turns the symptoms a log request names into catalog
+// entries. Free-text names from older clients are matched by key and added
+// to the catalog when the account doesn't have them yet. It returns the
+// items to record and their keys for the log's JSON array.
+func resolveSymptomItems(db *database.DB, accountID int64, names []string, reqItems []SymptomItemRequest) ([]models.SymptomLogItem, []string, *respond.FieldError) {
+	repo := repository.NewSymptomCatalogRepository(db)
+	var items []models.SymptomLogItem
+	var keys []string
+	seen := make(map[int64]bool)
+
+	for i, req := range reqItems {
+		field := fmt.Sprintf("symptom_items[%d]", i)
+		entry, err := repo.GetByID(req.CatalogID, accountID)
+		if err != nil {
+			f := respond.Field(field+".catalog_id", "not found")
+			return nil, nil, &f
+		}
+		if seen[entry.ID] {
+			continue
+		}
+		item := models.SymptomLogItem{CatalogID: entry.ID}
+		if req.Severity != nil {
+			if entry.ScaleType != SymptomScaleSeverity {
+				f := respond.Field(field+".severity", "is only accepted for severity symptoms")
+				return nil, nil, &f
+			}
+			item.Severity = sql.NullInt64{Int64: int64(*req.Severity), Valid: true}
+		}
+		seen[entry.ID] = true
+		items = append(items, item)
+		keys = append(keys, entry.Key)
+	}
+
+	for i, name := range names {
+		entry, err := repo.GetByKey(repository.SymptomKey(name), accountID)
+		if err == repository.ErrNotFound {
+			entry, err = repo.GetByKey(itemTypeSlug(name), accountID)
+		}
+		if err == repository.ErrNotFound {
+			entry = &models.SymptomCatalogItem{
+				AccountID: accountID,
+				Key:       itemTypeSlug(name),
+				Name:      strings.TrimSpace(name),
+				Category:  "other",
+				ScaleType: SymptomScalePresence,
+				SortOrder: 20,
+			}
+			if entry.Key == "" || len(entry.Name) > maxSymptomNameLength {
+				f := respond.Field(fmt.Sprintf("symptoms[%d]", i), "is not a valid symptom name")
+				return nil, nil, &f
+			}
+			err = repo.Create(entry)
+		}
+		if err != nil {
+			f := respond.Field(fmt.Sprintf("symptoms[%d]", i), "could not be added to the symptom catalog")
+			return nil, nil, &f
+		}
+		if seen[entry.ID] {
+			continue
+		}
+		seen[entry.ID] = true
+		items = append(items, models.SymptomLogItem{CatalogID: entry.ID})
+		keys = append(keys, entry.Key)
+	}
+
+	return items, keys, nil
+}
+
+// symptomItemResponses describes the catalog symptoms recorded on a log
+func symptomItemResponses(items []models.SymptomLogItem, catalog map[int64]*models.SymptomCatalogItem) []SymptomItemResponse {
+	resp := make([]SymptomItemResponse, 0, len(items))
+	for _, item := range items {
+		entry, ok := catalog[item.CatalogID]
+		if !ok {
+			continue
+		}
+		r := SymptomItemResponse{CatalogID: entry.ID, Key: entry.Key, Name: entry.Name, Category: entry.Category}
+		if item.Severity.Valid {
+			r.Severity = &item.Severity.Int64
+		}
+		resp = append(resp, r)
+	}
+	return resp
+}
+
+// symptomCatalogByID loads an account's whole catalog, archived symptoms
+// included, keyed by ID
+func symptomCatalogByID(db *database.DB, accountID int64) (map[int64]*models.SymptomCatalogItem, error) {
+	entries, err := repository.NewSymptomCatalogRepository(db).List(accountID, true)
+	if err != nil {
+		return nil, err
+	}
+	catalog := make(map[int64]*models.SymptomCatalogItem, len(entries))
+	for _, e := range entries {
+		catalog[e.ID] = e
+	}
+	return catalog, nil
+}
+
+// HandleGetSymptomCatalog lists the symptoms the account can log, with
+// archived ones included when ?include_archived=true
+func HandleGetSymptomCatalog(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		includeArchived := r.URL.Query().Get("include_archived") == "true"
+		entries, err := repository.NewSymptomCatalogRepository(db).List(accountID, includeArchived)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve symptom catalog", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]SymptomCatalogResponse, 0, len(entries))
+		for _, e := range entries {
+			resp = append(resp, toSymptomCatalogResponse(e))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleCreateSymptomCatalogItem adds a symptom to the account's catalog
+func HandleCreateSymptomCatalogItem(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req SymptomCatalogRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Name == nil {
+			respond.Validation(w, "name is required", respond.Field("name", "is required"))
+			return
+		}
+
+		item := &models.SymptomCatalogItem{
+			AccountID: accountID,
+			Category:  "general",
+			ScaleType: SymptomScalePresence,
+		}
+		if field := applySymptomCatalogRequest(item, &req); field != nil {
+			respond.Validation(w, "Invalid symptom: "+field.Message, *field)
+			return
+		}
+		item.Key = itemTypeSlug(item.Name)
+		if req.Key != nil && *req.Key != "" {
+			item.Key = itemTypeSlug(*req.Key)
+		}
+		if item.Key == "" {
+			respond.Validation(w, "key must contain letters or digits", respond.Field("key", "must contain letters or digits"))
+			return
+		}
+
+		if err := repository.NewSymptomCatalogRepository(db).Create(item); err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				respond.Error(w, "A symptom with this key already exists", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to create symptom", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"symptom_catalog",
+			sql.NullInt64{Int64: item.ID, Valid: true},
+			map[string]interface{}{"key": item.Key, "scale_type": item.ScaleType},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusCreated, toSymptomCatalogResponse(item))
+	}
+}
+
+// HandleUpdateSymptomCatalogItem renames, recategorises, reorders or
+// archives a catalog symptom
+func HandleUpdateSymptomCatalogItem(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid symptom ID", http.StatusBadRequest)
+			return
+		}
+
+		var req SymptomCatalogRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		repo := repository.NewSymptomCatalogRepository(db)
+		item, err := repo.GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Symptom not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve symptom", http.StatusInternalServerError)
+			return
+		}
+		if req.Key != nil && *req.Key != item.Key {
+			respond.Validation(w, "key cannot be changed", respond.Field("key", "cannot be changed"))
+			return
+		}
+		if field := applySymptomCatalogRequest(item, &req); field != nil {
+			respond.Validation(w, "Invalid symptom: "+field.Message, *field)
+			return
+		}
+
+		if err := repo.Update(item); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Symptom not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to update symptom", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"symptom_catalog",
+			sql.NullInt64{Int64: item.ID, Valid: true},
+			map[string]interface{}{"key": item.Key, "scale_type": item.ScaleType, "is_archived": item.IsArchived},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, toSymptomCatalogResponse(item))
+	}
+}
+
+// HandleDeleteSymptomCatalogItem removes a symptom from the catalog. One
+// that symptom logs have recorded is archived instead, so their history
+// keeps it.
+func HandleDeleteSymptomCatalogItem(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid symptom ID", http.StatusBadRequest)
+			return
+		}
+
+		repo := repository.NewSymptomCatalogRepository(db)
+		item, err := repo.GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Symptom not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve symptom", http.StatusInternalServerError)
+			return
+		}
+
+		uses, err := repo.CountUses(item.ID)
+		if err != nil {
+			respond.Error(w, "Failed to check symptom logs", http.StatusInternalServerError)
+			return
+		}
+
+		action := "delete"
+		if uses > 0 {
+			action = "archive"
+			item.IsArchived = true
+			err = repo.Update(item)
+		} else {
+			err = repo.Delete(item.ID, accountID)
+		}
+		if err != nil {
+			respond.Error(w, "Failed to delete symptom", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			action,
+			"symptom_catalog",
+			sql.NullInt64{Int64: item.ID, Valid: true},
+			map[string]interface{}{"key": item.Key, "uses": uses},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		if uses > 0 {
+			respondJSON(w, http.StatusOK, toSymptomCatalogResponse(item))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
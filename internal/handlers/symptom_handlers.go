@@ -4,16 +4,19 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
 	"injection-tracker/internal/middleware"
 	"injection-tracker/internal/models"
 	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/web"
 
 	"github.com/go-chi/chi/v5"
 	"golang.org/x/text/cases"
@@ -22,149 +25,145 @@ import (
 
 // CreateSymptomRequest represents the request body for creating a symptom log
 type CreateSymptomRequest struct {
-	CourseID     int64    `json:"course_id"`
-	Timestamp    *string  `json:"timestamp,omitempty"`
-	PainLevel    *int     `json:"pain_level,omitempty"`
-	PainLocation *string  `json:"pain_location,omitempty"`
-	PainType     *string  `json:"pain_type,omitempty"`
-	Symptoms     []string `json:"symptoms,omitempty"`
-	Notes        *string  `json:"notes,omitempty"`
+	CourseID     int64                `json:"course_id" validate:"required"`
+	Timestamp    *string              `json:"timestamp,omitempty"`
+	PainLevel    *int                 `json:"pain_level,omitempty" validate:"min=1,max=10"`
+	PainLocation *string              `json:"pain_location,omitempty" validate:"max=100"`
+	PainType     *string              `json:"pain_type,omitempty" validate:"max=100"`
+	Symptoms     []string             `json:"symptoms,omitempty" validate:"max=50,itemmax=100"` // Catalog keys; unknown names are added to the catalog
+	SymptomItems []SymptomItemRequest `json:"symptom_items,omitempty" validate:"max=50"`
+	Notes        *string              `json:"notes,omitempty" validate:"multiline,max=2000"`
+	AttachmentID *int64               `json:"attachment_id,omitempty"`
+	Visibility   string               `json:"visibility,omitempty" validate:"oneof=shared private"` // Defaults to shared
 }
 
 // UpdateSymptomRequest represents the request body for updating a symptom log
 type UpdateSymptomRequest struct {
-	CourseID     *int64   `json:"course_id,omitempty"`
-	Timestamp    *string  `json:"timestamp,omitempty"`
-	PainLevel    *int     `json:"pain_level,omitempty"`
-	PainLocation *string  `json:"pain_location,omitempty"`
-	PainType     *string  `json:"pain_type,omitempty"`
-	Symptoms     []string `json:"symptoms,omitempty"`
-	Notes        *string  `json:"notes,omitempty"`
+	CourseID     *int64               `json:"course_id,omitempty"`
+	Timestamp    *string              `json:"timestamp,omitempty"`
+	PainLevel    *int                 `json:"pain_level,omitempty" validate:"min=1,max=10"`
+	PainLocation *string              `json:"pain_location,omitempty" validate:"max=100"`
+	PainType     *string              `json:"pain_type,omitempty" validate:"max=100"`
+	Symptoms     []string             `json:"symptoms,omitempty" validate:"max=50,itemmax=100"` // With symptom_items, replaces the recorded symptoms
+	SymptomItems []SymptomItemRequest `json:"symptom_items,omitempty" validate:"max=50"`
+	Notes        *string              `json:"notes,omitempty" validate:"multiline,max=2000"`
+	AttachmentID *int64               `json:"attachment_id,omitempty"` // 0 clears the attachment
+	Visibility   *string              `json:"visibility,omitempty"`    // Only its author can change it
 }
 
-// HandleGetSymptoms returns a list of symptom logs with optional filtering
+// SymptomLogResponse is a symptom log as returned by the API
+type SymptomLogResponse struct {
+	ID           int64                 `json:"id"`
+	CourseID     int64                 `json:"course_id"`
+	LoggedBy     *int64                `json:"logged_by"`
+	Timestamp    string                `json:"timestamp"`
+	PainLevel    *int64                `json:"pain_level"`
+	PainLocation string                `json:"pain_location"`
+	PainType     string                `json:"pain_type"`
+	Symptoms     string                `json:"symptoms"`
+	Items        []SymptomItemResponse `json:"items"`
+	Notes        string                `json:"notes"`
+	AttachmentID *int64                `json:"attachment_id"`
+	Visibility   string                `json:"visibility"`
+	CreatedAt    string                `json:"created_at"`
+	UpdatedAt    string                `json:"updated_at"`
+}
+
+// HandleGetSymptoms returns a page of symptom logs with optional filtering
 func HandleGetSymptoms(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Parse query parameters
-		courseID := r.URL.Query().Get("course_id")
-		startDate := r.URL.Query().Get("start_date")
-		endDate := r.URL.Query().Get("end_date")
-		limitStr := r.URL.Query().Get("limit")
-		offsetStr := r.URL.Query().Get("offset")
-
-		// Set defaults
-		limit := 50
-		offset := 0
+		page, err := parsePageRequest(r)
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		if limitStr != "" {
-			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-				limit = l
+		var filter repository.SymptomFilter
+		if v := r.URL.Query().Get("course_id"); v != "" {
+			filter.CourseID, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				respond.Validation(w, "Invalid course_id", respond.Field("course_id", "is invalid"))
+				return
 			}
 		}
-		if offsetStr != "" {
-			if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-				offset = o
-			}
+		filter.Start, filter.End, err = parseListDateRange(r, userLocation(db, userID))
+		if err != nil {
+			respond.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		symptomRepo := repository.NewSymptomRepository(db)
-		var symptoms []*models.SymptomLog
-		var err error
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
+			return
+		}
 
-		// Filter by course or date range
-		if courseID != "" {
-			cid, err := strconv.ParseInt(courseID, 10, 64)
-			if err != nil {
-				http.Error(w, "Invalid course_id", http.StatusBadRequest)
-				return
-			}
-			symptoms, err = symptomRepo.ListByCourse(cid, accountID, limit, offset)
-			if err != nil {
-				http.Error(w, "Failed to retrieve symptom logs", http.StatusInternalServerError)
-				return
-			}
-		} else if startDate != "" && endDate != "" {
-			start, err1 := time.Parse("2006-01-02", startDate)
-			end, err2 := time.Parse("2006-01-02", endDate)
-			if err1 != nil || err2 != nil {
-				http.Error(w, "Invalid date format, use YYYY-MM-DD", http.StatusBadRequest)
-				return
-			}
-			symptoms, err = symptomRepo.ListByDateRange(accountID, start, end, limit, offset)
-		} else {
-			symptoms, err = symptomRepo.List(accountID, limit, offset)
+		result, err := repository.NewSymptomRepository(db).ListPage(accountID, viewer, filter, page)
+		if err != nil {
+			listPageError(w, err, "Failed to retrieve symptom logs")
+			return
 		}
 
+		logIDs := make([]int64, 0, len(result.Items))
+		for _, symptom := range result.Items {
+			logIDs = append(logIDs, symptom.ID)
+		}
+		items, err := repository.NewSymptomCatalogRepository(db).ItemsForLogs(logIDs)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve symptom logs", http.StatusInternalServerError)
+			return
+		}
+		catalog, err := symptomCatalogByID(db, accountID)
 		if err != nil {
-			http.Error(w, "Failed to retrieve symptom logs", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve symptom logs", http.StatusInternalServerError)
 			return
 		}
 
 		// Get user's timezone preference
 		userTimezone := GetUserTimezone(db, userID)
 
-		// Convert to JSON-serializable format
-		response := make([]map[string]interface{}, len(symptoms))
-		for i, symptom := range symptoms {
+		respondJSON(w, http.StatusOK, newListResponse(result, func(symptom *models.SymptomLog) SymptomLogResponse {
 			// Convert timestamps to user's timezone
-			timestamp := ConvertToUserTZ(symptom.Timestamp, userTimezone)
-			createdAt := ConvertToUserTZ(symptom.CreatedAt, userTimezone)
-			updatedAt := ConvertToUserTZ(symptom.UpdatedAt, userTimezone)
-
-			response[i] = map[string]interface{}{
-				"id":            symptom.ID,
-				"course_id":     symptom.CourseID,
-				"logged_by":     nullInt64ToInt(symptom.LoggedBy),
-				"timestamp":     timestamp.Format(time.RFC3339),
-				"pain_level":    nullInt64ToInt(symptom.PainLevel),
-				"pain_location": nullStringToString(symptom.PainLocation),
-				"pain_type":     nullStringToString(symptom.PainType),
-				"symptoms":      nullStringToString(symptom.Symptoms),
-				"notes":         nullStringToString(symptom.Notes),
-				"created_at":    createdAt.Format(time.RFC3339),
-				"updated_at":    updatedAt.Format(time.RFC3339),
+			return SymptomLogResponse{
+				ID:           symptom.ID,
+				CourseID:     symptom.CourseID,
+				LoggedBy:     nullInt64ToInt(symptom.LoggedBy),
+				Timestamp:    ConvertToUserTZ(symptom.Timestamp, userTimezone).Format(time.RFC3339),
+				PainLevel:    nullInt64ToInt(symptom.PainLevel),
+				PainLocation: nullStringToString(symptom.PainLocation),
+				PainType:     nullStringToString(symptom.PainType),
+				Symptoms:     nullStringToString(symptom.Symptoms),
+				Items:        symptomItemResponses(items[symptom.ID], catalog),
+				Notes:        nullStringToString(symptom.Notes),
+				AttachmentID: nullInt64ToInt(symptom.AttachmentID),
+				Visibility:   symptom.Visibility,
+				CreatedAt:    ConvertToUserTZ(symptom.CreatedAt, userTimezone).Format(time.RFC3339),
+				UpdatedAt:    ConvertToUserTZ(symptom.UpdatedAt, userTimezone).Format(time.RFC3339),
 			}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Printf("Failed to encode symptoms response: %v", err)
-		}
+		}))
 	}
 }
 
 // HandleCreateSymptom creates a new symptom log
 func HandleCreateSymptom(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		var req CreateSymptomRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
-		}
-
-		// Validate required fields
-		if req.CourseID == 0 {
-			http.Error(w, "course_id is required", http.StatusBadRequest)
-			return
-		}
-
-		// Validate pain level if provided
-		if req.PainLevel != nil && (*req.PainLevel < 1 || *req.PainLevel > 10) {
-			http.Error(w, "pain_level must be between 1 and 10", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
@@ -174,19 +173,30 @@ func HandleCreateSymptom(db *database.DB) http.HandlerFunc {
 			var err error
 			timestamp, err = time.Parse(time.RFC3339, *req.Timestamp)
 			if err != nil {
-				http.Error(w, "Invalid timestamp format, use RFC3339", http.StatusBadRequest)
+				respond.Validation(w, "Invalid timestamp format, use RFC3339", respond.Field("timestamp", "invalid format, use RFC3339"))
 				return
 			}
 		} else {
 			timestamp = time.Now()
 		}
 
-		// Convert symptoms array to JSON string
+		if req.AttachmentID != nil && !attachmentVisible(db, *req.AttachmentID, accountID, userID) {
+			respond.Validation(w, "attachment_id not found", respond.Field("attachment_id", "not found"))
+			return
+		}
+
+		// Record the symptoms against the catalog, keeping their keys as a
+		// JSON array for older clients
+		items, keys, field := resolveSymptomItems(db, accountID, req.Symptoms, req.SymptomItems)
+		if field != nil {
+			respond.Validation(w, "Invalid symptoms: "+field.Message, *field)
+			return
+		}
 		var symptomsJSON sql.NullString
-		if len(req.Symptoms) > 0 {
-			jsonBytes, err := json.Marshal(req.Symptoms)
+		if len(keys) > 0 {
+			jsonBytes, err := json.Marshal(keys)
 			if err != nil {
-				http.Error(w, "Failed to encode symptoms", http.StatusInternalServerError)
+				respond.Error(w, "Failed to encode symptoms", http.StatusInternalServerError)
 				return
 			}
 			symptomsJSON = sql.NullString{String: string(jsonBytes), Valid: true}
@@ -202,11 +212,17 @@ func HandleCreateSymptom(db *database.DB) http.HandlerFunc {
 			PainType:     nullString(req.PainType),
 			Symptoms:     symptomsJSON,
 			Notes:        nullString(req.Notes),
+			AttachmentID: nullInt64(req.AttachmentID),
+			Visibility:   req.Visibility,
 		}
 
 		symptomRepo := repository.NewSymptomRepository(db)
 		if err := symptomRepo.Create(symptom); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create symptom log: %v", err), http.StatusInternalServerError)
+			respond.Error(w, fmt.Sprintf("Failed to create symptom log: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err := repository.NewSymptomCatalogRepository(db).SetLogItems(symptom.ID, items); err != nil {
+			respond.Error(w, "Failed to record symptoms", http.StatusInternalServerError)
 			return
 		}
 
@@ -220,6 +236,7 @@ func HandleCreateSymptom(db *database.DB) http.HandlerFunc {
 			map[string]interface{}{
 				"course_id":  symptom.CourseID,
 				"pain_level": req.PainLevel,
+				"visibility": symptom.Visibility,
 			},
 			r.RemoteAddr,
 			r.UserAgent(),
@@ -228,7 +245,7 @@ func HandleCreateSymptom(db *database.DB) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(symptom); err != nil {
-			log.Printf("Failed to encode symptom response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode symptom response", "err", err)
 		}
 	}
 }
@@ -236,49 +253,53 @@ func HandleCreateSymptom(db *database.DB) http.HandlerFunc {
 // HandleGetSymptom returns a single symptom log by ID
 func HandleGetSymptom(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid symptom log ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid symptom log ID", http.StatusBadRequest)
 			return
 		}
 
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
+			return
+		}
 		symptomRepo := repository.NewSymptomRepository(db)
-		symptom, err := symptomRepo.GetByID(id, accountID)
+		symptom, err := symptomRepo.GetByID(id, accountID, viewer)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Symptom log not found", http.StatusNotFound)
+				respond.Error(w, "Symptom log not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve symptom log", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve symptom log", http.StatusInternalServerError)
 			return
 		}
 
-		// Convert to JSON-serializable format
-		response := map[string]interface{}{
-			"id":            symptom.ID,
-			"course_id":     symptom.CourseID,
-			"logged_by":     nullInt64ToInt(symptom.LoggedBy),
-			"timestamp":     symptom.Timestamp.Format(time.RFC3339),
-			"pain_level":    nullInt64ToInt(symptom.PainLevel),
-			"pain_location": nullStringToString(symptom.PainLocation),
-			"pain_type":     nullStringToString(symptom.PainType),
-			"symptoms":      nullStringToString(symptom.Symptoms),
-			"notes":         nullStringToString(symptom.Notes),
-			"created_at":    symptom.CreatedAt.Format(time.RFC3339),
-			"updated_at":    symptom.UpdatedAt.Format(time.RFC3339),
+		resp := symptomResponse(symptom)
+		items, err := repository.NewSymptomCatalogRepository(db).ItemsForLogs([]int64{symptom.ID})
+		if err != nil {
+			respond.Error(w, "Failed to retrieve symptom log", http.StatusInternalServerError)
+			return
+		}
+		catalog, err := symptomCatalogByID(db, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve symptom log", http.StatusInternalServerError)
+			return
 		}
+		resp["items"] = symptomItemResponses(items[symptom.ID], catalog)
 
+		setVersionHeaders(w, symptom.UpdatedAt)
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Printf("Failed to encode symptom response: %v", err)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			middleware.Log(r.Context()).Error("Failed to encode symptom response", "err", err)
 		}
 	}
 }
@@ -286,41 +307,43 @@ func HandleGetSymptom(db *database.DB) http.HandlerFunc {
 // HandleUpdateSymptom updates an existing symptom log
 func HandleUpdateSymptom(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid symptom log ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid symptom log ID", http.StatusBadRequest)
 			return
 		}
 
 		var req UpdateSymptomRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		// Validate pain level if provided
-		if req.PainLevel != nil && (*req.PainLevel < 1 || *req.PainLevel > 10) {
-			http.Error(w, "pain_level must be between 1 and 10", http.StatusBadRequest)
+		// Get existing symptom log
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
 			return
 		}
-
-		// Get existing symptom log
 		symptomRepo := repository.NewSymptomRepository(db)
-		symptom, err := symptomRepo.GetByID(id, accountID)
+		symptom, err := symptomRepo.GetByID(id, accountID, viewer)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Symptom log not found", http.StatusNotFound)
+				respond.Error(w, "Symptom log not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve symptom log", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve symptom log", http.StatusInternalServerError)
+			return
+		}
+		current := *symptom
+		if !checkPreconditions(w, r, symptom.UpdatedAt, true, func() interface{} { return symptomResponse(&current) }) {
 			return
 		}
 
@@ -331,7 +354,7 @@ func HandleUpdateSymptom(db *database.DB) http.HandlerFunc {
 		if req.Timestamp != nil {
 			timestamp, err := time.Parse(time.RFC3339, *req.Timestamp)
 			if err != nil {
-				http.Error(w, "Invalid timestamp format, use RFC3339", http.StatusBadRequest)
+				respond.Validation(w, "Invalid timestamp format, use RFC3339", respond.Field("timestamp", "invalid format, use RFC3339"))
 				return
 			}
 			symptom.Timestamp = timestamp
@@ -353,13 +376,22 @@ func HandleUpdateSymptom(db *database.DB) http.HandlerFunc {
 				symptom.PainType = sql.NullString{String: *req.PainType, Valid: true}
 			}
 		}
-		if req.Symptoms != nil {
-			if len(req.Symptoms) == 0 {
+		var items []models.SymptomLogItem
+		replaceItems := req.Symptoms != nil || req.SymptomItems != nil
+		if replaceItems {
+			var keys []string
+			var field *respond.FieldError
+			items, keys, field = resolveSymptomItems(db, accountID, req.Symptoms, req.SymptomItems)
+			if field != nil {
+				respond.Validation(w, "Invalid symptoms: "+field.Message, *field)
+				return
+			}
+			if len(keys) == 0 {
 				symptom.Symptoms = sql.NullString{Valid: false}
 			} else {
-				jsonBytes, err := json.Marshal(req.Symptoms)
+				jsonBytes, err := json.Marshal(keys)
 				if err != nil {
-					http.Error(w, "Failed to encode symptoms", http.StatusInternalServerError)
+					respond.Error(w, "Failed to encode symptoms", http.StatusInternalServerError)
 					return
 				}
 				symptom.Symptoms = sql.NullString{String: string(jsonBytes), Valid: true}
@@ -372,12 +404,46 @@ func HandleUpdateSymptom(db *database.DB) http.HandlerFunc {
 				symptom.Notes = sql.NullString{String: *req.Notes, Valid: true}
 			}
 		}
+		if req.Visibility != nil && *req.Visibility != symptom.Visibility {
+			if !validSymptomVisibility(*req.Visibility) {
+				respond.Validation(w, "visibility must be 'shared' or 'private'", respond.Field("visibility", "must be 'shared' or 'private'"))
+				return
+			}
+			if !symptom.LoggedBy.Valid || symptom.LoggedBy.Int64 != userID {
+				respond.Error(w, "Only the person who logged a symptom can change who sees it", http.StatusForbidden)
+				return
+			}
+			symptom.Visibility = *req.Visibility
+		}
+		if req.AttachmentID != nil {
+			if *req.AttachmentID == 0 {
+				symptom.AttachmentID = sql.NullInt64{Valid: false}
+			} else {
+				if !attachmentVisible(db, *req.AttachmentID, accountID, userID) {
+					respond.Validation(w, "attachment_id not found", respond.Field("attachment_id", "not found"))
+					return
+				}
+				symptom.AttachmentID = sql.NullInt64{Int64: *req.AttachmentID, Valid: true}
+			}
+		}
 
 		// Update symptom log
-		if err := symptomRepo.Update(symptom, accountID); err != nil {
-			http.Error(w, "Failed to update symptom log", http.StatusInternalServerError)
+		if err := symptomRepo.Update(symptom, accountID, current.UpdatedAt); err != nil {
+			if err == repository.ErrStale {
+				if latest, err := symptomRepo.GetByID(id, accountID, viewer); err == nil {
+					respondPreconditionFailed(w, latest.UpdatedAt, symptomResponse(latest))
+					return
+				}
+			}
+			respond.Error(w, "Failed to update symptom log", http.StatusInternalServerError)
 			return
 		}
+		if replaceItems {
+			if err := repository.NewSymptomCatalogRepository(db).SetLogItems(symptom.ID, items); err != nil {
+				respond.Error(w, "Failed to record symptoms", http.StatusInternalServerError)
+				return
+			}
+		}
 
 		// Create audit log
 		auditRepo := repository.NewAuditRepository(db)
@@ -387,51 +453,60 @@ func HandleUpdateSymptom(db *database.DB) http.HandlerFunc {
 			"symptom_log",
 			sql.NullInt64{Int64: id, Valid: true},
 			map[string]interface{}{
-				"course_id": symptom.CourseID,
+				"course_id":  symptom.CourseID,
+				"visibility": symptom.Visibility,
 			},
 			r.RemoteAddr,
 			r.UserAgent(),
 		)
 
+		setVersionHeaders(w, symptom.UpdatedAt)
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(symptom); err != nil {
-			log.Printf("Failed to encode symptom response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode symptom response", "err", err)
 		}
 	}
 }
 
-// HandleDeleteSymptom deletes a symptom log
+// HandleDeleteSymptom moves a symptom log to the trash
 func HandleDeleteSymptom(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid symptom log ID", http.StatusBadRequest)
+			respond.Error(w, "Invalid symptom log ID", http.StatusBadRequest)
 			return
 		}
 
 		// Verify symptom log exists
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
+			return
+		}
 		symptomRepo := repository.NewSymptomRepository(db)
-		symptom, err := symptomRepo.GetByID(id, accountID)
+		symptom, err := symptomRepo.GetByID(id, accountID, viewer)
 		if err != nil {
 			if err == repository.ErrNotFound {
-				http.Error(w, "Symptom log not found", http.StatusNotFound)
+				respond.Error(w, "Symptom log not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "Failed to retrieve symptom log", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve symptom log", http.StatusInternalServerError)
+			return
+		}
+		if !checkPreconditions(w, r, symptom.UpdatedAt, false, func() interface{} { return symptomResponse(symptom) }) {
 			return
 		}
 
-		// Delete symptom log
-		if err := symptomRepo.Delete(id, accountID); err != nil {
-			http.Error(w, "Failed to delete symptom log", http.StatusInternalServerError)
+		trashed := moveToTrash(w, db, symptomTrashItem(symptom, accountID, userID, userLocation(db, userID)), "Symptom log not found")
+		if trashed == nil {
 			return
 		}
 
@@ -444,6 +519,7 @@ func HandleDeleteSymptom(db *database.DB) http.HandlerFunc {
 			sql.NullInt64{Int64: id, Valid: true},
 			map[string]interface{}{
 				"course_id": symptom.CourseID,
+				"trash_id":  trashed.ID,
 			},
 			r.RemoteAddr,
 			r.UserAgent(),
@@ -453,120 +529,202 @@ func HandleDeleteSymptom(db *database.DB) http.HandlerFunc {
 	}
 }
 
+// symptomTrashItem is how a symptom log being deleted goes into the trash:
+// named by its time, and a private log stays private there
+func symptomTrashItem(symptom *models.SymptomLog, accountID, userID int64, loc *time.Location) *models.TrashItem {
+	return &models.TrashItem{
+		AccountID: accountID,
+		ItemType:  repository.TrashSymptomLog,
+		ItemID:    symptom.ID,
+		Label:     symptom.Timestamp.In(loc).Format("2006-01-02 15:04"),
+		IsPrivate: symptom.Visibility == repository.SymptomVisibilityPrivate,
+		PrivateTo: symptom.LoggedBy,
+		DeletedBy: sql.NullInt64{Int64: userID, Valid: true},
+	}
+}
+
+// HandleBulkDeleteSymptoms moves the symptom logs in ?ids= to the trash in
+// one transaction. If any can't be deleted, none are; the results say which.
+func HandleBulkDeleteSymptoms(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ids, ok := bulkIDs(w, r)
+		if !ok {
+			return
+		}
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
+			return
+		}
+
+		symptomRepo := repository.NewSymptomRepository(db)
+		loc := userLocation(db, userID)
+		results := make([]BulkItemResult, len(ids))
+		items := make([]*models.TrashItem, len(ids))
+		courseIDs := make([]int64, len(ids))
+		for i, id := range ids {
+			results[i] = BulkItemResult{ID: id}
+			symptom, err := symptomRepo.GetByID(id, accountID, viewer)
+			if err != nil {
+				if err == repository.ErrNotFound {
+					results[i] = bulkFailed(id, http.StatusNotFound, "Symptom log not found")
+				} else {
+					results[i] = bulkFailed(id, http.StatusInternalServerError, "Failed to retrieve symptom log")
+				}
+				continue
+			}
+			items[i] = symptomTrashItem(symptom, accountID, userID, loc)
+			courseIDs[i] = symptom.CourseID
+		}
+		if bulkHasFailures(results) {
+			respondBulkFailed(w, results)
+			return
+		}
+
+		if err := repository.NewTrashRepository(db).MoveAllToTrash(items); err != nil {
+			respondBulkError(w, err, results, "Symptom log not found", "Failed to move to the trash")
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		for i, item := range items {
+			_ = auditRepo.LogWithDetails(
+				sql.NullInt64{Int64: userID, Valid: true},
+				"delete",
+				"symptom_log",
+				sql.NullInt64{Int64: item.ItemID, Valid: true},
+				map[string]interface{}{
+					"course_id": courseIDs[i],
+					"trash_id":  item.ID,
+					"bulk":      true,
+				},
+				r.RemoteAddr,
+				r.UserAgent(),
+			)
+			results[i].Status = BulkApplied
+			results[i].StatusCode = http.StatusNoContent
+			results[i].TrashID = &items[i].ID
+		}
+
+		respondJSON(w, http.StatusOK, BulkResponse{Results: results})
+	}
+}
+
 // HandleGetRecentSymptoms returns recent symptom logs
 func HandleGetRecentSymptoms(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
 			return
 		}
 
 		symptomRepo := repository.NewSymptomRepository(db)
-		symptoms, err := symptomRepo.List(accountID, 10, 0)
+		symptoms, err := symptomRepo.List(accountID, viewer, 10, 0)
 		if err != nil {
-			http.Error(w, "Failed to retrieve symptoms", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve symptoms", http.StatusInternalServerError)
 			return
 		}
 
 		// Return empty state HTML if no symptoms
 		if len(symptoms) == 0 {
-			w.Header().Set("Content-Type", "text/html")
-			_, _ = w.Write([]byte(`
-				<div style="text-align: center; padding: 2rem; color: var(--pico-muted-color);">
-					<p>No symptoms logged yet.</p>
-					<small>Use the form above to log your first symptom.</small>
-				</div>
-			`))
+			renderFragment(w, r, http.StatusOK, "empty.html", web.EmptyState{
+				Message: "No symptoms logged yet.",
+				Hint:    "Use the form above to log your first symptom.",
+			})
 			return
 		}
 
-		// Build HTML for symptoms list
-		w.Header().Set("Content-Type", "text/html")
-		html := `<div style="display: flex; flex-direction: column; gap: 1rem;">`
-
+		p := i18n.FromContext(r.Context())
+		type symptomItem struct {
+			ID        int64
+			Date      string
+			When      string
+			Private   bool
+			PainLevel int64
+			Location  string
+			Type      string
+			Symptoms  []string
+			Notes     string
+		}
+		items := make([]symptomItem, 0, len(symptoms))
 		for _, symptom := range symptoms {
-			symptomsJSON := ""
-			if symptom.Symptoms.Valid {
-				symptomsJSON = symptom.Symptoms.String
-			}
-
-			// Format timestamp
-			formattedTime := symptom.Timestamp.Format("Jan 2, 2006 3:04 PM")
-			timeAgo := formatTimeAgo(symptom.Timestamp)
-
-			// Get pain level (handle null)
-			painLevel := int64(0)
-			if symptom.PainLevel.Valid {
-				painLevel = symptom.PainLevel.Int64
+			item := symptomItem{
+				ID:        symptom.ID,
+				Date:      p.DateTime(symptom.Timestamp),
+				When:      formatTimeAgo(p, symptom.Timestamp),
+				Private:   symptom.Visibility == repository.SymptomVisibilityPrivate,
+				PainLevel: symptom.PainLevel.Int64,
+				Location:  nullStringValue(symptom.PainLocation, p.T("N/A")),
+				Type:      nullStringValue(symptom.PainType, p.T("N/A")),
+				Notes:     symptom.Notes.String,
 			}
 
-			html += fmt.Sprintf(`
-				<article style="margin: 0;">
-					<header style="margin-bottom: 0.5rem;">
-						<div style="display: flex; justify-content: space-between; align-items: center;">
-							<strong>%s</strong>
-							<small>%s</small>
-						</div>
-					</header>
-					<div style="margin-bottom: 0.5rem;">
-						<strong>Pain Level:</strong> %d/10 &nbsp;
-						<strong>Location:</strong> %s &nbsp;
-						<strong>Type:</strong> %s
-					</div>`,
-				formattedTime,
-				timeAgo,
-				painLevel,
-				nullStringValue(symptom.PainLocation, "N/A"),
-				nullStringValue(symptom.PainType, "N/A"),
-			)
-
-			if symptomsJSON != "" && symptomsJSON != "[]" && symptomsJSON != "null" {
-				// Parse JSON symptoms array
-				var symptoms []string
-				if err := json.Unmarshal([]byte(symptomsJSON), &symptoms); err == nil && len(symptoms) > 0 {
-					html += `<div><strong>Symptoms:</strong> `
-					for i, symptom := range symptoms {
-						if i > 0 {
-							html += ", "
-						}
-						// Format symptom names nicely
-						formattedSymptom := strings.ReplaceAll(symptom, "_", " ")
-						formattedSymptom = cases.Title(language.English).String(formattedSymptom)
-						html += formattedSymptom
-					}
-					html += `</div>`
+			// Format symptom names nicely; the template translates them
+			var names []string
+			if symptom.Symptoms.Valid && json.Unmarshal([]byte(symptom.Symptoms.String), &names) == nil {
+				for _, name := range names {
+					item.Symptoms = append(item.Symptoms, cases.Title(language.English).String(strings.ReplaceAll(name, "_", " ")))
 				}
 			}
+			items = append(items, item)
+		}
+		renderFragment(w, r, http.StatusOK, "recent_symptoms.html", items)
+	}
+}
 
-			if symptom.Notes.Valid && symptom.Notes.String != "" {
-				html += fmt.Sprintf(`<div><strong>Notes:</strong> %s</div>`, symptom.Notes.String)
-			}
-
-			// Add action buttons
-			html += fmt.Sprintf(`
-				<footer style="margin-top: 1rem; padding-top: 1rem; border-top: 1px solid var(--pico-muted-border-color);">
-					<div class="grid" style="grid-template-columns: 1fr 1fr;">
-						<button data-action="delete-symptom" data-symptom-id="%d" class="outline secondary" style="font-size: 0.9rem;">
-							Delete
-						</button>
-						<button data-action="edit-symptom" data-symptom-id="%d" class="outline" style="font-size: 0.9rem;">
-							Edit
-						</button>
-					</div>
-				</footer>
-			`, symptom.ID, symptom.ID)
-
-			html += `</article>`
-		}
-
-		html += `</div>`
-		_, _ = w.Write([]byte(html))
+// symptomViewer loads which of the account's symptom logs the requesting
+// user may see, answering with an error itself if that fails
+func symptomViewer(db *database.DB, w http.ResponseWriter, r *http.Request) (repository.SymptomViewer, bool) {
+	viewer, err := repository.LoadSymptomViewer(db, middleware.GetAccountID(r.Context()), middleware.GetUserID(r.Context()))
+	if err != nil {
+		middleware.Log(r.Context()).Error("Failed to load symptom visibility", "err", err)
+		respond.Error(w, "Failed to retrieve symptom logs", http.StatusInternalServerError)
+		return viewer, false
 	}
+	return viewer, true
+}
+
+// validSymptomVisibility reports whether v is a symptom log visibility
+func validSymptomVisibility(v string) bool {
+	return v == repository.SymptomVisibilityShared || v == repository.SymptomVisibilityPrivate
 }
 
 // nullStringValue returns the string value or a default if null
+// symptomResponse converts a symptom log to the JSON shape served by
+// GET /api/symptoms/{id}
+func symptomResponse(symptom *models.SymptomLog) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            symptom.ID,
+		"course_id":     symptom.CourseID,
+		"logged_by":     nullInt64ToInt(symptom.LoggedBy),
+		"timestamp":     symptom.Timestamp.Format(time.RFC3339),
+		"pain_level":    nullInt64ToInt(symptom.PainLevel),
+		"pain_location": nullStringToString(symptom.PainLocation),
+		"pain_type":     nullStringToString(symptom.PainType),
+		"symptoms":      nullStringToString(symptom.Symptoms),
+		"notes":         nullStringToString(symptom.Notes),
+		"attachment_id": nullInt64ToInt(symptom.AttachmentID),
+		"visibility":    symptom.Visibility,
+		"created_at":    symptom.CreatedAt.Format(time.RFC3339),
+		"updated_at":    symptom.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
 func nullStringValue(ns sql.NullString, defaultVal string) string {
 	if ns.Valid {
 		return ns.String
@@ -582,6 +740,14 @@ func nullStringToString(ns sql.NullString) string {
 	return ""
 }
 
+// nullStringToPtr returns a pointer to the string value or nil if null
+func nullStringToPtr(ns sql.NullString) *string {
+	if ns.Valid {
+		return &ns.String
+	}
+	return nil
+}
+
 // nullInt64ToInt returns the int64 value or 0 if null
 func nullInt64ToInt(ni sql.NullInt64) *int64 {
 	if ni.Valid {
@@ -590,80 +756,284 @@ func nullInt64ToInt(ni sql.NullInt64) *int64 {
 	return nil
 }
 
-// formatTimeAgo returns a human-readable time ago string
-func formatTimeAgo(t time.Time) string {
+// formatTimeAgo returns a human-readable time ago string, in p's language
+func formatTimeAgo(p *i18n.Printer, t time.Time) string {
 	duration := time.Since(t)
 	if duration.Hours() < 1 {
 		minutes := int(duration.Minutes())
 		if minutes == 1 {
-			return "1 minute ago"
+			return p.T("1 minute ago")
 		}
-		return fmt.Sprintf("%d minutes ago", minutes)
+		return p.T("%d minutes ago", minutes)
 	} else if duration.Hours() < 24 {
 		hours := int(duration.Hours())
 		if hours == 1 {
-			return "1 hour ago"
+			return p.T("1 hour ago")
 		}
-		return fmt.Sprintf("%d hours ago", hours)
+		return p.T("%d hours ago", hours)
 	} else {
 		days := int(duration.Hours() / 24)
 		if days == 1 {
-			return "1 day ago"
+			return p.T("1 day ago")
 		}
-		return fmt.Sprintf("%d days ago", days)
+		return p.T("%d days ago", days)
 	}
 }
 
-// HandleGetSymptomTrends returns symptom trend data for charts
+// Symptom trend limits. A year of daily buckets stays well under a thousand
+// points, and raw entries are only ever returned for the most recent logs.
+const (
+	maxSymptomTrendDays    = 366
+	maxSymptomTrendLogs    = 5000
+	maxSymptomTrendEntries = 500
+)
+
+// SymptomTrendsResponse is pain and symptom history over a window, grouped
+// into day or week buckets in the user's timezone
+type SymptomTrendsResponse struct {
+	Granularity string         `json:"granularity"` // day, week or entry
+	StartDate   string         `json:"start_date"`
+	EndDate     string         `json:"end_date"`
+	Buckets     []PainBucket   `json:"buckets,omitempty"`
+	Symptoms    []SymptomTrend `json:"symptoms"`
+	Truncated   bool           `json:"truncated,omitempty"`  // Window held more logs than were read
+	Dates       []string       `json:"dates,omitempty"`      // Per entry, for granularity=entry
+	PainLevels  []int          `json:"painLevels,omitempty"` // Per entry, for granularity=entry
+	Labs        []LabSeries    `json:"labs"`                 // Lab results drawn in the window, for drawing over pain
+}
+
+// PainBucket sums up the logs in one day or week. Every bucket in the window
+// is present, with nil pain figures where no pain was recorded.
+type PainBucket struct {
+	Start   string   `json:"start"` // First day of the bucket
+	Count   int      `json:"count"` // Symptom logs
+	AvgPain *float64 `json:"avg_pain"`
+	MinPain *int     `json:"min_pain"`
+	MaxPain *int     `json:"max_pain"`
+}
+
+// HandleGetSymptomTrends returns symptom trends over the last days (default
+// 30). granularity is day (the default), week, or entry for the raw pain
+// level of each of the most recent logs.
 func HandleGetSymptomTrends(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		accountID := middleware.GetAccountID(r.Context())
 		if userID == 0 || accountID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		days := 30
 		if daysParam := r.URL.Query().Get("days"); daysParam != "" {
 			if d, err := strconv.Atoi(daysParam); err == nil && d > 0 {
-				days = d
+				days = min(d, maxSymptomTrendDays)
 			}
 		}
+		granularity := r.URL.Query().Get("granularity")
+		switch granularity {
+		case "":
+			granularity = "day"
+		case "day", "week", "entry":
+		default:
+			respond.Validation(w, "Invalid granularity", respond.Field("granularity", "must be day, week or entry"))
+			return
+		}
 
-		symptomRepo := repository.NewSymptomRepository(db)
-		endDate := time.Now()
-		startDate := endDate.AddDate(0, 0, -days)
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
+			return
+		}
 
-		symptoms, err := symptomRepo.ListByDateRange(accountID, startDate, endDate, 1000, 0)
+		loc := userLocation(db, userID)
+		now := time.Now().In(loc)
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		startDate := today.AddDate(0, 0, -(days - 1))
+		if granularity == "week" {
+			startDate = startOfWeek(startDate)
+		}
+
+		symptomRepo := repository.NewSymptomRepository(db)
+		symptoms, err := symptomRepo.ListByDateRange(accountID, viewer, startDate.UTC(), now.UTC(), maxSymptomTrendLogs, 0)
 		if err != nil {
-			http.Error(w, "Failed to retrieve symptom trends", http.StatusInternalServerError)
+			respond.Error(w, "Failed to retrieve symptom trends", http.StatusInternalServerError)
 			return
 		}
 
-		// Build trend data
-		dates := []string{}
-		painLevels := []int{}
-
+		logIDs := make([]int64, 0, len(symptoms))
 		for _, symptom := range symptoms {
-			dates = append(dates, symptom.Timestamp.Format("2006-01-02"))
-			if symptom.PainLevel.Valid {
-				painLevels = append(painLevels, int(symptom.PainLevel.Int64))
-			} else {
-				painLevels = append(painLevels, 0)
+			logIDs = append(logIDs, symptom.ID)
+		}
+		items, err := repository.NewSymptomCatalogRepository(db).ItemsForLogs(logIDs)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve symptom trends", http.StatusInternalServerError)
+			return
+		}
+		catalog, err := symptomCatalogByID(db, accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve symptom trends", http.StatusInternalServerError)
+			return
+		}
+
+		bucketOf := func(t time.Time) string {
+			t = t.In(loc)
+			if granularity == "week" {
+				return startOfWeek(t).Format("2006-01-02")
 			}
+			return t.Format("2006-01-02")
 		}
 
-		response := map[string]interface{}{
-			"dates":      dates,
-			"painLevels": painLevels,
+		response := SymptomTrendsResponse{
+			Granularity: granularity,
+			StartDate:   startDate.Format("2006-01-02"),
+			EndDate:     today.Format("2006-01-02"),
+			Symptoms:    symptomTrends(symptoms, items, catalog, bucketOf),
+			Truncated:   len(symptoms) == maxSymptomTrendLogs,
+		}
+		labs, err := repository.NewLabResultRepository(db).List(accountID, repository.LabResultFilter{Start: startDate}, maxLabTrendResults)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve symptom trends", http.StatusInternalServerError)
+			return
+		}
+		if granularity == "entry" {
+			response.Labs = labSeries(labs, loc, nil)
+			// Logs come newest first
+			response.Dates = []string{}
+			response.PainLevels = []int{}
+			for _, symptom := range symptoms[:min(len(symptoms), maxSymptomTrendEntries)] {
+				response.Dates = append(response.Dates, symptom.Timestamp.In(loc).Format("2006-01-02"))
+				response.PainLevels = append(response.PainLevels, int(symptom.PainLevel.Int64))
+			}
+		} else {
+			response.Buckets = painBuckets(symptoms, startDate, today, granularity == "week", bucketOf)
+			response.Labs = labSeries(labs, loc, bucketOf)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Printf("Failed to encode symptom trends response: %v", err)
+			middleware.Log(r.Context()).Error("Failed to encode symptom trends response", "err", err)
+		}
+	}
+}
+
+// startOfWeek returns midnight on the Monday of t's week, in t's location
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+// painBuckets groups logs' pain levels into one bucket per day, or per week,
+// from start to end
+func painBuckets(logs []*models.SymptomLog, start, end time.Time, weekly bool, bucketOf func(time.Time) string) []PainBucket {
+	buckets := []PainBucket{}
+	index := make(map[string]int)
+	step := 1
+	if weekly {
+		step = 7
+	}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, step) {
+		key := day.Format("2006-01-02")
+		index[key] = len(buckets)
+		buckets = append(buckets, PainBucket{Start: key})
+	}
+
+	totals := make([]int64, len(buckets))
+	counts := make([]int64, len(buckets))
+	for _, log := range logs {
+		i, ok := index[bucketOf(log.Timestamp)]
+		if !ok {
+			continue
+		}
+		b := &buckets[i]
+		b.Count++
+		if !log.PainLevel.Valid {
+			continue
+		}
+		pain := int(log.PainLevel.Int64)
+		totals[i] += log.PainLevel.Int64
+		counts[i]++
+		if b.MinPain == nil || pain < *b.MinPain {
+			low := pain
+			b.MinPain = &low
+		}
+		if b.MaxPain == nil || pain > *b.MaxPain {
+			high := pain
+			b.MaxPain = &high
+		}
+	}
+	for i := range buckets {
+		if counts[i] > 0 {
+			avg := float64(totals[i]) / float64(counts[i])
+			buckets[i].AvgPain = &avg
 		}
 	}
+	return buckets
+}
+
+// SymptomTrend sums up how often one catalog symptom was logged over the
+// trend window
+type SymptomTrend struct {
+	CatalogID   int64          `json:"catalog_id"`
+	Key         string         `json:"key"`
+	Name        string         `json:"name"`
+	Category    string         `json:"category"`
+	ScaleType   string         `json:"scale_type"`
+	Count       int            `json:"count"`
+	AvgSeverity *float64       `json:"avg_severity,omitempty"`
+	Buckets     map[string]int `json:"buckets"` // Logs per bucket, keyed by the bucket's first day
+}
+
+// symptomTrends groups the catalog symptoms recorded on logs by catalog
+// entry, in catalog order
+func symptomTrends(logs []*models.SymptomLog, items map[int64][]models.SymptomLogItem, catalog map[int64]*models.SymptomCatalogItem, bucketOf func(time.Time) string) []SymptomTrend {
+	byID := make(map[int64]*SymptomTrend)
+	severityTotals := make(map[int64]int64)
+	severityCounts := make(map[int64]int64)
+	for _, log := range logs {
+		bucket := bucketOf(log.Timestamp)
+		for _, item := range items[log.ID] {
+			entry, ok := catalog[item.CatalogID]
+			if !ok {
+				continue
+			}
+			trend := byID[item.CatalogID]
+			if trend == nil {
+				trend = &SymptomTrend{
+					CatalogID: entry.ID,
+					Key:       entry.Key,
+					Name:      entry.Name,
+					Category:  entry.Category,
+					ScaleType: entry.ScaleType,
+					Buckets:   map[string]int{},
+				}
+				byID[item.CatalogID] = trend
+			}
+			trend.Count++
+			trend.Buckets[bucket]++
+			if item.Severity.Valid {
+				severityTotals[item.CatalogID] += item.Severity.Int64
+				severityCounts[item.CatalogID]++
+			}
+		}
+	}
+
+	trends := make([]SymptomTrend, 0, len(byID))
+	for id, trend := range byID {
+		if severityCounts[id] > 0 {
+			avg := float64(severityTotals[id]) / float64(severityCounts[id])
+			trend.AvgSeverity = &avg
+		}
+		trends = append(trends, *trend)
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		a, b := catalog[trends[i].CatalogID], catalog[trends[j].CatalogID]
+		if a.SortOrder != b.SortOrder {
+			return a.SortOrder < b.SortOrder
+		}
+		return a.Name < b.Name
+	})
+	return trends
 }
 
 // Helper function to convert *int to sql.NullInt64
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestPainBuckets(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	at := func(day, hour int, pain int64) *models.SymptomLog {
+		return &models.SymptomLog{
+			Timestamp: time.Date(2026, 3, day, hour, 0, 0, 0, loc).UTC(),
+			PainLevel: sql.NullInt64{Int64: pain, Valid: pain > 0},
+		}
+	}
+	logs := []*models.SymptomLog{at(4, 23, 6), at(4, 8, 2), at(3, 12, 0), at(9, 9, 5)}
+	bucketOf := func(t time.Time) string { return t.In(loc).Format("2006-01-02") }
+
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+	end := time.Date(2026, 3, 8, 0, 0, 0, 0, loc)
+	daily := painBuckets(logs, start, end, false, bucketOf)
+	if len(daily) != 7 {
+		t.Fatalf("Expected a bucket per day, got %d", len(daily))
+	}
+	// 23:00 in New York is the next day in UTC but stays on the 4th
+	if b := daily[2]; b.Start != "2026-03-04" || b.Count != 2 || *b.AvgPain != 4 || *b.MinPain != 2 || *b.MaxPain != 6 {
+		t.Errorf("Unexpected bucket for the 4th: %+v", b)
+	}
+	if b := daily[1]; b.Count != 1 || b.AvgPain != nil || b.MinPain != nil {
+		t.Errorf("Expected a log without pain to count without pain figures, got %+v", b)
+	}
+
+	weekOf := func(t time.Time) string { return startOfWeek(t.In(loc)).Format("2006-01-02") }
+	weekly := painBuckets(logs, start, time.Date(2026, 3, 10, 0, 0, 0, 0, loc), true, weekOf)
+	if len(weekly) != 2 || weekly[0].Count != 3 || weekly[1].Start != "2026-03-09" || weekly[1].Count != 1 {
+		t.Errorf("Unexpected weekly buckets: %+v", weekly)
+	}
+}
+
+func TestStartOfWeek(t *testing.T) {
+	sunday := time.Date(2026, 3, 8, 15, 0, 0, 0, time.UTC)
+	if got := startOfWeek(sunday); !got.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected Sunday to belong to the week starting Monday the 2nd, got %s", got)
+	}
+}
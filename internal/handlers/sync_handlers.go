@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/validate"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// maxSyncBytes caps the size of a sync request
+	maxSyncBytes = 1 << 20
+	// syncRetention is how long applied operations are remembered
+	syncRetention = 30 * 24 * time.Hour
+)
+
+// Sync operation result statuses
+const (
+	SyncApplied    = "applied"     // Applied now
+	SyncDuplicate  = "duplicate"   // Applied by an earlier request; the original result is returned
+	SyncConflict   = "conflict"    // The record changed since the client last saw it; body is the current record
+	SyncFailed     = "failed"      // Rejected; body is the error
+	SyncInProgress = "in_progress" // Being applied by another request; send it again later
+)
+
+var (
+	syncOperationIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	// syncReferencePattern matches a path segment naming an earlier
+	// operation, e.g. /injections/{6f1c...}, replaced with the ID it created
+	syncReferencePattern = regexp.MustCompile(`\{([0-9a-fA-F-]{36})\}`)
+)
+
+// SyncOperationRequest is one change queued while offline. Path and Body are
+// what the client would have sent to the API directly.
+type SyncOperationRequest struct {
+	ID     string          `json:"id"` // Client-generated UUID
+	Method string          `json:"method" validate:"required,oneof=POST PUT DELETE"`
+	Path   string          `json:"path" validate:"required,max=500"` // e.g. /api/v1/injections; {uuid} stands for the ID created by that operation
+	Body   json.RawMessage `json:"body,omitempty"`
+	// BaseUpdatedAt is the updated_at the client last saw for the record a
+	// PUT or DELETE changes. The operation is a conflict if it has changed
+	// since.
+	BaseUpdatedAt *time.Time `json:"base_updated_at,omitempty"`
+}
+
+// SyncRequest is a batch of offline changes, applied in order
+type SyncRequest struct {
+	Operations []SyncOperationRequest `json:"operations" validate:"required,max=100"`
+}
+
+// SyncOperationResult reports what happened to one operation
+type SyncOperationResult struct {
+	ID         string          `json:"id"`
+	Status     string          `json:"status"`
+	StatusCode int             `json:"status_code"`
+	EntityID   *int64          `json:"entity_id,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+}
+
+// SyncResponse lists a result for every operation, in request order
+type SyncResponse struct {
+	Results []SyncOperationResult `json:"results"`
+}
+
+// syncRouter serves the API routes that can be queued offline. Operations
+// go through the same handlers as direct requests, so they get the same
+// validation, inventory changes, audit logs, webhooks and live events.
+func syncRouter(db *database.DB) http.Handler {
+	r := chi.NewRouter()
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		respond.Error(w, "This operation can't be synced", http.StatusNotFound)
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		respond.Error(w, "This operation can't be synced", http.StatusMethodNotAllowed)
+	})
+
+	r.Post("/injections", HandleCreateInjection(db))
+	r.Get("/injections/{id}", HandleGetInjection(db))
+	r.Put("/injections/{id}", HandleUpdateInjection(db))
+	r.Delete("/injections/{id}", HandleDeleteInjection(db))
+	r.Post("/injections/{id}/restore", HandleRestoreInjection(db))
+	r.Post("/symptoms", HandleCreateSymptom(db))
+	r.Get("/symptoms/{id}", HandleGetSymptom(db))
+	r.Put("/symptoms/{id}", HandleUpdateSymptom(db))
+	r.Delete("/symptoms/{id}", HandleDeleteSymptom(db))
+	r.Post("/medications/{id}/log", HandleLogMedication(db))
+	r.Post("/inventory/{itemType}/adjust", HandleAdjustInventory(db))
+	return r
+}
+
+// HandleSync applies changes the PWA queued while offline. Operations are
+// applied one at a time in order, each in the transaction of the handler it
+// is routed to, and a failed operation doesn't stop the rest. Applied
+// operations are remembered by ID, so sending a batch again is safe. An
+// operation is only reported applied once that has been recorded; if it
+// can't be, it is reported failed and left claimed, so resending it within
+// repository.SyncClaimTimeout reports it in progress rather than applying it
+// a second time.
+func HandleSync(db *database.DB) http.HandlerFunc {
+	router := syncRouter(db)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSyncBytes)
+		var req SyncRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if fields := validateSyncRequest(req); len(fields) > 0 {
+			respond.Validation(w, "", fields...)
+			return
+		}
+
+		repo := repository.NewSyncRepository(db)
+		if _, err := repo.Prune(time.Now().Add(-syncRetention)); err != nil {
+			middleware.Log(r.Context()).Warn("Failed to prune sync operations", "err", err)
+		}
+
+		s := &syncRun{
+			repo:      repo,
+			record:    repository.NewSyncRepository(db.Detach()),
+			router:    router,
+			parent:    r,
+			accountID: accountID,
+			userID:    userID,
+			created:   map[string]*int64{},
+		}
+		resp := SyncResponse{Results: make([]SyncOperationResult, 0, len(req.Operations))}
+		for _, op := range req.Operations {
+			resp.Results = append(resp.Results, s.apply(op))
+		}
+
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// validateSyncRequest checks what a batch's validate tags can't before
+// anything is applied: that every operation has its own UUID
+func validateSyncRequest(req SyncRequest) []respond.FieldError {
+	var v validate.Validator
+	seen := map[string]bool{}
+	for i, op := range req.Operations {
+		field := fmt.Sprintf("operations[%d].id", i)
+		if !syncOperationIDPattern.MatchString(op.ID) {
+			v.Add(field, "must be a UUID")
+		} else if seen[strings.ToLower(op.ID)] {
+			v.Add(field, "is repeated")
+		}
+		seen[strings.ToLower(op.ID)] = true
+	}
+	return v.Errors()
+}
+
+// syncRun applies the operations of one sync request
+type syncRun struct {
+	repo *repository.SyncRepository
+	// record stores what happened to an operation once its handler has run.
+	// It isn't bound to the request, so a client that hangs up mid-batch
+	// doesn't leave a change made but not recorded.
+	record    *repository.SyncRepository
+	router    http.Handler
+	parent    *http.Request
+	accountID int64
+	userID    int64
+	// created holds the entity IDs of operations seen in this request, nil
+	// for those that didn't apply
+	created map[string]*int64
+}
+
+func (s *syncRun) apply(op SyncOperationRequest) SyncOperationResult {
+	op.ID = strings.ToLower(op.ID)
+	result := SyncOperationResult{ID: op.ID}
+
+	path, err := s.resolvePath(op.Path)
+	if err != nil {
+		s.created[op.ID] = nil
+		return s.failed(result, http.StatusFailedDependency, err.Error())
+	}
+
+	claimed, existing, err := s.repo.Claim(s.accountID, op.ID, s.userID, op.Method, path)
+	if err != nil {
+		middleware.Log(s.parent.Context()).Error("Failed to claim sync operation", "operation_id", op.ID, "err", err)
+		s.created[op.ID] = nil
+		return s.failed(result, http.StatusInternalServerError, "Failed to record operation")
+	}
+	if !claimed {
+		if existing.Status != "applied" {
+			s.created[op.ID] = nil
+			result.Status = SyncInProgress
+			result.StatusCode = http.StatusConflict
+			return result
+		}
+		result.Status = SyncDuplicate
+		result.StatusCode = int(existing.StatusCode.Int64)
+		if existing.Response.Valid && existing.Response.String != "" {
+			result.Body = json.RawMessage(existing.Response.String)
+		}
+		if existing.EntityID.Valid {
+			result.EntityID = &existing.EntityID.Int64
+		}
+		s.created[op.ID] = result.EntityID
+		return result
+	}
+
+	release := func() {
+		s.created[op.ID] = nil
+		if err := s.record.Release(s.accountID, op.ID); err != nil {
+			middleware.Log(s.parent.Context()).Error("Failed to release sync operation", "operation_id", op.ID, "err", err)
+		}
+	}
+
+	status, body := s.serve(op.Method, path, op.Body, syncPreconditions(op))
+	result.StatusCode = status
+	result.Body = body
+	if status == http.StatusPreconditionFailed {
+		release()
+		result.Status = SyncConflict
+		var failed struct {
+			Current json.RawMessage `json:"current"`
+		}
+		if json.Unmarshal(body, &failed) == nil {
+			result.Body = failed.Current
+		}
+		return result
+	}
+	if status < 200 || status >= 300 {
+		release()
+		result.Status = SyncFailed
+		return result
+	}
+
+	var entityID sql.NullInt64
+	if id := entityIDFromBody(body); id != nil {
+		entityID = sql.NullInt64{Int64: *id, Valid: true}
+	}
+	if err := s.record.Complete(s.accountID, op.ID, status, string(body), entityID); err != nil {
+		// The change was made but the claim is still pending. Keep it, so
+		// the operation isn't applied again when the client resends it.
+		middleware.Log(s.parent.Context()).Error("Failed to record sync operation", "operation_id", op.ID, "err", err)
+		s.created[op.ID] = nil
+		return s.failed(result, http.StatusInternalServerError, "Failed to record operation")
+	}
+
+	result.Status = SyncApplied
+	if entityID.Valid {
+		result.EntityID = &entityID.Int64
+	}
+	s.created[op.ID] = result.EntityID
+	return result
+}
+
+// resolvePath strips the API prefix from path and fills in references to
+// earlier operations
+func (s *syncRun) resolvePath(path string) (string, error) {
+	path, _, _ = strings.Cut(path, "?")
+	for _, prefix := range []string{fmt.Sprintf("/api/v%d/", middleware.LatestAPIVersion), "/api/"} {
+		if strings.HasPrefix(path, prefix) {
+			path = "/" + strings.TrimPrefix(path, prefix)
+			break
+		}
+	}
+
+	var missing string
+	path = syncReferencePattern.ReplaceAllStringFunc(path, func(ref string) string {
+		id := strings.ToLower(ref[1 : len(ref)-1])
+		if entityID, ok := s.created[id]; ok {
+			if entityID != nil {
+				return strconv.FormatInt(*entityID, 10)
+			}
+		} else if op, err := s.repo.Get(s.accountID, id); err == nil && op.Status == "applied" && op.EntityID.Valid {
+			return strconv.FormatInt(op.EntityID.Int64, 10)
+		}
+		missing = id
+		return ref
+	})
+	if missing != "" {
+		return "", fmt.Errorf("Operation %s has not been applied", missing)
+	}
+	return path, nil
+}
+
+// syncPreconditions are the conditional headers for an operation. Changes
+// based on a known version are made only if the record hasn't changed since;
+// PUTs without one overwrite whatever is there, as they would have online
+// before the edit endpoints required a precondition.
+func syncPreconditions(op SyncOperationRequest) http.Header {
+	header := http.Header{}
+	switch {
+	case op.Method == http.MethodPost:
+	case op.BaseUpdatedAt != nil:
+		header.Set("If-Unmodified-Since", op.BaseUpdatedAt.UTC().Format(http.TimeFormat))
+	case op.Method == http.MethodPut:
+		header.Set("If-Match", "*")
+	}
+	return header
+}
+
+// serve runs one request through the sync router as the current user
+func (s *syncRun) serve(method, path string, body []byte, header http.Header) (int, json.RawMessage) {
+	// Start without the outer request's chi routing state
+	ctx := context.WithValue(s.parent.Context(), chi.RouteCtxKey, (*chi.Context)(nil))
+	req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(body))
+	if err != nil {
+		return http.StatusBadRequest, syncErrorBody(http.StatusBadRequest, "Invalid path")
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.parent.UserAgent())
+	req.RemoteAddr = s.parent.RemoteAddr
+
+	rec := &syncRecorder{header: http.Header{}}
+	s.router.ServeHTTP(rec, req)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	out := bytes.TrimSpace(rec.body.Bytes())
+	if len(out) == 0 {
+		return rec.status, nil
+	}
+	if !json.Valid(out) {
+		out, _ = json.Marshal(string(out))
+	}
+	return rec.status, out
+}
+
+func (s *syncRun) failed(result SyncOperationResult, status int, message string) SyncOperationResult {
+	result.Status = SyncFailed
+	result.StatusCode = status
+	result.Body = syncErrorBody(status, message)
+	return result
+}
+
+// syncErrorBody is the error envelope for a failed operation
+func syncErrorBody(status int, message string) json.RawMessage {
+	body, _ := json.Marshal(respond.ErrorResponse{Error: respond.ErrorBody{Code: respond.CodeFor(status), Message: message}})
+	return body
+}
+
+// entityIDFromBody finds the ID of the record an operation created or changed
+func entityIDFromBody(body json.RawMessage) *int64 {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return nil
+	}
+	for _, key := range []string{"id", "ID"} {
+		var id int64
+		if raw, ok := fields[key]; ok && json.Unmarshal(raw, &id) == nil && id > 0 {
+			return &id
+		}
+	}
+	return nil
+}
+
+// syncRecorder captures a handler's response to a synced operation, or
+// what a shared check wrote about one record of a bulk request
+type syncRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *syncRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *syncRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *syncRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
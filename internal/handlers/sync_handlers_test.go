@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+)
+
+func TestHandleSyncUnrecordedOperation(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	// Recording results fails as if the disk filled up after the injection
+	// was saved
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'one', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner');
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Progesterone', '2026-03-01 00:00:00', 1);
+		CREATE TRIGGER fail_sync_complete BEFORE UPDATE OF status ON sync_operations
+		WHEN NEW.status = 'applied' BEGIN SELECT RAISE(ABORT, 'database or disk is full'); END;
+	`); err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+
+	sync := func() SyncOperationResult {
+		body := `{"operations": [{"id": "6f1c0c1e-3d4c-4a6b-9a51-2f0c1d2e3f40", "method": "POST", "path": "/api/v1/injections", "body": {"course_id": 1, "side": "left"}}]}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/sync", strings.NewReader(body))
+		userCtx := &middleware.UserContext{UserID: 1, AccountID: 1, Role: "owner"}
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, userCtx))
+		rec := httptest.NewRecorder()
+		HandleSync(db)(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp SyncResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Results) != 1 {
+			t.Fatalf("Unexpected response %s (%v)", rec.Body.String(), err)
+		}
+		return resp.Results[0]
+	}
+	injections := func() int {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM injections`).Scan(&n); err != nil {
+			t.Fatalf("Failed to count injections: %v", err)
+		}
+		return n
+	}
+
+	if result := sync(); result.Status != SyncFailed || result.StatusCode != http.StatusInternalServerError || result.EntityID != nil {
+		t.Errorf("Expected an unrecorded operation to fail, got %+v", result)
+	}
+	var status string
+	if err := db.QueryRow(`SELECT status FROM sync_operations`).Scan(&status); err != nil || status != "pending" {
+		t.Errorf("Expected the claim kept, got %q (%v)", status, err)
+	}
+
+	if result := sync(); result.Status != SyncInProgress {
+		t.Errorf("Expected the resent operation in progress, got %+v", result)
+	}
+	if n := injections(); n != 1 {
+		t.Errorf("Expected the injection logged once, got %d", n)
+	}
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+)
+
+// trashLabelLength caps the part of a journal entry's body used to name it
+// in the trash when it has no title
+const trashLabelLength = 60
+
+// TrashItemResponse is a trash item as listed by the API
+type TrashItemResponse struct {
+	ID                int64     `json:"id"`
+	ItemType          string    `json:"item_type"` // 'course', 'symptom_log', 'medication' or 'journal_entry'
+	ItemID            int64     `json:"item_id"`   // Its ID, which it keeps when restored
+	Label             string    `json:"label"`
+	DeletedBy         *int64    `json:"deleted_by,omitempty"`
+	DeletedByUsername string    `json:"deleted_by_username,omitempty"`
+	DeletedAt         time.Time `json:"deleted_at"`
+	ExpiresAt         time.Time `json:"expires_at"` // When it is purged for good
+}
+
+// EmptyTrashResponse says how many items emptying the trash removed
+type EmptyTrashResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+func toTrashItemResponse(item *models.TrashItem) TrashItemResponse {
+	resp := TrashItemResponse{
+		ID:                item.ID,
+		ItemType:          item.ItemType,
+		ItemID:            item.ItemID,
+		Label:             item.Label,
+		DeletedByUsername: item.DeletedByUsername,
+		DeletedAt:         item.DeletedAt,
+		ExpiresAt:         item.DeletedAt.Add(repository.TrashRetention),
+	}
+	if item.DeletedBy.Valid {
+		resp.DeletedBy = &item.DeletedBy.Int64
+	}
+	return resp
+}
+
+// moveToTrash deletes an item the caller has already loaded, keeping it in
+// the trash. It writes the error response and returns nil if it can't.
+func moveToTrash(w http.ResponseWriter, db *database.DB, item *models.TrashItem, notFound string) *models.TrashItem {
+	if err := repository.NewTrashRepository(db).MoveToTrash(item); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respond.Error(w, notFound, http.StatusNotFound)
+			return nil
+		}
+		respond.Error(w, "Failed to move to the trash", http.StatusInternalServerError)
+		return nil
+	}
+	return item
+}
+
+// journalTrashLabel names a journal entry in the trash: its title, or the
+// start of its body
+func journalTrashLabel(entry *models.JournalEntry) string {
+	if entry.Title.Valid && strings.TrimSpace(entry.Title.String) != "" {
+		return entry.Title.String
+	}
+	body := []rune(strings.Join(strings.Fields(entry.Body), " "))
+	if len(body) > trashLabelLength {
+		return string(body[:trashLabelLength-3]) + "..."
+	}
+	return string(body)
+}
+
+// HandleGetTrash lists what the user can see in the account's trash, most
+// recently deleted first. ?type= limits it to one type of item.
+func HandleGetTrash(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		itemType := r.URL.Query().Get("type")
+		if itemType != "" && !repository.IsTrashType(itemType) {
+			respond.Error(w, "type must be course, symptom_log, medication or journal_entry", http.StatusBadRequest)
+			return
+		}
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
+			return
+		}
+
+		items, err := repository.NewTrashRepository(db).List(accountID, viewer, itemType)
+		if err != nil {
+			respond.Error(w, "Failed to get trash", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]TrashItemResponse, 0, len(items))
+		for _, item := range items {
+			resp = append(resp, toTrashItemResponse(item))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// trashItemFromURL loads the trash item named by the {id} URL parameter,
+// if the user can see it, writing the error response if it can't
+func trashItemFromURL(w http.ResponseWriter, r *http.Request, db *database.DB, accountID int64) (*models.TrashItem, repository.SymptomViewer, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respond.Error(w, "Invalid trash item ID", http.StatusBadRequest)
+		return nil, repository.SymptomViewer{}, false
+	}
+	viewer, ok := symptomViewer(db, w, r)
+	if !ok {
+		return nil, viewer, false
+	}
+	item, err := repository.NewTrashRepository(db).GetByID(id, accountID, viewer)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			respond.Error(w, "Trash item not found", http.StatusNotFound)
+		} else {
+			respond.Error(w, "Failed to get trash item", http.StatusInternalServerError)
+		}
+		return nil, viewer, false
+	}
+	return item, viewer, true
+}
+
+// HandleRestoreTrashItem puts a trash item back where it was, with its
+// original ID and everything deleted with it
+func HandleRestoreTrashItem(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		item, _, ok := trashItemFromURL(w, r, db, accountID)
+		if !ok {
+			return
+		}
+
+		if err := repository.NewTrashRepository(db).Restore(item); err != nil {
+			if errors.Is(err, repository.ErrTrashConflict) {
+				middleware.Log(r.Context()).Info("Trash item can't be restored", "trash_id", item.ID, "err", err)
+				respond.Error(w, "This can't be restored because something it belongs to has been deleted since, such as its course; restore that first", http.StatusConflict)
+				return
+			}
+			respond.Error(w, "Failed to restore trash item", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"restore",
+			item.ItemType,
+			sql.NullInt64{Int64: item.ItemID, Valid: true},
+			map[string]interface{}{"label": item.Label, "trash_id": item.ID},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, toTrashItemResponse(item))
+	}
+}
+
+// HandlePurgeTrashItem deletes a trash item for good. Owners can purge any
+// item; members only what they deleted.
+func HandlePurgeTrashItem(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		item, viewer, ok := trashItemFromURL(w, r, db, accountID)
+		if !ok {
+			return
+		}
+		if item.DeletedBy.Int64 != userID && middleware.GetRole(r.Context()) != "owner" {
+			respond.Error(w, "Forbidden: only the account owner or whoever deleted it can purge it", http.StatusForbidden)
+			return
+		}
+
+		if err := repository.NewTrashRepository(db).Purge(item.ID, accountID, viewer); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				respond.Error(w, "Trash item not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to purge trash item", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"purge",
+			item.ItemType,
+			sql.NullInt64{Int64: item.ItemID, Valid: true},
+			map[string]interface{}{"label": item.Label, "trash_id": item.ID},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleEmptyTrash deletes everything in the trash the owner can see for
+// good
+func HandleEmptyTrash(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if middleware.GetRole(r.Context()) != "owner" {
+			respond.Error(w, "Forbidden: only the account owner can empty the trash", http.StatusForbidden)
+			return
+		}
+
+		viewer, ok := symptomViewer(db, w, r)
+		if !ok {
+			return
+		}
+		purged, err := repository.NewTrashRepository(db).PurgeAll(accountID, viewer)
+		if err != nil {
+			respond.Error(w, "Failed to empty trash", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"purge",
+			"trash",
+			sql.NullInt64{},
+			map[string]interface{}{"count": purged},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, EmptyTrashResponse{Purged: purged})
+	}
+}
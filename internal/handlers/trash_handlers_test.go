@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"injection-tracker/internal/models"
+)
+
+func TestJournalTrashLabel(t *testing.T) {
+	entry := &models.JournalEntry{Title: sql.NullString{String: "Clinic visit", Valid: true}, Body: "Notes"}
+	if got := journalTrashLabel(entry); got != "Clinic visit" {
+		t.Errorf("Expected the title, got %q", got)
+	}
+
+	entry.Title = sql.NullString{String: "  ", Valid: true}
+	entry.Body = "Felt   fine\nafter the\tdose"
+	if got := journalTrashLabel(entry); got != "Felt fine after the dose" {
+		t.Errorf("Expected the body on one line, got %q", got)
+	}
+
+	// Long bodies are cut on a character, not a byte
+	entry.Body = strings.Repeat("ü", 100)
+	got := journalTrashLabel(entry)
+	if got != strings.Repeat("ü", trashLabelLength-3)+"..." {
+		t.Errorf("Expected the body cut to %d characters, got %q", trashLabelLength, got)
+	}
+}
@@ -0,0 +1,469 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+)
+
+// Trip limits
+const (
+	maxTripDays = 365 // Longest a trip can last
+	maxTrips    = 200 // Most trips listed at once
+)
+
+// TripRequest is the payload for recording or updating a trip. On update,
+// omitted fields are left alone. home_timezone defaults to the user's
+// timezone and shift to instant.
+type TripRequest struct {
+	Destination  *string    `json:"destination,omitempty" validate:"max=100"`
+	Timezone     *string    `json:"timezone,omitempty" validate:"max=64"`
+	HomeTimezone *string    `json:"home_timezone,omitempty" validate:"max=64"`
+	StartsAt     *time.Time `json:"starts_at,omitempty"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	Shift        *string    `json:"shift,omitempty" validate:"oneof=instant gradual"`
+	Notes        *string    `json:"notes,omitempty" validate:"multiline,max=2000"`
+}
+
+// TripResponse is a trip as returned by the API
+type TripResponse struct {
+	ID           int64     `json:"id"`
+	Destination  string    `json:"destination"`
+	Timezone     string    `json:"timezone"`
+	HomeTimezone string    `json:"home_timezone"`
+	StartsAt     time.Time `json:"starts_at"`
+	EndsAt       time.Time `json:"ends_at"`
+	Shift        string    `json:"shift"`  // instant or gradual
+	Status       string    `json:"status"` // upcoming, active, returning (shifting back after a gradual trip) or past
+	Notes        string    `json:"notes,omitempty"`
+	CreatedBy    *int64    `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TravelDueResponse is when an injection falls due on the clocks at home
+// and at the destination, while a trip moves the schedule
+type TravelDueResponse struct {
+	TripID      int64     `json:"trip_id"`
+	Destination string    `json:"destination"`
+	Home        time.Time `json:"home"`  // In the trip's home timezone
+	Local       time.Time `json:"local"` // In the destination's timezone
+}
+
+func toTripResponse(t *models.Trip, now time.Time) TripResponse {
+	return TripResponse{
+		ID:           t.ID,
+		Destination:  t.Destination,
+		Timezone:     t.Timezone,
+		HomeTimezone: t.HomeTimezone,
+		StartsAt:     t.StartsAt,
+		EndsAt:       t.EndsAt,
+		Shift:        t.Shift,
+		Status:       services.TripStatus(t, now),
+		Notes:        t.Notes.String,
+		CreatedBy:    nullInt64ToInt(t.CreatedBy),
+		CreatedAt:    t.CreatedAt,
+		UpdatedAt:    t.UpdatedAt,
+	}
+}
+
+// travelDue shows due on the trip's home and destination clocks; it is nil
+// without a trip
+func travelDue(trip *models.Trip, due time.Time) *TravelDueResponse {
+	if trip == nil {
+		return nil
+	}
+	resp := &TravelDueResponse{TripID: trip.ID, Destination: trip.Destination, Home: due, Local: due}
+	if loc, err := time.LoadLocation(trip.HomeTimezone); err == nil {
+		resp.Home = due.In(loc)
+	}
+	if loc, err := time.LoadLocation(trip.Timezone); err == nil {
+		resp.Local = due.In(loc)
+	}
+	return resp
+}
+
+// travelDueFormData is a due time at home and away as the pages show it
+func travelDueFormData(travel *TravelDueResponse) map[string]interface{} {
+	return map[string]interface{}{
+		"Destination": travel.Destination,
+		"Home":        travel.Home.Format("Jan 2, 15:04 MST"),
+		"Local":       travel.Local.Format("Jan 2, 15:04 MST"),
+	}
+}
+
+// travelTimezones are suggested when recording a trip; any IANA timezone
+// can be entered
+var travelTimezones = []string{
+	"America/New_York", "America/Chicago", "America/Denver", "America/Phoenix",
+	"America/Los_Angeles", "America/Anchorage", "Pacific/Honolulu", "America/Mexico_City",
+	"America/Sao_Paulo", "UTC", "Europe/London", "Europe/Paris", "Europe/Athens",
+	"Africa/Johannesburg", "Asia/Dubai", "Asia/Kolkata", "Asia/Bangkok", "Asia/Shanghai",
+	"Asia/Tokyo", "Australia/Sydney", "Pacific/Auckland",
+}
+
+// tripPageData is the account's trip under way, or else its next one, as the
+// injections page shows it, with when the next injection is due at home and
+// away while the trip moves the schedule. It is nil when there's neither.
+func tripPageData(db *database.DB, accountID, userID int64, now time.Time) map[string]interface{} {
+	trips, err := repository.NewTripRepository(db).List(accountID, 20)
+	if err != nil {
+		return nil
+	}
+	// Latest start first: stop at the first under way, else keep the soonest
+	// upcoming
+	var trip *models.Trip
+	status := ""
+	for _, t := range trips {
+		s := services.TripStatus(t, now)
+		if s == services.TripPast {
+			break
+		}
+		trip, status = t, s
+		if s != services.TripUpcoming {
+			break
+		}
+	}
+	if trip == nil {
+		return nil
+	}
+
+	data := map[string]interface{}{
+		"ID":           trip.ID,
+		"Destination":  trip.Destination,
+		"Timezone":     trip.Timezone,
+		"HomeTimezone": trip.HomeTimezone,
+		"Starts":       FormatDateTimeForUser(db, userID, trip.StartsAt),
+		"Ends":         FormatDateTimeForUser(db, userID, trip.EndsAt),
+		"Gradual":      trip.Shift == models.TripShiftGradual,
+		"Status":       status,
+		"Active":       status == services.TripActive,
+	}
+	loc := userLocation(db, userID)
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	if plan, err := planInjections(db, accountID, loc, today, today.AddDate(0, 0, courseScheduleHorizonDays), 1); err == nil && plan != nil && len(plan.Doses) > 0 {
+		if travel := travelDue(plan.Trip, plan.Doses[0].Due); travel != nil {
+			data["NextDue"] = travelDueFormData(travel)
+		}
+	}
+	return data
+}
+
+// applyTripRequest validates req and copies the provided fields onto t,
+// returning the first invalid field
+func applyTripRequest(t *models.Trip, req *TripRequest) *respond.FieldError {
+	if req.Destination != nil {
+		destination := strings.Join(strings.Fields(*req.Destination), " ")
+		if destination == "" {
+			fe := respond.Field("destination", "is required")
+			return &fe
+		}
+		t.Destination = destination
+	}
+	for _, tz := range []struct {
+		field string
+		value *string
+		dest  *string
+	}{
+		{"timezone", req.Timezone, &t.Timezone},
+		{"home_timezone", req.HomeTimezone, &t.HomeTimezone},
+	} {
+		if tz.value == nil {
+			continue
+		}
+		if _, err := time.LoadLocation(*tz.value); err != nil || *tz.value == "" {
+			fe := respond.Field(tz.field, "must be a timezone such as Asia/Tokyo")
+			return &fe
+		}
+		*tz.dest = *tz.value
+	}
+	if req.StartsAt != nil {
+		t.StartsAt = req.StartsAt.UTC()
+	}
+	if req.EndsAt != nil {
+		t.EndsAt = req.EndsAt.UTC()
+	}
+	if !t.EndsAt.After(t.StartsAt) {
+		fe := respond.Field("ends_at", "must be after starts_at")
+		return &fe
+	}
+	if t.EndsAt.Sub(t.StartsAt) > maxTripDays*24*time.Hour {
+		fe := respond.Field("ends_at", fmt.Sprintf("must be within %d days of starts_at", maxTripDays))
+		return &fe
+	}
+	if req.Shift != nil {
+		t.Shift = *req.Shift
+	}
+	if req.Notes != nil {
+		t.Notes = optionalText(req.Notes)
+	}
+	return nil
+}
+
+// tripOverlaps writes a conflict response, returning true, when t overlaps
+// another of the account's trips
+func tripOverlaps(w http.ResponseWriter, db *database.DB, t *models.Trip) bool {
+	overlaps, err := repository.NewTripRepository(db).Overlaps(t.AccountID, t.StartsAt, t.EndsAt, t.ID)
+	if err != nil {
+		respond.Error(w, "Failed to check trips", http.StatusInternalServerError)
+		return true
+	}
+	if overlaps {
+		respond.Error(w, "Trip overlaps another trip", http.StatusConflict)
+		return true
+	}
+	return false
+}
+
+// HandleGetTrips lists the account's trips, latest start first
+func HandleGetTrips(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		trips, err := repository.NewTripRepository(db).List(accountID, maxTrips)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve trips", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		resp := make([]TripResponse, 0, len(trips))
+		for _, t := range trips {
+			resp = append(resp, toTripResponse(t, now))
+		}
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleGetTrip returns a single trip
+func HandleGetTrip(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid trip ID", http.StatusBadRequest)
+			return
+		}
+
+		trip, err := repository.NewTripRepository(db).GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Trip not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve trip", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, toTripResponse(trip, time.Now()))
+	}
+}
+
+// HandleCreateTrip records a trip for the account, turning on travel mode
+// while it lasts
+func HandleCreateTrip(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req TripRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		var missing []respond.FieldError
+		if req.Destination == nil {
+			missing = append(missing, respond.Field("destination", "is required"))
+		}
+		if req.Timezone == nil {
+			missing = append(missing, respond.Field("timezone", "is required"))
+		}
+		if req.StartsAt == nil {
+			missing = append(missing, respond.Field("starts_at", "is required"))
+		}
+		if req.EndsAt == nil {
+			missing = append(missing, respond.Field("ends_at", "is required"))
+		}
+		if len(missing) > 0 {
+			respond.Validation(w, "destination, timezone, starts_at and ends_at are required", missing...)
+			return
+		}
+
+		trip := &models.Trip{
+			AccountID:    accountID,
+			HomeTimezone: GetUserTimezone(db, userID),
+			Shift:        models.TripShiftInstant,
+			CreatedBy:    sql.NullInt64{Int64: userID, Valid: true},
+		}
+		if fe := applyTripRequest(trip, &req); fe != nil {
+			respond.Validation(w, "Invalid trip: "+fe.Field+" "+fe.Message, *fe)
+			return
+		}
+		if tripOverlaps(w, db, trip) {
+			return
+		}
+
+		if err := repository.NewTripRepository(db).Create(trip); err != nil {
+			respond.Error(w, "Failed to record trip", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"trip",
+			sql.NullInt64{Int64: trip.ID, Valid: true},
+			map[string]interface{}{
+				"timezone":  trip.Timezone,
+				"starts_at": trip.StartsAt,
+				"ends_at":   trip.EndsAt,
+				"shift":     trip.Shift,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusCreated, toTripResponse(trip, time.Now()))
+	}
+}
+
+// HandleUpdateTrip updates a trip, e.g. to end it early
+func HandleUpdateTrip(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid trip ID", http.StatusBadRequest)
+			return
+		}
+
+		var req TripRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		repo := repository.NewTripRepository(db)
+		trip, err := repo.GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Trip not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve trip", http.StatusInternalServerError)
+			return
+		}
+		if fe := applyTripRequest(trip, &req); fe != nil {
+			respond.Validation(w, "Invalid trip: "+fe.Field+" "+fe.Message, *fe)
+			return
+		}
+		if tripOverlaps(w, db, trip) {
+			return
+		}
+
+		if err := repo.Update(trip); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Trip not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to update trip", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"trip",
+			sql.NullInt64{Int64: trip.ID, Valid: true},
+			map[string]interface{}{
+				"timezone":  trip.Timezone,
+				"starts_at": trip.StartsAt,
+				"ends_at":   trip.EndsAt,
+				"shift":     trip.Shift,
+			},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		respondJSON(w, http.StatusOK, toTripResponse(trip, time.Now()))
+	}
+}
+
+// HandleDeleteTrip deletes a trip; injections fall due on home time again
+// straight away
+func HandleDeleteTrip(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid trip ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := repository.NewTripRepository(db).Delete(id, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Trip not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete trip", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"trip",
+			sql.NullInt64{Int64: id, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -1,17 +1,19 @@
 package handlers
 
 import (
+	"bytes"
 	"database/sql"
-	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
+	"injection-tracker/internal/auth"
 	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
 	"injection-tracker/internal/middleware"
 	"injection-tracker/internal/models"
 	"injection-tracker/internal/repository"
+	"injection-tracker/internal/services"
 	"injection-tracker/internal/web"
 
 	"github.com/go-chi/chi/v5"
@@ -28,11 +30,14 @@ func getBasePageData(db *database.DB, r *http.Request, csrf *middleware.CSRFProt
 		"IsAuthenticated": true,
 		"AccountID":       accountID,
 		"UserID":          userID,
+		"Role":            middleware.GetRole(r.Context()),
+		// Edits made from the page send this as If-Unmodified-Since
+		"RenderedAt": time.Now().UTC().Format(http.TimeFormat),
 	}
 
 	// Generate CSRF token if CSRF protection is available
 	if csrf != nil {
-		data["CSRFToken"] = csrf.GenerateToken()
+		data["CSRFToken"] = csrf.GenerateToken(r)
 	}
 
 	// Inject site settings
@@ -41,9 +46,37 @@ func getBasePageData(db *database.DB, r *http.Request, csrf *middleware.CSRFProt
 	data["SiteURL"] = site.SiteURL
 	data["SiteDescription"] = site.SiteDescription
 
+	data["Announcements"] = activeAnnouncements(db)
+	// Only the admin gets this far while it's on; remind them
+	data["MaintenanceMode"], _ = maintenance.Status()
+	data["Features"] = enabledFeatures(db, accountID)
+	if userCtx := middleware.GetUserContext(r); userCtx != nil {
+		// Only read-only accounts get a page; locked ones are signed out
+		data["ReadOnlyAccount"] = userCtx.Suspension == models.AccountReadOnly
+	}
+
+	if impersonatorID := middleware.GetImpersonatorID(r.Context()); impersonatorID != 0 {
+		impersonationBanner(db, r, data, userID, impersonatorID)
+	}
+
 	return data
 }
 
+// impersonationBanner adds who is impersonating the user, and until when,
+// for the banner shown on every page while they do
+func impersonationBanner(db *database.DB, r *http.Request, data map[string]interface{}, userID, impersonatorID int64) {
+	data["Impersonator"] = "admin"
+	if admin, err := repository.NewUserRepository(db).GetByID(impersonatorID); err == nil {
+		data["Impersonator"] = admin.Username
+	}
+	if userCtx := middleware.GetUserContext(r); userCtx != nil {
+		data["ImpersonatedUser"] = userCtx.Username
+	}
+	if session, err := repository.NewSessionRepository(db).GetActive(middleware.GetSessionID(r.Context())); err == nil {
+		data["ImpersonationEndsAt"] = session.ExpiresAt.In(userLocation(db, userID))
+	}
+}
+
 // HandleHome redirects to login if not authenticated, otherwise to dashboard
 func HandleHome(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -61,64 +94,77 @@ func HandleHome(db *database.DB) http.HandlerFunc {
 }
 
 // HandleLoginPage renders the login page
-func HandleLoginPage(w http.ResponseWriter, r *http.Request) {
-	// Redirect to dashboard if already logged in
-	if userCtx := middleware.GetUserContext(r); userCtx != nil {
-		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
-		return
-	}
-
-	data := map[string]interface{}{
-		"Title":           "Login",
-		"IsAuthenticated": false,
-		"CSRFToken":       "", // Will be generated by HTMX
-	}
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	// Render login.html - the Render function will execute base.html with the login content block
-	if err := web.Render(w, "login.html", data); err != nil {
-		http.Error(w, "Failed to render template: "+err.Error(), http.StatusInternalServerError)
+func HandleLoginPage(db *database.DB, jwtManager *auth.JWTManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		// Redirect to dashboard if already logged in
 		if userCtx := middleware.GetUserContext(r); userCtx != nil {
 			http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
 			return
 		}
 
-		return
+		data := map[string]interface{}{
+			"Title":              "Login",
+			"IsAuthenticated":    false,
+			"CSRFToken":          "", // Will be generated by HTMX
+			"RegistrationClosed": registrationMode(db.WithContext(r.Context())) != RegistrationOpen,
+			// Offered unless the site turned trusting devices off; part
+			// of a day counts as one
+			"TrustDeviceDays": int((jwtManager.DeviceTrustDuration() + 24*time.Hour - 1) / (24 * time.Hour)),
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// Render login.html - the Render function will execute base.html with the login content block
+		if err := web.Render(w, r, "login.html", data); err != nil {
+			http.Error(w, "Failed to render template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 }
 
-// HandleRegisterPage renders the registration page
-func HandleRegisterPage(w http.ResponseWriter, r *http.Request) {
-	data := map[string]interface{}{
-		"Title":           "Register",
-		"IsAuthenticated": false,
-	}
+// HandleRegisterPage renders the registration page, or says why registering
+// isn't possible when the site is invite-only and there's no invitation or
+// registration is disabled
+func HandleRegisterPage(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mode := registrationMode(db.WithContext(r.Context()))
+		data := map[string]interface{}{
+			"Title":              "Register",
+			"IsAuthenticated":    false,
+			"RegistrationMode":   mode,
+			"RegistrationClosed": mode != RegistrationOpen,
+			"CanRegister": mode == RegistrationOpen ||
+				(mode == RegistrationInviteOnly && r.URL.Query().Get("invite") != ""),
+		}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := web.Render(w, "register.html", data); err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
-		return
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := web.Render(w, r, "register.html", data); err != nil {
+			http.Error(w, "Failed to render template", http.StatusInternalServerError)
+			return
+		}
 	}
 }
 
 // HandleForgotPasswordPage renders the forgot password page
-func HandleForgotPasswordPage(w http.ResponseWriter, r *http.Request) {
-	data := map[string]interface{}{
-		"Title":           "Forgot Password",
-		"IsAuthenticated": false,
-	}
+func HandleForgotPasswordPage(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := map[string]interface{}{
+			"Title":              "Forgot Password",
+			"IsAuthenticated":    false,
+			"RegistrationClosed": registrationMode(db.WithContext(r.Context())) != RegistrationOpen,
+		}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := web.Render(w, "forgot-password.html", data); err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
-		return
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := web.Render(w, r, "forgot-password.html", data); err != nil {
+			http.Error(w, "Failed to render template", http.StatusInternalServerError)
+			return
+		}
 	}
 }
 
 // HandleSetupPage renders the first-run setup page
 func HandleSetupPage(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		// Check if setup is already complete
 		var count int
 		if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err == nil && count > 0 {
@@ -133,16 +179,22 @@ func HandleSetupPage(db *database.DB) http.HandlerFunc {
 		}
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "setup.html", data); err != nil {
+		if err := web.Render(w, r, "setup.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
 	}
 }
 
+// dashboardPinnedEntries is how many pinned journal entries the dashboard
+// shows, newest first
+const dashboardPinnedEntries = 3
+
 // HandleDashboard renders the dashboard page
 func HandleDashboard(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		p := i18n.FromContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Dashboard"
 
@@ -158,10 +210,10 @@ func HandleDashboard(db *database.DB, csrf *middleware.CSRFProtection) http.Hand
 			activeData := map[string]interface{}{
 				"ID":        activeCourse.ID,
 				"Name":      activeCourse.Name,
-				"StartDate": activeCourse.StartDate.Format("Jan 2, 2006"),
+				"StartDate": p.Date(activeCourse.StartDate),
 			}
 			if activeCourse.ExpectedEndDate.Valid {
-				activeData["ExpectedEndDate"] = activeCourse.ExpectedEndDate.Time.Format("Jan 2, 2006")
+				activeData["ExpectedEndDate"] = p.Date(activeCourse.ExpectedEndDate.Time)
 			}
 			if activeCourse.Notes.Valid {
 				activeData["Notes"] = activeCourse.Notes.String
@@ -179,14 +231,14 @@ func HandleDashboard(db *database.DB, csrf *middleware.CSRFProtection) http.Hand
 			err := db.QueryRow(`
 				SELECT id, timestamp, side
 				FROM injections
-				WHERE course_id = ?
+				WHERE course_id = ? AND voided_at IS NULL
 				ORDER BY timestamp DESC
 				LIMIT 1
 			`, activeCourse.ID).Scan(&lastInjection.ID, &lastInjection.Timestamp, &lastInjection.Side)
 			if err == nil {
 				// Convert timestamp to user's timezone
 				convertedTime := ConvertToUserTZ(lastInjection.Timestamp, userTimezone)
-				lastInjection.TimeAgo = formatTimeAgoWeb(convertedTime)
+				lastInjection.TimeAgo = formatTimeAgoWeb(p, convertedTime)
 				lastSide = lastInjection.Side
 				data["LastInjection"] = &lastInjection
 			}
@@ -196,27 +248,33 @@ func HandleDashboard(db *database.DB, csrf *middleware.CSRFProtection) http.Hand
 
 			// Total injections
 			var totalInjections int
-			_ = db.QueryRow("SELECT COUNT(*) FROM injections WHERE course_id = ?", activeCourse.ID).Scan(&totalInjections)
+			_ = db.QueryRow("SELECT COUNT(*) FROM injections WHERE course_id = ? AND voided_at IS NULL", activeCourse.ID).Scan(&totalInjections)
 			stats["TotalInjections"] = totalInjections
 
 			// Side counts
 			var leftCount, rightCount int
-			_ = db.QueryRow("SELECT COUNT(*) FROM injections WHERE course_id = ? AND side = 'left'", activeCourse.ID).Scan(&leftCount)
-			_ = db.QueryRow("SELECT COUNT(*) FROM injections WHERE course_id = ? AND side = 'right'", activeCourse.ID).Scan(&rightCount)
+			_ = db.QueryRow("SELECT COUNT(*) FROM injections WHERE course_id = ? AND side = 'left' AND voided_at IS NULL", activeCourse.ID).Scan(&leftCount)
+			_ = db.QueryRow("SELECT COUNT(*) FROM injections WHERE course_id = ? AND side = 'right' AND voided_at IS NULL", activeCourse.ID).Scan(&rightCount)
 			stats["LeftCount"] = leftCount
 			stats["RightCount"] = rightCount
 
-			// Next injection site (opposite of last injection)
+			// Next injection site from the rotation planner (falls back to alternating sides)
 			nextSide := "Left" // Default recommendation
 			if lastSide == "left" {
 				nextSide = "Right"
 			} else if lastSide == "right" {
 				nextSide = "Left"
 			}
-			stats["NextInjectionSite"] = nextSide
-			stats["LastInjectionSide"] = cases.Title(language.English).String(lastSide)
+			if FeatureEnabled(db, accountID, services.FeatureRotationPlanner) {
+				if suggestion, err := suggestNextInjectionSite(db, accountID, services.DefaultSiteRotationRules()); err == nil {
+					nextSide = cases.Title(language.English).String(suggestion.Side)
+					data["NextSite"] = suggestion
+				}
+			}
+			stats["NextInjectionSite"] = p.T(nextSide)
+			stats["LastInjectionSide"] = p.T(cases.Title(language.English).String(lastSide))
 			if lastSide == "" {
-				stats["LastInjectionSide"] = "None"
+				stats["LastInjectionSide"] = p.T("None")
 			}
 
 			// Course duration
@@ -224,6 +282,37 @@ func HandleDashboard(db *database.DB, csrf *middleware.CSRFProtection) http.Hand
 
 			data["Stats"] = stats
 
+			// With several courses running at once, break the figures down per
+			// course and add them up across all of them
+			if summaries, err := activeCourseSummaries(db, accountID); err == nil && len(summaries) > 1 {
+				courses := make([]map[string]interface{}, 0, len(summaries))
+				var total, left, right int
+				for _, c := range summaries {
+					lastInjection := p.T("None")
+					if c.LastInjectionAt != nil {
+						lastInjection = formatTimeAgoWeb(p, ConvertToUserTZ(*c.LastInjectionAt, userTimezone))
+					}
+					courses = append(courses, map[string]interface{}{
+						"ID":              c.CourseID,
+						"Name":            c.Name,
+						"Compound":        c.Compound,
+						"TotalInjections": c.TotalInjections,
+						"LeftCount":       c.LeftCount,
+						"RightCount":      c.RightCount,
+						"LastInjection":   lastInjection,
+					})
+					total += c.TotalInjections
+					left += c.LeftCount
+					right += c.RightCount
+				}
+				data["ActiveCourses"] = courses
+				data["CombinedStats"] = map[string]interface{}{
+					"TotalInjections": total,
+					"LeftCount":       left,
+					"RightCount":      right,
+				}
+			}
+
 			// Get low stock items
 			lowStockItems := []map[string]interface{}{}
 			rows, err := db.Query(`
@@ -254,10 +343,34 @@ func HandleDashboard(db *database.DB, csrf *middleware.CSRFProtection) http.Hand
 				}
 			}
 			data["LowStockItems"] = lowStockItems
+
+			// Journal entries pinned to the dashboard
+			pinned, err := repository.NewJournalRepository(db).ListPage(accountID,
+				repository.JournalFilter{PinnedOnly: true}, repository.PageRequest{Limit: dashboardPinnedEntries})
+			if err == nil && len(pinned.Items) > 0 {
+				entries := make([]map[string]interface{}, 0, len(pinned.Items))
+				for _, e := range pinned.Items {
+					body := e.Body
+					if len([]rune(body)) > 400 {
+						body = string([]rune(body)[:400]) + "…"
+					}
+					entry := map[string]interface{}{
+						"ID":    e.ID,
+						"Title": e.Title.String,
+						"Body":  body,
+						"Tags":  e.Tags,
+					}
+					if e.EntryDate.Valid {
+						entry["Date"] = p.Date(e.EntryDate.Time.UTC())
+					}
+					entries = append(entries, entry)
+				}
+				data["PinnedJournal"] = entries
+			}
 		}
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "dashboard.html", data); err != nil {
+		if err := web.Render(w, r, "dashboard.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
@@ -267,6 +380,7 @@ func HandleDashboard(db *database.DB, csrf *middleware.CSRFProtection) http.Hand
 // HandleInjectionsPage renders the injections history page
 func HandleInjectionsPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Injections"
 
@@ -284,14 +398,21 @@ func HandleInjectionsPage(db *database.DB, csrf *middleware.CSRFProtection) http
 				"Name": activeCourse.Name,
 			}
 
-			// Get injections for this course
+			// When several courses are running the log form asks which one
+			if activeCourses, err := courseRepo.ListActive(accountID); err == nil && len(activeCourses) > 1 {
+				data["ActiveCourses"] = activeCourses
+			}
+
+			// Get injections for every active course
 			rows, err := db.Query(`
-				SELECT id, timestamp, side, pain_level, notes
-				FROM injections
-				WHERE course_id = ?
-				ORDER BY timestamp DESC
+				SELECT i.id, i.timestamp, i.side, i.pain_level, i.notes, l.lot_number, c.name
+				FROM injections i
+				JOIN courses c ON c.id = i.course_id
+				LEFT JOIN inventory_lots l ON l.id = i.lot_id
+				WHERE c.account_id = ? AND c.is_active = TRUE AND i.voided_at IS NULL
+				ORDER BY i.timestamp DESC
 				LIMIT 50
-			`, activeCourse.ID)
+			`, accountID)
 			if err == nil {
 				defer rows.Close()
 				injections := []map[string]interface{}{}
@@ -300,9 +421,10 @@ func HandleInjectionsPage(db *database.DB, csrf *middleware.CSRFProtection) http
 					var timestamp time.Time
 					var side string
 					var painLevel sql.NullInt64
-					var notes sql.NullString
+					var notes, lotNumber sql.NullString
+					var courseName string
 
-					if err := rows.Scan(&id, &timestamp, &side, &painLevel, &notes); err == nil {
+					if err := rows.Scan(&id, &timestamp, &side, &painLevel, &notes, &lotNumber, &courseName); err == nil {
 						// Convert timestamp to user's timezone
 						convertedTime := ConvertToUserTZ(timestamp, userTimezone)
 						timeStr := FormatTimeForUser(db, userID, timestamp)
@@ -314,15 +436,47 @@ func HandleInjectionsPage(db *database.DB, csrf *middleware.CSRFProtection) http
 							"SideLower": side, // Add lowercase version for radio buttons
 							"PainLevel": painLevel.Int64,
 							"Notes":     notes.String,
+							"LotNumber": lotNumber.String,
+							"Course":    courseName,
 						})
 					}
 				}
 				data["Injections"] = injections
 			}
+
+			// Doses missed in the last 30 days, to say why they were skipped
+			missed, err := repository.NewMissedInjectionRepository(db).List(accountID, repository.MissedInjectionFilter{
+				Start: time.Now().AddDate(0, 0, -30),
+			}, 20)
+			if err == nil && len(missed) > 0 {
+				p := i18n.FromContext(r.Context())
+				items := []map[string]interface{}{}
+				for _, m := range missed {
+					items = append(items, map[string]interface{}{
+						"ID":        m.ID,
+						"Due":       FormatDateTimeForUser(db, userID, m.DueAt),
+						"Course":    m.CourseName,
+						"Reason":    m.Reason.String,
+						"Label":     missedReasonLabel(p, m.Reason.String),
+						"Note":      m.Note.String,
+						"Annotated": m.Reason.Valid,
+					})
+				}
+				reasons := []map[string]string{}
+				for _, reason := range models.MissedInjectionReasons {
+					reasons = append(reasons, map[string]string{"Value": reason, "Label": missedReasonLabel(p, reason)})
+				}
+				data["MissedInjections"] = items
+				data["MissedReasons"] = reasons
+			}
+
+			// Travel mode: the trip under way or coming up
+			data["Trip"] = tripPageData(db, accountID, userID, time.Now())
+			data["TravelTimezones"] = travelTimezones
 		}
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "injections.html", data); err != nil {
+		if err := web.Render(w, r, "injections.html", data); err != nil {
 			http.Error(w, "Failed to render template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -332,6 +486,7 @@ func HandleInjectionsPage(db *database.DB, csrf *middleware.CSRFProtection) http
 // HandleSymptomsPage renders the symptoms page
 func HandleSymptomsPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Symptoms"
 		accountID := middleware.GetAccountID(r.Context())
@@ -346,8 +501,13 @@ func HandleSymptomsPage(db *database.DB, csrf *middleware.CSRFProtection) http.H
 			}
 		}
 
+		// Symptoms the account can tick off, in catalog order
+		if catalog, err := repository.NewSymptomCatalogRepository(db).List(accountID, false); err == nil {
+			data["SymptomCatalog"] = catalog
+		}
+
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "symptoms.html", data); err != nil {
+		if err := web.Render(w, r, "symptoms.html", data); err != nil {
 			http.Error(w, "Failed to render template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -357,6 +517,7 @@ func HandleSymptomsPage(db *database.DB, csrf *middleware.CSRFProtection) http.H
 // HandleMedicationsPage renders the medications page
 func HandleMedicationsPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Medications - Injection Tracker"
 		data["Action"] = r.URL.Query().Get("action")
@@ -366,18 +527,7 @@ func HandleMedicationsPage(db *database.DB, csrf *middleware.CSRFProtection) htt
 		medicationRepo := repository.NewMedicationRepository(db)
 		activeMeds, err := medicationRepo.ListActive(accountID)
 		if err == nil && len(activeMeds) > 0 {
-			// Check if each medication was taken today
-			for _, med := range activeMeds {
-				// Query if there's a log entry for today
-				var count int
-				_ = db.QueryRow(`
-					SELECT COUNT(*) FROM medication_logs
-					WHERE medication_id = ?
-					AND DATE(timestamp) = DATE('now')
-					AND taken = 1
-				`, med.ID).Scan(&count)
-				med.TakenToday = count > 0
-			}
+			setTakenToday(db, activeMeds, userLocation(db, middleware.GetUserID(r.Context())))
 			data["ActiveMedications"] = activeMeds
 		}
 
@@ -395,8 +545,13 @@ func HandleMedicationsPage(db *database.DB, csrf *middleware.CSRFProtection) htt
 			}
 		}
 
+		// Item types a medication's doses can be taken out of
+		if types, err := repository.NewInventoryItemTypeRepository(db).List(accountID); err == nil && len(types) > 0 {
+			data["InventoryItemTypes"] = types
+		}
+
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "medications.html", data); err != nil {
+		if err := web.Render(w, r, "medications.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
@@ -406,9 +561,28 @@ func HandleMedicationsPage(db *database.DB, csrf *middleware.CSRFProtection) htt
 // HandleInventoryPage renders the inventory page
 func HandleInventoryPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Inventory - Injection Tracker"
 
+		// Item types the account tracks, for the add-inventory form
+		itemTypeOptions := []map[string]interface{}{}
+		typeNames := map[string]string{}
+		if types, err := repository.NewInventoryItemTypeRepository(db).List(middleware.GetAccountID(r.Context())); err == nil {
+			for _, t := range types {
+				conv := itemUnitConversion(t)
+				itemTypeOptions = append(itemTypeOptions, map[string]interface{}{
+					"ItemType":     t.ItemType,
+					"Name":         t.Name,
+					"IsLiquid":     t.Unit == "mL",
+					"PurchaseUnit": conv.PurchaseUnit,
+					"PerPurchase":  conv.PerPurchase,
+				})
+				typeNames[t.ItemType] = t.Name
+			}
+		}
+		data["ItemTypes"] = itemTypeOptions
+
 		// Fetch inventory items
 		rows, err := db.Query(`
 			SELECT id, item_type, quantity, unit, expiration_date,
@@ -449,10 +623,14 @@ func HandleInventoryPage(db *database.DB, csrf *middleware.CSRFProtection) http.
 					}
 
 					// Build display item
+					displayName := typeNames[item.ItemType]
+					if displayName == "" {
+						displayName = getInventoryDisplayName(item.ItemType)
+					}
 					displayItem := map[string]interface{}{
 						"ID":                item.ID,
 						"ItemType":          item.ItemType,
-						"DisplayName":       getInventoryDisplayName(item.ItemType),
+						"DisplayName":       displayName,
 						"Icon":              getInventoryIcon(item.ItemType),
 						"Quantity":          item.Quantity,
 						"Unit":              item.Unit,
@@ -492,7 +670,7 @@ func HandleInventoryPage(db *database.DB, csrf *middleware.CSRFProtection) http.
 		}
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "inventory.html", data); err != nil {
+		if err := web.Render(w, r, "inventory.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
@@ -512,7 +690,7 @@ func getInventoryDisplayName(itemType string) string {
 	if name, ok := names[itemType]; ok {
 		return name
 	}
-	return itemType
+	return formatItemTypeName(itemType)
 }
 
 // getInventoryIcon returns an icon/emoji for inventory items
@@ -534,29 +712,61 @@ func getInventoryIcon(itemType string) string {
 // HandleCoursesPage renders the courses page
 func HandleCoursesPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Courses"
 		accountID := middleware.GetAccountID(r.Context())
+		userID := middleware.GetUserID(r.Context())
 
-		// Get active course
+		// Get active courses; several can run at once
 		courseRepo := repository.NewCourseRepository(db)
-		activeCourse, err := courseRepo.GetActiveCourse(accountID)
-		if err == nil && activeCourse != nil {
-			activeData := map[string]interface{}{
-				"ID":           activeCourse.ID,
-				"Name":         activeCourse.Name,
-				"StartDate":    activeCourse.StartDate.Format("Jan 2, 2006"),
-				"StartDateISO": activeCourse.StartDate.Format("2006-01-02"),
-				"Notes":        "",
-			}
-			if activeCourse.ExpectedEndDate.Valid {
-				activeData["ExpectedEndDate"] = activeCourse.ExpectedEndDate.Time.Format("Jan 2, 2006")
-				activeData["ExpectedEndDateISO"] = activeCourse.ExpectedEndDate.Time.Format("2006-01-02")
+		if activeCourses, err := courseRepo.ListActive(accountID); err == nil && len(activeCourses) > 0 {
+			courses := make([]map[string]interface{}, 0, len(activeCourses))
+			for _, course := range activeCourses {
+				activeData := map[string]interface{}{
+					"ID":           course.ID,
+					"Name":         course.Name,
+					"StartDate":    course.StartDate.Format("Jan 2, 2006"),
+					"StartDateISO": course.StartDate.Format("2006-01-02"),
+					"Notes":        "",
+				}
+				if course.ExpectedEndDate.Valid {
+					activeData["ExpectedEndDate"] = course.ExpectedEndDate.Time.Format("Jan 2, 2006")
+					activeData["ExpectedEndDateISO"] = course.ExpectedEndDate.Time.Format("2006-01-02")
+				}
+				if course.Notes.Valid {
+					activeData["Notes"] = course.Notes.String
+				}
+				if course.DoseML.Valid {
+					activeData["DoseML"] = formatDose(course.DoseML.Float64)
+				}
+				if course.Concentration.Valid {
+					activeData["Concentration"] = formatDose(course.Concentration.Float64)
+				}
+				if steps, err := repository.NewDoseScheduleRepository(db).List(course.ID, accountID); err == nil {
+					activeData["Taper"] = toDoseScheduleResponse(course.ID, steps).Steps
+				}
+				if schedule, err := repository.NewCourseScheduleRepository(db).Get(course.ID, accountID); err == nil || err == repository.ErrNotFound {
+					if resp, err := courseScheduleResponse(db, accountID, course, schedule, userLocation(db, userID)); err == nil {
+						activeData["Schedule"] = courseScheduleFormData(db, userID, resp)
+					}
+				}
+				courses = append(courses, activeData)
 			}
-			if activeCourse.Notes.Valid {
-				activeData["Notes"] = activeCourse.Notes.String
+			data["ActiveCourses"] = courses
+			data["ActiveCourse"] = courses[0]
+		}
+
+		// Compounds selectable for new courses
+		if compounds, err := repository.NewCompoundRepository(db).ListActive(accountID); err == nil {
+			compoundOptions := []map[string]interface{}{}
+			for _, c := range compounds {
+				compoundOptions = append(compoundOptions, map[string]interface{}{
+					"ID":   c.ID,
+					"Name": c.Name,
+				})
 			}
-			data["ActiveCourse"] = activeData
+			data["Compounds"] = compoundOptions
 		}
 
 		// Get past courses
@@ -591,7 +801,7 @@ func HandleCoursesPage(db *database.DB, csrf *middleware.CSRFProtection) http.Ha
 		}
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "courses.html", data); err != nil {
+		if err := web.Render(w, r, "courses.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
@@ -601,11 +811,12 @@ func HandleCoursesPage(db *database.DB, csrf *middleware.CSRFProtection) http.Ha
 // HandleCalendarPage renders the calendar page
 func HandleCalendarPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Calendar - Injection Tracker"
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "calendar.html", data); err != nil {
+		if err := web.Render(w, r, "calendar.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
@@ -615,11 +826,12 @@ func HandleCalendarPage(db *database.DB, csrf *middleware.CSRFProtection) http.H
 // HandleReportsPage renders the reports page
 func HandleReportsPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Reports - Injection Tracker"
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "reports.html", data); err != nil {
+		if err := web.Render(w, r, "reports.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
@@ -629,6 +841,7 @@ func HandleReportsPage(db *database.DB, csrf *middleware.CSRFProtection) http.Ha
 // HandleSettingsPage renders the settings page
 func HandleSettingsPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Settings"
 
@@ -636,50 +849,56 @@ func HandleSettingsPage(db *database.DB, csrf *middleware.CSRFProtection) http.H
 
 		// Get user-specific settings
 		settings := map[string]interface{}{
-			"Theme":               "auto",
-			"Timezone":            "America/New_York",
-			"DateFormat":          "MM/DD/YYYY",
-			"TimeFormat":          "12h",
-			"AdvancedMode":        false,
-			"EnableNotifications": false,
-			"InjectionReminders":  false,
-			"ReminderTime":        "19:00",
-			"LowStockAlerts":      true,
+			"Theme":                  "auto",
+			"Timezone":               "America/New_York",
+			"Locale":                 i18n.FromContext(r.Context()).Lang(),
+			"DateFormat":             "MM/DD/YYYY",
+			"TimeFormat":             "12h",
+			"Units":                  "imperial",
+			"WeekStart":              "sunday",
+			"AdvancedMode":           false,
+			"EnableNotifications":    false,
+			"InjectionReminders":     false,
+			"ReminderTime":           "19:00",
+			"LowStockAlerts":         true,
+			"DuplicateWindowMinutes": DefaultDuplicateWindow,
 		}
 
-		// Query user settings
-		rows, err := db.Query(`SELECT key, value FROM settings WHERE key LIKE ? OR key NOT LIKE 'user_%'`,
-			fmt.Sprintf("user_%%_%d", userID))
-		if err == nil {
-			defer rows.Close()
-			for rows.Next() {
-				var key, value string
-				if err := rows.Scan(&key, &value); err == nil {
-					switch {
-					case strings.HasPrefix(key, fmt.Sprintf("user_theme_%d", userID)):
-						settings["Theme"] = value
-					case strings.HasPrefix(key, fmt.Sprintf("user_timezone_%d", userID)):
-						settings["Timezone"] = value
-					case strings.HasPrefix(key, fmt.Sprintf("user_date_format_%d", userID)):
-						settings["DateFormat"] = value
-					case strings.HasPrefix(key, fmt.Sprintf("user_time_format_%d", userID)):
-						settings["TimeFormat"] = value
-					case key == "advanced_mode_enabled":
-						settings["AdvancedMode"] = (value == "true")
-					case strings.HasPrefix(key, fmt.Sprintf("user_enable_notifications_%d", userID)):
-						settings["EnableNotifications"] = (value == "true")
-					case key == "injection_reminders":
-						settings["InjectionReminders"] = (value == "true")
-					case key == "reminder_time":
-						settings["ReminderTime"] = value
-					case key == "low_stock_alerts":
-						settings["LowStockAlerts"] = (value == "true")
+		// Query user preferences and settings, then the ones shared by the
+		// account
+		settingsRepo := repository.NewSettingsRepository(db)
+		if prefs, err := settingsRepo.GetPreferences(userID); err == nil {
+			settings["Theme"] = prefs.Theme
+			settings["Timezone"] = prefs.Timezone
+			settings["DateFormat"] = prefs.DateFormat
+			settings["TimeFormat"] = prefs.TimeFormat
+			settings["Units"] = prefs.Units
+			settings["WeekStart"] = prefs.WeekStart
+		}
+		if value, err := settingsRepo.GetUser(userID, repository.UserSettingEnableNotifications); err == nil {
+			settings["EnableNotifications"] = (value == "true")
+		}
+		if shared, err := settingsRepo.ListAccount(middleware.GetAccountID(r.Context())); err == nil {
+			for _, setting := range shared {
+				switch setting.Key {
+				case repository.AccountSettingAdvancedMode:
+					settings["AdvancedMode"] = (setting.Value == "true")
+				case repository.AccountSettingInjectionReminders:
+					settings["InjectionReminders"] = (setting.Value == "true")
+				case repository.AccountSettingReminderTime:
+					settings["ReminderTime"] = setting.Value
+				case repository.AccountSettingLowStockAlerts:
+					settings["LowStockAlerts"] = (setting.Value == "true")
+				case repository.AccountSettingDuplicateWindow:
+					if minutes, err := strconv.Atoi(setting.Value); err == nil {
+						settings["DuplicateWindowMinutes"] = minutes
 					}
 				}
 			}
 		}
 
 		data["Settings"] = settings
+		data["Languages"] = i18n.Languages
 		data["UserID"] = userID
 		data["User"] = map[string]interface{}{
 			"Username": "User", // TODO: Get actual username
@@ -692,7 +911,7 @@ func HandleSettingsPage(db *database.DB, csrf *middleware.CSRFProtection) http.H
 		data["LastBackup"] = "N/A"
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "settings.html", data); err != nil {
+		if err := web.Render(w, r, "settings.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
@@ -717,6 +936,7 @@ func HandleLogSymptomPage(db *database.DB) http.HandlerFunc {
 // HandleEditSymptomPage renders the edit symptom page
 func HandleEditSymptomPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Edit Symptom - Injection Tracker"
 		accountID := middleware.GetAccountID(r.Context())
@@ -729,9 +949,14 @@ func HandleEditSymptomPage(db *database.DB, csrf *middleware.CSRFProtection) htt
 			return
 		}
 
-		// Get symptom log
+		// Get symptom log, if it isn't someone else's private one
+		viewer, err := repository.LoadSymptomViewer(db, accountID, middleware.GetUserID(r.Context()))
+		if err != nil {
+			http.Error(w, "Failed to load symptom log", http.StatusInternalServerError)
+			return
+		}
 		symptomRepo := repository.NewSymptomRepository(db)
-		symptom, err := symptomRepo.GetByID(id, accountID)
+		symptom, err := symptomRepo.GetByID(id, accountID, viewer)
 		if err != nil {
 			http.Error(w, "Symptom log not found", http.StatusNotFound)
 			return
@@ -746,8 +971,13 @@ func HandleEditSymptomPage(db *database.DB, csrf *middleware.CSRFProtection) htt
 
 		data["Symptom"] = symptom
 
+		// Symptoms the account can tick off, in catalog order
+		if catalog, err := repository.NewSymptomCatalogRepository(db).List(accountID, false); err == nil {
+			data["SymptomCatalog"] = catalog
+		}
+
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "symptom_edit.html", data); err != nil {
+		if err := web.Render(w, r, "symptom_edit.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
@@ -757,6 +987,7 @@ func HandleEditSymptomPage(db *database.DB, csrf *middleware.CSRFProtection) htt
 // HandleSymptomsHistoryPage renders the symptoms history page
 func HandleSymptomsHistoryPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Symptom History - Injection Tracker"
 		accountID := middleware.GetAccountID(r.Context())
@@ -768,8 +999,13 @@ func HandleSymptomsHistoryPage(db *database.DB, csrf *middleware.CSRFProtection)
 			data["ActiveCourse"] = activeCourse
 		}
 
+		// Symptoms the account can tick off, in catalog order
+		if catalog, err := repository.NewSymptomCatalogRepository(db).List(accountID, false); err == nil {
+			data["SymptomCatalog"] = catalog
+		}
+
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "symptoms-history.html", data); err != nil {
+		if err := web.Render(w, r, "symptoms-history.html", data); err != nil {
 			http.Error(w, "Failed to render template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -791,156 +1027,107 @@ func HandleLogMedicationPage(db *database.DB) http.HandlerFunc {
 	}
 }
 
-// formatTimeAgoWeb returns a human-readable time ago string
-func formatTimeAgoWeb(t time.Time) string {
+// formatTimeAgoWeb returns a human-readable time ago string, in p's
+// language
+func formatTimeAgoWeb(p *i18n.Printer, t time.Time) string {
 	duration := time.Since(t)
 	if duration.Hours() < 1 {
 		minutes := int(duration.Minutes())
 		if minutes == 1 {
-			return "1 minute ago"
+			return p.T("1 minute ago")
 		}
-		return fmt.Sprintf("%d minutes ago", minutes)
+		return p.T("%d minutes ago", minutes)
 	} else if duration.Hours() < 24 {
 		hours := int(duration.Hours())
 		if hours == 1 {
-			return "1 hour ago"
+			return p.T("1 hour ago")
 		}
-		return fmt.Sprintf("%d hours ago", hours)
+		return p.T("%d hours ago", hours)
 	} else {
 		days := int(duration.Hours() / 24)
 		if days == 1 {
-			return "1 day ago"
+			return p.T("1 day ago")
 		}
-		return fmt.Sprintf("%d days ago", days)
+		return p.T("%d days ago", days)
 	}
 }
 
+// dashboardActivityEntries is how many entries the dashboard's recent
+// activity panel shows
+const dashboardActivityEntries = 10
+
 // HandleGetRecentActivity returns recent activity HTML for dashboard
 func HandleGetRecentActivity(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		p := i18n.FromContext(r.Context())
 		userID := middleware.GetUserID(r.Context())
 		userTimezone := GetUserTimezone(db, userID)
 
-		// Get recent activity using UNION to combine and sort by timestamp
-		rows, err := db.Query(`
-			SELECT 'injection' as type, timestamp, side as detail1, COALESCE(CAST(pain_level AS TEXT), '') as detail2, notes, id
-			FROM injections
-			UNION ALL
-			SELECT 'symptom' as type, timestamp, COALESCE(pain_location, '') as detail1, COALESCE(CAST(pain_level AS TEXT), '') as detail2, notes, id
-			FROM symptom_logs
-			UNION ALL
-			SELECT 'medication' as type, timestamp,
-				COALESCE((SELECT name FROM medications WHERE id = medication_logs.medication_id), '') as detail1,
-				CASE WHEN taken = 1 THEN 'taken' ELSE 'missed' END as detail2,
-				notes, medication_logs.id
-			FROM medication_logs
-			ORDER BY timestamp DESC
-			LIMIT 10
-		`)
-
+		activity, err := listActivity(db, middleware.GetAccountID(r.Context()), userID,
+			repository.ActivityFilter{}, repository.PageRequest{Limit: dashboardActivityEntries})
 		if err != nil {
-			w.Header().Set("Content-Type", "text/html")
-			_, _ = w.Write([]byte(`<p>Error loading activity</p>`))
+			renderFragment(w, r, http.StatusOK, "empty.html", web.EmptyState{Message: "Error loading activity"})
 			return
 		}
-		defer rows.Close()
 
-		activities := []map[string]interface{}{}
-		for rows.Next() {
-			var actType, detail1, detail2 string
-			var timestamp time.Time
-			var notes sql.NullString
-			var id int64
-
-			if err := rows.Scan(&actType, &timestamp, &detail1, &detail2, &notes, &id); err == nil {
-				// Convert timestamp to user's timezone
-				convertedTime := ConvertToUserTZ(timestamp, userTimezone)
-				activities = append(activities, map[string]interface{}{
-					"Type":      actType,
-					"Detail1":   detail1,
-					"Detail2":   detail2,
-					"Notes":     notes.String,
-					"Timestamp": convertedTime,
-					"TimeAgo":   formatTimeAgoWeb(convertedTime),
-					"ID":        id,
-				})
-			}
-		}
-
-		if len(activities) == 0 {
-			w.Header().Set("Content-Type", "text/html")
-			_, _ = w.Write([]byte(`
-				<div style="text-align: center; padding: 2rem; color: var(--pico-muted-color);">
-					<p>No recent activity yet.</p>
-					<small>Start by logging your first injection, symptom, or medication!</small>
-				</div>
-			`))
+		if len(activity.Items) == 0 {
+			renderFragment(w, r, http.StatusOK, "empty.html", web.EmptyState{
+				Message: "No recent activity yet.",
+				Hint:    "Start by logging your first injection, symptom, or medication!",
+			})
 			return
 		}
 
-		// Render recent activity with better styling
-		w.Header().Set("Content-Type", "text/html")
-		html := `<div style="display: flex; flex-direction: column; gap: 0.5rem;">`
-
-		for _, activity := range activities {
-			html += `<article style="margin: 0; padding: 0.75rem;">`
-
-			switch activity["Type"].(string) {
-			case "injection":
-				side := activity["Detail1"].(string)
-				painLevel := activity["Detail2"].(string)
-				html += fmt.Sprintf(`<div style="display: flex; justify-content: space-between; align-items: start;">
-					<div>
-						<strong>Injection (%s)</strong>`, cases.Title(language.English).String(side))
-				if painLevel != "" && painLevel != "0" {
-					html += fmt.Sprintf(` <small>Pain: %s/10</small>`, painLevel)
-				}
-				html += fmt.Sprintf(`<br><small style="color: var(--pico-muted-color);">%s</small>`, activity["TimeAgo"])
-			case "symptom":
-				location := activity["Detail1"].(string)
-				painLevel := activity["Detail2"].(string)
-				html += `<div style="display: flex; justify-content: space-between; align-items: start;">
-					<div>
-						<strong>Symptom Logged</strong>`
-				if location != "" {
-					html += fmt.Sprintf(` <small>%s</small>`, strings.ReplaceAll(location, "_", " "))
-				}
-				if painLevel != "" && painLevel != "0" {
-					html += fmt.Sprintf(` <small>Pain: %s/10</small>`, painLevel)
-				}
-				html += fmt.Sprintf(`<br><small style="color: var(--pico-muted-color);">%s</small>`, activity["TimeAgo"])
-			case "medication":
-				medName := activity["Detail1"].(string)
-				status := activity["Detail2"].(string)
-				statusColor := "var(--pico-ins-color)"
-				if status == "missed" {
-					statusColor = "var(--pico-del-color)"
-				}
-				html += fmt.Sprintf(`<div style="display: flex; justify-content: space-between; align-items: start;">
-					<div>
-						<strong>%s</strong> <small style="color: %s;">%s</small>
-						<br><small style="color: var(--pico-muted-color);">%s</small>`,
-					medName, statusColor, cases.Title(language.English).String(status), activity["TimeAgo"])
+		type activityItem struct {
+			Title     string
+			PainLevel int64
+			When      string
+			Notes     string
+		}
+		items := make([]activityItem, 0, len(activity.Items))
+		for _, e := range activity.Items {
+			item := activityItem{
+				Title: services.ActivityTitle(p, e),
+				When:  formatTimeAgoWeb(p, ConvertToUserTZ(e.Timestamp, userTimezone)),
+				Notes: e.Notes.String,
 			}
-
-			if notes, ok := activity["Notes"].(string); ok && notes != "" {
-				if len(notes) > 60 {
-					notes = notes[:60] + "..."
-				}
-				html += fmt.Sprintf(`<br><small>%s</small>`, notes)
+			if e.PainLevel.Valid {
+				item.PainLevel = e.PainLevel.Int64
 			}
-
-			html += `</div></div></article>`
+			if e.ActorName.Valid {
+				item.When = p.T("%s by %s", item.When, e.ActorName.String)
+			}
+			if len([]rune(item.Notes)) > 60 {
+				item.Notes = string([]rune(item.Notes)[:60]) + "..."
+			}
+			items = append(items, item)
 		}
+		renderFragment(w, r, http.StatusOK, "recent_activity.html", items)
+	}
+}
 
-		html += `</div>`
-		_, _ = w.Write([]byte(html))
+// renderFragment answers an HTMX request with the partial name, in the
+// request's language
+func renderFragment(w http.ResponseWriter, r *http.Request, status int, name string, data interface{}) {
+	// Render first so a template error doesn't leave half a fragment
+	var buf bytes.Buffer
+	if err := web.RenderFragment(&buf, i18n.FromContext(r.Context()), name, data); err != nil {
+		middleware.Log(r.Context()).Error("Failed to render fragment", "fragment", name, "err", err)
+		http.Error(w, "Failed to render", http.StatusInternalServerError)
+		return
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
 }
 
-// HandleActivityPage renders the full activity history page
+// HandleActivityPage renders the full activity history page, a page of the
+// activity feed at a time
 func HandleActivityPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		p := i18n.FromContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Activity History - Injection Tracker"
 
@@ -948,56 +1135,35 @@ func HandleActivityPage(db *database.DB, csrf *middleware.CSRFProtection) http.H
 		userID := middleware.GetUserID(r.Context())
 		userTimezone := GetUserTimezone(db, userID)
 
-		// Get all activity using UNION to combine and sort by timestamp
-		rows, err := db.Query(`
-			SELECT 'injection' as type, timestamp, side as detail1, COALESCE(CAST(pain_level AS TEXT), '') as detail2, notes, id
-			FROM injections
-			UNION ALL
-			SELECT 'symptom' as type, timestamp, COALESCE(pain_location, '') as detail1, COALESCE(CAST(pain_level AS TEXT), '') as detail2, notes, id
-			FROM symptom_logs
-			UNION ALL
-			SELECT 'medication' as type, timestamp,
-				COALESCE((SELECT name FROM medications WHERE id = medication_logs.medication_id), '') as detail1,
-				CASE WHEN taken = 1 THEN 'taken' ELSE 'missed' END as detail2,
-				notes, medication_logs.id
-			FROM medication_logs
-			ORDER BY timestamp DESC
-		`)
-
+		activity, err := listActivity(db, middleware.GetAccountID(r.Context()), userID,
+			repository.ActivityFilter{}, repository.PageRequest{Cursor: r.URL.Query().Get("cursor")})
 		if err != nil {
 			http.Error(w, "Failed to load activity", http.StatusInternalServerError)
 			return
 		}
-		defer rows.Close()
 
 		activities := []map[string]interface{}{}
-		for rows.Next() {
-			var actType, detail1, detail2 string
-			var timestamp time.Time
-			var notes sql.NullString
-			var id int64
-
-			if err := rows.Scan(&actType, &timestamp, &detail1, &detail2, &notes, &id); err == nil {
-				// Convert timestamp to user's timezone
-				convertedTime := ConvertToUserTZ(timestamp, userTimezone)
-				formattedDateTime := FormatDateTimeForUser(db, userID, timestamp)
-				activities = append(activities, map[string]interface{}{
-					"Type":          actType,
-					"Detail1":       detail1,
-					"Detail2":       detail2,
-					"Notes":         notes.String,
-					"Timestamp":     convertedTime,
-					"TimeAgo":       formatTimeAgoWeb(convertedTime),
-					"FormattedDate": formattedDateTime,
-					"ID":            id,
-				})
-			}
+		for _, e := range activity.Items {
+			convertedTime := ConvertToUserTZ(e.Timestamp, userTimezone)
+			activities = append(activities, map[string]interface{}{
+				"Type":          e.Kind,
+				"Title":         services.ActivityTitle(p, e),
+				"Action":        e.Action,
+				"PainLevel":     e.PainLevel.Int64,
+				"Actor":         e.ActorName.String,
+				"Notes":         e.Notes.String,
+				"Timestamp":     convertedTime,
+				"TimeAgo":       formatTimeAgoWeb(p, convertedTime),
+				"FormattedDate": FormatDateTimeForUser(db, userID, e.Timestamp),
+				"ID":            e.ID,
+			})
 		}
 
 		data["Activities"] = activities
+		data["NextCursor"] = activity.NextCursor
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "activity.html", data); err != nil {
+		if err := web.Render(w, r, "activity.html", data); err != nil {
 			http.Error(w, "Failed to render template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -1007,11 +1173,12 @@ func HandleActivityPage(db *database.DB, csrf *middleware.CSRFProtection) http.H
 // HandleInventoryHistoryPage renders the full inventory history page
 func HandleInventoryHistoryPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		data["Title"] = "Inventory History - Injection Tracker"
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "inventory_history.html", data); err != nil {
+		if err := web.Render(w, r, "inventory_history.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
@@ -1021,6 +1188,7 @@ func HandleInventoryHistoryPage(db *database.DB, csrf *middleware.CSRFProtection
 // HandleInventoryItemHistoryPage renders the inventory history for a specific item type
 func HandleInventoryItemHistoryPage(db *database.DB, csrf *middleware.CSRFProtection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		data := getBasePageData(db, r, csrf)
 		itemType := chi.URLParam(r, "itemType")
 
@@ -1036,8 +1204,11 @@ func HandleInventoryItemHistoryPage(db *database.DB, csrf *middleware.CSRFProtec
 
 		displayName, ok := displayNames[itemType]
 		if !ok {
-			http.Error(w, "Invalid item type", http.StatusBadRequest)
-			return
+			if !isValidItemType(db, middleware.GetAccountID(r.Context()), itemType) {
+				http.Error(w, "Invalid item type", http.StatusBadRequest)
+				return
+			}
+			displayName = formatItemTypeName(itemType)
 		}
 
 		data["ItemType"] = itemType
@@ -1045,7 +1216,7 @@ func HandleInventoryItemHistoryPage(db *database.DB, csrf *middleware.CSRFProtec
 		data["Title"] = displayName + " History - Injection Tracker"
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := web.Render(w, "inventory_item_history.html", data); err != nil {
+		if err := web.Render(w, r, "inventory_item_history.html", data); err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
 		}
@@ -26,7 +26,7 @@ func TestHandleDashboard(t *testing.T) {
 	injection := createTestInjection(t, db, course.ID, user.ID, account.ID)
 
 	// Create CSRF protection
-	csrf := middleware.NewCSRFProtection("test-secret")
+	csrf := middleware.NewCSRFProtection("test-secret", middleware.CSRFOptions{})
 
 	// Create handler
 	handler := HandleDashboard(db, csrf)
@@ -130,8 +130,13 @@ func createTestTables(t *testing.T, db *database.DB) {
 		CREATE TABLE accounts (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			name TEXT NOT NULL,
+			owners_see_private BOOLEAN NOT NULL DEFAULT 0,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			suspension TEXT,
+			suspension_reason TEXT,
+			suspended_at TIMESTAMP,
+			suspended_by INTEGER
 		)
 	`)
 	if err != nil {
@@ -169,6 +174,9 @@ func createTestTables(t *testing.T, db *database.DB) {
 			actual_end_date DATE,
 			is_active BOOLEAN DEFAULT 1,
 			notes TEXT,
+			dose_ml REAL,
+			concentration_mg_per_ml REAL,
+			compound_id INTEGER,
 			account_id INTEGER NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -195,6 +203,12 @@ func createTestTables(t *testing.T, db *database.DB) {
 			has_knots BOOLEAN DEFAULT 0,
 			site_reaction TEXT CHECK(site_reaction IN ('none', 'redness', 'swelling', 'bruising', 'other')),
 			notes TEXT,
+			dose_ml REAL,
+			attachment_id INTEGER,
+			lot_id INTEGER,
+			voided_at TIMESTAMP,
+			voided_by INTEGER,
+			void_reason TEXT,
 			account_id INTEGER NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -219,6 +233,8 @@ func createTestTables(t *testing.T, db *database.DB) {
 			pain_type TEXT,
 			symptoms TEXT,
 			notes TEXT,
+			attachment_id INTEGER,
+			visibility TEXT NOT NULL DEFAULT 'shared',
 			account_id INTEGER NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -260,6 +276,7 @@ func createTestTables(t *testing.T, db *database.DB) {
 			logged_by INTEGER,
 			timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			taken BOOLEAN NOT NULL,
+			late BOOLEAN NOT NULL DEFAULT 0,
 			notes TEXT,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (medication_id) REFERENCES medications(id) ON DELETE CASCADE,
@@ -269,6 +286,51 @@ func createTestTables(t *testing.T, db *database.DB) {
 	if err != nil {
 		t.Fatalf("Failed to create medication_logs table: %v", err)
 	}
+
+	// Create the inventory and audit tables the activity feed reads
+	_, err = db.Exec(`
+		CREATE TABLE inventory_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			item_type TEXT NOT NULL,
+			quantity REAL NOT NULL DEFAULT 0,
+			unit TEXT NOT NULL,
+			account_id INTEGER NOT NULL
+		);
+		CREATE TABLE inventory_item_types (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL,
+			item_type TEXT NOT NULL,
+			name TEXT NOT NULL
+		);
+		CREATE TABLE inventory_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER,
+			item_type TEXT NOT NULL,
+			change_amount REAL NOT NULL,
+			quantity_before REAL NOT NULL,
+			quantity_after REAL NOT NULL,
+			reason TEXT NOT NULL,
+			reference_id INTEGER,
+			reference_type TEXT,
+			performed_by INTEGER,
+			timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			notes TEXT
+		);
+		CREATE TABLE audit_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER,
+			action TEXT NOT NULL,
+			entity_type TEXT NOT NULL,
+			entity_id INTEGER,
+			details TEXT,
+			ip_address TEXT,
+			user_agent TEXT,
+			timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create inventory and audit tables: %v", err)
+	}
 }
 
 func createTestAccount(t *testing.T, db *database.DB) *models.Account {
@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// WebhookRequest is the payload for creating or updating a webhook
+type WebhookRequest struct {
+	URL          string   `json:"url" validate:"max=2000"`
+	Events       []string `json:"events" validate:"max=20,itemmax=50"`
+	Description  *string  `json:"description,omitempty" validate:"max=200"`
+	IsEnabled    *bool    `json:"is_enabled,omitempty"`
+	RotateSecret bool     `json:"rotate_secret,omitempty"`
+}
+
+// WebhookResponse is a webhook as returned by the API.
+// Secret is only populated when it is created or rotated.
+type WebhookResponse struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description,omitempty"`
+	IsEnabled   bool      `json:"is_enabled"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDeliveryResponse is a delivery log entry
+type WebhookDeliveryResponse struct {
+	ID             int64           `json:"id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int64          `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+func toWebhookResponse(w *models.Webhook, includeSecret bool) WebhookResponse {
+	resp := WebhookResponse{
+		ID:          w.ID,
+		URL:         w.URL,
+		Events:      []string{},
+		Description: w.Description.String,
+		IsEnabled:   w.IsEnabled,
+		CreatedAt:   w.CreatedAt,
+		UpdatedAt:   w.UpdatedAt,
+	}
+	_ = json.Unmarshal([]byte(w.Events), &resp.Events)
+	if includeSecret {
+		resp.Secret = w.Secret
+	}
+	return resp
+}
+
+func toWebhookDeliveryResponse(d *models.WebhookDelivery) WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		ID:        d.ID,
+		Event:     d.Event,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		LastError: d.LastError.String,
+		CreatedAt: d.CreatedAt,
+		Payload:   json.RawMessage(d.Payload),
+	}
+	if d.ResponseStatus.Valid {
+		resp.ResponseStatus = &d.ResponseStatus.Int64
+	}
+	if d.NextAttemptAt.Valid {
+		resp.NextAttemptAt = &d.NextAttemptAt.Time
+	}
+	if d.DeliveredAt.Valid {
+		resp.DeliveredAt = &d.DeliveredAt.Time
+	}
+	return resp
+}
+
+// encodeWebhookEvents validates and de-duplicates the subscribed events
+func encodeWebhookEvents(events []string) (string, bool) {
+	if len(events) == 0 {
+		return "", false
+	}
+
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, e := range events {
+		if !services.IsValidWebhookEvent(e) {
+			return "", false
+		}
+		if !seen[e] {
+			seen[e] = true
+			unique = append(unique, e)
+		}
+	}
+
+	b, _ := json.Marshal(unique)
+	return string(b), true
+}
+
+// emitWebhookEvent queues an event for the account's webhooks. Deliveries
+// carry on after the request that triggered them has finished.
+func emitWebhookEvent(db *database.DB, accountID int64, event string, data interface{}) {
+	if accountID == 0 {
+		return
+	}
+	services.NewWebhookService(db.Detach()).Emit(accountID, event, data)
+}
+
+// emitLowStockIfCrossed fires inventory.low_stock when a change takes an item
+// from above its threshold to at or below it. The item's stock alert is
+// brought up to date whether or not it crossed.
+func emitLowStockIfCrossed(db *database.DB, accountID int64, item *models.InventoryItem, quantityBefore float64) {
+	if item == nil {
+		return
+	}
+	checkStockAlert(db, accountID, item)
+	if !item.LowStockThreshold.Valid {
+		return
+	}
+	threshold := item.LowStockThreshold.Float64
+	if quantityBefore <= threshold || item.Quantity > threshold {
+		return
+	}
+
+	emitWebhookEvent(db, accountID, services.EventInventoryLowStock, map[string]interface{}{
+		"item_type":       item.ItemType,
+		"quantity":        item.Quantity,
+		"unit":            item.Unit,
+		"threshold":       threshold,
+		"quantity_before": quantityBefore,
+	})
+}
+
+// HandleGetWebhooks lists the account's webhooks
+func HandleGetWebhooks(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		webhookRepo := repository.NewWebhookRepository(db)
+		webhooks, err := webhookRepo.List(accountID)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve webhooks", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]WebhookResponse, 0, len(webhooks))
+		for _, wh := range webhooks {
+			resp = append(resp, toWebhookResponse(wh, false))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"webhooks":         resp,
+			"available_events": services.WebhookEvents,
+		})
+	}
+}
+
+// HandleGetWebhook returns a single webhook
+func HandleGetWebhook(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+			return
+		}
+
+		webhookRepo := repository.NewWebhookRepository(db)
+		webhook, err := webhookRepo.GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve webhook", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toWebhookResponse(webhook, false))
+	}
+}
+
+// HandleCreateWebhook registers a webhook (owner only). The generated signing
+// secret is returned once in the response.
+func HandleCreateWebhook(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		role := middleware.GetRole(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if role != "owner" {
+			respond.Error(w, "Forbidden: only account owner can manage webhooks", http.StatusForbidden)
+			return
+		}
+
+		var req WebhookRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		if err := services.ValidateWebhookURL(req.URL); err != nil {
+			respond.Validation(w, "url must be an absolute http(s) URL", respond.Field("url", "must be an absolute http(s) URL"))
+			return
+		}
+
+		events, ok := encodeWebhookEvents(req.Events)
+		if !ok {
+			respond.Error(w, "events must list one or more of: "+strings.Join(services.WebhookEvents, ", "), http.StatusBadRequest)
+			return
+		}
+
+		secret, err := services.GenerateWebhookSecret()
+		if err != nil {
+			respond.Error(w, "Failed to generate webhook secret", http.StatusInternalServerError)
+			return
+		}
+
+		webhook := &models.Webhook{
+			AccountID:   accountID,
+			URL:         req.URL,
+			Secret:      secret,
+			Events:      events,
+			Description: nullString(req.Description),
+			IsEnabled:   req.IsEnabled == nil || *req.IsEnabled,
+			CreatedBy:   sql.NullInt64{Int64: userID, Valid: true},
+		}
+
+		webhookRepo := repository.NewWebhookRepository(db)
+		if err := webhookRepo.Create(webhook); err != nil {
+			respond.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"create",
+			"webhook",
+			sql.NullInt64{Int64: webhook.ID, Valid: true},
+			map[string]interface{}{"url": webhook.URL, "events": req.Events},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(toWebhookResponse(webhook, true))
+	}
+}
+
+// HandleUpdateWebhook updates a webhook (owner only)
+func HandleUpdateWebhook(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		role := middleware.GetRole(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if role != "owner" {
+			respond.Error(w, "Forbidden: only account owner can manage webhooks", http.StatusForbidden)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+			return
+		}
+
+		var req WebhookRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		webhookRepo := repository.NewWebhookRepository(db)
+		webhook, err := webhookRepo.GetByID(id, accountID)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve webhook", http.StatusInternalServerError)
+			return
+		}
+
+		if req.URL != "" {
+			if err := services.ValidateWebhookURL(req.URL); err != nil {
+				respond.Validation(w, "url must be an absolute http(s) URL", respond.Field("url", "must be an absolute http(s) URL"))
+				return
+			}
+			webhook.URL = req.URL
+		}
+		if req.Events != nil {
+			events, ok := encodeWebhookEvents(req.Events)
+			if !ok {
+				respond.Error(w, "events must list one or more of: "+strings.Join(services.WebhookEvents, ", "), http.StatusBadRequest)
+				return
+			}
+			webhook.Events = events
+		}
+		if req.Description != nil {
+			webhook.Description = nullString(req.Description)
+		}
+		if req.IsEnabled != nil {
+			webhook.IsEnabled = *req.IsEnabled
+		}
+		if req.RotateSecret {
+			secret, err := services.GenerateWebhookSecret()
+			if err != nil {
+				respond.Error(w, "Failed to generate webhook secret", http.StatusInternalServerError)
+				return
+			}
+			webhook.Secret = secret
+		}
+
+		if err := webhookRepo.Update(webhook); err != nil {
+			respond.Error(w, "Failed to update webhook", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"update",
+			"webhook",
+			sql.NullInt64{Int64: webhook.ID, Valid: true},
+			map[string]interface{}{"url": webhook.URL, "rotated_secret": req.RotateSecret},
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		webhook.UpdatedAt = time.Now()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(toWebhookResponse(webhook, req.RotateSecret))
+	}
+}
+
+// HandleDeleteWebhook removes a webhook and its delivery log (owner only)
+func HandleDeleteWebhook(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		role := middleware.GetRole(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if role != "owner" {
+			respond.Error(w, "Forbidden: only account owner can manage webhooks", http.StatusForbidden)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+			return
+		}
+
+		webhookRepo := repository.NewWebhookRepository(db)
+		if err := webhookRepo.Delete(id, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+			return
+		}
+
+		auditRepo := repository.NewAuditRepository(db)
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{Int64: userID, Valid: true},
+			"delete",
+			"webhook",
+			sql.NullInt64{Int64: id, Valid: true},
+			nil,
+			r.RemoteAddr,
+			r.UserAgent(),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleGetWebhookDeliveries returns the delivery log for a webhook
+func HandleGetWebhookDeliveries(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		userID := middleware.GetUserID(r.Context())
+		accountID := middleware.GetAccountID(r.Context())
+		if userID == 0 || accountID == 0 {
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			respond.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+			return
+		}
+
+		limit := 50
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 200 {
+				limit = l
+			}
+		}
+		offset := 0
+		if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+			if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+				offset = o
+			}
+		}
+
+		webhookRepo := repository.NewWebhookRepository(db)
+		if _, err := webhookRepo.GetByID(id, accountID); err != nil {
+			if err == repository.ErrNotFound {
+				respond.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			respond.Error(w, "Failed to retrieve webhook", http.StatusInternalServerError)
+			return
+		}
+
+		deliveries, err := webhookRepo.ListDeliveries(id, limit, offset)
+		if err != nil {
+			respond.Error(w, "Failed to retrieve deliveries", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]WebhookDeliveryResponse, 0, len(deliveries))
+		for _, d := range deliveries {
+			resp = append(resp, toWebhookDeliveryResponse(d))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/models"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumn(i); got != want {
+			t.Errorf("xlsxColumn(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestBuildExportWorkbook(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	data := &ExportData{
+		Injections: []ExportInjection{{
+			ID:        7,
+			Timestamp: time.Date(2025, 1, 2, 18, 0, 0, 0, time.UTC),
+			Side:      "left",
+			DoseML:    1,
+			Notes:     "a < b & c",
+		}},
+	}
+	inventory := []*models.InventoryItem{{
+		ItemType:       "progesterone",
+		Quantity:       10,
+		Unit:           "mL",
+		ExpirationDate: sql.NullTime{Time: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+	}}
+
+	var buf bytes.Buffer
+	if err := buildExportWorkbook(data, inventory, loc, i18n.Default()).write(&buf); err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Workbook is not a zip file: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+	}
+
+	for _, name := range []string{"Injections", "Symptoms", "Medications", "Inventory"} {
+		if !strings.Contains(files["xl/workbook.xml"], `name="`+name+`"`) {
+			t.Errorf("Expected a %s sheet", name)
+		}
+	}
+
+	injections := files["xl/worksheets/sheet1.xml"]
+	if !strings.Contains(injections, `state="frozen"`) {
+		t.Error("Expected the header row to be frozen")
+	}
+	// 2025-01-02 13:00 in New York is serial 45659 + 13/24
+	if !strings.Contains(injections, `<c r="B2" s="2"><v>45659.541666666664</v></c>`) {
+		t.Errorf("Expected injection time as a local date cell, got %s", injections)
+	}
+	if !strings.Contains(injections, "a &lt; b &amp; c") {
+		t.Error("Expected notes to be escaped")
+	}
+
+	if !strings.Contains(files["xl/worksheets/sheet4.xml"], `<c r="F2" s="1"><v>45809</v></c>`) {
+		t.Errorf("Expected expiration as a date cell, got %s", files["xl/worksheets/sheet4.xml"])
+	}
+}
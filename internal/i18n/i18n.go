@@ -0,0 +1,238 @@
+// Package i18n translates what the server writes for people to read: pages,
+// HTMX fragments, emails, notifications and exports. Messages are written in
+// English in the code and looked up by that text in the catalog of the
+// reader's language, so anything missing from a catalog shows in English:
+//
+//	p := i18n.FromContext(r.Context())
+//	p.T("No injections recorded yet.")
+//	p.T("Showing %d of %d injections.", shown, total)
+//
+// Catalogs live in locales/<language>.json. Each maps the English text to
+// its translation, keeping the same fmt verbs in the same order, and gives
+// the language's date layouts and month names.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+// Language is one the server can write in
+type Language struct {
+	Tag  string // BCP 47 tag, as saved in the locale preference
+	Name string // In the language itself, for pickers
+}
+
+// Languages lists the languages with a catalog, English first
+var Languages = []Language{
+	{Tag: "en", Name: "English"},
+	{Tag: "es", Name: "Español"},
+	{Tag: "de", Name: "Deutsch"},
+}
+
+// catalog is one language's messages and date formats
+type catalog struct {
+	DateLayout     string            `json:"date"`         // Go layout, e.g. "Jan 2, 2006"
+	DateTimeLayout string            `json:"date_time"`    // Go layout, e.g. "Jan 2, 2006 3:04 PM"
+	LongDateLayout string            `json:"long_date"`    // Go layout, e.g. "January 2, 2006"
+	MonthDayLayout string            `json:"month_day"`    // Go layout, e.g. "Jan 2"
+	TimeLayout     string            `json:"time"`         // Go layout, e.g. "3:04 PM"
+	Months         []string          `json:"months"`       // January to December, for "January"
+	ShortMonths    []string          `json:"short_months"` // Jan to Dec, for "Jan"
+	Messages       map[string]string `json:"messages"`
+}
+
+var english = &catalog{
+	DateLayout:     "Jan 2, 2006",
+	DateTimeLayout: "Jan 2, 2006 3:04 PM",
+	LongDateLayout: "January 2, 2006",
+	MonthDayLayout: "Jan 2",
+	TimeLayout:     "3:04 PM",
+}
+
+var (
+	catalogs = map[language.Tag]*catalog{language.English: english}
+	// tags lists the catalogs' languages in the order matcher knows them
+	tags    = []language.Tag{language.English}
+	matcher language.Matcher
+	// sources maps each translation, lowercased, back to its English text
+	sources = map[string]string{}
+)
+
+func init() {
+	entries, err := localesFS.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		data, err := localesFS.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var c catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", entry.Name(), err))
+		}
+		tag := language.MustParse(strings.TrimSuffix(entry.Name(), ".json"))
+		catalogs[tag] = &c
+		tags = append(tags, tag)
+		for source, translation := range c.Messages {
+			sources[strings.ToLower(translation)] = source
+		}
+	}
+	matcher = language.NewMatcher(tags)
+}
+
+// Printer writes messages in one language
+type Printer struct {
+	tag     language.Tag
+	catalog *catalog
+}
+
+// Date and DateTime are arguments to T that are written in the printer's
+// date format. They let a message be built once and written in each
+// reader's language, as notifications are.
+type (
+	Date     time.Time
+	DateTime time.Time
+)
+
+// Default returns the printer for English, used when nothing says otherwise
+func Default() *Printer {
+	return &Printer{tag: language.English, catalog: english}
+}
+
+// For returns the printer for a saved locale such as "de-AT", falling back
+// to the closest language there is a catalog for, or English
+func For(locale string) *Printer {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return Default()
+	}
+	return match(tag)
+}
+
+// Negotiate picks the language for a request: the user's saved locale when
+// there is one, otherwise the best match for the Accept-Language header
+func Negotiate(locale, acceptLanguage string) *Printer {
+	if locale != "" {
+		return For(locale)
+	}
+	wanted, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(wanted) == 0 {
+		return Default()
+	}
+	return match(wanted...)
+}
+
+func match(want ...language.Tag) *Printer {
+	_, index, confidence := matcher.Match(want...)
+	if confidence == language.No {
+		return Default()
+	}
+	return &Printer{tag: tags[index], catalog: catalogs[tags[index]]}
+}
+
+// Lang is the printer's language as a BCP 47 tag, e.g. "es", for the lang
+// attribute and Content-Language
+func (p *Printer) Lang() string {
+	return p.tag.String()
+}
+
+// T translates msg and, given args, fills them in as fmt.Sprintf would.
+// Date and DateTime args are written in the language's date format.
+func (p *Printer) T(msg string, args ...interface{}) string {
+	format := msg
+	if translation := p.catalog.Messages[msg]; translation != "" {
+		format = translation
+	}
+	if len(args) == 0 {
+		return format
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case Date:
+			values[i] = p.Date(time.Time(v))
+		case DateTime:
+			values[i] = p.DateTime(time.Time(v))
+		default:
+			values[i] = arg
+		}
+	}
+	return fmt.Sprintf(format, values...)
+}
+
+// Date writes t as a short date, e.g. "Mar 4, 2026" or "4. März 2026"
+func (p *Printer) Date(t time.Time) string {
+	return p.format(t, p.catalog.DateLayout)
+}
+
+// DateTime writes t as a short date and time of day
+func (p *Printer) DateTime(t time.Time) string {
+	return p.format(t, p.catalog.DateTimeLayout)
+}
+
+// LongDate writes t with the month in full, e.g. "March 4, 2026"
+func (p *Printer) LongDate(t time.Time) string {
+	return p.format(t, p.catalog.LongDateLayout)
+}
+
+// MonthDay writes t as a day of the year, e.g. "Mar 4", for chart axes
+func (p *Printer) MonthDay(t time.Time) string {
+	return p.format(t, p.catalog.MonthDayLayout)
+}
+
+// Time writes the time of day of t
+func (p *Printer) Time(t time.Time) string {
+	return t.Format(p.catalog.TimeLayout)
+}
+
+// format formats t with layout, then swaps the English month name Go
+// writes for the catalog's
+func (p *Printer) format(t time.Time, layout string) string {
+	s := t.Format(layout)
+	month := int(t.Month()) - 1
+	switch {
+	case strings.Contains(layout, "January") && len(p.catalog.Months) == 12:
+		s = strings.Replace(s, t.Month().String(), p.catalog.Months[month], 1)
+	case strings.Contains(layout, "Jan") && len(p.catalog.ShortMonths) == 12:
+		s = strings.Replace(s, t.Month().String()[:3], p.catalog.ShortMonths[month], 1)
+	}
+	return s
+}
+
+// Source returns the English text that s translates, matching case
+// insensitively, or s itself. Imports use it to read files exported in
+// another language.
+func Source(s string) string {
+	if source, ok := sources[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return source
+	}
+	return s
+}
+
+type contextKey struct{}
+
+// WithPrinter returns a context carrying p
+func WithPrinter(ctx context.Context, p *Printer) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the printer negotiated for the request, or English
+func FromContext(ctx context.Context) *Printer {
+	if p, ok := ctx.Value(contextKey{}).(*Printer); ok {
+		return p
+	}
+	return Default()
+}
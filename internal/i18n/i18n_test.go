@@ -0,0 +1,115 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		locale string
+		accept string
+		want   string
+	}{
+		{"", "", "en"},
+		{"", "es-ES,es;q=0.9,en;q=0.8", "es"},
+		{"", "de-AT", "de"},
+		{"", "fr-FR,fr;q=0.9", "en"},
+		{"", "fr-FR,de;q=0.5", "de"},
+		{"", "not a header", "en"},
+		{"de", "es", "de"},
+		{"es-MX", "", "es"},
+		{"fr", "es", "en"},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.locale, tt.accept).Lang(); got != tt.want {
+			t.Errorf("Negotiate(%q, %q) = %s, want %s", tt.locale, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestDates(t *testing.T) {
+	at := time.Date(2026, time.March, 4, 14, 5, 0, 0, time.UTC)
+	tests := []struct {
+		locale                       string
+		date, dateTime, long, monDay string
+	}{
+		{"en", "Mar 4, 2026", "Mar 4, 2026 2:05 PM", "March 4, 2026", "Mar 4"},
+		{"es", "4 mar 2026", "4 mar 2026 14:05", "4 de marzo de 2026", "4 mar"},
+		{"de", "4. März 2026", "4. März 2026, 14:05", "4. März 2026", "4. März"},
+	}
+	for _, tt := range tests {
+		p := For(tt.locale)
+		if got := p.Date(at); got != tt.date {
+			t.Errorf("%s: Date = %q, want %q", tt.locale, got, tt.date)
+		}
+		if got := p.DateTime(at); got != tt.dateTime {
+			t.Errorf("%s: DateTime = %q, want %q", tt.locale, got, tt.dateTime)
+		}
+		if got := p.LongDate(at); got != tt.long {
+			t.Errorf("%s: LongDate = %q, want %q", tt.locale, got, tt.long)
+		}
+		if got := p.MonthDay(at); got != tt.monDay {
+			t.Errorf("%s: MonthDay = %q, want %q", tt.locale, got, tt.monDay)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	p := For("es")
+	if got := p.T("Showing %d of %d injections.", 3, 10); got != "Se muestran 3 de 10 inyecciones." {
+		t.Errorf("T = %q", got)
+	}
+	if got := p.T("Not in any catalog"); got != "Not in any catalog" {
+		t.Errorf("T of an unknown message = %q, want it unchanged", got)
+	}
+
+	expired := time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC)
+	if got := p.T("%s expired on %s. Please dispose of it and restock.", "Progesterone", Date(expired)); got != "Progesterone caducó el 1 may 2026. Deséchalo y repón existencias." {
+		t.Errorf("T with a Date = %q", got)
+	}
+	if got := Default().T("%s expired on %s. Please dispose of it and restock.", "Progesterone", Date(expired)); got != "Progesterone expired on May 1, 2026. Please dispose of it and restock." {
+		t.Errorf("English T with a Date = %q", got)
+	}
+}
+
+func TestSource(t *testing.T) {
+	tests := map[string]string{
+		"Fecha":        "Date",
+		"DOSIS (ML)":   "Dose (mL)",
+		" Schmerzart ": "Pain Type",
+		"Ja":           "Yes",
+		"Date":         "Date",
+		"unknown":      "unknown",
+	}
+	for in, want := range tests {
+		if got := Source(in); got != want {
+			t.Errorf("Source(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestCatalogVerbs checks every translation takes the same arguments as its
+// English text, so T never writes %!d(string=...) in another language
+func TestCatalogVerbs(t *testing.T) {
+	verb := regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+	for tag, c := range catalogs {
+		for source, translation := range c.Messages {
+			want, got := verb.FindAllString(source, -1), verb.FindAllString(translation, -1)
+			if len(want) != len(got) {
+				t.Errorf("%s: %q has verbs %v, want %v", tag, translation, got, want)
+				continue
+			}
+			for i := range want {
+				if want[i] != got[i] {
+					t.Errorf("%s: %q has verbs %v, want %v", tag, translation, got, want)
+					break
+				}
+			}
+		}
+		if c != english && (len(c.Months) != 12 || len(c.ShortMonths) != 12) {
+			t.Errorf("%s: want 12 months and 12 short months", tag)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", "json")
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	logger.Info("dropped")
+	logger.Warn("kept", "user_id", 7)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the warning, got %q", buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Expected JSON output: %v", err)
+	}
+	if entry["msg"] != "kept" || entry["user_id"] != float64(7) {
+		t.Errorf("Unexpected entry: %v", entry)
+	}
+
+	if _, err := New(&buf, "loud", "text"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+	if _, err := New(&buf, "info", "xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", "text")
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	logger.Info("dropped")
+	if err := SetLevel("info"); err != nil {
+		t.Fatalf("Failed to set level: %v", err)
+	}
+	logger.Info("kept")
+
+	if strings.Contains(buf.String(), "dropped") || !strings.Contains(buf.String(), "kept") {
+		t.Errorf("Expected only the message after SetLevel, got %q", buf.String())
+	}
+	if err := SetLevel("loud"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
@@ -2,10 +2,12 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"injection-tracker/internal/auth"
+	"injection-tracker/internal/respond"
 )
 
 type contextKey string
@@ -20,6 +22,18 @@ type UserContext struct {
 	Username  string
 	AccountID int64  // Account the user belongs to
 	Role      string // 'owner' or 'member'
+	// TokenAuth is set when the request signed in with an Authorization
+	// header rather than the auth cookie, as API clients do
+	TokenAuth bool
+	// SessionID is the token's session, empty for tokens issued before
+	// sessions were tracked
+	SessionID string
+	// ImpersonatorID is the admin acting as the user, or 0
+	ImpersonatorID int64
+	// Suspension is the account's suspension, models.AccountReadOnly or
+	// models.AccountLocked, or empty. It is not in the token; it is looked
+	// up along with the membership on each request.
+	Suspension string
 }
 
 // AuthMiddleware validates JWT tokens and adds user context
@@ -33,36 +47,79 @@ func NewAuthMiddleware(jwtManager *auth.JWTManager) *AuthMiddleware {
 	}
 }
 
-// RequireAuth ensures the user is authenticated
+// RequireAuth ensures the user is authenticated. An expired token gets 401
+// with the code token_expired, so clients know to refresh it.
 func (am *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
+	return am.authenticate(next, false)
+}
+
+// RequireRefreshableAuth is RequireAuth for refreshing the token, which also
+// lets through expired tokens that belong to a session. Whether the session
+// still allows it is up to handlers.CurrentSession.
+func (am *AuthMiddleware) RequireRefreshableAuth(next http.Handler) http.Handler {
+	return am.authenticate(next, true)
+}
+
+func (am *AuthMiddleware) authenticate(next http.Handler, allowExpired bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Get token from cookie or Authorization header
 		token := am.getToken(r)
 		if token == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// Validate token
-		claims, err := am.jwtManager.ValidateToken(token)
+		var claims *auth.Claims
+		var err error
+		if allowExpired {
+			claims, err = am.jwtManager.ValidateExpiredToken(token)
+			if errors.Is(err, auth.ErrExpiredToken) {
+				// Tokens from before sessions were tracked can't be
+				// refreshed once they expire
+				if claims.SessionID == "" {
+					respond.ErrorWithCode(w, http.StatusUnauthorized, respond.CodeSessionExpired, "Session expired")
+					return
+				}
+				err = nil
+			}
+		} else {
+			claims, err = am.jwtManager.ValidateToken(token)
+		}
+		if errors.Is(err, auth.ErrExpiredToken) {
+			respond.ErrorWithCode(w, http.StatusUnauthorized, respond.CodeTokenExpired, "Session token expired")
+			return
+		}
 		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// Add user context
 		userCtx := &UserContext{
-			UserID:    claims.UserID,
-			Username:  claims.Username,
-			AccountID: claims.AccountID,
-			Role:      claims.Role,
+			UserID:         claims.UserID,
+			Username:       claims.Username,
+			AccountID:      claims.AccountID,
+			Role:           claims.Role,
+			TokenAuth:      !hasAuthCookie(r),
+			SessionID:      claims.SessionID,
+			ImpersonatorID: claims.ImpersonatorID,
 		}
 		ctx := context.WithValue(r.Context(), UserContextKey, userCtx)
+		ctx = auth.WithImpersonator(ctx, claims.ImpersonatorID)
+		noteUser(ctx, userCtx.UserID, userCtx.AccountID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// hasAuthCookie reports whether r carries the auth cookie, which getToken
+// prefers to the Authorization header
+func hasAuthCookie(r *http.Request) bool {
+	_, err := r.Cookie("auth_token")
+	return err == nil
+}
+
 // getToken extracts JWT token from request
 func (am *AuthMiddleware) getToken(r *http.Request) string {
 	// Try cookie first
@@ -112,4 +169,30 @@ func GetRole(ctx context.Context) string {
 		return userCtx.Role
 	}
 	return ""
-}
\ No newline at end of file
+}
+
+// TokenAuthenticated reports whether the request signed in with an
+// Authorization header rather than the auth cookie
+func TokenAuthenticated(ctx context.Context) bool {
+	if userCtx, ok := ctx.Value(UserContextKey).(*UserContext); ok {
+		return userCtx.TokenAuth
+	}
+	return false
+}
+
+// GetSessionID retrieves the session the request's token belongs to
+func GetSessionID(ctx context.Context) string {
+	if userCtx, ok := ctx.Value(UserContextKey).(*UserContext); ok {
+		return userCtx.SessionID
+	}
+	return ""
+}
+
+// GetImpersonatorID retrieves the admin impersonating the request's user, or
+// 0 when the user signed in themselves
+func GetImpersonatorID(ctx context.Context) int64 {
+	if userCtx, ok := ctx.Value(UserContextKey).(*UserContext); ok {
+		return userCtx.ImpersonatorID
+	}
+	return 0
+}
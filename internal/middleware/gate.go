@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// RequestGate lets one request run something while no other request is in
+// flight, such as swapping the database during a restore. Requests arriving
+// meanwhile wait at the gate rather than fail.
+type RequestGate struct {
+	mu sync.RWMutex
+
+	// draining is closed while Exclusive waits, telling streams that would
+	// otherwise stay open to end
+	drainMu  sync.Mutex
+	draining chan struct{}
+}
+
+type gateKey struct{}
+
+// ErrNotThroughGate is returned by Exclusive for a request that didn't pass
+// through the gate's middleware
+var ErrNotThroughGate = errors.New("request did not pass through the gate")
+
+// NewRequestGate creates an open gate
+func NewRequestGate() *RequestGate {
+	return &RequestGate{draining: make(chan struct{})}
+}
+
+// Middleware holds the gate open for the duration of each request
+func (g *RequestGate) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.mu.RLock()
+		defer g.mu.RUnlock()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), gateKey{}, g)))
+	})
+}
+
+// Draining is closed when a request is waiting to run exclusively.
+// Long-lived requests like event streams should end when it is.
+func (g *RequestGate) Draining() <-chan struct{} {
+	g.drainMu.Lock()
+	defer g.drainMu.Unlock()
+	return g.draining
+}
+
+// Exclusive waits for every other request to finish, then runs fn while
+// new requests wait. r is the request calling it, which must have come
+// through the gate's middleware.
+func (g *RequestGate) Exclusive(r *http.Request, fn func() error) error {
+	if r.Context().Value(gateKey{}) != g {
+		return ErrNotThroughGate
+	}
+
+	g.drainMu.Lock()
+	select {
+	case <-g.draining:
+		// Another request is already waiting
+	default:
+		close(g.draining)
+	}
+	g.drainMu.Unlock()
+
+	// Trade this request's hold for the whole gate, and back again for
+	// the middleware to release
+	g.mu.RUnlock()
+	g.mu.Lock()
+	defer func() {
+		g.drainMu.Lock()
+		g.draining = make(chan struct{})
+		g.drainMu.Unlock()
+		g.mu.Unlock()
+		g.mu.RLock()
+	}()
+	return fn()
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestGateExclusive(t *testing.T) {
+	gate := NewRequestGate()
+
+	var mu sync.Mutex
+	var order []string
+	record := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, s)
+	}
+
+	slowStarted := make(chan struct{})
+	release := make(chan struct{})
+	handler := gate.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			close(slowStarted)
+			<-release
+			record("slow done")
+		case "/stream":
+			<-gate.Draining()
+			record("stream ended")
+		case "/restore":
+			err := gate.Exclusive(r, func() error {
+				record("exclusive")
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Exclusive failed: %v", err)
+			}
+		default:
+			record("later")
+		}
+	}))
+	serve := func(path string, wg *sync.WaitGroup) {
+		defer wg.Done()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go serve("/slow", &wg)
+	go serve("/stream", &wg)
+	<-slowStarted
+	go serve("/restore", &wg)
+
+	// The stream is told to end; the exclusive work waits for the slow
+	// request
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	time.Sleep(10 * time.Millisecond)
+
+	// Arrives during the exclusive work and waits for it
+	wg.Add(1)
+	go serve("/later", &wg)
+	wg.Wait()
+
+	want := []string{"stream ended", "slow done", "exclusive", "later"}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != len(want) {
+		t.Fatalf("Order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("Order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestRequestGateRequiresMiddleware(t *testing.T) {
+	gate := NewRequestGate()
+	r := httptest.NewRequest(http.MethodPost, "/restore", nil)
+	err := gate.Exclusive(r, func() error {
+		t.Error("fn ran for a request that skipped the gate")
+		return nil
+	})
+	if !errors.Is(err, ErrNotThroughGate) {
+		t.Errorf("Exclusive = %v, want ErrNotThroughGate", err)
+	}
+}
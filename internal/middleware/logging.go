@@ -1,9 +1,15 @@
 package middleware
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"injection-tracker/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
 // responseWriter wraps http.ResponseWriter to capture status code
@@ -24,7 +30,54 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
-// Logger logs all HTTP requests
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush a stream
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// requestLogKey holds the request's *requestLog
+type requestLogKey struct{}
+
+// requestLog carries what the access log reports but only learns further
+// in: RequireAuth fills in the user once the token checks out
+type requestLog struct {
+	userID    int64
+	accountID int64
+	// impersonatorID is the admin acting as the user, if any
+	impersonatorID int64
+}
+
+// noteUser records the authenticated user for the access log, and the admin
+// impersonating them
+func noteUser(ctx context.Context, userID, accountID int64) {
+	if info, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		info.userID = userID
+		info.accountID = accountID
+		info.impersonatorID = auth.Impersonator(ctx)
+	}
+}
+
+// Log returns the logger for a request, tagged with its request ID and, once
+// authenticated, the user and account. Use it instead of the log package in
+// handlers so entries can be tied back to the request that caused them.
+func Log(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := chimiddleware.GetReqID(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if userID := GetUserID(ctx); userID != 0 {
+		logger = logger.With("user_id", userID, "account_id", GetAccountID(ctx))
+	}
+	if impersonatorID := GetImpersonatorID(ctx); impersonatorID != 0 {
+		logger = logger.With("impersonator_id", impersonatorID)
+	}
+	return logger
+}
+
+// Logger writes an access log entry for every request: its ID, route,
+// status, latency and, for authenticated requests, user and account. Server
+// errors are logged at error level.
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -34,19 +87,37 @@ func Logger(next http.Handler) http.Handler {
 			ResponseWriter: w,
 			statusCode:     http.StatusOK,
 		}
+		info := &requestLog{}
+		ctx := context.WithValue(r.Context(), requestLogKey{}, info)
 
 		// Call the next handler
-		next.ServeHTTP(wrapped, r)
-
-		// Log the request
-		duration := time.Since(start)
-		log.Printf(
-			"%s %s %d %dms %s",
-			r.Method,
-			r.URL.Path,
-			wrapped.statusCode,
-			duration.Milliseconds(),
-			getIP(r),
+		next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+		attrs := []slog.Attr{
+			slog.String("request_id", chimiddleware.GetReqID(ctx)),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+		}
+		if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+			attrs = append(attrs, slog.String("route", rctx.RoutePattern()))
+		}
+		attrs = append(attrs,
+			slog.Int("status", wrapped.statusCode),
+			slog.Int64("bytes", wrapped.written),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("ip", getIP(r)),
 		)
+		if info.userID != 0 {
+			attrs = append(attrs, slog.Int64("user_id", info.userID), slog.Int64("account_id", info.accountID))
+		}
+		if info.impersonatorID != 0 {
+			attrs = append(attrs, slog.Int64("impersonator_id", info.impersonatorID))
+		}
+
+		level := slog.LevelInfo
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(ctx, level, "request", attrs...)
 	})
-}
\ No newline at end of file
+}
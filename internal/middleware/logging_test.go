@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	// Stands in for RequireAuth
+	fakeAuth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), UserContextKey, &UserContext{UserID: 3, AccountID: 9})
+			noteUser(ctx, 3, 9)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+
+	r := chi.NewRouter()
+	r.Use(chimiddleware.RequestID)
+	r.Use(Logger)
+	r.With(fakeAuth).Get("/api/courses/{id}", func(w http.ResponseWriter, r *http.Request) {
+		Log(r.Context()).Warn("inside")
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/api/courses/12", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a handler entry and an access entry, got %q", buf.String())
+	}
+	var inside, access map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &inside); err != nil {
+		t.Fatalf("Failed to parse handler entry: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &access); err != nil {
+		t.Fatalf("Failed to parse access entry: %v", err)
+	}
+
+	if inside["request_id"] == nil || inside["request_id"] != access["request_id"] {
+		t.Errorf("Expected both entries to share a request ID, got %v and %v", inside["request_id"], access["request_id"])
+	}
+	if inside["user_id"] != float64(3) || inside["account_id"] != float64(9) {
+		t.Errorf("Expected the handler entry to carry the user, got %v", inside)
+	}
+	if access["route"] != "/api/courses/{id}" || access["status"] != float64(http.StatusTeapot) || access["user_id"] != float64(3) {
+		t.Errorf("Unexpected access entry: %v", access)
+	}
+	if _, ok := access["latency"]; !ok {
+		t.Errorf("Expected latency in the access entry: %v", access)
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"injection-tracker/internal/respond"
+)
+
+// MaintenanceMode turns requests away with 503 Service Unavailable while
+// enabled, except those it's told to let through, so an administrator can
+// finish a restore or migration without users changing data underneath.
+type MaintenanceMode struct {
+	mu      sync.RWMutex
+	enabled bool
+	message string
+
+	// page writes the response for a turned-away page request; API
+	// requests get a JSON error
+	page func(w http.ResponseWriter, r *http.Request, message string)
+}
+
+// DefaultMaintenanceMessage is shown when maintenance mode is enabled
+// without a message of its own
+const DefaultMaintenanceMessage = "The site is down for maintenance. Please try again shortly."
+
+// maintenanceRetryAfter is the Retry-After sent with a 503, in seconds
+const maintenanceRetryAfter = "300"
+
+// NewMaintenanceMode creates a disabled maintenance mode. page renders the
+// page shown to turned-away browsers; nil sends the message as plain text.
+func NewMaintenanceMode(page func(w http.ResponseWriter, r *http.Request, message string)) *MaintenanceMode {
+	if page == nil {
+		page = func(w http.ResponseWriter, r *http.Request, message string) {
+			http.Error(w, message, http.StatusServiceUnavailable)
+		}
+	}
+	return &MaintenanceMode{page: page}
+}
+
+// Set enables or disables maintenance mode
+func (m *MaintenanceMode) Set(enabled bool, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enabled = enabled
+	m.message = message
+}
+
+// Status reports whether maintenance mode is enabled, and its message
+func (m *MaintenanceMode) Status() (enabled bool, message string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled, m.message
+}
+
+// Middleware turns away every request allow doesn't let through while
+// maintenance mode is enabled. allow runs only then, so it may be costly.
+func (m *MaintenanceMode) Middleware(allow func(r *http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			enabled, message := m.Status()
+			if !enabled || (allow != nil && allow(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if message == "" {
+				message = DefaultMaintenanceMessage
+			}
+
+			w.Header().Set("Retry-After", maintenanceRetryAfter)
+			w.Header().Set("Cache-Control", "no-store")
+			if strings.HasPrefix(r.URL.Path, "/api/") || r.Header.Get("HX-Request") != "" {
+				respond.Error(w, message, http.StatusServiceUnavailable)
+				return
+			}
+			m.page(w, r, message)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	m := NewMaintenanceMode(func(w http.ResponseWriter, r *http.Request, message string) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("<p>" + message + "</p>"))
+	})
+	handler := m.Middleware(func(r *http.Request) bool {
+		return r.Header.Get("X-Admin") != ""
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	serve := func(path string, admin bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if admin {
+			req.Header.Set("X-Admin", "1")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("/dashboard", false); rec.Code != http.StatusOK {
+		t.Fatalf("Disabled: status = %d, want 200", rec.Code)
+	}
+
+	m.Set(true, "")
+	rec := serve("/dashboard", false)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), DefaultMaintenanceMessage) {
+		t.Errorf("Page: status = %d, body = %q", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Page: no Retry-After")
+	}
+
+	m.Set(true, "Back at noon")
+	rec = serve("/api/injections", false)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"service_unavailable"`) ||
+		!strings.Contains(rec.Body.String(), "Back at noon") {
+		t.Errorf("API: status = %d, body = %q", rec.Code, rec.Body)
+	}
+
+	if rec := serve("/dashboard", true); rec.Code != http.StatusOK {
+		t.Errorf("Allowed request: status = %d, want 200", rec.Code)
+	}
+
+	m.Set(false, "Back at noon")
+	if rec := serve("/api/injections", false); rec.Code != http.StatusOK {
+		t.Errorf("Disabled again: status = %d, want 200", rec.Code)
+	}
+}
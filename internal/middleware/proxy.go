@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// forwardingHeaders are the headers a proxy uses to describe the original
+// request. Only trusted proxies get to set them.
+var forwardingHeaders = append([]string{"X-Forwarded-For", "X-Real-IP", "X-Forwarded-Proto"}, countryHeaders...)
+
+// countryHeaders are where proxies and CDNs that look up the client's
+// country put it: Cloudflare, CloudFront, and a generic one for nginx with
+// a GeoIP module
+var countryHeaders = []string{"CF-IPCountry", "CloudFront-Viewer-Country", "X-Country-Code"}
+
+// TrustedProxies is the set of networks whose forwarding headers are
+// believed
+type TrustedProxies struct {
+	mu   sync.RWMutex
+	nets []*net.IPNet
+}
+
+// ParseTrustedProxies reads a list of CIDRs and bare IP addresses
+func ParseTrustedProxies(list []string) (*TrustedProxies, error) {
+	t := &TrustedProxies{}
+	for _, entry := range list {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			t.nets = append(t.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		t.nets = append(t.nets, n)
+	}
+	return t, nil
+}
+
+// Replace trusts the networks in other instead, for a configuration reload
+func (t *TrustedProxies) Replace(other *TrustedProxies) {
+	other.mu.RLock()
+	nets := other.nets
+	other.mu.RUnlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nets = nets
+}
+
+// Contains reports whether ip is a trusted proxy
+func (t *TrustedProxies) Contains(ip net.IP) bool {
+	if t == nil || ip == nil {
+		return false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, n := range t.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP sets r.RemoteAddr to the client's address when the request came
+// through a trusted proxy. X-Forwarded-For is read from the right, skipping
+// trusted proxies, so addresses a client prepends itself are ignored. From
+// anyone else the forwarding headers are removed, so nothing further down
+// can be fooled by them.
+func RealIP(trusted *TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !trusted.Contains(net.ParseIP(ClientIP(r))) {
+				for _, h := range forwardingHeaders {
+					r.Header.Del(h)
+				}
+			} else if ip := forwardedClient(r, trusted); ip != "" {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClient is the client address a trusted proxy reported, or ""
+func forwardedClient(r *http.Request, trusted *TrustedProxies) string {
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// Whatever is left of a malformed entry can't be trusted
+			return ""
+		}
+		if !trusted.Contains(ip) || i == 0 {
+			return ip.String()
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// ClientIP is the client's address without the port. Behind RealIP it is
+// the address a trusted proxy reported.
+func ClientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// ClientCountry is the ISO 3166 country code a trusted proxy looked up for
+// the client, or "" if there is none. P-TRACK has no GeoIP database of its
+// own.
+func ClientCountry(r *http.Request) string {
+	for _, h := range countryHeaders {
+		code := strings.ToUpper(strings.TrimSpace(r.Header.Get(h)))
+		// XX and T1 are Cloudflare's unknown and Tor
+		if len(code) == 2 && code != "XX" && code != "T1" {
+			return code
+		}
+	}
+	return ""
+}
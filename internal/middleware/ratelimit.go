@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"injection-tracker/internal/respond"
+)
+
+// RateLimitStore counts requests in fixed windows. The in-memory store is
+// private to one process; a shared store (the database) keeps counts across
+// restarts and between replicas.
+type RateLimitStore interface {
+	// Hit counts a request against key and returns how many requests key
+	// has made in its current window, this one included, and when the window
+	// ends. A window starts with the first request after the last one ended.
+	Hit(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error)
+}
+
+// RateLimitPolicy is how many requests a client may make per window. Name
+// keeps the counts of policies apart; Key decides who the client is.
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	Key    func(*http.Request) string
+}
+
+// RateLimitByIP limits each client address
+func RateLimitByIP(r *http.Request) string {
+	return "ip:" + getIP(r)
+}
+
+// RateLimitByUser limits each signed-in user, wherever they connect from.
+// Anonymous requests are limited by address.
+func RateLimitByUser(r *http.Request) string {
+	if userID := GetUserID(r.Context()); userID != 0 {
+		return "user:" + strconv.FormatInt(userID, 10)
+	}
+	return RateLimitByIP(r)
+}
+
+// RateLimiter enforces a RateLimitPolicy and reports it to clients in the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers
+type RateLimiter struct {
+	mu     sync.RWMutex
+	policy RateLimitPolicy
+	store  RateLimitStore
+}
+
+// NewRateLimiter limits each IP address to requestsPerWindow requests per
+// window, counted in memory
+func NewRateLimiter(requestsPerWindow int, window time.Duration) *RateLimiter {
+	return NewRateLimiterWithStore(RateLimitPolicy{
+		Name:   "default",
+		Limit:  requestsPerWindow,
+		Window: window,
+		Key:    RateLimitByIP,
+	}, NewMemoryRateLimitStore())
+}
+
+// NewRateLimiterWithStore enforces policy with counts kept in store
+func NewRateLimiterWithStore(policy RateLimitPolicy, store RateLimitStore) *RateLimiter {
+	if policy.Key == nil {
+		policy.Key = RateLimitByIP
+	}
+	return &RateLimiter{policy: policy, store: store}
+}
+
+// SetLimit changes how many requests the policy allows per window. Counts
+// already made carry over.
+func (rl *RateLimiter) SetLimit(limit int, window time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.policy.Limit = limit
+	rl.policy.Window = window
+}
+
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rl.mu.RLock()
+		policy := rl.policy
+		rl.mu.RUnlock()
+
+		now := time.Now()
+		key := policy.Name + ":" + policy.Key(r)
+		count, reset, err := rl.store.Hit(r.Context(), key, policy.Window, now)
+		if err != nil {
+			// A broken store shouldn't take the whole site down with it
+			Log(r.Context()).Warn("Rate limit store failed", "policy", policy.Name, "err", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		resetSeconds := int(math.Ceil(reset.Sub(now).Seconds()))
+		if resetSeconds < 0 {
+			resetSeconds = 0
+		}
+		remaining := policy.Limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(resetSeconds))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Window.Seconds())))
+
+		if count > policy.Limit {
+			h.Set("Retry-After", strconv.Itoa(resetSeconds))
+			respond.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// memoryRateLimitStore keeps counts in this process
+type memoryRateLimitStore struct {
+	mu       sync.Mutex
+	windows  map[string]*rateLimitWindow
+	prunedAt time.Time
+}
+
+type rateLimitWindow struct {
+	hits int
+	end  time.Time
+}
+
+// memoryPruneInterval is how often expired windows are dropped
+const memoryPruneInterval = 5 * time.Minute
+
+// NewMemoryRateLimitStore returns a store that counts in memory. Counts are
+// lost on restart and aren't shared with other processes.
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{windows: make(map[string]*rateLimitWindow)}
+}
+
+func (s *memoryRateLimitStore) Hit(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.prunedAt) >= memoryPruneInterval {
+		for k, w := range s.windows {
+			if !w.end.After(now) {
+				delete(s.windows, k)
+			}
+		}
+		s.prunedAt = now
+	}
+
+	w, ok := s.windows[key]
+	if !ok || !w.end.After(now) {
+		w = &rateLimitWindow{end: now.Add(window)}
+		s.windows[key] = w
+	}
+	w.hits++
+	return w.hits, w.end, nil
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Headers(t *testing.T) {
+	limiter := NewRateLimiter(2, time.Minute)
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i, want := range []string{"1", "0"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200, got %d", i+1, w.Code)
+		}
+		if got := w.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("Request %d: expected RateLimit-Limit 2, got %q", i+1, got)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != want {
+			t.Errorf("Request %d: expected RateLimit-Remaining %s, got %q", i+1, want, got)
+		}
+		if got := w.Header().Get("RateLimit-Reset"); got != "60" {
+			t.Errorf("Request %d: expected RateLimit-Reset 60, got %q", i+1, got)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.168.1.1:12345"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got %q", got)
+	}
+}
+
+func TestRateLimiter_ByUser(t *testing.T) {
+	limiter := NewRateLimiterWithStore(RateLimitPolicy{
+		Name:   "export",
+		Limit:  1,
+		Window: time.Minute,
+		Key:    RateLimitByUser,
+	}, NewMemoryRateLimitStore())
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(userID int64, addr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = addr
+		if userID != 0 {
+			req = req.WithContext(context.WithValue(req.Context(), UserContextKey, &UserContext{UserID: userID}))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request(1, "10.0.0.1:1"); code != http.StatusOK {
+		t.Fatalf("Expected the first request to pass, got %d", code)
+	}
+	// The same user from somewhere else shares the budget
+	if code := request(1, "10.0.0.2:1"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected user 1 to be limited from another address, got %d", code)
+	}
+	// Another user from the same address doesn't
+	if code := request(2, "10.0.0.1:1"); code != http.StatusOK {
+		t.Errorf("Expected user 2 to have their own budget, got %d", code)
+	}
+	// Anonymous requests fall back to the address
+	if code := request(0, "10.0.0.1:1"); code != http.StatusOK {
+		t.Errorf("Expected an anonymous request to be limited by address, got %d", code)
+	}
+}
+
+func TestRateLimiter_PoliciesAreSeparate(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	login := NewRateLimiterWithStore(RateLimitPolicy{Name: "login", Limit: 1, Window: time.Minute}, store).Middleware(ok)
+	public := NewRateLimiterWithStore(RateLimitPolicy{Name: "public", Limit: 1, Window: time.Minute}, store).Middleware(ok)
+
+	for _, h := range []http.Handler{login, public} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected each policy to count separately, got %d", w.Code)
+		}
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Hit(context.Context, string, time.Duration, time.Time) (int, time.Time, error) {
+	return 0, time.Time{}, errors.New("database is locked")
+}
+
+func TestRateLimiter_StoreFailureAllows(t *testing.T) {
+	limiter := NewRateLimiterWithStore(RateLimitPolicy{Name: "public", Limit: 1, Window: time.Minute}, failingRateLimitStore{})
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected requests to pass when the store fails, got %d", w.Code)
+	}
+}
+
+func TestMemoryRateLimitStore_Window(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	ctx := context.Background()
+	start := time.Now()
+
+	store.Hit(ctx, "k", time.Minute, start)
+	hits, reset, _ := store.Hit(ctx, "k", time.Minute, start.Add(30*time.Second))
+	if hits != 2 || !reset.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected 2 hits in a window ending at %v, got %d ending %v", start.Add(time.Minute), hits, reset)
+	}
+
+	hits, reset, _ = store.Hit(ctx, "k", time.Minute, start.Add(time.Minute))
+	if hits != 1 || !reset.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Expected a new window once the last ended, got %d ending %v", hits, reset)
+	}
+}
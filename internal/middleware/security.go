@@ -1,43 +1,66 @@
 package middleware
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
-	"golang.org/x/time/rate"
+	"injection-tracker/internal/respond"
 )
 
+// CSPReportPath is where browsers send Content Security Policy violation
+// reports
+const CSPReportPath = "/api/v1/csp-report"
+
+const cspNonceKey contextKey = "csp_nonce"
+
+// CSPNonce returns the nonce inline <script> and <style> blocks in the
+// response must carry, or "" when the policy is off
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey).(string)
+	return nonce
+}
+
 // SecurityHeaders adds security headers to all responses
 func SecurityHeaders(cspEnabled, hstsEnabled bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Content Security Policy
 			if cspEnabled {
-				// Generate nonce for inline scripts
+				// Inline scripts and style blocks only run with this
+				// request's nonce. style attributes are still allowed, as
+				// Alpine and Chart.js set them; 'unsafe-eval' is for
+				// Alpine's and htmx's expressions.
 				nonce := generateNonce()
-				// Note: Nonce can be added to context in production
-				// ctx := context.WithValue(r.Context(), cspNonceKey, nonce)
-				// r = r.WithContext(ctx)
+				r = r.WithContext(context.WithValue(r.Context(), cspNonceKey, nonce))
 
 				csp := fmt.Sprintf(
 					"default-src 'self'; "+
-						"script-src 'self' 'unsafe-inline' 'unsafe-eval' 'nonce-%s' https://unpkg.com https://cdn.jsdelivr.net; "+
-						"style-src 'self' 'unsafe-inline' https://unpkg.com https://cdn.jsdelivr.net https://fonts.googleapis.com; "+
+						"script-src 'self' 'nonce-%[1]s' 'unsafe-eval' https://unpkg.com https://cdn.jsdelivr.net; "+
+						"style-src 'self' 'nonce-%[1]s' https://unpkg.com https://cdn.jsdelivr.net https://fonts.googleapis.com; "+
+						"style-src-attr 'unsafe-inline'; "+
 						"img-src 'self' data: https:; "+
 						"font-src 'self' data: https://fonts.gstatic.com; "+
 						"connect-src 'self'; "+
 						"frame-ancestors 'none'; "+
 						"base-uri 'self'; "+
-						"form-action 'self'",
-					nonce,
+						"form-action 'self'; "+
+						"object-src 'none'; "+
+						"report-uri %[2]s; "+
+						"report-to csp",
+					nonce, CSPReportPath,
 				)
 				w.Header().Set("Content-Security-Policy", csp)
+				w.Header().Set("Reporting-Endpoints", fmt.Sprintf(`csp="%s"`, CSPReportPath))
 			}
 
 			// HTTP Strict Transport Security
@@ -57,8 +80,9 @@ func SecurityHeaders(cspEnabled, hstsEnabled bool) func(http.Handler) http.Handl
 			// Referrer-Policy
 			w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
 
-			// Permissions-Policy
-			w.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
+			// Permissions-Policy: the camera is allowed for our own pages so
+			// restocks can be scanned
+			w.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=(self)")
 
 			// Remove server header
 			w.Header().Set("X-Powered-By", "")
@@ -69,27 +93,52 @@ func SecurityHeaders(cspEnabled, hstsEnabled bool) func(http.Handler) http.Handl
 	}
 }
 
-// CSRF protection middleware
+// CSRFCookieName is the cookie holding the browser's CSRF session
+const CSRFCookieName = "csrf_session"
+
+const csrfSessionKey contextKey = "csrf_session"
+
+// CSRFOptions configure a CSRFProtection. The zero value gives tokens a
+// day to live and a cookie for the host the request was made to.
+type CSRFOptions struct {
+	// TokenTTL is how long a token is good for. A request whose token is
+	// more than halfway there gets a fresh one in the X-CSRF-Token header.
+	TokenTTL time.Duration
+	// CookieDomain is the Domain of the session cookie, for a site served
+	// from several subdomains
+	CookieDomain string
+}
+
+// CSRFProtection checks requests that change data came from our own pages,
+// with per-session tokens. Each browser gets a random session in the
+// csrf_session cookie, and a token is that session and the signed-in user
+// signed with the secret and the time it was issued. Nothing is stored, so
+// every open tab can hold its own token, a retried request can send the
+// same one again, and tokens survive restarts and work on every server
+// sharing the secret.
+//
+// Requests signed in with an Authorization header rather than the auth
+// cookie are exempt: a browser never adds that header by itself, so it
+// can't be forged from another site.
 type CSRFProtection struct {
-	secret string
-	tokens sync.Map // map[string]time.Time for token expiration
+	secret []byte
+	opts   CSRFOptions
 }
 
-func NewCSRFProtection(secret string) *CSRFProtection {
-	csrf := &CSRFProtection{
-		secret: secret,
+func NewCSRFProtection(secret string, opts CSRFOptions) *CSRFProtection {
+	if opts.TokenTTL <= 0 {
+		opts.TokenTTL = 24 * time.Hour
 	}
-
-	// Start cleanup goroutine
-	go csrf.cleanupExpiredTokens()
-
-	return csrf
+	return &CSRFProtection{secret: []byte(secret), opts: opts}
 }
 
 func (c *CSRFProtection) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip CSRF for GET, HEAD, OPTIONS (safe methods)
-		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		session := c.session(w, r)
+		r = r.WithContext(context.WithValue(r.Context(), csrfSessionKey, session))
+
+		// Skip CSRF for GET, HEAD, OPTIONS (safe methods) and API clients
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions || TokenAuthenticated(r.Context()) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -101,143 +150,103 @@ func (c *CSRFProtection) Middleware(next http.Handler) http.Handler {
 		}
 
 		// Validate token
-		if !c.ValidateToken(token) {
-			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+		issued, ok := c.check(r, token)
+		if !ok {
+			respond.ErrorWithCode(w, http.StatusForbidden, respond.CodeCSRFInvalid, "Invalid CSRF token")
 			return
 		}
 
+		// Slide the expiry for pages left open, so they never hit it
+		if time.Since(issued) > c.opts.TokenTTL/2 {
+			w.Header().Set("X-CSRF-Token", c.GenerateToken(r))
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (c *CSRFProtection) GenerateToken() string {
+// session returns the browser's CSRF session, starting one if it has none
+func (c *CSRFProtection) session(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(CSRFCookieName); err == nil && len(cookie.Value) >= 16 && len(cookie.Value) <= 64 {
+		return cookie.Value
+	}
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
 		panic(err)
 	}
-	token := base64.URLEncoding.EncodeToString(b)
-
-	// Store token with expiration
-	c.tokens.Store(token, time.Now().Add(24*time.Hour))
-
-	return token
+	session := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    session,
+		Path:     "/",
+		Domain:   c.opts.CookieDomain,
+		HttpOnly: true,
+		Secure:   SecureCookie(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return session
 }
 
-func (c *CSRFProtection) ValidateToken(token string) bool {
-	if token == "" {
-		return false
-	}
-
-	expiry, ok := c.tokens.Load(token)
-	if !ok {
-		return false
+// sessionOf is the CSRF session of r, from Middleware or the cookie
+func sessionOf(r *http.Request) string {
+	if session, ok := r.Context().Value(csrfSessionKey).(string); ok {
+		return session
 	}
-
-	expiryTime, ok := expiry.(time.Time)
-	if !ok || time.Now().After(expiryTime) {
-		c.tokens.Delete(token)
-		return false
+	if cookie, err := r.Cookie(CSRFCookieName); err == nil {
+		return cookie.Value
 	}
-
-	// Token is valid - keep it for reuse within expiration window (SPA-friendly)
-	return true
+	return ""
 }
 
-func (c *CSRFProtection) cleanupExpiredTokens() {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		now := time.Now()
-		c.tokens.Range(func(key, value interface{}) bool {
-			if expiry, ok := value.(time.Time); ok && now.After(expiry) {
-				c.tokens.Delete(key)
-			}
-			return true
-		})
+// GenerateToken returns a token for the session and user of r, or "" if r
+// has no CSRF session
+func (c *CSRFProtection) GenerateToken(r *http.Request) string {
+	session := sessionOf(r)
+	if session == "" {
+		return ""
 	}
+	issued := strconv.FormatInt(time.Now().Unix(), 10)
+	return issued + "." + c.sign(session, GetUserID(r.Context()), issued)
 }
 
-// RateLimiter implements rate limiting per IP address
-type RateLimiter struct {
-	visitors map[string]*rate.Limiter
-	mu       sync.RWMutex
-	rate     rate.Limit
-	burst    int
+// ValidateToken reports whether token was issued to the session and user of
+// r and hasn't expired
+func (c *CSRFProtection) ValidateToken(r *http.Request, token string) bool {
+	_, ok := c.check(r, token)
+	return ok
 }
 
-func NewRateLimiter(requestsPerWindow int, window time.Duration) *RateLimiter {
-	rl := &RateLimiter{
-		visitors: make(map[string]*rate.Limiter),
-		rate:     rate.Limit(float64(requestsPerWindow) / window.Seconds()),
-		burst:    requestsPerWindow,
+func (c *CSRFProtection) check(r *http.Request, token string) (time.Time, bool) {
+	session := sessionOf(r)
+	issued, sig, found := strings.Cut(token, ".")
+	if session == "" || !found {
+		return time.Time{}, false
 	}
-
-	// Start cleanup goroutine
-	go rl.cleanupVisitors()
-
-	return rl
-}
-
-func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getIP(r)
-		limiter := rl.getLimiter(ip)
-
-		if !limiter.Allow() {
-			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
-func (rl *RateLimiter) getLimiter(ip string) *rate.Limiter {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	limiter, exists := rl.visitors[ip]
-	if !exists {
-		limiter = rate.NewLimiter(rl.rate, rl.burst)
-		rl.visitors[ip] = limiter
+	unix, err := strconv.ParseInt(issued, 10, 64)
+	if err != nil {
+		return time.Time{}, false
 	}
-
-	return limiter
+	at := time.Unix(unix, 0)
+	now := time.Now()
+	if now.After(at.Add(c.opts.TokenTTL)) || at.After(now.Add(time.Minute)) {
+		return time.Time{}, false
+	}
+	if !SecureCompare(sig, c.sign(session, GetUserID(r.Context()), issued)) {
+		return time.Time{}, false
+	}
+	return at, true
 }
 
-func (rl *RateLimiter) cleanupVisitors() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		rl.mu.Lock()
-		// Remove visitors that haven't made requests recently
-		for ip := range rl.visitors {
-			delete(rl.visitors, ip)
-		}
-		rl.mu.Unlock()
-	}
+func (c *CSRFProtection) sign(session string, userID int64, issued string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	fmt.Fprintf(mac, "%s|%d|%s", session, userID, issued)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
 }
 
-// getIP extracts the real IP address from the request
+// getIP is the address rate limits are keyed on. Forwarding headers have
+// already been applied, or removed, by RealIP.
 func getIP(r *http.Request) string {
-	// Check X-Forwarded-For header (if behind proxy)
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		// Take the first IP
-		ips := strings.Split(forwarded, ",")
-		return strings.TrimSpace(ips[0])
-	}
-
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
-	}
-
-	// Fallback to RemoteAddr
-	return r.RemoteAddr
+	return ClientIP(r)
 }
 
 // generateNonce generates a random nonce for CSP
@@ -249,6 +258,36 @@ func generateNonce() string {
 	return base64.StdEncoding.EncodeToString(b)
 }
 
+// cookieSecure is how SecureCookie decides; see SetCookieSecure
+var cookieSecure atomic.Value
+
+// SetCookieSecure sets whether cookies are marked Secure: "always",
+// "never", or "auto" to follow the scheme of each request. Always would
+// break logins on plain-HTTP development servers, where browsers drop
+// Secure cookies.
+func SetCookieSecure(mode string) {
+	cookieSecure.Store(mode)
+}
+
+// SecureCookie reports whether cookies set in response to r should be
+// marked Secure
+func SecureCookie(r *http.Request) bool {
+	mode, _ := cookieSecure.Load().(string)
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return IsHTTPS(r)
+}
+
+// IsHTTPS reports whether r came in over HTTPS, either directly or through
+// a proxy that says so in X-Forwarded-Proto
+func IsHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
 // SecureCompare performs constant-time comparison of two strings
 func SecureCompare(a, b string) bool {
 	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
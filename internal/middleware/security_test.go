@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -46,6 +49,42 @@ func TestSecurityHeaders(t *testing.T) {
 	}
 }
 
+func TestSecurityHeaders_CSPNonce(t *testing.T) {
+	var nonces []string
+	handler := SecurityHeaders(true, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, CSPNonce(r.Context()))
+	}))
+
+	var policies []string
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		policies = append(policies, w.Header().Get("Content-Security-Policy"))
+		if w.Header().Get("Reporting-Endpoints") != `csp="`+CSPReportPath+`"` {
+			t.Errorf("Reporting-Endpoints = %q", w.Header().Get("Reporting-Endpoints"))
+		}
+	}
+
+	if nonces[0] == "" || nonces[0] == nonces[1] {
+		t.Fatalf("expected a fresh nonce for each request, got %q", nonces)
+	}
+	for i, policy := range policies {
+		if !strings.Contains(policy, "'nonce-"+nonces[i]+"'") {
+			t.Errorf("CSP %q does not allow the request's nonce %q", policy, nonces[i])
+		}
+		for _, directive := range strings.Split(policy, ";") {
+			directive = strings.TrimSpace(directive)
+			if (strings.HasPrefix(directive, "script-src ") || strings.HasPrefix(directive, "style-src ")) &&
+				strings.Contains(directive, "'unsafe-inline'") {
+				t.Errorf("%q still allows inline code", directive)
+			}
+		}
+		if !strings.Contains(policy, "report-uri "+CSPReportPath) {
+			t.Errorf("CSP %q has no report-uri", policy)
+		}
+	}
+}
+
 func TestSecurityHeaders_CSPDisabled(t *testing.T) {
 	handler := SecurityHeaders(false, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -73,7 +112,7 @@ func TestSecurityHeaders_CSPDisabled(t *testing.T) {
 }
 
 func TestCSRFProtection_SafeMethods(t *testing.T) {
-	csrf := NewCSRFProtection("test-secret")
+	csrf := NewCSRFProtection("test-secret", CSRFOptions{})
 
 	safeMethods := []string{http.MethodGet, http.MethodHead, http.MethodOptions}
 
@@ -96,7 +135,7 @@ func TestCSRFProtection_SafeMethods(t *testing.T) {
 }
 
 func TestCSRFProtection_UnsafeMethodsWithoutToken(t *testing.T) {
-	csrf := NewCSRFProtection("test-secret")
+	csrf := NewCSRFProtection("test-secret", CSRFOptions{})
 
 	unsafeMethods := []string{http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch}
 
@@ -122,11 +161,19 @@ func TestCSRFProtection_UnsafeMethodsWithoutToken(t *testing.T) {
 	}
 }
 
+// csrfRequest is a request from the browser with the given CSRF session
+func csrfRequest(method, body, session string) *http.Request {
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: session})
+	return req
+}
+
 func TestCSRFProtection_ValidToken(t *testing.T) {
-	csrf := NewCSRFProtection("test-secret")
+	csrf := NewCSRFProtection("test-secret", CSRFOptions{})
+	session := "session-0123456789abcdef"
 
 	// Generate token
-	token := csrf.GenerateToken()
+	token := csrf.GenerateToken(csrfRequest(http.MethodGet, "", session))
 
 	handler := csrf.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -134,7 +181,7 @@ func TestCSRFProtection_ValidToken(t *testing.T) {
 
 	// Test with token in header
 	t.Run("Token in header", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req := csrfRequest(http.MethodPost, "", session)
 		req.Header.Set("X-CSRF-Token", token)
 		w := httptest.NewRecorder()
 
@@ -145,12 +192,9 @@ func TestCSRFProtection_ValidToken(t *testing.T) {
 		}
 	})
 
-	// Generate new token for form test (tokens are one-time use)
-	token = csrf.GenerateToken()
-
 	// Test with token in form
 	t.Run("Token in form", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("csrf_token="+token))
+		req := csrfRequest(http.MethodPost, "csrf_token="+token, session)
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		w := httptest.NewRecorder()
 
@@ -160,16 +204,29 @@ func TestCSRFProtection_ValidToken(t *testing.T) {
 			t.Errorf("Expected status 200 with valid token, got %d", w.Code)
 		}
 	})
+
+	// A token is only good for the session it was issued to
+	t.Run("Token from another session", func(t *testing.T) {
+		req := csrfRequest(http.MethodPost, "", "session-fedcba9876543210")
+		req.Header.Set("X-CSRF-Token", token)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 with another session's token, got %d", w.Code)
+		}
+	})
 }
 
 func TestCSRFProtection_InvalidToken(t *testing.T) {
-	csrf := NewCSRFProtection("test-secret")
+	csrf := NewCSRFProtection("test-secret", CSRFOptions{})
 
 	handler := csrf.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
-	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req := csrfRequest(http.MethodPost, "", "session-0123456789abcdef")
 	req.Header.Set("X-CSRF-Token", "invalid-token")
 	w := httptest.NewRecorder()
 
@@ -181,56 +238,117 @@ func TestCSRFProtection_InvalidToken(t *testing.T) {
 }
 
 func TestCSRFProtection_TokenExpiration(t *testing.T) {
-	csrf := NewCSRFProtection("test-secret")
-
-	// Generate token
-	token := csrf.GenerateToken()
-
-	// Manually expire the token
-	csrf.tokens.Store(token, time.Now().Add(-25*time.Hour))
+	csrf := NewCSRFProtection("test-secret", CSRFOptions{TokenTTL: time.Hour})
+	session := "session-0123456789abcdef"
 
 	handler := csrf.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
+	post := func(issued time.Time) *httptest.ResponseRecorder {
+		unix := strconv.FormatInt(issued.Unix(), 10)
+		req := csrfRequest(http.MethodPost, "", session)
+		req.Header.Set("X-CSRF-Token", unix+"."+csrf.sign(session, 0, unix))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
 
-	req := httptest.NewRequest(http.MethodPost, "/", nil)
-	req.Header.Set("X-CSRF-Token", token)
-	w := httptest.NewRecorder()
-
-	handler.ServeHTTP(w, req)
-
-	if w.Code != http.StatusForbidden {
+	if w := post(time.Now().Add(-61 * time.Minute)); w.Code != http.StatusForbidden {
 		t.Errorf("Expected status 403 with expired token, got %d", w.Code)
 	}
+
+	// Past half its life, the token still works and a new one comes back
+	w := post(time.Now().Add(-45 * time.Minute))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 with an old token, got %d", w.Code)
+	}
+	fresh := w.Header().Get("X-CSRF-Token")
+	if !csrf.ValidateToken(csrfRequest(http.MethodPost, "", session), fresh) {
+		t.Errorf("Expected a fresh token in the response, got %q", fresh)
+	}
+	if w := post(time.Now()); w.Header().Get("X-CSRF-Token") != "" {
+		t.Error("A new token shouldn't be replaced")
+	}
 }
 
 func TestCSRFProtection_TokenReusable(t *testing.T) {
-	csrf := NewCSRFProtection("test-secret")
+	csrf := NewCSRFProtection("test-secret", CSRFOptions{})
+	session := "session-0123456789abcdef"
 
-	token := csrf.GenerateToken()
+	// Tokens from two tabs, and the same one sent twice by a retry
+	tab1 := csrf.GenerateToken(csrfRequest(http.MethodGet, "", session))
+	tab2 := csrf.GenerateToken(csrfRequest(http.MethodGet, "", session))
 
 	handler := csrf.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
-	// First use should succeed
-	req1 := httptest.NewRequest(http.MethodPost, "/", nil)
-	req1.Header.Set("X-CSRF-Token", token)
-	w1 := httptest.NewRecorder()
-	handler.ServeHTTP(w1, req1)
+	for i, token := range []string{tab1, tab2, tab1, tab2} {
+		req := csrfRequest(http.MethodPost, "", session)
+		req.Header.Set("X-CSRF-Token", token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Request %d: expected status 200, got %d", i+1, w.Code)
+		}
+	}
+}
+
+func TestCSRFProtection_Session(t *testing.T) {
+	csrf := NewCSRFProtection("test-secret", CSRFOptions{CookieDomain: "example.com"})
+
+	var token string
+	handler := csrf.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = csrf.GenerateToken(r)
+	}))
+
+	// A browser without a session is given one, and a token for it
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != CSRFCookieName || cookies[0].Domain != "example.com" || !cookies[0].HttpOnly {
+		t.Fatalf("Expected an HttpOnly session cookie for example.com, got %v", cookies)
+	}
+	if !csrf.ValidateToken(csrfRequest(http.MethodPost, "", cookies[0].Value), token) {
+		t.Error("Expected the token to be valid for the new session")
+	}
+
+	// The same session is kept after that
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, csrfRequest(http.MethodGet, "", cookies[0].Value))
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("Expected the existing session to be kept")
+	}
 
-	if w1.Code != http.StatusOK {
-		t.Errorf("Expected status 200 on first use, got %d", w1.Code)
+	// A token is for one user as well as one session
+	req := csrfRequest(http.MethodPost, "", cookies[0].Value)
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, &UserContext{UserID: 7}))
+	if csrf.ValidateToken(req, token) {
+		t.Error("Expected a token issued before sign-in to be refused for a user")
 	}
+}
 
-	// Second use should also succeed (tokens are reusable until expiry for SPA support)
-	req2 := httptest.NewRequest(http.MethodPost, "/", nil)
-	req2.Header.Set("X-CSRF-Token", token)
-	w2 := httptest.NewRecorder()
-	handler.ServeHTTP(w2, req2)
+func TestCSRFProtection_TokenAuthExempt(t *testing.T) {
+	csrf := NewCSRFProtection("test-secret", CSRFOptions{})
+
+	handler := csrf.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
 
-	if w2.Code != http.StatusOK {
-		t.Errorf("Expected status 200 on second use (reusable token), got %d", w2.Code)
+	for _, tokenAuth := range []bool{true, false} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), UserContextKey, &UserContext{UserID: 1, TokenAuth: tokenAuth}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		want := http.StatusForbidden
+		if tokenAuth {
+			want = http.StatusOK
+		}
+		if w.Code != want {
+			t.Errorf("TokenAuth %v: expected status %d, got %d", tokenAuth, want, w.Code)
+		}
 	}
 }
 
@@ -343,7 +461,12 @@ func TestRateLimiter_XForwardedFor(t *testing.T) {
 	}
 }
 
-func TestGetIP(t *testing.T) {
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"192.168.0.0/16", "10.0.0.3"})
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+
 	tests := []struct {
 		name          string
 		remoteAddr    string
@@ -354,7 +477,7 @@ func TestGetIP(t *testing.T) {
 		{
 			name:       "RemoteAddr only",
 			remoteAddr: "192.168.1.1:12345",
-			expectedIP: "192.168.1.1:12345",
+			expectedIP: "192.168.1.1",
 		},
 		{
 			name:          "X-Forwarded-For single",
@@ -363,10 +486,10 @@ func TestGetIP(t *testing.T) {
 			expectedIP:    "10.0.0.1",
 		},
 		{
-			name:          "X-Forwarded-For multiple",
+			name:          "X-Forwarded-For skips trusted hops from the right",
 			remoteAddr:    "192.168.1.1:12345",
 			xForwardedFor: "10.0.0.1, 10.0.0.2, 10.0.0.3",
-			expectedIP:    "10.0.0.1",
+			expectedIP:    "10.0.0.2",
 		},
 		{
 			name:       "X-Real-IP",
@@ -381,6 +504,18 @@ func TestGetIP(t *testing.T) {
 			xRealIP:       "10.0.0.2",
 			expectedIP:    "10.0.0.1",
 		},
+		{
+			name:          "Untrusted peer can't spoof X-Forwarded-For",
+			remoteAddr:    "203.0.113.9:12345",
+			xForwardedFor: "10.0.0.1",
+			expectedIP:    "203.0.113.9",
+		},
+		{
+			name:       "Untrusted peer can't spoof X-Real-IP",
+			remoteAddr: "203.0.113.9:12345",
+			xRealIP:    "10.0.0.1",
+			expectedIP: "203.0.113.9",
+		},
 	}
 
 	for _, tt := range tests {
@@ -394,7 +529,11 @@ func TestGetIP(t *testing.T) {
 				req.Header.Set("X-Real-IP", tt.xRealIP)
 			}
 
-			ip := getIP(req)
+			var ip string
+			RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ip = getIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
 			if ip != tt.expectedIP {
 				t.Errorf("Expected IP %s, got %s", tt.expectedIP, ip)
 			}
@@ -402,6 +541,57 @@ func TestGetIP(t *testing.T) {
 	}
 }
 
+func TestRealIPStripsUntrustedHeaders(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.9:12345"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("CF-IPCountry", "NZ")
+
+	RealIP(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsHTTPS(r) {
+			t.Error("Expected X-Forwarded-Proto from an untrusted peer to be ignored")
+		}
+		if country := ClientCountry(r); country != "" {
+			t.Errorf("Expected CF-IPCountry from an untrusted peer to be ignored, got %q", country)
+		}
+	})).ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestClientCountry(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"192.168.0.0/16"})
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+	for header, want := range map[string]string{"nz": "NZ", "XX": "", "T1": "", "Nowhere": ""} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		req.Header.Set("CF-IPCountry", header)
+
+		var country string
+		RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			country = ClientCountry(r)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+		if country != want {
+			t.Errorf("ClientCountry with CF-IPCountry %q = %q, want %q", header, country, want)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	if _, err := ParseTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Error("Expected an error for an invalid entry")
+	}
+	trusted, err := ParseTrustedProxies([]string{"::1", "172.16.0.0/12"})
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+	for ip, want := range map[string]bool{"::1": true, "172.20.1.1": true, "172.32.0.1": false, "127.0.0.1": false} {
+		if got := trusted.Contains(net.ParseIP(ip)); got != want {
+			t.Errorf("Contains(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
 func TestSecureCompare(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -507,13 +697,10 @@ func BenchmarkRateLimiter(b *testing.B) {
 }
 
 func BenchmarkCSRFValidation(b *testing.B) {
-	csrf := NewCSRFProtection("test-secret")
+	csrf := NewCSRFProtection("test-secret", CSRFOptions{})
 
-	// Pre-generate tokens
-	tokens := make([]string, b.N)
-	for i := 0; i < b.N; i++ {
-		tokens[i] = csrf.GenerateToken()
-	}
+	session := "session-0123456789abcdef"
+	token := csrf.GenerateToken(csrfRequest(http.MethodGet, "", session))
 
 	handler := csrf.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -521,9 +708,35 @@ func BenchmarkCSRFValidation(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		req := httptest.NewRequest(http.MethodPost, "/", nil)
-		req.Header.Set("X-CSRF-Token", tokens[i])
+		req := csrfRequest(http.MethodPost, "", session)
+		req.Header.Set("X-CSRF-Token", token)
 		w := httptest.NewRecorder()
 		handler.ServeHTTP(w, req)
 	}
 }
+
+func TestSecureCookie(t *testing.T) {
+	defer SetCookieSecure("auto")
+
+	plain := httptest.NewRequest(http.MethodGet, "/", nil)
+	proxied := httptest.NewRequest(http.MethodGet, "/", nil)
+	proxied.Header.Set("X-Forwarded-Proto", "https")
+
+	tests := []struct {
+		mode    string
+		req     *http.Request
+		want    bool
+		comment string
+	}{
+		{"auto", plain, false, "plain HTTP"},
+		{"auto", proxied, true, "HTTPS through a proxy"},
+		{"always", plain, true, "forced on"},
+		{"never", proxied, false, "forced off"},
+	}
+	for _, tt := range tests {
+		SetCookieSecure(tt.mode)
+		if got := SecureCookie(tt.req); got != tt.want {
+			t.Errorf("%s (%s): SecureCookie = %v, want %v", tt.mode, tt.comment, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/respond"
+)
+
+// LatestAPIVersion is the newest API version the server speaks
+const LatestAPIVersion = 1
+
+const apiVersionKey contextKey = "api_version"
+
+// APIVersioning serves /api/v{n}/... from the unversioned /api/... routes and
+// records n in the request context, so handlers only need registering once.
+//
+// Plain /api/... still works as an alias for version 1, or for the version
+// asked for in an API-Version request header, but its responses carry
+// Deprecation and Sunset headers (RFC 9745, RFC 8594) and a Link to the
+// versioned path. Every API response says which version served it in
+// API-Version.
+func APIVersioning(deprecated, sunset time.Time) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rest, ok := strings.CutPrefix(r.URL.Path, "/api/")
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var version int
+			if segment, _, _ := strings.Cut(rest, "/"); isVersionSegment(segment) {
+				version, _ = strconv.Atoi(segment[1:])
+				if version < 1 || version > LatestAPIVersion {
+					respond.Error(w, "Unsupported API version", http.StatusNotFound)
+					return
+				}
+				r = stripPathPrefix(r, "/api/"+segment)
+			} else {
+				version = 1
+				if v := r.Header.Get("API-Version"); v != "" {
+					n, err := strconv.Atoi(strings.TrimPrefix(v, "v"))
+					if err != nil || n < 1 || n > LatestAPIVersion {
+						respond.Error(w, "Unsupported API version", http.StatusBadRequest)
+						return
+					}
+					version = n
+				}
+				w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecated.Unix()))
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+				w.Header().Add("Link", fmt.Sprintf(`</api/v%d/%s>; rel="successor-version"`, version, rest))
+			}
+
+			w.Header().Set("API-Version", strconv.Itoa(version))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey, version)))
+		})
+	}
+}
+
+// APIVersion returns the API version negotiated for the request. Handlers
+// that change shape between versions branch on it; outside the API it is
+// the latest version.
+func APIVersion(r *http.Request) int {
+	if v, ok := r.Context().Value(apiVersionKey).(int); ok {
+		return v
+	}
+	return LatestAPIVersion
+}
+
+// isVersionSegment reports whether a path segment looks like v1, v2, ...
+func isVersionSegment(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'v' {
+		return false
+	}
+	for _, c := range segment[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// stripPathPrefix returns a shallow copy of r with prefix, /api and the
+// version segment, replaced by /api. RawPath, which routers use when the path
+// has escapes such as %2F, gets the same change as Path.
+func stripPathPrefix(r *http.Request, prefix string) *http.Request {
+	strip := func(path string) string {
+		rest := strings.TrimPrefix(path, prefix)
+		if rest == "" {
+			rest = "/"
+		}
+		return "/api" + rest
+	}
+
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.Path = strip(u.Path)
+	if u.RawPath != "" {
+		u.RawPath = strip(u.RawPath)
+	}
+	r2.URL = &u
+	return r2
+}
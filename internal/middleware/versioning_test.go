@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIVersioning(t *testing.T) {
+	deprecated := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	var gotPath string
+	var gotVersion int
+	handler := APIVersioning(deprecated, sunset)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVersion = APIVersion(r)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		status     int
+		wantPath   string
+		deprecated bool
+	}{
+		{"versioned", "/api/v1/injections", "", http.StatusOK, "/api/injections", false},
+		{"alias", "/api/injections", "", http.StatusOK, "/api/injections", true},
+		{"alias with header", "/api/injections", "1", http.StatusOK, "/api/injections", true},
+		{"unknown version", "/api/v9/injections", "", http.StatusNotFound, "", false},
+		{"unknown header version", "/api/injections", "9", http.StatusBadRequest, "", false},
+		{"not a version", "/api/vials/1", "", http.StatusOK, "/api/vials/1", true},
+		{"outside the API", "/dashboard", "", http.StatusOK, "/dashboard", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPath = ""
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("API-Version", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("Expected handler path %q, got %q", tt.wantPath, gotPath)
+			}
+			if tt.status == http.StatusOK && gotVersion != 1 {
+				t.Errorf("Expected version 1, got %d", gotVersion)
+			}
+
+			if tt.deprecated {
+				if got := w.Header().Get("Deprecation"); got != "@1790812800" {
+					t.Errorf("Unexpected Deprecation header %q", got)
+				}
+				if got := w.Header().Get("Sunset"); got != "Thu, 01 Apr 2027 00:00:00 GMT" {
+					t.Errorf("Unexpected Sunset header %q", got)
+				}
+				if got := w.Header().Get("Link"); got != `</api/v1`+tt.path[len("/api"):]+`>; rel="successor-version"` {
+					t.Errorf("Unexpected Link header %q", got)
+				}
+			} else if w.Header().Get("Deprecation") != "" {
+				t.Error("Expected no Deprecation header")
+			}
+		})
+	}
+}
+
+func TestAPIVersioningEscapedPath(t *testing.T) {
+	var got *http.Request
+	handler := APIVersioning(time.Now(), time.Now())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+
+	// An escaped slash keeps the segment whole, so the raw path must be
+	// rewritten along with the decoded one
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/barcodes/ab%2F12", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got == nil {
+		t.Fatal("Expected the request to reach the handler")
+	}
+	if got.URL.Path != "/api/barcodes/ab/12" {
+		t.Errorf("Expected path /api/barcodes/ab/12, got %q", got.URL.Path)
+	}
+	if got.URL.RawPath != "/api/barcodes/ab%2F12" || got.URL.EscapedPath() != "/api/barcodes/ab%2F12" {
+		t.Errorf("Expected raw path /api/barcodes/ab%%2F12, got %q (escaped %q)", got.URL.RawPath, got.URL.EscapedPath())
+	}
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"strings"
 	"time"
 )
 
@@ -29,6 +30,9 @@ type Course struct {
 	ActualEndDate   sql.NullTime
 	IsActive        bool
 	Notes           sql.NullString
+	DoseML          sql.NullFloat64 // Volume per injection; defaults to DefaultDoseML when unset
+	Concentration   sql.NullFloat64 // mg per mL, used to report doses in mg
+	CompoundID      sql.NullInt64   // What is injected; NULL means progesterone (legacy)
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 	CreatedBy       sql.NullInt64
@@ -70,6 +74,115 @@ func (c *Course) DaysActive() int {
 	return int(endDate.Sub(c.StartDate).Hours() / 24)
 }
 
+// DoseStep is one step of a course's taper schedule: the volume to give per
+// injection from StartDate to EndDate, inclusive
+type DoseStep struct {
+	ID        int64
+	CourseID  int64
+	StartDate time.Time
+	EndDate   sql.NullTime // NULL runs until the course ends
+	DoseML    float64
+	CreatedAt time.Time
+}
+
+// Covers reports whether the step applies on day's calendar date
+func (s *DoseStep) Covers(day time.Time) bool {
+	date := day.Format("2006-01-02")
+	if date < s.StartDate.Format("2006-01-02") {
+		return false
+	}
+	return !s.EndDate.Valid || date <= s.EndDate.Time.Format("2006-01-02")
+}
+
+// CourseSchedule is when a course's injections fall due: every
+// IntervalHours, or on Weekdays. Whole-day intervals and weekdays are due at
+// TimeOfDay, or the account's reminder time when it's unset.
+type CourseSchedule struct {
+	CourseID      int64
+	IntervalHours int            // 0 for a weekday schedule
+	Weekdays      []time.Weekday // Sunday first; empty for an interval
+	TimeOfDay     sql.NullString // HH:MM
+	UpdatedAt     time.Time
+}
+
+// weekdayNames are the short names weekdays are stored and sent as
+var weekdayNames = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// WeekdayName is d's short name, e.g. "mon"
+func WeekdayName(d time.Weekday) string {
+	return weekdayNames[d]
+}
+
+// ParseWeekday reads a short weekday name such as "mon"; case doesn't matter
+func ParseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for d, n := range weekdayNames {
+		if n == name {
+			return time.Weekday(d), true
+		}
+	}
+	return 0, false
+}
+
+// MissedInjectionReasons are the reasons a missed dose can be given for
+var MissedInjectionReasons = []string{"clinic_instruction", "travel", "forgot", "side_effects", "out_of_supply", "other"}
+
+// IsMissedInjectionReason reports whether reason is one of
+// MissedInjectionReasons
+func IsMissedInjectionReason(reason string) bool {
+	for _, r := range MissedInjectionReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// MissedInjection is a dose a course's schedule had due that wasn't logged,
+// with why it was skipped once a member has said
+type MissedInjection struct {
+	ID          int64
+	CourseID    int64
+	DueAt       time.Time
+	Reason      sql.NullString // One of MissedInjectionReasons
+	Note        sql.NullString
+	AnnotatedBy sql.NullInt64
+	AnnotatedAt sql.NullTime
+	CreatedAt   time.Time
+
+	// Computed fields (set by repository)
+	CourseName          string
+	AnnotatedByUsername string
+}
+
+// Trip shifts: how injections move onto a trip's clock
+const (
+	TripShiftInstant = "instant" // At once when the trip starts, and back when it ends
+	TripShiftGradual = "gradual" // An hour a day, from the start and again from the end
+)
+
+// Trip is time spent away in another timezone, during which the account's
+// injections fall due on the destination's clock
+type Trip struct {
+	ID           int64
+	AccountID    int64
+	Destination  string // e.g. "Tokyo"
+	Timezone     string // IANA name of the destination's timezone, e.g. "Asia/Tokyo"
+	HomeTimezone string // IANA name of the timezone the trip started from
+	StartsAt     time.Time
+	EndsAt       time.Time
+	Shift        string // TripShiftInstant or TripShiftGradual
+	Notes        sql.NullString
+	CreatedBy    sql.NullInt64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Covers reports whether t falls within the trip
+func (t *Trip) Covers(at time.Time) bool {
+	return !at.Before(t.StartsAt) && at.Before(t.EndsAt)
+}
+
 // Injection represents an injection record
 type Injection struct {
 	ID             int64
@@ -83,7 +196,13 @@ type Injection struct {
 	HasKnots       bool
 	SiteReaction   sql.NullString
 	Notes          sql.NullString
-	AccountID      int64 // Account this injection belongs to
+	DoseML         sql.NullFloat64 // Volume actually injected
+	AttachmentID   sql.NullInt64   // Optional photo of the site
+	LotID          sql.NullInt64   // Medication lot the dose was drawn from
+	AccountID      int64           // Account this injection belongs to
+	VoidedAt       sql.NullTime    // Set when voided; voided injections are kept but not counted
+	VoidedBy       sql.NullInt64
+	VoidReason     sql.NullString
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 }
@@ -98,6 +217,43 @@ func (i *Injection) TimeStr() string {
 	return i.Timestamp.Format("15:04")
 }
 
+// Injection session statuses. Steps can only be recorded while a session
+// is open.
+const (
+	InjectionSessionOpen      = "open"
+	InjectionSessionCompleted = "completed"
+	InjectionSessionCancelled = "cancelled"
+)
+
+// InjectionSession is a guided injection in progress, or the record of one
+type InjectionSession struct {
+	ID               int64
+	AccountID        int64
+	CourseID         int64
+	Status           string
+	StartedBy        sql.NullInt64
+	StartedAt        time.Time
+	WarmingStartedAt sql.NullTime   // Set while the warm-up timer runs
+	WarmingSeconds   int64          // Warming timed so far, not counting a running timer
+	Details          sql.NullString // The injection as entered, as JSON
+	DetailsAt        sql.NullTime
+	InjectionID      sql.NullInt64 // The injection written on completion
+	ClosedAt         sql.NullTime  // When it was completed or cancelled
+	DurationSeconds  sql.NullInt64 // Start to completion
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// WarmingTime is how long the medication has been warmed, including a
+// running timer up to now
+func (s *InjectionSession) WarmingTime(now time.Time) time.Duration {
+	d := time.Duration(s.WarmingSeconds) * time.Second
+	if s.WarmingStartedAt.Valid && now.After(s.WarmingStartedAt.Time) {
+		d += now.Sub(s.WarmingStartedAt.Time).Truncate(time.Second)
+	}
+	return d
+}
+
 // SymptomLog represents a symptom log entry
 type SymptomLog struct {
 	ID           int64
@@ -107,13 +263,36 @@ type SymptomLog struct {
 	PainLevel    sql.NullInt64
 	PainLocation sql.NullString
 	PainType     sql.NullString
-	Symptoms     sql.NullString // JSON array
+	Symptoms     sql.NullString // JSON array of symptom catalog keys
 	Notes        sql.NullString
-	AccountID    int64 // Account this symptom log belongs to
+	AttachmentID sql.NullInt64 // Optional photo of the reaction
+	Visibility   string        // 'shared' or 'private' (only its author sees it)
+	AccountID    int64         // Account this symptom log belongs to
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
 
+// SymptomCatalogItem is a symptom an account's logs can record
+type SymptomCatalogItem struct {
+	ID         int64
+	AccountID  int64
+	Key        string // Stored in symptom_logs.symptoms
+	Name       string
+	Category   string
+	ScaleType  string // 'presence' or 'severity' (rated 1-10)
+	SortOrder  int
+	IsArchived bool // Hidden from new logs but kept for old ones
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// SymptomLogItem is a catalog symptom recorded on a symptom log
+type SymptomLogItem struct {
+	SymptomLogID int64
+	CatalogID    int64
+	Severity     sql.NullInt64 // Only for 'severity' symptoms
+}
+
 // Medication represents a medication
 type Medication struct {
 	ID                int64
@@ -124,15 +303,38 @@ type Medication struct {
 	EndDate           sql.NullTime
 	IsActive          bool
 	Notes             sql.NullString
-	ScheduledTime     sql.NullString // HH:MM format (e.g., "08:00")
+	ScheduledTime     sql.NullString // HH:MM format (e.g., "08:00"); the first of DoseTimes
+	DoseTimes         []string       // HH:MM each day's doses are due, sorted
 	TimeWindowMinutes sql.NullInt64  // Minutes before/after scheduled time
 	ReminderEnabled   bool
+	CatalogID         sql.NullInt64   // Catalog entry the name was picked from
+	InventoryItemType sql.NullString  // Item taken out of stock when a dose is logged as taken
+	UnitsPerDose      sql.NullFloat64 // Units of InventoryItemType one dose uses
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
 	AccountID         int64 // Account this medication belongs to
 
 	// Computed fields (set by repository)
-	TakenToday bool
+	TakenToday      bool
+	DosesToday      int // Doses due today, 0 if it has no dose times
+	DosesTakenToday int
+}
+
+// MedicationCatalogEntry is a normalized medication name in the instance's
+// catalog: an ingredient in a standard dose form
+type MedicationCatalogEntry struct {
+	ID         int64
+	Name       string // Ingredient, e.g. "Progesterone"
+	DoseForm   string // e.g. "Vaginal Insert"
+	Route      sql.NullString
+	BrandNames sql.NullString // Comma separated, searched alongside the name
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// DisplayName is the name a medication picked from the catalog is given
+func (e *MedicationCatalogEntry) DisplayName() string {
+	return e.Name + " " + e.DoseForm
 }
 
 // FormattedEndDate returns the end date in a readable format
@@ -150,8 +352,54 @@ type MedicationLog struct {
 	LoggedBy     sql.NullInt64
 	Timestamp    time.Time
 	Taken        bool
+	Late         bool // Taken, but after the dose's window closed
 	Notes        sql.NullString
+	ScheduledFor sql.NullTime // The dose this log is for
+	CreatedAt    time.Time
+}
+
+// MedicationDoseSnooze holds back the reminder for one of a medication's
+// doses
+type MedicationDoseSnooze struct {
+	ID           int64
+	MedicationID int64
+	ScheduledFor time.Time // The dose snoozed
+	SnoozedUntil time.Time
+	SnoozeCount  int
+	SnoozedBy    sql.NullInt64
 	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Activity feed entry kinds
+const (
+	ActivityInjection  = "injection"
+	ActivitySymptom    = "symptom"
+	ActivityMedication = "medication"
+	ActivityInventory  = "inventory"
+	ActivityCourse     = "course"
+)
+
+// ActivityEntry is one entry in an account's activity feed, drawn from the
+// table its Kind names
+type ActivityEntry struct {
+	Kind      string
+	ID        int64 // ID in the entry's own table
+	Timestamp time.Time
+	ActorID   sql.NullInt64
+	ActorName sql.NullString
+	CourseID  sql.NullInt64
+	// Action is what happened: the side injected, whether a medication was
+	// taken, the reason stock changed or the course audit action
+	Action string
+	// Subject is what it happened to: the pain location, medication, item
+	// or course name
+	Subject      string
+	PainLevel    sql.NullInt64
+	Amount       sql.NullFloat64 // Dose for injections, change for inventory
+	Notes        sql.NullString
+	MedicationID sql.NullInt64
+	ItemType     sql.NullString
 }
 
 // InventoryItem represents an inventory item
@@ -182,6 +430,8 @@ type InventoryHistory struct {
 	PerformedBy    sql.NullInt64
 	Timestamp      time.Time
 	Notes          sql.NullString
+	ProfileVersion sql.NullInt64 // Consumption profile version that applied, for injection decrements
+	ReversedBy     sql.NullInt64 // The entry that undid this one, if any
 }
 
 // Notification represents a user notification
@@ -207,6 +457,8 @@ type AuditLog struct {
 	IPAddress  sql.NullString
 	UserAgent  sql.NullString
 	Timestamp  time.Time
+	// ImpersonatorID is the admin who did it while impersonating the user
+	ImpersonatorID sql.NullInt64
 }
 
 // Setting represents a system setting
@@ -217,14 +469,41 @@ type Setting struct {
 	UpdatedBy sql.NullInt64
 }
 
+// UserPreferences is how a user wants the app to show times, dates and
+// measurements
+type UserPreferences struct {
+	UserID     int64
+	Timezone   string // IANA name, such as America/New_York
+	Locale     string // BCP 47 tag, such as en-US
+	DateFormat string // MM/DD/YYYY, DD/MM/YYYY or YYYY-MM-DD
+	TimeFormat string // 12h or 24h
+	Units      string // metric or imperial
+	WeekStart  string // sunday or monday
+	Theme      string // auto, light or dark
+	UpdatedAt  time.Time
+}
+
 // Account represents a family/couple account (multi-user support)
 type Account struct {
-	ID        int64
-	Name      sql.NullString
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID               int64
+	Name             sql.NullString
+	OwnersSeePrivate bool // Owners see members' private symptom logs
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	// Suspension is set by the admin to AccountReadOnly or AccountLocked
+	Suspension       sql.NullString
+	SuspensionReason sql.NullString
+	SuspendedAt      sql.NullTime
+	SuspendedBy      sql.NullInt64
 }
 
+// Account suspensions. A read-only account's members can look at their
+// data but not change it; a locked account's members can't sign in.
+const (
+	AccountReadOnly = "read_only"
+	AccountLocked   = "locked"
+)
+
 // AccountMember represents a user's membership in an account
 type AccountMember struct {
 	AccountID int64
@@ -234,7 +513,9 @@ type AccountMember struct {
 	InvitedBy sql.NullInt64
 
 	// Computed fields (set by repository)
-	Username string // Username of this member
+	Username      string       // Username of this member
+	LastLogin     sql.NullTime // When they last logged in
+	LastAuditedAt sql.NullTime // When they last did anything that was audited
 }
 
 // AccountInvitation represents an invitation to join an account
@@ -259,3 +540,576 @@ type AccountInvitation struct {
 func (i *AccountInvitation) IsExpiredCheck() bool {
 	return time.Now().After(i.ExpiresAt)
 }
+
+// NotificationChannel represents an external notification destination (ntfy, Gotify, webhook)
+type NotificationChannel struct {
+	ID         int64
+	AccountID  int64
+	Type       string // 'ntfy', 'gotify', or 'webhook'
+	Name       string
+	Config     string // JSON object, shape depends on Type
+	IsEnabled  bool
+	LastSentAt sql.NullTime
+	LastError  sql.NullString
+	CreatedBy  sql.NullInt64
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Webhook represents an outbound webhook subscription for data events
+type Webhook struct {
+	ID          int64
+	AccountID   int64
+	URL         string
+	Secret      string
+	Events      string // JSON array of event names
+	Description sql.NullString
+	IsEnabled   bool
+	CreatedBy   sql.NullInt64
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// WebhookDelivery represents a single event delivery to a webhook, including retries
+type WebhookDelivery struct {
+	ID             int64
+	WebhookID      int64
+	Event          string
+	Payload        string // JSON body sent to the webhook
+	Status         string // 'pending', 'success', or 'failed'
+	Attempts       int
+	ResponseStatus sql.NullInt64
+	LastError      sql.NullString
+	NextAttemptAt  sql.NullTime
+	CreatedAt      time.Time
+	DeliveredAt    sql.NullTime
+}
+
+// Attachment represents an uploaded image linked to injections or symptom logs
+type Attachment struct {
+	ID               int64
+	AccountID        int64
+	UploadedBy       sql.NullInt64
+	OriginalFilename string
+	ContentType      string
+	SizeBytes        int64
+	SHA256           string
+	StorageKey       string
+	ThumbnailKey     sql.NullString
+	Width            sql.NullInt64
+	Height           sql.NullInt64
+	CreatedAt        time.Time
+}
+
+// Compound represents an injectable medication that courses can track
+type Compound struct {
+	ID                int64
+	AccountID         int64
+	Name              string
+	Concentration     sql.NullFloat64 // mg per mL
+	Route             string          // intramuscular, subcutaneous, intradermal, other
+	InventoryItemType string          // inventory_items.item_type its stock is tracked under
+	DefaultDoseML     sql.NullFloat64
+	Notes             sql.NullString
+	IsActive          bool
+	CreatedBy         sql.NullInt64
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// InventoryItemType is an account's definition of a tracked inventory item
+type InventoryItemType struct {
+	ID                    int64
+	AccountID             int64
+	ItemType              string // Key used by inventory_items and inventory_history
+	Name                  string
+	Unit                  string  // mL or count
+	DecrementPerInjection float64 // Used per injection; medications use the dose instead
+	ReorderThreshold      sql.NullFloat64
+	PurchaseUnit          sql.NullString  // vial or box, the unit restocks can be entered in
+	UnitsPerPurchase      sql.NullFloat64 // Usage units in one purchase unit
+	SortOrder             int
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
+
+// ConsumptionProfile is a numbered snapshot of what one injection uses
+// besides the medication
+type ConsumptionProfile struct {
+	ID        int64
+	AccountID int64
+	Version   int
+	Items     []ConsumptionProfileItem
+	CreatedBy sql.NullInt64
+	CreatedAt time.Time
+}
+
+// ConsumptionProfileItem is the amount of one item type used per injection
+type ConsumptionProfileItem struct {
+	ItemType string
+	Amount   float64
+}
+
+// PurchaseOrder is an order of supplies from a supplier
+type PurchaseOrder struct {
+	ID              int64
+	AccountID       int64
+	Supplier        string
+	OrderNumber     sql.NullString
+	Status          string // ordered, received or cancelled
+	OrderedAt       time.Time
+	ExpectedArrival sql.NullTime
+	ReceivedAt      sql.NullTime
+	ShippingCost    float64
+	Notes           sql.NullString
+	CreatedBy       sql.NullInt64
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+
+	Items []PurchaseOrderItem
+}
+
+// TotalCost is the cost of all items plus shipping
+func (o *PurchaseOrder) TotalCost() float64 {
+	total := o.ShippingCost
+	for _, item := range o.Items {
+		total += item.Quantity * item.UnitCost
+	}
+	return total
+}
+
+// PurchaseOrderItem is one line of a purchase order
+type PurchaseOrderItem struct {
+	ID             int64
+	OrderID        int64
+	ItemType       string
+	Quantity       float64
+	UnitCost       float64
+	LotNumber      sql.NullString
+	ExpirationDate sql.NullTime
+	LotID          sql.NullInt64 // Lot created when the order was received
+}
+
+// PurchaseSpend is the total spent on orders in one reporting group, such as
+// a month or an item type
+type PurchaseSpend struct {
+	Key          string // Period ("2006-01") or item type
+	Orders       int
+	Quantity     float64 // Items only; not meaningful across item types
+	ItemCost     float64
+	ShippingCost float64
+}
+
+// InventoryLot is one received batch of an inventory item. Stock is consumed
+// from the oldest lot first.
+type InventoryLot struct {
+	ID                int64
+	AccountID         int64
+	ItemType          string
+	LotNumber         sql.NullString
+	ExpirationDate    sql.NullTime
+	QuantityReceived  float64
+	QuantityRemaining float64
+	ReceivedAt        time.Time
+	Notes             sql.NullString
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// Vial statuses. A vial is sealed until first drawn from, open until it is
+// drained or thrown out.
+const (
+	VialSealed    = "sealed"
+	VialOpen      = "open"
+	VialEmpty     = "empty"
+	VialDiscarded = "discarded"
+)
+
+// InventoryVial is one multi-dose vial of medication. Once opened it must be
+// discarded by DiscardAt, however much is left.
+type InventoryVial struct {
+	ID               int64
+	AccountID        int64
+	ItemType         string
+	LotID            sql.NullInt64
+	Label            sql.NullString
+	VolumeML         float64
+	RemainingML      float64
+	DiscardAfterDays int
+	Status           string
+	OpenedAt         sql.NullTime
+	DiscardAt        sql.NullTime
+	ClosedAt         sql.NullTime // When it was drained or discarded
+	Notes            sql.NullString
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// NDCProduct is an entry in the instance's National Drug Code directory
+type NDCProduct struct {
+	NDC                string // 10 digits, no dashes
+	ProductName        string
+	Labeler            sql.NullString
+	ItemType           sql.NullString // Suggested item type for restocks
+	PackageDescription sql.NullString
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// BarcodeMapping is an account's own record of which item type a scanned
+// code stocks
+type BarcodeMapping struct {
+	ID        int64
+	AccountID int64
+	Code      string // The scan's lookup key, see services.ScannedCode.Key
+	ItemType  string
+	Label     sql.NullString
+	CreatedBy sql.NullInt64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Stocktake statuses. Counts can only be entered while a stocktake is open.
+const (
+	StocktakeOpen      = "open"
+	StocktakeCompleted = "completed"
+	StocktakeCancelled = "cancelled"
+)
+
+// Stocktake is a physical count of an account's stock
+type Stocktake struct {
+	ID        int64
+	AccountID int64
+	Status    string
+	Notes     sql.NullString
+	StartedBy sql.NullInt64
+	StartedAt time.Time
+	ClosedBy  sql.NullInt64 // Who completed or cancelled it
+	ClosedAt  sql.NullTime
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// StocktakeCount is what was found of an item, or of one lot of it, during a
+// stocktake. RecordedQuantity is what the inventory held when it was counted.
+type StocktakeCount struct {
+	ID               int64
+	StocktakeID      int64
+	ItemType         string
+	LotID            sql.NullInt64 // Null when the whole item was counted
+	LotNumber        sql.NullString
+	RecordedQuantity float64
+	CountedQuantity  float64
+	Notes            sql.NullString
+	CountedBy        sql.NullInt64
+	CountedAt        time.Time
+}
+
+// Variance is how much more was counted than recorded; negative for a
+// shortfall
+func (c *StocktakeCount) Variance() float64 {
+	return c.CountedQuantity - c.RecordedQuantity
+}
+
+// Stock alert levels, from least to most severe. An item is low at or under
+// its threshold, critical at half of it and out at zero.
+const (
+	StockAlertLow      = "low"
+	StockAlertCritical = "critical"
+	StockAlertOut      = "out"
+)
+
+// StockAlert is an item's low stock alert, active until the item is
+// restocked above its threshold
+type StockAlert struct {
+	ID             int64
+	AccountID      int64
+	ItemType       string
+	Level          string  // StockAlertLow, StockAlertCritical or StockAlertOut
+	Quantity       float64 // As of the last check
+	Threshold      float64
+	RaisedAt       time.Time
+	NotifiedAt     sql.NullTime // Last delivery
+	NotifyCount    int
+	AcknowledgedAt sql.NullTime
+	AcknowledgedBy sql.NullInt64
+	ResolvedAt     sql.NullTime
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// ReportSchedule is a summary report emailed to a user every week or month
+type ReportSchedule struct {
+	ID            int64
+	AccountID     int64
+	UserID        int64
+	Name          string
+	Frequency     string        // 'weekly' or 'monthly'
+	DayOfWeek     int           // 0 (Sunday) to 6; weekly only
+	DayOfMonth    int           // 1 to 28; monthly only
+	Hour          int           // Hour of day in the user's timezone
+	CourseID      sql.NullInt64 // Limit the report to one course
+	IncludePDF    bool
+	SavedReportID sql.NullInt64 // Attach a saved report as CSV
+	IsEnabled     bool
+	NextRunAt     time.Time
+	LastSentAt    sql.NullTime
+	LastError     sql.NullString
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// SavedReport is a report a user has built: Definition is its entities,
+// columns, date range, grouping and filters as JSON
+type SavedReport struct {
+	ID         int64
+	AccountID  int64
+	UserID     int64
+	Name       string
+	Definition string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// TrashItem is a deleted course, symptom log, medication or journal entry
+// that can still be restored. Snapshot holds its rows as JSON.
+type TrashItem struct {
+	ID        int64
+	AccountID int64
+	ItemType  string // 'course', 'symptom_log', 'medication' or 'journal_entry'
+	ItemID    int64  // Its ID before it was deleted, kept on restore
+	Label     string
+	IsPrivate bool          // A private symptom log, seen only by PrivateTo
+	PrivateTo sql.NullInt64 // And owners who can see private logs
+	Snapshot  string
+	DeletedBy sql.NullInt64
+	DeletedAt time.Time
+
+	// Computed fields (set by repository)
+	DeletedByUsername string
+}
+
+// Vital is a body measurement such as temperature or weight
+type Vital struct {
+	ID         int64
+	AccountID  int64
+	RecordedBy sql.NullInt64
+	Timestamp  time.Time
+	Type       string  // 'body_temperature' or 'weight'
+	Value      float64 // In Unit, which is fixed per type
+	Unit       string
+	Source     string // 'manual', 'apple_health' or 'google_fit'
+	Notes      sql.NullString
+	CreatedAt  time.Time
+}
+
+// WellnessCheckIn is a member's once-a-day record of how they're doing
+type WellnessCheckIn struct {
+	ID          int64
+	AccountID   int64
+	UserID      sql.NullInt64
+	Date        time.Time       // Calendar date in the member's timezone, at midnight UTC
+	Mood        sql.NullInt64   // 1-5
+	Energy      sql.NullInt64   // 1-5
+	SleepHours  sql.NullFloat64 // Hours slept the night before
+	Temperature sql.NullFloat64 // degC
+	Weight      sql.NullFloat64 // kg
+	Notes       sql.NullString
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// JournalEntry is a free-form markdown note, optionally about a course or a
+// particular day
+type JournalEntry struct {
+	ID        int64
+	AccountID int64
+	AuthorID  sql.NullInt64
+	CourseID  sql.NullInt64
+	EntryDate sql.NullTime // Calendar date, at midnight UTC
+	Title     sql.NullString
+	Body      string
+	IsPinned  bool
+	Tags      []string // Lowercase, sorted
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// LabResult is one measurement from a blood draw, such as a progesterone
+// level
+type LabResult struct {
+	ID            int64
+	AccountID     int64
+	CourseID      sql.NullInt64
+	Analyte       string  // As entered, e.g. "Progesterone"
+	Value         float64 // In Unit
+	Unit          string  // As reported by the lab, e.g. "ng/mL"
+	ReferenceLow  sql.NullFloat64
+	ReferenceHigh sql.NullFloat64
+	DrawnAt       time.Time
+	Notes         sql.NullString
+	CreatedBy     sql.NullInt64
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Flag says whether the value is below ("low") or above ("high") its
+// reference range; it is empty when in range or there's no range to go by
+func (l *LabResult) Flag() string {
+	if l.ReferenceLow.Valid && l.Value < l.ReferenceLow.Float64 {
+		return "low"
+	}
+	if l.ReferenceHigh.Valid && l.Value > l.ReferenceHigh.Float64 {
+		return "high"
+	}
+	return ""
+}
+
+// CalendarToken grants read-only access to a user's calendar feed. Only a
+// hash of the token is stored.
+type CalendarToken struct {
+	ID         int64
+	AccountID  int64
+	UserID     int64
+	Name       string
+	TokenHash  string
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+// Appointment is a clinic visit or other dated event shown on the calendar
+type Appointment struct {
+	ID        int64
+	AccountID int64
+	Title     string
+	StartsAt  time.Time
+	EndsAt    sql.NullTime
+	Location  sql.NullString
+	Notes     sql.NullString
+	CreatedBy sql.NullInt64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SyncOperation is an offline change replayed through the sync endpoint,
+// kept so a resent operation isn't applied twice
+type SyncOperation struct {
+	AccountID  int64
+	ClientID   string // UUID chosen by the client
+	UserID     sql.NullInt64
+	Method     string
+	Path       string
+	Status     string // pending, applied
+	StatusCode sql.NullInt64
+	Response   sql.NullString
+	EntityID   sql.NullInt64
+	CreatedAt  time.Time
+}
+
+// Announcement is a site-wide banner posted by the admin
+type Announcement struct {
+	ID        int64
+	Message   string
+	Severity  string // info, warning, danger
+	ExpiresAt sql.NullTime
+	CreatedBy sql.NullInt64
+	CreatedAt time.Time
+}
+
+// FeatureFlagOverride is the admin's setting of a feature flag, site-wide
+// when AccountID is null and for one account otherwise
+type FeatureFlagOverride struct {
+	ID        int64
+	Key       string
+	AccountID sql.NullInt64
+	Enabled   bool
+	UpdatedBy sql.NullInt64
+	UpdatedAt time.Time
+}
+
+// ResourceQuota is the admin's limits on what an account can use, site-wide
+// when AccountID is null and for one account otherwise. A null limit falls
+// back to the site-wide one; a null or zero site-wide limit is unlimited.
+type ResourceQuota struct {
+	ID                  int64
+	AccountID           sql.NullInt64
+	MaxMembers          sql.NullInt64
+	AttachmentStorageMB sql.NullInt64
+	BackupMB            sql.NullInt64
+	UpdatedBy           sql.NullInt64
+	UpdatedAt           time.Time
+}
+
+// AccountUsage is how much of what quotas limit an account is using.
+// Pending invitations count toward the member limit when inviting.
+type AccountUsage struct {
+	AccountID          int64
+	Name               string
+	Members            int64
+	PendingInvitations int64
+	Attachments        int64
+	AttachmentBytes    int64
+}
+
+// ShareLink gives someone without a login a read-only view of an account's
+// dashboard until it expires or is revoked. Only a hash of the token is
+// stored.
+type ShareLink struct {
+	ID         int64
+	AccountID  int64
+	CreatedBy  int64
+	Name       string
+	TokenHash  string
+	Scopes     []string // What the link shows, see handlers.ShareScopes
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+
+	// Computed fields (set by repository)
+	CreatorUsername string
+}
+
+// ShareLinkAccess records one view of a share link
+type ShareLinkAccess struct {
+	ID          int64
+	ShareLinkID int64
+	AccessedAt  time.Time
+	IPAddress   sql.NullString
+	UserAgent   sql.NullString
+}
+
+// SessionToken is one signed-in device. The session id is carried in the
+// device's JWT; only its hash is stored.
+type SessionToken struct {
+	ID         int64
+	UserID     int64
+	TokenHash  string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+	IPAddress  sql.NullString
+	UserAgent  sql.NullString
+	IsRevoked  bool
+	// ImpersonatorID is the admin signed in as the user, for an
+	// impersonation session
+	ImpersonatorID sql.NullInt64
+	// TrustedDeviceID is the trusted device the session was started on
+	TrustedDeviceID sql.NullInt64
+}
+
+// TrustedDevice is a device the user chose to trust when signing in. It
+// keeps a token of its own, separate from its sessions, so the trust
+// outlasts them.
+type TrustedDevice struct {
+	ID         int64
+	UserID     int64
+	TokenHash  string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+	IPAddress  sql.NullString
+	UserAgent  sql.NullString
+	IsRevoked  bool
+}
@@ -18,6 +18,9 @@ var (
 	ErrInvitationExpired    = errors.New("invitation has expired")
 	ErrInvitationUsed       = errors.New("invitation already used")
 	ErrUserAlreadyInAccount = errors.New("user already belongs to an account")
+	ErrDeletionTokenInvalid = errors.New("deletion confirmation token is invalid or expired")
+	ErrLastOwner            = errors.New("user is the only owner of an account with other members")
+	ErrNotOwner             = errors.New("user is not an owner of the account")
 )
 
 type AccountRepository struct {
@@ -70,6 +73,13 @@ func (r *AccountRepository) Create(name *string, ownerUserID int64) (int64, erro
 		return 0, fmt.Errorf("failed to add owner to account: %w", err)
 	}
 
+	if err = seedInventoryItemTypes(tx, accountID); err != nil {
+		return 0, err
+	}
+	if err = seedSymptomCatalog(tx, accountID); err != nil {
+		return 0, err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -83,10 +93,12 @@ func (r *AccountRepository) GetByID(accountID int64) (*models.Account, error) {
 	var name sql.NullString
 
 	err := r.db.QueryRow(`
-		SELECT id, name, created_at, updated_at
+		SELECT id, name, owners_see_private, created_at, updated_at,
+		       suspension, suspension_reason, suspended_at, suspended_by
 		FROM accounts
 		WHERE id = ?
-	`, accountID).Scan(&account.ID, &name, &account.CreatedAt, &account.UpdatedAt)
+	`, accountID).Scan(&account.ID, &name, &account.OwnersSeePrivate, &account.CreatedAt, &account.UpdatedAt,
+		&account.Suspension, &account.SuspensionReason, &account.SuspendedAt, &account.SuspendedBy)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrAccountNotFound
@@ -105,11 +117,13 @@ func (r *AccountRepository) GetUserAccount(userID int64) (*models.Account, error
 	var name sql.NullString
 
 	err := r.db.QueryRow(`
-		SELECT a.id, a.name, a.created_at, a.updated_at
+		SELECT a.id, a.name, a.owners_see_private, a.created_at, a.updated_at,
+		       a.suspension, a.suspension_reason, a.suspended_at, a.suspended_by
 		FROM accounts a
 		JOIN account_members am ON am.account_id = a.id
 		WHERE am.user_id = ?
-	`, userID).Scan(&account.ID, &name, &account.CreatedAt, &account.UpdatedAt)
+	`, userID).Scan(&account.ID, &name, &account.OwnersSeePrivate, &account.CreatedAt, &account.UpdatedAt,
+		&account.Suspension, &account.SuspensionReason, &account.SuspendedAt, &account.SuspendedBy)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrAccountNotFound
@@ -145,6 +159,86 @@ func (r *AccountRepository) UpdateName(accountID int64, name string) error {
 	return nil
 }
 
+// SetOwnersSeePrivate sets whether the account's owners see its members'
+// private symptom logs
+func (r *AccountRepository) SetOwnersSeePrivate(accountID int64, enabled bool) error {
+	result, err := r.db.Exec(`
+		UPDATE accounts
+		SET owners_see_private = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, enabled, accountID)
+
+	if err != nil {
+		return fmt.Errorf("failed to update account: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrAccountNotFound
+	}
+
+	return nil
+}
+
+// Suspend suspends an account as models.AccountReadOnly or
+// models.AccountLocked, or changes how an already suspended one is. Its data
+// is kept as it is.
+func (r *AccountRepository) Suspend(accountID int64, suspension, reason string, suspendedBy int64) error {
+	if suspension != models.AccountReadOnly && suspension != models.AccountLocked {
+		return fmt.Errorf("unknown suspension %q", suspension)
+	}
+	result, err := r.db.Exec(`
+		UPDATE accounts
+		SET suspension = ?, suspension_reason = ?, suspended_at = ?, suspended_by = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, suspension, sql.NullString{String: reason, Valid: reason != ""}, time.Now().UTC(), suspendedBy, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to suspend account: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrAccountNotFound
+	}
+
+	return nil
+}
+
+// AccountNotSuspended is a SQL condition true when the account whose ID is
+// in column isn't suspended, for what should leave suspended accounts as they
+// are, such as background jobs. A NULL account ID isn't suspended.
+func AccountNotSuspended(column string) string {
+	return `NOT EXISTS (SELECT 1 FROM accounts sa WHERE sa.id = ` + column + ` AND sa.suspension IS NOT NULL)`
+}
+
+// Reactivate lifts an account's suspension
+func (r *AccountRepository) Reactivate(accountID int64) error {
+	result, err := r.db.Exec(`
+		UPDATE accounts
+		SET suspension = NULL, suspension_reason = NULL, suspended_at = NULL, suspended_by = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to reactivate account: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrAccountNotFound
+	}
+
+	return nil
+}
+
 // Delete deletes an account and all associated data (CASCADE)
 func (r *AccountRepository) Delete(accountID int64) error {
 	result, err := r.db.Exec(`DELETE FROM accounts WHERE id = ?`, accountID)
@@ -167,8 +261,11 @@ func (r *AccountRepository) Delete(accountID int64) error {
 // ACCOUNT MEMBER OPERATIONS
 // ==============================================
 
-// GetMembers retrieves all members of an account with their usernames
+// GetMembers retrieves all members of an account with their usernames, when
+// they last logged in and when they last did anything that was audited
 func (r *AccountRepository) GetMembers(accountID int64) ([]*models.AccountMember, error) {
+	// Joining the newest audit entry rather than taking MAX(timestamp) keeps
+	// the column's type, which SQLite loses on aggregates
 	rows, err := r.db.Query(`
 		SELECT
 			am.account_id,
@@ -176,9 +273,14 @@ func (r *AccountRepository) GetMembers(accountID int64) ([]*models.AccountMember
 			am.role,
 			am.joined_at,
 			am.invited_by,
-			u.username
+			u.username,
+			u.last_login,
+			al.timestamp
 		FROM account_members am
 		JOIN users u ON u.id = am.user_id
+		LEFT JOIN audit_logs al ON al.id = (
+			SELECT MAX(id) FROM audit_logs WHERE user_id = am.user_id
+		)
 		WHERE am.account_id = ?
 		ORDER BY am.joined_at ASC
 	`, accountID)
@@ -200,6 +302,8 @@ func (r *AccountRepository) GetMembers(accountID int64) ([]*models.AccountMember
 			&member.JoinedAt,
 			&invitedBy,
 			&member.Username,
+			&member.LastLogin,
+			&member.LastAuditedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan member: %w", err)
@@ -209,7 +313,7 @@ func (r *AccountRepository) GetMembers(accountID int64) ([]*models.AccountMember
 		members = append(members, &member)
 	}
 
-	return members, nil
+	return members, rows.Err()
 }
 
 // GetMember retrieves a specific member's information
@@ -238,7 +342,7 @@ func (r *AccountRepository) GetMember(accountID, userID int64) (*models.AccountM
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("member not found")
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get member: %w", err)
@@ -279,48 +383,165 @@ func (r *AccountRepository) AddMember(accountID, userID int64, role string, invi
 	return nil
 }
 
-// RemoveMember removes a user from an account
-func (r *AccountRepository) RemoveMember(accountID, userID int64) error {
-	result, err := r.db.Exec(`
-		DELETE FROM account_members
-		WHERE account_id = ? AND user_id = ?
-	`, accountID, userID)
+// RemoveMember takes a user out of an account and gives them a new account of
+// their own, so they can still log in. What they logged stays in the old
+// account under their name. Returns ErrLastOwner if they are its only owner,
+// and the new account's ID.
+func (r *AccountRepository) RemoveMember(accountID, userID int64) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
 
+	role, err := memberRole(tx, accountID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to remove member: %w", err)
+		return 0, err
+	}
+	if role == "owner" {
+		if err := checkOtherOwners(tx, accountID, userID); err != nil {
+			return 0, err
+		}
 	}
 
-	rows, err := result.RowsAffected()
+	if _, err := tx.Exec(`
+		DELETE FROM account_members
+		WHERE account_id = ? AND user_id = ?
+	`, accountID, userID); err != nil {
+		return 0, fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	var newAccountID int64
+	err = tx.QueryRow(`
+		INSERT INTO accounts (created_at, updated_at)
+		VALUES (CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id
+	`).Scan(&newAccountID)
 	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
+		return 0, fmt.Errorf("failed to create account: %w", err)
 	}
-	if rows == 0 {
-		return fmt.Errorf("member not found")
+	if _, err := tx.Exec(`
+		INSERT INTO account_members (account_id, user_id, role, joined_at)
+		VALUES (?, ?, 'owner', CURRENT_TIMESTAMP)
+	`, newAccountID, userID); err != nil {
+		return 0, fmt.Errorf("failed to add owner to account: %w", err)
+	}
+	if err := seedInventoryItemTypes(tx, newAccountID); err != nil {
+		return 0, err
+	}
+	if err := seedSymptomCatalog(tx, newAccountID); err != nil {
+		return 0, err
 	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return newAccountID, nil
 }
 
-// UpdateMemberRole updates a member's role
+// UpdateMemberRole updates a member's role. Returns ErrLastOwner rather than
+// leave the account without an owner.
 func (r *AccountRepository) UpdateMemberRole(accountID, userID int64, role string) error {
-	result, err := r.db.Exec(`
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	current, err := memberRole(tx, accountID, userID)
+	if err != nil {
+		return err
+	}
+	if current == "owner" && role != "owner" {
+		if err := checkOtherOwners(tx, accountID, userID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`
 		UPDATE account_members
 		SET role = ?
 		WHERE account_id = ? AND user_id = ?
-	`, role, accountID, userID)
+	`, role, accountID, userID); err != nil {
+		return fmt.Errorf("failed to update member role: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
+	return nil
+}
+
+// TransferOwnership makes another member the owner of an account and the
+// current owner a member
+func (r *AccountRepository) TransferOwnership(accountID, fromUserID, toUserID int64) error {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to update member role: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	rows, err := result.RowsAffected()
+	if _, err := memberRole(tx, accountID, toUserID); err != nil {
+		return err
+	}
+	role, err := memberRole(tx, accountID, fromUserID)
 	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
+		return err
 	}
-	if rows == 0 {
-		return fmt.Errorf("member not found")
+	if role != "owner" {
+		return ErrNotOwner
 	}
 
+	if _, err := tx.Exec(`
+		UPDATE account_members SET role = 'owner' WHERE account_id = ? AND user_id = ?
+	`, accountID, toUserID); err != nil {
+		return fmt.Errorf("failed to promote new owner: %w", err)
+	}
+	if _, err := tx.Exec(`
+		UPDATE account_members SET role = 'member' WHERE account_id = ? AND user_id = ?
+	`, accountID, fromUserID); err != nil {
+		return fmt.Errorf("failed to demote previous owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// memberRole returns a member's role, or ErrNotFound if the user isn't in
+// the account
+func memberRole(tx *sql.Tx, accountID, userID int64) (string, error) {
+	var role string
+	err := tx.QueryRow(`
+		SELECT role FROM account_members WHERE account_id = ? AND user_id = ?
+	`, accountID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get member: %w", err)
+	}
+	return role, nil
+}
+
+// checkOtherOwners returns ErrLastOwner unless someone besides the user owns
+// the account
+func checkOtherOwners(tx *sql.Tx, accountID, userID int64) error {
+	var owners int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM account_members
+		WHERE account_id = ? AND role = 'owner' AND user_id != ?
+	`, accountID, userID).Scan(&owners)
+	if err != nil {
+		return fmt.Errorf("failed to count owners: %w", err)
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
 	return nil
 }
 
@@ -343,8 +564,19 @@ func hashToken(token string) string {
 	return base64.URLEncoding.EncodeToString(hash[:])
 }
 
-// CreateInvitation creates an invitation and returns the token (not hashed)
-func (r *AccountRepository) CreateInvitation(accountID int64, email string, invitedBy int64, expiresAt time.Time) (string, error) {
+// CreateInvitation creates an invitation to join with the given role and
+// returns the token (not hashed)
+func (r *AccountRepository) CreateInvitation(accountID int64, email string, invitedBy int64, role string, expiresAt time.Time) (string, error) {
+	return r.createInvitation(accountID, email, invitedBy, role, expiresAt)
+}
+
+// CreateOwnerInvitation creates an invitation to join as the account's owner,
+// for handing an account over to someone who hasn't registered yet
+func (r *AccountRepository) CreateOwnerInvitation(accountID int64, email string, invitedBy int64, expiresAt time.Time) (string, error) {
+	return r.createInvitation(accountID, email, invitedBy, "owner", expiresAt)
+}
+
+func (r *AccountRepository) createInvitation(accountID int64, email string, invitedBy int64, role string, expiresAt time.Time) (string, error) {
 	// Generate token
 	token, err := generateToken()
 	if err != nil {
@@ -357,8 +589,8 @@ func (r *AccountRepository) CreateInvitation(accountID int64, email string, invi
 	_, err = r.db.Exec(`
 		INSERT INTO account_invitations (
 			account_id, email, token_hash, invited_by, role, created_at, expires_at
-		) VALUES (?, ?, ?, ?, 'member', CURRENT_TIMESTAMP, ?)
-	`, accountID, email, tokenHash, invitedBy, expiresAt)
+		) VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?)
+	`, accountID, email, tokenHash, invitedBy, role, expiresAt)
 
 	if err != nil {
 		return "", fmt.Errorf("failed to create invitation: %w", err)
@@ -367,7 +599,8 @@ func (r *AccountRepository) CreateInvitation(accountID int64, email string, invi
 	return token, nil
 }
 
-// GetInvitationByToken retrieves an invitation by its token
+// GetInvitationByToken retrieves an invitation by its token. Invitations to
+// a suspended account aren't found, since joining would change it.
 func (r *AccountRepository) GetInvitationByToken(token string) (*models.AccountInvitation, error) {
 	tokenHash := hashToken(token)
 
@@ -390,7 +623,7 @@ func (r *AccountRepository) GetInvitationByToken(token string) (*models.AccountI
 			u.username
 		FROM account_invitations i
 		JOIN users u ON u.id = i.invited_by
-		WHERE i.token_hash = ?
+		WHERE i.token_hash = ? AND `+AccountNotSuspended("i.account_id")+`
 	`, tokenHash).Scan(
 		&invitation.ID,
 		&invitation.AccountID,
@@ -480,6 +713,105 @@ func (r *AccountRepository) GetPendingInvitations(accountID int64) ([]*models.Ac
 	return invitations, nil
 }
 
+// invitationColumns are scanned by scanInvitation
+const invitationColumns = `i.id, i.account_id, i.email, i.token_hash, i.invited_by, i.role,
+	i.created_at, i.expires_at, i.accepted_at, i.accepted_by, COALESCE(u.username, '')`
+
+func scanInvitation(row interface{ Scan(...interface{}) error }, now time.Time) (*models.AccountInvitation, error) {
+	var invitation models.AccountInvitation
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.AccountID,
+		&invitation.Email,
+		&invitation.TokenHash,
+		&invitation.InvitedBy,
+		&invitation.Role,
+		&invitation.CreatedAt,
+		&invitation.ExpiresAt,
+		&invitation.AcceptedAt,
+		&invitation.AcceptedBy,
+		&invitation.InviterUsername,
+	)
+	if err != nil {
+		return nil, err
+	}
+	invitation.IsExpired = now.After(invitation.ExpiresAt)
+	return &invitation, nil
+}
+
+// ListInvitations retrieves an account's invitations, accepted or not,
+// newest first
+func (r *AccountRepository) ListInvitations(accountID int64) ([]*models.AccountInvitation, error) {
+	rows, err := r.db.Query(`
+		SELECT `+invitationColumns+`
+		FROM account_invitations i
+		LEFT JOIN users u ON u.id = i.invited_by
+		WHERE i.account_id = ?
+		ORDER BY i.created_at DESC, i.id DESC
+	`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	var invitations []*models.AccountInvitation
+	now := time.Now()
+	for rows.Next() {
+		invitation, err := scanInvitation(rows, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+// GetInvitation retrieves one of an account's invitations
+func (r *AccountRepository) GetInvitation(accountID, invitationID int64) (*models.AccountInvitation, error) {
+	invitation, err := scanInvitation(r.db.QueryRow(`
+		SELECT `+invitationColumns+`
+		FROM account_invitations i
+		LEFT JOIN users u ON u.id = i.invited_by
+		WHERE i.account_id = ? AND i.id = ?
+	`, accountID, invitationID), time.Now())
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+// RenewInvitation replaces an unaccepted invitation's token, so links sent
+// before stop working, and moves its expiry. It returns the new token (not
+// hashed).
+func (r *AccountRepository) RenewInvitation(accountID, invitationID int64, expiresAt time.Time) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE account_invitations SET token_hash = ?, expires_at = ?
+		WHERE account_id = ? AND id = ? AND accepted_at IS NULL
+	`, hashToken(token), expiresAt, accountID, invitationID)
+	if err != nil {
+		return "", fmt.Errorf("failed to renew invitation: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		if _, err := r.GetInvitation(accountID, invitationID); err != nil {
+			return "", err
+		}
+		return "", ErrInvitationUsed
+	}
+	return token, nil
+}
+
 // AcceptInvitation marks an invitation as accepted and adds user to account
 func (r *AccountRepository) AcceptInvitation(invitationID, userID int64) error {
 	// Get the invitation details first
@@ -529,9 +861,10 @@ func (r *AccountRepository) AcceptInvitation(invitationID, userID int64) error {
 	return nil
 }
 
-// DeleteInvitation deletes an invitation (e.g., revoke before acceptance)
-func (r *AccountRepository) DeleteInvitation(invitationID int64) error {
-	result, err := r.db.Exec(`DELETE FROM account_invitations WHERE id = ?`, invitationID)
+// DeleteInvitation deletes one of an account's invitations (e.g., revoke
+// before acceptance)
+func (r *AccountRepository) DeleteInvitation(accountID, invitationID int64) error {
+	result, err := r.db.Exec(`DELETE FROM account_invitations WHERE account_id = ? AND id = ?`, accountID, invitationID)
 	if err != nil {
 		return fmt.Errorf("failed to delete invitation: %w", err)
 	}
@@ -559,3 +892,154 @@ func (r *AccountRepository) ValidateInvitation(invitation *models.AccountInvitat
 
 	return nil
 }
+
+// ==============================================
+// SELF-SERVICE DELETION
+// ==============================================
+
+// CreateDeletionRequest starts deleting a user, replacing any pending request,
+// and returns the confirmation token (not hashed)
+func (r *AccountRepository) CreateDeletionRequest(userID int64, expiresAt time.Time) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO account_deletion_requests (user_id, token_hash, created_at, expires_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			token_hash = excluded.token_hash,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+	`, userID, hashToken(token), expiresAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to create deletion request: %w", err)
+	}
+
+	return token, nil
+}
+
+// ValidateDeletionRequest checks a confirmation token without using it up
+func (r *AccountRepository) ValidateDeletionRequest(userID int64, token string) error {
+	return checkDeletionRequest(r.db, userID, token)
+}
+
+// CancelDeletionRequest drops a user's pending deletion request, if any
+func (r *AccountRepository) CancelDeletionRequest(userID int64) error {
+	if _, err := r.db.Exec(`DELETE FROM account_deletion_requests WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to cancel deletion request: %w", err)
+	}
+	return nil
+}
+
+// CanDeleteUser reports ErrLastOwner if deleting the user would leave their
+// account's other members without an owner
+func (r *AccountRepository) CanDeleteUser(userID int64) error {
+	_, _, err := userDeletionScope(r.db, userID)
+	return err
+}
+
+// DeleteUser deletes a user who confirmed a deletion request with token. A
+// sole member takes their account and everything in it along; otherwise the
+// account keeps the user's entries, no longer attributed to anyone. The
+// user's audit entries lose their IP address and user agent, and one entry
+// without any identifying details records the deletion. Returns the ID of the
+// deleted account, or 0 if the account was kept.
+func (r *AccountRepository) DeleteUser(userID int64, token string) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkDeletionRequest(tx, userID, token); err != nil {
+		return 0, err
+	}
+	accountID, soleMember, err := userDeletionScope(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if soleMember {
+		if _, err := tx.Exec(`DELETE FROM accounts WHERE id = ?`, accountID); err != nil {
+			return 0, fmt.Errorf("failed to delete account: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE audit_logs SET ip_address = NULL, user_agent = NULL WHERE user_id = ?`, userID); err != nil {
+		return 0, fmt.Errorf("failed to anonymize audit logs: %w", err)
+	}
+	if err := NewSettingsRepositoryTx(tx).DeleteUser(userID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = ?`, userID); err != nil {
+		return 0, fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	details := `{"self_service":true,"account_deleted":false}`
+	if soleMember {
+		details = `{"self_service":true,"account_deleted":true}`
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO audit_logs (action, entity_type, details, timestamp)
+		VALUES ('delete', 'user', ?, CURRENT_TIMESTAMP)
+	`, details); err != nil {
+		return 0, fmt.Errorf("failed to log deletion: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if !soleMember {
+		return 0, nil
+	}
+	return accountID, nil
+}
+
+// checkDeletionRequest returns ErrDeletionTokenInvalid unless token matches
+// the user's pending, unexpired deletion request
+func checkDeletionRequest(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, userID int64, token string) error {
+	var expiresAt time.Time
+	err := q.QueryRow(`
+		SELECT expires_at FROM account_deletion_requests WHERE user_id = ? AND token_hash = ?
+	`, userID, hashToken(token)).Scan(&expiresAt)
+	if err == sql.ErrNoRows || (err == nil && time.Now().After(expiresAt)) {
+		return ErrDeletionTokenInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get deletion request: %w", err)
+	}
+	return nil
+}
+
+// userDeletionScope returns the user's account and whether they are its only
+// member, or ErrLastOwner if the other members would be left without an owner
+func userDeletionScope(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, userID int64) (accountID int64, soleMember bool, err error) {
+	var role string
+	err = q.QueryRow(`SELECT account_id, role FROM account_members WHERE user_id = ?`, userID).Scan(&accountID, &role)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get account membership: %w", err)
+	}
+
+	var members, otherOwners int
+	err = q.QueryRow(`
+		SELECT COUNT(*), COUNT(CASE WHEN role = 'owner' AND user_id != ? THEN 1 END)
+		FROM account_members WHERE account_id = ?
+	`, userID, accountID).Scan(&members, &otherOwners)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to count account members: %w", err)
+	}
+	if members > 1 && role == "owner" && otherOwners == 0 {
+		return 0, false, ErrLastOwner
+	}
+	return accountID, members == 1, nil
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestAccountRepository_DeleteUser(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	// A second member, and data of the account to be deleted with it
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (2, 'member', 'hash');
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 2, 'member');
+		INSERT INTO courses (id, account_id, name, start_date) VALUES (1, 1, 'Course', CURRENT_TIMESTAMP);
+		INSERT INTO injections (id, course_id, timestamp, side) VALUES (1, 1, CURRENT_TIMESTAMP, 'left');
+		INSERT INTO accounts (id, name) VALUES (2, 'Other');
+		INSERT INTO inventory_history (account_id, item_type, change_amount, quantity_before, quantity_after, reason, reference_id, reference_type)
+		VALUES (1, 'progesterone', -1, 10, 9, 'injection', 1, 'injection'),
+		       (2, 'progesterone', 5, 0, 5, 'restock', NULL, NULL);
+		INSERT INTO user_settings (user_id, key, value) VALUES (1, 'checkin_reminder', '20:00'), (2, 'checkin_reminder', '');
+		INSERT INTO user_preferences (user_id, theme) VALUES (1, 'dark'), (2, 'light');
+		INSERT INTO audit_logs (user_id, action, entity_type, ip_address, user_agent) VALUES (1, 'login', 'user', '10.0.0.1', 'test');
+	`); err != nil {
+		t.Fatalf("Failed to seed data: %v", err)
+	}
+
+	repo := NewAccountRepository(db.DB)
+	if err := repo.CanDeleteUser(1); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("Expected ErrLastOwner for only owner with other members, got %v", err)
+	}
+
+	// A member leaves the account and its data behind
+	token, err := repo.CreateDeletionRequest(2, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create deletion request: %v", err)
+	}
+	if _, err := repo.DeleteUser(2, "wrong"); !errors.Is(err, ErrDeletionTokenInvalid) {
+		t.Errorf("Expected ErrDeletionTokenInvalid for wrong token, got %v", err)
+	}
+	accountID, err := repo.DeleteUser(2, token)
+	if err != nil || accountID != 0 {
+		t.Fatalf("Expected member deleted without account, got %d (%v)", accountID, err)
+	}
+
+	// Now the owner is alone, so the account goes too
+	expired, _ := repo.CreateDeletionRequest(1, time.Now().Add(-time.Minute))
+	if err := repo.ValidateDeletionRequest(1, expired); !errors.Is(err, ErrDeletionTokenInvalid) {
+		t.Errorf("Expected ErrDeletionTokenInvalid for expired token, got %v", err)
+	}
+	token, _ = repo.CreateDeletionRequest(1, time.Now().Add(time.Hour))
+	accountID, err = repo.DeleteUser(1, token)
+	if err != nil || accountID != 1 {
+		t.Fatalf("Expected account 1 deleted, got %d (%v)", accountID, err)
+	}
+
+	for table, want := range map[string]int{
+		"users":                                  0,
+		"accounts":                               1,
+		"injections":                             0,
+		"inventory_history WHERE account_id = 1": 0,
+		"inventory_history WHERE account_id = 2": 1,
+		"user_settings":                          0,
+		"user_preferences":                       0,
+		"account_deletion_requests":              0,
+		"audit_logs WHERE user_id IS NOT NULL OR ip_address IS NOT NULL": 0,
+		"audit_logs WHERE action = 'delete' AND entity_type = 'user'":    2,
+	} {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count); err != nil {
+			t.Fatalf("Failed to count %s: %v", table, err)
+		}
+		if count != want {
+			t.Errorf("Expected %d rows in %s, got %d", want, table, count)
+		}
+	}
+}
+
+func TestAccountRepository_Invitations(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO accounts (id, name) VALUES (2, 'Other')`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+
+	repo := NewAccountRepository(db.DB)
+	token, err := repo.CreateInvitation(1, "partner@example.com", 1, "owner", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create invitation: %v", err)
+	}
+	inv, err := repo.GetInvitationByToken(token)
+	if err != nil {
+		t.Fatalf("Failed to get invitation: %v", err)
+	}
+	if inv.Role != "owner" {
+		t.Errorf("Role = %q, want owner", inv.Role)
+	}
+
+	// Other accounts can't see, renew or revoke it
+	if _, err := repo.GetInvitation(2, inv.ID); !errors.Is(err, ErrInvitationNotFound) {
+		t.Errorf("Expected ErrInvitationNotFound from another account, got %v", err)
+	}
+	if err := repo.DeleteInvitation(2, inv.ID); !errors.Is(err, ErrInvitationNotFound) {
+		t.Errorf("Expected ErrInvitationNotFound revoking from another account, got %v", err)
+	}
+
+	renewed, err := repo.RenewInvitation(1, inv.ID, time.Now().Add(48*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to renew invitation: %v", err)
+	}
+	if _, err := repo.GetInvitationByToken(token); !errors.Is(err, ErrInvitationNotFound) {
+		t.Errorf("Old token still works after renewal: %v", err)
+	}
+	if _, err := repo.GetInvitationByToken(renewed); err != nil {
+		t.Errorf("New token doesn't work: %v", err)
+	}
+
+	list, err := repo.ListInvitations(1)
+	if err != nil || len(list) != 1 || list[0].InviterUsername == "" {
+		t.Fatalf("Expected 1 invitation with its inviter, got %d (%v)", len(list), err)
+	}
+
+	if _, err := db.Exec(`UPDATE account_invitations SET accepted_at = CURRENT_TIMESTAMP WHERE id = ?`, inv.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.RenewInvitation(1, inv.ID, time.Now().Add(time.Hour)); !errors.Is(err, ErrInvitationUsed) {
+		t.Errorf("Expected ErrInvitationUsed renewing an accepted invitation, got %v", err)
+	}
+	if err := repo.DeleteInvitation(1, inv.ID); err != nil {
+		t.Errorf("Failed to delete invitation: %v", err)
+	}
+}
+
+func TestAccountRepository_Members(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (2, 'member', 'hash');
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 2, 'member');
+		INSERT INTO courses (id, account_id, name, start_date, created_by) VALUES (1, 1, 'Course', CURRENT_TIMESTAMP, 2);
+		INSERT INTO audit_logs (user_id, action, entity_type) VALUES (2, 'create', 'injection');
+	`); err != nil {
+		t.Fatalf("Failed to seed data: %v", err)
+	}
+
+	repo := NewAccountRepository(db.DB)
+	members, err := repo.GetMembers(1)
+	if err != nil || len(members) != 2 {
+		t.Fatalf("Expected 2 members, got %d (%v)", len(members), err)
+	}
+	if members[0].LastAuditedAt.Valid || !members[1].LastAuditedAt.Valid {
+		t.Errorf("Expected only the member to have audited activity, got %v and %v", members[0].LastAuditedAt, members[1].LastAuditedAt)
+	}
+
+	if err := repo.UpdateMemberRole(1, 1, "member"); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("Expected ErrLastOwner demoting the only owner, got %v", err)
+	}
+	if _, err := repo.RemoveMember(1, 1); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("Expected ErrLastOwner removing the only owner, got %v", err)
+	}
+	if err := repo.UpdateMemberRole(1, 3, "owner"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a non-member, got %v", err)
+	}
+
+	// Ownership changes hands
+	if err := repo.TransferOwnership(1, 2, 1); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner transferring from a member, got %v", err)
+	}
+	if err := repo.TransferOwnership(1, 1, 2); err != nil {
+		t.Fatalf("Failed to transfer ownership: %v", err)
+	}
+	if m, _ := repo.GetMember(1, 1); m == nil || m.Role != "member" {
+		t.Errorf("Expected previous owner to be a member, got %+v", m)
+	}
+	if m, _ := repo.GetMember(1, 2); m == nil || m.Role != "owner" {
+		t.Errorf("Expected new owner, got %+v", m)
+	}
+
+	// The previous owner is removed and lands in an account of their own,
+	// leaving what the others logged where it was
+	newAccountID, err := repo.RemoveMember(1, 1)
+	if err != nil {
+		t.Fatalf("Failed to remove member: %v", err)
+	}
+	account, err := repo.GetUserAccount(1)
+	if err != nil || account.ID != newAccountID || newAccountID == 1 {
+		t.Errorf("Expected user 1 in new account %d, got %+v (%v)", newAccountID, account, err)
+	}
+	if m, _ := repo.GetMember(newAccountID, 1); m == nil || m.Role != "owner" {
+		t.Errorf("Expected user 1 to own their new account, got %+v", m)
+	}
+	var courses int
+	_ = db.QueryRow(`SELECT COUNT(*) FROM courses WHERE account_id = 1 AND created_by = 2`).Scan(&courses)
+	if courses != 1 {
+		t.Errorf("Expected the course to stay in the account, got %d", courses)
+	}
+	if _, err := repo.GetMember(1, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after removal, got %v", err)
+	}
+}
+
+func TestAccountRepository_Suspend(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewAccountRepository(db.DB)
+	if err := repo.Suspend(1, "frozen", "", 1); err == nil {
+		t.Error("Expected an unknown suspension to be refused")
+	}
+	if err := repo.Suspend(99, models.AccountLocked, "", 1); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected ErrAccountNotFound, got %v", err)
+	}
+
+	if err := repo.Suspend(1, models.AccountReadOnly, "Billing dispute", 1); err != nil {
+		t.Fatalf("Failed to suspend account: %v", err)
+	}
+	account, err := repo.GetUserAccount(1)
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	if account.Suspension.String != models.AccountReadOnly || account.SuspensionReason.String != "Billing dispute" ||
+		!account.SuspendedAt.Valid || account.SuspendedBy.Int64 != 1 {
+		t.Errorf("Expected a read-only suspension by user 1, got %+v", account)
+	}
+
+	// Nobody can join a suspended account
+	token, err := repo.CreateInvitation(1, "new@example.com", 1, "member", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create invitation: %v", err)
+	}
+	if _, err := repo.GetInvitationByToken(token); !errors.Is(err, ErrInvitationNotFound) {
+		t.Errorf("Expected the invitation not found while suspended, got %v", err)
+	}
+
+	if err := repo.Reactivate(1); err != nil {
+		t.Fatalf("Failed to reactivate account: %v", err)
+	}
+	if _, err := repo.GetInvitationByToken(token); err != nil {
+		t.Errorf("Expected the invitation found again, got %v", err)
+	}
+	account, err = repo.GetByID(1)
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	if account.Suspension.Valid || account.SuspensionReason.Valid || account.SuspendedAt.Valid {
+		t.Errorf("Expected the suspension cleared, got %+v", account)
+	}
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// ActivityRepository reads an account's activity feed: its injections,
+// symptom logs, medication logs, inventory changes and course events in one
+// newest-first list
+type ActivityRepository struct {
+	db *database.DB
+}
+
+func NewActivityRepository(db *database.DB) *ActivityRepository {
+	return &ActivityRepository{db: db}
+}
+
+// ActivityKinds lists the kinds of entry in the feed, in the order they are
+// described to users
+var ActivityKinds = []string{
+	models.ActivityInjection,
+	models.ActivitySymptom,
+	models.ActivityMedication,
+	models.ActivityInventory,
+	models.ActivityCourse,
+}
+
+// activityCourseActions are the course audit actions that appear in the
+// feed; edits and deletions are left to the audit log
+var activityCourseActions = []string{"create", "activate", "close", "auto_close"}
+
+// ActivityFilter narrows the feed. Zero values don't filter.
+type ActivityFilter struct {
+	Kinds []string // Any of ActivityKinds
+	// CourseID keeps only entries about one course, which leaves out
+	// medication logs and inventory changes
+	CourseID int64
+}
+
+// activitySource is the query behind one kind of entry, with its
+// arguments. Every source selects the same columns in the same order.
+func activitySource(kind string, accountID int64, viewer SymptomViewer) (string, []interface{}) {
+	switch kind {
+	case models.ActivityInjection:
+		return `SELECT 'injection', i.id, i.timestamp, i.administered_by, u.username,
+				i.course_id, i.side, c.name, i.pain_level, i.dose_ml,
+				i.notes, NULL, NULL
+			FROM injections i
+			JOIN courses c ON c.id = i.course_id
+			LEFT JOIN users u ON u.id = i.administered_by
+			WHERE c.account_id = ? AND i.voided_at IS NULL`, []interface{}{accountID}
+	case models.ActivitySymptom:
+		visible, visibleArgs := viewer.Condition("s")
+		return `SELECT 'symptom', s.id, s.timestamp, s.logged_by, u.username,
+				s.course_id, 'logged', COALESCE(s.pain_location, ''), s.pain_level, NULL,
+				s.notes, NULL, NULL
+			FROM symptom_logs s
+			JOIN courses c ON c.id = s.course_id
+			LEFT JOIN users u ON u.id = s.logged_by
+			WHERE c.account_id = ? AND ` + visible, append([]interface{}{accountID}, visibleArgs...)
+	case models.ActivityMedication:
+		return `SELECT 'medication', ml.id, ml.timestamp, ml.logged_by, u.username,
+				NULL, CASE WHEN ml.taken = FALSE THEN 'missed' WHEN ml.late = TRUE THEN 'late' ELSE 'taken' END, m.name, NULL, NULL,
+				ml.notes, ml.medication_id, NULL
+			FROM medication_logs ml
+			JOIN medications m ON m.id = ml.medication_id
+			LEFT JOIN users u ON u.id = ml.logged_by
+			WHERE m.account_id = ?`, []interface{}{accountID}
+	case models.ActivityInventory:
+		// Stock used by an injection or a medication dose is shown by the
+		// injection or medication log itself
+		return `SELECT 'inventory', h.id, h.timestamp, h.performed_by, u.username,
+				NULL, h.reason, COALESCE(t.name, h.item_type), NULL, h.change_amount,
+				h.notes, NULL, h.item_type
+			FROM inventory_history h
+			LEFT JOIN inventory_item_types t ON t.account_id = ? AND t.item_type = h.item_type
+			LEFT JOIN users u ON u.id = h.performed_by
+			WHERE h.account_id = ?
+			AND COALESCE(h.reference_type, '') NOT IN ('injection', 'medication_log')`, []interface{}{accountID, accountID}
+	default:
+		args := []interface{}{accountID}
+		for _, action := range activityCourseActions {
+			args = append(args, action)
+		}
+		return `SELECT 'course', a.id, a.timestamp, a.user_id, u.username,
+				c.id, a.action, c.name, NULL, NULL,
+				NULL, NULL, NULL
+			FROM audit_logs a
+			JOIN courses c ON c.id = a.entity_id
+			LEFT JOIN users u ON u.id = a.user_id
+			WHERE a.entity_type = 'course' AND c.account_id = ?
+			AND a.action IN (?` + strings.Repeat(", ?", len(activityCourseActions)-1) + `)`, args
+	}
+}
+
+// ListPage retrieves one page of the account's activity, newest first,
+// leaving out symptom logs viewer may not see
+func (r *ActivityRepository) ListPage(accountID int64, viewer SymptomViewer, filter ActivityFilter, page PageRequest) (*Page[*models.ActivityEntry], error) {
+	var after *activityCursor
+	if page.Cursor != "" {
+		c, err := decodeActivityCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+
+	kinds := filter.Kinds
+	if len(kinds) == 0 {
+		kinds = ActivityKinds
+	}
+	var parts []string
+	var args []interface{}
+	for _, kind := range ActivityKinds {
+		if !containsString(kinds, kind) {
+			continue
+		}
+		if filter.CourseID != 0 && kind != models.ActivityInjection && kind != models.ActivitySymptom && kind != models.ActivityCourse {
+			continue
+		}
+		query, queryArgs := activitySource(kind, accountID, viewer)
+		parts = append(parts, query)
+		args = append(args, queryArgs...)
+	}
+	result := &Page[*models.ActivityEntry]{Items: []*models.ActivityEntry{}}
+	if len(parts) == 0 {
+		return result, nil
+	}
+
+	const columns = `kind, id, ts, actor_id, actor_name, course_id, action, subject, pain_level, amount, notes, medication_id, item_type`
+	feed := `WITH feed (` + columns + `) AS (` + strings.Join(parts, "\nUNION ALL\n") + `) `
+	where := ` WHERE TRUE`
+	if filter.CourseID != 0 {
+		where += ` AND feed.course_id = ?`
+		args = append(args, filter.CourseID)
+	}
+	if err := r.db.QueryRow(feed+`SELECT COUNT(*) FROM feed`+where, args...).Scan(&result.Total); err != nil {
+		return nil, fmt.Errorf("failed to count activity: %w", err)
+	}
+
+	// As with listPage, rows after the cursor are found using the cursor
+	// row's stored timestamp, which may be in a different text format than
+	// a bound time.Time
+	if after != nil {
+		where += ` AND (feed.ts, feed.kind, feed.id) < (COALESCE((SELECT ts FROM feed WHERE kind = ? AND id = ?), ?), ?, ?)`
+		args = append(args, after.Kind, after.ID, after.Timestamp, after.Kind, after.ID)
+	}
+	size := page.size()
+	args = append(args, size+1) // One extra to tell whether there's another page
+	rows, err := r.db.Query(feed+`SELECT `+columns+` FROM feed`+where+` ORDER BY feed.ts DESC, feed.kind DESC, feed.id DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list activity: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.ActivityEntry
+		if err := rows.Scan(&e.Kind, &e.ID, &e.Timestamp, &e.ActorID, &e.ActorName, &e.CourseID, &e.Action, &e.Subject,
+			&e.PainLevel, &e.Amount, &e.Notes, &e.MedicationID, &e.ItemType); err != nil {
+			return nil, fmt.Errorf("failed to scan activity: %w", err)
+		}
+		result.Items = append(result.Items, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list activity: %w", err)
+	}
+	if len(result.Items) > size {
+		result.Items = result.Items[:size]
+		last := result.Items[size-1]
+		result.NextCursor = encodeActivityCursor(last.Timestamp, last.Kind, last.ID)
+	}
+	return result, nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// activityCursor is the position of the last entry on a page of the feed.
+// IDs are only unique within a kind, so the kind is part of it.
+type activityCursor struct {
+	Timestamp time.Time
+	Kind      string
+	ID        int64
+}
+
+func encodeActivityCursor(ts time.Time, kind string, id int64) string {
+	raw := strconv.FormatInt(ts.UnixNano(), 10) + ":" + kind + ":" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeActivityCursor(s string) (*activityCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || !containsString(ActivityKinds, parts[1]) {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &activityCursor{Timestamp: time.Unix(0, nanos).UTC(), Kind: parts[1], ID: id}, nil
+}
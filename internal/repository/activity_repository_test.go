@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestActivityRepository_ListPage(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for _, stmt := range []string{
+		`INSERT INTO accounts (id, name) VALUES (2, 'Other Account')`,
+		`INSERT INTO users (id, username, password_hash) VALUES (2, 'otheruser', 'hash')`,
+		`INSERT INTO inventory_items (item_type, quantity, unit, account_id) VALUES ('swab', 10, 'count', 1)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to seed: %v", err)
+		}
+	}
+	var courseID, otherCourseID int64
+	if err := db.QueryRow(`INSERT INTO courses (name, start_date, is_active, account_id) VALUES ('Course', ?, TRUE, 1) RETURNING id`, base).Scan(&courseID); err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+	if err := db.QueryRow(`INSERT INTO courses (name, start_date, is_active, account_id) VALUES ('Other', ?, TRUE, 2) RETURNING id`, base).Scan(&otherCourseID); err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+
+	user := sql.NullInt64{Int64: 1, Valid: true}
+	audit := NewAuditRepository(db)
+	if err := audit.LogWithDetails(user, "create", "course", sql.NullInt64{Int64: courseID, Valid: true}, nil, "", ""); err != nil {
+		t.Fatalf("Failed to log course event: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE audit_logs SET timestamp = ?`, base); err != nil {
+		t.Fatalf("Failed to date course event: %v", err)
+	}
+
+	injections := NewInjectionRepository(db)
+	for i, c := range []int64{courseID, otherCourseID} {
+		injection := &models.Injection{CourseID: c, Timestamp: base.Add(time.Duration(i+1) * time.Hour), Side: "left", AdministeredBy: user}
+		if err := injections.Create(injection); err != nil {
+			t.Fatalf("Failed to create injection: %v", err)
+		}
+	}
+	symptoms := NewSymptomRepository(db)
+	for _, v := range []string{SymptomVisibilityShared, SymptomVisibilityPrivate} {
+		symptom := &models.SymptomLog{CourseID: courseID, Timestamp: base.Add(3 * time.Hour), LoggedBy: sql.NullInt64{Int64: 2, Valid: true}, Visibility: v}
+		if err := symptoms.Create(symptom); err != nil {
+			t.Fatalf("Failed to create symptom log: %v", err)
+		}
+	}
+	medications := NewMedicationRepository(db)
+	medication := &models.Medication{Name: "Estradiol", IsActive: true, AccountID: 1}
+	if err := medications.Create(medication); err != nil {
+		t.Fatalf("Failed to create medication: %v", err)
+	}
+	if err := medications.CreateLog(&models.MedicationLog{MedicationID: medication.ID, LoggedBy: user, Timestamp: base.Add(4 * time.Hour), Taken: true}); err != nil {
+		t.Fatalf("Failed to log medication: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO inventory_history (account_id, item_type, change_amount, quantity_before, quantity_after, reason, performed_by, timestamp)
+		VALUES (1, 'swab', 10, 0, 10, 'restock', 1, ?), (1, 'swab', -1, 10, 9, 'injection', 1, ?)`, base.Add(5*time.Hour), base.Add(5*time.Hour)); err != nil {
+		t.Fatalf("Failed to record inventory history: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE inventory_history SET reference_type = 'injection' WHERE reason = 'injection'`); err != nil {
+		t.Fatalf("Failed to link inventory history: %v", err)
+	}
+
+	repo := NewActivityRepository(db)
+	viewer := SymptomViewer{UserID: 1}
+
+	// Newest first, across kinds, leaving out the other account, the private
+	// symptom log and stock used by injections
+	page, err := repo.ListPage(1, viewer, ActivityFilter{}, PageRequest{Limit: 3})
+	if err != nil {
+		t.Fatalf("Failed to list activity: %v", err)
+	}
+	if page.Total != 5 || len(page.Items) != 3 || page.NextCursor == "" {
+		t.Fatalf("Expected 3 of 5 entries and a cursor, got %d of %d (%q)", len(page.Items), page.Total, page.NextCursor)
+	}
+	want := []string{models.ActivityInventory, models.ActivityMedication, models.ActivitySymptom}
+	for i, e := range page.Items {
+		if e.Kind != want[i] {
+			t.Errorf("Entry %d: expected %s, got %s", i, want[i], e.Kind)
+		}
+	}
+	if e := page.Items[0]; e.Action != "restock" || e.ItemType.String != "swab" || e.Amount.Float64 != 10 || e.ActorName.String != "testuser" {
+		t.Errorf("Unexpected inventory entry %+v", e)
+	}
+	if e := page.Items[1]; e.Action != "taken" || e.Subject != "Estradiol" || e.MedicationID.Int64 != medication.ID {
+		t.Errorf("Unexpected medication entry %+v", e)
+	}
+	if e := page.Items[2]; e.ActorName.String != "otheruser" {
+		t.Errorf("Expected the symptom log attributed to its logger, got %+v", e)
+	}
+
+	page, err = repo.ListPage(1, viewer, ActivityFilter{}, PageRequest{Limit: 3, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("Failed to list the second page: %v", err)
+	}
+	if len(page.Items) != 2 || page.NextCursor != "" {
+		t.Fatalf("Expected the last 2 entries, got %d (%q)", len(page.Items), page.NextCursor)
+	}
+	if e := page.Items[0]; e.Kind != models.ActivityInjection || e.Action != "left" || e.Subject != "Course" {
+		t.Errorf("Unexpected injection entry %+v", e)
+	}
+	if e := page.Items[1]; e.Kind != models.ActivityCourse || e.Action != "create" || e.CourseID.Int64 != courseID {
+		t.Errorf("Unexpected course entry %+v", e)
+	}
+
+	// Filtering by kind and by course
+	page, err = repo.ListPage(1, viewer, ActivityFilter{Kinds: []string{models.ActivityMedication, models.ActivitySymptom}, CourseID: courseID}, PageRequest{})
+	if err != nil || page.Total != 1 || page.Items[0].Kind != models.ActivitySymptom {
+		t.Errorf("Expected only the course's symptom log, got %+v (%v)", page, err)
+	}
+
+	if _, err := repo.ListPage(1, viewer, ActivityFilter{}, PageRequest{Cursor: "bogus"}); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
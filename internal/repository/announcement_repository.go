@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type AnnouncementRepository struct {
+	db *database.DB
+}
+
+func NewAnnouncementRepository(db *database.DB) *AnnouncementRepository {
+	return &AnnouncementRepository{db: db}
+}
+
+const announcementColumns = `id, message, severity, expires_at, created_by, created_at`
+
+// Create posts an announcement
+func (r *AnnouncementRepository) Create(a *models.Announcement) error {
+	a.CreatedAt = time.Now()
+	err := r.db.QueryRow(`
+		INSERT INTO announcements (message, severity, expires_at, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id
+	`, a.Message, a.Severity, a.ExpiresAt, a.CreatedBy, a.CreatedAt).Scan(&a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create announcement: %w", err)
+	}
+	return nil
+}
+
+// List retrieves every announcement, newest first, including expired ones
+func (r *AnnouncementRepository) List() ([]*models.Announcement, error) {
+	return r.list(`SELECT ` + announcementColumns + ` FROM announcements ORDER BY created_at DESC, id DESC`)
+}
+
+// ListActive retrieves the announcements that haven't expired by now, newest
+// first
+func (r *AnnouncementRepository) ListActive(now time.Time) ([]*models.Announcement, error) {
+	return r.list(`SELECT `+announcementColumns+` FROM announcements
+		WHERE expires_at IS NULL OR expires_at > ?
+		ORDER BY created_at DESC, id DESC`, now)
+}
+
+func (r *AnnouncementRepository) list(query string, args ...interface{}) ([]*models.Announcement, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query announcements: %w", err)
+	}
+	defer rows.Close()
+
+	var announcements []*models.Announcement
+	for rows.Next() {
+		var a models.Announcement
+		if err := rows.Scan(&a.ID, &a.Message, &a.Severity, &a.ExpiresAt, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan announcement: %w", err)
+		}
+		announcements = append(announcements, &a)
+	}
+	return announcements, rows.Err()
+}
+
+// Delete removes an announcement
+func (r *AnnouncementRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM announcements WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete announcement: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestAnnouncementRepository_ListActive(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewAnnouncementRepository(db)
+	now := time.Now()
+	for _, a := range []*models.Announcement{
+		{Message: "Standing notice", Severity: "info"},
+		{Message: "Old outage", Severity: "danger", ExpiresAt: sql.NullTime{Time: now.Add(-time.Hour), Valid: true}},
+		{Message: "Maintenance tonight", Severity: "warning", ExpiresAt: sql.NullTime{Time: now.Add(time.Hour), Valid: true}, CreatedBy: sql.NullInt64{Int64: 1, Valid: true}},
+	} {
+		if err := repo.Create(a); err != nil {
+			t.Fatalf("Failed to create announcement: %v", err)
+		}
+	}
+
+	active, err := repo.ListActive(now)
+	if err != nil {
+		t.Fatalf("Failed to list active announcements: %v", err)
+	}
+	if len(active) != 2 || active[0].Message != "Maintenance tonight" || active[1].Message != "Standing notice" {
+		t.Errorf("Expected the two unexpired announcements, newest first, got %+v", active)
+	}
+
+	all, err := repo.List()
+	if err != nil || len(all) != 3 {
+		t.Fatalf("Expected all 3 announcements, got %d (%v)", len(all), err)
+	}
+
+	if err := repo.Delete(all[0].ID); err != nil {
+		t.Fatalf("Failed to delete announcement: %v", err)
+	}
+	if err := repo.Delete(all[0].ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+}
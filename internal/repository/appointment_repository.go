@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type AppointmentRepository struct {
+	db *database.DB
+}
+
+func NewAppointmentRepository(db *database.DB) *AppointmentRepository {
+	return &AppointmentRepository{db: db}
+}
+
+const appointmentColumns = `id, account_id, title, starts_at, ends_at, location, notes, created_by, created_at, updated_at`
+
+func scanAppointment(row rowScanner) (*models.Appointment, error) {
+	var a models.Appointment
+	err := row.Scan(
+		&a.ID,
+		&a.AccountID,
+		&a.Title,
+		&a.StartsAt,
+		&a.EndsAt,
+		&a.Location,
+		&a.Notes,
+		&a.CreatedBy,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Create creates an appointment
+func (r *AppointmentRepository) Create(appointment *models.Appointment) error {
+	now := time.Now()
+	var id int64
+	err := r.db.QueryRow(`
+		INSERT INTO appointments (account_id, title, starts_at, ends_at, location, notes, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`,
+		appointment.AccountID,
+		appointment.Title,
+		appointment.StartsAt.UTC(),
+		appointment.EndsAt,
+		appointment.Location,
+		appointment.Notes,
+		appointment.CreatedBy,
+		now,
+		now,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to create appointment: %w", err)
+	}
+
+	appointment.ID = id
+	appointment.CreatedAt = now
+	appointment.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves an appointment, scoped to the account
+func (r *AppointmentRepository) GetByID(id, accountID int64) (*models.Appointment, error) {
+	query := `SELECT ` + appointmentColumns + ` FROM appointments WHERE id = ? AND account_id = ?`
+	appointment, err := scanAppointment(r.db.QueryRow(query, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appointment: %w", err)
+	}
+	return appointment, nil
+}
+
+// List retrieves an account's appointments starting in [start, end), in
+// start order. Zero times leave that end of the range open.
+func (r *AppointmentRepository) List(accountID int64, start, end time.Time) ([]*models.Appointment, error) {
+	query := `SELECT ` + appointmentColumns + ` FROM appointments WHERE account_id = ?`
+	args := []interface{}{accountID}
+	if !start.IsZero() {
+		query += " AND starts_at >= ?"
+		args = append(args, start.UTC())
+	}
+	if !end.IsZero() {
+		query += " AND starts_at < ?"
+		args = append(args, end.UTC())
+	}
+	query += " ORDER BY starts_at ASC, id ASC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query appointments: %w", err)
+	}
+	defer rows.Close()
+
+	var appointments []*models.Appointment
+	for rows.Next() {
+		appointment, err := scanAppointment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan appointment: %w", err)
+		}
+		appointments = append(appointments, appointment)
+	}
+	return appointments, rows.Err()
+}
+
+// Update updates an appointment's details
+func (r *AppointmentRepository) Update(appointment *models.Appointment) error {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE appointments
+		SET title = ?, starts_at = ?, ends_at = ?, location = ?, notes = ?, updated_at = ?
+		WHERE id = ? AND account_id = ?
+	`,
+		appointment.Title,
+		appointment.StartsAt.UTC(),
+		appointment.EndsAt,
+		appointment.Location,
+		appointment.Notes,
+		now,
+		appointment.ID,
+		appointment.AccountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update appointment: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	appointment.UpdatedAt = now
+	return nil
+}
+
+// Delete deletes an appointment
+func (r *AppointmentRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec("DELETE FROM appointments WHERE id = ? AND account_id = ?", id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete appointment: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type AttachmentRepository struct {
+	db *database.DB
+}
+
+func NewAttachmentRepository(db *database.DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+const attachmentColumns = `id, account_id, uploaded_by, original_filename, content_type, size_bytes, sha256,
+		       storage_key, thumbnail_key, width, height, created_at`
+
+// Create records metadata for a stored attachment
+func (r *AttachmentRepository) Create(attachment *models.Attachment) error {
+	query := `
+		INSERT INTO attachments (account_id, uploaded_by, original_filename, content_type, size_bytes, sha256,
+		                         storage_key, thumbnail_key, width, height, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	now := time.Now()
+	var id int64
+	err := r.db.QueryRow(query,
+		attachment.AccountID,
+		attachment.UploadedBy,
+		attachment.OriginalFilename,
+		attachment.ContentType,
+		attachment.SizeBytes,
+		attachment.SHA256,
+		attachment.StorageKey,
+		attachment.ThumbnailKey,
+		attachment.Width,
+		attachment.Height,
+		now,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+
+	attachment.ID = id
+	attachment.CreatedAt = now
+	return nil
+}
+
+// GetByID retrieves an attachment by ID within an account. Attachments on a
+// symptom log viewer can't see are treated as not found.
+func (r *AttachmentRepository) GetByID(id, accountID int64, viewer SymptomViewer) (*models.Attachment, error) {
+	visible, visibleArgs := viewer.Condition("s")
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = ? AND account_id = ?
+		AND NOT EXISTS (SELECT 1 FROM symptom_logs s WHERE s.attachment_id = attachments.id AND NOT ` + visible + `)`
+	args := append([]interface{}{id, accountID}, visibleArgs...)
+
+	var a models.Attachment
+	err := r.db.QueryRow(query, args...).Scan(
+		&a.ID,
+		&a.AccountID,
+		&a.UploadedBy,
+		&a.OriginalFilename,
+		&a.ContentType,
+		&a.SizeBytes,
+		&a.SHA256,
+		&a.StorageKey,
+		&a.ThumbnailKey,
+		&a.Width,
+		&a.Height,
+		&a.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+
+	return &a, nil
+}
+
+// ListByAccount returns every attachment recorded for an account
+func (r *AttachmentRepository) ListByAccount(accountID int64) ([]*models.Attachment, error) {
+	rows, err := r.db.Query(`SELECT `+attachmentColumns+` FROM attachments WHERE account_id = ? ORDER BY id`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []*models.Attachment
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(
+			&a.ID,
+			&a.AccountID,
+			&a.UploadedBy,
+			&a.OriginalFilename,
+			&a.ContentType,
+			&a.SizeBytes,
+			&a.SHA256,
+			&a.StorageKey,
+			&a.ThumbnailKey,
+			&a.Width,
+			&a.Height,
+			&a.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, &a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// Delete removes an attachment record. Injections and symptom logs that
+// referenced it have their attachment_id cleared by the foreign key.
+func (r *AttachmentRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec(`DELETE FROM attachments WHERE id = ? AND account_id = ?`, id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CountByStorageKey counts attachment records (in any account) that share a
+// stored file. Identical uploads are stored once, so files may only be removed
+// once this drops to zero.
+func (r *AttachmentRepository) CountByStorageKey(storageKey string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM attachments WHERE storage_key = ?`, storageKey).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count attachments by storage key: %w", err)
+	}
+	return count, nil
+}
+
+// LinkToInjection sets the attachment on an injection owned by the account
+func (r *AttachmentRepository) LinkToInjection(attachmentID, injectionID, accountID int64) error {
+	result, err := r.db.Exec(`
+		UPDATE injections SET attachment_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+		AND EXISTS (SELECT 1 FROM courses WHERE id = injections.course_id AND account_id = ?)
+	`, attachmentID, injectionID, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to link attachment to injection: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// LinkToSymptomLog sets the attachment on a symptom log owned by the account
+// that viewer can see
+func (r *AttachmentRepository) LinkToSymptomLog(attachmentID, symptomID, accountID int64, viewer SymptomViewer) error {
+	visible, visibleArgs := viewer.Condition("")
+	args := append([]interface{}{attachmentID, symptomID, accountID}, visibleArgs...)
+	result, err := r.db.Exec(`
+		UPDATE symptom_logs SET attachment_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+		AND EXISTS (SELECT 1 FROM courses WHERE id = symptom_logs.course_id AND account_id = ?)
+		AND `+visible, args...)
+	if err != nil {
+		return fmt.Errorf("failed to link attachment to symptom log: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func createTestAttachment(t *testing.T, repo *AttachmentRepository, storageKey string) *models.Attachment {
+	attachment := &models.Attachment{
+		AccountID:        1,
+		UploadedBy:       sql.NullInt64{Int64: 1, Valid: true},
+		OriginalFilename: "site.jpg",
+		ContentType:      "image/jpeg",
+		SizeBytes:        1234,
+		SHA256:           "abc123",
+		StorageKey:       storageKey,
+	}
+	if err := repo.Create(attachment); err != nil {
+		t.Fatalf("Failed to create attachment: %v", err)
+	}
+	return attachment
+}
+
+func TestAttachmentRepository_CreateGetDelete(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewAttachmentRepository(db)
+	attachment := createTestAttachment(t, repo, "ab/abc123.jpg")
+
+	retrieved, err := repo.GetByID(attachment.ID, 1, SymptomViewer{UserID: 1})
+	if err != nil {
+		t.Fatalf("Failed to get attachment: %v", err)
+	}
+	if retrieved.StorageKey != "ab/abc123.jpg" || retrieved.ContentType != "image/jpeg" {
+		t.Errorf("Unexpected attachment: %+v", retrieved)
+	}
+
+	if _, err := repo.GetByID(attachment.ID, 2, SymptomViewer{UserID: 1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for other account, got %v", err)
+	}
+
+	if err := repo.Delete(attachment.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting from other account, got %v", err)
+	}
+	if err := repo.Delete(attachment.ID, 1); err != nil {
+		t.Fatalf("Failed to delete attachment: %v", err)
+	}
+}
+
+func TestAttachmentRepository_CountByStorageKey(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewAttachmentRepository(db)
+	first := createTestAttachment(t, repo, "ab/shared.jpg")
+	createTestAttachment(t, repo, "ab/shared.jpg")
+
+	count, err := repo.CountByStorageKey("ab/shared.jpg")
+	if err != nil {
+		t.Fatalf("Failed to count attachments: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 attachments sharing the key, got %d", count)
+	}
+
+	if err := repo.Delete(first.ID, 1); err != nil {
+		t.Fatalf("Failed to delete attachment: %v", err)
+	}
+	count, _ = repo.CountByStorageKey("ab/shared.jpg")
+	if count != 1 {
+		t.Errorf("Expected 1 attachment after delete, got %d", count)
+	}
+}
+
+func TestAttachmentRepository_LinkToInjection(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	result, err := db.Exec(`INSERT INTO courses (name, start_date, is_active, account_id) VALUES ('Course', ?, 1, 1)`, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+	courseID, _ := result.LastInsertId()
+
+	injectionRepo := NewInjectionRepository(db)
+	injection := &models.Injection{CourseID: courseID, Timestamp: time.Now(), Side: "left"}
+	if err := injectionRepo.Create(injection); err != nil {
+		t.Fatalf("Failed to create injection: %v", err)
+	}
+
+	repo := NewAttachmentRepository(db)
+	attachment := createTestAttachment(t, repo, "ab/site.jpg")
+
+	if err := repo.LinkToInjection(attachment.ID, injection.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound linking from other account, got %v", err)
+	}
+	if err := repo.LinkToInjection(attachment.ID, injection.ID, 1); err != nil {
+		t.Fatalf("Failed to link attachment: %v", err)
+	}
+
+	linked, err := injectionRepo.GetByID(injection.ID, 1)
+	if err != nil {
+		t.Fatalf("Failed to get injection: %v", err)
+	}
+	if !linked.AttachmentID.Valid || linked.AttachmentID.Int64 != attachment.ID {
+		t.Errorf("Expected attachment %d on injection, got %+v", attachment.ID, linked.AttachmentID)
+	}
+
+	// Deleting the attachment clears the reference but keeps the injection
+	if err := repo.Delete(attachment.ID, 1); err != nil {
+		t.Fatalf("Failed to delete attachment: %v", err)
+	}
+	linked, err = injectionRepo.GetByID(injection.ID, 1)
+	if err != nil {
+		t.Fatalf("Injection should survive attachment deletion: %v", err)
+	}
+	if linked.AttachmentID.Valid {
+		t.Errorf("Expected attachment reference to be cleared, got %d", linked.AttachmentID.Int64)
+	}
+}
+
+func TestAttachmentRepository_LinkToPrivateSymptomLog(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (2, 'member', 'hash');
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 2, 'member');
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', '2026-03-01 00:00:00', 1);
+		INSERT INTO symptom_logs (id, course_id, logged_by, timestamp, visibility) VALUES (1, 1, 1, '2026-03-02 08:00:00', 'private');
+	`); err != nil {
+		t.Fatalf("Failed to seed symptom log: %v", err)
+	}
+
+	repo := NewAttachmentRepository(db)
+	attachment := createTestAttachment(t, repo, "ab/site.jpg")
+
+	if err := repo.LinkToSymptomLog(attachment.ID, 1, 1, SymptomViewer{UserID: 2}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound linking to another member's private log, got %v", err)
+	}
+	if err := repo.LinkToSymptomLog(attachment.ID, 1, 1, SymptomViewer{UserID: 2, SeeAll: true}); err != nil {
+		t.Errorf("Expected an owner who sees private logs to link, got %v", err)
+	}
+	if err := repo.LinkToSymptomLog(attachment.ID, 1, 1, SymptomViewer{UserID: 1}); err != nil {
+		t.Fatalf("Failed to link attachment for the author: %v", err)
+	}
+
+	if _, err := repo.GetByID(attachment.ID, 1, SymptomViewer{UserID: 2}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound getting an attachment on another member's private log, got %v", err)
+	}
+	if _, err := repo.GetByID(attachment.ID, 1, SymptomViewer{UserID: 1}); err != nil {
+		t.Errorf("Expected the author to get the attachment, got %v", err)
+	}
+}
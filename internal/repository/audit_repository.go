@@ -1,11 +1,13 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"time"
 
+	"injection-tracker/internal/auth"
 	"injection-tracker/internal/database"
 	"injection-tracker/internal/models"
 )
@@ -18,13 +20,20 @@ func NewAuditRepository(db *database.DB) *AuditRepository {
 	return &AuditRepository{db: db}
 }
 
-// Log creates a new audit log entry
+// Log creates a new audit log entry. Entries logged while an admin is
+// impersonating the user record the admin too.
 func (r *AuditRepository) Log(entry *models.AuditLog) error {
+	if !entry.ImpersonatorID.Valid {
+		entry.ImpersonatorID = ImpersonatorOf(r.db.Context())
+	}
+
 	query := `
-		INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp)
-		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details, ip_address, user_agent, impersonator_id, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		RETURNING id
 	`
-	result, err := r.db.Exec(
+	var id int64
+	err := r.db.QueryRow(
 		query,
 		entry.UserID,
 		entry.Action,
@@ -33,20 +42,23 @@ func (r *AuditRepository) Log(entry *models.AuditLog) error {
 		entry.Details,
 		entry.IPAddress,
 		entry.UserAgent,
-	)
+		entry.ImpersonatorID,
+	).Scan(&id)
 	if err != nil {
 		return fmt.Errorf("failed to create audit log: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	entry.ID = id
 	return nil
 }
 
+// ImpersonatorOf is the admin impersonating the user a request in ctx is
+// made by, for audit entries written in a transaction rather than with Log
+func ImpersonatorOf(ctx context.Context) sql.NullInt64 {
+	id := auth.Impersonator(ctx)
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
 // LogWithDetails logs an action with structured details
 func (r *AuditRepository) LogWithDetails(userID sql.NullInt64, action, entityType string, entityID sql.NullInt64, details map[string]interface{}, ipAddress, userAgent string) error {
 	var detailsJSON sql.NullString
@@ -74,7 +86,7 @@ func (r *AuditRepository) LogWithDetails(userID sql.NullInt64, action, entityTyp
 // GetByUser retrieves audit logs for a specific user
 func (r *AuditRepository) GetByUser(userID int64, limit, offset int) ([]*models.AuditLog, error) {
 	query := `
-		SELECT id, user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp
+		SELECT id, user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp, impersonator_id
 		FROM audit_logs
 		WHERE user_id = ?
 		ORDER BY timestamp DESC
@@ -92,7 +104,7 @@ func (r *AuditRepository) GetByUser(userID int64, limit, offset int) ([]*models.
 // GetByAction retrieves audit logs for a specific action
 func (r *AuditRepository) GetByAction(action string, limit, offset int) ([]*models.AuditLog, error) {
 	query := `
-		SELECT id, user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp
+		SELECT id, user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp, impersonator_id
 		FROM audit_logs
 		WHERE action = ?
 		ORDER BY timestamp DESC
@@ -110,7 +122,7 @@ func (r *AuditRepository) GetByAction(action string, limit, offset int) ([]*mode
 // GetByEntity retrieves audit logs for a specific entity
 func (r *AuditRepository) GetByEntity(entityType string, entityID int64, limit, offset int) ([]*models.AuditLog, error) {
 	query := `
-		SELECT id, user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp
+		SELECT id, user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp, impersonator_id
 		FROM audit_logs
 		WHERE entity_type = ? AND entity_id = ?
 		ORDER BY timestamp DESC
@@ -128,7 +140,7 @@ func (r *AuditRepository) GetByEntity(entityType string, entityID int64, limit,
 // GetByDateRange retrieves audit logs within a date range
 func (r *AuditRepository) GetByDateRange(startDate, endDate time.Time, limit, offset int) ([]*models.AuditLog, error) {
 	query := `
-		SELECT id, user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp
+		SELECT id, user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp, impersonator_id
 		FROM audit_logs
 		WHERE timestamp BETWEEN ? AND ?
 		ORDER BY timestamp DESC
@@ -146,14 +158,15 @@ func (r *AuditRepository) GetByDateRange(startDate, endDate time.Time, limit, of
 // GetRecentFailedLogins retrieves recent failed login attempts
 func (r *AuditRepository) GetRecentFailedLogins(minutes int, limit int) ([]*models.AuditLog, error) {
 	query := `
-		SELECT id, user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp
+		SELECT id, user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp, impersonator_id
 		FROM audit_logs
 		WHERE action = 'login_failed'
-		  AND timestamp >= datetime('now', '-' || ? || ' minutes')
+		  AND timestamp >= ?
 		ORDER BY timestamp DESC
 		LIMIT ?
 	`
-	rows, err := r.db.Query(query, minutes, limit)
+	since := time.Now().UTC().Add(-time.Duration(minutes) * time.Minute)
+	rows, err := r.db.Query(query, since, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get recent failed logins: %w", err)
 	}
@@ -162,6 +175,27 @@ func (r *AuditRepository) GetRecentFailedLogins(minutes int, limit int) ([]*mode
 	return r.scanAuditLogs(rows)
 }
 
+// GetLogins retrieves a user's sign-in attempts since a time, successful
+// and failed, newest first
+func (r *AuditRepository) GetLogins(userID int64, since time.Time, limit int) ([]*models.AuditLog, error) {
+	query := `
+		SELECT id, user_id, action, entity_type, entity_id, details, ip_address, user_agent, timestamp, impersonator_id
+		FROM audit_logs
+		WHERE user_id = ?
+		  AND action IN ('login_success', 'login_failed')
+		  AND timestamp >= ?
+		ORDER BY timestamp DESC, id DESC
+		LIMIT ?
+	`
+	rows, err := r.db.Query(query, userID, since.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logins: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanAuditLogs(rows)
+}
+
 // CountFailedLoginsByIP counts failed login attempts by IP address within a time window
 func (r *AuditRepository) CountFailedLoginsByIP(ipAddress string, minutes int) (int, error) {
 	query := `
@@ -169,16 +203,81 @@ func (r *AuditRepository) CountFailedLoginsByIP(ipAddress string, minutes int) (
 		FROM audit_logs
 		WHERE action = 'login_failed'
 		  AND ip_address = ?
-		  AND timestamp >= datetime('now', '-' || ? || ' minutes')
+		  AND timestamp >= ?
 	`
+	since := time.Now().UTC().Add(-time.Duration(minutes) * time.Minute)
 	var count int
-	err := r.db.QueryRow(query, ipAddress, minutes).Scan(&count)
+	err := r.db.QueryRow(query, ipAddress, since).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count failed logins by IP: %w", err)
 	}
 	return count, nil
 }
 
+// AuditFilter narrows ListPage. Zero values don't filter.
+type AuditFilter struct {
+	UserID         int64
+	Action         string
+	EntityType     string
+	EntityID       int64
+	ImpersonatorID int64     // What an admin did while impersonating
+	Start          time.Time // Inclusive
+	End            time.Time // Exclusive
+}
+
+// where returns the WHERE clause and arguments for the filter, aliasing
+// audit_logs as a
+func (f AuditFilter) where() (string, []interface{}) {
+	clause := ` WHERE 1=1`
+	args := []interface{}{}
+	if f.UserID != 0 {
+		clause += ` AND a.user_id = ?`
+		args = append(args, f.UserID)
+	}
+	if f.Action != "" {
+		clause += ` AND a.action = ?`
+		args = append(args, f.Action)
+	}
+	if f.EntityType != "" {
+		clause += ` AND a.entity_type = ?`
+		args = append(args, f.EntityType)
+	}
+	if f.EntityID != 0 {
+		clause += ` AND a.entity_id = ?`
+		args = append(args, f.EntityID)
+	}
+	if f.ImpersonatorID != 0 {
+		clause += ` AND a.impersonator_id = ?`
+		args = append(args, f.ImpersonatorID)
+	}
+	if !f.Start.IsZero() {
+		clause += ` AND a.timestamp >= ?`
+		args = append(args, f.Start)
+	}
+	if !f.End.IsZero() {
+		clause += ` AND a.timestamp < ?`
+		args = append(args, f.End)
+	}
+	return clause, args
+}
+
+// ListPage retrieves a page of audit logs, newest first
+func (r *AuditRepository) ListPage(filter AuditFilter, page PageRequest) (*Page[*models.AuditLog], error) {
+	where, args := filter.where()
+	from := `
+		FROM audit_logs a` + where
+
+	return listPage(r.db, page, pageQuery{
+		Columns: `a.id, a.user_id, a.action, a.entity_type, a.entity_id, a.details, a.ip_address, a.user_agent, a.timestamp, a.impersonator_id`,
+		From:    from,
+		Args:    args,
+		Table:   "audit_logs",
+		Alias:   "a",
+	}, r.scanAuditLogs, func(l *models.AuditLog) (time.Time, int64) {
+		return l.Timestamp, l.ID
+	})
+}
+
 // scanAuditLogs scans rows into audit log structs
 func (r *AuditRepository) scanAuditLogs(rows *sql.Rows) ([]*models.AuditLog, error) {
 	var logs []*models.AuditLog
@@ -194,6 +293,7 @@ func (r *AuditRepository) scanAuditLogs(rows *sql.Rows) ([]*models.AuditLog, err
 			&log.IPAddress,
 			&log.UserAgent,
 			&log.Timestamp,
+			&log.ImpersonatorID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan audit log: %w", err)
@@ -204,13 +304,47 @@ func (r *AuditRepository) scanAuditLogs(rows *sql.Rows) ([]*models.AuditLog, err
 	return logs, rows.Err()
 }
 
-// DeleteOldLogs deletes audit logs older than specified days (for maintenance)
-func (r *AuditRepository) DeleteOldLogs(days int) (int64, error) {
-	query := `
-		DELETE FROM audit_logs
-		WHERE timestamp < datetime('now', '-' || ? || ' days')
-	`
-	result, err := r.db.Exec(query, days)
+// Each calls fn for every audit log matching the filter, newest first,
+// without loading them all at once. It stops at the first error fn returns.
+func (r *AuditRepository) Each(filter AuditFilter, fn func(*models.AuditLog) error) error {
+	where, args := filter.where()
+	rows, err := r.db.Query(`
+		SELECT a.id, a.user_id, a.action, a.entity_type, a.entity_id, a.details, a.ip_address, a.user_agent, a.timestamp, a.impersonator_id
+		FROM audit_logs a`+where+`
+		ORDER BY a.timestamp DESC, a.id DESC`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var log models.AuditLog
+		err := rows.Scan(
+			&log.ID,
+			&log.UserID,
+			&log.Action,
+			&log.EntityType,
+			&log.EntityID,
+			&log.Details,
+			&log.IPAddress,
+			&log.UserAgent,
+			&log.Timestamp,
+			&log.ImpersonatorID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		if err := fn(&log); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// DeleteBefore deletes audit logs older than cutoff and returns how many
+// went
+func (r *AuditRepository) DeleteBefore(cutoff time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM audit_logs WHERE timestamp < ?`, cutoff)
 	if err != nil {
 		return 0, fmt.Errorf("failed to delete old audit logs: %w", err)
 	}
@@ -221,4 +355,9 @@ func (r *AuditRepository) DeleteOldLogs(days int) (int64, error) {
 	}
 
 	return rowsAffected, nil
+}
+
+// DeleteOldLogs deletes audit logs older than specified days (for maintenance)
+func (r *AuditRepository) DeleteOldLogs(days int) (int64, error) {
+	return r.DeleteBefore(time.Now().UTC().AddDate(0, 0, -days))
 }
\ No newline at end of file
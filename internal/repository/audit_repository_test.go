@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestAuditRepository_FilterAndPrune(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	now := time.Now().UTC()
+	for _, entry := range []struct {
+		action string
+		entity string
+		age    time.Duration
+	}{
+		{"login", "user", 0},
+		{"create", "injection", time.Hour},
+		{"create", "injection", 40 * 24 * time.Hour},
+		{"delete", "symptom", 100 * 24 * time.Hour},
+	} {
+		_, err := db.Exec(`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp) VALUES (1, ?, ?, 1, ?)`,
+			entry.action, entry.entity, now.Add(-entry.age))
+		if err != nil {
+			t.Fatalf("Failed to seed audit log: %v", err)
+		}
+	}
+
+	repo := NewAuditRepository(db)
+
+	page, err := repo.ListPage(AuditFilter{Action: "create", EntityType: "injection"}, PageRequest{Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to list audit logs: %v", err)
+	}
+	if page.Total != 2 || len(page.Items) != 1 || page.NextCursor == "" {
+		t.Errorf("Expected 1 of 2 entries with a next cursor, got %d of %d", len(page.Items), page.Total)
+	}
+
+	var actions []string
+	err = repo.Each(AuditFilter{UserID: 1, Start: now.AddDate(0, 0, -60)}, func(l *models.AuditLog) error {
+		actions = append(actions, l.Action)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate audit logs: %v", err)
+	}
+	if len(actions) != 3 || actions[0] != "login" {
+		t.Errorf("Expected the 3 newest entries, newest first, got %v", actions)
+	}
+
+	deleted, err := repo.DeleteBefore(now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("Failed to prune audit logs: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 entries pruned, got %d", deleted)
+	}
+
+	logs, err := repo.GetByUser(1, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to get audit logs: %v", err)
+	}
+	if len(logs) != 2 {
+		t.Errorf("Expected 2 entries left, got %d", len(logs))
+	}
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// BarcodeRepository stores what scanned codes stand for: the instance's NDC
+// directory and each account's own barcode mappings
+type BarcodeRepository struct {
+	db *database.DB
+}
+
+func NewBarcodeRepository(db *database.DB) *BarcodeRepository {
+	return &BarcodeRepository{db: db}
+}
+
+const (
+	barcodeMappingColumns = `id, account_id, code, item_type, label, created_by, created_at, updated_at`
+	ndcProductColumns     = `ndc, product_name, labeler, item_type, package_description, created_at, updated_at`
+)
+
+func scanBarcodeMapping(row rowScanner) (*models.BarcodeMapping, error) {
+	var m models.BarcodeMapping
+	err := row.Scan(&m.ID, &m.AccountID, &m.Code, &m.ItemType, &m.Label, &m.CreatedBy, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func scanNDCProduct(row rowScanner) (*models.NDCProduct, error) {
+	var p models.NDCProduct
+	err := row.Scan(&p.NDC, &p.ProductName, &p.Labeler, &p.ItemType, &p.PackageDescription, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetMapping retrieves the account's mapping for a code
+func (r *BarcodeRepository) GetMapping(accountID int64, code string) (*models.BarcodeMapping, error) {
+	query := `SELECT ` + barcodeMappingColumns + ` FROM barcode_mappings WHERE account_id = ? AND code = ?`
+	mapping, err := scanBarcodeMapping(r.db.QueryRow(query, accountID, code))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get barcode mapping: %w", err)
+	}
+	return mapping, nil
+}
+
+// ListMappings retrieves all of an account's mappings, by item type
+func (r *BarcodeRepository) ListMappings(accountID int64) ([]*models.BarcodeMapping, error) {
+	rows, err := r.db.Query(`SELECT `+barcodeMappingColumns+` FROM barcode_mappings
+		WHERE account_id = ? ORDER BY item_type, code`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query barcode mappings: %w", err)
+	}
+	defer rows.Close()
+
+	mappings := []*models.BarcodeMapping{}
+	for rows.Next() {
+		mapping, err := scanBarcodeMapping(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan barcode mapping: %w", err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, rows.Err()
+}
+
+// SaveMapping maps a code to an item type for the account, replacing any
+// mapping the code already had
+func (r *BarcodeRepository) SaveMapping(mapping *models.BarcodeMapping) error {
+	now := time.Now()
+	err := r.db.QueryRow(`
+		INSERT INTO barcode_mappings (account_id, code, item_type, label, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, code) DO UPDATE SET
+			item_type = excluded.item_type,
+			label = excluded.label,
+			updated_at = excluded.updated_at
+		RETURNING id, created_by, created_at
+	`,
+		mapping.AccountID,
+		mapping.Code,
+		mapping.ItemType,
+		mapping.Label,
+		mapping.CreatedBy,
+		now,
+		now,
+	).Scan(&mapping.ID, &mapping.CreatedBy, &mapping.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save barcode mapping: %w", err)
+	}
+	mapping.UpdatedAt = now
+	return nil
+}
+
+// DeleteMapping removes one of an account's mappings
+func (r *BarcodeRepository) DeleteMapping(id, accountID int64) error {
+	result, err := r.db.Exec(`DELETE FROM barcode_mappings WHERE id = ? AND account_id = ?`, id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete barcode mapping: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetNDCProduct looks up a 10-digit NDC in the directory
+func (r *BarcodeRepository) GetNDCProduct(ndc string) (*models.NDCProduct, error) {
+	query := `SELECT ` + ndcProductColumns + ` FROM ndc_products WHERE ndc = ?`
+	product, err := scanNDCProduct(r.db.QueryRow(query, ndc))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NDC product: %w", err)
+	}
+	return product, nil
+}
+
+// ListNDCProducts retrieves up to limit directory entries by NDC. A non-empty
+// q keeps those whose NDC, product name or labeler contains it.
+func (r *BarcodeRepository) ListNDCProducts(q string, limit int) ([]*models.NDCProduct, error) {
+	query := `SELECT ` + ndcProductColumns + ` FROM ndc_products`
+	var args []interface{}
+	if q = strings.TrimSpace(q); q != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(q)) + "%"
+		query += ` WHERE ndc LIKE ? ESCAPE '!' OR LOWER(product_name) LIKE ? ESCAPE '!' OR LOWER(COALESCE(labeler, '')) LIKE ? ESCAPE '!'`
+		args = append(args, pattern, pattern, pattern)
+	}
+	query += ` ORDER BY ndc LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query NDC products: %w", err)
+	}
+	defer rows.Close()
+
+	products := []*models.NDCProduct{}
+	for rows.Next() {
+		product, err := scanNDCProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan NDC product: %w", err)
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
+
+// SaveNDCProducts adds directory entries, replacing those with the same NDC,
+// in one transaction so a bad row in an import leaves the directory as it was
+func (r *BarcodeRepository) SaveNDCProducts(products []*models.NDCProduct) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	for _, p := range products {
+		err := tx.QueryRow(`
+			INSERT INTO ndc_products (ndc, product_name, labeler, item_type, package_description, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(ndc) DO UPDATE SET
+				product_name = excluded.product_name,
+				labeler = excluded.labeler,
+				item_type = excluded.item_type,
+				package_description = excluded.package_description,
+				updated_at = excluded.updated_at
+			RETURNING created_at
+		`, p.NDC, p.ProductName, p.Labeler, p.ItemType, p.PackageDescription, now, now).Scan(&p.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to save NDC product %s: %w", p.NDC, err)
+		}
+		p.UpdatedAt = now
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit NDC products: %w", err)
+	}
+	return nil
+}
+
+// DeleteNDCProduct removes an NDC from the directory
+func (r *BarcodeRepository) DeleteNDCProduct(ndc string) error {
+	result, err := r.db.Exec(`DELETE FROM ndc_products WHERE ndc = ?`, ndc)
+	if err != nil {
+		return fmt.Errorf("failed to delete NDC product: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
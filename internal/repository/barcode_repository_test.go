@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"injection-tracker/internal/models"
+)
+
+func TestBarcodeRepository(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewBarcodeRepository(db)
+	products := []*models.NDCProduct{
+		{NDC: "1234567890", ProductName: "Progesterone in Oil 50 mg/mL", Labeler: sql.NullString{String: "Acme", Valid: true}, ItemType: sql.NullString{String: "progesterone", Valid: true}},
+		{NDC: "5432187609", ProductName: "Alcohol Prep Pads"},
+	}
+	if err := repo.SaveNDCProducts(products); err != nil {
+		t.Fatalf("Failed to save NDC products: %v", err)
+	}
+
+	// Saving again replaces the entry
+	products[1].ProductName = "Alcohol Prep Pads, Medium"
+	if err := repo.SaveNDCProducts(products[1:]); err != nil {
+		t.Fatalf("Failed to resave NDC product: %v", err)
+	}
+	product, err := repo.GetNDCProduct("5432187609")
+	if err != nil {
+		t.Fatalf("Failed to get NDC product: %v", err)
+	}
+	if product.ProductName != "Alcohol Prep Pads, Medium" {
+		t.Errorf("Expected the product renamed, got %q", product.ProductName)
+	}
+
+	// A bad row leaves the directory as it was
+	bad := []*models.NDCProduct{{NDC: "1111111111", ProductName: "Fine"}, {NDC: "123", ProductName: "Too short"}}
+	if err := repo.SaveNDCProducts(bad); err == nil {
+		t.Error("Expected an error saving a short NDC")
+	}
+	if _, err := repo.GetNDCProduct("1111111111"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the import rolled back, got %v", err)
+	}
+
+	if found, _ := repo.ListNDCProducts("acme", 10); len(found) != 1 || found[0].NDC != "1234567890" {
+		t.Errorf("Expected a search by labeler to find one product, got %d", len(found))
+	}
+	if found, _ := repo.ListNDCProducts("", 10); len(found) != 2 {
+		t.Errorf("Expected 2 products, got %d", len(found))
+	}
+	if found, _ := repo.ListNDCProducts("%", 10); len(found) != 0 {
+		t.Errorf("Expected a wildcard searched literally, got %d", len(found))
+	}
+
+	mapping := &models.BarcodeMapping{AccountID: 1, Code: "1234567890", ItemType: "progesterone", CreatedBy: sql.NullInt64{Int64: 1, Valid: true}}
+	if err := repo.SaveMapping(mapping); err != nil {
+		t.Fatalf("Failed to save mapping: %v", err)
+	}
+	remapped := &models.BarcodeMapping{AccountID: 1, Code: "1234567890", ItemType: "progesterone_pio"}
+	if err := repo.SaveMapping(remapped); err != nil {
+		t.Fatalf("Failed to remap code: %v", err)
+	}
+	if remapped.ID != mapping.ID || !remapped.CreatedBy.Valid {
+		t.Errorf("Expected the existing mapping updated, got id %d (was %d)", remapped.ID, mapping.ID)
+	}
+	got, err := repo.GetMapping(1, "1234567890")
+	if err != nil {
+		t.Fatalf("Failed to get mapping: %v", err)
+	}
+	if got.ItemType != "progesterone_pio" {
+		t.Errorf("Expected the remapped item type, got %q", got.ItemType)
+	}
+	if list, _ := repo.ListMappings(1); len(list) != 1 {
+		t.Errorf("Expected 1 mapping, got %d", len(list))
+	}
+
+	if err := repo.DeleteMapping(mapping.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another account's delete to miss, got %v", err)
+	}
+	if err := repo.DeleteMapping(mapping.ID, 1); err != nil {
+		t.Fatalf("Failed to delete mapping: %v", err)
+	}
+	if _, err := repo.GetMapping(1, "1234567890"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the mapping gone, got %v", err)
+	}
+	if err := repo.DeleteNDCProduct("1234567890"); err != nil {
+		t.Fatalf("Failed to delete NDC product: %v", err)
+	}
+	if err := repo.DeleteNDCProduct("1234567890"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected deleting twice to miss, got %v", err)
+	}
+}
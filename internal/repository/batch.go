@@ -0,0 +1,18 @@
+package repository
+
+import "fmt"
+
+// BatchError is returned by the methods that change several records in one
+// transaction when one of them fails. None of the changes are kept.
+type BatchError struct {
+	Index int // Position of the record that failed
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
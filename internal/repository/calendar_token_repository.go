@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type CalendarTokenRepository struct {
+	db *database.DB
+}
+
+func NewCalendarTokenRepository(db *database.DB) *CalendarTokenRepository {
+	return &CalendarTokenRepository{db: db}
+}
+
+const calendarTokenColumns = `id, account_id, user_id, name, token_hash, created_at, last_used_at, revoked_at`
+
+// Create creates a calendar token for a user and returns it with the plain
+// token, which is not stored and can't be recovered later
+func (r *CalendarTokenRepository) Create(accountID, userID int64, name string) (*models.CalendarToken, string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	now := time.Now()
+	calendarToken := &models.CalendarToken{
+		AccountID: accountID,
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+	}
+	err = r.db.QueryRow(`
+		INSERT INTO calendar_tokens (account_id, user_id, name, token_hash, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id
+	`, accountID, userID, name, calendarToken.TokenHash, now).Scan(&calendarToken.ID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create calendar token: %w", err)
+	}
+	return calendarToken, token, nil
+}
+
+// List retrieves a user's calendar tokens that haven't been revoked
+func (r *CalendarTokenRepository) List(accountID, userID int64) ([]*models.CalendarToken, error) {
+	query := `SELECT ` + calendarTokenColumns + ` FROM calendar_tokens
+		WHERE account_id = ? AND user_id = ? AND revoked_at IS NULL
+		ORDER BY created_at ASC, id ASC`
+	rows, err := r.db.Query(query, accountID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query calendar tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*models.CalendarToken
+	for rows.Next() {
+		t, err := scanCalendarToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan calendar token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// GetByToken looks up an unrevoked calendar token from its plain value
+func (r *CalendarTokenRepository) GetByToken(token string) (*models.CalendarToken, error) {
+	query := `SELECT ` + calendarTokenColumns + ` FROM calendar_tokens WHERE token_hash = ? AND revoked_at IS NULL`
+	t, err := scanCalendarToken(r.db.QueryRow(query, hashToken(token)))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar token: %w", err)
+	}
+	return t, nil
+}
+
+// MarkUsed records when a token's feed was last fetched
+func (r *CalendarTokenRepository) MarkUsed(id int64, when time.Time) error {
+	if _, err := r.db.Exec(`UPDATE calendar_tokens SET last_used_at = ? WHERE id = ?`, when, id); err != nil {
+		return fmt.Errorf("failed to update calendar token: %w", err)
+	}
+	return nil
+}
+
+// Revoke revokes one of a user's calendar tokens
+func (r *CalendarTokenRepository) Revoke(id, accountID, userID int64) error {
+	result, err := r.db.Exec(`
+		UPDATE calendar_tokens SET revoked_at = ?
+		WHERE id = ? AND account_id = ? AND user_id = ? AND revoked_at IS NULL
+	`, time.Now(), id, accountID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke calendar token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanCalendarToken(row rowScanner) (*models.CalendarToken, error) {
+	var t models.CalendarToken
+	err := row.Scan(&t.ID, &t.AccountID, &t.UserID, &t.Name, &t.TokenHash, &t.CreatedAt, &t.LastUsedAt, &t.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
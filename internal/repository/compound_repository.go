@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type CompoundRepository struct {
+	db *database.DB
+}
+
+func NewCompoundRepository(db *database.DB) *CompoundRepository {
+	return &CompoundRepository{db: db}
+}
+
+const compoundColumns = `id, account_id, name, concentration_mg_per_ml, route, inventory_item_type, default_dose_ml,
+		       notes, is_active, created_by, created_at, updated_at`
+
+// Create creates a new compound
+func (r *CompoundRepository) Create(compound *models.Compound) error {
+	query := `
+		INSERT INTO compounds (account_id, name, concentration_mg_per_ml, route, inventory_item_type, default_dose_ml,
+		                       notes, is_active, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	now := time.Now()
+	var id int64
+	err := r.db.QueryRow(query,
+		compound.AccountID,
+		compound.Name,
+		compound.Concentration,
+		compound.Route,
+		compound.InventoryItemType,
+		compound.DefaultDoseML,
+		compound.Notes,
+		compound.IsActive,
+		compound.CreatedBy,
+		now,
+		now,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to create compound: %w", err)
+	}
+
+	compound.ID = id
+	compound.CreatedAt = now
+	compound.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves a compound by ID within an account
+func (r *CompoundRepository) GetByID(id, accountID int64) (*models.Compound, error) {
+	query := `SELECT ` + compoundColumns + ` FROM compounds WHERE id = ? AND account_id = ?`
+	return r.scanCompound(r.db.QueryRow(query, id, accountID))
+}
+
+// GetByInventoryItemType retrieves the compound whose stock is tracked under itemType
+func (r *CompoundRepository) GetByInventoryItemType(itemType string, accountID int64) (*models.Compound, error) {
+	query := `SELECT ` + compoundColumns + ` FROM compounds WHERE inventory_item_type = ? AND account_id = ?`
+	return r.scanCompound(r.db.QueryRow(query, itemType, accountID))
+}
+
+// List retrieves all compounds for an account
+func (r *CompoundRepository) List(accountID int64) ([]*models.Compound, error) {
+	query := `SELECT ` + compoundColumns + ` FROM compounds WHERE account_id = ? ORDER BY name COLLATE NOCASE`
+	return r.queryCompounds(query, accountID)
+}
+
+// ListActive retrieves the compounds available for new courses
+func (r *CompoundRepository) ListActive(accountID int64) ([]*models.Compound, error) {
+	query := `SELECT ` + compoundColumns + ` FROM compounds WHERE account_id = ? AND is_active = TRUE ORDER BY name COLLATE NOCASE`
+	return r.queryCompounds(query, accountID)
+}
+
+// Update updates a compound's details. The inventory item type is fixed at
+// creation so existing stock and history stay attached to it.
+func (r *CompoundRepository) Update(compound *models.Compound) error {
+	query := `
+		UPDATE compounds
+		SET name = ?, concentration_mg_per_ml = ?, route = ?, default_dose_ml = ?, notes = ?, is_active = ?
+		WHERE id = ? AND account_id = ?
+	`
+	result, err := r.db.Exec(query,
+		compound.Name,
+		compound.Concentration,
+		compound.Route,
+		compound.DefaultDoseML,
+		compound.Notes,
+		compound.IsActive,
+		compound.ID,
+		compound.AccountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update compound: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete deletes a compound; courses that used it fall back to progesterone
+func (r *CompoundRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec("DELETE FROM compounds WHERE id = ? AND account_id = ?", id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete compound: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CountCourses returns how many courses reference a compound
+func (r *CompoundRepository) CountCourses(id, accountID int64) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM courses WHERE compound_id = ? AND account_id = ?", id, accountID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count courses: %w", err)
+	}
+	return count, nil
+}
+
+func (r *CompoundRepository) scanCompound(row *sql.Row) (*models.Compound, error) {
+	var c models.Compound
+	err := row.Scan(
+		&c.ID,
+		&c.AccountID,
+		&c.Name,
+		&c.Concentration,
+		&c.Route,
+		&c.InventoryItemType,
+		&c.DefaultDoseML,
+		&c.Notes,
+		&c.IsActive,
+		&c.CreatedBy,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get compound: %w", err)
+	}
+
+	return &c, nil
+}
+
+func (r *CompoundRepository) queryCompounds(query string, args ...interface{}) ([]*models.Compound, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query compounds: %w", err)
+	}
+	defer rows.Close()
+
+	var compounds []*models.Compound
+	for rows.Next() {
+		var c models.Compound
+		err := rows.Scan(
+			&c.ID,
+			&c.AccountID,
+			&c.Name,
+			&c.Concentration,
+			&c.Route,
+			&c.InventoryItemType,
+			&c.DefaultDoseML,
+			&c.Notes,
+			&c.IsActive,
+			&c.CreatedBy,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan compound: %w", err)
+		}
+		compounds = append(compounds, &c)
+	}
+
+	return compounds, rows.Err()
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestCompoundRepository_CRUD(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewCompoundRepository(db)
+	compound := &models.Compound{
+		AccountID:         1,
+		Name:              "Estradiol Valerate",
+		Concentration:     sql.NullFloat64{Float64: 40, Valid: true},
+		Route:             "intramuscular",
+		InventoryItemType: "estradiol_valerate",
+		DefaultDoseML:     sql.NullFloat64{Float64: 0.25, Valid: true},
+		IsActive:          true,
+	}
+	if err := repo.Create(compound); err != nil {
+		t.Fatalf("Failed to create compound: %v", err)
+	}
+
+	retrieved, err := repo.GetByInventoryItemType("estradiol_valerate", 1)
+	if err != nil {
+		t.Fatalf("Failed to get compound by item type: %v", err)
+	}
+	if retrieved.ID != compound.ID || retrieved.DefaultDoseML.Float64 != 0.25 {
+		t.Errorf("Unexpected compound: %+v", retrieved)
+	}
+
+	if _, err := repo.GetByID(compound.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for other account, got %v", err)
+	}
+
+	duplicate := *compound
+	if err := repo.Create(&duplicate); err == nil {
+		t.Error("Expected duplicate compound name to be rejected")
+	}
+
+	compound.IsActive = false
+	compound.Route = "subcutaneous"
+	if err := repo.Update(compound); err != nil {
+		t.Fatalf("Failed to update compound: %v", err)
+	}
+	active, err := repo.ListActive(1)
+	if err != nil {
+		t.Fatalf("Failed to list active compounds: %v", err)
+	}
+	if len(active) != 0 {
+		t.Errorf("Expected no active compounds, got %d", len(active))
+	}
+
+	if err := repo.Delete(compound.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting from other account, got %v", err)
+	}
+	if err := repo.Delete(compound.ID, 1); err != nil {
+		t.Fatalf("Failed to delete compound: %v", err)
+	}
+}
+
+func TestCompoundRepository_CourseReference(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewCompoundRepository(db)
+	compound := &models.Compound{AccountID: 1, Name: "B12", Route: "intramuscular", InventoryItemType: "b12", IsActive: true}
+	if err := repo.Create(compound); err != nil {
+		t.Fatalf("Failed to create compound: %v", err)
+	}
+
+	courseRepo := NewCourseRepository(db)
+	course := &models.Course{
+		Name:       "B12 Course",
+		StartDate:  time.Now(),
+		IsActive:   true,
+		CompoundID: sql.NullInt64{Int64: compound.ID, Valid: true},
+		AccountID:  1,
+	}
+	if err := courseRepo.Create(course); err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+
+	count, err := repo.CountCourses(compound.ID, 1)
+	if err != nil {
+		t.Fatalf("Failed to count courses: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 course using the compound, got %d", count)
+	}
+
+	// Deleting the compound leaves the course without one (progesterone)
+	if err := repo.Delete(compound.ID, 1); err != nil {
+		t.Fatalf("Failed to delete compound: %v", err)
+	}
+	retrieved, err := courseRepo.GetByID(course.ID, 1)
+	if err != nil {
+		t.Fatalf("Course should survive compound deletion: %v", err)
+	}
+	if retrieved.CompoundID.Valid {
+		t.Errorf("Expected compound reference to be cleared, got %d", retrieved.CompoundID.Int64)
+	}
+}
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type ConsumptionProfileRepository struct {
+	db *database.DB
+}
+
+func NewConsumptionProfileRepository(db *database.DB) *ConsumptionProfileRepository {
+	return &ConsumptionProfileRepository{db: db}
+}
+
+// GetCurrent returns the account's current profile, recording a new version
+// first if the item types' per-injection amounts have changed since the last
+func (r *ConsumptionProfileRepository) GetCurrent(accountID int64) (*models.ConsumptionProfile, error) {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	profile, err := EnsureConsumptionProfileVersion(tx, accountID, sql.NullInt64{})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return profile, nil
+}
+
+// GetVersion retrieves one recorded version of an account's profile
+func (r *ConsumptionProfileRepository) GetVersion(accountID int64, version int) (*models.ConsumptionProfile, error) {
+	profile, err := getConsumptionProfile(r.db, `WHERE account_id = ? AND version = ?`, accountID, version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return profile, err
+}
+
+// List retrieves every recorded version of an account's profile, newest first
+func (r *ConsumptionProfileRepository) List(accountID int64) ([]*models.ConsumptionProfile, error) {
+	rows, err := r.db.Query(`SELECT version FROM consumption_profiles WHERE account_id = ? ORDER BY version DESC`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query consumption profiles: %w", err)
+	}
+	var versions []int
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan consumption profile: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query consumption profiles: %w", err)
+	}
+
+	profiles := make([]*models.ConsumptionProfile, 0, len(versions))
+	for _, v := range versions {
+		profile, err := r.GetVersion(accountID, v)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// Update sets the per-injection amount of every item type in the account,
+// treating item types missing from amounts as unused, and returns the
+// resulting profile version
+func (r *ConsumptionProfileRepository) Update(accountID int64, amounts map[string]float64, userID int64) (*models.ConsumptionProfile, error) {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`
+		UPDATE inventory_item_types SET decrement_per_injection = 0
+		WHERE account_id = ? AND decrement_per_injection != 0
+	`, accountID); err != nil {
+		return nil, fmt.Errorf("failed to reset consumption profile: %w", err)
+	}
+	for itemType, amount := range amounts {
+		result, err := tx.Exec(`
+			UPDATE inventory_item_types SET decrement_per_injection = ?
+			WHERE account_id = ? AND item_type = ?
+		`, amount, accountID, itemType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update consumption of %s: %w", itemType, err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return nil, ErrNotFound
+		}
+	}
+
+	profile, err := EnsureConsumptionProfileVersion(tx, accountID, sql.NullInt64{Int64: userID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return profile, nil
+}
+
+// EnsureConsumptionProfileVersion returns the profile version matching the
+// account's current per-injection amounts, recording a new version when they
+// differ from the latest one. Item types can change their amounts one at a
+// time, so versions are created when they are first needed rather than on
+// every edit.
+func EnsureConsumptionProfileVersion(tx *sql.Tx, accountID int64, createdBy sql.NullInt64) (*models.ConsumptionProfile, error) {
+	rows, err := tx.Query(`
+		SELECT item_type, decrement_per_injection
+		FROM inventory_item_types
+		WHERE account_id = ? AND decrement_per_injection > 0
+		ORDER BY item_type
+	`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query consumption profile: %w", err)
+	}
+	var current []models.ConsumptionProfileItem
+	for rows.Next() {
+		var item models.ConsumptionProfileItem
+		if err := rows.Scan(&item.ItemType, &item.Amount); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan consumption profile: %w", err)
+		}
+		current = append(current, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query consumption profile: %w", err)
+	}
+
+	latest, err := getConsumptionProfile(tx, `WHERE account_id = ? ORDER BY version DESC LIMIT 1`, accountID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if latest != nil && sameConsumptionProfileItems(latest.Items, current) {
+		return latest, nil
+	}
+
+	profile := &models.ConsumptionProfile{
+		AccountID: accountID,
+		Version:   1,
+		Items:     current,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	if latest != nil {
+		profile.Version = latest.Version + 1
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO consumption_profiles (account_id, version, created_by, created_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING id
+	`, accountID, profile.Version, profile.CreatedBy, profile.CreatedAt).Scan(&profile.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumption profile version: %w", err)
+	}
+	for _, item := range current {
+		if _, err := tx.Exec(`
+			INSERT INTO consumption_profile_items (profile_id, item_type, amount) VALUES (?, ?, ?)
+		`, profile.ID, item.ItemType, item.Amount); err != nil {
+			return nil, fmt.Errorf("failed to create consumption profile item: %w", err)
+		}
+	}
+	return profile, nil
+}
+
+// getConsumptionProfile loads the first profile matching where, with its
+// items in item type order. Returns sql.ErrNoRows if there is none.
+func getConsumptionProfile(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, where string, args ...interface{}) (*models.ConsumptionProfile, error) {
+	var profile models.ConsumptionProfile
+	err := q.QueryRow(`
+		SELECT id, account_id, version, created_by, created_at FROM consumption_profiles `+where,
+		args...,
+	).Scan(&profile.ID, &profile.AccountID, &profile.Version, &profile.CreatedBy, &profile.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consumption profile: %w", err)
+	}
+
+	rows, err := q.Query(`
+		SELECT item_type, amount FROM consumption_profile_items WHERE profile_id = ? ORDER BY item_type
+	`, profile.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query consumption profile items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.ConsumptionProfileItem
+		if err := rows.Scan(&item.ItemType, &item.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan consumption profile item: %w", err)
+		}
+		profile.Items = append(profile.Items, item)
+	}
+	return &profile, rows.Err()
+}
+
+// sameConsumptionProfileItems compares two item lists sorted by item type
+func sameConsumptionProfileItems(a, b []models.ConsumptionProfileItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"injection-tracker/internal/models"
+)
+
+func TestConsumptionProfileRepository_Versions(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	itemTypeRepo := NewInventoryItemTypeRepository(db)
+	for _, it := range []*models.InventoryItemType{
+		{AccountID: 1, ItemType: "swab", Name: "Alcohol Swabs", Unit: "count", DecrementPerInjection: 1},
+		{AccountID: 1, ItemType: "draw_needle", Name: "Draw Needles", Unit: "count", DecrementPerInjection: 1},
+	} {
+		if err := itemTypeRepo.Create(it); err != nil {
+			t.Fatalf("Failed to create item type: %v", err)
+		}
+	}
+
+	repo := NewConsumptionProfileRepository(db)
+	first, err := repo.GetCurrent(1)
+	if err != nil {
+		t.Fatalf("Failed to get current profile: %v", err)
+	}
+	if first.Version != 1 || len(first.Items) != 2 {
+		t.Fatalf("Expected version 1 with 2 items, got version %d with %d", first.Version, len(first.Items))
+	}
+
+	again, _ := repo.GetCurrent(1)
+	if again.Version != 1 {
+		t.Errorf("Expected unchanged profile to stay at version 1, got %d", again.Version)
+	}
+
+	// Two swabs and no draw needle
+	updated, err := repo.Update(1, map[string]float64{"swab": 2}, 1)
+	if err != nil {
+		t.Fatalf("Failed to update profile: %v", err)
+	}
+	if updated.Version != 2 || len(updated.Items) != 1 || updated.Items[0] != (models.ConsumptionProfileItem{ItemType: "swab", Amount: 2}) {
+		t.Errorf("Unexpected updated profile: %+v", updated)
+	}
+	drawNeedle, _ := itemTypeRepo.GetByItemType("draw_needle", 1)
+	if drawNeedle.DecrementPerInjection != 0 {
+		t.Errorf("Expected item left out of the profile to be unused, got %v", drawNeedle.DecrementPerInjection)
+	}
+
+	// Editing an item type directly is picked up as the next version
+	swab, _ := itemTypeRepo.GetByItemType("swab", 1)
+	swab.DecrementPerInjection = 3
+	if err := itemTypeRepo.Update(swab); err != nil {
+		t.Fatalf("Failed to update item type: %v", err)
+	}
+	current, _ := repo.GetCurrent(1)
+	if current.Version != 3 {
+		t.Errorf("Expected version 3 after item type edit, got %d", current.Version)
+	}
+
+	old, err := repo.GetVersion(1, 1)
+	if err != nil || len(old.Items) != 2 {
+		t.Errorf("Expected version 1 to keep its 2 items, got %+v (%v)", old, err)
+	}
+	if _, err := repo.GetVersion(1, 9); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for missing version, got %v", err)
+	}
+
+	versions, err := repo.List(1)
+	if err != nil || len(versions) != 3 || versions[0].Version != 3 {
+		t.Errorf("Expected 3 versions newest first, got %d (%v)", len(versions), err)
+	}
+
+	if _, err := repo.Update(1, map[string]float64{"unknown": 1}, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown item type, got %v", err)
+	}
+}
@@ -20,27 +20,27 @@ func NewCourseRepository(db *database.DB) *CourseRepository {
 // Create creates a new course
 func (r *CourseRepository) Create(course *models.Course) error {
 	query := `
-		INSERT INTO courses (name, start_date, expected_end_date, is_active, notes, created_at, updated_at, created_by, account_id)
-		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?)
+		INSERT INTO courses (name, start_date, expected_end_date, is_active, notes, dose_ml, concentration_mg_per_ml, compound_id, created_at, updated_at, created_by, account_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?)
+		RETURNING id
 	`
-	result, err := r.db.Exec(query,
+	var id int64
+	err := r.db.QueryRow(query,
 		course.Name,
 		course.StartDate,
 		course.ExpectedEndDate,
 		course.IsActive,
 		course.Notes,
+		course.DoseML,
+		course.Concentration,
+		course.CompoundID,
 		course.CreatedBy,
 		course.AccountID,
-	)
+	).Scan(&id)
 	if err != nil {
 		return fmt.Errorf("failed to create course: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	course.ID = id
 	return nil
 }
@@ -48,7 +48,7 @@ func (r *CourseRepository) Create(course *models.Course) error {
 // GetByID retrieves a course by ID and account (ensures data isolation)
 func (r *CourseRepository) GetByID(id int64, accountID int64) (*models.Course, error) {
 	query := `
-		SELECT id, name, start_date, expected_end_date, actual_end_date, is_active, notes, created_at, updated_at, created_by, account_id
+		SELECT id, name, start_date, expected_end_date, actual_end_date, is_active, notes, dose_ml, concentration_mg_per_ml, compound_id, created_at, updated_at, created_by, account_id
 		FROM courses
 		WHERE id = ? AND account_id = ?
 	`
@@ -61,6 +61,9 @@ func (r *CourseRepository) GetByID(id int64, accountID int64) (*models.Course, e
 		&course.ActualEndDate,
 		&course.IsActive,
 		&course.Notes,
+		&course.DoseML,
+		&course.Concentration,
+		&course.CompoundID,
 		&course.CreatedAt,
 		&course.UpdatedAt,
 		&course.CreatedBy,
@@ -76,12 +79,13 @@ func (r *CourseRepository) GetByID(id int64, accountID int64) (*models.Course, e
 	return &course, nil
 }
 
-// GetActiveCourse retrieves the currently active course for an account
+// GetActiveCourse retrieves the most recently started active course for an account.
+// Accounts may run several courses at once; use ListActive to get all of them.
 func (r *CourseRepository) GetActiveCourse(accountID int64) (*models.Course, error) {
 	query := `
-		SELECT id, name, start_date, expected_end_date, actual_end_date, is_active, notes, created_at, updated_at, created_by, account_id
+		SELECT id, name, start_date, expected_end_date, actual_end_date, is_active, notes, dose_ml, concentration_mg_per_ml, compound_id, created_at, updated_at, created_by, account_id
 		FROM courses
-		WHERE is_active = 1 AND account_id = ?
+		WHERE is_active = TRUE AND account_id = ?
 		ORDER BY start_date DESC
 		LIMIT 1
 	`
@@ -94,6 +98,9 @@ func (r *CourseRepository) GetActiveCourse(accountID int64) (*models.Course, err
 		&course.ActualEndDate,
 		&course.IsActive,
 		&course.Notes,
+		&course.DoseML,
+		&course.Concentration,
+		&course.CompoundID,
 		&course.CreatedAt,
 		&course.UpdatedAt,
 		&course.CreatedBy,
@@ -113,7 +120,7 @@ func (r *CourseRepository) GetActiveCourse(accountID int64) (*models.Course, err
 func (r *CourseRepository) Update(course *models.Course, accountID int64) error {
 	query := `
 		UPDATE courses
-		SET name = ?, start_date = ?, expected_end_date = ?, actual_end_date = ?, is_active = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
+		SET name = ?, start_date = ?, expected_end_date = ?, actual_end_date = ?, is_active = ?, notes = ?, dose_ml = ?, concentration_mg_per_ml = ?, compound_id = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND account_id = ?
 	`
 	result, err := r.db.Exec(query,
@@ -123,6 +130,9 @@ func (r *CourseRepository) Update(course *models.Course, accountID int64) error
 		course.ActualEndDate,
 		course.IsActive,
 		course.Notes,
+		course.DoseML,
+		course.Concentration,
+		course.CompoundID,
 		course.ID,
 		accountID,
 	)
@@ -141,24 +151,11 @@ func (r *CourseRepository) Update(course *models.Course, accountID int64) error
 	return nil
 }
 
-// Activate sets a course as active and deactivates all other courses in the same account
+// Activate marks a course as active (only if it belongs to the account).
+// Other active courses keep running, so an account can track several at once.
 func (r *CourseRepository) Activate(id int64, accountID int64) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer func() { _ = tx.Rollback() }()
-
-	// Deactivate all courses in this account
-	query := `UPDATE courses SET is_active = 0, updated_at = CURRENT_TIMESTAMP WHERE account_id = ?`
-	_, err = tx.Exec(query, accountID)
-	if err != nil {
-		return fmt.Errorf("failed to deactivate courses: %w", err)
-	}
-
-	// Activate the specified course (only if it belongs to this account)
-	query = `UPDATE courses SET is_active = 1, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND account_id = ?`
-	result, err := tx.Exec(query, id, accountID)
+	query := `UPDATE courses SET is_active = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND account_id = ?`
+	result, err := r.db.Exec(query, id, accountID)
 	if err != nil {
 		return fmt.Errorf("failed to activate course: %w", err)
 	}
@@ -171,10 +168,6 @@ func (r *CourseRepository) Activate(id int64, accountID int64) error {
 		return ErrNotFound
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return nil
 }
 
@@ -182,7 +175,7 @@ func (r *CourseRepository) Activate(id int64, accountID int64) error {
 func (r *CourseRepository) Close(id int64, accountID int64, endDate time.Time) error {
 	query := `
 		UPDATE courses
-		SET actual_end_date = ?, is_active = 0, updated_at = CURRENT_TIMESTAMP
+		SET actual_end_date = ?, is_active = FALSE, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND account_id = ?
 	`
 	result, err := r.db.Exec(query, endDate, id, accountID)
@@ -203,22 +196,8 @@ func (r *CourseRepository) Close(id int64, accountID int64, endDate time.Time) e
 
 // Reopen reopens a closed course by clearing the actual end date and activating it (only in same account)
 func (r *CourseRepository) Reopen(id int64, accountID int64) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer func() { _ = tx.Rollback() }()
-
-	// Deactivate all courses in this account
-	query := `UPDATE courses SET is_active = 0, updated_at = CURRENT_TIMESTAMP WHERE account_id = ?`
-	_, err = tx.Exec(query, accountID)
-	if err != nil {
-		return fmt.Errorf("failed to deactivate courses: %w", err)
-	}
-
-	// Reopen and activate the specified course (only if it belongs to this account)
-	query = `UPDATE courses SET actual_end_date = NULL, is_active = 1, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND account_id = ?`
-	result, err := tx.Exec(query, id, accountID)
+	query := `UPDATE courses SET actual_end_date = NULL, is_active = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND account_id = ?`
+	result, err := r.db.Exec(query, id, accountID)
 	if err != nil {
 		return fmt.Errorf("failed to reopen course: %w", err)
 	}
@@ -231,10 +210,6 @@ func (r *CourseRepository) Reopen(id int64, accountID int64) error {
 		return ErrNotFound
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return nil
 }
 
@@ -260,7 +235,7 @@ func (r *CourseRepository) Delete(id int64, accountID int64) error {
 // List retrieves all courses for an account
 func (r *CourseRepository) List(accountID int64) ([]*models.Course, error) {
 	query := `
-		SELECT id, name, start_date, expected_end_date, actual_end_date, is_active, notes, created_at, updated_at, created_by, account_id
+		SELECT id, name, start_date, expected_end_date, actual_end_date, is_active, notes, dose_ml, concentration_mg_per_ml, compound_id, created_at, updated_at, created_by, account_id
 		FROM courses
 		WHERE account_id = ?
 		ORDER BY start_date DESC
@@ -277,9 +252,9 @@ func (r *CourseRepository) List(accountID int64) ([]*models.Course, error) {
 // ListActive retrieves all active courses for an account
 func (r *CourseRepository) ListActive(accountID int64) ([]*models.Course, error) {
 	query := `
-		SELECT id, name, start_date, expected_end_date, actual_end_date, is_active, notes, created_at, updated_at, created_by, account_id
+		SELECT id, name, start_date, expected_end_date, actual_end_date, is_active, notes, dose_ml, concentration_mg_per_ml, compound_id, created_at, updated_at, created_by, account_id
 		FROM courses
-		WHERE is_active = 1 AND account_id = ?
+		WHERE is_active = TRUE AND account_id = ?
 		ORDER BY start_date DESC
 	`
 	rows, err := r.db.Query(query, accountID)
@@ -294,9 +269,9 @@ func (r *CourseRepository) ListActive(accountID int64) ([]*models.Course, error)
 // ListCompleted retrieves all completed courses for an account
 func (r *CourseRepository) ListCompleted(accountID int64) ([]*models.Course, error) {
 	query := `
-		SELECT id, name, start_date, expected_end_date, actual_end_date, is_active, notes, created_at, updated_at, created_by, account_id
+		SELECT id, name, start_date, expected_end_date, actual_end_date, is_active, notes, dose_ml, concentration_mg_per_ml, compound_id, created_at, updated_at, created_by, account_id
 		FROM courses
-		WHERE is_active = 0 AND actual_end_date IS NOT NULL AND account_id = ?
+		WHERE is_active = FALSE AND actual_end_date IS NOT NULL AND account_id = ?
 		ORDER BY actual_end_date DESC
 	`
 	rows, err := r.db.Query(query, accountID)
@@ -321,6 +296,9 @@ func (r *CourseRepository) scanCourses(rows *sql.Rows) ([]*models.Course, error)
 			&course.ActualEndDate,
 			&course.IsActive,
 			&course.Notes,
+			&course.DoseML,
+			&course.Concentration,
+			&course.CompoundID,
 			&course.CreatedAt,
 			&course.UpdatedAt,
 			&course.CreatedBy,
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// CourseScheduleRepository stores when courses' injections fall due
+type CourseScheduleRepository struct {
+	db *database.DB
+}
+
+func NewCourseScheduleRepository(db *database.DB) *CourseScheduleRepository {
+	return &CourseScheduleRepository{db: db}
+}
+
+// formatWeekdays stores weekdays as their short names, e.g. "mon,wed,fri"
+func formatWeekdays(days []time.Weekday) sql.NullString {
+	if len(days) == 0 {
+		return sql.NullString{}
+	}
+	names := make([]string, len(days))
+	for i, d := range days {
+		names[i] = models.WeekdayName(d)
+	}
+	return sql.NullString{String: strings.Join(names, ","), Valid: true}
+}
+
+// parseWeekdays reads weekdays stored by formatWeekdays, skipping any it
+// doesn't know
+func parseWeekdays(stored sql.NullString) []time.Weekday {
+	if !stored.Valid {
+		return nil
+	}
+	var days []time.Weekday
+	for _, name := range strings.Split(stored.String, ",") {
+		if d, ok := models.ParseWeekday(name); ok {
+			days = append(days, d)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	return days
+}
+
+// scanCourseSchedule reads a row of course_id, interval_hours, weekdays,
+// time_of_day, updated_at
+func scanCourseSchedule(row rowScanner) (*models.CourseSchedule, error) {
+	var s models.CourseSchedule
+	var interval sql.NullInt64
+	var weekdays sql.NullString
+	if err := row.Scan(&s.CourseID, &interval, &weekdays, &s.TimeOfDay, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	s.IntervalHours = int(interval.Int64)
+	s.Weekdays = parseWeekdays(weekdays)
+	return &s, nil
+}
+
+// Get retrieves a course's schedule. It returns ErrNotFound if the course
+// has none, and so follows the account's settings, or isn't the account's.
+func (r *CourseScheduleRepository) Get(courseID, accountID int64) (*models.CourseSchedule, error) {
+	schedule, err := scanCourseSchedule(r.db.QueryRow(`
+		SELECT s.course_id, s.interval_hours, s.weekdays, s.time_of_day, s.updated_at
+		FROM course_schedules s
+		JOIN courses c ON c.id = s.course_id
+		WHERE s.course_id = ? AND c.account_id = ?
+	`, courseID, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get course schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// ListByAccount retrieves the schedules of the account's courses that have
+// one, by course ID
+func (r *CourseScheduleRepository) ListByAccount(accountID int64) (map[int64]*models.CourseSchedule, error) {
+	rows, err := r.db.Query(`
+		SELECT s.course_id, s.interval_hours, s.weekdays, s.time_of_day, s.updated_at
+		FROM course_schedules s
+		JOIN courses c ON c.id = s.course_id
+		WHERE c.account_id = ?
+	`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query course schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := map[int64]*models.CourseSchedule{}
+	for rows.Next() {
+		schedule, err := scanCourseSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan course schedule: %w", err)
+		}
+		schedules[schedule.CourseID] = schedule
+	}
+	return schedules, rows.Err()
+}
+
+// Set saves a course's schedule, replacing any it had. The caller checks it
+// has either IntervalHours or Weekdays. It returns ErrNotFound if the course
+// isn't the account's.
+func (r *CourseScheduleRepository) Set(schedule *models.CourseSchedule, accountID int64) error {
+	var exists bool
+	err := r.db.QueryRow(`SELECT TRUE FROM courses WHERE id = ? AND account_id = ?`, schedule.CourseID, accountID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get course: %w", err)
+	}
+
+	var interval sql.NullInt64
+	if schedule.IntervalHours > 0 {
+		interval = sql.NullInt64{Int64: int64(schedule.IntervalHours), Valid: true}
+	}
+	schedule.UpdatedAt = time.Now()
+	_, err = r.db.Exec(`
+		INSERT INTO course_schedules (course_id, interval_hours, weekdays, time_of_day, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(course_id) DO UPDATE SET
+			interval_hours = excluded.interval_hours,
+			weekdays = excluded.weekdays,
+			time_of_day = excluded.time_of_day,
+			updated_at = excluded.updated_at
+	`, schedule.CourseID, interval, formatWeekdays(schedule.Weekdays), schedule.TimeOfDay, schedule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save course schedule: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a course's schedule, so it follows the account's settings
+// again. It returns ErrNotFound if the course had none.
+func (r *CourseScheduleRepository) Delete(courseID, accountID int64) error {
+	result, err := r.db.Exec(`
+		DELETE FROM course_schedules
+		WHERE course_id = ? AND course_id IN (SELECT id FROM courses WHERE account_id = ?)
+	`, courseID, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete course schedule: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestCourseScheduleRepository_SetGetDelete(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO accounts (id) VALUES (2);
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', CURRENT_TIMESTAMP, 1);
+	`); err != nil {
+		t.Fatalf("Failed to seed course: %v", err)
+	}
+
+	repo := NewCourseScheduleRepository(db)
+	if _, err := repo.Get(1, 1); err != ErrNotFound {
+		t.Errorf("Expected no schedule yet, got %v", err)
+	}
+
+	weekly := &models.CourseSchedule{
+		CourseID:  1,
+		Weekdays:  []time.Weekday{time.Friday, time.Monday},
+		TimeOfDay: sql.NullString{String: "08:30", Valid: true},
+	}
+	if err := repo.Set(weekly, 1); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := repo.Set(weekly, 2); err != ErrNotFound {
+		t.Errorf("Expected another account's course to be refused, got %v", err)
+	}
+	got, err := repo.Get(1, 1)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(got.Weekdays) != 2 || got.Weekdays[0] != time.Monday || got.Weekdays[1] != time.Friday || got.TimeOfDay.String != "08:30" {
+		t.Errorf("Unexpected schedule: %+v", got)
+	}
+
+	// Setting it again replaces it
+	if err := repo.Set(&models.CourseSchedule{CourseID: 1, IntervalHours: 72}, 1); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	schedules, err := repo.ListByAccount(1)
+	if err != nil || len(schedules) != 1 {
+		t.Fatalf("Expected one schedule, got %d, %v", len(schedules), err)
+	}
+	if s := schedules[1]; s.IntervalHours != 72 || len(s.Weekdays) != 0 || s.TimeOfDay.Valid {
+		t.Errorf("Expected the interval to replace the weekdays, got %+v", s)
+	}
+	if _, err := repo.Get(1, 2); err != ErrNotFound {
+		t.Errorf("Expected another account not to see the schedule, got %v", err)
+	}
+
+	if err := repo.Delete(1, 2); err != ErrNotFound {
+		t.Errorf("Expected another account not to delete the schedule, got %v", err)
+	}
+	if err := repo.Delete(1, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := repo.Delete(1, 1); err != ErrNotFound {
+		t.Errorf("Expected nothing left to delete, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// DoseScheduleRepository stores courses' taper schedules
+type DoseScheduleRepository struct {
+	db *database.DB
+}
+
+func NewDoseScheduleRepository(db *database.DB) *DoseScheduleRepository {
+	return &DoseScheduleRepository{db: db}
+}
+
+// List retrieves a course's taper steps in date order. It returns
+// ErrNotFound if the course isn't the account's.
+func (r *DoseScheduleRepository) List(courseID, accountID int64) ([]*models.DoseStep, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT TRUE FROM courses WHERE id = ? AND account_id = ?`, courseID, accountID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get course: %w", err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT id, course_id, start_date, end_date, dose_ml, created_at
+		FROM course_dose_steps
+		WHERE course_id = ?
+		ORDER BY start_date
+	`, courseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dose steps: %w", err)
+	}
+	defer rows.Close()
+
+	steps := []*models.DoseStep{}
+	for rows.Next() {
+		var step models.DoseStep
+		if err := rows.Scan(&step.ID, &step.CourseID, &step.StartDate, &step.EndDate, &step.DoseML, &step.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan dose step: %w", err)
+		}
+		steps = append(steps, &step)
+	}
+	return steps, rows.Err()
+}
+
+// Replace swaps a course's taper schedule for steps; an empty list removes
+// it. The caller checks the steps don't overlap. It returns ErrNotFound if
+// the course isn't the account's.
+func (r *DoseScheduleRepository) Replace(courseID, accountID int64, steps []*models.DoseStep) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists bool
+	err = tx.QueryRow(`SELECT TRUE FROM courses WHERE id = ? AND account_id = ?`, courseID, accountID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get course: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM course_dose_steps WHERE course_id = ?`, courseID); err != nil {
+		return fmt.Errorf("failed to clear dose steps: %w", err)
+	}
+	now := time.Now()
+	for _, step := range steps {
+		step.CourseID = courseID
+		step.CreatedAt = now
+		err := tx.QueryRow(`
+			INSERT INTO course_dose_steps (course_id, start_date, end_date, dose_ml, created_at)
+			VALUES (?, ?, ?, ?, ?)
+			RETURNING id
+		`, courseID, step.StartDate, step.EndDate, step.DoseML, now).Scan(&step.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create dose step: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ScheduledDoseML returns the dose the taper schedule gives on day's
+// calendar date, and false when no step covers it
+func ScheduledDoseML(steps []*models.DoseStep, day time.Time) (float64, bool) {
+	for _, step := range steps {
+		if step.Covers(day) {
+			return step.DoseML, true
+		}
+	}
+	return 0, false
+}
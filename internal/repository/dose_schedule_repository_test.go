@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestDoseScheduleRepository_Replace(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO accounts (id) VALUES (2);
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', CURRENT_TIMESTAMP, 1);
+	`); err != nil {
+		t.Fatalf("Failed to seed course: %v", err)
+	}
+
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	repo := NewDoseScheduleRepository(db)
+	steps := []*models.DoseStep{
+		{StartDate: day("2026-03-01"), EndDate: sql.NullTime{Time: day("2026-03-14"), Valid: true}, DoseML: 1},
+		{StartDate: day("2026-03-15"), DoseML: 0.5},
+	}
+	if err := repo.Replace(1, 1, steps); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	if err := repo.Replace(1, 2, nil); err != ErrNotFound {
+		t.Errorf("Expected another account's course to be refused, got %v", err)
+	}
+
+	got, err := repo.List(1, 1)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Expected two steps, got %d, %v", len(got), err)
+	}
+	if dose, ok := ScheduledDoseML(got, day("2026-03-14")); !ok || dose != 1 {
+		t.Errorf("Expected the first step's dose on its last day, got %v, %v", dose, ok)
+	}
+	if dose, ok := ScheduledDoseML(got, day("2026-06-01")); !ok || dose != 0.5 {
+		t.Errorf("Expected the open-ended step to keep going, got %v, %v", dose, ok)
+	}
+	if _, ok := ScheduledDoseML(got, day("2026-02-28")); ok {
+		t.Error("Expected no scheduled dose before the schedule starts")
+	}
+
+	// A day late in the evening somewhere west of UTC is still that day
+	evening := time.Date(2026, 3, 14, 22, 0, 0, 0, time.FixedZone("EST", -5*3600))
+	if dose, _ := ScheduledDoseML(got, evening); dose != 1 {
+		t.Errorf("Expected the local calendar date to pick the step, got %v", dose)
+	}
+
+	if err := repo.Replace(1, 1, nil); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	if got, _ := repo.List(1, 1); len(got) != 0 {
+		t.Errorf("Expected the schedule to be cleared, got %d steps", len(got))
+	}
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type FeatureFlagRepository struct {
+	db *database.DB
+}
+
+func NewFeatureFlagRepository(db *database.DB) *FeatureFlagRepository {
+	return &FeatureFlagRepository{db: db}
+}
+
+const featureFlagColumns = `id, key, account_id, enabled, updated_by, updated_at`
+
+// List retrieves every flag the admin has set, site-wide ones first
+func (r *FeatureFlagRepository) List() ([]*models.FeatureFlagOverride, error) {
+	return r.list(`SELECT ` + featureFlagColumns + ` FROM feature_flags
+		ORDER BY key, account_id IS NOT NULL, account_id`)
+}
+
+// ForAccount retrieves the site-wide flags and the given account's own
+func (r *FeatureFlagRepository) ForAccount(accountID int64) ([]*models.FeatureFlagOverride, error) {
+	return r.list(`SELECT `+featureFlagColumns+` FROM feature_flags
+		WHERE account_id IS NULL OR account_id = ?
+		ORDER BY key, account_id IS NOT NULL`, accountID)
+}
+
+func (r *FeatureFlagRepository) list(query string, args ...interface{}) ([]*models.FeatureFlagOverride, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	var flags []*models.FeatureFlagOverride
+	for rows.Next() {
+		var f models.FeatureFlagOverride
+		if err := rows.Scan(&f.ID, &f.Key, &f.AccountID, &f.Enabled, &f.UpdatedBy, &f.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feature flag: %w", err)
+		}
+		flags = append(flags, &f)
+	}
+	return flags, rows.Err()
+}
+
+// Set switches a flag on or off, site-wide when accountID is null
+func (r *FeatureFlagRepository) Set(key string, accountID sql.NullInt64, enabled bool, updatedBy int64) error {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE feature_flags SET enabled = ?, updated_by = ?, updated_at = ?
+		WHERE key = ? AND COALESCE(account_id, 0) = ?
+	`, enabled, updatedBy, now, key, accountID.Int64)
+	if err != nil {
+		return fmt.Errorf("failed to update feature flag: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if rows > 0 {
+		return nil
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO feature_flags (key, account_id, enabled, updated_by, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, key, accountID, enabled, updatedBy, now)
+	if err != nil {
+		return fmt.Errorf("failed to create feature flag: %w", err)
+	}
+	return nil
+}
+
+// Clear removes the admin's setting of a flag, site-wide when accountID is
+// null, so it falls back to the next level
+func (r *FeatureFlagRepository) Clear(key string, accountID sql.NullInt64) error {
+	result, err := r.db.Exec(`DELETE FROM feature_flags WHERE key = ? AND COALESCE(account_id, 0) = ?`, key, accountID.Int64)
+	if err != nil {
+		return fmt.Errorf("failed to clear feature flag: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestFeatureFlagRepository_SetAndClear(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewFeatureFlagRepository(db)
+	global := sql.NullInt64{}
+	account := sql.NullInt64{Int64: 1, Valid: true}
+
+	if err := repo.Set("push_notifications", global, true, 1); err != nil {
+		t.Fatalf("Failed to set global flag: %v", err)
+	}
+	if err := repo.Set("push_notifications", account, true, 1); err != nil {
+		t.Fatalf("Failed to set account flag: %v", err)
+	}
+	// Setting again updates rather than duplicating
+	if err := repo.Set("push_notifications", account, false, 1); err != nil {
+		t.Fatalf("Failed to update account flag: %v", err)
+	}
+
+	flags, err := repo.ForAccount(1)
+	if err != nil {
+		t.Fatalf("Failed to list flags: %v", err)
+	}
+	if len(flags) != 2 || flags[0].AccountID.Valid || !flags[0].Enabled || flags[1].AccountID.Int64 != 1 || flags[1].Enabled {
+		t.Fatalf("Expected global on then account off, got %+v %+v", flags[0], flags[len(flags)-1])
+	}
+
+	others, err := repo.ForAccount(2)
+	if err != nil || len(others) != 1 {
+		t.Fatalf("Expected only the global flag for another account, got %d (%v)", len(others), err)
+	}
+
+	if err := repo.Clear("push_notifications", account); err != nil {
+		t.Fatalf("Failed to clear account flag: %v", err)
+	}
+	if err := repo.Clear("push_notifications", account); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound clearing twice, got %v", err)
+	}
+	all, err := repo.List()
+	if err != nil || len(all) != 1 {
+		t.Errorf("Expected 1 flag left, got %d (%v)", len(all), err)
+	}
+}
@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// DefaultDoseML is the volume used when neither an injection nor its course
+// specifies one
+const DefaultDoseML = 1.0
+
+// DefaultMedicationItemType is the inventory item decremented for courses
+// that have no compound
+const DefaultMedicationItemType = "progesterone"
+
+// CourseMedication is what a course injects: the inventory item its stock is
+// tracked under and the volume given per injection
+type CourseMedication struct {
+	ItemType string
+	DoseML   float64
+}
+
+// GetCourseMedication resolves a course's medication. Course settings win
+// over its compound's defaults; courses without a compound are progesterone.
+// It returns ErrNotFound if the course isn't the account's.
+func GetCourseMedication(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, courseID, accountID int64) (CourseMedication, error) {
+	var itemType sql.NullString
+	var courseDose, compoundDose sql.NullFloat64
+	err := q.QueryRow(`
+		SELECT m.inventory_item_type, c.dose_ml, m.default_dose_ml
+		FROM courses c
+		LEFT JOIN compounds m ON m.id = c.compound_id
+		WHERE c.id = ? AND c.account_id = ?
+	`, courseID, accountID).Scan(&itemType, &courseDose, &compoundDose)
+	if err == sql.ErrNoRows {
+		return CourseMedication{}, ErrNotFound
+	}
+	if err != nil {
+		return CourseMedication{}, fmt.Errorf("failed to get course medication: %w", err)
+	}
+
+	med := CourseMedication{ItemType: DefaultMedicationItemType, DoseML: DefaultDoseML}
+	if itemType.Valid && itemType.String != "" {
+		med.ItemType = itemType.String
+	}
+	if courseDose.Valid && courseDose.Float64 > 0 {
+		med.DoseML = courseDose.Float64
+	} else if compoundDose.Valid && compoundDose.Float64 > 0 {
+		med.DoseML = compoundDose.Float64
+	}
+	return med, nil
+}
+
+// consumedItem is an amount of one inventory item used by an injection
+type consumedItem struct {
+	itemType string
+	amount   float64
+	unit     string
+}
+
+// injectionConsumption lists what one injection uses: the medication by dose,
+// plus each other item type the account has set a per-injection decrement for
+func injectionConsumption(tx *sql.Tx, accountID int64, medicationItemType string, doseML float64) ([]consumedItem, error) {
+	items := []consumedItem{{medicationItemType, doseML, "mL"}}
+
+	rows, err := tx.Query(`
+		SELECT item_type, decrement_per_injection, unit
+		FROM inventory_item_types
+		WHERE account_id = ? AND decrement_per_injection > 0 AND item_type != ?
+		ORDER BY sort_order, name
+	`, accountID, medicationItemType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item consumedItem
+		if err := rows.Scan(&item.itemType, &item.amount, &item.unit); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// ConsumeInjectionInventory takes what one injection uses out of stock: the
+// medication by dose and everything in the consumption profile. Each item is
+// drawn from its oldest lots and the medication also from its open vial.
+// The medication lot is recorded on the injection, and the history notes the
+// profile version that applied. Stock stops at zero rather than failing the
+// injection. It returns each item's quantity beforehand so low stock can be
+// reported.
+func ConsumeInjectionInventory(tx *sql.Tx, injectionID, accountID, userID int64, medicationItemType string, doseML float64, note string) (map[string]float64, error) {
+	inventoryItems, err := injectionConsumption(tx, accountID, medicationItemType, doseML)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load consumption profile: %w", err)
+	}
+	profile, err := EnsureConsumptionProfileVersion(tx, accountID, sql.NullInt64{Int64: userID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	quantitiesBefore := make(map[string]float64, len(inventoryItems))
+	for _, item := range inventoryItems {
+		var currentQty float64
+		err := tx.QueryRow(`
+			SELECT quantity FROM inventory_items WHERE item_type = ? AND account_id = ?
+		`, item.itemType, accountID).Scan(&currentQty)
+		if err == sql.ErrNoRows {
+			// Item doesn't exist - initialize with 0 quantity
+			_, err = tx.Exec(`
+				INSERT INTO inventory_items (item_type, quantity, unit, account_id, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, item.itemType, 0.0, item.unit, accountID, now, now)
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize inventory for %s: %w", item.itemType, err)
+			}
+			currentQty = 0.0
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to check inventory for %s: %w", item.itemType, err)
+		}
+
+		quantitiesBefore[item.itemType] = currentQty
+
+		// Calculate new quantity (don't go below 0)
+		newQty := currentQty - item.amount
+		if newQty < 0 {
+			newQty = 0
+		}
+
+		_, err = tx.Exec(`
+			UPDATE inventory_items
+			SET quantity = ?, updated_at = ?
+			WHERE item_type = ? AND account_id = ?
+		`, newQty, now, item.itemType, accountID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update inventory for %s: %w", item.itemType, err)
+		}
+
+		// Draw from the oldest lots first; the medication lot is kept on the injection
+		lotID, err := ConsumeLotsFIFO(tx, accountID, item.itemType, item.amount, sql.NullInt64{Int64: injectionID, Valid: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to consume inventory lots for %s: %w", item.itemType, err)
+		}
+		if item.itemType == medicationItemType && lotID.Valid {
+			if _, err := tx.Exec(`UPDATE injections SET lot_id = ? WHERE id = ?`, lotID, injectionID); err != nil {
+				return nil, fmt.Errorf("failed to record injection lot: %w", err)
+			}
+		}
+		if item.itemType == medicationItemType {
+			if err := ConsumeVials(tx, accountID, item.itemType, item.amount, injectionID); err != nil {
+				return nil, err
+			}
+		}
+
+		// Log inventory change, noting the profile version supplies were taken by
+		var profileVersion sql.NullInt64
+		if item.itemType != medicationItemType {
+			profileVersion = sql.NullInt64{Int64: int64(profile.Version), Valid: true}
+		}
+		_, err = tx.Exec(`
+			INSERT INTO inventory_history (
+				account_id, item_type, change_amount, quantity_before, quantity_after,
+				reason, reference_id, reference_type, performed_by, timestamp, notes, profile_version
+			) VALUES (?, ?, ?, ?, ?, 'injection', ?, 'injection', ?, ?, ?, ?)
+		`, accountID, item.itemType, -item.amount, currentQty, newQty, injectionID, userID, now, note, profileVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to log inventory history for %s: %w", item.itemType, err)
+		}
+	}
+	return quantitiesBefore, nil
+}
+
+// ReturnInjectionInventory puts back whatever an injection still holds by
+// reversing each entry logged against it that hasn't been reversed: the
+// original decrement, dose corrections and, after a void and restore, the
+// re-decrement. Stock goes back to the lots and vials it was drawn from.
+func ReturnInjectionInventory(tx *sql.Tx, injectionID, accountID, userID int64, note string) error {
+	rows, err := tx.Query(`
+		SELECT h.id
+		FROM inventory_history h
+		WHERE h.reference_id = ? AND h.reference_type = 'injection' AND h.reversed_by IS NULL
+		AND NOT EXISTS (SELECT 1 FROM inventory_history r WHERE r.reversed_by = h.id)
+		ORDER BY h.id
+	`, injectionID)
+	if err != nil {
+		return fmt.Errorf("failed to query inventory history: %w", err)
+	}
+
+	var entries []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan inventory history: %w", err)
+		}
+		entries = append(entries, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query inventory history: %w", err)
+	}
+
+	for _, id := range entries {
+		if _, err := reverseEntry(tx, id, accountID, userID, sql.NullString{String: note, Valid: true}); err != nil {
+			return fmt.Errorf("failed to reverse inventory entry #%d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// AdjustInjectionDoseInventory corrects the medication stock when an
+// injection's recorded dose changes. The correction is logged against the
+// injection so voiding it later returns the full amount.
+func AdjustInjectionDoseInventory(tx *sql.Tx, injectionID, accountID, userID int64, medicationItemType string, oldDose, newDose float64) error {
+	change := oldDose - newDose // positive when less was actually used
+	if change == 0 {
+		return nil
+	}
+
+	var currentQty float64
+	err := tx.QueryRow(`
+		SELECT quantity FROM inventory_items WHERE item_type = ? AND account_id = ?
+	`, medicationItemType, accountID).Scan(&currentQty)
+	if err == sql.ErrNoRows {
+		// Nothing was tracked, so there is nothing to correct
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get current inventory for %s: %w", medicationItemType, err)
+	}
+
+	newQty := currentQty + change
+	if newQty < 0 {
+		newQty = 0
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(`
+		UPDATE inventory_items SET quantity = ?, updated_at = ? WHERE item_type = ? AND account_id = ?
+	`, newQty, now, medicationItemType, accountID); err != nil {
+		return fmt.Errorf("failed to update inventory for %s: %w", medicationItemType, err)
+	}
+
+	// Keep the vials and lots in step: a smaller dose goes back to where it
+	// came from, a larger one draws more from the open vial and oldest lot
+	if change > 0 {
+		err = ReturnInjectionLots(tx, injectionID, medicationItemType, change)
+		if err == nil {
+			err = ReturnInjectionVials(tx, injectionID, medicationItemType, change)
+		}
+	} else {
+		_, err = ConsumeLotsFIFO(tx, accountID, medicationItemType, -change, sql.NullInt64{Int64: injectionID, Valid: true})
+		if err == nil {
+			err = ConsumeVials(tx, accountID, medicationItemType, -change, injectionID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO inventory_history (
+			account_id, item_type, change_amount, quantity_before, quantity_after,
+			reason, reference_id, reference_type, performed_by, timestamp, notes
+		) VALUES (?, ?, ?, ?, ?, 'injection', ?, 'injection', ?, ?, ?)
+	`, accountID, medicationItemType, change, currentQty, newQty, injectionID, userID, now,
+		fmt.Sprintf("Dose for injection #%d changed from %s mL to %s mL", injectionID, formatML(oldDose), formatML(newDose)))
+	if err != nil {
+		return fmt.Errorf("failed to log dose correction: %w", err)
+	}
+	return nil
+}
+
+// formatML renders a volume with at most two decimals
+func formatML(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// logAudit writes an audit entry as part of tx, so it only exists if the
+// change it describes does. Like AuditRepository.Log, it records the admin
+// impersonating the user in ctx.
+func logAudit(ctx context.Context, tx *sql.Tx, userID int64, action, entityType string, entityID int64, details string) error {
+	_, err := tx.Exec(`
+		INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details, impersonator_id, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, userID, action, entityType, entityID, details, ImpersonatorOf(ctx), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+func stockOf(t *testing.T, db *database.DB, itemType string) float64 {
+	t.Helper()
+	item, err := NewInventoryRepository(db).GetByType(itemType, 1)
+	if err != nil {
+		t.Fatalf("Failed to get %s: %v", itemType, err)
+	}
+	return item.Quantity
+}
+
+func TestInjectionRepository_InventoryLifecycle(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	for _, stmt := range []string{
+		`INSERT INTO inventory_item_types (account_id, item_type, name, unit, decrement_per_injection) VALUES (1, 'swab', 'Swabs', 'count', 1)`,
+		`INSERT INTO inventory_items (item_type, quantity, unit, account_id) VALUES ('progesterone', 3, 'mL', 1)`,
+		`INSERT INTO inventory_items (item_type, quantity, unit, account_id) VALUES ('swab', 10, 'count', 1)`,
+		`INSERT INTO accounts (id, name) VALUES (2, 'Other Account')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to seed: %v", err)
+		}
+	}
+	var courseID, otherCourseID int64
+	if err := db.QueryRow(`INSERT INTO courses (name, start_date, is_active, dose_ml, account_id) VALUES ('Course', ?, TRUE, 0.5, 1) RETURNING id`, time.Now()).Scan(&courseID); err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+	if err := db.QueryRow(`INSERT INTO courses (name, start_date, is_active, account_id) VALUES ('Other', ?, TRUE, 2) RETURNING id`, time.Now()).Scan(&otherCourseID); err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+
+	repo := NewInjectionRepository(db)
+
+	// Another account's course can't be logged against
+	if _, err := repo.Record(&models.Injection{CourseID: otherCourseID, Timestamp: time.Now(), Side: "left"}, 1, 1, 0); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for another account's course, got %v", err)
+	}
+
+	injection := &models.Injection{CourseID: courseID, Timestamp: time.Now(), Side: "left"}
+	usage, err := repo.Record(injection, 1, 1, 0)
+	if err != nil {
+		t.Fatalf("Failed to record injection: %v", err)
+	}
+	if injection.ID == 0 || injection.DoseML.Float64 != 0.5 {
+		t.Errorf("Expected an ID and the course dose, got %d and %v", injection.ID, injection.DoseML)
+	}
+	if usage.MedicationItemType != "progesterone" || usage.QuantitiesBefore["progesterone"] != 3 || usage.QuantitiesBefore["swab"] != 10 {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+	if got := stockOf(t, db, "progesterone"); got != 2.5 {
+		t.Errorf("Expected 2.5 mL after recording, got %v", got)
+	}
+	if got := stockOf(t, db, "swab"); got != 9 {
+		t.Errorf("Expected 9 swabs after recording, got %v", got)
+	}
+
+	// A larger dose takes the difference
+	recorded := injection.UpdatedAt
+	injection.DoseML = sql.NullFloat64{Float64: 0.75, Valid: true}
+	if err := repo.Amend(injection, 1, 1, recorded); err != nil {
+		t.Fatalf("Failed to amend injection: %v", err)
+	}
+	if got := stockOf(t, db, "progesterone"); got != 2.25 {
+		t.Errorf("Expected 2.25 mL after the dose change, got %v", got)
+	}
+
+	// A change made against the version before that one is refused, and
+	// takes no stock
+	injection.DoseML = sql.NullFloat64{Float64: 1, Valid: true}
+	if err := repo.Amend(injection, 1, 1, recorded); err != ErrStale {
+		t.Errorf("Expected ErrStale amending an old version, got %v", err)
+	}
+	if got := stockOf(t, db, "progesterone"); got != 2.25 {
+		t.Errorf("Expected 2.25 mL after the refused change, got %v", got)
+	}
+	injection.DoseML = sql.NullFloat64{Float64: 0.75, Valid: true}
+
+	if err := repo.Amend(injection, 2, 1, injection.UpdatedAt); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound amending from another account, got %v", err)
+	}
+
+	// Voiding returns everything the injection still holds
+	if err := repo.Void(injection.ID, 1, 1, sql.NullString{String: "duplicate", Valid: true}); err != nil {
+		t.Fatalf("Failed to void injection: %v", err)
+	}
+	if got := stockOf(t, db, "progesterone"); got != 3 {
+		t.Errorf("Expected 3 mL after voiding, got %v", got)
+	}
+	if got := stockOf(t, db, "swab"); got != 10 {
+		t.Errorf("Expected 10 swabs after voiding, got %v", got)
+	}
+	if err := repo.Void(injection.ID, 1, 1, sql.NullString{}); err != ErrInjectionVoided {
+		t.Errorf("Expected ErrInjectionVoided voiding twice, got %v", err)
+	}
+	if err := repo.Amend(injection, 1, 1, injection.UpdatedAt); err != ErrInjectionVoided {
+		t.Errorf("Expected ErrInjectionVoided amending a voided injection, got %v", err)
+	}
+
+	if _, err := repo.Restore(injection.ID, 1, 1); err != nil {
+		t.Fatalf("Failed to restore injection: %v", err)
+	}
+	if got := stockOf(t, db, "progesterone"); got != 2.25 {
+		t.Errorf("Expected 2.25 mL after restoring, got %v", got)
+	}
+	if _, err := repo.Restore(injection.ID, 1, 1); err != ErrInjectionNotVoided {
+		t.Errorf("Expected ErrInjectionNotVoided restoring twice, got %v", err)
+	}
+
+	var audits int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM audit_logs WHERE entity_type = 'injection' AND entity_id = ?`, injection.ID).Scan(&audits); err != nil {
+		t.Fatalf("Failed to count audit logs: %v", err)
+	}
+	if audits != 4 {
+		t.Errorf("Expected 4 audit entries (create, update, void, restore), got %d", audits)
+	}
+}
+
+func TestInventoryRepository_ApplyAdjustment(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	def := &models.InventoryItemType{
+		AccountID:        1,
+		ItemType:         "gauze",
+		Name:             "Gauze",
+		Unit:             "count",
+		ReorderThreshold: sql.NullFloat64{Float64: 4, Valid: true},
+	}
+	repo := NewInventoryRepository(db)
+
+	// The first restock creates the item from its type
+	before, err := repo.ApplyAdjustment(def, 1, 1, InventoryAdjustment{
+		Change:    10,
+		Reason:    "restock",
+		LotNumber: sql.NullString{String: "G1", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to restock: %v", err)
+	}
+	if before != 0 {
+		t.Errorf("Expected 0 before the first restock, got %v", before)
+	}
+	item, err := repo.GetByType("gauze", 1)
+	if err != nil {
+		t.Fatalf("Failed to get item: %v", err)
+	}
+	if item.Quantity != 10 || item.LowStockThreshold.Float64 != 4 || item.LotNumber.String != "G1" {
+		t.Errorf("Unexpected item after restock: %+v", item)
+	}
+
+	if _, err := repo.ApplyAdjustment(def, 1, 1, InventoryAdjustment{Change: -11, Reason: "expired"}); !errors.Is(err, ErrNegativeStock) {
+		t.Errorf("Expected ErrNegativeStock, got %v", err)
+	}
+	if before, err := repo.ApplyAdjustment(def, 1, 1, InventoryAdjustment{Change: -3, Reason: "expired"}); err != nil || before != 10 {
+		t.Errorf("Expected removal from 10 to succeed, got %v, %v", before, err)
+	}
+
+	history, err := repo.GetHistory("gauze", 1, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("Expected 2 history entries, got %d", len(history))
+	}
+
+	item.Notes = sql.NullString{String: "top shelf", Valid: true}
+	if err := repo.UpdateDetails(item, 1); err != nil {
+		t.Fatalf("Failed to update details: %v", err)
+	}
+	item.AccountID = 2
+	if err := repo.UpdateDetails(item, 1); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for another account, got %v", err)
+	}
+}
+
+func TestInventoryRepository_ReverseEntry(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	// Less medication is on hand than a dose, so the injection's decrement
+	// stops at zero
+	if _, err := db.Exec(`INSERT INTO inventory_items (item_type, quantity, unit, account_id) VALUES ('progesterone', 0.25, 'mL', 1)`); err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+	var courseID int64
+	if err := db.QueryRow(`INSERT INTO courses (name, start_date, is_active, account_id) VALUES ('Course', ?, TRUE, 1) RETURNING id`, time.Now()).Scan(&courseID); err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+	injections := NewInjectionRepository(db)
+	injection := &models.Injection{CourseID: courseID, Timestamp: time.Now(), Side: "left"}
+	if _, err := injections.Record(injection, 1, 1, 0); err != nil {
+		t.Fatalf("Failed to record injection: %v", err)
+	}
+
+	// Only what was actually taken comes back, however often it is voided
+	for i := 0; i < 2; i++ {
+		if err := injections.Void(injection.ID, 1, 1, sql.NullString{}); err != nil {
+			t.Fatalf("Failed to void injection: %v", err)
+		}
+		if got := stockOf(t, db, "progesterone"); got != 0.25 {
+			t.Fatalf("Expected 0.25 mL back after voiding, got %v", got)
+		}
+		if _, err := injections.Restore(injection.ID, 1, 1); err != nil {
+			t.Fatalf("Failed to restore injection: %v", err)
+		}
+	}
+
+	def := &models.InventoryItemType{AccountID: 1, ItemType: "gauze", Name: "Gauze", Unit: "count"}
+	repo := NewInventoryRepository(db)
+	for _, adj := range []InventoryAdjustment{{Change: 10, Reason: "restock"}, {Change: -4, Reason: "expired"}} {
+		if _, err := repo.ApplyAdjustment(def, 1, 1, adj); err != nil {
+			t.Fatalf("Failed to adjust: %v", err)
+		}
+	}
+	history, err := repo.GetHistory("gauze", 1, 10, 0)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("Expected 2 gauze entries, got %d (%v)", len(history), err)
+	}
+	removal, restock := history[0], history[1]
+	if removal.Reason != "expired" {
+		removal, restock = restock, removal
+	}
+
+	if _, err := repo.ReverseEntry(removal.ID, 2, 1, sql.NullString{}); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound from another account, got %v", err)
+	}
+	reversal, err := repo.ReverseEntry(removal.ID, 1, 1, sql.NullString{})
+	if err != nil {
+		t.Fatalf("Failed to reverse removal: %v", err)
+	}
+	if reversal.ChangeAmount != 4 || reversal.Reason != "expired" || reversal.QuantityAfter != 10 {
+		t.Errorf("Expected the 4 expired back, got %+v", reversal)
+	}
+	if _, err := repo.ReverseEntry(removal.ID, 1, 1, sql.NullString{}); !errors.Is(err, ErrEntryReversed) {
+		t.Errorf("Expected ErrEntryReversed, got %v", err)
+	}
+	if _, err := repo.ReverseEntry(reversal.ID, 1, 1, sql.NullString{}); !errors.Is(err, ErrEntryNotReversible) {
+		t.Errorf("Expected ErrEntryNotReversible for a reversal, got %v", err)
+	}
+
+	// The restock can't be taken back once some of it is used
+	if _, err := repo.ApplyAdjustment(def, 1, 1, InventoryAdjustment{Change: -1, Reason: "manual_adjustment"}); err != nil {
+		t.Fatalf("Failed to use gauze: %v", err)
+	}
+	if _, err := repo.ReverseEntry(restock.ID, 1, 1, sql.NullString{}); !errors.Is(err, ErrNegativeStock) {
+		t.Errorf("Expected ErrNegativeStock, got %v", err)
+	}
+}
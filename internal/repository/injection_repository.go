@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"injection-tracker/internal/database"
@@ -20,10 +23,12 @@ func NewInjectionRepository(db *database.DB) *InjectionRepository {
 // Create creates a new injection record (course_id must belong to account - verified by caller)
 func (r *InjectionRepository) Create(injection *models.Injection) error {
 	query := `
-		INSERT INTO injections (course_id, administered_by, timestamp, side, site_x, site_y, pain_level, has_knots, site_reaction, notes, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO injections (course_id, administered_by, timestamp, side, site_x, site_y, pain_level, has_knots, site_reaction, notes, dose_ml, attachment_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id
 	`
-	result, err := r.db.Exec(query,
+	var id int64
+	err := r.db.QueryRow(query,
 		injection.CourseID,
 		injection.AdministeredBy,
 		injection.Timestamp,
@@ -34,16 +39,13 @@ func (r *InjectionRepository) Create(injection *models.Injection) error {
 		injection.HasKnots,
 		injection.SiteReaction,
 		injection.Notes,
-	)
+		injection.DoseML,
+		injection.AttachmentID,
+	).Scan(&id)
 	if err != nil {
 		return fmt.Errorf("failed to create injection: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	injection.ID = id
 	return nil
 }
@@ -51,7 +53,7 @@ func (r *InjectionRepository) Create(injection *models.Injection) error {
 // GetByID retrieves an injection by ID and account (ensures data isolation via course)
 func (r *InjectionRepository) GetByID(id int64, accountID int64) (*models.Injection, error) {
 	query := `
-		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.created_at, i.updated_at
+		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.dose_ml, i.attachment_id, i.lot_id, i.voided_at, i.voided_by, i.void_reason, i.created_at, i.updated_at
 		FROM injections i
 		JOIN courses c ON c.id = i.course_id
 		WHERE i.id = ? AND c.account_id = ?
@@ -69,6 +71,12 @@ func (r *InjectionRepository) GetByID(id int64, accountID int64) (*models.Inject
 		&injection.HasKnots,
 		&injection.SiteReaction,
 		&injection.Notes,
+		&injection.DoseML,
+		&injection.AttachmentID,
+		&injection.LotID,
+		&injection.VoidedAt,
+		&injection.VoidedBy,
+		&injection.VoidReason,
 		&injection.CreatedAt,
 		&injection.UpdatedAt,
 	)
@@ -79,6 +87,7 @@ func (r *InjectionRepository) GetByID(id int64, accountID int64) (*models.Inject
 		return nil, fmt.Errorf("failed to get injection: %w", err)
 	}
 
+	injection.AccountID = accountID
 	return &injection, nil
 }
 
@@ -86,7 +95,7 @@ func (r *InjectionRepository) GetByID(id int64, accountID int64) (*models.Inject
 func (r *InjectionRepository) Update(injection *models.Injection, accountID int64) error {
 	query := `
 		UPDATE injections
-		SET course_id = ?, administered_by = ?, timestamp = ?, side = ?, site_x = ?, site_y = ?, pain_level = ?, has_knots = ?, site_reaction = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
+		SET course_id = ?, administered_by = ?, timestamp = ?, side = ?, site_x = ?, site_y = ?, pain_level = ?, has_knots = ?, site_reaction = ?, notes = ?, dose_ml = ?, attachment_id = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 		AND EXISTS (SELECT 1 FROM courses WHERE id = ? AND account_id = ?)
 	`
@@ -101,6 +110,8 @@ func (r *InjectionRepository) Update(injection *models.Injection, accountID int6
 		injection.HasKnots,
 		injection.SiteReaction,
 		injection.Notes,
+		injection.DoseML,
+		injection.AttachmentID,
 		injection.ID,
 		injection.CourseID,
 		accountID,
@@ -143,13 +154,321 @@ func (r *InjectionRepository) Delete(id int64, accountID int64) error {
 	return nil
 }
 
+// ErrInjectionVoided is returned when editing or voiding a voided injection
+var ErrInjectionVoided = errors.New("injection is voided")
+
+// ErrInjectionNotVoided is returned when restoring an injection that isn't voided
+var ErrInjectionNotVoided = errors.New("injection is not voided")
+
+// InventoryUsage is what logging or restoring an injection took out of stock
+type InventoryUsage struct {
+	MedicationItemType string
+	// QuantitiesBefore is each item's quantity before the injection, so
+	// callers can tell whether it crossed the low stock threshold
+	QuantitiesBefore map[string]float64
+}
+
+// DuplicateInjectionError is returned when logging an injection that looks
+// like one already logged: on the same course and side, within the
+// duplicate window of it
+type DuplicateInjectionError struct {
+	Existing *models.Injection // The closest such injection
+}
+
+func (e *DuplicateInjectionError) Error() string {
+	return fmt.Sprintf("injection looks like a duplicate of injection %d", e.Existing.ID)
+}
+
+// Record logs an injection and takes what it uses out of the account's
+// inventory, with an audit entry, in one transaction. An invalid DoseML is
+// filled in from the course. It returns ErrNotFound if the course isn't the
+// account's, and a *DuplicateInjectionError if duplicateWindow is positive
+// and the course has an injection on the same side within it.
+func (r *InjectionRepository) Record(injection *models.Injection, accountID, userID int64, duplicateWindow time.Duration) (*InventoryUsage, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := r.checkDuplicate(tx, injection, accountID, duplicateWindow); err != nil {
+		return nil, err
+	}
+	usage, err := recordInjection(r.db.Context(), tx, injection, accountID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return usage, nil
+}
+
+// recordInjection does the work of Record within the caller's transaction
+func recordInjection(ctx context.Context, tx *sql.Tx, injection *models.Injection, accountID, userID int64) (*InventoryUsage, error) {
+	medication, err := GetCourseMedication(tx, injection.CourseID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if !injection.DoseML.Valid {
+		injection.DoseML = sql.NullFloat64{Float64: medication.DoseML, Valid: true}
+	}
+
+	now := time.Now()
+	err = tx.QueryRow(`
+		INSERT INTO injections (
+			course_id, administered_by, timestamp, side,
+			site_x, site_y, pain_level, has_knots,
+			site_reaction, notes, dose_ml, attachment_id, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`,
+		injection.CourseID,
+		injection.AdministeredBy,
+		injection.Timestamp,
+		injection.Side,
+		injection.SiteX,
+		injection.SiteY,
+		injection.PainLevel,
+		injection.HasKnots,
+		injection.SiteReaction,
+		injection.Notes,
+		injection.DoseML,
+		injection.AttachmentID,
+		now,
+		now,
+	).Scan(&injection.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create injection: %w", err)
+	}
+
+	quantitiesBefore, err := ConsumeInjectionInventory(tx, injection.ID, accountID, userID, medication.ItemType, injection.DoseML.Float64,
+		fmt.Sprintf("Auto-decremented for injection #%d", injection.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrement inventory: %w", err)
+	}
+	// Noting the lot the dose came from stamps updated_at again
+	if err := tx.QueryRow(`SELECT updated_at FROM injections WHERE id = ?`, injection.ID).Scan(&injection.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("failed to read injection version: %w", err)
+	}
+
+	if err := logAudit(ctx, tx, userID, "create", "injection", injection.ID,
+		fmt.Sprintf("Created injection on %s side (%s mL) with auto inventory decrement", injection.Side, formatML(injection.DoseML.Float64))); err != nil {
+		return nil, err
+	}
+
+	injection.AccountID = accountID
+	injection.CreatedAt = now
+	return &InventoryUsage{MedicationItemType: medication.ItemType, QuantitiesBefore: quantitiesBefore}, nil
+}
+
+// Amend saves changes to an injection's details, correcting the medication
+// stock if the dose changed. Unlike Update it refuses voided injections and
+// leaves the course alone. version is the updated_at the changes were made
+// against. It returns ErrNotFound if the injection isn't the account's, and
+// ErrStale if it has changed since version.
+func (r *InjectionRepository) Amend(injection *models.Injection, accountID, userID int64, version time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var courseID int64
+	var oldDose sql.NullFloat64
+	var voidedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT i.course_id, i.dose_ml, i.voided_at
+		FROM injections i
+		JOIN courses c ON c.id = i.course_id
+		WHERE i.id = ? AND c.account_id = ?
+	`, injection.ID, accountID).Scan(&courseID, &oldDose, &voidedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get injection: %w", err)
+	}
+	if voidedAt.Valid {
+		return ErrInjectionVoided
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE injections
+		SET timestamp = ?, side = ?, site_x = ?, site_y = ?, pain_level = ?, has_knots = ?,
+			site_reaction = ?, notes = ?, dose_ml = ?, attachment_id = ?, updated_at = ?
+		WHERE id = ? AND `+r.db.Dialect.SameTime("updated_at"),
+		injection.Timestamp,
+		injection.Side,
+		injection.SiteX,
+		injection.SiteY,
+		injection.PainLevel,
+		injection.HasKnots,
+		injection.SiteReaction,
+		injection.Notes,
+		injection.DoseML,
+		injection.AttachmentID,
+		now,
+		injection.ID,
+		version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update injection: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrStale
+	}
+
+	previous := DefaultDoseML
+	if oldDose.Valid {
+		previous = oldDose.Float64
+	}
+	if injection.DoseML.Valid && injection.DoseML.Float64 != previous {
+		medication, err := GetCourseMedication(tx, courseID, accountID)
+		if err != nil {
+			return err
+		}
+		if err := AdjustInjectionDoseInventory(tx, injection.ID, accountID, userID, medication.ItemType, previous, injection.DoseML.Float64); err != nil {
+			return fmt.Errorf("failed to adjust inventory: %w", err)
+		}
+	}
+
+	if err := logAudit(r.db.Context(), tx, userID, "update", "injection", injection.ID, "Updated injection"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	injection.UpdatedAt = now
+	return nil
+}
+
+// Void marks an injection voided and returns its inventory. The row is kept,
+// with who voided it, when and why, so it stays in the audit trail and can
+// be restored.
+func (r *InjectionRepository) Void(id, accountID, userID int64, reason sql.NullString) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var voidedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT i.voided_at
+		FROM injections i
+		JOIN courses c ON c.id = i.course_id
+		WHERE i.id = ? AND c.account_id = ?
+	`, id, accountID).Scan(&voidedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get injection: %w", err)
+	}
+	if voidedAt.Valid {
+		return ErrInjectionVoided
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(`
+		UPDATE injections SET voided_at = ?, voided_by = ?, void_reason = ?, updated_at = ?
+		WHERE id = ?
+	`, now, userID, reason, now, id); err != nil {
+		return fmt.Errorf("failed to void injection: %w", err)
+	}
+
+	if err := ReturnInjectionInventory(tx, id, accountID, userID, fmt.Sprintf("Returned for voided injection #%d", id)); err != nil {
+		return fmt.Errorf("failed to return inventory: %w", err)
+	}
+
+	details := "Voided injection with inventory returned"
+	if reason.Valid && reason.String != "" {
+		details += ": " + reason.String
+	}
+	if err := logAudit(r.db.Context(), tx, userID, "void", "injection", id, details); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Restore un-voids an injection, taking its dose and the current
+// consumption profile out of inventory again
+func (r *InjectionRepository) Restore(id, accountID, userID int64) (*InventoryUsage, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var courseID int64
+	var dose sql.NullFloat64
+	var voidedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT i.course_id, i.dose_ml, i.voided_at
+		FROM injections i
+		JOIN courses c ON c.id = i.course_id
+		WHERE i.id = ? AND c.account_id = ?
+	`, id, accountID).Scan(&courseID, &dose, &voidedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get injection: %w", err)
+	}
+	if !voidedAt.Valid {
+		return nil, ErrInjectionNotVoided
+	}
+
+	medication, err := GetCourseMedication(tx, courseID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	doseML := DefaultDoseML
+	if dose.Valid {
+		doseML = dose.Float64
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE injections SET voided_at = NULL, voided_by = NULL, void_reason = NULL, updated_at = ?
+		WHERE id = ?
+	`, time.Now(), id); err != nil {
+		return nil, fmt.Errorf("failed to restore injection: %w", err)
+	}
+
+	quantitiesBefore, err := ConsumeInjectionInventory(tx, id, accountID, userID, medication.ItemType, doseML,
+		fmt.Sprintf("Re-decremented for restored injection #%d", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrement inventory: %w", err)
+	}
+
+	if err := logAudit(r.db.Context(), tx, userID, "restore", "injection", id, "Restored voided injection with inventory decrement"); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &InventoryUsage{MedicationItemType: medication.ItemType, QuantitiesBefore: quantitiesBefore}, nil
+}
+
 // List retrieves all injections for an account with pagination
 func (r *InjectionRepository) List(accountID int64, limit, offset int) ([]*models.Injection, error) {
 	query := `
-		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.created_at, i.updated_at
+		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.dose_ml, i.attachment_id, i.lot_id, i.created_at, i.updated_at
 		FROM injections i
 		JOIN courses c ON c.id = i.course_id
-		WHERE c.account_id = ?
+		WHERE c.account_id = ? AND i.voided_at IS NULL
 		ORDER BY i.timestamp DESC
 		LIMIT ? OFFSET ?
 	`
@@ -165,10 +484,10 @@ func (r *InjectionRepository) List(accountID int64, limit, offset int) ([]*model
 // ListByCourse retrieves all injections for a specific course (course must belong to account)
 func (r *InjectionRepository) ListByCourse(courseID int64, accountID int64, limit, offset int) ([]*models.Injection, error) {
 	query := `
-		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.created_at, i.updated_at
+		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.dose_ml, i.attachment_id, i.lot_id, i.created_at, i.updated_at
 		FROM injections i
 		JOIN courses c ON c.id = i.course_id
-		WHERE i.course_id = ? AND c.account_id = ?
+		WHERE i.course_id = ? AND c.account_id = ? AND i.voided_at IS NULL
 		ORDER BY i.timestamp DESC
 		LIMIT ? OFFSET ?
 	`
@@ -184,10 +503,10 @@ func (r *InjectionRepository) ListByCourse(courseID int64, accountID int64, limi
 // ListByDateRange retrieves injections within a date range for an account
 func (r *InjectionRepository) ListByDateRange(accountID int64, startDate, endDate time.Time, limit, offset int) ([]*models.Injection, error) {
 	query := `
-		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.created_at, i.updated_at
+		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.dose_ml, i.attachment_id, i.lot_id, i.created_at, i.updated_at
 		FROM injections i
 		JOIN courses c ON c.id = i.course_id
-		WHERE c.account_id = ? AND i.timestamp BETWEEN ? AND ?
+		WHERE c.account_id = ? AND i.timestamp BETWEEN ? AND ? AND i.voided_at IS NULL
 		ORDER BY i.timestamp DESC
 		LIMIT ? OFFSET ?
 	`
@@ -203,10 +522,10 @@ func (r *InjectionRepository) ListByDateRange(accountID int64, startDate, endDat
 // GetRecent retrieves the most recent injections for an account
 func (r *InjectionRepository) GetRecent(accountID int64, count int) ([]*models.Injection, error) {
 	query := `
-		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.created_at, i.updated_at
+		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.dose_ml, i.attachment_id, i.lot_id, i.created_at, i.updated_at
 		FROM injections i
 		JOIN courses c ON c.id = i.course_id
-		WHERE c.account_id = ?
+		WHERE c.account_id = ? AND i.voided_at IS NULL
 		ORDER BY i.timestamp DESC
 		LIMIT ?
 	`
@@ -222,10 +541,10 @@ func (r *InjectionRepository) GetRecent(accountID int64, count int) ([]*models.I
 // GetLastBySide retrieves the most recent injection for a specific side for an account
 func (r *InjectionRepository) GetLastBySide(accountID int64, side string) (*models.Injection, error) {
 	query := `
-		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.created_at, i.updated_at
+		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.dose_ml, i.attachment_id, i.lot_id, i.created_at, i.updated_at
 		FROM injections i
 		JOIN courses c ON c.id = i.course_id
-		WHERE c.account_id = ? AND i.side = ?
+		WHERE c.account_id = ? AND i.side = ? AND i.voided_at IS NULL
 		ORDER BY i.timestamp DESC
 		LIMIT 1
 	`
@@ -242,6 +561,9 @@ func (r *InjectionRepository) GetLastBySide(accountID int64, side string) (*mode
 		&injection.HasKnots,
 		&injection.SiteReaction,
 		&injection.Notes,
+		&injection.DoseML,
+		&injection.AttachmentID,
+		&injection.LotID,
 		&injection.CreatedAt,
 		&injection.UpdatedAt,
 	)
@@ -261,7 +583,7 @@ func (r *InjectionRepository) CountByCourse(courseID int64, accountID int64) (in
 		SELECT COUNT(*)
 		FROM injections i
 		JOIN courses c ON c.id = i.course_id
-		WHERE i.course_id = ? AND c.account_id = ?
+		WHERE i.course_id = ? AND c.account_id = ? AND i.voided_at IS NULL
 	`
 	var count int64
 	err := r.db.QueryRow(query, courseID, accountID).Scan(&count)
@@ -277,7 +599,7 @@ func (r *InjectionRepository) CountByDateRange(accountID int64, startDate, endDa
 		SELECT COUNT(*)
 		FROM injections i
 		JOIN courses c ON c.id = i.course_id
-		WHERE c.account_id = ? AND i.timestamp BETWEEN ? AND ?
+		WHERE c.account_id = ? AND i.timestamp BETWEEN ? AND ? AND i.voided_at IS NULL
 	`
 	var count int64
 	err := r.db.QueryRow(query, accountID, startDate, endDate).Scan(&count)
@@ -290,13 +612,13 @@ func (r *InjectionRepository) CountByDateRange(accountID int64, startDate, endDa
 // GetSiteHistory retrieves injection sites within the last N days for heat map visualization (for an account)
 func (r *InjectionRepository) GetSiteHistory(accountID int64, side string, days int) ([]*models.Injection, error) {
 	query := `
-		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.created_at, i.updated_at
+		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.dose_ml, i.attachment_id, i.lot_id, i.created_at, i.updated_at
 		FROM injections i
 		JOIN courses c ON c.id = i.course_id
-		WHERE c.account_id = ? AND i.side = ? AND i.site_x IS NOT NULL AND i.site_y IS NOT NULL AND i.timestamp >= datetime('now', ? || ' days')
+		WHERE c.account_id = ? AND i.side = ? AND i.site_x IS NOT NULL AND i.site_y IS NOT NULL AND i.voided_at IS NULL AND i.timestamp >= ?
 		ORDER BY i.timestamp DESC
 	`
-	rows, err := r.db.Query(query, accountID, side, fmt.Sprintf("-%d", days))
+	rows, err := r.db.Query(query, accountID, side, time.Now().UTC().AddDate(0, 0, -days))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get site history: %w", err)
 	}
@@ -305,6 +627,200 @@ func (r *InjectionRepository) GetSiteHistory(accountID int64, side string, days
 	return r.scanInjections(rows)
 }
 
+// FindDuplicates returns the course's injections on side within window of
+// at, closest first. Voided injections don't count.
+func (r *InjectionRepository) FindDuplicates(accountID, courseID int64, side string, at time.Time, window time.Duration) ([]*models.Injection, error) {
+	return r.findDuplicates(r.db, accountID, courseID, side, at, window)
+}
+
+// checkDuplicate returns a *DuplicateInjectionError if injection is within
+// window of another on its course and side. The course is locked first, so
+// two requests logging the same dose at once can't both get past it.
+func (r *InjectionRepository) checkDuplicate(tx *sql.Tx, injection *models.Injection, accountID int64, window time.Duration) error {
+	if window <= 0 {
+		return nil
+	}
+	var courseID int64
+	err := tx.QueryRow(`SELECT id FROM courses WHERE id = ? AND account_id = ?`+r.db.Dialect.ForUpdate(),
+		injection.CourseID, accountID).Scan(&courseID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock course: %w", err)
+	}
+
+	matches, err := r.findDuplicates(tx, accountID, injection.CourseID, injection.Side, injection.Timestamp, window)
+	if err != nil {
+		return err
+	}
+	if len(matches) > 0 {
+		return &DuplicateInjectionError{Existing: matches[0]}
+	}
+	return nil
+}
+
+func (r *InjectionRepository) findDuplicates(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, accountID, courseID int64, side string, at time.Time, window time.Duration) ([]*models.Injection, error) {
+	query := `
+		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.dose_ml, i.attachment_id, i.lot_id, i.created_at, i.updated_at
+		FROM injections i
+		JOIN courses c ON c.id = i.course_id
+		WHERE c.account_id = ? AND i.course_id = ? AND i.side = ? AND i.voided_at IS NULL
+		  AND i.timestamp >= ? AND i.timestamp <= ?
+		ORDER BY i.timestamp
+	`
+	rows, err := q.Query(query, accountID, courseID, side, at.Add(-window).UTC(), at.Add(window).UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate injections: %w", err)
+	}
+	defer rows.Close()
+
+	injections, err := r.scanInjections(rows)
+	if err != nil {
+		return nil, err
+	}
+	distance := func(i *models.Injection) time.Duration {
+		if d := i.Timestamp.Sub(at); d > 0 {
+			return d
+		}
+		return at.Sub(i.Timestamp)
+	}
+	sort.SliceStable(injections, func(a, b int) bool { return distance(injections[a]) < distance(injections[b]) })
+	return injections, nil
+}
+
+// ListDuplicateGroups finds the account's likely double-logged injections:
+// runs of injections on the same course and side, each within window of the
+// one before, from since on. Groups are oldest first, as are the injections
+// in each. Voided injections are left out. courseID 0 looks at every course.
+func (r *InjectionRepository) ListDuplicateGroups(accountID, courseID int64, since time.Time, window time.Duration) ([][]*models.Injection, error) {
+	query := `
+		SELECT i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.dose_ml, i.attachment_id, i.lot_id, i.created_at, i.updated_at
+		FROM injections i
+		JOIN courses c ON c.id = i.course_id
+		WHERE c.account_id = ? AND i.voided_at IS NULL AND i.timestamp >= ?`
+	args := []interface{}{accountID, since.UTC()}
+	if courseID != 0 {
+		query += ` AND i.course_id = ?`
+		args = append(args, courseID)
+	}
+	query += ` ORDER BY i.course_id, i.side, i.timestamp, i.id`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list injections: %w", err)
+	}
+	defer rows.Close()
+
+	injections, err := r.scanInjections(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups [][]*models.Injection
+	var run []*models.Injection
+	flush := func() {
+		if len(run) > 1 {
+			groups = append(groups, run)
+		}
+		run = nil
+	}
+	for _, injection := range injections {
+		if len(run) > 0 {
+			last := run[len(run)-1]
+			if last.CourseID != injection.CourseID || last.Side != injection.Side || injection.Timestamp.Sub(last.Timestamp) > window {
+				flush()
+			}
+		}
+		run = append(run, injection)
+	}
+	flush()
+
+	sort.SliceStable(groups, func(a, b int) bool { return groups[a][0].Timestamp.Before(groups[b][0].Timestamp) })
+	return groups, nil
+}
+
+// InjectionFilter narrows ListPage. Zero values don't filter.
+type InjectionFilter struct {
+	CourseID      int64
+	Side          string
+	Start         time.Time // Inclusive
+	End           time.Time // Exclusive
+	IncludeVoided bool
+}
+
+// ListPage retrieves a page of an account's injections, newest first,
+// including the void columns
+func (r *InjectionRepository) ListPage(accountID int64, filter InjectionFilter, page PageRequest) (*Page[*models.Injection], error) {
+	from := `
+		FROM injections i
+		JOIN courses c ON c.id = i.course_id
+		WHERE c.account_id = ?`
+	args := []interface{}{accountID}
+	if !filter.IncludeVoided {
+		from += ` AND i.voided_at IS NULL`
+	}
+	if filter.CourseID != 0 {
+		from += ` AND i.course_id = ?`
+		args = append(args, filter.CourseID)
+	}
+	if filter.Side != "" {
+		from += ` AND i.side = ?`
+		args = append(args, filter.Side)
+	}
+	if !filter.Start.IsZero() {
+		from += ` AND i.timestamp >= ?`
+		args = append(args, filter.Start)
+	}
+	if !filter.End.IsZero() {
+		from += ` AND i.timestamp < ?`
+		args = append(args, filter.End)
+	}
+
+	return listPage(r.db, page, pageQuery{
+		Columns: `i.id, i.course_id, i.administered_by, i.timestamp, i.side, i.site_x, i.site_y, i.pain_level, i.has_knots, i.site_reaction, i.notes, i.dose_ml, i.attachment_id, i.lot_id, i.voided_at, i.voided_by, i.void_reason, i.created_at, i.updated_at`,
+		From:    from,
+		Args:    args,
+		Table:   "injections",
+		Alias:   "i",
+	}, func(rows *sql.Rows) ([]*models.Injection, error) {
+		var injections []*models.Injection
+		for rows.Next() {
+			var injection models.Injection
+			err := rows.Scan(
+				&injection.ID,
+				&injection.CourseID,
+				&injection.AdministeredBy,
+				&injection.Timestamp,
+				&injection.Side,
+				&injection.SiteX,
+				&injection.SiteY,
+				&injection.PainLevel,
+				&injection.HasKnots,
+				&injection.SiteReaction,
+				&injection.Notes,
+				&injection.DoseML,
+				&injection.AttachmentID,
+				&injection.LotID,
+				&injection.VoidedAt,
+				&injection.VoidedBy,
+				&injection.VoidReason,
+				&injection.CreatedAt,
+				&injection.UpdatedAt,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("failed to scan injection: %w", err)
+			}
+			injections = append(injections, &injection)
+		}
+		return injections, rows.Err()
+	}, func(i *models.Injection) (time.Time, int64) {
+		return i.Timestamp, i.ID
+	})
+}
+
 // scanInjections is a helper to scan multiple injection rows
 func (r *InjectionRepository) scanInjections(rows *sql.Rows) ([]*models.Injection, error) {
 	var injections []*models.Injection
@@ -322,6 +838,9 @@ func (r *InjectionRepository) scanInjections(rows *sql.Rows) ([]*models.Injectio
 			&injection.HasKnots,
 			&injection.SiteReaction,
 			&injection.Notes,
+			&injection.DoseML,
+			&injection.AttachmentID,
+			&injection.LotID,
 			&injection.CreatedAt,
 			&injection.UpdatedAt,
 		)
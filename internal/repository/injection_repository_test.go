@@ -36,6 +36,9 @@ func setupInjectionTestDB(t *testing.T) *database.DB {
 			actual_end_date DATE,
 			is_active BOOLEAN DEFAULT 1,
 			notes TEXT,
+			dose_ml REAL,
+			concentration_mg_per_ml REAL,
+			compound_id INTEGER,
 			account_id INTEGER NOT NULL DEFAULT 1 REFERENCES accounts(id) ON DELETE CASCADE,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -54,6 +57,12 @@ func setupInjectionTestDB(t *testing.T) *database.DB {
 			has_knots BOOLEAN DEFAULT 0,
 			site_reaction TEXT CHECK(site_reaction IN ('none', 'redness', 'swelling', 'bruising', 'other')),
 			notes TEXT,
+			dose_ml REAL,
+			attachment_id INTEGER,
+			lot_id INTEGER,
+			voided_at TIMESTAMP,
+			voided_by INTEGER,
+			void_reason TEXT,
 			account_id INTEGER NOT NULL DEFAULT 1 REFERENCES accounts(id) ON DELETE CASCADE,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
@@ -465,6 +474,48 @@ func TestInjectionRepository_CountByCourse(t *testing.T) {
 	}
 }
 
+func TestInjectionRepository_VoidedExcluded(t *testing.T) {
+	db := setupInjectionTestDB(t)
+	defer db.Close()
+
+	courseID := createTestCourse(t, db)
+	repo := NewInjectionRepository(db)
+
+	var voided *models.Injection
+	for _, side := range []string{"left", "right", "left"} {
+		injection := &models.Injection{
+			CourseID:  courseID,
+			Timestamp: time.Now(),
+			Side:      side,
+		}
+		if err := repo.Create(injection); err != nil {
+			t.Fatalf("Failed to create injection: %v", err)
+		}
+		voided = injection
+	}
+	if _, err := db.Exec(`UPDATE injections SET voided_at = ?, void_reason = 'duplicate' WHERE id = ?`, time.Now(), voided.ID); err != nil {
+		t.Fatalf("Failed to void injection: %v", err)
+	}
+
+	count, err := repo.CountByCourse(courseID, 1)
+	if err != nil || count != 2 {
+		t.Errorf("Expected 2 counted injections, got %d (%v)", count, err)
+	}
+	list, err := repo.List(1, 10, 0)
+	if err != nil || len(list) != 2 {
+		t.Errorf("Expected 2 listed injections, got %d (%v)", len(list), err)
+	}
+	last, err := repo.GetLastBySide(1, "left")
+	if err != nil || last.ID == voided.ID {
+		t.Errorf("Expected last left injection to skip the voided one, got %+v (%v)", last, err)
+	}
+
+	// Still retrievable directly, e.g. to restore it
+	if _, err := repo.GetByID(voided.ID, 1); err != nil {
+		t.Errorf("Expected voided injection to be retrievable by ID, got %v", err)
+	}
+}
+
 func TestInjectionRepository_GetSiteHistory(t *testing.T) {
 	db := setupInjectionTestDB(t)
 	defer db.Close()
@@ -524,8 +575,8 @@ func BenchmarkInjectionRepository_Create(b *testing.B) {
 	db, _ := database.Open(dbPath)
 	defer db.Close()
 
-	_, _ = db.Exec("CREATE TABLE courses (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, start_date DATE NOT NULL, expected_end_date DATE, actual_end_date DATE, is_active BOOLEAN DEFAULT 1, notes TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, created_by INTEGER);")
-	_, _ = db.Exec("CREATE TABLE injections (id INTEGER PRIMARY KEY AUTOINCREMENT, course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE, administered_by INTEGER, timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, side TEXT NOT NULL CHECK(side IN ('left', 'right')), site_x REAL, site_y REAL, pain_level INTEGER CHECK(pain_level BETWEEN 1 AND 10), has_knots BOOLEAN DEFAULT 0, site_reaction TEXT, notes TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);")
+	_, _ = db.Exec("CREATE TABLE courses (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, start_date DATE NOT NULL, expected_end_date DATE, actual_end_date DATE, is_active BOOLEAN DEFAULT 1, notes TEXT, dose_ml REAL, concentration_mg_per_ml REAL, compound_id INTEGER, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, created_by INTEGER);")
+	_, _ = db.Exec("CREATE TABLE injections (id INTEGER PRIMARY KEY AUTOINCREMENT, course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE, administered_by INTEGER, timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, side TEXT NOT NULL CHECK(side IN ('left', 'right')), site_x REAL, site_y REAL, pain_level INTEGER CHECK(pain_level BETWEEN 1 AND 10), has_knots BOOLEAN DEFAULT 0, site_reaction TEXT, notes TEXT, dose_ml REAL, attachment_id INTEGER, lot_id INTEGER, voided_at TIMESTAMP, voided_by INTEGER, void_reason TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);")
 
 	result, _ := db.Exec("INSERT INTO courses (name, start_date, is_active) VALUES (?, ?, ?)", "Test Course", time.Now(), true)
 	courseID, _ := result.LastInsertId()
@@ -542,3 +593,133 @@ func BenchmarkInjectionRepository_Create(b *testing.B) {
 		_ = repo.Create(injection)
 	}
 }
+
+func TestInjectionRepository_ListPage(t *testing.T) {
+	db := setupInjectionTestDB(t)
+	defer db.Close()
+
+	courseID := createTestCourse(t, db)
+	repo := NewInjectionRepository(db)
+
+	// Pairs share a timestamp so paging has to break ties on id
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 8; i++ {
+		injection := &models.Injection{
+			CourseID:  courseID,
+			Timestamp: base.Add(time.Duration(-(i / 2)) * time.Hour),
+			Side:      "left",
+		}
+		if err := repo.Create(injection); err != nil {
+			t.Fatalf("Failed to create injection: %v", err)
+		}
+	}
+	if _, err := db.Exec("UPDATE injections SET voided_at = CURRENT_TIMESTAMP WHERE id = 3"); err != nil {
+		t.Fatalf("Failed to void injection: %v", err)
+	}
+
+	var seen []*models.Injection
+	page := PageRequest{Limit: 3}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("Paging did not terminate")
+		}
+		result, err := repo.ListPage(1, InjectionFilter{}, page)
+		if err != nil {
+			t.Fatalf("Failed to list page: %v", err)
+		}
+		if result.Total != 7 {
+			t.Errorf("Expected total 7, got %d", result.Total)
+		}
+		seen = append(seen, result.Items...)
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor = result.NextCursor
+	}
+
+	if len(seen) != 7 {
+		t.Fatalf("Expected 7 injections across pages, got %d", len(seen))
+	}
+	ids := map[int64]bool{}
+	for i, injection := range seen {
+		if ids[injection.ID] {
+			t.Errorf("Injection %d returned twice", injection.ID)
+		}
+		ids[injection.ID] = true
+		if injection.ID == 3 {
+			t.Error("Voided injection listed")
+		}
+		if i > 0 {
+			prev := seen[i-1]
+			if prev.Timestamp.Before(injection.Timestamp) || (prev.Timestamp.Equal(injection.Timestamp) && prev.ID < injection.ID) {
+				t.Errorf("Injections out of order at %d", i)
+			}
+		}
+	}
+
+	voided, err := repo.ListPage(1, InjectionFilter{IncludeVoided: true, Start: base.Add(-time.Hour)}, PageRequest{})
+	if err != nil {
+		t.Fatalf("Failed to list with voided: %v", err)
+	}
+	if voided.Total != 4 || len(voided.Items) != 4 || voided.NextCursor != "" {
+		t.Errorf("Expected all 4 injections since the start, got total %d, %d items", voided.Total, len(voided.Items))
+	}
+
+	if _, err := repo.ListPage(1, InjectionFilter{}, PageRequest{Cursor: "not a cursor"}); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestInjectionRepository_FindDuplicates(t *testing.T) {
+	db := setupInjectionTestDB(t)
+	defer db.Close()
+
+	courseID := createTestCourse(t, db)
+	repo := NewInjectionRepository(db)
+
+	base := time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		offset time.Duration
+		side   string
+	}{
+		{0, "left"},
+		{10 * time.Minute, "left"},  // Double-logged
+		{15 * time.Minute, "right"}, // Other side
+		{48 * time.Hour, "left"},
+		{48*time.Hour + 20*time.Minute, "left"},
+		{48*time.Hour + 45*time.Minute, "left"}, // Chained to the one before
+	} {
+		injection := &models.Injection{CourseID: courseID, Timestamp: base.Add(tt.offset), Side: tt.side}
+		if err := repo.Create(injection); err != nil {
+			t.Fatalf("Failed to create injection: %v", err)
+		}
+	}
+
+	matches, err := repo.FindDuplicates(1, courseID, "left", base.Add(8*time.Minute), 30*time.Minute)
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+	if len(matches) != 2 || matches[0].ID != 2 || matches[1].ID != 1 {
+		t.Errorf("Expected injections 2 then 1, closest first, got %v", matches)
+	}
+	if matches, err := repo.FindDuplicates(1, courseID, "left", base.Add(24*time.Hour), 30*time.Minute); err != nil || len(matches) != 0 {
+		t.Errorf("Expected no duplicates a day later, got %d (%v)", len(matches), err)
+	}
+	if matches, err := repo.FindDuplicates(2, courseID, "left", base, 30*time.Minute); err != nil || len(matches) != 0 {
+		t.Errorf("Expected no duplicates in another account, got %d (%v)", len(matches), err)
+	}
+
+	if _, err := db.Exec(`UPDATE injections SET voided_at = CURRENT_TIMESTAMP WHERE id = 2`); err != nil {
+		t.Fatalf("Failed to void injection: %v", err)
+	}
+	groups, err := repo.ListDuplicateGroups(1, 0, base.Add(-time.Hour), 30*time.Minute)
+	if err != nil {
+		t.Fatalf("ListDuplicateGroups failed: %v", err)
+	}
+	if len(groups) != 1 || len(groups[0]) != 3 || groups[0][0].ID != 4 || groups[0][2].ID != 6 {
+		t.Errorf("Expected one group of injections 4 to 6, got %v", groups)
+	}
+	if groups, err := repo.ListDuplicateGroups(1, courseID, base.Add(47*time.Hour), 10*time.Minute); err != nil || len(groups) != 0 {
+		t.Errorf("Expected no groups with a 10 minute window, got %d (%v)", len(groups), err)
+	}
+}
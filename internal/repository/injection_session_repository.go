@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// ErrInjectionSessionInProgress is returned when starting a guided
+// injection while the member already has one open in the account
+var ErrInjectionSessionInProgress = errors.New("an injection session is already in progress")
+
+// ErrInjectionSessionClosed is returned when recording a step of, completing
+// or cancelling a session that is no longer open
+var ErrInjectionSessionClosed = errors.New("injection session is no longer open")
+
+// InjectionSessionRepository records guided injections step by step and
+// writes the injection when one is completed
+type InjectionSessionRepository struct {
+	db *database.DB
+}
+
+func NewInjectionSessionRepository(db *database.DB) *InjectionSessionRepository {
+	return &InjectionSessionRepository{db: db}
+}
+
+const injectionSessionColumns = `id, account_id, course_id, status, started_by, started_at, warming_started_at, warming_seconds,
+	details, details_at, injection_id, closed_at, duration_seconds, created_at, updated_at`
+
+func scanInjectionSession(row rowScanner) (*models.InjectionSession, error) {
+	var s models.InjectionSession
+	err := row.Scan(
+		&s.ID,
+		&s.AccountID,
+		&s.CourseID,
+		&s.Status,
+		&s.StartedBy,
+		&s.StartedAt,
+		&s.WarmingStartedAt,
+		&s.WarmingSeconds,
+		&s.Details,
+		&s.DetailsAt,
+		&s.InjectionID,
+		&s.ClosedAt,
+		&s.DurationSeconds,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Start opens a session for the member on one of the account's courses. It
+// returns ErrNotFound if the course isn't the account's and
+// ErrInjectionSessionInProgress if the member already has one open.
+func (r *InjectionSessionRepository) Start(s *models.InjectionSession) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var courses int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM courses WHERE id = ? AND account_id = ?`, s.CourseID, s.AccountID).Scan(&courses); err != nil {
+		return fmt.Errorf("failed to check course: %w", err)
+	}
+	if courses == 0 {
+		return ErrNotFound
+	}
+
+	var open int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM injection_sessions WHERE account_id = ? AND started_by = ? AND status = 'open'`,
+		s.AccountID, s.StartedBy).Scan(&open); err != nil {
+		return fmt.Errorf("failed to check for an open injection session: %w", err)
+	}
+	if open > 0 {
+		return ErrInjectionSessionInProgress
+	}
+
+	now := time.Now()
+	s.Status = models.InjectionSessionOpen
+	s.StartedAt = now
+	err = tx.QueryRow(`
+		INSERT INTO injection_sessions (account_id, course_id, status, started_by, started_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, s.AccountID, s.CourseID, s.Status, s.StartedBy, now.UTC(), now, now).Scan(&s.ID)
+	if err != nil {
+		return fmt.Errorf("failed to start injection session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	s.CreatedAt = now
+	s.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves a session, scoped to the account
+func (r *InjectionSessionRepository) GetByID(id, accountID int64) (*models.InjectionSession, error) {
+	query := `SELECT ` + injectionSessionColumns + ` FROM injection_sessions WHERE id = ? AND account_id = ?`
+	s, err := scanInjectionSession(r.db.QueryRow(query, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get injection session: %w", err)
+	}
+	return s, nil
+}
+
+// GetOpen retrieves the member's open session in the account
+func (r *InjectionSessionRepository) GetOpen(accountID, userID int64) (*models.InjectionSession, error) {
+	query := `SELECT ` + injectionSessionColumns + ` FROM injection_sessions
+		WHERE account_id = ? AND started_by = ? AND status = 'open'`
+	s, err := scanInjectionSession(r.db.QueryRow(query, accountID, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get injection session: %w", err)
+	}
+	return s, nil
+}
+
+// openInjectionSession reloads a session within tx, returning
+// ErrInjectionSessionClosed unless it is open
+func openInjectionSession(tx *sql.Tx, s *models.InjectionSession) (*models.InjectionSession, error) {
+	query := `SELECT ` + injectionSessionColumns + ` FROM injection_sessions WHERE id = ? AND account_id = ?`
+	current, err := scanInjectionSession(tx.QueryRow(query, s.ID, s.AccountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get injection session: %w", err)
+	}
+	if current.Status != models.InjectionSessionOpen {
+		return nil, ErrInjectionSessionClosed
+	}
+	return current, nil
+}
+
+// updateOpenInjectionSession runs change against the session's current
+// state in a transaction, then saves its warming timer and details. s is
+// updated to match.
+func (r *InjectionSessionRepository) updateOpenInjectionSession(s *models.InjectionSession, change func(current *models.InjectionSession, now time.Time)) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	current, err := openInjectionSession(tx, s)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	change(current, now)
+	current.UpdatedAt = now
+
+	_, err = tx.Exec(`
+		UPDATE injection_sessions
+		SET warming_started_at = ?, warming_seconds = ?, details = ?, details_at = ?, updated_at = ?
+		WHERE id = ?
+	`, current.WarmingStartedAt, current.WarmingSeconds, current.Details, current.DetailsAt, now, current.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update injection session: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	*s = *current
+	return nil
+}
+
+// stopWarming adds a running warm-up timer's time to the session's total
+func stopWarming(s *models.InjectionSession, now time.Time) {
+	if s.WarmingStartedAt.Valid {
+		s.WarmingSeconds = int64(s.WarmingTime(now) / time.Second)
+		s.WarmingStartedAt = sql.NullTime{}
+	}
+}
+
+// StartWarming starts the session's warm-up timer. Starting a timer that is
+// already running leaves it be.
+func (r *InjectionSessionRepository) StartWarming(s *models.InjectionSession) error {
+	return r.updateOpenInjectionSession(s, func(current *models.InjectionSession, now time.Time) {
+		if !current.WarmingStartedAt.Valid {
+			current.WarmingStartedAt = sql.NullTime{Time: now.UTC(), Valid: true}
+		}
+	})
+}
+
+// StopWarming stops the session's warm-up timer, adding the time it ran to
+// the session's warming time
+func (r *InjectionSessionRepository) StopWarming(s *models.InjectionSession) error {
+	return r.updateOpenInjectionSession(s, stopWarming)
+}
+
+// SaveDetails records the injection's details, replacing any entered
+// before. The caller validates them.
+func (r *InjectionSessionRepository) SaveDetails(s *models.InjectionSession, details string) error {
+	return r.updateOpenInjectionSession(s, func(current *models.InjectionSession, now time.Time) {
+		current.Details = sql.NullString{String: details, Valid: true}
+		current.DetailsAt = sql.NullTime{Time: now.UTC(), Valid: true}
+	})
+}
+
+// Complete writes the session's injection the way InjectionRepository's
+// Record does, duplicate check included, and closes the session with its timings, all in one
+// transaction. A running warm-up timer is stopped. It returns
+// ErrInjectionSessionClosed if the session was closed meanwhile.
+func (r *InjectionSessionRepository) Complete(s *models.InjectionSession, injection *models.Injection, userID int64, duplicateWindow time.Duration) (*InventoryUsage, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	current, err := openInjectionSession(tx, s)
+	if err != nil {
+		return nil, err
+	}
+	injection.CourseID = current.CourseID
+	if err := NewInjectionRepository(r.db).checkDuplicate(tx, injection, current.AccountID, duplicateWindow); err != nil {
+		return nil, err
+	}
+	usage, err := recordInjection(r.db.Context(), tx, injection, current.AccountID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stopWarming(current, now)
+	current.Status = models.InjectionSessionCompleted
+	current.InjectionID = sql.NullInt64{Int64: injection.ID, Valid: true}
+	current.ClosedAt = sql.NullTime{Time: now.UTC(), Valid: true}
+	current.DurationSeconds = sql.NullInt64{Int64: int64(now.Sub(current.StartedAt) / time.Second), Valid: true}
+	current.UpdatedAt = now
+	_, err = tx.Exec(`
+		UPDATE injection_sessions
+		SET status = ?, warming_started_at = NULL, warming_seconds = ?, injection_id = ?, closed_at = ?, duration_seconds = ?, updated_at = ?
+		WHERE id = ?
+	`, current.Status, current.WarmingSeconds, current.InjectionID, current.ClosedAt, current.DurationSeconds, now, current.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete injection session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	*s = *current
+	return usage, nil
+}
+
+// Cancel closes a session without writing an injection
+func (r *InjectionSessionRepository) Cancel(s *models.InjectionSession) error {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE injection_sessions SET status = 'cancelled', warming_started_at = NULL, closed_at = ?, updated_at = ?
+		WHERE id = ? AND account_id = ? AND status = 'open'
+	`, now.UTC(), now, s.ID, s.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel injection session: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrInjectionSessionClosed
+	}
+	s.Status = models.InjectionSessionCancelled
+	s.WarmingStartedAt = sql.NullTime{}
+	s.ClosedAt = sql.NullTime{Time: now, Valid: true}
+	return nil
+}
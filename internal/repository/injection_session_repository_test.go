@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestInjectionSessionRepository_Lifecycle(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	for _, stmt := range []string{
+		`INSERT INTO inventory_items (item_type, quantity, unit, account_id) VALUES ('progesterone', 3, 'mL', 1)`,
+		`INSERT INTO accounts (id, name) VALUES (2, 'Other Account')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to seed: %v", err)
+		}
+	}
+	var courseID, otherCourseID int64
+	if err := db.QueryRow(`INSERT INTO courses (name, start_date, is_active, dose_ml, account_id) VALUES ('Course', ?, TRUE, 0.5, 1) RETURNING id`, time.Now()).Scan(&courseID); err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+	if err := db.QueryRow(`INSERT INTO courses (name, start_date, is_active, account_id) VALUES ('Other', ?, TRUE, 2) RETURNING id`, time.Now()).Scan(&otherCourseID); err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+
+	repo := NewInjectionSessionRepository(db)
+	startedBy := sql.NullInt64{Int64: 1, Valid: true}
+
+	// Another account's course can't be injected from
+	if err := repo.Start(&models.InjectionSession{AccountID: 1, CourseID: otherCourseID, StartedBy: startedBy}); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for another account's course, got %v", err)
+	}
+
+	session := &models.InjectionSession{AccountID: 1, CourseID: courseID, StartedBy: startedBy}
+	if err := repo.Start(session); err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	if session.ID == 0 || session.Status != models.InjectionSessionOpen {
+		t.Fatalf("Expected an open session, got %+v", session)
+	}
+	if err := repo.Start(&models.InjectionSession{AccountID: 1, CourseID: courseID, StartedBy: startedBy}); err != ErrInjectionSessionInProgress {
+		t.Fatalf("Expected ErrInjectionSessionInProgress, got %v", err)
+	}
+	if open, err := repo.GetOpen(1, 1); err != nil || open.ID != session.ID {
+		t.Fatalf("Expected the open session, got %+v (%v)", open, err)
+	}
+
+	// The warm-up timer adds up across runs
+	if err := repo.StartWarming(session); err != nil || !session.WarmingStartedAt.Valid {
+		t.Fatalf("Failed to start warming: %+v (%v)", session, err)
+	}
+	if _, err := db.Exec(`UPDATE injection_sessions SET warming_started_at = ?, warming_seconds = 30 WHERE id = ?`,
+		time.Now().Add(-90*time.Second).UTC(), session.ID); err != nil {
+		t.Fatalf("Failed to backdate warming: %v", err)
+	}
+	if err := repo.StopWarming(session); err != nil {
+		t.Fatalf("Failed to stop warming: %v", err)
+	}
+	if session.WarmingStartedAt.Valid || session.WarmingSeconds < 120 || session.WarmingSeconds > 125 {
+		t.Errorf("Expected about 120s of warming and the timer stopped, got %ds (%v)", session.WarmingSeconds, session.WarmingStartedAt)
+	}
+
+	if err := repo.SaveDetails(session, `{"side":"left"}`); err != nil {
+		t.Fatalf("Failed to save details: %v", err)
+	}
+	if got, err := repo.GetByID(session.ID, 1); err != nil || got.Details.String != `{"side":"left"}` || !got.DetailsAt.Valid {
+		t.Fatalf("Expected the details saved, got %+v (%v)", got, err)
+	}
+	if _, err := repo.GetByID(session.ID, 2); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound from another account, got %v", err)
+	}
+
+	// Completing writes the injection and closes the session
+	injection := &models.Injection{Timestamp: time.Now(), Side: "left", AdministeredBy: startedBy}
+	usage, err := repo.Complete(session, injection, 1, 0)
+	if err != nil {
+		t.Fatalf("Failed to complete session: %v", err)
+	}
+	if injection.ID == 0 || injection.CourseID != courseID || usage.QuantitiesBefore["progesterone"] != 3 {
+		t.Errorf("Expected the injection recorded on the session's course, got %+v and %+v", injection, usage)
+	}
+	if session.Status != models.InjectionSessionCompleted || session.InjectionID.Int64 != injection.ID ||
+		!session.ClosedAt.Valid || !session.DurationSeconds.Valid || session.WarmingSeconds < 120 {
+		t.Errorf("Unexpected completed session %+v", session)
+	}
+	var quantity float64
+	if err := db.QueryRow(`SELECT quantity FROM inventory_items WHERE item_type = 'progesterone'`).Scan(&quantity); err != nil || quantity != 2.5 {
+		t.Errorf("Expected the dose taken from stock, got %v (%v)", quantity, err)
+	}
+
+	// A closed session can't be completed or cancelled again
+	if _, err := repo.Complete(session, &models.Injection{Timestamp: time.Now(), Side: "right"}, 1, 0); err != ErrInjectionSessionClosed {
+		t.Errorf("Expected ErrInjectionSessionClosed completing twice, got %v", err)
+	}
+	if err := repo.Cancel(session); err != ErrInjectionSessionClosed {
+		t.Errorf("Expected ErrInjectionSessionClosed cancelling, got %v", err)
+	}
+	if _, err := repo.GetOpen(1, 1); err != ErrNotFound {
+		t.Errorf("Expected no open session, got %v", err)
+	}
+
+	// A cancelled session writes nothing and frees the member to start again
+	cancelled := &models.InjectionSession{AccountID: 1, CourseID: courseID, StartedBy: startedBy}
+	if err := repo.Start(cancelled); err != nil {
+		t.Fatalf("Failed to start another session: %v", err)
+	}
+	if err := repo.Cancel(cancelled); err != nil || cancelled.Status != models.InjectionSessionCancelled {
+		t.Fatalf("Failed to cancel session: %+v (%v)", cancelled, err)
+	}
+	if err := repo.SaveDetails(cancelled, `{}`); err != ErrInjectionSessionClosed {
+		t.Errorf("Expected ErrInjectionSessionClosed saving details, got %v", err)
+	}
+	var injections int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM injections`).Scan(&injections); err != nil || injections != 1 {
+		t.Errorf("Expected one injection, got %d (%v)", injections, err)
+	}
+}
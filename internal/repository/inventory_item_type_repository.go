@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type InventoryItemTypeRepository struct {
+	db *database.DB
+}
+
+func NewInventoryItemTypeRepository(db *database.DB) *InventoryItemTypeRepository {
+	return &InventoryItemTypeRepository{db: db}
+}
+
+// DefaultInventoryItemTypes are created for every new account
+var DefaultInventoryItemTypes = []models.InventoryItemType{
+	{ItemType: "progesterone", Name: "Progesterone", Unit: "mL", SortOrder: 0},
+	{ItemType: "draw_needle", Name: "Draw Needles", Unit: "count", DecrementPerInjection: 1, SortOrder: 1},
+	{ItemType: "injection_needle", Name: "Injection Needles", Unit: "count", DecrementPerInjection: 1, SortOrder: 2},
+	{ItemType: "syringe", Name: "Syringes", Unit: "count", DecrementPerInjection: 1, SortOrder: 3},
+	{ItemType: "swab", Name: "Alcohol Swabs", Unit: "count", DecrementPerInjection: 1, SortOrder: 4},
+	{ItemType: "gauze", Name: "Gauze Pads", Unit: "count", SortOrder: 5},
+}
+
+const inventoryItemTypeColumns = `id, account_id, item_type, name, unit, decrement_per_injection, reorder_threshold,
+		       purchase_unit, units_per_purchase, sort_order, created_at, updated_at`
+
+// seedInventoryItemTypes creates the default item types for a new account
+func seedInventoryItemTypes(tx *sql.Tx, accountID int64) error {
+	for _, t := range DefaultInventoryItemTypes {
+		_, err := tx.Exec(`
+			INSERT INTO inventory_item_types (account_id, item_type, name, unit, decrement_per_injection, sort_order)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING
+		`, accountID, t.ItemType, t.Name, t.Unit, t.DecrementPerInjection, t.SortOrder)
+		if err != nil {
+			return fmt.Errorf("failed to seed inventory item type %s: %w", t.ItemType, err)
+		}
+	}
+	return nil
+}
+
+// Create creates a new inventory item type
+func (r *InventoryItemTypeRepository) Create(t *models.InventoryItemType) error {
+	query := `
+		INSERT INTO inventory_item_types (account_id, item_type, name, unit, decrement_per_injection, reorder_threshold,
+		                                  purchase_unit, units_per_purchase, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	now := time.Now()
+	var id int64
+	err := r.db.QueryRow(query,
+		t.AccountID,
+		t.ItemType,
+		t.Name,
+		t.Unit,
+		t.DecrementPerInjection,
+		t.ReorderThreshold,
+		t.PurchaseUnit,
+		t.UnitsPerPurchase,
+		t.SortOrder,
+		now,
+		now,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to create inventory item type: %w", err)
+	}
+
+	t.ID = id
+	t.CreatedAt = now
+	t.UpdatedAt = now
+	return nil
+}
+
+// EnsureExists creates the item type if the account doesn't have it yet,
+// leaving an existing definition untouched
+func (r *InventoryItemTypeRepository) EnsureExists(t *models.InventoryItemType) error {
+	_, err := r.db.Exec(`
+		INSERT INTO inventory_item_types (account_id, item_type, name, unit, decrement_per_injection, reorder_threshold, sort_order)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, t.AccountID, t.ItemType, t.Name, t.Unit, t.DecrementPerInjection, t.ReorderThreshold, t.SortOrder)
+	if err != nil {
+		return fmt.Errorf("failed to create inventory item type: %w", err)
+	}
+	return nil
+}
+
+// GetByItemType retrieves an account's definition of an item type
+func (r *InventoryItemTypeRepository) GetByItemType(itemType string, accountID int64) (*models.InventoryItemType, error) {
+	query := `SELECT ` + inventoryItemTypeColumns + ` FROM inventory_item_types WHERE item_type = ? AND account_id = ?`
+	return r.scanItemType(r.db.QueryRow(query, itemType, accountID))
+}
+
+// List retrieves all item types for an account in display order
+func (r *InventoryItemTypeRepository) List(accountID int64) ([]*models.InventoryItemType, error) {
+	query := `SELECT ` + inventoryItemTypeColumns + ` FROM inventory_item_types WHERE account_id = ? ORDER BY sort_order, name`
+	rows, err := r.db.Query(query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inventory item types: %w", err)
+	}
+	defer rows.Close()
+
+	var types []*models.InventoryItemType
+	for rows.Next() {
+		var t models.InventoryItemType
+		err := rows.Scan(
+			&t.ID,
+			&t.AccountID,
+			&t.ItemType,
+			&t.Name,
+			&t.Unit,
+			&t.DecrementPerInjection,
+			&t.ReorderThreshold,
+			&t.PurchaseUnit,
+			&t.UnitsPerPurchase,
+			&t.SortOrder,
+			&t.CreatedAt,
+			&t.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan inventory item type: %w", err)
+		}
+		types = append(types, &t)
+	}
+
+	return types, rows.Err()
+}
+
+// Update updates an item type's name, per-injection decrement, reorder
+// threshold, purchase unit and sort order. The reorder threshold is copied to the
+// account's stock record so low-stock alerts use it.
+func (r *InventoryItemTypeRepository) Update(t *models.InventoryItemType) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.Exec(`
+		UPDATE inventory_item_types
+		SET name = ?, decrement_per_injection = ?, reorder_threshold = ?, purchase_unit = ?, units_per_purchase = ?,
+		    sort_order = ?
+		WHERE item_type = ? AND account_id = ?
+	`,
+		t.Name,
+		t.DecrementPerInjection,
+		t.ReorderThreshold,
+		t.PurchaseUnit,
+		t.UnitsPerPurchase,
+		t.SortOrder,
+		t.ItemType,
+		t.AccountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update inventory item type: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	_, err = tx.Exec(`
+		UPDATE inventory_items SET low_stock_threshold = ?
+		WHERE item_type = ? AND account_id = ?
+	`, t.ReorderThreshold, t.ItemType, t.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to update low stock threshold: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes an item type definition. Stock and history rows are kept.
+func (r *InventoryItemTypeRepository) Delete(itemType string, accountID int64) error {
+	result, err := r.db.Exec("DELETE FROM inventory_item_types WHERE item_type = ? AND account_id = ?", itemType, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete inventory item type: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *InventoryItemTypeRepository) scanItemType(row *sql.Row) (*models.InventoryItemType, error) {
+	var t models.InventoryItemType
+	err := row.Scan(
+		&t.ID,
+		&t.AccountID,
+		&t.ItemType,
+		&t.Name,
+		&t.Unit,
+		&t.DecrementPerInjection,
+		&t.ReorderThreshold,
+		&t.PurchaseUnit,
+		&t.UnitsPerPurchase,
+		&t.SortOrder,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory item type: %w", err)
+	}
+
+	return &t, nil
+}
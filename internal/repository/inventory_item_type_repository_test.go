@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"injection-tracker/internal/models"
+)
+
+func TestInventoryItemTypeRepository_SeededForNewAccount(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO users (id, username, password_hash) VALUES (2, 'second', 'hash')`); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	accountID, err := NewAccountRepository(db.DB).Create(nil, 2)
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	types, err := NewInventoryItemTypeRepository(db).List(accountID)
+	if err != nil {
+		t.Fatalf("Failed to list item types: %v", err)
+	}
+	if len(types) != len(DefaultInventoryItemTypes) {
+		t.Fatalf("Expected %d default item types, got %d", len(DefaultInventoryItemTypes), len(types))
+	}
+	if types[0].ItemType != "progesterone" || types[0].Unit != "mL" || types[0].DecrementPerInjection != 0 {
+		t.Errorf("Unexpected first item type: %+v", types[0])
+	}
+}
+
+func TestInventoryItemTypeRepository_CRUD(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewInventoryItemTypeRepository(db)
+	itemType := &models.InventoryItemType{
+		AccountID:             1,
+		ItemType:              "sharps_container",
+		Name:                  "Sharps Container",
+		Unit:                  "count",
+		DecrementPerInjection: 0.05,
+	}
+	if err := repo.Create(itemType); err != nil {
+		t.Fatalf("Failed to create item type: %v", err)
+	}
+
+	// Existing definitions are left alone
+	if err := repo.EnsureExists(&models.InventoryItemType{AccountID: 1, ItemType: "sharps_container", Name: "Other", Unit: "mL"}); err != nil {
+		t.Fatalf("EnsureExists failed: %v", err)
+	}
+	retrieved, err := repo.GetByItemType("sharps_container", 1)
+	if err != nil {
+		t.Fatalf("Failed to get item type: %v", err)
+	}
+	if retrieved.Name != "Sharps Container" || retrieved.Unit != "count" {
+		t.Errorf("EnsureExists overwrote the definition: %+v", retrieved)
+	}
+
+	if _, err := repo.GetByItemType("sharps_container", 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for other account, got %v", err)
+	}
+
+	if _, err := db.Exec(`INSERT INTO inventory_items (item_type, quantity, unit, account_id) VALUES ('sharps_container', 2, 'count', 1)`); err != nil {
+		t.Fatalf("Failed to create inventory item: %v", err)
+	}
+	itemType.ReorderThreshold = sql.NullFloat64{Float64: 1, Valid: true}
+	itemType.PurchaseUnit = sql.NullString{String: "box", Valid: true}
+	itemType.UnitsPerPurchase = sql.NullFloat64{Float64: 4, Valid: true}
+	if err := repo.Update(itemType); err != nil {
+		t.Fatalf("Failed to update item type: %v", err)
+	}
+	retrieved, err = repo.GetByItemType("sharps_container", 1)
+	if err != nil {
+		t.Fatalf("Failed to get item type: %v", err)
+	}
+	if retrieved.PurchaseUnit.String != "box" || retrieved.UnitsPerPurchase.Float64 != 4 {
+		t.Errorf("Expected boxes of 4, got %+v %+v", retrieved.PurchaseUnit, retrieved.UnitsPerPurchase)
+	}
+
+	item, err := NewInventoryRepository(db).GetByType("sharps_container", 1)
+	if err != nil {
+		t.Fatalf("Failed to get inventory item: %v", err)
+	}
+	if !item.LowStockThreshold.Valid || item.LowStockThreshold.Float64 != 1 {
+		t.Errorf("Expected reorder threshold to be copied to the stock record, got %+v", item.LowStockThreshold)
+	}
+
+	if err := repo.Delete("sharps_container", 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting from other account, got %v", err)
+	}
+	if err := repo.Delete("sharps_container", 1); err != nil {
+		t.Fatalf("Failed to delete item type: %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// lotEpsilon absorbs floating point residue when splitting doses across lots
+const lotEpsilon = 1e-9
+
+type InventoryLotRepository struct {
+	db *database.DB
+}
+
+func NewInventoryLotRepository(db *database.DB) *InventoryLotRepository {
+	return &InventoryLotRepository{db: db}
+}
+
+const inventoryLotColumns = `id, account_id, item_type, lot_number, expiration_date, quantity_received, quantity_remaining,
+		       received_at, notes, created_at, updated_at`
+
+// fifoOrder is the order lots are consumed in: oldest received first
+const fifoOrder = `ORDER BY received_at, id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanInventoryLot(row rowScanner) (*models.InventoryLot, error) {
+	var lot models.InventoryLot
+	err := row.Scan(
+		&lot.ID,
+		&lot.AccountID,
+		&lot.ItemType,
+		&lot.LotNumber,
+		&lot.ExpirationDate,
+		&lot.QuantityReceived,
+		&lot.QuantityRemaining,
+		&lot.ReceivedAt,
+		&lot.Notes,
+		&lot.CreatedAt,
+		&lot.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &lot, nil
+}
+
+// GetByID retrieves a lot, scoped to the account
+func (r *InventoryLotRepository) GetByID(id, accountID int64) (*models.InventoryLot, error) {
+	query := `SELECT ` + inventoryLotColumns + ` FROM inventory_lots WHERE id = ? AND account_id = ?`
+	lot, err := scanInventoryLot(r.db.QueryRow(query, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory lot: %w", err)
+	}
+	return lot, nil
+}
+
+// ListByItemType retrieves an item's lots in consumption order. Empty lots
+// are only included when includeEmpty is set.
+func (r *InventoryLotRepository) ListByItemType(accountID int64, itemType string, includeEmpty bool) ([]*models.InventoryLot, error) {
+	query := `SELECT ` + inventoryLotColumns + ` FROM inventory_lots WHERE account_id = ? AND item_type = ?`
+	if !includeEmpty {
+		query += ` AND quantity_remaining > 0`
+	}
+	query += ` ` + fifoOrder
+
+	rows, err := r.db.Query(query, accountID, itemType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inventory lots: %w", err)
+	}
+	defer rows.Close()
+
+	lots := []*models.InventoryLot{}
+	for rows.Next() {
+		lot, err := scanInventoryLot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan inventory lot: %w", err)
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// GetActiveLot retrieves the lot the next use of an item will be drawn from
+func (r *InventoryLotRepository) GetActiveLot(accountID int64, itemType string) (*models.InventoryLot, error) {
+	return getActiveLot(r.db, accountID, itemType)
+}
+
+func getActiveLot(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, accountID int64, itemType string) (*models.InventoryLot, error) {
+	query := `SELECT ` + inventoryLotColumns + ` FROM inventory_lots
+		WHERE account_id = ? AND item_type = ? AND quantity_remaining > 0 ` + fifoOrder + ` LIMIT 1`
+	lot, err := scanInventoryLot(q.QueryRow(query, accountID, itemType))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active lot: %w", err)
+	}
+	return lot, nil
+}
+
+// ReceiveLot records newly received stock as a lot. The caller updates the
+// item's total quantity in the same transaction.
+func ReceiveLot(tx *sql.Tx, lot *models.InventoryLot) error {
+	now := time.Now()
+	if lot.ReceivedAt.IsZero() {
+		lot.ReceivedAt = now
+	}
+	lot.QuantityRemaining = lot.QuantityReceived
+
+	var id int64
+	err := tx.QueryRow(`
+		INSERT INTO inventory_lots (account_id, item_type, lot_number, expiration_date, quantity_received,
+		                            quantity_remaining, received_at, notes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`,
+		lot.AccountID,
+		lot.ItemType,
+		lot.LotNumber,
+		lot.ExpirationDate,
+		lot.QuantityReceived,
+		lot.QuantityRemaining,
+		lot.ReceivedAt,
+		lot.Notes,
+		now,
+		now,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to create inventory lot: %w", err)
+	}
+
+	lot.ID = id
+	lot.CreatedAt = now
+	lot.UpdatedAt = now
+	return syncActiveLot(tx, lot.AccountID, lot.ItemType)
+}
+
+// ConsumeLotsFIFO takes amount from an item's lots, oldest first, and returns
+// the first lot drawn from. Any amount beyond what the lots hold is stock that
+// predates lot tracking and is not recorded against a lot. When injectionID is
+// set, the consumption is remembered so it can be returned later.
+func ConsumeLotsFIFO(tx *sql.Tx, accountID int64, itemType string, amount float64, injectionID sql.NullInt64) (sql.NullInt64, error) {
+	var firstLot sql.NullInt64
+	if amount <= 0 {
+		return firstLot, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, quantity_remaining FROM inventory_lots
+		WHERE account_id = ? AND item_type = ? AND quantity_remaining > 0
+		`+fifoOrder, accountID, itemType)
+	if err != nil {
+		return firstLot, fmt.Errorf("failed to query inventory lots: %w", err)
+	}
+
+	type lotBalance struct {
+		id        int64
+		remaining float64
+	}
+	var lots []lotBalance
+	for rows.Next() {
+		var l lotBalance
+		if err := rows.Scan(&l.id, &l.remaining); err != nil {
+			rows.Close()
+			return firstLot, fmt.Errorf("failed to scan inventory lot: %w", err)
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return firstLot, fmt.Errorf("failed to query inventory lots: %w", err)
+	}
+
+	need := amount
+	for _, l := range lots {
+		if need <= lotEpsilon {
+			break
+		}
+		take := min(l.remaining, need)
+		remaining := l.remaining - take
+		if remaining < lotEpsilon {
+			remaining = 0
+		}
+
+		if _, err := tx.Exec(`UPDATE inventory_lots SET quantity_remaining = ? WHERE id = ?`, remaining, l.id); err != nil {
+			return firstLot, fmt.Errorf("failed to consume from lot: %w", err)
+		}
+		if injectionID.Valid {
+			_, err := tx.Exec(`
+				INSERT INTO inventory_lot_consumptions (lot_id, injection_id, amount) VALUES (?, ?, ?)
+			`, l.id, injectionID, take)
+			if err != nil {
+				return firstLot, fmt.Errorf("failed to record lot consumption: %w", err)
+			}
+		}
+		if !firstLot.Valid {
+			firstLot = sql.NullInt64{Int64: l.id, Valid: true}
+		}
+		need -= take
+	}
+
+	return firstLot, syncActiveLot(tx, accountID, itemType)
+}
+
+// ReturnInjectionLots gives back up to amount of an item to the lots an
+// injection drew it from, most recently consumed first. A non-positive amount
+// returns everything the injection took of every item.
+func ReturnInjectionLots(tx *sql.Tx, injectionID int64, itemType string, amount float64) error {
+	query := `
+		SELECT c.id, c.lot_id, c.amount, l.account_id, l.item_type
+		FROM inventory_lot_consumptions c
+		JOIN inventory_lots l ON l.id = c.lot_id
+		WHERE c.injection_id = ?`
+	args := []interface{}{injectionID}
+	if amount > 0 {
+		query += ` AND l.item_type = ?`
+		args = append(args, itemType)
+	}
+	query += ` ORDER BY c.id DESC`
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query lot consumptions: %w", err)
+	}
+
+	type consumption struct {
+		id, lotID, accountID int64
+		amount               float64
+		itemType             string
+	}
+	var consumptions []consumption
+	for rows.Next() {
+		var c consumption
+		if err := rows.Scan(&c.id, &c.lotID, &c.amount, &c.accountID, &c.itemType); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan lot consumption: %w", err)
+		}
+		consumptions = append(consumptions, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query lot consumptions: %w", err)
+	}
+
+	type itemKey struct {
+		accountID int64
+		itemType  string
+	}
+	touched := map[itemKey]bool{}
+	remaining := amount
+	for _, c := range consumptions {
+		give := c.amount
+		if amount > 0 {
+			if remaining <= lotEpsilon {
+				break
+			}
+			give = min(c.amount, remaining)
+			remaining -= give
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE inventory_lots SET quantity_remaining = quantity_remaining + ? WHERE id = ?
+		`, give, c.lotID); err != nil {
+			return fmt.Errorf("failed to return stock to lot: %w", err)
+		}
+		if c.amount-give < lotEpsilon {
+			_, err = tx.Exec(`DELETE FROM inventory_lot_consumptions WHERE id = ?`, c.id)
+		} else {
+			_, err = tx.Exec(`UPDATE inventory_lot_consumptions SET amount = ? WHERE id = ?`, c.amount-give, c.id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update lot consumption: %w", err)
+		}
+		touched[itemKey{c.accountID, c.itemType}] = true
+	}
+
+	for key := range touched {
+		if err := syncActiveLot(tx, key.accountID, key.itemType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncActiveLot copies the active lot's number and expiration onto the item's
+// summary row, which the alerts and expiration notifications read
+func syncActiveLot(tx *sql.Tx, accountID int64, itemType string) error {
+	lot, err := getActiveLot(tx, accountID, itemType)
+	if err == ErrNotFound {
+		// Keep the last known details; the item is out of tracked stock
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE inventory_items SET lot_number = ?, expiration_date = ?, updated_at = ?
+		WHERE item_type = ? AND account_id = ?
+	`, lot.LotNumber, lot.ExpirationDate, time.Now(), itemType, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to update active lot: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func receiveTestLot(t *testing.T, tx *sql.Tx, lotNumber string, qty float64, receivedAt time.Time) *models.InventoryLot {
+	lot := &models.InventoryLot{
+		AccountID:        1,
+		ItemType:         "progesterone",
+		LotNumber:        sql.NullString{String: lotNumber, Valid: true},
+		ExpirationDate:   sql.NullTime{Time: receivedAt.AddDate(1, 0, 0), Valid: true},
+		QuantityReceived: qty,
+		ReceivedAt:       receivedAt,
+	}
+	if err := ReceiveLot(tx, lot); err != nil {
+		t.Fatalf("Failed to receive lot %s: %v", lotNumber, err)
+	}
+	return lot
+}
+
+func TestInventoryLots_ConsumeFIFOAndReturn(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO inventory_items (item_type, quantity, unit, account_id) VALUES ('progesterone', 3, 'mL', 1)`); err != nil {
+		t.Fatalf("Failed to create inventory item: %v", err)
+	}
+	result, err := db.Exec(`INSERT INTO courses (name, start_date, is_active, account_id) VALUES ('Course', ?, 1, 1)`, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+	courseID, _ := result.LastInsertId()
+	injection := &models.Injection{CourseID: courseID, Timestamp: time.Now(), Side: "left"}
+	if err := NewInjectionRepository(db).Create(injection); err != nil {
+		t.Fatalf("Failed to create injection: %v", err)
+	}
+
+	tx, err := db.BeginTx()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	older := receiveTestLot(t, tx, "A1", 1, now.AddDate(0, -1, 0))
+	newer := receiveTestLot(t, tx, "B2", 2, now)
+
+	lotID, err := ConsumeLotsFIFO(tx, 1, "progesterone", 1.5, sql.NullInt64{Int64: injection.ID, Valid: true})
+	if err != nil {
+		t.Fatalf("Failed to consume lots: %v", err)
+	}
+	if !lotID.Valid || lotID.Int64 != older.ID {
+		t.Errorf("Expected first lot drawn to be %d, got %+v", older.ID, lotID)
+	}
+
+	var olderRemaining, newerRemaining float64
+	_ = tx.QueryRow(`SELECT quantity_remaining FROM inventory_lots WHERE id = ?`, older.ID).Scan(&olderRemaining)
+	_ = tx.QueryRow(`SELECT quantity_remaining FROM inventory_lots WHERE id = ?`, newer.ID).Scan(&newerRemaining)
+	if olderRemaining != 0 || newerRemaining != 1.5 {
+		t.Errorf("Expected 0 and 1.5 remaining, got %v and %v", olderRemaining, newerRemaining)
+	}
+
+	var activeLot sql.NullString
+	_ = tx.QueryRow(`SELECT lot_number FROM inventory_items WHERE item_type = 'progesterone' AND account_id = 1`).Scan(&activeLot)
+	if activeLot.String != "B2" {
+		t.Errorf("Expected item to show active lot B2, got %q", activeLot.String)
+	}
+
+	// Returning part of the dose goes back to the most recently drawn lot
+	if err := ReturnInjectionLots(tx, injection.ID, "progesterone", 0.25); err != nil {
+		t.Fatalf("Failed to return partial amount: %v", err)
+	}
+	_ = tx.QueryRow(`SELECT quantity_remaining FROM inventory_lots WHERE id = ?`, newer.ID).Scan(&newerRemaining)
+	if newerRemaining != 1.75 {
+		t.Errorf("Expected 1.75 remaining in newer lot, got %v", newerRemaining)
+	}
+
+	// Returning everything restores both lots and the older one becomes active again
+	if err := ReturnInjectionLots(tx, injection.ID, "", 0); err != nil {
+		t.Fatalf("Failed to return all: %v", err)
+	}
+	_ = tx.QueryRow(`SELECT quantity_remaining FROM inventory_lots WHERE id = ?`, older.ID).Scan(&olderRemaining)
+	_ = tx.QueryRow(`SELECT quantity_remaining FROM inventory_lots WHERE id = ?`, newer.ID).Scan(&newerRemaining)
+	if olderRemaining != 1 || newerRemaining != 2 {
+		t.Errorf("Expected lots restored to 1 and 2, got %v and %v", olderRemaining, newerRemaining)
+	}
+	_ = tx.QueryRow(`SELECT lot_number FROM inventory_items WHERE item_type = 'progesterone' AND account_id = 1`).Scan(&activeLot)
+	if activeLot.String != "A1" {
+		t.Errorf("Expected item to show active lot A1, got %q", activeLot.String)
+	}
+
+	var consumptions int
+	_ = tx.QueryRow(`SELECT COUNT(*) FROM inventory_lot_consumptions WHERE injection_id = ?`, injection.ID).Scan(&consumptions)
+	if consumptions != 0 {
+		t.Errorf("Expected no consumptions left, got %d", consumptions)
+	}
+}
+
+func TestInventoryLotRepository_ListByItemType(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	tx, err := db.BeginTx()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	now := time.Now()
+	receiveTestLot(t, tx, "A1", 1, now.AddDate(0, -1, 0))
+	receiveTestLot(t, tx, "B2", 2, now)
+	if _, err := ConsumeLotsFIFO(tx, 1, "progesterone", 1, sql.NullInt64{}); err != nil {
+		t.Fatalf("Failed to consume lots: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	repo := NewInventoryLotRepository(db)
+	lots, err := repo.ListByItemType(1, "progesterone", false)
+	if err != nil {
+		t.Fatalf("Failed to list lots: %v", err)
+	}
+	if len(lots) != 1 || lots[0].LotNumber.String != "B2" {
+		t.Errorf("Expected only lot B2 with stock, got %d lots", len(lots))
+	}
+
+	all, _ := repo.ListByItemType(1, "progesterone", true)
+	if len(all) != 2 || all[0].LotNumber.String != "A1" {
+		t.Errorf("Expected both lots oldest first, got %d lots", len(all))
+	}
+
+	active, err := repo.GetActiveLot(1, "progesterone")
+	if err != nil || active.LotNumber.String != "B2" {
+		t.Errorf("Expected active lot B2, got %+v (%v)", active, err)
+	}
+	if _, err := repo.GetActiveLot(2, "progesterone"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for other account, got %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"injection-tracker/internal/database"
 	"injection-tracker/internal/models"
@@ -60,8 +63,10 @@ func (r *InventoryRepository) Upsert(item *models.InventoryItem, accountID int64
 			low_stock_threshold = excluded.low_stock_threshold,
 			notes = excluded.notes,
 			updated_at = CURRENT_TIMESTAMP
+			RETURNING id
 	`
-	result, err := r.db.Exec(query,
+	var id int64
+	err := r.db.QueryRow(query,
 		item.ItemType,
 		item.Quantity,
 		item.Unit,
@@ -70,16 +75,11 @@ func (r *InventoryRepository) Upsert(item *models.InventoryItem, accountID int64
 		item.LowStockThreshold,
 		item.Notes,
 		accountID,
-	)
+	).Scan(&id)
 	if err != nil {
 		return fmt.Errorf("failed to upsert inventory item: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	item.ID = id
 	item.AccountID = accountID
 	return nil
@@ -144,10 +144,10 @@ func (r *InventoryRepository) AdjustQuantity(itemType string, accountID int64, d
 
 	// Log the change
 	query = `
-		INSERT INTO inventory_history (item_type, change_amount, quantity_before, quantity_after, reason, reference_id, reference_type, performed_by, timestamp, notes)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?)
+		INSERT INTO inventory_history (account_id, item_type, change_amount, quantity_before, quantity_after, reason, reference_id, reference_type, performed_by, timestamp, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?)
 	`
-	_, err = tx.Exec(query, itemType, delta, currentQuantity, newQuantity, reason, referenceID, referenceType, userID, notes)
+	_, err = tx.Exec(query, accountID, itemType, delta, currentQuantity, newQuantity, reason, referenceID, referenceType, userID, notes)
 	if err != nil {
 		return fmt.Errorf("failed to log inventory change: %w", err)
 	}
@@ -215,10 +215,10 @@ func (r *InventoryRepository) DecrementForInjection(injectionID int64, accountID
 
 		// Log the change
 		query = `
-			INSERT INTO inventory_history (item_type, change_amount, quantity_before, quantity_after, reason, reference_id, reference_type, performed_by, timestamp, notes)
-			VALUES (?, ?, ?, ?, 'injection', ?, 'injection', ?, CURRENT_TIMESTAMP, NULL)
+			INSERT INTO inventory_history (account_id, item_type, change_amount, quantity_before, quantity_after, reason, reference_id, reference_type, performed_by, timestamp, notes)
+			VALUES (?, ?, ?, ?, ?, 'injection', ?, 'injection', ?, CURRENT_TIMESTAMP, NULL)
 		`
-		_, err = tx.Exec(query, itemType, -amount, currentQuantity, newQuantity, injectionID, userID)
+		_, err = tx.Exec(query, accountID, itemType, -amount, currentQuantity, newQuantity, injectionID, userID)
 		if err != nil {
 			return fmt.Errorf("failed to log inventory change for %s: %w", itemType, err)
 		}
@@ -231,6 +231,331 @@ func (r *InventoryRepository) DecrementForInjection(injectionID int64, accountID
 	return nil
 }
 
+// ErrNegativeStock is returned by ApplyAdjustment for a removal larger than
+// the stock on hand
+var ErrNegativeStock = errors.New("would result in negative quantity")
+
+// InventoryAdjustment is a manual change to an item's stock. The optional
+// fields describe the lot an addition arrives as and are copied to the item.
+type InventoryAdjustment struct {
+	Change            float64
+	Reason            string
+	Notes             sql.NullString
+	ExpirationDate    sql.NullTime
+	LotNumber         sql.NullString
+	LowStockThreshold sql.NullFloat64
+}
+
+// ApplyAdjustment changes the stock of one of the account's item types and
+// logs it to the inventory history and audit log. Additions arrive as a new
+// lot; removals come out of the oldest lots. The item is created from its
+// type definition if the account has no stock of it yet. It returns the
+// quantity beforehand.
+func (r *InventoryRepository) ApplyAdjustment(def *models.InventoryItemType, accountID, userID int64, adj InventoryAdjustment) (float64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	quantityBefore, _, err := applyAdjustment(r.db.Context(), tx, def, accountID, userID, adj)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return quantityBefore, nil
+}
+
+// applyAdjustment is ApplyAdjustment within tx. It also returns the lot an
+// addition was received as.
+func applyAdjustment(ctx context.Context, tx *sql.Tx, def *models.InventoryItemType, accountID, userID int64, adj InventoryAdjustment) (float64, sql.NullInt64, error) {
+	var lotID sql.NullInt64
+	now := time.Now()
+	var currentQty float64
+	err := tx.QueryRow(`
+		SELECT quantity FROM inventory_items WHERE item_type = ? AND account_id = ?
+	`, def.ItemType, accountID).Scan(&currentQty)
+	if err == sql.ErrNoRows {
+		threshold := adj.LowStockThreshold
+		if !threshold.Valid {
+			threshold = def.ReorderThreshold
+		}
+		_, err = tx.Exec(`
+			INSERT INTO inventory_items (item_type, quantity, unit, expiration_date, lot_number, low_stock_threshold, account_id, created_at, updated_at)
+			VALUES (?, 0, ?, ?, ?, ?, ?, ?, ?)
+		`, def.ItemType, def.Unit, adj.ExpirationDate, adj.LotNumber, threshold, accountID, now, now)
+		if err != nil {
+			return 0, lotID, fmt.Errorf("failed to create inventory item: %w", err)
+		}
+	} else if err != nil {
+		return 0, lotID, fmt.Errorf("failed to get current quantity: %w", err)
+	}
+
+	newQty := currentQty + adj.Change
+	if newQty < 0 {
+		return 0, lotID, fmt.Errorf("%w (%.2f)", ErrNegativeStock, newQty)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE inventory_items
+		SET quantity = ?, updated_at = ?,
+			expiration_date = COALESCE(?, expiration_date),
+			lot_number = COALESCE(?, lot_number),
+			low_stock_threshold = COALESCE(?, low_stock_threshold)
+		WHERE item_type = ? AND account_id = ?
+	`, newQty, now, adj.ExpirationDate, adj.LotNumber, adj.LowStockThreshold, def.ItemType, accountID)
+	if err != nil {
+		return 0, lotID, fmt.Errorf("failed to update quantity: %w", err)
+	}
+
+	if adj.Change > 0 {
+		lot := &models.InventoryLot{
+			AccountID:        accountID,
+			ItemType:         def.ItemType,
+			LotNumber:        adj.LotNumber,
+			ExpirationDate:   adj.ExpirationDate,
+			QuantityReceived: adj.Change,
+			Notes:            adj.Notes,
+		}
+		err = ReceiveLot(tx, lot)
+		lotID = sql.NullInt64{Int64: lot.ID, Valid: true}
+	} else {
+		_, err = ConsumeLotsFIFO(tx, accountID, def.ItemType, -adj.Change, sql.NullInt64{})
+	}
+	if err != nil {
+		return 0, lotID, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO inventory_history (
+			account_id, item_type, change_amount, quantity_before, quantity_after,
+			reason, performed_by, timestamp, notes
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, accountID, def.ItemType, adj.Change, currentQty, newQty, adj.Reason, userID, now, adj.Notes)
+	if err != nil {
+		return 0, lotID, fmt.Errorf("failed to log inventory change: %w", err)
+	}
+
+	if err := logAudit(ctx, tx, userID, "adjust", "inventory", 0,
+		fmt.Sprintf("Adjusted %s inventory by %.2f (reason: %s)", def.ItemType, adj.Change, adj.Reason)); err != nil {
+		return 0, lotID, err
+	}
+
+	return currentQty, lotID, nil
+}
+
+// ErrEntryReversed is returned when reversing a history entry that has
+// already been reversed
+var ErrEntryReversed = errors.New("inventory entry has already been reversed")
+
+// ErrEntryNotReversible is returned for history entries that can't be
+// reversed: reversals themselves, and changes owned by a vial or purchase
+// order, which have their own way back
+var ErrEntryNotReversible = errors.New("inventory entry cannot be reversed")
+
+// ReverseEntry undoes one of the account's inventory history entries with a
+// new entry for the opposite of the change it actually made, with an audit
+// entry. It returns the reversal, or ErrNotFound if the entry isn't the
+// account's.
+func (r *InventoryRepository) ReverseEntry(id, accountID, userID int64, note sql.NullString) (*models.InventoryHistory, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if !note.Valid || note.String == "" {
+		note = sql.NullString{String: fmt.Sprintf("Reversed entry #%d", id), Valid: true}
+	}
+	reversal, err := reverseEntry(tx, id, accountID, userID, note)
+	if err != nil {
+		return nil, err
+	}
+	if err := logAudit(r.db.Context(), tx, userID, "reverse", "inventory", id,
+		fmt.Sprintf("Reversed %s inventory entry #%d by %.2f", reversal.ItemType, id, reversal.ChangeAmount)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return reversal, nil
+}
+
+// reverseEntry is ReverseEntry within tx, without the audit entry. The
+// reversal keeps the original's reason and reference so totals by either
+// net out, and is taken from the quantities before and after rather than
+// the amount requested, since decrements stop at zero. Stock an injection
+// took goes back to the lots and vials it came from; otherwise a removal
+// comes back as a new lot and an addition comes out of the oldest lots.
+// Reversing stock an injection returned stops at zero like the injection
+// itself; any other reversal fails with ErrNegativeStock rather than take
+// more than is on hand.
+func reverseEntry(tx *sql.Tx, id, accountID, userID int64, note sql.NullString) (*models.InventoryHistory, error) {
+	var entry models.InventoryHistory
+	var isReversal bool
+	err := tx.QueryRow(`
+		SELECT h.id, h.item_type, h.quantity_before, h.quantity_after, h.reason,
+		       h.reference_id, h.reference_type, h.profile_version, h.reversed_by,
+		       EXISTS (SELECT 1 FROM inventory_history r WHERE r.reversed_by = h.id)
+		FROM inventory_history h
+		WHERE h.id = ? AND h.account_id = ?
+	`, id, accountID).Scan(&entry.ID, &entry.ItemType, &entry.QuantityBefore, &entry.QuantityAfter, &entry.Reason,
+		&entry.ReferenceID, &entry.ReferenceType, &entry.ProfileVersion, &entry.ReversedBy, &isReversal)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory entry: %w", err)
+	}
+	if entry.ReversedBy.Valid {
+		return nil, ErrEntryReversed
+	}
+	injection := entry.ReferenceType.Valid && entry.ReferenceType.String == "injection"
+	owned := entry.ReferenceType.Valid && !injection && entry.ReferenceType.String != "stocktake" && entry.ReferenceType.String != "medication_log"
+	if isReversal || owned {
+		return nil, ErrEntryNotReversible
+	}
+
+	var currentQty float64
+	err = tx.QueryRow(`
+		SELECT quantity FROM inventory_items WHERE item_type = ? AND account_id = ?
+	`, entry.ItemType, accountID).Scan(&currentQty)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current quantity: %w", err)
+	}
+
+	change := entry.QuantityBefore - entry.QuantityAfter
+	newQty := currentQty + change
+	if newQty < 0 {
+		if !injection {
+			return nil, fmt.Errorf("%w (%.2f)", ErrNegativeStock, newQty)
+		}
+		newQty = 0
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(`
+		UPDATE inventory_items SET quantity = ?, updated_at = ? WHERE item_type = ? AND account_id = ?
+	`, newQty, now, entry.ItemType, accountID); err != nil {
+		return nil, fmt.Errorf("failed to update quantity: %w", err)
+	}
+
+	switch {
+	case injection && change > 0:
+		err = ReturnInjectionLots(tx, entry.ReferenceID.Int64, entry.ItemType, change)
+		if err == nil {
+			err = ReturnInjectionVials(tx, entry.ReferenceID.Int64, entry.ItemType, change)
+		}
+	case injection && change < 0:
+		injectionID := entry.ReferenceID
+		_, err = ConsumeLotsFIFO(tx, accountID, entry.ItemType, -change, injectionID)
+		if err == nil {
+			err = ConsumeVials(tx, accountID, entry.ItemType, -change, injectionID.Int64)
+		}
+	case change > 0:
+		err = ReceiveLot(tx, &models.InventoryLot{
+			AccountID:        accountID,
+			ItemType:         entry.ItemType,
+			QuantityReceived: change,
+			Notes:            note,
+		})
+	case change < 0:
+		_, err = ConsumeLotsFIFO(tx, accountID, entry.ItemType, -change, sql.NullInt64{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reversal := &models.InventoryHistory{
+		ItemType:       entry.ItemType,
+		ChangeAmount:   newQty - currentQty,
+		QuantityBefore: currentQty,
+		QuantityAfter:  newQty,
+		Reason:         entry.Reason,
+		ReferenceID:    entry.ReferenceID,
+		ReferenceType:  entry.ReferenceType,
+		PerformedBy:    sql.NullInt64{Int64: userID, Valid: true},
+		Timestamp:      now,
+		Notes:          note,
+		ProfileVersion: entry.ProfileVersion,
+	}
+	err = tx.QueryRow(`
+		INSERT INTO inventory_history (
+			account_id, item_type, change_amount, quantity_before, quantity_after,
+			reason, reference_id, reference_type, performed_by, timestamp, notes, profile_version
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, accountID, reversal.ItemType, reversal.ChangeAmount, reversal.QuantityBefore, reversal.QuantityAfter, reversal.Reason,
+		reversal.ReferenceID, reversal.ReferenceType, reversal.PerformedBy, reversal.Timestamp, reversal.Notes,
+		reversal.ProfileVersion).Scan(&reversal.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log inventory reversal: %w", err)
+	}
+
+	// Claim the entry last, so a concurrent reversal of it fails here
+	result, err := tx.Exec(`
+		UPDATE inventory_history SET reversed_by = ? WHERE id = ? AND reversed_by IS NULL
+	`, reversal.ID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark inventory entry reversed: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to check rows affected: %w", err)
+	} else if n == 0 {
+		return nil, ErrEntryReversed
+	}
+	return reversal, nil
+}
+
+// UpdateDetails saves an item's quantity, lot, expiration, threshold and
+// notes as given, with an audit entry. Setting the quantity this way isn't
+// logged to the inventory history; ApplyAdjustment is for stock changes.
+func (r *InventoryRepository) UpdateDetails(item *models.InventoryItem, userID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE inventory_items
+		SET quantity = ?, expiration_date = ?, lot_number = ?, low_stock_threshold = ?, notes = ?, updated_at = ?
+		WHERE item_type = ? AND account_id = ?
+	`,
+		item.Quantity,
+		item.ExpirationDate,
+		item.LotNumber,
+		item.LowStockThreshold,
+		item.Notes,
+		now,
+		item.ItemType,
+		item.AccountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update inventory item: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	if err := logAudit(r.db.Context(), tx, userID, "update", "inventory", 0, fmt.Sprintf("Updated inventory for %s", item.ItemType)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	item.UpdatedAt = now
+	return nil
+}
+
 // List retrieves all inventory items for a specific account
 func (r *InventoryRepository) List(accountID int64) ([]*models.InventoryItem, error) {
 	query := `
@@ -265,13 +590,12 @@ func (r *InventoryRepository) ListLowStock(accountID int64) ([]*models.Inventory
 	return r.scanInventoryItems(rows)
 }
 
-// GetHistory retrieves inventory history for an item type (filtered by account via JOIN)
+// GetHistory retrieves inventory history for an item type (filtered by account)
 func (r *InventoryRepository) GetHistory(itemType string, accountID int64, limit, offset int) ([]*models.InventoryHistory, error) {
 	query := `
-		SELECT h.id, h.item_type, h.change_amount, h.quantity_before, h.quantity_after, h.reason, h.reference_id, h.reference_type, h.performed_by, h.timestamp, h.notes
+		SELECT h.id, h.item_type, h.change_amount, h.quantity_before, h.quantity_after, h.reason, h.reference_id, h.reference_type, h.performed_by, h.timestamp, h.notes, h.profile_version, h.reversed_by
 		FROM inventory_history h
-		WHERE h.item_type = ?
-		AND EXISTS (SELECT 1 FROM inventory_items i WHERE i.item_type = h.item_type AND i.account_id = ?)
+		WHERE h.item_type = ? AND h.account_id = ?
 		ORDER BY h.timestamp DESC
 		LIMIT ? OFFSET ?
 	`
@@ -287,9 +611,9 @@ func (r *InventoryRepository) GetHistory(itemType string, accountID int64, limit
 // GetAllHistory retrieves all inventory history with pagination (filtered by account)
 func (r *InventoryRepository) GetAllHistory(accountID int64, limit, offset int) ([]*models.InventoryHistory, error) {
 	query := `
-		SELECT h.id, h.item_type, h.change_amount, h.quantity_before, h.quantity_after, h.reason, h.reference_id, h.reference_type, h.performed_by, h.timestamp, h.notes
+		SELECT h.id, h.item_type, h.change_amount, h.quantity_before, h.quantity_after, h.reason, h.reference_id, h.reference_type, h.performed_by, h.timestamp, h.notes, h.profile_version, h.reversed_by
 		FROM inventory_history h
-		WHERE EXISTS (SELECT 1 FROM inventory_items i WHERE i.item_type = h.item_type AND i.account_id = ?)
+		WHERE h.account_id = ?
 		ORDER BY h.timestamp DESC
 		LIMIT ? OFFSET ?
 	`
@@ -307,8 +631,7 @@ func (r *InventoryRepository) CountHistory(itemType string, accountID int64) (in
 	query := `
 		SELECT COUNT(*)
 		FROM inventory_history h
-		WHERE h.item_type = ?
-		AND EXISTS (SELECT 1 FROM inventory_items i WHERE i.item_type = h.item_type AND i.account_id = ?)
+		WHERE h.item_type = ? AND h.account_id = ?
 	`
 	var count int64
 	err := r.db.QueryRow(query, itemType, accountID).Scan(&count)
@@ -318,6 +641,29 @@ func (r *InventoryRepository) CountHistory(itemType string, accountID int64) (in
 	return count, nil
 }
 
+// HistoryPage retrieves a page of an account's inventory history, newest
+// first, for one item type or for all of them when itemType is empty
+func (r *InventoryRepository) HistoryPage(itemType string, accountID int64, page PageRequest) (*Page[*models.InventoryHistory], error) {
+	from := `
+		FROM inventory_history h
+		WHERE h.account_id = ?`
+	args := []interface{}{accountID}
+	if itemType != "" {
+		from += ` AND h.item_type = ?`
+		args = append(args, itemType)
+	}
+
+	return listPage(r.db, page, pageQuery{
+		Columns: `h.id, h.item_type, h.change_amount, h.quantity_before, h.quantity_after, h.reason, h.reference_id, h.reference_type, h.performed_by, h.timestamp, h.notes, h.profile_version, h.reversed_by`,
+		From:    from,
+		Args:    args,
+		Table:   "inventory_history",
+		Alias:   "h",
+	}, r.scanInventoryHistory, func(h *models.InventoryHistory) (time.Time, int64) {
+		return h.Timestamp, h.ID
+	})
+}
+
 // Delete deletes an inventory item for a specific account
 func (r *InventoryRepository) Delete(itemType string, accountID int64) error {
 	query := `DELETE FROM inventory_items WHERE item_type = ? AND account_id = ?`
@@ -381,6 +727,8 @@ func (r *InventoryRepository) scanInventoryHistory(rows *sql.Rows) ([]*models.In
 			&h.PerformedBy,
 			&h.Timestamp,
 			&h.Notes,
+			&h.ProfileVersion,
+			&h.ReversedBy,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan inventory history: %w", err)
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"injection-tracker/internal/database"
 	"injection-tracker/internal/models"
@@ -44,6 +45,7 @@ func setupInventoryTestDB(t *testing.T) *database.DB {
 
 		CREATE TABLE inventory_history (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER,
 			item_type TEXT NOT NULL,
 			change_amount REAL NOT NULL,
 			quantity_before REAL NOT NULL,
@@ -53,7 +55,9 @@ func setupInventoryTestDB(t *testing.T) *database.DB {
 			reference_type TEXT,
 			performed_by INTEGER,
 			timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			notes TEXT
+			notes TEXT,
+			profile_version INTEGER,
+			reversed_by INTEGER
 		);
 
 		CREATE INDEX idx_inventory_history_type ON inventory_history(item_type);
@@ -686,7 +690,7 @@ func BenchmarkInventoryRepository_DecrementForInjection(b *testing.B) {
 	defer db.Close()
 
 	_, _ = db.Exec("CREATE TABLE inventory_items (id INTEGER PRIMARY KEY AUTOINCREMENT, item_type TEXT UNIQUE NOT NULL CHECK(item_type IN ('progesterone', 'draw_needle', 'injection_needle', 'syringe', 'swab', 'gauze')), quantity REAL NOT NULL, unit TEXT NOT NULL, expiration_date TIMESTAMP, lot_number TEXT, low_stock_threshold REAL, notes TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);")
-	_, _ = db.Exec("CREATE TABLE inventory_history (id INTEGER PRIMARY KEY AUTOINCREMENT, item_type TEXT NOT NULL, change_amount REAL NOT NULL, quantity_before REAL NOT NULL, quantity_after REAL NOT NULL, reason TEXT NOT NULL, reference_id INTEGER, reference_type TEXT, performed_by INTEGER, timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP, notes TEXT);")
+	_, _ = db.Exec("CREATE TABLE inventory_history (id INTEGER PRIMARY KEY AUTOINCREMENT, account_id INTEGER, item_type TEXT NOT NULL, change_amount REAL NOT NULL, quantity_before REAL NOT NULL, quantity_after REAL NOT NULL, reason TEXT NOT NULL, reference_id INTEGER, reference_type TEXT, performed_by INTEGER, timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP, notes TEXT);")
 
 	// Create items with large quantities for benchmarking
 	items := []string{"progesterone", "draw_needle", "injection_needle", "syringe", "swab"}
@@ -701,3 +705,65 @@ func BenchmarkInventoryRepository_DecrementForInjection(b *testing.B) {
 		_ = repo.DecrementForInjection(int64(i), 1, 1, 1.0)
 	}
 }
+
+func TestInventoryRepository_HistoryPage(t *testing.T) {
+	db := setupInventoryTestDB(t)
+	defer db.Close()
+
+	createTestInventoryItems(t, db)
+	repo := NewInventoryRepository(db)
+
+	// Adjustments in the same second share a CURRENT_TIMESTAMP, and the
+	// bound time.Time below is stored in a different text format
+	for i := 0; i < 4; i++ {
+		if err := repo.AdjustQuantity("progesterone", 1, -0.5, "test_usage", sql.NullInt64{}, sql.NullString{}, sql.NullInt64{}, sql.NullString{}); err != nil {
+			t.Fatalf("Failed to adjust: %v", err)
+		}
+	}
+	if _, err := db.Exec(`
+		INSERT INTO inventory_history (account_id, item_type, change_amount, quantity_before, quantity_after, reason, timestamp)
+		VALUES (1, 'syringe', 5, 0, 5, 'purchase', ?)
+	`, time.Now().UTC().Add(-time.Hour)); err != nil {
+		t.Fatalf("Failed to insert history: %v", err)
+	}
+
+	var ids []int64
+	page := PageRequest{Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("Paging did not terminate")
+		}
+		result, err := repo.HistoryPage("", 1, page)
+		if err != nil {
+			t.Fatalf("Failed to get history page: %v", err)
+		}
+		if result.Total != 5 {
+			t.Errorf("Expected total 5, got %d", result.Total)
+		}
+		for _, h := range result.Items {
+			ids = append(ids, h.ID)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor = result.NextCursor
+	}
+	want := []int64{4, 3, 2, 1, 5}
+	if len(ids) != len(want) {
+		t.Fatalf("Expected ids %v, got %v", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("Expected ids %v, got %v", want, ids)
+		}
+	}
+
+	// Other accounts see nothing
+	other, err := repo.HistoryPage("progesterone", 2, PageRequest{})
+	if err != nil {
+		t.Fatalf("Failed to get history page: %v", err)
+	}
+	if other.Total != 0 || len(other.Items) != 0 {
+		t.Errorf("Expected no history for another account, got %d", other.Total)
+	}
+}
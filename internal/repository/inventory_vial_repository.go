@@ -0,0 +1,529 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// ErrVialNotSealed is returned when opening a vial that has already been
+// opened
+var ErrVialNotSealed = errors.New("vial has already been opened")
+
+// ErrVialClosed is returned when discarding a vial that is already empty or
+// discarded
+var ErrVialClosed = errors.New("vial is already empty or discarded")
+
+// InventoryVialRepository tracks an account's medication vials one by one
+type InventoryVialRepository struct {
+	db *database.DB
+}
+
+func NewInventoryVialRepository(db *database.DB) *InventoryVialRepository {
+	return &InventoryVialRepository{db: db}
+}
+
+const inventoryVialColumns = `id, account_id, item_type, lot_id, label, volume_ml, remaining_ml, discard_after_days,
+		       status, opened_at, discard_at, closed_at, notes, created_at, updated_at`
+
+// vialOrder lists open vials first, in the order they are drawn from, then
+// sealed ones in the order they will be opened, then the rest
+const vialOrder = `ORDER BY CASE status WHEN 'open' THEN 0 WHEN 'sealed' THEN 1 ELSE 2 END, opened_at, id`
+
+func scanInventoryVial(row rowScanner) (*models.InventoryVial, error) {
+	var v models.InventoryVial
+	err := row.Scan(
+		&v.ID,
+		&v.AccountID,
+		&v.ItemType,
+		&v.LotID,
+		&v.Label,
+		&v.VolumeML,
+		&v.RemainingML,
+		&v.DiscardAfterDays,
+		&v.Status,
+		&v.OpenedAt,
+		&v.DiscardAt,
+		&v.ClosedAt,
+		&v.Notes,
+		&v.CreatedAt,
+		&v.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *InventoryVialRepository) query(query string, args ...interface{}) ([]*models.InventoryVial, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vials: %w", err)
+	}
+	defer rows.Close()
+
+	vials := []*models.InventoryVial{}
+	for rows.Next() {
+		vial, err := scanInventoryVial(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan vial: %w", err)
+		}
+		vials = append(vials, vial)
+	}
+	return vials, rows.Err()
+}
+
+// GetByID retrieves a vial, scoped to the account
+func (r *InventoryVialRepository) GetByID(id, accountID int64) (*models.InventoryVial, error) {
+	query := `SELECT ` + inventoryVialColumns + ` FROM inventory_vials WHERE id = ? AND account_id = ?`
+	vial, err := scanInventoryVial(r.db.QueryRow(query, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vial: %w", err)
+	}
+	return vial, nil
+}
+
+// List retrieves an account's vials, open ones first. An empty itemType
+// lists every item's. Empty and discarded vials are only included when
+// includeClosed is set.
+func (r *InventoryVialRepository) List(accountID int64, itemType string, includeClosed bool) ([]*models.InventoryVial, error) {
+	query := `SELECT ` + inventoryVialColumns + ` FROM inventory_vials WHERE account_id = ?`
+	args := []interface{}{accountID}
+	if itemType != "" {
+		query += ` AND item_type = ?`
+		args = append(args, itemType)
+	}
+	if !includeClosed {
+		query += ` AND status IN ('sealed', 'open')`
+	}
+	return r.query(query+` `+vialOrder, args...)
+}
+
+// ListDueForDiscard retrieves the account's open vials whose beyond-use date
+// falls before the given time, soonest first
+func (r *InventoryVialRepository) ListDueForDiscard(accountID int64, before time.Time) ([]*models.InventoryVial, error) {
+	query := `SELECT ` + inventoryVialColumns + ` FROM inventory_vials
+		WHERE account_id = ? AND status = 'open' AND discard_at < ?
+		ORDER BY discard_at, id`
+	return r.query(query, accountID, before.UTC())
+}
+
+// VialReceipt describes vials arriving together from one lot
+type VialReceipt struct {
+	Count            int
+	VolumeML         float64
+	DiscardAfterDays int
+	Label            sql.NullString
+	LotNumber        sql.NullString
+	ExpirationDate   sql.NullTime
+	Notes            sql.NullString
+}
+
+// Receive adds sealed vials to stock. Their combined volume is received as
+// one lot, the same as a restock, and each vial records that lot. It returns
+// the new vials and the item's quantity beforehand.
+func (r *InventoryVialRepository) Receive(def *models.InventoryItemType, accountID, userID int64, receipt VialReceipt) ([]*models.InventoryVial, float64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	notes := receipt.Notes
+	if !notes.Valid {
+		notes = sql.NullString{String: fmt.Sprintf("Received %d vial(s) of %s mL", receipt.Count, formatML(receipt.VolumeML)), Valid: true}
+	}
+	quantityBefore, lotID, err := applyAdjustment(r.db.Context(), tx, def, accountID, userID, InventoryAdjustment{
+		Change:         float64(receipt.Count) * receipt.VolumeML,
+		Reason:         "restock",
+		Notes:          notes,
+		LotNumber:      receipt.LotNumber,
+		ExpirationDate: receipt.ExpirationDate,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	vials := make([]*models.InventoryVial, 0, receipt.Count)
+	for i := 0; i < receipt.Count; i++ {
+		vial := &models.InventoryVial{
+			AccountID:        accountID,
+			ItemType:         def.ItemType,
+			LotID:            lotID,
+			Label:            receipt.Label,
+			VolumeML:         receipt.VolumeML,
+			RemainingML:      receipt.VolumeML,
+			DiscardAfterDays: receipt.DiscardAfterDays,
+			Status:           models.VialSealed,
+			Notes:            receipt.Notes,
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		}
+		err := tx.QueryRow(`
+			INSERT INTO inventory_vials (account_id, item_type, lot_id, label, volume_ml, remaining_ml,
+			                             discard_after_days, status, notes, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id
+		`,
+			vial.AccountID,
+			vial.ItemType,
+			vial.LotID,
+			vial.Label,
+			vial.VolumeML,
+			vial.RemainingML,
+			vial.DiscardAfterDays,
+			vial.Status,
+			vial.Notes,
+			now,
+			now,
+		).Scan(&vial.ID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create vial: %w", err)
+		}
+		vials = append(vials, vial)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return vials, quantityBefore, nil
+}
+
+// Open starts a sealed vial's beyond-use clock from openedAt. It returns
+// ErrVialNotSealed if the vial has been opened before.
+func (r *InventoryVialRepository) Open(vial *models.InventoryVial, openedAt time.Time) error {
+	if vial.Status != models.VialSealed {
+		return ErrVialNotSealed
+	}
+	discardAt := openedAt.AddDate(0, 0, vial.DiscardAfterDays)
+	result, err := r.db.Exec(`
+		UPDATE inventory_vials SET status = 'open', opened_at = ?, discard_at = ?, updated_at = ?
+		WHERE id = ? AND account_id = ? AND status = 'sealed'
+	`, openedAt.UTC(), discardAt.UTC(), time.Now(), vial.ID, vial.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to open vial: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrVialNotSealed
+	}
+
+	vial.Status = models.VialOpen
+	vial.OpenedAt = sql.NullTime{Time: openedAt, Valid: true}
+	vial.DiscardAt = sql.NullTime{Time: discardAt, Valid: true}
+	return nil
+}
+
+// Update saves a vial's label, notes, discard-after days and opened date. An
+// open vial's beyond-use date follows from the last two.
+func (r *InventoryVialRepository) Update(vial *models.InventoryVial) error {
+	if vial.OpenedAt.Valid {
+		vial.DiscardAt = sql.NullTime{Time: vial.OpenedAt.Time.AddDate(0, 0, vial.DiscardAfterDays), Valid: true}
+	}
+	var openedAt, discardAt interface{}
+	if vial.OpenedAt.Valid {
+		openedAt, discardAt = vial.OpenedAt.Time.UTC(), vial.DiscardAt.Time.UTC()
+	}
+
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE inventory_vials
+		SET label = ?, notes = ?, discard_after_days = ?, opened_at = ?, discard_at = ?, updated_at = ?
+		WHERE id = ? AND account_id = ?
+	`, vial.Label, vial.Notes, vial.DiscardAfterDays, openedAt, discardAt, now, vial.ID, vial.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to update vial: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	vial.UpdatedAt = now
+	return nil
+}
+
+// Discard throws a vial out. Whatever was left in it comes out of stock,
+// from the lot it belongs to, and is logged to the inventory history as
+// expired. It returns the item's quantity beforehand.
+func (r *InventoryVialRepository) Discard(vial *models.InventoryVial, userID int64, note string) (float64, error) {
+	if vial.Status != models.VialSealed && vial.Status != models.VialOpen {
+		return 0, ErrVialClosed
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE inventory_vials SET status = 'discarded', remaining_ml = 0, closed_at = ?, updated_at = ?
+		WHERE id = ? AND account_id = ? AND status IN ('sealed', 'open')
+	`, now.UTC(), now, vial.ID, vial.AccountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to discard vial: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return 0, ErrVialClosed
+	}
+
+	var currentQty float64
+	err = tx.QueryRow(`
+		SELECT quantity FROM inventory_items WHERE item_type = ? AND account_id = ?
+	`, vial.ItemType, vial.AccountID).Scan(&currentQty)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to get current inventory for %s: %w", vial.ItemType, err)
+	}
+
+	wasted := min(vial.RemainingML, currentQty)
+	if wasted > lotEpsilon {
+		newQty := currentQty - wasted
+		if _, err := tx.Exec(`
+			UPDATE inventory_items SET quantity = ?, updated_at = ? WHERE item_type = ? AND account_id = ?
+		`, newQty, now, vial.ItemType, vial.AccountID); err != nil {
+			return 0, fmt.Errorf("failed to update inventory for %s: %w", vial.ItemType, err)
+		}
+		if err := takeFromVialLot(tx, vial, wasted); err != nil {
+			return 0, err
+		}
+
+		if note == "" {
+			note = fmt.Sprintf("Discarded vial #%d with %s mL left", vial.ID, formatML(vial.RemainingML))
+		}
+		_, err = tx.Exec(`
+			INSERT INTO inventory_history (
+				account_id, item_type, change_amount, quantity_before, quantity_after,
+				reason, reference_id, reference_type, performed_by, timestamp, notes
+			) VALUES (?, ?, ?, ?, ?, 'expired', ?, 'vial', ?, ?, ?)
+		`, vial.AccountID, vial.ItemType, -wasted, currentQty, newQty, vial.ID, userID, now, note)
+		if err != nil {
+			return 0, fmt.Errorf("failed to log discarded vial: %w", err)
+		}
+	}
+
+	if err := logAudit(r.db.Context(), tx, userID, "discard", "inventory_vial", vial.ID,
+		fmt.Sprintf("Discarded %s vial #%d with %s mL left", vial.ItemType, vial.ID, formatML(vial.RemainingML))); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	vial.Status = models.VialDiscarded
+	vial.RemainingML = 0
+	vial.ClosedAt = sql.NullTime{Time: now, Valid: true}
+	return currentQty, nil
+}
+
+// takeFromVialLot removes a discarded vial's leftovers from its lot, or from
+// the oldest lots if it has none or the lot holds less
+func takeFromVialLot(tx *sql.Tx, vial *models.InventoryVial, amount float64) error {
+	if vial.LotID.Valid {
+		var remaining float64
+		err := tx.QueryRow(`SELECT quantity_remaining FROM inventory_lots WHERE id = ?`, vial.LotID).Scan(&remaining)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to get vial lot: %w", err)
+		}
+		take := min(remaining, amount)
+		if take > 0 {
+			if _, err := tx.Exec(`UPDATE inventory_lots SET quantity_remaining = ? WHERE id = ?`, remaining-take, vial.LotID); err != nil {
+				return fmt.Errorf("failed to update vial lot: %w", err)
+			}
+			amount -= take
+		}
+	}
+	if amount > lotEpsilon {
+		_, err := ConsumeLotsFIFO(tx, vial.AccountID, vial.ItemType, amount, sql.NullInt64{})
+		return err
+	}
+	return syncActiveLot(tx, vial.AccountID, vial.ItemType)
+}
+
+// Delete stops tracking a vial. Stock is unchanged; what the vial held is no
+// longer attributed to any vial.
+func (r *InventoryVialRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec(`DELETE FROM inventory_vials WHERE id = ? AND account_id = ?`, id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete vial: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ConsumeVials draws amount of an item from its open vials, earliest opened
+// first, opening sealed vials as needed. Opening a vial starts its
+// beyond-use clock. A vial drawn dry becomes empty. Any amount beyond what
+// the vials hold is stock not kept in vials. The draw is recorded against
+// the injection so it can be returned.
+func ConsumeVials(tx *sql.Tx, accountID int64, itemType string, amount float64, injectionID int64) error {
+	if amount <= 0 {
+		return nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, status, remaining_ml, discard_after_days FROM inventory_vials
+		WHERE account_id = ? AND item_type = ? AND status IN ('open', 'sealed') AND remaining_ml > 0
+		`+vialOrder, accountID, itemType)
+	if err != nil {
+		return fmt.Errorf("failed to query vials: %w", err)
+	}
+
+	type vialBalance struct {
+		id               int64
+		status           string
+		remaining        float64
+		discardAfterDays int
+	}
+	var vials []vialBalance
+	for rows.Next() {
+		var v vialBalance
+		if err := rows.Scan(&v.id, &v.status, &v.remaining, &v.discardAfterDays); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan vial: %w", err)
+		}
+		vials = append(vials, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query vials: %w", err)
+	}
+
+	now := time.Now()
+	need := amount
+	for _, v := range vials {
+		if need <= lotEpsilon {
+			break
+		}
+		take := min(v.remaining, need)
+		remaining := v.remaining - take
+		if remaining < lotEpsilon {
+			remaining = 0
+		}
+
+		if v.status == models.VialSealed {
+			_, err = tx.Exec(`
+				UPDATE inventory_vials SET status = 'open', opened_at = ?, discard_at = ? WHERE id = ?
+			`, now.UTC(), now.AddDate(0, 0, v.discardAfterDays).UTC(), v.id)
+			if err != nil {
+				return fmt.Errorf("failed to open vial: %w", err)
+			}
+		}
+		if remaining == 0 {
+			_, err = tx.Exec(`UPDATE inventory_vials SET remaining_ml = 0, status = 'empty', closed_at = ? WHERE id = ?`, now.UTC(), v.id)
+		} else {
+			_, err = tx.Exec(`UPDATE inventory_vials SET remaining_ml = ? WHERE id = ?`, remaining, v.id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to draw from vial: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO inventory_vial_consumptions (vial_id, injection_id, amount) VALUES (?, ?, ?)
+		`, v.id, injectionID, take); err != nil {
+			return fmt.Errorf("failed to record vial consumption: %w", err)
+		}
+		need -= take
+	}
+	return nil
+}
+
+// ReturnInjectionVials puts up to amount of an item back into the vials an
+// injection drew it from, most recent draw first. A non-positive amount
+// returns everything the injection drew from any vial. A vial emptied by the
+// draw is open again; volume drawn from a vial since discarded isn't
+// returned to it.
+func ReturnInjectionVials(tx *sql.Tx, injectionID int64, itemType string, amount float64) error {
+	query := `
+		SELECT c.id, c.vial_id, c.amount, v.status
+		FROM inventory_vial_consumptions c
+		JOIN inventory_vials v ON v.id = c.vial_id
+		WHERE c.injection_id = ?`
+	args := []interface{}{injectionID}
+	if amount > 0 {
+		query += ` AND v.item_type = ?`
+		args = append(args, itemType)
+	}
+	query += ` ORDER BY c.id DESC`
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query vial consumptions: %w", err)
+	}
+
+	type consumption struct {
+		id, vialID int64
+		amount     float64
+		status     string
+	}
+	var consumptions []consumption
+	for rows.Next() {
+		var c consumption
+		if err := rows.Scan(&c.id, &c.vialID, &c.amount, &c.status); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan vial consumption: %w", err)
+		}
+		consumptions = append(consumptions, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query vial consumptions: %w", err)
+	}
+
+	remaining := amount
+	for _, c := range consumptions {
+		give := c.amount
+		if amount > 0 {
+			if remaining <= lotEpsilon {
+				break
+			}
+			give = min(c.amount, remaining)
+			remaining -= give
+		}
+
+		if c.status != models.VialDiscarded {
+			if _, err := tx.Exec(`
+				UPDATE inventory_vials
+				SET remaining_ml = remaining_ml + ?,
+					status = CASE WHEN status = 'empty' THEN 'open' ELSE status END,
+					closed_at = CASE WHEN status = 'empty' THEN NULL ELSE closed_at END
+				WHERE id = ?
+			`, give, c.vialID); err != nil {
+				return fmt.Errorf("failed to return volume to vial: %w", err)
+			}
+		}
+		if c.amount-give < lotEpsilon {
+			_, err = tx.Exec(`DELETE FROM inventory_vial_consumptions WHERE id = ?`, c.id)
+		} else {
+			_, err = tx.Exec(`UPDATE inventory_vial_consumptions SET amount = ? WHERE id = ?`, c.amount-give, c.id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update vial consumption: %w", err)
+		}
+	}
+	return nil
+}
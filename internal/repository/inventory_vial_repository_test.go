@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestInventoryVials_Lifecycle(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	var courseID int64
+	if err := db.QueryRow(`INSERT INTO courses (name, start_date, is_active, dose_ml, account_id) VALUES ('Course', ?, TRUE, 4, 1) RETURNING id`, time.Now()).Scan(&courseID); err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+
+	def := &models.InventoryItemType{AccountID: 1, ItemType: "progesterone", Name: "Progesterone", Unit: "mL"}
+	vialRepo := NewInventoryVialRepository(db)
+	vials, before, err := vialRepo.Receive(def, 1, 1, VialReceipt{
+		Count:            2,
+		VolumeML:         10,
+		DiscardAfterDays: 28,
+		LotNumber:        sql.NullString{String: "P1", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to receive vials: %v", err)
+	}
+	if before != 0 || len(vials) != 2 || !vials[0].LotID.Valid || vials[0].Status != models.VialSealed {
+		t.Fatalf("Expected two sealed vials from a new lot, got %d (before %v)", len(vials), before)
+	}
+	if got := stockOf(t, db, "progesterone"); got != 20 {
+		t.Errorf("Expected 20 mL after receiving, got %v", got)
+	}
+
+	vial := func(id int64) *models.InventoryVial {
+		t.Helper()
+		v, err := vialRepo.GetByID(id, 1)
+		if err != nil {
+			t.Fatalf("Failed to get vial: %v", err)
+		}
+		return v
+	}
+
+	// Injections open the first vial and run into the second
+	injections := NewInjectionRepository(db)
+	var recorded []*models.Injection
+	for i := 0; i < 3; i++ {
+		injection := &models.Injection{CourseID: courseID, Timestamp: time.Now(), Side: "left"}
+		if _, err := injections.Record(injection, 1, 1, 0); err != nil {
+			t.Fatalf("Failed to record injection: %v", err)
+		}
+		recorded = append(recorded, injection)
+	}
+	first, second := vial(vials[0].ID), vial(vials[1].ID)
+	if first.Status != models.VialEmpty || first.RemainingML != 0 || !first.ClosedAt.Valid {
+		t.Errorf("Expected the first vial drained, got %s with %v mL", first.Status, first.RemainingML)
+	}
+	if second.Status != models.VialOpen || second.RemainingML != 8 || !second.DiscardAt.Valid {
+		t.Errorf("Expected the second vial opened with 8 mL, got %s with %v mL", second.Status, second.RemainingML)
+	}
+	if days := second.DiscardAt.Time.Sub(second.OpenedAt.Time).Hours() / 24; days != 28 {
+		t.Errorf("Expected a 28 day beyond-use date, got %v days", days)
+	}
+
+	// Voiding the injection that drained the first vial opens it again
+	if err := injections.Void(recorded[2].ID, 1, 1, sql.NullString{}); err != nil {
+		t.Fatalf("Failed to void injection: %v", err)
+	}
+	first, second = vial(vials[0].ID), vial(vials[1].ID)
+	if first.Status != models.VialOpen || first.RemainingML != 2 || second.RemainingML != 10 {
+		t.Errorf("Expected 2 mL back in the first vial and 10 in the second, got %v and %v", first.RemainingML, second.RemainingML)
+	}
+	if second.Status != models.VialOpen {
+		t.Errorf("Expected the second vial to stay open once pierced, got %s", second.Status)
+	}
+
+	// A smaller dose goes back to the vial
+	recorded[1].DoseML = sql.NullFloat64{Float64: 3, Valid: true}
+	if err := injections.Amend(recorded[1], 1, 1, recorded[1].UpdatedAt); err != nil {
+		t.Fatalf("Failed to amend injection: %v", err)
+	}
+	if got := vial(vials[0].ID).RemainingML; got != 3 {
+		t.Errorf("Expected 3 mL in the first vial after the correction, got %v", got)
+	}
+
+	due, err := vialRepo.ListDueForDiscard(1, time.Now().AddDate(0, 0, 29))
+	if err != nil {
+		t.Fatalf("ListDueForDiscard failed: %v", err)
+	}
+	if len(due) != 2 || due[0].ID != vials[0].ID {
+		t.Errorf("Expected both open vials due for discard, first opened first, got %d", len(due))
+	}
+	if due, _ := vialRepo.ListDueForDiscard(1, time.Now()); len(due) != 0 {
+		t.Errorf("Expected nothing past its discard date yet, got %d", len(due))
+	}
+
+	if err := vialRepo.Open(vial(vials[0].ID), time.Now()); !errors.Is(err, ErrVialNotSealed) {
+		t.Errorf("Expected ErrVialNotSealed opening an open vial, got %v", err)
+	}
+	received, _, err := vialRepo.Receive(def, 1, 1, VialReceipt{Count: 1, VolumeML: 5, DiscardAfterDays: 28})
+	if err != nil {
+		t.Fatalf("Failed to receive vial: %v", err)
+	}
+	sealed := received[0]
+	opened := time.Now().Add(-48 * time.Hour)
+	if err := vialRepo.Open(sealed, opened); err != nil {
+		t.Fatalf("Failed to open vial: %v", err)
+	}
+	sealed.DiscardAfterDays = 1
+	if err := vialRepo.Update(sealed); err != nil {
+		t.Fatalf("Failed to update vial: %v", err)
+	}
+	if due, _ := vialRepo.ListDueForDiscard(1, time.Now()); len(due) != 1 || due[0].ID != sealed.ID {
+		t.Errorf("Expected the shortened vial past its discard date, got %d", len(due))
+	}
+
+	// Discarding takes the leftovers out of stock and the vial's lot
+	stockBefore := stockOf(t, db, "progesterone")
+	quantityBefore, err := vialRepo.Discard(sealed, 1, "")
+	if err != nil {
+		t.Fatalf("Failed to discard vial: %v", err)
+	}
+	if quantityBefore != stockBefore || stockOf(t, db, "progesterone") != stockBefore-5 {
+		t.Errorf("Expected 5 mL discarded from %v, got %v", stockBefore, stockOf(t, db, "progesterone"))
+	}
+	lot, err := NewInventoryLotRepository(db).GetByID(sealed.LotID.Int64, 1)
+	if err != nil {
+		t.Fatalf("Failed to get lot: %v", err)
+	}
+	if lot.QuantityRemaining != 0 {
+		t.Errorf("Expected the vial's lot emptied, got %v", lot.QuantityRemaining)
+	}
+	if _, err := vialRepo.Discard(sealed, 1, ""); !errors.Is(err, ErrVialClosed) {
+		t.Errorf("Expected ErrVialClosed discarding twice, got %v", err)
+	}
+	var reason string
+	if err := db.QueryRow(`SELECT reason FROM inventory_history WHERE reference_type = 'vial' AND reference_id = ?`, sealed.ID).Scan(&reason); err != nil || reason != "expired" {
+		t.Errorf("Expected the discard logged as expired, got %q (%v)", reason, err)
+	}
+
+	list, err := vialRepo.List(1, "progesterone", false)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 {
+		t.Errorf("Expected only the two open vials listed, got %d", len(list))
+	}
+	if err := vialRepo.Delete(sealed.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another account's delete to miss, got %v", err)
+	}
+}
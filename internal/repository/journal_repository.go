@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// JournalRepository stores an account's journal entries and their tags
+type JournalRepository struct {
+	db *database.DB
+}
+
+func NewJournalRepository(db *database.DB) *JournalRepository {
+	return &JournalRepository{db: db}
+}
+
+const journalEntryColumns = `e.id, e.account_id, e.author_id, e.course_id, e.entry_date, e.title, e.body, e.is_pinned, e.created_at, e.updated_at`
+
+// JournalTag normalizes a tag as it is stored: trimmed, lowercased, with
+// runs of whitespace collapsed to one space
+func JournalTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+}
+
+// JournalFilter narrows ListPage. Zero values don't filter.
+type JournalFilter struct {
+	CourseID   int64
+	Tag        string
+	Query      string    // Matched against the title, body and tags, ignoring case
+	Date       time.Time // The day the entry is about
+	Start      time.Time // Written at or after, inclusive
+	End        time.Time // Written before, exclusive
+	PinnedOnly bool
+}
+
+// JournalTagCount is a tag and how many of an account's entries carry it
+type JournalTagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+func scanJournalEntry(row rowScanner) (*models.JournalEntry, error) {
+	var e models.JournalEntry
+	err := row.Scan(
+		&e.ID,
+		&e.AccountID,
+		&e.AuthorID,
+		&e.CourseID,
+		&e.EntryDate,
+		&e.Title,
+		&e.Body,
+		&e.IsPinned,
+		&e.CreatedAt,
+		&e.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	e.Tags = []string{}
+	return &e, nil
+}
+
+// Create records a journal entry and its tags
+func (r *JournalRepository) Create(entry *models.JournalEntry) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	err = tx.QueryRow(`
+		INSERT INTO journal_entries (account_id, author_id, course_id, entry_date, title, body, is_pinned, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`,
+		entry.AccountID,
+		entry.AuthorID,
+		entry.CourseID,
+		entry.EntryDate,
+		entry.Title,
+		entry.Body,
+		entry.IsPinned,
+		now,
+		now,
+	).Scan(&entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create journal entry: %w", err)
+	}
+	if err := setJournalTags(tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves one of an account's journal entries
+func (r *JournalRepository) GetByID(id, accountID int64) (*models.JournalEntry, error) {
+	query := `SELECT ` + journalEntryColumns + ` FROM journal_entries e WHERE e.id = ? AND e.account_id = ?`
+	entry, err := scanJournalEntry(r.db.QueryRow(query, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get journal entry: %w", err)
+	}
+	if err := r.loadTags([]*models.JournalEntry{entry}); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ListPage retrieves a page of an account's journal entries, newest first
+func (r *JournalRepository) ListPage(accountID int64, filter JournalFilter, page PageRequest) (*Page[*models.JournalEntry], error) {
+	from := `FROM journal_entries e WHERE e.account_id = ?`
+	args := []interface{}{accountID}
+	if filter.CourseID != 0 {
+		from += ` AND e.course_id = ?`
+		args = append(args, filter.CourseID)
+	}
+	if tag := JournalTag(filter.Tag); tag != "" {
+		from += ` AND EXISTS (SELECT 1 FROM journal_entry_tags t WHERE t.entry_id = e.id AND t.tag = ?)`
+		args = append(args, tag)
+	}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(q)) + "%"
+		from += ` AND (LOWER(COALESCE(e.title, '')) LIKE ? ESCAPE '!' OR LOWER(e.body) LIKE ? ESCAPE '!'
+			OR EXISTS (SELECT 1 FROM journal_entry_tags t WHERE t.entry_id = e.id AND t.tag LIKE ? ESCAPE '!'))`
+		args = append(args, pattern, pattern, pattern)
+	}
+	if !filter.Date.IsZero() {
+		from += ` AND e.entry_date = ?`
+		args = append(args, filter.Date)
+	}
+	if !filter.Start.IsZero() {
+		from += ` AND e.created_at >= ?`
+		args = append(args, filter.Start)
+	}
+	if !filter.End.IsZero() {
+		from += ` AND e.created_at < ?`
+		args = append(args, filter.End)
+	}
+	if filter.PinnedOnly {
+		from += ` AND e.is_pinned = TRUE`
+	}
+
+	result, err := listPage(r.db, page, pageQuery{
+		Columns:    journalEntryColumns,
+		From:       from,
+		Args:       args,
+		Table:      "journal_entries",
+		Alias:      "e",
+		TimeColumn: "created_at",
+	}, scanJournalEntries, func(e *models.JournalEntry) (time.Time, int64) {
+		return e.CreatedAt, e.ID
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadTags(result.Items); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// likeEscaper escapes LIKE wildcards for patterns using ESCAPE '!'
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func scanJournalEntries(rows *sql.Rows) ([]*models.JournalEntry, error) {
+	entries := []*models.JournalEntry{}
+	for rows.Next() {
+		entry, err := scanJournalEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan journal entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Update saves an entry's fields and replaces its tags. Its author is fixed
+// once written.
+func (r *JournalRepository) Update(entry *models.JournalEntry) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE journal_entries
+		SET course_id = ?, entry_date = ?, title = ?, body = ?, is_pinned = ?, updated_at = ?
+		WHERE id = ? AND account_id = ?
+	`,
+		entry.CourseID,
+		entry.EntryDate,
+		entry.Title,
+		entry.Body,
+		entry.IsPinned,
+		now,
+		entry.ID,
+		entry.AccountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update journal entry: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	if err := setJournalTags(tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	entry.UpdatedAt = now
+	return nil
+}
+
+// Delete removes one of an account's journal entries along with its tags
+func (r *JournalRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec(`DELETE FROM journal_entries WHERE id = ? AND account_id = ?`, id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete journal entry: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Tags lists the tags used in an account's journal, most used first
+func (r *JournalRepository) Tags(accountID int64) ([]JournalTagCount, error) {
+	rows, err := r.db.Query(`
+		SELECT t.tag, COUNT(*)
+		FROM journal_entry_tags t
+		JOIN journal_entries e ON e.id = t.entry_id
+		WHERE e.account_id = ?
+		GROUP BY t.tag
+		ORDER BY COUNT(*) DESC, t.tag
+	`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query journal tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []JournalTagCount{}
+	for rows.Next() {
+		var t JournalTagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan journal tag: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// setJournalTags replaces an entry's tags with its normalized Tags
+func setJournalTags(tx *sql.Tx, entry *models.JournalEntry) error {
+	if _, err := tx.Exec(`DELETE FROM journal_entry_tags WHERE entry_id = ?`, entry.ID); err != nil {
+		return fmt.Errorf("failed to clear journal tags: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	tags := []string{}
+	for _, tag := range entry.Tags {
+		tag = JournalTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		if _, err := tx.Exec(`INSERT INTO journal_entry_tags (entry_id, tag) VALUES (?, ?)`, entry.ID, tag); err != nil {
+			return fmt.Errorf("failed to tag journal entry: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	entry.Tags = tags
+	return nil
+}
+
+// loadTags fills in the tags of each entry
+func (r *JournalRepository) loadTags(entries []*models.JournalEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*models.JournalEntry, len(entries))
+	args := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		byID[e.ID] = e
+		args = append(args, e.ID)
+	}
+	rows, err := r.db.Query(`
+		SELECT entry_id, tag FROM journal_entry_tags
+		WHERE entry_id IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")+`)
+		ORDER BY tag`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query journal tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entryID int64
+		var tag string
+		if err := rows.Scan(&entryID, &tag); err != nil {
+			return fmt.Errorf("failed to scan journal tag: %w", err)
+		}
+		byID[entryID].Tags = append(byID[entryID].Tags, tag)
+	}
+	return rows.Err()
+}
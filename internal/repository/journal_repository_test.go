@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestJournalRepository(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewJournalRepository(db)
+	visit := &models.JournalEntry{
+		AccountID: 1,
+		AuthorID:  sql.NullInt64{Int64: 1, Valid: true},
+		EntryDate: sql.NullTime{Time: time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC), Valid: true},
+		Title:     sql.NullString{String: "Clinic visit", Valid: true},
+		Body:      "Dr. Lee wants **bloods** in 2 weeks. 100% on schedule.",
+		Tags:      []string{" Clinic ", "labs", "clinic"},
+	}
+	if err := repo.Create(visit); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if len(visit.Tags) != 2 || visit.Tags[0] != "clinic" || visit.Tags[1] != "labs" {
+		t.Errorf("Expected normalized, deduplicated tags, got %v", visit.Tags)
+	}
+	note := &models.JournalEntry{AccountID: 1, Body: "Felt tired today", Tags: []string{"mood"}}
+	if err := repo.Create(note); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+
+	list := func(filter JournalFilter) []*models.JournalEntry {
+		t.Helper()
+		page, err := repo.ListPage(1, filter, PageRequest{})
+		if err != nil {
+			t.Fatalf("ListPage failed: %v", err)
+		}
+		return page.Items
+	}
+	if all := list(JournalFilter{}); len(all) != 2 || all[0].ID != note.ID {
+		t.Errorf("Expected both entries, newest first, got %d", len(all))
+	}
+	if got := list(JournalFilter{Tag: "CLINIC"}); len(got) != 1 || got[0].ID != visit.ID {
+		t.Errorf("Expected the clinic entry by tag, got %d", len(got))
+	}
+	if got := list(JournalFilter{Query: "BLOODS"}); len(got) != 1 || got[0].ID != visit.ID {
+		t.Errorf("Expected the search to match the body ignoring case, got %d", len(got))
+	}
+	if got := list(JournalFilter{Query: "mood"}); len(got) != 1 || got[0].ID != note.ID {
+		t.Errorf("Expected the search to match tags, got %d", len(got))
+	}
+	// Wildcards are matched literally
+	if got := list(JournalFilter{Query: "0%"}); len(got) != 1 {
+		t.Errorf("Expected one match for a literal %%, got %d", len(got))
+	}
+	if got := list(JournalFilter{Query: "_"}); len(got) != 0 {
+		t.Errorf("Expected no match for a literal _, got %d", len(got))
+	}
+	if got := list(JournalFilter{Date: visit.EntryDate.Time}); len(got) != 1 || got[0].ID != visit.ID {
+		t.Errorf("Expected the entry about the 20th, got %d", len(got))
+	}
+
+	note.IsPinned = true
+	note.Tags = []string{"energy"}
+	if err := repo.Update(note); err != nil {
+		t.Fatalf("Failed to update entry: %v", err)
+	}
+	if got := list(JournalFilter{PinnedOnly: true}); len(got) != 1 || got[0].Tags[0] != "energy" {
+		t.Errorf("Expected the pinned entry with its new tags, got %+v", got)
+	}
+
+	tags, err := repo.Tags(1)
+	if err != nil {
+		t.Fatalf("Tags failed: %v", err)
+	}
+	if len(tags) != 3 {
+		t.Errorf("Expected 3 tags in use, got %+v", tags)
+	}
+
+	if err := repo.Delete(visit.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another account's delete to miss, got %v", err)
+	}
+	if err := repo.Delete(visit.ID, 1); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+	if _, err := repo.GetByID(visit.ID, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// LabResultRepository stores an account's lab results
+type LabResultRepository struct {
+	db *database.DB
+}
+
+func NewLabResultRepository(db *database.DB) *LabResultRepository {
+	return &LabResultRepository{db: db}
+}
+
+const labResultColumns = `id, account_id, course_id, analyte, value, unit, reference_low, reference_high, drawn_at, notes, created_by, created_at, updated_at`
+
+// LabResultFilter narrows List. Zero values don't filter.
+type LabResultFilter struct {
+	Analyte string // Matched ignoring case
+	// CourseID keeps results linked to the course, along with those not
+	// linked to any
+	CourseID int64
+	Start    time.Time // Drawn at or after, inclusive
+	End      time.Time // Drawn before, exclusive
+}
+
+// LabAnalyte is an analyte an account has results for
+type LabAnalyte struct {
+	Analyte  string    `json:"analyte"`
+	Count    int       `json:"count"`
+	LatestAt time.Time `json:"latest_at"`
+}
+
+func scanLabResult(row rowScanner) (*models.LabResult, error) {
+	var l models.LabResult
+	err := row.Scan(
+		&l.ID,
+		&l.AccountID,
+		&l.CourseID,
+		&l.Analyte,
+		&l.Value,
+		&l.Unit,
+		&l.ReferenceLow,
+		&l.ReferenceHigh,
+		&l.DrawnAt,
+		&l.Notes,
+		&l.CreatedBy,
+		&l.CreatedAt,
+		&l.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Create records a lab result
+func (r *LabResultRepository) Create(result *models.LabResult) error {
+	now := time.Now()
+	err := r.db.QueryRow(`
+		INSERT INTO lab_results (account_id, course_id, analyte, value, unit, reference_low, reference_high, drawn_at, notes, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`,
+		result.AccountID,
+		result.CourseID,
+		result.Analyte,
+		result.Value,
+		result.Unit,
+		result.ReferenceLow,
+		result.ReferenceHigh,
+		result.DrawnAt.UTC(),
+		result.Notes,
+		result.CreatedBy,
+		now,
+		now,
+	).Scan(&result.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create lab result: %w", err)
+	}
+	result.CreatedAt = now
+	result.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves one of an account's lab results
+func (r *LabResultRepository) GetByID(id, accountID int64) (*models.LabResult, error) {
+	query := `SELECT ` + labResultColumns + ` FROM lab_results WHERE id = ? AND account_id = ?`
+	result, err := scanLabResult(r.db.QueryRow(query, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lab result: %w", err)
+	}
+	return result, nil
+}
+
+// List retrieves up to limit of an account's lab results, newest draw first
+func (r *LabResultRepository) List(accountID int64, filter LabResultFilter, limit int) ([]*models.LabResult, error) {
+	query := `SELECT ` + labResultColumns + ` FROM lab_results WHERE account_id = ?`
+	args := []interface{}{accountID}
+	if analyte := strings.TrimSpace(filter.Analyte); analyte != "" {
+		query += " AND LOWER(analyte) = ?"
+		args = append(args, strings.ToLower(analyte))
+	}
+	if filter.CourseID != 0 {
+		query += " AND (course_id = ? OR course_id IS NULL)"
+		args = append(args, filter.CourseID)
+	}
+	if !filter.Start.IsZero() {
+		query += " AND drawn_at >= ?"
+		args = append(args, filter.Start.UTC())
+	}
+	if !filter.End.IsZero() {
+		query += " AND drawn_at < ?"
+		args = append(args, filter.End.UTC())
+	}
+	query += " ORDER BY drawn_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lab results: %w", err)
+	}
+	defer rows.Close()
+
+	results := []*models.LabResult{}
+	for rows.Next() {
+		result, err := scanLabResult(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lab result: %w", err)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// Analytes lists the analytes an account has results for, most recently
+// drawn first. Spellings differing only in case count as one, under the
+// most recent spelling.
+func (r *LabResultRepository) Analytes(accountID int64) ([]LabAnalyte, error) {
+	rows, err := r.db.Query(`SELECT analyte, drawn_at FROM lab_results WHERE account_id = ? ORDER BY drawn_at DESC, id DESC`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lab analytes: %w", err)
+	}
+	defer rows.Close()
+
+	analytes := []LabAnalyte{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var analyte string
+		var drawnAt time.Time
+		if err := rows.Scan(&analyte, &drawnAt); err != nil {
+			return nil, fmt.Errorf("failed to scan lab analyte: %w", err)
+		}
+		key := strings.ToLower(analyte)
+		if i, ok := index[key]; ok {
+			analytes[i].Count++
+			continue
+		}
+		index[key] = len(analytes)
+		analytes = append(analytes, LabAnalyte{Analyte: analyte, Count: 1, LatestAt: drawnAt})
+	}
+	return analytes, rows.Err()
+}
+
+// Update saves a lab result's fields
+func (r *LabResultRepository) Update(result *models.LabResult) error {
+	now := time.Now()
+	res, err := r.db.Exec(`
+		UPDATE lab_results
+		SET course_id = ?, analyte = ?, value = ?, unit = ?, reference_low = ?, reference_high = ?, drawn_at = ?, notes = ?, updated_at = ?
+		WHERE id = ? AND account_id = ?
+	`,
+		result.CourseID,
+		result.Analyte,
+		result.Value,
+		result.Unit,
+		result.ReferenceLow,
+		result.ReferenceHigh,
+		result.DrawnAt.UTC(),
+		result.Notes,
+		now,
+		result.ID,
+		result.AccountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update lab result: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	result.UpdatedAt = now
+	return nil
+}
+
+// Delete removes one of an account's lab results
+func (r *LabResultRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec(`DELETE FROM lab_results WHERE id = ? AND account_id = ?`, id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete lab result: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PriorDose is the injection given most recently before a blood draw, which
+// is what a level is read against
+type PriorDose struct {
+	InjectedAt time.Time
+	DoseML     float64         // DefaultDoseML when the injection has no volume
+	DoseMG     sql.NullFloat64 // Only when the course has a concentration
+}
+
+// PriorDose finds the last injection, not voided, given at or before
+// drawnAt. It returns ErrNotFound when there is none.
+func (r *LabResultRepository) PriorDose(accountID int64, drawnAt time.Time) (*PriorDose, error) {
+	var dose PriorDose
+	err := r.db.QueryRow(`
+		SELECT i.timestamp, COALESCE(i.dose_ml, ?),
+			COALESCE(i.dose_ml, ?) * COALESCE(c.concentration_mg_per_ml, m.concentration_mg_per_ml)
+		FROM injections i
+		JOIN courses c ON c.id = i.course_id
+		LEFT JOIN compounds m ON m.id = c.compound_id
+		WHERE c.account_id = ? AND i.voided_at IS NULL AND i.timestamp <= ?
+		ORDER BY i.timestamp DESC
+		LIMIT 1
+	`, DefaultDoseML, DefaultDoseML, accountID, drawnAt.UTC()).Scan(&dose.InjectedAt, &dose.DoseML, &dose.DoseMG)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dose before lab draw: %w", err)
+	}
+	return &dose, nil
+}
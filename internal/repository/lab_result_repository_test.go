@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestLabResultRepository(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', CURRENT_TIMESTAMP, 1)`); err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+	injected := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	if _, err := db.Exec(`INSERT INTO injections (course_id, timestamp, side, dose_ml) VALUES (1, ?, 'left', 1.5)`, injected); err != nil {
+		t.Fatalf("Failed to create injection: %v", err)
+	}
+
+	repo := NewLabResultRepository(db)
+	first := &models.LabResult{
+		AccountID:    1,
+		Analyte:      "Progesterone",
+		Value:        8.2,
+		Unit:         "ng/mL",
+		ReferenceLow: sql.NullFloat64{Float64: 10, Valid: true},
+		DrawnAt:      time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC),
+	}
+	second := &models.LabResult{
+		AccountID: 1,
+		CourseID:  sql.NullInt64{Int64: 1, Valid: true},
+		Analyte:   "progesterone",
+		Value:     24,
+		Unit:      "ng/mL",
+		DrawnAt:   time.Date(2026, 3, 16, 8, 0, 0, 0, time.UTC),
+	}
+	estradiol := &models.LabResult{
+		AccountID: 1,
+		Analyte:   "Estradiol",
+		Value:     310,
+		Unit:      "pg/mL",
+		DrawnAt:   time.Date(2026, 2, 20, 8, 0, 0, 0, time.UTC),
+	}
+	for _, l := range []*models.LabResult{first, second, estradiol} {
+		if err := repo.Create(l); err != nil {
+			t.Fatalf("Failed to create lab result: %v", err)
+		}
+	}
+	if first.Flag() != "low" || second.Flag() != "" {
+		t.Errorf("Expected the first result flagged low and the second unflagged, got %q and %q", first.Flag(), second.Flag())
+	}
+
+	list := func(filter LabResultFilter) []*models.LabResult {
+		t.Helper()
+		results, err := repo.List(1, filter, 100)
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		return results
+	}
+	if got := list(LabResultFilter{Analyte: "PROGESTERONE"}); len(got) != 2 || got[0].ID != second.ID {
+		t.Errorf("Expected both progesterone results, newest first, got %d", len(got))
+	}
+	if got := list(LabResultFilter{Start: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}); len(got) != 2 {
+		t.Errorf("Expected the two March draws, got %d", len(got))
+	}
+	if got := list(LabResultFilter{CourseID: 1}); len(got) != 3 {
+		t.Errorf("Expected the course's result and the unlinked ones, got %d", len(got))
+	}
+	if got := list(LabResultFilter{CourseID: 2}); len(got) != 2 {
+		t.Errorf("Expected only unlinked results for another course, got %d", len(got))
+	}
+
+	analytes, err := repo.Analytes(1)
+	if err != nil {
+		t.Fatalf("Analytes failed: %v", err)
+	}
+	if len(analytes) != 2 || analytes[0].Analyte != "progesterone" || analytes[0].Count != 2 {
+		t.Errorf("Expected progesterone (latest spelling) with 2 results first, got %+v", analytes)
+	}
+
+	dose, err := repo.PriorDose(1, first.DrawnAt)
+	if err != nil {
+		t.Fatalf("PriorDose failed: %v", err)
+	}
+	if !dose.InjectedAt.Equal(injected) || dose.DoseML != 1.5 {
+		t.Errorf("Expected the 1.5 mL injection before the draw, got %+v", dose)
+	}
+	if _, err := repo.PriorDose(1, estradiol.DrawnAt); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected no dose before the first injection, got %v", err)
+	}
+
+	first.Value = 11
+	if err := repo.Update(first); err != nil {
+		t.Fatalf("Failed to update lab result: %v", err)
+	}
+	got, err := repo.GetByID(first.ID, 1)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if got.Value != 11 || got.Flag() != "" {
+		t.Errorf("Expected the corrected value back in range, got %v %q", got.Value, got.Flag())
+	}
+
+	if err := repo.Delete(first.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another account's delete to miss, got %v", err)
+	}
+	if err := repo.Delete(first.ID, 1); err != nil {
+		t.Fatalf("Failed to delete lab result: %v", err)
+	}
+	if _, err := repo.GetByID(first.ID, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// MedicationCatalogRepository reads the instance's catalog of normalized
+// medication names
+type MedicationCatalogRepository struct {
+	db *database.DB
+}
+
+func NewMedicationCatalogRepository(db *database.DB) *MedicationCatalogRepository {
+	return &MedicationCatalogRepository{db: db}
+}
+
+const medicationCatalogColumns = `id, name, dose_form, route, brand_names, created_at, updated_at`
+
+func scanMedicationCatalogEntry(row rowScanner) (*models.MedicationCatalogEntry, error) {
+	var e models.MedicationCatalogEntry
+	if err := row.Scan(&e.ID, &e.Name, &e.DoseForm, &e.Route, &e.BrandNames, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// GetByID retrieves a catalog entry
+func (r *MedicationCatalogRepository) GetByID(id int64) (*models.MedicationCatalogEntry, error) {
+	query := `SELECT ` + medicationCatalogColumns + ` FROM medication_catalog WHERE id = ?`
+	entry, err := scanMedicationCatalogEntry(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get catalog medication: %w", err)
+	}
+	return entry, nil
+}
+
+// Search returns up to limit entries matching every word of q in their name,
+// dose form or brand names. Names starting with the first word come first,
+// so "est" lists Estradiol before Ethinyl Estradiol.
+func (r *MedicationCatalogRepository) Search(q string, limit int) ([]*models.MedicationCatalogEntry, error) {
+	words := strings.Fields(strings.ToLower(q))
+	if len(words) == 0 {
+		return []*models.MedicationCatalogEntry{}, nil
+	}
+
+	query := `SELECT ` + medicationCatalogColumns + ` FROM medication_catalog WHERE 1 = 1`
+	var args []interface{}
+	for _, word := range words {
+		pattern := "%" + likeEscaper.Replace(word) + "%"
+		query += ` AND (LOWER(name || ' ' || dose_form) LIKE ? ESCAPE '!' OR LOWER(COALESCE(brand_names, '')) LIKE ? ESCAPE '!')`
+		args = append(args, pattern, pattern)
+	}
+	query += ` ORDER BY CASE WHEN LOWER(name) LIKE ? ESCAPE '!' THEN 0 ELSE 1 END, name, dose_form LIMIT ?`
+	args = append(args, likeEscaper.Replace(words[0])+"%", limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search medication catalog: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.MedicationCatalogEntry{}
+	for rows.Next() {
+		entry, err := scanMedicationCatalogEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan catalog medication: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+
+	"injection-tracker/internal/models"
+)
+
+func TestMedicationCatalogRepository_Search(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewMedicationCatalogRepository(db)
+
+	names := func(q string) []string {
+		t.Helper()
+		entries, err := repo.Search(q, 10)
+		if err != nil {
+			t.Fatalf("Search(%q) failed: %v", q, err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.DisplayName())
+		}
+		return got
+	}
+
+	// Every word has to match, in any order
+	got := names("vag prog")
+	if len(got) != 3 || got[0] != "Progesterone Vaginal Gel" {
+		t.Errorf("Expected the three vaginal progesterone forms, got %v", got)
+	}
+
+	// Names starting with the search come first
+	got = names("estradiol")
+	if len(got) == 0 || got[0] != "Estradiol Oral Tablet" || got[len(got)-1] != "Ethinyl Estradiol and Norethindrone Oral Tablet" {
+		t.Errorf("Expected Estradiol first and combinations last, got %v", got)
+	}
+
+	// Brand names find the generic
+	if got := names("Endometrin"); len(got) != 1 || got[0] != "Progesterone Vaginal Insert" {
+		t.Errorf("Expected Endometrin to find Progesterone Vaginal Insert, got %v", got)
+	}
+
+	// LIKE wildcards are matched literally
+	if got := names("%"); len(got) != 0 {
+		t.Errorf("Expected no matches for %%, got %v", got)
+	}
+	if got := names("  "); len(got) != 0 {
+		t.Errorf("Expected no matches for a blank search, got %v", got)
+	}
+}
+
+func TestMedicationRepository_CatalogLink(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	entries, err := NewMedicationCatalogRepository(db).Search("letrozole", 1)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected letrozole in the catalog, got %d (%v)", len(entries), err)
+	}
+	entry, err := NewMedicationCatalogRepository(db).GetByID(entries[0].ID)
+	if err != nil || entry.Route.String != "Oral" {
+		t.Fatalf("Failed to get catalog entry: %+v (%v)", entry, err)
+	}
+	if _, err := NewMedicationCatalogRepository(db).GetByID(-1); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a missing entry, got %v", err)
+	}
+
+	repo := NewMedicationRepository(db)
+	medication := &models.Medication{
+		Name:      entry.DisplayName(),
+		IsActive:  true,
+		CatalogID: sql.NullInt64{Int64: entry.ID, Valid: true},
+		AccountID: 1,
+	}
+	if err := repo.Create(medication); err != nil {
+		t.Fatalf("Failed to create medication: %v", err)
+	}
+	got, err := repo.GetByID(medication.ID, 1)
+	if err != nil || got.CatalogID != medication.CatalogID {
+		t.Fatalf("Expected the catalog link saved, got %+v (%v)", got, err)
+	}
+
+	got.CatalogID = sql.NullInt64{}
+	if err := repo.Update(got, 1, got.UpdatedAt); err != nil {
+		t.Fatalf("Failed to update medication: %v", err)
+	}
+	list, err := repo.List(1)
+	if err != nil || len(list) != 1 || list[0].CatalogID.Valid {
+		t.Errorf("Expected the catalog link cleared, got %+v (%v)", list, err)
+	}
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+// RecordLog saves a medication log and, for a medication linked to an
+// inventory item, keeps the item's stock in step, all in one transaction.
+// A dose logged as taken takes its units out of stock; a later log for the
+// same dose doesn't take them again, and one logging it as missed puts back
+// what the earlier log took. The usage is nil when no stock changed.
+func (r *MedicationRepository) RecordLog(log *models.MedicationLog, medication *models.Medication, accountID, userID int64) (*InventoryUsage, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// The dose's latest log before this one decides what stock it already holds
+	var previous sql.NullInt64
+	var previousTaken bool
+	if log.ScheduledFor.Valid {
+		err := tx.QueryRow(`
+			SELECT id, taken FROM medication_logs
+			WHERE medication_id = ? AND scheduled_for = ?
+			ORDER BY timestamp DESC, id DESC LIMIT 1
+		`, log.MedicationID, log.ScheduledFor.Time).Scan(&previous, &previousTaken)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to check earlier logs: %w", err)
+		}
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO medication_logs (medication_id, logged_by, timestamp, taken, late, notes, scheduled_for, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		RETURNING id
+	`, log.MedicationID, log.LoggedBy, log.Timestamp, log.Taken, log.Late, log.Notes, log.ScheduledFor).Scan(&log.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create medication log: %w", err)
+	}
+
+	var usage *InventoryUsage
+	itemType := medication.InventoryItemType.String
+	if medication.InventoryItemType.Valid && itemType != "" {
+		var before float64
+		changed := true
+		switch {
+		case log.Taken && !(previous.Valid && previousTaken):
+			before, err = ConsumeMedicationInventory(tx, log.ID, accountID, userID, itemType, MedicationDoseUnits(medication),
+				fmt.Sprintf("Auto-decremented for %s dose (log #%d)", medication.Name, log.ID))
+		case !log.Taken && previous.Valid && previousTaken:
+			before, err = ReturnMedicationDoseInventory(tx, log.MedicationID, log.ScheduledFor.Time, accountID, userID,
+				fmt.Sprintf("Dose of %s logged as missed (log #%d)", medication.Name, log.ID))
+		default:
+			changed = false
+		}
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			usage = &InventoryUsage{MedicationItemType: itemType, QuantitiesBefore: map[string]float64{itemType: before}}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return usage, nil
+}
+
+// MedicationDoseUnits is how much of its inventory item one dose of a
+// medication uses, one unit unless set
+func MedicationDoseUnits(medication *models.Medication) float64 {
+	if medication.UnitsPerDose.Valid && medication.UnitsPerDose.Float64 > 0 {
+		return medication.UnitsPerDose.Float64
+	}
+	return 1
+}
+
+// ConsumeMedicationInventory takes a logged dose out of stock, drawing from
+// the item's oldest lots. Like injections, stock stops at zero rather than
+// failing the log, and an item with no stock record yet gets one from its
+// type. It returns the quantity beforehand.
+func ConsumeMedicationInventory(tx *sql.Tx, logID, accountID, userID int64, itemType string, amount float64, note string) (float64, error) {
+	var currentQty float64
+	err := tx.QueryRow(`
+		SELECT quantity FROM inventory_items WHERE item_type = ? AND account_id = ?
+	`, itemType, accountID).Scan(&currentQty)
+	if err == sql.ErrNoRows {
+		now := time.Now()
+		_, err = tx.Exec(`
+			INSERT INTO inventory_items (item_type, quantity, unit, low_stock_threshold, account_id, created_at, updated_at)
+			SELECT item_type, 0, unit, reorder_threshold, account_id, ?, ?
+			FROM inventory_item_types WHERE item_type = ? AND account_id = ?
+		`, now, now, itemType, accountID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to check inventory for %s: %w", itemType, err)
+	}
+
+	newQty := currentQty - amount
+	if newQty < 0 {
+		newQty = 0
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE inventory_items SET quantity = ?, updated_at = ? WHERE item_type = ? AND account_id = ?
+	`, newQty, now, itemType, accountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update inventory for %s: %w", itemType, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	} else if n == 0 {
+		// The item type was deleted after the medication was linked to it
+		return 0, nil
+	}
+
+	if _, err := ConsumeLotsFIFO(tx, accountID, itemType, amount, sql.NullInt64{}); err != nil {
+		return 0, fmt.Errorf("failed to consume inventory lots for %s: %w", itemType, err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO inventory_history (
+			account_id, item_type, change_amount, quantity_before, quantity_after,
+			reason, reference_id, reference_type, performed_by, timestamp, notes
+		) VALUES (?, ?, ?, ?, ?, 'other', ?, 'medication_log', ?, ?, ?)
+	`, accountID, itemType, newQty-currentQty, currentQty, newQty, logID, userID, now, note)
+	if err != nil {
+		return 0, fmt.Errorf("failed to log inventory history for %s: %w", itemType, err)
+	}
+	return currentQty, nil
+}
+
+// ReturnMedicationDoseInventory puts back the stock logs for one of a
+// medication's doses took by reversing their entries that haven't been. It
+// returns the quantity before the first reversal, or 0 if there was nothing
+// to return.
+func ReturnMedicationDoseInventory(tx *sql.Tx, medicationID int64, scheduledFor time.Time, accountID, userID int64, note string) (float64, error) {
+	rows, err := tx.Query(`
+		SELECT h.id
+		FROM inventory_history h
+		WHERE h.reference_type = 'medication_log' AND h.reversed_by IS NULL
+		AND h.reference_id IN (SELECT id FROM medication_logs WHERE medication_id = ? AND scheduled_for = ?)
+		AND NOT EXISTS (SELECT 1 FROM inventory_history r WHERE r.reversed_by = h.id)
+		ORDER BY h.id
+	`, medicationID, scheduledFor)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query inventory history: %w", err)
+	}
+
+	var entries []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan inventory history: %w", err)
+		}
+		entries = append(entries, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query inventory history: %w", err)
+	}
+
+	var before float64
+	for i, id := range entries {
+		reversal, err := reverseEntry(tx, id, accountID, userID, sql.NullString{String: note, Valid: true})
+		if err != nil {
+			return 0, fmt.Errorf("failed to reverse inventory entry #%d: %w", id, err)
+		}
+		if i == 0 {
+			before = reversal.QuantityBefore
+		}
+	}
+	return before, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestMedicationRepository_RecordLogInventory(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO inventory_item_types (account_id, item_type, name, unit, reorder_threshold)
+		VALUES (1, 'letrozole', 'Letrozole 2.5 mg', 'count', 5)
+	`); err != nil {
+		t.Fatalf("Failed to seed item type: %v", err)
+	}
+
+	repo := NewMedicationRepository(db)
+	medication := &models.Medication{
+		Name:              "Letrozole Oral Tablet",
+		IsActive:          true,
+		InventoryItemType: sql.NullString{String: "letrozole", Valid: true},
+		UnitsPerDose:      sql.NullFloat64{Float64: 2, Valid: true},
+		AccountID:         1,
+	}
+	if err := repo.Create(medication); err != nil {
+		t.Fatalf("Failed to create medication: %v", err)
+	}
+	got, err := repo.GetByID(medication.ID, 1)
+	if err != nil || got.InventoryItemType != medication.InventoryItemType || got.UnitsPerDose != medication.UnitsPerDose {
+		t.Fatalf("Expected the inventory link saved, got %+v (%v)", got, err)
+	}
+
+	dose := sql.NullTime{Time: time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC), Valid: true}
+	record := func(taken bool, scheduledFor sql.NullTime) *InventoryUsage {
+		t.Helper()
+		log := &models.MedicationLog{MedicationID: medication.ID, Timestamp: time.Now(), Taken: taken, ScheduledFor: scheduledFor}
+		usage, err := repo.RecordLog(log, medication, 1, 1)
+		if err != nil {
+			t.Fatalf("Failed to record log: %v", err)
+		}
+		return usage
+	}
+
+	// The first dose creates the stock record from the item type
+	usage := record(true, dose)
+	if usage == nil || usage.QuantitiesBefore["letrozole"] != 0 {
+		t.Fatalf("Expected the dose to use stock, got %+v", usage)
+	}
+	item, err := NewInventoryRepository(db).GetByType("letrozole", 1)
+	if err != nil || item.Unit != "count" || item.LowStockThreshold.Float64 != 5 {
+		t.Fatalf("Expected a stock record from the item type, got %+v (%v)", item, err)
+	}
+
+	if _, err := db.Exec(`UPDATE inventory_items SET quantity = 20 WHERE item_type = 'letrozole'`); err != nil {
+		t.Fatalf("Failed to restock: %v", err)
+	}
+	nextDose := sql.NullTime{Time: dose.Time.AddDate(0, 0, 1), Valid: true}
+	if usage := record(true, nextDose); usage == nil || usage.QuantitiesBefore["letrozole"] != 20 {
+		t.Fatalf("Expected the dose to use stock, got %+v", usage)
+	}
+	if q := stockOf(t, db, "letrozole"); q != 18 {
+		t.Errorf("Expected 18 left after a two tablet dose, got %v", q)
+	}
+
+	// Logging the same dose taken again doesn't take it twice
+	if usage := record(true, nextDose); usage != nil {
+		t.Errorf("Expected no stock change for a repeat log, got %+v", usage)
+	}
+	if q := stockOf(t, db, "letrozole"); q != 18 {
+		t.Errorf("Expected 18 left after the repeat, got %v", q)
+	}
+
+	// Logging it missed afterwards puts the tablets back
+	if usage := record(false, nextDose); usage == nil || usage.QuantitiesBefore["letrozole"] != 18 {
+		t.Errorf("Expected the stock returned, got %+v", usage)
+	}
+	if q := stockOf(t, db, "letrozole"); q != 20 {
+		t.Errorf("Expected 20 after the dose was logged missed, got %v", q)
+	}
+
+	// A missed dose with nothing taken before changes nothing
+	if usage := record(false, sql.NullTime{}); usage != nil {
+		t.Errorf("Expected no stock change for a missed dose, got %+v", usage)
+	}
+
+	var entries int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM inventory_history WHERE reference_type = 'medication_log'`).Scan(&entries); err != nil {
+		t.Fatalf("Failed to count history: %v", err)
+	}
+	if entries != 3 {
+		t.Errorf("Expected two decrements and a return in the history, got %d entries", entries)
+	}
+
+	// Unlinked medications leave inventory alone
+	medication.InventoryItemType = sql.NullString{}
+	if usage := record(true, sql.NullTime{}); usage != nil {
+		t.Errorf("Expected no stock change for an unlinked medication, got %+v", usage)
+	}
+}
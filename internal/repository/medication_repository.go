@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"injection-tracker/internal/database"
@@ -20,10 +21,12 @@ func NewMedicationRepository(db *database.DB) *MedicationRepository {
 // Create creates a new medication
 func (r *MedicationRepository) Create(medication *models.Medication) error {
 	query := `
-		INSERT INTO medications (name, dosage, frequency, start_date, end_date, is_active, notes, scheduled_time, time_window_minutes, reminder_enabled, account_id, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO medications (name, dosage, frequency, start_date, end_date, is_active, notes, scheduled_time, dose_times, time_window_minutes, reminder_enabled, catalog_id, inventory_item_type, units_per_dose, account_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id
 	`
-	result, err := r.db.Exec(query,
+	var id int64
+	err := r.db.QueryRow(query,
 		medication.Name,
 		medication.Dosage,
 		medication.Frequency,
@@ -32,19 +35,18 @@ func (r *MedicationRepository) Create(medication *models.Medication) error {
 		medication.IsActive,
 		medication.Notes,
 		medication.ScheduledTime,
+		joinDoseTimes(medication.DoseTimes),
 		medication.TimeWindowMinutes,
 		medication.ReminderEnabled,
+		medication.CatalogID,
+		medication.InventoryItemType,
+		medication.UnitsPerDose,
 		medication.AccountID,
-	)
+	).Scan(&id)
 	if err != nil {
 		return fmt.Errorf("failed to create medication: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	medication.ID = id
 	return nil
 }
@@ -52,11 +54,12 @@ func (r *MedicationRepository) Create(medication *models.Medication) error {
 // GetByID retrieves a medication by ID and account (ensures data isolation)
 func (r *MedicationRepository) GetByID(id int64, accountID int64) (*models.Medication, error) {
 	query := `
-		SELECT id, name, dosage, frequency, start_date, end_date, is_active, notes, scheduled_time, time_window_minutes, reminder_enabled, created_at, updated_at, account_id
+		SELECT id, name, dosage, frequency, start_date, end_date, is_active, notes, scheduled_time, dose_times, time_window_minutes, reminder_enabled, catalog_id, inventory_item_type, units_per_dose, created_at, updated_at, account_id
 		FROM medications
 		WHERE id = ? AND account_id = ?
 	`
 	var medication models.Medication
+	var doseTimes sql.NullString
 	err := r.db.QueryRow(query, id, accountID).Scan(
 		&medication.ID,
 		&medication.Name,
@@ -67,8 +70,12 @@ func (r *MedicationRepository) GetByID(id int64, accountID int64) (*models.Medic
 		&medication.IsActive,
 		&medication.Notes,
 		&medication.ScheduledTime,
+		&doseTimes,
 		&medication.TimeWindowMinutes,
 		&medication.ReminderEnabled,
+		&medication.CatalogID,
+		&medication.InventoryItemType,
+		&medication.UnitsPerDose,
 		&medication.CreatedAt,
 		&medication.UpdatedAt,
 		&medication.AccountID,
@@ -79,18 +86,65 @@ func (r *MedicationRepository) GetByID(id int64, accountID int64) (*models.Medic
 	if err != nil {
 		return nil, fmt.Errorf("failed to get medication: %w", err)
 	}
+	medication.DoseTimes = splitDoseTimes(doseTimes)
 
 	return &medication, nil
 }
 
-// Update updates a medication (only if it belongs to the account)
-func (r *MedicationRepository) Update(medication *models.Medication, accountID int64) error {
+// Update updates a medication (only if it belongs to the account). version
+// is the updated_at the changes were made against; if the medication has
+// changed since, Update returns ErrStale.
+func (r *MedicationRepository) Update(medication *models.Medication, accountID int64, version time.Time) error {
+	err := updateMedication(r.db, medication, accountID, r.db.Dialect.SameTime("updated_at"), version)
+	if err != ErrNotFound {
+		return err
+	}
+	var exists int
+	err = r.db.QueryRow(`SELECT 1 FROM medications WHERE id = ? AND account_id = ?`, medication.ID, accountID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check medication: %w", err)
+	}
+	return ErrStale
+}
+
+// UpdateAll saves several medications in one transaction. If one can't be
+// saved none are, and the error is a *BatchError naming it.
+func (r *MedicationRepository) UpdateAll(medications []*models.Medication, accountID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for i, medication := range medications {
+		if err := updateMedication(tx, medication, accountID, "TRUE"); err != nil {
+			return &BatchError{Index: i, Err: err}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit medications: %w", err)
+	}
+	return nil
+}
+
+// updateMedication saves a medication if it is the account's and cond, with
+// its args, holds
+func updateMedication(q interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, medication *models.Medication, accountID int64, cond string, condArgs ...interface{}) error {
 	query := `
 		UPDATE medications
-		SET name = ?, dosage = ?, frequency = ?, start_date = ?, end_date = ?, is_active = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ? AND account_id = ?
+		SET name = ?, dosage = ?, frequency = ?, start_date = ?, end_date = ?, is_active = ?, notes = ?,
+		    scheduled_time = ?, dose_times = ?, time_window_minutes = ?, reminder_enabled = ?, catalog_id = ?,
+		    inventory_item_type = ?, units_per_dose = ?, updated_at = ?
+		WHERE id = ? AND account_id = ? AND ` + cond + `
 	`
-	result, err := r.db.Exec(query,
+	// updated_at keeps sub-second precision so it can serve as an ETag
+	now := time.Now().UTC()
+	args := []interface{}{
 		medication.Name,
 		medication.Dosage,
 		medication.Frequency,
@@ -98,9 +152,18 @@ func (r *MedicationRepository) Update(medication *models.Medication, accountID i
 		medication.EndDate,
 		medication.IsActive,
 		medication.Notes,
+		medication.ScheduledTime,
+		joinDoseTimes(medication.DoseTimes),
+		medication.TimeWindowMinutes,
+		medication.ReminderEnabled,
+		medication.CatalogID,
+		medication.InventoryItemType,
+		medication.UnitsPerDose,
+		now,
 		medication.ID,
 		accountID,
-	)
+	}
+	result, err := q.Exec(query, append(args, condArgs...)...)
 	if err != nil {
 		return fmt.Errorf("failed to update medication: %w", err)
 	}
@@ -111,12 +174,13 @@ func (r *MedicationRepository) Update(medication *models.Medication, accountID i
 	if rows == 0 {
 		return ErrNotFound
 	}
+	medication.UpdatedAt = now
 	return nil
 }
 
 // Delete deletes a medication (soft delete by setting is_active to false, only if it belongs to the account)
 func (r *MedicationRepository) Delete(id int64, accountID int64) error {
-	query := `UPDATE medications SET is_active = 0, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND account_id = ?`
+	query := `UPDATE medications SET is_active = FALSE, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND account_id = ?`
 	result, err := r.db.Exec(query, id, accountID)
 	if err != nil {
 		return fmt.Errorf("failed to delete medication: %w", err)
@@ -151,7 +215,7 @@ func (r *MedicationRepository) HardDelete(id int64, accountID int64) error {
 // List retrieves all medications for an account
 func (r *MedicationRepository) List(accountID int64) ([]*models.Medication, error) {
 	query := `
-		SELECT id, name, dosage, frequency, start_date, end_date, is_active, notes, scheduled_time, time_window_minutes, reminder_enabled, created_at, updated_at, account_id
+		SELECT id, name, dosage, frequency, start_date, end_date, is_active, notes, scheduled_time, dose_times, time_window_minutes, reminder_enabled, catalog_id, inventory_item_type, units_per_dose, created_at, updated_at, account_id
 		FROM medications
 		WHERE account_id = ?
 		ORDER BY name
@@ -168,9 +232,9 @@ func (r *MedicationRepository) List(accountID int64) ([]*models.Medication, erro
 // ListActive retrieves all active medications for an account
 func (r *MedicationRepository) ListActive(accountID int64) ([]*models.Medication, error) {
 	query := `
-		SELECT id, name, dosage, frequency, start_date, end_date, is_active, notes, scheduled_time, time_window_minutes, reminder_enabled, created_at, updated_at, account_id
+		SELECT id, name, dosage, frequency, start_date, end_date, is_active, notes, scheduled_time, dose_times, time_window_minutes, reminder_enabled, catalog_id, inventory_item_type, units_per_dose, created_at, updated_at, account_id
 		FROM medications
-		WHERE is_active = 1 AND account_id = ?
+		WHERE is_active = TRUE AND account_id = ?
 		ORDER BY name
 	`
 	rows, err := r.db.Query(query, accountID)
@@ -185,25 +249,24 @@ func (r *MedicationRepository) ListActive(accountID int64) ([]*models.Medication
 // CreateLog creates a new medication log entry
 func (r *MedicationRepository) CreateLog(log *models.MedicationLog) error {
 	query := `
-		INSERT INTO medication_logs (medication_id, logged_by, timestamp, taken, notes, created_at)
-		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO medication_logs (medication_id, logged_by, timestamp, taken, late, notes, scheduled_for, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		RETURNING id
 	`
-	result, err := r.db.Exec(query,
+	var id int64
+	err := r.db.QueryRow(query,
 		log.MedicationID,
 		log.LoggedBy,
 		log.Timestamp,
 		log.Taken,
+		log.Late,
 		log.Notes,
-	)
+		log.ScheduledFor,
+	).Scan(&id)
 	if err != nil {
 		return fmt.Errorf("failed to create medication log: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	log.ID = id
 	return nil
 }
@@ -211,7 +274,7 @@ func (r *MedicationRepository) CreateLog(log *models.MedicationLog) error {
 // GetLogByID retrieves a medication log by ID
 func (r *MedicationRepository) GetLogByID(id int64) (*models.MedicationLog, error) {
 	query := `
-		SELECT id, medication_id, logged_by, timestamp, taken, notes, created_at
+		SELECT id, medication_id, logged_by, timestamp, taken, late, notes, scheduled_for, created_at
 		FROM medication_logs
 		WHERE id = ?
 	`
@@ -222,7 +285,9 @@ func (r *MedicationRepository) GetLogByID(id int64) (*models.MedicationLog, erro
 		&log.LoggedBy,
 		&log.Timestamp,
 		&log.Taken,
+		&log.Late,
 		&log.Notes,
+		&log.ScheduledFor,
 		&log.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -239,7 +304,7 @@ func (r *MedicationRepository) GetLogByID(id int64) (*models.MedicationLog, erro
 func (r *MedicationRepository) UpdateLog(log *models.MedicationLog) error {
 	query := `
 		UPDATE medication_logs
-		SET medication_id = ?, logged_by = ?, timestamp = ?, taken = ?, notes = ?
+		SET medication_id = ?, logged_by = ?, timestamp = ?, taken = ?, late = ?, notes = ?, scheduled_for = ?
 		WHERE id = ?
 	`
 	_, err := r.db.Exec(query,
@@ -247,7 +312,9 @@ func (r *MedicationRepository) UpdateLog(log *models.MedicationLog) error {
 		log.LoggedBy,
 		log.Timestamp,
 		log.Taken,
+		log.Late,
 		log.Notes,
+		log.ScheduledFor,
 		log.ID,
 	)
 	if err != nil {
@@ -269,7 +336,7 @@ func (r *MedicationRepository) DeleteLog(id int64) error {
 // ListLogs retrieves medication logs for a specific medication with pagination
 func (r *MedicationRepository) ListLogs(medicationID int64, limit, offset int) ([]*models.MedicationLog, error) {
 	query := `
-		SELECT id, medication_id, logged_by, timestamp, taken, notes, created_at
+		SELECT id, medication_id, logged_by, timestamp, taken, late, notes, scheduled_for, created_at
 		FROM medication_logs
 		WHERE medication_id = ?
 		ORDER BY timestamp DESC
@@ -287,7 +354,7 @@ func (r *MedicationRepository) ListLogs(medicationID int64, limit, offset int) (
 // ListLogsByDateRange retrieves medication logs within a date range
 func (r *MedicationRepository) ListLogsByDateRange(medicationID int64, startDate, endDate time.Time, limit, offset int) ([]*models.MedicationLog, error) {
 	query := `
-		SELECT id, medication_id, logged_by, timestamp, taken, notes, created_at
+		SELECT id, medication_id, logged_by, timestamp, taken, late, notes, scheduled_for, created_at
 		FROM medication_logs
 		WHERE medication_id = ? AND timestamp BETWEEN ? AND ?
 		ORDER BY timestamp DESC
@@ -302,10 +369,55 @@ func (r *MedicationRepository) ListLogsByDateRange(medicationID int64, startDate
 	return r.scanMedicationLogs(rows)
 }
 
+// ListLogsBetween retrieves every log of a medication timed in [start, end),
+// oldest first
+func (r *MedicationRepository) ListLogsBetween(medicationID int64, start, end time.Time) ([]*models.MedicationLog, error) {
+	query := `
+		SELECT id, medication_id, logged_by, timestamp, taken, late, notes, scheduled_for, created_at
+		FROM medication_logs
+		WHERE medication_id = ? AND timestamp >= ? AND timestamp < ?
+		ORDER BY timestamp, id
+	`
+	rows, err := r.db.Query(query, medicationID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list medication logs: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanMedicationLogs(rows)
+}
+
+// ListLogsPage retrieves a page of a medication's logs, newest first,
+// optionally limited to [start, end)
+func (r *MedicationRepository) ListLogsPage(medicationID int64, start, end time.Time, page PageRequest) (*Page[*models.MedicationLog], error) {
+	from := `
+		FROM medication_logs l
+		WHERE l.medication_id = ?`
+	args := []interface{}{medicationID}
+	if !start.IsZero() {
+		from += ` AND l.timestamp >= ?`
+		args = append(args, start)
+	}
+	if !end.IsZero() {
+		from += ` AND l.timestamp < ?`
+		args = append(args, end)
+	}
+
+	return listPage(r.db, page, pageQuery{
+		Columns: `l.id, l.medication_id, l.logged_by, l.timestamp, l.taken, l.late, l.notes, l.scheduled_for, l.created_at`,
+		From:    from,
+		Args:    args,
+		Table:   "medication_logs",
+		Alias:   "l",
+	}, r.scanMedicationLogs, func(l *models.MedicationLog) (time.Time, int64) {
+		return l.Timestamp, l.ID
+	})
+}
+
 // GetRecentLogs retrieves the most recent medication logs for a medication
 func (r *MedicationRepository) GetRecentLogs(medicationID int64, count int) ([]*models.MedicationLog, error) {
 	query := `
-		SELECT id, medication_id, logged_by, timestamp, taken, notes, created_at
+		SELECT id, medication_id, logged_by, timestamp, taken, late, notes, scheduled_for, created_at
 		FROM medication_logs
 		WHERE medication_id = ?
 		ORDER BY timestamp DESC
@@ -335,7 +447,7 @@ func (r *MedicationRepository) CountLogs(medicationID int64) (int64, error) {
 func (r *MedicationRepository) GetAdherenceRate(medicationID int64, startDate, endDate time.Time) (float64, error) {
 	query := `
 		SELECT
-			COUNT(CASE WHEN taken = 1 THEN 1 END) * 100.0 / COUNT(*) AS adherence_rate
+			COUNT(CASE WHEN taken = TRUE THEN 1 END) * 100.0 / COUNT(*) AS adherence_rate
 		FROM medication_logs
 		WHERE medication_id = ? AND timestamp BETWEEN ? AND ?
 	`
@@ -355,6 +467,7 @@ func (r *MedicationRepository) scanMedications(rows *sql.Rows) ([]*models.Medica
 	var medications []*models.Medication
 	for rows.Next() {
 		var medication models.Medication
+		var doseTimes sql.NullString
 		err := rows.Scan(
 			&medication.ID,
 			&medication.Name,
@@ -365,8 +478,12 @@ func (r *MedicationRepository) scanMedications(rows *sql.Rows) ([]*models.Medica
 			&medication.IsActive,
 			&medication.Notes,
 			&medication.ScheduledTime,
+			&doseTimes,
 			&medication.TimeWindowMinutes,
 			&medication.ReminderEnabled,
+			&medication.CatalogID,
+			&medication.InventoryItemType,
+			&medication.UnitsPerDose,
 			&medication.CreatedAt,
 			&medication.UpdatedAt,
 			&medication.AccountID,
@@ -374,6 +491,7 @@ func (r *MedicationRepository) scanMedications(rows *sql.Rows) ([]*models.Medica
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan medication: %w", err)
 		}
+		medication.DoseTimes = splitDoseTimes(doseTimes)
 		medications = append(medications, &medication)
 	}
 
@@ -391,7 +509,9 @@ func (r *MedicationRepository) scanMedicationLogs(rows *sql.Rows) ([]*models.Med
 			&log.LoggedBy,
 			&log.Timestamp,
 			&log.Taken,
+			&log.Late,
 			&log.Notes,
+			&log.ScheduledFor,
 			&log.CreatedAt,
 		)
 		if err != nil {
@@ -401,4 +521,19 @@ func (r *MedicationRepository) scanMedicationLogs(rows *sql.Rows) ([]*models.Med
 	}
 
 	return logs, rows.Err()
-}
\ No newline at end of file
+}
+// joinDoseTimes stores dose times as one comma separated column
+func joinDoseTimes(times []string) sql.NullString {
+	if len(times) == 0 {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: strings.Join(times, ","), Valid: true}
+}
+
+// splitDoseTimes reads dose times back from their column
+func splitDoseTimes(s sql.NullString) []string {
+	if !s.Valid || s.String == "" {
+		return nil
+	}
+	return strings.Split(s.String, ",")
+}
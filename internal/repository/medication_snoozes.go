@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+// SnoozeDose holds back the reminder for one of a medication's doses until
+// the given time, replacing any earlier snooze of the same dose
+func (r *MedicationRepository) SnoozeDose(medicationID int64, scheduledFor, until time.Time, userID int64) (*models.MedicationDoseSnooze, error) {
+	now := time.Now().UTC()
+	snooze := &models.MedicationDoseSnooze{
+		MedicationID: medicationID,
+		ScheduledFor: scheduledFor.UTC(),
+		SnoozedUntil: until.UTC(),
+		UpdatedAt:    now,
+	}
+	snooze.SnoozedBy.Int64, snooze.SnoozedBy.Valid = userID, userID != 0
+
+	err := r.db.QueryRow(`
+		INSERT INTO medication_dose_snoozes (medication_id, scheduled_for, snoozed_until, snooze_count, snoozed_by, created_at, updated_at)
+		VALUES (?, ?, ?, 1, ?, ?, ?)
+		ON CONFLICT(medication_id, scheduled_for) DO UPDATE SET
+			snoozed_until = excluded.snoozed_until,
+			snooze_count = medication_dose_snoozes.snooze_count + 1,
+			snoozed_by = excluded.snoozed_by,
+			updated_at = excluded.updated_at
+		RETURNING id, snooze_count, created_at
+	`, medicationID, snooze.ScheduledFor, snooze.SnoozedUntil, snooze.SnoozedBy, now, now).Scan(&snooze.ID, &snooze.SnoozeCount, &snooze.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snooze dose: %w", err)
+	}
+	return snooze, nil
+}
+
+// ListSnoozesBetween retrieves the snoozes of a medication's doses due in
+// [start, end)
+func (r *MedicationRepository) ListSnoozesBetween(medicationID int64, start, end time.Time) ([]*models.MedicationDoseSnooze, error) {
+	rows, err := r.db.Query(`
+		SELECT id, medication_id, scheduled_for, snoozed_until, snooze_count, snoozed_by, created_at, updated_at
+		FROM medication_dose_snoozes
+		WHERE medication_id = ? AND scheduled_for >= ? AND scheduled_for < ?
+		ORDER BY scheduled_for
+	`, medicationID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dose snoozes: %w", err)
+	}
+	defer rows.Close()
+
+	var snoozes []*models.MedicationDoseSnooze
+	for rows.Next() {
+		var s models.MedicationDoseSnooze
+		if err := rows.Scan(&s.ID, &s.MedicationID, &s.ScheduledFor, &s.SnoozedUntil, &s.SnoozeCount, &s.SnoozedBy, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan dose snooze: %w", err)
+		}
+		snoozes = append(snoozes, &s)
+	}
+	return snoozes, rows.Err()
+}
+
+// RecordMissedDose notes that one of a medication's doses has been reported
+// missed. It reports false if it already had been.
+func (r *MedicationRepository) RecordMissedDose(medicationID int64, scheduledFor time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO medication_missed_doses (medication_id, scheduled_for, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(medication_id, scheduled_for) DO NOTHING
+	`, medicationID, scheduledFor.UTC(), time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to record missed dose: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record missed dose: %w", err)
+	}
+	return n > 0, nil
+}
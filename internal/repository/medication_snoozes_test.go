@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestMedicationRepository_SnoozeDose(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewMedicationRepository(db)
+	medication := &models.Medication{Name: "Progesterone Vaginal Insert", IsActive: true, DoseTimes: []string{"08:00", "20:00"}, AccountID: 1}
+	if err := repo.Create(medication); err != nil {
+		t.Fatalf("Failed to create medication: %v", err)
+	}
+
+	due := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	snooze, err := repo.SnoozeDose(medication.ID, due, due.Add(10*time.Minute), 1)
+	if err != nil || snooze.SnoozeCount != 1 {
+		t.Fatalf("Expected a first snooze, got %+v (%v)", snooze, err)
+	}
+
+	// Snoozing the same dose again replaces the snooze and counts it
+	snooze, err = repo.SnoozeDose(medication.ID, due, due.Add(25*time.Minute), 1)
+	if err != nil || snooze.SnoozeCount != 2 {
+		t.Fatalf("Expected a second snooze, got %+v (%v)", snooze, err)
+	}
+	if _, err := repo.SnoozeDose(medication.ID, due.Add(12*time.Hour), due.Add(12*time.Hour+5*time.Minute), 1); err != nil {
+		t.Fatalf("Failed to snooze the evening dose: %v", err)
+	}
+
+	snoozes, err := repo.ListSnoozesBetween(medication.ID, due, due.Add(12*time.Hour))
+	if err != nil || len(snoozes) != 1 {
+		t.Fatalf("Expected only the morning snooze, got %d (%v)", len(snoozes), err)
+	}
+	if got := snoozes[0]; !got.ScheduledFor.Equal(due) || !got.SnoozedUntil.Equal(due.Add(25*time.Minute)) || got.SnoozeCount != 2 {
+		t.Errorf("Unexpected snooze %+v", got)
+	}
+
+	// Logs record whether a dose was taken late
+	log := &models.MedicationLog{MedicationID: medication.ID, Timestamp: due.Add(3 * time.Hour), Taken: true, Late: true}
+	if err := repo.CreateLog(log); err != nil {
+		t.Fatalf("Failed to log dose: %v", err)
+	}
+	if got, err := repo.GetLogByID(log.ID); err != nil || !got.Late {
+		t.Errorf("Expected the log saved as late, got %+v (%v)", got, err)
+	}
+}
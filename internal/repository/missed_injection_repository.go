@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// MissedInjectionRepository stores doses that fell due and weren't logged,
+// and why they were skipped
+type MissedInjectionRepository struct {
+	db *database.DB
+}
+
+func NewMissedInjectionRepository(db *database.DB) *MissedInjectionRepository {
+	return &MissedInjectionRepository{db: db}
+}
+
+const missedInjectionColumns = `m.id, m.course_id, m.due_at, m.reason, m.note, m.annotated_by, m.annotated_at, m.created_at, c.name, COALESCE(u.username, '')`
+
+const missedInjectionFrom = `
+	FROM missed_injections m
+	JOIN courses c ON c.id = m.course_id
+	LEFT JOIN users u ON u.id = m.annotated_by`
+
+// MissedInjectionFilter narrows List. Zero values don't filter.
+type MissedInjectionFilter struct {
+	CourseID        int64
+	Start           time.Time // Due at or after, inclusive
+	End             time.Time // Due before, exclusive
+	UnannotatedOnly bool      // Only those nobody has given a reason for
+}
+
+func scanMissedInjection(row rowScanner) (*models.MissedInjection, error) {
+	var m models.MissedInjection
+	err := row.Scan(
+		&m.ID,
+		&m.CourseID,
+		&m.DueAt,
+		&m.Reason,
+		&m.Note,
+		&m.AnnotatedBy,
+		&m.AnnotatedAt,
+		&m.CreatedAt,
+		&m.CourseName,
+		&m.AnnotatedByUsername,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Record notes that a course's dose due at dueAt wasn't given. It reports
+// false if that dose was already recorded, annotated or not.
+func (r *MissedInjectionRepository) Record(courseID int64, dueAt time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO missed_injections (course_id, due_at, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(course_id, due_at) DO NOTHING
+	`, courseID, dueAt.UTC(), time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to record missed injection: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record missed injection: %w", err)
+	}
+	return n > 0, nil
+}
+
+// GetByID retrieves one of the account's missed doses
+func (r *MissedInjectionRepository) GetByID(id, accountID int64) (*models.MissedInjection, error) {
+	missed, err := scanMissedInjection(r.db.QueryRow(`SELECT `+missedInjectionColumns+missedInjectionFrom+`
+		WHERE m.id = ? AND c.account_id = ?`, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get missed injection: %w", err)
+	}
+	return missed, nil
+}
+
+// List retrieves the account's missed doses, most recently due first
+func (r *MissedInjectionRepository) List(accountID int64, filter MissedInjectionFilter, limit int) ([]*models.MissedInjection, error) {
+	query := `SELECT ` + missedInjectionColumns + missedInjectionFrom + ` WHERE c.account_id = ?`
+	args := []interface{}{accountID}
+	if filter.CourseID != 0 {
+		query += " AND m.course_id = ?"
+		args = append(args, filter.CourseID)
+	}
+	if !filter.Start.IsZero() {
+		query += " AND m.due_at >= ?"
+		args = append(args, filter.Start.UTC())
+	}
+	if !filter.End.IsZero() {
+		query += " AND m.due_at < ?"
+		args = append(args, filter.End.UTC())
+	}
+	if filter.UnannotatedOnly {
+		query += " AND m.reason IS NULL"
+	}
+	query += " ORDER BY m.due_at DESC, m.id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query missed injections: %w", err)
+	}
+	defer rows.Close()
+
+	missed := []*models.MissedInjection{}
+	for rows.Next() {
+		m, err := scanMissedInjection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan missed injection: %w", err)
+		}
+		missed = append(missed, m)
+	}
+	return missed, rows.Err()
+}
+
+// Annotate records why a missed dose was skipped, replacing any reason given
+// before. It returns ErrNotFound if the dose isn't the account's.
+func (r *MissedInjectionRepository) Annotate(missed *models.MissedInjection, accountID int64) error {
+	missed.AnnotatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	result, err := r.db.Exec(`
+		UPDATE missed_injections
+		SET reason = ?, note = ?, annotated_by = ?, annotated_at = ?
+		WHERE id = ? AND course_id IN (SELECT id FROM courses WHERE account_id = ?)
+	`, missed.Reason, missed.Note, missed.AnnotatedBy, missed.AnnotatedAt, missed.ID, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to annotate missed injection: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete dismisses a missed dose, e.g. one that was given but logged late
+func (r *MissedInjectionRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec(`
+		DELETE FROM missed_injections
+		WHERE id = ? AND course_id IN (SELECT id FROM courses WHERE account_id = ?)
+	`, id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete missed injection: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClearLogged removes a course's unannotated missed doses that have since
+// had an injection logged within grace either side of when they fell due,
+// as happens when a dose is given on time but logged afterwards. It returns
+// how many were removed.
+func (r *MissedInjectionRepository) ClearLogged(courseID int64, grace time.Duration) (int, error) {
+	rows, err := r.db.Query(`
+		SELECT id, due_at FROM missed_injections
+		WHERE course_id = ? AND reason IS NULL
+	`, courseID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query missed injections: %w", err)
+	}
+	type pending struct {
+		id    int64
+		dueAt time.Time
+	}
+	var unannotated []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.dueAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan missed injection: %w", err)
+		}
+		unannotated = append(unannotated, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query missed injections: %w", err)
+	}
+
+	cleared := 0
+	for _, p := range unannotated {
+		result, err := r.db.Exec(`
+			DELETE FROM missed_injections
+			WHERE id = ? AND EXISTS (
+				SELECT 1 FROM injections
+				WHERE course_id = ? AND voided_at IS NULL AND timestamp >= ? AND timestamp <= ?
+			)
+		`, p.id, courseID, p.dueAt.Add(-grace).UTC(), p.dueAt.Add(grace).UTC())
+		if err != nil {
+			return cleared, fmt.Errorf("failed to clear missed injection: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			cleared += int(n)
+		}
+	}
+	return cleared, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestMissedInjectionRepository(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO accounts (id) VALUES (2);
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', CURRENT_TIMESTAMP, 1);
+	`); err != nil {
+		t.Fatalf("Failed to seed course: %v", err)
+	}
+
+	repo := NewMissedInjectionRepository(db)
+	first := time.Date(2026, 3, 1, 19, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 1)
+	for _, due := range []time.Time{first, second} {
+		if ok, err := repo.Record(1, due); err != nil || !ok {
+			t.Fatalf("Record failed: %v, %v", ok, err)
+		}
+	}
+	// The same dose in another timezone is still the same dose
+	if ok, err := repo.Record(1, first.In(time.FixedZone("EST", -5*3600))); err != nil || ok {
+		t.Errorf("Expected a dose to be recorded once, got %v, %v", ok, err)
+	}
+
+	missed, err := repo.List(1, MissedInjectionFilter{}, 10)
+	if err != nil || len(missed) != 2 || !missed[0].DueAt.Equal(second) || missed[0].CourseName != "Course" {
+		t.Fatalf("Expected both misses, latest first, got %v, %v", missed, err)
+	}
+	if missed, _ := repo.List(2, MissedInjectionFilter{}, 10); len(missed) != 0 {
+		t.Errorf("Expected another account to see none, got %d", len(missed))
+	}
+
+	annotation := &models.MissedInjection{
+		ID:          missed[1].ID,
+		Reason:      sql.NullString{String: "clinic_instruction", Valid: true},
+		Note:        sql.NullString{String: "Paused for bloods", Valid: true},
+		AnnotatedBy: sql.NullInt64{Int64: 1, Valid: true},
+	}
+	if err := repo.Annotate(annotation, 2); err != ErrNotFound {
+		t.Errorf("Expected another account's miss to be refused, got %v", err)
+	}
+	if err := repo.Annotate(annotation, 1); err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+	got, err := repo.GetByID(annotation.ID, 1)
+	if err != nil || got.Reason.String != "clinic_instruction" || got.Note.String != "Paused for bloods" || !got.AnnotatedAt.Valid {
+		t.Errorf("Unexpected annotated miss: %+v, %v", got, err)
+	}
+	if missed, _ := repo.List(1, MissedInjectionFilter{UnannotatedOnly: true}, 10); len(missed) != 1 || !missed[0].DueAt.Equal(second) {
+		t.Errorf("Expected only the unannotated miss, got %v", missed)
+	}
+
+	// Doses logged late, within the grace, clear unannotated misses only
+	if _, err := db.Exec(`
+		INSERT INTO injections (course_id, timestamp, side) VALUES (1, ?, 'left'), (1, ?, 'right')
+	`, first.Add(2*time.Hour), second.Add(2*time.Hour)); err != nil {
+		t.Fatalf("Failed to seed injections: %v", err)
+	}
+	cleared, err := repo.ClearLogged(1, time.Hour)
+	if err != nil || cleared != 0 {
+		t.Errorf("Expected nothing outside the grace cleared, got %d, %v", cleared, err)
+	}
+	cleared, err = repo.ClearLogged(1, 3*time.Hour)
+	if err != nil || cleared != 1 {
+		t.Errorf("Expected the unannotated miss cleared, got %d, %v", cleared, err)
+	}
+
+	if err := repo.Delete(annotation.ID, 2); err != ErrNotFound {
+		t.Errorf("Expected another account not to dismiss the miss, got %v", err)
+	}
+	if err := repo.Delete(annotation.ID, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if missed, _ := repo.List(1, MissedInjectionFilter{}, 10); len(missed) != 0 {
+		t.Errorf("Expected no misses left, got %d", len(missed))
+	}
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/secrets"
+)
+
+type NotificationChannelRepository struct {
+	db *database.DB
+}
+
+func NewNotificationChannelRepository(db *database.DB) *NotificationChannelRepository {
+	return &NotificationChannelRepository{db: db}
+}
+
+// Create creates a new notification channel. Its config is stored
+// encrypted, as it holds tokens and secrets.
+func (r *NotificationChannelRepository) Create(channel *models.NotificationChannel) error {
+	config, err := secrets.Active().Seal(r.db, channel.Config)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt notification channel config: %w", err)
+	}
+	query := `
+		INSERT INTO notification_channels (account_id, type, name, config, is_enabled, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	now := time.Now()
+	var id int64
+	err = r.db.QueryRow(query,
+		channel.AccountID,
+		channel.Type,
+		channel.Name,
+		config,
+		channel.IsEnabled,
+		channel.CreatedBy,
+		now,
+		now,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to create notification channel: %w", err)
+	}
+
+	channel.ID = id
+	channel.CreatedAt = now
+	channel.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves a notification channel by ID within an account
+func (r *NotificationChannelRepository) GetByID(id, accountID int64) (*models.NotificationChannel, error) {
+	query := `
+		SELECT id, account_id, type, name, config, is_enabled, last_sent_at, last_error,
+		       created_by, created_at, updated_at
+		FROM notification_channels
+		WHERE id = ? AND account_id = ?
+	`
+	var c models.NotificationChannel
+	err := r.db.QueryRow(query, id, accountID).Scan(
+		&c.ID,
+		&c.AccountID,
+		&c.Type,
+		&c.Name,
+		&c.Config,
+		&c.IsEnabled,
+		&c.LastSentAt,
+		&c.LastError,
+		&c.CreatedBy,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification channel: %w", err)
+	}
+	if err := r.openConfigs([]*models.NotificationChannel{&c}); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// List retrieves all notification channels for an account
+func (r *NotificationChannelRepository) List(accountID int64) ([]*models.NotificationChannel, error) {
+	return r.list(accountID, false)
+}
+
+// ListEnabled retrieves only the enabled notification channels for an account
+func (r *NotificationChannelRepository) ListEnabled(accountID int64) ([]*models.NotificationChannel, error) {
+	return r.list(accountID, true)
+}
+
+func (r *NotificationChannelRepository) list(accountID int64, enabledOnly bool) ([]*models.NotificationChannel, error) {
+	query := `
+		SELECT id, account_id, type, name, config, is_enabled, last_sent_at, last_error,
+		       created_by, created_at, updated_at
+		FROM notification_channels
+		WHERE account_id = ?
+	`
+	if enabledOnly {
+		query += " AND is_enabled = TRUE"
+	}
+	query += " ORDER BY created_at ASC, id ASC"
+
+	rows, err := r.db.Query(query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification channels: %w", err)
+	}
+	defer rows.Close()
+
+	channels, err := r.scanChannels(rows)
+	if err != nil {
+		return nil, err
+	}
+	return channels, r.openConfigs(channels)
+}
+
+// Update updates a notification channel's name, config and enabled flag
+func (r *NotificationChannelRepository) Update(channel *models.NotificationChannel) error {
+	config, err := secrets.Active().Seal(r.db, channel.Config)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt notification channel config: %w", err)
+	}
+	query := `
+		UPDATE notification_channels
+		SET name = ?, config = ?, is_enabled = ?
+		WHERE id = ? AND account_id = ?
+	`
+	result, err := r.db.Exec(query,
+		channel.Name,
+		config,
+		channel.IsEnabled,
+		channel.ID,
+		channel.AccountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update notification channel: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete deletes a notification channel
+func (r *NotificationChannelRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec("DELETE FROM notification_channels WHERE id = ? AND account_id = ?", id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete notification channel: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RecordDelivery stores the outcome of the latest delivery attempt.
+// A nil sendErr marks the channel as healthy and clears any previous error.
+func (r *NotificationChannelRepository) RecordDelivery(id int64, sendErr error) error {
+	var err error
+	if sendErr == nil {
+		_, err = r.db.Exec(`
+			UPDATE notification_channels
+			SET last_sent_at = ?, last_error = NULL
+			WHERE id = ?
+		`, time.Now(), id)
+	} else {
+		_, err = r.db.Exec(`
+			UPDATE notification_channels
+			SET last_error = ?
+			WHERE id = ?
+		`, sendErr.Error(), id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record notification channel delivery: %w", err)
+	}
+
+	return nil
+}
+
+// openConfigs decrypts the configs of channels read from the database
+func (r *NotificationChannelRepository) openConfigs(channels []*models.NotificationChannel) error {
+	for _, c := range channels {
+		config, err := secrets.Active().Open(r.db, c.Config)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt config of notification channel %d: %w", c.ID, err)
+		}
+		c.Config = config
+	}
+	return nil
+}
+
+// scanChannels is a helper to scan multiple notification channel rows
+func (r *NotificationChannelRepository) scanChannels(rows *sql.Rows) ([]*models.NotificationChannel, error) {
+	var channels []*models.NotificationChannel
+	for rows.Next() {
+		var c models.NotificationChannel
+		err := rows.Scan(
+			&c.ID,
+			&c.AccountID,
+			&c.Type,
+			&c.Name,
+			&c.Config,
+			&c.IsEnabled,
+			&c.LastSentAt,
+			&c.LastError,
+			&c.CreatedBy,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification channel: %w", err)
+		}
+		channels = append(channels, &c)
+	}
+
+	return channels, rows.Err()
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"injection-tracker/internal/models"
+)
+
+func TestNotificationChannelRepository_CreateAndGet(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewNotificationChannelRepository(db)
+
+	channel := &models.NotificationChannel{
+		AccountID: 1,
+		Type:      "ntfy",
+		Name:      "Phone",
+		Config:    `{"server_url":"https://ntfy.sh","topic":"ptrack-test"}`,
+		IsEnabled: true,
+		CreatedBy: sql.NullInt64{Int64: 1, Valid: true},
+	}
+
+	if err := repo.Create(channel); err != nil {
+		t.Fatalf("Failed to create channel: %v", err)
+	}
+	if channel.ID == 0 {
+		t.Fatal("Expected channel ID to be set after creation")
+	}
+
+	retrieved, err := repo.GetByID(channel.ID, 1)
+	if err != nil {
+		t.Fatalf("Failed to get channel: %v", err)
+	}
+	if retrieved.Name != "Phone" || retrieved.Type != "ntfy" {
+		t.Errorf("Unexpected channel: %+v", retrieved)
+	}
+	if retrieved.Config != channel.Config {
+		t.Errorf("Expected config %s, got %s", channel.Config, retrieved.Config)
+	}
+
+	// Channels must not be visible from another account
+	if _, err := repo.GetByID(channel.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for other account, got %v", err)
+	}
+}
+
+func TestNotificationChannelRepository_ListEnabled(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewNotificationChannelRepository(db)
+
+	enabled := &models.NotificationChannel{AccountID: 1, Type: "gotify", Name: "Gotify", Config: "{}", IsEnabled: true}
+	disabled := &models.NotificationChannel{AccountID: 1, Type: "webhook", Name: "Hook", Config: "{}", IsEnabled: false}
+	for _, c := range []*models.NotificationChannel{enabled, disabled} {
+		if err := repo.Create(c); err != nil {
+			t.Fatalf("Failed to create channel: %v", err)
+		}
+	}
+
+	all, err := repo.List(1)
+	if err != nil {
+		t.Fatalf("Failed to list channels: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected 2 channels, got %d", len(all))
+	}
+
+	active, err := repo.ListEnabled(1)
+	if err != nil {
+		t.Fatalf("Failed to list enabled channels: %v", err)
+	}
+	if len(active) != 1 || active[0].ID != enabled.ID {
+		t.Errorf("Expected only the enabled channel, got %d channels", len(active))
+	}
+}
+
+func TestNotificationChannelRepository_UpdateAndDelete(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewNotificationChannelRepository(db)
+
+	channel := &models.NotificationChannel{AccountID: 1, Type: "webhook", Name: "Hook", Config: "{}", IsEnabled: true}
+	if err := repo.Create(channel); err != nil {
+		t.Fatalf("Failed to create channel: %v", err)
+	}
+
+	channel.Name = "Renamed"
+	channel.IsEnabled = false
+	if err := repo.Update(channel); err != nil {
+		t.Fatalf("Failed to update channel: %v", err)
+	}
+
+	retrieved, err := repo.GetByID(channel.ID, 1)
+	if err != nil {
+		t.Fatalf("Failed to get channel: %v", err)
+	}
+	if retrieved.Name != "Renamed" || retrieved.IsEnabled {
+		t.Errorf("Update not applied: %+v", retrieved)
+	}
+
+	if err := repo.Delete(channel.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting from other account, got %v", err)
+	}
+	if err := repo.Delete(channel.ID, 1); err != nil {
+		t.Fatalf("Failed to delete channel: %v", err)
+	}
+	if _, err := repo.GetByID(channel.ID, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestNotificationChannelRepository_RecordDelivery(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewNotificationChannelRepository(db)
+
+	channel := &models.NotificationChannel{AccountID: 1, Type: "ntfy", Name: "Phone", Config: "{}", IsEnabled: true}
+	if err := repo.Create(channel); err != nil {
+		t.Fatalf("Failed to create channel: %v", err)
+	}
+
+	if err := repo.RecordDelivery(channel.ID, errors.New("connection refused")); err != nil {
+		t.Fatalf("Failed to record failed delivery: %v", err)
+	}
+	retrieved, _ := repo.GetByID(channel.ID, 1)
+	if !retrieved.LastError.Valid || retrieved.LastError.String != "connection refused" {
+		t.Errorf("Expected last_error to be recorded, got %+v", retrieved.LastError)
+	}
+
+	if err := repo.RecordDelivery(channel.ID, nil); err != nil {
+		t.Fatalf("Failed to record successful delivery: %v", err)
+	}
+	retrieved, _ = repo.GetByID(channel.ID, 1)
+	if retrieved.LastError.Valid {
+		t.Errorf("Expected last_error to be cleared, got %s", retrieved.LastError.String)
+	}
+	if !retrieved.LastSentAt.Valid {
+		t.Error("Expected last_sent_at to be set")
+	}
+}
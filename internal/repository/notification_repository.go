@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
 	"injection-tracker/internal/models"
 )
 
@@ -22,8 +23,10 @@ func (r *NotificationRepository) Create(notification *models.Notification) error
 	query := `
 		INSERT INTO notifications (user_id, type, title, message, is_read, scheduled_time, created_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
 	`
-	result, err := r.db.Exec(query,
+	var id int64
+	err := r.db.QueryRow(query,
 		notification.UserID,
 		notification.Type,
 		notification.Title,
@@ -31,16 +34,11 @@ func (r *NotificationRepository) Create(notification *models.Notification) error
 		notification.IsRead,
 		notification.ScheduledTime,
 		time.Now(),
-	)
+	).Scan(&id)
 	if err != nil {
 		return fmt.Errorf("failed to create notification: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	notification.ID = id
 	notification.CreatedAt = time.Now()
 	return nil
@@ -84,7 +82,7 @@ func (r *NotificationRepository) GetByUserID(userID int64, includeRead bool, lim
 	args := []interface{}{userID}
 
 	if !includeRead {
-		query += " AND is_read = 0"
+		query += " AND is_read = FALSE"
 	}
 
 	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
@@ -104,7 +102,7 @@ func (r *NotificationRepository) CountUnread(userID int64) (int64, error) {
 	query := `
 		SELECT COUNT(*)
 		FROM notifications
-		WHERE (user_id = ? OR user_id IS NULL) AND is_read = 0
+		WHERE (user_id = ? OR user_id IS NULL) AND is_read = FALSE
 	`
 	var count int64
 	err := r.db.QueryRow(query, userID).Scan(&count)
@@ -118,7 +116,7 @@ func (r *NotificationRepository) CountUnread(userID int64) (int64, error) {
 func (r *NotificationRepository) MarkAsRead(id int64, userID int64) error {
 	query := `
 		UPDATE notifications
-		SET is_read = 1
+		SET is_read = TRUE
 		WHERE id = ? AND (user_id = ? OR user_id IS NULL)
 	`
 	result, err := r.db.Exec(query, id, userID)
@@ -141,8 +139,8 @@ func (r *NotificationRepository) MarkAsRead(id int64, userID int64) error {
 func (r *NotificationRepository) MarkAllAsRead(userID int64) error {
 	query := `
 		UPDATE notifications
-		SET is_read = 1
-		WHERE (user_id = ? OR user_id IS NULL) AND is_read = 0
+		SET is_read = TRUE
+		WHERE (user_id = ? OR user_id IS NULL) AND is_read = FALSE
 	`
 	_, err := r.db.Exec(query, userID)
 	if err != nil {
@@ -178,9 +176,9 @@ func (r *NotificationRepository) Delete(id int64, userID int64) error {
 func (r *NotificationRepository) DeleteOldRead(daysOld int) error {
 	query := `
 		DELETE FROM notifications
-		WHERE is_read = 1 AND created_at < datetime('now', ?)
+		WHERE is_read = TRUE AND created_at < ?
 	`
-	_, err := r.db.Exec(query, fmt.Sprintf("-%d days", daysOld))
+	_, err := r.db.Exec(query, time.Now().UTC().AddDate(0, 0, -daysOld))
 	if err != nil {
 		return fmt.Errorf("failed to delete old notifications: %w", err)
 	}
@@ -191,7 +189,7 @@ func (r *NotificationRepository) DeleteOldRead(daysOld int) error {
 // CreateLowStockNotification creates a low stock notification
 func (r *NotificationRepository) CreateLowStockNotification(userID sql.NullInt64, itemType string, quantity float64, threshold float64, severity string) error {
 	// Check if a similar notification already exists (within last 24 hours)
-	exists, err := r.notificationExists(userID, "low_stock", itemType, 24)
+	exists, err := r.RecentlyNotified(userID, "low_stock", itemType, 24)
 	if err != nil {
 		return err
 	}
@@ -199,19 +197,13 @@ func (r *NotificationRepository) CreateLowStockNotification(userID sql.NullInt64
 		return nil // Don't create duplicate notification
 	}
 
-	title := "Low Stock Alert"
-	if severity == "critical" {
-		title = "Critical: Stock Very Low"
-	}
-
-	message := fmt.Sprintf("%s is running low (%.1f remaining, threshold: %.1f). Please restock soon.",
-		formatItemType(itemType), quantity, threshold)
+	title, message, args := LowStockNotificationContent(itemType, quantity, threshold, severity)
 
 	notification := &models.Notification{
 		UserID:  userID,
 		Type:    "low_stock",
 		Title:   title,
-		Message: message,
+		Message: i18n.Default().T(message, args...),
 		IsRead:  false,
 	}
 
@@ -221,7 +213,7 @@ func (r *NotificationRepository) CreateLowStockNotification(userID sql.NullInt64
 // CreateExpirationNotification creates an expiration warning notification
 func (r *NotificationRepository) CreateExpirationNotification(userID sql.NullInt64, itemType string, expirationDate time.Time, isExpired bool) error {
 	// Check if a similar notification already exists (within last 24 hours)
-	exists, err := r.notificationExists(userID, "expiration_warning", itemType, 24)
+	exists, err := r.RecentlyNotified(userID, "expiration_warning", itemType, 24)
 	if err != nil {
 		return err
 	}
@@ -229,45 +221,87 @@ func (r *NotificationRepository) CreateExpirationNotification(userID sql.NullInt
 		return nil // Don't create duplicate notification
 	}
 
-	var title, message string
-	if isExpired {
-		title = "Expired Medication"
-		message = fmt.Sprintf("%s expired on %s. Please dispose of it and restock.",
-			formatItemType(itemType), expirationDate.Format("Jan 2, 2006"))
-	} else {
-		daysUntil := int(time.Until(expirationDate).Hours() / 24)
-		title = "Medication Expiring Soon"
-		message = fmt.Sprintf("%s will expire in %d days (on %s).",
-			formatItemType(itemType), daysUntil, expirationDate.Format("Jan 2, 2006"))
-	}
+	title, message, args := ExpirationNotificationContent(itemType, expirationDate, isExpired)
 
 	notification := &models.Notification{
 		UserID:  userID,
 		Type:    "expiration_warning",
 		Title:   title,
-		Message: message,
+		Message: i18n.Default().T(message, args...),
 		IsRead:  false,
 	}
 
 	return r.Create(notification)
 }
 
-// notificationExists checks if a similar notification already exists recently
-func (r *NotificationRepository) notificationExists(userID sql.NullInt64, notifType, keyword string, hoursAgo int) (bool, error) {
+// LowStockNotificationContent builds the title and message for a low stock
+// notification. The message is a format for i18n's Printer.T with its
+// args, so it can be written in each recipient's language. The item keeps
+// its name, which the notifications are deduplicated by.
+func LowStockNotificationContent(itemType string, quantity, threshold float64, severity string) (string, string, []interface{}) {
+	if severity == "out" {
+		return "Out of Stock", "%s is out of stock (threshold: %.1f). Restock before the next injection.",
+			[]interface{}{formatItemType(itemType), threshold}
+	}
+
+	title := "Low Stock Alert"
+	if severity == "critical" {
+		title = "Critical: Stock Very Low"
+	}
+
+	return title, "%s is running low (%.1f remaining, threshold: %.1f). Please restock soon.",
+		[]interface{}{formatItemType(itemType), quantity, threshold}
+}
+
+// ExpirationNotificationContent builds the title and message for an
+// expiration warning, as LowStockNotificationContent does
+func ExpirationNotificationContent(itemType string, expirationDate time.Time, isExpired bool) (string, string, []interface{}) {
+	if isExpired {
+		return "Expired Medication", "%s expired on %s. Please dispose of it and restock.",
+			[]interface{}{formatItemType(itemType), i18n.Date(expirationDate)}
+	}
+
+	daysUntil := int(time.Until(expirationDate).Hours() / 24)
+	return "Medication Expiring Soon", "%s will expire in %d days (on %s).",
+		[]interface{}{formatItemType(itemType), daysUntil, i18n.Date(expirationDate)}
+}
+
+// VialDiscardNotificationContent builds the title and message for an open
+// vial nearing or past its beyond-use date, as LowStockNotificationContent
+// does. The message names the vial as "#3 " in every language, which
+// VialNotificationKey matches so repeats can be recognised.
+func VialDiscardNotificationContent(vial *models.InventoryVial, now time.Time) (string, string, []interface{}) {
+	discardAt := vial.DiscardAt.Time
+	if !discardAt.After(now) {
+		return "Vial Past Discard Date", "Vial #%d was opened and reached its discard date on %s. Please throw it out, with the %s mL left in it.",
+			[]interface{}{vial.ID, i18n.Date(discardAt), formatML(vial.RemainingML)}
+	}
+	return "Vial Due for Disposal", "Vial #%d was opened and must be discarded by %s (%s mL left).",
+		[]interface{}{vial.ID, i18n.DateTime(discardAt), formatML(vial.RemainingML)}
+}
+
+// VialNotificationKey identifies a vial in notification messages whatever
+// their language. The trailing space keeps vial #1 from matching vial #12.
+func VialNotificationKey(vialID int64) string {
+	return fmt.Sprintf("#%d ", vialID)
+}
+
+// RecentlyNotified checks if a similar notification already exists recently
+func (r *NotificationRepository) RecentlyNotified(userID sql.NullInt64, notifType, keyword string, hoursAgo int) (bool, error) {
 	query := `
 		SELECT EXISTS(
 			SELECT 1 FROM notifications
 			WHERE type = ?
 			AND message LIKE ?
-			AND created_at > datetime('now', ?)
-			AND (user_id = ? OR (user_id IS NULL AND ? IS NULL))
+			AND created_at > ?
+			AND (user_id = ? OR (user_id IS NULL AND CAST(? AS BIGINT) IS NULL))
 		)
 	`
 	var exists bool
 	err := r.db.QueryRow(query,
 		notifType,
 		"%"+keyword+"%",
-		fmt.Sprintf("-%d hours", hoursAgo),
+		time.Now().UTC().Add(-time.Duration(hoursAgo)*time.Hour),
 		userID,
 		userID,
 	).Scan(&exists)
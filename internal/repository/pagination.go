@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+)
+
+// Page sizes for cursor-paginated lists
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// ErrInvalidCursor is returned when a page cursor can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageRequest asks for one page of a newest-first list. An empty Cursor
+// starts from the newest record; otherwise it is the NextCursor of the
+// previous page.
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+// Page is one page of a list along with the size of the whole list
+type Page[T any] struct {
+	Items      []T
+	Total      int64
+	NextCursor string // Empty on the last page
+}
+
+// size returns the page size, applying the default and the cap
+func (p PageRequest) size() int {
+	switch {
+	case p.Limit <= 0:
+		return DefaultPageSize
+	case p.Limit > MaxPageSize:
+		return MaxPageSize
+	}
+	return p.Limit
+}
+
+// pageCursor is the position of the last record on a page. Lists are
+// ordered by (timestamp DESC, id DESC) so the pair is unique.
+type pageCursor struct {
+	Timestamp time.Time
+	ID        int64
+}
+
+func encodeCursor(ts time.Time, id int64) string {
+	raw := strconv.FormatInt(ts.UnixNano(), 10) + ":" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	tsPart, idPart, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(tsPart, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &pageCursor{Timestamp: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// pageQuery describes a newest-first list over a table with id and
+// timestamp columns
+type pageQuery struct {
+	Columns    string // SELECT list
+	From       string // FROM and WHERE clauses, without ORDER BY
+	Args       []interface{}
+	Table      string // Table being listed
+	Alias      string // Its alias in From
+	TimeColumn string // Column the list is ordered by, timestamp when empty
+}
+
+// listPage runs q one page at a time. Rows after the cursor are found by
+// looking up the cursor row's stored timestamp, since older rows may hold
+// timestamps in a different text format than a bound time.Time would
+// produce and SQLite compares them as text; the timestamp carried in the
+// cursor is only used if that row has since been deleted.
+func listPage[T any](db *database.DB, p PageRequest, q pageQuery, scan func(*sql.Rows) ([]T, error), key func(T) (time.Time, int64)) (*Page[T], error) {
+	var after *pageCursor
+	if p.Cursor != "" {
+		c, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+
+	page := &Page[T]{}
+	if err := db.QueryRow(`SELECT COUNT(*) `+q.From, q.Args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count %s: %w", q.Table, err)
+	}
+
+	column := q.TimeColumn
+	if column == "" {
+		column = "timestamp"
+	}
+	query := `SELECT ` + q.Columns + ` ` + q.From
+	args := append([]interface{}{}, q.Args...)
+	if after != nil {
+		query += fmt.Sprintf(` AND (%[1]s.%[3]s, %[1]s.id) < (COALESCE((SELECT %[3]s FROM %[2]s WHERE id = ?), ?), ?)`, q.Alias, q.Table, column)
+		args = append(args, after.ID, after.Timestamp, after.ID)
+	}
+	size := p.size()
+	query += fmt.Sprintf(` ORDER BY %[1]s.%[2]s DESC, %[1]s.id DESC LIMIT ?`, q.Alias, column)
+	args = append(args, size+1) // One extra to tell whether there's another page
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", q.Table, err)
+	}
+	defer rows.Close()
+
+	items, err := scan(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > size {
+		items = items[:size]
+		page.NextCursor = encodeCursor(key(items[size-1]))
+	}
+	page.Items = items
+	return page, nil
+}
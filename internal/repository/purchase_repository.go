@@ -0,0 +1,345 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type PurchaseRepository struct {
+	db *database.DB
+}
+
+func NewPurchaseRepository(db *database.DB) *PurchaseRepository {
+	return &PurchaseRepository{db: db}
+}
+
+const purchaseOrderColumns = `id, account_id, supplier, order_number, status, ordered_at, expected_arrival, received_at,
+		       shipping_cost, notes, created_by, created_at, updated_at`
+
+const purchaseOrderItemColumns = `id, order_id, item_type, quantity, unit_cost, lot_number, expiration_date, lot_id`
+
+// Create creates a purchase order and its items
+func (r *PurchaseRepository) Create(order *models.PurchaseOrder) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	var id int64
+	err = tx.QueryRow(`
+		INSERT INTO purchase_orders (account_id, supplier, order_number, status, ordered_at, expected_arrival,
+		                             shipping_cost, notes, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`,
+		order.AccountID,
+		order.Supplier,
+		order.OrderNumber,
+		order.Status,
+		order.OrderedAt,
+		order.ExpectedArrival,
+		order.ShippingCost,
+		order.Notes,
+		order.CreatedBy,
+		now,
+		now,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to create purchase order: %w", err)
+	}
+
+	if err := insertPurchaseOrderItems(tx, id, order.Items); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit purchase order: %w", err)
+	}
+
+	order.ID = id
+	order.CreatedAt = now
+	order.UpdatedAt = now
+	for i := range order.Items {
+		order.Items[i].OrderID = id
+	}
+	return nil
+}
+
+func insertPurchaseOrderItems(tx *sql.Tx, orderID int64, items []models.PurchaseOrderItem) error {
+	for i := range items {
+		item := &items[i]
+		err := tx.QueryRow(`
+			INSERT INTO purchase_order_items (order_id, item_type, quantity, unit_cost, lot_number, expiration_date)
+			VALUES (?, ?, ?, ?, ?, ?)
+			RETURNING id
+		`, orderID, item.ItemType, item.Quantity, item.UnitCost, item.LotNumber, item.ExpirationDate).Scan(&item.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create purchase order item: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetByID retrieves a purchase order and its items, scoped to the account
+func (r *PurchaseRepository) GetByID(id, accountID int64) (*models.PurchaseOrder, error) {
+	query := `SELECT ` + purchaseOrderColumns + ` FROM purchase_orders WHERE id = ? AND account_id = ?`
+	order, err := scanPurchaseOrder(r.db.QueryRow(query, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get purchase order: %w", err)
+	}
+
+	items, err := r.listItems(`WHERE order_id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	order.Items = items[id]
+	return order, nil
+}
+
+// List retrieves an account's purchase orders, newest first, optionally
+// filtered by status
+func (r *PurchaseRepository) List(accountID int64, status string) ([]*models.PurchaseOrder, error) {
+	query := `SELECT ` + purchaseOrderColumns + ` FROM purchase_orders WHERE account_id = ?`
+	args := []interface{}{accountID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY ordered_at DESC, id DESC`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchase orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []*models.PurchaseOrder{}
+	for rows.Next() {
+		order, err := scanPurchaseOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan purchase order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query purchase orders: %w", err)
+	}
+
+	items, err := r.listItems(`WHERE order_id IN (SELECT id FROM purchase_orders WHERE account_id = ?)`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		order.Items = items[order.ID]
+	}
+	return orders, nil
+}
+
+// listItems loads purchase order items matching where, grouped by order
+func (r *PurchaseRepository) listItems(where string, args ...interface{}) (map[int64][]models.PurchaseOrderItem, error) {
+	rows, err := r.db.Query(`SELECT `+purchaseOrderItemColumns+` FROM purchase_order_items `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchase order items: %w", err)
+	}
+	defer rows.Close()
+
+	items := map[int64][]models.PurchaseOrderItem{}
+	for rows.Next() {
+		var item models.PurchaseOrderItem
+		err := rows.Scan(
+			&item.ID,
+			&item.OrderID,
+			&item.ItemType,
+			&item.Quantity,
+			&item.UnitCost,
+			&item.LotNumber,
+			&item.ExpirationDate,
+			&item.LotID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan purchase order item: %w", err)
+		}
+		items[item.OrderID] = append(items[item.OrderID], item)
+	}
+	return items, rows.Err()
+}
+
+// Update saves an order's details and replaces its items. Only orders that
+// have not been received may be updated.
+func (r *PurchaseRepository) Update(order *models.PurchaseOrder) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.Exec(`
+		UPDATE purchase_orders
+		SET supplier = ?, order_number = ?, status = ?, ordered_at = ?, expected_arrival = ?,
+		    shipping_cost = ?, notes = ?, updated_at = ?
+		WHERE id = ? AND account_id = ? AND status != 'received'
+	`,
+		order.Supplier,
+		order.OrderNumber,
+		order.Status,
+		order.OrderedAt,
+		order.ExpectedArrival,
+		order.ShippingCost,
+		order.Notes,
+		time.Now(),
+		order.ID,
+		order.AccountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update purchase order: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM purchase_order_items WHERE order_id = ?`, order.ID); err != nil {
+		return fmt.Errorf("failed to replace purchase order items: %w", err)
+	}
+	if err := insertPurchaseOrderItems(tx, order.ID, order.Items); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MarkPurchaseOrderReceived records that an order arrived and which lot each
+// item became. The caller adds the stock to inventory in the same transaction.
+func MarkPurchaseOrderReceived(tx *sql.Tx, order *models.PurchaseOrder, receivedAt time.Time) error {
+	result, err := tx.Exec(`
+		UPDATE purchase_orders SET status = 'received', received_at = ?, updated_at = ?
+		WHERE id = ? AND account_id = ? AND status = 'ordered'
+	`, receivedAt, time.Now(), order.ID, order.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to mark purchase order received: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+
+	for _, item := range order.Items {
+		if _, err := tx.Exec(`UPDATE purchase_order_items SET lot_id = ? WHERE id = ?`, item.LotID, item.ID); err != nil {
+			return fmt.Errorf("failed to link purchase order item to lot: %w", err)
+		}
+	}
+
+	order.Status = "received"
+	order.ReceivedAt = sql.NullTime{Time: receivedAt, Valid: true}
+	return nil
+}
+
+// Delete removes a purchase order that has not been received
+func (r *PurchaseRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec(`
+		DELETE FROM purchase_orders WHERE id = ? AND account_id = ? AND status != 'received'
+	`, id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete purchase order: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SpendByMonth totals non-cancelled orders placed between start and end
+// (inclusive dates) by the month they were ordered
+func (r *PurchaseRepository) SpendByMonth(accountID int64, start, end time.Time) ([]models.PurchaseSpend, error) {
+	from, until := dayBounds(start, end)
+	rows, err := r.db.Query(`
+		SELECT `+r.db.Dialect.Month("o.ordered_at")+` AS period,
+		       COUNT(*),
+		       COALESCE(SUM((SELECT SUM(i.quantity * i.unit_cost) FROM purchase_order_items i WHERE i.order_id = o.id)), 0),
+		       COALESCE(SUM(o.shipping_cost), 0)
+		FROM purchase_orders o
+		WHERE o.account_id = ? AND o.status != 'cancelled'
+		  AND o.ordered_at >= ? AND o.ordered_at < ?
+		GROUP BY period
+		ORDER BY period
+	`, accountID, from, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query spend by month: %w", err)
+	}
+	defer rows.Close()
+
+	spend := []models.PurchaseSpend{}
+	for rows.Next() {
+		var s models.PurchaseSpend
+		if err := rows.Scan(&s.Key, &s.Orders, &s.ItemCost, &s.ShippingCost); err != nil {
+			return nil, fmt.Errorf("failed to scan spend: %w", err)
+		}
+		spend = append(spend, s)
+	}
+	return spend, rows.Err()
+}
+
+// SpendByItemType totals the items of non-cancelled orders placed between
+// start and end by item type. Shipping is not split across items.
+func (r *PurchaseRepository) SpendByItemType(accountID int64, start, end time.Time) ([]models.PurchaseSpend, error) {
+	from, until := dayBounds(start, end)
+	rows, err := r.db.Query(`
+		SELECT i.item_type, COUNT(DISTINCT o.id), SUM(i.quantity), SUM(i.quantity * i.unit_cost)
+		FROM purchase_order_items i
+		JOIN purchase_orders o ON o.id = i.order_id
+		WHERE o.account_id = ? AND o.status != 'cancelled'
+		  AND o.ordered_at >= ? AND o.ordered_at < ?
+		GROUP BY i.item_type
+		ORDER BY SUM(i.quantity * i.unit_cost) DESC, i.item_type
+	`, accountID, from, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query spend by item type: %w", err)
+	}
+	defer rows.Close()
+
+	spend := []models.PurchaseSpend{}
+	for rows.Next() {
+		var s models.PurchaseSpend
+		if err := rows.Scan(&s.Key, &s.Orders, &s.Quantity, &s.ItemCost); err != nil {
+			return nil, fmt.Errorf("failed to scan spend: %w", err)
+		}
+		spend = append(spend, s)
+	}
+	return spend, rows.Err()
+}
+
+func scanPurchaseOrder(row rowScanner) (*models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	err := row.Scan(
+		&order.ID,
+		&order.AccountID,
+		&order.Supplier,
+		&order.OrderNumber,
+		&order.Status,
+		&order.OrderedAt,
+		&order.ExpectedArrival,
+		&order.ReceivedAt,
+		&order.ShippingCost,
+		&order.Notes,
+		&order.CreatedBy,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// dayBounds turns the inclusive dates start and end into the half-open UTC
+// range [from, until) that covers them
+func dayBounds(start, end time.Time) (from, until time.Time) {
+	from = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	until = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	return from, until
+}
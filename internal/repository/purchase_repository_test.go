@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func createTestPurchaseOrder(t *testing.T, repo *PurchaseRepository, orderedAt time.Time, shipping float64) *models.PurchaseOrder {
+	order := &models.PurchaseOrder{
+		AccountID:    1,
+		Supplier:     "Pharmacy",
+		Status:       "ordered",
+		OrderedAt:    orderedAt,
+		ShippingCost: shipping,
+		Items: []models.PurchaseOrderItem{
+			{ItemType: "progesterone", Quantity: 10, UnitCost: 4.5},
+			{ItemType: "syringe", Quantity: 100, UnitCost: 0.25},
+		},
+	}
+	if err := repo.Create(order); err != nil {
+		t.Fatalf("Failed to create purchase order: %v", err)
+	}
+	return order
+}
+
+func TestPurchaseRepository_CreateGetUpdate(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewPurchaseRepository(db)
+	order := createTestPurchaseOrder(t, repo, time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC), 5)
+
+	retrieved, err := repo.GetByID(order.ID, 1)
+	if err != nil {
+		t.Fatalf("Failed to get purchase order: %v", err)
+	}
+	if len(retrieved.Items) != 2 || retrieved.TotalCost() != 75 {
+		t.Errorf("Expected 2 items totalling 75, got %d items totalling %v", len(retrieved.Items), retrieved.TotalCost())
+	}
+	if _, err := repo.GetByID(order.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for other account, got %v", err)
+	}
+
+	retrieved.Supplier = "Other Pharmacy"
+	retrieved.Items = retrieved.Items[:1]
+	if err := repo.Update(retrieved); err != nil {
+		t.Fatalf("Failed to update purchase order: %v", err)
+	}
+	updated, _ := repo.GetByID(order.ID, 1)
+	if updated.Supplier != "Other Pharmacy" || len(updated.Items) != 1 {
+		t.Errorf("Expected updated supplier and 1 item, got %q and %d items", updated.Supplier, len(updated.Items))
+	}
+
+	orders, err := repo.List(1, "ordered")
+	if err != nil || len(orders) != 1 || len(orders[0].Items) != 1 {
+		t.Errorf("Expected 1 ordered order with its item, got %d (%v)", len(orders), err)
+	}
+}
+
+func TestPurchaseRepository_ReceivedOrdersAreLocked(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewPurchaseRepository(db)
+	order := createTestPurchaseOrder(t, repo, time.Now(), 0)
+
+	tx, err := db.BeginTx()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if err := MarkPurchaseOrderReceived(tx, order, time.Now()); err != nil {
+		t.Fatalf("Failed to mark received: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if err := repo.Update(order); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected received order update to fail, got %v", err)
+	}
+	if err := repo.Delete(order.ID, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected received order delete to fail, got %v", err)
+	}
+
+	tx, _ = db.BeginTx()
+	defer func() { _ = tx.Rollback() }()
+	if err := MarkPurchaseOrderReceived(tx, order, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected receiving twice to fail, got %v", err)
+	}
+}
+
+func TestPurchaseRepository_Spend(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewPurchaseRepository(db)
+	createTestPurchaseOrder(t, repo, time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC), 5)
+	createTestPurchaseOrder(t, repo, time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), 0)
+	createTestPurchaseOrder(t, repo, time.Date(2025, 2, 3, 0, 0, 0, 0, time.UTC), 0)
+	cancelled := createTestPurchaseOrder(t, repo, time.Date(2025, 2, 4, 0, 0, 0, 0, time.UTC), 0)
+	cancelled.Status = "cancelled"
+	if err := repo.Update(cancelled); err != nil {
+		t.Fatalf("Failed to cancel order: %v", err)
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+	byMonth, err := repo.SpendByMonth(1, start, end)
+	if err != nil {
+		t.Fatalf("Failed to get spend by month: %v", err)
+	}
+	if len(byMonth) != 2 {
+		t.Fatalf("Expected 2 months, got %d", len(byMonth))
+	}
+	if byMonth[0].Key != "2025-01" || byMonth[0].Orders != 2 || byMonth[0].ItemCost != 140 || byMonth[0].ShippingCost != 5 {
+		t.Errorf("Unexpected January spend: %+v", byMonth[0])
+	}
+	if byMonth[1].Key != "2025-02" || byMonth[1].Orders != 1 {
+		t.Errorf("Expected cancelled order excluded from February, got %+v", byMonth[1])
+	}
+
+	byItem, err := repo.SpendByItemType(1, start, end)
+	if err != nil {
+		t.Fatalf("Failed to get spend by item type: %v", err)
+	}
+	if len(byItem) != 2 || byItem[0].Key != "progesterone" || byItem[0].Quantity != 30 || byItem[0].ItemCost != 135 {
+		t.Errorf("Unexpected spend by item type: %+v", byItem)
+	}
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type QuotaRepository struct {
+	db *database.DB
+}
+
+func NewQuotaRepository(db *database.DB) *QuotaRepository {
+	return &QuotaRepository{db: db}
+}
+
+const quotaColumns = `id, account_id, max_members, attachment_storage_mb, backup_mb, updated_by, updated_at`
+
+// List retrieves every quota the admin has set, the site-wide one first
+func (r *QuotaRepository) List() ([]*models.ResourceQuota, error) {
+	return r.list(`SELECT ` + quotaColumns + ` FROM resource_quotas
+		ORDER BY account_id IS NOT NULL, account_id`)
+}
+
+// ForAccount retrieves the site-wide quota and the given account's own
+func (r *QuotaRepository) ForAccount(accountID int64) ([]*models.ResourceQuota, error) {
+	return r.list(`SELECT `+quotaColumns+` FROM resource_quotas
+		WHERE account_id IS NULL OR account_id = ?
+		ORDER BY account_id IS NOT NULL`, accountID)
+}
+
+func (r *QuotaRepository) list(query string, args ...interface{}) ([]*models.ResourceQuota, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quotas: %w", err)
+	}
+	defer rows.Close()
+
+	var quotas []*models.ResourceQuota
+	for rows.Next() {
+		var q models.ResourceQuota
+		if err := rows.Scan(&q.ID, &q.AccountID, &q.MaxMembers, &q.AttachmentStorageMB, &q.BackupMB, &q.UpdatedBy, &q.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan quota: %w", err)
+		}
+		quotas = append(quotas, &q)
+	}
+	return quotas, rows.Err()
+}
+
+// Set stores a quota, site-wide when its AccountID is null. A quota with no
+// limits at all is removed instead, so the account falls back to the
+// site-wide one.
+func (r *QuotaRepository) Set(quota *models.ResourceQuota) error {
+	if !quota.MaxMembers.Valid && !quota.AttachmentStorageMB.Valid && !quota.BackupMB.Valid {
+		_, err := r.db.Exec(`DELETE FROM resource_quotas WHERE COALESCE(account_id, 0) = ?`, quota.AccountID.Int64)
+		if err != nil {
+			return fmt.Errorf("failed to clear quota: %w", err)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE resource_quotas
+		SET max_members = ?, attachment_storage_mb = ?, backup_mb = ?, updated_by = ?, updated_at = ?
+		WHERE COALESCE(account_id, 0) = ?
+	`, quota.MaxMembers, quota.AttachmentStorageMB, quota.BackupMB, quota.UpdatedBy, now, quota.AccountID.Int64)
+	if err != nil {
+		return fmt.Errorf("failed to update quota: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if rows > 0 {
+		quota.UpdatedAt = now
+		return nil
+	}
+
+	err = r.db.QueryRow(`
+		INSERT INTO resource_quotas (account_id, max_members, attachment_storage_mb, backup_mb, updated_by, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, quota.AccountID, quota.MaxMembers, quota.AttachmentStorageMB, quota.BackupMB, quota.UpdatedBy, now).Scan(&quota.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create quota: %w", err)
+	}
+	quota.UpdatedAt = now
+	return nil
+}
+
+// usageQuery totals what quotas limit for each account
+const usageQuery = `
+	SELECT a.id, COALESCE(a.name, 'Account ' || a.id),
+	       (SELECT COUNT(*) FROM account_members WHERE account_id = a.id),
+	       (SELECT COUNT(*) FROM account_invitations
+	        WHERE account_id = a.id AND accepted_at IS NULL AND expires_at > ?),
+	       (SELECT COUNT(*) FROM attachments WHERE account_id = a.id),
+	       (SELECT COALESCE(SUM(size_bytes), 0) FROM attachments WHERE account_id = a.id)
+	FROM accounts a`
+
+// Usage totals what quotas limit for one account
+func (r *QuotaRepository) Usage(accountID int64) (*models.AccountUsage, error) {
+	var u models.AccountUsage
+	err := r.db.QueryRow(usageQuery+` WHERE a.id = ?`, time.Now(), accountID).
+		Scan(&u.AccountID, &u.Name, &u.Members, &u.PendingInvitations, &u.Attachments, &u.AttachmentBytes)
+	if err == sql.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account usage: %w", err)
+	}
+	return &u, nil
+}
+
+// ListUsage totals what quotas limit for every account
+func (r *QuotaRepository) ListUsage() ([]*models.AccountUsage, error) {
+	rows, err := r.db.Query(usageQuery+` ORDER BY a.id`, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []*models.AccountUsage
+	for rows.Next() {
+		var u models.AccountUsage
+		if err := rows.Scan(&u.AccountID, &u.Name, &u.Members, &u.PendingInvitations, &u.Attachments, &u.AttachmentBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan account usage: %w", err)
+		}
+		usage = append(usage, &u)
+	}
+	return usage, rows.Err()
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestQuotaRepository_Set(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+	repo := NewQuotaRepository(db)
+
+	site := &models.ResourceQuota{MaxMembers: sql.NullInt64{Int64: 5, Valid: true}}
+	if err := repo.Set(site); err != nil {
+		t.Fatalf("Set site-wide failed: %v", err)
+	}
+	own := &models.ResourceQuota{
+		AccountID:  sql.NullInt64{Int64: 1, Valid: true},
+		MaxMembers: sql.NullInt64{Int64: 2, Valid: true},
+		BackupMB:   sql.NullInt64{Int64: 10, Valid: true},
+	}
+	if err := repo.Set(own); err != nil {
+		t.Fatalf("Set account failed: %v", err)
+	}
+	// Setting it again replaces it rather than adding a row
+	own.BackupMB = sql.NullInt64{Int64: 20, Valid: true}
+	if err := repo.Set(own); err != nil {
+		t.Fatalf("Update account failed: %v", err)
+	}
+
+	quotas, err := repo.ForAccount(1)
+	if err != nil {
+		t.Fatalf("ForAccount failed: %v", err)
+	}
+	if len(quotas) != 2 || quotas[0].AccountID.Valid || quotas[1].BackupMB.Int64 != 20 || quotas[1].AttachmentStorageMB.Valid {
+		t.Fatalf("Expected the site-wide quota then the account's updated one, got %+v", quotas)
+	}
+
+	// A quota with no limits left is removed
+	if err := repo.Set(&models.ResourceQuota{AccountID: sql.NullInt64{Int64: 1, Valid: true}}); err != nil {
+		t.Fatalf("Clear account failed: %v", err)
+	}
+	quotas, err = repo.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(quotas) != 1 || quotas[0].AccountID.Valid {
+		t.Errorf("Expected only the site-wide quota left, got %+v", quotas)
+	}
+}
+
+func TestQuotaRepository_Usage(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO attachments (account_id, original_filename, content_type, size_bytes, sha256, storage_key)
+		VALUES (1, 'a.png', 'image/png', 1000, 'aa', 'aa.png'), (1, 'b.png', 'image/png', 500, 'bb', 'bb.png')
+	`); err != nil {
+		t.Fatalf("Failed to seed attachments: %v", err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO account_invitations (account_id, email, token_hash, invited_by, role, expires_at)
+		VALUES (1, 'new@example.com', 'h1', 1, 'member', ?), (1, 'old@example.com', 'h2', 1, 'member', ?)
+	`, time.Now().Add(time.Hour), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("Failed to seed invitations: %v", err)
+	}
+
+	usage, err := NewQuotaRepository(db).Usage(1)
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if usage.Name != "Test Account" || usage.Members != 1 || usage.PendingInvitations != 1 ||
+		usage.Attachments != 2 || usage.AttachmentBytes != 1500 {
+		t.Errorf("Unexpected usage, expired invitations shouldn't count: %+v", usage)
+	}
+
+	if _, err := NewQuotaRepository(db).Usage(99); err != ErrAccountNotFound {
+		t.Errorf("Expected ErrAccountNotFound, got %v", err)
+	}
+}
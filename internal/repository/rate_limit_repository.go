@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+)
+
+// RateLimitRepository keeps rate limit counts in the database, so they
+// survive restarts and are shared by every server using it. It satisfies
+// middleware.RateLimitStore.
+type RateLimitRepository struct {
+	db *database.DB
+}
+
+func NewRateLimitRepository(db *database.DB) *RateLimitRepository {
+	return &RateLimitRepository{db: db}
+}
+
+// Hit counts a request against key and returns the count for its current
+// window and when the window ends. The check and the increment are one
+// statement, so concurrent requests can't both see the last free slot.
+func (r *RateLimitRepository) Hit(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	nowMs := now.UnixMilli()
+	var hits int
+	var endMs int64
+	err := r.db.WithContext(ctx).QueryRow(`
+		INSERT INTO rate_limits (key, window_end, hits) VALUES (?, ?, 1)
+		ON CONFLICT (key) DO UPDATE SET
+			hits = CASE WHEN rate_limits.window_end > ? THEN rate_limits.hits + 1 ELSE 1 END,
+			window_end = CASE WHEN rate_limits.window_end > ? THEN rate_limits.window_end ELSE excluded.window_end END
+		RETURNING hits, window_end
+	`, key, now.Add(window).UnixMilli(), nowMs, nowMs).Scan(&hits, &endMs)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count rate limit hit: %w", err)
+	}
+	return hits, time.UnixMilli(endMs), nil
+}
+
+// Prune deletes the counts of windows that had ended by now
+func (r *RateLimitRepository) Prune(now time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM rate_limits WHERE window_end <= ?`, now.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune rate limits: %w", err)
+	}
+	return result.RowsAffected()
+}
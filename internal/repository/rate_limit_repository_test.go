@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimitRepository_Hit(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewRateLimitRepository(db)
+	ctx := context.Background()
+	start := time.Now().Truncate(time.Millisecond)
+
+	for i := 1; i <= 3; i++ {
+		hits, reset, err := repo.Hit(ctx, "login:ip:10.0.0.1", time.Minute, start.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatalf("Failed to hit: %v", err)
+		}
+		if hits != i {
+			t.Errorf("Hit %d: expected count %d, got %d", i, i, hits)
+		}
+		// The window runs from the first hit
+		if want := start.Add(time.Second + time.Minute); !reset.Equal(want) {
+			t.Errorf("Hit %d: expected reset at %v, got %v", i, want, reset)
+		}
+	}
+
+	// Other keys have their own counts
+	if hits, _, _ := repo.Hit(ctx, "login:ip:10.0.0.2", time.Minute, start); hits != 1 {
+		t.Errorf("Expected a separate count for another key, got %d", hits)
+	}
+
+	// A hit after the window ended starts a new one
+	later := start.Add(2 * time.Minute)
+	hits, reset, err := repo.Hit(ctx, "login:ip:10.0.0.1", time.Minute, later)
+	if err != nil || hits != 1 || !reset.Equal(later.Add(time.Minute)) {
+		t.Errorf("Expected a new window, got %d %v %v", hits, reset, err)
+	}
+
+	if n, err := repo.Prune(later); err != nil || n != 1 {
+		t.Errorf("Expected to prune 1 expired count, got %d %v", n, err)
+	}
+}
@@ -0,0 +1,781 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The kinds of value a report field holds
+const (
+	ReportText   = "text"
+	ReportNumber = "number"
+	ReportBool   = "bool"
+	ReportTime   = "time"
+	ReportDate   = "date" // A calendar day, kept as midnight UTC
+)
+
+// Limits on what one report may ask for
+const (
+	maxReportSections = 6
+	maxReportColumns  = 20
+	maxReportFilters  = 10
+	maxReportDays     = 3660
+	// MaxReportRows is how many records a section may read before the
+	// report is refused as too big
+	MaxReportRows = 10000
+)
+
+// ErrReportTooBig is returned for a section with more than MaxReportRows
+// records
+var ErrReportTooBig = fmt.Errorf("more than %d records; narrow the date range or add filters", MaxReportRows)
+
+// ReportField is a column of an entity that a report can show, filter or
+// group by. The SQL it reads is fixed here; nothing from a report
+// definition but its name is ever compared against it.
+type ReportField struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Kind  string `json:"kind"`
+	expr  string
+}
+
+// ReportEntity is a kind of record a report can list
+type ReportEntity struct {
+	Name   string        `json:"name"`
+	Label  string        `json:"label"`
+	Fields []ReportField `json:"fields"`
+	from   string
+	scope  string // Keeps one account's records; takes the account ID
+	// symptoms marks an entity of symptom logs, which are kept to those the
+	// viewer may see
+	symptoms bool
+}
+
+// reportEntities is everything a report can be built from. The first field
+// of each is the one its date range applies to.
+var reportEntities = []ReportEntity{
+	{
+		Name:  "injections",
+		Label: "Injections",
+		from: `injections i
+			JOIN courses c ON c.id = i.course_id
+			LEFT JOIN compounds m ON m.id = c.compound_id
+			LEFT JOIN users u ON u.id = i.administered_by`,
+		scope: "c.account_id = ? AND i.voided_at IS NULL",
+		Fields: []ReportField{
+			{Name: "timestamp", Label: "Date", Kind: ReportTime, expr: "i.timestamp"},
+			{Name: "course", Label: "Course", Kind: ReportText, expr: "c.name"},
+			{Name: "compound", Label: "Compound", Kind: ReportText, expr: "m.name"},
+			{Name: "side", Label: "Side", Kind: ReportText, expr: "i.side"},
+			{Name: "dose_ml", Label: "Dose (mL)", Kind: ReportNumber, expr: "COALESCE(i.dose_ml, " + strconv.FormatFloat(DefaultDoseML, 'f', -1, 64) + ")"},
+			{Name: "pain_level", Label: "Pain Level", Kind: ReportNumber, expr: "i.pain_level"},
+			{Name: "has_knots", Label: "Has Knots", Kind: ReportBool, expr: "i.has_knots"},
+			{Name: "site_reaction", Label: "Site Reaction", Kind: ReportText, expr: "i.site_reaction"},
+			{Name: "administered_by", Label: "Administered By", Kind: ReportText, expr: "u.username"},
+			{Name: "notes", Label: "Notes", Kind: ReportText, expr: "i.notes"},
+		},
+	},
+	{
+		Name:  "symptoms",
+		Label: "Symptoms",
+		from: `symptom_logs s
+			JOIN courses c ON c.id = s.course_id
+			LEFT JOIN users u ON u.id = s.logged_by`,
+		scope:    "c.account_id = ?",
+		symptoms: true,
+		Fields: []ReportField{
+			{Name: "timestamp", Label: "Date", Kind: ReportTime, expr: "s.timestamp"},
+			{Name: "course", Label: "Course", Kind: ReportText, expr: "c.name"},
+			{Name: "pain_level", Label: "Pain Level", Kind: ReportNumber, expr: "s.pain_level"},
+			{Name: "pain_location", Label: "Pain Location", Kind: ReportText, expr: "s.pain_location"},
+			{Name: "pain_type", Label: "Pain Type", Kind: ReportText, expr: "s.pain_type"},
+			{Name: "symptoms", Label: "Symptoms", Kind: ReportText, expr: "s.symptoms"},
+			{Name: "logged_by", Label: "Logged By", Kind: ReportText, expr: "u.username"},
+			{Name: "notes", Label: "Notes", Kind: ReportText, expr: "s.notes"},
+		},
+	},
+	{
+		Name:  "medications",
+		Label: "Medications",
+		from: `medication_logs ml
+			JOIN medications m ON m.id = ml.medication_id
+			LEFT JOIN users u ON u.id = ml.logged_by`,
+		scope: "m.account_id = ?",
+		Fields: []ReportField{
+			{Name: "timestamp", Label: "Date", Kind: ReportTime, expr: "ml.timestamp"},
+			{Name: "medication", Label: "Medication", Kind: ReportText, expr: "m.name"},
+			{Name: "dosage", Label: "Dosage", Kind: ReportText, expr: "m.dosage"},
+			{Name: "taken", Label: "Taken", Kind: ReportBool, expr: "ml.taken"},
+			{Name: "logged_by", Label: "Logged By", Kind: ReportText, expr: "u.username"},
+			{Name: "notes", Label: "Notes", Kind: ReportText, expr: "ml.notes"},
+		},
+	},
+	{
+		Name:  "checkins",
+		Label: "Check-ins",
+		from: `wellness_checkins w
+			LEFT JOIN users u ON u.id = w.user_id`,
+		scope: "w.account_id = ?",
+		Fields: []ReportField{
+			{Name: "date", Label: "Date", Kind: ReportDate, expr: "w.date"},
+			{Name: "member", Label: "Member", Kind: ReportText, expr: "u.username"},
+			{Name: "mood", Label: "Mood", Kind: ReportNumber, expr: "w.mood"},
+			{Name: "energy", Label: "Energy", Kind: ReportNumber, expr: "w.energy"},
+			{Name: "sleep_hours", Label: "Sleep (hours)", Kind: ReportNumber, expr: "w.sleep_hours"},
+			{Name: "temperature", Label: "Temperature (C)", Kind: ReportNumber, expr: "w.temperature"},
+			{Name: "weight", Label: "Weight (kg)", Kind: ReportNumber, expr: "w.weight"},
+			{Name: "notes", Label: "Notes", Kind: ReportText, expr: "w.notes"},
+		},
+	},
+	{
+		Name:  "journal",
+		Label: "Journal",
+		from: `journal_entries j
+			LEFT JOIN users u ON u.id = j.author_id
+			LEFT JOIN courses c ON c.id = j.course_id`,
+		scope: "j.account_id = ?",
+		Fields: []ReportField{
+			{Name: "written", Label: "Written", Kind: ReportTime, expr: "j.created_at"},
+			{Name: "entry_date", Label: "Date", Kind: ReportDate, expr: "j.entry_date"},
+			{Name: "author", Label: "Author", Kind: ReportText, expr: "u.username"},
+			{Name: "course", Label: "Course", Kind: ReportText, expr: "c.name"},
+			{Name: "title", Label: "Title", Kind: ReportText, expr: "j.title"},
+			{Name: "body", Label: "Body", Kind: ReportText, expr: "j.body"},
+			{Name: "pinned", Label: "Pinned", Kind: ReportBool, expr: "j.is_pinned"},
+		},
+	},
+	{
+		Name:  "labs",
+		Label: "Lab results",
+		from: `lab_results l
+			LEFT JOIN courses c ON c.id = l.course_id`,
+		scope: "l.account_id = ?",
+		Fields: []ReportField{
+			{Name: "drawn", Label: "Drawn", Kind: ReportTime, expr: "l.drawn_at"},
+			{Name: "analyte", Label: "Analyte", Kind: ReportText, expr: "l.analyte"},
+			{Name: "value", Label: "Value", Kind: ReportNumber, expr: "l.value"},
+			{Name: "unit", Label: "Unit", Kind: ReportText, expr: "l.unit"},
+			{Name: "reference_low", Label: "Reference Low", Kind: ReportNumber, expr: "l.reference_low"},
+			{Name: "reference_high", Label: "Reference High", Kind: ReportNumber, expr: "l.reference_high"},
+			{Name: "course", Label: "Course", Kind: ReportText, expr: "c.name"},
+			{Name: "notes", Label: "Notes", Kind: ReportText, expr: "l.notes"},
+		},
+	},
+	{
+		Name:  "missed",
+		Label: "Missed Injections",
+		from: `missed_injections x
+			JOIN courses c ON c.id = x.course_id
+			LEFT JOIN users u ON u.id = x.annotated_by`,
+		scope: "c.account_id = ?",
+		Fields: []ReportField{
+			{Name: "due", Label: "Due", Kind: ReportTime, expr: "x.due_at"},
+			{Name: "course", Label: "Course", Kind: ReportText, expr: "c.name"},
+			{Name: "reason", Label: "Reason", Kind: ReportText, expr: "x.reason"},
+			{Name: "notes", Label: "Notes", Kind: ReportText, expr: "x.note"},
+			{Name: "annotated_by", Label: "Annotated By", Kind: ReportText, expr: "u.username"},
+		},
+	},
+	{
+		Name:  "trips",
+		Label: "Trips",
+		from:  `trips t`,
+		scope: "t.account_id = ?",
+		Fields: []ReportField{
+			{Name: "starts", Label: "Leaving", Kind: ReportTime, expr: "t.starts_at"},
+			{Name: "ends", Label: "Back", Kind: ReportTime, expr: "t.ends_at"},
+			{Name: "destination", Label: "Destination", Kind: ReportText, expr: "t.destination"},
+			{Name: "timezone", Label: "Timezone", Kind: ReportText, expr: "t.timezone"},
+			{Name: "home_timezone", Label: "Home Timezone", Kind: ReportText, expr: "t.home_timezone"},
+			{Name: "shift", Label: "Shift", Kind: ReportText, expr: "t.shift"},
+			{Name: "notes", Label: "Notes", Kind: ReportText, expr: "t.notes"},
+		},
+	},
+}
+
+// ReportEntities lists what reports can be built from
+func ReportEntities() []ReportEntity {
+	return reportEntities
+}
+
+func reportEntity(name string) (*ReportEntity, bool) {
+	for i := range reportEntities {
+		if reportEntities[i].Name == name {
+			return &reportEntities[i], true
+		}
+	}
+	return nil, false
+}
+
+// dated is the field the report's date range applies to
+func (e *ReportEntity) dated() *ReportField {
+	return &e.Fields[0]
+}
+
+func (e *ReportEntity) field(name string) (*ReportField, bool) {
+	for i := range e.Fields {
+		if e.Fields[i].Name == name {
+			return &e.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// ReportDefinition is what a saved report is made of: sections, each
+// listing one entity, over a shared date range
+type ReportDefinition struct {
+	DateRange ReportDateRange `json:"date_range"`
+	Sections  []ReportSection `json:"sections"`
+}
+
+// ReportDateRange says which days a report covers. Mode is last_days (the
+// Days up to and including today), this_month, last_month, this_year,
+// custom (Start to End, both YYYY-MM-DD and included) or all.
+type ReportDateRange struct {
+	Mode  string `json:"mode"`
+	Days  int    `json:"days,omitempty"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// ReportSection lists one entity's records. Grouped by a field, or by the
+// day, week or month of its date, every column needs an aggregate and
+// there is a row per group; otherwise there is a row per record, newest
+// first.
+type ReportSection struct {
+	Entity  string         `json:"entity"`
+	Columns []ReportColumn `json:"columns"`
+	GroupBy string         `json:"group_by,omitempty"`
+	Filters []ReportFilter `json:"filters,omitempty"`
+}
+
+// ReportColumn is a field to show, or with Aggregate (count, sum, avg, min
+// or max) a summary of it for each group. A count with no field counts
+// records.
+type ReportColumn struct {
+	Field     string `json:"field,omitempty"`
+	Aggregate string `json:"aggregate,omitempty"`
+}
+
+// ReportFilter keeps the records whose Field compares to Value by Op: eq,
+// ne, lt, lte, gt, gte or contains. Text compares without regard to case.
+type ReportFilter struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// The date buckets a section can be grouped by
+var reportBuckets = map[string]string{"day": "Day", "week": "Week", "month": "Month"}
+
+// The operators each kind of field can be filtered with, as SQL
+var reportOps = map[string]map[string]string{
+	ReportText:   {"eq": "=", "ne": "<>", "contains": "LIKE"},
+	ReportNumber: {"eq": "=", "ne": "<>", "lt": "<", "lte": "<=", "gt": ">", "gte": ">="},
+	ReportBool:   {"eq": "=", "ne": "<>"},
+}
+
+// The aggregates each kind of field can be summarized with
+var reportAggregates = map[string][]string{
+	ReportText:   {"count"},
+	ReportBool:   {"count"},
+	ReportNumber: {"count", "sum", "avg", "min", "max"},
+	ReportTime:   {"count", "min", "max"},
+	ReportDate:   {"count", "min", "max"},
+}
+
+// Validate checks a definition against the entities and fields reports can
+// use, returning an error fit to show whoever wrote it
+func (d *ReportDefinition) Validate() error {
+	if _, _, err := d.DateRange.Bounds(time.Now(), time.UTC); err != nil {
+		return err
+	}
+	if len(d.Sections) == 0 {
+		return errors.New("a report needs at least one section")
+	}
+	if len(d.Sections) > maxReportSections {
+		return fmt.Errorf("a report can have at most %d sections", maxReportSections)
+	}
+	for i := range d.Sections {
+		if err := d.Sections[i].validate(); err != nil {
+			return fmt.Errorf("section %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (s *ReportSection) validate() error {
+	entity, ok := reportEntity(s.Entity)
+	if !ok {
+		names := make([]string, len(reportEntities))
+		for i, e := range reportEntities {
+			names[i] = e.Name
+		}
+		return fmt.Errorf("unknown entity %q; use %s", s.Entity, strings.Join(names, ", "))
+	}
+
+	if len(s.Columns) == 0 {
+		return errors.New("choose at least one column")
+	}
+	if len(s.Columns) > maxReportColumns {
+		return fmt.Errorf("at most %d columns", maxReportColumns)
+	}
+	if s.GroupBy != "" && reportBuckets[s.GroupBy] == "" {
+		if _, ok := entity.field(s.GroupBy); !ok {
+			return fmt.Errorf("can't group by %q; use day, week, month or one of the entity's fields", s.GroupBy)
+		}
+	}
+	for _, c := range s.Columns {
+		if c.Field == "" {
+			if c.Aggregate != "count" {
+				return errors.New("a column needs a field unless it counts records")
+			}
+			if s.GroupBy == "" {
+				return errors.New("counting records needs the section to be grouped")
+			}
+			continue
+		}
+		field, ok := entity.field(c.Field)
+		if !ok {
+			return fmt.Errorf("unknown column %q", c.Field)
+		}
+		if s.GroupBy == "" {
+			if c.Aggregate != "" {
+				return fmt.Errorf("column %q: aggregates need the section to be grouped", c.Field)
+			}
+			continue
+		}
+		if c.Aggregate == "" {
+			return fmt.Errorf("column %q: grouped sections need an aggregate for each column", c.Field)
+		}
+		allowed := reportAggregates[field.Kind]
+		if !containsString(allowed, c.Aggregate) {
+			return fmt.Errorf("column %q: aggregate must be one of %s", c.Field, strings.Join(allowed, ", "))
+		}
+	}
+
+	if len(s.Filters) > maxReportFilters {
+		return fmt.Errorf("at most %d filters", maxReportFilters)
+	}
+	for _, f := range s.Filters {
+		if _, _, err := entity.filterCondition(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bounds returns the start of the first day a range covers and the start
+// of the day after its last, in loc, as of now. Both are zero for all.
+func (d ReportDateRange) Bounds(now time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	tomorrow := today.AddDate(0, 0, 1)
+	switch d.Mode {
+	case "last_days":
+		if d.Days < 1 || d.Days > maxReportDays {
+			return time.Time{}, time.Time{}, fmt.Errorf("days must be between 1 and %d", maxReportDays)
+		}
+		return tomorrow.AddDate(0, 0, -d.Days), tomorrow, nil
+	case "this_month":
+		return time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc), tomorrow, nil
+	case "last_month":
+		first := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+		return first.AddDate(0, -1, 0), first, nil
+	case "this_year":
+		return time.Date(local.Year(), time.January, 1, 0, 0, 0, 0, loc), tomorrow, nil
+	case "custom":
+		start, err := time.ParseInLocation("2006-01-02", d.Start, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("start must be a date in the format YYYY-MM-DD")
+		}
+		end, err := time.ParseInLocation("2006-01-02", d.End, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("end must be a date in the format YYYY-MM-DD")
+		}
+		if end.Before(start) {
+			return time.Time{}, time.Time{}, errors.New("end must not be before start")
+		}
+		return start, end.AddDate(0, 0, 1), nil
+	case "all":
+		return time.Time{}, time.Time{}, nil
+	}
+	return time.Time{}, time.Time{}, errors.New("date range mode must be last_days, this_month, last_month, this_year, custom or all")
+}
+
+// filterCondition turns a filter into an SQL condition on a fixed field
+// expression, with the value only ever passed as an argument
+func (e *ReportEntity) filterCondition(f ReportFilter) (string, interface{}, error) {
+	field, ok := e.field(f.Field)
+	if !ok {
+		return "", nil, fmt.Errorf("can't filter on unknown field %q", f.Field)
+	}
+	op, ok := reportOps[field.Kind][f.Op]
+	if !ok {
+		if reportOps[field.Kind] == nil {
+			return "", nil, fmt.Errorf("can't filter on %q; the date range covers it", f.Field)
+		}
+		ops := make([]string, 0, len(reportOps[field.Kind]))
+		for name := range reportOps[field.Kind] {
+			ops = append(ops, name)
+		}
+		sort.Strings(ops)
+		return "", nil, fmt.Errorf("filter on %q: op must be one of %s", f.Field, strings.Join(ops, ", "))
+	}
+
+	switch field.Kind {
+	case ReportNumber:
+		v, err := strconv.ParseFloat(strings.TrimSpace(f.Value), 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("filter on %q: %q is not a number", f.Field, f.Value)
+		}
+		return field.expr + " " + op + " ?", v, nil
+	case ReportBool:
+		v, err := strconv.ParseBool(strings.TrimSpace(f.Value))
+		if err != nil {
+			return "", nil, fmt.Errorf("filter on %q: value must be true or false", f.Field)
+		}
+		return "COALESCE(" + field.expr + ", FALSE) " + op + " ?", v, nil
+	}
+	value := strings.ToLower(f.Value)
+	if f.Op == "contains" {
+		return "LOWER(COALESCE(" + field.expr + ", '')) LIKE ? ESCAPE '!'", "%" + likeEscaper.Replace(value) + "%", nil
+	}
+	return "LOWER(COALESCE(" + field.expr + ", '')) " + op + " ?", value, nil
+}
+
+// ReportTable is a section of a report as run. Rows hold nil, string,
+// float64, bool or time.Time, in the order of Columns.
+type ReportTable struct {
+	Entity  string
+	Label   string
+	Columns []ReportTableColumn
+	Rows    [][]interface{}
+}
+
+// ReportTableColumn heads a column of a report table: Label is the field's
+// (or date bucket's) English name, and Aggregate how it was summarized
+type ReportTableColumn struct {
+	Label     string
+	Aggregate string
+	Kind      string
+}
+
+// Run runs each of a definition's sections for an account over start up to
+// end, leaving out the symptom logs viewer may not see. Either bound may be
+// zero to leave that end open. Days and weeks are grouped in loc, weeks
+// starting on Monday.
+func (r *SavedReportRepository) Run(def *ReportDefinition, accountID int64, viewer SymptomViewer, start, end time.Time, loc *time.Location) ([]*ReportTable, error) {
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+
+	tables := make([]*ReportTable, 0, len(def.Sections))
+	for i := range def.Sections {
+		table, err := r.runSection(&def.Sections[i], accountID, viewer, start, end, loc)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// reportQuery is the SQL for a section, reading fields in order
+type reportQuery struct {
+	sql    string
+	args   []interface{}
+	fields []*ReportField
+}
+
+// buildReportQuery builds a validated section's query. Only the fixed
+// expressions of the entity's fields go into the SQL; filter values and
+// dates are arguments.
+func buildReportQuery(s *ReportSection, accountID int64, viewer SymptomViewer, start, end time.Time, loc *time.Location) (*reportQuery, error) {
+	entity, ok := reportEntity(s.Entity)
+	if !ok {
+		return nil, fmt.Errorf("unknown entity %q", s.Entity)
+	}
+
+	q := &reportQuery{}
+	seen := map[string]bool{}
+	need := func(name string) {
+		if field, ok := entity.field(name); ok && !seen[name] {
+			seen[name] = true
+			q.fields = append(q.fields, field)
+		}
+	}
+	when := entity.dated()
+	need(when.Name)
+	if reportBuckets[s.GroupBy] == "" {
+		need(s.GroupBy)
+	}
+	for _, c := range s.Columns {
+		need(c.Field)
+	}
+
+	exprs := make([]string, len(q.fields))
+	for i, f := range q.fields {
+		exprs[i] = f.expr
+	}
+	conditions := []string{entity.scope}
+	q.args = []interface{}{accountID}
+	if entity.symptoms {
+		visible, visibleArgs := viewer.Condition("s")
+		conditions = append(conditions, visible)
+		q.args = append(q.args, visibleArgs...)
+	}
+
+	bound := func(t time.Time) interface{} {
+		if when.Kind == ReportDate {
+			return CheckInDate(t.In(loc))
+		}
+		return t.UTC()
+	}
+	if !start.IsZero() {
+		conditions = append(conditions, when.expr+" >= ?")
+		q.args = append(q.args, bound(start))
+	}
+	if !end.IsZero() {
+		conditions = append(conditions, when.expr+" < ?")
+		q.args = append(q.args, bound(end))
+	}
+	for _, f := range s.Filters {
+		condition, arg, err := entity.filterCondition(f)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		q.args = append(q.args, arg)
+	}
+
+	q.sql = `SELECT ` + strings.Join(exprs, ", ") + ` FROM ` + entity.from +
+		` WHERE ` + strings.Join(conditions, " AND ") +
+		` ORDER BY ` + when.expr + ` DESC LIMIT ?`
+	// One extra to tell whether there are too many
+	q.args = append(q.args, MaxReportRows+1)
+	return q, nil
+}
+
+func (r *SavedReportRepository) runSection(s *ReportSection, accountID int64, viewer SymptomViewer, start, end time.Time, loc *time.Location) (*ReportTable, error) {
+	q, err := buildReportQuery(s, accountID, viewer, start, end, loc)
+	if err != nil {
+		return nil, err
+	}
+	entity, _ := reportEntity(s.Entity)
+
+	rows, err := r.db.Query(q.sql, q.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run report: %w", err)
+	}
+	defer rows.Close()
+
+	// Each record as a map of field name to value
+	var records []map[string]interface{}
+	for rows.Next() {
+		dest := make([]interface{}, len(q.fields))
+		for i, f := range q.fields {
+			switch f.Kind {
+			case ReportNumber:
+				dest[i] = new(sql.NullFloat64)
+			case ReportBool:
+				dest[i] = new(sql.NullBool)
+			case ReportTime, ReportDate:
+				dest[i] = new(sql.NullTime)
+			default:
+				dest[i] = new(sql.NullString)
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan report row: %w", err)
+		}
+		record := make(map[string]interface{}, len(q.fields))
+		for i, f := range q.fields {
+			record[f.Name] = reportValue(dest[i], f.Kind, loc)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read report rows: %w", err)
+	}
+	if len(records) > MaxReportRows {
+		return nil, fmt.Errorf("%s: %w", entity.Label, ErrReportTooBig)
+	}
+
+	table := &ReportTable{Entity: entity.Name, Label: entity.Label}
+	if s.GroupBy == "" {
+		for _, c := range s.Columns {
+			field, _ := entity.field(c.Field)
+			table.Columns = append(table.Columns, ReportTableColumn{Label: field.Label, Kind: field.Kind})
+		}
+		for _, record := range records {
+			row := make([]interface{}, len(s.Columns))
+			for i, c := range s.Columns {
+				row[i] = record[c.Field]
+			}
+			table.Rows = append(table.Rows, row)
+		}
+		return table, nil
+	}
+
+	groupRecords(table, entity, s, records, loc)
+	return table, nil
+}
+
+// reportValue unwraps a scanned value, with times in loc and calendar days
+// left as they are stored
+func reportValue(v interface{}, kind string, loc *time.Location) interface{} {
+	switch v := v.(type) {
+	case *sql.NullFloat64:
+		if v.Valid {
+			return v.Float64
+		}
+	case *sql.NullBool:
+		if v.Valid {
+			return v.Bool
+		}
+	case *sql.NullTime:
+		if v.Valid && kind == ReportDate {
+			return CheckInDate(v.Time.UTC())
+		}
+		if v.Valid {
+			return v.Time.In(loc)
+		}
+	case *sql.NullString:
+		if v.Valid {
+			return v.String
+		}
+	}
+	return nil
+}
+
+// groupRecords fills in a grouped section's table: a row per group, in
+// order of the group, with each column aggregated over its records
+func groupRecords(table *ReportTable, entity *ReportEntity, s *ReportSection, records []map[string]interface{}, loc *time.Location) {
+	when := entity.dated()
+	if label := reportBuckets[s.GroupBy]; label != "" {
+		table.Columns = append(table.Columns, ReportTableColumn{Label: label, Kind: ReportDate})
+	} else {
+		field, _ := entity.field(s.GroupBy)
+		table.Columns = append(table.Columns, ReportTableColumn{Label: field.Label, Kind: field.Kind})
+	}
+	for _, c := range s.Columns {
+		column := ReportTableColumn{Label: "Records", Aggregate: c.Aggregate, Kind: ReportNumber}
+		if field, ok := entity.field(c.Field); ok {
+			column.Label = field.Label
+			if c.Aggregate == "min" || c.Aggregate == "max" {
+				column.Kind = field.Kind
+			}
+		}
+		table.Columns = append(table.Columns, column)
+	}
+
+	var keys []interface{}
+	groups := map[interface{}][]map[string]interface{}{}
+	for _, record := range records {
+		var key interface{}
+		if reportBuckets[s.GroupBy] != "" {
+			if t, ok := record[when.Name].(time.Time); ok {
+				key = reportBucket(t, s.GroupBy, when.Kind, loc)
+			}
+		} else {
+			key = record[s.GroupBy]
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], record)
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return reportLess(keys[i], keys[j]) })
+
+	for _, key := range keys {
+		row := []interface{}{key}
+		for _, c := range s.Columns {
+			row = append(row, aggregate(groups[key], c))
+		}
+		table.Rows = append(table.Rows, row)
+	}
+}
+
+// reportBucket returns the first day of the day, week or month t falls in.
+// Calendar days are bucketed as they are, as midnight UTC.
+func reportBucket(t time.Time, bucket, kind string, loc *time.Location) time.Time {
+	if kind == ReportDate {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	switch bucket {
+	case "week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case "month":
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day
+}
+
+// reportLess orders group keys: nothing first, then by value
+func reportLess(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	switch a := a.(type) {
+	case time.Time:
+		return a.Before(b.(time.Time))
+	case float64:
+		return a < b.(float64)
+	case bool:
+		return !a && b.(bool)
+	case string:
+		return a < b.(string)
+	}
+	return false
+}
+
+// aggregate summarizes a column over a group's records. Records without a
+// value are left out; a sum, average, min or max of none is nil.
+func aggregate(records []map[string]interface{}, c ReportColumn) interface{} {
+	if c.Field == "" {
+		return float64(len(records))
+	}
+
+	var count int
+	var sum float64
+	var best interface{}
+	for _, record := range records {
+		v := record[c.Field]
+		if v == nil {
+			continue
+		}
+		count++
+		if f, ok := v.(float64); ok {
+			sum += f
+		}
+		if best == nil ||
+			(c.Aggregate == "min" && reportLess(v, best)) ||
+			(c.Aggregate == "max" && reportLess(best, v)) {
+			best = v
+		}
+	}
+
+	switch c.Aggregate {
+	case "count":
+		return float64(count)
+	case "sum":
+		if count == 0 {
+			return nil
+		}
+		return sum
+	case "avg":
+		if count == 0 {
+			return nil
+		}
+		return sum / float64(count)
+	}
+	return best
+}
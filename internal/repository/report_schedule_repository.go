@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type ReportScheduleRepository struct {
+	db *database.DB
+}
+
+func NewReportScheduleRepository(db *database.DB) *ReportScheduleRepository {
+	return &ReportScheduleRepository{db: db}
+}
+
+const reportScheduleColumns = `id, account_id, user_id, name, frequency, day_of_week, day_of_month, hour,
+		       course_id, include_pdf, saved_report_id, is_enabled, next_run_at, last_sent_at, last_error, created_at, updated_at`
+
+// Create creates a new report schedule
+func (r *ReportScheduleRepository) Create(schedule *models.ReportSchedule) error {
+	query := `
+		INSERT INTO report_schedules (account_id, user_id, name, frequency, day_of_week, day_of_month, hour,
+			course_id, include_pdf, saved_report_id, is_enabled, next_run_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	now := time.Now()
+	var id int64
+	err := r.db.QueryRow(query,
+		schedule.AccountID,
+		schedule.UserID,
+		schedule.Name,
+		schedule.Frequency,
+		schedule.DayOfWeek,
+		schedule.DayOfMonth,
+		schedule.Hour,
+		schedule.CourseID,
+		schedule.IncludePDF,
+		schedule.SavedReportID,
+		schedule.IsEnabled,
+		schedule.NextRunAt.UTC(),
+		now,
+		now,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to create report schedule: %w", err)
+	}
+
+	schedule.ID = id
+	schedule.CreatedAt = now
+	schedule.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves one of a user's report schedules
+func (r *ReportScheduleRepository) GetByID(id, accountID, userID int64) (*models.ReportSchedule, error) {
+	query := `SELECT ` + reportScheduleColumns + ` FROM report_schedules WHERE id = ? AND account_id = ? AND user_id = ?`
+	rows, err := r.db.Query(query, id, accountID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report schedule: %w", err)
+	}
+	defer rows.Close()
+
+	schedules, err := r.scanSchedules(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, ErrNotFound
+	}
+	return schedules[0], nil
+}
+
+// List retrieves a user's report schedules
+func (r *ReportScheduleRepository) List(accountID, userID int64) ([]*models.ReportSchedule, error) {
+	query := `SELECT ` + reportScheduleColumns + ` FROM report_schedules WHERE account_id = ? AND user_id = ? ORDER BY id ASC`
+	rows, err := r.db.Query(query, accountID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query report schedules: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanSchedules(rows)
+}
+
+// ListDue retrieves the enabled schedules, across all accounts that aren't
+// suspended, that should have been sent by now. Only the report scheduler
+// should use this.
+func (r *ReportScheduleRepository) ListDue(now time.Time) ([]*models.ReportSchedule, error) {
+	query := `SELECT ` + reportScheduleColumns + ` FROM report_schedules WHERE is_enabled = TRUE AND next_run_at <= ? AND ` + AccountNotSuspended("account_id") + ` ORDER BY next_run_at ASC`
+	rows, err := r.db.Query(query, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due report schedules: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanSchedules(rows)
+}
+
+// Update updates a report schedule's settings and next run
+func (r *ReportScheduleRepository) Update(schedule *models.ReportSchedule) error {
+	query := `
+		UPDATE report_schedules
+		SET name = ?, frequency = ?, day_of_week = ?, day_of_month = ?, hour = ?,
+			course_id = ?, include_pdf = ?, saved_report_id = ?, is_enabled = ?, next_run_at = ?
+		WHERE id = ? AND account_id = ? AND user_id = ?
+	`
+	result, err := r.db.Exec(query,
+		schedule.Name,
+		schedule.Frequency,
+		schedule.DayOfWeek,
+		schedule.DayOfMonth,
+		schedule.Hour,
+		schedule.CourseID,
+		schedule.IncludePDF,
+		schedule.SavedReportID,
+		schedule.IsEnabled,
+		schedule.NextRunAt.UTC(),
+		schedule.ID,
+		schedule.AccountID,
+		schedule.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update report schedule: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete deletes a report schedule
+func (r *ReportScheduleRepository) Delete(id, accountID, userID int64) error {
+	result, err := r.db.Exec("DELETE FROM report_schedules WHERE id = ? AND account_id = ? AND user_id = ?", id, accountID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete report schedule: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RecordRun stores the outcome of sending a report. A nil sendErr clears any
+// previous error. A zero nextRunAt leaves the schedule's next run alone, as
+// for reports sent on demand.
+func (r *ReportScheduleRepository) RecordRun(id int64, sendErr error, nextRunAt time.Time) error {
+	var err error
+	switch {
+	case sendErr == nil && nextRunAt.IsZero():
+		_, err = r.db.Exec(`UPDATE report_schedules SET last_sent_at = ?, last_error = NULL WHERE id = ?`,
+			time.Now().UTC(), id)
+	case sendErr == nil:
+		_, err = r.db.Exec(`UPDATE report_schedules SET last_sent_at = ?, last_error = NULL, next_run_at = ? WHERE id = ?`,
+			time.Now().UTC(), nextRunAt.UTC(), id)
+	case nextRunAt.IsZero():
+		_, err = r.db.Exec(`UPDATE report_schedules SET last_error = ? WHERE id = ?`,
+			sendErr.Error(), id)
+	default:
+		_, err = r.db.Exec(`UPDATE report_schedules SET last_error = ?, next_run_at = ? WHERE id = ?`,
+			sendErr.Error(), nextRunAt.UTC(), id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record report run: %w", err)
+	}
+
+	return nil
+}
+
+// DisableForCourse turns off every enabled report schedule limited to a
+// course, returning how many there were
+func (r *ReportScheduleRepository) DisableForCourse(courseID int64) (int64, error) {
+	result, err := r.db.Exec(`UPDATE report_schedules SET is_enabled = FALSE WHERE course_id = ? AND is_enabled = TRUE`, courseID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to disable report schedules: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// scanSchedules is a helper to scan multiple report schedule rows
+func (r *ReportScheduleRepository) scanSchedules(rows *sql.Rows) ([]*models.ReportSchedule, error) {
+	var schedules []*models.ReportSchedule
+	for rows.Next() {
+		var s models.ReportSchedule
+		err := rows.Scan(
+			&s.ID,
+			&s.AccountID,
+			&s.UserID,
+			&s.Name,
+			&s.Frequency,
+			&s.DayOfWeek,
+			&s.DayOfMonth,
+			&s.Hour,
+			&s.CourseID,
+			&s.IncludePDF,
+			&s.SavedReportID,
+			&s.IsEnabled,
+			&s.NextRunAt,
+			&s.LastSentAt,
+			&s.LastError,
+			&s.CreatedAt,
+			&s.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan report schedule: %w", err)
+		}
+		schedules = append(schedules, &s)
+	}
+
+	return schedules, rows.Err()
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type SavedReportRepository struct {
+	db *database.DB
+}
+
+func NewSavedReportRepository(db *database.DB) *SavedReportRepository {
+	return &SavedReportRepository{db: db}
+}
+
+const savedReportColumns = `id, account_id, user_id, name, definition, created_at, updated_at`
+
+// Create creates a new saved report
+func (r *SavedReportRepository) Create(report *models.SavedReport) error {
+	now := time.Now()
+	err := r.db.QueryRow(`
+		INSERT INTO saved_reports (account_id, user_id, name, definition, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, report.AccountID, report.UserID, report.Name, report.Definition, now, now).Scan(&report.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create saved report: %w", err)
+	}
+
+	report.CreatedAt = now
+	report.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves one of a user's saved reports
+func (r *SavedReportRepository) GetByID(id, accountID, userID int64) (*models.SavedReport, error) {
+	query := `SELECT ` + savedReportColumns + ` FROM saved_reports WHERE id = ? AND account_id = ? AND user_id = ?`
+	report, err := scanSavedReport(r.db.QueryRow(query, id, accountID, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved report: %w", err)
+	}
+	return report, nil
+}
+
+// List retrieves a user's saved reports by name
+func (r *SavedReportRepository) List(accountID, userID int64) ([]*models.SavedReport, error) {
+	query := `SELECT ` + savedReportColumns + ` FROM saved_reports WHERE account_id = ? AND user_id = ? ORDER BY name ASC, id ASC`
+	rows, err := r.db.Query(query, accountID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []*models.SavedReport
+	for rows.Next() {
+		report, err := scanSavedReport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan saved report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// Update updates a saved report's name and definition
+func (r *SavedReportRepository) Update(report *models.SavedReport) error {
+	result, err := r.db.Exec(`
+		UPDATE saved_reports SET name = ?, definition = ?
+		WHERE id = ? AND account_id = ? AND user_id = ?
+	`, report.Name, report.Definition, report.ID, report.AccountID, report.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to update saved report: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete deletes a saved report. Report schedules that attached it carry
+// on without it.
+func (r *SavedReportRepository) Delete(id, accountID, userID int64) error {
+	result, err := r.db.Exec("DELETE FROM saved_reports WHERE id = ? AND account_id = ? AND user_id = ?", id, accountID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved report: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanSavedReport(row rowScanner) (*models.SavedReport, error) {
+	var report models.SavedReport
+	err := row.Scan(&report.ID, &report.AccountID, &report.UserID, &report.Name, &report.Definition, &report.CreatedAt, &report.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestSavedReportRepository(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewSavedReportRepository(db)
+	report := &models.SavedReport{AccountID: 1, UserID: 1, Name: "Pain by month", Definition: `{"sections":[]}`}
+	if err := repo.Create(report); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	report.Name = "Monthly pain"
+	if err := repo.Update(report); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	got, err := repo.GetByID(report.ID, 1, 1)
+	if err != nil || got.Name != "Monthly pain" {
+		t.Fatalf("Expected the renamed report, got %+v, %v", got, err)
+	}
+	if _, err := repo.GetByID(report.ID, 1, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another user's report to be not found, got %v", err)
+	}
+
+	// A schedule attaching the report carries on without it once deleted
+	if _, err := db.Exec(`
+		INSERT INTO report_schedules (account_id, user_id, name, frequency, next_run_at, saved_report_id)
+		VALUES (1, 1, 'Weekly', 'weekly', CURRENT_TIMESTAMP, ?)
+	`, report.ID); err != nil {
+		t.Fatalf("Failed to create schedule: %v", err)
+	}
+	if err := repo.Delete(report.ID, 1, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	schedules, err := NewReportScheduleRepository(db).List(1, 1)
+	if err != nil || len(schedules) != 1 || schedules[0].SavedReportID.Valid {
+		t.Errorf("Expected the schedule kept without its report, got %v, %v", schedules, err)
+	}
+	if err := repo.Delete(report.ID, 1, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a second delete to be not found, got %v", err)
+	}
+}
+
+func TestReportDefinitionValidate(t *testing.T) {
+	lastWeek := ReportDateRange{Mode: "last_days", Days: 7}
+	tests := []struct {
+		name    string
+		section ReportSection
+		want    string // Part of the error, or "" for none
+	}{
+		{"plain columns", ReportSection{Entity: "injections", Columns: []ReportColumn{{Field: "timestamp"}, {Field: "side"}}}, ""},
+		{"grouped", ReportSection{Entity: "symptoms", GroupBy: "month", Columns: []ReportColumn{{Aggregate: "count"}, {Field: "pain_level", Aggregate: "avg"}}}, ""},
+		{"unknown entity", ReportSection{Entity: "users", Columns: []ReportColumn{{Field: "password_hash"}}}, "unknown entity"},
+		{"unknown column", ReportSection{Entity: "injections", Columns: []ReportColumn{{Field: "i.notes); DROP TABLE injections; --"}}}, "unknown column"},
+		{"aggregate ungrouped", ReportSection{Entity: "injections", Columns: []ReportColumn{{Field: "pain_level", Aggregate: "avg"}}}, "need the section to be grouped"},
+		{"grouped without aggregate", ReportSection{Entity: "injections", GroupBy: "side", Columns: []ReportColumn{{Field: "pain_level"}}}, "need an aggregate"},
+		{"average of text", ReportSection{Entity: "injections", GroupBy: "side", Columns: []ReportColumn{{Field: "notes", Aggregate: "avg"}}}, "aggregate must be one of count"},
+		{"unknown group", ReportSection{Entity: "injections", GroupBy: "year", Columns: []ReportColumn{{Aggregate: "count"}}}, "can't group by"},
+		{"unknown op", ReportSection{Entity: "injections", Columns: []ReportColumn{{Field: "side"}}, Filters: []ReportFilter{{Field: "side", Op: "OR 1=1", Value: "left"}}}, "op must be one of contains, eq, ne"},
+		{"number filter", ReportSection{Entity: "injections", Columns: []ReportColumn{{Field: "side"}}, Filters: []ReportFilter{{Field: "pain_level", Op: "gte", Value: "1 OR 1=1"}}}, "is not a number"},
+		{"date filter", ReportSection{Entity: "injections", Columns: []ReportColumn{{Field: "side"}}, Filters: []ReportFilter{{Field: "timestamp", Op: "eq", Value: "2026-01-01"}}}, "the date range covers it"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := &ReportDefinition{DateRange: lastWeek, Sections: []ReportSection{tt.section}}
+			err := def.Validate()
+			if tt.want == "" && err != nil {
+				t.Errorf("Expected it to be valid, got %v", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if err := (&ReportDefinition{DateRange: ReportDateRange{Mode: "forever"}, Sections: []ReportSection{tests[0].section}}).Validate(); err == nil {
+		t.Error("Expected an unknown date range mode to be refused")
+	}
+}
+
+func TestReportDateRangeBounds(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	now := time.Date(2026, 3, 15, 2, 0, 0, 0, time.UTC) // Mar 14, 10pm in New York
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 0, 0, 0, 0, loc) }
+
+	tests := []struct {
+		r          ReportDateRange
+		start, end time.Time
+	}{
+		{ReportDateRange{Mode: "last_days", Days: 7}, day(3, 8), day(3, 15)},
+		{ReportDateRange{Mode: "this_month"}, day(3, 1), day(3, 15)},
+		{ReportDateRange{Mode: "last_month"}, day(2, 1), day(3, 1)},
+		{ReportDateRange{Mode: "this_year"}, day(1, 1), day(3, 15)},
+		{ReportDateRange{Mode: "custom", Start: "2026-01-10", End: "2026-01-10"}, day(1, 10), day(1, 11)},
+		{ReportDateRange{Mode: "all"}, time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		start, end, err := tt.r.Bounds(now, loc)
+		if err != nil || !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("%s: got %v to %v (%v), want %v to %v", tt.r.Mode, start, end, err, tt.start, tt.end)
+		}
+	}
+
+	if _, _, err := (ReportDateRange{Mode: "custom", Start: "2026-02-01", End: "2026-01-01"}).Bounds(now, loc); err == nil {
+		t.Error("Expected an end before the start to be refused")
+	}
+}
+
+func TestBuildReportQueryBindsValues(t *testing.T) {
+	hostile := "x' OR '1'='1"
+	section := &ReportSection{
+		Entity:  "symptoms",
+		Columns: []ReportColumn{{Field: "notes"}},
+		Filters: []ReportFilter{{Field: "notes", Op: "contains", Value: hostile}, {Field: "pain_level", Op: "gt", Value: "3"}},
+	}
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	q, err := buildReportQuery(section, 1, SymptomViewer{UserID: 2}, start, time.Time{}, time.UTC)
+	if err != nil {
+		t.Fatalf("buildReportQuery failed: %v", err)
+	}
+	if strings.Contains(q.sql, "OR '1'") {
+		t.Errorf("Filter value made it into the SQL: %s", q.sql)
+	}
+	if !strings.Contains(q.sql, "logged_by = ?") {
+		t.Errorf("Expected private symptom logs to be kept out: %s", q.sql)
+	}
+	want := []interface{}{int64(1), int64(2), start, "%x' or '1'='1%", 3.0, MaxReportRows + 1}
+	if len(q.args) != len(want) {
+		t.Fatalf("Expected args %v, got %v", want, q.args)
+	}
+	for i := range want {
+		if q.args[i] != want[i] {
+			t.Errorf("Arg %d: got %v, want %v", i, q.args[i], want[i])
+		}
+	}
+}
+
+func TestSavedReportRepositoryRun(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (2, 'member', 'hash');
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 2, 'member');
+		INSERT INTO accounts (id, name) VALUES (2, 'Other');
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', CURRENT_TIMESTAMP, 1);
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (2, 2, 'Theirs', CURRENT_TIMESTAMP, 1);
+	`); err != nil {
+		t.Fatalf("Failed to seed accounts: %v", err)
+	}
+	for _, inj := range []struct {
+		course int64
+		at     time.Time
+		side   string
+		pain   interface{}
+		dose   interface{}
+	}{
+		{1, time.Date(2026, 2, 27, 20, 0, 0, 0, time.UTC), "left", 4, 1.0},
+		{1, time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC), "right", 2, nil},
+		{1, time.Date(2026, 3, 4, 20, 0, 0, 0, time.UTC), "left", nil, 0.5},
+		{2, time.Date(2026, 3, 3, 20, 0, 0, 0, time.UTC), "left", 9, 1.0},
+	} {
+		if _, err := db.Exec(`INSERT INTO injections (course_id, timestamp, side, pain_level, dose_ml) VALUES (?, ?, ?, ?, ?)`,
+			inj.course, inj.at, inj.side, inj.pain, inj.dose); err != nil {
+			t.Fatalf("Failed to create injection: %v", err)
+		}
+	}
+	if _, err := db.Exec(`
+		INSERT INTO symptom_logs (course_id, logged_by, timestamp, pain_level, notes, visibility)
+		VALUES (1, 1, '2026-03-02 10:00:00', 3, 'Shared', 'shared'), (1, 2, '2026-03-02 11:00:00', 5, 'Mine', 'private')
+	`); err != nil {
+		t.Fatalf("Failed to create symptom logs: %v", err)
+	}
+
+	repo := NewSavedReportRepository(db)
+	run := func(def *ReportDefinition, viewer SymptomViewer) []*ReportTable {
+		t.Helper()
+		tables, err := repo.Run(def, 1, viewer, time.Time{}, time.Time{}, time.UTC)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		return tables
+	}
+	all := ReportDateRange{Mode: "all"}
+
+	tables := run(&ReportDefinition{DateRange: all, Sections: []ReportSection{{
+		Entity:  "injections",
+		Columns: []ReportColumn{{Field: "side"}, {Field: "dose_ml"}},
+		Filters: []ReportFilter{{Field: "side", Op: "eq", Value: "LEFT"}},
+	}}}, SymptomViewer{UserID: 1})
+	rows := tables[0].Rows
+	if len(rows) != 2 || rows[0][0] != "left" || rows[0][1] != 0.5 || rows[1][1] != 1.0 {
+		t.Errorf("Expected this account's two left injections, newest first, got %v", rows)
+	}
+
+	// Grouped by month, with the injection logged without a dose counted
+	// at the default
+	tables = run(&ReportDefinition{DateRange: all, Sections: []ReportSection{{
+		Entity:  "injections",
+		GroupBy: "month",
+		Columns: []ReportColumn{{Aggregate: "count"}, {Field: "pain_level", Aggregate: "avg"}, {Field: "dose_ml", Aggregate: "sum"}},
+	}}}, SymptomViewer{UserID: 1})
+	rows = tables[0].Rows
+	if len(rows) != 2 {
+		t.Fatalf("Expected February and March, got %v", rows)
+	}
+	march := rows[1]
+	if !march[0].(time.Time).Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) || march[1] != 2.0 || march[2] != 2.0 || march[3] != 1.5 {
+		t.Errorf("Expected March with 2 injections, pain 2 and 1.5 mL, got %v", march)
+	}
+	if len(tables[0].Columns) != 4 || tables[0].Columns[0].Label != "Month" {
+		t.Errorf("Expected the month then the three columns, got %+v", tables[0].Columns)
+	}
+
+	// Private symptom logs stay private
+	symptoms := &ReportDefinition{DateRange: all, Sections: []ReportSection{{Entity: "symptoms", Columns: []ReportColumn{{Field: "notes"}}}}}
+	if rows := run(symptoms, SymptomViewer{UserID: 1})[0].Rows; len(rows) != 1 || rows[0][0] != "Shared" {
+		t.Errorf("Expected the owner to see only the shared log, got %v", rows)
+	}
+	if rows := run(symptoms, SymptomViewer{UserID: 2})[0].Rows; len(rows) != 2 {
+		t.Errorf("Expected the member to see both logs, got %v", rows)
+	}
+
+	// The date range is half open
+	tables, err := repo.Run(&ReportDefinition{DateRange: all, Sections: []ReportSection{{Entity: "injections", Columns: []ReportColumn{{Field: "timestamp"}}}}},
+		1, SymptomViewer{UserID: 1}, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 4, 20, 0, 0, 0, time.UTC), time.UTC)
+	if err != nil || len(tables[0].Rows) != 1 {
+		t.Errorf("Expected one injection from March 1 up to the last, got %v, %v", tables, err)
+	}
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/secrets"
+)
+
+// secretColumn is a column holding secrets, keyed by keyColumn. keys limits
+// it to some rows, for site_settings.
+type secretColumn struct {
+	table, keyColumn, column string
+	keys                     []string
+}
+
+// secretColumns are everywhere secrets are stored
+var secretColumns = []secretColumn{
+	{table: "site_settings", keyColumn: "key", column: "value", keys: SecretSiteSettings},
+	{table: "notification_channels", keyColumn: "id", column: "config"},
+	{table: "webhooks", keyColumn: "id", column: "secret"},
+}
+
+// storedSecret is one secret as it is in the database
+type storedSecret struct {
+	key   interface{}
+	value string
+}
+
+// SecretCounts counts the secrets stored in the database
+type SecretCounts struct {
+	Encrypted   int
+	Unencrypted int
+}
+
+// SecretRepository finds every secret stored in the database, to report on
+// them and re-encrypt them under a new data key
+type SecretRepository struct {
+	db *database.DB
+}
+
+func NewSecretRepository(db *database.DB) *SecretRepository {
+	return &SecretRepository{db: db}
+}
+
+// Count counts the stored secrets that are encrypted and those that aren't.
+// Empty values aren't counted.
+func (r *SecretRepository) Count() (SecretCounts, error) {
+	var counts SecretCounts
+	for _, c := range secretColumns {
+		values, err := readSecrets(r.db, c)
+		if err != nil {
+			return counts, err
+		}
+		for _, v := range values {
+			switch {
+			case v.value == "":
+			case secrets.IsSealed(v.value):
+				counts.Encrypted++
+			default:
+				counts.Unencrypted++
+			}
+		}
+	}
+	return counts, nil
+}
+
+// Rotate re-encrypts every stored secret under a new data key sealed with
+// the keyring's master key, including any stored before encryption was set
+// up, then deletes the old data keys. It returns how many secrets it
+// encrypted. Either all of it happens or none of it does.
+func (r *SecretRepository) Rotate(k *secrets.Keyring) (int, error) {
+	if k == nil {
+		return 0, secrets.ErrNoMasterKey
+	}
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	keyID, err := k.Rotate(tx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, c := range secretColumns {
+		values, err := readSecrets(tx, c)
+		if err != nil {
+			return 0, err
+		}
+		for _, v := range values {
+			if v.value == "" {
+				continue
+			}
+			plaintext, err := k.Open(tx, v.value)
+			if err != nil {
+				return 0, fmt.Errorf("failed to decrypt %s %v: %w", c.table, v.key, err)
+			}
+			sealed, err := k.Seal(tx, plaintext)
+			if err != nil {
+				return 0, err
+			}
+			query := fmt.Sprintf(`UPDATE %s SET %s = ? WHERE %s = ?`, c.table, c.column, c.keyColumn)
+			if _, err := tx.Exec(query, sealed, v.key); err != nil {
+				return 0, fmt.Errorf("failed to save %s %v: %w", c.table, v.key, err)
+			}
+			count++
+		}
+	}
+	if err := secrets.DeleteDataKeysBefore(tx, keyID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return count, nil
+}
+
+// readSecrets reads a secret column. The rows are all read before any is
+// decrypted, which needs queries of its own.
+func readSecrets(q settingsQuerier, c secretColumn) ([]storedSecret, error) {
+	query := fmt.Sprintf(`SELECT %s, %s FROM %s`, c.keyColumn, c.column, c.table)
+	args := make([]interface{}, len(c.keys))
+	if len(c.keys) > 0 {
+		query += fmt.Sprintf(` WHERE %s IN (?%s)`, c.keyColumn, strings.Repeat(", ?", len(c.keys)-1))
+		for i, key := range c.keys {
+			args[i] = key
+		}
+	}
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", c.table, err)
+	}
+	defer rows.Close()
+
+	var values []storedSecret
+	for rows.Next() {
+		var v storedSecret
+		if err := rows.Scan(&v.key, &v.value); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", c.table, err)
+		}
+		if b, ok := v.key.([]byte); ok {
+			v.key = string(b)
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/secrets"
+)
+
+func useTestKeyring(t *testing.T, b byte) *secrets.Keyring {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+	k, err := secrets.NewKeyring(key, nil)
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	secrets.Use(k)
+	t.Cleanup(func() { secrets.Use(nil) })
+	return k
+}
+
+func TestSecretsStoredEncrypted(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+	useTestKeyring(t, 'a')
+
+	settings := NewSettingsRepository(db)
+	if err := settings.SetSite("smtp_password", "hunter2", sql.NullInt64{}); err != nil {
+		t.Fatalf("SetSite failed: %v", err)
+	}
+	if err := settings.SetSite("smtp_host", "mail.example.com", sql.NullInt64{}); err != nil {
+		t.Fatalf("SetSite failed: %v", err)
+	}
+	webhook := createTestWebhook(t, NewWebhookRepository(db))
+	channel := &models.NotificationChannel{AccountID: 1, Type: "ntfy", Name: "Phone", Config: `{"token":"tk_123"}`, IsEnabled: true}
+	if err := NewNotificationChannelRepository(db).Create(channel); err != nil {
+		t.Fatalf("Failed to create channel: %v", err)
+	}
+
+	var stored string
+	for _, q := range []struct{ query, secret string }{
+		{`SELECT value FROM site_settings WHERE key = 'smtp_password'`, "hunter2"},
+		{`SELECT secret FROM webhooks`, webhook.Secret},
+		{`SELECT config FROM notification_channels`, "tk_123"},
+	} {
+		if err := db.QueryRow(q.query).Scan(&stored); err != nil {
+			t.Fatalf("%s: %v", q.query, err)
+		}
+		if !secrets.IsSealed(stored) || strings.Contains(stored, q.secret) {
+			t.Errorf("%s = %q, want it encrypted", q.query, stored)
+		}
+	}
+	if err := db.QueryRow(`SELECT value FROM site_settings WHERE key = 'smtp_host'`).Scan(&stored); err != nil || stored != "mail.example.com" {
+		t.Errorf("smtp_host stored as %q, %v; want it left alone", stored, err)
+	}
+
+	if value, err := settings.GetSite("smtp_password"); err != nil || value != "hunter2" {
+		t.Errorf("GetSite = %q, %v", value, err)
+	}
+	if got, err := NewWebhookRepository(db).GetByID(webhook.ID, 1); err != nil || got.Secret != webhook.Secret {
+		t.Errorf("Webhook secret = %v, %v", got, err)
+	}
+	channels, err := NewNotificationChannelRepository(db).List(1)
+	if err != nil || len(channels) != 1 || channels[0].Config != channel.Config {
+		t.Errorf("Channels = %v, %v", channels, err)
+	}
+}
+
+func TestSecretRepository_Rotate(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	// Saved before a key was configured
+	settings := NewSettingsRepository(db)
+	if err := settings.SetSite("auto_backup_passphrase", "correct horse", sql.NullInt64{}); err != nil {
+		t.Fatalf("SetSite failed: %v", err)
+	}
+	createTestWebhook(t, NewWebhookRepository(db))
+
+	repo := NewSecretRepository(db)
+	if counts, err := repo.Count(); err != nil || counts.Unencrypted != 2 || counts.Encrypted != 0 {
+		t.Fatalf("Count = %+v, %v", counts, err)
+	}
+	if _, err := repo.Rotate(nil); err == nil {
+		t.Error("Rotate without a key succeeded")
+	}
+
+	k := useTestKeyring(t, 'a')
+	n, err := repo.Rotate(k)
+	if err != nil || n != 2 {
+		t.Fatalf("Rotate = %d, %v", n, err)
+	}
+	if counts, err := repo.Count(); err != nil || counts.Unencrypted != 0 || counts.Encrypted != 2 {
+		t.Errorf("Count after rotating = %+v, %v", counts, err)
+	}
+	first, _ := secrets.CurrentDataKey(db)
+
+	if _, err := repo.Rotate(k); err != nil {
+		t.Fatalf("Second rotate failed: %v", err)
+	}
+	var keys int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM secret_keys`).Scan(&keys); err != nil || keys != 1 {
+		t.Errorf("%d data keys after rotating, want only the new one", keys)
+	}
+	if second, _ := secrets.CurrentDataKey(db); second.ID == first.ID {
+		t.Error("Rotate kept the same data key")
+	}
+	if value, err := settings.GetSite("auto_backup_passphrase"); err != nil || value != "correct horse" {
+		t.Errorf("GetSite after rotating = %q, %v", value, err)
+	}
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// SessionRepository keeps track of signed-in devices, so a session can be
+// listed and revoked even though its JWT is otherwise stateless
+type SessionRepository struct {
+	db *database.DB
+}
+
+func NewSessionRepository(db *database.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+const sessionColumns = `id, user_id, token_hash, expires_at, created_at, last_used_at, ip_address, user_agent, is_revoked, impersonator_id, trusted_device_id`
+
+// Create starts a session for a user and returns it with the session id to
+// put in the token, which is not stored. The user's sessions that have
+// expired or been revoked are cleared out at the same time.
+func (r *SessionRepository) Create(userID int64, expiresAt time.Time, ipAddress, userAgent string) (*models.SessionToken, string, error) {
+	return r.create(userID, sql.NullInt64{}, sql.NullInt64{}, expiresAt, ipAddress, userAgent)
+}
+
+// CreateTrusted starts a session on one of the user's trusted devices.
+// Revoking the device's trust signs it out.
+func (r *SessionRepository) CreateTrusted(userID, trustedDeviceID int64, expiresAt time.Time, ipAddress, userAgent string) (*models.SessionToken, string, error) {
+	return r.create(userID, sql.NullInt64{}, sql.NullInt64{Int64: trustedDeviceID, Valid: true}, expiresAt, ipAddress, userAgent)
+}
+
+// CreateImpersonation starts a session for the admin impersonatorID to act
+// as a user. It is listed with the user's other sessions, so they can see
+// it and sign it out.
+func (r *SessionRepository) CreateImpersonation(userID, impersonatorID int64, expiresAt time.Time, ipAddress, userAgent string) (*models.SessionToken, string, error) {
+	return r.create(userID, sql.NullInt64{Int64: impersonatorID, Valid: true}, sql.NullInt64{}, expiresAt, ipAddress, userAgent)
+}
+
+func (r *SessionRepository) create(userID int64, impersonatorID, trustedDeviceID sql.NullInt64, expiresAt time.Time, ipAddress, userAgent string) (*models.SessionToken, string, error) {
+	sessionID, err := generateToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if _, err := r.db.Exec(`DELETE FROM session_tokens WHERE user_id = ? AND (expires_at < ? OR is_revoked = TRUE)`, userID, now); err != nil {
+		return nil, "", fmt.Errorf("failed to clear old sessions: %w", err)
+	}
+
+	session := &models.SessionToken{
+		UserID:          userID,
+		TokenHash:       hashToken(sessionID),
+		ExpiresAt:       expiresAt.UTC(),
+		CreatedAt:       now,
+		LastUsedAt:      sql.NullTime{Time: now, Valid: true},
+		IPAddress:       sql.NullString{String: ipAddress, Valid: ipAddress != ""},
+		UserAgent:       sql.NullString{String: userAgent, Valid: userAgent != ""},
+		ImpersonatorID:  impersonatorID,
+		TrustedDeviceID: trustedDeviceID,
+	}
+	err = r.db.QueryRow(`
+		INSERT INTO session_tokens (user_id, token_hash, expires_at, created_at, last_used_at, ip_address, user_agent, is_revoked, impersonator_id, trusted_device_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, FALSE, ?, ?)
+		RETURNING id
+	`, userID, session.TokenHash, session.ExpiresAt, now, now, session.IPAddress, session.UserAgent, impersonatorID, trustedDeviceID).Scan(&session.ID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create session: %w", err)
+	}
+	return session, sessionID, nil
+}
+
+// GetActive looks up a session from the id in its token. Sessions that have
+// been revoked or have expired are not found.
+func (r *SessionRepository) GetActive(sessionID string) (*models.SessionToken, error) {
+	session, err := r.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !session.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return session, nil
+}
+
+// Get looks up a session from the id in its token, even if it has expired,
+// so the caller can tell why it can't be used. Revoked sessions are not
+// found.
+func (r *SessionRepository) Get(sessionID string) (*models.SessionToken, error) {
+	query := `SELECT ` + sessionColumns + ` FROM session_tokens WHERE token_hash = ? AND is_revoked = FALSE`
+	session, err := scanSession(r.db.QueryRow(query, hashToken(sessionID)))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+// ListActive retrieves a user's sessions that haven't expired or been
+// revoked, most recently used first
+func (r *SessionRepository) ListActive(userID int64) ([]*models.SessionToken, error) {
+	query := `SELECT ` + sessionColumns + ` FROM session_tokens
+		WHERE user_id = ? AND is_revoked = FALSE AND expires_at > ?
+		ORDER BY last_used_at DESC, id DESC`
+	rows, err := r.db.Query(query, userID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*models.SessionToken
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// MarkUsed records when a session was last seen
+func (r *SessionRepository) MarkUsed(id int64, when time.Time) error {
+	if _, err := r.db.Exec(`UPDATE session_tokens SET last_used_at = ? WHERE id = ?`, when.UTC(), id); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return nil
+}
+
+// Extend moves a session's expiry
+func (r *SessionRepository) Extend(id int64, expiresAt time.Time) error {
+	if _, err := r.db.Exec(`UPDATE session_tokens SET expires_at = ? WHERE id = ?`, expiresAt.UTC(), id); err != nil {
+		return fmt.Errorf("failed to extend session: %w", err)
+	}
+	return nil
+}
+
+// Revoke signs out one of a user's sessions. Its token stops working at
+// once.
+func (r *SessionRepository) Revoke(id, userID int64) error {
+	result, err := r.db.Exec(`
+		UPDATE session_tokens SET is_revoked = TRUE
+		WHERE id = ? AND user_id = ? AND is_revoked = FALSE
+	`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanSession(row rowScanner) (*models.SessionToken, error) {
+	var s models.SessionToken
+	var createdAt sql.NullTime
+	err := row.Scan(&s.ID, &s.UserID, &s.TokenHash, &s.ExpiresAt, &createdAt, &s.LastUsedAt, &s.IPAddress, &s.UserAgent, &s.IsRevoked, &s.ImpersonatorID, &s.TrustedDeviceID)
+	if err != nil {
+		return nil, err
+	}
+	s.CreatedAt = createdAt.Time
+	return &s, nil
+}
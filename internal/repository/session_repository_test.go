@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/auth"
+)
+
+func TestSessionRepository(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+	now := time.Now()
+
+	session, sessionID, err := repo.Create(1, now.Add(time.Hour), "10.0.0.1", "Firefox")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if session.TokenHash == sessionID {
+		t.Error("Expected only a hash of the session id to be stored")
+	}
+	other, otherID, err := repo.Create(1, now.Add(time.Hour), "10.0.0.2", "Safari")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	got, err := repo.GetActive(sessionID)
+	if err != nil || got.ID != session.ID || got.IPAddress.String != "10.0.0.1" {
+		t.Fatalf("Expected session %d, got %+v (%v)", session.ID, got, err)
+	}
+	if _, err := repo.GetActive("nope"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown session, got %v", err)
+	}
+
+	if err := repo.Revoke(other.ID, 2); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound revoking another user's session, got %v", err)
+	}
+	if err := repo.Revoke(other.ID, 1); err != nil {
+		t.Fatalf("Failed to revoke session: %v", err)
+	}
+	if _, err := repo.GetActive(otherID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a revoked session, got %v", err)
+	}
+	if err := repo.Revoke(other.ID, 1); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound revoking twice, got %v", err)
+	}
+
+	if err := repo.Extend(session.ID, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("Failed to extend session: %v", err)
+	}
+	if _, err := repo.GetActive(sessionID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an expired session, got %v", err)
+	}
+	if got, err := repo.Get(sessionID); err != nil || got.ID != session.ID {
+		t.Errorf("Expected Get to find the expired session, got %+v (%v)", got, err)
+	}
+	if _, err := repo.Get(otherID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound getting a revoked session, got %v", err)
+	}
+
+	// Starting another session clears out the expired and revoked ones
+	if _, _, err := repo.Create(1, now.Add(time.Hour), "10.0.0.3", "Chrome"); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM session_tokens WHERE user_id = 1`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("Expected only the new session left, got %d (%v)", count, err)
+	}
+	sessions, err := repo.ListActive(1)
+	if err != nil || len(sessions) != 1 || sessions[0].UserAgent.String != "Chrome" {
+		t.Errorf("Expected the Chrome session, got %+v (%v)", sessions, err)
+	}
+}
+
+func TestSessionRepository_Impersonation(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO users (id, username, password_hash, email) VALUES (2, 'admin', 'hash', 'admin@example.com')`); err != nil {
+		t.Fatalf("Failed to create admin: %v", err)
+	}
+
+	session, sessionID, err := NewSessionRepository(db).CreateImpersonation(1, 2, time.Now().Add(time.Hour), "10.0.0.1", "Firefox")
+	if err != nil {
+		t.Fatalf("Failed to create impersonation session: %v", err)
+	}
+	got, err := NewSessionRepository(db).GetActive(sessionID)
+	if err != nil || got.ID != session.ID || got.ImpersonatorID.Int64 != 2 {
+		t.Fatalf("Expected session %d impersonated by 2, got %+v (%v)", session.ID, got, err)
+	}
+
+	// What is logged in the session names the admin as well as the user
+	audit := NewAuditRepository(db.WithContext(auth.WithImpersonator(context.Background(), 2)))
+	if err := audit.LogWithDetails(sql.NullInt64{Int64: 1, Valid: true}, "update", "settings", sql.NullInt64{}, nil, "", ""); err != nil {
+		t.Fatalf("Failed to log: %v", err)
+	}
+	if err := NewAuditRepository(db).LogWithDetails(sql.NullInt64{Int64: 1, Valid: true}, "update", "settings", sql.NullInt64{}, nil, "", ""); err != nil {
+		t.Fatalf("Failed to log: %v", err)
+	}
+	page, err := NewAuditRepository(db).ListPage(AuditFilter{ImpersonatorID: 2}, PageRequest{})
+	if err != nil {
+		t.Fatalf("Failed to list audit logs: %v", err)
+	}
+	if page.Total != 1 || page.Items[0].UserID.Int64 != 1 || page.Items[0].ImpersonatorID.Int64 != 2 {
+		t.Errorf("Expected one entry by user 1 impersonated by 2, got %d", page.Total)
+	}
+}
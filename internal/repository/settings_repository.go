@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/secrets"
+)
+
+// Account settings, shared by an account's members
+const (
+	AccountSettingAdvancedMode       = "advanced_mode_enabled"
+	AccountSettingHeatMapDays        = "heat_map_days"
+	AccountSettingLowStockAlerts     = "low_stock_alerts"
+	AccountSettingInjectionReminders = "injection_reminders"
+	AccountSettingReminderTime       = "reminder_time"
+	AccountSettingReminderFrequency  = "reminder_frequency"
+	AccountSettingDuplicateWindow    = "duplicate_window_minutes"
+)
+
+// SecretSiteSettings are the site settings stored encrypted: see package
+// secrets
+var SecretSiteSettings = []string{
+	"smtp_password",
+	"auto_backup_passphrase",
+	"auto_backup_destination_config",
+}
+
+// isSecretSiteSetting reports whether key is one of SecretSiteSettings
+func isSecretSiteSetting(key string) bool {
+	for _, k := range SecretSiteSettings {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// User settings, a user's own notification choices. Display preferences
+// are typed, in user_preferences.
+const (
+	UserSettingEnableNotifications = "enable_notifications"
+	UserSettingCheckInReminder     = "checkin_reminder" // HH:MM, empty when off
+)
+
+// UserSettingKeys lists the user settings, in the order they are shown
+var UserSettingKeys = []string{
+	UserSettingEnableNotifications,
+	UserSettingCheckInReminder,
+}
+
+// DefaultUserPreferences are the preferences of a user who never saved any
+func DefaultUserPreferences(userID int64) *models.UserPreferences {
+	return &models.UserPreferences{
+		UserID:     userID,
+		Timezone:   "America/New_York",
+		Locale:     "en-US",
+		DateFormat: "MM/DD/YYYY",
+		TimeFormat: "12h",
+		Units:      "imperial",
+		WeekStart:  "sunday",
+		Theme:      "auto",
+	}
+}
+
+// settingsQuerier is what the repository runs its statements on: the
+// database or a transaction
+type settingsQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SettingsRepository reads and writes settings at their three levels: the
+// admin's site-wide settings, the settings an account's members share, and
+// each user's own settings and display preferences. Getters return
+// ErrNotFound for a setting that was never saved.
+type SettingsRepository struct {
+	q  settingsQuerier
+	db *database.DB // Nil within a transaction
+}
+
+func NewSettingsRepository(db *database.DB) *SettingsRepository {
+	return &SettingsRepository{q: db, db: db}
+}
+
+// NewSettingsRepositoryTx returns a repository that works within tx
+func NewSettingsRepositoryTx(tx *sql.Tx) *SettingsRepository {
+	return &SettingsRepository{q: tx}
+}
+
+// GetSite reads a site-wide setting, decrypting secret ones
+func (r *SettingsRepository) GetSite(key string) (string, error) {
+	value, err := r.get(`SELECT value FROM site_settings WHERE key = ?`, key)
+	if err != nil || !isSecretSiteSetting(key) {
+		return value, err
+	}
+	value, err = secrets.Active().Open(r.q, value)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// SetSite saves a site-wide setting, encrypting secret ones
+func (r *SettingsRepository) SetSite(key, value string, updatedBy sql.NullInt64) error {
+	if isSecretSiteSetting(key) {
+		sealed, err := secrets.Active().Seal(r.q, value)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", key, err)
+		}
+		value = sealed
+	}
+	_, err := r.q.Exec(`
+		INSERT INTO site_settings (key, value, updated_at, updated_by)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET
+			value = excluded.value,
+			updated_at = excluded.updated_at,
+			updated_by = excluded.updated_by
+	`, key, value, time.Now().UTC(), updatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", key, err)
+	}
+	return nil
+}
+
+// DeleteSite removes a site-wide setting, so its default applies again
+func (r *SettingsRepository) DeleteSite(key string) error {
+	if _, err := r.q.Exec(`DELETE FROM site_settings WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// ListSite lists the site-wide settings by key, with secret ones decrypted
+func (r *SettingsRepository) ListSite() ([]*models.Setting, error) {
+	settings, err := r.list(`SELECT key, value, updated_at, updated_by FROM site_settings ORDER BY key`)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range settings {
+		if !isSecretSiteSetting(s.Key) {
+			continue
+		}
+		if s.Value, err = secrets.Active().Open(r.q, s.Value); err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", s.Key, err)
+		}
+	}
+	return settings, nil
+}
+
+// GetAccount reads one of an account's settings
+func (r *SettingsRepository) GetAccount(accountID int64, key string) (string, error) {
+	return r.get(`SELECT value FROM account_settings WHERE account_id = ? AND key = ?`, accountID, key)
+}
+
+// SetAccount saves one of an account's settings
+func (r *SettingsRepository) SetAccount(accountID int64, key, value string, updatedBy sql.NullInt64) error {
+	_, err := r.q.Exec(`
+		INSERT INTO account_settings (account_id, key, value, updated_at, updated_by)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, key) DO UPDATE SET
+			value = excluded.value,
+			updated_at = excluded.updated_at,
+			updated_by = excluded.updated_by
+	`, accountID, key, value, time.Now().UTC(), updatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", key, err)
+	}
+	return nil
+}
+
+// ListAccount lists an account's settings by key
+func (r *SettingsRepository) ListAccount(accountID int64) ([]*models.Setting, error) {
+	return r.list(`SELECT key, value, updated_at, updated_by FROM account_settings WHERE account_id = ? ORDER BY key`, accountID)
+}
+
+// GetUser reads one of a user's settings
+func (r *SettingsRepository) GetUser(userID int64, key string) (string, error) {
+	return r.get(`SELECT value FROM user_settings WHERE user_id = ? AND key = ?`, userID, key)
+}
+
+// SetUser saves one of a user's settings
+func (r *SettingsRepository) SetUser(userID int64, key, value string) error {
+	_, err := r.q.Exec(`
+		INSERT INTO user_settings (user_id, key, value, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, key) DO UPDATE SET
+			value = excluded.value,
+			updated_at = excluded.updated_at
+	`, userID, key, value, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", key, err)
+	}
+	return nil
+}
+
+// ListUser returns a user's settings by key
+func (r *SettingsRepository) ListUser(userID int64) (map[string]string, error) {
+	settings, err := r.list(`SELECT key, value, updated_at, NULL FROM user_settings WHERE user_id = ? ORDER BY key`, userID)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(settings))
+	for _, s := range settings {
+		values[s.Key] = s.Value
+	}
+	return values, nil
+}
+
+// DeleteUser removes all of a user's settings
+func (r *SettingsRepository) DeleteUser(userID int64) error {
+	if _, err := r.q.Exec(`DELETE FROM user_settings WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete user settings: %w", err)
+	}
+	return nil
+}
+
+// GetPreferences reads a user's display preferences, or the defaults if
+// they never saved any. UpdatedAt is zero for the defaults.
+func (r *SettingsRepository) GetPreferences(userID int64) (*models.UserPreferences, error) {
+	p := models.UserPreferences{UserID: userID}
+	var updatedAt sql.NullTime
+	err := r.q.QueryRow(`
+		SELECT timezone, locale, date_format, time_format, units, week_start, theme, updated_at
+		FROM user_preferences WHERE user_id = ?
+	`, userID).Scan(&p.Timezone, &p.Locale, &p.DateFormat, &p.TimeFormat, &p.Units, &p.WeekStart, &p.Theme, &updatedAt)
+	if err == sql.ErrNoRows {
+		return DefaultUserPreferences(userID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	p.UpdatedAt = updatedAt.Time
+	return &p, nil
+}
+
+// SavePreferences saves all of a user's display preferences and sets
+// p.UpdatedAt
+func (r *SettingsRepository) SavePreferences(p *models.UserPreferences) error {
+	now := time.Now().UTC()
+	_, err := r.q.Exec(`
+		INSERT INTO user_preferences (user_id, timezone, locale, date_format, time_format, units, week_start, theme, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			timezone = excluded.timezone,
+			locale = excluded.locale,
+			date_format = excluded.date_format,
+			time_format = excluded.time_format,
+			units = excluded.units,
+			week_start = excluded.week_start,
+			theme = excluded.theme,
+			updated_at = excluded.updated_at
+	`, p.UserID, p.Timezone, p.Locale, p.DateFormat, p.TimeFormat, p.Units, p.WeekStart, p.Theme, now)
+	if err != nil {
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+	p.UpdatedAt = now
+	return nil
+}
+
+// UpdatePreferences saves a user's display preferences like
+// SavePreferences, but only if they are still as they were at version, the
+// updated_at they were read with (zero if they were never saved). If they
+// have changed since it returns ErrStale. It can't be used within a
+// transaction.
+func (r *SettingsRepository) UpdatePreferences(p *models.UserPreferences, version time.Time) error {
+	now := time.Now().UTC()
+	result, err := r.db.Exec(`
+		INSERT INTO user_preferences (user_id, timezone, locale, date_format, time_format, units, week_start, theme, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			timezone = excluded.timezone,
+			locale = excluded.locale,
+			date_format = excluded.date_format,
+			time_format = excluded.time_format,
+			units = excluded.units,
+			week_start = excluded.week_start,
+			theme = excluded.theme,
+			updated_at = excluded.updated_at
+		WHERE `+r.db.Dialect.SameTime("user_preferences.updated_at"),
+		p.UserID, p.Timezone, p.Locale, p.DateFormat, p.TimeFormat, p.Units, p.WeekStart, p.Theme, now, version)
+	if err != nil {
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrStale
+	}
+	p.UpdatedAt = now
+	return nil
+}
+
+func (r *SettingsRepository) get(query string, args ...interface{}) (string, error) {
+	var value string
+	err := r.q.QueryRow(query, args...).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get setting: %w", err)
+	}
+	return value, nil
+}
+
+func (r *SettingsRepository) list(query string, args ...interface{}) ([]*models.Setting, error) {
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list settings: %w", err)
+	}
+	defer rows.Close()
+
+	var settings []*models.Setting
+	for rows.Next() {
+		var s models.Setting
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&s.Key, &s.Value, &updatedAt, &s.UpdatedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan setting: %w", err)
+		}
+		s.UpdatedAt = updatedAt.Time
+		settings = append(settings, &s)
+	}
+	return settings, rows.Err()
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestSettingsRepository_Levels(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	for _, stmt := range []string{
+		`INSERT INTO accounts (id, name) VALUES (2, 'Other Account')`,
+		`INSERT INTO users (id, username, password_hash) VALUES (2, 'otheruser', 'hash')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to seed: %v", err)
+		}
+	}
+	repo := NewSettingsRepository(db)
+	admin := sql.NullInt64{Int64: 1, Valid: true}
+
+	if _, err := repo.GetSite("site_title"); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for an unset setting, got %v", err)
+	}
+	if err := repo.SetSite("site_title", "Clinic", admin); err != nil {
+		t.Fatalf("Failed to save site setting: %v", err)
+	}
+	// Saving again replaces the value
+	if err := repo.SetSite("site_title", "P-TRACK", admin); err != nil {
+		t.Fatalf("Failed to update site setting: %v", err)
+	}
+	if value, err := repo.GetSite("site_title"); err != nil || value != "P-TRACK" {
+		t.Errorf("Expected the updated site title, got %q (%v)", value, err)
+	}
+	site, err := repo.ListSite()
+	if err != nil {
+		t.Fatalf("Failed to list site settings: %v", err)
+	}
+	found := false
+	for _, s := range site {
+		if s.Key == "site_title" {
+			found = s.UpdatedBy.Valid && !s.UpdatedAt.IsZero()
+		}
+	}
+	if !found {
+		t.Errorf("Expected the site title listed with who changed it and when")
+	}
+	if err := repo.DeleteSite("site_title"); err != nil {
+		t.Fatalf("Failed to delete site setting: %v", err)
+	}
+	if _, err := repo.GetSite("site_title"); err != ErrNotFound {
+		t.Errorf("Expected the site setting gone, got %v", err)
+	}
+
+	// Each account has its own settings
+	if err := repo.SetAccount(1, AccountSettingLowStockAlerts, "false", admin); err != nil {
+		t.Fatalf("Failed to save account setting: %v", err)
+	}
+	if value, err := repo.GetAccount(1, AccountSettingLowStockAlerts); err != nil || value != "false" {
+		t.Errorf("Expected alerts off for account 1, got %q (%v)", value, err)
+	}
+	if _, err := repo.GetAccount(2, AccountSettingLowStockAlerts); err != ErrNotFound {
+		t.Errorf("Expected account 2 unaffected, got %v", err)
+	}
+	if shared, err := repo.ListAccount(1); err != nil || len(shared) != 1 || shared[0].Key != AccountSettingLowStockAlerts {
+		t.Errorf("Expected one account setting, got %d (%v)", len(shared), err)
+	}
+
+	// And each user their own preferences, within a transaction too
+	tx, err := db.BeginTx()
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	txRepo := NewSettingsRepositoryTx(tx)
+	if err := txRepo.SetUser(1, UserSettingCheckInReminder, "20:00"); err != nil {
+		t.Fatalf("Failed to save user setting: %v", err)
+	}
+	if err := txRepo.SetUser(2, UserSettingCheckInReminder, "08:00"); err != nil {
+		t.Fatalf("Failed to save user setting: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	prefs, err := repo.ListUser(1)
+	if err != nil || len(prefs) != 1 || prefs[UserSettingCheckInReminder] != "20:00" {
+		t.Errorf("Expected user 1's reminder at 20:00, got %v (%v)", prefs, err)
+	}
+
+	if err := repo.DeleteUser(1); err != nil {
+		t.Fatalf("Failed to delete user settings: %v", err)
+	}
+	if _, err := repo.GetUser(1, UserSettingCheckInReminder); err != ErrNotFound {
+		t.Errorf("Expected user 1's settings gone, got %v", err)
+	}
+	if value, err := repo.GetUser(2, UserSettingCheckInReminder); err != nil || value != "08:00" {
+		t.Errorf("Expected user 2's reminder kept, got %q (%v)", value, err)
+	}
+}
+
+func TestSettingsRepository_Preferences(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+	repo := NewSettingsRepository(db)
+
+	prefs, err := repo.GetPreferences(1)
+	if err != nil {
+		t.Fatalf("Failed to get preferences: %v", err)
+	}
+	if *prefs != *DefaultUserPreferences(1) {
+		t.Errorf("Expected the defaults before anything is saved, got %+v", prefs)
+	}
+
+	prefs.Timezone = "Europe/Paris"
+	prefs.Units = "metric"
+	prefs.WeekStart = "monday"
+	if err := repo.SavePreferences(prefs); err != nil {
+		t.Fatalf("Failed to save preferences: %v", err)
+	}
+	if prefs.UpdatedAt.IsZero() {
+		t.Error("Expected UpdatedAt set on save")
+	}
+
+	got, err := repo.GetPreferences(1)
+	if err != nil {
+		t.Fatalf("Failed to get preferences: %v", err)
+	}
+	if got.Timezone != "Europe/Paris" || got.Units != "metric" || got.WeekStart != "monday" || got.Locale != "en-US" || !got.UpdatedAt.Equal(prefs.UpdatedAt) {
+		t.Errorf("Expected the saved preferences back, got %+v", got)
+	}
+
+	// The table only takes values the app knows
+	got.Theme = "purple"
+	if err := repo.SavePreferences(got); err == nil {
+		t.Error("Expected an unknown theme to be refused")
+	}
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type ShareLinkRepository struct {
+	db *database.DB
+}
+
+func NewShareLinkRepository(db *database.DB) *ShareLinkRepository {
+	return &ShareLinkRepository{db: db}
+}
+
+const shareLinkColumns = `s.id, s.account_id, s.created_by, s.name, s.token_hash, s.scopes, s.expires_at,
+	s.created_at, s.last_used_at, s.revoked_at, COALESCE(u.username, '')`
+
+const shareLinkFrom = ` FROM share_links s LEFT JOIN users u ON u.id = s.created_by`
+
+// Create creates a share link and returns it with the plain token, which is
+// not stored and can't be recovered later
+func (r *ShareLinkRepository) Create(accountID, createdBy int64, name string, scopes []string, expiresAt time.Time) (*models.ShareLink, string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	now := time.Now()
+	link := &models.ShareLink{
+		AccountID: accountID,
+		CreatedBy: createdBy,
+		Name:      name,
+		TokenHash: hashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	err = r.db.QueryRow(`
+		INSERT INTO share_links (account_id, created_by, name, token_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, accountID, createdBy, name, link.TokenHash, strings.Join(scopes, ","), expiresAt, now).Scan(&link.ID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create share link: %w", err)
+	}
+	return link, token, nil
+}
+
+// List retrieves an account's share links that haven't been revoked, newest
+// first. Expired links are included.
+func (r *ShareLinkRepository) List(accountID int64) ([]*models.ShareLink, error) {
+	query := `SELECT ` + shareLinkColumns + shareLinkFrom + `
+		WHERE s.account_id = ? AND s.revoked_at IS NULL
+		ORDER BY s.created_at DESC, s.id DESC`
+	rows, err := r.db.Query(query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query share links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []*models.ShareLink
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan share link: %w", err)
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// Get retrieves one of an account's share links, revoked or not
+func (r *ShareLinkRepository) Get(accountID, id int64) (*models.ShareLink, error) {
+	query := `SELECT ` + shareLinkColumns + shareLinkFrom + ` WHERE s.id = ? AND s.account_id = ?`
+	link, err := scanShareLink(r.db.QueryRow(query, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share link: %w", err)
+	}
+	return link, nil
+}
+
+// GetByToken looks up a share link that is neither revoked nor expired from
+// its plain token
+func (r *ShareLinkRepository) GetByToken(token string, now time.Time) (*models.ShareLink, error) {
+	query := `SELECT ` + shareLinkColumns + shareLinkFrom + ` WHERE s.token_hash = ? AND s.revoked_at IS NULL`
+	link, err := scanShareLink(r.db.QueryRow(query, hashToken(token)))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share link: %w", err)
+	}
+	if !now.Before(link.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return link, nil
+}
+
+// Revoke revokes one of an account's share links
+func (r *ShareLinkRepository) Revoke(accountID, id int64) error {
+	result, err := r.db.Exec(`
+		UPDATE share_links SET revoked_at = ?
+		WHERE id = ? AND account_id = ? AND revoked_at IS NULL
+	`, time.Now(), id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke share link: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordAccess logs a view of a share link
+func (r *ShareLinkRepository) RecordAccess(id int64, ipAddress, userAgent string, when time.Time) error {
+	if _, err := r.db.Exec(`
+		INSERT INTO share_link_accesses (share_link_id, accessed_at, ip_address, user_agent)
+		VALUES (?, ?, ?, ?)
+	`, id, when, ipAddress, userAgent); err != nil {
+		return fmt.Errorf("failed to record share link access: %w", err)
+	}
+	if _, err := r.db.Exec(`UPDATE share_links SET last_used_at = ? WHERE id = ?`, when, id); err != nil {
+		return fmt.Errorf("failed to update share link: %w", err)
+	}
+	return nil
+}
+
+// ListAccesses retrieves the most recent views of a share link, newest first
+func (r *ShareLinkRepository) ListAccesses(id int64, limit int) ([]*models.ShareLinkAccess, error) {
+	rows, err := r.db.Query(`
+		SELECT id, share_link_id, accessed_at, ip_address, user_agent
+		FROM share_link_accesses
+		WHERE share_link_id = ?
+		ORDER BY accessed_at DESC, id DESC
+		LIMIT ?
+	`, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query share link accesses: %w", err)
+	}
+	defer rows.Close()
+
+	var accesses []*models.ShareLinkAccess
+	for rows.Next() {
+		var a models.ShareLinkAccess
+		if err := rows.Scan(&a.ID, &a.ShareLinkID, &a.AccessedAt, &a.IPAddress, &a.UserAgent); err != nil {
+			return nil, fmt.Errorf("failed to scan share link access: %w", err)
+		}
+		accesses = append(accesses, &a)
+	}
+	return accesses, rows.Err()
+}
+
+func scanShareLink(row rowScanner) (*models.ShareLink, error) {
+	var link models.ShareLink
+	var scopes string
+	err := row.Scan(&link.ID, &link.AccountID, &link.CreatedBy, &link.Name, &link.TokenHash, &scopes, &link.ExpiresAt,
+		&link.CreatedAt, &link.LastUsedAt, &link.RevokedAt, &link.CreatorUsername)
+	if err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		link.Scopes = strings.Split(scopes, ",")
+	}
+	return &link, nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShareLinkRepository(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewShareLinkRepository(db)
+	now := time.Now()
+
+	link, token, err := repo.Create(1, 1, "Nurse", []string{"injections", "schedule"}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create share link: %v", err)
+	}
+	expired, expiredToken, err := repo.Create(1, 1, "Old", []string{"adherence"}, now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create share link: %v", err)
+	}
+
+	got, err := repo.GetByToken(token, now)
+	if err != nil || got.ID != link.ID || len(got.Scopes) != 2 || got.Scopes[1] != "schedule" {
+		t.Fatalf("Expected link %d with its scopes, got %+v (%v)", link.ID, got, err)
+	}
+	if _, err := repo.GetByToken(expiredToken, now); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an expired link, got %v", err)
+	}
+	if _, err := repo.GetByToken("nope", now); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown token, got %v", err)
+	}
+
+	if err := repo.RecordAccess(link.ID, "10.0.0.1", "test", now); err != nil {
+		t.Fatalf("Failed to record access: %v", err)
+	}
+	accesses, err := repo.ListAccesses(link.ID, 10)
+	if err != nil || len(accesses) != 1 || accesses[0].IPAddress.String != "10.0.0.1" {
+		t.Fatalf("Expected one access, got %d (%v)", len(accesses), err)
+	}
+
+	links, err := repo.List(1)
+	if err != nil || len(links) != 2 {
+		t.Fatalf("Expected 2 links, got %d (%v)", len(links), err)
+	}
+	for _, l := range links {
+		if l.LastUsedAt.Valid != (l.ID == link.ID) {
+			t.Errorf("Expected only the used link to have last_used_at, got %+v", l)
+		}
+		if l.CreatorUsername == "" {
+			t.Errorf("Expected the creator's username, got %+v", l)
+		}
+	}
+
+	if err := repo.Revoke(2, link.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound revoking from another account, got %v", err)
+	}
+	if err := repo.Revoke(1, link.ID); err != nil {
+		t.Fatalf("Failed to revoke share link: %v", err)
+	}
+	if _, err := repo.GetByToken(token, now); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a revoked link, got %v", err)
+	}
+	if links, _ := repo.List(1); len(links) != 1 || links[0].ID != expired.ID {
+		t.Errorf("Expected only the expired link listed after revoking, got %d", len(links))
+	}
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// StockAlertRepository keeps the state of an account's low stock alerts:
+// their level, when they were last delivered and whether anyone has
+// acknowledged them
+type StockAlertRepository struct {
+	db *database.DB
+}
+
+func NewStockAlertRepository(db *database.DB) *StockAlertRepository {
+	return &StockAlertRepository{db: db}
+}
+
+const stockAlertColumns = `id, account_id, item_type, level, quantity, threshold, raised_at, notified_at, notify_count,
+	acknowledged_at, acknowledged_by, resolved_at, created_at, updated_at`
+
+func scanStockAlert(row rowScanner) (*models.StockAlert, error) {
+	var a models.StockAlert
+	err := row.Scan(
+		&a.ID,
+		&a.AccountID,
+		&a.ItemType,
+		&a.Level,
+		&a.Quantity,
+		&a.Threshold,
+		&a.RaisedAt,
+		&a.NotifiedAt,
+		&a.NotifyCount,
+		&a.AcknowledgedAt,
+		&a.AcknowledgedBy,
+		&a.ResolvedAt,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetActive returns an item's active alert, or ErrNotFound if it has none
+func (r *StockAlertRepository) GetActive(accountID int64, itemType string) (*models.StockAlert, error) {
+	a, err := scanStockAlert(r.db.QueryRow(`
+		SELECT `+stockAlertColumns+` FROM stock_alerts
+		WHERE account_id = ? AND item_type = ? AND resolved_at IS NULL
+	`, accountID, itemType))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock alert: %w", err)
+	}
+	return a, nil
+}
+
+// ListActive returns the account's active alerts, most severe first
+func (r *StockAlertRepository) ListActive(accountID int64) ([]*models.StockAlert, error) {
+	rows, err := r.db.Query(`
+		SELECT `+stockAlertColumns+` FROM stock_alerts
+		WHERE account_id = ? AND resolved_at IS NULL
+		ORDER BY CASE level WHEN 'out' THEN 1 WHEN 'critical' THEN 2 ELSE 3 END, raised_at
+	`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stock alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []*models.StockAlert{}
+	for rows.Next() {
+		a, err := scanStockAlert(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock alert: %w", err)
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// Raise records a new active alert for an item
+func (r *StockAlertRepository) Raise(a *models.StockAlert) error {
+	now := time.Now()
+	a.RaisedAt = now
+	err := r.db.QueryRow(`
+		INSERT INTO stock_alerts (account_id, item_type, level, quantity, threshold, raised_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, a.AccountID, a.ItemType, a.Level, a.Quantity, a.Threshold, now, now, now).Scan(&a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to raise stock alert: %w", err)
+	}
+	a.CreatedAt = now
+	a.UpdatedAt = now
+	return nil
+}
+
+// Update saves an active alert's level, quantity, threshold and
+// acknowledgement
+func (r *StockAlertRepository) Update(a *models.StockAlert) error {
+	now := time.Now()
+	_, err := r.db.Exec(`
+		UPDATE stock_alerts
+		SET level = ?, quantity = ?, threshold = ?, acknowledged_at = ?, acknowledged_by = ?, updated_at = ?
+		WHERE id = ?
+	`, a.Level, a.Quantity, a.Threshold, a.AcknowledgedAt, a.AcknowledgedBy, now, a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update stock alert: %w", err)
+	}
+	a.UpdatedAt = now
+	return nil
+}
+
+// MarkNotified records that an alert was delivered at the given time
+func (r *StockAlertRepository) MarkNotified(a *models.StockAlert, at time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE stock_alerts SET notified_at = ?, notify_count = notify_count + 1, updated_at = ? WHERE id = ?
+	`, at, at, a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to mark stock alert notified: %w", err)
+	}
+	a.NotifiedAt = sql.NullTime{Time: at, Valid: true}
+	a.NotifyCount++
+	return nil
+}
+
+// Acknowledge stops an active alert being repeated until its level
+// escalates. It returns ErrNotFound if the alert isn't one of the account's
+// active alerts.
+func (r *StockAlertRepository) Acknowledge(id, accountID, userID int64) (*models.StockAlert, error) {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE stock_alerts
+		SET acknowledged_at = COALESCE(acknowledged_at, ?), acknowledged_by = COALESCE(acknowledged_by, ?), updated_at = ?
+		WHERE id = ? AND account_id = ? AND resolved_at IS NULL
+	`, now, userID, now, id, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acknowledge stock alert: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil, ErrNotFound
+	}
+
+	a, err := scanStockAlert(r.db.QueryRow(`SELECT `+stockAlertColumns+` FROM stock_alerts WHERE id = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock alert: %w", err)
+	}
+	return a, nil
+}
+
+// Resolve closes an alert once its item is back above the threshold
+func (r *StockAlertRepository) Resolve(a *models.StockAlert) error {
+	now := time.Now()
+	_, err := r.db.Exec(`
+		UPDATE stock_alerts SET resolved_at = ?, updated_at = ? WHERE id = ? AND resolved_at IS NULL
+	`, now, now, a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve stock alert: %w", err)
+	}
+	a.ResolvedAt = sql.NullTime{Time: now, Valid: true}
+	return nil
+}
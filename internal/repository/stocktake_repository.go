@@ -0,0 +1,466 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// ErrStocktakeInProgress is returned when starting a stocktake while the
+// account already has one open
+var ErrStocktakeInProgress = errors.New("a stocktake is already in progress")
+
+// ErrStocktakeClosed is returned when counting, completing or cancelling a
+// stocktake that is no longer open
+var ErrStocktakeClosed = errors.New("stocktake is no longer open")
+
+// ErrStocktakeCountMixed is returned when counting an item by lot after
+// counting it as a whole, or the other way round
+var ErrStocktakeCountMixed = errors.New("item is already counted the other way; count it either as a whole or by lot")
+
+// StocktakeRepository records physical counts of an account's stock and
+// posts the differences to its inventory
+type StocktakeRepository struct {
+	db *database.DB
+}
+
+func NewStocktakeRepository(db *database.DB) *StocktakeRepository {
+	return &StocktakeRepository{db: db}
+}
+
+const stocktakeColumns = `id, account_id, status, notes, started_by, started_at, closed_by, closed_at, created_at, updated_at`
+
+func scanStocktake(row rowScanner) (*models.Stocktake, error) {
+	var s models.Stocktake
+	err := row.Scan(
+		&s.ID,
+		&s.AccountID,
+		&s.Status,
+		&s.Notes,
+		&s.StartedBy,
+		&s.StartedAt,
+		&s.ClosedBy,
+		&s.ClosedAt,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Start opens a stocktake for the account. It returns ErrStocktakeInProgress
+// if one is already open.
+func (r *StocktakeRepository) Start(s *models.Stocktake) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var open int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM stocktakes WHERE account_id = ? AND status = 'open'`, s.AccountID).Scan(&open); err != nil {
+		return fmt.Errorf("failed to check for an open stocktake: %w", err)
+	}
+	if open > 0 {
+		return ErrStocktakeInProgress
+	}
+
+	now := time.Now()
+	s.Status = models.StocktakeOpen
+	s.StartedAt = now
+	err = tx.QueryRow(`
+		INSERT INTO stocktakes (account_id, status, notes, started_by, started_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, s.AccountID, s.Status, s.Notes, s.StartedBy, now.UTC(), now, now).Scan(&s.ID)
+	if err != nil {
+		return fmt.Errorf("failed to start stocktake: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	s.CreatedAt = now
+	s.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves a stocktake, scoped to the account
+func (r *StocktakeRepository) GetByID(id, accountID int64) (*models.Stocktake, error) {
+	query := `SELECT ` + stocktakeColumns + ` FROM stocktakes WHERE id = ? AND account_id = ?`
+	s, err := scanStocktake(r.db.QueryRow(query, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stocktake: %w", err)
+	}
+	return s, nil
+}
+
+// List retrieves an account's stocktakes, most recent first
+func (r *StocktakeRepository) List(accountID int64, limit int) ([]*models.Stocktake, error) {
+	rows, err := r.db.Query(`SELECT `+stocktakeColumns+` FROM stocktakes
+		WHERE account_id = ? ORDER BY started_at DESC, id DESC LIMIT ?`, accountID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stocktakes: %w", err)
+	}
+	defer rows.Close()
+
+	stocktakes := []*models.Stocktake{}
+	for rows.Next() {
+		s, err := scanStocktake(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stocktake: %w", err)
+		}
+		stocktakes = append(stocktakes, s)
+	}
+	return stocktakes, rows.Err()
+}
+
+// ListCounts retrieves a stocktake's counts by item, whole-item counts
+// before lot counts
+func (r *StocktakeRepository) ListCounts(stocktakeID int64) ([]*models.StocktakeCount, error) {
+	return listStocktakeCounts(r.db, stocktakeID)
+}
+
+func listStocktakeCounts(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, stocktakeID int64) ([]*models.StocktakeCount, error) {
+	rows, err := q.Query(`
+		SELECT c.id, c.stocktake_id, c.item_type, c.lot_id, l.lot_number, c.recorded_quantity, c.counted_quantity,
+		       c.notes, c.counted_by, c.counted_at
+		FROM stocktake_counts c
+		LEFT JOIN inventory_lots l ON l.id = c.lot_id
+		WHERE c.stocktake_id = ?
+		ORDER BY c.item_type, c.lot_id IS NOT NULL, l.received_at, c.lot_id
+	`, stocktakeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stocktake counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []*models.StocktakeCount{}
+	for rows.Next() {
+		var c models.StocktakeCount
+		err := rows.Scan(&c.ID, &c.StocktakeID, &c.ItemType, &c.LotID, &c.LotNumber, &c.RecordedQuantity,
+			&c.CountedQuantity, &c.Notes, &c.CountedBy, &c.CountedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stocktake count: %w", err)
+		}
+		counts = append(counts, &c)
+	}
+	return counts, rows.Err()
+}
+
+// checkStocktakeOpen returns ErrStocktakeClosed unless the stocktake is open
+func checkStocktakeOpen(tx *sql.Tx, s *models.Stocktake) error {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM stocktakes WHERE id = ? AND account_id = ?`, s.ID, s.AccountID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get stocktake: %w", err)
+	}
+	if status != models.StocktakeOpen {
+		return ErrStocktakeClosed
+	}
+	return nil
+}
+
+// RecordCount saves what was found of an item, or of one of its lots, with
+// the quantity the inventory holds right now as the recorded quantity.
+// Counting the same item or lot again replaces the earlier count. The
+// caller checks the lot belongs to the account and item.
+func (r *StocktakeRepository) RecordCount(s *models.Stocktake, c *models.StocktakeCount) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkStocktakeOpen(tx, s); err != nil {
+		return err
+	}
+
+	// An item is counted either as a whole or lot by lot, never both
+	var other int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM stocktake_counts
+		WHERE stocktake_id = ? AND item_type = ? AND (lot_id IS NULL) = ?
+	`, s.ID, c.ItemType, c.LotID.Valid).Scan(&other)
+	if err != nil {
+		return fmt.Errorf("failed to check stocktake counts: %w", err)
+	}
+	if other > 0 {
+		return ErrStocktakeCountMixed
+	}
+
+	if c.LotID.Valid {
+		err = tx.QueryRow(`SELECT quantity_remaining, lot_number FROM inventory_lots WHERE id = ?`, c.LotID).
+			Scan(&c.RecordedQuantity, &c.LotNumber)
+	} else {
+		err = tx.QueryRow(`SELECT quantity FROM inventory_items WHERE item_type = ? AND account_id = ?`,
+			c.ItemType, s.AccountID).Scan(&c.RecordedQuantity)
+		if err == sql.ErrNoRows {
+			c.RecordedQuantity, err = 0, nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get recorded quantity: %w", err)
+	}
+
+	c.StocktakeID = s.ID
+	c.CountedAt = time.Now()
+	if c.LotID.Valid {
+		err = tx.QueryRow(`SELECT id FROM stocktake_counts WHERE stocktake_id = ? AND lot_id = ?`, s.ID, c.LotID).Scan(&c.ID)
+	} else {
+		err = tx.QueryRow(`SELECT id FROM stocktake_counts WHERE stocktake_id = ? AND item_type = ? AND lot_id IS NULL`,
+			s.ID, c.ItemType).Scan(&c.ID)
+	}
+	switch {
+	case err == sql.ErrNoRows:
+		err = tx.QueryRow(`
+			INSERT INTO stocktake_counts (stocktake_id, item_type, lot_id, recorded_quantity, counted_quantity,
+			                              notes, counted_by, counted_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id
+		`, c.StocktakeID, c.ItemType, c.LotID, c.RecordedQuantity, c.CountedQuantity, c.Notes, c.CountedBy,
+			c.CountedAt.UTC()).Scan(&c.ID)
+	case err == nil:
+		_, err = tx.Exec(`
+			UPDATE stocktake_counts
+			SET recorded_quantity = ?, counted_quantity = ?, notes = ?, counted_by = ?, counted_at = ?
+			WHERE id = ?
+		`, c.RecordedQuantity, c.CountedQuantity, c.Notes, c.CountedBy, c.CountedAt.UTC(), c.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save stocktake count: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DeleteCount removes a count from an open stocktake
+func (r *StocktakeRepository) DeleteCount(s *models.Stocktake, countID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkStocktakeOpen(tx, s); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM stocktake_counts WHERE id = ? AND stocktake_id = ?`, countID, s.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete stocktake count: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// Complete closes a stocktake and posts each counted item's variance to its
+// stock as one inventory history entry with reason 'stocktake'. The
+// variance is applied as a change rather than setting the counted quantity,
+// so anything used or received after the count still stands. Lot counts
+// adjust their lots; a whole-item shortfall comes out of the oldest lots and
+// a whole-item surplus isn't attributed to any lot. Nothing goes below
+// zero. It returns the history entries posted.
+func (r *StocktakeRepository) Complete(s *models.Stocktake, userID int64) ([]*models.InventoryHistory, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkStocktakeOpen(tx, s); err != nil {
+		return nil, err
+	}
+
+	counts, err := listStocktakeCounts(tx, s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	type itemVariance struct {
+		itemType     string
+		recorded     float64
+		counted      float64
+		variance     float64
+		lotsCounted  bool
+		lotsAdjusted bool
+	}
+	var items []*itemVariance
+	byType := map[string]*itemVariance{}
+	for _, c := range counts {
+		item := byType[c.ItemType]
+		if item == nil {
+			item = &itemVariance{itemType: c.ItemType}
+			byType[c.ItemType] = item
+			items = append(items, item)
+		}
+		item.recorded += c.RecordedQuantity
+		item.counted += c.CountedQuantity
+		item.variance += c.Variance()
+
+		if !c.LotID.Valid || c.Variance() == 0 {
+			continue
+		}
+		item.lotsCounted = true
+		var remaining float64
+		err := tx.QueryRow(`SELECT quantity_remaining FROM inventory_lots WHERE id = ?`, c.LotID).Scan(&remaining)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get lot: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE inventory_lots SET quantity_remaining = ? WHERE id = ?`,
+			max(remaining+c.Variance(), 0), c.LotID); err != nil {
+			return nil, fmt.Errorf("failed to adjust lot: %w", err)
+		}
+		item.lotsAdjusted = true
+	}
+
+	now := time.Now()
+	var posted []*models.InventoryHistory
+	for _, item := range items {
+		if item.lotsAdjusted {
+			if err := syncActiveLot(tx, s.AccountID, item.itemType); err != nil {
+				return nil, err
+			}
+		}
+		if item.variance == 0 {
+			continue
+		}
+
+		var currentQty float64
+		err := tx.QueryRow(`SELECT quantity FROM inventory_items WHERE item_type = ? AND account_id = ?`,
+			item.itemType, s.AccountID).Scan(&currentQty)
+		if err == sql.ErrNoRows {
+			// Found stock of an item the account never stocked before
+			_, err = tx.Exec(`
+				INSERT INTO inventory_items (item_type, quantity, unit, low_stock_threshold, account_id, created_at, updated_at)
+				SELECT item_type, 0, unit, reorder_threshold, account_id, ?, ?
+				FROM inventory_item_types WHERE item_type = ? AND account_id = ?
+			`, now, now, item.itemType, s.AccountID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current inventory for %s: %w", item.itemType, err)
+		}
+
+		newQty := max(currentQty+item.variance, 0)
+		change := newQty - currentQty
+		if change == 0 {
+			continue
+		}
+		if _, err := tx.Exec(`
+			UPDATE inventory_items SET quantity = ?, updated_at = ? WHERE item_type = ? AND account_id = ?
+		`, newQty, now, item.itemType, s.AccountID); err != nil {
+			return nil, fmt.Errorf("failed to update inventory for %s: %w", item.itemType, err)
+		}
+		if change < 0 && !item.lotsCounted {
+			if _, err := ConsumeLotsFIFO(tx, s.AccountID, item.itemType, -change, sql.NullInt64{}); err != nil {
+				return nil, err
+			}
+		}
+
+		h := &models.InventoryHistory{
+			ItemType:       item.itemType,
+			ChangeAmount:   change,
+			QuantityBefore: currentQty,
+			QuantityAfter:  newQty,
+			Reason:         "stocktake",
+			ReferenceID:    sql.NullInt64{Int64: s.ID, Valid: true},
+			ReferenceType:  sql.NullString{String: "stocktake", Valid: true},
+			PerformedBy:    sql.NullInt64{Int64: userID, Valid: true},
+			Timestamp:      now,
+			Notes: sql.NullString{String: fmt.Sprintf("Stocktake #%d: counted %s against %s recorded",
+				s.ID, formatML(item.counted), formatML(item.recorded)), Valid: true},
+		}
+		err = tx.QueryRow(`
+			INSERT INTO inventory_history (
+				account_id, item_type, change_amount, quantity_before, quantity_after,
+				reason, reference_id, reference_type, performed_by, timestamp, notes
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id
+		`, s.AccountID, h.ItemType, h.ChangeAmount, h.QuantityBefore, h.QuantityAfter, h.Reason, h.ReferenceID,
+			h.ReferenceType, h.PerformedBy, h.Timestamp, h.Notes).Scan(&h.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to log stocktake adjustment: %w", err)
+		}
+		posted = append(posted, h)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE stocktakes SET status = 'completed', closed_by = ?, closed_at = ?, updated_at = ? WHERE id = ?
+	`, userID, now.UTC(), now, s.ID); err != nil {
+		return nil, fmt.Errorf("failed to complete stocktake: %w", err)
+	}
+	if err := logAudit(r.db.Context(), tx, userID, "complete", "stocktake", s.ID,
+		fmt.Sprintf("Completed stocktake #%d: %d item(s) counted, %d adjusted", s.ID, len(items), len(posted))); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	s.Status = models.StocktakeCompleted
+	s.ClosedBy = sql.NullInt64{Int64: userID, Valid: true}
+	s.ClosedAt = sql.NullTime{Time: now, Valid: true}
+	return posted, nil
+}
+
+// Cancel closes a stocktake without touching stock. Its counts are kept.
+func (r *StocktakeRepository) Cancel(s *models.Stocktake, userID int64) error {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE stocktakes SET status = 'cancelled', closed_by = ?, closed_at = ?, updated_at = ?
+		WHERE id = ? AND account_id = ? AND status = 'open'
+	`, userID, now.UTC(), now, s.ID, s.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel stocktake: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrStocktakeClosed
+	}
+	s.Status = models.StocktakeCancelled
+	s.ClosedBy = sql.NullInt64{Int64: userID, Valid: true}
+	s.ClosedAt = sql.NullTime{Time: now, Valid: true}
+	return nil
+}
+
+// Adjustments retrieves the inventory history entries a completed stocktake
+// posted
+func (r *StocktakeRepository) Adjustments(stocktakeID int64) ([]*models.InventoryHistory, error) {
+	rows, err := r.db.Query(`
+		SELECT h.id, h.item_type, h.change_amount, h.quantity_before, h.quantity_after, h.reason, h.reference_id, h.reference_type, h.performed_by, h.timestamp, h.notes, h.profile_version, h.reversed_by
+		FROM inventory_history h
+		WHERE h.reference_type = 'stocktake' AND h.reference_id = ?
+		ORDER BY h.item_type, h.id
+	`, stocktakeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stocktake adjustments: %w", err)
+	}
+	defer rows.Close()
+
+	return NewInventoryRepository(r.db).scanInventoryHistory(rows)
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"injection-tracker/internal/models"
+)
+
+func TestStocktakeRepository_CountAndComplete(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	swab := &models.InventoryItemType{AccountID: 1, ItemType: "swab", Name: "Alcohol Swabs", Unit: "count"}
+	gauze := &models.InventoryItemType{AccountID: 1, ItemType: "gauze", Name: "Gauze Pads", Unit: "count"}
+	itemTypes := NewInventoryItemTypeRepository(db)
+	for _, def := range []*models.InventoryItemType{swab, gauze} {
+		if err := itemTypes.Create(def); err != nil {
+			t.Fatalf("Failed to create item type: %v", err)
+		}
+	}
+
+	inventory := NewInventoryRepository(db)
+	for _, qty := range []float64{20, 10} {
+		if _, err := inventory.ApplyAdjustment(swab, 1, 1, InventoryAdjustment{Change: qty, Reason: "restock"}); err != nil {
+			t.Fatalf("Failed to restock: %v", err)
+		}
+	}
+	lots, err := NewInventoryLotRepository(db).ListByItemType(1, "swab", false)
+	if err != nil || len(lots) != 2 {
+		t.Fatalf("Expected 2 swab lots, got %d (%v)", len(lots), err)
+	}
+
+	repo := NewStocktakeRepository(db)
+	stocktake := &models.Stocktake{AccountID: 1, StartedBy: sql.NullInt64{Int64: 1, Valid: true}}
+	if err := repo.Start(stocktake); err != nil {
+		t.Fatalf("Failed to start stocktake: %v", err)
+	}
+	if err := repo.Start(&models.Stocktake{AccountID: 1}); !errors.Is(err, ErrStocktakeInProgress) {
+		t.Errorf("Expected ErrStocktakeInProgress, got %v", err)
+	}
+
+	// Two swabs are missing from the first lot, and gauze that was never
+	// stocked turns up
+	lotCount := &models.StocktakeCount{ItemType: "swab", LotID: sql.NullInt64{Int64: lots[0].ID, Valid: true}, CountedQuantity: 18}
+	if err := repo.RecordCount(stocktake, lotCount); err != nil {
+		t.Fatalf("Failed to record lot count: %v", err)
+	}
+	if lotCount.RecordedQuantity != 20 || lotCount.Variance() != -2 {
+		t.Errorf("Expected 20 recorded and a variance of -2, got %v and %v", lotCount.RecordedQuantity, lotCount.Variance())
+	}
+	if err := repo.RecordCount(stocktake, &models.StocktakeCount{ItemType: "swab", CountedQuantity: 28}); !errors.Is(err, ErrStocktakeCountMixed) {
+		t.Errorf("Expected ErrStocktakeCountMixed, got %v", err)
+	}
+	if err := repo.RecordCount(stocktake, &models.StocktakeCount{ItemType: "gauze", CountedQuantity: 3}); err != nil {
+		t.Fatalf("Failed to record gauze count: %v", err)
+	}
+	recount := &models.StocktakeCount{ItemType: "gauze", CountedQuantity: 5}
+	if err := repo.RecordCount(stocktake, recount); err != nil {
+		t.Fatalf("Failed to recount gauze: %v", err)
+	}
+	counts, err := repo.ListCounts(stocktake.ID)
+	if err != nil || len(counts) != 2 {
+		t.Fatalf("Expected the recount to replace the first, got %d counts (%v)", len(counts), err)
+	}
+
+	// Swabs used after the count still come off
+	if _, err := inventory.ApplyAdjustment(swab, 1, 1, InventoryAdjustment{Change: -3, Reason: "manual_adjustment"}); err != nil {
+		t.Fatalf("Failed to use swabs: %v", err)
+	}
+
+	posted, err := repo.Complete(stocktake, 1)
+	if err != nil {
+		t.Fatalf("Failed to complete stocktake: %v", err)
+	}
+	if len(posted) != 2 || posted[0].ItemType != "gauze" || posted[1].ItemType != "swab" {
+		t.Fatalf("Expected gauze and swab adjustments, got %d", len(posted))
+	}
+	if posted[1].Reason != "stocktake" || posted[1].ChangeAmount != -2 || posted[1].QuantityAfter != 25 {
+		t.Errorf("Expected swabs to go from 27 to 25, got %+v", posted[1])
+	}
+	if got := stockOf(t, db, "gauze"); got != 5 {
+		t.Errorf("Expected 5 gauze pads, got %v", got)
+	}
+	lot, err := NewInventoryLotRepository(db).GetByID(lots[0].ID, 1)
+	if err != nil || lot.QuantityRemaining != 15 {
+		t.Errorf("Expected the first lot to hold 15 after use and the shortfall, got %+v (%v)", lot, err)
+	}
+
+	adjustments, err := repo.Adjustments(stocktake.ID)
+	if err != nil || len(adjustments) != 2 {
+		t.Errorf("Expected the 2 posted entries back, got %d (%v)", len(adjustments), err)
+	}
+
+	if stocktake.Status != models.StocktakeCompleted {
+		t.Errorf("Expected the stocktake completed, got %s", stocktake.Status)
+	}
+	if err := repo.RecordCount(stocktake, &models.StocktakeCount{ItemType: "gauze", CountedQuantity: 1}); !errors.Is(err, ErrStocktakeClosed) {
+		t.Errorf("Expected ErrStocktakeClosed, got %v", err)
+	}
+
+	next := &models.Stocktake{AccountID: 1}
+	if err := repo.Start(next); err != nil {
+		t.Fatalf("Failed to start another stocktake: %v", err)
+	}
+	if err := repo.Cancel(next, 1); err != nil {
+		t.Fatalf("Failed to cancel stocktake: %v", err)
+	}
+	if err := repo.Cancel(next, 1); !errors.Is(err, ErrStocktakeClosed) {
+		t.Errorf("Expected ErrStocktakeClosed cancelling twice, got %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// SymptomCatalogRepository stores the symptoms each account can log
+type SymptomCatalogRepository struct {
+	db *database.DB
+}
+
+func NewSymptomCatalogRepository(db *database.DB) *SymptomCatalogRepository {
+	return &SymptomCatalogRepository{db: db}
+}
+
+// DefaultSymptomCatalog is created for every new account. The keys are the
+// ones the symptom form used before catalogs existed.
+var DefaultSymptomCatalog = []models.SymptomCatalogItem{
+	{Key: "nausea", Name: "Nausea", Category: "digestive", SortOrder: 0},
+	{Key: "fatigue", Name: "Fatigue", Category: "general", SortOrder: 1},
+	{Key: "headache", Name: "Headache", Category: "general", SortOrder: 2},
+	{Key: "mood_changes", Name: "Mood Changes", Category: "mood", SortOrder: 3},
+	{Key: "bloating", Name: "Bloating", Category: "digestive", SortOrder: 4},
+	{Key: "breast_tenderness", Name: "Tenderness", Category: "hormonal", SortOrder: 5},
+	{Key: "spotting", Name: "Spotting", Category: "hormonal", SortOrder: 6},
+	{Key: "hot_flashes", Name: "Hot Flashes", Category: "hormonal", SortOrder: 7},
+	{Key: "dizziness", Name: "Dizziness", Category: "general", SortOrder: 8},
+	{Key: "knots", Name: "Knots", Category: "injection_site", SortOrder: 9},
+}
+
+// SymptomKey is the catalog key a free-text symptom is stored under, the
+// same one migration 028 gave symptoms that logs already used
+func SymptomKey(symptom string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(symptom)), " ", "_")
+}
+
+const symptomCatalogColumns = `id, account_id, key, name, category, scale_type, sort_order, is_archived, created_at, updated_at`
+
+// seedSymptomCatalog creates the default symptoms for a new account
+func seedSymptomCatalog(tx *sql.Tx, accountID int64) error {
+	for _, s := range DefaultSymptomCatalog {
+		_, err := tx.Exec(`
+			INSERT INTO symptom_catalog (account_id, key, name, category, scale_type, sort_order)
+			VALUES (?, ?, ?, ?, 'presence', ?)
+			ON CONFLICT DO NOTHING
+		`, accountID, s.Key, s.Name, s.Category, s.SortOrder)
+		if err != nil {
+			return fmt.Errorf("failed to seed symptom %s: %w", s.Key, err)
+		}
+	}
+	return nil
+}
+
+// Create adds a symptom to an account's catalog
+func (r *SymptomCatalogRepository) Create(item *models.SymptomCatalogItem) error {
+	now := time.Now()
+	err := r.db.QueryRow(`
+		INSERT INTO symptom_catalog (account_id, key, name, category, scale_type, sort_order, is_archived, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, item.AccountID, item.Key, item.Name, item.Category, item.ScaleType, item.SortOrder, item.IsArchived, now, now).Scan(&item.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create symptom: %w", err)
+	}
+	item.CreatedAt = now
+	item.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves one of an account's symptoms
+func (r *SymptomCatalogRepository) GetByID(id, accountID int64) (*models.SymptomCatalogItem, error) {
+	query := `SELECT ` + symptomCatalogColumns + ` FROM symptom_catalog WHERE id = ? AND account_id = ?`
+	return r.scanItem(r.db.QueryRow(query, id, accountID))
+}
+
+// GetByKey retrieves an account's symptom by its key
+func (r *SymptomCatalogRepository) GetByKey(key string, accountID int64) (*models.SymptomCatalogItem, error) {
+	query := `SELECT ` + symptomCatalogColumns + ` FROM symptom_catalog WHERE key = ? AND account_id = ?`
+	return r.scanItem(r.db.QueryRow(query, key, accountID))
+}
+
+// List retrieves an account's symptoms in display order, leaving out
+// archived ones unless includeArchived is set
+func (r *SymptomCatalogRepository) List(accountID int64, includeArchived bool) ([]*models.SymptomCatalogItem, error) {
+	query := `SELECT ` + symptomCatalogColumns + ` FROM symptom_catalog WHERE account_id = ?`
+	if !includeArchived {
+		query += ` AND is_archived = FALSE`
+	}
+	query += ` ORDER BY sort_order, name`
+
+	rows, err := r.db.Query(query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query symptom catalog: %w", err)
+	}
+	defer rows.Close()
+
+	items := []*models.SymptomCatalogItem{}
+	for rows.Next() {
+		var item models.SymptomCatalogItem
+		err := rows.Scan(&item.ID, &item.AccountID, &item.Key, &item.Name, &item.Category, &item.ScaleType,
+			&item.SortOrder, &item.IsArchived, &item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan symptom: %w", err)
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+// Update saves a symptom's name, category, scale type, sort order and
+// archived flag. The key is fixed once created.
+func (r *SymptomCatalogRepository) Update(item *models.SymptomCatalogItem) error {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE symptom_catalog
+		SET name = ?, category = ?, scale_type = ?, sort_order = ?, is_archived = ?, updated_at = ?
+		WHERE id = ? AND account_id = ?
+	`, item.Name, item.Category, item.ScaleType, item.SortOrder, item.IsArchived, now, item.ID, item.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to update symptom: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	item.UpdatedAt = now
+	return nil
+}
+
+// Delete removes a symptom from the catalog, along with every log's record
+// of it. Callers archive symptoms that logs use instead.
+func (r *SymptomCatalogRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec(`DELETE FROM symptom_catalog WHERE id = ? AND account_id = ?`, id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete symptom: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CountUses returns how many symptom logs record a catalog symptom
+func (r *SymptomCatalogRepository) CountUses(id int64) (int64, error) {
+	var count int64
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM symptom_log_items WHERE catalog_id = ?`, id).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count symptom uses: %w", err)
+	}
+	return count, nil
+}
+
+// SetLogItems replaces the catalog symptoms recorded on a symptom log
+func (r *SymptomCatalogRepository) SetLogItems(symptomLogID int64, items []models.SymptomLogItem) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM symptom_log_items WHERE symptom_log_id = ?`, symptomLogID); err != nil {
+		return fmt.Errorf("failed to clear symptom log items: %w", err)
+	}
+	for _, item := range items {
+		_, err := tx.Exec(`INSERT INTO symptom_log_items (symptom_log_id, catalog_id, severity) VALUES (?, ?, ?)`,
+			symptomLogID, item.CatalogID, item.Severity)
+		if err != nil {
+			return fmt.Errorf("failed to record symptom log item: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ItemsForLogs returns the catalog symptoms recorded on each of the given
+// symptom logs, keyed by log ID
+func (r *SymptomCatalogRepository) ItemsForLogs(symptomLogIDs []int64) (map[int64][]models.SymptomLogItem, error) {
+	items := make(map[int64][]models.SymptomLogItem)
+	if len(symptomLogIDs) == 0 {
+		return items, nil
+	}
+
+	args := make([]interface{}, len(symptomLogIDs))
+	for i, id := range symptomLogIDs {
+		args[i] = id
+	}
+	query := `
+		SELECT sli.symptom_log_id, sli.catalog_id, sli.severity
+		FROM symptom_log_items sli
+		JOIN symptom_catalog sc ON sc.id = sli.catalog_id
+		WHERE sli.symptom_log_id IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ") + `)
+		ORDER BY sc.sort_order, sc.name`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query symptom log items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.SymptomLogItem
+		if err := rows.Scan(&item.SymptomLogID, &item.CatalogID, &item.Severity); err != nil {
+			return nil, fmt.Errorf("failed to scan symptom log item: %w", err)
+		}
+		items[item.SymptomLogID] = append(items[item.SymptomLogID], item)
+	}
+	return items, rows.Err()
+}
+
+func (r *SymptomCatalogRepository) scanItem(row *sql.Row) (*models.SymptomCatalogItem, error) {
+	var item models.SymptomCatalogItem
+	err := row.Scan(&item.ID, &item.AccountID, &item.Key, &item.Name, &item.Category, &item.ScaleType,
+		&item.SortOrder, &item.IsArchived, &item.CreatedAt, &item.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get symptom: %w", err)
+	}
+	return &item, nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"injection-tracker/internal/models"
+)
+
+func TestSymptomCatalogRepository_SeededForNewAccount(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO users (id, username, password_hash) VALUES (2, 'second', 'hash')`); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	accountID, err := NewAccountRepository(db.DB).Create(nil, 2)
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	items, err := NewSymptomCatalogRepository(db).List(accountID, false)
+	if err != nil {
+		t.Fatalf("Failed to list symptom catalog: %v", err)
+	}
+	if len(items) != len(DefaultSymptomCatalog) {
+		t.Fatalf("Expected %d default symptoms, got %d", len(DefaultSymptomCatalog), len(items))
+	}
+	if items[0].Key != "nausea" || items[0].ScaleType != "presence" {
+		t.Errorf("Unexpected first symptom: %+v", items[0])
+	}
+}
+
+func TestSymptomCatalogRepository_LogItems(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewSymptomCatalogRepository(db)
+	cramps := &models.SymptomCatalogItem{AccountID: 1, Key: SymptomKey(" Leg Cramps "), Name: "Leg Cramps", Category: "general", ScaleType: "severity"}
+	if err := repo.Create(cramps); err != nil {
+		t.Fatalf("Failed to create symptom: %v", err)
+	}
+	if cramps.Key != "leg_cramps" {
+		t.Errorf("Expected key leg_cramps, got %q", cramps.Key)
+	}
+	if err := repo.Create(&models.SymptomCatalogItem{AccountID: 1, Key: "leg_cramps", Name: "Again", Category: "general", ScaleType: "presence"}); err == nil {
+		t.Error("Expected a duplicate key to be refused")
+	}
+
+	if _, err := db.Exec(`INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', '2026-01-01', 1)`); err != nil {
+		t.Fatalf("Failed to create course: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO symptom_logs (id, course_id, timestamp) VALUES (1, 1, CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatalf("Failed to create symptom log: %v", err)
+	}
+	err := repo.SetLogItems(1, []models.SymptomLogItem{{CatalogID: cramps.ID, Severity: sql.NullInt64{Int64: 7, Valid: true}}})
+	if err != nil {
+		t.Fatalf("SetLogItems failed: %v", err)
+	}
+
+	items, err := repo.ItemsForLogs([]int64{1, 2})
+	if err != nil {
+		t.Fatalf("ItemsForLogs failed: %v", err)
+	}
+	if len(items[1]) != 1 || items[1][0].CatalogID != cramps.ID || items[1][0].Severity.Int64 != 7 {
+		t.Errorf("Unexpected items for log 1: %+v", items[1])
+	}
+	if uses, err := repo.CountUses(cramps.ID); err != nil || uses != 1 {
+		t.Errorf("Expected 1 use, got %d, %v", uses, err)
+	}
+
+	// Archived symptoms drop out of the default list but keep their history
+	cramps.IsArchived = true
+	if err := repo.Update(cramps); err != nil {
+		t.Fatalf("Failed to archive symptom: %v", err)
+	}
+	listed, err := repo.List(1, false)
+	if err != nil {
+		t.Fatalf("Failed to list symptom catalog: %v", err)
+	}
+	if len(listed) != 0 {
+		t.Errorf("Expected the archived symptom left out, got %d", len(listed))
+	}
+	if all, _ := repo.List(1, true); len(all) != 1 {
+		t.Errorf("Expected the archived symptom with include_archived, got %d", len(all))
+	}
+
+	if err := repo.SetLogItems(1, nil); err != nil {
+		t.Fatalf("Failed to clear log items: %v", err)
+	}
+	if err := repo.Delete(cramps.ID, 1); err != nil {
+		t.Fatalf("Failed to delete symptom: %v", err)
+	}
+	if _, err := repo.GetByID(cramps.ID, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
@@ -9,6 +9,13 @@ import (
 	"injection-tracker/internal/models"
 )
 
+// Symptom log visibility. Private logs are only shown to the member who
+// logged them.
+const (
+	SymptomVisibilityShared  = "shared"
+	SymptomVisibilityPrivate = "private"
+)
+
 type SymptomRepository struct {
 	db *database.DB
 }
@@ -17,13 +24,60 @@ func NewSymptomRepository(db *database.DB) *SymptomRepository {
 	return &SymptomRepository{db: db}
 }
 
+// SymptomViewer is the user symptom logs are read for. They see shared logs
+// and their own private ones, or every log when SeeAll is set.
+type SymptomViewer struct {
+	UserID int64
+	SeeAll bool
+}
+
+// LoadSymptomViewer works out what userID may see of accountID's symptom
+// logs: everything if they own it and it lets owners see private logs
+func LoadSymptomViewer(db *database.DB, accountID, userID int64) (SymptomViewer, error) {
+	viewer := SymptomViewer{UserID: userID}
+	var role string
+	var ownersSeePrivate bool
+	err := db.QueryRow(`
+		SELECT am.role, a.owners_see_private
+		FROM account_members am
+		JOIN accounts a ON a.id = am.account_id
+		WHERE am.account_id = ? AND am.user_id = ?
+	`, accountID, userID).Scan(&role, &ownersSeePrivate)
+	if err == sql.ErrNoRows {
+		return viewer, nil
+	}
+	if err != nil {
+		return viewer, fmt.Errorf("failed to load symptom visibility: %w", err)
+	}
+	viewer.SeeAll = role == "owner" && ownersSeePrivate
+	return viewer, nil
+}
+
+// Condition returns an SQL condition, with its arguments, that keeps only
+// the symptom logs v may see. alias is the symptom_logs table's alias in
+// the query, or "" if it has none.
+func (v SymptomViewer) Condition(alias string) (string, []interface{}) {
+	if v.SeeAll {
+		return "TRUE", nil
+	}
+	if alias != "" {
+		alias += "."
+	}
+	return fmt.Sprintf("(%svisibility = '%s' OR %slogged_by = ?)", alias, SymptomVisibilityShared, alias), []interface{}{v.UserID}
+}
+
 // Create creates a new symptom log entry (course_id must belong to account - verified by caller)
 func (r *SymptomRepository) Create(symptom *models.SymptomLog) error {
+	if symptom.Visibility == "" {
+		symptom.Visibility = SymptomVisibilityShared
+	}
 	query := `
-		INSERT INTO symptom_logs (course_id, logged_by, timestamp, pain_level, pain_location, pain_type, symptoms, notes, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO symptom_logs (course_id, logged_by, timestamp, pain_level, pain_location, pain_type, symptoms, notes, attachment_id, visibility, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id
 	`
-	result, err := r.db.Exec(query,
+	var id int64
+	err := r.db.QueryRow(query,
 		symptom.CourseID,
 		symptom.LoggedBy,
 		symptom.Timestamp,
@@ -32,30 +86,28 @@ func (r *SymptomRepository) Create(symptom *models.SymptomLog) error {
 		symptom.PainType,
 		symptom.Symptoms,
 		symptom.Notes,
-	)
+		symptom.AttachmentID,
+		symptom.Visibility,
+	).Scan(&id)
 	if err != nil {
 		return fmt.Errorf("failed to create symptom log: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	symptom.ID = id
 	return nil
 }
 
 // GetByID retrieves a symptom log by ID and account (ensures data isolation via course)
-func (r *SymptomRepository) GetByID(id int64, accountID int64) (*models.SymptomLog, error) {
+// if viewer may see it
+func (r *SymptomRepository) GetByID(id int64, accountID int64, viewer SymptomViewer) (*models.SymptomLog, error) {
+	visible, visibleArgs := viewer.Condition("s")
 	query := `
-		SELECT s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type, s.symptoms, s.notes, s.created_at, s.updated_at
+		SELECT s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type, s.symptoms, s.notes, s.attachment_id, s.visibility, s.created_at, s.updated_at
 		FROM symptom_logs s
 		JOIN courses c ON c.id = s.course_id
-		WHERE s.id = ? AND c.account_id = ?
-	`
+		WHERE s.id = ? AND c.account_id = ? AND ` + visible
 	var symptom models.SymptomLog
-	err := r.db.QueryRow(query, id, accountID).Scan(
+	err := r.db.QueryRow(query, append([]interface{}{id, accountID}, visibleArgs...)...).Scan(
 		&symptom.ID,
 		&symptom.CourseID,
 		&symptom.LoggedBy,
@@ -65,6 +117,8 @@ func (r *SymptomRepository) GetByID(id int64, accountID int64) (*models.SymptomL
 		&symptom.PainType,
 		&symptom.Symptoms,
 		&symptom.Notes,
+		&symptom.AttachmentID,
+		&symptom.Visibility,
 		&symptom.CreatedAt,
 		&symptom.UpdatedAt,
 	)
@@ -78,14 +132,21 @@ func (r *SymptomRepository) GetByID(id int64, accountID int64) (*models.SymptomL
 	return &symptom, nil
 }
 
-// Update updates a symptom log entry (only if it belongs to the account via course)
-func (r *SymptomRepository) Update(symptom *models.SymptomLog, accountID int64) error {
+// Update updates a symptom log entry (only if it belongs to the account via
+// course). version is the updated_at the changes were made against; if the
+// log has changed since, Update returns ErrStale.
+func (r *SymptomRepository) Update(symptom *models.SymptomLog, accountID int64, version time.Time) error {
+	if symptom.Visibility == "" {
+		symptom.Visibility = SymptomVisibilityShared
+	}
 	query := `
 		UPDATE symptom_logs
-		SET course_id = ?, logged_by = ?, timestamp = ?, pain_level = ?, pain_location = ?, pain_type = ?, symptoms = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?
+		SET course_id = ?, logged_by = ?, timestamp = ?, pain_level = ?, pain_location = ?, pain_type = ?, symptoms = ?, notes = ?, attachment_id = ?, visibility = ?, updated_at = ?
+		WHERE id = ? AND ` + r.db.Dialect.SameTime("updated_at") + `
 		AND EXISTS (SELECT 1 FROM courses WHERE id = ? AND account_id = ?)
 	`
+	// updated_at keeps sub-second precision so it can serve as an ETag
+	now := time.Now().UTC()
 	result, err := r.db.Exec(query,
 		symptom.CourseID,
 		symptom.LoggedBy,
@@ -95,7 +156,11 @@ func (r *SymptomRepository) Update(symptom *models.SymptomLog, accountID int64)
 		symptom.PainType,
 		symptom.Symptoms,
 		symptom.Notes,
+		symptom.AttachmentID,
+		symptom.Visibility,
+		now,
 		symptom.ID,
+		version,
 		symptom.CourseID,
 		accountID,
 	)
@@ -108,8 +173,20 @@ func (r *SymptomRepository) Update(symptom *models.SymptomLog, accountID int64)
 		return fmt.Errorf("failed to check rows affected: %w", err)
 	}
 	if rows == 0 {
-		return ErrNotFound
+		var exists int
+		err := r.db.QueryRow(`
+			SELECT 1 FROM symptom_logs
+			WHERE id = ? AND EXISTS (SELECT 1 FROM courses WHERE id = ? AND account_id = ?)
+		`, symptom.ID, symptom.CourseID, accountID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check symptom log: %w", err)
+		}
+		return ErrStale
 	}
+	symptom.UpdatedAt = now
 
 	return nil
 }
@@ -137,17 +214,19 @@ func (r *SymptomRepository) Delete(id int64, accountID int64) error {
 	return nil
 }
 
-// List retrieves all symptom logs for an account with pagination
-func (r *SymptomRepository) List(accountID int64, limit, offset int) ([]*models.SymptomLog, error) {
+// List retrieves the symptom logs viewer may see in an account, with pagination
+func (r *SymptomRepository) List(accountID int64, viewer SymptomViewer, limit, offset int) ([]*models.SymptomLog, error) {
+	visible, visibleArgs := viewer.Condition("s")
 	query := `
-		SELECT s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type, s.symptoms, s.notes, s.created_at, s.updated_at
+		SELECT s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type, s.symptoms, s.notes, s.attachment_id, s.visibility, s.created_at, s.updated_at
 		FROM symptom_logs s
 		JOIN courses c ON c.id = s.course_id
-		WHERE c.account_id = ?
+		WHERE c.account_id = ? AND ` + visible + `
 		ORDER BY s.timestamp DESC
 		LIMIT ? OFFSET ?
 	`
-	rows, err := r.db.Query(query, accountID, limit, offset)
+	args := append([]interface{}{accountID}, visibleArgs...)
+	rows, err := r.db.Query(query, append(args, limit, offset)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list symptom logs: %w", err)
 	}
@@ -156,17 +235,19 @@ func (r *SymptomRepository) List(accountID int64, limit, offset int) ([]*models.
 	return r.scanSymptomLogs(rows)
 }
 
-// ListByCourse retrieves all symptom logs for a specific course (course must belong to account)
-func (r *SymptomRepository) ListByCourse(courseID int64, accountID int64, limit, offset int) ([]*models.SymptomLog, error) {
+// ListByCourse retrieves the symptom logs viewer may see for a specific course (course must belong to account)
+func (r *SymptomRepository) ListByCourse(courseID int64, accountID int64, viewer SymptomViewer, limit, offset int) ([]*models.SymptomLog, error) {
+	visible, visibleArgs := viewer.Condition("s")
 	query := `
-		SELECT s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type, s.symptoms, s.notes, s.created_at, s.updated_at
+		SELECT s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type, s.symptoms, s.notes, s.attachment_id, s.visibility, s.created_at, s.updated_at
 		FROM symptom_logs s
 		JOIN courses c ON c.id = s.course_id
-		WHERE s.course_id = ? AND c.account_id = ?
+		WHERE s.course_id = ? AND c.account_id = ? AND ` + visible + `
 		ORDER BY s.timestamp DESC
 		LIMIT ? OFFSET ?
 	`
-	rows, err := r.db.Query(query, courseID, accountID, limit, offset)
+	args := append([]interface{}{courseID, accountID}, visibleArgs...)
+	rows, err := r.db.Query(query, append(args, limit, offset)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list symptom logs by course: %w", err)
 	}
@@ -175,17 +256,19 @@ func (r *SymptomRepository) ListByCourse(courseID int64, accountID int64, limit,
 	return r.scanSymptomLogs(rows)
 }
 
-// ListByDateRange retrieves symptom logs within a date range for an account
-func (r *SymptomRepository) ListByDateRange(accountID int64, startDate, endDate time.Time, limit, offset int) ([]*models.SymptomLog, error) {
+// ListByDateRange retrieves the symptom logs viewer may see within a date range for an account
+func (r *SymptomRepository) ListByDateRange(accountID int64, viewer SymptomViewer, startDate, endDate time.Time, limit, offset int) ([]*models.SymptomLog, error) {
+	visible, visibleArgs := viewer.Condition("s")
 	query := `
-		SELECT s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type, s.symptoms, s.notes, s.created_at, s.updated_at
+		SELECT s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type, s.symptoms, s.notes, s.attachment_id, s.visibility, s.created_at, s.updated_at
 		FROM symptom_logs s
 		JOIN courses c ON c.id = s.course_id
-		WHERE c.account_id = ? AND s.timestamp BETWEEN ? AND ?
+		WHERE c.account_id = ? AND s.timestamp BETWEEN ? AND ? AND ` + visible + `
 		ORDER BY s.timestamp DESC
 		LIMIT ? OFFSET ?
 	`
-	rows, err := r.db.Query(query, accountID, startDate, endDate, limit, offset)
+	args := append([]interface{}{accountID, startDate, endDate}, visibleArgs...)
+	rows, err := r.db.Query(query, append(args, limit, offset)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list symptom logs by date range: %w", err)
 	}
@@ -194,17 +277,19 @@ func (r *SymptomRepository) ListByDateRange(accountID int64, startDate, endDate
 	return r.scanSymptomLogs(rows)
 }
 
-// GetRecent retrieves the most recent symptom logs for an account
-func (r *SymptomRepository) GetRecent(accountID int64, count int) ([]*models.SymptomLog, error) {
+// GetRecent retrieves the most recent symptom logs viewer may see in an account
+func (r *SymptomRepository) GetRecent(accountID int64, viewer SymptomViewer, count int) ([]*models.SymptomLog, error) {
+	visible, visibleArgs := viewer.Condition("s")
 	query := `
-		SELECT s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type, s.symptoms, s.notes, s.created_at, s.updated_at
+		SELECT s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type, s.symptoms, s.notes, s.attachment_id, s.visibility, s.created_at, s.updated_at
 		FROM symptom_logs s
 		JOIN courses c ON c.id = s.course_id
-		WHERE c.account_id = ?
+		WHERE c.account_id = ? AND ` + visible + `
 		ORDER BY s.timestamp DESC
 		LIMIT ?
 	`
-	rows, err := r.db.Query(query, accountID, count)
+	args := append([]interface{}{accountID}, visibleArgs...)
+	rows, err := r.db.Query(query, append(args, count)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get recent symptom logs: %w", err)
 	}
@@ -213,48 +298,49 @@ func (r *SymptomRepository) GetRecent(accountID int64, count int) ([]*models.Sym
 	return r.scanSymptomLogs(rows)
 }
 
-// CountByCourse counts symptom logs for a specific course (course must belong to account)
-func (r *SymptomRepository) CountByCourse(courseID int64, accountID int64) (int64, error) {
+// CountByCourse counts the symptom logs viewer may see for a specific course (course must belong to account)
+func (r *SymptomRepository) CountByCourse(courseID int64, accountID int64, viewer SymptomViewer) (int64, error) {
+	visible, visibleArgs := viewer.Condition("s")
 	query := `
 		SELECT COUNT(*)
 		FROM symptom_logs s
 		JOIN courses c ON c.id = s.course_id
-		WHERE s.course_id = ? AND c.account_id = ?
-	`
+		WHERE s.course_id = ? AND c.account_id = ? AND ` + visible
 	var count int64
-	err := r.db.QueryRow(query, courseID, accountID).Scan(&count)
+	err := r.db.QueryRow(query, append([]interface{}{courseID, accountID}, visibleArgs...)...).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count symptom logs by course: %w", err)
 	}
 	return count, nil
 }
 
-// CountByDateRange counts symptom logs within a date range for an account
-func (r *SymptomRepository) CountByDateRange(accountID int64, startDate, endDate time.Time) (int64, error) {
+// CountByDateRange counts the symptom logs viewer may see within a date range for an account
+func (r *SymptomRepository) CountByDateRange(accountID int64, viewer SymptomViewer, startDate, endDate time.Time) (int64, error) {
+	visible, visibleArgs := viewer.Condition("s")
 	query := `
 		SELECT COUNT(*)
 		FROM symptom_logs s
 		JOIN courses c ON c.id = s.course_id
-		WHERE c.account_id = ? AND s.timestamp BETWEEN ? AND ?
-	`
+		WHERE c.account_id = ? AND s.timestamp BETWEEN ? AND ? AND ` + visible
 	var count int64
-	err := r.db.QueryRow(query, accountID, startDate, endDate).Scan(&count)
+	err := r.db.QueryRow(query, append([]interface{}{accountID, startDate, endDate}, visibleArgs...)...).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count symptom logs by date range: %w", err)
 	}
 	return count, nil
 }
 
-// GetAveragePainLevel calculates the average pain level for a course (course must belong to account)
-func (r *SymptomRepository) GetAveragePainLevel(courseID int64, accountID int64) (float64, error) {
+// GetAveragePainLevel calculates the average pain level over the symptom logs viewer may see for a course
+// (course must belong to account)
+func (r *SymptomRepository) GetAveragePainLevel(courseID int64, accountID int64, viewer SymptomViewer) (float64, error) {
+	visible, visibleArgs := viewer.Condition("s")
 	query := `
 		SELECT AVG(s.pain_level)
 		FROM symptom_logs s
 		JOIN courses c ON c.id = s.course_id
-		WHERE s.course_id = ? AND c.account_id = ? AND s.pain_level IS NOT NULL
-	`
+		WHERE s.course_id = ? AND c.account_id = ? AND s.pain_level IS NOT NULL AND ` + visible
 	var avg sql.NullFloat64
-	err := r.db.QueryRow(query, courseID, accountID).Scan(&avg)
+	err := r.db.QueryRow(query, append([]interface{}{courseID, accountID}, visibleArgs...)...).Scan(&avg)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get average pain level: %w", err)
 	}
@@ -264,6 +350,51 @@ func (r *SymptomRepository) GetAveragePainLevel(courseID int64, accountID int64)
 	return avg.Float64, nil
 }
 
+// CanSee reports whether v may see a symptom log with the given visibility,
+// logged by loggedBy. It matches Condition, for logs read by other means.
+func (v SymptomViewer) CanSee(visibility string, loggedBy sql.NullInt64) bool {
+	return v.SeeAll || visibility != SymptomVisibilityPrivate || (loggedBy.Valid && loggedBy.Int64 == v.UserID)
+}
+
+// SymptomFilter narrows ListPage. Zero values don't filter.
+type SymptomFilter struct {
+	CourseID int64
+	Start    time.Time // Inclusive
+	End      time.Time // Exclusive
+}
+
+// ListPage retrieves a page of the symptom logs viewer may see in an account, newest first
+func (r *SymptomRepository) ListPage(accountID int64, viewer SymptomViewer, filter SymptomFilter, page PageRequest) (*Page[*models.SymptomLog], error) {
+	visible, visibleArgs := viewer.Condition("s")
+	from := `
+		FROM symptom_logs s
+		JOIN courses c ON c.id = s.course_id
+		WHERE c.account_id = ? AND ` + visible
+	args := append([]interface{}{accountID}, visibleArgs...)
+	if filter.CourseID != 0 {
+		from += ` AND s.course_id = ?`
+		args = append(args, filter.CourseID)
+	}
+	if !filter.Start.IsZero() {
+		from += ` AND s.timestamp >= ?`
+		args = append(args, filter.Start)
+	}
+	if !filter.End.IsZero() {
+		from += ` AND s.timestamp < ?`
+		args = append(args, filter.End)
+	}
+
+	return listPage(r.db, page, pageQuery{
+		Columns: `s.id, s.course_id, s.logged_by, s.timestamp, s.pain_level, s.pain_location, s.pain_type, s.symptoms, s.notes, s.attachment_id, s.visibility, s.created_at, s.updated_at`,
+		From:    from,
+		Args:    args,
+		Table:   "symptom_logs",
+		Alias:   "s",
+	}, r.scanSymptomLogs, func(s *models.SymptomLog) (time.Time, int64) {
+		return s.Timestamp, s.ID
+	})
+}
+
 // scanSymptomLogs is a helper to scan multiple symptom log rows
 func (r *SymptomRepository) scanSymptomLogs(rows *sql.Rows) ([]*models.SymptomLog, error) {
 	var symptoms []*models.SymptomLog
@@ -279,6 +410,8 @@ func (r *SymptomRepository) scanSymptomLogs(rows *sql.Rows) ([]*models.SymptomLo
 			&symptom.PainType,
 			&symptom.Symptoms,
 			&symptom.Notes,
+			&symptom.AttachmentID,
+			&symptom.Visibility,
 			&symptom.CreatedAt,
 			&symptom.UpdatedAt,
 		)
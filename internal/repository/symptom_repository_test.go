@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestSymptomRepository_PrivateLogs(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (2, 'member', 'hash');
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 2, 'member');
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', CURRENT_TIMESTAMP, 1);
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+
+	repo := NewSymptomRepository(db)
+	shared := &models.SymptomLog{CourseID: 1, LoggedBy: sql.NullInt64{Int64: 1, Valid: true}, Timestamp: time.Now()}
+	private := &models.SymptomLog{CourseID: 1, LoggedBy: sql.NullInt64{Int64: 2, Valid: true}, Timestamp: time.Now(),
+		Visibility: SymptomVisibilityPrivate, Notes: sql.NullString{String: "Just for me", Valid: true}}
+	for _, s := range []*models.SymptomLog{shared, private} {
+		if err := repo.Create(s); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	if shared.Visibility != SymptomVisibilityShared {
+		t.Errorf("Expected logs to be shared by default, got %q", shared.Visibility)
+	}
+
+	owner, err := LoadSymptomViewer(db, 1, 1)
+	if err != nil {
+		t.Fatalf("LoadSymptomViewer failed: %v", err)
+	}
+	if owner.SeeAll {
+		t.Error("Expected owners not to see private logs until the account allows it")
+	}
+	member, err := LoadSymptomViewer(db, 1, 2)
+	if err != nil {
+		t.Fatalf("LoadSymptomViewer failed: %v", err)
+	}
+
+	if _, err := repo.GetByID(private.ID, 1, owner); err != ErrNotFound {
+		t.Errorf("Expected another member's private log to be hidden, got %v", err)
+	}
+	if got, err := repo.GetByID(private.ID, 1, member); err != nil || got.Visibility != SymptomVisibilityPrivate {
+		t.Errorf("Expected the author to see their private log, got %+v, %v", got, err)
+	}
+	if logs, err := repo.List(1, owner, 10, 0); err != nil || len(logs) != 1 || logs[0].ID != shared.ID {
+		t.Errorf("Expected only the shared log, got %d logs, %v", len(logs), err)
+	}
+	if count, err := repo.CountByCourse(1, 1, member); err != nil || count != 2 {
+		t.Errorf("Expected the author to count both logs, got %d, %v", count, err)
+	}
+
+	if _, err := db.Exec(`UPDATE accounts SET owners_see_private = TRUE WHERE id = 1`); err != nil {
+		t.Fatalf("Failed to update account: %v", err)
+	}
+	owner, err = LoadSymptomViewer(db, 1, 1)
+	if err != nil {
+		t.Fatalf("LoadSymptomViewer failed: %v", err)
+	}
+	if page, err := repo.ListPage(1, owner, SymptomFilter{}, PageRequest{Limit: 10}); err != nil || len(page.Items) != 2 {
+		t.Errorf("Expected the owner to see every log once allowed, got %v", err)
+	}
+	if member, _ = LoadSymptomViewer(db, 1, 2); member.SeeAll {
+		t.Error("Expected the override to apply to owners only")
+	}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// SyncClaimTimeout is how long a pending operation stays claimed. After that
+// the request applying it is assumed to have died and the operation can be
+// claimed again.
+const SyncClaimTimeout = 5 * time.Minute
+
+type SyncRepository struct {
+	db *database.DB
+}
+
+func NewSyncRepository(db *database.DB) *SyncRepository {
+	return &SyncRepository{db: db}
+}
+
+const syncOperationColumns = `account_id, client_id, user_id, method, path, status, status_code, response, entity_id, created_at`
+
+// Claim marks an operation as being applied. It returns true if the caller
+// should apply it; otherwise the existing record is returned, which is
+// either the result of an earlier attempt or a pending claim by another
+// request.
+func (r *SyncRepository) Claim(accountID int64, clientID string, userID int64, method, path string) (bool, *models.SyncOperation, error) {
+	now := time.Now().UTC()
+	result, err := r.db.Exec(`
+		INSERT INTO sync_operations (account_id, client_id, user_id, method, path, status, created_at)
+		VALUES (?, ?, ?, ?, ?, 'pending', ?)
+		ON CONFLICT (account_id, client_id) DO UPDATE SET
+			user_id = excluded.user_id, method = excluded.method, path = excluded.path, created_at = excluded.created_at
+		WHERE sync_operations.status = 'pending' AND sync_operations.created_at < ?
+	`, accountID, clientID, userID, method, path, now, now.Add(-SyncClaimTimeout))
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to claim sync operation: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows > 0 {
+		return true, nil, nil
+	}
+
+	existing, err := r.Get(accountID, clientID)
+	if err != nil {
+		return false, nil, err
+	}
+	return false, existing, nil
+}
+
+// Get retrieves an operation by its client ID
+func (r *SyncRepository) Get(accountID int64, clientID string) (*models.SyncOperation, error) {
+	query := `SELECT ` + syncOperationColumns + ` FROM sync_operations WHERE account_id = ? AND client_id = ?`
+	op, err := scanSyncOperation(r.db.QueryRow(query, accountID, clientID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync operation: %w", err)
+	}
+	return op, nil
+}
+
+// Complete records the result of an applied operation
+func (r *SyncRepository) Complete(accountID int64, clientID string, statusCode int, response string, entityID sql.NullInt64) error {
+	_, err := r.db.Exec(`
+		UPDATE sync_operations SET status = 'applied', status_code = ?, response = ?, entity_id = ?
+		WHERE account_id = ? AND client_id = ?
+	`, statusCode, response, entityID, accountID, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to complete sync operation: %w", err)
+	}
+	return nil
+}
+
+// Release drops the claim on an operation that wasn't applied, so it can be
+// sent again
+func (r *SyncRepository) Release(accountID int64, clientID string) error {
+	_, err := r.db.Exec(`
+		DELETE FROM sync_operations WHERE account_id = ? AND client_id = ? AND status = 'pending'
+	`, accountID, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to release sync operation: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes operations recorded before the cutoff
+func (r *SyncRepository) Prune(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM sync_operations WHERE created_at < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune sync operations: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func scanSyncOperation(row rowScanner) (*models.SyncOperation, error) {
+	var op models.SyncOperation
+	err := row.Scan(&op.AccountID, &op.ClientID, &op.UserID, &op.Method, &op.Path, &op.Status,
+		&op.StatusCode, &op.Response, &op.EntityID, &op.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
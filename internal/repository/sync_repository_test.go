@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestSyncRepository_Claim(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewSyncRepository(db)
+	const id = "0b7e8f1e-3d4c-4a6b-9a51-2f0c1d2e3f40"
+
+	claimed, _, err := repo.Claim(1, id, 1, "POST", "/injections")
+	if err != nil || !claimed {
+		t.Fatalf("Expected the first claim to succeed, got %v %v", claimed, err)
+	}
+
+	claimed, existing, err := repo.Claim(1, id, 1, "POST", "/injections")
+	if err != nil || claimed || existing.Status != "pending" {
+		t.Fatalf("Expected a pending claim to block, got %v %+v %v", claimed, existing, err)
+	}
+
+	if err := repo.Complete(1, id, 201, `{"ID":7}`, sql.NullInt64{Int64: 7, Valid: true}); err != nil {
+		t.Fatalf("Failed to complete: %v", err)
+	}
+	claimed, existing, err = repo.Claim(1, id, 1, "POST", "/injections")
+	if err != nil || claimed {
+		t.Fatalf("Expected an applied operation not to be claimed again, got %v %v", claimed, err)
+	}
+	if existing.Status != "applied" || existing.StatusCode.Int64 != 201 || existing.EntityID.Int64 != 7 {
+		t.Errorf("Unexpected stored result %+v", existing)
+	}
+
+	// Released operations and stale claims can be claimed again
+	const other = "1b7e8f1e-3d4c-4a6b-9a51-2f0c1d2e3f40"
+	if _, _, err := repo.Claim(1, other, 1, "POST", "/symptoms"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Release(1, other); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, _, _ := repo.Claim(1, other, 1, "POST", "/symptoms"); !claimed {
+		t.Error("Expected a released operation to be claimable")
+	}
+	if _, err := db.Exec(`UPDATE sync_operations SET created_at = ? WHERE client_id = ?`,
+		time.Now().UTC().Add(-2*SyncClaimTimeout), other); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, _, _ := repo.Claim(1, other, 1, "POST", "/symptoms"); !claimed {
+		t.Error("Expected a stale claim to be taken over")
+	}
+
+	if n, err := repo.Prune(time.Now().Add(time.Minute)); err != nil || n != 2 {
+		t.Errorf("Expected to prune 2 operations, got %d %v", n, err)
+	}
+}
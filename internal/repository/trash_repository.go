@@ -0,0 +1,567 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// Trash item types
+const (
+	TrashCourse       = "course"
+	TrashSymptomLog   = "symptom_log"
+	TrashMedication   = "medication"
+	TrashJournalEntry = "journal_entry"
+)
+
+// TrashRetention is how long a deleted item stays in the trash before it is
+// purged for good
+const TrashRetention = 30 * 24 * time.Hour
+
+// ErrTrashConflict is returned when a trashed item can't be put back:
+// something it refers to has been deleted since, or a row has taken its place
+var ErrTrashConflict = errors.New("trashed item conflicts with current data")
+
+// trashTable is a table whose rows go to the trash with an item. where picks
+// them out by the item's ID, its only placeholder.
+type trashTable struct {
+	table string
+	where string
+}
+
+// trashLink is a column that ON DELETE SET NULL clears when an item is
+// deleted. A restore points the same rows back at the item.
+type trashLink struct {
+	table  string
+	column string
+}
+
+// trashKind is what deleting an item of one type takes with it. The item's
+// own table comes first and the rest follow parents before children, the
+// order a restore inserts them in; the foreign keys' ON DELETE CASCADE
+// removes them all when the item is deleted. owned limits the item to an
+// account, whose ID is its only placeholder.
+type trashKind struct {
+	owned  string
+	tables []trashTable
+	links  []trashLink
+}
+
+var trashKinds = map[string]trashKind{
+	TrashCourse: {
+		owned: "account_id = ?",
+		tables: []trashTable{
+			{"courses", "id = ?"},
+			{"course_dose_steps", "course_id = ?"},
+			{"course_schedules", "course_id = ?"},
+			{"missed_injections", "course_id = ?"},
+			{"injections", "course_id = ?"},
+			{"inventory_lot_consumptions", "injection_id IN (SELECT id FROM injections WHERE course_id = ?)"},
+			{"inventory_vial_consumptions", "injection_id IN (SELECT id FROM injections WHERE course_id = ?)"},
+			{"injection_sessions", "course_id = ?"},
+			{"symptom_logs", "course_id = ?"},
+			{"symptom_log_items", "symptom_log_id IN (SELECT id FROM symptom_logs WHERE course_id = ?)"},
+		},
+		links: []trashLink{
+			{"journal_entries", "course_id"},
+			{"lab_results", "course_id"},
+			{"report_schedules", "course_id"},
+		},
+	},
+	TrashSymptomLog: {
+		owned: "EXISTS (SELECT 1 FROM courses WHERE id = symptom_logs.course_id AND account_id = ?)",
+		tables: []trashTable{
+			{"symptom_logs", "id = ?"},
+			{"symptom_log_items", "symptom_log_id = ?"},
+		},
+	},
+	TrashMedication: {
+		owned: "account_id = ?",
+		tables: []trashTable{
+			{"medications", "id = ?"},
+			{"medication_logs", "medication_id = ?"},
+			{"medication_dose_snoozes", "medication_id = ?"},
+			{"medication_missed_doses", "medication_id = ?"},
+		},
+	},
+	TrashJournalEntry: {
+		owned: "account_id = ?",
+		tables: []trashTable{
+			{"journal_entries", "id = ?"},
+			{"journal_entry_tags", "entry_id = ?"},
+		},
+	},
+}
+
+// IsTrashType reports whether items of type t can go to the trash
+func IsTrashType(t string) bool {
+	_, ok := trashKinds[t]
+	return ok
+}
+
+// trashSnapshot is what a trash item's snapshot holds
+type trashSnapshot struct {
+	Rows  []trashRow    `json:"rows"`
+	Links []trashLinked `json:"links,omitempty"`
+}
+
+// trashRow is one deleted row, column by column; a nil value is NULL
+type trashRow struct {
+	Table  string                 `json:"table"`
+	Values map[string]*trashValue `json:"values"`
+}
+
+// trashLinked is the rows whose link to the item was cleared
+type trashLinked struct {
+	Table  string  `json:"table"`
+	Column string  `json:"column"`
+	IDs    []int64 `json:"ids"`
+}
+
+// trashValue keeps a column value's Go type through JSON, so a restore
+// writes back exactly what the driver read
+type trashValue struct {
+	Int   *int64     `json:"int,omitempty"`
+	Float *float64   `json:"float,omitempty"`
+	Text  *string    `json:"text,omitempty"`
+	Bytes *[]byte    `json:"bytes,omitempty"`
+	Bool  *bool      `json:"bool,omitempty"`
+	Time  *time.Time `json:"time,omitempty"`
+}
+
+func newTrashValue(v interface{}) (*trashValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case int64:
+		return &trashValue{Int: &v}, nil
+	case int32:
+		n := int64(v)
+		return &trashValue{Int: &n}, nil
+	case float64:
+		return &trashValue{Float: &v}, nil
+	case float32:
+		f := float64(v)
+		return &trashValue{Float: &f}, nil
+	case string:
+		return &trashValue{Text: &v}, nil
+	case []byte:
+		b := append([]byte{}, v...)
+		return &trashValue{Bytes: &b}, nil
+	case bool:
+		return &trashValue{Bool: &v}, nil
+	case time.Time:
+		return &trashValue{Time: &v}, nil
+	}
+	return nil, fmt.Errorf("unsupported column type %T", v)
+}
+
+func (v *trashValue) value() interface{} {
+	switch {
+	case v == nil:
+		return nil
+	case v.Int != nil:
+		return *v.Int
+	case v.Float != nil:
+		return *v.Float
+	case v.Text != nil:
+		return *v.Text
+	case v.Bytes != nil:
+		return *v.Bytes
+	case v.Bool != nil:
+		return *v.Bool
+	case v.Time != nil:
+		return *v.Time
+	}
+	return nil
+}
+
+// trashIdent matches the column names a snapshot may write to
+var trashIdent = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+type TrashRepository struct {
+	db *database.DB
+}
+
+func NewTrashRepository(db *database.DB) *TrashRepository {
+	return &TrashRepository{db: db}
+}
+
+const trashColumns = `t.id, t.account_id, t.item_type, t.item_id, t.label, t.is_private, t.private_to, t.snapshot, t.deleted_by, COALESCE(u.username, ''), t.deleted_at`
+
+// MoveToTrash deletes an item, with everything that goes with it, and keeps
+// a snapshot of them in the trash. item says what to delete and how to show
+// it; ID, Snapshot and DeletedAt are filled in. It returns ErrNotFound if
+// the item doesn't belong to the account.
+func (r *TrashRepository) MoveToTrash(item *models.TrashItem) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := moveToTrash(tx, item); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit trash item: %w", err)
+	}
+	return nil
+}
+
+// MoveAllToTrash moves several items to the trash in one transaction, as
+// MoveToTrash does. If one can't be moved none are, and the error is a
+// *BatchError naming it.
+func (r *TrashRepository) MoveAllToTrash(items []*models.TrashItem) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for i, item := range items {
+		if err := moveToTrash(tx, item); err != nil {
+			return &BatchError{Index: i, Err: err}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit trash items: %w", err)
+	}
+	return nil
+}
+
+func moveToTrash(tx *sql.Tx, item *models.TrashItem) error {
+	kind, ok := trashKinds[item.ItemType]
+	if !ok {
+		return fmt.Errorf("unknown trash item type %q", item.ItemType)
+	}
+
+	var snapshot trashSnapshot
+	root := kind.tables[0]
+	rows, err := snapshotRows(tx, root.table, root.where+" AND "+kind.owned, item.ItemID, item.AccountID)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return ErrNotFound
+	}
+	snapshot.Rows = rows
+	for _, t := range kind.tables[1:] {
+		rows, err := snapshotRows(tx, t.table, t.where, item.ItemID)
+		if err != nil {
+			return err
+		}
+		snapshot.Rows = append(snapshot.Rows, rows...)
+	}
+	for _, l := range kind.links {
+		ids, err := linkedIDs(tx, l, item.ItemID)
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			snapshot.Links = append(snapshot.Links, trashLinked{Table: l.table, Column: l.column, IDs: ids})
+		}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode trash snapshot: %w", err)
+	}
+	item.Snapshot = string(data)
+	item.DeletedAt = time.Now()
+
+	err = tx.QueryRow(`
+		INSERT INTO trash_items (account_id, item_type, item_id, label, is_private, private_to, snapshot, deleted_by, deleted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, item.AccountID, item.ItemType, item.ItemID, item.Label, item.IsPrivate, item.PrivateTo, item.Snapshot, item.DeletedBy, item.DeletedAt).Scan(&item.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create trash item: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM `+root.table+` WHERE id = ?`, item.ItemID); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", item.ItemType, err)
+	}
+	return nil
+}
+
+// snapshotRows reads the rows of table matching where. Each column is read
+// through COALESCE so it comes back as stored: the SQLite driver turns a
+// bare DATE or TIMESTAMP column into a time.Time, and writing that back
+// would change its format.
+func snapshotRows(tx *sql.Tx, table, where string, args ...interface{}) ([]trashRow, error) {
+	probe, err := tx.Query(`SELECT * FROM ` + table + ` WHERE 1 = 0`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	columns, err := probe.Columns()
+	probe.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+
+	selects := make([]string, len(columns))
+	for i, c := range columns {
+		selects[i] = `COALESCE("` + c + `", NULL)`
+	}
+	rows, err := tx.Query(`SELECT `+strings.Join(selects, ", ")+` FROM `+table+` WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var out []trashRow
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", table, err)
+		}
+		row := trashRow{Table: table, Values: make(map[string]*trashValue, len(columns))}
+		for i, c := range columns {
+			v, err := newTrashValue(values[i])
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", table, c, err)
+			}
+			row.Values[c] = v
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// linkedIDs lists the rows of l's table that point at the item
+func linkedIDs(tx *sql.Tx, l trashLink, itemID int64) ([]int64, error) {
+	rows, err := tx.Query(`SELECT id FROM `+l.table+` WHERE `+l.column+` = ? ORDER BY id`, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", l.table, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", l.table, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// visible returns the condition, with its arguments, that keeps the trash
+// items viewer may see: private symptom logs are shown only to whoever
+// could see them before they were deleted
+func (r *TrashRepository) visible(viewer SymptomViewer) (string, []interface{}) {
+	if viewer.SeeAll {
+		return "TRUE", nil
+	}
+	return "(t.is_private = FALSE OR t.private_to = ?)", []interface{}{viewer.UserID}
+}
+
+// List retrieves the account's trash items viewer may see, most recently
+// deleted first. itemType limits them to one type when set.
+func (r *TrashRepository) List(accountID int64, viewer SymptomViewer, itemType string) ([]*models.TrashItem, error) {
+	cond, args := r.visible(viewer)
+	query := `SELECT ` + trashColumns + ` FROM trash_items t LEFT JOIN users u ON u.id = t.deleted_by WHERE t.account_id = ? AND ` + cond
+	args = append([]interface{}{accountID}, args...)
+	if itemType != "" {
+		query += ` AND t.item_type = ?`
+		args = append(args, itemType)
+	}
+	query += ` ORDER BY t.deleted_at DESC, t.id DESC`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+
+	var items []*models.TrashItem
+	for rows.Next() {
+		item, err := scanTrashItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trash item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetByID retrieves a trash item viewer may see
+func (r *TrashRepository) GetByID(id, accountID int64, viewer SymptomViewer) (*models.TrashItem, error) {
+	cond, args := r.visible(viewer)
+	query := `SELECT ` + trashColumns + ` FROM trash_items t LEFT JOIN users u ON u.id = t.deleted_by WHERE t.id = ? AND t.account_id = ? AND ` + cond
+	item, err := scanTrashItem(r.db.QueryRow(query, append([]interface{}{id, accountID}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trash item: %w", err)
+	}
+	return item, nil
+}
+
+// Restore puts a trash item's rows back with their original IDs, relinks
+// the rows that pointed at it, and removes it from the trash. It returns
+// ErrTrashConflict if the rows can't go back, leaving the item in the trash.
+func (r *TrashRepository) Restore(item *models.TrashItem) error {
+	kind, ok := trashKinds[item.ItemType]
+	if !ok {
+		return fmt.Errorf("unknown trash item type %q", item.ItemType)
+	}
+	var snapshot trashSnapshot
+	if err := json.Unmarshal([]byte(item.Snapshot), &snapshot); err != nil {
+		return fmt.Errorf("failed to decode trash snapshot: %w", err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, row := range snapshot.Rows {
+		if !kind.hasTable(row.Table) {
+			return fmt.Errorf("trash snapshot has rows for %q", row.Table)
+		}
+		columns := make([]string, 0, len(row.Values))
+		for c := range row.Values {
+			if !trashIdent.MatchString(c) {
+				return fmt.Errorf("trash snapshot has column %q", c)
+			}
+			columns = append(columns, c)
+		}
+		sort.Strings(columns)
+		args := make([]interface{}, len(columns))
+		for i, c := range columns {
+			args[i] = row.Values[c].value()
+		}
+		query := `INSERT INTO ` + row.Table + ` ("` + strings.Join(columns, `", "`) + `") VALUES (` + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + `)`
+		if _, err := tx.Exec(query, args...); err != nil {
+			if isConstraintError(err) {
+				return fmt.Errorf("%w: %s: %v", ErrTrashConflict, row.Table, err)
+			}
+			return fmt.Errorf("failed to restore %s: %w", row.Table, err)
+		}
+	}
+
+	for _, linked := range snapshot.Links {
+		if !kind.hasLink(linked.Table, linked.Column) {
+			return fmt.Errorf("trash snapshot links %s.%s", linked.Table, linked.Column)
+		}
+		args := []interface{}{item.ItemID}
+		for _, id := range linked.IDs {
+			args = append(args, id)
+		}
+		// Rows that have been linked to something else since keep that link
+		query := `UPDATE ` + linked.Table + ` SET ` + linked.Column + ` = ? WHERE id IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(linked.IDs)), ", ") + `) AND ` + linked.Column + ` IS NULL`
+		if _, err := tx.Exec(query, args...); err != nil {
+			return fmt.Errorf("failed to relink %s: %w", linked.Table, err)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM trash_items WHERE id = ?`, item.ID); err != nil {
+		return fmt.Errorf("failed to remove trash item: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return nil
+}
+
+func (k trashKind) hasTable(table string) bool {
+	for _, t := range k.tables {
+		if t.table == table {
+			return true
+		}
+	}
+	return false
+}
+
+func (k trashKind) hasLink(table, column string) bool {
+	for _, l := range k.links {
+		if l.table == table && l.column == column {
+			return true
+		}
+	}
+	return false
+}
+
+// isConstraintError reports whether err is a foreign key, unique or check
+// constraint failing, in SQLite's wording or PostgreSQL's
+func isConstraintError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "constraint failed") || strings.Contains(msg, "violates")
+}
+
+// Purge deletes a trash item for good
+func (r *TrashRepository) Purge(id, accountID int64, viewer SymptomViewer) error {
+	cond, args := r.visible(viewer)
+	result, err := r.db.Exec(`DELETE FROM trash_items AS t WHERE t.id = ? AND t.account_id = ? AND `+cond, append([]interface{}{id, accountID}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to purge trash item: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PurgeAll empties the trash of everything viewer may see, returning how
+// many items went
+func (r *TrashRepository) PurgeAll(accountID int64, viewer SymptomViewer) (int64, error) {
+	cond, args := r.visible(viewer)
+	result, err := r.db.Exec(`DELETE FROM trash_items AS t WHERE t.account_id = ? AND `+cond, append([]interface{}{accountID}, args...)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to empty trash: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// PurgeExpired deletes every account's trash items deleted before cutoff,
+// leaving suspended accounts' trash until they are reactivated
+func (r *TrashRepository) PurgeExpired(cutoff time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM trash_items WHERE deleted_at < ? AND `+AccountNotSuspended("account_id"), cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired trash: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func scanTrashItem(row rowScanner) (*models.TrashItem, error) {
+	item := &models.TrashItem{}
+	err := row.Scan(
+		&item.ID,
+		&item.AccountID,
+		&item.ItemType,
+		&item.ItemID,
+		&item.Label,
+		&item.IsPrivate,
+		&item.PrivateTo,
+		&item.Snapshot,
+		&item.DeletedBy,
+		&item.DeletedByUsername,
+		&item.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
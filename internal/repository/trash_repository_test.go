@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestTrashRepositoryRestoresCourse(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (2, 'member', 'hash');
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 2, 'member');
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', '2026-01-05', 1);
+		INSERT INTO injections (id, course_id, timestamp, side, pain_level) VALUES (7, 1, '2026-01-06 20:00:00', 'left', 3);
+		INSERT INTO symptom_logs (id, course_id, logged_by, timestamp, notes, visibility) VALUES (4, 1, 2, '2026-01-06 21:00:00', 'Sore', 'private');
+		INSERT INTO symptom_catalog (id, account_id, key, name, category) VALUES (90, 1, 'sore', 'Sore', 'injection_site');
+		INSERT INTO symptom_log_items (symptom_log_id, catalog_id, severity) VALUES (4, 90, 2);
+		INSERT INTO journal_entries (id, account_id, course_id, body) VALUES (3, 1, 1, 'Started');
+	`); err != nil {
+		t.Fatalf("Failed to seed course: %v", err)
+	}
+	count := func(query string) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("Count failed: %v", err)
+		}
+		return n
+	}
+
+	repo := NewTrashRepository(db)
+	item := &models.TrashItem{AccountID: 1, ItemType: TrashCourse, ItemID: 1, Label: "Course", DeletedBy: sql.NullInt64{Int64: 1, Valid: true}}
+	if err := repo.MoveToTrash(item); err != nil {
+		t.Fatalf("MoveToTrash failed: %v", err)
+	}
+	if n := count(`SELECT COUNT(*) FROM courses`) + count(`SELECT COUNT(*) FROM injections`) + count(`SELECT COUNT(*) FROM symptom_log_items`); n != 0 {
+		t.Errorf("Expected the course and its records deleted, %d rows left", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM journal_entries WHERE course_id IS NULL`); n != 1 {
+		t.Errorf("Expected the journal entry kept without its course")
+	}
+
+	items, err := repo.List(1, SymptomViewer{UserID: 2}, "")
+	if err != nil || len(items) != 1 || items[0].ItemID != 1 {
+		t.Fatalf("Expected the course in the trash, got %v, %v", items, err)
+	}
+
+	if err := repo.Restore(items[0]); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	var startDate string
+	if err := db.QueryRow(`SELECT CAST(start_date AS TEXT) FROM courses WHERE id = 1`).Scan(&startDate); err != nil || startDate != "2026-01-05" {
+		t.Errorf("Expected the course back as stored, got %q, %v", startDate, err)
+	}
+	if n := count(`SELECT COUNT(*) FROM injections WHERE id = 7 AND pain_level = 3`); n != 1 {
+		t.Error("Expected the injection back with its ID")
+	}
+	if n := count(`SELECT COUNT(*) FROM symptom_log_items WHERE symptom_log_id = 4 AND severity = 2`); n != 1 {
+		t.Error("Expected the symptom log's items back")
+	}
+	if n := count(`SELECT COUNT(*) FROM symptom_logs WHERE id = 4 AND visibility = 'private' AND logged_by = 2`); n != 1 {
+		t.Error("Expected the private symptom log back as it was")
+	}
+	if n := count(`SELECT COUNT(*) FROM journal_entries WHERE course_id = 1`); n != 1 {
+		t.Error("Expected the journal entry linked to the course again")
+	}
+	if n := count(`SELECT COUNT(*) FROM trash_items`); n != 0 {
+		t.Errorf("Expected the trash empty after the restore, got %d", n)
+	}
+
+	// Another account's course can't be trashed
+	if err := repo.MoveToTrash(&models.TrashItem{AccountID: 2, ItemType: TrashCourse, ItemID: 1, Label: "Course"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another account's course to be not found, got %v", err)
+	}
+}
+
+func TestTrashRepositoryPrivacyAndConflicts(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (2, 'member', 'hash');
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 2, 'member');
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', CURRENT_TIMESTAMP, 1);
+		INSERT INTO symptom_logs (id, course_id, logged_by, timestamp, visibility) VALUES (4, 1, 2, CURRENT_TIMESTAMP, 'private');
+	`); err != nil {
+		t.Fatalf("Failed to seed symptom log: %v", err)
+	}
+
+	repo := NewTrashRepository(db)
+	symptom := &models.TrashItem{
+		AccountID: 1, ItemType: TrashSymptomLog, ItemID: 4, Label: "2026-01-06 21:00",
+		IsPrivate: true, PrivateTo: sql.NullInt64{Int64: 2, Valid: true},
+	}
+	if err := repo.MoveToTrash(symptom); err != nil {
+		t.Fatalf("MoveToTrash failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		viewer SymptomViewer
+		want   int
+	}{
+		{SymptomViewer{UserID: 2}, 1},
+		{SymptomViewer{UserID: 1}, 0},
+		{SymptomViewer{UserID: 1, SeeAll: true}, 1},
+	} {
+		items, err := repo.List(1, tt.viewer, TrashSymptomLog)
+		if err != nil || len(items) != tt.want {
+			t.Errorf("Expected %+v to see %d items, got %d, %v", tt.viewer, tt.want, len(items), err)
+		}
+	}
+	if _, err := repo.GetByID(symptom.ID, 1, SymptomViewer{UserID: 1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another member's private log to be hidden, got %v", err)
+	}
+	if err := repo.Purge(symptom.ID, 1, SymptomViewer{UserID: 1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another member not to purge a private log, got %v", err)
+	}
+
+	// With its course gone too, the log has nothing to go back to
+	course := &models.TrashItem{AccountID: 1, ItemType: TrashCourse, ItemID: 1, Label: "Course"}
+	if err := repo.MoveToTrash(course); err != nil {
+		t.Fatalf("MoveToTrash failed: %v", err)
+	}
+	item, err := repo.GetByID(symptom.ID, 1, SymptomViewer{UserID: 2})
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if err := repo.Restore(item); !errors.Is(err, ErrTrashConflict) {
+		t.Errorf("Expected a conflict restoring a log without its course, got %v", err)
+	}
+	if _, err := repo.GetByID(symptom.ID, 1, SymptomViewer{UserID: 2}); err != nil {
+		t.Errorf("Expected the log left in the trash, got %v", err)
+	}
+
+	// Expired items are purged; newer ones stay
+	if _, err := db.Exec(`UPDATE trash_items SET deleted_at = ? WHERE id = ?`, time.Now().Add(-31*24*time.Hour), course.ID); err != nil {
+		t.Fatalf("Failed to age trash item: %v", err)
+	}
+	purged, err := repo.PurgeExpired(time.Now().Add(-TrashRetention))
+	if err != nil || purged != 1 {
+		t.Errorf("Expected one expired item purged, got %d, %v", purged, err)
+	}
+	purged, err = repo.PurgeAll(1, SymptomViewer{UserID: 2})
+	if err != nil || purged != 1 {
+		t.Errorf("Expected the rest purged, got %d, %v", purged, err)
+	}
+}
+
+func TestTrashRepositoryMoveAllRollsBack(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', CURRENT_TIMESTAMP, 1);
+		INSERT INTO symptom_logs (id, course_id, timestamp) VALUES (4, 1, CURRENT_TIMESTAMP), (5, 1, CURRENT_TIMESTAMP);
+	`); err != nil {
+		t.Fatalf("Failed to seed symptom logs: %v", err)
+	}
+	item := func(id int64) *models.TrashItem {
+		return &models.TrashItem{AccountID: 1, ItemType: TrashSymptomLog, ItemID: id, Label: "Log"}
+	}
+
+	repo := NewTrashRepository(db)
+	err := repo.MoveAllToTrash([]*models.TrashItem{item(4), item(99), item(5)})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected the second item not found, got %v", err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM symptom_logs) + (SELECT COUNT(*) FROM trash_items)`).Scan(&n); err != nil || n != 2 {
+		t.Errorf("Expected nothing moved, got %d rows, %v", n, err)
+	}
+
+	if err := repo.MoveAllToTrash([]*models.TrashItem{item(4), item(5)}); err != nil {
+		t.Fatalf("MoveAllToTrash failed: %v", err)
+	}
+	items, err := repo.List(1, SymptomViewer{UserID: 1}, TrashSymptomLog)
+	if err != nil || len(items) != 2 {
+		t.Errorf("Expected both logs in the trash, got %d, %v", len(items), err)
+	}
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// TripRepository stores an account's trips for travel mode
+type TripRepository struct {
+	db *database.DB
+}
+
+func NewTripRepository(db *database.DB) *TripRepository {
+	return &TripRepository{db: db}
+}
+
+const tripColumns = `id, account_id, destination, timezone, home_timezone, starts_at, ends_at, shift, notes, created_by, created_at, updated_at`
+
+func scanTrip(row rowScanner) (*models.Trip, error) {
+	var t models.Trip
+	err := row.Scan(
+		&t.ID,
+		&t.AccountID,
+		&t.Destination,
+		&t.Timezone,
+		&t.HomeTimezone,
+		&t.StartsAt,
+		&t.EndsAt,
+		&t.Shift,
+		&t.Notes,
+		&t.CreatedBy,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// queryTrips runs a query selecting tripColumns
+func (r *TripRepository) queryTrips(query string, args ...interface{}) ([]*models.Trip, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trips: %w", err)
+	}
+	defer rows.Close()
+
+	trips := []*models.Trip{}
+	for rows.Next() {
+		trip, err := scanTrip(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trip: %w", err)
+		}
+		trips = append(trips, trip)
+	}
+	return trips, rows.Err()
+}
+
+// Create records a trip
+func (r *TripRepository) Create(trip *models.Trip) error {
+	now := time.Now()
+	err := r.db.QueryRow(`
+		INSERT INTO trips (account_id, destination, timezone, home_timezone, starts_at, ends_at, shift, notes, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`,
+		trip.AccountID,
+		trip.Destination,
+		trip.Timezone,
+		trip.HomeTimezone,
+		trip.StartsAt.UTC(),
+		trip.EndsAt.UTC(),
+		trip.Shift,
+		trip.Notes,
+		trip.CreatedBy,
+		now,
+		now,
+	).Scan(&trip.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create trip: %w", err)
+	}
+	trip.CreatedAt = now
+	trip.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves one of an account's trips
+func (r *TripRepository) GetByID(id, accountID int64) (*models.Trip, error) {
+	query := `SELECT ` + tripColumns + ` FROM trips WHERE id = ? AND account_id = ?`
+	trip, err := scanTrip(r.db.QueryRow(query, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trip: %w", err)
+	}
+	return trip, nil
+}
+
+// List retrieves up to limit of an account's trips, latest start first
+func (r *TripRepository) List(accountID int64, limit int) ([]*models.Trip, error) {
+	return r.queryTrips(`SELECT `+tripColumns+` FROM trips WHERE account_id = ? ORDER BY starts_at DESC, id DESC LIMIT ?`, accountID, limit)
+}
+
+// Between retrieves an account's trips that overlap [start, end), earliest
+// first
+func (r *TripRepository) Between(accountID int64, start, end time.Time) ([]*models.Trip, error) {
+	return r.queryTrips(`
+		SELECT `+tripColumns+` FROM trips
+		WHERE account_id = ? AND starts_at < ? AND ends_at > ?
+		ORDER BY starts_at, id
+	`, accountID, end.UTC(), start.UTC())
+}
+
+// Latest retrieves the account's trip that most recently started at or
+// before at, which may since have ended. It returns ErrNotFound when there
+// is none.
+func (r *TripRepository) Latest(accountID int64, at time.Time) (*models.Trip, error) {
+	query := `SELECT ` + tripColumns + ` FROM trips WHERE account_id = ? AND starts_at <= ? ORDER BY starts_at DESC, id DESC LIMIT 1`
+	trip, err := scanTrip(r.db.QueryRow(query, accountID, at.UTC()))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest trip: %w", err)
+	}
+	return trip, nil
+}
+
+// Overlaps reports whether any of the account's trips other than excludeID
+// overlaps [start, end)
+func (r *TripRepository) Overlaps(accountID int64, start, end time.Time, excludeID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM trips
+			WHERE account_id = ? AND id <> ? AND starts_at < ? AND ends_at > ?
+		)
+	`, accountID, excludeID, end.UTC(), start.UTC()).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check trip overlap: %w", err)
+	}
+	return exists, nil
+}
+
+// Update saves a trip's fields
+func (r *TripRepository) Update(trip *models.Trip) error {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE trips
+		SET destination = ?, timezone = ?, home_timezone = ?, starts_at = ?, ends_at = ?, shift = ?, notes = ?, updated_at = ?
+		WHERE id = ? AND account_id = ?
+	`,
+		trip.Destination,
+		trip.Timezone,
+		trip.HomeTimezone,
+		trip.StartsAt.UTC(),
+		trip.EndsAt.UTC(),
+		trip.Shift,
+		trip.Notes,
+		now,
+		trip.ID,
+		trip.AccountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update trip: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	trip.UpdatedAt = now
+	return nil
+}
+
+// Delete removes one of an account's trips
+func (r *TripRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec(`DELETE FROM trips WHERE id = ? AND account_id = ?`, id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete trip: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
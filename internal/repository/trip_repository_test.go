@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestTripRepository(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO accounts (id) VALUES (2)`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+
+	repo := NewTripRepository(db)
+	start := time.Date(2026, 7, 1, 9, 0, 0, 0, time.FixedZone("BST", 3600))
+	trip := &models.Trip{
+		AccountID:    1,
+		Destination:  "New York",
+		Timezone:     "America/New_York",
+		HomeTimezone: "Europe/London",
+		StartsAt:     start,
+		EndsAt:       start.AddDate(0, 0, 9),
+		Shift:        models.TripShiftGradual,
+	}
+	if err := repo.Create(trip); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if overlaps, err := repo.Overlaps(1, start.AddDate(0, 0, 8), start.AddDate(0, 0, 12), 0); err != nil || !overlaps {
+		t.Errorf("Expected an overlapping trip, got %v, %v", overlaps, err)
+	}
+	if overlaps, _ := repo.Overlaps(1, start.AddDate(0, 0, 8), start.AddDate(0, 0, 12), trip.ID); overlaps {
+		t.Error("Expected the trip itself not to count")
+	}
+	if overlaps, _ := repo.Overlaps(1, start.AddDate(0, 0, 9), start.AddDate(0, 0, 12), 0); overlaps {
+		t.Error("Expected a trip starting as another ends not to overlap")
+	}
+	if overlaps, _ := repo.Overlaps(2, start, start.AddDate(0, 0, 1), 0); overlaps {
+		t.Error("Expected another account's trips not to count")
+	}
+
+	if _, err := repo.Latest(1, start.Add(-time.Minute)); err != ErrNotFound {
+		t.Errorf("Expected no trip started yet, got %v", err)
+	}
+	latest, err := repo.Latest(1, start.AddDate(0, 1, 0))
+	if err != nil || latest.ID != trip.ID || !latest.StartsAt.Equal(start) || latest.Shift != models.TripShiftGradual {
+		t.Errorf("Expected the trip as the latest, got %+v, %v", latest, err)
+	}
+
+	if trips, err := repo.Between(1, start.AddDate(0, 0, -7), start); err != nil || len(trips) != 0 {
+		t.Errorf("Expected no trips the week before, got %v, %v", trips, err)
+	}
+	if trips, err := repo.Between(1, start.AddDate(0, 0, 5), start.AddDate(0, 0, 20)); err != nil || len(trips) != 1 {
+		t.Errorf("Expected the trip in range, got %v, %v", trips, err)
+	}
+
+	trip.AccountID = 2
+	if err := repo.Update(trip); err != ErrNotFound {
+		t.Errorf("Expected another account's update to be refused, got %v", err)
+	}
+	trip.AccountID = 1
+	trip.EndsAt = start.AddDate(0, 0, 5)
+	trip.Shift = models.TripShiftInstant
+	if err := repo.Update(trip); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	got, err := repo.GetByID(trip.ID, 1)
+	if err != nil || !got.EndsAt.Equal(start.AddDate(0, 0, 5)) || got.Shift != models.TripShiftInstant {
+		t.Errorf("Unexpected updated trip: %+v, %v", got, err)
+	}
+
+	if trips, err := repo.List(1, 10); err != nil || len(trips) != 1 {
+		t.Errorf("Expected one trip, got %v, %v", trips, err)
+	}
+	if err := repo.Delete(trip.ID, 2); err != ErrNotFound {
+		t.Errorf("Expected another account's delete to be refused, got %v", err)
+	}
+	if err := repo.Delete(trip.ID, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := repo.GetByID(trip.ID, 1); err != ErrNotFound {
+		t.Errorf("Expected the trip gone, got %v", err)
+	}
+}
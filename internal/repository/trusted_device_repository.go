@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// TrustedDeviceRepository keeps track of the devices users have chosen to
+// trust when signing in
+type TrustedDeviceRepository struct {
+	db *database.DB
+}
+
+func NewTrustedDeviceRepository(db *database.DB) *TrustedDeviceRepository {
+	return &TrustedDeviceRepository{db: db}
+}
+
+const trustedDeviceColumns = `id, user_id, token_hash, expires_at, created_at, last_used_at, ip_address, user_agent, is_revoked`
+
+// Create trusts a device for a user until expiresAt and returns it with the
+// token for the device to keep, which is not stored. The user's trusted
+// devices that have expired or been revoked are cleared out at the same
+// time.
+func (r *TrustedDeviceRepository) Create(userID int64, expiresAt time.Time, ipAddress, userAgent string) (*models.TrustedDevice, string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate device token: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if _, err := r.db.Exec(`DELETE FROM trusted_devices WHERE user_id = ? AND (expires_at < ? OR is_revoked = TRUE)`, userID, now); err != nil {
+		return nil, "", fmt.Errorf("failed to clear old trusted devices: %w", err)
+	}
+
+	device := &models.TrustedDevice{
+		UserID:     userID,
+		TokenHash:  hashToken(token),
+		ExpiresAt:  expiresAt.UTC(),
+		CreatedAt:  now,
+		LastUsedAt: sql.NullTime{Time: now, Valid: true},
+		IPAddress:  sql.NullString{String: ipAddress, Valid: ipAddress != ""},
+		UserAgent:  sql.NullString{String: userAgent, Valid: userAgent != ""},
+	}
+	err = r.db.QueryRow(`
+		INSERT INTO trusted_devices (user_id, token_hash, expires_at, created_at, last_used_at, ip_address, user_agent, is_revoked)
+		VALUES (?, ?, ?, ?, ?, ?, ?, FALSE)
+		RETURNING id
+	`, userID, device.TokenHash, device.ExpiresAt, now, now, device.IPAddress, device.UserAgent).Scan(&device.ID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to trust device: %w", err)
+	}
+	return device, token, nil
+}
+
+// GetActive looks up the user's trusted device from its token. A token for
+// another user, or whose trust has expired or been revoked, is not found.
+func (r *TrustedDeviceRepository) GetActive(userID int64, token string) (*models.TrustedDevice, error) {
+	query := `SELECT ` + trustedDeviceColumns + ` FROM trusted_devices
+		WHERE token_hash = ? AND user_id = ? AND is_revoked = FALSE AND expires_at > ?`
+	device, err := scanTrustedDevice(r.db.QueryRow(query, hashToken(token), userID, time.Now().UTC()))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trusted device: %w", err)
+	}
+	return device, nil
+}
+
+// ListActive retrieves a user's trusted devices whose trust hasn't expired
+// or been revoked, most recently used first
+func (r *TrustedDeviceRepository) ListActive(userID int64) ([]*models.TrustedDevice, error) {
+	query := `SELECT ` + trustedDeviceColumns + ` FROM trusted_devices
+		WHERE user_id = ? AND is_revoked = FALSE AND expires_at > ?
+		ORDER BY last_used_at DESC, id DESC`
+	rows, err := r.db.Query(query, userID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trusted devices: %w", err)
+	}
+	defer rows.Close()
+
+	var devices []*models.TrustedDevice
+	for rows.Next() {
+		d, err := scanTrustedDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trusted device: %w", err)
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+// MarkUsed records when a trusted device last signed in
+func (r *TrustedDeviceRepository) MarkUsed(id int64, when time.Time) error {
+	if _, err := r.db.Exec(`UPDATE trusted_devices SET last_used_at = ? WHERE id = ?`, when.UTC(), id); err != nil {
+		return fmt.Errorf("failed to update trusted device: %w", err)
+	}
+	return nil
+}
+
+// Revoke stops trusting one of a user's devices and signs out the sessions
+// started on it
+func (r *TrustedDeviceRepository) Revoke(id, userID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.Exec(`
+		UPDATE trusted_devices SET is_revoked = TRUE
+		WHERE id = ? AND user_id = ? AND is_revoked = FALSE
+	`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke trusted device: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(`UPDATE session_tokens SET is_revoked = TRUE WHERE trusted_device_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to revoke the device's sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func scanTrustedDevice(row rowScanner) (*models.TrustedDevice, error) {
+	var d models.TrustedDevice
+	var createdAt sql.NullTime
+	err := row.Scan(&d.ID, &d.UserID, &d.TokenHash, &d.ExpiresAt, &createdAt, &d.LastUsedAt, &d.IPAddress, &d.UserAgent, &d.IsRevoked)
+	if err != nil {
+		return nil, err
+	}
+	d.CreatedAt = createdAt.Time
+	return &d, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrustedDeviceRepository(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+	if _, err := db.Exec(`INSERT INTO users (id, username, password_hash) VALUES (2, 'other', 'hash')`); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	repo := NewTrustedDeviceRepository(db)
+	sessions := NewSessionRepository(db)
+	now := time.Now()
+
+	device, token, err := repo.Create(1, now.Add(30*24*time.Hour), "10.0.0.1", "Firefox")
+	if err != nil {
+		t.Fatalf("Failed to trust device: %v", err)
+	}
+	if device.TokenHash == token {
+		t.Error("Expected only a hash of the device token to be stored")
+	}
+
+	got, err := repo.GetActive(1, token)
+	if err != nil || got.ID != device.ID || got.UserAgent.String != "Firefox" {
+		t.Fatalf("Expected trusted device %d, got %+v (%v)", device.ID, got, err)
+	}
+	if _, err := repo.GetActive(2, token); err != ErrNotFound {
+		t.Errorf("Expected another user's device token not to be found, got %v", err)
+	}
+
+	session, sessionID, err := sessions.CreateTrusted(1, device.ID, device.ExpiresAt, "10.0.0.1", "Firefox")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if s, err := sessions.GetActive(sessionID); err != nil || s.TrustedDeviceID.Int64 != device.ID {
+		t.Errorf("Expected the session on the trusted device, got %+v (%v)", s, err)
+	}
+	untrusted, untrustedID, err := sessions.Create(1, now.Add(time.Hour), "10.0.0.2", "Safari")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	devices, err := repo.ListActive(1)
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("Expected one trusted device, got %+v (%v)", devices, err)
+	}
+
+	if err := repo.Revoke(device.ID, 2); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound revoking another user's device, got %v", err)
+	}
+	if err := repo.Revoke(device.ID, 1); err != nil {
+		t.Fatalf("Failed to revoke trusted device: %v", err)
+	}
+	if _, err := repo.GetActive(1, token); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a revoked device, got %v", err)
+	}
+	if _, err := sessions.GetActive(sessionID); err != ErrNotFound {
+		t.Errorf("Expected session %d on the revoked device signed out, got %v", session.ID, err)
+	}
+	if _, err := sessions.GetActive(untrustedID); err != nil {
+		t.Errorf("Expected session %d on another device kept, got %v", untrusted.ID, err)
+	}
+
+	// Trusting another device clears out the revoked one
+	if _, _, err := repo.Create(1, now.Add(time.Hour), "10.0.0.3", "Chrome"); err != nil {
+		t.Fatalf("Failed to trust device: %v", err)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM trusted_devices WHERE user_id = 1`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("Expected only the new trusted device left, got %d (%v)", count, err)
+	}
+}
@@ -22,17 +22,14 @@ func (r *UserRepository) Create(user *models.User) error {
 	query := `
 		INSERT INTO users (username, password_hash, email, is_active, created_at)
 		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		RETURNING id
 	`
-	result, err := r.db.Exec(query, user.Username, user.PasswordHash, user.Email, user.IsActive)
+	var id int64
+	err := r.db.QueryRow(query, user.Username, user.PasswordHash, user.Email, user.IsActive).Scan(&id)
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	user.ID = id
 	return nil
 }
@@ -191,7 +188,7 @@ func (r *UserRepository) UpdatePassword(id int64, passwordHash string) error {
 
 // Delete deletes a user (soft delete by setting is_active to false)
 func (r *UserRepository) Delete(id int64) error {
-	query := `UPDATE users SET is_active = 0 WHERE id = ?`
+	query := `UPDATE users SET is_active = FALSE WHERE id = ?`
 	_, err := r.db.Exec(query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
@@ -205,7 +202,7 @@ func (r *UserRepository) List() ([]*models.User, error) {
 		SELECT id, username, password_hash, email, is_active,
 		       failed_login_attempts, locked_until, created_at, last_login
 		FROM users
-		WHERE is_active = 1
+		WHERE is_active = TRUE
 		ORDER BY created_at DESC
 	`
 	rows, err := r.db.Query(query)
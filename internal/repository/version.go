@@ -0,0 +1,8 @@
+package repository
+
+import "errors"
+
+// ErrStale is returned by the updates that are given the updated_at a change
+// was made against when the record has been changed since, so the change
+// would overwrite someone else's
+var ErrStale = errors.New("record has changed since it was read")
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+type VitalRepository struct {
+	db *database.DB
+}
+
+func NewVitalRepository(db *database.DB) *VitalRepository {
+	return &VitalRepository{db: db}
+}
+
+// List retrieves an account's vitals, newest first. An empty vitalType
+// returns every type; zero times leave that end of the range open.
+func (r *VitalRepository) List(accountID int64, vitalType string, start, end time.Time, limit int) ([]*models.Vital, error) {
+	query := `
+		SELECT id, account_id, recorded_by, timestamp, type, value, unit, source, notes, created_at
+		FROM vitals
+		WHERE account_id = ?
+	`
+	args := []interface{}{accountID}
+	if vitalType != "" {
+		query += " AND type = ?"
+		args = append(args, vitalType)
+	}
+	if !start.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, start.UTC())
+	}
+	if !end.IsZero() {
+		query += " AND timestamp < ?"
+		args = append(args, end.UTC())
+	}
+	query += " ORDER BY timestamp DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vitals: %w", err)
+	}
+	defer rows.Close()
+
+	var vitals []*models.Vital
+	for rows.Next() {
+		var v models.Vital
+		if err := rows.Scan(&v.ID, &v.AccountID, &v.RecordedBy, &v.Timestamp, &v.Type, &v.Value, &v.Unit,
+			&v.Source, &v.Notes, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan vital: %w", err)
+		}
+		vitals = append(vitals, &v)
+	}
+
+	return vitals, rows.Err()
+}
+
+// Create records a vital. It returns false without error when the account
+// already has one of the same type at the same time.
+func (r *VitalRepository) Create(tx *sql.Tx, vital *models.Vital) (bool, error) {
+	err := tx.QueryRow(`
+		INSERT INTO vitals (account_id, recorded_by, timestamp, type, value, unit, source, notes, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	`,
+		vital.AccountID,
+		vital.RecordedBy,
+		vital.Timestamp.UTC(),
+		vital.Type,
+		vital.Value,
+		vital.Unit,
+		vital.Source,
+		vital.Notes,
+		time.Now(),
+	).Scan(&vital.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create vital: %w", err)
+	}
+	return true, nil
+}
+
+// Times returns the times, per type, at which the account has vitals
+func (r *VitalRepository) Times(accountID int64) (map[string]map[time.Time]bool, error) {
+	rows, err := r.db.Query(`SELECT type, timestamp FROM vitals WHERE account_id = ?`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vitals: %w", err)
+	}
+	defer rows.Close()
+
+	times := make(map[string]map[time.Time]bool)
+	for rows.Next() {
+		var vitalType string
+		var timestamp time.Time
+		if err := rows.Scan(&vitalType, &timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan vital: %w", err)
+		}
+		if times[vitalType] == nil {
+			times[vitalType] = make(map[time.Time]bool)
+		}
+		times[vitalType][timestamp.UTC()] = true
+	}
+
+	return times, rows.Err()
+}
+
+// HealthImportMappings returns the account's overrides of the default health
+// app mappings, by source record type
+func (r *VitalRepository) HealthImportMappings(accountID int64) (map[string]string, error) {
+	rows, err := r.db.Query(`SELECT source_type, target FROM health_import_mappings WHERE account_id = ?`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query health import mappings: %w", err)
+	}
+	defer rows.Close()
+
+	mappings := make(map[string]string)
+	for rows.Next() {
+		var sourceType, target string
+		if err := rows.Scan(&sourceType, &target); err != nil {
+			return nil, fmt.Errorf("failed to scan health import mapping: %w", err)
+		}
+		mappings[sourceType] = target
+	}
+
+	return mappings, rows.Err()
+}
+
+// SetHealthImportMappings stores mapping overrides; an empty target removes
+// the override so the default applies again
+func (r *VitalRepository) SetHealthImportMappings(accountID int64, mappings map[string]string) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for sourceType, target := range mappings {
+		if target == "" {
+			_, err = tx.Exec(`DELETE FROM health_import_mappings WHERE account_id = ? AND source_type = ?`, accountID, sourceType)
+		} else {
+			_, err = tx.Exec(`
+				INSERT INTO health_import_mappings (account_id, source_type, target, updated_at)
+				VALUES (?, ?, ?, ?)
+				ON CONFLICT(account_id, source_type) DO UPDATE SET target = excluded.target, updated_at = excluded.updated_at
+			`, accountID, sourceType, target, time.Now())
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save health import mapping: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
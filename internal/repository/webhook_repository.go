@@ -0,0 +1,336 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/secrets"
+)
+
+type WebhookRepository struct {
+	db *database.DB
+}
+
+func NewWebhookRepository(db *database.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const webhookColumns = `id, account_id, url, secret, events, description, is_enabled, created_by, created_at, updated_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, response_status, last_error,
+		       next_attempt_at, created_at, delivered_at`
+
+// Create creates a new webhook. Its secret is stored encrypted.
+func (r *WebhookRepository) Create(webhook *models.Webhook) error {
+	secret, err := secrets.Active().Seal(r.db, webhook.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+	query := `
+		INSERT INTO webhooks (account_id, url, secret, events, description, is_enabled, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	now := time.Now()
+	var id int64
+	err = r.db.QueryRow(query,
+		webhook.AccountID,
+		webhook.URL,
+		secret,
+		webhook.Events,
+		webhook.Description,
+		webhook.IsEnabled,
+		webhook.CreatedBy,
+		now,
+		now,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	webhook.ID = id
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves a webhook by ID within an account
+func (r *WebhookRepository) GetByID(id, accountID int64) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ? AND account_id = ?`
+	return r.scanWebhook(r.db.QueryRow(query, id, accountID))
+}
+
+// FindByID retrieves a webhook by ID regardless of account.
+// Only background delivery workers should use this.
+func (r *WebhookRepository) FindByID(id int64) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ?`
+	return r.scanWebhook(r.db.QueryRow(query, id))
+}
+
+// List retrieves all webhooks for an account
+func (r *WebhookRepository) List(accountID int64) ([]*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE account_id = ? ORDER BY created_at ASC, id ASC`
+	return r.queryWebhooks(query, accountID)
+}
+
+// ListEnabled retrieves the enabled webhooks for an account
+func (r *WebhookRepository) ListEnabled(accountID int64) ([]*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE account_id = ? AND is_enabled = TRUE ORDER BY id ASC`
+	return r.queryWebhooks(query, accountID)
+}
+
+// Update updates a webhook's url, events, description, secret and enabled flag
+func (r *WebhookRepository) Update(webhook *models.Webhook) error {
+	secret, err := secrets.Active().Seal(r.db, webhook.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+	query := `
+		UPDATE webhooks
+		SET url = ?, secret = ?, events = ?, description = ?, is_enabled = ?
+		WHERE id = ? AND account_id = ?
+	`
+	result, err := r.db.Exec(query,
+		webhook.URL,
+		secret,
+		webhook.Events,
+		webhook.Description,
+		webhook.IsEnabled,
+		webhook.ID,
+		webhook.AccountID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete deletes a webhook and its delivery log
+func (r *WebhookRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec("DELETE FROM webhooks WHERE id = ? AND account_id = ?", id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateDelivery queues a delivery for a webhook
+func (r *WebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	now := time.Now().UTC()
+	var id int64
+	err := r.db.QueryRow(query,
+		delivery.WebhookID,
+		delivery.Event,
+		delivery.Payload,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		now,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+
+	delivery.ID = id
+	delivery.CreatedAt = now
+	return nil
+}
+
+// ListDeliveries retrieves the most recent deliveries for a webhook
+func (r *WebhookRepository) ListDeliveries(webhookID int64, limit, offset int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := r.db.Query(query, webhookID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanDeliveries(rows)
+}
+
+// ListDueDeliveries retrieves pending deliveries whose next attempt is due
+func (r *WebhookRepository) ListDueDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= ?
+		ORDER BY next_attempt_at ASC
+		LIMIT ?
+	`
+	rows, err := r.db.Query(query, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanDeliveries(rows)
+}
+
+// UpdateDelivery stores the result of a delivery attempt
+func (r *WebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, response_status = ?, last_error = ?, next_attempt_at = ?, delivered_at = ?
+		WHERE id = ?
+	`
+	_, err := r.db.Exec(query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.ResponseStatus,
+		delivery.LastError,
+		delivery.NextAttemptAt,
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteOldDeliveries deletes finished deliveries older than the specified number of days
+func (r *WebhookRepository) DeleteOldDeliveries(daysOld int) error {
+	query := `
+		DELETE FROM webhook_deliveries
+		WHERE status != 'pending' AND created_at < ?
+	`
+	_, err := r.db.Exec(query, time.Now().UTC().AddDate(0, 0, -daysOld))
+	if err != nil {
+		return fmt.Errorf("failed to delete old webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) scanWebhook(row *sql.Row) (*models.Webhook, error) {
+	var w models.Webhook
+	err := row.Scan(
+		&w.ID,
+		&w.AccountID,
+		&w.URL,
+		&w.Secret,
+		&w.Events,
+		&w.Description,
+		&w.IsEnabled,
+		&w.CreatedBy,
+		&w.CreatedAt,
+		&w.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	if err := r.openSecrets([]*models.Webhook{&w}); err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+func (r *WebhookRepository) queryWebhooks(query string, args ...interface{}) ([]*models.Webhook, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		var w models.Webhook
+		err := rows.Scan(
+			&w.ID,
+			&w.AccountID,
+			&w.URL,
+			&w.Secret,
+			&w.Events,
+			&w.Description,
+			&w.IsEnabled,
+			&w.CreatedBy,
+			&w.CreatedAt,
+			&w.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, &w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, r.openSecrets(webhooks)
+}
+
+// openSecrets decrypts the secrets of webhooks read from the database
+func (r *WebhookRepository) openSecrets(webhooks []*models.Webhook) error {
+	for _, w := range webhooks {
+		secret, err := secrets.Active().Open(r.db, w.Secret)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt secret of webhook %d: %w", w.ID, err)
+		}
+		w.Secret = secret
+	}
+	return nil
+}
+
+// scanDeliveries is a helper to scan multiple webhook delivery rows
+func (r *WebhookRepository) scanDeliveries(rows *sql.Rows) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.Event,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.ResponseStatus,
+			&d.LastError,
+			&d.NextAttemptAt,
+			&d.CreatedAt,
+			&d.DeliveredAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, &d)
+	}
+
+	return deliveries, rows.Err()
+}
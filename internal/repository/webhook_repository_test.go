@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func createTestWebhook(t *testing.T, repo *WebhookRepository) *models.Webhook {
+	webhook := &models.Webhook{
+		AccountID: 1,
+		URL:       "https://example.com/hook",
+		Secret:    "whsec_test",
+		Events:    `["injection.created"]`,
+		IsEnabled: true,
+		CreatedBy: sql.NullInt64{Int64: 1, Valid: true},
+	}
+	if err := repo.Create(webhook); err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	return webhook
+}
+
+func TestWebhookRepository_CreateAndGet(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+	webhook := createTestWebhook(t, repo)
+
+	retrieved, err := repo.GetByID(webhook.ID, 1)
+	if err != nil {
+		t.Fatalf("Failed to get webhook: %v", err)
+	}
+	if retrieved.URL != webhook.URL || retrieved.Secret != webhook.Secret {
+		t.Errorf("Unexpected webhook: %+v", retrieved)
+	}
+
+	if _, err := repo.GetByID(webhook.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for other account, got %v", err)
+	}
+}
+
+func TestWebhookRepository_DueDeliveries(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+	webhook := createTestWebhook(t, repo)
+
+	due := &models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		Event:         "injection.created",
+		Payload:       `{"event":"injection.created"}`,
+		Status:        "pending",
+		NextAttemptAt: sql.NullTime{Time: time.Now().UTC().Add(-time.Minute), Valid: true},
+	}
+	later := &models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		Event:         "injection.created",
+		Payload:       `{"event":"injection.created"}`,
+		Status:        "pending",
+		NextAttemptAt: sql.NullTime{Time: time.Now().UTC().Add(time.Hour), Valid: true},
+	}
+	for _, d := range []*models.WebhookDelivery{due, later} {
+		if err := repo.CreateDelivery(d); err != nil {
+			t.Fatalf("Failed to create delivery: %v", err)
+		}
+	}
+
+	deliveries, err := repo.ListDueDeliveries(time.Now(), 10)
+	if err != nil {
+		t.Fatalf("Failed to list due deliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].ID != due.ID {
+		t.Fatalf("Expected only the due delivery, got %d", len(deliveries))
+	}
+
+	// Mark it delivered; it should no longer be due
+	due.Status = "success"
+	due.Attempts = 1
+	due.ResponseStatus = sql.NullInt64{Int64: 200, Valid: true}
+	due.NextAttemptAt = sql.NullTime{}
+	due.DeliveredAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	if err := repo.UpdateDelivery(due); err != nil {
+		t.Fatalf("Failed to update delivery: %v", err)
+	}
+
+	deliveries, err = repo.ListDueDeliveries(time.Now(), 10)
+	if err != nil {
+		t.Fatalf("Failed to list due deliveries: %v", err)
+	}
+	if len(deliveries) != 0 {
+		t.Errorf("Expected no due deliveries, got %d", len(deliveries))
+	}
+
+	log, err := repo.ListDeliveries(webhook.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list deliveries: %v", err)
+	}
+	if len(log) != 2 {
+		t.Errorf("Expected 2 deliveries in log, got %d", len(log))
+	}
+}
+
+func TestWebhookRepository_DeleteCascadesDeliveries(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+	webhook := createTestWebhook(t, repo)
+
+	delivery := &models.WebhookDelivery{
+		WebhookID: webhook.ID,
+		Event:     "course.closed",
+		Payload:   "{}",
+		Status:    "failed",
+	}
+	if err := repo.CreateDelivery(delivery); err != nil {
+		t.Fatalf("Failed to create delivery: %v", err)
+	}
+
+	if err := repo.Delete(webhook.ID, 1); err != nil {
+		t.Fatalf("Failed to delete webhook: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM webhook_deliveries").Scan(&count); err != nil {
+		t.Fatalf("Failed to count deliveries: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected deliveries to be removed with webhook, got %d", count)
+	}
+}
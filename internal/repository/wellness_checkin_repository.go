@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+)
+
+// WellnessCheckInRepository stores members' daily wellness check-ins
+type WellnessCheckInRepository struct {
+	db *database.DB
+}
+
+func NewWellnessCheckInRepository(db *database.DB) *WellnessCheckInRepository {
+	return &WellnessCheckInRepository{db: db}
+}
+
+const wellnessCheckInColumns = `id, account_id, user_id, date, mood, energy, sleep_hours, temperature, weight, notes, created_at, updated_at`
+
+// CheckInDate is the value a calendar day is stored under: midnight UTC on
+// day's date in its own location
+func CheckInDate(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Create records a check-in. The account's unique constraint refuses a
+// second one for the same member and day.
+func (r *WellnessCheckInRepository) Create(checkIn *models.WellnessCheckIn) error {
+	now := time.Now()
+	checkIn.Date = CheckInDate(checkIn.Date)
+	err := r.db.QueryRow(`
+		INSERT INTO wellness_checkins (account_id, user_id, date, mood, energy, sleep_hours, temperature, weight, notes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`,
+		checkIn.AccountID,
+		checkIn.UserID,
+		checkIn.Date,
+		checkIn.Mood,
+		checkIn.Energy,
+		checkIn.SleepHours,
+		checkIn.Temperature,
+		checkIn.Weight,
+		checkIn.Notes,
+		now,
+		now,
+	).Scan(&checkIn.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create check-in: %w", err)
+	}
+	checkIn.CreatedAt = now
+	checkIn.UpdatedAt = now
+	return nil
+}
+
+// GetByID retrieves one of an account's check-ins
+func (r *WellnessCheckInRepository) GetByID(id, accountID int64) (*models.WellnessCheckIn, error) {
+	query := `SELECT ` + wellnessCheckInColumns + ` FROM wellness_checkins WHERE id = ? AND account_id = ?`
+	return r.scanCheckIn(r.db.QueryRow(query, id, accountID))
+}
+
+// GetForDay retrieves a member's check-in for a calendar day
+func (r *WellnessCheckInRepository) GetForDay(accountID, userID int64, day time.Time) (*models.WellnessCheckIn, error) {
+	query := `SELECT ` + wellnessCheckInColumns + ` FROM wellness_checkins WHERE account_id = ? AND user_id = ? AND date = ?`
+	return r.scanCheckIn(r.db.QueryRow(query, accountID, userID, CheckInDate(day)))
+}
+
+// List retrieves an account's check-ins from start to end inclusive, newest
+// first. A zero userID returns every member's; zero dates leave that end of
+// the range open.
+func (r *WellnessCheckInRepository) List(accountID, userID int64, start, end time.Time, limit int) ([]*models.WellnessCheckIn, error) {
+	query := `SELECT ` + wellnessCheckInColumns + ` FROM wellness_checkins WHERE account_id = ?`
+	args := []interface{}{accountID}
+	if userID != 0 {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	if !start.IsZero() {
+		query += " AND date >= ?"
+		args = append(args, CheckInDate(start))
+	}
+	if !end.IsZero() {
+		query += " AND date <= ?"
+		args = append(args, CheckInDate(end))
+	}
+	query += " ORDER BY date DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query check-ins: %w", err)
+	}
+	defer rows.Close()
+
+	checkIns := []*models.WellnessCheckIn{}
+	for rows.Next() {
+		var c models.WellnessCheckIn
+		if err := rows.Scan(&c.ID, &c.AccountID, &c.UserID, &c.Date, &c.Mood, &c.Energy, &c.SleepHours,
+			&c.Temperature, &c.Weight, &c.Notes, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan check-in: %w", err)
+		}
+		checkIns = append(checkIns, &c)
+	}
+	return checkIns, rows.Err()
+}
+
+// Update saves a check-in's readings and notes. Its member and day are
+// fixed once recorded.
+func (r *WellnessCheckInRepository) Update(checkIn *models.WellnessCheckIn) error {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE wellness_checkins
+		SET mood = ?, energy = ?, sleep_hours = ?, temperature = ?, weight = ?, notes = ?, updated_at = ?
+		WHERE id = ? AND account_id = ?
+	`, checkIn.Mood, checkIn.Energy, checkIn.SleepHours, checkIn.Temperature, checkIn.Weight, checkIn.Notes, now,
+		checkIn.ID, checkIn.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to update check-in: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	checkIn.UpdatedAt = now
+	return nil
+}
+
+// Delete removes one of an account's check-ins
+func (r *WellnessCheckInRepository) Delete(id, accountID int64) error {
+	result, err := r.db.Exec(`DELETE FROM wellness_checkins WHERE id = ? AND account_id = ?`, id, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete check-in: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *WellnessCheckInRepository) scanCheckIn(row *sql.Row) (*models.WellnessCheckIn, error) {
+	var c models.WellnessCheckIn
+	err := row.Scan(&c.ID, &c.AccountID, &c.UserID, &c.Date, &c.Mood, &c.Energy, &c.SleepHours,
+		&c.Temperature, &c.Weight, &c.Notes, &c.CreatedAt, &c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get check-in: %w", err)
+	}
+	return &c, nil
+}
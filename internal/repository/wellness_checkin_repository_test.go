@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestWellnessCheckInRepository(t *testing.T) {
+	db := setupTestDBForNotifications(t)
+	defer db.Close()
+
+	repo := NewWellnessCheckInRepository(db)
+	day := time.Date(2026, 3, 20, 22, 15, 0, 0, time.FixedZone("EST", -5*3600))
+	checkIn := &models.WellnessCheckIn{
+		AccountID:  1,
+		UserID:     sql.NullInt64{Int64: 1, Valid: true},
+		Date:       day,
+		Mood:       sql.NullInt64{Int64: 3, Valid: true},
+		SleepHours: sql.NullFloat64{Float64: 6.5, Valid: true},
+	}
+	if err := repo.Create(checkIn); err != nil {
+		t.Fatalf("Failed to create check-in: %v", err)
+	}
+	if got := checkIn.Date.Format("2006-01-02"); got != "2026-03-20" {
+		t.Errorf("Expected the local calendar day, got %s", got)
+	}
+
+	// One check-in per member per day
+	err := repo.Create(&models.WellnessCheckIn{AccountID: 1, UserID: checkIn.UserID, Date: day, Mood: checkIn.Mood})
+	if err == nil {
+		t.Error("Expected a second check-in for the same day to be refused")
+	}
+
+	got, err := repo.GetForDay(1, 1, day)
+	if err != nil {
+		t.Fatalf("GetForDay failed: %v", err)
+	}
+	if got.ID != checkIn.ID || got.Mood.Int64 != 3 || got.SleepHours.Float64 != 6.5 {
+		t.Errorf("Unexpected check-in: %+v", got)
+	}
+	if _, err := repo.GetForDay(1, 1, day.AddDate(0, 0, 1)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for another day, got %v", err)
+	}
+
+	got.Energy = sql.NullInt64{Int64: 2, Valid: true}
+	if err := repo.Update(got); err != nil {
+		t.Fatalf("Failed to update check-in: %v", err)
+	}
+	list, err := repo.List(1, 0, day.AddDate(0, 0, -7), day, 10)
+	if err != nil {
+		t.Fatalf("Failed to list check-ins: %v", err)
+	}
+	if len(list) != 1 || list[0].Energy.Int64 != 2 {
+		t.Errorf("Expected the updated check-in in range, got %+v", list)
+	}
+	if list, _ := repo.List(1, 0, day.AddDate(0, 0, 1), time.Time{}, 10); len(list) != 0 {
+		t.Errorf("Expected nothing after the check-in's day, got %d", len(list))
+	}
+
+	if err := repo.Delete(checkIn.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another account's delete to miss, got %v", err)
+	}
+	if err := repo.Delete(checkIn.ID, 1); err != nil {
+		t.Fatalf("Failed to delete check-in: %v", err)
+	}
+}
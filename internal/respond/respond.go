@@ -0,0 +1,156 @@
+// Package respond writes API responses. Every error goes out in the same
+// JSON envelope so clients can branch on a stable code:
+//
+//	{"error": {"code": "not_found", "message": "Course not found"}}
+//
+// Validation failures may add per-field details:
+//
+//	{"error": {"code": "validation_failed", "message": "...",
+//	           "fields": [{"field": "side", "message": "must be left or right"}]}}
+package respond
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Code identifies the kind of error independently of the message text
+type Code string
+
+const (
+	CodeValidationFailed     Code = "validation_failed"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeTokenExpired         Code = "token_expired"
+	CodeSessionIdle          Code = "session_idle"
+	CodeSessionExpired       Code = "session_expired"
+	CodeForbidden            Code = "forbidden"
+	CodeCSRFInvalid          Code = "csrf_invalid"
+	CodeAccountSuspended     Code = "account_suspended"
+	CodeQuotaExceeded        Code = "quota_exceeded"
+	CodeNotFound             Code = "not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeConflict             Code = "conflict"
+	CodePreconditionFailed   Code = "precondition_failed"
+	CodePreconditionRequired Code = "precondition_required"
+	CodePayloadTooLarge      Code = "payload_too_large"
+	CodeUnsupportedMedia     Code = "unsupported_media_type"
+	CodeRateLimited          Code = "rate_limited"
+	CodeInternal             Code = "internal_error"
+	CodeNotImplemented       Code = "not_implemented"
+	CodeServiceUnavailable   Code = "service_unavailable"
+	CodeUpstreamFailed       Code = "upstream_failed"
+	CodeUnprocessable        Code = "unprocessable"
+	CodePossibleDuplicate    Code = "possible_duplicate"
+)
+
+// FieldError is a problem with one request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ErrorBody describes an error
+type ErrorBody struct {
+	Code    Code         `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// CodeFor returns the default code for an HTTP status
+func CodeFor(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeValidationFailed
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusPreconditionRequired:
+		return CodePreconditionRequired
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMedia
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusNotImplemented:
+		return CodeNotImplemented
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return CodeUpstreamFailed
+	case http.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return Code(strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_"))
+}
+
+// JSON writes v as a JSON response
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to encode JSON response", "err", err)
+	}
+}
+
+// Error writes an error with the default code for status. It takes the same
+// arguments as http.Error.
+func Error(w http.ResponseWriter, message string, status int) {
+	ErrorWithCode(w, status, CodeFor(status), message)
+}
+
+// ErrorWithCode writes an error with a specific code
+func ErrorWithCode(w http.ResponseWriter, status int, code Code, message string) {
+	// As http.Error does, drop a Content-Length meant for the body that will
+	// no longer be sent
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	JSON(w, status, ErrorResponse{Error: ErrorBody{Code: code, Message: message}})
+}
+
+// Validation writes a 400 validation_failed error listing the bad fields.
+// message defaults to a summary of the first field.
+func Validation(w http.ResponseWriter, message string, fields ...FieldError) {
+	if message == "" && len(fields) > 0 {
+		message = fields[0].Field + " " + fields[0].Message
+	}
+	JSON(w, http.StatusBadRequest, ErrorResponse{Error: ErrorBody{
+		Code:    CodeValidationFailed,
+		Message: message,
+		Fields:  fields,
+	}})
+}
+
+// Field is shorthand for a FieldError
+func Field(field, message string) FieldError {
+	return FieldError{Field: field, Message: message}
+}
+
+// NotFound is a router NotFound handler for API paths
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Error(w, "Not found", http.StatusNotFound)
+}
+
+// MethodNotAllowed is a router MethodNotAllowed handler for API paths
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,82 @@
+package respond
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) ErrorBody {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", ct)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode error: %v (%s)", err, rec.Body.String())
+	}
+	return resp.Error
+}
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		status int
+		code   Code
+	}{
+		{http.StatusBadRequest, CodeValidationFailed},
+		{http.StatusUnauthorized, CodeUnauthorized},
+		{http.StatusNotFound, CodeNotFound},
+		{http.StatusConflict, CodeConflict},
+		{http.StatusPreconditionFailed, CodePreconditionFailed},
+		{http.StatusPreconditionRequired, CodePreconditionRequired},
+		{http.StatusTooManyRequests, CodeRateLimited},
+		{http.StatusInternalServerError, CodeInternal},
+		{http.StatusBadGateway, CodeUpstreamFailed},
+		{http.StatusGone, "gone"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		Error(rec, "Something went wrong", tt.status)
+
+		if rec.Code != tt.status {
+			t.Errorf("Expected status %d, got %d", tt.status, rec.Code)
+		}
+		body := decodeError(t, rec)
+		if body.Code != tt.code || body.Message != "Something went wrong" || body.Fields != nil {
+			t.Errorf("Status %d: unexpected error %+v", tt.status, body)
+		}
+	}
+}
+
+func TestValidation(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Validation(rec, "", Field("side", "must be left or right"), Field("pain_level", "must be between 1 and 10"))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+	body := decodeError(t, rec)
+	if body.Code != CodeValidationFailed {
+		t.Errorf("Expected validation_failed, got %q", body.Code)
+	}
+	if body.Message != "side must be left or right" {
+		t.Errorf("Expected the first field as the message, got %q", body.Message)
+	}
+	if len(body.Fields) != 2 || body.Fields[1].Field != "pain_level" {
+		t.Errorf("Unexpected fields: %+v", body.Fields)
+	}
+}
+
+func TestErrorWithCode(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Length", "10")
+	ErrorWithCode(rec, http.StatusForbidden, CodeCSRFInvalid, "Invalid CSRF token")
+
+	if rec.Header().Get("Content-Length") != "" {
+		t.Error("Expected Content-Length to be dropped")
+	}
+	if body := decodeError(t, rec); body.Code != CodeCSRFInvalid {
+		t.Errorf("Expected csrf_invalid, got %q", body.Code)
+	}
+}
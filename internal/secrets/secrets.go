@@ -0,0 +1,326 @@
+// Package secrets encrypts the secrets P-TRACK keeps in its database: the
+// SMTP password, the auto-backup passphrase and destination credentials,
+// notification channel configs and webhook signing secrets.
+//
+// It uses envelope encryption. Each value is sealed with AES-256-GCM under
+// a random data key, and data keys are kept in the secret_keys table sealed
+// under the master key, which comes from SECRETS_KEY (or SECRETS_KEY_FILE)
+// and is never stored. A sealed value looks like
+//
+//	enc:v1:<data key id>:<base64 nonce and ciphertext>
+//
+// Rotate starts a new data key under the current master key. Callers then
+// re-seal every stored value with it and drop the old data keys, which is
+// also how values saved before a master key was configured get encrypted.
+// To change the master key, start the server with the new one in
+// SECRETS_KEY and the old one in SECRETS_PREVIOUS_KEYS, rotate, and remove
+// the old key.
+//
+// Without a master key values are stored as given, which is allowed
+// outside production.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// prefix marks a sealed value
+const prefix = "enc:v1:"
+
+// keySize is the size of master and data keys: AES-256
+const keySize = 32
+
+// ErrNoMasterKey is returned when opening a sealed value with no master key
+// configured
+var ErrNoMasterKey = errors.New("secret is encrypted but no SECRETS_KEY is configured")
+
+// Querier is what keys are read and written with: the database or a
+// transaction
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// masterKey seals data keys. Its id is a fingerprint, stored beside each
+// data key so the right master key can be picked to open it.
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring holds the master keys. A nil Keyring stores values unencrypted.
+type Keyring struct {
+	current  masterKey
+	previous []masterKey
+}
+
+// NewKeyring returns a keyring sealing new data keys with master, a 32 byte
+// base64 key as made by "openssl rand -base64 32", and able to open those
+// sealed with any of the previous keys
+func NewKeyring(master string, previous []string) (*Keyring, error) {
+	current, err := parseMasterKey(master)
+	if err != nil {
+		return nil, fmt.Errorf("SECRETS_KEY: %w", err)
+	}
+	k := &Keyring{current: current}
+	for i, p := range previous {
+		key, err := parseMasterKey(p)
+		if err != nil {
+			return nil, fmt.Errorf("SECRETS_PREVIOUS_KEYS (key %d): %w", i+1, err)
+		}
+		k.previous = append(k.previous, key)
+	}
+	return k, nil
+}
+
+func parseMasterKey(s string) (masterKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(raw) != keySize {
+		return masterKey{}, fmt.Errorf("must be %d random bytes, base64 encoded; generate one with: openssl rand -base64 32", keySize)
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return masterKey{}, err
+	}
+	sum := sha256.Sum256(raw)
+	return masterKey{id: hex.EncodeToString(sum[:8]), aead: aead}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// MasterKeyID is the fingerprint of the master key new data keys are sealed
+// with, or "" for a nil keyring
+func (k *Keyring) MasterKeyID() string {
+	if k == nil {
+		return ""
+	}
+	return k.current.id
+}
+
+// HasMasterKey reports whether data keys sealed with the master key with
+// the given fingerprint can be opened
+func (k *Keyring) HasMasterKey(id string) bool {
+	if k == nil {
+		return false
+	}
+	_, err := k.masterKey(id)
+	return err == nil
+}
+
+// masterKey returns the master key with the given fingerprint
+func (k *Keyring) masterKey(id string) (masterKey, error) {
+	if k.current.id == id {
+		return k.current, nil
+	}
+	for _, p := range k.previous {
+		if p.id == id {
+			return p, nil
+		}
+	}
+	return masterKey{}, fmt.Errorf("data key is sealed with master key %s, which isn't configured; add it to SECRETS_PREVIOUS_KEYS", id)
+}
+
+// IsSealed reports whether value was sealed by a keyring
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Seal encrypts plaintext under the newest data key, making one if there
+// are none yet. Empty values stay empty, so "not set" can still be told
+// apart, and a nil keyring returns plaintext as it is.
+func (k *Keyring) Seal(q Querier, plaintext string) (string, error) {
+	if k == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	id, aead, err := k.currentDataKey(q)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), valueAD(id))
+	return prefix + strconv.FormatInt(id, 10) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value Seal returned. Values that aren't sealed, saved
+// before a master key was configured, are returned as they are.
+func (k *Keyring) Open(q Querier, value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrNoMasterKey
+	}
+	idPart, data, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if !ok || err != nil {
+		return "", errors.New("malformed encrypted secret")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", errors.New("malformed encrypted secret")
+	}
+
+	var wrapped, masterID string
+	err = q.QueryRow(`SELECT wrapped_key, master_key_id FROM secret_keys WHERE id = ?`, id).Scan(&wrapped, &masterID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("data key %d of an encrypted secret is missing", id)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get data key: %w", err)
+	}
+	aead, err := k.unwrap(id, wrapped, masterID)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted secret")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], valueAD(id))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret with data key %d", id)
+	}
+	return string(plaintext), nil
+}
+
+// DataKey describes the data key new values are sealed with
+type DataKey struct {
+	ID          int64
+	MasterKeyID string
+	CreatedAt   time.Time
+}
+
+// CurrentDataKey returns the newest data key, or nil if there are none
+func CurrentDataKey(q Querier) (*DataKey, error) {
+	var d DataKey
+	var createdAt sql.NullTime
+	err := q.QueryRow(`SELECT id, master_key_id, created_at FROM secret_keys ORDER BY id DESC LIMIT 1`).
+		Scan(&d.ID, &d.MasterKeyID, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data key: %w", err)
+	}
+	d.CreatedAt = createdAt.Time
+	return &d, nil
+}
+
+// Rotate makes a new data key, sealed with the current master key, which
+// Seal uses from then on. The caller re-seals the stored values and then
+// calls DeleteDataKeysBefore with its id.
+func (k *Keyring) Rotate(q Querier) (int64, error) {
+	if k == nil {
+		return 0, ErrNoMasterKey
+	}
+	return k.newDataKey(q)
+}
+
+// DeleteDataKeysBefore deletes the data keys older than id, once nothing is
+// sealed with them
+func DeleteDataKeysBefore(q Querier, id int64) error {
+	if _, err := q.Exec(`DELETE FROM secret_keys WHERE id < ?`, id); err != nil {
+		return fmt.Errorf("failed to delete old data keys: %w", err)
+	}
+	return nil
+}
+
+// currentDataKey returns the newest data key, making one if there are none
+func (k *Keyring) currentDataKey(q Querier) (int64, cipher.AEAD, error) {
+	var id int64
+	var wrapped, masterID string
+	err := q.QueryRow(`SELECT id, wrapped_key, master_key_id FROM secret_keys ORDER BY id DESC LIMIT 1`).
+		Scan(&id, &wrapped, &masterID)
+	if err == sql.ErrNoRows {
+		if _, err := k.newDataKey(q); err != nil {
+			return 0, nil, err
+		}
+		return k.currentDataKey(q)
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get data key: %w", err)
+	}
+	aead, err := k.unwrap(id, wrapped, masterID)
+	return id, aead, err
+}
+
+func (k *Keyring) newDataKey(q Querier) (int64, error) {
+	key := make([]byte, keySize)
+	nonce := make([]byte, k.current.aead.NonceSize())
+	if _, err := rand.Read(key); err != nil {
+		return 0, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrapped := k.current.aead.Seal(nonce, nonce, key, []byte(k.current.id))
+
+	var id int64
+	err := q.QueryRow(`
+		INSERT INTO secret_keys (wrapped_key, master_key_id, created_at)
+		VALUES (?, ?, ?)
+		RETURNING id
+	`, base64.StdEncoding.EncodeToString(wrapped), k.current.id, time.Now().UTC()).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save data key: %w", err)
+	}
+	return id, nil
+}
+
+// unwrap opens a data key with the master key that sealed it
+func (k *Keyring) unwrap(id int64, wrapped, masterID string) (cipher.AEAD, error) {
+	master, err := k.masterKey(masterID)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil || len(sealed) < master.aead.NonceSize() {
+		return nil, fmt.Errorf("data key %d is malformed", id)
+	}
+	n := master.aead.NonceSize()
+	key, err := master.aead.Open(nil, sealed[:n], sealed[n:], []byte(masterID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data key %d with master key %s", id, masterID)
+	}
+	return newAEAD(key)
+}
+
+// valueAD binds a sealed value to its data key id, so the id in the value
+// can't be swapped for another
+func valueAD(id int64) []byte {
+	return []byte(prefix + strconv.FormatInt(id, 10))
+}
+
+// active is the keyring the repositories use
+var active atomic.Pointer[Keyring]
+
+// Use makes k the keyring the repositories seal and open secrets with. It
+// is set once at startup; nil stores secrets unencrypted.
+func Use(k *Keyring) {
+	active.Store(k)
+}
+
+// Active returns the keyring set by Use, which may be nil
+func Active() *Keyring {
+	return active.Load()
+}
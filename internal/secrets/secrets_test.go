@@ -0,0 +1,140 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"injection-tracker/internal/database"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), keySize)))
+}
+
+func setupDB(t *testing.T) *database.DB {
+	db, err := database.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	return db
+}
+
+func newKeyring(t *testing.T, master string, previous ...string) *Keyring {
+	k, err := NewKeyring(master, previous)
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	return k
+}
+
+func TestSealAndOpen(t *testing.T) {
+	db := setupDB(t)
+	k := newKeyring(t, testKey('a'))
+
+	sealed, err := k.Seal(db, "hunter2")
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "hunter2") {
+		t.Fatalf("Seal returned %q", sealed)
+	}
+	if again, _ := k.Seal(db, "hunter2"); again == sealed {
+		t.Error("Sealing the same value twice should give different results")
+	}
+	if opened, err := k.Open(db, sealed); err != nil || opened != "hunter2" {
+		t.Errorf("Open = %q, %v", opened, err)
+	}
+
+	// Values saved before encryption was set up pass through
+	if opened, err := k.Open(db, "plain"); err != nil || opened != "plain" {
+		t.Errorf("Open of a plain value = %q, %v", opened, err)
+	}
+	if empty, _ := k.Seal(db, ""); empty != "" {
+		t.Errorf("Seal of an empty value = %q, want it left empty", empty)
+	}
+
+	// A value can't be moved to another data key
+	other, err := k.Rotate(db)
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	_, data, _ := strings.Cut(strings.TrimPrefix(sealed, prefix), ":")
+	if _, err := k.Open(db, prefix+strconv.FormatInt(other, 10)+":"+data); err == nil {
+		t.Error("Open succeeded with the value moved to another data key")
+	}
+}
+
+func TestNilKeyring(t *testing.T) {
+	db := setupDB(t)
+	var k *Keyring
+
+	if sealed, err := k.Seal(db, "hunter2"); err != nil || sealed != "hunter2" {
+		t.Errorf("Seal without a key = %q, %v; want the value as it is", sealed, err)
+	}
+	sealed, err := newKeyring(t, testKey('a')).Seal(db, "hunter2")
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if _, err := k.Open(db, sealed); !errors.Is(err, ErrNoMasterKey) {
+		t.Errorf("Open without a key = %v, want ErrNoMasterKey", err)
+	}
+}
+
+func TestMasterKeyRotation(t *testing.T) {
+	db := setupDB(t)
+	old := newKeyring(t, testKey('a'))
+	sealed, err := old.Seal(db, "hunter2")
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	// A new master key alone can't open what the old one sealed
+	if _, err := newKeyring(t, testKey('b')).Open(db, sealed); err == nil || !strings.Contains(err.Error(), old.MasterKeyID()) {
+		t.Errorf("Open with the wrong master key = %v, want it to name the missing key", err)
+	}
+
+	k := newKeyring(t, testKey('b'), testKey('a'))
+	if opened, err := k.Open(db, sealed); err != nil || opened != "hunter2" {
+		t.Fatalf("Open with the old key in previous = %q, %v", opened, err)
+	}
+
+	// Rotating puts the new data key under the new master key, so the old
+	// one is no longer needed once values are re-sealed
+	id, err := k.Rotate(db)
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	resealed, err := k.Seal(db, "hunter2")
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if err := DeleteDataKeysBefore(db, id); err != nil {
+		t.Fatalf("DeleteDataKeysBefore failed: %v", err)
+	}
+	key, err := CurrentDataKey(db)
+	if err != nil || key == nil || key.ID != id || key.MasterKeyID != k.MasterKeyID() {
+		t.Fatalf("CurrentDataKey = %+v, %v", key, err)
+	}
+	if opened, err := newKeyring(t, testKey('b')).Open(db, resealed); err != nil || opened != "hunter2" {
+		t.Errorf("Open with only the new key = %q, %v", opened, err)
+	}
+}
+
+func TestNewKeyringRejectsBadKeys(t *testing.T) {
+	short := base64.StdEncoding.EncodeToString([]byte("too short"))
+	for _, key := range []string{"", "not base64!", short} {
+		if _, err := NewKeyring(key, nil); err == nil {
+			t.Errorf("NewKeyring(%q) succeeded", key)
+		}
+	}
+	if _, err := NewKeyring(testKey('a'), []string{short}); err == nil || !strings.Contains(err.Error(), "SECRETS_PREVIOUS_KEYS") {
+		t.Errorf("NewKeyring with a bad previous key = %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"injection-tracker/internal/config"
+	"injection-tracker/internal/logging"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/services"
+)
+
+// liveSettings are the parts of the server a configuration reload can
+// change while it runs
+type liveSettings struct {
+	publicRateLimiter *middleware.RateLimiter
+	userRateLimiter   *middleware.RateLimiter
+	loginRateLimiter  *middleware.RateLimiter
+	exportRateLimiter *middleware.RateLimiter
+	trustedProxies    *middleware.TrustedProxies
+}
+
+// watchReload reloads the configuration on SIGHUP until shutdown
+func watchReload(running *config.Config, live *liveSettings) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	services.RunBackground(func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-hup:
+				reloadConfig(running, live)
+			case <-services.ShutdownChan():
+				return
+			}
+		}
+	})
+}
+
+// reloadConfig re-reads .env and the config file and applies what it can.
+// A configuration that doesn't validate is ignored as a whole, and settings
+// that need a restart are only reported; running is what the server
+// started with.
+func reloadConfig(running *config.Config, live *liveSettings) {
+	_ = config.LoadDotEnv(".env")
+	next, err := config.Load()
+	if err != nil {
+		slog.Error("Config reload failed, keeping current settings", "err", err)
+		return
+	}
+	trusted, err := middleware.ParseTrustedProxies(next.Security.TrustedProxies)
+	if err != nil {
+		slog.Error("Config reload failed, keeping current settings", "err", err)
+		return
+	}
+
+	// Load has checked the level
+	_ = logging.SetLevel(next.Logging.Level)
+	middleware.SetCookieSecure(next.Security.CookieSecure)
+	live.trustedProxies.Replace(trusted)
+	live.publicRateLimiter.SetLimit(next.Security.RateLimitRequests, next.Security.RateLimitWindow)
+	live.userRateLimiter.SetLimit(next.Security.RateLimitRequests, next.Security.RateLimitWindow)
+	live.loginRateLimiter.SetLimit(next.Security.LoginRateLimit, next.Security.LoginRateWindow)
+	live.exportRateLimiter.SetLimit(next.Security.ExportRateLimit, next.Security.ExportRateWindow)
+
+	if changed := running.NeedsRestart(next); len(changed) > 0 {
+		slog.Warn("Changed settings take effect after a restart", "settings", strings.Join(changed, ", "))
+	}
+	slog.Info("Reloaded configuration")
+}
@@ -0,0 +1,864 @@
+// Package server wires the handlers into the web server and runs it
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"injection-tracker/internal/auth"
+	"injection-tracker/internal/config"
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/handlers"
+	"injection-tracker/internal/middleware"
+	"injection-tracker/internal/repository"
+	"injection-tracker/internal/respond"
+	"injection-tracker/internal/services"
+	"injection-tracker/internal/systemd"
+	"injection-tracker/internal/web"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+)
+
+// The unversioned /api alias was deprecated when /api/v1 was introduced and
+// is removed at the sunset date
+var (
+	unversionedAPIDeprecated = time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+	unversionedAPISunset     = time.Date(2027, time.April, 16, 0, 0, 0, 0, time.UTC)
+)
+
+// Run serves the web app and API on db until the process is told to stop or
+// a restore needs a restart. db must be migrated.
+func Run(cfg *config.Config, db *database.DB) error {
+	// Start auto-backup scheduler
+	handlers.StartAutoBackupScheduler(db)
+
+	// Start audit log retention
+	services.StartAuditRetentionScheduler(db)
+	services.StartCourseClosureScheduler(db)
+	services.StartTrashRetentionScheduler(db)
+	services.StartCheckInReminderScheduler(db)
+	services.StartMedicationReminderScheduler(db)
+	services.StartInjectionReminderScheduler(db)
+	services.StartMissedInjectionScheduler(db)
+	services.StartMissedMedicationScheduler(db)
+
+	// Start inventory notification scheduler
+	services.StartNotificationScheduler(db)
+
+	// Start webhook delivery retry worker
+	services.StartWebhookWorker(db)
+
+	// Start scheduled report emails
+	handlers.StartReportScheduler(db)
+
+	// Initialize security components
+	jwtManager := auth.NewJWTManager(cfg.Security.JWTSecret, cfg.Security.SessionMaxLifetime).
+		WithIdleTimeout(cfg.Security.SessionIdleTimeout).
+		WithDeviceTrust(cfg.Security.TrustedDeviceDuration)
+	csrfProtection := middleware.NewCSRFProtection(cfg.Security.CSRFSecret, middleware.CSRFOptions{
+		TokenTTL:     cfg.Security.CSRFTokenTTL,
+		CookieDomain: cfg.Security.CSRFCookieDomain,
+	})
+	rateLimitStore := middleware.NewMemoryRateLimitStore()
+	if cfg.Security.RateLimitStore == "database" {
+		rateLimitStore = repository.NewRateLimitRepository(db.Detach())
+		services.StartRateLimitPruner(db)
+	}
+	// Anonymous requests are limited per address and signed-in ones per
+	// user, so a household behind one NAT doesn't share a single budget
+	publicRateLimiter := middleware.NewRateLimiterWithStore(middleware.RateLimitPolicy{
+		Name:   "public",
+		Limit:  cfg.Security.RateLimitRequests,
+		Window: cfg.Security.RateLimitWindow,
+		Key:    middleware.RateLimitByIP,
+	}, rateLimitStore)
+	userRateLimiter := middleware.NewRateLimiterWithStore(middleware.RateLimitPolicy{
+		Name:   "user",
+		Limit:  cfg.Security.RateLimitRequests,
+		Window: cfg.Security.RateLimitWindow,
+		Key:    middleware.RateLimitByUser,
+	}, rateLimitStore)
+	loginRateLimiter := middleware.NewRateLimiterWithStore(middleware.RateLimitPolicy{
+		Name:   "login",
+		Limit:  cfg.Security.LoginRateLimit,
+		Window: cfg.Security.LoginRateWindow,
+		Key:    middleware.RateLimitByIP,
+	}, rateLimitStore)
+	exportRateLimiter := middleware.NewRateLimiterWithStore(middleware.RateLimitPolicy{
+		Name:   "export",
+		Limit:  cfg.Security.ExportRateLimit,
+		Window: cfg.Security.ExportRateWindow,
+		Key:    middleware.RateLimitByUser,
+	}, rateLimitStore)
+	authMiddleware := middleware.NewAuthMiddleware(jwtManager)
+	middleware.SetCookieSecure(cfg.Security.CookieSecure)
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.Security.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	// SIGHUP applies changes to the settings that don't need a restart
+	watchReload(cfg, &liveSettings{
+		publicRateLimiter: publicRateLimiter,
+		userRateLimiter:   userRateLimiter,
+		loginRateLimiter:  loginRateLimiter,
+		exportRateLimiter: exportRateLimiter,
+		trustedProxies:    trustedProxies,
+	})
+
+	// Initialize router
+	r := chi.NewRouter()
+
+	// Apply global middleware
+	r.Use(chimiddleware.RequestID)
+	r.Use(middleware.RealIP(trustedProxies))
+	r.Use(middleware.Logger)
+	r.Use(chimiddleware.Recoverer)
+	r.Use(chimiddleware.Timeout(60 * time.Second))
+	// A restore waits here for requests in flight and holds new ones back
+	r.Use(handlers.RequestGate().Middleware)
+	r.Use(middleware.SecurityHeaders(cfg.Security.CSPEnabled, cfg.Security.HSTSEnabled))
+
+	// CORS configuration
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   []string{"https://*", "http://localhost:*"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link", "API-Version", "Deprecation", "Sunset", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	}))
+
+	// /api/v1 is the current API; plain /api is a deprecated alias for it
+	r.Use(middleware.APIVersioning(unversionedAPIDeprecated, unversionedAPISunset))
+
+	// Initialize templates
+	if err := initializeTemplates(); err != nil {
+		return fmt.Errorf("failed to initialize templates: %w", err)
+	}
+
+	// While in maintenance mode only the admin gets past this
+	handlers.LoadMaintenanceMode(db)
+	maintenanceGate := handlers.MaintenanceGate(db)
+
+	// Public routes (no authentication required)
+	r.Group(func(r chi.Router) {
+		r.Use(publicRateLimiter.Middleware)
+		r.Use(handlers.Localize(db))
+
+		// Health check
+		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("OK"))
+		})
+
+		// Setup routes (always available)
+		r.Get("/setup", handlers.HandleSetupPage(db))
+		r.Post("/api/setup", handlers.HandleSetup(db))
+
+		// Public web pages (with setup check middleware)
+		r.With(requireSetupComplete(db)).Get("/", handlers.HandleHome(db))
+		r.With(requireSetupComplete(db)).Get("/login", handlers.HandleLoginPage(db, jwtManager))
+		r.With(requireSetupComplete(db), maintenanceGate).Get("/register", handlers.HandleRegisterPage(db))
+		r.With(requireSetupComplete(db), maintenanceGate).Get("/forgot-password", handlers.HandleForgotPasswordPage(db))
+
+		// Authentication routes
+		r.Route("/api/auth", func(r chi.Router) {
+			r.With(loginRateLimiter.Middleware).Post("/login", handlers.HandleLogin(db, jwtManager))
+			r.With(loginRateLimiter.Middleware, maintenanceGate).Post("/register", handlers.HandleRegister(db))
+			r.With(maintenanceGate).Post("/forgot-password", handleForgotPassword(db))
+			r.With(maintenanceGate).Post("/reset-password", handleResetPassword(db))
+
+			// The rest need a login. They live here because this route
+			// takes every /api/auth path, so in the protected /api routes
+			// below they could never be reached.
+			signedIn := func(requireAuth func(http.Handler) http.Handler) func(chi.Router) {
+				return func(r chi.Router) {
+					r.Use(requireAuth)
+					r.Use(handlers.CurrentSession(db, jwtManager))
+					r.Use(handlers.CurrentMembership(db))
+					r.Use(handlers.AccountSuspension)
+					r.Use(handlers.Localize(db))
+					r.Use(userRateLimiter.Middleware)
+					r.Use(csrfProtection.Middleware)
+				}
+			}
+			r.Group(func(r chi.Router) {
+				signedIn(authMiddleware.RequireAuth)(r)
+
+				r.Get("/me", handlers.HandleGetCurrentUser(db))
+				r.Post("/logout", handlers.HandleLogout(db))
+				r.Post("/stop-impersonating", handlers.HandleStopImpersonation(db, jwtManager))
+			})
+			// An expired token can be refreshed while its session is
+			// still in use
+			r.Group(func(r chi.Router) {
+				signedIn(authMiddleware.RequireRefreshableAuth)(r)
+
+				r.Post("/refresh", handlers.HandleRefreshToken(db, jwtManager))
+			})
+		})
+
+		// Content Security Policy violation reports from browsers
+		r.Post("/api/csp-report", handlers.HandleCSPReport)
+
+		// Serve static files
+		r.Get("/static/*", http.StripPrefix("/static/", http.FileServer(http.Dir("./static"))).ServeHTTP)
+		r.Get("/manifest.json", serveManifest)
+
+		// Calendar feed, authenticated by the token in its URL so calendar
+		// apps can subscribe
+		r.With(maintenanceGate).Get("/calendar.ics", handlers.HandleCalendarFeed(db))
+
+		// Read-only dashboard for caregivers, authenticated by the token in
+		// its URL
+		r.With(maintenanceGate).Get("/share/{token}", handlers.HandleSharedDashboard(db))
+		r.Get("/service-worker.js", serveServiceWorker)
+	})
+
+	// Protected routes (authentication required)
+	r.Group(func(r chi.Router) {
+		r.Use(authMiddleware.RequireAuth)
+		r.Use(handlers.CurrentSession(db, jwtManager))
+		r.Use(handlers.CurrentMembership(db))
+		r.Use(handlers.AccountSuspension)
+		r.Use(handlers.Localize(db))
+		r.Use(maintenanceGate)
+		r.Use(userRateLimiter.Middleware)
+		r.Use(csrfProtection.Middleware)
+
+		// API routes
+		r.Route("/api", func(r chi.Router) {
+			r.NotFound(respond.NotFound)
+			r.MethodNotAllowed(respond.MethodNotAllowed)
+
+			r.Get("/csrf-token", handleGetCSRFToken(csrfProtection))
+			r.Get("/openapi.json", handlers.HandleOpenAPISpec)
+
+			// Dashboard routes
+			r.Get("/dashboard", handlers.HandleGetDashboard(db))
+			r.Get("/dashboard/recent", handlers.HandleGetRecentActivity(db))
+			r.Get("/activity", handlers.HandleGetActivity(db))
+			r.Get("/stats/milestones", handlers.HandleGetMilestones(db))
+			r.Get("/announcements", handlers.HandleGetAnnouncements(db))
+			r.Get("/features", handlers.HandleGetFeatures(db))
+
+			// Live updates for the account's other open sessions
+			r.Get("/events", handlers.HandleEvents(db))
+
+			// Changes the PWA queued while offline
+			r.Post("/sync", handlers.HandleSync(db))
+
+			// Account management routes
+			r.Route("/account", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetAccount(db))
+				r.Put("/", handlers.HandleUpdateAccount(db))
+				r.Get("/members", handlers.HandleGetAccountMembers(db))
+				r.Delete("/members/{userID}", handlers.HandleRemoveAccountMember(db))
+				r.Put("/members/{userID}/role", handlers.HandleUpdateMemberRole(db))
+				r.Post("/members/{userID}/transfer-ownership", handlers.HandleTransferOwnership(db))
+				r.Get("/my-data", handlers.HandleGetMyData(db))
+				// Only the user can delete their account, not an admin
+				// impersonating them
+				r.With(handlers.NoImpersonation).Post("/deletion", handlers.HandleRequestAccountDeletion(db))
+				r.With(handlers.NoImpersonation).Delete("/deletion", handlers.HandleCancelAccountDeletion(db))
+				r.With(handlers.NoImpersonation).Post("/deletion/confirm", handlers.HandleConfirmAccountDeletion(db))
+
+				// Invitations
+				r.Route("/invitations", func(r chi.Router) {
+					r.Post("/", handlers.HandleCreateInvitation(db))
+					r.Get("/", handlers.HandleGetInvitations(db))
+					r.Post("/{id}/resend", handlers.HandleResendInvitation(db))
+					r.Delete("/{id}", handlers.HandleRevokeInvitation(db))
+					r.Post("/accept", handlers.HandleAcceptInvitation(db))
+				})
+			})
+
+			// Course routes
+			r.Route("/courses", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetCourses(db))
+				r.Post("/", handlers.HandleCreateCourse(db))
+				r.Get("/active", handlers.HandleGetActiveCourse(db))
+				r.Get("/{id}", handlers.HandleGetCourse(db))
+				r.Put("/{id}", handlers.HandleUpdateCourse(db))
+				r.Delete("/{id}", handlers.HandleDeleteCourse(db))
+				r.Post("/{id}/activate", handlers.HandleActivateCourse(db))
+				r.Post("/{id}/close", handlers.HandleCloseCourse(db))
+				r.Get("/{id}/taper", handlers.HandleGetDoseSchedule(db))
+				r.Put("/{id}/taper", handlers.HandleUpdateDoseSchedule(db))
+				r.Get("/{id}/schedule", handlers.HandleGetCourseSchedule(db))
+				r.Put("/{id}/schedule", handlers.HandleUpdateCourseSchedule(db))
+				r.Delete("/{id}/schedule", handlers.HandleDeleteCourseSchedule(db))
+			})
+
+			// Compound routes (injectable medications courses can track)
+			r.Route("/compounds", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetCompounds(db))
+				r.Post("/", handlers.HandleCreateCompound(db))
+				r.Get("/{id}", handlers.HandleGetCompound(db))
+				r.Put("/{id}", handlers.HandleUpdateCompound(db))
+				r.Delete("/{id}", handlers.HandleDeleteCompound(db))
+			})
+
+			// Injection routes
+			r.Route("/injections", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetInjections(db))
+				r.Post("/", handlers.HandleCreateInjection(db))
+				r.Get("/recent", handlers.HandleGetRecentInjections(db))
+				r.Get("/stats", handlers.HandleGetInjectionStats(db))
+				r.Get("/duplicates", handlers.HandleGetInjectionDuplicates(db))
+				r.Get("/missed", handlers.HandleGetMissedInjections(db))
+				r.Put("/missed/{id}", handlers.HandleAnnotateMissedInjection(db))
+				r.Delete("/missed/{id}", handlers.HandleDeleteMissedInjection(db))
+				r.Post("/sessions", handlers.HandleStartInjectionSession(db))
+				r.Get("/sessions/current", handlers.HandleGetCurrentInjectionSession(db))
+				r.Get("/sessions/{id}", handlers.HandleGetInjectionSession(db))
+				r.Post("/sessions/{id}/warming", handlers.HandleInjectionSessionWarming(db))
+				r.Put("/sessions/{id}/details", handlers.HandleInjectionSessionDetails(db))
+				r.Post("/sessions/{id}/complete", handlers.HandleCompleteInjectionSession(db))
+				r.Post("/sessions/{id}/cancel", handlers.HandleCancelInjectionSession(db))
+				r.With(handlers.RequireFeature(db, services.FeatureRotationPlanner)).Get("/next-site", handlers.HandleGetNextSite(db))
+				r.Get("/{id}", handlers.HandleGetInjection(db))
+				r.Put("/{id}", handlers.HandleUpdateInjection(db))
+				r.Delete("/{id}", handlers.HandleDeleteInjection(db))
+				r.Post("/{id}/restore", handlers.HandleRestoreInjection(db))
+			})
+
+			// Symptom routes
+			r.Route("/symptoms", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetSymptoms(db))
+				r.Post("/", handlers.HandleCreateSymptom(db))
+				r.Delete("/", handlers.HandleBulkDeleteSymptoms(db))
+				r.Get("/recent", handlers.HandleGetRecentSymptoms(db))
+				r.Get("/trends", handlers.HandleGetSymptomTrends(db))
+				r.Get("/catalog", handlers.HandleGetSymptomCatalog(db))
+				r.Post("/catalog", handlers.HandleCreateSymptomCatalogItem(db))
+				r.Put("/catalog/{id}", handlers.HandleUpdateSymptomCatalogItem(db))
+				r.Delete("/catalog/{id}", handlers.HandleDeleteSymptomCatalogItem(db))
+				r.Get("/{id}", handlers.HandleGetSymptom(db))
+				r.Put("/{id}", handlers.HandleUpdateSymptom(db))
+				r.Delete("/{id}", handlers.HandleDeleteSymptom(db))
+			})
+
+			// Daily wellness check-ins
+			r.Route("/checkins", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetCheckIns(db))
+				r.Post("/", handlers.HandleCreateCheckIn(db))
+				r.Get("/today", handlers.HandleGetTodayCheckIn(db))
+				r.Get("/trends", handlers.HandleGetCheckInTrends(db))
+				r.Put("/{id}", handlers.HandleUpdateCheckIn(db))
+				r.Delete("/{id}", handlers.HandleDeleteCheckIn(db))
+			})
+
+			r.Route("/journal", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetJournalEntries(db))
+				r.Post("/", handlers.HandleCreateJournalEntry(db))
+				r.Get("/tags", handlers.HandleGetJournalTags(db))
+				r.Get("/{id}", handlers.HandleGetJournalEntry(db))
+				r.Put("/{id}", handlers.HandleUpdateJournalEntry(db))
+				r.Delete("/{id}", handlers.HandleDeleteJournalEntry(db))
+			})
+
+			r.Route("/labs", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetLabResults(db))
+				r.Post("/", handlers.HandleCreateLabResult(db))
+				r.Get("/analytes", handlers.HandleGetLabAnalytes(db))
+				r.Get("/trends", handlers.HandleGetLabTrends(db))
+				r.Get("/{id}", handlers.HandleGetLabResult(db))
+				r.Put("/{id}", handlers.HandleUpdateLabResult(db))
+				r.Delete("/{id}", handlers.HandleDeleteLabResult(db))
+			})
+
+			// Travel mode
+			r.Route("/trips", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetTrips(db))
+				r.Post("/", handlers.HandleCreateTrip(db))
+				r.Get("/{id}", handlers.HandleGetTrip(db))
+				r.Put("/{id}", handlers.HandleUpdateTrip(db))
+				r.Delete("/{id}", handlers.HandleDeleteTrip(db))
+			})
+
+			// Attachment routes (photos for injections and symptom logs)
+			r.Route("/attachments", func(r chi.Router) {
+				r.Post("/", handlers.HandleUploadAttachment(db))
+				r.Get("/{id}", handlers.HandleGetAttachment(db))
+				r.Get("/{id}/file", handlers.HandleGetAttachmentFile(db))
+				r.Get("/{id}/thumbnail", handlers.HandleGetAttachmentThumbnail(db))
+				r.Delete("/{id}", handlers.HandleDeleteAttachment(db))
+			})
+
+			// Medication routes
+			r.Route("/medications", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetMedications(db))
+				r.Post("/", handlers.HandleCreateMedication(db))
+				r.Get("/search", handlers.HandleSearchMedicationCatalog(db))
+				r.Get("/schedule/today", handlers.HandleGetDailySchedule(db))
+				r.Get("/adherence", handlers.HandleGetAdherence(db))
+				r.Patch("/bulk", handlers.HandleBulkUpdateMedications(db))
+				r.Get("/{id}", handlers.HandleGetMedication(db))
+				r.Put("/{id}", handlers.HandleUpdateMedication(db))
+				r.Delete("/{id}", handlers.HandleDeleteMedication(db))
+				r.Post("/{id}/log", handlers.HandleLogMedication(db))
+				r.Get("/{id}/logs", handlers.HandleGetMedicationLogs(db))
+				r.Get("/{id}/doses", handlers.HandleGetMedicationDoses(db))
+				r.Post("/{id}/snooze", handlers.HandleSnoozeMedicationDose(db))
+			})
+
+			// Inventory routes
+			r.Route("/inventory", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetInventory(db))
+				r.Put("/{itemType}", handlers.HandleUpdateInventory(db))
+				r.Get("/history", handlers.HandleGetAllInventoryHistory(db))
+				r.Get("/history/recent", handlers.HandleGetRecentInventoryChanges(db))
+				r.Post("/history/{id}/reverse", handlers.HandleReverseInventoryEntry(db))
+				r.Get("/{itemType}/history", handlers.HandleGetInventoryHistory(db))
+				r.Post("/{itemType}/adjust", handlers.HandleAdjustInventory(db))
+				r.Get("/{itemType}/lots", handlers.HandleGetInventoryLots(db))
+				r.Get("/vials", handlers.HandleGetInventoryVials(db))
+				r.Post("/vials", handlers.HandleReceiveInventoryVials(db))
+				r.Get("/vials/{id}", handlers.HandleGetInventoryVial(db))
+				r.Put("/vials/{id}", handlers.HandleUpdateInventoryVial(db))
+				r.Delete("/vials/{id}", handlers.HandleDeleteInventoryVial(db))
+				r.Post("/vials/{id}/open", handlers.HandleOpenInventoryVial(db))
+				r.Post("/vials/{id}/discard", handlers.HandleDiscardInventoryVial(db))
+				r.Post("/barcode/resolve", handlers.HandleResolveBarcode(db))
+				r.Get("/barcode/mappings", handlers.HandleGetBarcodeMappings(db))
+				r.Post("/barcode/mappings", handlers.HandleSaveBarcodeMapping(db))
+				r.Delete("/barcode/mappings/{id}", handlers.HandleDeleteBarcodeMapping(db))
+				r.Get("/stocktakes", handlers.HandleGetStocktakes(db))
+				r.Post("/stocktakes", handlers.HandleStartStocktake(db))
+				r.Get("/stocktakes/{id}", handlers.HandleGetStocktake(db))
+				r.Get("/stocktakes/{id}/export", handlers.HandleExportStocktake(db))
+				r.Post("/stocktakes/{id}/counts", handlers.HandleRecordStocktakeCount(db))
+				r.Delete("/stocktakes/{id}/counts/{countId}", handlers.HandleDeleteStocktakeCount(db))
+				r.Post("/stocktakes/{id}/complete", handlers.HandleCompleteStocktake(db))
+				r.Post("/stocktakes/{id}/cancel", handlers.HandleCancelStocktake(db))
+				r.Get("/forecast", handlers.HandleGetInventoryForecast(db))
+				r.Get("/alerts", handlers.HandleGetInventoryAlerts(db))
+				r.Get("/stock-alerts", handlers.HandleGetStockAlerts(db))
+				r.Post("/stock-alerts/{id}/acknowledge", handlers.HandleAcknowledgeStockAlert(db))
+				r.Post("/settings", handlers.HandleUpdateInventorySettings(db))
+				r.Get("/item-types", handlers.HandleGetInventoryItemTypes(db))
+				r.Post("/item-types", handlers.HandleCreateInventoryItemType(db))
+				r.Put("/item-types/{itemType}", handlers.HandleUpdateInventoryItemType(db))
+				r.Delete("/item-types/{itemType}", handlers.HandleDeleteInventoryItemType(db))
+				r.Get("/consumption-profile", handlers.HandleGetConsumptionProfile(db))
+				r.Put("/consumption-profile", handlers.HandleUpdateConsumptionProfile(db))
+				r.Get("/consumption-profile/versions", handlers.HandleGetConsumptionProfileVersions(db))
+			})
+
+			// Purchase order routes
+			r.Route("/purchases", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetPurchaseOrders(db))
+				r.Post("/", handlers.HandleCreatePurchaseOrder(db))
+				r.Get("/spend", handlers.HandleGetPurchaseSpend(db))
+				r.Get("/{id}", handlers.HandleGetPurchaseOrder(db))
+				r.Put("/{id}", handlers.HandleUpdatePurchaseOrder(db))
+				r.Delete("/{id}", handlers.HandleDeletePurchaseOrder(db))
+				r.Post("/{id}/receive", handlers.HandleReceivePurchaseOrder(db))
+			})
+
+			// Export routes
+			r.Route("/export", func(r chi.Router) {
+				// Exports are expensive to build, so they get a budget of
+				// their own
+				r.Use(exportRateLimiter.Middleware)
+				r.Get("/pdf", handlers.HandleExportPDF(db))
+				r.Get("/csv", handlers.HandleExportCSV(db))
+				r.Get("/xlsx", handlers.HandleExportXLSX(db))
+				r.Get("/fhir", handlers.HandleExportFHIR(db))
+				r.Get("/json", handlers.HandleExportAccountData(db))
+			})
+
+			// Import routes
+			r.Post("/import/injections", handlers.HandleImportInjections(db))
+			r.Post("/import/json", handlers.HandleImportAccountData(db))
+			r.Post("/import/health", handlers.HandleImportHealth(db))
+			r.Get("/import/health/mappings", handlers.HandleGetHealthImportMappings(db))
+			r.Put("/import/health/mappings", handlers.HandleUpdateHealthImportMappings(db))
+			r.Get("/vitals", handlers.HandleGetVitals(db))
+
+			// Settings routes
+			r.Get("/settings", handlers.HandleGetSettings(db))
+			r.Put("/settings", handlers.HandleUpdateSettings(db))
+			r.Post("/settings/profile", handlers.HandleUpdateProfile(db))
+			r.With(handlers.NoImpersonation).Post("/settings/password", handlers.HandleChangePassword(db))
+			r.Post("/settings/app", handlers.HandleUpdateAppSettings(db))
+			r.Post("/settings/notifications", handlers.HandleUpdateNotificationSettings(db))
+			r.Get("/me/preferences", handlers.HandleGetPreferences(db))
+			r.Put("/me/preferences", handlers.HandleUpdatePreferences(db))
+			r.Get("/me/security", handlers.HandleGetSecurity(db))
+			r.Delete("/me/security/sessions/{id}", handlers.HandleRevokeSession(db))
+			r.Delete("/me/security/devices/{id}", handlers.HandleRevokeTrustedDevice(db))
+
+			// Notification routes
+			r.Get("/notifications", handlers.HandleGetNotifications(db))
+			r.Get("/notifications/count", handlers.HandleGetUnreadCount(db))
+			r.Put("/notifications/{id}/read", handlers.HandleMarkNotificationRead(db))
+			r.Post("/notifications/mark-all-read", handlers.HandleMarkAllNotificationsRead(db))
+			r.Delete("/notifications/{id}", handlers.HandleDeleteNotification(db))
+
+			// Notification channel routes (ntfy, Gotify, webhooks)
+			r.Route("/notification-channels", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetNotificationChannels(db))
+				r.Post("/", handlers.HandleCreateNotificationChannel(db))
+				r.Put("/{id}", handlers.HandleUpdateNotificationChannel(db))
+				r.Delete("/{id}", handlers.HandleDeleteNotificationChannel(db))
+				r.Post("/{id}/test", handlers.HandleTestNotificationChannel(db))
+			})
+
+			// Calendar data
+			r.Get("/calendar", handlers.HandleGetCalendar(db))
+
+			// Appointment routes
+			r.Route("/appointments", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetAppointments(db))
+				r.Post("/", handlers.HandleCreateAppointment(db))
+				r.Get("/{id}", handlers.HandleGetAppointment(db))
+				r.Put("/{id}", handlers.HandleUpdateAppointment(db))
+				r.Delete("/{id}", handlers.HandleDeleteAppointment(db))
+			})
+
+			// Calendar feed token routes
+			r.Route("/calendar/tokens", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetCalendarTokens(db))
+				r.Post("/", handlers.HandleCreateCalendarToken(db))
+				r.Delete("/{id}", handlers.HandleRevokeCalendarToken(db))
+			})
+
+			// Caregiver share link routes
+			r.Route("/share-links", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetShareLinks(db))
+				r.Post("/", handlers.HandleCreateShareLink(db))
+				r.Delete("/{id}", handlers.HandleRevokeShareLink(db))
+				r.Get("/{id}/accesses", handlers.HandleGetShareLinkAccesses(db))
+			})
+
+			// Trash routes
+			r.Route("/trash", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetTrash(db))
+				r.Delete("/", handlers.HandleEmptyTrash(db))
+				r.Post("/{id}/restore", handlers.HandleRestoreTrashItem(db))
+				r.Delete("/{id}", handlers.HandlePurgeTrashItem(db))
+			})
+
+			// Saved report routes
+			r.Route("/reports", func(r chi.Router) {
+				r.Get("/fields", handlers.HandleGetReportFields())
+				r.Post("/preview", handlers.HandlePreviewReport(db))
+				r.Get("/", handlers.HandleGetSavedReports(db))
+				r.Post("/", handlers.HandleCreateSavedReport(db))
+				r.Get("/{id}", handlers.HandleGetSavedReport(db))
+				r.Put("/{id}", handlers.HandleUpdateSavedReport(db))
+				r.Delete("/{id}", handlers.HandleDeleteSavedReport(db))
+				r.Get("/{id}/run", handlers.HandleRunSavedReport(db))
+			})
+
+			// Scheduled report email routes
+			r.Route("/report-schedules", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetReportSchedules(db))
+				r.Post("/", handlers.HandleCreateReportSchedule(db))
+				r.Put("/{id}", handlers.HandleUpdateReportSchedule(db))
+				r.Delete("/{id}", handlers.HandleDeleteReportSchedule(db))
+				r.Post("/{id}/send", handlers.HandleSendReportNow(db))
+			})
+
+			// Webhook routes
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Get("/", handlers.HandleGetWebhooks(db))
+				r.Post("/", handlers.HandleCreateWebhook(db))
+				r.Get("/{id}", handlers.HandleGetWebhook(db))
+				r.Put("/{id}", handlers.HandleUpdateWebhook(db))
+				r.Delete("/{id}", handlers.HandleDeleteWebhook(db))
+				r.Get("/{id}/deliveries", handlers.HandleGetWebhookDeliveries(db))
+			})
+
+			// Admin routes (first user only)
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(handlers.RequireAdmin(db))
+				r.Get("/settings", handlers.HandleGetAdminSettings(db))
+				r.Put("/smtp", handlers.HandleUpdateSMTPSettings(db))
+				r.Post("/smtp/test", handlers.HandleTestSMTP(db))
+				r.Get("/stats", handlers.HandleGetSiteStats(db))
+				// Site settings
+				r.Get("/site", handlers.HandleGetSiteSettings(db))
+				r.Put("/site", handlers.HandleUpdateSiteSettings(db))
+				// Announcements and maintenance mode
+				r.Get("/announcements", handlers.HandleListAllAnnouncements(db))
+				r.Post("/announcements", handlers.HandleCreateAnnouncement(db))
+				r.Delete("/announcements/{id}", handlers.HandleDeleteAnnouncement(db))
+				r.Get("/maintenance", handlers.HandleGetMaintenanceMode(db))
+				r.Put("/maintenance", handlers.HandleUpdateMaintenanceMode(db))
+
+				// Feature flags
+				r.Get("/flags", handlers.HandleListFeatureFlags(db))
+				r.Put("/flags", handlers.HandleSetFeatureFlag(db))
+				r.Delete("/flags", handlers.HandleClearFeatureFlag(db))
+				// NDC directory for barcode lookups
+				r.Get("/ndc-products", handlers.HandleListNDCProducts(db))
+				r.Post("/ndc-products", handlers.HandleImportNDCProducts(db))
+				r.Delete("/ndc-products", handlers.HandleDeleteNDCProduct(db))
+				// User management
+				r.Get("/users", handlers.HandleGetAllUsers(db))
+				r.Put("/users/status", handlers.HandleDeactivateUser(db))
+				r.Delete("/users", handlers.HandleDeleteUser(db))
+				r.Post("/users/impersonate", handlers.HandleStartImpersonation(db, jwtManager))
+				// Account management
+				r.Get("/accounts", handlers.HandleGetAllAccounts(db))
+				r.Delete("/accounts", handlers.HandleDeleteAccount(db))
+				r.Post("/accounts/suspend", handlers.HandleSuspendAccount(db))
+				r.Post("/accounts/reactivate", handlers.HandleReactivateAccount(db))
+				r.Get("/accounts/usage", handlers.HandleGetAccountUsage(db))
+				r.Get("/quotas", handlers.HandleGetQuotas(db))
+				r.Put("/quotas", handlers.HandleUpdateQuotas(db))
+				r.Get("/accounts/backups", handlers.HandleListAccountBackups(db))
+				r.Post("/accounts/backups", handlers.HandleCreateAccountBackup(db))
+				r.Get("/accounts/backups/download", handlers.HandleDownloadAccountBackup(db))
+				r.Delete("/accounts/backups", handlers.HandleDeleteAccountBackup(db))
+				r.Post("/accounts/backups/upload", handlers.HandleUploadAccountBackup(db))
+				r.Post("/accounts/backups/restore", handlers.HandleRestoreAccountBackup(db))
+				// Backup management
+				r.Get("/backups", handlers.HandleListBackups(db))
+				r.Post("/backups", handlers.HandleCreateBackup(db))
+				r.Get("/backups/download", handlers.HandleDownloadBackup(db))
+				r.Delete("/backups", handlers.HandleDeleteBackup(db))
+				r.Post("/backups/upload", handlers.HandleUploadBackup(db))
+				r.Post("/backups/restore", handlers.HandleRestoreBackup(db))
+				r.Get("/backups/auto", handlers.HandleGetAutoBackupSettings(db))
+				r.Put("/backups/auto", handlers.HandleUpdateAutoBackupSettings(db))
+				r.Get("/backups/remote", handlers.HandleListRemoteBackups(db))
+				r.Post("/backups/remote/fetch", handlers.HandleFetchRemoteBackup(db))
+				// Database integrity
+				r.Get("/integrity", handlers.HandleCheckIntegrity(db))
+				r.Post("/integrity/repair", handlers.HandleRepairIntegrity(db))
+
+				// Encryption of stored secrets
+				r.Get("/secrets", handlers.HandleGetSecretsStatus(db))
+				r.Post("/secrets/rotate", handlers.HandleRotateSecrets(db))
+
+				// Diagnostics
+				r.Get("/diagnostics", handlers.HandleGetDiagnostics(db))
+				r.Get("/diagnostics/bundle", handlers.HandleDownloadSupportBundle(db))
+				// Audit logs
+				r.Get("/audit-logs", handlers.HandleGetAuditLogs(db))
+				r.Get("/audit-logs/export", handlers.HandleExportAuditLogs(db))
+				r.Get("/audit-logs/retention", handlers.HandleGetAuditRetention(db))
+				r.Put("/audit-logs/retention", handlers.HandleUpdateAuditRetention(db))
+				r.Post("/audit-logs/prune", handlers.HandlePruneAuditLogs(db))
+				// Closing courses past their end date
+				r.Get("/courses/auto-close", handlers.HandleGetCourseAutoClose(db))
+				r.Put("/courses/auto-close", handlers.HandleUpdateCourseAutoClose(db))
+				r.Post("/courses/auto-close/run", handlers.HandleRunCourseAutoClose(db))
+			})
+			r.Get("/me/admin", handlers.HandleCheckAdmin(db))
+		})
+
+		// Protected web pages (HTML responses)
+		r.Get("/dashboard", handlers.HandleDashboard(db, csrfProtection))
+		r.Get("/activity", handlers.HandleActivityPage(db, csrfProtection))
+		r.Get("/injections", handlers.HandleInjectionsPage(db, csrfProtection))
+		r.Get("/symptoms", handlers.HandleSymptomsPage(db, csrfProtection))
+		r.Get("/symptoms/log", handlers.HandleLogSymptomPage(db))
+		r.Get("/symptoms/{id}/edit", handlers.HandleEditSymptomPage(db, csrfProtection))
+		r.Get("/symptoms/history", handlers.HandleSymptomsHistoryPage(db, csrfProtection))
+		r.Get("/medications", handlers.HandleMedicationsPage(db, csrfProtection))
+		r.Get("/medications/log", handlers.HandleLogMedicationPage(db))
+		r.Get("/medications/new", handlers.HandleNewMedicationPage(db))
+		r.Get("/inventory", handlers.HandleInventoryPage(db, csrfProtection))
+		r.Get("/inventory/history", handlers.HandleInventoryHistoryPage(db, csrfProtection))
+		r.Get("/inventory/{itemType}/history", handlers.HandleInventoryItemHistoryPage(db, csrfProtection))
+		r.Get("/courses", handlers.HandleCoursesPage(db, csrfProtection))
+		r.Get("/courses/new", handlers.HandleNewCoursePage(db))
+		r.Get("/calendar", handlers.HandleCalendarPage(db, csrfProtection))
+		r.Get("/reports", handlers.HandleReportsPage(db, csrfProtection))
+		r.Get("/settings", handlers.HandleSettingsPage(db, csrfProtection))
+		r.Get("/help", handlers.HandleHelpPage(db, csrfProtection))
+		r.Get("/about", handlers.HandleAboutPage(db, csrfProtection))
+		r.Get("/api-docs", handlers.HandleAPIDocsPage(db, csrfProtection))
+	})
+
+	// Start server
+	addr := fmt.Sprintf(":%s", cfg.Server.Port)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Live event streams and schedulers stop when shutdown starts
+	srv.RegisterOnShutdown(services.Shutdown)
+
+	tlsConfig, httpHandler, err := setupTLS(cfg.TLS, cfg.Server.Port)
+	if err != nil {
+		return fmt.Errorf("failed to set up TLS: %w", err)
+	}
+	srv.TLSConfig = tlsConfig
+
+	// Under systemd socket activation the socket outlives the process, so
+	// connections wait in its backlog during a restart instead of failing
+	ln, err := systemd.Listener()
+	if err != nil {
+		return fmt.Errorf("failed to use systemd socket: %w", err)
+	}
+	if ln == nil {
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			return fmt.Errorf("server failed to start: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			serveErr <- srv.ServeTLS(ln, "", "")
+		} else {
+			serveErr <- srv.Serve(ln)
+		}
+	}()
+	slog.Info("Server starting", "addr", ln.Addr().String(), "tls", tlsConfig != nil)
+
+	// With TLS on, plain HTTP only answers ACME challenges and redirects
+	var httpSrv *http.Server
+	if httpHandler != nil && cfg.TLS.HTTPPort != "" {
+		httpSrv = &http.Server{
+			Addr:              ":" + cfg.TLS.HTTPPort,
+			Handler:           httpHandler,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			if err := httpSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("HTTP redirect listener failed", "addr", httpSrv.Addr, "err", err)
+			}
+		}()
+	}
+	if err := systemd.Notify(systemd.Ready); err != nil {
+		slog.Warn("Failed to notify systemd", "err", err)
+	}
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+		slog.Info("Shutting down")
+	}
+	stop()
+	_ = systemd.Notify(systemd.Stopping)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if httpSrv != nil {
+		_ = httpSrv.Shutdown(shutdownCtx)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Requests still running at shutdown", "err", err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Server failed", "err", err)
+	}
+	if err := services.WaitBackground(shutdownCtx); err != nil {
+		slog.Warn("Background jobs still running at shutdown", "err", err)
+	}
+	slog.Info("Server stopped")
+	return nil
+}
+
+// initializeTemplates loads all HTML templates
+func initializeTemplates() error {
+	return web.InitTemplates()
+}
+
+// handleForgotPassword handles password reset request (not implemented)
+func handleForgotPassword(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respond.Error(w, "Password reset not implemented yet. Please contact administrator.", http.StatusNotImplemented)
+	}
+}
+
+// handleResetPassword handles password reset with token (not implemented)
+func handleResetPassword(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respond.Error(w, "Password reset not implemented yet. Please contact administrator.", http.StatusNotImplemented)
+	}
+}
+
+// handleGetCSRFToken returns a new CSRF token
+func handleGetCSRFToken(csrf *middleware.CSRFProtection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := csrf.GenerateToken(r)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"csrf_token":"%s"}`, token)
+	}
+}
+
+// serveManifest serves the PWA manifest.json file
+func serveManifest(w http.ResponseWriter, r *http.Request) {
+	manifestPath := "./static/manifest.json"
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		middleware.Log(r.Context()).Error("Failed to read manifest", "err", err)
+		http.Error(w, "Manifest not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/manifest+json")
+	w.Header().Set("Cache-Control", "public, max-age=3600") // Cache for 1 hour
+	if _, err := w.Write(data); err != nil {
+		middleware.Log(r.Context()).Warn("Failed to write manifest data", "err", err)
+	}
+}
+
+// serveServiceWorker serves the service worker JavaScript file
+func serveServiceWorker(w http.ResponseWriter, r *http.Request) {
+	swPath := "./static/sw.js"
+	data, err := os.ReadFile(swPath)
+	if err != nil {
+		middleware.Log(r.Context()).Error("Failed to read service worker", "err", err)
+		http.Error(w, "Service worker not found", http.StatusNotFound)
+		return
+	}
+
+	// Service workers must be served with proper MIME type and no caching
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expires", "0")
+	w.Header().Set("Service-Worker-Allowed", "/") // Allow service worker to control entire origin
+	if _, err := w.Write(data); err != nil {
+		middleware.Log(r.Context()).Warn("Failed to write service worker data", "err", err)
+	}
+}
+
+// requireSetupComplete is middleware that redirects to setup if no users exist
+func requireSetupComplete(db *database.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			db := db.WithContext(r.Context())
+			var count int
+			err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+			if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+
+			if count == 0 {
+				// No users exist - redirect to setup
+				http.Redirect(w, r, "/setup", http.StatusSeeOther)
+				return
+			}
+
+			// Setup complete - continue to requested page
+			next.ServeHTTP(w, r)
+		})
+	}
+}
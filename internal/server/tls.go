@@ -0,0 +1,123 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"injection-tracker/internal/config"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// setupTLS returns the TLS config to serve HTTPS with and the handler for
+// the plain HTTP listener, which answers ACME challenges (if any) and
+// redirects everything else to HTTPS. Both are nil when TLS is off.
+func setupTLS(cfg config.TLSConfig, httpsPort string) (*tls.Config, http.Handler, error) {
+	if !cfg.Enabled() {
+		return nil, nil, nil
+	}
+	redirect := redirectToHTTPS(httpsPort)
+
+	if cfg.CertFile != "" {
+		kp, err := newKeypairReloader(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: kp.GetCertificate,
+		}, redirect, nil
+	}
+
+	if err := os.MkdirAll(cfg.ACMECacheDir, 0700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create ACME cache directory: %w", err)
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+		Cache:      autocert.DirCache(cfg.ACMECacheDir),
+		Email:      cfg.ACMEEmail,
+	}
+	if cfg.ACMEDirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.ACMEDirectoryURL}
+	}
+	tlsConfig := m.TLSConfig()
+	tlsConfig.MinVersion = tls.VersionTLS12
+	return tlsConfig, m.HTTPHandler(redirect), nil
+}
+
+// redirectToHTTPS sends requests to the same host and path over HTTPS
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// keypairReloader serves a certificate from files and picks up renewals
+// (from certbot, say) without a restart
+type keypairReloader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// keypairCheckInterval is how often the files are checked for a renewal
+const keypairCheckInterval = time.Minute
+
+func newKeypairReloader(certFile, keyFile string) (*keypairReloader, error) {
+	kp := &keypairReloader{certFile: certFile, keyFile: keyFile}
+	if err := kp.load(); err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+func (kp *keypairReloader) load() error {
+	info, err := os.Stat(kp.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(kp.certFile, kp.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	kp.cert = &cert
+	kp.modTime = info.ModTime()
+	return nil
+}
+
+// GetCertificate is tls.Config.GetCertificate. If a renewed certificate
+// fails to load, the previous one is kept.
+func (kp *keypairReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	if time.Since(kp.checkedAt) >= keypairCheckInterval {
+		kp.checkedAt = time.Now()
+		if info, err := os.Stat(kp.certFile); err == nil && !info.ModTime().Equal(kp.modTime) {
+			if err := kp.load(); err != nil {
+				slog.Error("Failed to reload TLS certificate", "err", err)
+			} else {
+				slog.Info("Reloaded TLS certificate", "file", kp.certFile)
+			}
+		}
+	}
+	return kp.cert, nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// NotifyAccountSuspension tells every member of an account that the admin
+// has suspended it, changed how, or reactivated it (suspension empty), in
+// the app and by email while SMTP is set up. Members of a locked account
+// can't sign in to read the notification, so the email is often the only
+// word they get.
+func NotifyAccountSuspension(db *database.DB, accountID int64, suspension, reason string) error {
+	rows, err := db.Query(`
+		SELECT u.id, COALESCE(u.email, ''), COALESCE(p.locale, '')
+		FROM account_members am
+		JOIN users u ON u.id = am.user_id
+		LEFT JOIN user_preferences p ON p.user_id = u.id
+		WHERE am.account_id = ?
+	`, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to get account members: %w", err)
+	}
+	type member struct {
+		id            int64
+		email, locale string
+	}
+	var members []member
+	for rows.Next() {
+		var m member
+		if err := rows.Scan(&m.id, &m.email, &m.locale); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan account member: %w", err)
+		}
+		members = append(members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get account members: %w", err)
+	}
+
+	smtpCfg := LoadSMTPConfig(db)
+	notifications := repository.NewNotificationRepository(db)
+	for _, m := range members {
+		title, message := suspensionMessage(i18n.For(m.locale), suspension, reason)
+		err := notifications.Create(&models.Notification{
+			UserID:  sql.NullInt64{Int64: m.id, Valid: true},
+			Type:    "system",
+			Title:   title,
+			Message: message,
+		})
+		if err != nil {
+			return err
+		}
+		if m.email == "" || !smtpCfg.IsConfigured() {
+			continue
+		}
+		// One member's bad address shouldn't keep the rest from hearing
+		if err := SendEmail(smtpCfg, m.email, title, message); err != nil {
+			slog.Error("Failed to email account suspension", "user_id", m.id, "err", err)
+		}
+	}
+	return nil
+}
+
+// suspensionMessage is the notification telling a member about suspension
+func suspensionMessage(p *i18n.Printer, suspension, reason string) (string, string) {
+	var title, message string
+	switch suspension {
+	case models.AccountReadOnly:
+		title = p.T("Account suspended")
+		message = p.T("The site administrator has made your account read-only. You can still sign in and look at your data, but nothing can be changed until it is reactivated.")
+	case models.AccountLocked:
+		title = p.T("Account suspended")
+		message = p.T("The site administrator has locked your account. No one can sign in to it until it is reactivated; your data is kept as it is.")
+	default:
+		return p.T("Account reactivated"), p.T("The site administrator has reactivated your account. You can use it as before.")
+	}
+	if reason != "" {
+		message += " " + p.T("Reason: %s", reason)
+	}
+	return title, message
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/models"
+)
+
+// ActivityTitle describes an activity feed entry in a few words, in p's
+// language
+func ActivityTitle(p *i18n.Printer, e *models.ActivityEntry) string {
+	switch e.Kind {
+	case models.ActivityInjection:
+		return p.T("Injection (%s)", p.T(capitalize(e.Action)))
+	case models.ActivitySymptom:
+		if e.Subject != "" {
+			return p.T("Symptom logged (%s)", strings.ReplaceAll(e.Subject, "_", " "))
+		}
+		return p.T("Symptom logged")
+	case models.ActivityMedication:
+		switch e.Action {
+		case "late":
+			return p.T("%s taken late", e.Subject)
+		case "missed":
+			return p.T("%s missed", e.Subject)
+		}
+		return p.T("%s taken", e.Subject)
+	case models.ActivityInventory:
+		change := fmt.Sprintf("%+g", e.Amount.Float64)
+		switch e.Action {
+		case "restock":
+			return p.T("Restocked %s (%s)", e.Subject, change)
+		case "manual_adjustment":
+			return p.T("Adjusted %s (%s)", e.Subject, change)
+		case "expired":
+			return p.T("Discarded expired %s (%s)", e.Subject, change)
+		case "stocktake":
+			return p.T("Stocktake of %s (%s)", e.Subject, change)
+		}
+		return p.T("%s changed (%s)", e.Subject, change)
+	case models.ActivityCourse:
+		switch e.Action {
+		case "create":
+			return p.T("Started course %s", e.Subject)
+		case "activate":
+			return p.T("Resumed course %s", e.Subject)
+		case "auto_close":
+			return p.T("Closed course %s automatically", e.Subject)
+		}
+		return p.T("Closed course %s", e.Subject)
+	}
+	return e.Subject
+}
+
+// ActivityLink is the API path of the record an activity feed entry is
+// about: the injection, symptom log or course itself, the medication logged
+// or the item's inventory history
+func ActivityLink(e *models.ActivityEntry) string {
+	switch e.Kind {
+	case models.ActivityInjection:
+		return "/api/injections/" + strconv.FormatInt(e.ID, 10)
+	case models.ActivitySymptom:
+		return "/api/symptoms/" + strconv.FormatInt(e.ID, 10)
+	case models.ActivityMedication:
+		return "/api/medications/" + strconv.FormatInt(e.MedicationID.Int64, 10) + "/logs"
+	case models.ActivityInventory:
+		return "/api/inventory/" + url.PathEscape(e.ItemType.String) + "/history"
+	case models.ActivityCourse:
+		return "/api/courses/" + strconv.FormatInt(e.CourseID.Int64, 10)
+	}
+	return ""
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/models"
+)
+
+func TestActivityTitleAndLink(t *testing.T) {
+	tests := []struct {
+		entry models.ActivityEntry
+		title string
+		link  string
+	}{
+		{
+			entry: models.ActivityEntry{Kind: models.ActivityInjection, ID: 7, Action: "left", Subject: "Cycle 2"},
+			title: "Injection (Left)",
+			link:  "/api/injections/7",
+		},
+		{
+			entry: models.ActivityEntry{Kind: models.ActivitySymptom, ID: 3, Action: "logged", Subject: "upper_left"},
+			title: "Symptom logged (upper left)",
+			link:  "/api/symptoms/3",
+		},
+		{
+			entry: models.ActivityEntry{Kind: models.ActivityMedication, ID: 9, Action: "late", Subject: "Estradiol", MedicationID: sql.NullInt64{Int64: 2, Valid: true}},
+			title: "Estradiol taken late",
+			link:  "/api/medications/2/logs",
+		},
+		{
+			entry: models.ActivityEntry{Kind: models.ActivityInventory, ID: 4, Action: "restock", Subject: "Alcohol Swabs",
+				Amount: sql.NullFloat64{Float64: 100, Valid: true}, ItemType: sql.NullString{String: "swab", Valid: true}},
+			title: "Restocked Alcohol Swabs (+100)",
+			link:  "/api/inventory/swab/history",
+		},
+		{
+			entry: models.ActivityEntry{Kind: models.ActivityInventory, ID: 5, Action: "manual_adjustment", Subject: "Gauze",
+				Amount: sql.NullFloat64{Float64: -2.5, Valid: true}, ItemType: sql.NullString{String: "gauze pad", Valid: true}},
+			title: "Adjusted Gauze (-2.5)",
+			link:  "/api/inventory/gauze%20pad/history",
+		},
+		{
+			entry: models.ActivityEntry{Kind: models.ActivityCourse, ID: 11, Action: "auto_close", Subject: "Cycle 1", CourseID: sql.NullInt64{Int64: 1, Valid: true}},
+			title: "Closed course Cycle 1 automatically",
+			link:  "/api/courses/1",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := ActivityTitle(i18n.Default(), &tt.entry); got != tt.title {
+			t.Errorf("ActivityTitle(%s %s) = %q, want %q", tt.entry.Kind, tt.entry.Action, got, tt.title)
+		}
+		if got := ActivityLink(&tt.entry); got != tt.link {
+			t.Errorf("ActivityLink(%s %d) = %q, want %q", tt.entry.Kind, tt.entry.ID, got, tt.link)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultUploadDir is where attachments are stored when no other store is configured
+const DefaultUploadDir = "data/uploads"
+
+// ErrInvalidStorageKey is returned for keys that could escape the store root
+var ErrInvalidStorageKey = errors.New("invalid storage key")
+
+// AttachmentStore persists attachment files under opaque, slash-separated keys.
+// Implementations must treat Put on an existing key as a no-op or overwrite;
+// keys are content-addressed so the bytes are identical.
+type AttachmentStore interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// LocalAttachmentStore keeps attachments on the local filesystem
+type LocalAttachmentStore struct {
+	root string
+}
+
+// NewLocalAttachmentStore returns a store rooted at dir
+func NewLocalAttachmentStore(dir string) *LocalAttachmentStore {
+	return &LocalAttachmentStore{root: dir}
+}
+
+func (s *LocalAttachmentStore) path(key string) (string, error) {
+	if key == "" || strings.Contains(key, "..") || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidStorageKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file atomically via a temp file in the same directory
+func (s *LocalAttachmentStore) Put(key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		// Same key means same content; nothing to do
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write attachment: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write attachment: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0640); err != nil {
+		return fmt.Errorf("failed to set attachment permissions: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store attachment: %w", err)
+	}
+	return nil
+}
+
+// Open opens a stored file for reading
+func (s *LocalAttachmentStore) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Delete removes a stored file; missing files are not an error
+func (s *LocalAttachmentStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return nil
+}
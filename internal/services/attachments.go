@@ -0,0 +1,248 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register GIF decoder
+	"image/jpeg"
+	_ "image/png" // register PNG decoder
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+const (
+	// MaxAttachmentSize is the largest accepted upload (10 MB)
+	MaxAttachmentSize = 10 << 20
+
+	// ThumbnailMaxDimension bounds the longest edge of generated thumbnails
+	ThumbnailMaxDimension = 320
+
+	// maxThumbnailSourcePixels guards against decompression bombs
+	maxThumbnailSourcePixels = 50_000_000
+)
+
+var (
+	ErrAttachmentTooLarge        = errors.New("attachment exceeds maximum size")
+	ErrAttachmentEmpty           = errors.New("attachment is empty")
+	ErrUnsupportedAttachmentType = errors.New("unsupported attachment type")
+)
+
+// attachmentTypes maps accepted (sniffed) content types to stored file extensions
+var attachmentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// DetectAttachmentType sniffs the content type from the file bytes.
+// The client-supplied type and filename extension are never trusted.
+func DetectAttachmentType(data []byte) (contentType, ext string, err error) {
+	contentType = http.DetectContentType(data)
+	ext, ok := attachmentTypes[contentType]
+	if !ok {
+		return "", "", ErrUnsupportedAttachmentType
+	}
+	return contentType, ext, nil
+}
+
+// attachmentKey builds a content-addressed key, sharded by hash prefix
+func attachmentKey(sum, suffix string) string {
+	return sum[:2] + "/" + sum + suffix
+}
+
+// GenerateThumbnail decodes an image and returns a JPEG no larger than maxDim
+// on either edge, along with the original dimensions. Formats without a
+// registered decoder (e.g. WebP) return an error and are stored without one.
+func GenerateThumbnail(data []byte, maxDim int) (thumb []byte, width, height int, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if cfg.Width*cfg.Height > maxThumbnailSourcePixels {
+		return nil, cfg.Width, cfg.Height, fmt.Errorf("image too large to thumbnail: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, cfg.Width, cfg.Height, err
+	}
+
+	dst := scaleImage(src, maxDim)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, cfg.Width, cfg.Height, err
+	}
+	return buf.Bytes(), cfg.Width, cfg.Height, nil
+}
+
+// scaleImage downsamples src so its longest edge is at most maxDim, averaging
+// each block of source pixels. Smaller images are copied unchanged.
+func scaleImage(src image.Image, maxDim int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dw, dh := sw, sh
+	if sw > maxDim || sh > maxDim {
+		if sw >= sh {
+			dw, dh = maxDim, max(1, sh*maxDim/sw)
+		} else {
+			dw, dh = max(1, sw*maxDim/sh), maxDim
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := b.Min.Y + y*sh/dh
+		y1 := max(y0+1, b.Min.Y+(y+1)*sh/dh)
+		for x := 0; x < dw; x++ {
+			x0 := b.Min.X + x*sw/dw
+			x1 := max(x0+1, b.Min.X+(x+1)*sw/dw)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
+
+// AttachmentService validates, stores and removes uploaded attachments
+type AttachmentService struct {
+	repo  *repository.AttachmentRepository
+	store AttachmentStore
+}
+
+// NewAttachmentService creates an attachment service backed by store
+func NewAttachmentService(db *database.DB, store AttachmentStore) *AttachmentService {
+	return &AttachmentService{
+		repo:  repository.NewAttachmentRepository(db),
+		store: store,
+	}
+}
+
+// Upload validates and stores a file, then records it for the account
+func (s *AttachmentService) Upload(accountID int64, uploadedBy sql.NullInt64, filename string, data []byte) (*models.Attachment, error) {
+	if len(data) == 0 {
+		return nil, ErrAttachmentEmpty
+	}
+	if len(data) > MaxAttachmentSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	contentType, ext, err := DetectAttachmentType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(data)
+	sum := hex.EncodeToString(hash[:])
+
+	attachment := &models.Attachment{
+		AccountID:        accountID,
+		UploadedBy:       uploadedBy,
+		OriginalFilename: sanitizeFilename(filename),
+		ContentType:      contentType,
+		SizeBytes:        int64(len(data)),
+		SHA256:           sum,
+		StorageKey:       attachmentKey(sum, ext),
+	}
+
+	if err := s.store.Put(attachment.StorageKey, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	thumb, width, height, err := GenerateThumbnail(data, ThumbnailMaxDimension)
+	if width > 0 && height > 0 {
+		attachment.Width = sql.NullInt64{Int64: int64(width), Valid: true}
+		attachment.Height = sql.NullInt64{Int64: int64(height), Valid: true}
+	}
+	if err != nil {
+		slog.Info("No thumbnail for attachment", "sha256", sum, "err", err)
+	} else {
+		thumbKey := attachmentKey(sum, "_thumb.jpg")
+		if err := s.store.Put(thumbKey, bytes.NewReader(thumb)); err != nil {
+			slog.Error("Failed to store thumbnail", "sha256", sum, "err", err)
+		} else {
+			attachment.ThumbnailKey = sql.NullString{String: thumbKey, Valid: true}
+		}
+	}
+
+	if err := s.repo.Create(attachment); err != nil {
+		s.removeUnreferenced(attachment)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// Delete removes an attachment record and, if no other record shares the
+// same content, its stored files
+func (s *AttachmentService) Delete(attachment *models.Attachment) error {
+	if err := s.repo.Delete(attachment.ID, attachment.AccountID); err != nil {
+		return err
+	}
+	s.removeUnreferenced(attachment)
+	return nil
+}
+
+// RemoveFiles deletes the stored files of attachments whose records are
+// already gone, such as after their account was deleted
+func (s *AttachmentService) RemoveFiles(attachments []*models.Attachment) {
+	for _, attachment := range attachments {
+		s.removeUnreferenced(attachment)
+	}
+}
+
+func (s *AttachmentService) removeUnreferenced(attachment *models.Attachment) {
+	count, err := s.repo.CountByStorageKey(attachment.StorageKey)
+	if err != nil || count > 0 {
+		return
+	}
+	if err := s.store.Delete(attachment.StorageKey); err != nil {
+		slog.Error("Failed to delete attachment file", "key", attachment.StorageKey, "err", err)
+	}
+	if attachment.ThumbnailKey.Valid {
+		if err := s.store.Delete(attachment.ThumbnailKey.String); err != nil {
+			slog.Error("Failed to delete thumbnail", "key", attachment.ThumbnailKey.String, "err", err)
+		}
+	}
+}
+
+// sanitizeFilename keeps only the base name of a client-supplied filename
+func sanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		return "upload"
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return name
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectAttachmentType(t *testing.T) {
+	contentType, ext, err := DetectAttachmentType(testPNG(t, 4, 4))
+	if err != nil {
+		t.Fatalf("Expected PNG to be accepted: %v", err)
+	}
+	if contentType != "image/png" || ext != ".png" {
+		t.Errorf("Expected image/png and .png, got %s and %s", contentType, ext)
+	}
+
+	if _, _, err := DetectAttachmentType([]byte("<html><script>alert(1)</script></html>")); err != ErrUnsupportedAttachmentType {
+		t.Errorf("Expected HTML to be rejected, got %v", err)
+	}
+}
+
+func TestGenerateThumbnail(t *testing.T) {
+	thumb, width, height, err := GenerateThumbnail(testPNG(t, 800, 400), ThumbnailMaxDimension)
+	if err != nil {
+		t.Fatalf("Failed to generate thumbnail: %v", err)
+	}
+	if width != 800 || height != 400 {
+		t.Errorf("Expected original dimensions 800x400, got %dx%d", width, height)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("Thumbnail is not a JPEG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != ThumbnailMaxDimension || b.Dy() != ThumbnailMaxDimension/2 {
+		t.Errorf("Expected %dx%d thumbnail, got %dx%d", ThumbnailMaxDimension, ThumbnailMaxDimension/2, b.Dx(), b.Dy())
+	}
+}
+
+func TestLocalAttachmentStore(t *testing.T) {
+	store := NewLocalAttachmentStore(t.TempDir())
+
+	if err := store.Put("ab/abcdef.png", bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatalf("Failed to put file: %v", err)
+	}
+
+	f, err := store.Open("ab/abcdef.png")
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "data" {
+		t.Errorf("Expected stored data, got %q", data)
+	}
+
+	if err := store.Delete("ab/abcdef.png"); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	if _, err := store.Open("ab/abcdef.png"); err == nil {
+		t.Error("Expected file to be gone after delete")
+	}
+
+	for _, key := range []string{"../escape", "/etc/passwd", "ab/../../x"} {
+		if err := store.Put(key, bytes.NewReader(nil)); err != ErrInvalidStorageKey {
+			t.Errorf("Expected %q to be rejected, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// AuditArchiveDir is where pruned audit logs are archived
+var AuditArchiveDir = filepath.Join("data", "audit-archive")
+
+// AuditRetention is how long audit logs are kept. Days of 0 keeps them
+// forever; with Archive set, entries are written to a gzipped CSV in
+// AuditArchiveDir before they are deleted.
+type AuditRetention struct {
+	Days    int    `json:"days" validate:"min=0"`
+	Archive bool   `json:"archive"`
+	LastRun string `json:"last_run,omitempty"`
+}
+
+// AuditCSVHeader is the header row of audit log CSV exports and archives
+var AuditCSVHeader = []string{"ID", "Timestamp", "User ID", "Action", "Entity Type", "Entity ID", "Details", "IP Address", "User Agent", "Impersonator ID"}
+
+// AuditCSVRow formats an audit log as a CSV row matching AuditCSVHeader
+func AuditCSVRow(l *models.AuditLog) []string {
+	row := []string{
+		strconv.FormatInt(l.ID, 10),
+		l.Timestamp.UTC().Format(time.RFC3339),
+		"",
+		l.Action,
+		l.EntityType,
+		"",
+		l.Details.String,
+		l.IPAddress.String,
+		l.UserAgent.String,
+		"",
+	}
+	if l.UserID.Valid {
+		row[2] = strconv.FormatInt(l.UserID.Int64, 10)
+	}
+	if l.EntityID.Valid {
+		row[5] = strconv.FormatInt(l.EntityID.Int64, 10)
+	}
+	if l.ImpersonatorID.Valid {
+		row[9] = strconv.FormatInt(l.ImpersonatorID.Int64, 10)
+	}
+	return row
+}
+
+// LoadAuditRetention reads the audit log retention policy from the site
+// settings
+func LoadAuditRetention(db *database.DB) AuditRetention {
+	policy := AuditRetention{}
+	settings := repository.NewSettingsRepository(db)
+
+	if value, err := settings.GetSite("audit_retention_days"); err == nil {
+		_, _ = fmt.Sscanf(value, "%d", &policy.Days)
+	}
+	if value, err := settings.GetSite("audit_retention_archive"); err == nil {
+		policy.Archive = value == "true"
+	}
+	if value, err := settings.GetSite("audit_retention_last_run"); err == nil {
+		policy.LastRun = value
+	}
+
+	return policy
+}
+
+// SaveAuditRetention stores the audit log retention policy. LastRun is left
+// alone.
+func SaveAuditRetention(db *database.DB, policy AuditRetention) error {
+	settings := repository.NewSettingsRepository(db)
+	for key, value := range map[string]string{
+		"audit_retention_days":    strconv.Itoa(policy.Days),
+		"audit_retention_archive": strconv.FormatBool(policy.Archive),
+	} {
+		if err := settings.SetSite(key, value, sql.NullInt64{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneAuditLogs applies the retention policy as of now. It returns how many
+// entries were removed and, if they were archived, the archive's path.
+func PruneAuditLogs(db *database.DB, now time.Time) (int64, string, error) {
+	policy := LoadAuditRetention(db)
+	if policy.Days <= 0 {
+		return 0, "", nil
+	}
+
+	cutoff := now.UTC().AddDate(0, 0, -policy.Days)
+	repo := repository.NewAuditRepository(db)
+
+	var archivePath string
+	if policy.Archive {
+		path, count, err := archiveAuditLogs(repo, cutoff, now)
+		if err != nil {
+			return 0, "", err
+		}
+		if count > 0 {
+			archivePath = path
+		}
+	}
+
+	deleted, err := repo.DeleteBefore(cutoff)
+	if err != nil {
+		return 0, "", err
+	}
+	_ = repository.NewSettingsRepository(db).SetSite("audit_retention_last_run", now.UTC().Format("2006-01-02 15:04:05"), sql.NullInt64{})
+	return deleted, archivePath, nil
+}
+
+// archiveAuditLogs writes the entries older than cutoff to a new archive
+// file. An empty archive is removed again.
+func archiveAuditLogs(repo *repository.AuditRepository, cutoff, now time.Time) (string, int, error) {
+	if err := os.MkdirAll(AuditArchiveDir, 0700); err != nil {
+		return "", 0, fmt.Errorf("failed to create audit archive directory: %w", err)
+	}
+	path := filepath.Join(AuditArchiveDir, fmt.Sprintf("audit-logs-before-%s-%s.csv.gz",
+		cutoff.Format("2006-01-02"), now.UTC().Format("20060102-150405")))
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create audit archive: %w", err)
+	}
+	count, err := writeAuditArchive(f, repo, cutoff)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || count == 0 {
+		os.Remove(path)
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to write audit archive: %w", err)
+	}
+	return path, count, nil
+}
+
+func writeAuditArchive(f *os.File, repo *repository.AuditRepository, cutoff time.Time) (int, error) {
+	gz := gzip.NewWriter(f)
+	w := csv.NewWriter(gz)
+	if err := w.Write(AuditCSVHeader); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := repo.Each(repository.AuditFilter{End: cutoff}, func(l *models.AuditLog) error {
+		count++
+		return w.Write(AuditCSVRow(l))
+	})
+	if err != nil {
+		return 0, err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return 0, err
+	}
+	return count, gz.Close()
+}
+
+// StartAuditRetentionScheduler prunes audit logs shortly after startup and
+// then once a day
+func StartAuditRetentionScheduler(db *database.DB) {
+	run := func() {
+		deleted, archive, err := PruneAuditLogs(db, time.Now())
+		RecordSchedulerRun("audit_retention", err)
+		if err != nil {
+			slog.Error("Audit log retention failed", "err", err)
+			return
+		}
+		if deleted > 0 {
+			if archive != "" {
+				slog.Info("Archived audit log entries", "count", deleted, "archive", archive)
+			} else {
+				slog.Info("Pruned audit log entries", "count", deleted)
+			}
+		}
+	}
+
+	RegisterScheduler("audit_retention", 24*time.Hour)
+	RunBackground(func() {
+		// Wait for server to fully start
+		if !SleepUnlessShutdown(time.Minute) {
+			return
+		}
+		run()
+
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-shutdownChan:
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Background work (the schedulers and anything a request leaves running
+// after its response) goes through RunBackground so that shutdown can stop
+// it and wait for it to finish
+var (
+	shutdownChan = make(chan struct{})
+	shutdownMu   sync.Mutex
+	shuttingDown bool
+	background   sync.WaitGroup
+)
+
+// ShutdownChan is closed once the server starts shutting down
+func ShutdownChan() <-chan struct{} {
+	return shutdownChan
+}
+
+// RunBackground runs fn in its own goroutine and has WaitBackground wait for
+// it. Once shutdown has started fn is dropped, so it must be something that
+// is safe to skip or that will be picked up again after a restart.
+func RunBackground(fn func()) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	if shuttingDown {
+		return
+	}
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		fn()
+	}()
+}
+
+// SleepUnlessShutdown waits for d and reports whether the server is still
+// running afterwards
+func SleepUnlessShutdown(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-shutdownChan:
+		return false
+	}
+}
+
+// Shutdown tells background workers and long-lived streams to stop. It is
+// safe to call more than once.
+func Shutdown() {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	if !shuttingDown {
+		shuttingDown = true
+		close(shutdownChan)
+	}
+}
+
+// WaitBackground waits for the work started with RunBackground to finish,
+// or for ctx to end
+func WaitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
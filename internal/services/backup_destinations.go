@@ -0,0 +1,813 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported backup destination types
+const (
+	BackupDestinationS3     = "s3"
+	BackupDestinationWebDAV = "webdav"
+	BackupDestinationRsync  = "rsync"
+)
+
+// backupDestinationSecretKeys lists config keys that must never be returned
+// to clients
+var backupDestinationSecretKeys = []string{"secret_access_key", "password"}
+
+// validBackupName matches the file names backups are created with. Remote
+// names are checked against it before they go into a URL or a shell command.
+var validBackupName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ErrNoBackupDestination is returned when no remote destination is configured
+var ErrNoBackupDestination = errors.New("no backup destination configured")
+
+// RemoteBackup is a backup file held by a destination
+type RemoteBackup struct {
+	Name    string    `json:"filename"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"created_at"`
+}
+
+// BackupDestination stores copies of backup files off the server
+type BackupDestination interface {
+	// Upload copies a local file to the destination as name
+	Upload(ctx context.Context, name, localPath string) error
+	// Download copies name from the destination to a local file
+	Download(ctx context.Context, name, localPath string) error
+	// List returns every file at the destination, newest first
+	List(ctx context.Context) ([]RemoteBackup, error)
+	// Delete removes name; a missing file is not an error
+	Delete(ctx context.Context, name string) error
+}
+
+// S3Config configures an S3-compatible bucket (AWS, MinIO, Backblaze B2,
+// Wasabi and so on)
+type S3Config struct {
+	Endpoint        string `json:"endpoint"`
+	Region          string `json:"region"`
+	Bucket          string `json:"bucket"`
+	Prefix          string `json:"prefix,omitempty"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	// PathStyle puts the bucket in the path rather than the host name,
+	// which most self-hosted servers need
+	PathStyle bool `json:"path_style,omitempty"`
+}
+
+// WebDAVConfig configures a WebDAV collection (Nextcloud, ownCloud, Apache)
+type WebDAVConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// RsyncConfig configures a directory reached by rsync over SSH. The SSH key
+// must not need a passphrase and the host must already be in known_hosts.
+type RsyncConfig struct {
+	// Target is user@host:/path/to/dir
+	Target       string `json:"target"`
+	Port         int    `json:"port,omitempty"`
+	IdentityFile string `json:"identity_file,omitempty"`
+}
+
+// IsValidBackupDestinationType checks if the destination type is supported
+func IsValidBackupDestinationType(destType string) bool {
+	switch destType {
+	case BackupDestinationS3, BackupDestinationWebDAV, BackupDestinationRsync:
+		return true
+	}
+	return false
+}
+
+// NewBackupDestination parses a destination's config and returns it. It
+// doubles as config validation for the API handlers.
+func NewBackupDestination(destType, config string, client *http.Client) (BackupDestination, error) {
+	switch destType {
+	case BackupDestinationS3:
+		var cfg S3Config
+		if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+			return nil, fmt.Errorf("invalid S3 config: %w", err)
+		}
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = "https://s3.amazonaws.com"
+		}
+		if cfg.Region == "" {
+			cfg.Region = "us-east-1"
+		}
+		if err := validateChannelURL(cfg.Endpoint); err != nil {
+			return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+		}
+		if cfg.Bucket == "" || strings.ContainsAny(cfg.Bucket, "/?# ") {
+			return nil, fmt.Errorf("S3 bucket is required and may not contain '/', '?', '#' or spaces")
+		}
+		if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+			return nil, fmt.Errorf("S3 access key ID and secret access key are required")
+		}
+		cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+		return &s3Destination{cfg: cfg, client: client}, nil
+
+	case BackupDestinationWebDAV:
+		var cfg WebDAVConfig
+		if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+			return nil, fmt.Errorf("invalid WebDAV config: %w", err)
+		}
+		if err := validateChannelURL(cfg.URL); err != nil {
+			return nil, fmt.Errorf("invalid WebDAV url: %w", err)
+		}
+		if !strings.HasSuffix(cfg.URL, "/") {
+			cfg.URL += "/"
+		}
+		return &webDAVDestination{cfg: cfg, client: client}, nil
+
+	case BackupDestinationRsync:
+		var cfg RsyncConfig
+		if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+			return nil, fmt.Errorf("invalid rsync config: %w", err)
+		}
+		host, dir, ok := strings.Cut(cfg.Target, ":")
+		if !ok || host == "" || dir == "" || strings.HasPrefix(host, "-") || strings.ContainsAny(cfg.Target, " '\"\\") {
+			return nil, fmt.Errorf("rsync target must look like user@host:/path/to/dir")
+		}
+		if cfg.Port < 0 || cfg.Port > 65535 {
+			return nil, fmt.Errorf("rsync port must be between 1 and 65535")
+		}
+		if strings.ContainsAny(cfg.IdentityFile, " '\"\\") {
+			return nil, fmt.Errorf("rsync identity_file may not contain spaces or quotes")
+		}
+		return &rsyncDestination{cfg: cfg, host: host, dir: strings.TrimRight(dir, "/")}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported backup destination type: %s", destType)
+}
+
+// RedactBackupDestinationConfig returns the config with secret values masked
+func RedactBackupDestinationConfig(config string) map[string]interface{} {
+	values := map[string]interface{}{}
+	_ = json.Unmarshal([]byte(config), &values)
+
+	for _, key := range backupDestinationSecretKeys {
+		if v, ok := values[key].(string); ok && v != "" {
+			values[key] = "********"
+		}
+	}
+	return values
+}
+
+// MergeBackupDestinationSecrets copies secrets from the stored config into an
+// updated one when the client left them blank or masked
+func MergeBackupDestinationSecrets(stored string, updated map[string]interface{}) {
+	previous := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(stored), &previous); err != nil {
+		return
+	}
+
+	for _, key := range backupDestinationSecretKeys {
+		v, _ := updated[key].(string)
+		if v == "" || v == "********" {
+			if old, ok := previous[key]; ok {
+				updated[key] = old
+			}
+		}
+	}
+}
+
+// UploadBackup copies a local backup to dest and checks that the copy there
+// is complete, by comparing its size with the local file's
+func UploadBackup(ctx context.Context, dest BackupDestination, localPath string) error {
+	name := path.Base(strings.ReplaceAll(localPath, "\\", "/"))
+	if !validBackupName.MatchString(name) {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	if err := dest.Upload(ctx, name, localPath); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+
+	remote, err := dest.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify upload of %s: %w", name, err)
+	}
+	for _, b := range remote {
+		if b.Name != name {
+			continue
+		}
+		if b.Size != info.Size() {
+			return fmt.Errorf("upload of %s is incomplete: %d of %d bytes arrived", name, b.Size, info.Size())
+		}
+		return nil
+	}
+	return fmt.Errorf("upload of %s is missing from the destination", name)
+}
+
+// PruneRemoteBackups deletes the oldest remote files whose names start with
+// prefix, keeping the newest keep of them. Other files are left alone.
+func PruneRemoteBackups(ctx context.Context, dest BackupDestination, prefix string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	remote, err := dest.List(ctx)
+	if err != nil {
+		return err
+	}
+	kept := 0
+	for _, b := range remote {
+		if !strings.HasPrefix(b.Name, prefix) {
+			continue
+		}
+		kept++
+		if kept <= keep {
+			continue
+		}
+		if err := dest.Delete(ctx, b.Name); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", b.Name, err)
+		}
+	}
+	return nil
+}
+
+// DownloadBackup fetches name from dest into localPath, via a temporary
+// file so a failed download doesn't leave a partial backup behind
+func DownloadBackup(ctx context.Context, dest BackupDestination, name, localPath string) error {
+	if !validBackupName.MatchString(name) {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	tmp := localPath + ".part"
+	if err := dest.Download(ctx, name, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	if err := os.Rename(tmp, localPath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func sortNewestFirst(backups []RemoteBackup) {
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].ModTime.Equal(backups[j].ModTime) {
+			return backups[i].ModTime.After(backups[j].ModTime)
+		}
+		return backups[i].Name > backups[j].Name
+	})
+}
+
+// writeResponseFile saves a successful response body to localPath
+func writeResponseFile(resp *http.Response, localPath string) error {
+	f, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// responseError turns a non-2xx response into an error
+func responseError(resp *http.Response) error {
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+}
+
+// ============================================
+// S3
+// ============================================
+
+type s3Destination struct {
+	cfg    S3Config
+	client *http.Client
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// objectURL returns the URL of key in the bucket, or of the bucket itself
+// when key is empty
+func (d *s3Destination) objectURL(key string) *url.URL {
+	u, _ := url.Parse(strings.TrimRight(d.cfg.Endpoint, "/"))
+	if d.cfg.PathStyle {
+		u.Path = "/" + d.cfg.Bucket + "/" + key
+	} else {
+		u.Host = d.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return u
+}
+
+func (d *s3Destination) key(name string) string {
+	if d.cfg.Prefix == "" {
+		return name
+	}
+	return d.cfg.Prefix + "/" + name
+}
+
+// do signs and sends a request. payloadHash is the hex SHA-256 of the body.
+func (d *s3Destination) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	now := time.Now
+	if d.now != nil {
+		now = d.now
+	}
+	signS3Request(req, d.cfg, payloadHash, now())
+	return d.client.Do(req)
+}
+
+func (d *s3Destination) Upload(ctx context.Context, name, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// S3 checks the body against the signed hash and rejects a mismatch,
+	// so a corrupted upload can't be stored
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, d.objectURL(d.key(name)).String(), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := d.do(req, hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (d *s3Destination) Download(ctx context.Context, name, localPath string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.objectURL(d.key(name)).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.do(req, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return writeResponseFile(resp, localPath)
+}
+
+func (d *s3Destination) Delete(ctx context.Context, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, d.objectURL(d.key(name)).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.do(req, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// s3ListResult is the part of a ListObjectsV2 response we use
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+func (d *s3Destination) List(ctx context.Context) ([]RemoteBackup, error) {
+	prefix := ""
+	if d.cfg.Prefix != "" {
+		prefix = d.cfg.Prefix + "/"
+	}
+
+	var backups []RemoteBackup
+	token := ""
+	for {
+		u := d.objectURL("")
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := d.do(req, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := responseError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid list response: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			name := strings.TrimPrefix(c.Key, prefix)
+			// Objects in "subdirectories" of the prefix aren't ours
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			backups = append(backups, RemoteBackup{Name: name, Size: c.Size, ModTime: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sortNewestFirst(backups)
+	return backups, nil
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signS3Request adds AWS Signature Version 4 headers to req. Every header
+// already on the request is signed.
+func signS3Request(req *http.Request, cfg S3Config, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3Escape(req.URL.Path, false),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretAccessKey), day)
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes everything but unreserved characters (and, in
+// paths, slashes), as SigV4 requires
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3CanonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// ============================================
+// WebDAV
+// ============================================
+
+type webDAVDestination struct {
+	cfg    WebDAVConfig
+	client *http.Client
+}
+
+func (d *webDAVDestination) request(ctx context.Context, method, name string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, d.cfg.URL+url.PathEscape(name), body)
+	if err != nil {
+		return nil, err
+	}
+	if d.cfg.Username != "" {
+		req.SetBasicAuth(d.cfg.Username, d.cfg.Password)
+	}
+	return req, nil
+}
+
+func (d *webDAVDestination) Upload(ctx context.Context, name, localPath string) error {
+	// Create the collection on first use; servers answer 405 if it exists
+	if req, err := d.request(ctx, "MKCOL", "", nil); err == nil {
+		if resp, err := d.client.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	req, err := d.request(ctx, http.MethodPut, name, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (d *webDAVDestination) Download(ctx context.Context, name, localPath string) error {
+	req, err := d.request(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return writeResponseFile(resp, localPath)
+}
+
+func (d *webDAVDestination) Delete(ctx context.Context, name string) error {
+	req, err := d.request(ctx, http.MethodDelete, name, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// webDAVMultistatus is the part of a PROPFIND response we use
+type webDAVMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ContentLength string    `xml:"getcontentlength"`
+				LastModified  string    `xml:"getlastmodified"`
+				ResourceType  *struct{} `xml:"resourcetype>collection"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const webDAVPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:getcontentlength/><d:getlastmodified/><d:resourcetype/></d:prop></d:propfind>`
+
+func (d *webDAVDestination) List(ctx context.Context) ([]RemoteBackup, error) {
+	req, err := d.request(ctx, "PROPFIND", "", strings.NewReader(webDAVPropfind))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		// Nothing has been uploaded yet
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, responseError(resp)
+	}
+
+	var ms webDAVMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("invalid PROPFIND response: %w", err)
+	}
+	var backups []RemoteBackup
+	for _, r := range ms.Responses {
+		href, err := url.PathUnescape(r.Href)
+		if err != nil {
+			continue
+		}
+		name := path.Base(strings.TrimRight(href, "/"))
+		for _, ps := range r.Propstat {
+			if ps.Prop.ResourceType != nil || ps.Prop.ContentLength == "" {
+				continue
+			}
+			size, err := strconv.ParseInt(ps.Prop.ContentLength, 10, 64)
+			if err != nil {
+				continue
+			}
+			modTime, _ := http.ParseTime(ps.Prop.LastModified)
+			backups = append(backups, RemoteBackup{Name: name, Size: size, ModTime: modTime})
+		}
+	}
+	sortNewestFirst(backups)
+	return backups, nil
+}
+
+// ============================================
+// rsync over SSH
+// ============================================
+
+type rsyncDestination struct {
+	cfg  RsyncConfig
+	host string
+	dir  string
+}
+
+// sshArgs are the options for ssh, shared by rsync -e and direct ssh calls.
+// BatchMode makes a missing key or unknown host fail instead of prompting.
+func (d *rsyncDestination) sshArgs() []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if d.cfg.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(d.cfg.Port))
+	}
+	if d.cfg.IdentityFile != "" {
+		args = append(args, "-i", d.cfg.IdentityFile)
+	}
+	return args
+}
+
+func (d *rsyncDestination) rsync(ctx context.Context, args ...string) (string, error) {
+	full := append([]string{"-e", "ssh " + strings.Join(d.sshArgs(), " ")}, args...)
+	cmd := exec.CommandContext(ctx, "rsync", full...)
+	var stdout, stderr strings.Builder
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("rsync: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("rsync: %w", err)
+	}
+	return stdout.String(), nil
+}
+
+func (d *rsyncDestination) remote(name string) string {
+	return d.host + ":" + d.dir + "/" + name
+}
+
+func (d *rsyncDestination) Upload(ctx context.Context, name, localPath string) error {
+	// rsync checks each file it writes against a whole-file checksum
+	_, err := d.rsync(ctx, "--mkpath", "--times", "--", localPath, d.remote(name))
+	return err
+}
+
+func (d *rsyncDestination) Download(ctx context.Context, name, localPath string) error {
+	_, err := d.rsync(ctx, "--", d.remote(name), localPath)
+	return err
+}
+
+func (d *rsyncDestination) Delete(ctx context.Context, name string) error {
+	if !validBackupName.MatchString(name) {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	args := append(d.sshArgs(), "--", d.host, "rm -f -- "+shellQuote(d.dir+"/"+name))
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ssh: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (d *rsyncDestination) List(ctx context.Context) ([]RemoteBackup, error) {
+	out, err := d.rsync(ctx, "--list-only", "--", d.host+":"+d.dir+"/")
+	if err != nil {
+		// A directory that hasn't been created yet holds no backups
+		if strings.Contains(err.Error(), "No such file or directory") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	backups := parseRsyncList(out)
+	sortNewestFirst(backups)
+	return backups, nil
+}
+
+// parseRsyncList reads the regular files from rsync --list-only output:
+//
+//	-rw-r--r--        557,056 2026/10/16 15:47:38 auto_2026-10-16_154738.db
+func parseRsyncList(out string) []RemoteBackup {
+	var backups []RemoteBackup
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "-") {
+			continue
+		}
+		size, err := strconv.ParseInt(strings.NewReplacer(",", "", ".", "").Replace(fields[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		modTime, _ := time.ParseInLocation("2006/01/02 15:04:05", fields[2]+" "+fields[3], time.Local)
+		name := strings.Join(fields[4:], " ")
+		backups = append(backups, RemoteBackup{Name: name, Size: size, ModTime: modTime})
+	}
+	return backups
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
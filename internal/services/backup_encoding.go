@@ -0,0 +1,272 @@
+package services
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// Suffixes added to a backup's file name by BackupCodec.Encode, in the
+// order they are applied: backup.db.gz.age is compressed, then encrypted
+const (
+	CompressedBackupSuffix = ".gz"
+	EncryptedBackupSuffix  = ".age"
+)
+
+// Backup encryption modes
+const (
+	BackupEncryptionPassphrase = "passphrase"
+	BackupEncryptionKeyFile    = "keyfile"
+)
+
+// ErrBackupKeyRequired is returned when decoding an encrypted backup with no
+// passphrase or key file to decrypt it with
+var ErrBackupKeyRequired = errors.New("backup is encrypted; a passphrase or key file is needed to decrypt it")
+
+// ErrBackupKeyMismatch is returned when the passphrase or key file given
+// doesn't decrypt the backup
+var ErrBackupKeyMismatch = errors.New("backup was encrypted with a different passphrase or key file")
+
+// IsBackupKeyError reports whether err means a backup couldn't be decrypted
+// with the keys given, which the caller can fix by supplying the right one
+func IsBackupKeyError(err error) bool {
+	return errors.Is(err, ErrBackupKeyRequired) || errors.Is(err, ErrBackupKeyMismatch)
+}
+
+// BackupCodec compresses and encrypts backup files. Encryption uses age
+// (https://age-encryption.org), so backups can also be decrypted by hand
+// with the age command line tool: a passphrase becomes an scrypt recipient,
+// and a key file holds X25519 identities as written by age-keygen.
+type BackupCodec struct {
+	Compress   bool
+	Passphrase string
+	KeyFile    string
+}
+
+// Encrypted reports whether Encode encrypts
+func (c BackupCodec) Encrypted() bool {
+	return c.Passphrase != "" || c.KeyFile != ""
+}
+
+// Suffix returns what Encode adds to a backup's file name
+func (c BackupCodec) Suffix() string {
+	suffix := ""
+	if c.Compress {
+		suffix += CompressedBackupSuffix
+	}
+	if c.Encrypted() {
+		suffix += EncryptedBackupSuffix
+	}
+	return suffix
+}
+
+// Validate checks the key file can be read and holds an identity
+func (c BackupCodec) Validate() error {
+	if c.KeyFile == "" {
+		return nil
+	}
+	_, err := c.keyFileIdentities()
+	return err
+}
+
+// keyFileIdentities reads the X25519 identities in the key file
+func (c BackupCodec) keyFileIdentities() ([]age.Identity, error) {
+	f, err := os.Open(c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup key file: %w", err)
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup key file %s: %w", c.KeyFile, err)
+	}
+	return identities, nil
+}
+
+// recipients returns who Encode encrypts to
+func (c BackupCodec) recipients() ([]age.Recipient, error) {
+	if c.Passphrase != "" {
+		// An scrypt recipient can't be mixed with others
+		r, err := age.NewScryptRecipient(c.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return []age.Recipient{r}, nil
+	}
+
+	identities, err := c.keyFileIdentities()
+	if err != nil {
+		return nil, err
+	}
+	var recipients []age.Recipient
+	for _, id := range identities {
+		if x, ok := id.(*age.X25519Identity); ok {
+			recipients = append(recipients, x.Recipient())
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("backup key file %s has no X25519 identity", c.KeyFile)
+	}
+	return recipients, nil
+}
+
+// identities returns what Decode tries, which is both the passphrase and
+// the key file when both are set
+func (c BackupCodec) identities() ([]age.Identity, error) {
+	var identities []age.Identity
+	if c.KeyFile != "" {
+		ids, err := c.keyFileIdentities()
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, ids...)
+	}
+	if c.Passphrase != "" {
+		id, err := age.NewScryptIdentity(c.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, id)
+	}
+	if len(identities) == 0 {
+		return nil, ErrBackupKeyRequired
+	}
+	return identities, nil
+}
+
+// Encode writes src to dst compressed and encrypted as configured. dst
+// should end in Suffix.
+func (c BackupCodec) Encode(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = c.encode(in, out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
+
+func (c BackupCodec) encode(in io.Reader, out io.Writer) error {
+	// Writers are closed innermost first so gzip flushes into age
+	var closers []io.Closer
+	w := out
+	if c.Encrypted() {
+		recipients, err := c.recipients()
+		if err != nil {
+			return err
+		}
+		enc, err := age.Encrypt(w, recipients...)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt backup: %w", err)
+		}
+		closers = append(closers, enc)
+		w = enc
+	}
+	if c.Compress {
+		gz := gzip.NewWriter(w)
+		closers = append(closers, gz)
+		w = gz
+	}
+
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode writes the plain backup in src to dst, undoing whatever its file
+// name's suffixes say was applied. The codec's settings only supply the
+// keys, so a backup made before they changed still decodes as long as the
+// key it was encrypted with is given.
+func (c BackupCodec) Decode(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, compressed, encrypted := SplitBackupName(src)
+	err = c.decode(in, out, compressed, encrypted)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
+
+func (c BackupCodec) decode(in io.Reader, out io.Writer, compressed, encrypted bool) error {
+	r := in
+	if encrypted {
+		identities, err := c.identities()
+		if err != nil {
+			return err
+		}
+		dec, err := age.Decrypt(r, identities...)
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return ErrBackupKeyMismatch
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decrypt backup: %w", err)
+		}
+		r = dec
+	}
+	if compressed {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to decompress backup: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	return nil
+}
+
+// SplitBackupName strips the codec suffixes from a backup's file name and
+// reports which were there
+func SplitBackupName(name string) (plain string, compressed, encrypted bool) {
+	if strings.HasSuffix(name, EncryptedBackupSuffix) {
+		name, encrypted = strings.TrimSuffix(name, EncryptedBackupSuffix), true
+	}
+	if strings.HasSuffix(name, CompressedBackupSuffix) {
+		name, compressed = strings.TrimSuffix(name, CompressedBackupSuffix), true
+	}
+	return name, compressed, encrypted
+}
+
+// IsBackupName reports whether name is a backup with extension ext, plain
+// or encoded
+func IsBackupName(name, ext string) bool {
+	plain, _, _ := SplitBackupName(name)
+	return strings.HasSuffix(plain, ext)
+}
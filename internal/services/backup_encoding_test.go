@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestBackupCodecRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	plain := bytes.Repeat([]byte("SQLite format 3\x00 injection site rotation "), 1000)
+	src := filepath.Join(dir, "manual_2026-01-01_000000.db")
+	if err := os.WriteFile(src, plain, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "backup.key")
+	if err := os.WriteFile(keyFile, []byte("# created by age-keygen\n"+identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		codec  BackupCodec
+		suffix string
+	}{
+		{"compressed", BackupCodec{Compress: true}, ".gz"},
+		{"passphrase", BackupCodec{Passphrase: "correct horse battery"}, ".age"},
+		{"keyfile", BackupCodec{KeyFile: keyFile}, ".age"},
+		{"both", BackupCodec{Compress: true, Passphrase: "correct horse battery"}, ".gz.age"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.codec.Suffix(); got != tt.suffix {
+				t.Fatalf("Suffix() = %q, want %q", got, tt.suffix)
+			}
+			encoded := src + tt.codec.Suffix()
+			if err := tt.codec.Encode(src, encoded); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			data, _ := os.ReadFile(encoded)
+			if bytes.Contains(data, []byte("injection site")) {
+				t.Error("Encoded backup still contains the plain text")
+			}
+
+			decoded := filepath.Join(dir, "decoded.db")
+			if err := tt.codec.Decode(encoded, decoded); err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if data, _ := os.ReadFile(decoded); !bytes.Equal(data, plain) {
+				t.Error("Decoded backup differs from the original")
+			}
+		})
+	}
+}
+
+func TestBackupCodecKeyErrors(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "auto_2026-01-01_000000.db")
+	if err := os.WriteFile(src, []byte("SQLite format 3\x00"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	encoded := src + EncryptedBackupSuffix
+	if err := (BackupCodec{Passphrase: "the first passphrase"}).Encode(src, encoded); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded := filepath.Join(dir, "decoded.db")
+
+	err := BackupCodec{Compress: true}.Decode(encoded, decoded)
+	if !errors.Is(err, ErrBackupKeyRequired) {
+		t.Errorf("Decode with no key = %v, want ErrBackupKeyRequired", err)
+	}
+	err = BackupCodec{Passphrase: "the wrong passphrase"}.Decode(encoded, decoded)
+	if !errors.Is(err, ErrBackupKeyMismatch) {
+		t.Errorf("Decode with the wrong passphrase = %v, want ErrBackupKeyMismatch", err)
+	}
+	if _, err := os.Stat(decoded); !os.IsNotExist(err) {
+		t.Error("A failed decode left its output behind")
+	}
+
+	missing := BackupCodec{KeyFile: filepath.Join(dir, "missing.key")}
+	if err := missing.Validate(); err == nil || !strings.Contains(err.Error(), "key file") {
+		t.Errorf("Validate with a missing key file = %v", err)
+	}
+}
+
+func TestSplitBackupName(t *testing.T) {
+	tests := []struct {
+		name                  string
+		plain                 string
+		compressed, encrypted bool
+	}{
+		{"auto_2026-01-01_000000.db", "auto_2026-01-01_000000.db", false, false},
+		{"auto_2026-01-01_000000.db.gz", "auto_2026-01-01_000000.db", true, false},
+		{"auto_2026-01-01_000000.dump.age", "auto_2026-01-01_000000.dump", false, true},
+		{"auto_2026-01-01_000000.db.gz.age", "auto_2026-01-01_000000.db", true, true},
+	}
+	for _, tt := range tests {
+		plain, compressed, encrypted := SplitBackupName(tt.name)
+		if plain != tt.plain || compressed != tt.compressed || encrypted != tt.encrypted {
+			t.Errorf("SplitBackupName(%q) = %q, %v, %v", tt.name, plain, compressed, encrypted)
+		}
+	}
+
+	if !IsBackupName("manual_2026-01-01_000000.db.gz.age", ".db") {
+		t.Error("Encoded SQLite backup not recognised")
+	}
+	if IsBackupName("notes.txt.age", ".db") || IsBackupName("manual.dump.gz", ".db") {
+		t.Error("Non-SQLite file taken for a SQLite backup")
+	}
+}
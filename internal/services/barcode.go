@@ -0,0 +1,285 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Scanned code formats
+const (
+	BarcodeGS1   = "gs1"   // GS1 element string, as on a DataMatrix
+	BarcodeGTIN  = "gtin"  // A bare GTIN-13 or GTIN-14
+	BarcodeUPC   = "upc"   // UPC-A carrying an NDC
+	BarcodeNDC   = "ndc"   // A National Drug Code, typed or printed
+	BarcodeOther = "other" // Anything else, only usable through a mapping
+)
+
+// ErrEmptyBarcode is returned for a payload with nothing in it
+var ErrEmptyBarcode = errors.New("barcode is empty")
+
+// ScannedCode is what a barcode payload says about a package
+type ScannedCode struct {
+	Format         string
+	Raw            string
+	GTIN           string    // 14 digits
+	NDC            string    // 10 digits, no dashes
+	LotNumber      string    // GS1 AI 10
+	ExpirationDate time.Time // GS1 AI 17; zero if absent
+	SerialNumber   string    // GS1 AI 21
+}
+
+// Key is the code a package is looked up by: its NDC when it has one, else
+// its GTIN, else the payload itself. Lot, expiry and serial don't take part,
+// so every package of a product shares a key.
+func (c ScannedCode) Key() string {
+	switch {
+	case c.NDC != "":
+		return c.NDC
+	case c.GTIN != "":
+		return c.GTIN
+	}
+	return c.Raw
+}
+
+// gs1Separator is the FNC1 character that ends a variable length field
+const gs1Separator = "\x1d"
+
+// gs1FixedLengths are the fixed length application identifiers and the
+// length of their data
+var gs1FixedLengths = map[string]int{
+	"00": 18, // SSCC
+	"01": 14, // GTIN
+	"02": 14, // GTIN of contained items
+	"11": 6,  // Production date
+	"12": 6,  // Due date
+	"13": 6,  // Packaging date
+	"15": 6,  // Best before
+	"16": 6,  // Sell by
+	"17": 6,  // Expiration date
+	"20": 2,  // Variant
+}
+
+// gs1VariableAIs are the variable length identifiers understood, which run
+// to the next separator
+var gs1VariableAIs = []string{
+	"10",         // Lot
+	"21",         // Serial
+	"22",         // Consumer product variant
+	"30",         // Count
+	"37",         // Count of trade items
+	"240", "241", // Additional product identification
+	"710", "711", "712", "713", "714", // National healthcare reimbursement numbers
+}
+
+// gs1Bracketed matches the human readable form, (01)00312345678906(17)...
+var gs1Bracketed = regexp.MustCompile(`\((\d{2,4})\)([^(]*)`)
+
+// symbologyIdentifiers prefix the payload from scanners that report which
+// symbology was read: ]d2 DataMatrix, ]C1 GS1-128, ]Q3 QR, ]e0 DataBar
+var symbologyIdentifiers = []string{"]d2", "]C1", "]Q3", "]e0"}
+
+// ParseBarcode reads a scanned or typed payload: a GS1 element string (with
+// FNC1 separators or in bracketed form), a bare GTIN, a UPC-A or an NDC with
+// or without dashes. US drug GTINs and UPCs carry the 10-digit NDC, which is
+// extracted. Anything else is returned as BarcodeOther.
+func ParseBarcode(payload string) (ScannedCode, error) {
+	raw := strings.TrimSpace(payload)
+	for _, prefix := range symbologyIdentifiers {
+		raw = strings.TrimPrefix(raw, prefix)
+	}
+	raw = strings.TrimPrefix(raw, gs1Separator)
+	if raw == "" {
+		return ScannedCode{}, ErrEmptyBarcode
+	}
+
+	code := ScannedCode{Format: BarcodeOther, Raw: raw}
+	if fields, ok := parseGS1(raw); ok {
+		code.Format = BarcodeGS1
+		code.GTIN = fields["01"]
+		code.LotNumber = fields["10"]
+		code.SerialNumber = fields["21"]
+		if expires, ok := parseGS1Date(fields["17"]); ok {
+			code.ExpirationDate = expires
+		}
+		code.NDC = ndcFromGTIN(code.GTIN)
+		return code, nil
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, raw)
+	if !isDigits(digits) {
+		return code, nil
+	}
+	switch len(digits) {
+	case 10:
+		code.Format = BarcodeNDC
+		code.NDC = digits
+	case 11:
+		if ndc := ndcFromNDC11(raw, digits); ndc != "" {
+			code.Format = BarcodeNDC
+			code.NDC = ndc
+		}
+	case 12:
+		if validCheckDigit(digits) {
+			code.Format = BarcodeUPC
+			code.GTIN = "00" + digits
+			code.NDC = ndcFromGTIN(code.GTIN)
+		}
+	case 13, 14:
+		if validCheckDigit(digits) {
+			code.Format = BarcodeGTIN
+			code.GTIN = strings.Repeat("0", 14-len(digits)) + digits
+			code.NDC = ndcFromGTIN(code.GTIN)
+		}
+	}
+	return code, nil
+}
+
+// parseGS1 splits a GS1 element string into its fields. It reports false if
+// the payload isn't one.
+func parseGS1(raw string) (map[string]string, bool) {
+	fields := make(map[string]string)
+	if strings.HasPrefix(raw, "(") {
+		matches := gs1Bracketed.FindAllStringSubmatch(raw, -1)
+		if len(matches) == 0 {
+			return nil, false
+		}
+		for _, m := range matches {
+			fields[m[1]] = strings.TrimSpace(m[2])
+		}
+		return fields, fields["01"] != "" || fields["00"] != ""
+	}
+
+	// Without brackets, the string has to start with an identifier and a
+	// GTIN to be told apart from an ordinary number
+	if !strings.HasPrefix(raw, "01") || len(raw) < 16 {
+		return nil, false
+	}
+	rest := raw
+	for rest != "" {
+		rest = strings.TrimPrefix(rest, gs1Separator)
+		if rest == "" {
+			break
+		}
+		ai, length, ok := gs1Identifier(rest)
+		if !ok {
+			return nil, false
+		}
+		rest = rest[len(ai):]
+		if length > 0 {
+			if len(rest) < length {
+				return nil, false
+			}
+			fields[ai], rest = rest[:length], rest[length:]
+			continue
+		}
+		end := strings.Index(rest, gs1Separator)
+		if end < 0 {
+			end = len(rest)
+		}
+		fields[ai], rest = rest[:end], rest[end:]
+	}
+	return fields, isDigits(fields["01"])
+}
+
+// gs1Identifier reads the application identifier at the start of s,
+// returning its data length, or 0 for variable length
+func gs1Identifier(s string) (string, int, bool) {
+	if len(s) >= 2 {
+		if n, ok := gs1FixedLengths[s[:2]]; ok {
+			return s[:2], n, true
+		}
+	}
+	for _, ai := range gs1VariableAIs {
+		if strings.HasPrefix(s, ai) {
+			return ai, 0, true
+		}
+	}
+	return "", 0, false
+}
+
+// parseGS1Date reads a YYMMDD date. A day of 00 means the end of the month.
+func parseGS1Date(s string) (time.Time, bool) {
+	if len(s) != 6 || !isDigits(s) {
+		return time.Time{}, false
+	}
+	year := 2000 + int(s[0]-'0')*10 + int(s[1]-'0')
+	month := time.Month(int(s[2]-'0')*10 + int(s[3]-'0'))
+	day := int(s[4]-'0')*10 + int(s[5]-'0')
+	if month < 1 || month > 12 || day > 31 {
+		return time.Time{}, false
+	}
+	if day == 0 {
+		return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC), true
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), true
+}
+
+// ndcFromGTIN extracts the NDC from a US drug GTIN-14: an indicator digit,
+// the 03 prefix, the 10-digit NDC and a check digit
+func ndcFromGTIN(gtin string) string {
+	if len(gtin) != 14 || gtin[1:3] != "03" {
+		return ""
+	}
+	return gtin[3:13]
+}
+
+// ndcFromNDC11 turns an 11-digit billing NDC (5-4-2) back into the 10 digits
+// printed on the package by dropping the zero that padded one segment. With
+// dashes the padded segment is the first one starting with zero; without,
+// the labeler is assumed padded. It returns "" if no segment was padded.
+func ndcFromNDC11(raw, digits string) string {
+	segments := strings.Split(raw, "-")
+	if len(segments) == 3 && len(segments[0]) == 5 && len(segments[1]) == 4 && len(segments[2]) == 2 {
+		for i, seg := range segments {
+			if strings.HasPrefix(seg, "0") {
+				segments[i] = seg[1:]
+				return strings.Join(segments, "")
+			}
+		}
+	}
+	if digits[0] == '0' {
+		return digits[1:]
+	}
+	return ""
+}
+
+// validCheckDigit checks the GS1 mod 10 check digit that ends a GTIN or UPC
+func validCheckDigit(digits string) bool {
+	sum := 0
+	for i := len(digits) - 2; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10-sum%10)%10 == int(digits[len(digits)-1]-'0')
+}
+
+// FormatNDC renders a 10-digit NDC with dashes. The split isn't recorded in
+// the digits, so this is the common 5-3-2 labeler-product-package layout.
+func FormatNDC(ndc string) string {
+	if len(ndc) != 10 {
+		return ndc
+	}
+	return ndc[:5] + "-" + ndc[5:8] + "-" + ndc[8:]
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseBarcode(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		format  string
+		ndc     string
+		gtin    string
+		lot     string
+		expires string
+		serial  string
+	}{
+		{"DataMatrix with separators", "]d201003123456789061726053110ABC123\x1d21SN0001", BarcodeGS1, "1234567890", "00312345678906", "ABC123", "2026-05-31", "SN0001"},
+		{"Lot before expiry", "010031234567890610LOT7\x1d17270100", BarcodeGS1, "1234567890", "00312345678906", "LOT7", "2027-01-31", ""},
+		{"Bracketed", "(01)00312345678906(17)261115(10)A1B2", BarcodeGS1, "1234567890", "00312345678906", "A1B2", "2026-11-15", ""},
+		{"UPC-A", "312345678906", BarcodeUPC, "1234567890", "00312345678906", "", "", ""},
+		{"GTIN-14", "00312345678906", BarcodeGTIN, "1234567890", "00312345678906", "", "", ""},
+		{"NDC 10", "12345-678-90", BarcodeNDC, "1234567890", "", "", "", ""},
+		{"NDC 11 padded product", "12345-0678-90", BarcodeNDC, "1234567890", "", "", "", ""},
+		{"NDC 11 padded labeler", "01234-5678-90", BarcodeNDC, "1234567890", "", "", "", ""},
+		{"NDC 11 unpadded", "12345678901", BarcodeOther, "", "", "", "", ""},
+		{"Bad check digit", "312345678907", BarcodeOther, "", "", "", "", ""},
+		{"Free text", "PROG-OIL", BarcodeOther, "", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := ParseBarcode(tt.payload)
+			if err != nil {
+				t.Fatalf("ParseBarcode failed: %v", err)
+			}
+			if code.Format != tt.format || code.NDC != tt.ndc || code.GTIN != tt.gtin {
+				t.Errorf("Expected %s ndc %q gtin %q, got %s ndc %q gtin %q", tt.format, tt.ndc, tt.gtin, code.Format, code.NDC, code.GTIN)
+			}
+			if code.LotNumber != tt.lot || code.SerialNumber != tt.serial {
+				t.Errorf("Expected lot %q serial %q, got %q %q", tt.lot, tt.serial, code.LotNumber, code.SerialNumber)
+			}
+			expires := ""
+			if !code.ExpirationDate.IsZero() {
+				expires = code.ExpirationDate.Format("2006-01-02")
+			}
+			if expires != tt.expires {
+				t.Errorf("Expected expiry %q, got %q", tt.expires, expires)
+			}
+		})
+	}
+
+	if _, err := ParseBarcode("  ]d2 "); !errors.Is(err, ErrEmptyBarcode) {
+		t.Errorf("Expected ErrEmptyBarcode, got %v", err)
+	}
+}
+
+func TestScannedCodeKey(t *testing.T) {
+	a, _ := ParseBarcode("(01)00312345678906(17)261115(10)A1B2")
+	b, _ := ParseBarcode("54321-876-09")
+	c, _ := ParseBarcode("12345-678-90")
+	if a.Key() != c.Key() || a.Key() != "1234567890" {
+		t.Errorf("Expected packages of one product to share a key, got %q and %q", a.Key(), c.Key())
+	}
+	if b.Key() == a.Key() {
+		t.Errorf("Expected different products to have different keys")
+	}
+	other, _ := ParseBarcode(" PROG-OIL ")
+	if other.Key() != "PROG-OIL" {
+		t.Errorf("Expected the trimmed payload as key, got %q", other.Key())
+	}
+	if got := FormatNDC("1234567890"); got != "12345-678-90" {
+		t.Errorf("Expected 12345-678-90, got %s", got)
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// iCalendar (RFC 5545) feeds of upcoming injections, medication times and
+// appointments, for subscribing from calendar apps.
+
+const icalTimeUTC = "20060102T150405Z"
+
+// CalendarEvent is one VEVENT in a feed
+type CalendarEvent struct {
+	UID         string // Stable across fetches so clients update rather than duplicate
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	// RRule repeats the event, e.g. "FREQ=DAILY;INTERVAL=2". Repeating
+	// events are written in TZ so they keep their wall-clock time across
+	// daylight saving changes.
+	RRule string
+	TZ    *time.Location
+}
+
+// WriteICalendar writes events as an iCalendar feed named name
+func WriteICalendar(w io.Writer, name string, events []CalendarEvent, stamp time.Time) error {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICalLine(s))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//P-TRACK//Calendar Feed//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeICalText(name))
+	// Ask clients to refresh hourly; most poll less often regardless
+	line("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	line("X-PUBLISHED-TTL:PT1H")
+
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + stamp.UTC().Format(icalTimeUTC))
+		if e.RRule != "" && e.TZ != nil && e.TZ != time.UTC && e.TZ.String() != "Local" {
+			line("DTSTART;TZID=" + e.TZ.String() + ":" + e.Start.In(e.TZ).Format("20060102T150405"))
+			line("DTEND;TZID=" + e.TZ.String() + ":" + e.End.In(e.TZ).Format("20060102T150405"))
+		} else {
+			line("DTSTART:" + e.Start.UTC().Format(icalTimeUTC))
+			line("DTEND:" + e.End.UTC().Format(icalTimeUTC))
+		}
+		if e.RRule != "" {
+			line("RRULE:" + e.RRule)
+		}
+		line("SUMMARY:" + escapeICalText(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + escapeICalText(e.Description))
+		}
+		if e.Location != "" {
+			line("LOCATION:" + escapeICalText(e.Location))
+		}
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeICalText escapes a TEXT value
+func escapeICalText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// foldICalLine splits a content line into 75-octet pieces, each continuation
+// starting with a space, without breaking a UTF-8 sequence
+func foldICalLine(s string) string {
+	const limit = 75
+	if len(s) <= limit {
+		return s
+	}
+	var b strings.Builder
+	for width := limit; len(s) > width; width = limit - 1 {
+		cut := width
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+var (
+	everyNPattern = regexp.MustCompile(`^every\s+(\d+)\s+(hour|day|week)s?$`)
+	dailyPhrases  = map[string]bool{"": true, "daily": true, "every day": true, "once daily": true, "once a day": true}
+	weeklyPhrases = map[string]bool{"weekly": true, "every week": true, "once weekly": true, "once a week": true}
+)
+
+// MedicationRRule turns a medication's frequency ("Every day", "Every 2
+// days", "Every 8 hours", "Weekly") into an RRULE. Frequencies it can't read
+// are treated as daily, as the daily schedule does.
+func MedicationRRule(frequency string) string {
+	f := strings.ToLower(strings.Join(strings.Fields(frequency), " "))
+	switch {
+	case dailyPhrases[f]:
+		return "FREQ=DAILY"
+	case weeklyPhrases[f]:
+		return "FREQ=WEEKLY"
+	case f == "every other day":
+		return "FREQ=DAILY;INTERVAL=2"
+	}
+	if m := everyNPattern.FindStringSubmatch(f); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err == nil && n > 0 {
+			rule := map[string]string{"hour": "FREQ=HOURLY", "day": "FREQ=DAILY", "week": "FREQ=WEEKLY"}[m[2]]
+			if n > 1 {
+				rule += fmt.Sprintf(";INTERVAL=%d", n)
+			}
+			return rule
+		}
+	}
+	return "FREQ=DAILY"
+}
+
+// AtClock returns the given day in loc at an HH:MM time of day. An invalid
+// time of day gives midnight.
+func AtClock(day time.Time, clock string, loc *time.Location) time.Time {
+	day = day.In(loc)
+	hm, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hm.Hour(), hm.Minute(), 0, 0, loc)
+}
+
+// InjectionSchedule describes when injections fall due
+type InjectionSchedule struct {
+	CourseStart    time.Time // First injection is due on this day when none is logged
+	LastInjection  time.Time // Zero if none logged
+	FrequencyHours int
+	Weekdays       []time.Weekday // Due on these days instead of every FrequencyHours
+	ReminderTime   string         // HH:MM; whole-day frequencies and weekdays are due at this time
+	Location       *time.Location
+}
+
+// DueTimes lists the times injections fall due in [from, until), at most max
+// of them. They follow on from the last injection; when the frequency is a
+// whole number of days they land on the reminder time in Location.
+func (s InjectionSchedule) DueTimes(from, until time.Time, max int) []time.Time {
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	if len(s.Weekdays) > 0 && max > 0 {
+		return s.weekdayDueTimes(from, until, max, loc)
+	}
+	if s.FrequencyHours <= 0 || max <= 0 {
+		return nil
+	}
+	days := 0
+	if s.FrequencyHours%24 == 0 {
+		days = s.FrequencyHours / 24
+	}
+
+	var next time.Time
+	switch {
+	case s.LastInjection.IsZero():
+		next = AtClock(s.CourseStart, s.ReminderTime, loc)
+	case days > 0:
+		next = AtClock(s.LastInjection.In(loc).AddDate(0, 0, days), s.ReminderTime, loc)
+	default:
+		next = s.LastInjection.Add(time.Duration(s.FrequencyHours) * time.Hour)
+	}
+
+	// Skip ahead to from without walking through a long gap a step at a time
+	if gap := from.Sub(next); gap > 0 {
+		steps := int(gap / (time.Duration(s.FrequencyHours) * time.Hour))
+		if days > 0 {
+			next = AtClock(next.AddDate(0, 0, steps*days), s.ReminderTime, loc)
+		} else {
+			next = next.Add(time.Duration(steps*s.FrequencyHours) * time.Hour)
+		}
+	}
+
+	var times []time.Time
+	for next.Before(until) && len(times) < max {
+		if !next.Before(from) {
+			times = append(times, next)
+		}
+		if days > 0 {
+			next = AtClock(next.AddDate(0, 0, days), s.ReminderTime, loc)
+		} else {
+			next = next.Add(time.Duration(s.FrequencyHours) * time.Hour)
+		}
+	}
+	return times
+}
+
+// weekdayDueTimes is DueTimes for a schedule on set weekdays: the first is
+// due on the first of them after the day of the last injection, or from the
+// course's start day when none is logged
+func (s InjectionSchedule) weekdayDueTimes(from, until time.Time, max int, loc *time.Location) []time.Time {
+	on := map[time.Weekday]bool{}
+	for _, d := range s.Weekdays {
+		on[d] = true
+	}
+
+	// The course's start is a calendar date, not a moment in loc
+	day := time.Date(s.CourseStart.Year(), s.CourseStart.Month(), s.CourseStart.Day(), 0, 0, 0, 0, loc)
+	if !s.LastInjection.IsZero() {
+		last := s.LastInjection.In(loc)
+		day = time.Date(last.Year(), last.Month(), last.Day()+1, 0, 0, 0, 0, loc)
+	}
+	if start := from.In(loc); day.Before(start) {
+		day = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	}
+
+	var times []time.Time
+	for ; len(times) < max; day = day.AddDate(0, 0, 1) {
+		due := AtClock(day, s.ReminderTime, loc)
+		if !due.Before(until) {
+			break
+		}
+		if on[day.Weekday()] && !due.Before(from) {
+			times = append(times, due)
+		}
+	}
+	return times
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInjectionScheduleDueTimes(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("No timezone data: %v", err)
+	}
+
+	// Every 3 days at 19:00, across the November DST change
+	schedule := InjectionSchedule{
+		LastInjection:  time.Date(2025, 10, 28, 20, 30, 0, 0, loc),
+		FrequencyHours: 72,
+		ReminderTime:   "19:00",
+		Location:       loc,
+	}
+	from := time.Date(2025, 11, 1, 0, 0, 0, 0, loc)
+	times := schedule.DueTimes(from, from.AddDate(0, 0, 7), 10)
+	want := []time.Time{
+		time.Date(2025, 11, 3, 19, 0, 0, 0, loc),
+		time.Date(2025, 11, 6, 19, 0, 0, 0, loc),
+	}
+	if len(times) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, times)
+	}
+	for i := range want {
+		if !times[i].Equal(want[i]) {
+			t.Errorf("Due time %d: expected %v, got %v", i, want[i], times[i])
+		}
+	}
+
+	// Hourly frequencies follow on from the last injection exactly
+	schedule = InjectionSchedule{
+		LastInjection:  time.Date(2025, 1, 1, 8, 15, 0, 0, time.UTC),
+		FrequencyHours: 12,
+		Location:       time.UTC,
+	}
+	times = schedule.DueTimes(time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC), 10)
+	if len(times) != 2 || !times[0].Equal(time.Date(2025, 1, 5, 8, 15, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected 12-hourly due times: %v", times)
+	}
+
+	// With nothing logged the course starts on its start date
+	schedule = InjectionSchedule{
+		CourseStart:    time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		FrequencyHours: 24,
+		ReminderTime:   "07:30",
+		Location:       time.UTC,
+	}
+	times = schedule.DueTimes(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), 3)
+	if len(times) != 3 || !times[0].Equal(time.Date(2025, 3, 10, 7, 30, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected due times for a new course: %v", times)
+	}
+
+	// Weekday schedules skip to the next listed day after the last injection
+	schedule = InjectionSchedule{
+		LastInjection: time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC),
+		Weekdays:      []time.Weekday{time.Monday, time.Thursday},
+		ReminderTime:  "08:00",
+		Location:      time.UTC,
+	}
+	times = schedule.DueTimes(time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC), 10)
+	if len(times) != 2 || !times[0].Equal(time.Date(2025, 1, 9, 8, 0, 0, 0, time.UTC)) || !times[1].Equal(time.Date(2025, 1, 13, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected weekday due times: %v", times)
+	}
+}
+
+func TestMedicationRRule(t *testing.T) {
+	tests := map[string]string{
+		"":                "FREQ=DAILY",
+		"Every day":       "FREQ=DAILY",
+		"Every 2 days":    "FREQ=DAILY;INTERVAL=2",
+		"every 8 hours":   "FREQ=HOURLY;INTERVAL=8",
+		"Weekly":          "FREQ=WEEKLY",
+		"Every 1 week":    "FREQ=WEEKLY",
+		"Every other day": "FREQ=DAILY;INTERVAL=2",
+		"As needed":       "FREQ=DAILY",
+	}
+	for frequency, want := range tests {
+		if got := MedicationRRule(frequency); got != want {
+			t.Errorf("MedicationRRule(%q) = %q, want %q", frequency, got, want)
+		}
+	}
+}
+
+func TestWriteICalendar(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("No timezone data: %v", err)
+	}
+	start := time.Date(2025, 6, 1, 9, 0, 0, 0, loc)
+	events := []CalendarEvent{
+		{
+			UID:         "appointment-1@p-track",
+			Summary:     "Scan, clinic; bring notes",
+			Description: "Line one\nLine two " + strings.Repeat("é", 40),
+			Start:       start,
+			End:         start.Add(time.Hour),
+		},
+		{
+			UID:     "medication-2@p-track",
+			Summary: "Take Estradiol",
+			Start:   start,
+			End:     start.Add(15 * time.Minute),
+			RRule:   "FREQ=DAILY",
+			TZ:      loc,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteICalendar(&buf, "P-TRACK", events, start); err != nil {
+		t.Fatalf("Failed to write calendar: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		`SUMMARY:Scan\, clinic\; bring notes` + "\r\n",
+		"DTSTART:20250601T080000Z\r\n",
+		"DTSTART;TZID=Europe/London:20250601T090000\r\n",
+		"RRULE:FREQ=DAILY\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in calendar:\n%s", want, out)
+		}
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line longer than 75 octets: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	if !strings.Contains(unfolded, "DESCRIPTION:Line one\\nLine two "+strings.Repeat("é", 40)+"\r\n") {
+		t.Errorf("Description did not survive folding:\n%s", unfolded)
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// checkInReminderInterval is how often reminder times are checked, and so
+// roughly how late a reminder can be
+const checkInReminderInterval = 15 * time.Minute
+
+// checkInReminderUser is a member who asked to be reminded to check in
+type checkInReminderUser struct {
+	UserID    int64
+	AccountID int64
+	Time      string // HH:MM
+	Timezone  string
+	Locale    string // Saved locale, empty when never set
+}
+
+// SendCheckInReminders reminds every user with a check-in reminder whose
+// time has passed today, in their timezone, and who hasn't checked in for
+// today yet. Each user is reminded at most once a day.
+func SendCheckInReminders(db *database.DB, now time.Time) (int, error) {
+	users, err := checkInReminderUsers(db)
+	if err != nil {
+		return 0, err
+	}
+
+	checkIns := repository.NewWellnessCheckInRepository(db)
+	notifications := repository.NewNotificationRepository(db)
+	sent := 0
+	for _, u := range users {
+		loc, err := time.LoadLocation(u.Timezone)
+		if err != nil {
+			loc, _ = time.LoadLocation("America/New_York")
+		}
+		local := now.In(loc)
+		due, err := time.ParseInLocation("2006-01-02 15:04", local.Format("2006-01-02")+" "+u.Time, loc)
+		if err != nil || local.Before(due) {
+			continue
+		}
+
+		if _, err := checkIns.GetForDay(u.AccountID, u.UserID, local); err == nil {
+			continue
+		} else if err != repository.ErrNotFound {
+			slog.Error("Failed to look up today's check-in", "user_id", u.UserID, "err", err)
+			continue
+		}
+
+		// The message, naming the date, doubles as the dedupe key
+		p := i18n.For(u.Locale)
+		message := p.T("Don't forget to check in for %s: how are your mood, energy and sleep today?", p.MonthDay(local))
+		userID := sql.NullInt64{Int64: u.UserID, Valid: true}
+		exists, err := notifications.RecentlyNotified(userID, "system", message, 24)
+		if err != nil {
+			slog.Error("Failed to check existing notifications", "user_id", u.UserID, "err", err)
+			continue
+		}
+		if exists {
+			continue
+		}
+
+		err = notifications.Create(&models.Notification{
+			UserID:  userID,
+			Type:    "system",
+			Title:   p.T("Daily check-in"),
+			Message: message,
+		})
+		if err != nil {
+			slog.Error("Failed to create check-in reminder", "user_id", u.UserID, "err", err)
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// checkInReminderUsers lists the active users with a check-in reminder set,
+// along with their account and timezone
+func checkInReminderUsers(db *database.DB) ([]checkInReminderUser, error) {
+	rows, err := db.Query(`
+		SELECT am.user_id, am.account_id, s.value, COALESCE(p.timezone, ''), COALESCE(p.locale, '')
+		FROM user_settings s
+		JOIN account_members am ON am.user_id = s.user_id
+		JOIN users u ON u.id = am.user_id
+		LEFT JOIN user_preferences p ON p.user_id = am.user_id
+		WHERE s.key = 'checkin_reminder' AND s.value != '' AND u.is_active = TRUE
+		AND ` + repository.AccountNotSuspended("am.account_id") + `
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query check-in reminders: %w", err)
+	}
+	defer rows.Close()
+
+	var users []checkInReminderUser
+	for rows.Next() {
+		var u checkInReminderUser
+		if err := rows.Scan(&u.UserID, &u.AccountID, &u.Time, &u.Timezone, &u.Locale); err != nil {
+			return nil, fmt.Errorf("failed to scan check-in reminder: %w", err)
+		}
+		u.Time = strings.TrimSpace(u.Time)
+		if u.Timezone == "" {
+			u.Timezone = "America/New_York"
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// StartCheckInReminderScheduler sends daily check-in reminders as their
+// times come round
+func StartCheckInReminderScheduler(db *database.DB) {
+	run := func() {
+		sent, err := SendCheckInReminders(db, time.Now())
+		RecordSchedulerRun("checkin_reminders", err)
+		if err != nil {
+			slog.Error("Sending check-in reminders failed", "err", err)
+		} else if sent > 0 {
+			slog.Info("Sent check-in reminders", "count", sent)
+		}
+	}
+
+	RegisterScheduler("checkin_reminders", checkInReminderInterval)
+	RunBackground(func() {
+		// Wait for server to fully start
+		if !SleepUnlessShutdown(time.Minute) {
+			return
+		}
+		run()
+
+		ticker := time.NewTicker(checkInReminderInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-shutdownChan:
+				return
+			}
+		}
+	})
+}
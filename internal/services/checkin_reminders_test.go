@@ -0,0 +1,62 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+func TestSendCheckInReminders(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash'), (2, 'partner', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner'), (1, 2, 'member');
+		INSERT INTO user_settings (user_id, key, value) VALUES (1, 'checkin_reminder', '20:00'), (2, 'checkin_reminder', '20:00');
+		INSERT INTO user_preferences (user_id, timezone) VALUES (1, 'UTC'), (2, 'UTC');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+
+	// Not due yet
+	sent, err := SendCheckInReminders(db, time.Date(2026, 3, 20, 19, 0, 0, 0, time.UTC))
+	if err != nil || sent != 0 {
+		t.Fatalf("Expected no reminders before 20:00, got %d, %v", sent, err)
+	}
+
+	// The partner has already checked in today
+	checkIns := repository.NewWellnessCheckInRepository(db)
+	checkIn := &models.WellnessCheckIn{AccountID: 1, Date: time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)}
+	checkIn.UserID.Int64, checkIn.UserID.Valid = 2, true
+	checkIn.Mood.Int64, checkIn.Mood.Valid = 4, true
+	if err := checkIns.Create(checkIn); err != nil {
+		t.Fatalf("Failed to create check-in: %v", err)
+	}
+
+	now := time.Date(2026, 3, 20, 20, 30, 0, 0, time.UTC)
+	sent, err = SendCheckInReminders(db, now)
+	if err != nil || sent != 1 {
+		t.Fatalf("Expected one reminder, got %d, %v", sent, err)
+	}
+	var userID int64
+	if err := db.QueryRow(`SELECT user_id FROM notifications WHERE title = 'Daily check-in'`).Scan(&userID); err != nil || userID != 1 {
+		t.Errorf("Expected the reminder for user 1, got %d, %v", userID, err)
+	}
+
+	// A second run the same day doesn't repeat it
+	if sent, err := SendCheckInReminders(db, now.Add(15*time.Minute)); err != nil || sent != 0 {
+		t.Errorf("Expected no repeat reminder, got %d, %v", sent, err)
+	}
+}
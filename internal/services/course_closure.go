@@ -0,0 +1,213 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/repository"
+)
+
+// DefaultCourseGraceDays is how long a course is left open past its expected
+// end date before it is closed automatically, unless an admin changes it
+const DefaultCourseGraceDays = 7
+
+// CourseAutoClose controls closing courses that have run past their expected
+// end date. A course is closed once GraceDays whole days have passed since
+// that date, which leaves room for a late injection or two.
+type CourseAutoClose struct {
+	Enabled   bool   `json:"enabled"`
+	GraceDays int    `json:"grace_days" validate:"min=0,max=365"`
+	LastRun   string `json:"last_run,omitempty"`
+}
+
+// AutoClosedCourse is a course closed because it ran past its end date
+type AutoClosedCourse struct {
+	ID                      int64     `json:"id"`
+	AccountID               int64     `json:"account_id"`
+	Name                    string    `json:"name"`
+	StartDate               time.Time `json:"start_date"`
+	EndDate                 time.Time `json:"end_date"`
+	ArchivedReportSchedules int64     `json:"archived_report_schedules"`
+}
+
+// LoadCourseAutoClose reads the course auto-close policy from the site
+// settings. Auto-close is on with DefaultCourseGraceDays until changed.
+func LoadCourseAutoClose(db *database.DB) CourseAutoClose {
+	policy := CourseAutoClose{Enabled: true, GraceDays: DefaultCourseGraceDays}
+	settings := repository.NewSettingsRepository(db)
+
+	if value, err := settings.GetSite("course_auto_close_enabled"); err == nil {
+		policy.Enabled = value == "true"
+	}
+	if value, err := settings.GetSite("course_auto_close_grace_days"); err == nil {
+		_, _ = fmt.Sscanf(value, "%d", &policy.GraceDays)
+	}
+	if value, err := settings.GetSite("course_auto_close_last_run"); err == nil {
+		policy.LastRun = value
+	}
+
+	return policy
+}
+
+// SaveCourseAutoClose stores the course auto-close policy. LastRun is left
+// alone.
+func SaveCourseAutoClose(db *database.DB, policy CourseAutoClose) error {
+	settings := repository.NewSettingsRepository(db)
+	for key, value := range map[string]string{
+		"course_auto_close_enabled":    strconv.FormatBool(policy.Enabled),
+		"course_auto_close_grace_days": strconv.Itoa(policy.GraceDays),
+	} {
+		if err := settings.SetSite(key, value, sql.NullInt64{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CloseExpiredCourses closes every active course whose expected end date is
+// more than the grace period before now. Each course is closed as of its
+// expected end date, the report schedules limited to it are disabled, and
+// the account is notified. A suspended account's courses stay open.
+func CloseExpiredCourses(db *database.DB, now time.Time) ([]AutoClosedCourse, error) {
+	policy := LoadCourseAutoClose(db)
+	if !policy.Enabled {
+		return nil, nil
+	}
+
+	due, err := expiredCourses(db, now.UTC().AddDate(0, 0, -policy.GraceDays))
+	if err != nil {
+		return nil, err
+	}
+
+	courseRepo := repository.NewCourseRepository(db)
+	scheduleRepo := repository.NewReportScheduleRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	dispatcher := NewNotificationDispatcher(db)
+	webhooks := NewWebhookService(db)
+
+	closed := make([]AutoClosedCourse, 0, len(due))
+	for _, c := range due {
+		if err := courseRepo.Close(c.ID, c.AccountID, c.EndDate); err != nil {
+			return closed, err
+		}
+		archived, err := scheduleRepo.DisableForCourse(c.ID)
+		if err != nil {
+			slog.Error("Failed to archive report schedules for closed course", "course_id", c.ID, "err", err)
+		}
+		c.ArchivedReportSchedules = archived
+		closed = append(closed, c)
+
+		endDate := c.EndDate.Format("2006-01-02")
+		_ = auditRepo.LogWithDetails(
+			sql.NullInt64{},
+			"auto_close",
+			"course",
+			sql.NullInt64{Int64: c.ID, Valid: true},
+			map[string]interface{}{
+				"name":                      c.Name,
+				"end_date":                  endDate,
+				"grace_days":                policy.GraceDays,
+				"archived_report_schedules": archived,
+			},
+			"",
+			"",
+		)
+
+		webhooks.Emit(c.AccountID, EventCourseClosed, map[string]interface{}{
+			"id":          c.ID,
+			"name":        c.Name,
+			"start_date":  c.StartDate.Format("2006-01-02"),
+			"end_date":    endDate,
+			"auto_closed": true,
+		})
+
+		message, args := "%s was due to end on %s and has been closed. Reopen it from the Courses page if it's still running.", []interface{}{c.Name, i18n.Date(c.EndDate)}
+		if archived > 0 {
+			message = "%s was due to end on %s and has been closed. Reopen it from the Courses page if it's still running. %d scheduled report(s) for it were turned off."
+			args = append(args, archived)
+		}
+		err = dispatcher.Dispatch(NotificationMessage{
+			AccountID: c.AccountID,
+			Type:      "system",
+			Title:     "Course closed",
+			Message:   message,
+			Args:      args,
+			Priority:  PriorityDefault,
+		})
+		if err != nil {
+			slog.Error("Failed to notify account of closed course", "account_id", c.AccountID, "course_id", c.ID, "err", err)
+		}
+	}
+
+	_ = repository.NewSettingsRepository(db).SetSite("course_auto_close_last_run", now.UTC().Format("2006-01-02 15:04:05"), sql.NullInt64{})
+	return closed, nil
+}
+
+// expiredCourses lists the active courses whose expected end date falls
+// before cutoff's calendar date. Dates are compared in Go so a DATE column
+// compares the same way on either database.
+func expiredCourses(db *database.DB, cutoff time.Time) ([]AutoClosedCourse, error) {
+	rows, err := db.Query(`
+		SELECT id, account_id, name, start_date, expected_end_date
+		FROM courses
+		WHERE is_active = TRUE AND expected_end_date IS NOT NULL AND ` + repository.AccountNotSuspended("account_id") + `
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active courses: %w", err)
+	}
+	defer rows.Close()
+
+	before := cutoff.Format("2006-01-02")
+	var due []AutoClosedCourse
+	for rows.Next() {
+		var c AutoClosedCourse
+		if err := rows.Scan(&c.ID, &c.AccountID, &c.Name, &c.StartDate, &c.EndDate); err != nil {
+			return nil, fmt.Errorf("failed to scan course: %w", err)
+		}
+		if c.EndDate.Format("2006-01-02") < before {
+			due = append(due, c)
+		}
+	}
+	return due, rows.Err()
+}
+
+// StartCourseClosureScheduler closes courses past their end date shortly
+// after startup and then once a day
+func StartCourseClosureScheduler(db *database.DB) {
+	run := func() {
+		closed, err := CloseExpiredCourses(db, time.Now())
+		RecordSchedulerRun("course_closure", err)
+		if err != nil {
+			slog.Error("Closing expired courses failed", "err", err)
+		}
+		for _, c := range closed {
+			slog.Info("Closed course past its end date", "course_id", c.ID, "account_id", c.AccountID, "end_date", c.EndDate.Format("2006-01-02"))
+		}
+	}
+
+	RegisterScheduler("course_closure", 24*time.Hour)
+	RunBackground(func() {
+		// Wait for server to fully start
+		if !SleepUnlessShutdown(time.Minute) {
+			return
+		}
+		run()
+
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-shutdownChan:
+				return
+			}
+		}
+	})
+}
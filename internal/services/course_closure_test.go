@@ -0,0 +1,84 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+)
+
+func TestCloseExpiredCourses(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash'), (2, 'locked', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO accounts (id, suspension) VALUES (2, 'locked');
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner'), (2, 2, 'owner');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := db.Exec(`INSERT INTO courses (id, account_id, name, start_date, expected_end_date, is_active) VALUES
+		(1, 1, 'Past grace', ?, ?, 1),
+		(2, 1, 'Within grace', ?, ?, 1),
+		(3, 1, 'Open ended', ?, NULL, 1),
+		(4, 2, 'Suspended account', ?, ?, 1)`,
+		start, time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC),
+		start, time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC),
+		start,
+		start, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Failed to seed courses: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO report_schedules (account_id, user_id, name, frequency, course_id, next_run_at) VALUES
+		(1, 1, 'Course report', 'weekly', 1, ?), (1, 1, 'Everything', 'weekly', NULL, ?)`, now, now); err != nil {
+		t.Fatalf("Failed to seed report schedules: %v", err)
+	}
+
+	closed, err := CloseExpiredCourses(db, now)
+	if err != nil {
+		t.Fatalf("CloseExpiredCourses failed: %v", err)
+	}
+	if len(closed) != 1 || closed[0].ID != 1 || closed[0].ArchivedReportSchedules != 1 {
+		t.Fatalf("Expected only the course more than 7 days past its end, and not suspended, to close, got %+v", closed)
+	}
+
+	var active bool
+	var endDate time.Time
+	if err := db.QueryRow(`SELECT is_active, actual_end_date FROM courses WHERE id = 1`).Scan(&active, &endDate); err != nil {
+		t.Fatalf("Failed to read course: %v", err)
+	}
+	if active || endDate.Format("2006-01-02") != "2026-03-12" {
+		t.Errorf("Expected the course closed as of its expected end date, got active=%t end=%s", active, endDate)
+	}
+	var enabled int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM report_schedules WHERE is_enabled = 1`).Scan(&enabled); err != nil {
+		t.Fatalf("Failed to count report schedules: %v", err)
+	}
+	if enabled != 1 {
+		t.Errorf("Expected only the course's report schedule turned off, got %d enabled", enabled)
+	}
+	var notified int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = 1 AND type = 'system'`).Scan(&notified); err != nil {
+		t.Fatalf("Failed to count notifications: %v", err)
+	}
+	if notified != 1 {
+		t.Errorf("Expected the account notified once, got %d", notified)
+	}
+
+	if err := SaveCourseAutoClose(db, CourseAutoClose{Enabled: false, GraceDays: 0}); err != nil {
+		t.Fatalf("SaveCourseAutoClose failed: %v", err)
+	}
+	if closed, err := CloseExpiredCourses(db, now); err != nil || len(closed) != 0 {
+		t.Errorf("Expected nothing closed while auto-close is off, got %+v, %v", closed, err)
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+// Feature flag keys
+const (
+	// FeatureRotationPlanner suggests where the next injection should go
+	FeatureRotationPlanner = "rotation_planner"
+	// FeaturePushNotifications shows browser notifications for activity
+	// from other users of the account while the app is in the background
+	FeaturePushNotifications = "push_notifications"
+)
+
+// FeatureFlag describes an experimental feature the admin can switch on or
+// off, for the whole site or for single accounts
+type FeatureFlag struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+}
+
+// FeatureFlags lists every known feature flag
+var FeatureFlags = []FeatureFlag{
+	{
+		Key:         FeatureRotationPlanner,
+		Name:        "Rotation planner",
+		Description: "Suggest the side and spot for the next injection from recent history and reactions.",
+		Default:     true,
+	},
+	{
+		Key:         FeaturePushNotifications,
+		Name:        "Push notifications",
+		Description: "Show browser notifications when another member logs an injection or medication while the app is in the background.",
+		Default:     false,
+	},
+}
+
+// LookupFeatureFlag returns the feature flag with the given key
+func LookupFeatureFlag(key string) (FeatureFlag, bool) {
+	for _, f := range FeatureFlags {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return FeatureFlag{}, false
+}
+
+// FeatureState is what the admin has set a flag to; nil means not set
+type FeatureState struct {
+	Global  *bool
+	Account *bool
+}
+
+// Enabled resolves whether the feature is on: an account's setting wins
+// over the site-wide one, which wins over the flag's default
+func (f FeatureFlag) Enabled(state FeatureState) bool {
+	if state.Account != nil {
+		return *state.Account
+	}
+	if state.Global != nil {
+		return *state.Global
+	}
+	return f.Default
+}
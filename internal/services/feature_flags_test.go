@@ -0,0 +1,33 @@
+package services
+
+import "testing"
+
+func TestFeatureFlagEnabled(t *testing.T) {
+	on, off := true, false
+	flag, ok := LookupFeatureFlag(FeaturePushNotifications)
+	if !ok {
+		t.Fatal("push_notifications flag not registered")
+	}
+
+	tests := []struct {
+		name  string
+		state FeatureState
+		want  bool
+	}{
+		{"default", FeatureState{}, flag.Default},
+		{"global on", FeatureState{Global: &on}, true},
+		{"account overrides global", FeatureState{Global: &on, Account: &off}, false},
+		{"account on with global unset", FeatureState{Account: &on}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flag.Enabled(tt.state); got != tt.want {
+				t.Errorf("Enabled(%+v) = %v, want %v", tt.state, got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := LookupFeatureFlag("no_such_flag"); ok {
+		t.Error("LookupFeatureFlag found an unknown flag")
+	}
+}
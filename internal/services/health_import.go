@@ -0,0 +1,241 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Import of body measurements and symptoms from health app exports: the
+// export.xml inside an Apple Health export, or the JSON data files from a
+// Google Fit takeout. Each record type maps to a target: "vital:<type>",
+// "symptom:<name>" or "ignore".
+
+const (
+	HealthSourceApple  = "apple_health"
+	HealthSourceGoogle = "google_fit"
+
+	HealthTargetIgnore = "ignore"
+)
+
+// VitalUnits is the unit each vital type is stored in
+var VitalUnits = map[string]string{
+	"body_temperature": "degC",
+	"weight":           "kg",
+}
+
+// DefaultHealthMappings maps health app record types to targets unless an
+// account overrides them. Record types not listed are ignored.
+var DefaultHealthMappings = map[string]string{
+	"HKQuantityTypeIdentifierBodyTemperature":      "vital:body_temperature",
+	"HKQuantityTypeIdentifierBasalBodyTemperature": "vital:body_temperature",
+	"HKQuantityTypeIdentifierBodyMass":             "vital:weight",
+	"HKCategoryTypeIdentifierHeadache":             "symptom:headache",
+	"HKCategoryTypeIdentifierNausea":               "symptom:nausea",
+	"HKCategoryTypeIdentifierFatigue":              "symptom:fatigue",
+	"HKCategoryTypeIdentifierDizziness":            "symptom:dizziness",
+	"HKCategoryTypeIdentifierMoodChanges":          "symptom:mood_changes",
+	"HKCategoryTypeIdentifierBloating":             "symptom:bloating",
+	"HKCategoryTypeIdentifierBreastPain":           "symptom:breast_pain",
+	"HKCategoryTypeIdentifierHotFlashes":           "symptom:hot_flashes",
+	"HKCategoryTypeIdentifierAbdominalCramps":      "symptom:cramps",
+	"com.google.weight":                            "vital:weight",
+	"com.google.body.temperature":                  "vital:body_temperature",
+}
+
+// googleFitUnits are the fixed units of Google Fit data types
+var googleFitUnits = map[string]string{
+	"com.google.weight":           "kg",
+	"com.google.body.temperature": "degC",
+}
+
+var symptomNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// ValidateHealthTarget checks a mapping target
+func ValidateHealthTarget(target string) error {
+	if target == HealthTargetIgnore {
+		return nil
+	}
+	kind, name, ok := strings.Cut(target, ":")
+	switch {
+	case ok && kind == "vital":
+		if _, known := VitalUnits[name]; known {
+			return nil
+		}
+		return fmt.Errorf("unknown vital type %q", name)
+	case ok && kind == "symptom":
+		if symptomNamePattern.MatchString(name) {
+			return nil
+		}
+		return fmt.Errorf("symptom name %q must be lowercase letters, digits and underscores", name)
+	}
+	return fmt.Errorf("target must be 'vital:<type>', 'symptom:<name>' or 'ignore'")
+}
+
+// HealthRecord is one measurement or symptom read from a health app export
+type HealthRecord struct {
+	SourceType string
+	Timestamp  time.Time
+	Category   bool    // A symptom-style record rather than a measurement
+	Value      float64 // Measurements only
+	Unit       string
+	Severity   string // Symptoms only: mild, moderate, severe, or empty if unspecified
+}
+
+// ConvertVital converts a measurement to the unit its vital type is stored in
+func ConvertVital(vitalType string, value float64, unit string) (float64, error) {
+	switch vitalType {
+	case "body_temperature":
+		switch unit {
+		case "degC":
+			return value, nil
+		case "degF":
+			return (value - 32) * 5 / 9, nil
+		}
+	case "weight":
+		switch unit {
+		case "kg":
+			return value, nil
+		case "g":
+			return value / 1000, nil
+		case "lb":
+			return value * 0.45359237, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported unit %q for %s", unit, vitalType)
+}
+
+// ParseAppleHealth streams an Apple Health export.xml, returning the records
+// whose type wanted accepts. Symptoms marked not present are left out.
+func ParseAppleHealth(r io.Reader, wanted func(sourceType string) bool) ([]HealthRecord, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+
+	var records []HealthRecord
+	sawHealthData := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid Apple Health export: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local == "HealthData" {
+			sawHealthData = true
+			continue
+		}
+		if start.Name.Local != "Record" {
+			continue
+		}
+
+		attrs := make(map[string]string, len(start.Attr))
+		for _, a := range start.Attr {
+			attrs[a.Name.Local] = a.Value
+		}
+		sourceType := attrs["type"]
+		if !wanted(sourceType) {
+			continue
+		}
+		timestamp, err := time.Parse("2006-01-02 15:04:05 -0700", attrs["startDate"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid startDate %q on %s record", attrs["startDate"], sourceType)
+		}
+
+		record := HealthRecord{SourceType: sourceType, Timestamp: timestamp}
+		if strings.HasPrefix(sourceType, "HKCategoryType") {
+			value := attrs["value"]
+			if strings.HasSuffix(value, "NotPresent") {
+				continue
+			}
+			record.Category = true
+			for _, severity := range []string{"Mild", "Moderate", "Severe"} {
+				if strings.HasSuffix(value, "Severity"+severity) {
+					record.Severity = strings.ToLower(severity)
+				}
+			}
+		} else {
+			record.Value, err = strconv.ParseFloat(attrs["value"], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q on %s record", attrs["value"], sourceType)
+			}
+			record.Unit = attrs["unit"]
+		}
+		records = append(records, record)
+	}
+
+	if !sawHealthData {
+		return nil, errors.New("not an Apple Health export (no HealthData element)")
+	}
+	return records, nil
+}
+
+// googleFitFile is one data file from a Google Fit takeout ("All Data")
+type googleFitFile struct {
+	DataPoints []struct {
+		DataTypeName   string `json:"dataTypeName"`
+		StartTimeNanos int64  `json:"startTimeNanos"`
+		FitValue       []struct {
+			Value struct {
+				FpVal  *float64 `json:"fpVal"`
+				IntVal *int64   `json:"intVal"`
+			} `json:"value"`
+		} `json:"fitValue"`
+	} `json:"Data Points"`
+}
+
+// ParseGoogleFit reads one Google Fit takeout data file, or a JSON array of
+// them, returning the data points whose type wanted accepts
+func ParseGoogleFit(r io.Reader, wanted func(sourceType string) bool) ([]HealthRecord, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []googleFitFile
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &files)
+	} else {
+		var file googleFitFile
+		err = json.Unmarshal(trimmed, &file)
+		files = []googleFitFile{file}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid Google Fit export: %w", err)
+	}
+
+	var records []HealthRecord
+	for _, file := range files {
+		for _, point := range file.DataPoints {
+			if !wanted(point.DataTypeName) || len(point.FitValue) == 0 {
+				continue
+			}
+			record := HealthRecord{
+				SourceType: point.DataTypeName,
+				Timestamp:  time.Unix(0, point.StartTimeNanos).UTC(),
+				Unit:       googleFitUnits[point.DataTypeName],
+			}
+			switch value := point.FitValue[0].Value; {
+			case value.FpVal != nil:
+				record.Value = *value.FpVal
+			case value.IntVal != nil:
+				record.Value = float64(*value.IntVal)
+			default:
+				continue
+			}
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
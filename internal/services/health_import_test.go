@@ -0,0 +1,91 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func wantDefaults(sourceType string) bool {
+	_, ok := DefaultHealthMappings[sourceType]
+	return ok
+}
+
+func TestParseAppleHealth(t *testing.T) {
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE HealthData [
+<!ELEMENT HealthData (Record)*>
+]>
+<HealthData locale="en_US">
+ <Record type="HKQuantityTypeIdentifierBodyMass" unit="lb" value="150" startDate="2025-01-02 08:00:00 -0500" endDate="2025-01-02 08:00:00 -0500"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" unit="count" value="900" startDate="2025-01-02 08:00:00 -0500"/>
+ <Record type="HKCategoryTypeIdentifierHeadache" value="HKCategoryValueSeverityModerate" startDate="2025-01-03 21:15:00 -0500"/>
+ <Record type="HKCategoryTypeIdentifierNausea" value="HKCategoryValueSeverityNotPresent" startDate="2025-01-03 21:15:00 -0500"/>
+</HealthData>`
+
+	records, err := ParseAppleHealth(strings.NewReader(body), wantDefaults)
+	if err != nil {
+		t.Fatalf("Failed to parse export: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected weight and headache only, got %+v", records)
+	}
+
+	weight := records[0]
+	if weight.Value != 150 || weight.Unit != "lb" || !weight.Timestamp.Equal(time.Date(2025, 1, 2, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected weight record: %+v", weight)
+	}
+	if headache := records[1]; !headache.Category || headache.Severity != "moderate" {
+		t.Errorf("Unexpected headache record: %+v", headache)
+	}
+
+	if _, err := ParseAppleHealth(strings.NewReader(`<Other/>`), wantDefaults); err == nil {
+		t.Error("Expected an error for XML that isn't a health export")
+	}
+}
+
+func TestParseGoogleFit(t *testing.T) {
+	body := `{"Data Source": "derived:com.google.weight", "Data Points": [
+		{"dataTypeName": "com.google.weight", "startTimeNanos": 1735804800000000000, "fitValue": [{"value": {"fpVal": 68.5}}]},
+		{"dataTypeName": "com.google.step_count.delta", "startTimeNanos": 1735804800000000000, "fitValue": [{"value": {"intVal": 10}}]}
+	]}`
+
+	records, err := ParseGoogleFit(strings.NewReader(body), wantDefaults)
+	if err != nil {
+		t.Fatalf("Failed to parse export: %v", err)
+	}
+	if len(records) != 1 || records[0].Value != 68.5 || records[0].Unit != "kg" ||
+		!records[0].Timestamp.Equal(time.Date(2025, 1, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+
+	if records, err := ParseGoogleFit(strings.NewReader("["+body+","+body+"]"), wantDefaults); err != nil || len(records) != 2 {
+		t.Errorf("Expected an array of files to be read, got %d records (%v)", len(records), err)
+	}
+}
+
+func TestConvertVital(t *testing.T) {
+	if c, err := ConvertVital("body_temperature", 98.6, "degF"); err != nil || math.Abs(c-37) > 0.001 {
+		t.Errorf("Expected 98.6 degF to be 37 degC, got %v (%v)", c, err)
+	}
+	if kg, err := ConvertVital("weight", 150, "lb"); err != nil || math.Abs(kg-68.04) > 0.01 {
+		t.Errorf("Expected 150 lb to be about 68.04 kg, got %v (%v)", kg, err)
+	}
+	if _, err := ConvertVital("weight", 10, "st"); err == nil {
+		t.Error("Expected an error for an unsupported unit")
+	}
+}
+
+func TestValidateHealthTarget(t *testing.T) {
+	for _, target := range []string{"ignore", "vital:weight", "symptom:hot_flashes"} {
+		if err := ValidateHealthTarget(target); err != nil {
+			t.Errorf("Expected %q to be valid: %v", target, err)
+		}
+	}
+	for _, target := range []string{"", "vital:height", "symptom:Hot Flashes", "other:x"} {
+		if err := ValidateHealthTarget(target); err == nil {
+			t.Errorf("Expected %q to be invalid", target)
+		}
+	}
+}
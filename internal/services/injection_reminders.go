@@ -0,0 +1,156 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// injectionReminderInterval is how often injections are checked for
+// reminders, and so roughly how late a reminder can be
+const injectionReminderInterval = 15 * time.Minute
+
+// injectionReminderLookback is how long after an injection fell due it is
+// still reminded of, e.g. after the server was down
+const injectionReminderLookback = 12 * time.Hour
+
+// SendInjectionReminders reminds the members of each account with
+// injection reminders on when an injection on any of its active courses
+// falls due and nothing has been logged on that course since. Due times follow the course's
+// schedule in each member's timezone, or while travelling the trip's (see
+// TravelLocation). Each due injection is reminded of once.
+func SendInjectionReminders(db *database.DB, now time.Time) (int, error) {
+	rows, err := db.Query(`
+		SELECT account_id FROM account_settings
+		WHERE key = ? AND value = 'true' AND `+repository.AccountNotSuspended("account_id")+`
+		ORDER BY account_id
+	`, repository.AccountSettingInjectionReminders)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query injection reminders: %w", err)
+	}
+	var accounts []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan injection reminder: %w", err)
+		}
+		accounts = append(accounts, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query injection reminders: %w", err)
+	}
+
+	courses := repository.NewCourseRepository(db)
+	trips := repository.NewTripRepository(db)
+	notifications := repository.NewNotificationRepository(db)
+	sent := 0
+	for _, accountID := range accounts {
+		active, err := courses.ListActive(accountID)
+		if err != nil {
+			slog.Error("Failed to load active courses for reminders", "account_id", accountID, "err", err)
+			continue
+		}
+		if len(active) == 0 {
+			continue
+		}
+		members, err := medicationReminderMembers(db, accountID)
+		if err != nil {
+			slog.Error("Failed to load account members for reminders", "account_id", accountID, "err", err)
+			continue
+		}
+
+		trip, err := trips.Latest(accountID, now)
+		if err != nil && err != repository.ErrNotFound {
+			slog.Error("Failed to load trip for reminders", "account_id", accountID, "err", err)
+			continue
+		}
+
+		for _, course := range active {
+			for _, member := range members {
+				loc, _ := TravelLocation(trip, member.Location, now)
+				schedule, err := LoadInjectionSchedule(db, accountID, course, loc)
+				if err != nil {
+					slog.Error("Failed to plan injections for reminders", "course_id", course.ID, "err", err)
+					continue
+				}
+				due := schedule.DueTimes(now.Add(-injectionReminderLookback), now, 1)
+				if len(due) == 0 {
+					continue
+				}
+				ok, err := sendInjectionReminder(notifications, member, course, due[0])
+				if err != nil {
+					slog.Error("Failed to send injection reminder", "course_id", course.ID, "user_id", member.UserID, "err", err)
+					continue
+				}
+				if ok {
+					sent++
+				}
+			}
+		}
+	}
+	return sent, nil
+}
+
+// sendInjectionReminder reminds a member of a due injection unless they've
+// already been reminded of it. The message, with the due time, doubles as
+// the dedupe key.
+func sendInjectionReminder(notifications *repository.NotificationRepository, member medicationReminderMember, course *models.Course, due time.Time) (bool, error) {
+	p := member.Printer
+	due = due.In(member.Location)
+	message := p.T("Time for your %s injection on %s. Course: %s.", due.Format("15:04"), p.MonthDay(due), course.Name)
+
+	userID := sql.NullInt64{Int64: member.UserID, Valid: true}
+	exists, err := notifications.RecentlyNotified(userID, "system", message, 48)
+	if err != nil || exists {
+		return false, err
+	}
+	err = notifications.Create(&models.Notification{
+		UserID:  userID,
+		Type:    "system",
+		Title:   p.T("Injection reminder"),
+		Message: message,
+	})
+	return err == nil, err
+}
+
+// StartInjectionReminderScheduler sends injection reminders as injections
+// fall due on their courses' schedules
+func StartInjectionReminderScheduler(db *database.DB) {
+	run := func() {
+		sent, err := SendInjectionReminders(db, time.Now())
+		RecordSchedulerRun("injection_reminders", err)
+		if err != nil {
+			slog.Error("Sending injection reminders failed", "err", err)
+		} else if sent > 0 {
+			slog.Info("Sent injection reminders", "count", sent)
+		}
+	}
+
+	RegisterScheduler("injection_reminders", injectionReminderInterval)
+	RunBackground(func() {
+		// Wait for server to fully start
+		if !SleepUnlessShutdown(time.Minute) {
+			return
+		}
+		run()
+
+		ticker := time.NewTicker(injectionReminderInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-shutdownChan:
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+)
+
+func TestSendInjectionRemindersEveryActiveCourse(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	// Progesterone daily at 19:00, estradiol every other day at 08:00
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner');
+		INSERT INTO user_preferences (user_id, timezone) VALUES (1, 'UTC');
+		INSERT INTO account_settings (account_id, key, value) VALUES (1, 'injection_reminders', 'true');
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Progesterone', '2026-03-01', 1), (2, 1, 'Estradiol', '2026-03-05', 1);
+		INSERT INTO course_schedules (course_id, interval_hours, time_of_day) VALUES (1, 24, '19:00'), (2, 48, '08:00');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+	for _, injection := range []struct {
+		courseID int64
+		at       time.Time
+	}{
+		{1, time.Date(2026, 3, 13, 19, 5, 0, 0, time.UTC)},
+		{2, time.Date(2026, 3, 12, 8, 10, 0, 0, time.UTC)},
+	} {
+		if _, err := db.Exec(`INSERT INTO injections (course_id, timestamp, side) VALUES (?, ?, 'left')`, injection.courseID, injection.at); err != nil {
+			t.Fatalf("Failed to seed injection: %v", err)
+		}
+	}
+	reminded := func(course string) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE title = 'Injection reminder' AND message LIKE ?`, "%Course: "+course+".").Scan(&n); err != nil {
+			t.Fatalf("Failed to count notifications: %v", err)
+		}
+		return n
+	}
+
+	// Only the estradiol course is due in the morning
+	if sent, err := SendInjectionReminders(db, time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)); err != nil || sent != 1 {
+		t.Fatalf("Expected one reminder in the morning, got %d, %v", sent, err)
+	}
+	if reminded("Estradiol") != 1 || reminded("Progesterone") != 0 {
+		t.Errorf("Expected only estradiol reminded, got %d estradiol and %d progesterone", reminded("Estradiol"), reminded("Progesterone"))
+	}
+
+	// By the evening progesterone is due too; estradiol isn't reminded again
+	if sent, err := SendInjectionReminders(db, time.Date(2026, 3, 14, 19, 30, 0, 0, time.UTC)); err != nil || sent != 1 {
+		t.Fatalf("Expected one reminder in the evening, got %d, %v", sent, err)
+	}
+	if reminded("Estradiol") != 1 || reminded("Progesterone") != 1 {
+		t.Errorf("Expected each course reminded once, got %d estradiol and %d progesterone", reminded("Estradiol"), reminded("Progesterone"))
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// Injection schedule settings for an account that hasn't changed them; a
+// course with its own schedule doesn't use them
+const (
+	DefaultInjectionFrequencyHours = 24
+	DefaultInjectionTime           = "19:00"
+)
+
+// MaxScheduleIntervalHours caps a course schedule's interval at 90 days
+const MaxScheduleIntervalHours = 90 * 24
+
+// NewInjectionSchedule is when a course's injections fall due: on its own
+// schedule, or on the account's frequencyHours and reminderTime when it has
+// none (schedule nil). LastInjection is left for the caller.
+func NewInjectionSchedule(course *models.Course, schedule *models.CourseSchedule, frequencyHours int, reminderTime string, loc *time.Location) InjectionSchedule {
+	s := InjectionSchedule{
+		CourseStart:    course.StartDate,
+		FrequencyHours: frequencyHours,
+		ReminderTime:   reminderTime,
+		Location:       loc,
+	}
+	if schedule != nil {
+		s.FrequencyHours = schedule.IntervalHours
+		s.Weekdays = schedule.Weekdays
+		if schedule.TimeOfDay.Valid {
+			s.ReminderTime = schedule.TimeOfDay.String
+		}
+	}
+	return s
+}
+
+// LoadInjectionSchedule works out when a course's injections fall due, in
+// loc: on its own schedule, or else the account's reminder_frequency and
+// reminder_time. It follows on from the course's last injection.
+func LoadInjectionSchedule(db *database.DB, accountID int64, course *models.Course, loc *time.Location) (InjectionSchedule, error) {
+	schedule, err := repository.NewCourseScheduleRepository(db).Get(course.ID, accountID)
+	if err != nil && err != repository.ErrNotFound {
+		return InjectionSchedule{}, err
+	}
+
+	frequency, clock := DefaultInjectionFrequencyHours, DefaultInjectionTime
+	if schedule == nil || !schedule.TimeOfDay.Valid {
+		settings := repository.NewSettingsRepository(db)
+		if value, err := settings.GetAccount(accountID, repository.AccountSettingReminderFrequency); err == nil {
+			if hours, err := strconv.Atoi(value); err == nil {
+				frequency = hours
+			}
+		} else if err != repository.ErrNotFound {
+			return InjectionSchedule{}, err
+		}
+		if value, err := settings.GetAccount(accountID, repository.AccountSettingReminderTime); err == nil {
+			clock = value
+		} else if err != repository.ErrNotFound {
+			return InjectionSchedule{}, err
+		}
+	}
+
+	s := NewInjectionSchedule(course, schedule, frequency, clock, loc)
+	recent, err := repository.NewInjectionRepository(db).ListByCourse(course.ID, accountID, 1, 0)
+	if err != nil {
+		return InjectionSchedule{}, err
+	}
+	if len(recent) > 0 {
+		s.LastInjection = recent[0].Timestamp
+	}
+	return s, nil
+}
+
+// Describe says how often injections fall due, e.g. "every 24 hours" or
+// "on Mon, Wed, Fri"
+func (s InjectionSchedule) Describe() string {
+	if len(s.Weekdays) > 0 {
+		names := make([]string, len(s.Weekdays))
+		for i, d := range s.Weekdays {
+			names[i] = d.String()[:3]
+		}
+		return "on " + strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("every %d hours", s.FrequencyHours)
+}
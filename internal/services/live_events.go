@@ -0,0 +1,168 @@
+package services
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Live event types pushed to an account's open sessions
+const (
+	LiveInjectionCreated  = "injection.created"
+	LiveInventoryAdjusted = "inventory.adjusted"
+	LiveMedicationLogged  = "medication.logged"
+)
+
+const (
+	// liveEventReplaySize is how many recent events per account are kept for
+	// clients reconnecting with Last-Event-ID
+	liveEventReplaySize = 100
+	// liveEventBuffer is how far a subscriber may fall behind before it is
+	// dropped; it reconnects and catches up from the replay buffer
+	liveEventBuffer = 32
+)
+
+// LiveEvent is a change in an account, as sent over server-sent events
+type LiveEvent struct {
+	ID   int64
+	Type string
+	Data []byte // JSON
+}
+
+// LiveSubscription receives an account's events until Close is called. C is
+// closed if the subscriber falls too far behind.
+type LiveSubscription struct {
+	C <-chan LiveEvent
+	// Missed holds the events after the requested Last-Event-ID, oldest
+	// first
+	Missed []LiveEvent
+	// Resync is set when events after Last-Event-ID are no longer available,
+	// so the client should reload rather than apply updates
+	Resync bool
+
+	close func()
+}
+
+// Close stops the subscription
+func (s *LiveSubscription) Close() {
+	s.close()
+}
+
+// EventBroker fans out live events to the subscribers of each account. IDs
+// are numbered on from the start time in milliseconds, so an ID from before a
+// restart is recognised as too old to replay.
+type EventBroker struct {
+	mu      sync.Mutex
+	firstID int64
+	nextID  int64
+	subs    map[int64]map[chan LiveEvent]struct{}
+	recent  map[int64][]LiveEvent
+	// dropped is the ID of the newest event that has fallen out of recent
+	dropped map[int64]int64
+}
+
+// NewEventBroker creates an empty broker
+func NewEventBroker() *EventBroker {
+	start := time.Now().UnixMilli()
+	return &EventBroker{
+		firstID: start + 1,
+		nextID:  start,
+		subs:    map[int64]map[chan LiveEvent]struct{}{},
+		recent:  map[int64][]LiveEvent{},
+		dropped: map[int64]int64{},
+	}
+}
+
+// Subscribe starts receiving accountID's events. lastEventID is the last
+// event the client saw, or 0 for a new connection.
+func (b *EventBroker) Subscribe(accountID, lastEventID int64) *LiveSubscription {
+	ch := make(chan LiveEvent, liveEventBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &LiveSubscription{C: ch}
+	if lastEventID > 0 {
+		if lastEventID < b.firstID || lastEventID > b.nextID || lastEventID < b.dropped[accountID] {
+			// From another run of the server, or too long ago
+			sub.Resync = true
+		} else {
+			for _, e := range b.recent[accountID] {
+				if e.ID > lastEventID {
+					sub.Missed = append(sub.Missed, e)
+				}
+			}
+		}
+	}
+
+	if b.subs[accountID] == nil {
+		b.subs[accountID] = map[chan LiveEvent]struct{}{}
+	}
+	b.subs[accountID][ch] = struct{}{}
+	sub.close = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(accountID, ch)
+	}
+	return sub
+}
+
+// Publish sends an event to every subscriber of accountID
+func (b *EventBroker) Publish(accountID int64, eventType string, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Failed to encode live event", "account_id", accountID, "event", eventType, "err", err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event := LiveEvent{ID: b.nextID, Type: eventType, Data: body}
+
+	recent := append(b.recent[accountID], event)
+	if len(recent) > liveEventReplaySize {
+		b.dropped[accountID] = recent[len(recent)-liveEventReplaySize-1].ID
+		recent = recent[len(recent)-liveEventReplaySize:]
+	}
+	b.recent[accountID] = recent
+
+	for ch := range b.subs[accountID] {
+		select {
+		case ch <- event:
+		default:
+			b.remove(accountID, ch)
+		}
+	}
+}
+
+// Reset forgets every account's recent events and ends every subscription,
+// for when the data they describe has been replaced wholesale. Clients
+// reconnecting with an earlier Last-Event-ID are told to resync.
+func (b *EventBroker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for accountID, subs := range b.subs {
+		for ch := range subs {
+			b.remove(accountID, ch)
+		}
+	}
+	b.recent = map[int64][]LiveEvent{}
+	b.dropped = map[int64]int64{}
+	b.firstID = b.nextID + 1
+}
+
+// remove drops a subscriber; b.mu must be held
+func (b *EventBroker) remove(accountID int64, ch chan LiveEvent) {
+	if _, ok := b.subs[accountID][ch]; !ok {
+		return
+	}
+	delete(b.subs[accountID], ch)
+	if len(b.subs[accountID]) == 0 {
+		delete(b.subs, accountID)
+	}
+	close(ch)
+}
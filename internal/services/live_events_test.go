@@ -0,0 +1,120 @@
+package services
+
+import (
+	"testing"
+)
+
+func receive(t *testing.T, sub *LiveSubscription) LiveEvent {
+	t.Helper()
+	select {
+	case e, ok := <-sub.C:
+		if !ok {
+			t.Fatal("Subscription closed unexpectedly")
+		}
+		return e
+	default:
+		t.Fatal("Expected an event")
+	}
+	return LiveEvent{}
+}
+
+func TestEventBrokerPublish(t *testing.T) {
+	b := NewEventBroker()
+	mine := b.Subscribe(1, 0)
+	defer mine.Close()
+	other := b.Subscribe(2, 0)
+	defer other.Close()
+
+	b.Publish(1, LiveInjectionCreated, map[string]interface{}{"id": 7})
+
+	e := receive(t, mine)
+	if e.Type != LiveInjectionCreated || string(e.Data) != `{"id":7}` {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	select {
+	case e := <-other.C:
+		t.Errorf("Another account received %+v", e)
+	default:
+	}
+}
+
+func TestEventBrokerReplay(t *testing.T) {
+	b := NewEventBroker()
+	first := b.Subscribe(1, 0)
+	b.Publish(1, LiveInjectionCreated, nil)
+	seen := receive(t, first)
+	first.Close()
+
+	b.Publish(2, LiveMedicationLogged, nil)
+	b.Publish(1, LiveInventoryAdjusted, nil)
+	b.Publish(1, LiveMedicationLogged, nil)
+
+	again := b.Subscribe(1, seen.ID)
+	defer again.Close()
+	if again.Resync {
+		t.Fatal("Expected the missed events to be replayed")
+	}
+	if len(again.Missed) != 2 || again.Missed[0].Type != LiveInventoryAdjusted || again.Missed[1].Type != LiveMedicationLogged {
+		t.Errorf("Unexpected missed events %+v", again.Missed)
+	}
+}
+
+func TestEventBrokerResync(t *testing.T) {
+	b := NewEventBroker()
+	b.Publish(1, LiveInjectionCreated, nil)
+	sub := b.Subscribe(1, 0)
+	for i := 0; i < liveEventReplaySize+1; i++ {
+		b.Publish(1, LiveInventoryAdjusted, nil)
+		<-sub.C
+	}
+	sub.Close()
+
+	tooOld := b.Subscribe(1, b.firstID)
+	defer tooOld.Close()
+	if !tooOld.Resync {
+		t.Error("Expected a resync once the event has left the replay buffer")
+	}
+
+	restarted := b.Subscribe(1, 1)
+	defer restarted.Close()
+	if !restarted.Resync {
+		t.Error("Expected a resync for an ID from an earlier run")
+	}
+}
+
+func TestEventBrokerDropsSlowSubscriber(t *testing.T) {
+	b := NewEventBroker()
+	sub := b.Subscribe(1, 0)
+	for i := 0; i < liveEventBuffer+1; i++ {
+		b.Publish(1, LiveInventoryAdjusted, nil)
+	}
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n != liveEventBuffer {
+		t.Errorf("Expected %d buffered events before the close, got %d", liveEventBuffer, n)
+	}
+	// Closing after being dropped is harmless
+	sub.Close()
+}
+
+func TestEventBrokerReset(t *testing.T) {
+	b := NewEventBroker()
+	sub := b.Subscribe(1, 0)
+	b.Publish(1, LiveInjectionCreated, nil)
+	seen := receive(t, sub)
+
+	b.Reset()
+	if _, ok := <-sub.C; ok {
+		t.Error("Expected the subscription to end")
+	}
+	sub.Close()
+
+	again := b.Subscribe(1, seen.ID)
+	defer again.Close()
+	if !again.Resync || len(again.Missed) != 0 {
+		t.Errorf("Expected a resync after reset, got %+v", again)
+	}
+}
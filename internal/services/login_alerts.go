@@ -0,0 +1,224 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// Earlier sign-ins in the audit log are what a new one is compared with.
+// Audit log retention can shorten the history further.
+const (
+	loginHistoryDays  = 180
+	loginHistoryLimit = 500
+)
+
+// ImpossibleTravelWindow is how soon after a sign-in from one country a
+// sign-in from another is treated as impossible travel
+const ImpossibleTravelWindow = 2 * time.Hour
+
+// LoginAnomaly is what was unusual about a successful sign-in, judged
+// against the user's earlier ones
+type LoginAnomaly struct {
+	NewIP            bool
+	NewDevice        bool
+	ImpossibleTravel bool
+	// PreviousCountry is where the sign-in before it came from, when it
+	// is ImpossibleTravel
+	PreviousCountry string
+}
+
+// ShouldAlert reports whether the user should be told about the sign-in. A
+// new address alone isn't enough, as home connections change address
+// often; it is still recorded.
+func (a LoginAnomaly) ShouldAlert() bool {
+	return a.NewDevice || a.ImpossibleTravel
+}
+
+// Details are the audit log details recording the anomaly
+func (a LoginAnomaly) Details() map[string]interface{} {
+	details := map[string]interface{}{}
+	if a.NewIP {
+		details["new_ip"] = true
+	}
+	if a.NewDevice {
+		details["new_device"] = true
+	}
+	if a.ImpossibleTravel {
+		details["impossible_travel"] = true
+		details["previous_country"] = a.PreviousCountry
+	}
+	return details
+}
+
+// LoginDetails are the details of a login_success audit log entry
+type LoginDetails struct {
+	Country          string `json:"country,omitempty"`
+	NewIP            bool   `json:"new_ip,omitempty"`
+	NewDevice        bool   `json:"new_device,omitempty"`
+	ImpossibleTravel bool   `json:"impossible_travel,omitempty"`
+	PreviousCountry  string `json:"previous_country,omitempty"`
+	Reason           string `json:"reason,omitempty"` // Why a login_failed failed
+}
+
+// ParseLoginDetails reads the details of a login audit log entry. Entries
+// with none, or that can't be read, give zero details.
+func ParseLoginDetails(l *models.AuditLog) LoginDetails {
+	var d LoginDetails
+	if l.Details.Valid {
+		_ = json.Unmarshal([]byte(l.Details.String), &d)
+	}
+	return d
+}
+
+// DetectLoginAnomaly compares a sign-in with the user's earlier successful
+// ones: whether the address or device is new, and whether the last sign-in
+// was from another country within ImpossibleTravelWindow. country is only
+// known behind a proxy that looks it up, so without one impossible travel
+// is never detected. A user's first sign-in is never unusual.
+//
+// Call it before the sign-in itself is logged.
+func DetectLoginAnomaly(db *database.DB, userID int64, ipAddress, userAgent, country string, now time.Time) (LoginAnomaly, error) {
+	var a LoginAnomaly
+	logins, err := repository.NewAuditRepository(db).GetLogins(userID, now.AddDate(0, 0, -loginHistoryDays), loginHistoryLimit)
+	if err != nil {
+		return a, err
+	}
+
+	device := DeviceName(userAgent)
+	seenIP, seenDevice, previous := false, false, false
+	for _, l := range logins {
+		if l.Action != "login_success" {
+			continue
+		}
+		if !previous {
+			// The most recent one, as logins are newest first
+			previous = true
+			prevCountry := ParseLoginDetails(l).Country
+			if country != "" && prevCountry != "" && prevCountry != country && now.Sub(l.Timestamp) < ImpossibleTravelWindow {
+				a.ImpossibleTravel = true
+				a.PreviousCountry = prevCountry
+			}
+		}
+		if l.IPAddress.String == ipAddress {
+			seenIP = true
+		}
+		if DeviceName(l.UserAgent.String) == device {
+			seenDevice = true
+		}
+	}
+	if !previous {
+		return a, nil
+	}
+	a.NewIP = !seenIP
+	a.NewDevice = !seenDevice
+	return a, nil
+}
+
+// DeviceName describes the browser and operating system in a User-Agent,
+// such as "Firefox on Windows". Version numbers are left out, so updating
+// a browser doesn't make it a new device.
+func DeviceName(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	var os string
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		os = "iOS"
+	case strings.Contains(userAgent, "Android"):
+		os = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		os = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"), strings.Contains(userAgent, "Macintosh"):
+		os = "macOS"
+	case strings.Contains(userAgent, "CrOS"):
+		os = "ChromeOS"
+	case strings.Contains(userAgent, "Linux"):
+		os = "Linux"
+	}
+
+	var browser string
+	switch {
+	case strings.Contains(userAgent, "Edg/"), strings.Contains(userAgent, "EdgiOS/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"), strings.Contains(userAgent, "FxiOS/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"), strings.Contains(userAgent, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	case os == "":
+		// API clients such as curl/8.5.0 or python-requests/2.31
+		name, _, _ := strings.Cut(userAgent, "/")
+		return strings.TrimSpace(name)
+	default:
+		browser = "Browser"
+	}
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
+
+// SendLoginAlert tells a user about an unusual sign-in to their account,
+// in the app and by email. Email goes to any user with an address while
+// SMTP is set up, whatever their notification settings, as it may be the
+// only warning they get that someone else has their password.
+func SendLoginAlert(db *database.DB, userID int64, a LoginAnomaly, ipAddress, userAgent, country string) error {
+	var email, locale string
+	err := db.QueryRow(`
+		SELECT COALESCE(u.email, ''), COALESCE(p.locale, '')
+		FROM users u
+		LEFT JOIN user_preferences p ON p.user_id = u.id
+		WHERE u.id = ?
+	`, userID).Scan(&email, &locale)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	p := i18n.For(locale)
+	from := ipAddress
+	if country != "" {
+		from += " (" + country + ")"
+	}
+	var title, message string
+	if a.ImpossibleTravel {
+		title = p.T("Unusual sign-in")
+		message = p.T("Your account was signed in to on %s from %s, soon after a sign-in from %s. If this wasn't you, sign that device out under Settings > Sign-in Activity and change your password.",
+			DeviceName(userAgent), from, a.PreviousCountry)
+	} else {
+		title = p.T("New device signed in")
+		message = p.T("Your account was signed in to on %s from %s. If this wasn't you, sign that device out under Settings > Sign-in Activity and change your password.",
+			DeviceName(userAgent), from)
+	}
+
+	err = repository.NewNotificationRepository(db).Create(&models.Notification{
+		UserID:  sql.NullInt64{Int64: userID, Valid: true},
+		Type:    "system",
+		Title:   title,
+		Message: message,
+	})
+	if err != nil {
+		return err
+	}
+
+	if email == "" {
+		return nil
+	}
+	smtpCfg := LoadSMTPConfig(db)
+	if !smtpCfg.IsConfigured() {
+		return nil
+	}
+	return SendEmail(smtpCfg, email, title, message)
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/repository"
+)
+
+const (
+	firefoxWindows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	safariIPhone   = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1"
+)
+
+func TestDeviceName(t *testing.T) {
+	tests := map[string]string{
+		firefoxWindows: "Firefox on Windows",
+		safariIPhone:   "Safari on iOS",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36":                         "Chrome on Linux",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0": "Edge on Windows",
+		"curl/8.5.0": "curl",
+		"":           "Unknown device",
+	}
+	for userAgent, want := range tests {
+		if got := DeviceName(userAgent); got != want {
+			t.Errorf("DeviceName(%q) = %q, want %q", userAgent, got, want)
+		}
+	}
+}
+
+func TestDetectLoginAnomaly(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO users (id, username, password_hash, email) VALUES (1, 'owner', 'hash', 'owner@example.com')`); err != nil {
+		t.Fatalf("Failed to seed user: %v", err)
+	}
+
+	now := time.Now()
+	audit := repository.NewAuditRepository(db)
+	user := sql.NullInt64{Int64: 1, Valid: true}
+
+	// The first sign-in has nothing to compare with
+	a, err := DetectLoginAnomaly(db, 1, "10.0.0.1", firefoxWindows, "NZ", now)
+	if err != nil || a.NewIP || a.NewDevice || a.ShouldAlert() {
+		t.Fatalf("Expected nothing unusual about the first sign-in, got %+v, %v", a, err)
+	}
+	_ = audit.LogWithDetails(user, "login_success", "user", user, map[string]interface{}{"country": "NZ"}, "10.0.0.1", firefoxWindows)
+	_ = audit.LogWithDetails(user, "login_failed", "user", user, nil, "10.0.0.9", safariIPhone)
+
+	// A browser update on a new address isn't a new device
+	a, err = DetectLoginAnomaly(db, 1, "10.0.0.2", firefoxWindows+"1", "NZ", now)
+	if err != nil || !a.NewIP || a.NewDevice || a.ShouldAlert() {
+		t.Errorf("Expected only a new address, got %+v, %v", a, err)
+	}
+
+	// A failed attempt from a device doesn't make it known
+	a, err = DetectLoginAnomaly(db, 1, "10.0.0.1", safariIPhone, "NZ", now)
+	if err != nil || a.NewIP || !a.NewDevice || !a.ShouldAlert() {
+		t.Errorf("Expected a new device, got %+v, %v", a, err)
+	}
+
+	a, err = DetectLoginAnomaly(db, 1, "10.0.0.1", firefoxWindows, "FR", now)
+	if err != nil || !a.ImpossibleTravel || a.PreviousCountry != "NZ" {
+		t.Errorf("Expected impossible travel from NZ, got %+v, %v", a, err)
+	}
+	a, err = DetectLoginAnomaly(db, 1, "10.0.0.1", firefoxWindows, "FR", now.Add(ImpossibleTravelWindow+time.Minute))
+	if err != nil || a.ImpossibleTravel {
+		t.Errorf("Expected no impossible travel after the window, got %+v, %v", a, err)
+	}
+
+	if err := SendLoginAlert(db, 1, LoginAnomaly{NewDevice: true}, "10.0.0.1", safariIPhone, "NZ"); err != nil {
+		t.Fatalf("SendLoginAlert failed: %v", err)
+	}
+	var title, message string
+	if err := db.QueryRow(`SELECT title, message FROM notifications WHERE user_id = 1`).Scan(&title, &message); err != nil {
+		t.Fatalf("Expected a notification: %v", err)
+	}
+	if title != "New device signed in" || message == "" {
+		t.Errorf("Unexpected notification %q: %q", title, message)
+	}
+}
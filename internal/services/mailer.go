@@ -0,0 +1,196 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	netsmtp "net/smtp"
+	"net/textproto"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/repository"
+)
+
+// SMTPConfig holds everything needed to send an email, including the password
+type SMTPConfig struct {
+	Host      string
+	Port      int
+	Username  string
+	Password  string
+	FromName  string
+	FromEmail string
+	Enabled   bool
+}
+
+// IsConfigured reports whether the SMTP settings are complete enough to send mail
+func (c SMTPConfig) IsConfigured() bool {
+	return c.Enabled && c.Host != "" && c.Port > 0 && c.FromEmail != ""
+}
+
+// LoadSMTPConfig reads the admin SMTP settings from the site settings
+func LoadSMTPConfig(db *database.DB) SMTPConfig {
+	cfg := SMTPConfig{}
+	settings := repository.NewSettingsRepository(db)
+	if value, err := settings.GetSite("smtp_host"); err == nil {
+		cfg.Host = value
+	}
+	if value, err := settings.GetSite("smtp_port"); err == nil {
+		_, _ = fmt.Sscanf(value, "%d", &cfg.Port)
+	}
+	if value, err := settings.GetSite("smtp_username"); err == nil {
+		cfg.Username = value
+	}
+	if value, err := settings.GetSite("smtp_password"); err == nil {
+		cfg.Password = value
+	}
+	if value, err := settings.GetSite("smtp_from_name"); err == nil {
+		cfg.FromName = value
+	}
+	if value, err := settings.GetSite("smtp_from_email"); err == nil {
+		cfg.FromEmail = value
+	}
+	if value, err := settings.GetSite("smtp_enabled"); err == nil {
+		cfg.Enabled = value == "true"
+	}
+
+	return cfg
+}
+
+// EmailAttachment is a file sent along with an email
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// SendEmail sends a plain-text email using the provided SMTP settings
+func SendEmail(cfg SMTPConfig, toEmail, subject, body string) error {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		fromHeader(cfg), toEmail, subject, body)
+
+	return sendMessage(cfg, toEmail, msg)
+}
+
+// SendEmailWithAttachments sends a plain-text email with files attached
+func SendEmailWithAttachments(cfg SMTPConfig, toEmail, subject, body string, attachments ...EmailAttachment) error {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		fromHeader(cfg), toEmail, subject, mw.Boundary())
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+	if _, err := text.Write([]byte(body)); err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+
+	for _, a := range attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to build message: %w", err)
+		}
+
+		// Base64 lines must stay under the 998 character SMTP limit
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+
+	if err := mw.Close(); err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+
+	return sendMessage(cfg, toEmail, buf.String())
+}
+
+// fromHeader formats the From header, including the sender name if set
+func fromHeader(cfg SMTPConfig) string {
+	if cfg.FromName != "" {
+		return fmt.Sprintf("%s <%s>", cfg.FromName, cfg.FromEmail)
+	}
+	return cfg.FromEmail
+}
+
+// sendMessage delivers a complete message, headers included
+func sendMessage(cfg SMTPConfig, toEmail, msg string) error {
+	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+
+	// Use TLS for port 465, STARTTLS for other ports
+	if cfg.Port == 465 {
+		// Direct TLS connection
+		tlsConfig := &tls.Config{
+			ServerName: cfg.Host,
+		}
+
+		conn, err := tls.Dial("tcp", addr, tlsConfig)
+		if err != nil {
+			return fmt.Errorf("TLS connection failed: %w", err)
+		}
+		defer conn.Close()
+
+		client, err := netsmtp.NewClient(conn, cfg.Host)
+		if err != nil {
+			return fmt.Errorf("SMTP client creation failed: %w", err)
+		}
+		defer client.Close()
+
+		// Auth if credentials provided
+		if cfg.Username != "" && cfg.Password != "" {
+			auth := netsmtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+			if err := client.Auth(auth); err != nil {
+				return fmt.Errorf("authentication failed: %w", err)
+			}
+		}
+
+		if err := client.Mail(cfg.FromEmail); err != nil {
+			return fmt.Errorf("MAIL FROM failed: %w", err)
+		}
+		if err := client.Rcpt(toEmail); err != nil {
+			return fmt.Errorf("RCPT TO failed: %w", err)
+		}
+
+		wc, err := client.Data()
+		if err != nil {
+			return fmt.Errorf("DATA failed: %w", err)
+		}
+		_, err = wc.Write([]byte(msg))
+		wc.Close()
+		if err != nil {
+			return fmt.Errorf("write message failed: %w", err)
+		}
+
+		return client.Quit()
+	}
+
+	// Standard SMTP with optional STARTTLS
+	var auth netsmtp.Auth
+	if cfg.Username != "" && cfg.Password != "" {
+		auth = netsmtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	err := netsmtp.SendMail(addr, auth, cfg.FromEmail, []string{toEmail}, []byte(msg))
+	if err != nil {
+		return fmt.Errorf("send mail failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// medicationReminderInterval is how often doses are checked for reminders.
+// It is shorter than the check-in interval so short snoozes come round
+// close to on time.
+const medicationReminderInterval = 5 * time.Minute
+
+// medicationReminderMember is an account member reminded of its doses
+type medicationReminderMember struct {
+	UserID   int64
+	Owner    bool
+	Location *time.Location
+	Printer  *i18n.Printer // In the member's language
+}
+
+// SendMedicationReminders reminds the members of each account of doses of
+// its medications with reminders enabled as they fall due, working out due
+// times in each member's timezone. A snoozed dose is reminded of again when
+// its snooze ends, as long as it is still within its window and unlogged.
+// Each dose, and each snooze of it, is reminded of once.
+func SendMedicationReminders(db *database.DB, now time.Time) (int, error) {
+	rows, err := db.Query(`
+		SELECT m.id, m.account_id
+		FROM medications m
+		WHERE m.reminder_enabled = TRUE AND m.is_active = TRUE
+		AND m.dose_times IS NOT NULL AND m.dose_times <> '' AND m.account_id IS NOT NULL
+		AND ` + repository.AccountNotSuspended("m.account_id") + `
+		ORDER BY m.id
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query medication reminders: %w", err)
+	}
+	type reminded struct{ medicationID, accountID int64 }
+	var medications []reminded
+	for rows.Next() {
+		var m reminded
+		if err := rows.Scan(&m.medicationID, &m.accountID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan medication reminder: %w", err)
+		}
+		medications = append(medications, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query medication reminders: %w", err)
+	}
+
+	medicationRepo := repository.NewMedicationRepository(db)
+	notifications := repository.NewNotificationRepository(db)
+	members := make(map[int64][]medicationReminderMember)
+	sent := 0
+	for _, m := range medications {
+		med, err := medicationRepo.GetByID(m.medicationID, m.accountID)
+		if err != nil {
+			slog.Error("Failed to load medication for reminders", "medication_id", m.medicationID, "err", err)
+			continue
+		}
+		if _, ok := members[m.accountID]; !ok {
+			if members[m.accountID], err = medicationReminderMembers(db, m.accountID); err != nil {
+				slog.Error("Failed to load account members for reminders", "account_id", m.accountID, "err", err)
+				continue
+			}
+		}
+
+		window := DoseWindow(med)
+		from, until := now.Add(-window), now.Add(window)
+		logs, err := medicationRepo.ListLogsBetween(med.ID, from.Add(-24*time.Hour).UTC(), until.Add(24*time.Hour).UTC())
+		if err != nil {
+			slog.Error("Failed to load medication logs for reminders", "medication_id", med.ID, "err", err)
+			continue
+		}
+		snoozes, err := medicationRepo.ListSnoozesBetween(med.ID, from.UTC(), until.UTC())
+		if err != nil {
+			slog.Error("Failed to load dose snoozes for reminders", "medication_id", med.ID, "err", err)
+			continue
+		}
+
+		for _, member := range members[m.accountID] {
+			doses := MedicationDoses(NewMedicationSchedule(med, member.Location), window, logs, from, until, now)
+			ApplySnoozes(doses, snoozes)
+			for _, d := range doses {
+				if d.Status != DoseDue || now.Before(d.Due) || now.Before(d.SnoozedUntil) {
+					continue
+				}
+				ok, err := sendDoseReminder(notifications, member, med, d)
+				if err != nil {
+					slog.Error("Failed to send medication reminder", "medication_id", med.ID, "user_id", member.UserID, "err", err)
+					continue
+				}
+				if ok {
+					sent++
+				}
+			}
+		}
+	}
+	return sent, nil
+}
+
+// sendDoseReminder reminds a member of a due dose unless they've already
+// been reminded of it, or of this snooze of it. The message, with the dose
+// and snooze times, doubles as the dedupe key.
+func sendDoseReminder(notifications *repository.NotificationRepository, member medicationReminderMember, med *models.Medication, d MedicationDose) (bool, error) {
+	p := member.Printer
+	due := d.Due.In(member.Location)
+	message := p.T("Time for your %s dose of %s on %s.", due.Format("15:04"), med.Name, p.MonthDay(due))
+	if !d.SnoozedUntil.IsZero() {
+		message = p.T("Time for your %s dose of %s on %s, snoozed until %s.", due.Format("15:04"), med.Name, p.MonthDay(due), d.SnoozedUntil.In(member.Location).Format("15:04"))
+	}
+
+	userID := sql.NullInt64{Int64: member.UserID, Valid: true}
+	exists, err := notifications.RecentlyNotified(userID, "system", message, 24)
+	if err != nil || exists {
+		return false, err
+	}
+	err = notifications.Create(&models.Notification{
+		UserID:  userID,
+		Type:    "system",
+		Title:   p.T("Medication reminder"),
+		Message: message,
+	})
+	return err == nil, err
+}
+
+// medicationReminderMembers lists an account's active members with their
+// timezones
+func medicationReminderMembers(db *database.DB, accountID int64) ([]medicationReminderMember, error) {
+	rows, err := db.Query(`
+		SELECT am.user_id, am.role = 'owner', COALESCE(p.timezone, ''), COALESCE(p.locale, '')
+		FROM account_members am
+		JOIN users u ON u.id = am.user_id
+		LEFT JOIN user_preferences p ON p.user_id = am.user_id
+		WHERE am.account_id = ? AND u.is_active = TRUE
+		ORDER BY am.user_id
+	`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []medicationReminderMember{}
+	for rows.Next() {
+		var m medicationReminderMember
+		var timezone, locale string
+		if err := rows.Scan(&m.UserID, &m.Owner, &timezone, &locale); err != nil {
+			return nil, fmt.Errorf("failed to scan account member: %w", err)
+		}
+		if m.Location, err = time.LoadLocation(timezone); err != nil || timezone == "" {
+			m.Location, _ = time.LoadLocation("America/New_York")
+		}
+		m.Printer = i18n.For(locale)
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// StartMedicationReminderScheduler sends medication dose reminders as doses
+// fall due and snoozes run out
+func StartMedicationReminderScheduler(db *database.DB) {
+	run := func() {
+		sent, err := SendMedicationReminders(db, time.Now())
+		RecordSchedulerRun("medication_reminders", err)
+		if err != nil {
+			slog.Error("Sending medication reminders failed", "err", err)
+		} else if sent > 0 {
+			slog.Info("Sent medication reminders", "count", sent)
+		}
+	}
+
+	RegisterScheduler("medication_reminders", medicationReminderInterval)
+	RunBackground(func() {
+		// Wait for server to fully start
+		if !SleepUnlessShutdown(time.Minute) {
+			return
+		}
+		run()
+
+		ticker := time.NewTicker(medicationReminderInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-shutdownChan:
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+func TestSendMedicationReminders(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash'), (2, 'partner', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner'), (1, 2, 'member');
+		INSERT INTO user_preferences (user_id, timezone) VALUES (1, 'UTC'), (2, 'UTC');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+
+	repo := repository.NewMedicationRepository(db)
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	medication := &models.Medication{
+		Name:              "Estradiol Oral Tablet",
+		IsActive:          true,
+		StartDate:         sql.NullTime{Time: start, Valid: true},
+		DoseTimes:         []string{"08:00", "20:00"},
+		TimeWindowMinutes: sql.NullInt64{Int64: 60, Valid: true},
+		ReminderEnabled:   true,
+		AccountID:         1,
+	}
+	if err := repo.Create(medication); err != nil {
+		t.Fatalf("Failed to create medication: %v", err)
+	}
+	quiet := &models.Medication{Name: "Folic Acid Oral Tablet", IsActive: true, DoseTimes: []string{"08:00"}, AccountID: 1}
+	if err := repo.Create(quiet); err != nil {
+		t.Fatalf("Failed to create medication: %v", err)
+	}
+
+	due := time.Date(2026, 3, 20, 8, 0, 0, 0, time.UTC)
+	count := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE title = 'Medication reminder'`).Scan(&n); err != nil {
+			t.Fatalf("Failed to count reminders: %v", err)
+		}
+		return n
+	}
+
+	// Within the window but not yet due
+	if sent, err := SendMedicationReminders(db, due.Add(-30*time.Minute)); err != nil || sent != 0 {
+		t.Fatalf("Expected no reminders before 08:00, got %d, %v", sent, err)
+	}
+
+	// Both members are reminded once, and only of the medication with reminders on
+	if sent, err := SendMedicationReminders(db, due.Add(5*time.Minute)); err != nil || sent != 2 {
+		t.Fatalf("Expected a reminder for each member, got %d, %v", sent, err)
+	}
+	if sent, err := SendMedicationReminders(db, due.Add(10*time.Minute)); err != nil || sent != 0 {
+		t.Errorf("Expected no repeat reminders, got %d, %v", sent, err)
+	}
+
+	// A snoozed dose is reminded of again once the snooze ends
+	if _, err := repo.SnoozeDose(medication.ID, due, due.Add(25*time.Minute), 1); err != nil {
+		t.Fatalf("Failed to snooze dose: %v", err)
+	}
+	if sent, err := SendMedicationReminders(db, due.Add(20*time.Minute)); err != nil || sent != 0 {
+		t.Errorf("Expected no reminders while snoozed, got %d, %v", sent, err)
+	}
+	if sent, err := SendMedicationReminders(db, due.Add(25*time.Minute)); err != nil || sent != 2 {
+		t.Errorf("Expected the snoozed reminder, got %d, %v", sent, err)
+	}
+
+	// Once the dose is logged there's nothing more to remind of
+	if _, err := repo.SnoozeDose(medication.ID, due, due.Add(40*time.Minute), 1); err != nil {
+		t.Fatalf("Failed to snooze dose: %v", err)
+	}
+	log := &models.MedicationLog{MedicationID: medication.ID, Timestamp: due.Add(35 * time.Minute), Taken: true, ScheduledFor: sql.NullTime{Time: due, Valid: true}}
+	if err := repo.CreateLog(log); err != nil {
+		t.Fatalf("Failed to log dose: %v", err)
+	}
+	if sent, err := SendMedicationReminders(db, due.Add(45*time.Minute)); err != nil || sent != 0 {
+		t.Errorf("Expected no reminders for a logged dose, got %d, %v", sent, err)
+	}
+	if n := count(); n != 4 {
+		t.Errorf("Expected 4 reminders in all, got %d", n)
+	}
+}
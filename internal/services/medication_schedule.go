@@ -0,0 +1,375 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+// MaxDoseTimes caps how many doses a medication can be due each day
+const MaxDoseTimes = 12
+
+// DefaultDoseWindow is how long either side of a dose's time it counts as
+// due when the medication sets no time window of its own
+const DefaultDoseWindow = 2 * time.Hour
+
+// doseMatchDistance is the furthest a log without scheduled_for can be from
+// the dose it is matched to
+const doseMatchDistance = 12 * time.Hour
+
+// MaxSnoozeMinutes caps how far one snooze can push a dose's reminder back
+const MaxSnoozeMinutes = 240
+
+// Dose statuses
+const (
+	DoseTaken    = "taken"    // Taken within its window
+	DoseLate     = "late"     // Taken, but logged as late or after its window closed
+	DoseMissed   = "missed"   // Logged as missed, or its window passed unlogged
+	DoseDue      = "due"      // Within its window and not logged yet
+	DoseUpcoming = "upcoming" // Its window hasn't opened
+)
+
+// NormalizeDoseTimes checks a medication's HH:MM dose times and returns them
+// sorted, without duplicates
+func NormalizeDoseTimes(times []string) ([]string, error) {
+	seen := make(map[string]bool, len(times))
+	var normalized []string
+	for _, t := range times {
+		t = strings.TrimSpace(t)
+		hm, err := time.Parse("15:04", t)
+		if err != nil || len(t) != 5 {
+			return nil, fmt.Errorf("%q is not an HH:MM time", t)
+		}
+		t = hm.Format("15:04")
+		if !seen[t] {
+			seen[t] = true
+			normalized = append(normalized, t)
+		}
+	}
+	if len(normalized) > MaxDoseTimes {
+		return nil, fmt.Errorf("at most %d dose times a day", MaxDoseTimes)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// ParseMedicationFrequency reads a medication's frequency the way
+// MedicationRRule does, as the days between the days doses are due, or for
+// "Every N hours" the hours between doses with days 0
+func ParseMedicationFrequency(frequency string) (days, hours int) {
+	f := strings.ToLower(strings.Join(strings.Fields(frequency), " "))
+	switch {
+	case dailyPhrases[f]:
+		return 1, 0
+	case weeklyPhrases[f]:
+		return 7, 0
+	case f == "every other day":
+		return 2, 0
+	}
+	if m := everyNPattern.FindStringSubmatch(f); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err == nil && n > 0 {
+			switch m[2] {
+			case "hour":
+				return 0, n
+			case "week":
+				return 7 * n, 0
+			}
+			return n, 0
+		}
+	}
+	return 1, 0
+}
+
+// MedicationSchedule describes when a medication's doses fall due
+type MedicationSchedule struct {
+	Times    []string // HH:MM doses are due on each scheduled day
+	Days     int      // Days between scheduled days, counted from Start
+	Hours    int      // Set instead of Days for doses every N hours from the first time on Start
+	Start    time.Time
+	End      time.Time // Last day doses are due; zero if ongoing
+	Location *time.Location
+}
+
+// NewMedicationSchedule builds a medication's schedule in loc. Doses start
+// on its start date, or the day it was added.
+func NewMedicationSchedule(med *models.Medication, loc *time.Location) MedicationSchedule {
+	s := MedicationSchedule{Times: med.DoseTimes, Location: loc}
+	s.Days, s.Hours = ParseMedicationFrequency(med.Frequency.String)
+
+	// Dates are stored as midnight UTC, so their fields are the calendar day
+	start := med.CreatedAt.In(loc)
+	if med.StartDate.Valid {
+		start = med.StartDate.Time
+	}
+	s.Start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	if med.EndDate.Valid {
+		end := med.EndDate.Time
+		s.End = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc)
+	}
+	return s
+}
+
+// civilDay numbers calendar days so they can be counted between
+func civilDay(t time.Time) int64 {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+}
+
+// DueTimes lists the times doses fall due in [from, until)
+func (s MedicationSchedule) DueTimes(from, until time.Time) []time.Time {
+	if len(s.Times) == 0 || !from.Before(until) {
+		return nil
+	}
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	var endLimit time.Time
+	if !s.End.IsZero() {
+		endLimit = time.Date(s.End.Year(), s.End.Month(), s.End.Day()+1, 0, 0, 0, 0, loc)
+		if endLimit.Before(until) {
+			until = endLimit
+		}
+	}
+
+	var times []time.Time
+	if s.Hours > 0 {
+		step := time.Duration(s.Hours) * time.Hour
+		next := AtClock(s.Start, s.Times[0], loc)
+		if gap := from.Sub(next); gap > 0 {
+			next = next.Add(gap / step * step)
+		}
+		for ; next.Before(until); next = next.Add(step) {
+			if !next.Before(from) {
+				times = append(times, next)
+			}
+		}
+		return times
+	}
+
+	days := int64(s.Days)
+	if days < 1 {
+		days = 1
+	}
+	startDay := civilDay(s.Start)
+	first := from.In(loc)
+	// Days are stepped through at noon, clear of DST changes, and run until
+	// one starts at or after until
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 12, 0, 0, 0, loc); ; day = day.AddDate(0, 0, 1) {
+		if !time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc).Before(until) {
+			break
+		}
+		n := civilDay(day) - startDay
+		if n < 0 || n%days != 0 {
+			continue
+		}
+		for _, clock := range s.Times {
+			at := AtClock(day, clock, loc)
+			if !at.Before(from) && at.Before(until) {
+				times = append(times, at)
+			}
+		}
+	}
+	return times
+}
+
+// DosesPerDay is how many doses fall due a day on average. A schedule
+// without dose times counts as one dose on each scheduled day.
+func (s MedicationSchedule) DosesPerDay() float64 {
+	if s.Hours > 0 {
+		return 24 / float64(s.Hours)
+	}
+	return float64(max(len(s.Times), 1)) / float64(max(s.Days, 1))
+}
+
+// DoseWindow is how long either side of its time a medication's dose counts
+// as due
+func DoseWindow(med *models.Medication) time.Duration {
+	if med.TimeWindowMinutes.Valid && med.TimeWindowMinutes.Int64 > 0 {
+		return time.Duration(med.TimeWindowMinutes.Int64) * time.Minute
+	}
+	return DefaultDoseWindow
+}
+
+// MedicationDose is one expected dose and what became of it
+type MedicationDose struct {
+	Due          time.Time
+	Status       string
+	Log          *models.MedicationLog // The log recorded for it, nil if none
+	SnoozedUntil time.Time             // When its snoozed reminder is due; zero if not snoozed
+}
+
+// MedicationDoses lays out the doses due in [from, until) with the log
+// recorded for each. A log is for the dose its scheduled_for names; older
+// logs are matched to the nearest dose not already logged. Where a dose has
+// several logs the latest counts. A dose taken after its window closed is
+// late even if its log doesn't say so. Unlogged doses are upcoming, due or
+// missed depending on where now falls against their window.
+func MedicationDoses(s MedicationSchedule, window time.Duration, logs []*models.MedicationLog, from, until, now time.Time) []MedicationDose {
+	due := s.DueTimes(from, until)
+	doses := make([]MedicationDose, len(due))
+	for i, t := range due {
+		doses[i].Due = t
+	}
+
+	sorted := make([]*models.MedicationLog, len(logs))
+	copy(sorted, logs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	var unmatched []*models.MedicationLog
+	for _, log := range sorted {
+		if !log.ScheduledFor.Valid {
+			unmatched = append(unmatched, log)
+			continue
+		}
+		if i := doseAt(doses, log.ScheduledFor.Time); i >= 0 {
+			doses[i].Log = log
+		} else {
+			unmatched = append(unmatched, log)
+		}
+	}
+	for _, log := range unmatched {
+		at := log.Timestamp
+		if log.ScheduledFor.Valid {
+			at = log.ScheduledFor.Time
+		}
+		if i := nearestDose(doses, at, true); i >= 0 {
+			doses[i].Log = log
+		}
+	}
+
+	for i := range doses {
+		d := &doses[i]
+		switch {
+		case d.Log != nil && d.Log.Taken && (d.Log.Late || d.Log.Timestamp.After(d.Due.Add(window))):
+			d.Status = DoseLate
+		case d.Log != nil && d.Log.Taken:
+			d.Status = DoseTaken
+		case d.Log != nil:
+			d.Status = DoseMissed
+		case now.Before(d.Due.Add(-window)):
+			d.Status = DoseUpcoming
+		case now.Before(d.Due.Add(window)):
+			d.Status = DoseDue
+		default:
+			d.Status = DoseMissed
+		}
+	}
+	return doses
+}
+
+// ApplySnoozes records each dose's snooze against it
+func ApplySnoozes(doses []MedicationDose, snoozes []*models.MedicationDoseSnooze) {
+	for _, snooze := range snoozes {
+		if i := doseAt(doses, snooze.ScheduledFor); i >= 0 {
+			doses[i].SnoozedUntil = snooze.SnoozedUntil
+		}
+	}
+}
+
+// SnoozeUntil works out when a dose's reminder comes round again if
+// snoozed for minutes at now. Only a due dose can be snoozed, and never
+// past the end of its window.
+func SnoozeUntil(d MedicationDose, window time.Duration, minutes int, now time.Time) (time.Time, error) {
+	if minutes < 1 || minutes > MaxSnoozeMinutes {
+		return time.Time{}, fmt.Errorf("minutes must be between 1 and %d", MaxSnoozeMinutes)
+	}
+	if d.Status != DoseDue {
+		return time.Time{}, fmt.Errorf("the dose is %s, only a due dose can be snoozed", d.Status)
+	}
+	until := now.Add(time.Duration(minutes) * time.Minute)
+	if closes := d.Due.Add(window); until.After(closes) {
+		until = closes
+	}
+	return until, nil
+}
+
+// DoseFor picks the dose a log made at the given time is for: the nearest
+// one not logged yet, else the nearest one
+func DoseFor(doses []MedicationDose, at time.Time) (time.Time, error) {
+	i := nearestDose(doses, at, true)
+	if i < 0 {
+		i = nearestDose(doses, at, false)
+	}
+	if i < 0 {
+		return time.Time{}, errors.New("no dose is due near that time")
+	}
+	return doses[i].Due, nil
+}
+
+// doseAt finds the dose due at t, or -1
+func doseAt(doses []MedicationDose, t time.Time) int {
+	for i, d := range doses {
+		if d.Due.Equal(t) {
+			return i
+		}
+	}
+	return -1
+}
+
+// nearestDose finds the dose nearest at within doseMatchDistance, only
+// considering unlogged ones if unlogged is set, or -1
+func nearestDose(doses []MedicationDose, at time.Time, unlogged bool) int {
+	best := -1
+	var bestDistance time.Duration
+	for i, d := range doses {
+		if unlogged && d.Log != nil {
+			continue
+		}
+		distance := d.Due.Sub(at)
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance <= doseMatchDistance && (best < 0 || distance < bestDistance) {
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
+
+// DoseAdherence counts doses by status
+type DoseAdherence struct {
+	Expected int
+	Taken    int // On time or late
+	Late     int
+	Missed   int
+	Pending  int // Upcoming or due
+}
+
+// Add counts a dose
+func (a *DoseAdherence) Add(d MedicationDose) {
+	a.Expected++
+	switch d.Status {
+	case DoseTaken:
+		a.Taken++
+	case DoseLate:
+		a.Taken++
+		a.Late++
+	case DoseMissed:
+		a.Missed++
+	default:
+		a.Pending++
+	}
+}
+
+// Rate is the percentage of settled doses that were taken, or 0 if none are
+func (a DoseAdherence) Rate() float64 {
+	if a.Taken+a.Missed == 0 {
+		return 0
+	}
+	return float64(a.Taken) / float64(a.Taken+a.Missed) * 100
+}
+
+// OnTimeRate is the percentage of settled doses that were taken on time,
+// or 0 if none are
+func (a DoseAdherence) OnTimeRate() float64 {
+	if a.Taken+a.Missed == 0 {
+		return 0
+	}
+	return float64(a.Taken-a.Late) / float64(a.Taken+a.Missed) * 100
+}
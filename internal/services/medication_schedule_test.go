@@ -0,0 +1,265 @@
+package services
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestNormalizeDoseTimes(t *testing.T) {
+	got, err := NormalizeDoseTimes([]string{"20:00", " 08:00", "20:00", "13:30"})
+	if err != nil || !reflect.DeepEqual(got, []string{"08:00", "13:30", "20:00"}) {
+		t.Errorf("Expected sorted unique times, got %v (%v)", got, err)
+	}
+	if got, err := NormalizeDoseTimes(nil); err != nil || got != nil {
+		t.Errorf("Expected no times for none given, got %v (%v)", got, err)
+	}
+	for _, bad := range []string{"8:00", "24:00", "08:00pm", ""} {
+		if _, err := NormalizeDoseTimes([]string{bad}); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+
+	many := make([]string, MaxDoseTimes+1)
+	for i := range many {
+		many[i] = time.Date(2026, 1, 1, i, 0, 0, 0, time.UTC).Format("15:04")
+	}
+	if _, err := NormalizeDoseTimes(many); err == nil {
+		t.Errorf("Expected more than %d times to be rejected", MaxDoseTimes)
+	}
+}
+
+func TestParseMedicationFrequency(t *testing.T) {
+	tests := []struct {
+		frequency   string
+		days, hours int
+	}{
+		{"Every day", 1, 0},
+		{"twice daily", 1, 0},
+		{"Weekly", 7, 0},
+		{"Every other day", 2, 0},
+		{"Every 3 days", 3, 0},
+		{"Every 2 weeks", 14, 0},
+		{"Every 8 hours", 0, 8},
+		{"As needed", 1, 0},
+	}
+	for _, tt := range tests {
+		if days, hours := ParseMedicationFrequency(tt.frequency); days != tt.days || hours != tt.hours {
+			t.Errorf("ParseMedicationFrequency(%q) = %d, %d, want %d, %d", tt.frequency, days, hours, tt.days, tt.hours)
+		}
+	}
+}
+
+func TestMedicationScheduleDosesPerDay(t *testing.T) {
+	tests := []struct {
+		schedule MedicationSchedule
+		want     float64
+	}{
+		{MedicationSchedule{Times: []string{"08:00", "20:00"}, Days: 1}, 2},
+		{MedicationSchedule{Times: []string{"09:00"}, Days: 2}, 0.5},
+		{MedicationSchedule{Times: []string{"06:00"}, Hours: 8}, 3},
+		{MedicationSchedule{Days: 7}, 1.0 / 7},
+	}
+	for _, tt := range tests {
+		if got := tt.schedule.DosesPerDay(); got != tt.want {
+			t.Errorf("DosesPerDay(%+v) = %v, want %v", tt.schedule, got, tt.want)
+		}
+	}
+}
+
+func TestMedicationScheduleDueTimes(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("No timezone data: %v", err)
+	}
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, loc) }
+	clocks := func(times []time.Time) []string {
+		var got []string
+		for _, at := range times {
+			got = append(got, at.In(loc).Format("02 15:04"))
+		}
+		return got
+	}
+
+	daily := MedicationSchedule{Times: []string{"08:00", "20:00"}, Days: 1, Start: day(1), Location: loc}
+	want := []string{"07 08:00", "07 20:00", "08 08:00", "08 20:00"}
+	if got := clocks(daily.DueTimes(day(7), day(9))); !reflect.DeepEqual(got, want) {
+		t.Errorf("Daily doses across the DST change = %v, want %v", got, want)
+	}
+
+	// Counted from the start day, and nothing after the end day
+	alternate := MedicationSchedule{Times: []string{"09:00"}, Days: 2, Start: day(2), End: day(6), Location: loc}
+	want = []string{"02 09:00", "04 09:00", "06 09:00"}
+	if got := clocks(alternate.DueTimes(day(1), day(12))); !reflect.DeepEqual(got, want) {
+		t.Errorf("Every other day doses = %v, want %v", got, want)
+	}
+
+	hourly := MedicationSchedule{Times: []string{"06:00"}, Hours: 8, Start: day(1), Location: loc}
+	want = []string{"03 06:00", "03 14:00", "03 22:00"}
+	if got := clocks(hourly.DueTimes(day(3), day(4))); !reflect.DeepEqual(got, want) {
+		t.Errorf("Every 8 hours doses = %v, want %v", got, want)
+	}
+
+	// A range ending before noon still finds the morning's doses
+	morning := day(8).Add(7 * time.Hour)
+	want = []string{"08 08:00"}
+	if got := clocks(daily.DueTimes(morning, morning.Add(2*time.Hour))); !reflect.DeepEqual(got, want) {
+		t.Errorf("Doses between 07:00 and 09:00 = %v, want %v", got, want)
+	}
+
+	if got := (MedicationSchedule{Days: 1, Start: day(1)}).DueTimes(day(1), day(2)); got != nil {
+		t.Errorf("Expected no doses without dose times, got %v", got)
+	}
+}
+
+func TestMedicationDoses(t *testing.T) {
+	day := time.Date(2026, 4, 10, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	schedule := MedicationSchedule{Times: []string{"08:00", "14:00", "20:00"}, Days: 1, Start: day, Location: time.UTC}
+	logAt := func(ts time.Time, taken bool) *models.MedicationLog {
+		return &models.MedicationLog{Timestamp: ts, Taken: taken}
+	}
+
+	// The 14:00 dose is logged for explicitly, and late as its window had
+	// closed; the early log without scheduled_for goes to the nearest
+	// unlogged dose
+	explicit := logAt(at(19, 0), true)
+	explicit.ScheduledFor = sql.NullTime{Time: at(14, 0), Valid: true}
+	legacy := logAt(at(7, 30), true)
+	logs := []*models.MedicationLog{explicit, legacy}
+
+	doses := MedicationDoses(schedule, time.Hour, logs, day, day.AddDate(0, 0, 1), at(15, 30))
+	if len(doses) != 3 {
+		t.Fatalf("Expected 3 doses, got %d", len(doses))
+	}
+	if doses[0].Log != legacy || doses[0].Status != DoseTaken {
+		t.Errorf("Expected the 08:00 dose taken by the legacy log, got %+v", doses[0])
+	}
+	if doses[1].Log != explicit || doses[1].Status != DoseLate {
+		t.Errorf("Expected the 14:00 dose taken late by the explicit log, got %+v", doses[1])
+	}
+	if doses[2].Log != nil || doses[2].Status != DoseUpcoming {
+		t.Errorf("Expected the 20:00 dose upcoming, got %+v", doses[2])
+	}
+
+	// Unlogged doses are due within the window and missed after it
+	doses = MedicationDoses(schedule, time.Hour, nil, day, day.AddDate(0, 0, 1), at(14, 30))
+	statuses := []string{doses[0].Status, doses[1].Status, doses[2].Status}
+	if want := []string{DoseMissed, DoseDue, DoseUpcoming}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("Statuses at 14:30 = %v, want %v", statuses, want)
+	}
+
+	// A later log for the same dose replaces the earlier one
+	missed := logAt(at(8, 5), false)
+	missed.ScheduledFor = sql.NullTime{Time: at(8, 0), Valid: true}
+	retaken := logAt(at(9, 0), true)
+	retaken.ScheduledFor = missed.ScheduledFor
+	doses = MedicationDoses(schedule, time.Hour, []*models.MedicationLog{retaken, missed}, day, day.AddDate(0, 0, 1), at(21, 30))
+	if doses[0].Log != retaken || doses[0].Status != DoseTaken {
+		t.Errorf("Expected the later log to count, got %+v", doses[0])
+	}
+
+	var adherence DoseAdherence
+	for _, d := range doses {
+		adherence.Add(d)
+	}
+	if adherence.Expected != 3 || adherence.Taken != 1 || adherence.Late != 0 || adherence.Missed != 2 || adherence.Pending != 0 {
+		t.Errorf("Unexpected adherence %+v", adherence)
+	}
+	if rate := adherence.Rate(); rate < 33.3 || rate > 33.4 {
+		t.Errorf("Expected a third taken, got %.1f%%", rate)
+	}
+}
+
+func TestMedicationDosesLate(t *testing.T) {
+	day := time.Date(2026, 4, 10, 0, 0, 0, 0, time.UTC)
+	schedule := MedicationSchedule{Times: []string{"08:00", "14:00", "20:00"}, Days: 1, Start: day, Location: time.UTC}
+	logFor := func(dose, at time.Duration, late bool) *models.MedicationLog {
+		return &models.MedicationLog{
+			Timestamp:    day.Add(at),
+			Taken:        true,
+			Late:         late,
+			ScheduledFor: sql.NullTime{Time: day.Add(dose), Valid: true},
+		}
+	}
+
+	// On time, logged as late within the window, and logged after the window
+	logs := []*models.MedicationLog{
+		logFor(8*time.Hour, 8*time.Hour+30*time.Minute, false),
+		logFor(14*time.Hour, 14*time.Hour+30*time.Minute, true),
+		logFor(20*time.Hour, 21*time.Hour+30*time.Minute, false),
+	}
+	doses := MedicationDoses(schedule, time.Hour, logs, day, day.AddDate(0, 0, 1), day.Add(23*time.Hour))
+	statuses := []string{doses[0].Status, doses[1].Status, doses[2].Status}
+	if want := []string{DoseTaken, DoseLate, DoseLate}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("Statuses = %v, want %v", statuses, want)
+	}
+
+	var adherence DoseAdherence
+	for _, d := range doses {
+		adherence.Add(d)
+	}
+	adherence.Add(MedicationDose{Status: DoseMissed})
+	if adherence.Taken != 3 || adherence.Late != 2 || adherence.Missed != 1 {
+		t.Errorf("Unexpected adherence %+v", adherence)
+	}
+	if rate := adherence.Rate(); rate != 75 {
+		t.Errorf("Expected 75%% taken, got %.1f%%", rate)
+	}
+	if rate := adherence.OnTimeRate(); rate != 25 {
+		t.Errorf("Expected 25%% on time, got %.1f%%", rate)
+	}
+}
+
+func TestSnoozeUntil(t *testing.T) {
+	due := time.Date(2026, 4, 10, 8, 0, 0, 0, time.UTC)
+	dose := MedicationDose{Due: due, Status: DoseDue}
+
+	got, err := SnoozeUntil(dose, time.Hour, 15, due.Add(5*time.Minute))
+	if err != nil || !got.Equal(due.Add(20*time.Minute)) {
+		t.Errorf("Expected a snooze to 08:20, got %v (%v)", got, err)
+	}
+
+	// Never past the end of the window
+	got, err = SnoozeUntil(dose, time.Hour, 30, due.Add(45*time.Minute))
+	if err != nil || !got.Equal(due.Add(time.Hour)) {
+		t.Errorf("Expected the snooze to stop at 09:00, got %v (%v)", got, err)
+	}
+
+	for _, status := range []string{DoseTaken, DoseMissed, DoseUpcoming} {
+		if _, err := SnoozeUntil(MedicationDose{Due: due, Status: status}, time.Hour, 10, due); err == nil {
+			t.Errorf("Expected a %s dose not to be snoozed", status)
+		}
+	}
+	if _, err := SnoozeUntil(dose, time.Hour, 0, due); err == nil {
+		t.Error("Expected a snooze of 0 minutes to be rejected")
+	}
+
+	doses := []MedicationDose{dose, {Due: due.Add(12 * time.Hour)}}
+	ApplySnoozes(doses, []*models.MedicationDoseSnooze{{ScheduledFor: due.In(time.FixedZone("EST", -5*3600)), SnoozedUntil: due.Add(20 * time.Minute)}})
+	if !doses[0].SnoozedUntil.Equal(due.Add(20*time.Minute)) || !doses[1].SnoozedUntil.IsZero() {
+		t.Errorf("Expected only the morning dose snoozed, got %+v", doses)
+	}
+}
+
+func TestDoseFor(t *testing.T) {
+	day := time.Date(2026, 4, 10, 0, 0, 0, 0, time.UTC)
+	schedule := MedicationSchedule{Times: []string{"08:00", "20:00"}, Days: 1, Start: day, Location: time.UTC}
+	morning := &models.MedicationLog{Timestamp: day.Add(8 * time.Hour), Taken: true}
+	doses := MedicationDoses(schedule, time.Hour, []*models.MedicationLog{morning}, day, day.AddDate(0, 0, 1), day.Add(9*time.Hour))
+
+	// Nearer the logged morning dose, but that one's already taken
+	got, err := DoseFor(doses, day.Add(10*time.Hour))
+	if err != nil || !got.Equal(day.Add(20*time.Hour)) {
+		t.Errorf("Expected the evening dose, got %v (%v)", got, err)
+	}
+
+	if _, err := DoseFor(nil, day); err == nil {
+		t.Error("Expected an error with no doses")
+	}
+}
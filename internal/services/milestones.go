@@ -0,0 +1,147 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Kinds of milestone
+const (
+	MilestoneInjections = "injections" // The course's 1st, 10th, 25th, 50th and every 50th injection after
+	MilestoneHalfway    = "halfway"    // Half way from the course's start to its expected end
+)
+
+// CourseProgress is a course and the times of its injections, from which
+// streaks and milestones are worked out
+type CourseProgress struct {
+	CourseID    int64
+	Name        string
+	Start       time.Time
+	ExpectedEnd time.Time // Zero when the course has no planned end
+	End         time.Time // Zero while the course is running
+	IsActive    bool
+	Injections  []time.Time // Oldest first
+}
+
+// Milestone is a point a course reached
+type Milestone struct {
+	Kind       string
+	CourseID   int64
+	CourseName string
+	Count      int // Which injection, for MilestoneInjections
+	ReachedAt  time.Time
+}
+
+// Title describes a milestone in a few words
+func (m Milestone) Title() string {
+	if m.Kind == MilestoneHalfway {
+		return "Halfway through " + m.CourseName
+	}
+	if m.Count == 1 {
+		return "First injection of " + m.CourseName
+	}
+	return ordinal(m.Count) + " injection of " + m.CourseName
+}
+
+// IsInjectionMilestone reports whether a course's nth injection is a milestone
+func IsInjectionMilestone(n int) bool {
+	return n == 1 || n == 10 || n == 25 || (n > 0 && n%50 == 0)
+}
+
+// NextInjectionMilestone returns the first milestone count after n injections
+func NextInjectionMilestone(n int) int {
+	next := n + 1
+	for !IsInjectionMilestone(next) {
+		next++
+	}
+	return next
+}
+
+// HalfwayDate returns the day, in loc, half way from a course's start to its
+// expected end, or the zero time when it has no expected end
+func HalfwayDate(c CourseProgress, loc *time.Location) time.Time {
+	if c.ExpectedEnd.IsZero() || !c.ExpectedEnd.After(c.Start) {
+		return time.Time{}
+	}
+	mid := c.Start.Add(c.ExpectedEnd.Sub(c.Start) / 2).In(loc)
+	return time.Date(mid.Year(), mid.Month(), mid.Day(), 0, 0, 0, 0, loc)
+}
+
+// CourseMilestones lists the milestones a course reached by now, oldest
+// first. A course closed before its halfway point never reaches it.
+func CourseMilestones(c CourseProgress, now time.Time, loc *time.Location) []Milestone {
+	var milestones []Milestone
+	for i, t := range c.Injections {
+		if n := i + 1; IsInjectionMilestone(n) && !t.After(now) {
+			milestones = append(milestones, Milestone{Kind: MilestoneInjections, CourseID: c.CourseID, CourseName: c.Name, Count: n, ReachedAt: t})
+		}
+	}
+	if half := HalfwayDate(c, loc); !half.IsZero() && !half.After(now) && (c.End.IsZero() || !c.End.Before(half)) {
+		milestones = append(milestones, Milestone{Kind: MilestoneHalfway, CourseID: c.CourseID, CourseName: c.Name, ReachedAt: half})
+	}
+	sort.SliceStable(milestones, func(i, j int) bool {
+		return milestones[i].ReachedAt.Before(milestones[j].ReachedAt)
+	})
+	return milestones
+}
+
+// InjectionStreaks counts, in loc, the days in a row with an injection up
+// to now (current) and the most there have ever been (longest). Only days a
+// course was running count: a gap between courses doesn't break a streak.
+// Today doesn't break the current streak before it has an injection.
+func InjectionStreaks(courses []CourseProgress, now time.Time, loc *time.Location) (current, longest int) {
+	if len(courses) == 0 {
+		return 0, 0
+	}
+
+	periods := make([]CoursePeriod, len(courses))
+	injectedDays := map[string]bool{}
+	first := courses[0].Start
+	for i, c := range courses {
+		periods[i] = CoursePeriod{Start: c.Start, End: c.End}
+		if c.Start.Before(first) {
+			first = c.Start
+		}
+		for _, t := range c.Injections {
+			injectedDays[t.In(loc).Format("2006-01-02")] = true
+		}
+	}
+
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	first = first.In(loc)
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); !day.After(today); day = day.AddDate(0, 0, 1) {
+		if !courseRunning(periods, day, day.AddDate(0, 0, 1)) {
+			continue
+		}
+		switch {
+		case injectedDays[day.Format("2006-01-02")]:
+			current++
+			if current > longest {
+				longest = current
+			}
+		case day.Before(today):
+			current = 0
+		}
+	}
+	return current, longest
+}
+
+// ordinal writes n as 1st, 2nd, 3rd, 4th and so on
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 100 {
+	case 11, 12, 13:
+	default:
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInjectionStreaks(t *testing.T) {
+	loc := time.UTC
+	day := func(d, hour int) time.Time { return time.Date(2025, 3, d, hour, 0, 0, 0, loc) }
+
+	// Three days in a row, a missed day, then two more; the second course
+	// starts after a gap that doesn't count against the streak
+	courses := []CourseProgress{
+		{Start: day(1, 0), End: day(6, 0), Injections: []time.Time{day(1, 9), day(2, 9), day(3, 9), day(5, 9), day(6, 9)}},
+		{Start: day(10, 0), Injections: []time.Time{day(10, 9), day(11, 21)}},
+	}
+
+	// Today (the 12th) has no injection yet, which doesn't break the streak
+	current, longest := InjectionStreaks(courses, day(12, 8), loc)
+	if current != 4 || longest != 4 {
+		t.Errorf("Expected a current and longest streak of 4, got %d and %d", current, longest)
+	}
+
+	// Tomorrow it does
+	if current, longest := InjectionStreaks(courses, day(13, 8), loc); current != 0 || longest != 4 {
+		t.Errorf("Expected the streak broken, got %d (longest %d)", current, longest)
+	}
+
+	// Days are counted in the user's time zone: 21:00 UTC on the 11th is the
+	// 12th in Sydney, leaving the 11th without an injection
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Skip("time zone data not available")
+	}
+	if current, _ := InjectionStreaks(courses, day(12, 8), sydney); current != 1 {
+		t.Errorf("Expected a streak of 1 in Sydney, got %d", current)
+	}
+
+	if current, longest := InjectionStreaks(nil, day(12, 8), loc); current != 0 || longest != 0 {
+		t.Errorf("Expected no streak without courses, got %d and %d", current, longest)
+	}
+}
+
+func TestCourseMilestones(t *testing.T) {
+	loc := time.UTC
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, loc)
+	course := CourseProgress{CourseID: 3, Name: "Cycle 1", Start: start, ExpectedEnd: start.AddDate(0, 0, 100)}
+	for i := 0; i < 60; i++ {
+		course.Injections = append(course.Injections, start.AddDate(0, 0, i).Add(9*time.Hour))
+	}
+
+	milestones := CourseMilestones(course, start.AddDate(0, 0, 59).Add(12*time.Hour), loc)
+	want := []string{"First injection of Cycle 1", "10th injection of Cycle 1", "25th injection of Cycle 1", "50th injection of Cycle 1", "Halfway through Cycle 1"}
+	if len(milestones) != len(want) {
+		t.Fatalf("Expected %d milestones, got %+v", len(want), milestones)
+	}
+	for i, m := range milestones {
+		if m.Title() != want[i] {
+			t.Errorf("Milestone %d: expected %q, got %q", i, want[i], m.Title())
+		}
+	}
+	if half := milestones[4].ReachedAt; !half.Equal(start.AddDate(0, 0, 50)) {
+		t.Errorf("Expected the halfway point on Feb 20, got %v", half)
+	}
+
+	// Closed before its halfway point
+	course.End = start.AddDate(0, 0, 40)
+	course.Injections = course.Injections[:40]
+	for _, m := range CourseMilestones(course, start.AddDate(0, 0, 90), loc) {
+		if m.Kind == MilestoneHalfway {
+			t.Errorf("Expected no halfway milestone for a course closed early")
+		}
+	}
+
+	for n, next := range map[int]int{0: 1, 1: 10, 10: 25, 30: 50, 50: 100, 149: 150} {
+		if got := NextInjectionMilestone(n); got != next {
+			t.Errorf("NextInjectionMilestone(%d) = %d, want %d", n, got, next)
+		}
+	}
+	for n, s := range map[int]string{2: "2nd", 3: "3rd", 11: "11th", 21: "21st", 112: "112th"} {
+		if got := ordinal(n); got != s {
+			t.Errorf("ordinal(%d) = %q, want %q", n, got, s)
+		}
+	}
+}
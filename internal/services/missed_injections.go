@@ -0,0 +1,174 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// missedInjectionInterval is how often courses are checked for missed doses
+const missedInjectionInterval = time.Hour
+
+// MissedInjectionGrace is how long after a dose falls due it can still be
+// given before it counts as missed: as long as it is still reminded of. A
+// dose logged within it of when it fell due, even afterwards, clears the
+// miss.
+const MissedInjectionGrace = injectionReminderLookback
+
+// missedInjectionLookback is how far back a course is checked, so a course
+// that hasn't been looked at in a while, or ever, doesn't flood its members
+// with old misses
+const missedInjectionLookback = 7 * 24 * time.Hour
+
+// missedInjectionMax caps the misses recorded for a course in one check
+const missedInjectionMax = 50
+
+// DetectMissedInjections compares each active course's schedule with the
+// injections logged and records each dose that fell due more than
+// MissedInjectionGrace ago with nothing logged since, telling the account's
+// members about it. Due times are worked out in the account owner's
+// timezone, or while travelling the trip's. A miss later logged after all
+// is cleared unless someone has said why it was skipped. Suspended
+// accounts are left alone. It returns how many misses were recorded.
+func DetectMissedInjections(db *database.DB, now time.Time) (int, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT account_id FROM courses
+		WHERE is_active = TRUE AND account_id IS NOT NULL AND ` + repository.AccountNotSuspended("account_id") + `
+		ORDER BY account_id
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query active courses: %w", err)
+	}
+	var accounts []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan active course: %w", err)
+		}
+		accounts = append(accounts, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query active courses: %w", err)
+	}
+
+	courses := repository.NewCourseRepository(db)
+	missedRepo := repository.NewMissedInjectionRepository(db)
+	notifications := repository.NewNotificationRepository(db)
+	recorded := 0
+	for _, accountID := range accounts {
+		active, err := courses.ListActive(accountID)
+		if err != nil {
+			slog.Error("Failed to load active courses for missed injections", "account_id", accountID, "err", err)
+			continue
+		}
+		members, err := medicationReminderMembers(db, accountID)
+		if err != nil {
+			slog.Error("Failed to load account members for missed injections", "account_id", accountID, "err", err)
+			continue
+		}
+		loc, _, err := LoadTravelLocation(db, accountID, ownerLocation(members), now)
+		if err != nil {
+			slog.Error("Failed to load trip for missed injections", "account_id", accountID, "err", err)
+			continue
+		}
+
+		for _, course := range active {
+			if _, err := missedRepo.ClearLogged(course.ID, MissedInjectionGrace); err != nil {
+				slog.Error("Failed to clear logged missed injections", "course_id", course.ID, "err", err)
+				continue
+			}
+			schedule, err := LoadInjectionSchedule(db, accountID, course, loc)
+			if err != nil {
+				slog.Error("Failed to plan injections for missed injections", "course_id", course.ID, "err", err)
+				continue
+			}
+			for _, due := range schedule.DueTimes(now.Add(-missedInjectionLookback), now.Add(-MissedInjectionGrace), missedInjectionMax) {
+				ok, err := missedRepo.Record(course.ID, due)
+				if err != nil {
+					slog.Error("Failed to record missed injection", "course_id", course.ID, "err", err)
+					break
+				}
+				if !ok {
+					continue
+				}
+				recorded++
+				for _, member := range members {
+					if err := notifyMissedInjection(notifications, member, course, due); err != nil {
+						slog.Error("Failed to send missed injection notification", "course_id", course.ID, "user_id", member.UserID, "err", err)
+					}
+				}
+			}
+		}
+	}
+	return recorded, nil
+}
+
+// ownerLocation is the timezone of an account's owner, or of its first
+// member if it has no owner, that its doses fall due in
+func ownerLocation(members []medicationReminderMember) *time.Location {
+	loc := time.UTC
+	if len(members) > 0 {
+		loc = members[0].Location
+	}
+	for _, m := range members {
+		if m.Owner {
+			return m.Location
+		}
+	}
+	return loc
+}
+
+// notifyMissedInjection tells a member a dose was missed, in their language
+// and timezone
+func notifyMissedInjection(notifications *repository.NotificationRepository, member medicationReminderMember, course *models.Course, due time.Time) error {
+	p := member.Printer
+	due = due.In(member.Location)
+	return notifications.Create(&models.Notification{
+		UserID:  sql.NullInt64{Int64: member.UserID, Valid: true},
+		Type:    "system",
+		Title:   p.T("Missed injection"),
+		Message: p.T("The %s injection on %s wasn't logged. Course: %s. You can say why it was skipped on the Injections page.", due.Format("15:04"), p.MonthDay(due), course.Name),
+	})
+}
+
+// StartMissedInjectionScheduler checks active courses for missed doses
+// hourly
+func StartMissedInjectionScheduler(db *database.DB) {
+	run := func() {
+		recorded, err := DetectMissedInjections(db, time.Now())
+		RecordSchedulerRun("missed_injections", err)
+		if err != nil {
+			slog.Error("Detecting missed injections failed", "err", err)
+		} else if recorded > 0 {
+			slog.Info("Recorded missed injections", "count", recorded)
+		}
+	}
+
+	RegisterScheduler("missed_injections", missedInjectionInterval)
+	RunBackground(func() {
+		// Wait for server to fully start
+		if !SleepUnlessShutdown(2 * time.Minute) {
+			return
+		}
+		run()
+
+		ticker := time.NewTicker(missedInjectionInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-shutdownChan:
+				return
+			}
+		}
+	})
+}
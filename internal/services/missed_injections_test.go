@@ -0,0 +1,81 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+func TestDetectMissedInjections(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	// Daily at 19:00, last given on the 10th
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash'), (2, 'partner', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner'), (1, 2, 'member');
+		INSERT INTO user_preferences (user_id, timezone) VALUES (1, 'UTC'), (2, 'UTC');
+		INSERT INTO courses (id, account_id, name, start_date, is_active) VALUES (1, 1, 'Course', '2026-03-01', 1);
+		INSERT INTO course_schedules (course_id, interval_hours, time_of_day) VALUES (1, 24, '19:00');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO injections (course_id, timestamp, side) VALUES (1, ?, 'left')`, time.Date(2026, 3, 10, 19, 5, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Failed to seed injection: %v", err)
+	}
+	notified := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE title = 'Missed injection'`).Scan(&n); err != nil {
+			t.Fatalf("Failed to count notifications: %v", err)
+		}
+		return n
+	}
+
+	// A suspended account is left alone
+	now := time.Date(2026, 3, 13, 20, 0, 0, 0, time.UTC)
+	accounts := repository.NewAccountRepository(db.DB)
+	if err := accounts.Suspend(1, models.AccountReadOnly, "", 1); err != nil {
+		t.Fatalf("Failed to suspend account: %v", err)
+	}
+	if recorded, err := DetectMissedInjections(db, now); err != nil || recorded != 0 {
+		t.Fatalf("Expected nothing recorded for a suspended account, got %d, %v", recorded, err)
+	}
+	if err := accounts.Reactivate(1); err != nil {
+		t.Fatalf("Failed to reactivate account: %v", err)
+	}
+
+	// The 11th and 12th are missed; the 13th is still within its grace
+	if recorded, err := DetectMissedInjections(db, now); err != nil || recorded != 2 {
+		t.Fatalf("Expected two missed doses, got %d, %v", recorded, err)
+	}
+	if n := notified(); n != 4 {
+		t.Errorf("Expected both members told of each miss, got %d notifications", n)
+	}
+	if recorded, err := DetectMissedInjections(db, now.Add(time.Minute)); err != nil || recorded != 0 {
+		t.Errorf("Expected misses recorded once, got %d, %v", recorded, err)
+	}
+
+	// A dose given on time on the 11th but logged late clears that miss
+	if _, err := db.Exec(`INSERT INTO injections (course_id, timestamp, side) VALUES (1, ?, 'right')`, time.Date(2026, 3, 11, 19, 30, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Failed to seed injection: %v", err)
+	}
+	if recorded, err := DetectMissedInjections(db, now.Add(2*time.Minute)); err != nil || recorded != 0 {
+		t.Errorf("Expected nothing new recorded, got %d, %v", recorded, err)
+	}
+	missed, err := repository.NewMissedInjectionRepository(db).List(1, repository.MissedInjectionFilter{}, 10)
+	if err != nil || len(missed) != 1 || !missed[0].DueAt.Equal(time.Date(2026, 3, 12, 19, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected only the 12th left missed, got %v, %v", missed, err)
+	}
+}
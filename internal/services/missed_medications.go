@@ -0,0 +1,150 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// missedMedicationInterval is how often medications are checked for doses
+// whose window closed unlogged
+const missedMedicationInterval = 15 * time.Minute
+
+// missedMedicationLookback is how far back doses are checked, so turning
+// the check on, or a medication that has been paused, doesn't report a
+// backlog of old misses
+const missedMedicationLookback = 24 * time.Hour
+
+// DetectMissedMedicationDoses finds doses of active medications whose window
+// has closed with nothing logged and sends the medication.missed webhook for
+// each, as logging a dose as not taken does. Due times are worked out in the
+// account owner's timezone, or while travelling the trip's. Each dose is
+// reported once, however it was missed. Suspended accounts are left alone.
+// It returns how many doses were reported.
+func DetectMissedMedicationDoses(db *database.DB, now time.Time) (int, error) {
+	rows, err := db.Query(`
+		SELECT m.id, m.account_id
+		FROM medications m
+		WHERE m.is_active = TRUE AND m.dose_times IS NOT NULL AND m.dose_times <> '' AND m.account_id IS NOT NULL
+		AND ` + repository.AccountNotSuspended("m.account_id") + `
+		ORDER BY m.account_id, m.id
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query scheduled medications: %w", err)
+	}
+	type scheduled struct{ medicationID, accountID int64 }
+	var medications []scheduled
+	for rows.Next() {
+		var m scheduled
+		if err := rows.Scan(&m.medicationID, &m.accountID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan scheduled medication: %w", err)
+		}
+		medications = append(medications, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query scheduled medications: %w", err)
+	}
+
+	medicationRepo := repository.NewMedicationRepository(db)
+	webhooks := NewWebhookService(db)
+	locations := make(map[int64]*time.Location)
+	reported := 0
+	for _, m := range medications {
+		loc, ok := locations[m.accountID]
+		if !ok {
+			members, err := medicationReminderMembers(db, m.accountID)
+			if err == nil {
+				loc, _, err = LoadTravelLocation(db, m.accountID, ownerLocation(members), now)
+			}
+			if err != nil {
+				slog.Error("Failed to load timezone for missed medications", "account_id", m.accountID, "err", err)
+				continue
+			}
+			locations[m.accountID] = loc
+		}
+
+		med, err := medicationRepo.GetByID(m.medicationID, m.accountID)
+		if err != nil {
+			slog.Error("Failed to load medication for missed doses", "medication_id", m.medicationID, "err", err)
+			continue
+		}
+		window := DoseWindow(med)
+		from, until := now.Add(-missedMedicationLookback), now.Add(-window)
+		logs, err := medicationRepo.ListLogsBetween(med.ID, from.Add(-24*time.Hour).UTC(), now.UTC())
+		if err != nil {
+			slog.Error("Failed to load medication logs for missed doses", "medication_id", med.ID, "err", err)
+			continue
+		}
+
+		for _, d := range MedicationDoses(NewMedicationSchedule(med, loc), window, logs, from, until, now) {
+			// Doses logged as not taken were reported when they were logged,
+			// and those due before the medication was added never were due
+			if d.Status != DoseMissed || d.Log != nil || d.Due.Before(med.CreatedAt) {
+				continue
+			}
+			ok, err := medicationRepo.RecordMissedDose(med.ID, d.Due)
+			if err != nil {
+				slog.Error("Failed to record missed dose", "medication_id", med.ID, "err", err)
+				break
+			}
+			if !ok {
+				continue
+			}
+			reported++
+			emitMissedDose(webhooks, med, d.Due)
+		}
+	}
+	return reported, nil
+}
+
+// emitMissedDose sends the medication.missed webhook for a dose whose
+// window closed unlogged
+func emitMissedDose(webhooks *WebhookService, med *models.Medication, due time.Time) {
+	webhooks.Emit(med.AccountID, EventMedicationMissed, map[string]interface{}{
+		"medication_id":   med.ID,
+		"medication_name": med.Name,
+		"scheduled_for":   due.UTC(),
+		"detected":        true,
+	})
+}
+
+// StartMissedMedicationScheduler checks scheduled medications for missed
+// doses every quarter hour
+func StartMissedMedicationScheduler(db *database.DB) {
+	run := func() {
+		reported, err := DetectMissedMedicationDoses(db, time.Now())
+		RecordSchedulerRun("missed_medications", err)
+		if err != nil {
+			slog.Error("Detecting missed medication doses failed", "err", err)
+		} else if reported > 0 {
+			slog.Info("Reported missed medication doses", "count", reported)
+		}
+	}
+
+	RegisterScheduler("missed_medications", missedMedicationInterval)
+	RunBackground(func() {
+		// Wait for server to fully start
+		if !SleepUnlessShutdown(2 * time.Minute) {
+			return
+		}
+		run()
+
+		ticker := time.NewTicker(missedMedicationInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-shutdownChan:
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+func TestDetectMissedMedicationDoses(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+	defer WaitBackground(context.Background())
+
+	// Due at 08:00 and 20:00; the morning dose is logged as not taken
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner');
+		INSERT INTO user_preferences (user_id, timezone) VALUES (1, 'UTC');
+		INSERT INTO medications (id, account_id, name, is_active, dose_times, time_window_minutes, created_at)
+		VALUES (1, 1, 'Estradiol', 1, '08:00,20:00', 60, '2026-03-10 00:00:00');
+		INSERT INTO medication_logs (medication_id, timestamp, taken, scheduled_for)
+		VALUES (1, '2026-03-12 09:30:00', 0, '2026-03-12 08:00:00');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO webhooks (account_id, url, secret, events) VALUES (1, ?, 'secret', '["medication.missed"]')`, receiver.URL); err != nil {
+		t.Fatalf("Failed to seed webhook: %v", err)
+	}
+	deliveries := func() []string {
+		t.Helper()
+		rows, err := db.Query(`SELECT payload FROM webhook_deliveries WHERE event = 'medication.missed' ORDER BY id`)
+		if err != nil {
+			t.Fatalf("Failed to list deliveries: %v", err)
+		}
+		defer rows.Close()
+		var payloads []string
+		for rows.Next() {
+			var payload string
+			if err := rows.Scan(&payload); err != nil {
+				t.Fatalf("Failed to scan delivery: %v", err)
+			}
+			payloads = append(payloads, payload)
+		}
+		return payloads
+	}
+
+	// A suspended account is left alone
+	now := time.Date(2026, 3, 12, 21, 30, 0, 0, time.UTC)
+	accounts := repository.NewAccountRepository(db.DB)
+	if err := accounts.Suspend(1, models.AccountReadOnly, "", 1); err != nil {
+		t.Fatalf("Failed to suspend account: %v", err)
+	}
+	if reported, err := DetectMissedMedicationDoses(db, now); err != nil || reported != 0 {
+		t.Fatalf("Expected nothing reported for a suspended account, got %d, %v", reported, err)
+	}
+	if err := accounts.Reactivate(1); err != nil {
+		t.Fatalf("Failed to reactivate account: %v", err)
+	}
+
+	// Only the evening dose, whose window has closed unlogged, is reported;
+	// the morning one was reported when it was logged
+	if reported, err := DetectMissedMedicationDoses(db, now); err != nil || reported != 1 {
+		t.Fatalf("Expected one missed dose, got %d, %v", reported, err)
+	}
+	payloads := deliveries()
+	if len(payloads) != 1 {
+		t.Fatalf("Expected one medication.missed delivery, got %d", len(payloads))
+	}
+	var payload struct {
+		Data struct {
+			MedicationID int64     `json:"medication_id"`
+			ScheduledFor time.Time `json:"scheduled_for"`
+			Detected     bool      `json:"detected"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(payloads[0]), &payload); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if payload.Data.MedicationID != 1 || !payload.Data.ScheduledFor.Equal(time.Date(2026, 3, 12, 20, 0, 0, 0, time.UTC)) || !payload.Data.Detected {
+		t.Errorf("Unexpected payload %s", payloads[0])
+	}
+
+	// Each dose is reported once, including when it's logged as not taken
+	// afterwards
+	if reported, err := DetectMissedMedicationDoses(db, now.Add(time.Minute)); err != nil || reported != 0 {
+		t.Errorf("Expected the miss reported once, got %d, %v", reported, err)
+	}
+	if recorded, err := repository.NewMedicationRepository(db).RecordMissedDose(1, time.Date(2026, 3, 12, 20, 0, 0, 0, time.UTC)); err != nil || recorded {
+		t.Errorf("Expected the dose already recorded, got %v, %v", recorded, err)
+	}
+}
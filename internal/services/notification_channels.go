@@ -0,0 +1,296 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/models"
+)
+
+// Supported notification channel types
+const (
+	ChannelTypeNtfy    = "ntfy"
+	ChannelTypeGotify  = "gotify"
+	ChannelTypeWebhook = "webhook"
+)
+
+// Notification priorities understood by every channel
+const (
+	PriorityDefault = "default"
+	PriorityHigh    = "high"
+)
+
+// WebhookSignatureHeader carries the HMAC-SHA256 of the request body when a secret is set
+const WebhookSignatureHeader = "X-PTrack-Signature"
+
+// channelSecretKeys lists config keys that must never be returned to clients
+var channelSecretKeys = []string{"token", "secret"}
+
+// NotificationMessage is a single notification to be fanned out to an account
+type NotificationMessage struct {
+	AccountID int64
+	Type      string // matches notifications.type (e.g. 'low_stock')
+	Title     string
+	Message   string
+	Priority  string // PriorityDefault or PriorityHigh
+
+	// Args, when set, make Message a format for i18n's Printer.T, so each
+	// recipient gets it in their language. Title is translated either way.
+	Args []interface{}
+
+	// DedupeKey suppresses the message if a notification of the same type
+	// mentioning this key was created within DedupeHours.
+	DedupeKey   string
+	DedupeHours int
+}
+
+// Localize returns the message written in p's language
+func (m NotificationMessage) Localize(p *i18n.Printer) NotificationMessage {
+	m.Title = p.T(m.Title)
+	m.Message = p.T(m.Message, m.Args...)
+	m.Args = nil
+	return m
+}
+
+// NotificationSender delivers a message to one external channel
+type NotificationSender interface {
+	Send(ctx context.Context, msg NotificationMessage) error
+}
+
+// NtfyConfig configures delivery to an ntfy topic
+type NtfyConfig struct {
+	ServerURL string `json:"server_url"`
+	Topic     string `json:"topic"`
+	Token     string `json:"token,omitempty"`
+}
+
+// GotifyConfig configures delivery to a Gotify server
+type GotifyConfig struct {
+	ServerURL string `json:"server_url"`
+	Token     string `json:"token"`
+}
+
+// WebhookConfig configures delivery to a generic JSON webhook
+type WebhookConfig struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// IsValidChannelType checks if the channel type is supported
+func IsValidChannelType(channelType string) bool {
+	switch channelType {
+	case ChannelTypeNtfy, ChannelTypeGotify, ChannelTypeWebhook:
+		return true
+	}
+	return false
+}
+
+// NewChannelSender parses a channel's config and returns a sender for it.
+// It doubles as config validation for the API handlers.
+func NewChannelSender(channel *models.NotificationChannel, client *http.Client) (NotificationSender, error) {
+	switch channel.Type {
+	case ChannelTypeNtfy:
+		var cfg NtfyConfig
+		if err := json.Unmarshal([]byte(channel.Config), &cfg); err != nil {
+			return nil, fmt.Errorf("invalid ntfy config: %w", err)
+		}
+		if cfg.ServerURL == "" {
+			cfg.ServerURL = "https://ntfy.sh"
+		}
+		if err := validateChannelURL(cfg.ServerURL); err != nil {
+			return nil, fmt.Errorf("invalid ntfy server_url: %w", err)
+		}
+		if cfg.Topic == "" || strings.ContainsAny(cfg.Topic, "/?# ") {
+			return nil, fmt.Errorf("ntfy topic is required and may not contain '/', '?', '#' or spaces")
+		}
+		return &ntfySender{cfg: cfg, client: client}, nil
+
+	case ChannelTypeGotify:
+		var cfg GotifyConfig
+		if err := json.Unmarshal([]byte(channel.Config), &cfg); err != nil {
+			return nil, fmt.Errorf("invalid gotify config: %w", err)
+		}
+		if err := validateChannelURL(cfg.ServerURL); err != nil {
+			return nil, fmt.Errorf("invalid gotify server_url: %w", err)
+		}
+		if cfg.Token == "" {
+			return nil, fmt.Errorf("gotify application token is required")
+		}
+		return &gotifySender{cfg: cfg, client: client}, nil
+
+	case ChannelTypeWebhook:
+		var cfg WebhookConfig
+		if err := json.Unmarshal([]byte(channel.Config), &cfg); err != nil {
+			return nil, fmt.Errorf("invalid webhook config: %w", err)
+		}
+		if err := validateChannelURL(cfg.URL); err != nil {
+			return nil, fmt.Errorf("invalid webhook url: %w", err)
+		}
+		return &webhookSender{cfg: cfg, client: client}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported channel type: %s", channel.Type)
+}
+
+// RedactChannelConfig returns the channel config with secret values masked
+func RedactChannelConfig(config string) map[string]interface{} {
+	values := map[string]interface{}{}
+	_ = json.Unmarshal([]byte(config), &values)
+
+	for _, key := range channelSecretKeys {
+		if v, ok := values[key].(string); ok && v != "" {
+			values[key] = "********"
+		}
+	}
+	return values
+}
+
+// MergeChannelSecrets copies secrets from the stored config into an updated
+// config when the client left them blank, so editing a channel does not
+// require re-entering its token.
+func MergeChannelSecrets(stored string, updated map[string]interface{}) {
+	previous := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(stored), &previous); err != nil {
+		return
+	}
+
+	for _, key := range channelSecretKeys {
+		v, _ := updated[key].(string)
+		if v == "" || v == "********" {
+			if old, ok := previous[key]; ok {
+				updated[key] = old
+			}
+		}
+	}
+}
+
+// SignWebhookPayload computes the signature sent in WebhookSignatureHeader
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func validateChannelURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an absolute http(s) URL")
+	}
+	return nil
+}
+
+// doChannelRequest sends the request and turns non-2xx responses into errors
+func doChannelRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+type ntfySender struct {
+	cfg    NtfyConfig
+	client *http.Client
+}
+
+func (s *ntfySender) Send(ctx context.Context, msg NotificationMessage) error {
+	endpoint := strings.TrimRight(s.cfg.ServerURL, "/") + "/" + s.cfg.Topic
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(msg.Message))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Title", msg.Title)
+	req.Header.Set("Tags", msg.Type)
+	if msg.Priority == PriorityHigh {
+		req.Header.Set("Priority", "high")
+	}
+	if s.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+	}
+
+	return doChannelRequest(s.client, req)
+}
+
+type gotifySender struct {
+	cfg    GotifyConfig
+	client *http.Client
+}
+
+func (s *gotifySender) Send(ctx context.Context, msg NotificationMessage) error {
+	priority := 5
+	if msg.Priority == PriorityHigh {
+		priority = 8
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"title":    msg.Title,
+		"message":  msg.Message,
+		"priority": priority,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimRight(s.cfg.ServerURL, "/") + "/message"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", s.cfg.Token)
+
+	return doChannelRequest(s.client, req)
+}
+
+type webhookSender struct {
+	cfg    WebhookConfig
+	client *http.Client
+}
+
+func (s *webhookSender) Send(ctx context.Context, msg NotificationMessage) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"event":      "notification",
+		"account_id": msg.AccountID,
+		"type":       msg.Type,
+		"title":      msg.Title,
+		"message":    msg.Message,
+		"priority":   msg.Priority,
+		"sent_at":    time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "P-TRACK")
+	if s.cfg.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(s.cfg.Secret, body))
+	}
+
+	return doChannelRequest(s.client, req)
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// channelSendTimeout bounds a single delivery to an external channel
+const channelSendTimeout = 10 * time.Second
+
+// NotificationDispatcher fans a notification out to every destination an account
+// has configured: in-app notifications (which also drive PWA push), email, and
+// external channels such as ntfy, Gotify and webhooks.
+type NotificationDispatcher struct {
+	db               *database.DB
+	notificationRepo *repository.NotificationRepository
+	channelRepo      *repository.NotificationChannelRepository
+	httpClient       *http.Client
+}
+
+// NewNotificationDispatcher creates a new notification dispatcher
+func NewNotificationDispatcher(db *database.DB) *NotificationDispatcher {
+	return &NotificationDispatcher{
+		db:               db,
+		notificationRepo: repository.NewNotificationRepository(db),
+		channelRepo:      repository.NewNotificationChannelRepository(db),
+		httpClient:       &http.Client{Timeout: channelSendTimeout},
+	}
+}
+
+// accountRecipient is a member of an account who may receive notifications
+type accountRecipient struct {
+	UserID       int64
+	Email        string
+	EmailEnabled bool
+	Locale       string // Saved locale, empty when never set
+}
+
+// Dispatch delivers a message to every member and channel of the account.
+// Individual delivery failures are logged and do not stop the fan-out.
+func (d *NotificationDispatcher) Dispatch(msg NotificationMessage) error {
+	recipients, err := d.getRecipients(msg.AccountID)
+	if err != nil {
+		return err
+	}
+
+	var smtpCfg SMTPConfig
+	smtpLoaded := false
+	delivered := false
+
+	for _, recipient := range recipients {
+		userID := sql.NullInt64{Int64: recipient.UserID, Valid: true}
+		local := msg.Localize(i18n.For(recipient.Locale))
+
+		if msg.DedupeKey != "" {
+			exists, err := d.notificationRepo.RecentlyNotified(userID, msg.Type, msg.DedupeKey, msg.DedupeHours)
+			if err != nil {
+				slog.Error("Failed to check existing notifications", "user_id", recipient.UserID, "err", err)
+				continue
+			}
+			if exists {
+				continue
+			}
+		}
+
+		notification := &models.Notification{
+			UserID:  userID,
+			Type:    msg.Type,
+			Title:   local.Title,
+			Message: local.Message,
+		}
+		if err := d.notificationRepo.Create(notification); err != nil {
+			slog.Error("Failed to create notification", "user_id", recipient.UserID, "err", err)
+			continue
+		}
+		delivered = true
+
+		if !recipient.EmailEnabled || recipient.Email == "" {
+			continue
+		}
+		if !smtpLoaded {
+			smtpCfg = LoadSMTPConfig(d.db)
+			smtpLoaded = true
+		}
+		if smtpCfg.IsConfigured() {
+			if err := SendEmail(smtpCfg, recipient.Email, local.Title, local.Message); err != nil {
+				slog.Error("Failed to email notification", "user_id", recipient.UserID, "err", err)
+			}
+		}
+	}
+
+	// Everyone already had this notification, so external channels did too
+	if !delivered && len(recipients) > 0 {
+		return nil
+	}
+
+	channels, err := d.channelRepo.ListEnabled(msg.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to list notification channels: %w", err)
+	}
+
+	for _, channel := range channels {
+		if err := d.SendToChannel(channel, msg); err != nil {
+			slog.Error("Failed to deliver notification to channel", "channel_id", channel.ID, "channel_type", channel.Type, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// SendToChannel delivers a message to a single channel and records the outcome
+func (d *NotificationDispatcher) SendToChannel(channel *models.NotificationChannel, msg NotificationMessage) error {
+	// Channels are shared by the account, so they get English
+	msg = msg.Localize(i18n.Default())
+	sender, err := NewChannelSender(channel, d.httpClient)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), channelSendTimeout)
+		err = sender.Send(ctx, msg)
+		cancel()
+	}
+
+	if recordErr := d.channelRepo.RecordDelivery(channel.ID, err); recordErr != nil {
+		slog.Error("Failed to record channel delivery", "channel_id", channel.ID, "err", recordErr)
+	}
+
+	return err
+}
+
+// getRecipients loads the members of an account along with their email and
+// language preferences
+func (d *NotificationDispatcher) getRecipients(accountID int64) ([]accountRecipient, error) {
+	query := `
+		SELECT am.user_id, COALESCE(u.email, ''), COALESCE(s.value, ''), COALESCE(p.locale, '')
+		FROM account_members am
+		JOIN users u ON u.id = am.user_id
+		LEFT JOIN user_settings s ON s.user_id = am.user_id AND s.key = 'enable_notifications'
+		LEFT JOIN user_preferences p ON p.user_id = am.user_id
+		WHERE am.account_id = ? AND u.is_active = TRUE
+	`
+	rows, err := d.db.Query(query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account members: %w", err)
+	}
+	defer rows.Close()
+
+	var recipients []accountRecipient
+	for rows.Next() {
+		var r accountRecipient
+		var emailPref string
+		if err := rows.Scan(&r.UserID, &r.Email, &emailPref, &r.Locale); err != nil {
+			return nil, fmt.Errorf("failed to scan account member: %w", err)
+		}
+		r.EmailEnabled = emailPref == "true"
+		recipients = append(recipients, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return recipients, nil
+}
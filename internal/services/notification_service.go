@@ -1,111 +1,111 @@
 package services
 
 import (
-	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"injection-tracker/internal/database"
 	"injection-tracker/internal/repository"
 )
 
+// ExpirationWarningDays is how far ahead of an expiration date warnings start
+const ExpirationWarningDays = 30
+
+// VialDiscardWarningHours is how far ahead of an open vial's beyond-use date
+// warnings start
+const VialDiscardWarningHours = 48
+
 // NotificationService handles the creation and management of notifications
 type NotificationService struct {
-	db                  *database.DB
-	notificationRepo    *repository.NotificationRepository
-	inventoryRepo       *repository.InventoryRepository
-	lowStockEnabled     bool
-	expirationEnabled   bool
+	db                *database.DB
+	notificationRepo  *repository.NotificationRepository
+	inventoryRepo     *repository.InventoryRepository
+	vialRepo          *repository.InventoryVialRepository
+	alertRepo         *repository.StockAlertRepository
+	dispatcher        *NotificationDispatcher
+	lowStockEnabled   bool
+	expirationEnabled bool
 }
 
 // NewNotificationService creates a new notification service
 func NewNotificationService(db *database.DB) *NotificationService {
 	return &NotificationService{
-		db:                  db,
-		notificationRepo:    repository.NewNotificationRepository(db),
-		inventoryRepo:       repository.NewInventoryRepository(db),
-		lowStockEnabled:     true,
-		expirationEnabled:   true,
+		db:                db,
+		notificationRepo:  repository.NewNotificationRepository(db),
+		inventoryRepo:     repository.NewInventoryRepository(db),
+		vialRepo:          repository.NewInventoryVialRepository(db),
+		alertRepo:         repository.NewStockAlertRepository(db),
+		dispatcher:        NewNotificationDispatcher(db),
+		lowStockEnabled:   true,
+		expirationEnabled: true,
 	}
 }
 
 // CheckAndCreateInventoryNotifications checks inventory and creates notifications for low stock and expiring items
 // This should be called periodically (e.g., daily or when inventory changes)
 func (s *NotificationService) CheckAndCreateInventoryNotifications(accountID int64) error {
-	log.Printf("Checking inventory notifications for account %d", accountID)
-
-	// Get all users in the account to send notifications
-	userIDs, err := s.getUserIDsForAccount(accountID)
-	if err != nil {
-		return fmt.Errorf("failed to get users for account: %w", err)
-	}
-
-	if len(userIDs) == 0 {
-		log.Printf("No users found for account %d", accountID)
-		return nil
-	}
+	slog.Debug("Checking inventory notifications", "account_id", accountID)
 
 	// Check low stock notifications
 	if s.lowStockEnabled {
-		if err := s.checkLowStockNotifications(accountID, userIDs); err != nil {
-			log.Printf("Error checking low stock notifications: %v", err)
+		if err := s.checkLowStockNotifications(accountID); err != nil {
+			slog.Error("Failed to check low stock notifications", "account_id", accountID, "err", err)
 		}
 	}
 
 	// Check expiration notifications
 	if s.expirationEnabled {
-		if err := s.checkExpirationNotifications(accountID, userIDs); err != nil {
-			log.Printf("Error checking expiration notifications: %v", err)
+		if err := s.checkExpirationNotifications(accountID); err != nil {
+			slog.Error("Failed to check expiration notifications", "account_id", accountID, "err", err)
+		}
+	}
+
+	// Open vials nearing their beyond-use date count as expiring stock
+	if s.expirationEnabled {
+		if err := s.checkVialDiscardNotifications(accountID); err != nil {
+			slog.Error("Failed to check vial discard notifications", "account_id", accountID, "err", err)
 		}
 	}
 
 	return nil
 }
 
-// checkLowStockNotifications creates notifications for low stock items
-func (s *NotificationService) checkLowStockNotifications(accountID int64, userIDs []int64) error {
-	lowStockItems, err := s.inventoryRepo.ListLowStock(accountID)
+// checkLowStockNotifications brings every item's stock alert up to date,
+// sending those that are due, and resolves alerts for items no longer
+// stocked
+func (s *NotificationService) checkLowStockNotifications(accountID int64) error {
+	items, err := s.inventoryRepo.List(accountID)
 	if err != nil {
-		return fmt.Errorf("failed to list low stock items: %w", err)
+		return fmt.Errorf("failed to list inventory items: %w", err)
 	}
 
-	for _, item := range lowStockItems {
-		if !item.LowStockThreshold.Valid {
-			continue
-		}
-
-		threshold := item.LowStockThreshold.Float64
-		severity := "warning"
-		if item.Quantity <= threshold/2 {
-			severity = "critical"
-		}
-
-		// Create notification for each user in the account
-		for _, userID := range userIDs {
-			userIDSQL := sql.NullInt64{Int64: userID, Valid: true}
-			err := s.notificationRepo.CreateLowStockNotification(
-				userIDSQL,
-				item.ItemType,
-				item.Quantity,
-				threshold,
-				severity,
-			)
-			if err != nil {
-				log.Printf("Failed to create low stock notification for user %d: %v", userID, err)
-			}
+	stocked := make(map[string]bool, len(items))
+	for _, item := range items {
+		stocked[item.ItemType] = true
+		if err := s.CheckStockAlert(accountID, item); err != nil {
+			slog.Error("Failed to check stock alert", "account_id", accountID, "item_type", item.ItemType, "err", err)
 		}
 	}
 
-	if len(lowStockItems) > 0 {
-		log.Printf("Created low stock notifications for %d items", len(lowStockItems))
+	active, err := s.alertRepo.ListActive(accountID)
+	if err != nil {
+		return fmt.Errorf("failed to list stock alerts: %w", err)
+	}
+	for _, alert := range active {
+		if stocked[alert.ItemType] {
+			continue
+		}
+		if err := s.alertRepo.Resolve(alert); err != nil {
+			slog.Error("Failed to resolve stock alert", "account_id", accountID, "item_type", alert.ItemType, "err", err)
+		}
 	}
 
 	return nil
 }
 
-// checkExpirationNotifications creates notifications for expiring or expired items
-func (s *NotificationService) checkExpirationNotifications(accountID int64, userIDs []int64) error {
+// checkExpirationNotifications dispatches notifications for expiring or expired items
+func (s *NotificationService) checkExpirationNotifications(accountID int64) error {
 	// Get all inventory items for the account
 	items, err := s.inventoryRepo.List(accountID)
 	if err != nil {
@@ -113,7 +113,7 @@ func (s *NotificationService) checkExpirationNotifications(accountID int64, user
 	}
 
 	now := time.Now()
-	warningDays := 30 // Warn 30 days before expiration
+	warningDays := ExpirationWarningDays
 
 	for _, item := range items {
 		if !item.ExpirationDate.Valid {
@@ -127,62 +127,75 @@ func (s *NotificationService) checkExpirationNotifications(accountID int64, user
 		isExpired := expirationDate.Before(now)
 		isExpiring := !isExpired && daysUntil <= warningDays
 
-		if isExpired || isExpiring {
-			// Create notification for each user in the account
-			for _, userID := range userIDs {
-				userIDSQL := sql.NullInt64{Int64: userID, Valid: true}
-				err := s.notificationRepo.CreateExpirationNotification(
-					userIDSQL,
-					item.ItemType,
-					expirationDate,
-					isExpired,
-				)
-				if err != nil {
-					log.Printf("Failed to create expiration notification for user %d: %v", userID, err)
-				}
-			}
+		if !isExpired && !isExpiring {
+			continue
+		}
+
+		priority := PriorityDefault
+		if isExpired {
+			priority = PriorityHigh
+		}
+
+		title, message, args := repository.ExpirationNotificationContent(item.ItemType, expirationDate, isExpired)
+		err := s.dispatcher.Dispatch(NotificationMessage{
+			AccountID:   accountID,
+			Type:        "expiration_warning",
+			Title:       title,
+			Message:     message,
+			Args:        args,
+			Priority:    priority,
+			DedupeKey:   item.ItemType,
+			DedupeHours: 24,
+		})
+		if err != nil {
+			slog.Error("Failed to dispatch expiration notification", "account_id", accountID, "item_type", item.ItemType, "err", err)
 		}
 	}
 
 	return nil
 }
 
-// getUserIDsForAccount retrieves all user IDs for a given account
-func (s *NotificationService) getUserIDsForAccount(accountID int64) ([]int64, error) {
-	query := `
-		SELECT user_id
-		FROM account_members
-		WHERE account_id = ?
-	`
-	rows, err := s.db.Query(query, accountID)
+// checkVialDiscardNotifications dispatches notifications for open vials
+// within VialDiscardWarningHours of their beyond-use date or past it
+func (s *NotificationService) checkVialDiscardNotifications(accountID int64) error {
+	now := time.Now()
+	vials, err := s.vialRepo.ListDueForDiscard(accountID, now.Add(VialDiscardWarningHours*time.Hour))
 	if err != nil {
-		return nil, fmt.Errorf("failed to query account members: %w", err)
+		return fmt.Errorf("failed to list vials due for discard: %w", err)
 	}
-	defer rows.Close()
 
-	var userIDs []int64
-	for rows.Next() {
-		var userID int64
-		if err := rows.Scan(&userID); err != nil {
-			return nil, fmt.Errorf("failed to scan user ID: %w", err)
+	for _, vial := range vials {
+		priority := PriorityDefault
+		if !vial.DiscardAt.Time.After(now) {
+			priority = PriorityHigh
 		}
-		userIDs = append(userIDs, userID)
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+		title, message, args := repository.VialDiscardNotificationContent(vial, now)
+		err := s.dispatcher.Dispatch(NotificationMessage{
+			AccountID:   accountID,
+			Type:        "expiration_warning",
+			Title:       title,
+			Message:     message,
+			Args:        args,
+			Priority:    priority,
+			DedupeKey:   repository.VialNotificationKey(vial.ID),
+			DedupeHours: 24,
+		})
+		if err != nil {
+			slog.Error("Failed to dispatch vial discard notification", "account_id", accountID, "vial_id", vial.ID, "err", err)
+		}
 	}
 
-	return userIDs, nil
+	return nil
 }
 
 // CheckAndCreateNotificationsForAllAccounts checks and creates notifications for all accounts
 // This can be called by a background worker or cron job
 func (s *NotificationService) CheckAndCreateNotificationsForAllAccounts() error {
-	log.Println("Checking notifications for all accounts")
+	slog.Debug("Checking notifications for all accounts")
 
-	// Get all account IDs
-	query := "SELECT id FROM accounts"
+	// Get the IDs of every account that isn't suspended
+	query := "SELECT id FROM accounts WHERE suspension IS NULL"
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return fmt.Errorf("failed to query accounts: %w", err)
@@ -193,12 +206,12 @@ func (s *NotificationService) CheckAndCreateNotificationsForAllAccounts() error
 	for rows.Next() {
 		var accountID int64
 		if err := rows.Scan(&accountID); err != nil {
-			log.Printf("Failed to scan account ID: %v", err)
+			slog.Error("Failed to scan account ID", "err", err)
 			continue
 		}
 
 		if err := s.CheckAndCreateInventoryNotifications(accountID); err != nil {
-			log.Printf("Failed to check notifications for account %d: %v", accountID, err)
+			slog.Error("Failed to check notifications", "account_id", accountID, "err", err)
 		}
 		accountCount++
 	}
@@ -207,19 +220,19 @@ func (s *NotificationService) CheckAndCreateNotificationsForAllAccounts() error
 		return fmt.Errorf("error iterating accounts: %w", err)
 	}
 
-	log.Printf("Completed notification check for %d accounts", accountCount)
+	slog.Info("Completed notification check", "accounts", accountCount)
 	return nil
 }
 
 // CleanupOldNotifications removes old read notifications (older than specified days)
 func (s *NotificationService) CleanupOldNotifications(daysOld int) error {
-	log.Printf("Cleaning up notifications older than %d days", daysOld)
+	slog.Debug("Cleaning up old notifications", "days", daysOld)
 
 	if err := s.notificationRepo.DeleteOldRead(daysOld); err != nil {
 		return fmt.Errorf("failed to delete old notifications: %w", err)
 	}
 
-	log.Println("Cleanup completed successfully")
+	slog.Debug("Notification cleanup completed")
 	return nil
 }
 
@@ -232,3 +245,33 @@ func (s *NotificationService) SetLowStockEnabled(enabled bool) {
 func (s *NotificationService) SetExpirationEnabled(enabled bool) {
 	s.expirationEnabled = enabled
 }
+
+// StartNotificationScheduler starts the background inventory notification checks
+func StartNotificationScheduler(db *database.DB) {
+	service := NewNotificationService(db)
+
+	RegisterScheduler("notifications", 6*time.Hour)
+	RunBackground(func() {
+		// Wait for server to fully start
+		if !SleepUnlessShutdown(30 * time.Second) {
+			return
+		}
+		RecordSchedulerRun("notifications", service.CheckAndCreateNotificationsForAllAccounts())
+
+		ticker := time.NewTicker(6 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				err := service.CheckAndCreateNotificationsForAllAccounts()
+				if cleanupErr := service.CleanupOldNotifications(30); err == nil {
+					err = cleanupErr
+				}
+				RecordSchedulerRun("notifications", err)
+			case <-shutdownChan:
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"fmt"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// Resources limited by quotas, as named in QuotaError
+const (
+	QuotaMembers           = "members"
+	QuotaAttachmentStorage = "attachment_storage"
+	QuotaBackup            = "backup"
+)
+
+// QuotaSettings are the limits the admin has set at one level. A nil limit
+// isn't set there: an account takes the site-wide limit instead, and a site
+// without one is unlimited. Zero is unlimited too, so an account can be let
+// off a site-wide limit.
+type QuotaSettings struct {
+	MaxMembers          *int64 `json:"max_members" validate:"min=0,max=100000"`
+	AttachmentStorageMB *int64 `json:"attachment_storage_mb" validate:"min=0,max=10000000"`
+	BackupMB            *int64 `json:"backup_mb" validate:"min=0,max=10000000"`
+}
+
+// QuotaLimits are the limits that apply to an account; zero is unlimited
+type QuotaLimits struct {
+	MaxMembers          int64 `json:"max_members"`
+	AttachmentStorageMB int64 `json:"attachment_storage_mb"`
+	BackupMB            int64 `json:"backup_mb"`
+}
+
+// NewQuotaSettings reads the limits set by a stored quota, or none for nil
+func NewQuotaSettings(quota *models.ResourceQuota) QuotaSettings {
+	var settings QuotaSettings
+	if quota == nil {
+		return settings
+	}
+	if quota.MaxMembers.Valid {
+		settings.MaxMembers = &quota.MaxMembers.Int64
+	}
+	if quota.AttachmentStorageMB.Valid {
+		settings.AttachmentStorageMB = &quota.AttachmentStorageMB.Int64
+	}
+	if quota.BackupMB.Valid {
+		settings.BackupMB = &quota.BackupMB.Int64
+	}
+	return settings
+}
+
+// ResolveQuotaLimits works out the limits for an account from the stored
+// quotas, its own limits taking precedence over the site-wide ones
+func ResolveQuotaLimits(quotas []*models.ResourceQuota, accountID int64) QuotaLimits {
+	var site, account QuotaSettings
+	for _, q := range quotas {
+		switch {
+		case !q.AccountID.Valid:
+			site = NewQuotaSettings(q)
+		case q.AccountID.Int64 == accountID:
+			account = NewQuotaSettings(q)
+		}
+	}
+
+	pick := func(own, fallback *int64) int64 {
+		if own != nil {
+			return *own
+		}
+		if fallback != nil {
+			return *fallback
+		}
+		return 0
+	}
+	return QuotaLimits{
+		MaxMembers:          pick(account.MaxMembers, site.MaxMembers),
+		AttachmentStorageMB: pick(account.AttachmentStorageMB, site.AttachmentStorageMB),
+		BackupMB:            pick(account.BackupMB, site.BackupMB),
+	}
+}
+
+// LoadQuotaLimits reads the limits that apply to an account
+func LoadQuotaLimits(db *database.DB, accountID int64) (QuotaLimits, error) {
+	quotas, err := repository.NewQuotaRepository(db).ForAccount(accountID)
+	if err != nil {
+		return QuotaLimits{}, err
+	}
+	return ResolveQuotaLimits(quotas, accountID), nil
+}
+
+// QuotaError is returned when something would take an account past one of
+// its limits. Its message is meant for the account's members.
+type QuotaError struct {
+	Resource string
+	Limit    int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf(e.Format(), e.Limit)
+}
+
+// Format is the error message with a verb for the limit, to be translated
+func (e *QuotaError) Format() string {
+	switch e.Resource {
+	case QuotaMembers:
+		return "This account has reached its limit of %d members"
+	case QuotaAttachmentStorage:
+		return "This account has used its %d MB of attachment storage"
+	default:
+		return "Backups of this account are limited to %d MB"
+	}
+}
+
+// CheckMemberQuota returns a QuotaError if the account can't take another
+// member. When inviting, pending invitations count as members already, so
+// an account can't hand out more invitations than it has room for.
+func CheckMemberQuota(db *database.DB, accountID int64, inviting bool) error {
+	limits, err := LoadQuotaLimits(db, accountID)
+	if err != nil || limits.MaxMembers == 0 {
+		return err
+	}
+	usage, err := repository.NewQuotaRepository(db).Usage(accountID)
+	if err != nil {
+		return err
+	}
+	members := usage.Members
+	if inviting {
+		members += usage.PendingInvitations
+	}
+	if members >= limits.MaxMembers {
+		return &QuotaError{Resource: QuotaMembers, Limit: limits.MaxMembers}
+	}
+	return nil
+}
+
+// CheckAttachmentQuota returns a QuotaError if storing size more bytes of
+// attachments would take the account past its storage limit
+func CheckAttachmentQuota(db *database.DB, accountID, size int64) error {
+	limits, err := LoadQuotaLimits(db, accountID)
+	if err != nil || limits.AttachmentStorageMB == 0 {
+		return err
+	}
+	usage, err := repository.NewQuotaRepository(db).Usage(accountID)
+	if err != nil {
+		return err
+	}
+	if usage.AttachmentBytes+size > limits.AttachmentStorageMB<<20 {
+		return &QuotaError{Resource: QuotaAttachmentStorage, Limit: limits.AttachmentStorageMB}
+	}
+	return nil
+}
+
+// CheckBackupQuota returns a QuotaError if a backup or import of size bytes
+// is larger than the account's backups may be
+func CheckBackupQuota(db *database.DB, accountID, size int64) error {
+	limits, err := LoadQuotaLimits(db, accountID)
+	if err != nil || limits.BackupMB == 0 {
+		return err
+	}
+	if size > limits.BackupMB<<20 {
+		return &QuotaError{Resource: QuotaBackup, Limit: limits.BackupMB}
+	}
+	return nil
+}
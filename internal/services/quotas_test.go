@@ -0,0 +1,79 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+func TestResolveQuotaLimits(t *testing.T) {
+	limit := func(v int64) sql.NullInt64 { return sql.NullInt64{Int64: v, Valid: true} }
+	quotas := []*models.ResourceQuota{
+		{MaxMembers: limit(5), AttachmentStorageMB: limit(100)},
+		{AccountID: limit(2), MaxMembers: limit(0), BackupMB: limit(10)},
+	}
+
+	if got := ResolveQuotaLimits(quotas, 1); got != (QuotaLimits{MaxMembers: 5, AttachmentStorageMB: 100}) {
+		t.Errorf("Expected the site-wide limits, got %+v", got)
+	}
+	// 0 lets the account off the site-wide member limit
+	if got := ResolveQuotaLimits(quotas, 2); got != (QuotaLimits{MaxMembers: 0, AttachmentStorageMB: 100, BackupMB: 10}) {
+		t.Errorf("Expected the account's own limits over the site-wide ones, got %+v", got)
+	}
+	if got := ResolveQuotaLimits(nil, 1); got != (QuotaLimits{}) {
+		t.Errorf("Expected no limits, got %+v", got)
+	}
+}
+
+func TestCheckQuotas(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash'), (2, 'member', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner'), (1, 2, 'member');
+		INSERT INTO attachments (account_id, original_filename, content_type, size_bytes, sha256, storage_key)
+		VALUES (1, 'a.png', 'image/png', 1048576, 'aa', 'aa.png');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+
+	// Nothing is limited until the admin sets a quota
+	if err := CheckMemberQuota(db, 1, true); err != nil {
+		t.Fatalf("Expected no member limit, got %v", err)
+	}
+
+	quota := &models.ResourceQuota{
+		MaxMembers:          sql.NullInt64{Int64: 2, Valid: true},
+		AttachmentStorageMB: sql.NullInt64{Int64: 2, Valid: true},
+		BackupMB:            sql.NullInt64{Int64: 1, Valid: true},
+	}
+	if err := repository.NewQuotaRepository(db).Set(quota); err != nil {
+		t.Fatalf("Failed to set quota: %v", err)
+	}
+
+	var quotaErr *QuotaError
+	if err := CheckMemberQuota(db, 1, true); !errors.As(err, &quotaErr) || quotaErr.Resource != QuotaMembers || quotaErr.Limit != 2 {
+		t.Errorf("Expected the member quota to be reached, got %v", err)
+	}
+	if err := CheckAttachmentQuota(db, 1, 1<<20); err != nil {
+		t.Errorf("Expected room for another MB of attachments, got %v", err)
+	}
+	if err := CheckAttachmentQuota(db, 1, 1<<20+1); !errors.As(err, &quotaErr) || quotaErr.Resource != QuotaAttachmentStorage {
+		t.Errorf("Expected the attachment quota to be exceeded, got %v", err)
+	}
+	if err := CheckBackupQuota(db, 1, 2<<20); !errors.As(err, &quotaErr) || quotaErr.Error() != "Backups of this account are limited to 1 MB" {
+		t.Errorf("Expected the backup quota to be exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"log/slog"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/repository"
+)
+
+// StartRateLimitPruner deletes expired rate limit counts from the database
+// once an hour
+func StartRateLimitPruner(db *database.DB) {
+	repo := repository.NewRateLimitRepository(db)
+
+	RunBackground(func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := repo.Prune(time.Now()); err != nil {
+					slog.Error("Failed to prune rate limits", "err", err)
+				}
+			case <-shutdownChan:
+				return
+			}
+		}
+	})
+}
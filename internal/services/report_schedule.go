@@ -0,0 +1,128 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+// NextReportRun returns the first time after `after` that a schedule is due,
+// reading its day and hour in loc
+func NextReportRun(s *models.ReportSchedule, loc *time.Location, after time.Time) time.Time {
+	local := after.In(loc)
+
+	if s.Frequency == "monthly" {
+		run := time.Date(local.Year(), local.Month(), s.DayOfMonth, s.Hour, 0, 0, 0, loc)
+		if !run.After(after) {
+			run = time.Date(local.Year(), local.Month()+1, s.DayOfMonth, s.Hour, 0, 0, 0, loc)
+		}
+		return run
+	}
+
+	days := (s.DayOfWeek - int(local.Weekday()) + 7) % 7
+	run := time.Date(local.Year(), local.Month(), local.Day()+days, s.Hour, 0, 0, 0, loc)
+	if !run.After(after) {
+		run = time.Date(local.Year(), local.Month(), local.Day()+days+7, s.Hour, 0, 0, 0, loc)
+	}
+	return run
+}
+
+// ReportPeriod returns the whole days a report sent at runAt covers: the
+// week or month up to the start of the day it is sent, in loc
+func ReportPeriod(frequency string, runAt time.Time, loc *time.Location) (time.Time, time.Time) {
+	local := runAt.In(loc)
+	end := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	if frequency == "monthly" {
+		return end.AddDate(0, -1, 0), end
+	}
+	return end.AddDate(0, 0, -7), end
+}
+
+// CoursePeriod is when a course was running; End is zero while it still is.
+// Schedule says which of its days injections are due on; nil is every day.
+type CoursePeriod struct {
+	Start    time.Time
+	End      time.Time
+	Schedule *models.CourseSchedule
+}
+
+// dueOn reports whether the course's schedule has an injection due on day,
+// midnight in its timezone. Intervals of under two days count as daily;
+// longer ones count whole days from the course's start.
+func (c CoursePeriod) dueOn(day time.Time) bool {
+	if c.Schedule == nil {
+		return true
+	}
+	if len(c.Schedule.Weekdays) > 0 {
+		for _, d := range c.Schedule.Weekdays {
+			if d == day.Weekday() {
+				return true
+			}
+		}
+		return false
+	}
+	every := c.Schedule.IntervalHours / 24
+	if every < 2 {
+		return true
+	}
+	start := time.Date(c.Start.Year(), c.Start.Month(), c.Start.Day(), 0, 0, 0, 0, day.Location())
+	days := int(math.Round(day.Sub(start).Hours() / 24))
+	return days >= 0 && days%every == 0
+}
+
+// InjectionAdherence counts the days from start up to end, in loc, on which
+// a running course had an injection due (expected) and how many of those
+// doses were given (injected). Injections are due daily unless the course
+// has a schedule; a dose counts as given by an injection on its day, or a
+// later one before the next dose's day while the course still runs.
+func InjectionAdherence(injections []time.Time, courses []CoursePeriod, start, end time.Time, loc *time.Location) (expected, injected int) {
+	injectedDays := map[string]bool{}
+	for _, t := range injections {
+		injectedDays[t.In(loc).Format("2006-01-02")] = true
+	}
+
+	first := start.In(loc)
+	last := end.In(loc)
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+	pending := false // A dose is due and not given yet
+	for day.Before(last) {
+		dayEnd := day.AddDate(0, 0, 1)
+		course := runningCourse(courses, day, dayEnd)
+		switch {
+		case course == nil:
+			pending = false
+		case course.dueOn(day):
+			expected++
+			pending = true
+		}
+		if pending && injectedDays[day.Format("2006-01-02")] {
+			injected++
+			pending = false
+		}
+		day = dayEnd
+	}
+	return expected, injected
+}
+
+// runningCourse returns the first of the courses that ran at some point in
+// the day from day to dayEnd, or nil if none did
+func runningCourse(courses []CoursePeriod, day, dayEnd time.Time) *CoursePeriod {
+	for i := range courses {
+		if courseRunning(courses[i:i+1], day, dayEnd) {
+			return &courses[i]
+		}
+	}
+	return nil
+}
+
+// courseRunning reports whether any of the courses ran at some point in the
+// day from day to dayEnd
+func courseRunning(courses []CoursePeriod, day, dayEnd time.Time) bool {
+	for _, c := range courses {
+		if c.Start.Before(dayEnd) && (c.End.IsZero() || !c.End.Before(day)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestNextReportRun(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	// Wednesday 2025-01-15, 10:00 in New York
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, loc)
+
+	weekly := &models.ReportSchedule{Frequency: "weekly", DayOfWeek: 1, Hour: 8}
+	if got := NextReportRun(weekly, loc, now); !got.Equal(time.Date(2025, 1, 20, 8, 0, 0, 0, loc)) {
+		t.Errorf("Expected next Monday 08:00, got %v", got)
+	}
+
+	sameDay := &models.ReportSchedule{Frequency: "weekly", DayOfWeek: 3, Hour: 9}
+	if got := NextReportRun(sameDay, loc, now); !got.Equal(time.Date(2025, 1, 22, 9, 0, 0, 0, loc)) {
+		t.Errorf("Expected a week later once today's hour has passed, got %v", got)
+	}
+
+	monthly := &models.ReportSchedule{Frequency: "monthly", DayOfMonth: 28, Hour: 8}
+	if got := NextReportRun(monthly, loc, now); !got.Equal(time.Date(2025, 1, 28, 8, 0, 0, 0, loc)) {
+		t.Errorf("Expected the 28th of this month, got %v", got)
+	}
+	monthly.DayOfMonth = 1
+	if got := NextReportRun(monthly, loc, now); !got.Equal(time.Date(2025, 2, 1, 8, 0, 0, 0, loc)) {
+		t.Errorf("Expected the 1st of next month, got %v", got)
+	}
+}
+
+func TestInjectionAdherence(t *testing.T) {
+	loc := time.UTC
+	start, end := ReportPeriod("weekly", time.Date(2025, 1, 8, 8, 0, 0, 0, loc), loc)
+
+	// The course started on the 3rd; two injections on the 4th count once
+	courses := []CoursePeriod{{Start: time.Date(2025, 1, 3, 0, 0, 0, 0, loc)}}
+	injections := []time.Time{
+		time.Date(2025, 1, 2, 9, 0, 0, 0, loc),
+		time.Date(2025, 1, 4, 9, 0, 0, 0, loc),
+		time.Date(2025, 1, 4, 21, 0, 0, 0, loc),
+		time.Date(2025, 1, 6, 9, 0, 0, 0, loc),
+	}
+
+	expected, injected := InjectionAdherence(injections, courses, start, end, loc)
+	if expected != 5 || injected != 2 {
+		t.Errorf("Expected 2 of 5 days, got %d of %d", injected, expected)
+	}
+
+	// On Mondays, Wednesdays and Fridays only scheduled days are expected,
+	// and Friday's dose given on Saturday still counts
+	courses[0].Schedule = &models.CourseSchedule{Weekdays: []time.Weekday{time.Monday, time.Wednesday, time.Friday}}
+	if expected, injected := InjectionAdherence(injections, courses, start, end, loc); expected != 2 || injected != 2 {
+		t.Errorf("Expected 2 of 2 scheduled days, got %d of %d", injected, expected)
+	}
+
+	if expected, _ := InjectionAdherence(injections, nil, start, end, loc); expected != 0 {
+		t.Errorf("Expected no days without a course, got %d", expected)
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SchedulerStatus is what a background scheduler has done since the server
+// started
+type SchedulerStatus struct {
+	Name      string     `json:"name"`
+	Interval  string     `json:"interval"`
+	StartedAt time.Time  `json:"started_at"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	Runs      int        `json:"runs"`
+	Failures  int        `json:"failures"`
+}
+
+var (
+	schedulersMu sync.Mutex
+	schedulers   = make(map[string]*SchedulerStatus)
+)
+
+// RegisterScheduler records that a scheduler has started and how often it
+// runs
+func RegisterScheduler(name string, interval time.Duration) {
+	schedulersMu.Lock()
+	defer schedulersMu.Unlock()
+	schedulers[name] = &SchedulerStatus{Name: name, Interval: interval.String(), StartedAt: time.Now()}
+}
+
+// RecordSchedulerRun records one run of a scheduler and what it returned
+func RecordSchedulerRun(name string, err error) {
+	schedulersMu.Lock()
+	defer schedulersMu.Unlock()
+	s, ok := schedulers[name]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	s.LastRun = &now
+	s.Runs++
+	s.LastError = ""
+	if err != nil {
+		s.Failures++
+		s.LastError = err.Error()
+	}
+}
+
+// SchedulerStatuses returns the status of every started scheduler, by name
+func SchedulerStatuses() []SchedulerStatus {
+	schedulersMu.Lock()
+	defer schedulersMu.Unlock()
+	statuses := make([]SchedulerStatus, 0, len(schedulers))
+	for _, s := range schedulers {
+		status := *s
+		if s.LastRun != nil {
+			lastRun := *s.LastRun
+			status.LastRun = &lastRun
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedulerStatus(t *testing.T) {
+	RegisterScheduler("test_scheduler", time.Hour)
+	RecordSchedulerRun("test_scheduler", errors.New("smtp unreachable"))
+	RecordSchedulerRun("test_scheduler", nil)
+	// Runs of schedulers that never started are ignored
+	RecordSchedulerRun("never_started", nil)
+
+	var found *SchedulerStatus
+	for _, s := range SchedulerStatuses() {
+		if s.Name == "never_started" {
+			t.Error("Recorded a run for a scheduler that was never registered")
+		}
+		if s.Name == "test_scheduler" {
+			found = &s
+		}
+	}
+	if found == nil {
+		t.Fatal("Registered scheduler missing from SchedulerStatuses")
+	}
+	if found.Runs != 2 || found.Failures != 1 || found.LastError != "" || found.LastRun == nil || found.Interval != "1h0m0s" {
+		t.Errorf("Status = %+v, want 2 runs, 1 failure and the error cleared by the last run", found)
+	}
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+// SiteRotationRules controls how the next injection site is chosen
+type SiteRotationRules struct {
+	LookbackDays      int     // How much history to consider
+	MinDaysSameSite   int     // Minimum days before a spot may be reused
+	ReactionAvoidDays int     // Days to steer clear of spots with knots or reactions
+	SiteRadius        float64 // Distance (normalized 0-1) within which two injections share a spot
+	GridSize          int     // Candidate spots per axis on each side
+}
+
+// DefaultSiteRotationRules returns the rotation rules used when none are configured
+func DefaultSiteRotationRules() SiteRotationRules {
+	return SiteRotationRules{
+		LookbackDays:      30,
+		MinDaysSameSite:   14,
+		ReactionAvoidDays: 21,
+		SiteRadius:        0.15,
+		GridSize:          5,
+	}
+}
+
+// SiteSuggestion is the recommended side and (optionally) spot for the next injection
+type SiteSuggestion struct {
+	Side         string        `json:"side"`
+	SiteX        *float64      `json:"site_x,omitempty"`
+	SiteY        *float64      `json:"site_y,omitempty"`
+	LastSide     string        `json:"last_side,omitempty"`
+	Reasons      []string      `json:"reasons"`
+	AvoidedSites []AvoidedSite `json:"avoided_sites"`
+}
+
+// AvoidedSite is a recently used or problematic spot the suggestion stays away from
+type AvoidedSite struct {
+	InjectionID int64   `json:"injection_id"`
+	Side        string  `json:"side"`
+	SiteX       float64 `json:"site_x"`
+	SiteY       float64 `json:"site_y"`
+	DaysAgo     int     `json:"days_ago"`
+	Reason      string  `json:"reason"` // "recent" or "reaction"
+}
+
+// hasProblem reports whether an injection left knots or a site reaction
+func hasProblem(inj *models.Injection) bool {
+	if inj.HasKnots {
+		return true
+	}
+	return inj.SiteReaction.Valid && inj.SiteReaction.String != "" && inj.SiteReaction.String != "none"
+}
+
+func oppositeSide(side string) string {
+	if side == "left" {
+		return "right"
+	}
+	return "left"
+}
+
+// SuggestNextSite picks the next side and spot from injection history.
+// history may be in any order; injections outside the lookback window are ignored.
+//
+// The side alternates from the most recent injection unless that side has had
+// knots or a reaction within ReactionAvoidDays and the other side has not.
+// A spot is only suggested when past injections recorded coordinates
+// (advanced mode); it is the grid point furthest from recent and problem sites.
+func SuggestNextSite(history []*models.Injection, now time.Time, rules SiteRotationRules) *SiteSuggestion {
+	cutoff := now.AddDate(0, 0, -rules.LookbackDays)
+
+	var recent []*models.Injection
+	var last *models.Injection
+	for _, inj := range history {
+		if inj.Timestamp.Before(cutoff) || inj.Timestamp.After(now) {
+			continue
+		}
+		recent = append(recent, inj)
+		if last == nil || inj.Timestamp.After(last.Timestamp) {
+			last = inj
+		}
+	}
+
+	suggestion := &SiteSuggestion{
+		Side:         "left",
+		Reasons:      []string{},
+		AvoidedSites: []AvoidedSite{},
+	}
+
+	if last == nil {
+		suggestion.Reasons = append(suggestion.Reasons, "No recent injections; starting on the left side")
+	} else {
+		suggestion.LastSide = last.Side
+		suggestion.Side = oppositeSide(last.Side)
+		suggestion.Reasons = append(suggestion.Reasons, fmt.Sprintf("Alternating from the %s side used last", last.Side))
+	}
+
+	// Steer away from a side that is still reacting
+	reactionCutoff := now.AddDate(0, 0, -rules.ReactionAvoidDays)
+	problemSides := map[string]bool{}
+	for _, inj := range recent {
+		if hasProblem(inj) && !inj.Timestamp.Before(reactionCutoff) {
+			problemSides[inj.Side] = true
+		}
+	}
+	if problemSides[suggestion.Side] && !problemSides[oppositeSide(suggestion.Side)] {
+		suggestion.Reasons = append(suggestion.Reasons,
+			fmt.Sprintf("Switched to the %s side because the %s side had knots or a reaction recently",
+				oppositeSide(suggestion.Side), suggestion.Side))
+		suggestion.Side = oppositeSide(suggestion.Side)
+	}
+
+	// Collect spots on the chosen side that should be avoided
+	sameSiteCutoff := now.AddDate(0, 0, -rules.MinDaysSameSite)
+	scale := 1.0
+	hasCoords := false
+	for _, inj := range recent {
+		if !inj.SiteX.Valid || !inj.SiteY.Valid {
+			continue
+		}
+		hasCoords = true
+		// Coordinates stored as percentages are normalized to 0-1
+		if inj.SiteX.Float64 > 1 || inj.SiteY.Float64 > 1 {
+			scale = 100
+		}
+	}
+	if !hasCoords {
+		return suggestion
+	}
+
+	type spot struct {
+		x, y    float64
+		daysAgo float64
+		problem bool
+	}
+	var spots []spot
+	for _, inj := range recent {
+		if inj.Side != suggestion.Side || !inj.SiteX.Valid || !inj.SiteY.Valid {
+			continue
+		}
+
+		daysAgo := now.Sub(inj.Timestamp).Hours() / 24
+		problem := hasProblem(inj) && !inj.Timestamp.Before(reactionCutoff)
+		isRecent := !inj.Timestamp.Before(sameSiteCutoff)
+		if !problem && !isRecent {
+			continue
+		}
+
+		s := spot{x: inj.SiteX.Float64 / scale, y: inj.SiteY.Float64 / scale, daysAgo: daysAgo, problem: problem}
+		spots = append(spots, s)
+
+		reason := "recent"
+		if problem {
+			reason = "reaction"
+		}
+		suggestion.AvoidedSites = append(suggestion.AvoidedSites, AvoidedSite{
+			InjectionID: inj.ID,
+			Side:        inj.Side,
+			SiteX:       inj.SiteX.Float64,
+			SiteY:       inj.SiteY.Float64,
+			DaysAgo:     int(daysAgo),
+			Reason:      reason,
+		})
+	}
+
+	// Score every candidate by its distance to the nearest spot to avoid.
+	// Candidates inside an avoided radius are only used if nothing else is free.
+	grid := rules.GridSize
+	if grid < 2 {
+		grid = 2
+	}
+	bestScore := math.Inf(-1)
+	var bestX, bestY float64
+	for i := 0; i < grid; i++ {
+		for j := 0; j < grid; j++ {
+			// Keep a margin so suggestions never sit on the edge of the area
+			x := 0.15 + 0.7*float64(i)/float64(grid-1)
+			y := 0.15 + 0.7*float64(j)/float64(grid-1)
+
+			score := math.Sqrt2 // further than any two points in the unit square
+			for _, s := range spots {
+				d := math.Hypot(x-s.x, y-s.y)
+				if d < rules.SiteRadius {
+					// Blocked; prefer the oldest non-problem spot if everything is blocked
+					d -= 10
+					if s.problem {
+						d -= 10
+					}
+					d += s.daysAgo / 100
+				}
+				if d < score {
+					score = d
+				}
+			}
+
+			// Prefer the centre slightly when scores tie
+			score -= math.Hypot(x-0.5, y-0.5) / 1000
+			if score > bestScore {
+				bestScore, bestX, bestY = score, x, y
+			}
+		}
+	}
+
+	bestX = math.Round(bestX*scale*100) / 100
+	bestY = math.Round(bestY*scale*100) / 100
+	suggestion.SiteX = &bestX
+	suggestion.SiteY = &bestY
+	if len(spots) > 0 {
+		suggestion.Reasons = append(suggestion.Reasons,
+			fmt.Sprintf("Spot chosen away from %d recently used or irritated site(s)", len(spots)))
+	}
+
+	return suggestion
+}
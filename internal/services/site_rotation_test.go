@@ -0,0 +1,86 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func injectionAt(id int64, side string, daysAgo int, now time.Time) *models.Injection {
+	return &models.Injection{
+		ID:        id,
+		Side:      side,
+		Timestamp: now.AddDate(0, 0, -daysAgo),
+	}
+}
+
+func TestSuggestNextSite_NoHistory(t *testing.T) {
+	suggestion := SuggestNextSite(nil, time.Now(), DefaultSiteRotationRules())
+
+	if suggestion.Side != "left" {
+		t.Errorf("Expected left side with no history, got %s", suggestion.Side)
+	}
+	if suggestion.SiteX != nil || suggestion.SiteY != nil {
+		t.Error("Expected no coordinates without site history")
+	}
+}
+
+func TestSuggestNextSite_Alternates(t *testing.T) {
+	now := time.Now()
+	history := []*models.Injection{
+		injectionAt(1, "left", 2, now),
+		injectionAt(2, "right", 1, now),
+	}
+
+	suggestion := SuggestNextSite(history, now, DefaultSiteRotationRules())
+	if suggestion.Side != "left" {
+		t.Errorf("Expected left after a right injection, got %s", suggestion.Side)
+	}
+	if suggestion.LastSide != "right" {
+		t.Errorf("Expected last side right, got %s", suggestion.LastSide)
+	}
+}
+
+func TestSuggestNextSite_AvoidsReactingSide(t *testing.T) {
+	now := time.Now()
+	knotted := injectionAt(1, "left", 2, now)
+	knotted.HasKnots = true
+	history := []*models.Injection{
+		knotted,
+		injectionAt(2, "right", 1, now),
+	}
+
+	suggestion := SuggestNextSite(history, now, DefaultSiteRotationRules())
+	if suggestion.Side != "right" {
+		t.Errorf("Expected to stay on right while left has knots, got %s", suggestion.Side)
+	}
+}
+
+func TestSuggestNextSite_AvoidsRecentSpots(t *testing.T) {
+	now := time.Now()
+	rules := DefaultSiteRotationRules()
+
+	recent := injectionAt(1, "left", 2, now)
+	recent.SiteX = sql.NullFloat64{Float64: 0.5, Valid: true}
+	recent.SiteY = sql.NullFloat64{Float64: 0.5, Valid: true}
+	history := []*models.Injection{recent, injectionAt(2, "right", 1, now)}
+
+	suggestion := SuggestNextSite(history, now, rules)
+	if suggestion.Side != "left" {
+		t.Fatalf("Expected left side, got %s", suggestion.Side)
+	}
+	if suggestion.SiteX == nil || suggestion.SiteY == nil {
+		t.Fatal("Expected coordinates when history has sites")
+	}
+
+	dx := *suggestion.SiteX - 0.5
+	dy := *suggestion.SiteY - 0.5
+	if dx*dx+dy*dy < rules.SiteRadius*rules.SiteRadius {
+		t.Errorf("Suggested spot (%.2f, %.2f) is too close to a recent site", *suggestion.SiteX, *suggestion.SiteY)
+	}
+	if len(suggestion.AvoidedSites) != 1 {
+		t.Errorf("Expected 1 avoided site, got %d", len(suggestion.AvoidedSites))
+	}
+}
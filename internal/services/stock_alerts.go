@@ -0,0 +1,148 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// StockAlertReminderInterval is how long an unacknowledged stock alert waits
+// before it is sent again at the same level
+const StockAlertReminderInterval = 24 * time.Hour
+
+// stockAlertRank orders alert levels by severity; no alert ranks lowest
+var stockAlertRank = map[string]int{
+	"":                        0,
+	models.StockAlertLow:      1,
+	models.StockAlertCritical: 2,
+	models.StockAlertOut:      3,
+}
+
+// StockAlertLevel is how far quantity has fallen against an item's low
+// stock threshold, or "" if it is above it
+func StockAlertLevel(quantity, threshold float64) string {
+	switch {
+	case quantity <= 0:
+		return models.StockAlertOut
+	case quantity <= threshold/2:
+		return models.StockAlertCritical
+	case quantity <= threshold:
+		return models.StockAlertLow
+	}
+	return ""
+}
+
+// StockAlertDue reports whether an item's alert should be sent now that it
+// is at level. A new alert or one that has escalated is always sent; an
+// acknowledged one is not repeated, and an unacknowledged one is repeated
+// once StockAlertReminderInterval has passed since it was last sent.
+func StockAlertDue(active *models.StockAlert, level string, now time.Time) bool {
+	if level == "" {
+		return false
+	}
+	if active == nil || stockAlertRank[level] > stockAlertRank[active.Level] {
+		return true
+	}
+	if active.AcknowledgedAt.Valid {
+		return false
+	}
+	return !active.NotifiedAt.Valid || now.Sub(active.NotifiedAt.Time) >= StockAlertReminderInterval
+}
+
+// stockAlertSeverity maps an alert level to the severity the notification
+// content and inventory alerts use
+func stockAlertSeverity(level string) string {
+	switch level {
+	case models.StockAlertOut:
+		return "out"
+	case models.StockAlertCritical:
+		return "critical"
+	}
+	return "warning"
+}
+
+// lowStockAlertsEnabled reports whether the account has left its low stock
+// alerts setting on
+func lowStockAlertsEnabled(db *database.DB, accountID int64) bool {
+	value, err := repository.NewSettingsRepository(db).GetAccount(accountID, repository.AccountSettingLowStockAlerts)
+	return err != nil || value != "false"
+}
+
+// CheckStockAlert brings an item's stock alert up to date with its
+// quantity: raising one when it falls to its low stock threshold, escalating
+// it as it falls further, and resolving it once restocked. The alert is sent
+// through the dispatcher whenever StockAlertDue says so. Escalating clears
+// an acknowledgement, which only covers the level it was given at.
+func (s *NotificationService) CheckStockAlert(accountID int64, item *models.InventoryItem) error {
+	level := ""
+	if item.LowStockThreshold.Valid {
+		level = StockAlertLevel(item.Quantity, item.LowStockThreshold.Float64)
+	}
+
+	active, err := s.alertRepo.GetActive(accountID, item.ItemType)
+	if err == repository.ErrNotFound {
+		active = nil
+	} else if err != nil {
+		return err
+	}
+
+	if level == "" {
+		if active != nil {
+			return s.alertRepo.Resolve(active)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	due := StockAlertDue(active, level, now)
+	if active == nil {
+		active = &models.StockAlert{
+			AccountID: accountID,
+			ItemType:  item.ItemType,
+			Level:     level,
+			Quantity:  item.Quantity,
+			Threshold: item.LowStockThreshold.Float64,
+		}
+		if err := s.alertRepo.Raise(active); err != nil {
+			return err
+		}
+	} else {
+		if stockAlertRank[level] > stockAlertRank[active.Level] {
+			active.AcknowledgedAt = sql.NullTime{}
+			active.AcknowledgedBy = sql.NullInt64{}
+		}
+		active.Level = level
+		active.Quantity = item.Quantity
+		active.Threshold = item.LowStockThreshold.Float64
+		if err := s.alertRepo.Update(active); err != nil {
+			return err
+		}
+	}
+
+	if !due || !s.lowStockEnabled || !lowStockAlertsEnabled(s.db, accountID) {
+		return nil
+	}
+
+	severity := stockAlertSeverity(level)
+	priority := PriorityDefault
+	if severity != "warning" {
+		priority = PriorityHigh
+	}
+	title, message, args := repository.LowStockNotificationContent(item.ItemType, item.Quantity, active.Threshold, severity)
+	err = s.dispatcher.Dispatch(NotificationMessage{
+		AccountID: accountID,
+		Type:      "low_stock",
+		Title:     title,
+		Message:   message,
+		Args:      args,
+		Priority:  priority,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to dispatch stock alert: %w", err)
+	}
+	return s.alertRepo.MarkNotified(active, now)
+}
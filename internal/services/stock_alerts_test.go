@@ -0,0 +1,154 @@
+package services
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+func TestStockAlertLevel(t *testing.T) {
+	tests := []struct {
+		quantity, threshold float64
+		want                string
+	}{
+		{11, 10, ""},
+		{10, 10, models.StockAlertLow},
+		{6, 10, models.StockAlertLow},
+		{5, 10, models.StockAlertCritical},
+		{0.5, 10, models.StockAlertCritical},
+		{0, 10, models.StockAlertOut},
+		{-1, 10, models.StockAlertOut},
+	}
+	for _, tt := range tests {
+		if got := StockAlertLevel(tt.quantity, tt.threshold); got != tt.want {
+			t.Errorf("StockAlertLevel(%v, %v) = %q, want %q", tt.quantity, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestStockAlertDue(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	notified := func(ago time.Duration) *models.StockAlert {
+		return &models.StockAlert{
+			Level:      models.StockAlertLow,
+			NotifiedAt: sql.NullTime{Time: now.Add(-ago), Valid: true},
+		}
+	}
+	acknowledged := notified(48 * time.Hour)
+	acknowledged.AcknowledgedAt = sql.NullTime{Time: now.Add(-time.Hour), Valid: true}
+
+	tests := []struct {
+		name   string
+		active *models.StockAlert
+		level  string
+		want   bool
+	}{
+		{"above threshold", nil, "", false},
+		{"new alert", nil, models.StockAlertLow, true},
+		{"sent recently", notified(time.Hour), models.StockAlertLow, false},
+		{"reminder due", notified(StockAlertReminderInterval), models.StockAlertLow, true},
+		{"escalated", notified(time.Hour), models.StockAlertCritical, true},
+		{"acknowledged", acknowledged, models.StockAlertLow, false},
+		{"acknowledged then escalated", acknowledged, models.StockAlertOut, true},
+	}
+	for _, tt := range tests {
+		if got := StockAlertDue(tt.active, tt.level, now); got != tt.want {
+			t.Errorf("%s: StockAlertDue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckStockAlert(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'owner', 'hash');
+		INSERT INTO accounts (id) VALUES (1);
+		INSERT INTO account_members (account_id, user_id, role) VALUES (1, 1, 'owner');
+	`); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+
+	service := NewNotificationService(db)
+	alerts := repository.NewStockAlertRepository(db)
+	item := &models.InventoryItem{ItemType: "swab", LowStockThreshold: sql.NullFloat64{Float64: 10, Valid: true}}
+	sent := func() int {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE type = 'low_stock'`).Scan(&n); err != nil {
+			t.Fatalf("Failed to count notifications: %v", err)
+		}
+		return n
+	}
+	check := func(quantity float64) {
+		item.Quantity = quantity
+		if err := service.CheckStockAlert(1, item); err != nil {
+			t.Fatalf("CheckStockAlert(%v) failed: %v", quantity, err)
+		}
+	}
+
+	check(12)
+	if n := sent(); n != 0 {
+		t.Fatalf("Expected no alert above the threshold, got %d", n)
+	}
+
+	check(8)
+	active, err := alerts.GetActive(1, "swab")
+	if err != nil || active.Level != models.StockAlertLow || active.NotifyCount != 1 {
+		t.Fatalf("Expected a low alert sent once, got %+v (%v)", active, err)
+	}
+
+	// Still low, and the reminder isn't due yet
+	check(7)
+	if n := sent(); n != 1 {
+		t.Errorf("Expected the low alert not to repeat, got %d notifications", n)
+	}
+
+	if _, err := alerts.Acknowledge(active.ID, 1, 1); err != nil {
+		t.Fatalf("Failed to acknowledge: %v", err)
+	}
+
+	// Falling to critical escalates and clears the acknowledgement
+	check(4)
+	active, err = alerts.GetActive(1, "swab")
+	if err != nil || active.Level != models.StockAlertCritical || active.AcknowledgedAt.Valid {
+		t.Fatalf("Expected an unacknowledged critical alert, got %+v (%v)", active, err)
+	}
+	if n := sent(); n != 2 {
+		t.Errorf("Expected the escalation to be sent, got %d notifications", n)
+	}
+
+	// Restocking resolves it, and the next shortfall raises a new alert
+	check(20)
+	if _, err := alerts.GetActive(1, "swab"); err != repository.ErrNotFound {
+		t.Errorf("Expected the alert resolved, got %v", err)
+	}
+	check(0)
+	next, err := alerts.GetActive(1, "swab")
+	if err != nil || next.ID == active.ID || next.Level != models.StockAlertOut {
+		t.Errorf("Expected a new out of stock alert, got %+v (%v)", next, err)
+	}
+
+	// The account setting silences delivery but alerts are still tracked
+	if _, err := db.Exec(`INSERT INTO account_settings (account_id, key, value) VALUES (1, 'low_stock_alerts', 'false')`); err != nil {
+		t.Fatalf("Failed to turn alerts off: %v", err)
+	}
+	item.ItemType = "gauze"
+	check(1)
+	if _, err := alerts.GetActive(1, "gauze"); err != nil {
+		t.Errorf("Expected a gauze alert tracked, got %v", err)
+	}
+	if n := sent(); n != 3 {
+		t.Errorf("Expected no notification with alerts off, got %d", n)
+	}
+}
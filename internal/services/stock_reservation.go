@@ -0,0 +1,95 @@
+package services
+
+import "time"
+
+const (
+	// DefaultReservationDays is how far ahead scheduled injections reserve stock
+	DefaultReservationDays = 14
+
+	// MaxReservationDays caps the reservation window
+	MaxReservationDays = 90
+)
+
+// ScheduledDose is an injection due on an active course and the medication
+// it will draw
+type ScheduledDose struct {
+	Due      time.Time
+	DoseML   float64
+	ItemType string // The course's medication item
+}
+
+// ReservableItem is one item's stock and what each scheduled injection takes
+// of it. A dose takes its DoseML of its own medication item instead of
+// PerInjection.
+type ReservableItem struct {
+	ItemType          string
+	Name              string
+	Unit              string
+	Quantity          float64
+	LowStockThreshold *float64
+	PerInjection      float64
+}
+
+// StockReservation is how much of an item the scheduled injections have
+// spoken for, and what is left uncommitted
+type StockReservation struct {
+	ItemType          string   `json:"item_type"`
+	Name              string   `json:"name"`
+	Unit              string   `json:"unit"`
+	Quantity          float64  `json:"quantity"`
+	Reserved          float64  `json:"reserved"`
+	Uncommitted       float64  `json:"uncommitted"` // Negative when the reservations can't all be met
+	LowStockThreshold *float64 `json:"low_stock_threshold,omitempty"`
+	Injections        int      `json:"injections"`
+	// ShortAt is when the first scheduled injection the stock can't cover
+	// falls due
+	ShortAt *time.Time `json:"short_at,omitempty"`
+}
+
+// Shortfall reports whether the reservations exceed the stock on hand
+func (s StockReservation) Shortfall() bool {
+	return s.ShortAt != nil
+}
+
+// BelowThreshold reports whether drawing the reservations would leave the
+// item at or under its low stock threshold
+func (s StockReservation) BelowThreshold() bool {
+	return s.LowStockThreshold != nil && s.Uncommitted <= *s.LowStockThreshold
+}
+
+// ReserveStock sets aside what each scheduled dose will use of each item, in
+// the order they fall due. Items the injections don't use are left out.
+func ReserveStock(items []ReservableItem, doses []ScheduledDose) []StockReservation {
+	reservations := []StockReservation{}
+	for _, item := range items {
+		res := StockReservation{
+			ItemType:          item.ItemType,
+			Name:              item.Name,
+			Unit:              item.Unit,
+			Quantity:          item.Quantity,
+			LowStockThreshold: item.LowStockThreshold,
+		}
+		for _, dose := range doses {
+			use := item.PerInjection
+			if dose.ItemType == item.ItemType {
+				use = dose.DoseML
+			}
+			if use <= 0 {
+				continue
+			}
+			res.Reserved += use
+			res.Injections++
+			if res.ShortAt == nil && roundRate(res.Reserved) > roundRate(item.Quantity) {
+				due := dose.Due
+				res.ShortAt = &due
+			}
+		}
+		if res.Injections == 0 {
+			continue
+		}
+		res.Reserved = roundRate(res.Reserved)
+		res.Uncommitted = roundRate(item.Quantity - res.Reserved)
+		reservations = append(reservations, res)
+	}
+	return reservations
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserveStock(t *testing.T) {
+	start := time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC)
+	var doses []ScheduledDose
+	for i := 0; i < 5; i++ {
+		// A taper drops the dose after the third injection
+		dose := 1.0
+		if i >= 3 {
+			dose = 0.5
+		}
+		doses = append(doses, ScheduledDose{Due: start.AddDate(0, 0, i), DoseML: dose, ItemType: "progesterone"})
+	}
+	threshold := 5.0
+	items := []ReservableItem{
+		{ItemType: "progesterone", Name: "Progesterone", Unit: "mL", Quantity: 3.5, PerInjection: 1},
+		{ItemType: "swab", Name: "Alcohol Swabs", Unit: "count", Quantity: 12, PerInjection: 2, LowStockThreshold: &threshold},
+		{ItemType: "gauze", Name: "Gauze", Unit: "count", Quantity: 30},
+	}
+
+	reservations := ReserveStock(items, doses)
+	if len(reservations) != 2 {
+		t.Fatalf("Expected reservations for the 2 items injections use, got %d", len(reservations))
+	}
+
+	// The medication follows the scheduled doses, not its per-injection amount
+	prog := reservations[0]
+	if prog.Reserved != 4 || prog.Uncommitted != -0.5 || prog.Injections != 5 {
+		t.Errorf("Expected 4 mL reserved leaving -0.5, got %v leaving %v over %d", prog.Reserved, prog.Uncommitted, prog.Injections)
+	}
+	if !prog.Shortfall() || !prog.ShortAt.Equal(doses[4].Due) {
+		t.Errorf("Expected the fifth injection to be short, got %v", prog.ShortAt)
+	}
+
+	swab := reservations[1]
+	if swab.Reserved != 10 || swab.Uncommitted != 2 || swab.Shortfall() {
+		t.Errorf("Expected 10 swabs reserved leaving 2, got %v leaving %v", swab.Reserved, swab.Uncommitted)
+	}
+	if !swab.BelowThreshold() {
+		t.Error("Expected the swabs to fall below their threshold once reserved")
+	}
+
+	if got := ReserveStock(items, nil); len(got) != 0 {
+		t.Errorf("Expected nothing reserved without scheduled injections, got %d", len(got))
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"math"
+
+	"injection-tracker/internal/models"
+)
+
+// StocktakeVariance is the difference between what was counted of an item
+// and what was recorded, summed over its lots when it was counted by lot
+type StocktakeVariance struct {
+	ItemType string
+	Recorded float64
+	Counted  float64
+	Variance float64
+	// Percent is the variance as a share of the recorded quantity; nil when
+	// nothing was recorded
+	Percent *float64
+	ByLot   bool
+	Counts  []*models.StocktakeCount
+}
+
+// Discrepancy reports whether the count differs from the record
+func (v StocktakeVariance) Discrepancy() bool {
+	return v.Variance != 0
+}
+
+// StocktakeVariances totals a stocktake's counts per item, keeping the
+// order the counts are in
+func StocktakeVariances(counts []*models.StocktakeCount) []StocktakeVariance {
+	var variances []StocktakeVariance
+	index := map[string]int{}
+	for _, c := range counts {
+		i, ok := index[c.ItemType]
+		if !ok {
+			i = len(variances)
+			index[c.ItemType] = i
+			variances = append(variances, StocktakeVariance{ItemType: c.ItemType})
+		}
+		v := &variances[i]
+		v.Recorded += c.RecordedQuantity
+		v.Counted += c.CountedQuantity
+		v.ByLot = v.ByLot || c.LotID.Valid
+		v.Counts = append(v.Counts, c)
+	}
+	for i := range variances {
+		v := &variances[i]
+		v.Recorded = roundRate(v.Recorded)
+		v.Counted = roundRate(v.Counted)
+		v.Variance = roundRate(v.Counted - v.Recorded)
+		if v.Recorded > 0 {
+			percent := math.Round(v.Variance/v.Recorded*1000) / 10
+			v.Percent = &percent
+		}
+	}
+	return variances
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+
+	"injection-tracker/internal/models"
+)
+
+func TestStocktakeVariances(t *testing.T) {
+	counts := []*models.StocktakeCount{
+		{ItemType: "gauze", RecordedQuantity: 0, CountedQuantity: 4},
+		{ItemType: "swab", LotID: sql.NullInt64{Int64: 1, Valid: true}, RecordedQuantity: 20, CountedQuantity: 18},
+		{ItemType: "swab", LotID: sql.NullInt64{Int64: 2, Valid: true}, RecordedQuantity: 20, CountedQuantity: 19},
+		{ItemType: "syringe", RecordedQuantity: 12, CountedQuantity: 12},
+	}
+
+	variances := StocktakeVariances(counts)
+	if len(variances) != 3 {
+		t.Fatalf("Expected 3 items, got %d", len(variances))
+	}
+
+	gauze := variances[0]
+	if gauze.Variance != 4 || gauze.Percent != nil {
+		t.Errorf("Expected a surplus of 4 with no percentage, got %v %v", gauze.Variance, gauze.Percent)
+	}
+
+	swab := variances[1]
+	if !swab.ByLot || len(swab.Counts) != 2 || swab.Recorded != 40 || swab.Variance != -3 {
+		t.Errorf("Expected the lots summed to a shortfall of 3 against 40, got %+v", swab)
+	}
+	if swab.Percent == nil || *swab.Percent != -7.5 {
+		t.Errorf("Expected -7.5%%, got %v", swab.Percent)
+	}
+
+	if variances[2].Discrepancy() {
+		t.Error("Expected syringes to match")
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// DefaultForecastLookbackDays is the window recent consumption is averaged over
+	DefaultForecastLookbackDays = 28
+
+	// DefaultReorderLeadTimeDays is how long a restock is assumed to take to arrive
+	DefaultReorderLeadTimeDays = 7
+)
+
+// SupplyItem is one inventory item and how it has been and will be used
+type SupplyItem struct {
+	ItemType     string
+	Name         string
+	Unit         string
+	Quantity     float64
+	PerInjection float64 // Planned use per injection on the active course
+	PerDay       float64 // Planned daily use by the medications taken from it
+	RecentUsage  float64 // Total used over the lookback window
+}
+
+// SupplyForecastParams describes the schedule the forecast projects forward
+type SupplyForecastParams struct {
+	InjectionsPerDay float64 // Active course's recent injection frequency; 0 if unknown
+	LookbackDays     int
+	LeadTimeDays     int
+	Now              time.Time
+}
+
+// SupplyForecast is the projected runway for one item
+type SupplyForecast struct {
+	ItemType        string     `json:"item_type"`
+	Name            string     `json:"name"`
+	Unit            string     `json:"unit"`
+	Quantity        float64    `json:"quantity"`
+	PlannedDailyUse float64    `json:"planned_daily_use"`
+	RecentDailyUse  float64    `json:"recent_daily_use"`
+	DailyUse        float64    `json:"daily_use"`
+	DaysOfSupply    *float64   `json:"days_of_supply,omitempty"`
+	RunOutDate      *time.Time `json:"run_out_date,omitempty"`
+	ReorderDate     *time.Time `json:"reorder_date,omitempty"`
+	ReorderNow      bool       `json:"reorder_now"`
+}
+
+// ForecastSupply projects how long each item will last. The daily rate is the
+// larger of the planned rate (injection frequency times per-injection use,
+// plus what medications' doses take each day) and the rate actually consumed
+// over the lookback window, so neither a change of schedule nor unplanned
+// losses are missed. Items with no expected use have no run-out date.
+func ForecastSupply(items []SupplyItem, params SupplyForecastParams) []SupplyForecast {
+	lookback := params.LookbackDays
+	if lookback <= 0 {
+		lookback = DefaultForecastLookbackDays
+	}
+	today := time.Date(params.Now.Year(), params.Now.Month(), params.Now.Day(), 0, 0, 0, 0, params.Now.Location())
+
+	forecasts := make([]SupplyForecast, 0, len(items))
+	for _, item := range items {
+		f := SupplyForecast{
+			ItemType:        item.ItemType,
+			Name:            item.Name,
+			Unit:            item.Unit,
+			Quantity:        item.Quantity,
+			PlannedDailyUse: roundRate(params.InjectionsPerDay*item.PerInjection + item.PerDay),
+			RecentDailyUse:  roundRate(math.Max(item.RecentUsage, 0) / float64(lookback)),
+		}
+		f.DailyUse = math.Max(f.PlannedDailyUse, f.RecentDailyUse)
+
+		if f.DailyUse > 0 {
+			days := math.Max(item.Quantity, 0) / f.DailyUse
+			days = math.Round(days*10) / 10
+			runOut := today.AddDate(0, 0, int(math.Floor(days)))
+			reorder := runOut.AddDate(0, 0, -params.LeadTimeDays)
+
+			f.DaysOfSupply = &days
+			f.RunOutDate = &runOut
+			f.ReorderDate = &reorder
+			f.ReorderNow = !reorder.After(today)
+		}
+		forecasts = append(forecasts, f)
+	}
+	return forecasts
+}
+
+// roundRate keeps daily rates to a readable precision
+func roundRate(rate float64) float64 {
+	return math.Round(rate*1000) / 1000
+}
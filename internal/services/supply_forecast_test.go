@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForecastSupply(t *testing.T) {
+	now := time.Date(2025, 3, 1, 15, 0, 0, 0, time.UTC)
+	items := []SupplyItem{
+		{ItemType: "progesterone", Unit: "mL", Quantity: 20, PerInjection: 1, RecentUsage: 14},
+		{ItemType: "swab", Unit: "count", Quantity: 50, PerInjection: 1, RecentUsage: 56},
+		{ItemType: "gauze", Unit: "count", Quantity: 10},
+	}
+
+	forecasts := ForecastSupply(items, SupplyForecastParams{
+		InjectionsPerDay: 1,
+		LookbackDays:     28,
+		LeadTimeDays:     7,
+		Now:              now,
+	})
+	if len(forecasts) != 3 {
+		t.Fatalf("Expected 3 forecasts, got %d", len(forecasts))
+	}
+
+	// Planned use (1/day) exceeds recent use (0.5/day)
+	prog := forecasts[0]
+	if prog.DailyUse != 1 || prog.RecentDailyUse != 0.5 {
+		t.Errorf("Expected daily use 1 (recent 0.5), got %v (recent %v)", prog.DailyUse, prog.RecentDailyUse)
+	}
+	if prog.DaysOfSupply == nil || *prog.DaysOfSupply != 20 {
+		t.Fatalf("Expected 20 days of supply, got %v", prog.DaysOfSupply)
+	}
+	if got := prog.RunOutDate.Format("2006-01-02"); got != "2025-03-21" {
+		t.Errorf("Expected run-out 2025-03-21, got %s", got)
+	}
+	if got := prog.ReorderDate.Format("2006-01-02"); got != "2025-03-14" {
+		t.Errorf("Expected reorder 2025-03-14, got %s", got)
+	}
+	if prog.ReorderNow {
+		t.Error("Did not expect reorder now with 20 days of supply")
+	}
+
+	// Recent use (2/day) exceeds planned use, leaving less than the lead time
+	swab := forecasts[1]
+	if swab.DailyUse != 2 || *swab.DaysOfSupply != 25 {
+		t.Errorf("Expected 2/day and 25 days for swabs, got %v and %v", swab.DailyUse, *swab.DaysOfSupply)
+	}
+
+	// Unused items have no run-out date
+	if forecasts[2].DaysOfSupply != nil || forecasts[2].RunOutDate != nil || forecasts[2].ReorderNow {
+		t.Errorf("Expected no projection for unused gauze, got %+v", forecasts[2])
+	}
+
+	low := ForecastSupply([]SupplyItem{{ItemType: "syringe", Quantity: 3, PerInjection: 1}}, SupplyForecastParams{
+		InjectionsPerDay: 1,
+		LeadTimeDays:     7,
+		Now:              now,
+	})
+	if !low[0].ReorderNow {
+		t.Error("Expected reorder now when supply is shorter than the lead time")
+	}
+
+	// Tablets taken by a medication twice a day, with no injections planned
+	tablets := ForecastSupply([]SupplyItem{{ItemType: "letrozole", Quantity: 30, PerDay: 2}}, SupplyForecastParams{Now: now})
+	if tablets[0].PlannedDailyUse != 2 || tablets[0].DaysOfSupply == nil || *tablets[0].DaysOfSupply != 15 {
+		t.Errorf("Expected 15 days of tablets at 2/day, got %+v", tablets[0])
+	}
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"log/slog"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/repository"
+)
+
+// PurgeExpiredTrash deletes trash items that have been in the trash longer
+// than repository.TrashRetention, returning how many went
+func PurgeExpiredTrash(db *database.DB, now time.Time) (int64, error) {
+	return repository.NewTrashRepository(db).PurgeExpired(now.Add(-repository.TrashRetention))
+}
+
+// StartTrashRetentionScheduler empties expired items out of the trash
+// shortly after startup and then once a day
+func StartTrashRetentionScheduler(db *database.DB) {
+	run := func() {
+		purged, err := PurgeExpiredTrash(db, time.Now())
+		RecordSchedulerRun("trash_retention", err)
+		if err != nil {
+			slog.Error("Purging expired trash failed", "err", err)
+			return
+		}
+		if purged > 0 {
+			slog.Info("Purged expired trash items", "count", purged)
+		}
+	}
+
+	RegisterScheduler("trash_retention", 24*time.Hour)
+	RunBackground(func() {
+		// Wait for server to fully start
+		if !SleepUnlessShutdown(time.Minute) {
+			return
+		}
+		run()
+
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-shutdownChan:
+				return
+			}
+		}
+	})
+}
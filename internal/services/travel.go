@@ -0,0 +1,126 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// TripShiftPerDay is how far a gradual trip moves the injection clock each
+// day, towards the destination's and then back
+const TripShiftPerDay = time.Hour
+
+// Trip statuses, as TripStatus reports them
+const (
+	TripUpcoming  = "upcoming"
+	TripActive    = "active"
+	TripReturning = "returning" // Ended, with a gradual shift still moving back to home time
+	TripPast      = "past"
+)
+
+// TravelLocation is the timezone injections fall due in at at, given the
+// account's latest trip (nil if none) and home, the timezone used when no
+// trip applies. An instant trip switches to the destination's timezone
+// while it lasts. A gradual one moves TripShiftPerDay a day from the trip's
+// home timezone towards the destination's, starting with the first day,
+// then back again the same way once it ends, going through fixed offsets
+// in between. It reports whether the trip moved it away from home.
+func TravelLocation(trip *models.Trip, home *time.Location, at time.Time) (*time.Location, bool) {
+	if trip == nil || at.Before(trip.StartsAt) {
+		return home, false
+	}
+	local, err := time.LoadLocation(trip.Timezone)
+	if err != nil {
+		return home, false
+	}
+	if trip.Shift != models.TripShiftGradual {
+		if trip.Covers(at) {
+			return local, true
+		}
+		return home, false
+	}
+
+	from := home
+	if loc, err := time.LoadLocation(trip.HomeTimezone); err == nil {
+		from = loc
+	}
+	_, homeOffset := at.In(from).Zone()
+	_, localOffset := at.In(local).Zone()
+	diff := time.Duration(localOffset-homeOffset) * time.Second
+	if diff == 0 {
+		return local, trip.Covers(at)
+	}
+
+	// How far the clock has moved on day n of a shift
+	moved := func(n int) time.Duration {
+		step := time.Duration(n) * TripShiftPerDay
+		if step > diff.Abs() {
+			step = diff.Abs()
+		}
+		if diff < 0 {
+			return -step
+		}
+		return step
+	}
+	day := func(since time.Duration) int { return int(since/(24*time.Hour)) + 1 }
+
+	var shift time.Duration
+	if trip.Covers(at) {
+		shift = moved(day(at.Sub(trip.StartsAt)))
+	} else {
+		reached := moved(day(trip.EndsAt.Sub(trip.StartsAt) - time.Nanosecond))
+		back := moved(day(at.Sub(trip.EndsAt)))
+		if back.Abs() >= reached.Abs() {
+			return home, false
+		}
+		shift = reached - back
+	}
+	if shift == diff {
+		return local, true
+	}
+	return fixedOffset(homeOffset + int(shift/time.Second)), true
+}
+
+// fixedOffset is a timezone offset seconds from UTC, named e.g. "UTC+05:30"
+func fixedOffset(seconds int) *time.Location {
+	sign, abs := "+", seconds
+	if abs < 0 {
+		sign, abs = "-", -abs
+	}
+	return time.FixedZone(fmt.Sprintf("UTC%s%02d:%02d", sign, abs/3600, abs%3600/60), seconds)
+}
+
+// TripStatus says where at falls in a trip: before it, during it, in a
+// gradual shift back after it, or after it
+func TripStatus(trip *models.Trip, at time.Time) string {
+	switch {
+	case at.Before(trip.StartsAt):
+		return TripUpcoming
+	case trip.Covers(at):
+		return TripActive
+	}
+	if _, away := TravelLocation(trip, time.UTC, at); away {
+		return TripReturning
+	}
+	return TripPast
+}
+
+// LoadTravelLocation is TravelLocation for the account's latest trip to have
+// started by at. The trip is returned when it moves the timezone from home.
+func LoadTravelLocation(db *database.DB, accountID int64, home *time.Location, at time.Time) (*time.Location, *models.Trip, error) {
+	trip, err := repository.NewTripRepository(db).Latest(accountID, at)
+	if err == repository.ErrNotFound {
+		return home, nil, nil
+	}
+	if err != nil {
+		return home, nil, err
+	}
+	loc, away := TravelLocation(trip, home, at)
+	if !away {
+		return home, nil, nil
+	}
+	return loc, trip, nil
+}
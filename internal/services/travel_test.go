@@ -0,0 +1,71 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"injection-tracker/internal/models"
+)
+
+func TestTravelLocation(t *testing.T) {
+	home, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("No timezone data: %v", err)
+	}
+	// London (UTC+1 in July) to New York (UTC-4), five hours behind
+	trip := &models.Trip{
+		Timezone:     "America/New_York",
+		HomeTimezone: "Europe/London",
+		StartsAt:     time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+		EndsAt:       time.Date(2026, 7, 10, 0, 0, 0, 0, time.UTC),
+	}
+	at := func(day, hour int) time.Time { return time.Date(2026, 7, day, hour, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		shift  string
+		at     time.Time
+		offset int // Hours from UTC
+		away   bool
+		status string
+	}{
+		{"Before the trip", models.TripShiftInstant, at(30, 0).AddDate(0, -1, 0), 1, false, TripUpcoming},
+		{"Instant during", models.TripShiftInstant, at(2, 12), -4, true, TripActive},
+		{"Instant after", models.TripShiftInstant, at(10, 1), 1, false, TripPast},
+		{"Gradual first day", models.TripShiftGradual, at(1, 12), 0, true, TripActive},
+		{"Gradual third day", models.TripShiftGradual, at(3, 12), -2, true, TripActive},
+		{"Gradual shifted", models.TripShiftGradual, at(6, 12), -4, true, TripActive},
+		{"Gradual first day back", models.TripShiftGradual, at(10, 12), -3, true, TripReturning},
+		{"Gradual back home", models.TripShiftGradual, at(15, 12), 1, false, TripPast},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trip.Shift = tt.shift
+			loc, away := TravelLocation(trip, home, tt.at)
+			if _, offset := tt.at.In(loc).Zone(); offset != tt.offset*3600 || away != tt.away {
+				t.Errorf("Expected UTC%+d (away %v), got %s at UTC%+d (away %v)", tt.offset, tt.away, loc, offset/3600, away)
+			}
+			if status := TripStatus(trip, tt.at); status != tt.status {
+				t.Errorf("Expected %s, got %s", tt.status, status)
+			}
+		})
+	}
+
+	// Injections due at 19:00 follow the destination's clock once there
+	trip.Shift = models.TripShiftGradual
+	loc, _ := TravelLocation(trip, home, at(6, 12))
+	schedule := InjectionSchedule{
+		LastInjection:  time.Date(2026, 7, 5, 18, 0, 0, 0, time.UTC),
+		FrequencyHours: 24,
+		ReminderTime:   "19:00",
+		Location:       loc,
+	}
+	due := schedule.DueTimes(at(6, 0), at(8, 0), 1)
+	if len(due) != 1 || !due[0].Equal(at(6, 23)) {
+		t.Errorf("Expected 19:00 New York time, got %v", due)
+	}
+
+	if loc, away := TravelLocation(nil, home, at(2, 12)); loc != home || away {
+		t.Errorf("Expected home with no trip, got %s", loc)
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"strings"
+)
+
+// Inventory units. Stock is kept and consumed in the usage units, mL and
+// count; vials and boxes are the purchase units restocks can be entered in.
+const (
+	UnitML    = "mL"
+	UnitCount = "count"
+	UnitVial  = "vial"
+	UnitBox   = "box"
+)
+
+var (
+	// ErrUnknownUnit is returned for a unit name that isn't recognised
+	ErrUnknownUnit = errors.New("unknown unit")
+
+	// ErrIncompatibleUnit is returned when converting between units that
+	// don't measure the same thing, such as boxes into mL
+	ErrIncompatibleUnit = errors.New("units are not compatible")
+
+	// ErrNoPackSize is returned when converting a purchase unit without
+	// knowing how much one holds
+	ErrNoPackSize = errors.New("purchase unit size is not set")
+)
+
+// unitAliases maps the spellings accepted from clients to a unit
+var unitAliases = map[string]string{
+	"ml":          UnitML,
+	"milliliter":  UnitML,
+	"milliliters": UnitML,
+	"millilitre":  UnitML,
+	"millilitres": UnitML,
+	"count":       UnitCount,
+	"each":        UnitCount,
+	"ea":          UnitCount,
+	"unit":        UnitCount,
+	"units":       UnitCount,
+	"vial":        UnitVial,
+	"vials":       UnitVial,
+	"box":         UnitBox,
+	"boxes":       UnitBox,
+	"pack":        UnitBox,
+	"packs":       UnitBox,
+}
+
+// NormalizeUnit returns the canonical name of a unit, accepting plurals and
+// common abbreviations in any case
+func NormalizeUnit(name string) (string, error) {
+	if unit, ok := unitAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return unit, nil
+	}
+	return "", ErrUnknownUnit
+}
+
+// PurchaseUnitFor returns the purchase unit that holds a usage unit: vials
+// of mL and boxes of count
+func PurchaseUnitFor(usageUnit string) string {
+	switch usageUnit {
+	case UnitML:
+		return UnitVial
+	case UnitCount:
+		return UnitBox
+	}
+	return ""
+}
+
+// UnitConversion converts between an item type's usage unit and the unit it
+// is bought in. PerPurchase is the vial size in mL or the pack size in
+// count; zero if the item type doesn't have one.
+type UnitConversion struct {
+	UsageUnit    string
+	PurchaseUnit string
+	PerPurchase  float64
+}
+
+// ToUsage converts amount in unit to the usage unit. A purchase unit uses
+// PerPurchase.
+func (c UnitConversion) ToUsage(amount float64, unit string) (float64, error) {
+	unit, err := NormalizeUnit(unit)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case unit == c.UsageUnit:
+		return amount, nil
+	case unit != PurchaseUnitFor(c.UsageUnit):
+		return 0, ErrIncompatibleUnit
+	case c.PerPurchase <= 0:
+		return 0, ErrNoPackSize
+	}
+	return roundRate(amount * c.PerPurchase), nil
+}
+
+// FromUsage converts an amount in the usage unit to purchase units, which
+// may be fractional. It reports false if there is no purchase unit.
+func (c UnitConversion) FromUsage(amount float64) (float64, bool) {
+	if c.PurchaseUnit == "" || c.PerPurchase <= 0 {
+		return 0, false
+	}
+	return roundRate(amount / c.PerPurchase), true
+}
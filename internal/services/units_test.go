@@ -0,0 +1,50 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeUnit(t *testing.T) {
+	tests := map[string]string{
+		"mL":    UnitML,
+		"ML":    UnitML,
+		" ml ":  UnitML,
+		"each":  UnitCount,
+		"Vials": UnitVial,
+		"pack":  UnitBox,
+	}
+	for name, want := range tests {
+		got, err := NormalizeUnit(name)
+		if err != nil || got != want {
+			t.Errorf("NormalizeUnit(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := NormalizeUnit("gallon"); !errors.Is(err, ErrUnknownUnit) {
+		t.Errorf("Expected ErrUnknownUnit, got %v", err)
+	}
+}
+
+func TestUnitConversion(t *testing.T) {
+	vials := UnitConversion{UsageUnit: UnitML, PurchaseUnit: UnitVial, PerPurchase: 10}
+	if got, err := vials.ToUsage(3, "vials"); err != nil || got != 30 {
+		t.Errorf("Expected 3 vials to be 30 mL, got %v, %v", got, err)
+	}
+	if got, err := vials.ToUsage(2.5, "mL"); err != nil || got != 2.5 {
+		t.Errorf("Expected mL to pass through, got %v, %v", got, err)
+	}
+	if _, err := vials.ToUsage(1, "box"); !errors.Is(err, ErrIncompatibleUnit) {
+		t.Errorf("Expected boxes of mL to be rejected, got %v", err)
+	}
+	if got, ok := vials.FromUsage(25); !ok || got != 2.5 {
+		t.Errorf("Expected 25 mL to be 2.5 vials, got %v", got)
+	}
+
+	loose := UnitConversion{UsageUnit: UnitCount}
+	if _, err := loose.ToUsage(2, "box"); !errors.Is(err, ErrNoPackSize) {
+		t.Errorf("Expected a box without a pack size to be rejected, got %v", err)
+	}
+	if _, ok := loose.FromUsage(100); ok {
+		t.Error("Expected no purchase quantity without a purchase unit")
+	}
+}
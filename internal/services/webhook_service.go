@@ -0,0 +1,284 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"injection-tracker/internal/database"
+	"injection-tracker/internal/models"
+	"injection-tracker/internal/repository"
+)
+
+// Webhook event names
+const (
+	EventInjectionCreated  = "injection.created"
+	EventInventoryLowStock = "inventory.low_stock"
+	EventCourseClosed      = "course.closed"
+	EventMedicationMissed  = "medication.missed"
+)
+
+// WebhookEvents lists every event a webhook can subscribe to
+var WebhookEvents = []string{
+	EventInjectionCreated,
+	EventInventoryLowStock,
+	EventCourseClosed,
+	EventMedicationMissed,
+}
+
+// Headers sent with every webhook delivery (the signature uses WebhookSignatureHeader)
+const (
+	WebhookEventHeader    = "X-PTrack-Event"
+	WebhookDeliveryHeader = "X-PTrack-Delivery"
+)
+
+// webhookRetryDelays is the backoff between attempts; a delivery is marked
+// failed once every delay has been used.
+var webhookRetryDelays = []time.Duration{
+	1 * time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+}
+
+// WebhookPayload is the JSON body POSTed for every event
+type WebhookPayload struct {
+	Event      string      `json:"event"`
+	AccountID  int64       `json:"account_id"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookService queues, signs and delivers outbound webhook events
+type WebhookService struct {
+	repo       *repository.WebhookRepository
+	httpClient *http.Client
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(db *database.DB) *WebhookService {
+	return &WebhookService{
+		repo:       repository.NewWebhookRepository(db),
+		httpClient: &http.Client{Timeout: channelSendTimeout},
+	}
+}
+
+// IsValidWebhookEvent checks if the event name is supported
+func IsValidWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateWebhookURL checks that a webhook URL is an absolute http(s) URL
+func ValidateWebhookURL(raw string) error {
+	return validateChannelURL(raw)
+}
+
+// GenerateWebhookSecret creates a random signing secret for a new webhook
+func GenerateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// WebhookSubscribes reports whether the webhook is subscribed to an event
+func WebhookSubscribes(webhook *models.Webhook, event string) bool {
+	var events []string
+	if err := json.Unmarshal([]byte(webhook.Events), &events); err != nil {
+		return false
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Emit queues an event for every enabled webhook of the account that subscribes
+// to it and attempts the first delivery in the background. Errors are logged so
+// callers can fire and forget from request handlers.
+func (s *WebhookService) Emit(accountID int64, event string, data interface{}) {
+	webhooks, err := s.repo.ListEnabled(accountID)
+	if err != nil {
+		slog.Error("Failed to list webhooks", "account_id", accountID, "err", err)
+		return
+	}
+
+	var payload []byte
+	for _, webhook := range webhooks {
+		if !WebhookSubscribes(webhook, event) {
+			continue
+		}
+
+		if payload == nil {
+			payload, err = json.Marshal(WebhookPayload{
+				Event:      event,
+				AccountID:  accountID,
+				OccurredAt: time.Now().UTC(),
+				Data:       data,
+			})
+			if err != nil {
+				slog.Error("Failed to encode webhook payload", "event", event, "err", err)
+				return
+			}
+		}
+
+		delivery := &models.WebhookDelivery{
+			WebhookID: webhook.ID,
+			Event:     event,
+			Payload:   string(payload),
+			Status:    "pending",
+			// The first attempt happens right away; this is the fallback if it never completes
+			NextAttemptAt: sql.NullTime{Time: time.Now().UTC().Add(webhookRetryDelays[0]), Valid: true},
+		}
+		if err := s.repo.CreateDelivery(delivery); err != nil {
+			slog.Error("Failed to queue webhook delivery", "webhook_id", webhook.ID, "err", err)
+			continue
+		}
+
+		// If this is skipped because the server is shutting down, the retry
+		// worker sends it after the restart
+		RunBackground(func() { s.Deliver(webhook, delivery) })
+	}
+}
+
+// Deliver makes one delivery attempt and schedules a retry on failure
+func (s *WebhookService) Deliver(webhook *models.Webhook, delivery *models.WebhookDelivery) {
+	statusCode, err := s.post(webhook, delivery)
+
+	delivery.Attempts++
+	delivery.ResponseStatus = sql.NullInt64{Int64: int64(statusCode), Valid: statusCode != 0}
+
+	switch {
+	case err == nil:
+		delivery.Status = "success"
+		delivery.LastError = sql.NullString{}
+		delivery.NextAttemptAt = sql.NullTime{}
+		delivery.DeliveredAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	case delivery.Attempts > len(webhookRetryDelays):
+		delivery.Status = "failed"
+		delivery.LastError = sql.NullString{String: err.Error(), Valid: true}
+		delivery.NextAttemptAt = sql.NullTime{}
+	default:
+		delivery.Status = "pending"
+		delivery.LastError = sql.NullString{String: err.Error(), Valid: true}
+		delivery.NextAttemptAt = sql.NullTime{
+			Time:  time.Now().UTC().Add(webhookRetryDelays[delivery.Attempts-1]),
+			Valid: true,
+		}
+	}
+
+	if err := s.repo.UpdateDelivery(delivery); err != nil {
+		slog.Error("Failed to record webhook delivery", "delivery_id", delivery.ID, "err", err)
+	}
+}
+
+// post sends the signed payload and returns the HTTP status code received
+func (s *WebhookService) post(webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), channelSendTimeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "P-TRACK")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, body))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return resp.StatusCode, nil
+}
+
+// RetryDueDeliveries re-attempts pending deliveries whose backoff has elapsed
+func (s *WebhookService) RetryDueDeliveries() error {
+	deliveries, err := s.repo.ListDueDeliveries(time.Now(), 100)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		webhook, err := s.repo.FindByID(delivery.WebhookID)
+		if err != nil {
+			slog.Error("Failed to load webhook for delivery", "webhook_id", delivery.WebhookID, "delivery_id", delivery.ID, "err", err)
+			continue
+		}
+		if !webhook.IsEnabled {
+			delivery.Status = "failed"
+			delivery.LastError = sql.NullString{String: "webhook disabled", Valid: true}
+			delivery.NextAttemptAt = sql.NullTime{}
+			if err := s.repo.UpdateDelivery(delivery); err != nil {
+				slog.Error("Failed to record webhook delivery", "delivery_id", delivery.ID, "err", err)
+			}
+			continue
+		}
+		s.Deliver(webhook, delivery)
+	}
+
+	return nil
+}
+
+// StartWebhookWorker starts the background retry loop for webhook deliveries
+func StartWebhookWorker(db *database.DB) {
+	service := NewWebhookService(db)
+	repo := repository.NewWebhookRepository(db)
+
+	RegisterScheduler("webhook_retries", time.Minute)
+	RunBackground(func() {
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+
+		lastCleanup := time.Time{}
+		for {
+			select {
+			case <-ticker.C:
+			case <-shutdownChan:
+				return
+			}
+
+			err := service.RetryDueDeliveries()
+			if err != nil {
+				slog.Error("Failed to retry webhook deliveries", "err", err)
+			}
+			RecordSchedulerRun("webhook_retries", err)
+
+			// Keep the delivery log bounded
+			if time.Since(lastCleanup) > 24*time.Hour {
+				if err := repo.DeleteOldDeliveries(30); err != nil {
+					slog.Error("Failed to clean up webhook deliveries", "err", err)
+				}
+				lastCleanup = time.Now()
+			}
+		}
+	})
+}
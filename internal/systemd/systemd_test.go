@@ -0,0 +1,53 @@
+package systemd
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify(Ready); err != nil {
+		t.Errorf("Expected no error outside systemd, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	if err := Notify(Ready); err != nil {
+		t.Fatalf("Failed to notify: %v", err)
+	}
+
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read notification: %v", err)
+	}
+	if got := string(buf[:n]); got != Ready {
+		t.Errorf("Expected %q, got %q", Ready, got)
+	}
+}
+
+func TestListenerWithoutActivation(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+	ln, err := Listener()
+	if ln != nil || err != nil {
+		t.Errorf("Expected no listener, got %v, %v", ln, err)
+	}
+
+	// Sockets passed to another process aren't ours
+	t.Setenv("LISTEN_PID", strconv.Itoa(1))
+	t.Setenv("LISTEN_FDS", "1")
+	ln, err = Listener()
+	if ln != nil || err != nil {
+		t.Errorf("Expected no listener for another pid, got %v, %v", ln, err)
+	}
+}
@@ -0,0 +1,302 @@
+// Package validate checks and cleans request bodies before handlers use
+// them, so every endpoint trims, limits and reports bad input the same way.
+// Rules are given in struct tags next to the JSON names:
+//
+//	type CreateCourseRequest struct {
+//		Name  string  `json:"name" validate:"required,max=100"`
+//		Notes *string `json:"notes,omitempty" validate:"multiline,max=2000"`
+//	}
+//
+// Every string field, including pointers to strings, strings in slices and
+// the fields of nested structs, is trimmed of surrounding whitespace and
+// refused if it contains control characters. The rules are:
+//
+//	required   must be set: a non-empty string or slice, a non-zero number, or a non-nil pointer (to a non-empty string)
+//	min=N      strings and slices: at least N characters or items; numbers: at least N
+//	max=N      strings and slices: at most N characters or items; numbers: at most N
+//	oneof=a b  must be one of the space-separated values (strings and integers)
+//	itemmax=N  each string in a slice is at most N characters
+//	multiline  line breaks and tabs are allowed, for notes and other free text
+//	raw        left exactly as sent and not checked, for passwords and passphrases
+//	-          skipped
+//
+// Optional fields that are nil or empty are only checked for being set.
+// Checks that need more than one field or the database are made by the
+// handler, which can add them to a Validator.
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"injection-tracker/internal/respond"
+)
+
+// Validator collects the problems found with a request
+type Validator struct {
+	fields []respond.FieldError
+}
+
+// Add records a problem with field
+func (v *Validator) Add(field, message string) {
+	v.fields = append(v.fields, respond.Field(field, message))
+}
+
+// Check records a problem with field unless ok
+func (v *Validator) Check(ok bool, field, message string) {
+	if !ok {
+		v.Add(field, message)
+	}
+}
+
+// Valid reports whether no problems were recorded
+func (v *Validator) Valid() bool {
+	return len(v.fields) == 0
+}
+
+// Errors returns the problems recorded, in the order they were found
+func (v *Validator) Errors() []respond.FieldError {
+	return v.fields
+}
+
+// Struct cleans and checks the struct ptr points to against its validate
+// tags, recording problems in v by their JSON field names
+func (v *Validator) Struct(ptr interface{}) {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: Struct needs a pointer to a struct, got %T", ptr))
+	}
+	v.structFields(rv.Elem(), "")
+}
+
+// Struct cleans and checks the struct ptr points to, returning the problems
+// found
+func Struct(ptr interface{}) []respond.FieldError {
+	var v Validator
+	v.Struct(ptr)
+	return v.Errors()
+}
+
+// rules are the parsed validate tag of a field
+type rules struct {
+	required  bool
+	multiline bool
+	raw       bool
+	min, max  *float64
+	itemMax   int
+	oneOf     []string
+}
+
+func parseRules(tag string) rules {
+	var r rules
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			r.required = true
+		case "multiline":
+			r.multiline = true
+		case "raw":
+			r.raw = true
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				panic(fmt.Sprintf("validate: bad rule %q", rule))
+			}
+			if name == "min" {
+				r.min = &n
+			} else {
+				r.max = &n
+			}
+		case "itemmax":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("validate: bad rule %q", rule))
+			}
+			r.itemMax = n
+		case "oneof":
+			r.oneOf = strings.Fields(arg)
+		case "":
+		default:
+			panic(fmt.Sprintf("validate: unknown rule %q", rule))
+		}
+	}
+	return r
+}
+
+func (v *Validator) structFields(s reflect.Value, prefix string) {
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		name := fieldName(sf)
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			v.structFields(s.Field(i), prefix)
+			continue
+		}
+		v.value(s.Field(i), prefix+name, parseRules(tag))
+	}
+}
+
+// fieldName is the name a struct field has in JSON
+func fieldName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+func (v *Validator) value(f reflect.Value, name string, r rules) {
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			if r.required {
+				v.Add(name, "is required")
+			}
+			return
+		}
+		f = f.Elem()
+		if f.Kind() != reflect.String {
+			r.required = false // set is enough, even to zero
+		}
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		v.stringValue(f, name, r)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := f.Int()
+		if r.required && n == 0 {
+			v.Add(name, "is required")
+			return
+		}
+		v.number(float64(n), name, r)
+		if len(r.oneOf) > 0 && !contains(r.oneOf, strconv.FormatInt(n, 10)) {
+			v.Add(name, "must be "+choices(r.oneOf))
+		}
+	case reflect.Float32, reflect.Float64:
+		n := f.Float()
+		if r.required && n == 0 {
+			v.Add(name, "is required")
+			return
+		}
+		v.number(n, name, r)
+	case reflect.Struct:
+		v.structFields(f, name+".")
+	case reflect.Slice:
+		if f.Type().Elem().Kind() == reflect.Uint8 {
+			return // []byte is binary data
+		}
+		if r.required && f.Len() == 0 {
+			v.Add(name, "is required")
+			return
+		}
+		if r.min != nil && float64(f.Len()) < *r.min {
+			v.Add(name, fmt.Sprintf("must have at least %s items", format(*r.min)))
+		}
+		if r.max != nil && float64(f.Len()) > *r.max {
+			v.Add(name, fmt.Sprintf("must have at most %s items", format(*r.max)))
+		}
+		item := rules{multiline: r.multiline, raw: r.raw, oneOf: r.oneOf}
+		if r.itemMax > 0 {
+			max := float64(r.itemMax)
+			item.max = &max
+		}
+		for i := 0; i < f.Len(); i++ {
+			v.value(f.Index(i), fmt.Sprintf("%s[%d]", name, i), item)
+		}
+	}
+}
+
+func (v *Validator) stringValue(f reflect.Value, name string, r rules) {
+	s := f.String()
+	if !r.raw {
+		s = strings.TrimSpace(s)
+		if f.CanSet() {
+			f.SetString(s)
+		}
+		if hasControl(s, r.multiline) {
+			if r.multiline {
+				v.Add(name, "must not contain control characters")
+			} else {
+				v.Add(name, "must not contain line breaks or control characters")
+			}
+			return
+		}
+	}
+	if s == "" {
+		if r.required {
+			v.Add(name, "is required")
+		}
+		return
+	}
+	length := float64(utf8.RuneCountInString(s))
+	if r.min != nil && length < *r.min {
+		v.Add(name, fmt.Sprintf("must be at least %s characters", format(*r.min)))
+	}
+	if r.max != nil && length > *r.max {
+		v.Add(name, fmt.Sprintf("must be at most %s characters", format(*r.max)))
+	}
+	if len(r.oneOf) > 0 && !contains(r.oneOf, s) {
+		v.Add(name, "must be "+choices(r.oneOf))
+	}
+}
+
+func (v *Validator) number(n float64, name string, r rules) {
+	switch {
+	case r.min != nil && r.max != nil && (n < *r.min || n > *r.max):
+		v.Add(name, fmt.Sprintf("must be between %s and %s", format(*r.min), format(*r.max)))
+	case r.min != nil && n < *r.min:
+		v.Add(name, fmt.Sprintf("must be at least %s", format(*r.min)))
+	case r.max != nil && n > *r.max:
+		v.Add(name, fmt.Sprintf("must be at most %s", format(*r.max)))
+	}
+}
+
+// hasControl reports whether s has control characters, other than line
+// breaks and tabs when multiline
+func hasControl(s string, multiline bool) bool {
+	for _, c := range s {
+		if multiline && (c == '\n' || c == '\r' || c == '\t') {
+			continue
+		}
+		if unicode.IsControl(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// choices lists values as "a, b or c"
+func choices(values []string) string {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
+
+func format(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
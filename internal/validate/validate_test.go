@@ -0,0 +1,151 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"injection-tracker/internal/respond"
+)
+
+type item struct {
+	Name string `json:"name" validate:"required,max=5"`
+}
+
+type request struct {
+	Name     string   `json:"name" validate:"required,max=10"`
+	Notes    *string  `json:"notes,omitempty" validate:"multiline,max=20"`
+	Password string   `json:"password" validate:"raw,max=8"`
+	Side     string   `json:"side" validate:"oneof=left right"`
+	Pain     *int     `json:"pain,omitempty" validate:"min=1,max=10"`
+	Dose     *float64 `json:"dose,omitempty" validate:"required,min=0"`
+	Tags     []string `json:"tags" validate:"max=2,itemmax=3"`
+	Items    []item   `json:"items"`
+	Skipped  string   `json:"skipped" validate:"-"`
+}
+
+func valid() request {
+	dose := 0.0
+	return request{Name: "Test", Dose: &dose}
+}
+
+func messages(fields []respond.FieldError) map[string]string {
+	m := make(map[string]string)
+	for _, f := range fields {
+		m[f.Field] = f.Message
+	}
+	return m
+}
+
+func TestStructCleans(t *testing.T) {
+	notes := "  line one\n\tline two  "
+	req := valid()
+	req.Name = "  Test \t"
+	req.Notes = &notes
+	req.Password = " secret "
+	req.Tags = []string{" a ", "b"}
+	req.Items = []item{{Name: " x "}}
+	req.Skipped = "  \x00 "
+
+	if fields := Struct(&req); len(fields) != 0 {
+		t.Fatalf("Struct found problems with a valid request: %v", fields)
+	}
+	if req.Name != "Test" {
+		t.Errorf("Name = %q, want it trimmed", req.Name)
+	}
+	if *req.Notes != "line one\n\tline two" {
+		t.Errorf("Notes = %q, want it trimmed", *req.Notes)
+	}
+	if req.Password != " secret " {
+		t.Errorf("Password = %q, want it left as sent", req.Password)
+	}
+	if req.Tags[0] != "a" || req.Items[0].Name != "x" {
+		t.Errorf("Slices were not trimmed: %q, %q", req.Tags[0], req.Items[0].Name)
+	}
+	if req.Skipped != "  \x00 " {
+		t.Errorf("Skipped = %q, want it left alone", req.Skipped)
+	}
+}
+
+func TestStructProblems(t *testing.T) {
+	notes := "bell\a"
+	pain := 11
+	req := request{
+		Name:     "line\nbreak",
+		Notes:    &notes,
+		Password: "far too long",
+		Side:     "up",
+		Pain:     &pain,
+		Tags:     []string{"ok", "long", "three"},
+		Items:    []item{{Name: "fine"}, {}},
+	}
+
+	got := messages(Struct(&req))
+	want := map[string]string{
+		"name":          "must not contain line breaks or control characters",
+		"notes":         "must not contain control characters",
+		"password":      "must be at most 8 characters",
+		"side":          "must be left or right",
+		"pain":          "must be between 1 and 10",
+		"dose":          "is required",
+		"tags":          "must have at most 2 items",
+		"tags[1]":       "must be at most 3 characters",
+		"tags[2]":       "must be at most 3 characters",
+		"items[1].name": "is required",
+	}
+	for field, msg := range want {
+		if got[field] != msg {
+			t.Errorf("%s: got %q, want %q", field, got[field], msg)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got problems %v, want %v", got, want)
+	}
+}
+
+func TestStructRequiredString(t *testing.T) {
+	blank := "   "
+	req := struct {
+		Name *string `json:"name" validate:"required"`
+		ID   int64   `json:"id" validate:"required,min=1"`
+	}{Name: &blank, ID: -1}
+
+	got := messages(Struct(&req))
+	if got["name"] != "is required" {
+		t.Errorf("name: got %q, want a blank string to be missing", got["name"])
+	}
+	if got["id"] != "must be at least 1" {
+		t.Errorf("id: got %q", got["id"])
+	}
+}
+
+func TestStructPanicsOnBadTags(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "unknown rule") {
+			t.Errorf("expected a panic for an unknown rule, got %v", r)
+		}
+	}()
+	Struct(&struct {
+		Name string `validate:"requird"`
+	}{})
+}
+
+func TestValidator(t *testing.T) {
+	var v Validator
+	if !v.Valid() {
+		t.Fatal("a new Validator should be valid")
+	}
+	v.Check(true, "a", "is fine")
+	v.Check(false, "b", "is wrong")
+	v.Add("c", "is also wrong")
+	v.Struct(&item{})
+
+	fields := v.Errors()
+	if v.Valid() || len(fields) != 3 {
+		t.Fatalf("Errors = %v, want three problems", fields)
+	}
+	for i, want := range []string{"b", "c", "name"} {
+		if fields[i].Field != want {
+			t.Errorf("problem %d is for %q, want %q", i, fields[i].Field, want)
+		}
+	}
+}
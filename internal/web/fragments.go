@@ -0,0 +1,53 @@
+package web
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+
+	"injection-tracker/internal/i18n"
+)
+
+// fragmentsFS holds the partials HTMX swaps into pages. Unlike the pages
+// they're built into the binary, as handlers and their tests render them
+// without a templates directory.
+//
+//go:embed fragments/*.html
+var fragmentsFS embed.FS
+
+// fragments is every partial, each named by its file name, e.g.
+// "recent_symptoms.html"
+var fragments = template.Must(template.New("fragments").Funcs(helperFuncs(i18n.Default(), "")).ParseFS(fragmentsFS, "fragments/*.html"))
+
+// EmptyState is the data for "empty.html", shown when a list has nothing in
+// it. Message and Hint are in English and written in the reader's language.
+type EmptyState struct {
+	Message string
+	Hint    string
+}
+
+// Alert is the data for "alert.html", a prominent message above a form.
+// Kind is "success" or "danger"; Title is translated, Message is written as
+// given.
+type Alert struct {
+	Kind    string
+	Title   string
+	Message string
+}
+
+// RenderFragment renders the partial name with data, in p's language. Data
+// is escaped for where it lands in the HTML, so user input can be passed as
+// is.
+func RenderFragment(w io.Writer, p *i18n.Printer, name string, data interface{}) error {
+	tmpl := fragments.Lookup(name)
+	if tmpl == nil {
+		return fmt.Errorf("fragment not found: %s", name)
+	}
+	// Bind the helpers to the request's language on a copy, as Render does
+	tmpl, err := fragments.Clone()
+	if err != nil {
+		return err
+	}
+	return tmpl.Funcs(helperFuncs(p, "")).ExecuteTemplate(w, name, data)
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"injection-tracker/internal/i18n"
+)
+
+func TestRenderFragmentEscapes(t *testing.T) {
+	items := []map[string]interface{}{{
+		"Title":     "Injection (Left)",
+		"PainLevel": 3,
+		"When":      "2 hours ago",
+		"Notes":     `<script>alert("x")</script>`,
+	}}
+
+	var buf bytes.Buffer
+	if err := RenderFragment(&buf, i18n.Default(), "recent_activity.html", items); err != nil {
+		t.Fatalf("RenderFragment: %v", err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "<script>") {
+		t.Errorf("Notes were not escaped:\n%s", out)
+	}
+	if !strings.Contains(out, "&lt;script&gt;") || !strings.Contains(out, "Pain: 3/10") {
+		t.Errorf("Unexpected fragment:\n%s", out)
+	}
+}
+
+func TestRenderFragmentTranslates(t *testing.T) {
+	var buf bytes.Buffer
+	err := RenderFragment(&buf, i18n.For("es"), "empty.html", EmptyState{
+		Message: "No symptoms logged yet.",
+		Hint:    "Use the form above to log your first symptom.",
+	})
+	if err != nil {
+		t.Fatalf("RenderFragment: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "Todavía no hay síntomas registrados.") {
+		t.Errorf("Expected the empty state in Spanish, got:\n%s", out)
+	}
+
+	// Rendering in Spanish must not change the language of later renders
+	buf.Reset()
+	if err := RenderFragment(&buf, i18n.Default(), "empty.html", EmptyState{Message: "No recent changes."}); err != nil {
+		t.Fatalf("RenderFragment: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "No recent changes.") {
+		t.Errorf("Expected the empty state in English, got:\n%s", out)
+	}
+
+	if err := RenderFragment(&buf, i18n.Default(), "missing.html", nil); err == nil {
+		t.Error("Expected an error for an unknown fragment")
+	}
+}
@@ -1,8 +1,9 @@
 package web
 
 import (
-	"fmt"
 	"time"
+
+	"injection-tracker/internal/i18n"
 )
 
 // formatDate formats a date as YYYY-MM-DD
@@ -52,36 +53,36 @@ func painLevelEmoji(level int) string {
 	return "😫"
 }
 
-// timeAgo returns human-readable time difference
-func timeAgo(t time.Time) string {
+// timeAgo returns human-readable time difference, in p's language
+func timeAgo(p *i18n.Printer, t time.Time) string {
 	duration := time.Since(t)
 
 	if duration < time.Minute {
-		return "just now"
+		return p.T("just now")
 	} else if duration < time.Hour {
 		minutes := int(duration.Minutes())
 		if minutes == 1 {
-			return "1 minute ago"
+			return p.T("1 minute ago")
 		}
-		return fmt.Sprintf("%d minutes ago", minutes)
+		return p.T("%d minutes ago", minutes)
 	} else if duration < 24*time.Hour {
 		hours := int(duration.Hours())
 		if hours == 1 {
-			return "1 hour ago"
+			return p.T("1 hour ago")
 		}
-		return fmt.Sprintf("%d hours ago", hours)
+		return p.T("%d hours ago", hours)
 	} else if duration < 7*24*time.Hour {
 		days := int(duration.Hours() / 24)
 		if days == 1 {
-			return "yesterday"
+			return p.T("yesterday")
 		}
-		return fmt.Sprintf("%d days ago", days)
+		return p.T("%d days ago", days)
 	} else if duration < 30*24*time.Hour {
 		weeks := int(duration.Hours() / 24 / 7)
 		if weeks == 1 {
-			return "1 week ago"
+			return p.T("1 week ago")
 		}
-		return fmt.Sprintf("%d weeks ago", weeks)
+		return p.T("%d weeks ago", weeks)
 	}
 
 	return formatDate(t)
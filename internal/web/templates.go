@@ -4,7 +4,12 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"net/http"
 	"path/filepath"
+	"time"
+
+	"injection-tracker/internal/i18n"
+	"injection-tracker/internal/middleware"
 )
 
 var templates map[string]*template.Template
@@ -13,16 +18,9 @@ var templates map[string]*template.Template
 func InitTemplates() error {
 	templates = make(map[string]*template.Template)
 
-	// Define helper functions
-	funcMap := template.FuncMap{
-		"formatDate":     formatDate,
-		"formatDateTime": formatDateTime,
-		"formatTime":     formatTime,
-		"sideBadgeClass": sideBadgeClass,
-		"painLevelClass": painLevelClass,
-		"painLevelEmoji": painLevelEmoji,
-		"timeAgo":        timeAgo,
-	}
+	// Define helper functions, in English until Render binds them to a
+	// request's language
+	funcMap := helperFuncs(i18n.Default(), "")
 
 	// Get all page templates
 	pages, err := filepath.Glob(filepath.Join("templates", "pages", "*.html"))
@@ -66,16 +64,41 @@ func InitTemplates() error {
 	return nil
 }
 
-// Render renders a template with data
+// helperFuncs returns the template helper functions, writing text in p's
+// language. cspNonce is the nonce inline <script> and <style> blocks need.
+func helperFuncs(p *i18n.Printer, nonce string) template.FuncMap {
+	return template.FuncMap{
+		"formatDate":     formatDate,
+		"formatDateTime": formatDateTime,
+		"formatTime":     formatTime,
+		"sideBadgeClass": sideBadgeClass,
+		"painLevelClass": painLevelClass,
+		"painLevelEmoji": painLevelEmoji,
+		"timeAgo":        func(t time.Time) string { return timeAgo(p, t) },
+		"t":              p.T,
+		"lang":           p.Lang,
+		"cspNonce":       func() string { return nonce },
+	}
+}
+
+// Render renders a template with data, in the language of r and with its
+// Content Security Policy nonce
 // The name should be the page template name (e.g., "login.html")
 // This will execute base.html which includes the page's content block
-func Render(w io.Writer, name string, data interface{}) error {
+func Render(w io.Writer, r *http.Request, name string, data interface{}) error {
 	tmpl, ok := templates[name]
 	if !ok {
 		return fmt.Errorf("template not found: %s", name)
 	}
+	// The loaded templates are never executed themselves, so each render
+	// can clone one and bind the helpers to the request
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	funcs := helperFuncs(i18n.FromContext(r.Context()), middleware.CSPNonce(r.Context()))
 	// Execute base.html which will include the content block from the page
-	return tmpl.ExecuteTemplate(w, "base.html", data)
+	return tmpl.Funcs(funcs).ExecuteTemplate(w, "base.html", data)
 }
 
 // InitTestTemplates initializes minimal templates for testing
@@ -83,15 +106,7 @@ func InitTestTemplates() error {
 	templates = make(map[string]*template.Template)
 
 	// Define helper functions
-	funcMap := template.FuncMap{
-		"formatDate":     formatDate,
-		"formatDateTime": formatDateTime,
-		"formatTime":     formatTime,
-		"sideBadgeClass": sideBadgeClass,
-		"painLevelClass": painLevelClass,
-		"painLevelEmoji": painLevelEmoji,
-		"timeAgo":        timeAgo,
-	}
+	funcMap := helperFuncs(i18n.Default(), "")
 
 	// Create minimal mock templates for testing
 	// These templates just output the data as JSON for validation
@@ -0,0 +1,3 @@
+-- Undo 006: configured ntfy, Gotify and webhook channels are lost
+DROP TRIGGER IF EXISTS update_notification_channels_timestamp;
+DROP TABLE IF EXISTS notification_channels;
@@ -0,0 +1,35 @@
+-- ============================================
+-- MIGRATION 006: EXTERNAL NOTIFICATION CHANNELS
+-- ============================================
+-- Lets each account route notifications to services other than
+-- email: ntfy topics, Gotify servers, and generic webhooks.
+--
+-- config holds a JSON object whose shape depends on type:
+--   ntfy:    {"server_url": "https://ntfy.sh", "topic": "...", "token": "..."}
+--   gotify:  {"server_url": "https://gotify.example.com", "token": "..."}
+--   webhook: {"url": "https://...", "secret": "..."}
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK(type IN ('ntfy', 'gotify', 'webhook')),
+    name TEXT NOT NULL,
+    config TEXT NOT NULL DEFAULT '{}',
+    is_enabled BOOLEAN NOT NULL DEFAULT 1,
+    last_sent_at TIMESTAMP,
+    last_error TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_channel_name CHECK (length(trim(name)) > 0)
+);
+
+CREATE INDEX idx_notification_channels_account ON notification_channels(account_id);
+CREATE INDEX idx_notification_channels_account_enabled ON notification_channels(account_id, is_enabled);
+
+CREATE TRIGGER IF NOT EXISTS update_notification_channels_timestamp
+AFTER UPDATE ON notification_channels
+BEGIN
+    UPDATE notification_channels SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,4 @@
+-- Undo 007: registered webhooks and their delivery log are lost
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TRIGGER IF EXISTS update_webhooks_timestamp;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,5 @@
+-- Undo 008: injections and symptom logs lose their photos. The uploaded
+-- files are left in storage.
+ALTER TABLE symptom_logs DROP COLUMN attachment_id;
+ALTER TABLE injections DROP COLUMN attachment_id;
+DROP TABLE IF EXISTS attachments;
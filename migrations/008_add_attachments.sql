@@ -0,0 +1,31 @@
+-- ============================================
+-- MIGRATION 008: PHOTO ATTACHMENTS
+-- ============================================
+-- Uploaded images (e.g. photos of site reactions) are stored outside
+-- the database under content-addressed keys; this table keeps the
+-- metadata. Injections and symptom logs can reference one attachment.
+--
+-- storage_key is the sha256-derived key of the original file;
+-- thumbnail_key is NULL when no thumbnail could be generated.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    uploaded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    original_filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    sha256 TEXT NOT NULL,
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT,
+    width INTEGER,
+    height INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_attachments_account ON attachments(account_id, created_at DESC);
+CREATE INDEX idx_attachments_storage_key ON attachments(storage_key);
+
+ALTER TABLE injections ADD COLUMN attachment_id INTEGER REFERENCES attachments(id) ON DELETE SET NULL;
+ALTER TABLE symptom_logs ADD COLUMN attachment_id INTEGER REFERENCES attachments(id) ON DELETE SET NULL;
@@ -0,0 +1,4 @@
+-- Undo 009: doses are lost; every injection counts as 1 mL again
+ALTER TABLE injections DROP COLUMN dose_ml;
+ALTER TABLE courses DROP COLUMN concentration_mg_per_ml;
+ALTER TABLE courses DROP COLUMN dose_ml;
@@ -0,0 +1,18 @@
+-- ============================================
+-- MIGRATION 009: CONFIGURABLE DOSE AMOUNTS
+-- ============================================
+-- Courses carry the prescribed dose volume (mL) and, optionally, the
+-- concentration (mg/mL) so doses can be reported in mg. Each injection
+-- records the volume actually given; this is what inventory is
+-- decremented by.
+--
+-- Existing injections are backfilled with the 1.0 mL that was
+-- previously hardcoded.
+-- ============================================
+
+ALTER TABLE courses ADD COLUMN dose_ml REAL CHECK(dose_ml IS NULL OR dose_ml > 0);
+ALTER TABLE courses ADD COLUMN concentration_mg_per_ml REAL CHECK(concentration_mg_per_ml IS NULL OR concentration_mg_per_ml > 0);
+
+ALTER TABLE injections ADD COLUMN dose_ml REAL CHECK(dose_ml IS NULL OR dose_ml > 0);
+
+UPDATE injections SET dose_ml = 1.0 WHERE dose_ml IS NULL;
@@ -0,0 +1,43 @@
+-- Undo 010: courses are progesterone again. inventory_items gets back the
+-- fixed item_type list from 001, so stock of any other item is lost.
+DROP INDEX IF EXISTS idx_courses_compound;
+ALTER TABLE courses DROP COLUMN compound_id;
+DROP TRIGGER IF EXISTS update_compounds_timestamp;
+DROP TABLE IF EXISTS compounds;
+
+CREATE TABLE inventory_items_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_type TEXT NOT NULL CHECK(item_type IN (
+        'progesterone', 'draw_needle', 'injection_needle',
+        'syringe', 'swab', 'gauze'
+    )),
+    quantity REAL NOT NULL CHECK(quantity >= 0),
+    unit TEXT NOT NULL CHECK(unit IN ('mL', 'count')),
+    expiration_date DATE,
+    lot_number TEXT,
+    low_stock_threshold REAL CHECK(low_stock_threshold IS NULL OR low_stock_threshold >= 0),
+    notes TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+    CONSTRAINT uq_inventory_item_type UNIQUE(item_type)
+);
+
+INSERT INTO inventory_items_old (id, item_type, quantity, unit, expiration_date, lot_number, low_stock_threshold, notes, created_at, updated_at, account_id)
+SELECT id, item_type, quantity, unit, expiration_date, lot_number, low_stock_threshold, notes, created_at, updated_at, account_id
+FROM inventory_items
+WHERE item_type IN ('progesterone', 'draw_needle', 'injection_needle', 'syringe', 'swab', 'gauze');
+
+DROP TABLE inventory_items;
+ALTER TABLE inventory_items_old RENAME TO inventory_items;
+
+CREATE INDEX idx_inventory_type ON inventory_items(item_type);
+CREATE INDEX idx_inventory_expiration ON inventory_items(expiration_date);
+CREATE INDEX idx_inventory_items_account ON inventory_items(account_id);
+CREATE UNIQUE INDEX idx_inventory_items_type_account ON inventory_items(item_type, account_id);
+
+CREATE TRIGGER update_inventory_items_timestamp
+AFTER UPDATE ON inventory_items
+BEGIN
+    UPDATE inventory_items SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,88 @@
+-- ============================================
+-- MIGRATION 010: INJECTABLE COMPOUNDS
+-- ============================================
+-- Courses were implicitly progesterone. A compound describes what is
+-- being injected (name, concentration, route) and which inventory item
+-- its stock is tracked under, so an account can track estradiol,
+-- testosterone, B12, etc. alongside or instead of progesterone.
+--
+-- Every existing account gets a "Progesterone" compound mapped to the
+-- existing 'progesterone' inventory item, and existing courses are
+-- linked to it.
+--
+-- inventory_items is rebuilt to drop the fixed item_type list; the
+-- medication inventory type is now whatever the compound maps to.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS compounds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    concentration_mg_per_ml REAL CHECK(concentration_mg_per_ml IS NULL OR concentration_mg_per_ml > 0),
+    route TEXT NOT NULL DEFAULT 'intramuscular' CHECK(route IN ('intramuscular', 'subcutaneous', 'intradermal', 'other')),
+    inventory_item_type TEXT NOT NULL,
+    default_dose_ml REAL CHECK(default_dose_ml IS NULL OR default_dose_ml > 0),
+    notes TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT 1,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(account_id, name),
+    UNIQUE(account_id, inventory_item_type)
+);
+
+CREATE INDEX idx_compounds_account ON compounds(account_id);
+
+CREATE TRIGGER IF NOT EXISTS update_compounds_timestamp
+AFTER UPDATE ON compounds
+BEGIN
+    UPDATE compounds SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+ALTER TABLE courses ADD COLUMN compound_id INTEGER REFERENCES compounds(id) ON DELETE SET NULL;
+CREATE INDEX idx_courses_compound ON courses(compound_id);
+
+-- Seed progesterone for existing accounts and link their courses
+INSERT INTO compounds (account_id, name, route, inventory_item_type)
+SELECT id, 'Progesterone', 'intramuscular', 'progesterone' FROM accounts;
+
+UPDATE courses
+SET compound_id = (
+    SELECT c.id FROM compounds c
+    WHERE c.account_id = courses.account_id AND c.inventory_item_type = 'progesterone'
+)
+WHERE compound_id IS NULL;
+
+-- Rebuild inventory_items without the fixed item_type list
+CREATE TABLE inventory_items_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    quantity REAL NOT NULL CHECK(quantity >= 0),
+    unit TEXT NOT NULL CHECK(unit IN ('mL', 'count')),
+    expiration_date DATE,
+    lot_number TEXT,
+    low_stock_threshold REAL CHECK(low_stock_threshold IS NULL OR low_stock_threshold >= 0),
+    notes TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+    CONSTRAINT uq_inventory_item_type UNIQUE(item_type)
+);
+
+INSERT INTO inventory_items_new (id, item_type, quantity, unit, expiration_date, lot_number, low_stock_threshold, notes, created_at, updated_at, account_id)
+SELECT id, item_type, quantity, unit, expiration_date, lot_number, low_stock_threshold, notes, created_at, updated_at, account_id
+FROM inventory_items;
+
+DROP TABLE inventory_items;
+ALTER TABLE inventory_items_new RENAME TO inventory_items;
+
+CREATE INDEX idx_inventory_type ON inventory_items(item_type);
+CREATE INDEX idx_inventory_expiration ON inventory_items(expiration_date);
+CREATE INDEX idx_inventory_items_account ON inventory_items(account_id);
+CREATE UNIQUE INDEX idx_inventory_items_type_account ON inventory_items(item_type, account_id);
+
+CREATE TRIGGER update_inventory_items_timestamp
+AFTER UPDATE ON inventory_items
+BEGIN
+    UPDATE inventory_items SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,4 @@
+-- Undo 011: item type names, units and per-injection amounts are lost;
+-- stock on hand is kept
+DROP TRIGGER IF EXISTS update_inventory_item_types_timestamp;
+DROP TABLE IF EXISTS inventory_item_types;
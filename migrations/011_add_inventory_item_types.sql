@@ -0,0 +1,63 @@
+-- ============================================
+-- MIGRATION 011: USER-DEFINED INVENTORY ITEM TYPES
+-- ============================================
+-- Each account defines the item types it tracks: display name, unit,
+-- how much is used per injection and the reorder threshold. Together
+-- these are the account's consumption profile, which replaces the
+-- hardcoded list of supplies decremented on every injection.
+--
+-- The medication an injection uses is still decremented by its dose
+-- (see compounds), so medication types default to 0 per injection.
+--
+-- Existing accounts get the previous built-in types with the previous
+-- behaviour (one of each needle, syringe and swab per injection), plus
+-- a type for each compound and any other item already in stock.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS inventory_item_types (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    name TEXT NOT NULL,
+    unit TEXT NOT NULL CHECK(unit IN ('mL', 'count')),
+    decrement_per_injection REAL NOT NULL DEFAULT 0 CHECK(decrement_per_injection >= 0),
+    reorder_threshold REAL CHECK(reorder_threshold IS NULL OR reorder_threshold >= 0),
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(account_id, item_type)
+);
+
+CREATE INDEX idx_inventory_item_types_account ON inventory_item_types(account_id, sort_order);
+
+CREATE TRIGGER IF NOT EXISTS update_inventory_item_types_timestamp
+AFTER UPDATE ON inventory_item_types
+BEGIN
+    UPDATE inventory_item_types SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- Built-in types for every existing account, keeping current thresholds
+INSERT INTO inventory_item_types (account_id, item_type, name, unit, decrement_per_injection, reorder_threshold, sort_order)
+SELECT a.id, d.item_type, d.name, d.unit, d.per_injection,
+    (SELECT i.low_stock_threshold FROM inventory_items i WHERE i.item_type = d.item_type AND i.account_id = a.id),
+    d.sort_order
+FROM accounts a
+CROSS JOIN (
+    SELECT 'progesterone' AS item_type, 'Progesterone' AS name, 'mL' AS unit, 0 AS per_injection, 0 AS sort_order
+    UNION ALL SELECT 'draw_needle', 'Draw Needles', 'count', 1, 1
+    UNION ALL SELECT 'injection_needle', 'Injection Needles', 'count', 1, 2
+    UNION ALL SELECT 'syringe', 'Syringes', 'count', 1, 3
+    UNION ALL SELECT 'swab', 'Alcohol Swabs', 'count', 1, 4
+    UNION ALL SELECT 'gauze', 'Gauze Pads', 'count', 0, 5
+) d;
+
+-- Medications added as compounds
+INSERT OR IGNORE INTO inventory_item_types (account_id, item_type, name, unit, sort_order)
+SELECT account_id, inventory_item_type, name, 'mL', 10
+FROM compounds;
+
+-- Anything else already stocked
+INSERT OR IGNORE INTO inventory_item_types (account_id, item_type, name, unit, reorder_threshold, sort_order)
+SELECT account_id, item_type, item_type, unit, low_stock_threshold, 20
+FROM inventory_items
+WHERE account_id IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Undo 012: lots are lost and injections forget which one they drew from;
+-- the totals on hand are kept
+ALTER TABLE injections DROP COLUMN lot_id;
+DROP TABLE IF EXISTS inventory_lot_consumptions;
+DROP TRIGGER IF EXISTS update_inventory_lots_timestamp;
+DROP TABLE IF EXISTS inventory_lots;
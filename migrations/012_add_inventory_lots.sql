@@ -0,0 +1,58 @@
+-- ============================================
+-- MIGRATION 012: INVENTORY LOTS
+-- ============================================
+-- Stock is now received in lots, each with its own lot number,
+-- expiration date and remaining quantity. Injections and manual
+-- decrements consume the oldest lot first (FIFO), and each injection
+-- records the medication lot it was drawn from.
+--
+-- inventory_items.quantity remains the total on hand; its lot_number
+-- and expiration_date mirror the active (oldest non-empty) lot so the
+-- existing alerts warn about the stock actually in use.
+--
+-- Existing stock becomes a single lot per item.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS inventory_lots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    lot_number TEXT,
+    expiration_date DATE,
+    quantity_received REAL NOT NULL CHECK(quantity_received >= 0),
+    quantity_remaining REAL NOT NULL CHECK(quantity_remaining >= 0),
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    notes TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_lots_fifo ON inventory_lots(account_id, item_type, received_at, id);
+
+CREATE TRIGGER IF NOT EXISTS update_inventory_lots_timestamp
+AFTER UPDATE ON inventory_lots
+BEGIN
+    UPDATE inventory_lots SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- How much each injection took from each lot, so deleting an injection
+-- or correcting its dose can return stock to the lots it came from
+CREATE TABLE IF NOT EXISTS inventory_lot_consumptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lot_id INTEGER NOT NULL REFERENCES inventory_lots(id) ON DELETE CASCADE,
+    injection_id INTEGER REFERENCES injections(id) ON DELETE CASCADE,
+    amount REAL NOT NULL CHECK(amount > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_lot_consumptions_injection ON inventory_lot_consumptions(injection_id);
+CREATE INDEX IF NOT EXISTS idx_lot_consumptions_lot ON inventory_lot_consumptions(lot_id);
+
+-- The medication lot each injection was drawn from
+ALTER TABLE injections ADD COLUMN lot_id INTEGER REFERENCES inventory_lots(id) ON DELETE SET NULL;
+
+-- Existing stock becomes one lot per item
+INSERT INTO inventory_lots (account_id, item_type, lot_number, expiration_date, quantity_received, quantity_remaining, received_at)
+SELECT account_id, item_type, lot_number, expiration_date, quantity, quantity, COALESCE(updated_at, created_at, CURRENT_TIMESTAMP)
+FROM inventory_items
+WHERE account_id IS NOT NULL AND quantity > 0;
@@ -0,0 +1,5 @@
+-- Undo 013: purchase orders and their costs are lost; stock they added
+-- is kept
+DROP TABLE IF EXISTS purchase_order_items;
+DROP TRIGGER IF EXISTS update_purchase_orders_timestamp;
+DROP TABLE IF EXISTS purchase_orders;
@@ -0,0 +1,46 @@
+-- ============================================
+-- MIGRATION 013: PURCHASE ORDERS
+-- ============================================
+-- Records supply orders: who they were bought from, what and how much
+-- was ordered, what it cost and when it is expected. Marking an order
+-- received adds its items to inventory as restocks (one lot per line),
+-- and the recorded costs back spend reports, e.g. for insurance
+-- reimbursement.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS purchase_orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    supplier TEXT NOT NULL CHECK(length(supplier) BETWEEN 1 AND 200),
+    order_number TEXT,
+    status TEXT NOT NULL DEFAULT 'ordered' CHECK(status IN ('ordered', 'received', 'cancelled')),
+    ordered_at DATE NOT NULL,
+    expected_arrival DATE,
+    received_at TIMESTAMP,
+    shipping_cost REAL NOT NULL DEFAULT 0 CHECK(shipping_cost >= 0),
+    notes TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_account ON purchase_orders(account_id, ordered_at DESC);
+
+CREATE TRIGGER IF NOT EXISTS update_purchase_orders_timestamp
+AFTER UPDATE ON purchase_orders
+BEGIN
+    UPDATE purchase_orders SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TABLE IF NOT EXISTS purchase_order_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    quantity REAL NOT NULL CHECK(quantity > 0),
+    unit_cost REAL NOT NULL DEFAULT 0 CHECK(unit_cost >= 0),
+    lot_number TEXT,
+    expiration_date DATE,
+    lot_id INTEGER REFERENCES inventory_lots(id) ON DELETE SET NULL  -- set when received
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_order_items_order ON purchase_order_items(order_id);
@@ -0,0 +1,5 @@
+-- Undo 014: profile versions are lost, and with them which version each
+-- inventory change was made with
+ALTER TABLE inventory_history DROP COLUMN profile_version;
+DROP TABLE IF EXISTS consumption_profile_items;
+DROP TABLE IF EXISTS consumption_profiles;
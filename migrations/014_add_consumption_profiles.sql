@@ -0,0 +1,38 @@
+-- ============================================
+-- MIGRATION 014: CONSUMPTION PROFILE VERSIONS
+-- ============================================
+-- An account's consumption profile ("kit") is what one injection uses
+-- besides the medication: the item types with a per-injection
+-- decrement. inventory_item_types holds the current amounts; each
+-- distinct set of amounts is snapshotted here as a numbered version so
+-- inventory history can record which profile an injection was
+-- decremented with, even after the profile changes.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS consumption_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL CHECK(version > 0),
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(account_id, version)
+);
+
+CREATE TABLE IF NOT EXISTS consumption_profile_items (
+    profile_id INTEGER NOT NULL REFERENCES consumption_profiles(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL,
+    amount REAL NOT NULL CHECK(amount > 0),
+    PRIMARY KEY (profile_id, item_type)
+);
+
+ALTER TABLE inventory_history ADD COLUMN profile_version INTEGER;
+
+-- Version 1 for every existing account is its current profile
+INSERT INTO consumption_profiles (account_id, version)
+SELECT id, 1 FROM accounts;
+
+INSERT INTO consumption_profile_items (profile_id, item_type, amount)
+SELECT p.id, t.item_type, t.decrement_per_injection
+FROM consumption_profiles p
+JOIN inventory_item_types t ON t.account_id = p.account_id
+WHERE t.decrement_per_injection > 0;
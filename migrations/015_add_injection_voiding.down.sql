@@ -0,0 +1,4 @@
+-- Undo 015: drop the void columns, un-voiding every voided injection
+ALTER TABLE injections DROP COLUMN void_reason;
+ALTER TABLE injections DROP COLUMN voided_by;
+ALTER TABLE injections DROP COLUMN voided_at;
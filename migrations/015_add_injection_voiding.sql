@@ -0,0 +1,12 @@
+-- ============================================
+-- MIGRATION 015: VOIDED INJECTIONS
+-- ============================================
+-- Deleting an injection now voids it instead: the row is kept with who
+-- voided it, when and why, and its inventory is returned. Voided
+-- injections are left out of lists, stats and exports but stay in the
+-- audit trail, and can be restored.
+-- ============================================
+
+ALTER TABLE injections ADD COLUMN voided_at TIMESTAMP;
+ALTER TABLE injections ADD COLUMN voided_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE injections ADD COLUMN void_reason TEXT CHECK(void_reason IS NULL OR length(void_reason) <= 500);
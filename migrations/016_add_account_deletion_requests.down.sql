@@ -0,0 +1,2 @@
+-- Undo 016: pending deletion requests are lost
+DROP TABLE IF EXISTS account_deletion_requests;
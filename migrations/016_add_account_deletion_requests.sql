@@ -0,0 +1,17 @@
+-- ============================================
+-- MIGRATION 016: SELF-SERVICE ACCOUNT DELETION
+-- ============================================
+-- Users can delete themselves (and, if they are the account's only
+-- member, the account and all its data). Deletion is two-step: a
+-- request hands out a short-lived confirmation token, stored hashed
+-- here, which must be sent back to actually delete anything. A user
+-- has at most one pending request.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS account_deletion_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
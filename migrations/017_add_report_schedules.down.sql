@@ -0,0 +1,3 @@
+-- Undo 017
+DROP TRIGGER IF EXISTS update_report_schedules_timestamp;
+DROP TABLE IF EXISTS report_schedules;
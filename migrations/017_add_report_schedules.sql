@@ -0,0 +1,36 @@
+-- ============================================
+-- MIGRATION 017: SCHEDULED REPORT EMAILS
+-- ============================================
+-- Users can have a summary of recent injections, pain, adherence and
+-- stock emailed to themselves every week or month, optionally with the
+-- PDF report attached. Send times are in the user's timezone; the
+-- scheduler works from next_run_at, which is kept in UTC.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS report_schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL CHECK(length(name) BETWEEN 1 AND 100),
+    frequency TEXT NOT NULL CHECK(frequency IN ('weekly', 'monthly')),
+    day_of_week INTEGER NOT NULL DEFAULT 1 CHECK(day_of_week BETWEEN 0 AND 6),
+    day_of_month INTEGER NOT NULL DEFAULT 1 CHECK(day_of_month BETWEEN 1 AND 28),
+    hour INTEGER NOT NULL DEFAULT 8 CHECK(hour BETWEEN 0 AND 23),
+    course_id INTEGER REFERENCES courses(id) ON DELETE SET NULL,
+    include_pdf BOOLEAN NOT NULL DEFAULT 1,
+    is_enabled BOOLEAN NOT NULL DEFAULT 1,
+    next_run_at TIMESTAMP NOT NULL,
+    last_sent_at TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_schedules_user ON report_schedules(account_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_report_schedules_due ON report_schedules(is_enabled, next_run_at);
+
+CREATE TRIGGER IF NOT EXISTS update_report_schedules_timestamp
+AFTER UPDATE ON report_schedules
+BEGIN
+    UPDATE report_schedules SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
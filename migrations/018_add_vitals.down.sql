@@ -0,0 +1,3 @@
+-- Undo 018: recorded vitals and import mappings are lost
+DROP TABLE IF EXISTS health_import_mappings;
+DROP TABLE IF EXISTS vitals;
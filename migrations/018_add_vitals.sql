@@ -0,0 +1,36 @@
+-- ============================================
+-- MIGRATION 018: VITALS AND HEALTH APP IMPORT
+-- ============================================
+-- Body measurements (temperature, weight) recorded against an account,
+-- entered by hand or imported from Apple Health or Google Fit. Values are
+-- stored in one unit per type (degC, kg) whatever the source used. A vital
+-- is unique per account, type and time so re-importing the same export
+-- adds nothing.
+--
+-- health_import_mappings holds an account's overrides of the built-in
+-- mapping from health app record types to vitals and symptoms.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS vitals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    recorded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    timestamp TIMESTAMP NOT NULL,
+    type TEXT NOT NULL CHECK(type IN ('body_temperature', 'weight')),
+    value REAL NOT NULL,
+    unit TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT 'manual' CHECK(source IN ('manual', 'apple_health', 'google_fit')),
+    notes TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(account_id, type, timestamp)
+);
+
+CREATE INDEX IF NOT EXISTS idx_vitals_account_timestamp ON vitals(account_id, timestamp DESC);
+
+CREATE TABLE IF NOT EXISTS health_import_mappings (
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    source_type TEXT NOT NULL CHECK(length(source_type) BETWEEN 1 AND 100),
+    target TEXT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, source_type)
+);
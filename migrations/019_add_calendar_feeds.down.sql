@@ -0,0 +1,3 @@
+-- Undo 019: appointments and calendar feed tokens are lost
+DROP TABLE IF EXISTS appointments;
+DROP TABLE IF EXISTS calendar_tokens;
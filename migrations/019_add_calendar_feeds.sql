@@ -0,0 +1,40 @@
+-- ============================================
+-- MIGRATION 019: CALENDAR FEEDS AND APPOINTMENTS
+-- ============================================
+-- Calendar apps (Google Calendar, Apple Calendar) subscribe to a feed by
+-- URL and can't log in, so each feed URL carries a secret token. Only a
+-- SHA-256 hash of the token is stored; the token itself is shown once when
+-- created. Revoking a token stops its feed immediately. A token belongs to
+-- the user who created it so their timezone is used for the feed.
+--
+-- appointments are clinic visits, scans and the like, shown in calendar
+-- feeds alongside upcoming injections and medication times.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_tokens_user ON calendar_tokens(account_id, user_id);
+
+CREATE TABLE IF NOT EXISTS appointments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP,
+    location TEXT,
+    notes TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointments_account_starts ON appointments(account_id, starts_at);
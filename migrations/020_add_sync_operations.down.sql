@@ -0,0 +1,3 @@
+-- Undo 020: a client that resends an already applied batch will apply it
+-- again
+DROP TABLE IF EXISTS sync_operations;
@@ -0,0 +1,29 @@
+-- ============================================
+-- MIGRATION 020: OFFLINE SYNC OPERATIONS
+-- ============================================
+-- The PWA queues changes made offline and sends them to POST /api/v1/sync
+-- when it is back online. Each queued change carries a UUID chosen by the
+-- client. Applied operations are remembered here with their response, so a
+-- batch that is sent again (say after a dropped connection) returns the
+-- original results instead of logging the same injection twice.
+--
+-- status is 'pending' while an operation is being applied and 'applied'
+-- afterwards. Failed operations are not kept, so they can be retried.
+-- Rows are pruned after 30 days.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS sync_operations (
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    client_id TEXT NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'applied')),
+    status_code INTEGER,
+    response TEXT,
+    entity_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, client_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_operations_created ON sync_operations(created_at);
@@ -0,0 +1,21 @@
+-- Undo 021: back to the whole-second triggers from 001
+DROP TRIGGER IF EXISTS update_injections_timestamp;
+CREATE TRIGGER update_injections_timestamp
+AFTER UPDATE ON injections
+BEGIN
+    UPDATE injections SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+DROP TRIGGER IF EXISTS update_symptom_logs_timestamp;
+CREATE TRIGGER update_symptom_logs_timestamp
+AFTER UPDATE ON symptom_logs
+BEGIN
+    UPDATE symptom_logs SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+DROP TRIGGER IF EXISTS update_medications_timestamp;
+CREATE TRIGGER update_medications_timestamp
+AFTER UPDATE ON medications
+BEGIN
+    UPDATE medications SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,37 @@
+-- ============================================
+-- MIGRATION 021: SUB-SECOND UPDATED_AT
+-- ============================================
+-- updated_at on injections, symptom logs and medications is their version
+-- for ETags and If-Match. The triggers from 001 stamp it with
+-- CURRENT_TIMESTAMP, which only has whole seconds, so two edits within a
+-- second would share a version and the second could overwrite the first
+-- unnoticed.
+--
+-- The triggers now stamp milliseconds, and only when the UPDATE didn't set
+-- updated_at itself, so the time the application wrote (and returned to the
+-- client) is the one that is kept.
+-- ============================================
+
+DROP TRIGGER IF EXISTS update_injections_timestamp;
+CREATE TRIGGER update_injections_timestamp
+AFTER UPDATE ON injections
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE injections SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
+
+DROP TRIGGER IF EXISTS update_symptom_logs_timestamp;
+CREATE TRIGGER update_symptom_logs_timestamp
+AFTER UPDATE ON symptom_logs
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE symptom_logs SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
+
+DROP TRIGGER IF EXISTS update_medications_timestamp;
+CREATE TRIGGER update_medications_timestamp
+AFTER UPDATE ON medications
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE medications SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
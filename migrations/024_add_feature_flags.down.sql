@@ -0,0 +1,2 @@
+-- Undo 024: every feature flag is back to its default
+DROP TABLE IF EXISTS feature_flags;
@@ -0,0 +1,3 @@
+-- Undo 025: share links stop working and their access history is lost
+DROP TABLE IF EXISTS share_link_accesses;
+DROP TABLE IF EXISTS share_links;
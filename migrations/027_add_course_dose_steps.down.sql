@@ -0,0 +1,2 @@
+-- Undo 027: taper schedules are lost; recorded injection doses are kept
+DROP TABLE IF EXISTS course_dose_steps;
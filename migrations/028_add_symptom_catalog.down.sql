@@ -0,0 +1,4 @@
+-- Undo 028: the catalog goes; logs keep their JSON arrays of symptom keys
+DROP TABLE IF EXISTS symptom_log_items;
+DROP TRIGGER IF EXISTS update_symptom_catalog_timestamp;
+DROP TABLE IF EXISTS symptom_catalog;
@@ -0,0 +1,80 @@
+-- ============================================
+-- MIGRATION 028: SYMPTOM CATALOG
+-- ============================================
+-- The symptoms a log can tick were a fixed list in the frontend, stored on
+-- each log as a JSON array of keys. Each account now has its own catalog:
+-- a key, display name, category and scale type per symptom. 'presence'
+-- symptoms are just ticked; 'severity' ones are rated 1-10 when logged.
+-- Entries that old logs still reference are archived rather than deleted.
+--
+-- symptom_log_items links a log to the catalog entries it records, with
+-- the severity if rated. The JSON array on symptom_logs is kept, holding
+-- the same keys, for older clients.
+--
+-- Every existing account gets the built-in symptoms, plus an entry for any
+-- other value its logs already use, and those logs are linked to them.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS symptom_catalog (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    key TEXT NOT NULL CHECK(length(key) BETWEEN 1 AND 50),
+    name TEXT NOT NULL CHECK(length(name) BETWEEN 1 AND 100),
+    category TEXT NOT NULL DEFAULT 'general' CHECK(length(category) BETWEEN 1 AND 50),
+    scale_type TEXT NOT NULL DEFAULT 'presence' CHECK(scale_type IN ('presence', 'severity')),
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    is_archived BOOLEAN NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(account_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_symptom_catalog_account ON symptom_catalog(account_id, sort_order);
+
+CREATE TRIGGER IF NOT EXISTS update_symptom_catalog_timestamp
+AFTER UPDATE ON symptom_catalog
+BEGIN
+    UPDATE symptom_catalog SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TABLE IF NOT EXISTS symptom_log_items (
+    symptom_log_id INTEGER NOT NULL REFERENCES symptom_logs(id) ON DELETE CASCADE,
+    catalog_id INTEGER NOT NULL REFERENCES symptom_catalog(id) ON DELETE CASCADE,
+    severity INTEGER CHECK(severity IS NULL OR severity BETWEEN 1 AND 10),
+    PRIMARY KEY (symptom_log_id, catalog_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_symptom_log_items_catalog ON symptom_log_items(catalog_id);
+
+-- Built-in symptoms for every existing account
+INSERT INTO symptom_catalog (account_id, key, name, category, sort_order)
+SELECT a.id, d.key, d.name, d.category, d.sort_order
+FROM accounts a
+CROSS JOIN (
+    SELECT 'nausea' AS key, 'Nausea' AS name, 'digestive' AS category, 0 AS sort_order
+    UNION ALL SELECT 'fatigue', 'Fatigue', 'general', 1
+    UNION ALL SELECT 'headache', 'Headache', 'general', 2
+    UNION ALL SELECT 'mood_changes', 'Mood Changes', 'mood', 3
+    UNION ALL SELECT 'bloating', 'Bloating', 'digestive', 4
+    UNION ALL SELECT 'breast_tenderness', 'Tenderness', 'hormonal', 5
+    UNION ALL SELECT 'spotting', 'Spotting', 'hormonal', 6
+    UNION ALL SELECT 'hot_flashes', 'Hot Flashes', 'hormonal', 7
+    UNION ALL SELECT 'dizziness', 'Dizziness', 'general', 8
+    UNION ALL SELECT 'knots', 'Knots', 'injection_site', 9
+) d;
+
+-- Anything else existing logs recorded
+INSERT OR IGNORE INTO symptom_catalog (account_id, key, name, category, sort_order)
+SELECT DISTINCT c.account_id, replace(lower(trim(je.value)), ' ', '_'), trim(je.value), 'other', 20
+FROM symptom_logs sl
+JOIN courses c ON c.id = sl.course_id
+JOIN json_each(CASE WHEN json_valid(sl.symptoms) AND json_type(sl.symptoms) = 'array' THEN sl.symptoms ELSE '[]' END) je
+WHERE je.type = 'text' AND length(trim(je.value)) BETWEEN 1 AND 50;
+
+INSERT OR IGNORE INTO symptom_log_items (symptom_log_id, catalog_id)
+SELECT sl.id, sc.id
+FROM symptom_logs sl
+JOIN courses c ON c.id = sl.course_id
+JOIN json_each(CASE WHEN json_valid(sl.symptoms) AND json_type(sl.symptoms) = 'array' THEN sl.symptoms ELSE '[]' END) je
+JOIN symptom_catalog sc ON sc.account_id = c.account_id AND sc.key = replace(lower(trim(je.value)), ' ', '_')
+WHERE je.type = 'text';
@@ -0,0 +1,3 @@
+-- Undo 029
+DROP TRIGGER IF EXISTS update_wellness_checkins_timestamp;
+DROP TABLE IF EXISTS wellness_checkins;
@@ -0,0 +1,4 @@
+-- Undo 030: journal entries are lost
+DROP TRIGGER IF EXISTS update_journal_entries_timestamp;
+DROP TABLE IF EXISTS journal_entry_tags;
+DROP TABLE IF EXISTS journal_entries;
@@ -0,0 +1,40 @@
+-- ============================================
+-- MIGRATION 030: JOURNAL
+-- ============================================
+-- Free-form notes that don't fit a symptom log: clinic visit notes,
+-- questions for the next appointment, how a week went. The body is markdown
+-- and is stored as written. An entry can be linked to a course and to a
+-- calendar day, carries any number of tags, and can be pinned to the
+-- dashboard.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    course_id INTEGER REFERENCES courses(id) ON DELETE SET NULL,
+    entry_date DATE,
+    title TEXT,
+    body TEXT NOT NULL,
+    is_pinned BOOLEAN NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_account_created ON journal_entries(account_id, created_at DESC);
+
+-- Tags are stored lowercased, so "Clinic" and "clinic" are the same tag
+CREATE TABLE IF NOT EXISTS journal_entry_tags (
+    entry_id INTEGER NOT NULL REFERENCES journal_entries(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (entry_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entry_tags_tag ON journal_entry_tags(tag);
+
+CREATE TRIGGER IF NOT EXISTS update_journal_entries_timestamp
+AFTER UPDATE ON journal_entries
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE journal_entries SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
@@ -0,0 +1,3 @@
+-- Undo 031: lab results are lost
+DROP TRIGGER IF EXISTS update_lab_results_timestamp;
+DROP TABLE IF EXISTS lab_results;
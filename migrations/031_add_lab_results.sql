@@ -0,0 +1,34 @@
+-- ============================================
+-- MIGRATION 031: LAB RESULTS
+-- ============================================
+-- Blood work such as progesterone and estradiol levels, kept alongside the
+-- injections so dose changes can be read against them. The analyte is free
+-- text, matched without regard to case; the unit is whatever the lab
+-- reported, and the reference range is the lab's own when given. A result
+-- can be linked to the course it was drawn during.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS lab_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    course_id INTEGER REFERENCES courses(id) ON DELETE SET NULL,
+    analyte TEXT NOT NULL,
+    value REAL NOT NULL,
+    unit TEXT NOT NULL,
+    reference_low REAL,
+    reference_high REAL,
+    drawn_at TIMESTAMP NOT NULL,
+    notes TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_lab_results_account_drawn ON lab_results(account_id, drawn_at);
+
+CREATE TRIGGER IF NOT EXISTS update_lab_results_timestamp
+AFTER UPDATE ON lab_results
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE lab_results SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
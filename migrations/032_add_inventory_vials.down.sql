@@ -0,0 +1,4 @@
+-- Undo 032: per-vial volumes and opened dates are lost
+DROP TABLE IF EXISTS inventory_vial_consumptions;
+DROP TRIGGER IF EXISTS update_inventory_vials_timestamp;
+DROP TABLE IF EXISTS inventory_vials;
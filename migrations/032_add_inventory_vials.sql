@@ -0,0 +1,56 @@
+-- ============================================
+-- MIGRATION 032: INVENTORY VIALS
+-- ============================================
+-- Medication arrives in multi-dose vials, and once a vial's stopper is
+-- pierced it has to be thrown out after a set number of days whatever is
+-- left in it. Vials are now tracked one by one: each has a volume, the mL
+-- still in it, the lot it came from and its discard-after days.
+--
+-- A vial starts 'sealed'. Opening it sets opened_at and discard_at, its
+-- beyond-use date. Injections draw from the open vials, earliest opened
+-- first, opening the next sealed vial when they run short; a drained vial
+-- is 'empty'. Discarding a vial takes what was left in it out of stock.
+--
+-- inventory_items.quantity remains the total on hand. Stock received
+-- before vials were tracked isn't in any vial.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS inventory_vials (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    lot_id INTEGER REFERENCES inventory_lots(id) ON DELETE SET NULL,
+    label TEXT,
+    volume_ml REAL NOT NULL CHECK(volume_ml > 0),
+    remaining_ml REAL NOT NULL CHECK(remaining_ml >= 0),
+    discard_after_days INTEGER NOT NULL CHECK(discard_after_days > 0),
+    status TEXT NOT NULL DEFAULT 'sealed' CHECK(status IN ('sealed', 'open', 'empty', 'discarded')),
+    opened_at TIMESTAMP,
+    discard_at TIMESTAMP,
+    closed_at TIMESTAMP,
+    notes TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_vials_account ON inventory_vials(account_id, item_type, status);
+
+CREATE TRIGGER IF NOT EXISTS update_inventory_vials_timestamp
+AFTER UPDATE ON inventory_vials
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE inventory_vials SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
+
+-- How much each injection drew from each vial, so voiding it or
+-- correcting its dose can put the volume back
+CREATE TABLE IF NOT EXISTS inventory_vial_consumptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    vial_id INTEGER NOT NULL REFERENCES inventory_vials(id) ON DELETE CASCADE,
+    injection_id INTEGER NOT NULL REFERENCES injections(id) ON DELETE CASCADE,
+    amount REAL NOT NULL CHECK(amount > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_vial_consumptions_injection ON inventory_vial_consumptions(injection_id);
+CREATE INDEX IF NOT EXISTS idx_vial_consumptions_vial ON inventory_vial_consumptions(vial_id);
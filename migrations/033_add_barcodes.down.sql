@@ -0,0 +1,5 @@
+-- Undo 033: the NDC directory and saved barcode mappings are lost
+DROP TRIGGER IF EXISTS update_barcode_mappings_timestamp;
+DROP TABLE IF EXISTS barcode_mappings;
+DROP TRIGGER IF EXISTS update_ndc_products_timestamp;
+DROP TABLE IF EXISTS ndc_products;
@@ -0,0 +1,49 @@
+-- ============================================
+-- MIGRATION 033: BARCODES
+-- ============================================
+-- Restocking from the camera scanner resolves a scanned GS1 DataMatrix,
+-- UPC or NDC to one of the account's item types. The lot and expiration
+-- come from the barcode itself; what product it is comes from two places.
+--
+-- ndc_products is a directory of National Drug Codes shared by the whole
+-- instance and kept by admins, each with a suggested item type.
+-- barcode_mappings are an account's own "this code is that item" entries,
+-- saved from the restock form; they take precedence over the directory and
+-- cover codes that aren't NDCs at all.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS ndc_products (
+    ndc TEXT PRIMARY KEY CHECK(length(ndc) = 10),
+    product_name TEXT NOT NULL CHECK(length(product_name) BETWEEN 1 AND 200),
+    labeler TEXT,
+    item_type TEXT CHECK(item_type IS NULL OR length(item_type) BETWEEN 1 AND 50),
+    package_description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER IF NOT EXISTS update_ndc_products_timestamp
+AFTER UPDATE ON ndc_products
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE ndc_products SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE ndc = NEW.ndc;
+END;
+
+CREATE TABLE IF NOT EXISTS barcode_mappings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    code TEXT NOT NULL CHECK(length(code) BETWEEN 1 AND 100),
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    label TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(account_id, code)
+);
+
+CREATE TRIGGER IF NOT EXISTS update_barcode_mappings_timestamp
+AFTER UPDATE ON barcode_mappings
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE barcode_mappings SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
@@ -0,0 +1,3 @@
+-- Undo 034: item types lose their purchase units
+ALTER TABLE inventory_item_types DROP COLUMN units_per_purchase;
+ALTER TABLE inventory_item_types DROP COLUMN purchase_unit;
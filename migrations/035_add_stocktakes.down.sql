@@ -0,0 +1,33 @@
+-- Undo 035: stocktakes and their counts are lost; history entries they
+-- posted become manual adjustments
+CREATE TABLE inventory_history_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_type TEXT NOT NULL,
+    change_amount REAL NOT NULL,
+    quantity_before REAL NOT NULL,
+    quantity_after REAL NOT NULL,
+    reason TEXT NOT NULL CHECK(reason IN ('injection', 'manual_adjustment', 'restock', 'expired', 'other')),
+    reference_id INTEGER,  -- ID of injection if auto-deducted
+    reference_type TEXT,   -- 'injection', 'symptom', etc.
+    performed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    notes TEXT,
+    profile_version INTEGER
+);
+
+INSERT INTO inventory_history_old (id, item_type, change_amount, quantity_before, quantity_after, reason, reference_id, reference_type, performed_by, timestamp, notes, profile_version)
+SELECT id, item_type, change_amount, quantity_before, quantity_after,
+       CASE reason WHEN 'stocktake' THEN 'manual_adjustment' ELSE reason END,
+       reference_id, reference_type, performed_by, timestamp, notes, profile_version
+FROM inventory_history;
+
+DROP TABLE inventory_history;
+ALTER TABLE inventory_history_old RENAME TO inventory_history;
+
+CREATE INDEX idx_inventory_history_type ON inventory_history(item_type);
+CREATE INDEX idx_inventory_history_timestamp ON inventory_history(timestamp DESC);
+CREATE INDEX idx_inventory_history_reference ON inventory_history(reference_type, reference_id);
+
+DROP TABLE IF EXISTS stocktake_counts;
+DROP TRIGGER IF EXISTS update_stocktakes_timestamp;
+DROP TABLE IF EXISTS stocktakes;
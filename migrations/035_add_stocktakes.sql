@@ -0,0 +1,78 @@
+-- ============================================
+-- MIGRATION 035: STOCKTAKES
+-- ============================================
+-- A stocktake is a physical count of what is on the shelf. While one is
+-- open, counts are entered per item or per lot, each remembering the
+-- quantity recorded at the moment it was counted. Completing the stocktake
+-- posts the difference as an inventory history entry with reason
+-- 'stocktake', so injections logged while counting aren't undone.
+--
+-- An account has at most one open stocktake. inventory_history is rebuilt
+-- to add 'stocktake' to its reasons.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS stocktakes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'completed', 'cancelled')),
+    notes TEXT,
+    started_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    started_at TIMESTAMP NOT NULL,
+    closed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    closed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stocktakes_account ON stocktakes(account_id, started_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktakes_open ON stocktakes(account_id) WHERE status = 'open';
+
+CREATE TRIGGER IF NOT EXISTS update_stocktakes_timestamp
+AFTER UPDATE ON stocktakes
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE stocktakes SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
+
+-- One count per item, or per lot of an item, in a stocktake
+CREATE TABLE IF NOT EXISTS stocktake_counts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stocktake_id INTEGER NOT NULL REFERENCES stocktakes(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    lot_id INTEGER REFERENCES inventory_lots(id) ON DELETE CASCADE,  -- NULL counts the whole item
+    recorded_quantity REAL NOT NULL,
+    counted_quantity REAL NOT NULL CHECK(counted_quantity >= 0),
+    notes TEXT,
+    counted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    counted_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktake_counts_item ON stocktake_counts(stocktake_id, item_type) WHERE lot_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktake_counts_lot ON stocktake_counts(stocktake_id, lot_id) WHERE lot_id IS NOT NULL;
+
+-- Rebuild inventory_history with 'stocktake' among its reasons
+CREATE TABLE inventory_history_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_type TEXT NOT NULL,
+    change_amount REAL NOT NULL,
+    quantity_before REAL NOT NULL,
+    quantity_after REAL NOT NULL,
+    reason TEXT NOT NULL CHECK(reason IN ('injection', 'manual_adjustment', 'restock', 'expired', 'stocktake', 'other')),
+    reference_id INTEGER,  -- ID of injection if auto-deducted
+    reference_type TEXT,   -- 'injection', 'vial', 'stocktake', etc.
+    performed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    notes TEXT,
+    profile_version INTEGER
+);
+
+INSERT INTO inventory_history_new (id, item_type, change_amount, quantity_before, quantity_after, reason, reference_id, reference_type, performed_by, timestamp, notes, profile_version)
+SELECT id, item_type, change_amount, quantity_before, quantity_after, reason, reference_id, reference_type, performed_by, timestamp, notes, profile_version
+FROM inventory_history;
+
+DROP TABLE inventory_history;
+ALTER TABLE inventory_history_new RENAME TO inventory_history;
+
+CREATE INDEX idx_inventory_history_type ON inventory_history(item_type);
+CREATE INDEX idx_inventory_history_timestamp ON inventory_history(timestamp DESC);
+CREATE INDEX idx_inventory_history_reference ON inventory_history(reference_type, reference_id);
@@ -0,0 +1,3 @@
+-- Undo 036: entries lose their link to the reversal that undid them
+DROP INDEX IF EXISTS idx_inventory_history_reversed_by;
+ALTER TABLE inventory_history DROP COLUMN reversed_by;
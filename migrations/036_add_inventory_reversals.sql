@@ -0,0 +1,23 @@
+-- ============================================
+-- MIGRATION 036: INVENTORY REVERSALS
+-- ============================================
+-- A history entry can be reversed by a new entry that undoes the change it
+-- actually made. reversed_by links the original to its reversal, so an
+-- entry is only ever reversed once. Voided injections returned their stock
+-- before this as 'other' entries; the injection entries each covered are
+-- linked to it so they aren't returned again.
+-- ============================================
+
+ALTER TABLE inventory_history ADD COLUMN reversed_by INTEGER REFERENCES inventory_history(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_inventory_history_reversed_by ON inventory_history(reversed_by);
+
+UPDATE inventory_history SET reversed_by = (
+    SELECT MIN(r.id) FROM inventory_history r
+    WHERE r.reference_type = 'injection'
+      AND r.reference_id = inventory_history.reference_id
+      AND r.item_type = inventory_history.item_type
+      AND r.reason = 'other'
+      AND r.id > inventory_history.id
+)
+WHERE reference_type = 'injection' AND reason = 'injection';
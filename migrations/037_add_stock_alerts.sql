@@ -0,0 +1,37 @@
+-- ============================================
+-- MIGRATION 037: STOCK ALERTS
+-- ============================================
+-- An item at or under its low stock threshold raises a stock alert, which
+-- is delivered through the notification channels and stays active until
+-- the item is restocked. The alert remembers its level (low, critical or
+-- out) and when it was last sent, so it is repeated once a day rather than
+-- on every check, and not at all once acknowledged. Running out escalates
+-- it, which sends it again even if acknowledged.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS stock_alerts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL,
+    level TEXT NOT NULL CHECK(level IN ('low', 'critical', 'out')),
+    quantity REAL NOT NULL,
+    threshold REAL NOT NULL,
+    raised_at TIMESTAMP NOT NULL,
+    notified_at TIMESTAMP,
+    notify_count INTEGER NOT NULL DEFAULT 0,
+    acknowledged_at TIMESTAMP,
+    acknowledged_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_alerts_account ON stock_alerts(account_id, raised_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_alerts_active ON stock_alerts(account_id, item_type) WHERE resolved_at IS NULL;
+
+CREATE TRIGGER IF NOT EXISTS update_stock_alerts_timestamp
+AFTER UPDATE ON stock_alerts
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE stock_alerts SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
@@ -0,0 +1,4 @@
+-- Undo 038: medications lose their link to the catalog, which is dropped
+ALTER TABLE medications DROP COLUMN catalog_id;
+DROP TRIGGER IF EXISTS update_medication_catalog_timestamp;
+DROP TABLE IF EXISTS medication_catalog;
@@ -0,0 +1,152 @@
+-- ============================================
+-- MIGRATION 038: MEDICATION CATALOG
+-- ============================================
+-- Medication names were free text, so the same drug turned up as
+-- "Progesterone", "PIO" and "progesterone oil" across accounts and in
+-- exports. medication_catalog is an instance-wide list of normalized
+-- names, each an ingredient with a standard dose form in the style of
+-- RxNorm's clinical drug forms ("Progesterone Vaginal Insert"), its route
+-- and common brand names to search by. It is seeded with fertility and
+-- pregnancy medications and other common prescriptions.
+--
+-- A medication picked from the catalog records it in catalog_id; its name
+-- stays editable, and medications typed in by hand have none.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS medication_catalog (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL CHECK(length(name) BETWEEN 1 AND 100),
+    dose_form TEXT NOT NULL CHECK(length(dose_form) BETWEEN 1 AND 50),
+    route TEXT,
+    brand_names TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(name, dose_form)
+);
+
+CREATE TRIGGER IF NOT EXISTS update_medication_catalog_timestamp
+AFTER UPDATE ON medication_catalog
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE medication_catalog SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;
+END;
+
+ALTER TABLE medications ADD COLUMN catalog_id INTEGER REFERENCES medication_catalog(id) ON DELETE SET NULL;
+
+INSERT INTO medication_catalog (name, dose_form, route, brand_names) VALUES
+    ('Progesterone', 'Injectable Solution', 'Intramuscular', 'Progesterone in Oil'),
+    ('Progesterone', 'Vaginal Insert', 'Vaginal', 'Endometrin'),
+    ('Progesterone', 'Vaginal Gel', 'Vaginal', 'Crinone'),
+    ('Progesterone', 'Vaginal Suppository', 'Vaginal', NULL),
+    ('Progesterone', 'Oral Capsule', 'Oral', 'Prometrium'),
+    ('Hydroxyprogesterone Caproate', 'Injectable Solution', 'Intramuscular', 'Makena'),
+    ('Estradiol', 'Oral Tablet', 'Oral', 'Estrace'),
+    ('Estradiol', 'Transdermal System', 'Transdermal', 'Climara, Minivelle, Vivelle-Dot'),
+    ('Estradiol', 'Topical Gel', 'Topical', 'Divigel, EstroGel'),
+    ('Estradiol', 'Vaginal Insert', 'Vaginal', 'Vagifem, Imvexxy'),
+    ('Estradiol Valerate', 'Injectable Solution', 'Intramuscular', 'Delestrogen'),
+    ('Estradiol Cypionate', 'Injectable Solution', 'Intramuscular', 'Depo-Estradiol'),
+    ('Letrozole', 'Oral Tablet', 'Oral', 'Femara'),
+    ('Clomiphene Citrate', 'Oral Tablet', 'Oral', 'Clomid'),
+    ('Follitropin Alfa', 'Injectable Solution', 'Subcutaneous', 'Gonal-f'),
+    ('Follitropin Beta', 'Injectable Solution', 'Subcutaneous', 'Follistim AQ'),
+    ('Menotropins', 'Injectable Solution', 'Subcutaneous', 'Menopur'),
+    ('Choriogonadotropin Alfa', 'Injectable Solution', 'Subcutaneous', 'Ovidrel'),
+    ('Chorionic Gonadotropin', 'Injectable Solution', 'Intramuscular', 'Novarel, Pregnyl'),
+    ('Leuprolide Acetate', 'Injectable Solution', 'Subcutaneous', 'Lupron'),
+    ('Leuprolide Acetate', 'Injectable Suspension', 'Intramuscular', 'Lupron Depot'),
+    ('Ganirelix Acetate', 'Prefilled Syringe', 'Subcutaneous', 'Fyremadel'),
+    ('Cetrorelix Acetate', 'Injectable Solution', 'Subcutaneous', 'Cetrotide'),
+    ('Enoxaparin Sodium', 'Prefilled Syringe', 'Subcutaneous', 'Lovenox'),
+    ('Heparin Sodium', 'Injectable Solution', 'Subcutaneous', NULL),
+    ('Rho(D) Immune Globulin', 'Prefilled Syringe', 'Intramuscular', 'RhoGAM'),
+    ('Soybean Oil', 'Injectable Emulsion', 'Intravenous', 'Intralipid'),
+    ('Aspirin', 'Oral Tablet', 'Oral', 'Bayer'),
+    ('Aspirin', 'Delayed Release Oral Tablet', 'Oral', 'Ecotrin'),
+    ('Aspirin', 'Chewable Tablet', 'Oral', NULL),
+    ('Folic Acid', 'Oral Tablet', 'Oral', NULL),
+    ('Prenatal Vitamins', 'Oral Tablet', 'Oral', NULL),
+    ('Prenatal Vitamins', 'Oral Capsule', 'Oral', NULL),
+    ('Cholecalciferol', 'Oral Capsule', 'Oral', 'Vitamin D3'),
+    ('Cholecalciferol', 'Oral Tablet', 'Oral', 'Vitamin D3'),
+    ('Cyanocobalamin', 'Oral Tablet', 'Oral', 'Vitamin B12'),
+    ('Cyanocobalamin', 'Injectable Solution', 'Intramuscular', 'Vitamin B12'),
+    ('Ferrous Sulfate', 'Oral Tablet', 'Oral', 'Feosol'),
+    ('Calcium Carbonate', 'Chewable Tablet', 'Oral', 'Tums'),
+    ('Magnesium Oxide', 'Oral Tablet', 'Oral', NULL),
+    ('Coenzyme Q10', 'Oral Capsule', 'Oral', 'CoQ10'),
+    ('Prasterone', 'Oral Capsule', 'Oral', 'DHEA'),
+    ('Omega-3-Acid Ethyl Esters', 'Oral Capsule', 'Oral', 'Lovaza, Fish Oil'),
+    ('Doxycycline', 'Oral Capsule', 'Oral', 'Vibramycin'),
+    ('Azithromycin', 'Oral Tablet', 'Oral', 'Zithromax'),
+    ('Amoxicillin', 'Oral Capsule', 'Oral', NULL),
+    ('Cephalexin', 'Oral Capsule', 'Oral', 'Keflex'),
+    ('Nitrofurantoin', 'Oral Capsule', 'Oral', 'Macrobid'),
+    ('Metronidazole', 'Oral Tablet', 'Oral', 'Flagyl'),
+    ('Metronidazole', 'Vaginal Gel', 'Vaginal', 'MetroGel-Vaginal'),
+    ('Fluconazole', 'Oral Tablet', 'Oral', 'Diflucan'),
+    ('Miconazole Nitrate', 'Vaginal Cream', 'Vaginal', 'Monistat'),
+    ('Valacyclovir', 'Oral Tablet', 'Oral', 'Valtrex'),
+    ('Methylprednisolone', 'Oral Tablet', 'Oral', 'Medrol'),
+    ('Prednisone', 'Oral Tablet', 'Oral', NULL),
+    ('Dexamethasone', 'Oral Tablet', 'Oral', NULL),
+    ('Hydroxychloroquine Sulfate', 'Oral Tablet', 'Oral', 'Plaquenil'),
+    ('Cabergoline', 'Oral Tablet', 'Oral', 'Dostinex'),
+    ('Bromocriptine Mesylate', 'Oral Tablet', 'Oral', 'Parlodel'),
+    ('Metformin Hydrochloride', 'Oral Tablet', 'Oral', 'Glucophage'),
+    ('Metformin Hydrochloride', 'Extended Release Oral Tablet', 'Oral', 'Glucophage XR'),
+    ('Levothyroxine Sodium', 'Oral Tablet', 'Oral', 'Synthroid, Levoxyl, Unithroid'),
+    ('Liothyronine Sodium', 'Oral Tablet', 'Oral', 'Cytomel'),
+    ('Medroxyprogesterone Acetate', 'Oral Tablet', 'Oral', 'Provera'),
+    ('Medroxyprogesterone Acetate', 'Injectable Suspension', 'Intramuscular', 'Depo-Provera'),
+    ('Norethindrone', 'Oral Tablet', 'Oral', 'Camila, Errin'),
+    ('Norethindrone Acetate', 'Oral Tablet', 'Oral', 'Aygestin'),
+    ('Ethinyl Estradiol and Levonorgestrel', 'Oral Tablet', 'Oral', NULL),
+    ('Ethinyl Estradiol and Norethindrone', 'Oral Tablet', 'Oral', NULL),
+    ('Testosterone Cypionate', 'Injectable Solution', 'Intramuscular', 'Depo-Testosterone'),
+    ('Testosterone Enanthate', 'Injectable Solution', 'Subcutaneous', 'Xyosted'),
+    ('Testosterone', 'Topical Gel', 'Topical', 'AndroGel'),
+    ('Spironolactone', 'Oral Tablet', 'Oral', 'Aldactone'),
+    ('Finasteride', 'Oral Tablet', 'Oral', 'Propecia, Proscar'),
+    ('Insulin Glargine', 'Injectable Solution', 'Subcutaneous', 'Lantus, Basaglar'),
+    ('Insulin Lispro', 'Injectable Solution', 'Subcutaneous', 'Humalog'),
+    ('Semaglutide', 'Injectable Solution', 'Subcutaneous', 'Ozempic, Wegovy'),
+    ('Tirzepatide', 'Injectable Solution', 'Subcutaneous', 'Mounjaro, Zepbound'),
+    ('Ondansetron', 'Oral Tablet', 'Oral', 'Zofran'),
+    ('Ondansetron', 'Orally Disintegrating Tablet', 'Oral', 'Zofran ODT'),
+    ('Doxylamine Succinate and Pyridoxine Hydrochloride', 'Delayed Release Oral Tablet', 'Oral', 'Diclegis'),
+    ('Promethazine Hydrochloride', 'Oral Tablet', 'Oral', 'Phenergan'),
+    ('Metoclopramide', 'Oral Tablet', 'Oral', 'Reglan'),
+    ('Famotidine', 'Oral Tablet', 'Oral', 'Pepcid'),
+    ('Omeprazole', 'Delayed Release Oral Capsule', 'Oral', 'Prilosec'),
+    ('Pantoprazole', 'Delayed Release Oral Tablet', 'Oral', 'Protonix'),
+    ('Docusate Sodium', 'Oral Capsule', 'Oral', 'Colace'),
+    ('Polyethylene Glycol 3350', 'Powder for Oral Solution', 'Oral', 'MiraLAX'),
+    ('Acetaminophen', 'Oral Tablet', 'Oral', 'Tylenol'),
+    ('Ibuprofen', 'Oral Tablet', 'Oral', 'Advil, Motrin'),
+    ('Naproxen Sodium', 'Oral Tablet', 'Oral', 'Aleve'),
+    ('Cetirizine Hydrochloride', 'Oral Tablet', 'Oral', 'Zyrtec'),
+    ('Loratadine', 'Oral Tablet', 'Oral', 'Claritin'),
+    ('Diphenhydramine Hydrochloride', 'Oral Capsule', 'Oral', 'Benadryl'),
+    ('Fluticasone Propionate', 'Nasal Spray', 'Nasal', 'Flonase'),
+    ('Montelukast', 'Oral Tablet', 'Oral', 'Singulair'),
+    ('Albuterol', 'Metered Dose Inhaler', 'Inhalation', 'ProAir, Ventolin'),
+    ('Sertraline', 'Oral Tablet', 'Oral', 'Zoloft'),
+    ('Escitalopram', 'Oral Tablet', 'Oral', 'Lexapro'),
+    ('Fluoxetine', 'Oral Capsule', 'Oral', 'Prozac'),
+    ('Bupropion Hydrochloride', 'Extended Release Oral Tablet', 'Oral', 'Wellbutrin XL'),
+    ('Trazodone Hydrochloride', 'Oral Tablet', 'Oral', NULL),
+    ('Zolpidem Tartrate', 'Oral Tablet', 'Oral', 'Ambien'),
+    ('Lorazepam', 'Oral Tablet', 'Oral', 'Ativan'),
+    ('Melatonin', 'Oral Tablet', 'Oral', NULL),
+    ('Gabapentin', 'Oral Capsule', 'Oral', 'Neurontin'),
+    ('Sumatriptan', 'Oral Tablet', 'Oral', 'Imitrex'),
+    ('Lisinopril', 'Oral Tablet', 'Oral', 'Prinivil, Zestril'),
+    ('Losartan Potassium', 'Oral Tablet', 'Oral', 'Cozaar'),
+    ('Amlodipine', 'Oral Tablet', 'Oral', 'Norvasc'),
+    ('Labetalol Hydrochloride', 'Oral Tablet', 'Oral', NULL),
+    ('Nifedipine', 'Extended Release Oral Tablet', 'Oral', 'Procardia XL'),
+    ('Atorvastatin', 'Oral Tablet', 'Oral', 'Lipitor'),
+    ('Sildenafil', 'Oral Tablet', 'Oral', 'Viagra'),
+    ('Epinephrine', 'Auto-Injector', 'Intramuscular', 'EpiPen'),
+    ('Naloxone Hydrochloride', 'Nasal Spray', 'Nasal', 'Narcan');
@@ -0,0 +1,17 @@
+-- ============================================
+-- MIGRATION 039: MEDICATION DOSE TIMES
+-- ============================================
+-- A medication had one scheduled_time, so twice-daily medications could
+-- only be tracked as taken or not each day. dose_times holds every time of
+-- day a dose is due, as sorted HH:MM separated by commas ("08:00,20:00").
+-- scheduled_time is kept as the first of them for older clients.
+--
+-- Logs record the dose they are for in scheduled_for, so adherence is
+-- worked out per dose rather than per day. Older logs have none and are
+-- matched to the nearest dose when adherence is calculated.
+-- ============================================
+
+ALTER TABLE medications ADD COLUMN dose_times TEXT;
+UPDATE medications SET dose_times = scheduled_time WHERE scheduled_time IS NOT NULL AND scheduled_time <> '';
+
+ALTER TABLE medication_logs ADD COLUMN scheduled_for TIMESTAMP;
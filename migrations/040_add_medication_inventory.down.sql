@@ -0,0 +1,4 @@
+-- Undo 040: medications are no longer linked to inventory; stock already
+-- taken by their logs stays in the history
+ALTER TABLE medications DROP COLUMN units_per_dose;
+ALTER TABLE medications DROP COLUMN inventory_item_type;
@@ -0,0 +1,28 @@
+-- ============================================
+-- MIGRATION 041: MEDICATION SNOOZES AND LATE DOSES
+-- ============================================
+-- Medications with reminder_enabled are reminded of as each dose falls
+-- due. A reminder can be snoozed for a few minutes at a time, never past
+-- the end of the dose's time window; medication_dose_snoozes holds the
+-- latest snooze for each dose, which the reminder waits for instead.
+--
+-- late marks a dose taken outside its window, whether logged as late or
+-- logged after the window closed, so adherence can tell on time, late and
+-- missed doses apart.
+-- ============================================
+
+ALTER TABLE medication_logs ADD COLUMN late BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS medication_dose_snoozes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    medication_id INTEGER NOT NULL REFERENCES medications(id) ON DELETE CASCADE,
+    scheduled_for TIMESTAMP NOT NULL,
+    snoozed_until TIMESTAMP NOT NULL,
+    snooze_count INTEGER NOT NULL DEFAULT 1 CHECK(snooze_count > 0),
+    snoozed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(medication_id, scheduled_for)
+);
+
+CREATE INDEX IF NOT EXISTS idx_medication_dose_snoozes_until ON medication_dose_snoozes(snoozed_until);
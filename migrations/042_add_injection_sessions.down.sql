@@ -0,0 +1,3 @@
+-- Undo 042: guided injection sessions are dropped; their injections stay
+DROP TRIGGER IF EXISTS update_injection_sessions_timestamp;
+DROP TABLE IF EXISTS injection_sessions;
@@ -0,0 +1,22 @@
+-- Undo 043: settings go back into one table. Account settings are taken from
+-- the first account, as there was only one set of them.
+CREATE TABLE IF NOT EXISTS settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_by INTEGER REFERENCES users(id) ON DELETE SET NULL
+);
+
+INSERT INTO settings (key, value, updated_at, updated_by)
+SELECT key, value, updated_at, updated_by FROM site_settings;
+
+INSERT INTO settings (key, value, updated_at, updated_by)
+SELECT key, value, updated_at, updated_by FROM account_settings
+WHERE account_id = (SELECT MIN(account_id) FROM account_settings);
+
+INSERT INTO settings (key, value, updated_at)
+SELECT 'user_' || key || '_' || user_id, value, updated_at FROM user_settings;
+
+DROP TABLE IF EXISTS user_settings;
+DROP TABLE IF EXISTS account_settings;
+DROP TABLE IF EXISTS site_settings;
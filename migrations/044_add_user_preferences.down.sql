@@ -0,0 +1,9 @@
+-- Undo 044: the theme, timezone and formats go back to user_settings; locale,
+-- units and the first day of the week are dropped
+INSERT INTO user_settings (user_id, key, value, updated_at)
+SELECT user_id, 'theme', theme, updated_at FROM user_preferences
+UNION ALL SELECT user_id, 'timezone', timezone, updated_at FROM user_preferences
+UNION ALL SELECT user_id, 'date_format', date_format, updated_at FROM user_preferences
+UNION ALL SELECT user_id, 'time_format', time_format, updated_at FROM user_preferences;
+
+DROP TABLE IF EXISTS user_preferences;
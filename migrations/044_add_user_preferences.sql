@@ -0,0 +1,34 @@
+-- ============================================
+-- MIGRATION 044: ADD USER PREFERENCES
+-- ============================================
+-- A user's display preferences become one typed row instead of loose
+-- user_settings keys: timezone, locale, date and time format, measurement
+-- units, the first day of the week and the theme. The theme, timezone and
+-- formats already saved move over; values the app never accepted fall back
+-- to the defaults.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    timezone TEXT NOT NULL DEFAULT 'America/New_York',
+    locale TEXT NOT NULL DEFAULT 'en-US',
+    date_format TEXT NOT NULL DEFAULT 'MM/DD/YYYY' CHECK(date_format IN ('MM/DD/YYYY', 'DD/MM/YYYY', 'YYYY-MM-DD')),
+    time_format TEXT NOT NULL DEFAULT '12h' CHECK(time_format IN ('12h', '24h')),
+    units TEXT NOT NULL DEFAULT 'imperial' CHECK(units IN ('metric', 'imperial')),
+    week_start TEXT NOT NULL DEFAULT 'sunday' CHECK(week_start IN ('sunday', 'monday')),
+    theme TEXT NOT NULL DEFAULT 'auto' CHECK(theme IN ('auto', 'light', 'dark')),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO user_preferences (user_id, timezone, date_format, time_format, theme, updated_at)
+SELECT s.user_id,
+    COALESCE(MAX(CASE WHEN s.key = 'timezone' AND s.value != '' THEN s.value END), 'America/New_York'),
+    COALESCE(MAX(CASE WHEN s.key = 'date_format' AND s.value IN ('MM/DD/YYYY', 'DD/MM/YYYY', 'YYYY-MM-DD') THEN s.value END), 'MM/DD/YYYY'),
+    COALESCE(MAX(CASE WHEN s.key = 'time_format' AND s.value IN ('12h', '24h') THEN s.value END), '12h'),
+    COALESCE(MAX(CASE WHEN s.key = 'theme' AND s.value IN ('auto', 'light', 'dark') THEN s.value END), 'auto'),
+    MAX(s.updated_at)
+FROM user_settings s
+WHERE s.key IN ('theme', 'timezone', 'date_format', 'time_format')
+GROUP BY s.user_id;
+
+DELETE FROM user_settings WHERE key IN ('theme', 'timezone', 'date_format', 'time_format');
@@ -0,0 +1,3 @@
+-- Undo 045: the data keys are lost, so run this only after the secrets
+-- have been decrypted (stop setting SECRETS_KEY and save them again)
+DROP TABLE IF EXISTS secret_keys;
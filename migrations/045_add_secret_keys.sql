@@ -0,0 +1,21 @@
+-- ============================================
+-- MIGRATION 045: ENCRYPTED SECRETS
+-- ============================================
+-- Secrets kept in the database (the SMTP password, the auto-backup
+-- passphrase and destination config, notification channel configs and
+-- webhook secrets) are encrypted with envelope encryption. Each is sealed
+-- with a data key from this table, and the data keys are sealed with the
+-- master key from SECRETS_KEY, which is never stored. master_key_id is the
+-- master key's fingerprint, so a data key sealed with an earlier master key
+-- can still be opened while it is being rotated out.
+--
+-- Existing values stay as they are until the admin rotates the keys, which
+-- encrypts them.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS secret_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    wrapped_key TEXT NOT NULL,
+    master_key_id TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,12 @@
+-- ============================================
+-- MIGRATION 046: ADMIN IMPERSONATION
+-- ============================================
+-- The admin can sign in as another user to help them. Such a session
+-- records who started it, so the user can see and sign it out, and every
+-- audit log entry made during it names the admin as well as the user.
+-- ============================================
+
+ALTER TABLE session_tokens ADD COLUMN impersonator_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE audit_logs ADD COLUMN impersonator_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_impersonator ON audit_logs(impersonator_id);
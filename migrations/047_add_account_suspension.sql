@@ -0,0 +1,13 @@
+-- ============================================
+-- MIGRATION 047: ACCOUNT SUSPENSION
+-- ============================================
+-- The admin can suspend a whole account, for a dispute or one left unused,
+-- rather than deactivating its members one by one. A read_only account's
+-- members can still sign in and look at their data but change nothing; a
+-- locked account's members can't sign in at all. Reactivating clears it.
+-- ============================================
+
+ALTER TABLE accounts ADD COLUMN suspension TEXT CHECK (suspension IN ('read_only', 'locked'));
+ALTER TABLE accounts ADD COLUMN suspension_reason TEXT;
+ALTER TABLE accounts ADD COLUMN suspended_at TIMESTAMP;
+ALTER TABLE accounts ADD COLUMN suspended_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+-- Undo 048: accounts are no longer limited
+DROP TABLE IF EXISTS resource_quotas;
@@ -0,0 +1,3 @@
+-- Undo 049: devices can no longer be trusted
+ALTER TABLE session_tokens DROP COLUMN trusted_device_id;
+DROP TABLE IF EXISTS trusted_devices;
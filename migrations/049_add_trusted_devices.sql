@@ -0,0 +1,25 @@
+-- ============================================
+-- MIGRATION 049: TRUSTED DEVICES
+-- ============================================
+-- A user signing in can trust the device for a while (30 days by default).
+-- The device keeps a token of its own, separate from its sessions, and
+-- sessions started on it last until the trust runs out and aren't ended
+-- for going unused. Trust is listed with the user's devices and can be
+-- revoked, which signs the device's sessions out too.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS trusted_devices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    ip_address TEXT,
+    user_agent TEXT,
+    is_revoked BOOLEAN DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_trusted_devices_user ON trusted_devices(user_id);
+
+ALTER TABLE session_tokens ADD COLUMN trusted_device_id INTEGER REFERENCES trusted_devices(id) ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+-- Undo 050: saved reports are dropped, and schedules stop attaching them
+ALTER TABLE report_schedules DROP COLUMN saved_report_id;
+DROP TRIGGER IF EXISTS update_saved_reports_timestamp;
+DROP TABLE IF EXISTS saved_reports;
@@ -0,0 +1,27 @@
+-- ============================================
+-- MIGRATION 051: TRASH
+-- ============================================
+-- Deleting a course, symptom log, medication or journal entry moves it to
+-- the trash for 30 days instead of removing it for good. The item is
+-- still deleted from its own table, so nothing that reads those tables
+-- has to skip trashed rows; snapshot holds it, and the rows deleted with
+-- it (a course's injections, a medication's doses), as JSON so a restore
+-- can put them back with their original IDs. A private symptom log stays
+-- private in the trash.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS trash_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL CHECK(item_type IN ('course', 'symptom_log', 'medication', 'journal_entry')),
+    item_id INTEGER NOT NULL,
+    label TEXT NOT NULL,
+    is_private BOOLEAN NOT NULL DEFAULT 0,
+    private_to INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    snapshot TEXT NOT NULL,
+    deleted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    deleted_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_trash_items_account ON trash_items(account_id, deleted_at DESC);
+CREATE INDEX IF NOT EXISTS idx_trash_items_deleted_at ON trash_items(deleted_at);
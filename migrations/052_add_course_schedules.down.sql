@@ -0,0 +1,2 @@
+-- Undo 052: courses go back to following the account's reminder frequency
+DROP TABLE IF EXISTS course_schedules;
@@ -0,0 +1,23 @@
+-- ============================================
+-- MIGRATION 053: MISSED INJECTIONS
+-- ============================================
+-- A background job compares each active course's schedule with the
+-- injections logged and records every dose that fell due and wasn't given.
+-- Members can say why it was skipped (reason, with an optional note), which
+-- the reports show alongside it. due_at is stored in UTC; a dose is only
+-- recorded once.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS missed_injections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    due_at TIMESTAMP NOT NULL,
+    reason TEXT CHECK(reason IS NULL OR reason IN ('clinic_instruction', 'travel', 'forgot', 'side_effects', 'out_of_supply', 'other')),
+    note TEXT,
+    annotated_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    annotated_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(course_id, due_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_missed_injections_due ON missed_injections(due_at);
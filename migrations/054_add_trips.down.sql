@@ -0,0 +1,2 @@
+-- Undo 054: trips are forgotten and injections fall due on home time again
+DROP TABLE IF EXISTS trips;
@@ -0,0 +1,27 @@
+-- ============================================
+-- MIGRATION 054: TRAVEL MODE
+-- ============================================
+-- A trip records time spent away in another timezone. While it lasts the
+-- account's injections fall due on the destination's clock instead of
+-- home's, moved there at once (instant) or an hour a day each way (gradual).
+-- home_timezone is the timezone the trip started from. Times are stored in
+-- UTC.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS trips (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    destination TEXT NOT NULL,
+    timezone TEXT NOT NULL,
+    home_timezone TEXT NOT NULL,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    shift TEXT NOT NULL DEFAULT 'instant' CHECK(shift IN ('instant', 'gradual')),
+    notes TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK(ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trips_account ON trips(account_id, starts_at);
@@ -0,0 +1,3 @@
+-- Undo 055: inventory history is tied to accounts by item type again
+DROP INDEX IF EXISTS idx_inventory_history_account;
+ALTER TABLE inventory_history DROP COLUMN account_id;
@@ -0,0 +1,16 @@
+-- ============================================
+-- MIGRATION 056: MISSED MEDICATION DOSES
+-- ============================================
+-- A dose whose window closes with nothing logged is reported by the
+-- medication.missed webhook, as a dose logged as not taken already is.
+-- medication_missed_doses holds each dose reported either way, so it is
+-- reported once. scheduled_for is stored in UTC.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS medication_missed_doses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    medication_id INTEGER NOT NULL REFERENCES medications(id) ON DELETE CASCADE,
+    scheduled_for TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(medication_id, scheduled_for)
+);
@@ -0,0 +1,10 @@
+// Package migrations holds the SQL migrations, embedded so the server binary
+// carries its own schema. NNN_name.sql upgrades to version NNN and the
+// optional NNN_name.down.sql undoes it. SQLite uses the files here and
+// PostgreSQL the twins in postgres/.
+package migrations
+
+import "embed"
+
+//go:embed *.sql postgres/*.sql
+var FS embed.FS
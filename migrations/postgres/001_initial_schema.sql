@@ -0,0 +1,609 @@
+-- ============================================
+-- POSTGRESQL SCHEMA
+-- ============================================
+-- The PostgreSQL twin of migrations/001 through 021: the schema a SQLite
+-- database has once all of them have run, written for PostgreSQL. From
+-- here on each migration in migrations/ gets a twin with the same name in
+-- migrations/postgres/.
+--
+-- Differences from SQLite:
+-- - ids are BIGSERIAL, REAL is DOUBLE PRECISION and times are TIMESTAMPTZ
+-- - COLLATE NOCASE is a case-insensitive ICU collation named nocase, so
+--   the same SQL works on both; LIKE can't use it, hence the email check
+--   compares under "C"
+-- - updated_at is kept by one BEFORE UPDATE trigger function, which leaves
+--   it alone when the UPDATE set it itself (as 021 does for SQLite)
+-- ============================================
+
+CREATE COLLATION IF NOT EXISTS nocase (provider = icu, locale = 'und-u-ks-level2', deterministic = false);
+
+-- Users and sessions
+CREATE TABLE users (
+    id BIGSERIAL PRIMARY KEY,
+    username TEXT COLLATE nocase UNIQUE NOT NULL,
+    password_hash TEXT NOT NULL,
+    email TEXT COLLATE nocase,
+    is_active BOOLEAN DEFAULT TRUE,
+    failed_login_attempts INTEGER DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_login TIMESTAMPTZ,
+    CONSTRAINT chk_username_length CHECK (length(username) >= 3 AND length(username) <= 50),
+    CONSTRAINT chk_email_format CHECK (email IS NULL OR (email COLLATE "C") LIKE '%@%.%')
+);
+
+CREATE TABLE session_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ,
+    ip_address TEXT,
+    user_agent TEXT,
+    is_revoked BOOLEAN DEFAULT FALSE
+);
+
+CREATE TABLE password_reset_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMPTZ
+);
+
+CREATE TABLE account_deletion_requests (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Accounts
+CREATE TABLE accounts (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE account_members (
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL DEFAULT 'member' CHECK(role IN ('owner', 'member')),
+    joined_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    invited_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    PRIMARY KEY (account_id, user_id),
+    CONSTRAINT chk_unique_user UNIQUE(user_id)  -- User can only be in ONE account
+);
+
+CREATE TABLE account_invitations (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    email TEXT COLLATE nocase NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    invited_by BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL DEFAULT 'member' CHECK(role IN ('owner', 'member')),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    accepted_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT chk_not_expired CHECK (accepted_at IS NULL OR accepted_at <= expires_at)
+);
+
+-- Attachments come before the injections and symptoms that point at them
+CREATE TABLE attachments (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    uploaded_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    original_filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 TEXT NOT NULL,
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT,
+    width INTEGER,
+    height INTEGER,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Compounds and courses
+CREATE TABLE compounds (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    concentration_mg_per_ml DOUBLE PRECISION CHECK(concentration_mg_per_ml IS NULL OR concentration_mg_per_ml > 0),
+    route TEXT NOT NULL DEFAULT 'intramuscular' CHECK(route IN ('intramuscular', 'subcutaneous', 'intradermal', 'other')),
+    inventory_item_type TEXT NOT NULL,
+    default_dose_ml DOUBLE PRECISION CHECK(default_dose_ml IS NULL OR default_dose_ml > 0),
+    notes TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(account_id, name),
+    UNIQUE(account_id, inventory_item_type)
+);
+
+CREATE TABLE courses (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    start_date DATE NOT NULL,
+    expected_end_date DATE,
+    actual_end_date DATE,
+    is_active BOOLEAN DEFAULT TRUE,
+    notes TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    account_id BIGINT REFERENCES accounts(id) ON DELETE CASCADE,
+    dose_ml DOUBLE PRECISION CHECK(dose_ml IS NULL OR dose_ml > 0),
+    concentration_mg_per_ml DOUBLE PRECISION CHECK(concentration_mg_per_ml IS NULL OR concentration_mg_per_ml > 0),
+    compound_id BIGINT REFERENCES compounds(id) ON DELETE SET NULL,
+    CONSTRAINT chk_dates CHECK (
+        expected_end_date IS NULL OR expected_end_date >= start_date
+    ),
+    CONSTRAINT chk_actual_end CHECK (
+        actual_end_date IS NULL OR actual_end_date >= start_date
+    ),
+    CONSTRAINT chk_name_length CHECK (length(trim(name)) > 0)
+);
+
+-- Inventory
+CREATE TABLE inventory_items (
+    id BIGSERIAL PRIMARY KEY,
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    quantity DOUBLE PRECISION NOT NULL CHECK(quantity >= 0),
+    unit TEXT NOT NULL CHECK(unit IN ('mL', 'count')),
+    expiration_date DATE,
+    lot_number TEXT,
+    low_stock_threshold DOUBLE PRECISION CHECK(low_stock_threshold IS NULL OR low_stock_threshold >= 0),
+    notes TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    account_id BIGINT REFERENCES accounts(id) ON DELETE CASCADE,
+    CONSTRAINT uq_inventory_item_type UNIQUE(item_type)
+);
+
+CREATE TABLE inventory_item_types (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    name TEXT NOT NULL,
+    unit TEXT NOT NULL CHECK(unit IN ('mL', 'count')),
+    decrement_per_injection DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK(decrement_per_injection >= 0),
+    reorder_threshold DOUBLE PRECISION CHECK(reorder_threshold IS NULL OR reorder_threshold >= 0),
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(account_id, item_type)
+);
+
+CREATE TABLE inventory_lots (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    lot_number TEXT,
+    expiration_date DATE,
+    quantity_received DOUBLE PRECISION NOT NULL CHECK(quantity_received >= 0),
+    quantity_remaining DOUBLE PRECISION NOT NULL CHECK(quantity_remaining >= 0),
+    received_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    notes TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE inventory_history (
+    id BIGSERIAL PRIMARY KEY,
+    item_type TEXT NOT NULL,
+    change_amount DOUBLE PRECISION NOT NULL,
+    quantity_before DOUBLE PRECISION NOT NULL,
+    quantity_after DOUBLE PRECISION NOT NULL,
+    reason TEXT NOT NULL CHECK(reason IN ('injection', 'manual_adjustment', 'restock', 'expired', 'other')),
+    reference_id BIGINT,   -- ID of injection if auto-deducted
+    reference_type TEXT,   -- 'injection', 'symptom', etc.
+    performed_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    notes TEXT,
+    profile_version INTEGER
+);
+
+CREATE TABLE consumption_profiles (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL CHECK(version > 0),
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(account_id, version)
+);
+
+CREATE TABLE consumption_profile_items (
+    profile_id BIGINT NOT NULL REFERENCES consumption_profiles(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL,
+    amount DOUBLE PRECISION NOT NULL CHECK(amount > 0),
+    PRIMARY KEY (profile_id, item_type)
+);
+
+CREATE TABLE purchase_orders (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    supplier TEXT NOT NULL CHECK(length(supplier) BETWEEN 1 AND 200),
+    order_number TEXT,
+    status TEXT NOT NULL DEFAULT 'ordered' CHECK(status IN ('ordered', 'received', 'cancelled')),
+    ordered_at DATE NOT NULL,
+    expected_arrival DATE,
+    received_at TIMESTAMPTZ,
+    shipping_cost DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK(shipping_cost >= 0),
+    notes TEXT,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE purchase_order_items (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    quantity DOUBLE PRECISION NOT NULL CHECK(quantity > 0),
+    unit_cost DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK(unit_cost >= 0),
+    lot_number TEXT,
+    expiration_date DATE,
+    lot_id BIGINT REFERENCES inventory_lots(id) ON DELETE SET NULL  -- set when received
+);
+
+-- Injections and symptoms
+CREATE TABLE injections (
+    id BIGSERIAL PRIMARY KEY,
+    course_id BIGINT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    administered_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    timestamp TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    side TEXT NOT NULL CHECK(side IN ('left', 'right')),
+
+    -- Advanced mode fields
+    site_x DOUBLE PRECISION CHECK(site_x IS NULL OR (site_x >= 0 AND site_x <= 1)),
+    site_y DOUBLE PRECISION CHECK(site_y IS NULL OR (site_y >= 0 AND site_y <= 1)),
+
+    -- Optional details
+    pain_level INTEGER CHECK(pain_level IS NULL OR (pain_level BETWEEN 1 AND 10)),
+    has_knots BOOLEAN DEFAULT FALSE,
+    site_reaction TEXT CHECK(site_reaction IS NULL OR site_reaction IN ('none', 'redness', 'swelling', 'bruising', 'other')),
+    notes TEXT,
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    attachment_id BIGINT REFERENCES attachments(id) ON DELETE SET NULL,
+    dose_ml DOUBLE PRECISION CHECK(dose_ml IS NULL OR dose_ml > 0),
+    lot_id BIGINT REFERENCES inventory_lots(id) ON DELETE SET NULL,
+    voided_at TIMESTAMPTZ,
+    voided_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    void_reason TEXT CHECK(void_reason IS NULL OR length(void_reason) <= 500)
+);
+
+CREATE TABLE inventory_lot_consumptions (
+    id BIGSERIAL PRIMARY KEY,
+    lot_id BIGINT NOT NULL REFERENCES inventory_lots(id) ON DELETE CASCADE,
+    injection_id BIGINT REFERENCES injections(id) ON DELETE CASCADE,
+    amount DOUBLE PRECISION NOT NULL CHECK(amount > 0),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE symptom_logs (
+    id BIGSERIAL PRIMARY KEY,
+    course_id BIGINT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    logged_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    timestamp TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    pain_level INTEGER CHECK(pain_level IS NULL OR (pain_level BETWEEN 1 AND 10)),
+    pain_location TEXT,
+    pain_type TEXT,
+    has_knots BOOLEAN DEFAULT FALSE,
+    symptoms TEXT,  -- JSON array of symptoms
+    dissipated_at TIMESTAMPTZ,  -- When symptoms went away
+    notes TEXT,
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    attachment_id BIGINT REFERENCES attachments(id) ON DELETE SET NULL
+);
+
+-- Medications
+CREATE TABLE medications (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    dosage TEXT,
+    frequency TEXT,
+    start_date DATE,
+    end_date DATE,
+    is_active BOOLEAN DEFAULT TRUE,
+    notes TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    scheduled_time TEXT,
+    time_window_minutes INTEGER DEFAULT 60,
+    reminder_enabled BOOLEAN DEFAULT FALSE,
+    account_id BIGINT REFERENCES accounts(id) ON DELETE CASCADE,
+    CONSTRAINT chk_medication_name CHECK (length(trim(name)) > 0),
+    CONSTRAINT chk_medication_dates CHECK (
+        end_date IS NULL OR start_date IS NULL OR end_date >= start_date
+    )
+);
+
+CREATE TABLE medication_logs (
+    id BIGSERIAL PRIMARY KEY,
+    medication_id BIGINT NOT NULL REFERENCES medications(id) ON DELETE CASCADE,
+    logged_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    timestamp TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    taken BOOLEAN NOT NULL,
+    notes TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Vitals and appointments
+CREATE TABLE vitals (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    recorded_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    type TEXT NOT NULL CHECK(type IN ('body_temperature', 'weight')),
+    value DOUBLE PRECISION NOT NULL,
+    unit TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT 'manual' CHECK(source IN ('manual', 'apple_health', 'google_fit')),
+    notes TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(account_id, type, timestamp)
+);
+
+CREATE TABLE health_import_mappings (
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    source_type TEXT NOT NULL CHECK(length(source_type) BETWEEN 1 AND 100),
+    target TEXT NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, source_type)
+);
+
+CREATE TABLE appointments (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ,
+    location TEXT,
+    notes TEXT,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Settings, notifications and auditing
+CREATE TABLE settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_by BIGINT REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK(type IN ('injection_reminder', 'low_stock', 'missed_injection', 'expiration_warning', 'system')),
+    title TEXT NOT NULL,
+    message TEXT NOT NULL,
+    is_read BOOLEAN DEFAULT FALSE,
+    scheduled_time TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE notification_channels (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK(type IN ('ntfy', 'gotify', 'webhook')),
+    name TEXT NOT NULL,
+    config TEXT NOT NULL DEFAULT '{}',
+    is_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_sent_at TIMESTAMPTZ,
+    last_error TEXT,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_channel_name CHECK (length(trim(name)) > 0)
+);
+
+CREATE TABLE webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL DEFAULT '[]',
+    description TEXT,
+    is_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'success', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE TABLE report_schedules (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL CHECK(length(name) BETWEEN 1 AND 100),
+    frequency TEXT NOT NULL CHECK(frequency IN ('weekly', 'monthly')),
+    day_of_week INTEGER NOT NULL DEFAULT 1 CHECK(day_of_week BETWEEN 0 AND 6),
+    day_of_month INTEGER NOT NULL DEFAULT 1 CHECK(day_of_month BETWEEN 1 AND 28),
+    hour INTEGER NOT NULL DEFAULT 8 CHECK(hour BETWEEN 0 AND 23),
+    course_id BIGINT REFERENCES courses(id) ON DELETE SET NULL,
+    include_pdf BOOLEAN NOT NULL DEFAULT TRUE,
+    is_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_sent_at TIMESTAMPTZ,
+    last_error TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE calendar_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE TABLE sync_operations (
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    client_id TEXT NOT NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'applied')),
+    status_code INTEGER,
+    response TEXT,
+    entity_id BIGINT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, client_id)
+);
+
+CREATE TABLE audit_logs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    entity_type TEXT NOT NULL,
+    entity_id BIGINT,
+    details TEXT,  -- JSON with additional details
+    ip_address TEXT,
+    user_agent TEXT,
+    timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes
+CREATE INDEX idx_users_username ON users(username);
+CREATE INDEX idx_users_email ON users(email);
+CREATE INDEX idx_session_tokens_user ON session_tokens(user_id);
+CREATE INDEX idx_session_tokens_hash ON session_tokens(token_hash);
+CREATE INDEX idx_session_tokens_expires ON session_tokens(expires_at);
+CREATE INDEX idx_reset_tokens_hash ON password_reset_tokens(token_hash);
+CREATE INDEX idx_reset_tokens_user ON password_reset_tokens(user_id);
+CREATE INDEX idx_account_members_user ON account_members(user_id);
+CREATE INDEX idx_account_members_account ON account_members(account_id);
+CREATE INDEX idx_invitations_token ON account_invitations(token_hash);
+CREATE INDEX idx_invitations_account ON account_invitations(account_id);
+CREATE INDEX idx_invitations_email ON account_invitations(email);
+CREATE INDEX idx_invitations_expires ON account_invitations(expires_at);
+CREATE INDEX idx_attachments_account ON attachments(account_id, created_at DESC);
+CREATE INDEX idx_attachments_storage_key ON attachments(storage_key);
+CREATE INDEX idx_compounds_account ON compounds(account_id);
+CREATE INDEX idx_courses_active ON courses(is_active);
+CREATE INDEX idx_courses_created_by ON courses(created_by);
+CREATE INDEX idx_courses_account ON courses(account_id);
+CREATE INDEX idx_courses_account_active ON courses(account_id, is_active);
+CREATE INDEX idx_courses_compound ON courses(compound_id);
+CREATE INDEX idx_inventory_type ON inventory_items(item_type);
+CREATE INDEX idx_inventory_expiration ON inventory_items(expiration_date);
+CREATE INDEX idx_inventory_items_account ON inventory_items(account_id);
+CREATE UNIQUE INDEX idx_inventory_items_type_account ON inventory_items(item_type, account_id);
+CREATE INDEX idx_inventory_item_types_account ON inventory_item_types(account_id, sort_order);
+CREATE INDEX idx_inventory_lots_fifo ON inventory_lots(account_id, item_type, received_at, id);
+CREATE INDEX idx_inventory_history_type ON inventory_history(item_type);
+CREATE INDEX idx_inventory_history_timestamp ON inventory_history(timestamp DESC);
+CREATE INDEX idx_inventory_history_reference ON inventory_history(reference_type, reference_id);
+CREATE INDEX idx_purchase_orders_account ON purchase_orders(account_id, ordered_at DESC);
+CREATE INDEX idx_purchase_order_items_order ON purchase_order_items(order_id);
+CREATE INDEX idx_injections_course ON injections(course_id);
+CREATE INDEX idx_injections_timestamp ON injections(timestamp DESC);
+CREATE INDEX idx_injections_side ON injections(side);
+CREATE INDEX idx_injections_course_timestamp ON injections(course_id, timestamp DESC);
+CREATE INDEX idx_lot_consumptions_injection ON inventory_lot_consumptions(injection_id);
+CREATE INDEX idx_lot_consumptions_lot ON inventory_lot_consumptions(lot_id);
+CREATE INDEX idx_symptom_logs_course ON symptom_logs(course_id);
+CREATE INDEX idx_symptom_logs_timestamp ON symptom_logs(timestamp DESC);
+CREATE INDEX idx_symptom_logs_course_timestamp ON symptom_logs(course_id, timestamp DESC);
+CREATE INDEX idx_medications_active ON medications(is_active);
+CREATE INDEX idx_medications_name ON medications(name);
+CREATE INDEX idx_medications_account ON medications(account_id);
+CREATE INDEX idx_medications_account_active ON medications(account_id, is_active);
+CREATE INDEX idx_medication_logs_med ON medication_logs(medication_id);
+CREATE INDEX idx_medication_logs_timestamp ON medication_logs(timestamp DESC);
+CREATE INDEX idx_medication_logs_med_timestamp ON medication_logs(medication_id, timestamp DESC);
+CREATE INDEX idx_vitals_account_timestamp ON vitals(account_id, timestamp DESC);
+CREATE INDEX idx_appointments_account_starts ON appointments(account_id, starts_at);
+CREATE INDEX idx_notifications_user ON notifications(user_id);
+CREATE INDEX idx_notifications_read ON notifications(is_read);
+CREATE INDEX idx_notifications_scheduled ON notifications(scheduled_time);
+CREATE INDEX idx_notification_channels_account ON notification_channels(account_id);
+CREATE INDEX idx_notification_channels_account_enabled ON notification_channels(account_id, is_enabled);
+CREATE INDEX idx_webhooks_account ON webhooks(account_id);
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at DESC);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX idx_report_schedules_user ON report_schedules(account_id, user_id);
+CREATE INDEX idx_report_schedules_due ON report_schedules(is_enabled, next_run_at);
+CREATE INDEX idx_calendar_tokens_user ON calendar_tokens(account_id, user_id);
+CREATE INDEX idx_sync_operations_created ON sync_operations(created_at);
+CREATE INDEX idx_audit_logs_user ON audit_logs(user_id);
+CREATE INDEX idx_audit_logs_entity ON audit_logs(entity_type, entity_id);
+CREATE INDEX idx_audit_logs_timestamp ON audit_logs(timestamp DESC);
+CREATE INDEX idx_audit_logs_action ON audit_logs(action);
+
+-- Default settings
+INSERT INTO settings (key, value) VALUES
+    ('advanced_mode_enabled', 'false'),
+    ('heat_map_days', '14'),
+    ('allow_registration', 'true'),
+    ('require_email_verification', 'false'),
+    ('injection_reminder_enabled', 'false'),
+    ('low_stock_alert_enabled', 'true')
+ON CONFLICT DO NOTHING;
+
+-- updated_at triggers
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+    IF NEW.updated_at IS NOT DISTINCT FROM OLD.updated_at THEN
+        NEW.updated_at = clock_timestamp();
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_accounts_timestamp BEFORE UPDATE ON accounts
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_compounds_timestamp BEFORE UPDATE ON compounds
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_courses_timestamp BEFORE UPDATE ON courses
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_inventory_items_timestamp BEFORE UPDATE ON inventory_items
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_inventory_item_types_timestamp BEFORE UPDATE ON inventory_item_types
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_inventory_lots_timestamp BEFORE UPDATE ON inventory_lots
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_purchase_orders_timestamp BEFORE UPDATE ON purchase_orders
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_injections_timestamp BEFORE UPDATE ON injections
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_symptom_logs_timestamp BEFORE UPDATE ON symptom_logs
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_medications_timestamp BEFORE UPDATE ON medications
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_notification_channels_timestamp BEFORE UPDATE ON notification_channels
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_webhooks_timestamp BEFORE UPDATE ON webhooks
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER update_report_schedules_timestamp BEFORE UPDATE ON report_schedules
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,2 @@
+-- Undo 022: rate limits are only counted in memory
+DROP TABLE IF EXISTS rate_limits;
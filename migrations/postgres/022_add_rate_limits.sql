@@ -0,0 +1,21 @@
+-- ============================================
+-- MIGRATION 022: SHARED RATE LIMIT COUNTERS
+-- ============================================
+-- Rate limits were counted in memory, so they reset whenever the server
+-- restarted and each replica behind a load balancer kept its own count.
+-- With RATE_LIMIT_STORE=database the counters live here instead.
+--
+-- key is the policy name and who is being limited (an IP address or a
+-- user), e.g. 'login:ip:203.0.113.7'. A window starts with the first hit
+-- after the previous one ended and window_end is when it runs out, in Unix
+-- milliseconds so every dialect compares it the same way. Expired rows are
+-- pruned hourly.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key TEXT PRIMARY KEY,
+    window_end BIGINT NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limits_window_end ON rate_limits(window_end);
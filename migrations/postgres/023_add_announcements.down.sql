@@ -0,0 +1,2 @@
+-- Undo 023: announcements are lost
+DROP TABLE IF EXISTS announcements;
@@ -0,0 +1,22 @@
+-- ============================================
+-- MIGRATION 023: SITE ANNOUNCEMENTS
+-- ============================================
+-- Banners the admin shows to every user, such as notice of planned
+-- maintenance. severity picks the banner's colour. An announcement with an
+-- expires_at stops showing once it passes and is kept for the admin's list
+-- until deleted.
+--
+-- Maintenance mode itself is a pair of settings (maintenance_mode and
+-- maintenance_message), not a table.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS announcements (
+    id BIGSERIAL PRIMARY KEY,
+    message TEXT NOT NULL,
+    severity TEXT NOT NULL DEFAULT 'info' CHECK(severity IN ('info', 'warning', 'danger')),
+    expires_at TIMESTAMPTZ,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_announcements_expires ON announcements(expires_at);
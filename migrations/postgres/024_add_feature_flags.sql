@@ -0,0 +1,21 @@
+-- ============================================
+-- MIGRATION 024: FEATURE FLAGS
+-- ============================================
+-- Switches for experimental features, set by the admin. A row with no
+-- account_id applies to the whole site; a row for an account overrides it
+-- there. Flags without a row take their default from the code
+-- (services.FeatureFlags), so only the admin's choices are stored.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS feature_flags (
+    id BIGSERIAL PRIMARY KEY,
+    key TEXT NOT NULL,
+    account_id BIGINT REFERENCES accounts(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL,
+    updated_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One site-wide row per flag and one per account; NULLs are distinct in a
+-- unique index, hence the COALESCE
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feature_flags_key_account ON feature_flags(key, COALESCE(account_id, 0));
@@ -0,0 +1,39 @@
+-- ============================================
+-- MIGRATION 025: CAREGIVER SHARE LINKS
+-- ============================================
+-- A share link gives a nurse or family member a read-only view of an
+-- account's dashboard without a login of their own. Like calendar feeds,
+-- the link carries a secret token and only its SHA-256 hash is stored.
+-- Links always expire, and can be revoked sooner.
+--
+-- scopes is a comma-separated list of what the link shows: injections,
+-- adherence, schedule and medications.
+--
+-- Every view of a link is recorded in share_link_accesses so the account
+-- can see who has been looking. Revoked links are kept with their history.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS share_links (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    created_by BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_links_account ON share_links(account_id);
+
+CREATE TABLE IF NOT EXISTS share_link_accesses (
+    id BIGSERIAL PRIMARY KEY,
+    share_link_id BIGINT NOT NULL REFERENCES share_links(id) ON DELETE CASCADE,
+    accessed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    ip_address TEXT,
+    user_agent TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_link_accesses_link ON share_link_accesses(share_link_id, accessed_at);
@@ -0,0 +1,3 @@
+-- Undo 026: every symptom log is shared again
+ALTER TABLE accounts DROP COLUMN owners_see_private;
+ALTER TABLE symptom_logs DROP COLUMN visibility;
@@ -0,0 +1,10 @@
+-- ============================================
+-- MIGRATION 026: PRIVATE SYMPTOM LOGS
+-- ============================================
+-- In a shared account a symptom log, notes included, can be marked private
+-- so only the member who logged it sees it. Owners can turn on
+-- owners_see_private to see every member's private logs as well.
+-- ============================================
+
+ALTER TABLE symptom_logs ADD COLUMN visibility TEXT NOT NULL DEFAULT 'shared' CHECK(visibility IN ('shared', 'private'));
+ALTER TABLE accounts ADD COLUMN owners_see_private BOOLEAN NOT NULL DEFAULT FALSE;
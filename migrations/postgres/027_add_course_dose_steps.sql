@@ -0,0 +1,22 @@
+-- ============================================
+-- MIGRATION 027: DOSE TAPER SCHEDULES
+-- ============================================
+-- Many protocols step the dose down over a few weeks. A course's taper
+-- schedule is a list of date ranges, each with the volume to give per
+-- injection. end_date is inclusive; NULL keeps the step going until the
+-- course ends. Days outside every step fall back to the course's dose.
+--
+-- New injections without a dose take it from the step covering their day,
+-- and reports flag injections whose dose differs from the schedule.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS course_dose_steps (
+    id BIGSERIAL PRIMARY KEY,
+    course_id BIGINT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    end_date DATE,
+    dose_ml DOUBLE PRECISION NOT NULL CHECK(dose_ml > 0),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_course_dose_steps_course ON course_dose_steps(course_id, start_date);
@@ -0,0 +1,3 @@
+-- Undo 028: the catalog goes; logs keep their JSON arrays of symptom keys
+DROP TABLE IF EXISTS symptom_log_items;
+DROP TABLE IF EXISTS symptom_catalog;
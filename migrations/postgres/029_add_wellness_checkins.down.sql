@@ -0,0 +1,2 @@
+-- Undo 029
+DROP TABLE IF EXISTS wellness_checkins;
@@ -0,0 +1,30 @@
+-- ============================================
+-- MIGRATION 029: DAILY WELLNESS CHECK-INS
+-- ============================================
+-- Symptom logs are about pain and specific symptoms. A check-in is a quick
+-- once-a-day record of how a member is doing overall: mood and energy on a
+-- 1-5 scale, hours slept, and optionally temperature (degC) and weight (kg).
+-- Every field but the date is optional. A member has at most one check-in
+-- per calendar day, in their own timezone.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS wellness_checkins (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    date DATE NOT NULL,
+    mood INTEGER CHECK(mood IS NULL OR mood BETWEEN 1 AND 5),
+    energy INTEGER CHECK(energy IS NULL OR energy BETWEEN 1 AND 5),
+    sleep_hours DOUBLE PRECISION CHECK(sleep_hours IS NULL OR sleep_hours BETWEEN 0 AND 24),
+    temperature DOUBLE PRECISION,
+    weight DOUBLE PRECISION,
+    notes TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(account_id, user_id, date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wellness_checkins_account_date ON wellness_checkins(account_id, date DESC);
+
+CREATE TRIGGER update_wellness_checkins_timestamp BEFORE UPDATE ON wellness_checkins
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,3 @@
+-- Undo 030: journal entries are lost
+DROP TABLE IF EXISTS journal_entry_tags;
+DROP TABLE IF EXISTS journal_entries;
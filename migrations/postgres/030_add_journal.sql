@@ -0,0 +1,36 @@
+-- ============================================
+-- MIGRATION 030: JOURNAL
+-- ============================================
+-- Free-form notes that don't fit a symptom log: clinic visit notes,
+-- questions for the next appointment, how a week went. The body is markdown
+-- and is stored as written. An entry can be linked to a course and to a
+-- calendar day, carries any number of tags, and can be pinned to the
+-- dashboard.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    author_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    course_id BIGINT REFERENCES courses(id) ON DELETE SET NULL,
+    entry_date DATE,
+    title TEXT,
+    body TEXT NOT NULL,
+    is_pinned BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_account_created ON journal_entries(account_id, created_at DESC);
+
+-- Tags are stored lowercased, so "Clinic" and "clinic" are the same tag
+CREATE TABLE IF NOT EXISTS journal_entry_tags (
+    entry_id BIGINT NOT NULL REFERENCES journal_entries(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (entry_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entry_tags_tag ON journal_entry_tags(tag);
+
+CREATE TRIGGER update_journal_entries_timestamp BEFORE UPDATE ON journal_entries
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,2 @@
+-- Undo 031: lab results are lost
+DROP TABLE IF EXISTS lab_results;
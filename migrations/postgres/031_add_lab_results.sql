@@ -0,0 +1,30 @@
+-- ============================================
+-- MIGRATION 031: LAB RESULTS
+-- ============================================
+-- Blood work such as progesterone and estradiol levels, kept alongside the
+-- injections so dose changes can be read against them. The analyte is free
+-- text, matched without regard to case; the unit is whatever the lab
+-- reported, and the reference range is the lab's own when given. A result
+-- can be linked to the course it was drawn during.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS lab_results (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    course_id BIGINT REFERENCES courses(id) ON DELETE SET NULL,
+    analyte TEXT NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    unit TEXT NOT NULL,
+    reference_low DOUBLE PRECISION,
+    reference_high DOUBLE PRECISION,
+    drawn_at TIMESTAMPTZ NOT NULL,
+    notes TEXT,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_lab_results_account_drawn ON lab_results(account_id, drawn_at);
+
+CREATE TRIGGER update_lab_results_timestamp BEFORE UPDATE ON lab_results
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,3 @@
+-- Undo 032: per-vial volumes and opened dates are lost
+DROP TABLE IF EXISTS inventory_vial_consumptions;
+DROP TABLE IF EXISTS inventory_vials;
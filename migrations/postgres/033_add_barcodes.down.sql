@@ -0,0 +1,3 @@
+-- Undo 033: the NDC directory and saved barcode mappings are lost
+DROP TABLE IF EXISTS barcode_mappings;
+DROP TABLE IF EXISTS ndc_products;
@@ -0,0 +1,10 @@
+-- ============================================
+-- MIGRATION 034: PURCHASE UNITS
+-- ============================================
+-- Stock is bought by the vial or box but used by the mL or count. An item
+-- type can name the unit it is bought in and how many usage units one holds,
+-- so a restock can be entered as "3 vials" and stored as 30 mL.
+-- ============================================
+
+ALTER TABLE inventory_item_types ADD COLUMN purchase_unit TEXT CHECK(purchase_unit IN ('vial', 'box'));
+ALTER TABLE inventory_item_types ADD COLUMN units_per_purchase DOUBLE PRECISION CHECK(units_per_purchase > 0);
@@ -0,0 +1,9 @@
+-- Undo 035: stocktakes and their counts are lost; history entries they
+-- posted become manual adjustments
+UPDATE inventory_history SET reason = 'manual_adjustment' WHERE reason = 'stocktake';
+ALTER TABLE inventory_history DROP CONSTRAINT IF EXISTS inventory_history_reason_check;
+ALTER TABLE inventory_history ADD CONSTRAINT inventory_history_reason_check
+    CHECK(reason IN ('injection', 'manual_adjustment', 'restock', 'expired', 'other'));
+
+DROP TABLE IF EXISTS stocktake_counts;
+DROP TABLE IF EXISTS stocktakes;
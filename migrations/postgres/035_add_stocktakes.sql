@@ -0,0 +1,51 @@
+-- ============================================
+-- MIGRATION 035: STOCKTAKES
+-- ============================================
+-- A stocktake is a physical count of what is on the shelf. While one is
+-- open, counts are entered per item or per lot, each remembering the
+-- quantity recorded at the moment it was counted. Completing the stocktake
+-- posts the difference as an inventory history entry with reason
+-- 'stocktake', so injections logged while counting aren't undone.
+--
+-- An account has at most one open stocktake. inventory_history gains
+-- 'stocktake' among its reasons.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS stocktakes (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'completed', 'cancelled')),
+    notes TEXT,
+    started_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    closed_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    closed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stocktakes_account ON stocktakes(account_id, started_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktakes_open ON stocktakes(account_id) WHERE status = 'open';
+
+CREATE TRIGGER update_stocktakes_timestamp BEFORE UPDATE ON stocktakes
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- One count per item, or per lot of an item, in a stocktake
+CREATE TABLE IF NOT EXISTS stocktake_counts (
+    id BIGSERIAL PRIMARY KEY,
+    stocktake_id BIGINT NOT NULL REFERENCES stocktakes(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL CHECK(length(item_type) BETWEEN 1 AND 50),
+    lot_id BIGINT REFERENCES inventory_lots(id) ON DELETE CASCADE,  -- NULL counts the whole item
+    recorded_quantity DOUBLE PRECISION NOT NULL,
+    counted_quantity DOUBLE PRECISION NOT NULL CHECK(counted_quantity >= 0),
+    notes TEXT,
+    counted_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    counted_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktake_counts_item ON stocktake_counts(stocktake_id, item_type) WHERE lot_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktake_counts_lot ON stocktake_counts(stocktake_id, lot_id) WHERE lot_id IS NOT NULL;
+
+ALTER TABLE inventory_history DROP CONSTRAINT IF EXISTS inventory_history_reason_check;
+ALTER TABLE inventory_history ADD CONSTRAINT inventory_history_reason_check
+    CHECK(reason IN ('injection', 'manual_adjustment', 'restock', 'expired', 'stocktake', 'other'));
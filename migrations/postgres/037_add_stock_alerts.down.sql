@@ -0,0 +1,2 @@
+-- Undo 037: stock alerts and their acknowledgements are lost
+DROP TABLE IF EXISTS stock_alerts;
@@ -0,0 +1,33 @@
+-- ============================================
+-- MIGRATION 037: STOCK ALERTS
+-- ============================================
+-- An item at or under its low stock threshold raises a stock alert, which
+-- is delivered through the notification channels and stays active until
+-- the item is restocked. The alert remembers its level (low, critical or
+-- out) and when it was last sent, so it is repeated once a day rather than
+-- on every check, and not at all once acknowledged. Running out escalates
+-- it, which sends it again even if acknowledged.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS stock_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    item_type TEXT NOT NULL,
+    level TEXT NOT NULL CHECK(level IN ('low', 'critical', 'out')),
+    quantity DOUBLE PRECISION NOT NULL,
+    threshold DOUBLE PRECISION NOT NULL,
+    raised_at TIMESTAMPTZ NOT NULL,
+    notified_at TIMESTAMPTZ,
+    notify_count INTEGER NOT NULL DEFAULT 0,
+    acknowledged_at TIMESTAMPTZ,
+    acknowledged_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_alerts_account ON stock_alerts(account_id, raised_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_alerts_active ON stock_alerts(account_id, item_type) WHERE resolved_at IS NULL;
+
+CREATE TRIGGER update_stock_alerts_timestamp BEFORE UPDATE ON stock_alerts
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
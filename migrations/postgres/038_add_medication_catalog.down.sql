@@ -0,0 +1,3 @@
+-- Undo 038: medications lose their link to the catalog, which is dropped
+ALTER TABLE medications DROP COLUMN catalog_id;
+DROP TABLE IF EXISTS medication_catalog;
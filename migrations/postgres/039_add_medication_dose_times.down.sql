@@ -0,0 +1,3 @@
+-- Undo 039: medications keep only their first dose time
+ALTER TABLE medication_logs DROP COLUMN scheduled_for;
+ALTER TABLE medications DROP COLUMN dose_times;
@@ -0,0 +1,12 @@
+-- ============================================
+-- MIGRATION 040: MEDICATION INVENTORY
+-- ============================================
+-- Only injections took stock out of inventory, so oral and vaginal
+-- medications had none. A medication can now name the inventory item type
+-- it is stocked as and how many units one dose uses. Logging a dose as
+-- taken decrements that item, with an inventory history entry referencing
+-- the medication log.
+-- ============================================
+
+ALTER TABLE medications ADD COLUMN inventory_item_type TEXT CHECK(inventory_item_type IS NULL OR length(inventory_item_type) BETWEEN 1 AND 50);
+ALTER TABLE medications ADD COLUMN units_per_dose DOUBLE PRECISION CHECK(units_per_dose IS NULL OR units_per_dose > 0);
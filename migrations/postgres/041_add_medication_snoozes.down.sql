@@ -0,0 +1,4 @@
+-- Undo 041: doses can't be snoozed and logs no longer record lateness
+DROP INDEX IF EXISTS idx_medication_dose_snoozes_until;
+DROP TABLE IF EXISTS medication_dose_snoozes;
+ALTER TABLE medication_logs DROP COLUMN late;
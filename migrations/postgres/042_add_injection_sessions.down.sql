@@ -0,0 +1,2 @@
+-- Undo 042: guided injection sessions are dropped; their injections stay
+DROP TABLE IF EXISTS injection_sessions;
@@ -0,0 +1,36 @@
+-- ============================================
+-- MIGRATION 042: GUIDED INJECTION SESSIONS
+-- ============================================
+-- A guided injection walks through preparing and giving an injection one
+-- step at a time. The session records how long the medication was warmed,
+-- timed across as many starts and stops as it takes, holds the injection's
+-- details once entered, and on completion writes the injection and closes
+-- the session in one transaction, keeping the total time taken.
+--
+-- details is the injection as entered, JSON in the shape of an injection
+-- request. A member has at most one open session per account.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS injection_sessions (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    course_id BIGINT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'completed', 'cancelled')),
+    started_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    warming_started_at TIMESTAMPTZ,  -- Set while the warm-up timer runs
+    warming_seconds INTEGER NOT NULL DEFAULT 0 CHECK(warming_seconds >= 0),
+    details TEXT,
+    details_at TIMESTAMPTZ,
+    injection_id BIGINT REFERENCES injections(id) ON DELETE SET NULL,
+    closed_at TIMESTAMPTZ,
+    duration_seconds INTEGER CHECK(duration_seconds >= 0),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_injection_sessions_account ON injection_sessions(account_id, started_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_injection_sessions_open ON injection_sessions(account_id, started_by) WHERE status = 'open';
+
+CREATE TRIGGER update_injection_sessions_timestamp BEFORE UPDATE ON injection_sessions
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,65 @@
+-- ============================================
+-- MIGRATION 043: SPLIT SETTINGS
+-- ============================================
+-- The settings table held three kinds of setting under one namespace: the
+-- admin's site-wide settings (SMTP, site details, backups and so on), the
+-- injection settings every account shared, and each user's preferences
+-- under user_<name>_<id> keys. They now have a table each:
+--
+--   site_settings     set by the admin, for the whole site
+--   account_settings  shared by an account's members
+--   user_settings     a user's own preferences, keyed by name alone
+--
+-- The injection settings are copied to every account, so each starts with
+-- the values all accounts saw until now. Per-user rows of users that no
+-- longer exist are dropped.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS site_settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_by BIGINT REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS account_settings (
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    PRIMARY KEY (account_id, key)
+);
+
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, key)
+);
+
+INSERT INTO account_settings (account_id, key, value, updated_at, updated_by)
+SELECT a.id, s.key, s.value, s.updated_at, s.updated_by
+FROM settings s
+CROSS JOIN accounts a
+WHERE s.key IN ('advanced_mode_enabled', 'heat_map_days', 'low_stock_alerts',
+    'injection_reminders', 'reminder_time', 'reminder_frequency');
+
+INSERT INTO user_settings (user_id, key, value, updated_at)
+SELECT u.id, n.name, s.value, s.updated_at
+FROM settings s
+CROSS JOIN (
+    SELECT 'theme' AS name UNION ALL SELECT 'timezone' UNION ALL SELECT 'date_format'
+    UNION ALL SELECT 'time_format' UNION ALL SELECT 'enable_notifications' UNION ALL SELECT 'checkin_reminder'
+) n
+JOIN users u ON s.key = 'user_' || n.name || '_' || u.id;
+
+INSERT INTO site_settings (key, value, updated_at, updated_by)
+SELECT key, value, updated_at, updated_by
+FROM settings
+WHERE key NOT LIKE 'user\_%' ESCAPE '\'
+AND key NOT IN ('advanced_mode_enabled', 'heat_map_days', 'low_stock_alerts',
+    'injection_reminders', 'reminder_time', 'reminder_frequency');
+
+DROP TABLE settings;
@@ -0,0 +1,4 @@
+-- Undo 046: sessions and audit log entries no longer record impersonation
+DROP INDEX IF EXISTS idx_audit_logs_impersonator;
+ALTER TABLE audit_logs DROP COLUMN impersonator_id;
+ALTER TABLE session_tokens DROP COLUMN impersonator_id;
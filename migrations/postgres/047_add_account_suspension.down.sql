@@ -0,0 +1,5 @@
+-- Undo 047: suspended accounts become usable again
+ALTER TABLE accounts DROP COLUMN suspended_by;
+ALTER TABLE accounts DROP COLUMN suspended_at;
+ALTER TABLE accounts DROP COLUMN suspension_reason;
+ALTER TABLE accounts DROP COLUMN suspension;
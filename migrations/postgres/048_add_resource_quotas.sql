@@ -0,0 +1,24 @@
+-- ============================================
+-- MIGRATION 048: RESOURCE QUOTAS
+-- ============================================
+-- Limits the admin sets on what an account can use, so one account on a
+-- shared instance can't crowd out the rest: how many members it has, how
+-- much its attachments take up and how large a backup of it can be. A row
+-- with no account_id applies to the whole site; a row for an account
+-- overrides it there, limit by limit. A null limit falls back to the
+-- site-wide one, and a null or zero site-wide limit is unlimited.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS resource_quotas (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT REFERENCES accounts(id) ON DELETE CASCADE,
+    max_members INTEGER CHECK (max_members >= 0),
+    attachment_storage_mb INTEGER CHECK (attachment_storage_mb >= 0),
+    backup_mb INTEGER CHECK (backup_mb >= 0),
+    updated_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One site-wide row and one per account; NULLs are distinct in a unique
+-- index, hence the COALESCE
+CREATE UNIQUE INDEX IF NOT EXISTS idx_resource_quotas_account ON resource_quotas(COALESCE(account_id, 0));
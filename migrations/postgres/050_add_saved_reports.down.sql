@@ -0,0 +1,3 @@
+-- Undo 050: saved reports are dropped, and schedules stop attaching them
+ALTER TABLE report_schedules DROP COLUMN saved_report_id;
+DROP TABLE IF EXISTS saved_reports;
@@ -0,0 +1,27 @@
+-- ============================================
+-- MIGRATION 050: SAVED REPORTS
+-- ============================================
+-- Users can define reports of their own: which records to include, which
+-- of their columns, over what dates, grouped how and filtered by what.
+-- The definition is stored as JSON and only ever turned into SQL through
+-- the fixed list of entities and columns in the report query builder.
+-- A saved report runs on demand to CSV or PDF, and a report schedule can
+-- attach one to its email, run over the email's period.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS saved_reports (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL CHECK(length(name) BETWEEN 1 AND 100),
+    definition TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_saved_reports_user ON saved_reports(account_id, user_id);
+
+CREATE TRIGGER update_saved_reports_timestamp BEFORE UPDATE ON saved_reports
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+ALTER TABLE report_schedules ADD COLUMN saved_report_id BIGINT REFERENCES saved_reports(id) ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+-- Undo 051: everything in the trash is gone for good
+DROP TABLE IF EXISTS trash_items;
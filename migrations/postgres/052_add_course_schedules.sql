@@ -0,0 +1,22 @@
+-- ============================================
+-- MIGRATION 052: COURSE SCHEDULES
+-- ============================================
+-- Each course can say when its injections fall due instead of every
+-- course following the account's reminder_frequency: either every
+-- interval_hours, or on set weekdays (comma separated, e.g. 'mon,wed,fri').
+-- Whole-day intervals and weekdays are due at time_of_day (HH:MM), or the
+-- account's reminder_time when it is NULL. A course without a row keeps
+-- following the account's settings.
+--
+-- The schedule drives the next-due time, injection reminders, adherence in
+-- reports and the calendar.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS course_schedules (
+    course_id BIGINT PRIMARY KEY REFERENCES courses(id) ON DELETE CASCADE,
+    interval_hours INTEGER CHECK(interval_hours IS NULL OR interval_hours BETWEEN 1 AND 2160),
+    weekdays TEXT,
+    time_of_day TEXT,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CHECK((interval_hours IS NULL) <> (weekdays IS NULL))
+);
@@ -0,0 +1,2 @@
+-- Undo 053: missed doses and why they were skipped are forgotten
+DROP TABLE IF EXISTS missed_injections;
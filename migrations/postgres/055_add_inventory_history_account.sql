@@ -0,0 +1,41 @@
+-- ============================================
+-- MIGRATION 055: INVENTORY HISTORY ACCOUNTS
+-- ============================================
+-- Inventory history was only tied to an account through its item type,
+-- which several accounts can share. account_id records whose stock an entry
+-- changed. Existing entries take it from what they reference, then from the
+-- account of whoever made the change, then from the only account with the
+-- item. The rest stay NULL and belong to no account's history.
+-- ============================================
+
+ALTER TABLE inventory_history ADD COLUMN account_id BIGINT REFERENCES accounts(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_inventory_history_account ON inventory_history(account_id, timestamp);
+
+UPDATE inventory_history SET account_id = CASE reference_type
+    WHEN 'injection' THEN (
+        SELECT c.account_id FROM injections i JOIN courses c ON c.id = i.course_id
+        WHERE i.id = inventory_history.reference_id)
+    WHEN 'medication_log' THEN (
+        SELECT m.account_id FROM medication_logs l JOIN medications m ON m.id = l.medication_id
+        WHERE l.id = inventory_history.reference_id)
+    WHEN 'stocktake' THEN (SELECT account_id FROM stocktakes WHERE id = inventory_history.reference_id)
+    WHEN 'vial' THEN (SELECT account_id FROM inventory_vials WHERE id = inventory_history.reference_id)
+    WHEN 'purchase' THEN (SELECT account_id FROM purchase_orders WHERE id = inventory_history.reference_id)
+END
+WHERE reference_type IS NOT NULL;
+
+UPDATE inventory_history SET account_id = (
+    SELECT MIN(am.account_id) FROM account_members am
+    JOIN inventory_items i ON i.account_id = am.account_id AND i.item_type = inventory_history.item_type
+    WHERE am.user_id = inventory_history.performed_by
+    HAVING COUNT(*) = 1
+)
+WHERE account_id IS NULL AND performed_by IS NOT NULL;
+
+UPDATE inventory_history SET account_id = (
+    SELECT MIN(i.account_id) FROM inventory_items i
+    WHERE i.item_type = inventory_history.item_type
+    HAVING COUNT(*) = 1
+)
+WHERE account_id IS NULL;
@@ -0,0 +1,2 @@
+-- Undo 056: missed doses already reported may be reported again
+DROP TABLE IF EXISTS medication_missed_doses;
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -14,6 +15,8 @@ import (
 	"injection-tracker/internal/middleware"
 	"injection-tracker/internal/models"
 	"injection-tracker/internal/repository"
+
+	"github.com/go-chi/chi/v5"
 )
 
 // setupSecurityTestDB creates a test database with required schema
@@ -29,8 +32,13 @@ func setupSecurityTestDB(t *testing.T) *database.DB {
 		CREATE TABLE accounts (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			name TEXT,
+			owners_see_private BOOLEAN NOT NULL DEFAULT 0,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			suspension TEXT,
+			suspension_reason TEXT,
+			suspended_at TIMESTAMP,
+			suspended_by INTEGER
 		);
 
 		INSERT INTO accounts (id, name) VALUES (1, 'Test Account');
@@ -73,6 +81,34 @@ func setupSecurityTestDB(t *testing.T) *database.DB {
 			accepted_by INTEGER REFERENCES users(id) ON DELETE SET NULL
 		);
 
+		CREATE TABLE inventory_item_types (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+			item_type TEXT NOT NULL,
+			name TEXT NOT NULL,
+			unit TEXT NOT NULL,
+			decrement_per_injection REAL NOT NULL DEFAULT 0,
+			reorder_threshold REAL,
+			sort_order INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(account_id, item_type)
+		);
+
+		CREATE TABLE symptom_catalog (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+			key TEXT NOT NULL,
+			name TEXT NOT NULL,
+			category TEXT NOT NULL DEFAULT 'general',
+			scale_type TEXT NOT NULL DEFAULT 'presence',
+			sort_order INTEGER NOT NULL DEFAULT 0,
+			is_archived BOOLEAN NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(account_id, key)
+		);
+
 		CREATE TABLE audit_logs (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			user_id INTEGER,
@@ -82,7 +118,34 @@ func setupSecurityTestDB(t *testing.T) *database.DB {
 			details TEXT,
 			ip_address TEXT,
 			user_agent TEXT,
-			timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			impersonator_id INTEGER REFERENCES users(id) ON DELETE SET NULL
+		);
+
+		CREATE TABLE session_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			last_used_at TIMESTAMP,
+			ip_address TEXT,
+			user_agent TEXT,
+			is_revoked BOOLEAN DEFAULT 0,
+			impersonator_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+			trusted_device_id INTEGER REFERENCES trusted_devices(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE trusted_devices (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			last_used_at TIMESTAMP,
+			ip_address TEXT,
+			user_agent TEXT,
+			is_revoked BOOLEAN DEFAULT 0
 		);
 	`
 	if _, err := db.Exec(schema); err != nil {
@@ -195,13 +258,20 @@ func TestSecurity_XSSPrevention(t *testing.T) {
 
 // TestSecurity_CSRFProtection tests CSRF token validation
 func TestSecurity_CSRFProtection(t *testing.T) {
-	csrf := middleware.NewCSRFProtection("test-secret")
+	csrf := middleware.NewCSRFProtection("test-secret", middleware.CSRFOptions{})
 
 	handler := csrf.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("success"))
 	}))
 
+	session := &http.Cookie{Name: middleware.CSRFCookieName, Value: "session-0123456789abcdef"}
+	newToken := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(session)
+		return csrf.GenerateToken(req)
+	}
+
 	t.Run("POST without CSRF token fails", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/api/test", nil)
 		w := httptest.NewRecorder()
@@ -214,9 +284,10 @@ func TestSecurity_CSRFProtection(t *testing.T) {
 	})
 
 	t.Run("POST with valid CSRF token succeeds", func(t *testing.T) {
-		token := csrf.GenerateToken()
+		token := newToken()
 
 		req := httptest.NewRequest(http.MethodPost, "/api/test", nil)
+		req.AddCookie(session)
 		req.Header.Set("X-CSRF-Token", token)
 		w := httptest.NewRecorder()
 
@@ -228,10 +299,11 @@ func TestSecurity_CSRFProtection(t *testing.T) {
 	})
 
 	t.Run("CSRF token can be reused within validity period", func(t *testing.T) {
-		token := csrf.GenerateToken()
+		token := newToken()
 
 		// First use
 		req1 := httptest.NewRequest(http.MethodPost, "/api/test", nil)
+		req1.AddCookie(session)
 		req1.Header.Set("X-CSRF-Token", token)
 		w1 := httptest.NewRecorder()
 		handler.ServeHTTP(w1, req1)
@@ -242,6 +314,7 @@ func TestSecurity_CSRFProtection(t *testing.T) {
 
 		// Second use should also succeed (tokens are reusable within validity period)
 		req2 := httptest.NewRequest(http.MethodPost, "/api/test", nil)
+		req2.AddCookie(session)
 		req2.Header.Set("X-CSRF-Token", token)
 		w2 := httptest.NewRecorder()
 		handler.ServeHTTP(w2, req2)
@@ -522,6 +595,10 @@ func TestSecurity_SessionManagement(t *testing.T) {
 	}
 
 	handler := handlers.HandleLogin(db, jwtManager)
+	trustManager := auth.NewJWTManager("test-secret", 2*time.Hour).
+		WithIdleTimeout(time.Hour).
+		WithDeviceTrust(30 * 24 * time.Hour)
+	trusting := handlers.HandleLogin(db, trustManager)
 
 	t.Run("Successful login sets secure cookie", func(t *testing.T) {
 		payload := map[string]string{
@@ -532,6 +609,7 @@ func TestSecurity_SessionManagement(t *testing.T) {
 
 		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(string(body)))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-Proto", "https")
 		w := httptest.NewRecorder()
 
 		handler.ServeHTTP(w, req)
@@ -567,6 +645,225 @@ func TestSecurity_SessionManagement(t *testing.T) {
 			t.Error("Cookie should have SameSite=Strict")
 		}
 	})
+
+	t.Run("Login over plain HTTP sets a cookie the browser keeps", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]string{
+			"username": "testuser",
+			"password": "password123",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Login failed: %d", w.Code)
+		}
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == "auth_token" && cookie.Secure {
+				t.Error("Cookie should not be Secure over plain HTTP, or browsers drop it")
+			}
+		}
+	})
+
+	t.Run("Revoked session can't be used", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]string{
+			"username": "testuser",
+			"password": "password123",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		var resp handlers.AuthResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Token == "" {
+			t.Fatalf("Login failed: %d", w.Code)
+		}
+
+		authMiddleware := middleware.NewAuthMiddleware(jwtManager)
+		router := chi.NewRouter()
+		router.Use(authMiddleware.RequireAuth, handlers.CurrentSession(db, jwtManager))
+		router.Get("/api/me/security", handlers.HandleGetSecurity(db))
+		router.Delete("/api/me/security/sessions/{id}", handlers.HandleRevokeSession(db))
+		send := func(method, path string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, nil)
+			req.Header.Set("Authorization", "Bearer "+resp.Token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		w = send(http.MethodGet, "/api/me/security")
+		var security handlers.SecurityResponse
+		if err := json.NewDecoder(w.Body).Decode(&security); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("Expected sign-in activity, got %d (%v)", w.Code, err)
+		}
+		var current int64
+		for _, s := range security.Sessions {
+			if s.Current {
+				current = s.ID
+			}
+		}
+		if current == 0 || len(security.RecentLogins) == 0 || !security.RecentLogins[0].Success {
+			t.Fatalf("Expected the current session and its login, got %+v", security)
+		}
+
+		if w := send(http.MethodDelete, "/api/me/security/sessions/"+strconv.FormatInt(current, 10)); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected the session revoked, got %d", w.Code)
+		}
+		if w := send(http.MethodGet, "/api/me/security"); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the revoked session's token rejected, got %d", w.Code)
+		}
+	})
+
+	t.Run("Trusted device keeps its sessions going until the trust runs out", func(t *testing.T) {
+		login := func(cookies ...*http.Cookie) (*httptest.ResponseRecorder, handlers.AuthResponse) {
+			body, _ := json.Marshal(map[string]interface{}{
+				"username":        "testuser",
+				"password":        "password123",
+				"remember_device": true,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/json")
+			for _, c := range cookies {
+				req.AddCookie(c)
+			}
+			w := httptest.NewRecorder()
+			trusting.ServeHTTP(w, req)
+			var resp handlers.AuthResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Token == "" {
+				t.Fatalf("Login failed: %d", w.Code)
+			}
+			return w, resp
+		}
+
+		w, resp := login()
+		var deviceCookie *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "device_token" {
+				deviceCookie = c
+			}
+		}
+		if deviceCookie == nil || !deviceCookie.HttpOnly || deviceCookie.MaxAge < int((29*24*time.Hour).Seconds()) {
+			t.Fatalf("Expected a 30-day device_token cookie, got %+v", deviceCookie)
+		}
+		claims, _ := trustManager.ValidateToken(resp.Token)
+		session, err := repository.NewSessionRepository(db).GetActive(claims.SessionID)
+		if err != nil || !session.TrustedDeviceID.Valid || time.Until(session.ExpiresAt) < 29*24*time.Hour {
+			t.Fatalf("Expected a session lasting the trust, got %+v (%v)", session, err)
+		}
+
+		// Signing in again on the device keeps the same trust
+		w, resp = login(deviceCookie)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "device_token" {
+				t.Error("Expected the device's trust to be kept, not replaced")
+			}
+		}
+		claims, _ = trustManager.ValidateToken(resp.Token)
+		again, err := repository.NewSessionRepository(db).GetActive(claims.SessionID)
+		if err != nil || again.TrustedDeviceID != session.TrustedDeviceID {
+			t.Fatalf("Expected the second session on the same device, got %+v (%v)", again, err)
+		}
+
+		authMiddleware := middleware.NewAuthMiddleware(trustManager)
+		router := chi.NewRouter()
+		router.Use(authMiddleware.RequireAuth, handlers.CurrentSession(db, trustManager))
+		router.Get("/api/me/security", handlers.HandleGetSecurity(db))
+		router.Delete("/api/me/security/devices/{id}", handlers.HandleRevokeTrustedDevice(db))
+		send := func(method, path string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, nil)
+			req.Header.Set("Authorization", "Bearer "+resp.Token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		// Going unused doesn't sign a trusted device out
+		if _, err := db.Exec(`UPDATE session_tokens SET last_used_at = ? WHERE id = ?`, time.Now().Add(-2*time.Hour).UTC(), again.ID); err != nil {
+			t.Fatalf("Failed to age session: %v", err)
+		}
+		w = send(http.MethodGet, "/api/me/security")
+		var security handlers.SecurityResponse
+		if err := json.NewDecoder(w.Body).Decode(&security); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("Expected the idle trusted session let through, got %d (%v)", w.Code, err)
+		}
+		if len(security.TrustedDevices) != 1 || !security.TrustedDevices[0].Current {
+			t.Fatalf("Expected the current trusted device listed, got %+v", security.TrustedDevices)
+		}
+
+		if w := send(http.MethodDelete, "/api/me/security/devices/"+strconv.FormatInt(security.TrustedDevices[0].ID, 10)); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected the device's trust revoked, got %d", w.Code)
+		}
+		if w := send(http.MethodGet, "/api/me/security"); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the device's sessions signed out, got %d", w.Code)
+		}
+	})
+
+	t.Run("Expired tokens refresh until the session goes idle or ends", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]string{
+			"username": "testuser",
+			"password": "password123",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		var resp handlers.AuthResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Token == "" {
+			t.Fatalf("Login failed: %d", w.Code)
+		}
+		claims, err := jwtManager.ValidateToken(resp.Token)
+		if err != nil {
+			t.Fatalf("Failed to read token: %v", err)
+		}
+		expired, _ := auth.NewJWTManager("test-secret", time.Millisecond).
+			GenerateSessionToken(claims.UserID, claims.Username, claims.AccountID, claims.Role, claims.SessionID)
+		time.Sleep(10 * time.Millisecond)
+
+		manager := auth.NewJWTManager("test-secret", 2*time.Hour).WithIdleTimeout(time.Hour)
+		authMiddleware := middleware.NewAuthMiddleware(manager)
+		router := chi.NewRouter()
+		router.With(authMiddleware.RequireAuth, handlers.CurrentSession(db, manager)).
+			Get("/api/me/security", handlers.HandleGetSecurity(db))
+		router.With(authMiddleware.RequireRefreshableAuth, handlers.CurrentSession(db, manager)).
+			Post("/api/auth/refresh", handlers.HandleRefreshToken(db, manager))
+		send := func(method, path string) (int, string) {
+			req := httptest.NewRequest(method, path, nil)
+			req.Header.Set("Authorization", "Bearer "+expired)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			var body struct {
+				Error struct {
+					Code string `json:"code"`
+				} `json:"error"`
+			}
+			_ = json.NewDecoder(w.Body).Decode(&body)
+			return w.Code, body.Error.Code
+		}
+
+		if code, reason := send(http.MethodGet, "/api/me/security"); code != http.StatusUnauthorized || reason != "token_expired" {
+			t.Errorf("Expected 401 token_expired, got %d %q", code, reason)
+		}
+		if code, _ := send(http.MethodPost, "/api/auth/refresh"); code != http.StatusOK {
+			t.Errorf("Expected the expired token refreshed, got %d", code)
+		}
+
+		if _, err := db.Exec(`UPDATE session_tokens SET last_used_at = ?`, time.Now().Add(-2*time.Hour).UTC()); err != nil {
+			t.Fatalf("Failed to age session: %v", err)
+		}
+		if code, reason := send(http.MethodPost, "/api/auth/refresh"); code != http.StatusUnauthorized || reason != "session_idle" {
+			t.Errorf("Expected 401 session_idle, got %d %q", code, reason)
+		}
+
+		if _, err := db.Exec(`UPDATE session_tokens SET last_used_at = ?, expires_at = ?`, time.Now().UTC(), time.Now().Add(-time.Minute).UTC()); err != nil {
+			t.Fatalf("Failed to expire session: %v", err)
+		}
+		if code, reason := send(http.MethodPost, "/api/auth/refresh"); code != http.StatusUnauthorized || reason != "session_expired" {
+			t.Errorf("Expected 401 session_expired, got %d %q", code, reason)
+		}
+	})
 }
 
 // TestSecurity_BcryptCost tests bcrypt cost factor
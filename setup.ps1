@@ -38,7 +38,9 @@ ENVIRONMENT=production
 # Security (Auto-generated)
 JWT_SECRET=${JWT_SECRET}
 CSRF_SECRET=${CSRF_SECRET}
-SESSION_DURATION=336h
+SESSION_MAX_LIFETIME=336h
+SESSION_IDLE_TIMEOUT=72h
+TRUSTED_DEVICE_DURATION=720h
 
 # Database
 DATABASE_PATH=./data/tracker.db
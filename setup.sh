@@ -25,6 +25,7 @@ fi
 echo "Generating secure secrets..."
 JWT_SECRET=$(openssl rand -base64 32)
 CSRF_SECRET=$(openssl rand -base64 32)
+SECRETS_KEY=$(openssl rand -base64 32)
 
 # Create directories
 echo "Creating directories..."
@@ -40,7 +41,11 @@ ENVIRONMENT=production
 # Security (Auto-generated)
 JWT_SECRET=${JWT_SECRET}
 CSRF_SECRET=${CSRF_SECRET}
-SESSION_DURATION=336h
+# Encrypts the secrets stored in the database; keep a copy somewhere safe
+SECRETS_KEY=${SECRETS_KEY}
+SESSION_MAX_LIFETIME=336h
+SESSION_IDLE_TIMEOUT=72h
+TRUSTED_DEVICE_DURATION=720h
 
 # Database
 DATABASE_PATH=./data/tracker.db
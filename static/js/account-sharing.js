@@ -7,18 +7,57 @@ function accountSharing() {
         inviteFeedback: '',
         invitationToken: '',
         invitationLink: '',
+        inviteEmail: '',
+        inviteExpiresInDays: 7,
         copied: false,
         currentUserID: 0,
         currentUserRole: '',
+        ownersSeePrivate: false,
+        privacyFeedback: '',
 
         init() {
             this.loadMembers();
             this.loadInvitations();
+            this.loadAccount();
+        },
+
+        async loadAccount() {
+            try {
+                const response = await fetch('/api/v1/account', {
+                    headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content }
+                });
+                if (!response.ok) throw new Error('Failed to load account');
+                const account = await response.json();
+                this.ownersSeePrivate = account.OwnersSeePrivate;
+            } catch (error) {
+                this.privacyFeedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
+        },
+
+        async saveOwnersSeePrivate() {
+            this.privacyFeedback = '';
+            try {
+                const response = await fetch('/api/v1/account', {
+                    method: 'PUT',
+                    headers: {
+                        'Content-Type': 'application/json',
+                        'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content
+                    },
+                    body: JSON.stringify({ owners_see_private: this.ownersSeePrivate })
+                });
+                if (!response.ok) {
+                    const error = await responseErrorText(response);
+                    throw new Error(error || 'Failed to update account');
+                }
+            } catch (error) {
+                this.ownersSeePrivate = !this.ownersSeePrivate;
+                this.privacyFeedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
         },
 
         async loadMembers() {
             try {
-                const response = await fetch('/api/account/members', {
+                const response = await fetch('/api/v1/account/members', {
                     headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content }
                 });
 
@@ -27,9 +66,9 @@ function accountSharing() {
                 this.members = await response.json();
 
                 // Find current user's role
-                const currentMember = this.members.find(m => m.UserID === this.currentUserID);
+                const currentMember = this.members.find(m => m.user_id === this.currentUserID);
                 if (currentMember) {
-                    this.currentUserRole = currentMember.Role;
+                    this.currentUserRole = currentMember.role;
                 }
 
                 this.renderMembers();
@@ -40,31 +79,63 @@ function accountSharing() {
         },
 
         renderMembers() {
-            const html = this.members.map(member => `
+            const isOwner = this.currentUserRole === 'owner';
+            const html = this.members.map((member, index) => {
+                const name = escapeInviteHTML(member.username || 'Unknown User');
+                const lastActive = member.last_active_at
+                    ? `Last active ${new Date(member.last_active_at).toLocaleDateString()}`
+                    : 'No activity yet';
+                return `
             <div style="display: flex; justify-content: space-between; align-items: center; padding: var(--space-3); background: var(--color-surface); border: 1px solid var(--color-border); border-radius: var(--radius-md); margin-bottom: var(--space-2);">
                 <div>
-                    <strong>${member.Username || 'Unknown User'}</strong>
-                    ${member.Role === 'owner' ? '<span class="badge" style="margin-left: 0.5rem; background: var(--brand-primary-bg); color: var(--brand-primary); padding: 2px 8px; font-size: 0.75rem; border-radius: 999px;">Owner</span>' : ''}
-                    ${member.UserID === this.currentUserID ? '<span class="badge" style="margin-left: 0.5rem; background: var(--color-bg-tertiary); color: var(--color-text-secondary); padding: 2px 8px; font-size: 0.75rem; border-radius: 999px;">You</span>' : ''}
+                    <strong>${name}</strong>
+                    ${member.role === 'owner' ? '<span class="badge" style="margin-left: 0.5rem; background: var(--brand-primary-bg); color: var(--brand-primary); padding: 2px 8px; font-size: 0.75rem; border-radius: 999px;">Owner</span>' : ''}
+                    ${member.user_id === this.currentUserID ? '<span class="badge" style="margin-left: 0.5rem; background: var(--color-bg-tertiary); color: var(--color-text-secondary); padding: 2px 8px; font-size: 0.75rem; border-radius: 999px;">You</span>' : ''}
+                    ${isOwner ? `<br><small style="color: var(--color-text-muted);">Joined ${new Date(member.joined_at).toLocaleDateString()} - ${lastActive}</small>` : ''}
                 </div>
-                ${member.UserID !== this.currentUserID && this.currentUserRole === 'owner' ? `
-                    <button type="button"
-                            class="btn-sm outline secondary"
-                            style="margin: 0; color: var(--danger-primary); border-color: var(--danger-border);"
-                            onclick="removeMember(${member.UserID}, '${member.Username}')">
-                        Remove
-                    </button>
+                ${member.user_id !== this.currentUserID && isOwner ? `
+                    <div style="display: flex; gap: var(--space-2);">
+                        ${member.role !== 'owner' ? `
+                        <button type="button"
+                                class="btn-sm outline"
+                                style="margin: 0;"
+                                data-action="transfer" data-index="${index}">
+                            Make Owner
+                        </button>
+                        ` : ''}
+                        <button type="button"
+                                class="btn-sm outline secondary"
+                                style="margin: 0; color: var(--danger-primary); border-color: var(--danger-border);"
+                                data-action="remove" data-index="${index}">
+                            Remove
+                        </button>
+                    </div>
                 ` : ''}
             </div>
-        `).join('');
+        `;
+            }).join('');
 
-            document.getElementById('members-list').innerHTML =
-                html || '<p style="color: var(--color-text-muted);">No members found</p>';
+            const list = document.getElementById('members-list');
+            list.innerHTML = html || '<p style="color: var(--color-text-muted);">No members found</p>';
+
+            // Handlers are added here, as the Content Security Policy blocks
+            // inline onclick attributes
+            list.querySelectorAll('[data-action]').forEach(button => {
+                const member = this.members[button.dataset.index];
+                const name = member.username || 'Unknown User';
+                button.addEventListener('click', () => {
+                    if (button.dataset.action === 'transfer') {
+                        transferOwnership(member.user_id, name);
+                    } else {
+                        removeMember(member.user_id, name);
+                    }
+                });
+            });
         },
 
         async loadInvitations() {
             try {
-                const response = await fetch('/api/invitations', {
+                const response = await fetch('/api/v1/account/invitations', {
                     headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content }
                 });
 
@@ -92,8 +163,9 @@ function accountSharing() {
                 return;
             }
 
-            const html = this.invitations.map(inv => {
+            const html = this.invitations.map((inv, index) => {
                 const accepted = inv.accepted_at ? `<span class="text-success" style="margin-left: 0.5rem;">(Accepted ${new Date(inv.accepted_at).toLocaleDateString()})</span>` : '';
+                const expired = inv.status === 'expired' ? '<span class="text-danger" style="margin-left: 0.5rem;">(Expired)</span>' : '';
 
                 // Get stored token from localStorage for pending invitations
                 const storedToken = !inv.accepted_at ? localStorage.getItem(`invite_token_${inv.id}`) : null;
@@ -103,19 +175,27 @@ function accountSharing() {
             <div style="padding: var(--space-3); background: var(--color-surface); border: 1px solid var(--color-border); border-radius: var(--radius-md); margin-bottom: var(--space-2);">
                 <div style="display: flex; justify-content: space-between; align-items: center;">
                     <div>
-                        <strong>Invitation ${accepted}</strong>
+                        <strong>${inv.email ? escapeInviteHTML(inv.email) : 'Invitation'} ${accepted}${expired}</strong>
                         <br><small style="color: var(--color-text-muted);">
                             Created ${new Date(inv.created_at).toLocaleDateString()} -
                             Expires ${new Date(inv.expires_at).toLocaleDateString()}
                         </small>
                     </div>
                     ${!inv.accepted_at ? `
-                    <button type="button"
-                            class="btn-sm outline secondary"
-                            style="margin: 0; color: var(--danger-primary); border-color: var(--danger-border);"
-                            onclick="confirmRevokeInvitation(${inv.id})">
-                        Revoke
-                    </button>
+                    <div style="display: flex; gap: var(--space-2);">
+                        <button type="button"
+                                class="btn-sm outline"
+                                style="margin: 0;"
+                                data-action="resend" data-index="${index}">
+                            ${inv.email ? 'Resend' : 'New Link'}
+                        </button>
+                        <button type="button"
+                                class="btn-sm outline secondary"
+                                style="margin: 0; color: var(--danger-primary); border-color: var(--danger-border);"
+                                data-action="revoke" data-index="${index}">
+                            Revoke
+                        </button>
+                    </div>
                     ` : ''}
                 </div>
                 ${inviteLink ? `
@@ -125,11 +205,11 @@ function accountSharing() {
                         <input type="text"
                                value="${inviteLink}"
                                readonly
-                               onclick="this.select()"
+                               data-action="select"
                                style="flex: 1; font-family: monospace; font-size: 0.85rem; margin: 0;">
                         <button type="button"
                                 class="btn-sm outline"
-                                onclick="copyToClipboard('${inviteLink}', this)"
+                                data-action="copy" data-link="${inviteLink}"
                                 style="margin: 0; white-space: nowrap;">
                             Copy Link
                         </button>
@@ -140,7 +220,28 @@ function accountSharing() {
             `;
             }).join('');
 
-            document.getElementById('invitations-list').innerHTML = html;
+            const list = document.getElementById('invitations-list');
+            list.innerHTML = html;
+
+            list.querySelectorAll('[data-action]').forEach(el => {
+                const inv = this.invitations[el.dataset.index];
+                el.addEventListener('click', () => {
+                    switch (el.dataset.action) {
+                    case 'resend':
+                        resendInvitation(inv.id);
+                        break;
+                    case 'revoke':
+                        confirmRevokeInvitation(inv.id);
+                        break;
+                    case 'select':
+                        el.select();
+                        break;
+                    case 'copy':
+                        copyToClipboard(el.dataset.link, el);
+                        break;
+                    }
+                });
+            });
         },
 
         async sendInvitation() {
@@ -150,20 +251,21 @@ function accountSharing() {
             this.invitationLink = '';
 
             try {
-                const response = await fetch('/api/invitations', {
+                const response = await fetch('/api/v1/account/invitations', {
                     method: 'POST',
                     headers: {
                         'Content-Type': 'application/json',
                         'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content
                     },
                     body: JSON.stringify({
-                        email: 'invitation@localhost',
-                        role: 'member'
+                        email: this.inviteEmail.trim(),
+                        role: 'member',
+                        expires_in_days: Number(this.inviteExpiresInDays)
                     })
                 });
 
                 if (!response.ok) {
-                    const error = await response.text();
+                    const error = await responseErrorText(response);
                     throw new Error(error || 'Failed to create invitation');
                 }
 
@@ -174,7 +276,10 @@ function accountSharing() {
                 // Store token in localStorage so we can display it later
                 localStorage.setItem(`invite_token_${data.id}`, data.token);
 
-                this.inviteFeedback = '<div class="alert-success">Invitation link created successfully!</div>';
+                this.inviteFeedback = data.emailed
+                    ? '<div class="alert-success">Invitation emailed! You can also share the link below.</div>'
+                    : '<div class="alert-success">Invitation link created successfully!</div>';
+                this.inviteEmail = '';
 
                 // Reload invitations list
                 await this.loadInvitations();
@@ -216,14 +321,17 @@ async function confirmRemoveMember() {
     const userId = window.pendingRemoveUserId;
 
     try {
-        const response = await fetch(`/api/account/members/${userId}`, {
+        const response = await fetch(`/api/v1/account/members/${userId}`, {
             method: 'DELETE',
             headers: {
                 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content
             }
         });
 
-        if (!response.ok) throw new Error('Failed to remove member');
+        if (!response.ok) {
+            const errorText = await responseErrorText(response);
+            throw new Error(errorText || 'Failed to remove member');
+        }
 
         modal.close();
         window.location.reload();
@@ -233,6 +341,47 @@ async function confirmRemoveMember() {
     }
 }
 
+function transferOwnership(userId, username) {
+    const modal = document.getElementById('transfer-ownership-modal');
+    document.getElementById('transfer-ownership-name').textContent = username;
+    document.getElementById('transfer-ownership-password').value = '';
+    document.getElementById('transfer-ownership-error').textContent = '';
+    modal.showModal();
+
+    // Store for confirmation
+    window.pendingTransferUserId = userId;
+}
+
+async function confirmTransferOwnership() {
+    const modal = document.getElementById('transfer-ownership-modal');
+    const userId = window.pendingTransferUserId;
+    const errorEl = document.getElementById('transfer-ownership-error');
+
+    try {
+        const response = await fetch(`/api/v1/account/members/${userId}/transfer-ownership`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content
+            },
+            body: JSON.stringify({
+                password: document.getElementById('transfer-ownership-password').value
+            })
+        });
+
+        if (!response.ok) {
+            const errorText = await responseErrorText(response);
+            throw new Error(errorText || 'Failed to transfer ownership');
+        }
+
+        modal.close();
+        window.location.reload();
+    } catch (error) {
+        // Leave the dialog open so a mistyped password can be corrected
+        errorEl.textContent = error.message;
+    }
+}
+
 function confirmRevokeInvitation(invitationId) {
     const modal = document.getElementById('revoke-invitation-modal');
     modal.showModal();
@@ -246,7 +395,7 @@ async function revokeInvitation() {
     const invitationId = window.pendingRevokeInvitationId;
 
     try {
-        const response = await fetch(`/api/invitations/${invitationId}`, {
+        const response = await fetch(`/api/v1/account/invitations/${invitationId}`, {
             method: 'DELETE',
             headers: {
                 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content
@@ -254,7 +403,7 @@ async function revokeInvitation() {
         });
 
         if (!response.ok) {
-            const errorText = await response.text();
+            const errorText = await responseErrorText(response);
             throw new Error(errorText || 'Failed to revoke invitation');
         }
 
@@ -269,6 +418,35 @@ async function revokeInvitation() {
     }
 }
 
+async function resendInvitation(invitationId) {
+    try {
+        const response = await fetch(`/api/v1/account/invitations/${invitationId}/resend`, {
+            method: 'POST',
+            headers: {
+                'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content
+            }
+        });
+
+        if (!response.ok) {
+            const errorText = await responseErrorText(response);
+            throw new Error(errorText || 'Failed to resend invitation');
+        }
+
+        // The old link no longer works; keep the new one to show
+        const data = await response.json();
+        localStorage.setItem(`invite_token_${invitationId}`, data.token);
+        window.location.reload();
+    } catch (error) {
+        alert('Error resending invitation: ' + error.message);
+    }
+}
+
+function escapeInviteHTML(text) {
+    const div = document.createElement('div');
+    div.textContent = text;
+    return div.innerHTML;
+}
+
 function copyToClipboard(text, button) {
     navigator.clipboard.writeText(text).then(() => {
         const originalText = button.textContent;
@@ -2,19 +2,40 @@
 function adminSettings() {
     return {
         smtp: { host: '', port: 587, username: '', password: '', from_name: 'P-TRACK', from_email: '', enabled: false },
-        site: { site_url: '', site_title: 'P-TRACK', site_description: '' },
+        site: { site_url: '', site_title: 'P-TRACK', site_description: '', registration_mode: 'open' },
         stats: {},
         users: [],
         accounts: [],
+        suspensionReason: '',
         myAccountId: 0,
         backups: [],
-        autoBackup: { enabled: false, frequency: 'daily', keep_count: 7, last_run: '' },
+        autoBackup: { enabled: false, frequency: 'daily', keep_count: 7, last_run: '', destination: '', destination_config: {} },
+        remoteBackups: [],
+        remoteBackupsError: '',
+        restorePassphrase: '',
         backupFeedback: '',
         creatingBackup: false,
         feedback: '',
         siteFeedback: '',
         usersFeedback: '',
         accountsFeedback: '',
+        announcements: [],
+        newAnnouncement: { message: '', severity: 'info', expires_at: '' },
+        maintenanceMode: { enabled: false, message: '' },
+        announcementsFeedback: '',
+        flags: [],
+        diagnostics: null,
+        secretsStatus: null,
+        secretsFeedback: '',
+        rotatingSecrets: false,
+        flagsFeedback: '',
+        quotas: { max_members: null, attachment_storage_mb: null, backup_mb: null },
+        accountUsage: [],
+        quotasFeedback: '',
+        accountBackups: [],
+        accountBackupPassphrase: '',
+        accountBackupCompress: true,
+        accountBackupOwnerEmail: '',
         saving: false,
         savingSite: false,
         testing: false,
@@ -44,7 +65,7 @@ function adminSettings() {
         async loadSettings() {
             try {
                 const csrf = document.querySelector('meta[name=csrf-token]').content;
-                const r = await fetch('/api/admin/settings', { headers: { 'X-CSRF-Token': csrf } });
+                const r = await fetch('/api/v1/admin/settings', { headers: { 'X-CSRF-Token': csrf } });
                 if (r.ok) {
                     const d = await r.json();
                     this.smtp = { ...this.smtp, ...d.smtp };
@@ -52,8 +73,14 @@ function adminSettings() {
                     this.stats = d.site_stats || {};
                     this.myAccountId = d.account_id || 0;
                 }
+                await this.loadAnnouncements();
                 await this.loadUsers();
                 await this.loadAccounts();
+                await this.loadQuotas();
+                await this.loadFlags();
+                await this.loadDiagnostics();
+                await this.loadSecretsStatus();
+                await this.loadAccountBackups();
                 await this.loadBackups();
                 await this.loadAutoBackupSettings();
             } catch (e) {
@@ -61,9 +88,162 @@ function adminSettings() {
             }
         },
 
+        async loadAnnouncements() {
+            try {
+                const csrf = document.querySelector('meta[name=csrf-token]').content;
+                const r = await fetch('/api/v1/admin/announcements', { headers: { 'X-CSRF-Token': csrf } });
+                if (r.ok) this.announcements = await r.json();
+                const m = await fetch('/api/v1/admin/maintenance', { headers: { 'X-CSRF-Token': csrf } });
+                if (m.ok) this.maintenanceMode = await m.json();
+            } catch (e) {
+                console.error('Failed to load announcements:', e);
+            }
+        },
+
+        async createAnnouncement() {
+            const body = { message: this.newAnnouncement.message, severity: this.newAnnouncement.severity };
+            if (this.newAnnouncement.expires_at) body.expires_at = new Date(this.newAnnouncement.expires_at).toISOString();
+            try {
+                const r = await fetch('/api/v1/admin/announcements', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                    body: JSON.stringify(body)
+                });
+                if (r.ok) {
+                    this.newAnnouncement = { message: '', severity: 'info', expires_at: '' };
+                    await this.loadAnnouncements();
+                    this.announcementsFeedback = '<div class="alert-success">Announcement posted!</div>';
+                } else {
+                    this.announcementsFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                }
+            } catch (e) {
+                this.announcementsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+            }
+            setTimeout(() => this.announcementsFeedback = '', 5000);
+        },
+
+        async deleteAnnouncement(a) {
+            try {
+                const r = await fetch('/api/v1/admin/announcements/' + a.id, {
+                    method: 'DELETE',
+                    headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content }
+                });
+                if (r.ok) {
+                    await this.loadAnnouncements();
+                } else {
+                    this.announcementsFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                    setTimeout(() => this.announcementsFeedback = '', 5000);
+                }
+            } catch (e) {
+                this.announcementsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+            }
+        },
+
+        async saveMaintenanceMode() {
+            try {
+                const r = await fetch('/api/v1/admin/maintenance', {
+                    method: 'PUT',
+                    headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                    body: JSON.stringify(this.maintenanceMode)
+                });
+                this.announcementsFeedback = r.ok
+                    ? '<div class="alert-success">Maintenance mode ' + (this.maintenanceMode.enabled ? 'on' : 'off') + '.</div>'
+                    : '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+            } catch (e) {
+                this.announcementsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+            }
+            setTimeout(() => this.announcementsFeedback = '', 5000);
+        },
+
+        async loadFlags() {
+            try {
+                const r = await fetch('/api/v1/admin/flags', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
+                if (r.ok) this.flags = (await r.json()).map(f => ({ ...f, newAccountId: 0 }));
+            } catch (e) {
+                console.error('Failed to load feature flags:', e);
+            }
+        },
+
+        accountName(id) {
+            const a = this.accounts.find(a => a.id === id);
+            return a ? (a.name || a.owner_name || 'Account ' + id) : 'Account ' + id;
+        },
+
+        // value is 'true' or 'false' to set the flag, '' to clear the setting;
+        // accountId 0 is the site-wide setting
+        async setFlag(f, accountId, value) {
+            const body = { key: f.key, account_id: accountId };
+            if (value !== '') body.enabled = value === 'true';
+            try {
+                const r = await fetch('/api/v1/admin/flags', {
+                    method: value === '' ? 'DELETE' : 'PUT',
+                    headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                    body: JSON.stringify(body)
+                });
+                if (!r.ok && r.status !== 404) {
+                    this.flagsFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                    setTimeout(() => this.flagsFeedback = '', 5000);
+                }
+            } catch (e) {
+                this.flagsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+            }
+            await this.loadFlags();
+        },
+
+        async loadDiagnostics() {
+            try {
+                const r = await fetch('/api/v1/admin/diagnostics', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
+                if (r.ok) this.diagnostics = await r.json();
+            } catch (e) {
+                console.error('Failed to load diagnostics:', e);
+            }
+        },
+
+        async loadSecretsStatus() {
+            try {
+                const r = await fetch('/api/v1/admin/secrets', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
+                if (r.ok) this.secretsStatus = await r.json();
+            } catch (e) {
+                console.error('Failed to load secrets status:', e);
+            }
+        },
+
+        async rotateSecrets() {
+            this.rotatingSecrets = true;
+            this.secretsFeedback = '';
+            try {
+                const r = await fetch('/api/v1/admin/secrets/rotate', {
+                    method: 'POST',
+                    headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content }
+                });
+                if (r.ok) {
+                    const d = await r.json();
+                    this.secretsStatus = d.status;
+                    this.secretsFeedback = '<div class="alert-success">Re-encrypted ' + d.reencrypted + ' secret(s).</div>';
+                } else {
+                    this.secretsFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                }
+            } catch (e) {
+                this.secretsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+            } finally {
+                this.rotatingSecrets = false;
+                setTimeout(() => this.secretsFeedback = '', 5000);
+            }
+        },
+
+        formatBytes(bytes) {
+            const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+            let i = 0;
+            while (bytes >= 1024 && i < units.length - 1) {
+                bytes /= 1024;
+                i++;
+            }
+            return (i === 0 ? bytes : bytes.toFixed(1)) + ' ' + units[i];
+        },
+
         async loadUsers() {
             try {
-                const r = await fetch('/api/admin/users', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
+                const r = await fetch('/api/v1/admin/users', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
                 if (r.ok) this.users = await r.json();
             } catch (e) {
                 console.error('Failed to load users:', e);
@@ -72,7 +252,7 @@ function adminSettings() {
 
         async loadAccounts() {
             try {
-                const r = await fetch('/api/admin/accounts', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
+                const r = await fetch('/api/v1/admin/accounts', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
                 if (r.ok) this.accounts = await r.json();
             } catch (e) {
                 console.error('Failed to load accounts:', e);
@@ -83,12 +263,12 @@ function adminSettings() {
             this.saving = true;
             this.feedback = '';
             try {
-                const r = await fetch('/api/admin/smtp', {
+                const r = await fetch('/api/v1/admin/smtp', {
                     method: 'PUT',
                     headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
                     body: JSON.stringify(this.smtp)
                 });
-                this.feedback = r.ok ? '<div class="alert-success">SMTP settings saved!</div>' : '<div class="alert-danger">' + await r.text() + '</div>';
+                this.feedback = r.ok ? '<div class="alert-success">SMTP settings saved!</div>' : '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
                 if (r.ok) this.smtp.password = '';
             } catch (e) {
                 this.feedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
@@ -102,12 +282,12 @@ function adminSettings() {
             this.savingSite = true;
             this.siteFeedback = '';
             try {
-                const r = await fetch('/api/admin/site', {
+                const r = await fetch('/api/v1/admin/site', {
                     method: 'PUT',
                     headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
                     body: JSON.stringify(this.site)
                 });
-                this.siteFeedback = r.ok ? '<div class="alert-success">Site settings saved!</div>' : '<div class="alert-danger">' + await r.text() + '</div>';
+                this.siteFeedback = r.ok ? '<div class="alert-success">Site settings saved!</div>' : '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
             } catch (e) {
                 this.siteFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
             } finally {
@@ -129,7 +309,7 @@ function adminSettings() {
             this.testing = true;
             this.feedback = '';
             try {
-                const r = await fetch('/api/admin/smtp/test', {
+                const r = await fetch('/api/v1/admin/smtp/test', {
                     method: 'POST',
                     headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
                     body: JSON.stringify({ email })
@@ -153,7 +333,7 @@ function adminSettings() {
                 actionTitle + ' User',
                 async () => {
                     try {
-                        const r = await fetch('/api/admin/users/status', {
+                        const r = await fetch('/api/v1/admin/users/status', {
                             method: 'PUT',
                             headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
                             body: JSON.stringify({ user_id: user.id, active: !user.is_active })
@@ -162,8 +342,33 @@ function adminSettings() {
                             user.is_active = !user.is_active;
                             this.usersFeedback = '<div class="alert-success">User status updated!</div>';
                         } else {
-                            this.usersFeedback = '<div class="alert-danger">' + await r.text() + '</div>';
+                            this.usersFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                        }
+                    } catch (e) {
+                        this.usersFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+                    }
+                    setTimeout(() => this.usersFeedback = '', 5000);
+                }
+            );
+        },
+
+        impersonateUser(user) {
+            this.showConfirmModal(
+                'Impersonate User',
+                'Sign in as ' + user.username + ' for up to an hour? Everything you do will be recorded under both of you, and they will see the session among their devices.',
+                'Impersonate',
+                async () => {
+                    try {
+                        const r = await fetch('/api/v1/admin/users/impersonate', {
+                            method: 'POST',
+                            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                            body: JSON.stringify({ user_id: user.id })
+                        });
+                        if (r.ok) {
+                            window.location.href = '/dashboard';
+                            return;
                         }
+                        this.usersFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
                     } catch (e) {
                         this.usersFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
                     }
@@ -179,7 +384,7 @@ function adminSettings() {
                 'Delete User',
                 async () => {
                     try {
-                        const r = await fetch('/api/admin/users', {
+                        const r = await fetch('/api/v1/admin/users', {
                             method: 'DELETE',
                             headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
                             body: JSON.stringify({ user_id: user.id })
@@ -189,7 +394,7 @@ function adminSettings() {
                             await this.loadAccounts();
                             this.usersFeedback = '<div class="alert-success">User deleted!</div>';
                         } else {
-                            this.usersFeedback = '<div class="alert-danger">' + await r.text() + '</div>';
+                            this.usersFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
                         }
                     } catch (e) {
                         this.usersFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
@@ -206,7 +411,7 @@ function adminSettings() {
                 'Delete Account',
                 async () => {
                     try {
-                        const r = await fetch('/api/admin/accounts', {
+                        const r = await fetch('/api/v1/admin/accounts', {
                             method: 'DELETE',
                             headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
                             body: JSON.stringify({ account_id: account.id })
@@ -216,7 +421,37 @@ function adminSettings() {
                             await this.loadUsers();
                             this.accountsFeedback = '<div class="alert-success">Account deleted!</div>';
                         } else {
-                            this.accountsFeedback = '<div class="alert-danger">' + await r.text() + '</div>';
+                            this.accountsFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                        }
+                    } catch (e) {
+                        this.accountsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+                    }
+                    setTimeout(() => this.accountsFeedback = '', 5000);
+                }
+            );
+        },
+
+        suspendAccount(account, suspension) {
+            const locked = suspension === 'locked';
+            this.showConfirmModal(
+                locked ? 'Lock Account' : 'Make Account Read-Only',
+                locked
+                    ? 'Lock account ' + account.name + '? Its members will be signed out and can\'t sign in until you reactivate it.'
+                    : 'Make account ' + account.name + ' read-only? Its members can sign in and look at their data but not change it.',
+                locked ? 'Lock Account' : 'Make Read-Only',
+                async () => {
+                    try {
+                        const r = await fetch('/api/v1/admin/accounts/suspend', {
+                            method: 'POST',
+                            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                            body: JSON.stringify({ account_id: account.id, suspension, reason: this.suspensionReason.trim() })
+                        });
+                        if (r.ok) {
+                            this.suspensionReason = '';
+                            await this.loadAccounts();
+                            this.accountsFeedback = '<div class="alert-success">Account suspended; its members have been told.</div>';
+                        } else {
+                            this.accountsFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
                         }
                     } catch (e) {
                         this.accountsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
@@ -226,9 +461,182 @@ function adminSettings() {
             );
         },
 
+        async reactivateAccount(account) {
+            try {
+                const r = await fetch('/api/v1/admin/accounts/reactivate', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                    body: JSON.stringify({ account_id: account.id })
+                });
+                if (r.ok) {
+                    await this.loadAccounts();
+                    this.accountsFeedback = '<div class="alert-success">Account reactivated; its members have been told.</div>';
+                } else {
+                    this.accountsFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                }
+            } catch (e) {
+                this.accountsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+            }
+            setTimeout(() => this.accountsFeedback = '', 5000);
+        },
+
+        async loadQuotas() {
+            try {
+                const csrf = document.querySelector('meta[name=csrf-token]').content;
+                const r = await fetch('/api/v1/admin/quotas', { headers: { 'X-CSRF-Token': csrf } });
+                if (r.ok) this.quotas = await r.json();
+                const u = await fetch('/api/v1/admin/accounts/usage', { headers: { 'X-CSRF-Token': csrf } });
+                if (u.ok) this.accountUsage = await u.json();
+            } catch (e) {
+                console.error('Failed to load quotas:', e);
+            }
+        },
+
+        // saveQuotas sets the limits site-wide (accountId 0) or for one
+        // account; a blank limit isn't set there
+        async saveQuotas(accountId, quota) {
+            const limit = v => (v === '' || v === null || v === undefined) ? null : Number(v);
+            try {
+                const r = await fetch('/api/v1/admin/quotas', {
+                    method: 'PUT',
+                    headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                    body: JSON.stringify({
+                        account_id: accountId,
+                        max_members: limit(quota.max_members),
+                        attachment_storage_mb: limit(quota.attachment_storage_mb),
+                        backup_mb: limit(quota.backup_mb)
+                    })
+                });
+                if (r.ok) {
+                    await this.loadQuotas();
+                    this.quotasFeedback = '<div class="alert-success">Quotas saved!</div>';
+                } else {
+                    this.quotasFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                }
+            } catch (e) {
+                this.quotasFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+            }
+            setTimeout(() => this.quotasFeedback = '', 3000);
+        },
+
+        // limitText shows a quota limit, 0 being unlimited
+        limitText(limit, unit) {
+            return limit ? limit + (unit ? ' ' + unit : '') : 'unlimited';
+        },
+
+        async loadAccountBackups() {
+            try {
+                const r = await fetch('/api/v1/admin/accounts/backups', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
+                if (r.ok) this.accountBackups = await r.json();
+            } catch (e) {
+                console.error('Failed to load account backups:', e);
+            }
+        },
+
+        async backupAccount(account) {
+            this.accountsFeedback = '<div class="alert-info">Backing up ' + account.name + '...</div>';
+            try {
+                const r = await fetch('/api/v1/admin/accounts/backups', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                    body: JSON.stringify({ account_id: account.id, compress: this.accountBackupCompress, passphrase: this.accountBackupPassphrase })
+                });
+                if (r.ok) {
+                    const d = await r.json();
+                    this.accountsFeedback = '<div class="alert-success">Backup created: ' + d.backup.filename + '</div>';
+                    await this.loadAccountBackups();
+                } else {
+                    this.accountsFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                }
+            } catch (e) {
+                this.accountsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+            }
+            setTimeout(() => this.accountsFeedback = '', 5000);
+        },
+
+        async uploadAccountBackup(event) {
+            const file = event.target.files[0];
+            if (!file) return;
+            const fd = new FormData();
+            fd.append('backup', file);
+            try {
+                const r = await fetch('/api/v1/admin/accounts/backups/upload', {
+                    method: 'POST',
+                    headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                    body: fd
+                });
+                if (r.ok) {
+                    this.accountsFeedback = '<div class="alert-success">Account backup uploaded. Restore it below.</div>';
+                    await this.loadAccountBackups();
+                } else {
+                    this.accountsFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                }
+            } catch (e) {
+                this.accountsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+            }
+            event.target.value = '';
+            setTimeout(() => this.accountsFeedback = '', 5000);
+        },
+
+        restoreAccountBackup(backup) {
+            this.showConfirmModal(
+                'Restore Account Backup',
+                'Restore ' + backup.filename + ' into a new account? Existing accounts are not changed.',
+                'Restore',
+                async () => {
+                    try {
+                        const r = await fetch('/api/v1/admin/accounts/backups/restore', {
+                            method: 'POST',
+                            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                            body: JSON.stringify({ filename: backup.filename, passphrase: this.accountBackupPassphrase, owner_email: this.accountBackupOwnerEmail })
+                        });
+                        if (r.ok) {
+                            const d = await r.json();
+                            let msg = 'Restored into account ' + d.account_id + '.';
+                            if (d.invite_token) {
+                                msg += ' Send the new owner this link to register: ' + window.location.origin + '/register?invite=' + d.invite_token;
+                            }
+                            this.accountsFeedback = '<div class="alert-success">' + msg + '</div>';
+                            await this.loadAccounts();
+                            return;
+                        }
+                        this.accountsFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                    } catch (e) {
+                        this.accountsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+                    }
+                    setTimeout(() => this.accountsFeedback = '', 5000);
+                }
+            );
+        },
+
+        deleteAccountBackup(backup) {
+            this.showConfirmModal(
+                'Delete Account Backup',
+                'Delete ' + backup.filename + '? This cannot be undone.',
+                'Delete',
+                async () => {
+                    try {
+                        const r = await fetch('/api/v1/admin/accounts/backups', {
+                            method: 'DELETE',
+                            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                            body: JSON.stringify({ filename: backup.filename })
+                        });
+                        if (r.ok) {
+                            await this.loadAccountBackups();
+                        } else {
+                            this.accountsFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                            setTimeout(() => this.accountsFeedback = '', 5000);
+                        }
+                    } catch (e) {
+                        this.accountsFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+                    }
+                }
+            );
+        },
+
         async loadBackups() {
             try {
-                const r = await fetch('/api/admin/backups', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
+                const r = await fetch('/api/v1/admin/backups', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
                 if (r.ok) this.backups = await r.json();
             } catch (e) {
                 console.error('Failed to load backups:', e);
@@ -237,26 +645,85 @@ function adminSettings() {
 
         async loadAutoBackupSettings() {
             try {
-                const r = await fetch('/api/admin/backups/auto', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
-                if (r.ok) this.autoBackup = await r.json();
+                const r = await fetch('/api/v1/admin/backups/auto', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
+                if (r.ok) {
+                    const d = await r.json();
+                    d.destination_config = d.destination_config || {};
+                    this.autoBackup = d;
+                    if (d.destination) await this.loadRemoteBackups();
+                }
             } catch (e) {
                 console.error('Failed to load auto-backup settings:', e);
             }
         },
 
+        async loadRemoteBackups() {
+            this.remoteBackupsError = '';
+            try {
+                const r = await fetch('/api/v1/admin/backups/remote', { headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
+                if (r.ok) {
+                    this.remoteBackups = await r.json();
+                } else {
+                    this.remoteBackups = [];
+                    this.remoteBackupsError = await responseErrorText(r);
+                }
+            } catch (e) {
+                this.remoteBackupsError = e.message;
+            }
+        },
+
+        restoreRemoteBackup(backup) {
+            this.showConfirmModal(
+                'Restore Remote Backup',
+                'Download ' + backup.filename + ' and restore from it? All current data will be replaced.',
+                'Restore Backup',
+                async () => {
+                    this.backupFeedback = '<div class="alert-info">Downloading backup...</div>';
+                    try {
+                        const r = await fetch('/api/v1/admin/backups/remote/fetch', {
+                            method: 'POST',
+                            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                            body: JSON.stringify({ filename: backup.filename, passphrase: this.restorePassphrase })
+                        });
+                        if (!r.ok) {
+                            this.backupFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
+                            return;
+                        }
+                        const d = await r.json();
+                        await this.loadBackups();
+                        const restore = await fetch('/api/v1/admin/backups/restore', {
+                            method: 'POST',
+                            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
+                            body: JSON.stringify({ filename: d.filename, confirm: true, passphrase: this.restorePassphrase })
+                        });
+                        if (restore.ok) {
+                            this.backupFeedback = '<div class="alert-success">Backup restored. Refreshing...</div>';
+                            setTimeout(() => location.reload(), 1500);
+                        } else {
+                            this.backupFeedback = '<div class="alert-danger">' + await responseErrorText(restore) + '</div>';
+                        }
+                    } catch (e) {
+                        this.backupFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
+                    }
+                }
+            );
+        },
+
         async saveAutoBackupSettings() {
             try {
-                const r = await fetch('/api/admin/backups/auto', {
+                const r = await fetch('/api/v1/admin/backups/auto', {
                     method: 'PUT',
                     headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
                     body: JSON.stringify(this.autoBackup)
                 });
                 if (r.ok) {
                     const d = await r.json();
+                    d.settings.destination_config = d.settings.destination_config || {};
                     this.autoBackup = d.settings;
                     this.backupFeedback = '<div class="alert-success">Auto-backup settings saved!</div>';
+                    if (this.autoBackup.destination) await this.loadRemoteBackups();
                 } else {
-                    this.backupFeedback = '<div class="alert-danger">' + await r.text() + '</div>';
+                    this.backupFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
                 }
             } catch (e) {
                 this.backupFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
@@ -268,13 +735,13 @@ function adminSettings() {
             this.creatingBackup = true;
             this.backupFeedback = '';
             try {
-                const r = await fetch('/api/admin/backups', { method: 'POST', headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
+                const r = await fetch('/api/v1/admin/backups', { method: 'POST', headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content } });
                 if (r.ok) {
                     const d = await r.json();
                     this.backupFeedback = '<div class="alert-success">' + d.message + '</div>';
                     await this.loadBackups();
                 } else {
-                    this.backupFeedback = '<div class="alert-danger">' + await r.text() + '</div>';
+                    this.backupFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
                 }
             } catch (e) {
                 this.backupFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
@@ -291,7 +758,7 @@ function adminSettings() {
                 'Delete Backup',
                 async () => {
                     try {
-                        const r = await fetch('/api/admin/backups', {
+                        const r = await fetch('/api/v1/admin/backups', {
                             method: 'DELETE',
                             headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
                             body: JSON.stringify({ filename: backup.filename })
@@ -300,7 +767,7 @@ function adminSettings() {
                             await this.loadBackups();
                             this.backupFeedback = '<div class="alert-success">Backup deleted!</div>';
                         } else {
-                            this.backupFeedback = '<div class="alert-danger">' + await r.text() + '</div>';
+                            this.backupFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
                         }
                     } catch (e) {
                         this.backupFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
@@ -315,9 +782,10 @@ function adminSettings() {
             if (!file) return;
             const fd = new FormData();
             fd.append('backup', file);
+            if (this.restorePassphrase) fd.append('passphrase', this.restorePassphrase);
             this.backupFeedback = '<div class="alert-info">Uploading...</div>';
             try {
-                const r = await fetch('/api/admin/backups/upload', {
+                const r = await fetch('/api/v1/admin/backups/upload', {
                     method: 'POST',
                     headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
                     body: fd
@@ -327,7 +795,7 @@ function adminSettings() {
                     this.backupFeedback = '<div class="alert-success">' + d.message + '</div>';
                     await this.loadBackups();
                 } else {
-                    this.backupFeedback = '<div class="alert-danger">' + await r.text() + '</div>';
+                    this.backupFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
                 }
             } catch (e) {
                 this.backupFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
@@ -339,25 +807,24 @@ function adminSettings() {
         restoreBackup(backup) {
             this.showConfirmModal(
                 'Restore Backup',
-                'Restore from backup ' + backup.filename + '? The server will restart.',
+                'Restore from backup ' + backup.filename + '? All current data will be replaced.',
                 'Restore Backup',
                 async () => {
                     this.backupFeedback = '<div class="alert-info">Restoring backup...</div>';
                     try {
-                        const r = await fetch('/api/admin/backups/restore', {
+                        const r = await fetch('/api/v1/admin/backups/restore', {
                             method: 'POST',
                             headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content },
-                            body: JSON.stringify({ filename: backup.filename, confirm: true })
+                            body: JSON.stringify({ filename: backup.filename, confirm: true, passphrase: this.restorePassphrase })
                         });
                         if (r.ok) {
-                            this.backupFeedback = '<div class="alert-success">Restore initiated. Refreshing...</div>';
-                            setTimeout(() => location.reload(), 5000);
+                            this.backupFeedback = '<div class="alert-success">Backup restored. Refreshing...</div>';
+                            setTimeout(() => location.reload(), 1500);
                         } else {
-                            this.backupFeedback = '<div class="alert-danger">' + await r.text() + '</div>';
+                            this.backupFeedback = '<div class="alert-danger">' + await responseErrorText(r) + '</div>';
                         }
                     } catch (e) {
-                        this.backupFeedback = '<div class="alert-info">Server restarting. Please refresh...</div>';
-                        setTimeout(() => location.reload(), 5000);
+                        this.backupFeedback = '<div class="alert-danger">Error: ' + e.message + '</div>';
                     }
                 }
             );
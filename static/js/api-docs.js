@@ -0,0 +1,19 @@
+// Swagger UI for /api-docs. Requests go out with the session cookie; the
+// CSRF token from the page is added to anything that changes data.
+document.addEventListener('DOMContentLoaded', () => {
+    const csrfToken = document.querySelector('meta[name="csrf-token"]')?.content;
+
+    SwaggerUIBundle({
+        url: '/api/v1/openapi.json',
+        dom_id: '#swagger-ui',
+        deepLinking: true,
+        docExpansion: 'none',
+        defaultModelsExpandDepth: 0,
+        requestInterceptor: (req) => {
+            if (csrfToken && !['GET', 'HEAD', 'OPTIONS'].includes((req.method || 'GET').toUpperCase())) {
+                req.headers['X-CSRF-Token'] = csrfToken;
+            }
+            return req;
+        }
+    });
+});
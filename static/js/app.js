@@ -16,7 +16,7 @@ document.addEventListener('alpine:init', () => {
         },
 
         fetchCount() {
-            fetch('/api/notifications/count')
+            fetch('/api/v1/notifications/count')
                 .then(response => response.json())
                 .then(data => {
                     this.count = data.count || 0;
@@ -62,6 +62,114 @@ document.addEventListener('htmx:configRequest', (event) => {
     }
 });
 
+// CSRF tokens expire, so the server sends a new one in X-CSRF-Token when the
+// one used is getting old. Scripts read the token from the meta tag for each
+// request, so keeping that current keeps every form on the page working.
+function updateCSRFToken(token) {
+    const meta = document.querySelector('meta[name="csrf-token"]');
+    if (token && meta) {
+        meta.content = token;
+    }
+}
+
+document.addEventListener('htmx:afterRequest', (event) => {
+    updateCSRFToken(event.detail.xhr?.getResponseHeader('X-CSRF-Token'));
+});
+
+// A page left open past the token's expiry fetches a new token and retries
+// once, rather than failing the user's save
+const nativeFetch = window.fetch.bind(window);
+window.fetch = async (input, init) => {
+    const response = await nativeFetch(input, init);
+    updateCSRFToken(response.headers.get('X-CSRF-Token'));
+    if (response.status === 401) {
+        const body = await response.clone().json().catch(() => null);
+        if (body?.error?.code === 'token_expired' && await refreshSession()) {
+            return nativeFetch(input, init);
+        }
+        sessionEnded(body?.error?.code);
+        return response;
+    }
+    if (response.status !== 403 || !init?.headers) {
+        return response;
+    }
+    const headers = new Headers(init.headers);
+    if (!headers.has('X-CSRF-Token')) {
+        return response;
+    }
+    const body = await response.clone().json().catch(() => null);
+    if (body?.error?.code !== 'csrf_invalid') {
+        return response;
+    }
+    const fresh = await nativeFetch('/api/v1/csrf-token').then(r => r.json()).catch(() => null);
+    if (!fresh?.csrf_token) {
+        return response;
+    }
+    updateCSRFToken(fresh.csrf_token);
+    headers.set('X-CSRF-Token', fresh.csrf_token);
+    return nativeFetch(input, { ...init, headers });
+};
+
+// Tokens only last as long as the session's idle timeout, so one that has
+// expired is refreshed and the request tried again. The session itself
+// ending, after going unused or reaching its lifetime, means signing in.
+let sessionRefresh = null;
+function refreshSession() {
+    if (!sessionRefresh) {
+        const csrfToken = document.querySelector('meta[name="csrf-token"]')?.content;
+        sessionRefresh = nativeFetch('/api/v1/auth/refresh', {
+            method: 'POST',
+            headers: csrfToken ? { 'X-CSRF-Token': csrfToken } : {},
+        })
+            .then(r => r.ok)
+            .catch(() => false)
+            .finally(() => { sessionRefresh = null; });
+    }
+    return sessionRefresh;
+}
+
+const sessionEndCodes = ['token_expired', 'session_idle', 'session_expired'];
+function sessionEnded(code) {
+    if (!sessionEndCodes.includes(code) || window.location.pathname === '/login') {
+        return;
+    }
+    showToast(code === 'session_idle'
+        ? 'You were signed out after a period of inactivity.'
+        : 'Session expired. Please log in again.', 'error');
+    setTimeout(() => window.location.href = '/login', 2000);
+}
+
+document.addEventListener('htmx:responseError', (event) => {
+    if (event.detail.xhr?.status !== 403) {
+        return;
+    }
+    let body = null;
+    try {
+        body = JSON.parse(event.detail.xhr.responseText);
+    } catch (e) {
+        return;
+    }
+    if (body?.error?.code === 'csrf_invalid') {
+        // The next attempt uses the new token
+        fetch('/api/v1/csrf-token')
+            .then(r => r.json())
+            .then(data => updateCSRFToken(data.csrf_token))
+            .catch(() => {});
+    }
+});
+
+// Fragments that move on once read, such as the registration success
+// message, name the page in data-redirect (inline scripts can't run under
+// the Content Security Policy)
+document.addEventListener('htmx:afterSwap', (event) => {
+    const el = event.detail.target.querySelector('[data-redirect]');
+    if (el) {
+        setTimeout(() => {
+            window.location.href = el.dataset.redirect;
+        }, Number(el.dataset.redirectAfter) || 0);
+    }
+});
+
 // Global loading states for HTMX
 document.addEventListener('htmx:beforeRequest', () => {
     Alpine.store('app').startLoading();
@@ -98,6 +206,7 @@ document.addEventListener('DOMContentLoaded', () => {
     checkOfflineStatus();
     initPWA();
     initMobileMenu();
+    initLiveUpdates();
 });
 
 // Mobile Menu Logic
@@ -368,12 +477,116 @@ function initPWA() {
     };
 }
 
-// Offline data sync
-async function syncOfflineData() {
-    if ('indexedDB' in window) {
-        // TODO: Implement IndexedDB sync logic
-        console.log('Syncing offline data...');
+// Live updates from the account's other sessions. Each server-sent event is
+// re-dispatched on document.body as live:<type>, and as live-update for htmx
+// triggers (hx-trigger="live-update from:body"). EventSource reconnects on
+// its own and resumes from the last event it saw.
+const LIVE_EVENT_TYPES = ['injection.created', 'inventory.adjusted', 'medication.logged', 'resync'];
+
+function initLiveUpdates() {
+    if (!document.body.hasAttribute('data-live-updates') || !('EventSource' in window)) {
+        return;
+    }
+
+    const source = new EventSource('/api/v1/events');
+    LIVE_EVENT_TYPES.forEach((type) => {
+        source.addEventListener(type, (e) => {
+            let detail = {};
+            try {
+                detail = JSON.parse(e.data);
+            } catch (err) {
+                console.warn('Ignoring malformed live event', type, err);
+            }
+            document.body.dispatchEvent(new CustomEvent('live:' + type, { detail }));
+            document.body.dispatchEvent(new CustomEvent('live-update', { detail: { type, ...detail } }));
+        });
+    });
+    window.addEventListener('pagehide', () => source.close());
+
+    if (hasFeature('push_notifications')) {
+        initLiveNotifications();
+    }
+}
+
+// Whether a feature flag is on for the account, as listed by the layout
+function hasFeature(key) {
+    return (document.body.dataset.features || '').split(' ').includes(key);
+}
+
+// Browser notifications for other members' activity, behind the
+// push_notifications feature flag. Only shown while the page is hidden;
+// the visible page already updates itself.
+const LIVE_NOTIFICATIONS = {
+    'injection.created': (d) => ({
+        title: 'Injection logged',
+        body: d.side ? 'An injection was logged on the ' + d.side + ' side.' : 'An injection was logged.',
+        url: '/injections'
+    }),
+    'medication.logged': (d) => ({
+        title: d.taken === false ? 'Medication skipped' : 'Medication taken',
+        body: d.medication_name || 'A medication was logged.',
+        url: '/medications'
+    })
+};
+
+function requestLiveNotifications() {
+    if (!('Notification' in window)) {
+        return Promise.resolve('unsupported');
     }
+    return Notification.requestPermission();
+}
+
+function initLiveNotifications() {
+    if (!('Notification' in window)) {
+        return;
+    }
+    const userID = document.body.dataset.userId;
+    Object.entries(LIVE_NOTIFICATIONS).forEach(([type, build]) => {
+        document.body.addEventListener('live:' + type, (e) => {
+            const detail = e.detail || {};
+            if (!document.hidden || Notification.permission !== 'granted' || String(detail.logged_by) === userID) {
+                return;
+            }
+            const n = build(detail);
+            const options = { body: n.body, icon: '/static/icons/icon-192.png', tag: type, data: { url: n.url } };
+            if (navigator.serviceWorker && navigator.serviceWorker.controller) {
+                navigator.serviceWorker.ready.then((reg) => reg.showNotification(n.title, options));
+            } else {
+                new Notification(n.title, options);
+            }
+        });
+    });
+}
+
+// Offline data sync. The service worker queues changes made offline; ask it
+// to send them now, for browsers without background sync.
+function syncOfflineData() {
+    if (navigator.serviceWorker && navigator.serviceWorker.controller) {
+        navigator.serviceWorker.controller.postMessage({ type: 'SYNC_NOW' });
+    }
+}
+
+// Report what happened to queued changes once the service worker has sent them
+if ('serviceWorker' in navigator) {
+    navigator.serviceWorker.addEventListener('message', (event) => {
+        if (!event.data || event.data.type !== 'SYNC_COMPLETE') {
+            return;
+        }
+        const results = event.data.results || [];
+        const saved = results.filter((r) => r.status === 'applied' || r.status === 'duplicate').length;
+        const conflicts = results.filter((r) => r.status === 'conflict').length;
+        const failed = results.filter((r) => r.status === 'failed').length;
+        if (saved > 0) {
+            showToast(`Saved ${saved} change${saved === 1 ? '' : 's'} made offline`, 'success');
+        }
+        if (conflicts > 0) {
+            showToast(`${conflicts} offline change${conflicts === 1 ? ' was' : 's were'} skipped because the record was edited elsewhere`, 'warning', 6000);
+        }
+        if (failed > 0) {
+            const message = apiErrorMessage(JSON.stringify(results.find((r) => r.status === 'failed').body));
+            showToast(`${failed} offline change${failed === 1 ? '' : 's'} couldn't be saved: ${message}`, 'error', 6000);
+        }
+    });
 }
 
 // Keyboard shortcuts
@@ -424,20 +637,20 @@ function showModal(title, content) {
         <div class="modal">
             <div class="modal-header">
                 <h3 class="modal-title">${title}</h3>
-                <button class="modal-close" onclick="this.closest('.modal-overlay').remove()">✕</button>
+                <button class="modal-close">✕</button>
             </div>
             <div class="modal-body">
                 ${content}
             </div>
             <div class="modal-footer">
-                <button class="btn btn-primary" onclick="this.closest('.modal-overlay').remove()">Close</button>
+                <button class="btn btn-primary modal-dismiss">Close</button>
             </div>
         </div>
     `;
 
     document.body.appendChild(modal);
     modal.addEventListener('click', (e) => {
-        if (e.target === modal) {
+        if (e.target === modal || e.target.closest('.modal-close, .modal-dismiss')) {
             modal.remove();
         }
     });
@@ -618,7 +831,7 @@ document.addEventListener('click', (e) => {
 // Service Worker Registration
 if ('serviceWorker' in navigator) {
     window.addEventListener('load', () => {
-        navigator.serviceWorker.register('/static/sw.js')
+        navigator.serviceWorker.register('/service-worker.js', { scope: '/' })
             .then((registration) => {
                 console.log('Service Worker registered:', registration.scope);
 
@@ -661,32 +874,109 @@ function showUpdateNotification() {
                 <span>⟳</span>
                 <span>A new version is available!</span>
             </div>
-            <button onclick="location.reload()" class="btn-sm btn-primary" style="margin-left: var(--space-2);">
+            <button class="btn-sm btn-primary" style="margin-left: var(--space-2);">
                 Update Now
             </button>
         </div>
     `;
+    notification.querySelector('button').addEventListener('click', () => location.reload());
     document.body.appendChild(notification);
 }
 
+// API errors are JSON: {"error": {"code", "message", "fields"}}. Pull out the
+// message, falling back to the raw body for anything else.
+function apiErrorMessage(text) {
+    try {
+        const body = JSON.parse(text);
+        if (body && body.error && body.error.message) {
+            return body.error.message;
+        }
+    } catch (e) {
+        // Not JSON
+    }
+    return text;
+}
+
+// Resolves to the error message of a failed fetch response
+function responseErrorText(response) {
+    return response.text().then(apiErrorMessage);
+}
+
+// Logging an injection answers 409 possible_duplicate when one on the same
+// course and side was just logged, e.g. by a partner. Resolves to true if
+// the user still wants it logged (resend with allow_duplicate), false if
+// not, or the error message for any other failure.
+function confirmDuplicateInjection(response) {
+    return response.text().then(text => {
+        let body = null;
+        try {
+            body = JSON.parse(text);
+        } catch (e) {
+            // Not JSON
+        }
+        if (response.status === 409 && body?.error?.code === 'possible_duplicate') {
+            return confirm(body.error.message + '\n\nLog it anyway?');
+        }
+        return apiErrorMessage(text);
+    });
+}
+
+// The page's render time, or failing that when this script loaded
+const pageLoadedAt = new Date(document.querySelector('meta[name="rendered-at"]')?.content || Date.now());
+
+// Headers for an edit (PUT) so it's rejected with 412 if someone else changed
+// the record since it was loaded, rather than silently overwriting their
+// change. Pass the record's updated_at when it was fetched separately;
+// otherwise the page's render time is used.
+function concurrencyHeaders(updatedAt) {
+    const since = updatedAt ? new Date(updatedAt) : pageLoadedAt;
+    if (Number.isNaN(since.getTime())) {
+        return {};
+    }
+    return { 'If-Unmodified-Since': since.toUTCString() };
+}
+
 // Export functions and utilities globally
 window.Utils = Utils;
+window.apiErrorMessage = apiErrorMessage;
+window.responseErrorText = responseErrorText;
+window.confirmDuplicateInjection = confirmDuplicateInjection;
+window.concurrencyHeaders = concurrencyHeaders;
 window.showToast = showToast;
 window.hapticFeedback = hapticFeedback;
 window.showModal = showModal;
 
 // Enhanced HTMX error handling
 document.body.addEventListener('htmx:responseError', (event) => {
-    const status = event.detail.xhr.status;
+    const xhr = event.detail.xhr;
+    const status = xhr.status;
+    const isJSON = (xhr.getResponseHeader('Content-Type') || '').includes('application/json');
+    const message = isJSON ? apiErrorMessage(xhr.responseText) : '';
     if (status === 401) {
-        showToast('Session expired. Please log in again.', 'error');
-        setTimeout(() => window.location.href = '/login', 2000);
+        let code = '';
+        try {
+            code = JSON.parse(xhr.responseText)?.error?.code || '';
+        } catch (e) {
+            // Not JSON
+        }
+        const config = event.detail.requestConfig;
+        if (code === 'token_expired' && config) {
+            refreshSession().then(ok => {
+                if (ok) {
+                    htmx.ajax(config.verb.toUpperCase(), config.path, { source: config.elt, values: config.parameters });
+                } else {
+                    sessionEnded(code);
+                }
+            });
+            return;
+        }
+        sessionEnded(sessionEndCodes.includes(code) ? code : 'session_expired');
     } else if (status === 403) {
-        showToast('Access denied. Invalid CSRF token.', 'error');
+        showToast(message || 'Access denied.', 'error');
     } else if (status === 429) {
         showToast('Too many requests. Please slow down.', 'error');
     } else {
-        showToast('An error occurred. Please try again.', 'error');
+        showToast(message || 'An error occurred. Please try again.', 'error');
     }
 });
 
@@ -701,3 +991,22 @@ if ('performance' in window) {
         }, 0);
     });
 }
+
+// The banner shown while the admin is impersonating a user signs them back
+// in as themselves, or out if their own session has ended
+document.addEventListener('click', async (event) => {
+    const button = event.target.closest('[data-action="stop-impersonating"]');
+    if (!button) return;
+    button.disabled = true;
+    try {
+        const r = await fetch('/api/v1/auth/stop-impersonating', {
+            method: 'POST',
+            headers: { 'X-CSRF-Token': document.querySelector('meta[name=csrf-token]').content }
+        });
+        const d = await r.json().catch(() => ({}));
+        window.location.href = r.ok && d.restored ? '/settings' : '/login';
+    } catch (e) {
+        button.disabled = false;
+        showToast('Error: ' + e.message, 'error');
+    }
+});
@@ -0,0 +1,94 @@
+// Calendar Feed Subscriptions Alpine.js Component
+function calendarFeeds() {
+    return {
+        tokens: [],
+        loading: true,
+        creating: false,
+        name: '',
+        feedback: '',
+        feedURL: '',
+        copied: false,
+
+        init() {
+            this.loadTokens();
+        },
+
+        csrfToken() {
+            return document.querySelector('meta[name=csrf-token]').content;
+        },
+
+        async loadTokens() {
+            try {
+                const response = await fetch('/api/v1/calendar/tokens', {
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) throw new Error('Failed to load calendar feeds');
+                this.tokens = await response.json();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            } finally {
+                this.loading = false;
+            }
+        },
+
+        async createToken() {
+            this.creating = true;
+            this.feedback = '';
+            this.feedURL = '';
+
+            try {
+                const response = await fetch('/api/v1/calendar/tokens', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                        'X-CSRF-Token': this.csrfToken()
+                    },
+                    body: JSON.stringify({ name: this.name })
+                });
+                if (!response.ok) {
+                    const error = await responseErrorText(response);
+                    throw new Error(error || 'Failed to create calendar feed');
+                }
+
+                const data = await response.json();
+                this.feedURL = data.url;
+                this.name = '';
+                await this.loadTokens();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            } finally {
+                this.creating = false;
+            }
+        },
+
+        async revokeToken(token) {
+            if (!confirm(`Revoke "${token.name}"? Calendars subscribed to it will stop updating.`)) {
+                return;
+            }
+
+            try {
+                const response = await fetch(`/api/v1/calendar/tokens/${token.id}`, {
+                    method: 'DELETE',
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) throw new Error('Failed to revoke calendar feed');
+                this.feedURL = '';
+                await this.loadTokens();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
+        },
+
+        copyFeedURL() {
+            navigator.clipboard.writeText(this.feedURL);
+            this.copied = true;
+            setTimeout(() => {
+                this.copied = false;
+            }, 2000);
+        },
+
+        formatDate(value) {
+            return value ? new Date(value).toLocaleDateString() : 'Never';
+        }
+    };
+}
@@ -0,0 +1,103 @@
+// Calendar Month View Alpine.js Component
+// Renders /api/calendar, which returns one entry per day of the month
+function calendarView() {
+    return {
+        month: '',
+        data: null,
+        loading: true,
+        error: '',
+        showLegend: true,
+        dayHeaders: ['Sun', 'Mon', 'Tue', 'Wed', 'Thu', 'Fri', 'Sat'],
+
+        init() {
+            const params = new URLSearchParams(window.location.search);
+            this.month = params.get('month') || this.formatMonth(new Date());
+            this.load();
+        },
+
+        formatMonth(date) {
+            return date.getFullYear() + '-' + String(date.getMonth() + 1).padStart(2, '0');
+        },
+
+        shiftMonth(delta) {
+            const [year, month] = this.month.split('-').map(Number);
+            this.month = this.formatMonth(new Date(year, month - 1 + delta, 1));
+            history.replaceState(null, '', '?month=' + this.month);
+            this.load();
+        },
+
+        async load() {
+            this.loading = true;
+            this.error = '';
+            try {
+                const response = await fetch('/api/v1/calendar?month=' + encodeURIComponent(this.month));
+                if (!response.ok) throw new Error(await responseErrorText(response) || 'Failed to load calendar');
+                this.data = await response.json();
+            } catch (error) {
+                this.error = error.message;
+                this.data = null;
+            } finally {
+                this.loading = false;
+            }
+        },
+
+        get title() {
+            const [year, month] = this.month.split('-').map(Number);
+            return new Date(year, month - 1, 1).toLocaleDateString('en-US', { month: 'long', year: 'numeric' });
+        },
+
+        // Days of the month padded with blanks so the first lands on its weekday
+        get cells() {
+            if (!this.data) return [];
+            const [year, month] = this.month.split('-').map(Number);
+            const blanks = new Date(year, month - 1, 1).getDay();
+            const cells = [];
+            for (let i = 0; i < blanks; i++) cells.push({ key: 'blank-' + i, blank: true });
+            this.data.days.forEach(day => cells.push({ key: day.date, blank: false, day: day }));
+            return cells;
+        },
+
+        dayNumber(day) {
+            return Number(day.date.slice(8));
+        },
+
+        isToday(day) {
+            const now = new Date();
+            return day.date === this.formatMonth(now) + '-' + String(now.getDate()).padStart(2, '0');
+        },
+
+        injectionTitle(inj) {
+            const time = new Date(inj.timestamp).toLocaleTimeString([], { hour: 'numeric', minute: '2-digit' });
+            const pain = inj.pain_level != null ? ', pain ' + inj.pain_level + '/10' : '';
+            return inj.side.charAt(0).toUpperCase() + inj.side.slice(1) + ' injection at ' + time + pain;
+        },
+
+        dueTitle(dose) {
+            const time = new Date(dose.due).toLocaleTimeString([], { hour: 'numeric', minute: '2-digit' });
+            return 'Injection due at ' + time + ' (' + dose.dose_ml + ' mL)';
+        },
+
+        symptomTitle(day) {
+            const pain = day.symptoms.max_pain != null ? ', worst pain ' + day.symptoms.max_pain + '/10' : '';
+            return day.symptoms.count + ' symptom log' + (day.symptoms.count === 1 ? '' : 's') + pain;
+        },
+
+        medicationTitle(day) {
+            return day.medications.doses
+                .map(dose => dose.medication + (dose.taken ? ' taken' : ' missed'))
+                .join(', ');
+        },
+
+        appointmentTitle(appt) {
+            const time = new Date(appt.starts_at).toLocaleTimeString([], { hour: 'numeric', minute: '2-digit' });
+            return time + ' ' + appt.title + (appt.location ? ' (' + appt.location + ')' : '');
+        },
+
+        // The export's end date is exclusive, so run to the 1st of next month
+        get exportURL() {
+            const [year, month] = this.month.split('-').map(Number);
+            const next = new Date(year, month, 1);
+            return '/api/v1/export/csv?start_date=' + this.month + '-01&end_date=' + this.formatMonth(next) + '-01';
+        }
+    };
+}
@@ -30,13 +30,16 @@ document.addEventListener('DOMContentLoaded', function () {
                 name: formData.get('name'),
                 start_date: formData.get('start_date'),
                 expected_end_date: formData.get('expected_end_date') || null,
-                notes: formData.get('notes') || null
+                notes: formData.get('notes') || null,
+                dose_ml: parseFloat(formData.get('dose_ml')) || null,
+                concentration_mg_per_ml: parseFloat(formData.get('concentration_mg_per_ml')) || null,
+                compound_id: parseInt(formData.get('compound_id'), 10) || null
             };
             const btn = e.target.querySelector('button[type=submit]');
             btn.disabled = true;
             btn.setAttribute('aria-busy', 'true');
 
-            fetch('/api/courses', {
+            fetch('/api/v1/courses', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
@@ -48,7 +51,7 @@ document.addEventListener('DOMContentLoaded', function () {
                     if (response.ok) {
                         window.location.reload();
                     } else {
-                        return response.text().then(text => {
+                        return responseErrorText(response).then(text => {
                             alert('Error: ' + text);
                             btn.disabled = false;
                             btn.removeAttribute('aria-busy');
@@ -103,11 +106,16 @@ document.addEventListener('DOMContentLoaded', function () {
                 actual_end_date: formData.get('actual_end_date') || null,
                 notes: formData.get('notes') || null
             };
+            // Only the active course form has dose fields; an empty value clears them
+            if (formData.has('dose_ml')) {
+                data.dose_ml = parseFloat(formData.get('dose_ml')) || 0;
+                data.concentration_mg_per_ml = parseFloat(formData.get('concentration_mg_per_ml')) || 0;
+            }
             const btn = e.target.querySelector('button[type=submit]');
             btn.disabled = true;
             btn.setAttribute('aria-busy', 'true');
 
-            fetch('/api/courses/' + courseId, {
+            fetch('/api/v1/courses/' + courseId, {
                 method: 'PUT',
                 headers: {
                     'Content-Type': 'application/json',
@@ -119,7 +127,7 @@ document.addEventListener('DOMContentLoaded', function () {
                     if (response.ok) {
                         window.location.reload();
                     } else {
-                        return response.text().then(text => {
+                        return responseErrorText(response).then(text => {
                             alert('Error: ' + text);
                             btn.disabled = false;
                             btn.removeAttribute('aria-busy');
@@ -139,7 +147,7 @@ document.addEventListener('DOMContentLoaded', function () {
         btn.addEventListener('click', function () {
             const courseId = this.getAttribute('data-course-id');
             if (confirm('Close this course?')) {
-                fetch('/api/courses/' + courseId + '/close', {
+                fetch('/api/v1/courses/' + courseId + '/close', {
                     method: 'POST',
                     headers: { 'X-CSRF-Token': getCSRFToken() }
                 })
@@ -147,7 +155,7 @@ document.addEventListener('DOMContentLoaded', function () {
                         if (response.ok) {
                             window.location.reload();
                         } else {
-                            response.text().then(text => console.error('Error:', text));
+                            responseErrorText(response).then(text => console.error('Error:', text));
                             window.location.reload();
                         }
                     });
@@ -159,7 +167,7 @@ document.addEventListener('DOMContentLoaded', function () {
     document.querySelectorAll('[data-action="activate-course"]').forEach(btn => {
         btn.addEventListener('click', function () {
             const courseId = this.getAttribute('data-course-id');
-            fetch('/api/courses/' + courseId + '/activate', {
+            fetch('/api/v1/courses/' + courseId + '/activate', {
                 method: 'POST',
                 headers: { 'X-CSRF-Token': getCSRFToken() }
             }).then(response => {
@@ -173,13 +181,161 @@ document.addEventListener('DOMContentLoaded', function () {
         });
     });
 
+    // Injection schedule modal buttons
+    document.querySelectorAll('[data-action="edit-course-schedule"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const modal = document.getElementById('schedule-course-' + this.getAttribute('data-course-id'));
+            if (modal) modal.showModal();
+        });
+    });
+
+    document.querySelectorAll('[data-action="close-course-schedule"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const modal = document.getElementById('schedule-course-' + this.getAttribute('data-course-id'));
+            if (modal) modal.close();
+        });
+    });
+
+    // Saving sends either an interval in hours or the checked weekdays
+    document.querySelectorAll('[data-form="course-schedule"]').forEach(form => {
+        form.addEventListener('submit', function (e) {
+            e.preventDefault();
+            const courseId = this.getAttribute('data-course-id');
+            const body = {};
+            if (form.querySelector('[name="kind"]:checked').value === 'weekdays') {
+                body.weekdays = Array.from(form.querySelectorAll('[name="weekday"]:checked')).map(box => box.value);
+            } else {
+                const every = parseInt(form.querySelector('[name="every"]').value, 10) || 0;
+                body.interval_hours = form.querySelector('[name="unit"]').value === 'days' ? every * 24 : every;
+            }
+            const timeOfDay = form.querySelector('[name="time_of_day"]').value;
+            if (timeOfDay) body.time_of_day = timeOfDay;
+            const btn = e.target.querySelector('button[type=submit]');
+            btn.disabled = true;
+            btn.setAttribute('aria-busy', 'true');
+
+            fetch('/api/v1/courses/' + courseId + '/schedule', {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCSRFToken()
+                },
+                body: JSON.stringify(body)
+            })
+                .then(response => {
+                    if (response.ok) {
+                        window.location.reload();
+                    } else {
+                        return responseErrorText(response).then(text => {
+                            alert('Error: ' + text);
+                            btn.disabled = false;
+                            btn.removeAttribute('aria-busy');
+                        });
+                    }
+                })
+                .catch(error => {
+                    alert('Error: ' + error.message);
+                    btn.disabled = false;
+                    btn.removeAttribute('aria-busy');
+                });
+        });
+    });
+
+    document.querySelectorAll('[data-action="reset-course-schedule"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const courseId = this.getAttribute('data-course-id');
+            if (!confirm('Go back to the account\'s reminder frequency and time for this course?')) return;
+            fetch('/api/v1/courses/' + courseId + '/schedule', {
+                method: 'DELETE',
+                headers: { 'X-CSRF-Token': getCSRFToken() }
+            })
+                .then(response => {
+                    if (response.ok) {
+                        window.location.reload();
+                    } else {
+                        return responseErrorText(response).then(text => alert('Error: ' + text));
+                    }
+                })
+                .catch(error => alert('Error: ' + error.message));
+        });
+    });
+
+    // Taper schedule modal buttons
+    document.querySelectorAll('[data-action="edit-taper"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const modal = document.getElementById('taper-course-' + this.getAttribute('data-course-id'));
+            if (modal) modal.showModal();
+        });
+    });
+
+    document.querySelectorAll('[data-action="close-taper"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const modal = document.getElementById('taper-course-' + this.getAttribute('data-course-id'));
+            if (modal) modal.close();
+        });
+    });
+
+    // Taper step rows are added from a template and removed in place
+    document.querySelectorAll('[data-form="taper-course"]').forEach(form => {
+        const stepsContainer = form.querySelector('[data-taper-steps]');
+
+        form.querySelector('[data-action="add-taper-step"]').addEventListener('click', function () {
+            const template = document.getElementById('taper-step-template');
+            stepsContainer.appendChild(template.content.cloneNode(true));
+        });
+
+        stepsContainer.addEventListener('click', function (e) {
+            if (e.target.matches('[data-action="remove-taper-step"]')) {
+                e.target.closest('[data-taper-step]').remove();
+            }
+        });
+
+        form.addEventListener('submit', function (e) {
+            e.preventDefault();
+            const courseId = this.getAttribute('data-course-id');
+            const steps = Array.from(stepsContainer.querySelectorAll('[data-taper-step]')).map(row => ({
+                start_date: row.querySelector('[name="start_date"]').value,
+                end_date: row.querySelector('[name="end_date"]').value || undefined,
+                dose_ml: parseFloat(row.querySelector('[name="dose_ml"]').value)
+            }));
+            const btn = e.target.querySelector('button[type=submit]');
+            btn.disabled = true;
+            btn.setAttribute('aria-busy', 'true');
+
+            fetch('/api/v1/courses/' + courseId + '/taper', {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCSRFToken()
+                },
+                body: JSON.stringify({ steps: steps })
+            })
+                .then(response => {
+                    if (response.ok) {
+                        window.location.reload();
+                    } else {
+                        return responseErrorText(response).then(text => {
+                            alert('Error: ' + text);
+                            btn.disabled = false;
+                            btn.removeAttribute('aria-busy');
+                        });
+                    }
+                })
+                .catch(error => {
+                    alert('Error: ' + error.message);
+                    btn.disabled = false;
+                    btn.removeAttribute('aria-busy');
+                });
+        });
+    });
+
     // Delete course buttons
     document.querySelectorAll('[data-action="delete-course"]').forEach(btn => {
         btn.addEventListener('click', function () {
             const courseId = this.getAttribute('data-course-id');
             const courseName = this.getAttribute('data-course-name');
-            if (confirm('Delete ' + courseName + '? This will delete all associated data.')) {
-                fetch('/api/courses/' + courseId, {
+            if (confirm('Delete ' + courseName + '? Its injections and symptom logs go with it. You can restore them all from the trash in Settings for 30 days.')) {
+                fetch('/api/v1/courses/' + courseId, {
                     method: 'DELETE',
                     headers: { 'X-CSRF-Token': getCSRFToken() }
                 }).then(response => {
@@ -89,7 +89,8 @@ document.addEventListener('DOMContentLoaded', function () {
             e.preventDefault();
 
             const formData = new FormData(e.target);
-            const courseId = e.target.getAttribute('data-course-id');
+            // The course picker only shows up when several courses are active
+            const courseId = formData.get('course_id') || e.target.getAttribute('data-course-id');
             const btn = e.target.querySelector('button[type=submit]');
 
             // Get date/time
@@ -108,26 +109,33 @@ document.addEventListener('DOMContentLoaded', function () {
             btn.disabled = true;
             btn.setAttribute('aria-busy', 'true');
 
-            fetch('/api/injections', {
+            const save = allowDuplicate => fetch('/api/v1/injections', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
                     'X-CSRF-Token': getCSRFToken()
                 },
-                body: JSON.stringify(data)
+                body: JSON.stringify(Object.assign({ allow_duplicate: allowDuplicate }, data))
             })
                 .then(response => {
-                    btn.disabled = false;
-                    btn.removeAttribute('aria-busy');
                     if (response.ok) {
                         window.location.reload();
-                    } else {
-                        return response.text().then(text => {
-                            console.error('Error:', text);
-                            alert('Error: ' + text);
-                        });
+                        return;
                     }
-                })
+                    return confirmDuplicateInjection(response).then(result => {
+                        if (result === true) {
+                            return save(true);
+                        }
+                        btn.disabled = false;
+                        btn.removeAttribute('aria-busy');
+                        if (result !== false) {
+                            console.error('Error:', result);
+                            alert('Error: ' + result);
+                        }
+                    });
+                });
+
+            save(false)
                 .catch(error => {
                     btn.disabled = false;
                     btn.removeAttribute('aria-busy');
@@ -195,11 +203,12 @@ document.addEventListener('DOMContentLoaded', function () {
             btn.disabled = true;
             btn.setAttribute('aria-busy', 'true');
 
-            fetch('/api/injections/' + id, {
+            fetch('/api/v1/injections/' + id, {
                 method: 'PUT',
                 headers: {
                     'Content-Type': 'application/json',
-                    'X-CSRF-Token': getCSRFToken()
+                    'X-CSRF-Token': getCSRFToken(),
+                    ...concurrencyHeaders()
                 },
                 body: JSON.stringify(data)
             })
@@ -207,7 +216,7 @@ document.addEventListener('DOMContentLoaded', function () {
                     if (response.ok) {
                         window.location.reload();
                     } else {
-                        return response.text().then(text => {
+                        return responseErrorText(response).then(text => {
                             btn.disabled = false;
                             btn.removeAttribute('aria-busy');
                             console.error('Error:', text);
@@ -224,6 +233,172 @@ document.addEventListener('DOMContentLoaded', function () {
         });
     });
 
+    // --- Missed Doses ---
+
+    let currentMissedId = null;
+    const annotateMissedModal = document.getElementById('annotate-missed');
+    const annotateMissedForm = document.getElementById('annotate-missed-form');
+
+    document.querySelectorAll('[data-action="annotate-missed"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            currentMissedId = this.getAttribute('data-id');
+            document.getElementById('annotate-missed-due').textContent = this.getAttribute('data-due');
+            const reason = this.getAttribute('data-reason');
+            if (reason) document.getElementById('missed-reason').value = reason;
+            document.getElementById('missed-note').value = this.getAttribute('data-note') || '';
+            if (annotateMissedModal) annotateMissedModal.showModal();
+        });
+    });
+
+    document.querySelectorAll('[data-action="close-annotate-missed"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            if (annotateMissedModal) annotateMissedModal.close();
+            currentMissedId = null;
+        });
+    });
+
+    if (annotateMissedForm) {
+        annotateMissedForm.addEventListener('submit', function (e) {
+            e.preventDefault();
+            if (!currentMissedId) return;
+            const btn = this.querySelector('button[type=submit]');
+            btn.disabled = true;
+            btn.setAttribute('aria-busy', 'true');
+
+            fetch('/api/v1/injections/missed/' + currentMissedId, {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCSRFToken()
+                },
+                body: JSON.stringify({
+                    reason: document.getElementById('missed-reason').value,
+                    note: document.getElementById('missed-note').value.trim()
+                })
+            }).then(response => {
+                if (response.ok) {
+                    window.location.reload();
+                } else {
+                    return responseErrorText(response).then(text => {
+                        alert('Error: ' + text);
+                        btn.disabled = false;
+                        btn.removeAttribute('aria-busy');
+                    });
+                }
+            }).catch(error => {
+                alert('Error: ' + error.message);
+                btn.disabled = false;
+                btn.removeAttribute('aria-busy');
+            });
+        });
+    }
+
+    // Dismissing is for a dose that was given after all
+    document.querySelectorAll('[data-action="dismiss-missed"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            if (!confirm('Dismiss this missed dose? Do this if it was given after all.')) return;
+            fetch('/api/v1/injections/missed/' + this.getAttribute('data-id'), {
+                method: 'DELETE',
+                headers: { 'X-CSRF-Token': getCSRFToken() }
+            }).then(response => {
+                if (response.ok) {
+                    window.location.reload();
+                } else {
+                    return responseErrorText(response).then(text => alert('Error: ' + text));
+                }
+            }).catch(error => alert('Error: ' + error.message));
+        });
+    });
+
+    // --- Travel Mode ---
+
+    const startTripModal = document.getElementById('start-trip');
+    const startTripForm = document.getElementById('start-trip-form');
+
+    document.querySelectorAll('[data-action="start-trip"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            if (startTripModal) startTripModal.showModal();
+        });
+    });
+
+    document.querySelectorAll('[data-action="close-start-trip"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            if (startTripModal) startTripModal.close();
+        });
+    });
+
+    // Saves a trip, reloading to show it
+    function saveTrip(url, method, body, btn) {
+        return fetch(url, {
+            method: method,
+            headers: {
+                'Content-Type': 'application/json',
+                'X-CSRF-Token': getCSRFToken()
+            },
+            body: JSON.stringify(body)
+        }).then(response => {
+            if (response.ok) {
+                window.location.reload();
+            } else {
+                return responseErrorText(response).then(text => {
+                    alert('Error: ' + text);
+                    if (btn) {
+                        btn.disabled = false;
+                        btn.removeAttribute('aria-busy');
+                    }
+                });
+            }
+        }).catch(error => {
+            alert('Error: ' + error.message);
+            if (btn) {
+                btn.disabled = false;
+                btn.removeAttribute('aria-busy');
+            }
+        });
+    }
+
+    if (startTripForm) {
+        startTripForm.addEventListener('submit', function (e) {
+            e.preventDefault();
+            const btn = this.querySelector('button[type=submit]');
+            btn.disabled = true;
+            btn.setAttribute('aria-busy', 'true');
+
+            // datetime-local values are in the browser's timezone
+            saveTrip('/api/v1/trips', 'POST', {
+                destination: document.getElementById('trip-destination').value.trim(),
+                timezone: document.getElementById('trip-timezone').value.trim(),
+                starts_at: new Date(document.getElementById('trip-starts').value).toISOString(),
+                ends_at: new Date(document.getElementById('trip-ends').value).toISOString(),
+                shift: document.getElementById('trip-shift').value
+            }, btn);
+        });
+    }
+
+    // Ending a trip early moves injections back to home time from now
+    document.querySelectorAll('[data-action="end-trip"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            if (!confirm('End this trip now?')) return;
+            saveTrip('/api/v1/trips/' + this.getAttribute('data-id'), 'PUT', { ends_at: new Date().toISOString() }, null);
+        });
+    });
+
+    document.querySelectorAll('[data-action="delete-trip"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            if (!confirm('Delete this trip? Injections go back to home time straight away.')) return;
+            fetch('/api/v1/trips/' + this.getAttribute('data-id'), {
+                method: 'DELETE',
+                headers: { 'X-CSRF-Token': getCSRFToken() }
+            }).then(response => {
+                if (response.ok) {
+                    window.location.reload();
+                } else {
+                    return responseErrorText(response).then(text => alert('Error: ' + text));
+                }
+            }).catch(error => alert('Error: ' + error.message));
+        });
+    });
+
     // --- Delete Injection ---
 
     let currentDeleteId = null;
@@ -264,17 +439,24 @@ document.addEventListener('DOMContentLoaded', function () {
             this.disabled = true;
             this.setAttribute('aria-busy', 'true');
 
-            fetch('/api/injections/' + currentDeleteId, {
+            const reasonInput = document.getElementById('void-reason');
+            const reason = reasonInput ? reasonInput.value.trim() : '';
+
+            fetch('/api/v1/injections/' + currentDeleteId, {
                 method: 'DELETE',
-                headers: { 'X-CSRF-Token': getCSRFToken() }
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCSRFToken()
+                },
+                body: JSON.stringify(reason ? { reason: reason } : {})
             }).then(response => {
                 if (response.ok) {
                     window.location.reload();
                 } else {
                     this.disabled = false;
                     this.removeAttribute('aria-busy');
-                    console.error('Error deleting injection');
-                    alert('Error deleting injection');
+                    console.error('Error voiding injection');
+                    alert('Error voiding injection');
                 }
             }).catch(error => {
                 this.disabled = false;
@@ -15,6 +15,8 @@ document.addEventListener('DOMContentLoaded', function () {
     if (addInventoryForm) {
         const itemTypeSelect = document.getElementById('add-item-type');
         const vialSizeContainer = document.getElementById('add-vial-size-container');
+        const unitContainer = document.getElementById('add-unit-container');
+        const unitSelect = document.getElementById('add-unit-select');
         const amountLabel = document.getElementById('add-amount-label');
         const amountInput = document.getElementById('add-amount-input');
         const lowStockInput = document.getElementById('add-low-stock-input');
@@ -22,26 +24,155 @@ document.addEventListener('DOMContentLoaded', function () {
         // Handle item type change
         if (itemTypeSelect) {
             itemTypeSelect.addEventListener('change', function () {
-                const isProgesterone = this.value === 'progesterone';
+                const option = this.options[this.selectedIndex];
+                const isLiquid = option.dataset.liquid === 'true';
+                const isBoxed = option.dataset.purchaseUnit === 'box';
 
-                // Toggle vial size field
+                // Toggle vial size field, starting from the item type's vial size
                 if (vialSizeContainer) {
-                    vialSizeContainer.style.display = isProgesterone ? 'block' : 'none';
+                    vialSizeContainer.style.display = isLiquid ? 'block' : 'none';
                     const input = vialSizeContainer.querySelector('input');
-                    if (input) input.required = isProgesterone;
+                    if (input) {
+                        input.required = isLiquid;
+                        if (isLiquid && option.dataset.perPurchase) input.value = option.dataset.perPurchase;
+                    }
+                }
+
+                // Boxed items can be entered by the box or singly
+                if (unitContainer) unitContainer.hidden = !isBoxed;
+                if (unitSelect) {
+                    unitSelect.options[0].textContent = isBoxed ? 'Boxes of ' + option.dataset.perPurchase : 'Boxes';
+                    unitSelect.value = isBoxed ? 'box' : 'count';
                 }
 
                 // Update amount label and step
-                if (amountLabel) amountLabel.textContent = isProgesterone ? 'Number of Vials' : 'Quantity';
-                if (amountInput) amountInput.step = isProgesterone ? '1' : '1';
+                if (amountLabel) amountLabel.textContent = isLiquid ? 'Number of Vials' : 'Quantity';
+                if (amountInput) amountInput.step = isLiquid ? '1' : '1';
 
                 // Update low stock step
-                if (lowStockInput) lowStockInput.step = isProgesterone ? '0.1' : '1';
+                if (lowStockInput) lowStockInput.step = isLiquid ? '0.1' : '1';
             });
             // Trigger change initially
             itemTypeSelect.dispatchEvent(new Event('change'));
         }
 
+        // Barcode scanning. Hardware scanners type the code and press Enter;
+        // phones use the camera where the browser has BarcodeDetector.
+        const barcodeInput = document.getElementById('add-barcode-input');
+        const barcodeCamera = document.getElementById('add-barcode-camera');
+        const barcodeVideo = document.getElementById('add-barcode-video');
+        const barcodeStatus = document.getElementById('add-barcode-status');
+        const rememberContainer = document.getElementById('add-barcode-remember-container');
+        const rememberCheckbox = document.getElementById('add-barcode-remember');
+        let scannedCode = null;
+
+        function setBarcodeStatus(text) {
+            if (barcodeStatus) barcodeStatus.textContent = text;
+        }
+
+        function resolveBarcode(payload) {
+            if (!payload.trim()) return;
+            setBarcodeStatus('Looking up code...');
+            fetch('/api/v1/inventory/barcode/resolve', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCSRFToken()
+                },
+                body: JSON.stringify({ payload: payload })
+            })
+                .then(response => {
+                    if (!response.ok) {
+                        return responseErrorText(response).then(text => { throw new Error(text); });
+                    }
+                    return response.json();
+                })
+                .then(result => {
+                    scannedCode = result.code;
+                    if (result.lot_number) addInventoryForm.elements.lot_number.value = result.lot_number;
+                    if (result.expiration_date) {
+                        addInventoryForm.elements.expiration_date.value = result.expiration_date.slice(0, 10);
+                    }
+
+                    const parts = [];
+                    if (result.product_name) parts.push(result.product_name);
+                    if (result.ndc) parts.push('NDC ' + result.ndc);
+                    if (result.matched && itemTypeSelect) {
+                        itemTypeSelect.value = result.item_type;
+                        itemTypeSelect.dispatchEvent(new Event('change'));
+                        parts.push('added as ' + result.item_name);
+                    } else {
+                        parts.push('not recognized, pick the item type');
+                    }
+                    if (result.is_expired) parts.push('this package has expired');
+                    setBarcodeStatus(parts.join(' - '));
+
+                    // Offer to remember codes picked by hand
+                    if (rememberContainer) rememberContainer.hidden = result.source === 'mapping';
+                    if (rememberCheckbox) rememberCheckbox.checked = !result.matched;
+                })
+                .catch(error => {
+                    scannedCode = null;
+                    setBarcodeStatus('Lookup failed: ' + error.message);
+                });
+        }
+
+        if (barcodeInput) {
+            barcodeInput.addEventListener('keydown', function (e) {
+                if (e.key === 'Enter') {
+                    e.preventDefault();
+                    resolveBarcode(this.value);
+                }
+            });
+            barcodeInput.addEventListener('change', function () {
+                resolveBarcode(this.value);
+            });
+        }
+
+        if (barcodeCamera && barcodeVideo && 'BarcodeDetector' in window && navigator.mediaDevices) {
+            barcodeCamera.hidden = false;
+            let stream = null;
+
+            function stopCamera() {
+                if (stream) stream.getTracks().forEach(track => track.stop());
+                stream = null;
+                barcodeVideo.hidden = true;
+                barcodeCamera.textContent = 'Camera';
+            }
+
+            barcodeCamera.addEventListener('click', function () {
+                if (stream) {
+                    stopCamera();
+                    return;
+                }
+                const detector = new BarcodeDetector({ formats: ['data_matrix', 'upc_a', 'code_128', 'ean_13'] });
+                navigator.mediaDevices.getUserMedia({ video: { facingMode: 'environment' } })
+                    .then(s => {
+                        stream = s;
+                        barcodeVideo.srcObject = s;
+                        barcodeVideo.hidden = false;
+                        barcodeCamera.textContent = 'Stop';
+                        return barcodeVideo.play();
+                    })
+                    .then(function scan() {
+                        if (!stream) return;
+                        return detector.detect(barcodeVideo).then(codes => {
+                            if (codes.length > 0) {
+                                barcodeInput.value = codes[0].rawValue;
+                                stopCamera();
+                                resolveBarcode(codes[0].rawValue);
+                            } else {
+                                setTimeout(scan, 250);
+                            }
+                        });
+                    })
+                    .catch(error => {
+                        stopCamera();
+                        setBarcodeStatus('Camera unavailable: ' + error.message);
+                    });
+            });
+        }
+
         // Handle submission
         addInventoryForm.addEventListener('submit', function (e) {
             e.preventDefault();
@@ -54,18 +185,20 @@ document.addEventListener('DOMContentLoaded', function () {
             const amount = parseFloat(formData.get('amount'));
             const vialSize = formData.get('vial_size') ? parseFloat(formData.get('vial_size')) : 0;
 
-            let changeAmount;
-            if (itemType === 'progesterone') {
-                changeAmount = vialSize * amount;
-            } else {
-                changeAmount = amount;
-            }
-
+            // Amounts are sent in the unit entered; the server converts
+            // vials and boxes to mL and count
             const data = {
-                change_amount: changeAmount,
+                change_amount: amount,
                 reason: 'restock', // Default for add form
                 notes: 'Added inventory'
             };
+            const selected = itemTypeSelect ? itemTypeSelect.selectedOptions[0] : null;
+            if (selected && selected.dataset.liquid === 'true') {
+                data.unit = 'vial';
+                data.units_per_purchase = vialSize;
+            } else if (selected && selected.dataset.purchaseUnit === 'box' && formData.get('unit') === 'box') {
+                data.unit = 'box';
+            }
 
             const lotNumber = formData.get('lot_number');
             const expirationDate = formData.get('expiration_date');
@@ -75,7 +208,7 @@ document.addEventListener('DOMContentLoaded', function () {
             if (expirationDate) data.expiration_date = expirationDate;
             if (lowStockThreshold) data.low_stock_threshold = parseFloat(lowStockThreshold);
 
-            fetch('/api/inventory/' + itemType + '/adjust', {
+            fetch('/api/v1/inventory/' + itemType + '/adjust', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
@@ -85,9 +218,22 @@ document.addEventListener('DOMContentLoaded', function () {
             })
                 .then(response => {
                     if (response.ok) {
-                        window.location.reload();
+                        if (!scannedCode || !rememberCheckbox || !rememberCheckbox.checked) {
+                            window.location.reload();
+                            return;
+                        }
+                        // The restock went through either way, so a failed
+                        // save only costs a manual pick next time
+                        return fetch('/api/v1/inventory/barcode/mappings', {
+                            method: 'POST',
+                            headers: {
+                                'Content-Type': 'application/json',
+                                'X-CSRF-Token': getCSRFToken()
+                            },
+                            body: JSON.stringify({ code: scannedCode, item_type: itemType })
+                        }).finally(() => window.location.reload());
                     } else {
-                        return response.text().then(text => {
+                        return responseErrorText(response).then(text => {
                             btn.disabled = false;
                             btn.textContent = 'Add to Inventory';
                             console.error('Error:', text);
@@ -161,7 +307,7 @@ document.addEventListener('DOMContentLoaded', function () {
                 data.low_stock_threshold = parseFloat(lowStockThreshold);
             }
 
-            fetch('/api/inventory/' + itemType + '/adjust', {
+            fetch('/api/v1/inventory/' + itemType + '/adjust', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
@@ -173,7 +319,7 @@ document.addEventListener('DOMContentLoaded', function () {
                     if (response.ok) {
                         window.location.reload();
                     } else {
-                        return response.text().then(text => {
+                        return responseErrorText(response).then(text => {
                             console.error('Error:', text);
                             btn.disabled = false;
                             btn.removeAttribute('aria-busy');
@@ -204,7 +350,7 @@ document.addEventListener('DOMContentLoaded', function () {
             const progPerInj = parseFloat(formData.get('progesterone_per_injection'));
             const autoDeduct = formData.get('auto_deduct') === 'on';
 
-            fetch('/api/inventory/settings', {
+            fetch('/api/v1/inventory/settings', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
@@ -220,7 +366,7 @@ document.addEventListener('DOMContentLoaded', function () {
                         feedback.innerHTML = '<div class="alert-success">Settings saved successfully!</div>';
                         setTimeout(() => { feedback.innerHTML = ''; }, 3000);
                     } else {
-                        return response.text().then(text => {
+                        return responseErrorText(response).then(text => {
                             feedback.innerHTML = '<div class="alert-danger">Error: ' + text + '</div>';
                         });
                     }
@@ -0,0 +1,87 @@
+// Sign-in Activity Alpine.js Component
+function loginSecurity() {
+    return {
+        sessions: [],
+        trustedDevices: [],
+        logins: [],
+        loading: true,
+        error: '',
+
+        init() {
+            this.load();
+        },
+
+        csrfToken() {
+            return document.querySelector('meta[name=csrf-token]').content;
+        },
+
+        async load() {
+            try {
+                const response = await fetch('/api/v1/me/security', {
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) throw new Error('Failed to load sign-in activity');
+                const data = await response.json();
+                this.sessions = data.sessions;
+                this.trustedDevices = data.trusted_devices || [];
+                this.logins = data.recent_logins;
+            } catch (error) {
+                this.error = error.message;
+            } finally {
+                this.loading = false;
+            }
+        },
+
+        async revokeSession(session) {
+            const message = session.current
+                ? 'Sign out this device? You will need to log in again.'
+                : `Sign out ${session.device}? It will need to log in again.`;
+            if (!confirm(message)) {
+                return;
+            }
+
+            try {
+                const response = await fetch(`/api/v1/me/security/sessions/${session.id}`, {
+                    method: 'DELETE',
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) throw new Error('Failed to sign out the device');
+                if (session.current) {
+                    window.location.href = '/login';
+                    return;
+                }
+                await this.load();
+            } catch (error) {
+                this.error = error.message;
+            }
+        },
+
+        async revokeTrust(device) {
+            const message = device.current
+                ? 'Stop trusting this device? It will be signed out.'
+                : `Stop trusting ${device.device}? Its sessions will be signed out.`;
+            if (!confirm(message)) {
+                return;
+            }
+
+            try {
+                const response = await fetch(`/api/v1/me/security/devices/${device.id}`, {
+                    method: 'DELETE',
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) throw new Error('Failed to stop trusting the device');
+                if (device.current) {
+                    window.location.href = '/login';
+                    return;
+                }
+                await this.load();
+            } catch (error) {
+                this.error = error.message;
+            }
+        },
+
+        formatDate(value) {
+            return value ? new Date(value).toLocaleString() : 'Never';
+        }
+    };
+}
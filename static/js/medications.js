@@ -44,7 +44,7 @@ document.addEventListener('DOMContentLoaded', function () {
     if (newMedForm) {
         newMedForm.addEventListener('submit', function (e) {
             e.preventDefault();
-            handleMedicationForm(this, '/api/medications', 'POST');
+            handleMedicationForm(this, '/api/v1/medications', 'POST');
         });
     }
 
@@ -62,7 +62,54 @@ document.addEventListener('DOMContentLoaded', function () {
         form.addEventListener('submit', function (e) {
             e.preventDefault();
             const medId = this.getAttribute('data-id');
-            handleMedicationForm(this, '/api/medications/' + medId, 'PUT');
+            handleMedicationForm(this, '/api/v1/medications/' + medId, 'PUT');
+        });
+    });
+
+    // --- Medication Catalog Typeahead ---
+    // Suggests normalized names as one is typed, and links the medication to
+    // the catalog entry when a suggestion is picked
+    const catalogList = document.getElementById('medication-catalog');
+    let catalogTimer = null;
+
+    document.querySelectorAll('[data-catalog-search]').forEach(input => {
+        const catalogInput = input.form.querySelector('input[name="catalog_id"]');
+
+        input.addEventListener('input', function () {
+            const picked = Array.from(catalogList.options).find(o => o.value === this.value);
+            catalogInput.value = picked ? picked.dataset.id : '';
+            if (picked) return;
+
+            clearTimeout(catalogTimer);
+            const q = this.value.trim();
+            if (q.length < 2) return;
+            catalogTimer = setTimeout(() => {
+                fetch('/api/v1/medications/search?q=' + encodeURIComponent(q))
+                    .then(response => response.ok ? response.json() : [])
+                    .then(entries => {
+                        catalogList.replaceChildren(...entries.map(entry => {
+                            const option = document.createElement('option');
+                            option.value = entry.display_name;
+                            option.dataset.id = entry.id;
+                            if (entry.brand_names) option.label = entry.brand_names;
+                            return option;
+                        }));
+                    })
+                    .catch(err => console.error('Error searching medications:', err));
+            }, 250);
+        });
+    });
+
+    // --- Dose Times ---
+    // Each dose a day has its own time input; add another after the last
+    document.querySelectorAll('[data-action="add-dose-time"]').forEach(btn => {
+        btn.addEventListener('click', function () {
+            const inputs = this.closest('[data-dose-times]').querySelectorAll('input[name="dose_time"]');
+            const input = document.createElement('input');
+            input.type = 'time';
+            input.name = 'dose_time';
+            inputs[inputs.length - 1].after(input);
+            input.focus();
         });
     });
 
@@ -78,10 +125,19 @@ document.addEventListener('DOMContentLoaded', function () {
             dosage: formData.get('dosage'),
             frequency_type: formData.get('frequency_type'),
             frequency_value: formData.get('frequency_value'),
-            reminder_time: formData.get('reminder_time'),
-            notes: formData.get('notes') || null
+            dose_times: formData.getAll('dose_time').filter(Boolean),
+            notes: formData.get('notes') || null,
+            catalog_id: Number(formData.get('catalog_id')) || 0
         };
 
+        // Only forms on accounts with item types offer an inventory link
+        if (formData.has('inventory_item_type')) {
+            data.inventory_item_type = formData.get('inventory_item_type');
+            if (data.inventory_item_type) {
+                data.units_per_dose = Number(formData.get('units_per_dose')) || 1;
+            }
+        }
+
         // For edit, we might need to explicitly set is_active if not present, 
         // but the Go backend might handle partial updates or we send what's needed.
         // The original inline JS sent `is_active: true` for edits, let's keep that consistency if needed,
@@ -114,7 +170,7 @@ document.addEventListener('DOMContentLoaded', function () {
                 if (response.ok) {
                     window.location.reload();
                 } else {
-                    return response.text().then(text => {
+                    return responseErrorText(response).then(text => {
                         console.error('Error:', text);
                         alert('Error: ' + text);
                         btn.disabled = false;
@@ -138,7 +194,7 @@ document.addEventListener('DOMContentLoaded', function () {
             // Wait, logic in HTML was: `if .TakenToday` then button says "Mark Missed" and sets taken=false.
             // So data-taken should be the *value to send*.
 
-            fetch('/api/medications/' + medId + '/log', {
+            fetch('/api/v1/medications/' + medId + '/log', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
@@ -186,11 +242,12 @@ document.addEventListener('DOMContentLoaded', function () {
             const editModal = document.getElementById('edit-medication-' + currentMedId);
             if (editModal && editModal.open) editModal.close();
 
-            fetch('/api/medications/' + currentMedId, {
+            fetch('/api/v1/medications/' + currentMedId, {
                 method: 'PUT',
                 headers: {
                     'Content-Type': 'application/json',
-                    'X-CSRF-Token': getCSRFToken()
+                    'X-CSRF-Token': getCSRFToken(),
+                    ...concurrencyHeaders()
                 },
                 body: JSON.stringify({ is_active: false })
             })
@@ -211,11 +268,12 @@ document.addEventListener('DOMContentLoaded', function () {
             const dosage = this.getAttribute('data-dosage');
             const frequency = this.getAttribute('data-frequency');
 
-            fetch('/api/medications/' + medId, {
+            fetch('/api/v1/medications/' + medId, {
                 method: 'PUT',
                 headers: {
                     'Content-Type': 'application/json',
-                    'X-CSRF-Token': getCSRFToken()
+                    'X-CSRF-Token': getCSRFToken(),
+                    ...concurrencyHeaders()
                 },
                 body: JSON.stringify({
                     is_active: true,
@@ -255,7 +313,7 @@ document.addEventListener('DOMContentLoaded', function () {
             const editModal = document.getElementById('edit-medication-' + currentMedId);
             if (editModal && editModal.open) editModal.close();
 
-            fetch('/api/medications/' + currentMedId, {
+            fetch('/api/v1/medications/' + currentMedId, {
                 method: 'DELETE',
                 headers: { 'X-CSRF-Token': getCSRFToken() }
             })
@@ -0,0 +1,230 @@
+// Saved Reports Alpine.js Component
+//
+// Builds a one-section report in the browser; the API takes up to six
+// sections, each over a different entity.
+function savedReports() {
+    return {
+        reports: [],
+        entities: [],
+        loading: true,
+        saving: false,
+        feedback: '',
+        preview: null,
+        editingID: 0,
+        form: {},
+
+        init() {
+            this.reset();
+            this.loadEntities();
+            this.loadReports();
+        },
+
+        csrfToken() {
+            return document.querySelector('meta[name=csrf-token]').content;
+        },
+
+        reset() {
+            this.editingID = 0;
+            this.preview = null;
+            this.form = {
+                name: '',
+                entity: 'injections',
+                columns: [],
+                aggregates: {},
+                groupBy: '',
+                mode: 'last_days',
+                days: 30,
+                start: '',
+                end: '',
+                filterField: '',
+                filterOp: 'eq',
+                filterValue: ''
+            };
+        },
+
+        entity() {
+            return this.entities.find(e => e.name === this.form.entity) || { fields: [] };
+        },
+
+        field(name) {
+            return this.entity().fields.find(f => f.name === name);
+        },
+
+        // The aggregates a field can be summarized with, as the API allows
+        aggregatesFor(name) {
+            const kind = (this.field(name) || {}).kind;
+            if (kind === 'number') return ['avg', 'sum', 'min', 'max', 'count'];
+            if (kind === 'time' || kind === 'date') return ['min', 'max', 'count'];
+            return ['count'];
+        },
+
+        opsFor(name) {
+            const kind = (this.field(name) || {}).kind;
+            if (kind === 'number') return ['eq', 'ne', 'lt', 'lte', 'gt', 'gte'];
+            if (kind === 'bool') return ['eq', 'ne'];
+            return ['eq', 'ne', 'contains'];
+        },
+
+        filterableFields() {
+            return this.entity().fields.filter(f => f.kind !== 'time' && f.kind !== 'date');
+        },
+
+        async loadEntities() {
+            try {
+                const response = await fetch('/api/v1/reports/fields');
+                if (!response.ok) throw new Error('Failed to load report fields');
+                this.entities = await response.json();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
+        },
+
+        async loadReports() {
+            try {
+                const response = await fetch('/api/v1/reports');
+                if (!response.ok) throw new Error('Failed to load saved reports');
+                this.reports = await response.json();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            } finally {
+                this.loading = false;
+            }
+        },
+
+        // definition turns the form into a report definition
+        definition() {
+            const section = { entity: this.form.entity, columns: [] };
+            if (this.form.groupBy) {
+                section.group_by = this.form.groupBy;
+                section.columns.push({ aggregate: 'count' });
+            }
+            this.form.columns.forEach(name => {
+                const column = { field: name };
+                if (this.form.groupBy) {
+                    column.aggregate = this.form.aggregates[name] || this.aggregatesFor(name)[0];
+                }
+                section.columns.push(column);
+            });
+            if (this.form.filterField) {
+                section.filters = [{ field: this.form.filterField, op: this.form.filterOp, value: String(this.form.filterValue) }];
+            }
+
+            const range = { mode: this.form.mode };
+            if (this.form.mode === 'last_days') range.days = Number(this.form.days);
+            if (this.form.mode === 'custom') {
+                range.start = this.form.start;
+                range.end = this.form.end;
+            }
+            return { date_range: range, sections: [section] };
+        },
+
+        async runPreview() {
+            this.feedback = '';
+            try {
+                const response = await fetch('/api/v1/reports/preview', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': this.csrfToken() },
+                    body: JSON.stringify(this.definition())
+                });
+                if (!response.ok) {
+                    const error = await responseErrorText(response);
+                    throw new Error(error || 'Failed to run report');
+                }
+                this.preview = await response.json();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
+        },
+
+        async save() {
+            this.saving = true;
+            this.feedback = '';
+            const url = this.editingID ? `/api/v1/reports/${this.editingID}` : '/api/v1/reports';
+            try {
+                const response = await fetch(url, {
+                    method: this.editingID ? 'PUT' : 'POST',
+                    headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': this.csrfToken() },
+                    body: JSON.stringify({ name: this.form.name, definition: this.definition() })
+                });
+                if (!response.ok) {
+                    const error = await responseErrorText(response);
+                    throw new Error(error || 'Failed to save report');
+                }
+                this.feedback = '<div class="alert-success">Report saved</div>';
+                this.reset();
+                await this.loadReports();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            } finally {
+                this.saving = false;
+            }
+        },
+
+        // edit loads a saved report back into the form. Only its first
+        // section can be edited here.
+        edit(report) {
+            const def = report.definition;
+            const section = def.sections[0];
+            this.reset();
+            this.editingID = report.id;
+            this.form.name = report.name;
+            this.form.entity = section.entity;
+            this.form.groupBy = section.group_by || '';
+            (section.columns || []).filter(c => c.field).forEach(c => {
+                this.form.columns.push(c.field);
+                if (c.aggregate) this.form.aggregates[c.field] = c.aggregate;
+            });
+            const filter = (section.filters || [])[0];
+            if (filter) {
+                this.form.filterField = filter.field;
+                this.form.filterOp = filter.op;
+                this.form.filterValue = filter.value;
+            }
+            this.form.mode = def.date_range.mode;
+            this.form.days = def.date_range.days || 30;
+            this.form.start = def.date_range.start || '';
+            this.form.end = def.date_range.end || '';
+        },
+
+        async remove(report) {
+            if (!confirm(`Delete "${report.name}"? Report emails that attach it will carry on without it.`)) {
+                return;
+            }
+            try {
+                const response = await fetch(`/api/v1/reports/${report.id}`, {
+                    method: 'DELETE',
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) {
+                    const error = await responseErrorText(response);
+                    throw new Error(error || 'Failed to delete report');
+                }
+                if (this.editingID === report.id) this.reset();
+                await this.loadReports();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
+        },
+
+        async view(report) {
+            this.feedback = '';
+            try {
+                const response = await fetch(`/api/v1/reports/${report.id}/run`);
+                if (!response.ok) {
+                    const error = await responseErrorText(response);
+                    throw new Error(error || 'Failed to run report');
+                }
+                this.preview = await response.json();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
+        },
+
+        formatCell(value) {
+            if (value === null || value === undefined) return '';
+            if (typeof value === 'boolean') return value ? 'Yes' : 'No';
+            if (typeof value === 'number') return String(Math.round(value * 100) / 100);
+            return value;
+        }
+    };
+}
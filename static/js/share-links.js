@@ -0,0 +1,131 @@
+// Caregiver Share Links Alpine.js Component
+function shareLinks() {
+    return {
+        links: [],
+        loading: true,
+        creating: false,
+        name: '',
+        scopes: ['injections', 'adherence', 'schedule'],
+        expiresInDays: 7,
+        feedback: '',
+        shareURL: '',
+        copied: false,
+        accesses: {},
+        currentUserID: 0,
+        currentUserRole: '',
+
+        init() {
+            this.loadLinks();
+        },
+
+        csrfToken() {
+            return document.querySelector('meta[name=csrf-token]').content;
+        },
+
+        async loadLinks() {
+            try {
+                const response = await fetch('/api/v1/share-links', {
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) throw new Error('Failed to load share links');
+                this.links = await response.json();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            } finally {
+                this.loading = false;
+            }
+        },
+
+        async createLink() {
+            this.creating = true;
+            this.feedback = '';
+            this.shareURL = '';
+
+            try {
+                const response = await fetch('/api/v1/share-links', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                        'X-CSRF-Token': this.csrfToken()
+                    },
+                    body: JSON.stringify({
+                        name: this.name,
+                        scopes: this.scopes,
+                        expires_in_days: Number(this.expiresInDays)
+                    })
+                });
+                if (!response.ok) {
+                    const error = await responseErrorText(response);
+                    throw new Error(error || 'Failed to create share link');
+                }
+
+                const data = await response.json();
+                this.shareURL = data.url;
+                this.name = '';
+                await this.loadLinks();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            } finally {
+                this.creating = false;
+            }
+        },
+
+        canRevoke(link) {
+            return link.created_by === this.currentUserID || this.currentUserRole === 'owner';
+        },
+
+        async revokeLink(link) {
+            if (!confirm(`Revoke "${link.name}"? Anyone using it will lose access straight away.`)) {
+                return;
+            }
+
+            try {
+                const response = await fetch(`/api/v1/share-links/${link.id}`, {
+                    method: 'DELETE',
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) {
+                    const error = await responseErrorText(response);
+                    throw new Error(error || 'Failed to revoke share link');
+                }
+                this.shareURL = '';
+                await this.loadLinks();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
+        },
+
+        async toggleAccesses(link) {
+            if (this.accesses[link.id]) {
+                delete this.accesses[link.id];
+                return;
+            }
+
+            try {
+                const response = await fetch(`/api/v1/share-links/${link.id}/accesses`, {
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) throw new Error('Failed to load views');
+                this.accesses[link.id] = await response.json();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
+        },
+
+        copyShareURL() {
+            navigator.clipboard.writeText(this.shareURL);
+            this.copied = true;
+            setTimeout(() => {
+                this.copied = false;
+            }, 2000);
+        },
+
+        formatDate(value) {
+            return value ? new Date(value).toLocaleDateString() : 'Never';
+        },
+
+        formatDateTime(value) {
+            return new Date(value).toLocaleString();
+        }
+    };
+}
@@ -17,7 +17,7 @@ class ThemeManager {
 
         if (csrfToken) {
             try {
-                const response = await fetch('/api/settings');
+                const response = await fetch('/api/v1/settings');
                 if (response.ok) {
                     const settings = await response.json();
                     if (settings.theme) {
@@ -68,16 +68,15 @@ class ThemeManager {
         if (saveToBackend) {
             const csrfToken = document.querySelector('meta[name="csrf-token"]')?.content;
             if (csrfToken) {
-                fetch('/api/settings/app', {
-                    method: 'POST',
+                // Only the theme changes, whatever else was saved meanwhile
+                fetch('/api/v1/me/preferences', {
+                    method: 'PUT',
                     headers: {
                         'Content-Type': 'application/json',
-                        'X-CSRF-Token': csrfToken
+                        'X-CSRF-Token': csrfToken,
+                        'If-Match': '*'
                     },
-                    body: JSON.stringify({
-                        theme: theme,
-                        advanced_mode: false // Will be overwritten by actual value from settings page
-                    })
+                    body: JSON.stringify({ theme: theme })
                 }).catch(err => console.error('Failed to save theme:', err));
             }
         }
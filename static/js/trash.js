@@ -0,0 +1,104 @@
+// Trash Alpine.js Component
+function trash() {
+    return {
+        items: [],
+        loading: true,
+        feedback: '',
+        currentUserID: 0,
+        currentUserRole: '',
+        typeLabels: {
+            course: 'Course',
+            symptom_log: 'Symptom log',
+            medication: 'Medication',
+            journal_entry: 'Journal entry'
+        },
+
+        init() {
+            this.loadItems();
+        },
+
+        csrfToken() {
+            return document.querySelector('meta[name=csrf-token]').content;
+        },
+
+        async loadItems() {
+            try {
+                const response = await fetch('/api/v1/trash', {
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) throw new Error('Failed to load the trash');
+                this.items = await response.json();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            } finally {
+                this.loading = false;
+            }
+        },
+
+        async restore(item) {
+            this.feedback = '';
+            try {
+                const response = await fetch(`/api/v1/trash/${item.id}/restore`, {
+                    method: 'POST',
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) {
+                    const error = await responseErrorText(response);
+                    throw new Error(error || 'Failed to restore');
+                }
+                this.feedback = `<div class="alert-success">Restored ${this.typeLabels[item.item_type].toLowerCase()}</div>`;
+                await this.loadItems();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
+        },
+
+        canPurge(item) {
+            return item.deleted_by === this.currentUserID || this.currentUserRole === 'owner';
+        },
+
+        async purge(item) {
+            if (!confirm(`Delete "${item.label}" for good? This can't be undone.`)) {
+                return;
+            }
+
+            try {
+                const response = await fetch(`/api/v1/trash/${item.id}`, {
+                    method: 'DELETE',
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) {
+                    const error = await responseErrorText(response);
+                    throw new Error(error || 'Failed to delete');
+                }
+                await this.loadItems();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
+        },
+
+        async emptyTrash() {
+            if (!confirm('Empty the trash? Everything in it will be deleted for good.')) {
+                return;
+            }
+
+            try {
+                const response = await fetch('/api/v1/trash', {
+                    method: 'DELETE',
+                    headers: { 'X-CSRF-Token': this.csrfToken() }
+                });
+                if (!response.ok) {
+                    const error = await responseErrorText(response);
+                    throw new Error(error || 'Failed to empty the trash');
+                }
+                await this.loadItems();
+            } catch (error) {
+                this.feedback = `<div class="alert-danger">Error: ${error.message}</div>`;
+            }
+        },
+
+        formatDate(value) {
+            return new Date(value).toLocaleDateString();
+        }
+    };
+}
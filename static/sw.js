@@ -1,7 +1,7 @@
 // Service Worker for Injection Tracker PWA
 // Version: 1.0.0 - Update this when deploying changes
 
-const CACHE_VERSION = '1.0.0';
+const CACHE_VERSION = '1.0.5';
 const CACHE_NAME = `injection-tracker-v${CACHE_VERSION}`;
 const RUNTIME_CACHE = `injection-tracker-runtime-v${CACHE_VERSION}`;
 const API_CACHE = `injection-tracker-api-v${CACHE_VERSION}`;
@@ -95,8 +95,14 @@ self.addEventListener('fetch', (event) => {
     const { request } = event;
     const url = new URL(request.url);
 
-    // Skip non-GET requests
+    // Changes that can be synced later are queued when the network is down;
+    // anything else that isn't a GET goes straight to the network
     if (request.method !== 'GET') {
+        if (url.origin === location.origin && isSyncable(request.method, url.pathname)) {
+            event.respondWith(
+                fetch(request.clone()).catch(() => queueOperation(request, url.pathname))
+            );
+        }
         return;
     }
 
@@ -105,6 +111,11 @@ self.addEventListener('fetch', (event) => {
         return;
     }
 
+    // Never cache event streams
+    if (request.headers.get('Accept') === 'text/event-stream') {
+        return;
+    }
+
     // API GET requests - network first with timed cache fallback
     if (url.pathname.startsWith('/api/')) {
         event.respondWith(
@@ -133,7 +144,7 @@ self.addEventListener('fetch', (event) => {
                                 return caches.match('/offline.html');
                             }
                             return new Response(
-                                JSON.stringify({ error: 'Offline', cached: false }),
+                                JSON.stringify({ error: { code: 'service_unavailable', message: 'Offline' }, cached: false }),
                                 { status: 503, headers: { 'Content-Type': 'application/json' } }
                             );
                         });
@@ -142,8 +153,14 @@ self.addEventListener('fetch', (event) => {
         return;
     }
 
-    // Static assets - cache first, fallback to network
-    if (url.origin === location.origin || STATIC_ASSETS.includes(request.url)) {
+    // htmx page fragments are always fetched live
+    if (request.headers.get('HX-Request')) {
+        return;
+    }
+
+    // Static assets - cache first, fallback to network. Pages fall through
+    // to network first so they are never stale while online.
+    if (url.pathname.startsWith('/static/') || STATIC_ASSETS.includes(request.url)) {
         event.respondWith(
             caches.match(request)
                 .then((cachedResponse) => {
@@ -183,82 +200,142 @@ self.addEventListener('fetch', (event) => {
     );
 });
 
-// Background sync for offline submissions
-self.addEventListener('sync', (event) => {
-    console.log('[SW] Background sync triggered:', event.tag);
-    if (event.tag === 'sync-injections') {
-        event.waitUntil(syncInjections());
-    } else if (event.tag === 'sync-symptoms') {
-        event.waitUntil(syncSymptoms());
-    } else if (event.tag === 'sync-medications') {
-        event.waitUntil(syncMedications());
-    }
-});
+// Offline sync queue. Changes made while offline are stored in IndexedDB
+// with a client-generated UUID and sent in order to /api/v1/sync once the
+// network is back. The server remembers applied UUIDs, so a batch that is
+// sent twice is only applied once.
+const SYNC_TAG = 'sync-queue';
 
-async function syncInjections() {
-    console.log('[SW] Syncing offline injections...');
-    try {
-        // Open IndexedDB and get pending injections
-        const db = await openDB();
-        const tx = db.transaction('pending_injections', 'readonly');
-        const store = tx.objectStore('pending_injections');
-        const pending = await store.getAll();
-
-        // Sync each pending injection
-        for (const injection of pending) {
-            try {
-                const response = await fetch('/api/injections', {
-                    method: 'POST',
-                    headers: { 'Content-Type': 'application/json' },
-                    body: JSON.stringify(injection.data)
-                });
+// Requests the sync endpoint accepts; keep in step with syncRouter in
+// internal/handlers/sync_handlers.go
+const SYNCABLE_OPERATIONS = [
+    ['POST', /^\/api\/(v1\/)?injections$/],
+    ['PUT', /^\/api\/(v1\/)?injections\/\d+$/],
+    ['DELETE', /^\/api\/(v1\/)?injections\/\d+$/],
+    ['POST', /^\/api\/(v1\/)?injections\/\d+\/restore$/],
+    ['POST', /^\/api\/(v1\/)?symptoms$/],
+    ['PUT', /^\/api\/(v1\/)?symptoms\/\d+$/],
+    ['DELETE', /^\/api\/(v1\/)?symptoms\/\d+$/],
+    ['POST', /^\/api\/(v1\/)?medications\/\d+\/log$/],
+    ['POST', /^\/api\/(v1\/)?inventory\/[^/]+\/adjust$/]
+];
 
-                if (response.ok) {
-                    // Remove from pending queue
-                    const deleteTx = db.transaction('pending_injections', 'readwrite');
-                    await deleteTx.objectStore('pending_injections').delete(injection.id);
-                    console.log('[SW] Synced injection:', injection.id);
-                }
-            } catch (err) {
-                console.error('[SW] Failed to sync injection:', err);
-            }
+function isSyncable(method, path) {
+    return SYNCABLE_OPERATIONS.some(([m, pattern]) => m === method && pattern.test(path));
+}
+
+// queueOperation stores a request that failed for lack of network and
+// answers it with 202 so the page can carry on
+async function queueOperation(request, path) {
+    const text = await request.text();
+    let body = null;
+    if (text) {
+        try {
+            body = JSON.parse(text);
+        } catch (err) {
+            return new Response(
+                JSON.stringify({ error: { code: 'service_unavailable', message: 'Offline' } }),
+                { status: 503, headers: { 'Content-Type': 'application/json' } }
+            );
         }
-    } catch (err) {
-        console.error('[SW] Sync failed:', err);
     }
-}
 
-async function syncSymptoms() {
-    console.log('[SW] Syncing offline symptoms...');
-    // Similar implementation to syncInjections
+    const operation = { id: self.crypto.randomUUID(), method: request.method, path, body };
+    // Edits keep the version the page loaded, so the server can tell if
+    // someone else changed the record before this reaches it
+    const since = request.headers.get('If-Unmodified-Since');
+    if (since && !Number.isNaN(Date.parse(since))) {
+        operation.base_updated_at = new Date(since).toISOString();
+    }
+    const db = await openDB();
+    await idbRequest(db.transaction('sync_queue', 'readwrite').objectStore('sync_queue').add(operation));
+
+    if (self.registration.sync) {
+        self.registration.sync.register(SYNC_TAG).catch(() => {});
+    }
+    return new Response(
+        JSON.stringify({ queued: true, id: operation.id }),
+        { status: 202, headers: { 'Content-Type': 'application/json' } }
+    );
 }
 
-async function syncMedications() {
-    console.log('[SW] Syncing offline medications...');
-    // Similar implementation to syncInjections
+// syncQueue sends queued operations and drops the ones the server has dealt
+// with. Operations another request is still applying stay queued.
+async function syncQueue() {
+    const db = await openDB();
+    const queued = await idbRequest(db.transaction('sync_queue', 'readonly').objectStore('sync_queue').getAll());
+    if (queued.length === 0) {
+        return;
+    }
+
+    const tokenResponse = await fetch('/api/v1/csrf-token', { credentials: 'same-origin' });
+    if (!tokenResponse.ok) {
+        throw new Error('Could not get a CSRF token');
+    }
+    const { csrf_token: csrfToken } = await tokenResponse.json();
+
+    // The server takes at most 100 operations per request
+    const batch = queued.slice(0, 100);
+    const response = await fetch('/api/v1/sync', {
+        method: 'POST',
+        credentials: 'same-origin',
+        headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': csrfToken },
+        body: JSON.stringify({
+            operations: batch.map(({ id, method, path, body }) => ({ id, method, path, body }))
+        })
+    });
+    if (!response.ok) {
+        throw new Error('Sync failed with status ' + response.status);
+    }
+    const { results } = await response.json();
+
+    const done = results.filter((result) => result.status !== 'in_progress');
+    const tx = db.transaction('sync_queue', 'readwrite');
+    const store = tx.objectStore('sync_queue');
+    for (const result of done) {
+        const operation = batch.find((op) => op.id === result.id);
+        if (operation) {
+            store.delete(operation.seq);
+        }
+    }
+
+    const clients = await self.clients.matchAll();
+    clients.forEach((client) => client.postMessage({ type: 'SYNC_COMPLETE', results }));
+
+    if (queued.length > batch.length && done.length > 0) {
+        await syncQueue();
+    }
 }
 
-// IndexedDB helper
-function openDB() {
-    return new Promise((resolve, reject) => {
-        const request = indexedDB.open('InjectionTrackerDB', 1);
+self.addEventListener('sync', (event) => {
+    if (event.tag === SYNC_TAG) {
+        event.waitUntil(syncQueue());
+    }
+});
 
-        request.onerror = () => reject(request.error);
+// IndexedDB helpers
+function idbRequest(request) {
+    return new Promise((resolve, reject) => {
         request.onsuccess = () => resolve(request.result);
+        request.onerror = () => reject(request.error);
+    });
+}
 
-        request.onupgradeneeded = (event) => {
-            const db = event.target.result;
-            if (!db.objectStoreNames.contains('pending_injections')) {
-                db.createObjectStore('pending_injections', { keyPath: 'id', autoIncrement: true });
-            }
-            if (!db.objectStoreNames.contains('pending_symptoms')) {
-                db.createObjectStore('pending_symptoms', { keyPath: 'id', autoIncrement: true });
-            }
-            if (!db.objectStoreNames.contains('pending_medications')) {
-                db.createObjectStore('pending_medications', { keyPath: 'id', autoIncrement: true });
+function openDB() {
+    const request = indexedDB.open('InjectionTrackerDB', 2);
+    request.onupgradeneeded = () => {
+        const db = request.result;
+        // Per-type stores from before the sync queue were never written to
+        ['pending_injections', 'pending_symptoms', 'pending_medications'].forEach((name) => {
+            if (db.objectStoreNames.contains(name)) {
+                db.deleteObjectStore(name);
             }
-        };
-    });
+        });
+        if (!db.objectStoreNames.contains('sync_queue')) {
+            db.createObjectStore('sync_queue', { keyPath: 'seq', autoIncrement: true });
+        }
+    };
+    return idbRequest(request);
 }
 
 // Push notifications
@@ -311,6 +388,11 @@ self.addEventListener('message', (event) => {
         self.skipWaiting();
     }
 
+    // Browsers without background sync ask when they come back online
+    if (event.data && event.data.type === 'SYNC_NOW') {
+        event.waitUntil(syncQueue().catch((err) => console.error('[SW] Sync failed:', err)));
+    }
+
     if (event.data && event.data.type === 'CLEAR_CACHE') {
         event.waitUntil(
             caches.keys().then((cacheNames) => {